@@ -426,6 +426,29 @@ func (m *MarketplaceFacadeService) GetMarketplaceCategories() ([]PackCategory, e
 	return result.Categories, nil
 }
 
+// defaultMaxPackUploadSize is the upload limit assumed for servers that do
+// not report one.
+const defaultMaxPackUploadSize = 500 * 1024 * 1024
+
+// maxPackUploadSize asks the marketplace server for its pack upload limit so
+// oversized files are rejected before uploading them.
+func (m *MarketplaceFacadeService) maxPackUploadSize() int64 {
+	mc := m.marketplaceClient
+	resp, err := mc.client.Get(mc.ServerURL + "/api/packs/upload-limits")
+	if err != nil {
+		return defaultMaxPackUploadSize
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		MaxPackSize int64 `json:"max_pack_size"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&result) != nil || result.MaxPackSize <= 0 {
+		return defaultMaxPackUploadSize
+	}
+	return result.MaxPackSize
+}
+
 // SharePackToMarketplace uploads a .qap file to the marketplace server.
 func (m *MarketplaceFacadeService) SharePackToMarketplace(packFilePath string, categoryID int64, pricingModel string, creditsPrice int, detailedDescription string) error {
 	m.ensureMarketplaceClient()
@@ -439,7 +462,7 @@ func (m *MarketplaceFacadeService) SharePackToMarketplace(packFilePath string, c
 	if err != nil {
 		return fmt.Errorf("failed to access pack file: %w", err)
 	}
-	const maxUploadSize = 500 * 1024 * 1024
+	maxUploadSize := m.maxPackUploadSize()
 	if fileInfo.Size() > maxUploadSize {
 		return fmt.Errorf("pack file too large (%dMB), maximum is %dMB", fileInfo.Size()/1024/1024, maxUploadSize/1024/1024)
 	}
@@ -645,7 +668,7 @@ func (m *MarketplaceFacadeService) ReplaceMarketplacePack(localPackPath string,
 	if err != nil {
 		return fmt.Errorf("failed to access pack file: %w", err)
 	}
-	const maxUploadSize = 500 * 1024 * 1024
+	maxUploadSize := m.maxPackUploadSize()
 	if fileInfo.Size() > maxUploadSize {
 		return fmt.Errorf("pack file too large (%dMB), maximum is %dMB", fileInfo.Size()/1024/1024, maxUploadSize/1024/1024)
 	}
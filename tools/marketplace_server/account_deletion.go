@@ -0,0 +1,234 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"marketplace_server/i18n"
+	"marketplace_server/templates"
+)
+
+// accountDeletionPhrase must be typed into the confirmation field before an
+// account is deleted.
+const accountDeletionPhrase = "DELETE"
+
+// accountDeletionBlocker returns the i18n key of the reason userID cannot be
+// deleted right now, or "" if nothing blocks it.
+func accountDeletionBlocker(userID int64) string {
+	var pending int
+	db.QueryRow("SELECT COUNT(*) FROM withdrawal_records WHERE user_id = ? AND status = 'pending'", userID).Scan(&pending)
+	if pending > 0 {
+		return "acct_delete_pending"
+	}
+	return ""
+}
+
+// accountWalletShared reports whether another live account is bound to email,
+// in which case the email wallet belongs to it too and must be kept.
+func accountWalletShared(userID int64, email string) bool {
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM users WHERE email = ? AND id != ? AND deleted_at IS NULL", email, userID).Scan(&n)
+	return n > 0
+}
+
+// deleteUserAccount anonymizes userID. Personal data is erased, the storefront
+// and packs are taken down, and financial records (credits transactions,
+// withdrawals, orders) are kept with their amounts but without personal
+// details. When this is the last account on its email, the email wallet is
+// removed and any remaining balance is written off in the ledger.
+func deleteUserAccount(userID int64, email string, shared bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if email != "" && !shared {
+		var balance float64
+		tx.QueryRow("SELECT COALESCE(credits_balance, 0) FROM email_wallets WHERE email = ?", email).Scan(&balance)
+		if balance != 0 {
+			if _, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, description)
+				VALUES (?, 'account_deletion', ?, 'Balance forfeited on account deletion')`, userID, -balance); err != nil {
+				return fmt.Errorf("write off balance: %w", err)
+			}
+		}
+		for _, q := range []string{
+			"DELETE FROM email_wallets WHERE email = ?",
+			"DELETE FROM password_reset_tokens WHERE email = ?",
+			"DELETE FROM email_verification_tokens WHERE email = ?",
+			"DELETE FROM storefront_email_suppressions WHERE email = ?",
+		} {
+			if _, err := tx.Exec(q, email); err != nil {
+				return fmt.Errorf("%s: %w", q, err)
+			}
+		}
+	}
+
+	anonName := fmt.Sprintf("Deleted user #%d", userID)
+	if _, err := tx.Exec(`UPDATE users SET display_name = ?, email = NULL, username = NULL, password_hash = NULL,
+		auth_id = ?, credits_balance = 0, is_blocked = 1, email_allowed = 0, preferred_lang = '', kyc_status = 'none',
+		deleted_at = CURRENT_TIMESTAMP WHERE id = ?`, anonName, fmt.Sprintf("deleted:%d", userID), userID); err != nil {
+		return fmt.Errorf("anonymize user: %w", err)
+	}
+
+	var storefrontID int64
+	tx.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID)
+	if storefrontID != 0 {
+		if _, err := tx.Exec(`UPDATE author_storefronts SET store_status = ?, store_name = '', store_slug = ?,
+			description = '', logo_data = NULL, logo_thumb_data = NULL, logo_content_type = '',
+			store_announcement = '', announcement_active = 0, contact = '', updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`, storeStatusDeleted, fmt.Sprintf("deleted-%d", storefrontID), storefrontID); err != nil {
+			return fmt.Errorf("close storefront: %w", err)
+		}
+		for _, q := range []string{
+			"DELETE FROM storefront_slug_history WHERE storefront_id = ?",
+			"DELETE FROM featured_storefronts WHERE storefront_id = ?",
+			"DELETE FROM storefront_followers WHERE storefront_id = ?",
+		} {
+			if _, err := tx.Exec(q, storefrontID); err != nil {
+				return fmt.Errorf("%s: %w", q, err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE pack_listings SET author_name = ?,
+		status = CASE WHEN status IN ('published', 'pending', 'quarantined') THEN 'delisted' ELSE status END
+		WHERE user_id = ?`, anonName, userID); err != nil {
+		return fmt.Errorf("delist packs: %w", err)
+	}
+	for _, q := range []string{
+		"DELETE FROM user_payment_info WHERE user_id = ?",
+		"DELETE FROM account_emails WHERE user_id = ?",
+		"DELETE FROM kyc_submissions WHERE user_id = ?",
+		"DELETE FROM cart_items WHERE user_id = ?",
+		"DELETE FROM user_wishlist WHERE user_id = ?",
+		"DELETE FROM user_recently_viewed WHERE user_id = ?",
+		"DELETE FROM storefront_followers WHERE user_id = ?",
+		"DELETE FROM notification_reads WHERE user_id = ?",
+		"DELETE FROM downloads_tokens WHERE user_id = ?",
+		"DELETE FROM api_keys WHERE owner_type = 'user' AND owner_id = ?",
+		"UPDATE withdrawal_records SET payment_details = '{}', display_name = '' WHERE user_id = ?",
+		"UPDATE custom_product_orders SET license_email = '', recipient_email = '' WHERE user_id = ?",
+		"UPDATE credits_transactions SET ip_address = '' WHERE user_id = ?",
+		"UPDATE user_downloads SET ip_address = '' WHERE user_id = ?",
+		"UPDATE user_data_exports SET ip_address = '' WHERE user_id = ?",
+	} {
+		if _, err := tx.Exec(q, userID); err != nil {
+			return fmt.Errorf("%s: %w", q, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// revokeUserSessions ends every session of userID. Unlike
+// revokeEmailUserSessions it leaves other accounts on the same email alone.
+func revokeUserSessions(userID int64) {
+	userSessionsMu.Lock()
+	for sid, entry := range userSessions {
+		if entry.UserID == userID {
+			delete(userSessions, sid)
+		}
+	}
+	userSessionsMu.Unlock()
+}
+
+// handleUserDeleteAccount handles GET/POST /user/delete-account. Deletion
+// requires the account password and the confirmation phrase; if the email
+// wallet still holds credits and no other account shares it, the user must
+// also agree to forfeit them.
+func handleUserDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	lang := i18n.DetectLang(r)
+
+	var email string
+	var publicID, slug string
+	if err := db.QueryRow("SELECT COALESCE(email, '') FROM users WHERE id = ?", userID).Scan(&email); err != nil {
+		http.Error(w, i18n.T(lang, "load_failed"), http.StatusInternalServerError)
+		return
+	}
+	var walletPwHash sql.NullString
+	var balance float64
+	db.QueryRow("SELECT password_hash, COALESCE(credits_balance, 0) FROM email_wallets WHERE email = ?", email).Scan(&walletPwHash, &balance)
+	if email == "" || !walletPwHash.Valid || walletPwHash.String == "" {
+		// Deletion is confirmed with the password, so one must exist first.
+		http.Redirect(w, r, "/user/set-password", http.StatusFound)
+		return
+	}
+	shared := accountWalletShared(userID, email)
+
+	renderForm := func(errMsg string, deleted bool) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := i18n.TemplateData(r)
+		i18n.MergeTemplateData(data, map[string]interface{}{
+			"Email":         email,
+			"Error":         errMsg,
+			"Deleted":       deleted,
+			"Balance":       balance,
+			"WalletShared":  shared,
+			"ConfirmPhrase": accountDeletionPhrase,
+		})
+		if err := templates.UserDeleteAccountTmpl.Execute(w, data); err != nil {
+			log.Printf("[DELETE-ACCOUNT] template execute error: %v", err)
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		renderForm("", false)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkPassword(r.FormValue("password"), walletPwHash.String) {
+		renderForm(i18n.T(lang, "invalid_old_password"), false)
+		return
+	}
+	if strings.TrimSpace(r.FormValue("confirm")) != accountDeletionPhrase {
+		renderForm(fmt.Sprintf(i18n.T(lang, "acct_delete_phrase"), accountDeletionPhrase), false)
+		return
+	}
+	if !shared && balance > 0 && r.FormValue("forfeit_balance") != "1" {
+		renderForm(i18n.T(lang, "acct_delete_forfeit"), false)
+		return
+	}
+	if key := accountDeletionBlocker(userID); key != "" {
+		renderForm(i18n.T(lang, key), false)
+		return
+	}
+
+	db.QueryRow("SELECT COALESCE(public_id, ''), store_slug FROM author_storefronts WHERE user_id = ?", userID).Scan(&publicID, &slug)
+	if err := deleteUserAccount(userID, email, shared); err != nil {
+		log.Printf("[DELETE-ACCOUNT] failed to delete user %d: %v", userID, err)
+		renderForm(i18n.T(lang, "acct_delete_failed"), false)
+		return
+	}
+
+	revokeUserSessions(userID)
+	http.SetCookie(w, makeSessionCookie("user_session", "", -1))
+	if slug != "" {
+		globalCache.InvalidateStorefront(slug)
+		if publicID != "" {
+			globalCache.InvalidateStorefront(publicID)
+		}
+	}
+	globalCache.InvalidateHomepage()
+	globalCache.InvalidateUserPurchased(userID)
+
+	detail := fmt.Sprintf("wallet_shared=%t forfeited=%.2f", shared, balance)
+	if shared {
+		detail = "wallet_shared=true"
+	}
+	recordAuditLog(0, "account_delete", fmt.Sprintf("user:%d", userID), detail, getClientIP(r))
+	log.Printf("[DELETE-ACCOUNT] user %d deleted their account (%s)", userID, detail)
+	renderForm("", true)
+}
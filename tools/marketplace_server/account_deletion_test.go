@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postDeleteAccount(userID string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/user/delete-account", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-User-ID", userID)
+	rec := httptest.NewRecorder()
+	handleUserDeleteAccount(rec, req)
+	return rec
+}
+
+func TestUserDeleteAccount(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, username) VALUES (1, 'email', 'alice@example.com', 'Alice', 'alice@example.com', 'alice')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance, password_hash) VALUES ('alice@example.com', 25, ?)", hashPassword("correct-pw"))
+	mustExec(t, "INSERT INTO user_payment_info (user_id, payment_type, payment_details) VALUES (1, 'paypal', '{\"email\":\"alice@pay.example\"}')")
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, ip_address) VALUES (1, 'purchase', -10, '10.0.0.1')")
+	mustExec(t, "INSERT INTO withdrawal_records (user_id, credits_amount, cash_rate, cash_amount, payment_details, display_name, status) VALUES (1, 50, 1, 50, '{\"email\":\"alice@pay.example\"}', 'Alice', 'paid')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id) VALUES (5, 1, 'Alice Store', 'alice-store', 'sf-alice')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, author_name, share_mode, status) VALUES (100, 1, 1, x'00', 'Alice Pack', 'Alice', 'per_use', 'published')")
+
+	rec := postDeleteAccount("1", url.Values{"password": {"wrong"}, "confirm": {"DELETE"}, "forfeit_balance": {"1"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `class="error-msg"`) {
+		t.Fatalf("wrong password: status %d", rec.Code)
+	}
+	rec = postDeleteAccount("1", url.Values{"password": {"correct-pw"}, "confirm": {"DELETE"}})
+	if !strings.Contains(rec.Body.String(), `class="error-msg"`) {
+		t.Fatal("deletion without forfeiting the balance should be refused")
+	}
+	var email sql.NullString
+	db.QueryRow("SELECT email FROM users WHERE id = 1").Scan(&email)
+	if email.String != "alice@example.com" {
+		t.Fatalf("account changed by refused request: email = %v", email)
+	}
+
+	rec = postDeleteAccount("1", url.Values{"password": {"correct-pw"}, "confirm": {"DELETE"}, "forfeit_balance": {"1"}})
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `class="error-msg"`) {
+		t.Fatalf("delete: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var name, authID string
+	var blocked int
+	var deletedAt sql.NullString
+	db.QueryRow("SELECT display_name, auth_id, email, is_blocked, deleted_at FROM users WHERE id = 1").Scan(&name, &authID, &email, &blocked, &deletedAt)
+	if name != "Deleted user #1" || authID != "deleted:1" || email.Valid || blocked != 1 || !deletedAt.Valid {
+		t.Errorf("user = %q %q %v blocked=%d deleted_at=%v", name, authID, email, blocked, deletedAt)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM email_wallets WHERE email = 'alice@example.com'").Scan(&n)
+	if n != 0 {
+		t.Error("email wallet should be removed with the last account")
+	}
+	db.QueryRow("SELECT COUNT(*) FROM user_payment_info WHERE user_id = 1").Scan(&n)
+	if n != 0 {
+		t.Error("payment info should be deleted")
+	}
+	var writeOff float64
+	db.QueryRow("SELECT amount FROM credits_transactions WHERE user_id = 1 AND transaction_type = 'account_deletion'").Scan(&writeOff)
+	if writeOff != -25 {
+		t.Errorf("balance write-off = %v, want -25", writeOff)
+	}
+	var ip, details string
+	db.QueryRow("SELECT COALESCE(ip_address, '') FROM credits_transactions WHERE user_id = 1 AND transaction_type = 'purchase'").Scan(&ip)
+	db.QueryRow("SELECT payment_details FROM withdrawal_records WHERE user_id = 1").Scan(&details)
+	if ip != "" || details != "{}" {
+		t.Errorf("ledger not anonymized: ip=%q payment_details=%q", ip, details)
+	}
+	var status, storeStatus string
+	db.QueryRow("SELECT status FROM pack_listings WHERE id = 100").Scan(&status)
+	db.QueryRow("SELECT store_status FROM author_storefronts WHERE id = 5").Scan(&storeStatus)
+	if status != "delisted" || storeStatus != storeStatusDeleted {
+		t.Errorf("pack status = %q, store status = %q", status, storeStatus)
+	}
+	if _, _, err := resolveStorefrontID("sf-alice"); err == nil {
+		t.Error("deleted storefront should not resolve")
+	}
+}
+
+func TestUserDeleteAccountSharedWallet(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'bob@example.com', 'Bob', 'bob@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'SN-1', 'Bob Desktop', 'bob@example.com')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance, password_hash) VALUES ('bob@example.com', 25, ?)", hashPassword("correct-pw"))
+
+	// The wallet stays with account 2, so no forfeit confirmation is needed.
+	rec := postDeleteAccount("1", url.Values{"password": {"correct-pw"}, "confirm": {"DELETE"}})
+	if strings.Contains(rec.Body.String(), `class="error-msg"`) {
+		t.Fatalf("delete: body %s", rec.Body.String())
+	}
+	var balance float64
+	if err := db.QueryRow("SELECT credits_balance FROM email_wallets WHERE email = 'bob@example.com'").Scan(&balance); err != nil || balance != 25 {
+		t.Errorf("shared wallet balance = %v (%v), want 25", balance, err)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE transaction_type = 'account_deletion'").Scan(&n)
+	if n != 0 {
+		t.Error("no balance should be written off while another account shares the wallet")
+	}
+}
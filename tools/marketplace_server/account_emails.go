@@ -0,0 +1,433 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"marketplace_server/i18n"
+	"marketplace_server/templates"
+)
+
+// Linked emails. Wallets are keyed by email, so a user who bought credits
+// under several addresses can link them to one account. The sign-in email
+// (users.email) is always part of the account and is never stored in
+// account_emails; linked emails are, once verified. The primary email is the
+// one new credits go to and the first one deductions draw from; it is the
+// sign-in email unless a linked email is marked is_primary.
+
+// walletBalanceEpsilon absorbs floating point residue when splitting a
+// deduction across wallets.
+const walletBalanceEpsilon = 1e-9
+
+// walletQuerier is satisfied by both *sql.DB and *sql.Tx, so the wallet
+// helpers below work inside and outside a transaction.
+type walletQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// walletShare is one email wallet of an account, in deduction order.
+type walletShare struct {
+	Email   string  `json:"email"`
+	Balance float64 `json:"balance"`
+}
+
+// combinedWalletBalance returns the balance the user sees: the sum of all
+// their wallets. Negative wallets are not netted against the others.
+func combinedWalletBalance(shares []walletShare) float64 {
+	var total float64
+	for _, s := range shares {
+		if s.Balance > 0 {
+			total += s.Balance
+		}
+	}
+	return total
+}
+
+// planWalletDeduction splits amount across shares in order, taking each
+// wallet down to zero before moving to the next. It returns the amount to
+// take from each wallet (wallets left untouched are omitted) and false if the
+// combined balance is insufficient.
+func planWalletDeduction(shares []walletShare, amount float64) ([]walletShare, bool) {
+	if amount <= 0 {
+		return nil, true
+	}
+	if combinedWalletBalance(shares)+walletBalanceEpsilon < amount {
+		return nil, false
+	}
+	var plan []walletShare
+	remaining := amount
+	for _, s := range shares {
+		if remaining <= walletBalanceEpsilon {
+			break
+		}
+		if s.Balance <= 0 {
+			continue
+		}
+		take := s.Balance
+		if take > remaining {
+			take = remaining
+		}
+		plan = append(plan, walletShare{Email: s.Email, Balance: take})
+		remaining -= take
+	}
+	return plan, true
+}
+
+// linkedWalletEmails returns the emails whose wallets belong to userID in
+// deduction order (primary first, then by link order), or nil if the user has
+// no linked emails and uses their sign-in email's wallet alone.
+func linkedWalletEmails(q walletQuerier, userID int64, signInEmail string) []string {
+	rows, err := q.Query(`SELECT email, is_primary FROM account_emails
+		WHERE user_id = ? AND verified_at IS NOT NULL ORDER BY verified_at, id`, userID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var primary string
+	var others []string
+	for rows.Next() {
+		var email string
+		var isPrimary int
+		if rows.Scan(&email, &isPrimary) != nil {
+			continue
+		}
+		if isPrimary == 1 && primary == "" {
+			primary = email
+		} else {
+			others = append(others, email)
+		}
+	}
+	if primary == "" && len(others) == 0 {
+		return nil
+	}
+	if primary == "" {
+		return append([]string{signInEmail}, others...)
+	}
+	return append([]string{primary, signInEmail}, others...)
+}
+
+// loadWalletShares returns the balances of emails in the given order. Missing
+// wallet rows are created first, as the single-email helpers do.
+func loadWalletShares(q walletQuerier, emails []string) ([]walletShare, error) {
+	shares := make([]walletShare, 0, len(emails))
+	for _, email := range emails {
+		q.Exec(`INSERT OR IGNORE INTO email_wallets (email, credits_balance)
+			SELECT ?, COALESCE(SUM(credits_balance), 0) FROM users WHERE email = ?`, email, email)
+		var balance float64
+		if err := q.QueryRow("SELECT credits_balance FROM email_wallets WHERE email = ?", email).Scan(&balance); err != nil {
+			return nil, err
+		}
+		shares = append(shares, walletShare{Email: email, Balance: balance})
+	}
+	return shares, nil
+}
+
+// accountWalletShares returns every wallet of userID in deduction order, or
+// nil if the user has no linked emails.
+func accountWalletShares(userID int64) []walletShare {
+	emails := linkedWalletEmails(db, userID, getEmailForUser(userID))
+	if emails == nil {
+		return nil
+	}
+	shares, err := loadWalletShares(db, emails)
+	if err != nil {
+		log.Printf("[ACCOUNT-EMAILS] failed to load wallets for user %d: %v", userID, err)
+		return nil
+	}
+	return shares
+}
+
+// deductLinkedWallets deducts amount across the wallets of a user with linked
+// emails. It returns 0 rows if their combined balance is insufficient.
+// Callers roll back the transaction on error, undoing partial deductions.
+func deductLinkedWallets(tx *sql.Tx, emails []string, amount float64) (int64, error) {
+	shares, err := loadWalletShares(tx, emails)
+	if err != nil {
+		return 0, err
+	}
+	plan, ok := planWalletDeduction(shares, amount)
+	if !ok {
+		return 0, nil
+	}
+	for _, part := range plan {
+		result, err := tx.Exec(
+			"UPDATE email_wallets SET credits_balance = credits_balance - ?, updated_at = CURRENT_TIMESTAMP WHERE email = ? AND credits_balance >= ?",
+			part.Balance, part.Email, part.Balance)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return 0, fmt.Errorf("wallet %q changed during deduction", part.Email)
+		}
+	}
+	return 1, nil
+}
+
+// emailTakenByOtherAccount reports whether email is the sign-in email of an
+// account other than userID, or is already linked to one.
+func emailTakenByOtherAccount(userID int64, email string) bool {
+	var n int
+	db.QueryRow(`SELECT (SELECT COUNT(*) FROM users WHERE LOWER(email) = ? AND id != ? AND deleted_at IS NULL) +
+		(SELECT COUNT(*) FROM account_emails WHERE email = ? AND user_id != ? AND verified_at IS NOT NULL)`,
+		email, userID, email, userID).Scan(&n)
+	return n > 0
+}
+
+// accountEmailInfo is one row of the linked emails page.
+type accountEmailInfo struct {
+	Email    string  `json:"email"`
+	Verified bool    `json:"verified"`
+	Primary  bool    `json:"primary"`
+	SignIn   bool    `json:"sign_in"`
+	Balance  float64 `json:"balance"`
+}
+
+// listAccountEmails returns the sign-in email followed by the linked and
+// pending emails of userID.
+func listAccountEmails(userID int64) ([]accountEmailInfo, error) {
+	signIn := getEmailForUser(userID)
+	rows, err := db.Query(`SELECT email, verified_at IS NOT NULL, is_primary FROM account_emails
+		WHERE user_id = ? ORDER BY verified_at IS NULL, verified_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []accountEmailInfo{{Email: signIn, Verified: true, Primary: true, SignIn: true}}
+	for rows.Next() {
+		var info accountEmailInfo
+		if err := rows.Scan(&info.Email, &info.Verified, &info.Primary); err != nil {
+			return nil, err
+		}
+		if info.Primary {
+			list[0].Primary = false
+		}
+		list = append(list, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Verified {
+			list[i].Balance = getWalletBalanceByEmail(list[i].Email)
+		}
+	}
+	return list, nil
+}
+
+// handleUserEmails handles GET /user/emails, the linked emails page.
+func handleUserEmails(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	emails, err := listAccountEmails(userID)
+	if err != nil {
+		log.Printf("[ACCOUNT-EMAILS] failed to list emails for user %d: %v", userID, err)
+		http.Error(w, i18n.T(i18n.DetectLang(r), "load_failed"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := i18n.TemplateData(r)
+	i18n.MergeTemplateData(data, map[string]interface{}{
+		"Emails":       emails,
+		"TotalBalance": getWalletBalance(userID),
+		"Success":      r.URL.Query().Get("success"),
+		"Error":        r.URL.Query().Get("error"),
+	})
+	if err := templates.UserEmailsTmpl.Execute(w, data); err != nil {
+		log.Printf("[ACCOUNT-EMAILS] template execute error: %v", err)
+	}
+}
+
+// handleUserEmailLink handles POST /user/emails/link, sending a verification
+// link to an email the user wants to add to their account.
+func handleUserEmailLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "未登录"})
+		return
+	}
+	lang := i18n.DetectLang(r)
+
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	if !isValidEmailAddress(email) {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": i18n.T(lang, "invalid_email")})
+		return
+	}
+	if strings.EqualFold(email, getEmailForUser(userID)) {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_link_own")})
+		return
+	}
+	if emailTakenByOtherAccount(userID, email) {
+		jsonResponse(w, http.StatusConflict, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_link_taken")})
+		return
+	}
+
+	var verified bool
+	var sentAt sql.NullString
+	err = db.QueryRow("SELECT verified_at IS NOT NULL, token_sent_at FROM account_emails WHERE user_id = ? AND email = ?", userID, email).Scan(&verified, &sentAt)
+	if err == nil && verified {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "verified": true})
+		return
+	}
+	now := time.Now().UTC()
+	if sentAt.Valid {
+		if t, perr := time.Parse("2006-01-02 15:04:05", sentAt.String); perr == nil {
+			if remaining := emailVerifyCooldown - now.Sub(t); remaining > 0 {
+				secs := int(remaining.Seconds()) + 1
+				jsonResponse(w, http.StatusTooManyRequests, map[string]interface{}{
+					"ok":       false,
+					"error":    fmt.Sprintf(i18n.T(lang, "email_verify_cooldown"), secs),
+					"cooldown": secs,
+				})
+				return
+			}
+		}
+	}
+
+	baseURL, ok := siteBaseURL()
+	if !ok {
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_verify_send_failed")})
+		return
+	}
+	token := generateSessionID()
+	if _, err := db.Exec(`INSERT INTO account_emails (user_id, email, token_hash, token_expires_at, token_sent_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, email) DO UPDATE SET token_hash = excluded.token_hash,
+			token_expires_at = excluded.token_expires_at, token_sent_at = excluded.token_sent_at`,
+		userID, email, hashEmailToken(token), now.Add(emailVerifyTokenTTL).Format("2006-01-02 15:04:05"),
+		now.Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("[ACCOUNT-EMAILS] failed to store link token for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+		return
+	}
+
+	link := baseURL + "/user/emails/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(i18n.T(lang, "email_link_body"), link, int(emailVerifyTokenTTL.Hours()))
+	if err := sendSystemEmail(email, i18n.T(lang, "email_link_subject"), body); err != nil {
+		log.Printf("[ACCOUNT-EMAILS] failed to send link email to %q: %v", email, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_verify_send_failed")})
+		return
+	}
+	log.Printf("[ACCOUNT-EMAILS] link verification sent to %q (user %d)", email, userID)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "cooldown": int(emailVerifyCooldown.Seconds())})
+}
+
+// handleUserEmailLinkVerify handles GET /user/emails/verify?token=... Like
+// handleUserEmailVerify it needs no session; the token identifies the account.
+func handleUserEmailLinkVerify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	nowStr := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	var id, userID int64
+	var email string
+	err := db.QueryRow(`SELECT id, user_id, email FROM account_emails
+		WHERE token_hash = ? AND verified_at IS NULL AND token_expires_at > ?`,
+		hashEmailToken(token), nowStr).Scan(&id, &userID, &email)
+	if token == "" || err != nil {
+		http.Redirect(w, r, "/user/emails?error=invalid", http.StatusFound)
+		return
+	}
+	// Another account may have claimed the email since the link was sent.
+	if emailTakenByOtherAccount(userID, email) {
+		db.Exec("DELETE FROM account_emails WHERE id = ?", id)
+		http.Redirect(w, r, "/user/emails?error=taken", http.StatusFound)
+		return
+	}
+	if _, err := db.Exec(`UPDATE account_emails SET verified_at = ?, token_hash = NULL, token_expires_at = NULL
+		WHERE id = ?`, nowStr, id); err != nil {
+		log.Printf("[ACCOUNT-EMAILS] failed to verify %q for user %d: %v", email, userID, err)
+		http.Redirect(w, r, "/user/emails?error=taken", http.StatusFound)
+		return
+	}
+	markEmailVerified(email)
+	log.Printf("[ACCOUNT-EMAILS] email %q linked to user %d", email, userID)
+	http.Redirect(w, r, "/user/emails?success=linked", http.StatusFound)
+}
+
+// handleUserEmailPrimary handles POST /user/emails/primary. Choosing the
+// sign-in email clears the primary flag of every linked email.
+func handleUserEmailPrimary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "未登录"})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+
+	tx, err := db.Begin()
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+		return
+	}
+	defer tx.Rollback()
+	if !strings.EqualFold(email, getEmailForUserTx(tx, userID)) {
+		var n int
+		tx.QueryRow("SELECT COUNT(*) FROM account_emails WHERE user_id = ? AND email = ? AND verified_at IS NOT NULL", userID, email).Scan(&n)
+		if n == 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": i18n.T(i18n.DetectLang(r), "email_not_linked")})
+			return
+		}
+	}
+	if _, err := tx.Exec("UPDATE account_emails SET is_primary = CASE WHEN email = ? THEN 1 ELSE 0 END WHERE user_id = ?", email, userID); err != nil {
+		log.Printf("[ACCOUNT-EMAILS] failed to set primary email for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+		return
+	}
+	log.Printf("[ACCOUNT-EMAILS] user %d set primary email to %q", userID, email)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+}
+
+// handleUserEmailUnlink handles POST /user/emails/unlink. The wallet stays
+// with the email; its balance simply stops counting towards the account.
+func handleUserEmailUnlink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "未登录"})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	lang := i18n.DetectLang(r)
+
+	var isPrimary int
+	if err := db.QueryRow("SELECT is_primary FROM account_emails WHERE user_id = ? AND email = ?", userID, email).Scan(&isPrimary); err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_not_linked")})
+		return
+	}
+	if isPrimary == 1 {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_unlink_primary")})
+		return
+	}
+	if _, err := db.Exec("DELETE FROM account_emails WHERE user_id = ? AND email = ?", userID, email); err != nil {
+		log.Printf("[ACCOUNT-EMAILS] failed to unlink %q from user %d: %v", email, userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+		return
+	}
+	log.Printf("[ACCOUNT-EMAILS] email %q unlinked from user %d", email, userID)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPlanWalletDeduction(t *testing.T) {
+	shares := []walletShare{{"primary@x", 10}, {"signin@x", 0}, {"old@x", 5.5}, {"debt@x", -3}}
+	if got := combinedWalletBalance(shares); got != 15.5 {
+		t.Fatalf("combined = %v, want 15.5", got)
+	}
+	tests := []struct {
+		amount float64
+		want   []walletShare
+		ok     bool
+	}{
+		{0, nil, true},
+		{4, []walletShare{{"primary@x", 4}}, true},
+		{10, []walletShare{{"primary@x", 10}}, true},
+		{12.25, []walletShare{{"primary@x", 10}, {"old@x", 2.25}}, true},
+		{15.5, []walletShare{{"primary@x", 10}, {"old@x", 5.5}}, true},
+		{15.51, nil, false},
+	}
+	for _, tt := range tests {
+		plan, ok := planWalletDeduction(shares, tt.amount)
+		if ok != tt.ok || len(plan) != len(tt.want) {
+			t.Errorf("amount %v: plan = %v, ok = %v; want %v, %v", tt.amount, plan, ok, tt.want, tt.ok)
+			continue
+		}
+		var sum float64
+		for i := range plan {
+			if plan[i].Email != tt.want[i].Email || math.Abs(plan[i].Balance-tt.want[i].Balance) > 1e-9 {
+				t.Errorf("amount %v: part %d = %v, want %v", tt.amount, i, plan[i], tt.want[i])
+			}
+			sum += plan[i].Balance
+		}
+		if ok && math.Abs(sum-tt.amount) > 1e-9 {
+			t.Errorf("amount %v: plan sums to %v", tt.amount, sum)
+		}
+	}
+
+	// Floating point residue must not make an exact balance look short.
+	plan, ok := planWalletDeduction([]walletShare{{"a@x", 0.1}, {"b@x", 0.2}}, 0.3)
+	if !ok || len(plan) != 2 {
+		t.Errorf("0.1+0.2 covering 0.3: plan = %v, ok = %v", plan, ok)
+	}
+}
+
+func TestLinkedWalletDeduction(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance) VALUES ('alice@example.com', 10), ('alice@work.example', 30), ('alice@old.example', 5)")
+	mustExec(t, "INSERT INTO account_emails (user_id, email, is_primary, verified_at) VALUES (1, 'alice@old.example', 0, '2026-01-01 00:00:00')")
+	mustExec(t, "INSERT INTO account_emails (user_id, email, is_primary, verified_at) VALUES (1, 'alice@work.example', 1, '2026-02-01 00:00:00')")
+	// A pending link does not count.
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance) VALUES ('pending@example.com', 100)")
+	mustExec(t, "INSERT INTO account_emails (user_id, email, token_hash) VALUES (1, 'pending@example.com', 'h')")
+
+	if got := getWalletBalance(1); got != 45 {
+		t.Fatalf("combined balance = %v, want 45", got)
+	}
+
+	balances := func() (float64, float64, float64) {
+		var signIn, work, old float64
+		db.QueryRow("SELECT credits_balance FROM email_wallets WHERE email = 'alice@example.com'").Scan(&signIn)
+		db.QueryRow("SELECT credits_balance FROM email_wallets WHERE email = 'alice@work.example'").Scan(&work)
+		db.QueryRow("SELECT credits_balance FROM email_wallets WHERE email = 'alice@old.example'").Scan(&old)
+		return signIn, work, old
+	}
+
+	// Primary (work) first, then the sign-in email, then other links by age.
+	tx, _ := db.Begin()
+	rows, err := deductWalletBalance(tx, 1, 38)
+	if err != nil || rows != 1 {
+		t.Fatalf("deduct 38: rows = %d, err = %v", rows, err)
+	}
+	tx.Commit()
+	if s, w, o := balances(); s != 2 || w != 0 || o != 5 {
+		t.Errorf("after 38: sign-in %v, work %v, old %v; want 2, 0, 5", s, w, o)
+	}
+
+	tx, _ = db.Begin()
+	rows, err = deductWalletBalance(tx, 1, 8)
+	if err != nil || rows != 0 {
+		t.Fatalf("deduct 8 of 7: rows = %d, err = %v", rows, err)
+	}
+	tx.Rollback()
+
+	tx, _ = db.Begin()
+	if err := addWalletBalance(tx, 1, 20); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+	if s, w, o := balances(); s != 2 || w != 20 || o != 5 {
+		t.Errorf("after top-up: sign-in %v, work %v, old %v; want 2, 20, 5", s, w, o)
+	}
+}
+
+func TestUserEmailLinkFlow(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'email', 'b', 'Bob', 'bob@example.com')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance) VALUES ('alice@example.com', 10), ('alice@old.example', 7)")
+
+	post := func(path, email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(url.Values{"email": {email}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		switch path {
+		case "/user/emails/link":
+			handleUserEmailLink(rec, req)
+		case "/user/emails/primary":
+			handleUserEmailPrimary(rec, req)
+		default:
+			handleUserEmailUnlink(rec, req)
+		}
+		return rec
+	}
+
+	if rec := post("/user/emails/link", "Bob@Example.com"); rec.Code != http.StatusConflict {
+		t.Errorf("linking another account's email: status %d", rec.Code)
+	}
+	if rec := post("/user/emails/link", "alice@example.com"); rec.Code != http.StatusBadRequest {
+		t.Errorf("linking the sign-in email: status %d", rec.Code)
+	}
+
+	// SMTP is not configured in tests, so simulate the emailed token.
+	mustExec(t, "INSERT INTO account_emails (user_id, email, token_hash, token_expires_at) VALUES (1, 'alice@old.example', ?, '2999-01-01 00:00:00')", hashEmailToken("tok"))
+	if rec := post("/user/emails/primary", "alice@old.example"); rec.Code != http.StatusBadRequest {
+		t.Errorf("making an unverified email primary: status %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	handleUserEmailLinkVerify(rec, httptest.NewRequest(http.MethodGet, "/user/emails/verify?token=tok", nil))
+	if loc := rec.Header().Get("Location"); loc != "/user/emails?success=linked" {
+		t.Fatalf("verify redirect = %q", loc)
+	}
+	if got := getWalletBalance(1); got != 17 {
+		t.Errorf("combined balance = %v, want 17", got)
+	}
+
+	// Bob cannot claim the email now that Alice has linked it.
+	if !emailTakenByOtherAccount(2, "alice@old.example") {
+		t.Error("linked email should be taken for other accounts")
+	}
+
+	if rec := post("/user/emails/primary", "alice@old.example"); rec.Code != http.StatusOK {
+		t.Fatalf("set primary: status %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := post("/user/emails/unlink", "alice@old.example"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unlinking the primary email: status %d", rec.Code)
+	}
+	post("/user/emails/primary", "alice@example.com")
+	if rec := post("/user/emails/unlink", "alice@old.example"); rec.Code != http.StatusOK {
+		t.Fatalf("unlink: status %d %s", rec.Code, rec.Body.String())
+	}
+	if got := getWalletBalance(1); got != 10 {
+		t.Errorf("balance after unlink = %v, want 10", got)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Admin login brute-force protection. Failures are counted separately per
+// username and per client IP; reaching the threshold locks that key for a
+// duration that doubles with every further lockout, up to adminLoginMaxLockout.
+const (
+	adminLoginMaxUserFailures = 5
+	adminLoginMaxIPFailures   = 20
+	adminLoginFailureWindow   = 15 * time.Minute // failures older than this are forgotten
+	adminLoginBaseLockout     = time.Minute
+	adminLoginMaxLockout      = time.Hour
+	adminLoginLockoutMemory   = 24 * time.Hour // how long escalation is remembered
+)
+
+type adminLoginAttempt struct {
+	Failures    int
+	Lockouts    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+var (
+	adminLoginAttempts   = make(map[string]*adminLoginAttempt)
+	adminLoginAttemptsMu sync.Mutex
+	adminLoginLastPrune  time.Time // guarded by adminLoginAttemptsMu
+)
+
+// expired reports whether the attempt can be forgotten: it is not locked, its
+// failures are outside the window, and a key that was locked before has gone
+// adminLoginLockoutMemory without failing, so escalation starts over.
+func (a *adminLoginAttempt) expired(now time.Time) bool {
+	if now.Before(a.LockedUntil) {
+		return false
+	}
+	if a.Lockouts > 0 {
+		return now.Sub(a.LastFailure) > adminLoginLockoutMemory
+	}
+	return now.Sub(a.LastFailure) > adminLoginFailureWindow
+}
+
+// adminLoginKeys returns the counter keys for a login attempt, each paired
+// with its failure threshold.
+func adminLoginKeys(username, ip string) map[string]int {
+	keys := map[string]int{"ip:" + ip: adminLoginMaxIPFailures}
+	if username = strings.ToLower(strings.TrimSpace(username)); username != "" {
+		keys["user:"+username] = adminLoginMaxUserFailures
+	}
+	return keys
+}
+
+// adminLoginLocked reports whether either the username or the IP is locked out.
+func adminLoginLocked(username, ip string, now time.Time) bool {
+	adminLoginAttemptsMu.Lock()
+	defer adminLoginAttemptsMu.Unlock()
+	for key := range adminLoginKeys(username, ip) {
+		if a, ok := adminLoginAttempts[key]; ok && now.Before(a.LockedUntil) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAdminLoginFailure counts a failed login and returns the lockout
+// duration if this failure triggered one (0 otherwise).
+func recordAdminLoginFailure(username, ip string, now time.Time) time.Duration {
+	type lockout struct {
+		key      string
+		duration time.Duration
+		failures int
+	}
+	var triggered []lockout
+
+	adminLoginAttemptsMu.Lock()
+	// Failures from many addresses add an entry each; sweep as they arrive
+	// rather than waiting for the periodic cleanup.
+	if now.Sub(adminLoginLastPrune) > adminLoginFailureWindow {
+		pruneAdminLoginAttempts(now)
+		adminLoginLastPrune = now
+	}
+	for key, threshold := range adminLoginKeys(username, ip) {
+		a, ok := adminLoginAttempts[key]
+		if !ok {
+			a = &adminLoginAttempt{}
+			adminLoginAttempts[key] = a
+		}
+		if now.Sub(a.LastFailure) > adminLoginFailureWindow {
+			a.Failures = 0
+		}
+		a.Failures++
+		a.LastFailure = now
+		if a.Failures >= threshold {
+			d := adminLoginBaseLockout << a.Lockouts
+			if d > adminLoginMaxLockout || d <= 0 {
+				d = adminLoginMaxLockout
+			}
+			a.Lockouts++
+			a.LockedUntil = now.Add(d)
+			triggered = append(triggered, lockout{key, d, a.Failures})
+			a.Failures = 0
+		}
+	}
+	adminLoginAttemptsMu.Unlock()
+
+	var longest time.Duration
+	for _, l := range triggered {
+		log.Printf("[LOGIN] locked out %s for %s after %d failed attempts", l.key, l.duration, l.failures)
+		recordAuditLog(0, "admin_login_lockout", l.key,
+			fmt.Sprintf("locked for %s after %d failed attempts", l.duration, l.failures), ip)
+		if l.duration > longest {
+			longest = l.duration
+		}
+	}
+	return longest
+}
+
+// clearAdminLoginFailures resets the counters after a successful login.
+func clearAdminLoginFailures(username, ip string) {
+	adminLoginAttemptsMu.Lock()
+	for key := range adminLoginKeys(username, ip) {
+		delete(adminLoginAttempts, key)
+	}
+	adminLoginAttemptsMu.Unlock()
+}
+
+// cleanupAdminLoginAttempts drops entries that are neither locked nor recent
+// enough to affect escalation.
+func cleanupAdminLoginAttempts(now time.Time) {
+	adminLoginAttemptsMu.Lock()
+	pruneAdminLoginAttempts(now)
+	adminLoginAttemptsMu.Unlock()
+}
+
+// pruneAdminLoginAttempts deletes expired entries. The caller holds
+// adminLoginAttemptsMu.
+func pruneAdminLoginAttempts(now time.Time) {
+	for key, a := range adminLoginAttempts {
+		if a.expired(now) {
+			delete(adminLoginAttempts, key)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// resetAdminLoginAttempts gives the test an empty counter table.
+func resetAdminLoginAttempts(t *testing.T) {
+	t.Helper()
+	adminLoginAttemptsMu.Lock()
+	prev, prevPrune := adminLoginAttempts, adminLoginLastPrune
+	adminLoginAttempts, adminLoginLastPrune = make(map[string]*adminLoginAttempt), time.Time{}
+	adminLoginAttemptsMu.Unlock()
+	t.Cleanup(func() {
+		adminLoginAttemptsMu.Lock()
+		adminLoginAttempts, adminLoginLastPrune = prev, prevPrune
+		adminLoginAttemptsMu.Unlock()
+	})
+}
+
+func TestAdminLoginLockout(t *testing.T) {
+	useTestDB(t)
+	resetAdminLoginAttempts(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// The username locks on the fifth failure, for the base duration.
+	for i := 1; i < adminLoginMaxUserFailures; i++ {
+		if d := recordAdminLoginFailure("Admin", "192.0.2.1", now); d != 0 {
+			t.Fatalf("failure %d locked for %s", i, d)
+		}
+	}
+	if adminLoginLocked("admin", "192.0.2.1", now) {
+		t.Fatal("locked before the threshold")
+	}
+	if d := recordAdminLoginFailure("admin", "192.0.2.1", now); d != adminLoginBaseLockout {
+		t.Fatalf("lockout = %s, want %s", d, adminLoginBaseLockout)
+	}
+	// Locked from any address until the lockout runs out.
+	if !adminLoginLocked(" ADMIN ", "198.51.100.7", now.Add(30*time.Second)) {
+		t.Error("username lockout not applied from another address")
+	}
+	if adminLoginLocked("admin", "192.0.2.1", now.Add(adminLoginBaseLockout+time.Second)) {
+		t.Error("lockout did not expire")
+	}
+
+	// The next lockout of the same key lasts twice as long.
+	later := now.Add(2 * time.Minute)
+	var d time.Duration
+	for i := 0; i < adminLoginMaxUserFailures; i++ {
+		d = recordAdminLoginFailure("admin", "192.0.2.1", later)
+	}
+	if d != 2*adminLoginBaseLockout {
+		t.Errorf("second lockout = %s, want %s", d, 2*adminLoginBaseLockout)
+	}
+
+	// Failures spread over more than the window never add up to a lockout.
+	for i := 0; i < 2*adminLoginMaxUserFailures; i++ {
+		if d := recordAdminLoginFailure("spread", "203.0.113.9", later.Add(time.Duration(i)*(adminLoginFailureWindow+time.Second))); d != 0 {
+			t.Fatalf("spread-out failure %d locked for %s", i, d)
+		}
+	}
+
+	// A successful login resets the counters.
+	resetAdminLoginAttempts(t)
+	for i := 1; i < adminLoginMaxUserFailures; i++ {
+		recordAdminLoginFailure("admin", "192.0.2.1", now)
+	}
+	clearAdminLoginFailures("admin", "192.0.2.1")
+	if d := recordAdminLoginFailure("admin", "192.0.2.1", now); d != 0 {
+		t.Errorf("counter survived a successful login: locked for %s", d)
+	}
+
+	// The address locks on its own threshold across usernames.
+	resetAdminLoginAttempts(t)
+	for i := 0; i < adminLoginMaxIPFailures; i++ {
+		recordAdminLoginFailure("user"+string(rune('a'+i)), "192.0.2.50", now)
+	}
+	if !adminLoginLocked("someone-else", "192.0.2.50", now) {
+		t.Error("address not locked after spraying usernames")
+	}
+}
+
+func TestAdminLoginAttemptsPruned(t *testing.T) {
+	useTestDB(t)
+	resetAdminLoginAttempts(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	recordAdminLoginFailure("", "192.0.2.1", now)
+	for i := 0; i < adminLoginMaxUserFailures; i++ {
+		recordAdminLoginFailure("locked", "192.0.2.2", now)
+	}
+	count := func() int {
+		adminLoginAttemptsMu.Lock()
+		defer adminLoginAttemptsMu.Unlock()
+		return len(adminLoginAttempts)
+	}
+	if n := count(); n != 3 {
+		t.Fatalf("tracking %d keys, want 3", n)
+	}
+
+	// A new failure after the window sweeps the stale counter, but keeps the
+	// key that was locked so its next lockout still escalates.
+	recordAdminLoginFailure("", "198.51.100.1", now.Add(adminLoginFailureWindow+time.Minute))
+	adminLoginAttemptsMu.Lock()
+	_, stale := adminLoginAttempts["ip:192.0.2.1"]
+	_, escalation := adminLoginAttempts["user:locked"]
+	adminLoginAttemptsMu.Unlock()
+	if stale || !escalation {
+		t.Errorf("after sweep: stale kept %v, locked key kept %v", stale, escalation)
+	}
+
+	cleanupAdminLoginAttempts(now.Add(adminLoginLockoutMemory + time.Hour))
+	if n := count(); n != 0 {
+		t.Errorf("%d keys left after the escalation memory passed", n)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Access levels for admin routes that are not tied to one permission.
+const (
+	permAnyAdmin   Permission = ""            // any logged-in admin (adminAuth)
+	permSuperAdmin Permission = "super_admin" // admin id=1 only (superAdminOnlyAuth)
+)
+
+// adminRoute is one admin endpoint and the permission it requires.
+type adminRoute struct {
+	Pattern    string
+	Permission Permission
+	Handler    http.HandlerFunc
+}
+
+// adminRoutes lists every authenticated admin endpoint. Keeping them in one
+// table means each one is registered through adminRouteHandler, and the
+// route/permission matrix can be checked in tests.
+func adminRoutes() []adminRoute {
+	return []adminRoute{
+		// Admin management (super admin id=1 only) and per-admin routes
+		{"/api/admin/admins", permSuperAdmin, handleAdminManagement},
+		{"/api/admin/permissions", permAnyAdmin, handleAdminPermissions},
+		{"/api/admin/profile", permAnyAdmin, handleUpdateProfile},
+		{"/api/admin/stats", permAnyAdmin, handleAdminStats},
+		{"/api/admin/2fa/", permAnyAdmin, handleAdminTOTP},
+		{"/api/admin/api-keys", permAnyAdmin, handleAdminAPIKeys},
+		{"/api/admin/api-keys/", permAnyAdmin, handleAdminAPIKeys},
+
+		// Category management
+		{"/api/admin/categories", PermCategories, handleAdminCategories},
+		{"/api/admin/categories/", PermCategories, handleAdminCategories},
+
+		// Marketplace management API routes (permission-based)
+		{"/api/admin/marketplace", PermMarketplace, handleAdminMarketplaceRoutes},
+		{"/api/admin/marketplace/", PermMarketplace, handleAdminMarketplaceRoutes},
+		{"/api/admin/price-history", PermMarketplace, handleAdminPriceHistory},
+		{"/api/admin/pack-integrity", PermMarketplace, handleAdminPackIntegrity},
+		{"/api/admin/pack-watermarks", PermMarketplace, handleAdminPackWatermarkLookup},
+
+		// Unified account management API routes (permission-based, replaces separate author/customer)
+		{"/api/admin/accounts", PermAccounts, handleAdminAccountRoutes},
+		{"/api/admin/accounts/", PermAccounts, handleAdminAccountRoutes},
+		{"/api/admin/impersonate", PermImpersonate, handleAdminImpersonate},
+		{"/api/admin/db-backup", PermBackup, handleAdminDBBackup},
+
+		// Author management API routes (permission-based, kept for backward compatibility)
+		{"/api/admin/authors", PermAuthors, handleAdminAuthorRoutes},
+		{"/api/admin/authors/", PermAuthors, handleAdminAuthorRoutes},
+		{"/api/admin/storefront-verification", PermAuthors, handleAdminStorefrontVerification},
+		{"/api/admin/storefronts/transfer", PermAuthors, handleAdminStorefrontTransfer},
+		{"/api/admin/kyc", PermKYC, handleAdminKYCRoutes},
+		{"/api/admin/kyc/", PermKYC, handleAdminKYCRoutes},
+
+		// Customer management API routes (permission-based, kept for backward compatibility)
+		{"/api/admin/customers", PermCustomers, handleAdminCustomerRoutes},
+		{"/api/admin/customers/", PermCustomers, handleAdminCustomerRoutes},
+		{"/api/admin/users", PermCustomers, handleAdminUsers},
+		{"/api/admin/users/export", PermCustomers, handleAdminUsers},
+
+		// Notification management API routes (permission-based)
+		{"/api/admin/notifications", PermNotifications, handleAdminNotificationRoutes},
+		{"/api/admin/notifications/", PermNotifications, handleAdminNotificationRoutes},
+
+		// Review API routes (permission-based)
+		{"/api/admin/review/", PermReview, handleReviewRoutes},
+		{"/api/admin/content-flags", PermReview, handleAdminContentFlags},
+		{"/api/admin/content-flags/", PermReview, handleAdminContentFlags},
+
+		// Sales management API routes (permission-based)
+		{"/api/admin/sales", PermSales, handleAdminSalesRoutes},
+		{"/api/admin/sales/", PermSales, handleAdminSalesRoutes},
+
+		// Featured storefronts management API routes (permission-based)
+		{"/api/admin/featured-storefronts", PermSettings, handleAdminFeaturedStorefronts},
+		{"/api/admin/featured-storefronts/", PermSettings, handleAdminFeaturedStorefronts},
+		{"/api/admin/featured-products", PermSettings, handleAdminFeaturedProducts},
+		{"/api/admin/featured-products/", PermSettings, handleAdminFeaturedProducts},
+
+		// System settings
+		{"/admin/settings/initial-credits", PermSettings, handleSetInitialCredits},
+		{"/admin/settings/credit-cash-rate", PermSettings, handleSetCreditCashRate},
+		{"/admin/settings/paypal", PermSettings, handleAdminPayPalSettings},
+		{"/admin/settings/pack-subscriptions", PermSettings, handleAdminPackSubscriptionSettings},
+		{"/admin/settings/time-limited", PermSettings, handleAdminTimeLimitedSettings},
+		{"/admin/settings/store-slugs", PermSettings, handleAdminStoreSlugSettings},
+		{"/admin/settings/content-filter", PermSettings, handleAdminContentFilterSettings},
+		{"/admin/settings/public-api", PermSettings, handleAdminPublicAPISettings},
+		{"/admin/settings/pack-storage", PermSettings, handleAdminPackStorageSettings},
+		{"/admin/settings/pack-storage/migrate", PermSettings, handleAdminPackStorageMigrate},
+		{"/admin/settings/pack-scan", PermSettings, handleAdminPackScanSettings},
+		{"/admin/settings/pack-upload", PermSettings, handleAdminPackUploadSettings},
+		{"/admin/settings/pack-watermark", PermSettings, handleAdminPackWatermarkSettings},
+		{"/admin/settings/idempotency", PermSettings, handleAdminIdempotencySettings},
+		{"/admin/settings/stale-orders", PermSettings, handleAdminStaleOrderSettings},
+		{"/admin/settings/credits-expiry", PermSettings, handleAdminCreditsExpirySettings},
+		{"/admin/settings/referrals", PermSettings, handleAdminReferralSettings},
+		{"/admin/settings/topup-tiers", PermSettings, handleAdminTopupTiers},
+		{"/admin/settings/license-retry", PermSettings, handleAdminLicenseRetrySettings},
+		{"/admin/settings/currency", PermSettings, handleAdminCurrencySettings},
+		{"/api/admin/fulfillment-jobs", PermSales, handleAdminFulfillmentJobs},
+		{"/api/admin/fulfillment-jobs/", PermSales, handleAdminFulfillmentJobs},
+		{"/admin/settings/encryption", permSuperAdmin, handleAdminEncryptionKeys},
+		{"/admin/settings/oauth", PermSettings, handleAdminOAuthSettings},
+		{"/admin/api/settings/revenue-split", PermSettings, handleAdminSaveRevenueSplit},
+		{"/admin/api/settings/withdrawal-fees", PermSettings, handleAdminSaveWithdrawalFees},
+		{"/admin/api/settings/default-language", PermSettings, handleSetDefaultLanguage},
+		{"/admin/api/settings/download-urls", PermSettings, handleSaveDownloadURLs},
+		{"/admin/api/settings/smtp", PermSettings, handleAdminSaveSMTPConfig},
+		{"/admin/api/settings/smtp-test", PermSettings, handleAdminTestSMTPConfig},
+		{"/admin/settings/service-portal-url", PermSettings, handleSaveServicePortalURL},
+		{"/admin/settings/site-url", PermSettings, handleAdminSiteURLSettings},
+		{"/admin/settings/csp", PermSettings, handleAdminCSPSettings},
+		{"/admin/settings/homepage-cache", PermSettings, handleAdminHomepageCache},
+		{"/admin/settings/homepage-cache/refresh", PermSettings, handleAdminHomepageCacheRefresh},
+		{"/admin/settings/trending", PermSettings, handleAdminTrendingSettings},
+		{"/admin/settings/support-parent-product-id", PermSettings, handleSaveSupportParentProductID},
+		{"/admin/api/settings/decoration-fee", PermBilling, handleSetDecorationFee},
+		{"/admin/api/settings/decoration-fee-max", PermBilling, handleSetDecorationFeeMax},
+		{"/admin/api/withdrawals/export", PermSettings, handleAdminExportWithdrawals},
+		{"/admin/api/withdrawals/report", PermSettings, handleAdminWithdrawalReport},
+		{"/admin/api/withdrawals/approve", PermSettings, handleAdminApproveWithdrawals},
+		{"/admin/api/withdrawals", PermSettings, handleAdminGetWithdrawals},
+
+		// Billing management API routes (permission-based)
+		{"/admin/api/billing", PermBilling, handleAdminBillingList},
+		{"/admin/api/billing/export", PermBilling, handleAdminBillingExport},
+
+		// Decoration billing details API routes (permission-based)
+		{"/admin/api/billing/decoration/export", PermBilling, handleDecorationBillingExport},
+		{"/admin/api/billing/decoration", PermBilling, handleDecorationBillingList},
+
+		// Storefront support management API routes (permission-based)
+		{"/admin/api/storefront-support/get-threshold", PermStorefrontSupport, handleGetSupportThreshold},
+		{"/admin/api/storefront-support/set-threshold", PermStorefrontSupport, handleSetSupportThreshold},
+		{"/admin/api/storefront-support/list", PermStorefrontSupport, handleAdminStorefrontSupportList},
+		{"/admin/api/storefront-support/approve", PermStorefrontSupport, handleAdminStorefrontSupportApprove},
+		{"/admin/api/storefront-support/disable", PermStorefrontSupport, handleAdminStorefrontSupportDisable},
+		{"/admin/api/storefront-support/re-approve", PermStorefrontSupport, handleAdminStorefrontSupportReApprove},
+		{"/admin/api/storefront-support/delete", PermStorefrontSupport, handleAdminStorefrontSupportDelete},
+
+		// Custom products admin routes (permission-based)
+		{"/api/admin/pending-custom-products", PermReview, handleAdminPendingCustomProducts},
+		{"/admin/storefront/", PermSettings, handleAdminCustomProductsToggle},
+		{"/admin/custom-product/", PermReview, handleAdminCustomProductRoutes},
+
+		// Admin dashboard page; each section checks permissions client-side
+		// and its API calls are guarded by the routes above.
+		{"/admin/", permAnyAdmin, handleAdminDashboard},
+	}
+}
+
+// adminRouteHandler wraps h with the middleware for perm.
+func adminRouteHandler(perm Permission, h http.HandlerFunc) http.HandlerFunc {
+	switch perm {
+	case permAnyAdmin:
+		return adminAuth(h)
+	case permSuperAdmin:
+		return superAdminOnlyAuth(h)
+	default:
+		return permissionAuth(perm)(h)
+	}
+}
+
+// registerAdminRoutes registers adminRoutes on mux.
+func registerAdminRoutes(mux *http.ServeMux) {
+	for _, rt := range adminRoutes() {
+		mux.HandleFunc(rt.Pattern, adminRouteHandler(rt.Permission, rt.Handler))
+	}
+}
+
+// handleAdminCustomProductRoutes dispatches /admin/custom-product/{id}/approve|reject.
+func handleAdminCustomProductRoutes(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/approve"):
+		handleAdminCustomProductApprove(w, r)
+	case strings.HasSuffix(r.URL.Path, "/reject"):
+		handleAdminCustomProductReject(w, r)
+	default:
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+	}
+}
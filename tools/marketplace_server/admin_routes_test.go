@@ -0,0 +1,196 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// adminRouteMatrix is the permission every admin endpoint must require.
+// Adding a route to adminRoutes without adding it here fails the test.
+var adminRouteMatrix = map[string]Permission{
+	"/api/admin/admins":                           permSuperAdmin,
+	"/api/admin/permissions":                      permAnyAdmin,
+	"/api/admin/profile":                          permAnyAdmin,
+	"/api/admin/stats":                            permAnyAdmin,
+	"/api/admin/2fa/":                             permAnyAdmin,
+	"/api/admin/api-keys":                         permAnyAdmin,
+	"/api/admin/api-keys/":                        permAnyAdmin,
+	"/api/admin/categories":                       PermCategories,
+	"/api/admin/categories/":                      PermCategories,
+	"/api/admin/marketplace":                      PermMarketplace,
+	"/api/admin/marketplace/":                     PermMarketplace,
+	"/api/admin/price-history":                    PermMarketplace,
+	"/api/admin/pack-integrity":                   PermMarketplace,
+	"/api/admin/pack-watermarks":                  PermMarketplace,
+	"/api/admin/accounts":                         PermAccounts,
+	"/api/admin/accounts/":                        PermAccounts,
+	"/api/admin/impersonate":                      PermImpersonate,
+	"/api/admin/db-backup":                        PermBackup,
+	"/api/admin/authors":                          PermAuthors,
+	"/api/admin/authors/":                         PermAuthors,
+	"/api/admin/storefront-verification":          PermAuthors,
+	"/api/admin/storefronts/transfer":             PermAuthors,
+	"/api/admin/kyc":                              PermKYC,
+	"/api/admin/kyc/":                             PermKYC,
+	"/api/admin/customers":                        PermCustomers,
+	"/api/admin/customers/":                       PermCustomers,
+	"/api/admin/users":                            PermCustomers,
+	"/api/admin/users/export":                     PermCustomers,
+	"/api/admin/notifications":                    PermNotifications,
+	"/api/admin/notifications/":                   PermNotifications,
+	"/api/admin/review/":                          PermReview,
+	"/api/admin/content-flags":                    PermReview,
+	"/api/admin/content-flags/":                   PermReview,
+	"/api/admin/sales":                            PermSales,
+	"/api/admin/sales/":                           PermSales,
+	"/api/admin/featured-storefronts":             PermSettings,
+	"/api/admin/featured-storefronts/":            PermSettings,
+	"/api/admin/featured-products":                PermSettings,
+	"/api/admin/featured-products/":               PermSettings,
+	"/admin/settings/initial-credits":             PermSettings,
+	"/admin/settings/credit-cash-rate":            PermSettings,
+	"/admin/settings/paypal":                      PermSettings,
+	"/admin/settings/pack-subscriptions":          PermSettings,
+	"/admin/settings/time-limited":                PermSettings,
+	"/admin/settings/store-slugs":                 PermSettings,
+	"/admin/settings/content-filter":              PermSettings,
+	"/admin/settings/public-api":                  PermSettings,
+	"/admin/settings/pack-storage":                PermSettings,
+	"/admin/settings/pack-storage/migrate":        PermSettings,
+	"/admin/settings/pack-scan":                   PermSettings,
+	"/admin/settings/pack-upload":                 PermSettings,
+	"/admin/settings/pack-watermark":              PermSettings,
+	"/admin/settings/idempotency":                 PermSettings,
+	"/admin/settings/stale-orders":                PermSettings,
+	"/admin/settings/credits-expiry":              PermSettings,
+	"/admin/settings/referrals":                   PermSettings,
+	"/admin/settings/topup-tiers":                 PermSettings,
+	"/admin/settings/license-retry":               PermSettings,
+	"/admin/settings/currency":                    PermSettings,
+	"/api/admin/fulfillment-jobs":                 PermSales,
+	"/api/admin/fulfillment-jobs/":                PermSales,
+	"/admin/settings/encryption":                  permSuperAdmin,
+	"/admin/settings/oauth":                       PermSettings,
+	"/admin/api/settings/revenue-split":           PermSettings,
+	"/admin/api/settings/withdrawal-fees":         PermSettings,
+	"/admin/api/settings/default-language":        PermSettings,
+	"/admin/api/settings/download-urls":           PermSettings,
+	"/admin/api/settings/smtp":                    PermSettings,
+	"/admin/api/settings/smtp-test":               PermSettings,
+	"/admin/settings/service-portal-url":          PermSettings,
+	"/admin/settings/site-url":                    PermSettings,
+	"/admin/settings/csp":                         PermSettings,
+	"/admin/settings/homepage-cache":              PermSettings,
+	"/admin/settings/homepage-cache/refresh":      PermSettings,
+	"/admin/settings/trending":                    PermSettings,
+	"/admin/settings/support-parent-product-id":   PermSettings,
+	"/admin/api/settings/decoration-fee":          PermBilling,
+	"/admin/api/settings/decoration-fee-max":      PermBilling,
+	"/admin/api/withdrawals/export":               PermSettings,
+	"/admin/api/withdrawals/report":               PermSettings,
+	"/admin/api/withdrawals/approve":              PermSettings,
+	"/admin/api/withdrawals":                      PermSettings,
+	"/admin/api/billing":                          PermBilling,
+	"/admin/api/billing/export":                   PermBilling,
+	"/admin/api/billing/decoration/export":        PermBilling,
+	"/admin/api/billing/decoration":               PermBilling,
+	"/admin/api/storefront-support/get-threshold": PermStorefrontSupport,
+	"/admin/api/storefront-support/set-threshold": PermStorefrontSupport,
+	"/admin/api/storefront-support/list":          PermStorefrontSupport,
+	"/admin/api/storefront-support/approve":       PermStorefrontSupport,
+	"/admin/api/storefront-support/disable":       PermStorefrontSupport,
+	"/admin/api/storefront-support/re-approve":    PermStorefrontSupport,
+	"/admin/api/storefront-support/delete":        PermStorefrontSupport,
+	"/api/admin/pending-custom-products":          PermReview,
+	"/admin/storefront/":                          PermSettings,
+	"/admin/custom-product/":                      PermReview,
+	"/admin/":                                     permAnyAdmin,
+}
+
+func TestAdminRoutesMatchMatrix(t *testing.T) {
+	routes := adminRoutes()
+	if len(routes) != len(adminRouteMatrix) {
+		t.Errorf("adminRoutes has %d routes, matrix has %d", len(routes), len(adminRouteMatrix))
+	}
+	for _, rt := range routes {
+		want, ok := adminRouteMatrix[rt.Pattern]
+		if !ok {
+			t.Errorf("%s is not in the permission matrix", rt.Pattern)
+			continue
+		}
+		if rt.Permission != want {
+			t.Errorf("%s requires %q, want %q", rt.Pattern, rt.Permission, want)
+		}
+		if rt.Permission != permAnyAdmin && rt.Permission != permSuperAdmin {
+			if _, ok := lookupPermission(rt.Permission); !ok {
+				t.Errorf("%s requires unregistered permission %q", rt.Pattern, rt.Permission)
+			}
+		}
+	}
+}
+
+func TestAdminRoutePermissionEnforcement(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO admin_credentials (id, username, password_hash, role, permissions) VALUES (1, 'root', 'x', 'super', '')")
+	mustExec(t, "INSERT INTO admin_credentials (id, username, password_hash, role, permissions) VALUES (2, 'none', 'x', 'regular', '')")
+	sessionFor := map[Permission]string{"": createSession(2), permSuperAdmin: createSession(1)}
+	for i, info := range permissionRegistry {
+		id := 10 + i
+		mustExec(t, "INSERT INTO admin_credentials (id, username, password_hash, role, permissions) VALUES (?, ?, 'x', 'regular', ?)",
+			id, "admin-"+string(info.Key), string(info.Key))
+		sessionFor[info.Key] = createSession(int64(id))
+	}
+	noPerms := sessionFor[""]
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }
+	call := func(rt adminRoute, sid string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, rt.Pattern, nil)
+		req.AddCookie(&http.Cookie{Name: "admin_session", Value: sid})
+		rec := httptest.NewRecorder()
+		adminRouteHandler(rt.Permission, ok)(rec, req)
+		return rec
+	}
+	for _, rt := range adminRoutes() {
+		if rec := call(rt, sessionFor[rt.Permission]); rec.Code != http.StatusTeapot {
+			t.Errorf("%s with %q: status %d", rt.Pattern, rt.Permission, rec.Code)
+		}
+		if rt.Permission == permAnyAdmin {
+			continue
+		}
+		rec := call(rt, noPerms)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "permission_denied") {
+			t.Errorf("%s without %q: status %d %s", rt.Pattern, rt.Permission, rec.Code, rec.Body.String())
+		}
+	}
+
+	// "accounts" and the legacy author/customer grants satisfy each other.
+	for _, c := range []struct{ route, grant Permission }{
+		{PermAuthors, PermAccounts}, {PermCustomers, PermAccounts}, {PermAccounts, PermCustomers},
+	} {
+		if rec := call(adminRoute{"/api/admin/x", c.route, nil}, sessionFor[c.grant]); rec.Code != http.StatusTeapot {
+			t.Errorf("%q grant on %q route: status %d", c.grant, c.route, rec.Code)
+		}
+	}
+
+	// Through the real mux, no route is reachable without a session.
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux)
+	for _, rt := range adminRoutes() {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, rt.Pattern, nil))
+		if rec.Code != http.StatusUnauthorized && rec.Code != http.StatusFound {
+			t.Errorf("%s without session: status %d", rt.Pattern, rec.Code)
+		}
+	}
+}
+
+func TestPermissionAuthRejectsUnknownPermission(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("permissionAuth accepted an unregistered permission")
+		}
+	}()
+	permissionAuth("setings")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// adminStatsTTL is how long GET /api/admin/stats serves a computed summary
+// before recomputing it.
+const adminStatsTTL = time.Minute
+
+// AdminSalesDay is one day of pack sales.
+type AdminSalesDay struct {
+	Date    string  `json:"date"`
+	Orders  int     `json:"orders"`
+	Credits float64 `json:"credits"`
+}
+
+// AdminStats is the platform summary shown on the admin dashboard.
+type AdminStats struct {
+	TotalUsers             int             `json:"total_users"`
+	PublishedPacks         int             `json:"published_packs"`
+	ActiveStorefronts      int             `json:"active_storefronts"`
+	PendingReviews         int             `json:"pending_reviews"`
+	PendingCustomProducts  int             `json:"pending_custom_products"`
+	PendingSupportRequests int             `json:"pending_support_requests"`
+	CreditsInCirculation   float64         `json:"credits_in_circulation"`
+	TotalOrders            int             `json:"total_orders"`
+	TotalSales             float64         `json:"total_sales"`
+	Sales7d                float64         `json:"sales_7d"`
+	Sales30d               float64         `json:"sales_30d"`
+	SalesTrend7d           []AdminSalesDay `json:"sales_trend_7d"`
+	SalesTrend30d          []AdminSalesDay `json:"sales_trend_30d"`
+	GeneratedAt            string          `json:"generated_at"`
+}
+
+// adminStatsCache holds the last summary. The lock is held while computing so
+// concurrent requests after expiry share a single computation.
+var adminStatsCache struct {
+	mu        sync.Mutex
+	stats     *AdminStats
+	expiresAt time.Time
+}
+
+// handleAdminStats handles GET /api/admin/stats.
+func handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	adminStatsCache.mu.Lock()
+	defer adminStatsCache.mu.Unlock()
+	now := time.Now()
+	if adminStatsCache.stats == nil || !now.Before(adminStatsCache.expiresAt) {
+		stats, err := computeAdminStats(now)
+		if err != nil {
+			log.Printf("[ADMIN-STATS] failed to compute stats: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+			return
+		}
+		adminStatsCache.stats = stats
+		adminStatsCache.expiresAt = now.Add(adminStatsTTL)
+	}
+	jsonResponse(w, http.StatusOK, adminStatsCache.stats)
+}
+
+// computeAdminStats runs the aggregate queries behind AdminStats. Sales use
+// the same transaction types as the sales report (see buildSalesWhereClause).
+func computeAdminStats(now time.Time) (*AdminStats, error) {
+	var s AdminStats
+	err := db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM users),
+		(SELECT COUNT(*) FROM pack_listings WHERE status = 'published'),
+		(SELECT COUNT(*) FROM author_storefronts WHERE COALESCE(store_status, 'active') = 'active'),
+		(SELECT COUNT(*) FROM pack_listings WHERE status = 'pending'),
+		(SELECT COUNT(*) FROM custom_products WHERE status = 'pending' AND deleted_at IS NULL),
+		(SELECT COUNT(*) FROM storefront_support_requests WHERE status = 'pending'),
+		(SELECT COALESCE(SUM(credits_balance), 0) FROM email_wallets)`).Scan(
+		&s.TotalUsers, &s.PublishedPacks, &s.ActiveStorefronts, &s.PendingReviews,
+		&s.PendingCustomProducts, &s.PendingSupportRequests, &s.CreditsInCirculation)
+	if err != nil {
+		return nil, fmt.Errorf("count totals: %w", err)
+	}
+
+	const salesWhere = "WHERE transaction_type IN ('purchase', 'purchase_uses', 'renew', 'download') AND listing_id IS NOT NULL"
+	if err := db.QueryRow("SELECT COUNT(*), COALESCE(SUM(ABS(amount)), 0) FROM credits_transactions "+salesWhere).Scan(&s.TotalOrders, &s.TotalSales); err != nil {
+		return nil, fmt.Errorf("sum sales: %w", err)
+	}
+
+	// Daily buckets for the last 30 days, today included (UTC, like created_at).
+	today := now.UTC()
+	s.SalesTrend30d = make([]AdminSalesDay, 30)
+	dayIndex := make(map[string]int, 30)
+	for i := range s.SalesTrend30d {
+		d := today.AddDate(0, 0, i-29).Format("2006-01-02")
+		s.SalesTrend30d[i].Date = d
+		dayIndex[d] = i
+	}
+	rows, err := db.Query(`SELECT DATE(created_at), COUNT(*), COALESCE(SUM(ABS(amount)), 0) FROM credits_transactions `+salesWhere+`
+		AND created_at >= ? GROUP BY DATE(created_at)`, s.SalesTrend30d[0].Date)
+	if err != nil {
+		return nil, fmt.Errorf("query sales trend: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		var orders int
+		var credits float64
+		if err := rows.Scan(&day, &orders, &credits); err != nil {
+			return nil, fmt.Errorf("scan sales trend: %w", err)
+		}
+		if i, ok := dayIndex[day]; ok {
+			s.SalesTrend30d[i].Orders = orders
+			s.SalesTrend30d[i].Credits = credits
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query sales trend: %w", err)
+	}
+	s.SalesTrend7d = s.SalesTrend30d[23:]
+	for i, d := range s.SalesTrend30d {
+		s.Sales30d += d.Credits
+		if i >= 23 {
+			s.Sales7d += d.Credits
+		}
+	}
+
+	s.GeneratedAt = now.UTC().Format(time.RFC3339)
+	return &s, nil
+}
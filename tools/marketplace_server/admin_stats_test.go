@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminStats(t *testing.T) {
+	useTestDB(t)
+	t.Cleanup(func() { adminStatsCache.stats = nil })
+	adminStatsCache.stats = nil
+
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Open', 'open')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, store_status) VALUES (11, 2, 'Paused', 'paused', 'paused')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 1, 1, x'00', 'A', 'free', 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (101, 1, 1, x'00', 'B', 'free', 'pending')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance) VALUES ('a@example.com', 40), ('b@example.com', 2.5)")
+	today := time.Now().UTC().Format("2006-01-02 15:04:05")
+	old := time.Now().UTC().AddDate(0, 0, -10).Format("2006-01-02 15:04:05")
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, created_at) VALUES (5, 'purchase', -30, 100, ?)", today)
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, created_at) VALUES (5, 'renew', -20, 100, ?)", old)
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, created_at) VALUES (5, 'topup', 500, ?)", today)
+
+	get := func() AdminStats {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handleAdminStats(rec, httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+		}
+		var s AdminStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	s := get()
+	if s.PublishedPacks != 1 || s.PendingReviews != 1 || s.ActiveStorefronts != 1 || s.CreditsInCirculation != 42.5 {
+		t.Errorf("totals = %+v", s)
+	}
+	if s.TotalOrders != 2 || s.TotalSales != 50 || s.Sales7d != 30 || s.Sales30d != 50 {
+		t.Errorf("sales = %d/%v/%v/%v, want 2/50/30/50", s.TotalOrders, s.TotalSales, s.Sales7d, s.Sales30d)
+	}
+	if len(s.SalesTrend7d) != 7 || len(s.SalesTrend30d) != 30 || s.SalesTrend7d[6].Orders != 1 {
+		t.Errorf("trends = %+v / %+v", s.SalesTrend7d, s.SalesTrend30d)
+	}
+
+	// The summary is served from cache until it expires.
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (102, 1, 1, x'00', 'C', 'free', 'published')")
+	if s := get(); s.PublishedPacks != 1 {
+		t.Errorf("cached published packs = %d, want 1", s.PublishedPacks)
+	}
+	adminStatsCache.expiresAt = time.Now().Add(-time.Second)
+	if s := get(); s.PublishedPacks != 2 {
+		t.Errorf("refreshed published packs = %d, want 2", s.PublishedPacks)
+	}
+}
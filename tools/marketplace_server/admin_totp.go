@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// adminTOTPIssuer is the issuer label shown in authenticator apps.
+const adminTOTPIssuer = "Vantagics Marketplace"
+
+// isAdminTOTPEnabled reports whether the admin has two-factor login turned on.
+func isAdminTOTPEnabled(adminID int64) bool {
+	var enabled int
+	db.QueryRow("SELECT COALESCE(totp_enabled, 0) FROM admin_credentials WHERE id = ?", adminID).Scan(&enabled)
+	return enabled == 1
+}
+
+// verifyAdminSecondFactor checks a TOTP code or, failing that, a one-time
+// recovery code for adminID. Accepted TOTP counters and recovery codes are
+// consumed so they cannot be reused.
+func verifyAdminSecondFactor(adminID int64, code string) bool {
+	var encSecret, recoveryJSON string
+	var lastCounter int64
+	err := db.QueryRow(`SELECT COALESCE(totp_secret, ''), COALESCE(totp_last_counter, 0), COALESCE(totp_recovery_codes, '')
+		FROM admin_credentials WHERE id = ?`, adminID).Scan(&encSecret, &lastCounter, &recoveryJSON)
+	if err != nil || encSecret == "" {
+		return false
+	}
+	secret, err := decryptPayPalSecret(encSecret)
+	if err != nil {
+		log.Printf("[ADMIN-2FA] failed to decrypt secret for admin %d: %v", adminID, err)
+		return false
+	}
+	// Both updates are conditional on the state read above, so of two logins
+	// racing with the same code only the one whose update lands succeeds.
+	if counter, ok := verifyTOTP(secret, code, time.Now(), uint64(lastCounter)); ok {
+		res, err := db.Exec("UPDATE admin_credentials SET totp_last_counter = ? WHERE id = ? AND COALESCE(totp_last_counter, 0) < ?",
+			int64(counter), adminID, int64(counter))
+		if err != nil {
+			log.Printf("[ADMIN-2FA] failed to record counter for admin %d: %v", adminID, err)
+			return false
+		}
+		n, _ := res.RowsAffected()
+		return n == 1
+	}
+	if remaining, ok := consumeRecoveryCode(recoveryJSON, code); ok {
+		res, err := db.Exec("UPDATE admin_credentials SET totp_recovery_codes = ? WHERE id = ? AND COALESCE(totp_recovery_codes, '') = ?",
+			remaining, adminID, recoveryJSON)
+		if err != nil {
+			log.Printf("[ADMIN-2FA] failed to consume recovery code for admin %d: %v", adminID, err)
+			return false
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			return false
+		}
+		log.Printf("[ADMIN-2FA] admin %d used a recovery code (%d left)", adminID, countRecoveryCodes(remaining))
+		return true
+	}
+	return false
+}
+
+// handleAdminTOTP handles the current admin's two-factor settings:
+//
+//	GET  /api/admin/2fa/status  — enabled flag and remaining recovery codes
+//	POST /api/admin/2fa/setup   — generate a pending secret, return it with the otpauth URI
+//	GET  /api/admin/2fa/qr      — PNG QR code of the pending provisioning URI (404 once enabled)
+//	POST /api/admin/2fa/enable  — confirm with a code; returns one-time recovery codes
+//	POST /api/admin/2fa/disable — requires the password and a code
+func handleAdminTOTP(w http.ResponseWriter, r *http.Request) {
+	adminID, err := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	if err != nil || adminID == 0 {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var username, encSecret, recoveryJSON string
+	var enabled int
+	if err := db.QueryRow(`SELECT username, COALESCE(totp_secret, ''), COALESCE(totp_enabled, 0), COALESCE(totp_recovery_codes, '')
+		FROM admin_credentials WHERE id = ?`, adminID).Scan(&username, &encSecret, &enabled, &recoveryJSON); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/admin/2fa/status":
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"enabled":            enabled == 1,
+			"recovery_remaining": countRecoveryCodes(recoveryJSON),
+		})
+
+	case "/api/admin/2fa/setup":
+		if r.Method != http.MethodPost {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		if enabled == 1 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "两步验证已启用，请先停用"})
+			return
+		}
+		secret := generateTOTPSecret()
+		encrypted, err := encryptPayPalSecret(secret)
+		if err != nil {
+			log.Printf("[ADMIN-2FA] failed to encrypt secret: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "服务器加密配置错误"})
+			return
+		}
+		if _, err := db.Exec("UPDATE admin_credentials SET totp_secret = ?, totp_enabled = 0, totp_last_counter = 0 WHERE id = ?", encrypted, adminID); err != nil {
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"secret": secret,
+			"uri":    totpProvisioningURI(adminTOTPIssuer, username, secret),
+		})
+
+	case "/api/admin/2fa/qr":
+		// Only a pending secret is shown; once confirmed the live secret
+		// must not be copyable from an admin session.
+		if encSecret == "" || enabled == 1 {
+			http.NotFound(w, r)
+			return
+		}
+		secret, err := decryptPayPalSecret(encSecret)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		qr, err := encodeQR([]byte(totpProvisioningURI(adminTOTPIssuer, username, secret)))
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		pngData, err := qr.PNG(5)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(pngData)
+
+	case "/api/admin/2fa/enable":
+		if r.Method != http.MethodPost {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var req struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if encSecret == "" || enabled == 1 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请先生成密钥"})
+			return
+		}
+		secret, err := decryptPayPalSecret(encSecret)
+		if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		counter, ok := verifyTOTP(secret, req.Code, time.Now(), 0)
+		if !ok {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "验证码错误"})
+			return
+		}
+		codes, hashesJSON := generateRecoveryCodes()
+		if _, err := db.Exec("UPDATE admin_credentials SET totp_enabled = 1, totp_last_counter = ?, totp_recovery_codes = ? WHERE id = ?",
+			int64(counter), hashesJSON, adminID); err != nil {
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		log.Printf("[ADMIN-2FA] admin %d enabled two-factor authentication", adminID)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "recovery_codes": codes})
+
+	case "/api/admin/2fa/disable":
+		if r.Method != http.MethodPost {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var req struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var passwordHash string
+		db.QueryRow("SELECT password_hash FROM admin_credentials WHERE id = ?", adminID).Scan(&passwordHash)
+		if !checkPassword(req.Password, passwordHash) {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_old_password"})
+			return
+		}
+		if enabled == 1 && !verifyAdminSecondFactor(adminID, req.Code) {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "验证码错误"})
+			return
+		}
+		if _, err := db.Exec("UPDATE admin_credentials SET totp_secret = '', totp_enabled = 0, totp_last_counter = 0, totp_recovery_codes = '' WHERE id = ?", adminID); err != nil {
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		log.Printf("[ADMIN-2FA] admin %d disabled two-factor authentication", adminID)
+		jsonResponse(w, http.StatusOK, map[string]bool{"ok": true})
+
+	default:
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+	}
+}
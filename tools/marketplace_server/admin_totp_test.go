@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"marketplace_server/i18n"
+)
+
+// seedTOTPAdmin creates admin 1 ("root", password "pw") with two-factor
+// login on and returns its TOTP secret and recovery codes.
+func seedTOTPAdmin(t *testing.T) (secret []byte, recovery []string) {
+	t.Helper()
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "totp-test-key")
+	secretB32 := generateTOTPSecret()
+	encrypted, err := encryptPayPalSecret(secretB32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, stored := generateRecoveryCodes()
+	mustExec(t, `INSERT INTO admin_credentials (id, username, password_hash, role, permissions, totp_secret, totp_enabled, totp_last_counter, totp_recovery_codes)
+		VALUES (1, 'root', ?, 'super', '', ?, 1, 0, ?)`, hashPassword("pw"), encrypted, stored)
+	secret, _ = totpBase32.DecodeString(secretB32)
+	return secret, codes
+}
+
+func TestAdminSecondFactorSingleUseUnderRace(t *testing.T) {
+	useTestDB(t)
+	secret, recovery := seedTOTPAdmin(t)
+
+	race := func(code string) int {
+		t.Helper()
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		accepted := 0
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if verifyAdminSecondFactor(1, code) {
+					mu.Lock()
+					accepted++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		return accepted
+	}
+
+	code := totpCodeAt(secret, uint64(time.Now().Unix()/totpPeriod))
+	if n := race(code); n != 1 {
+		t.Errorf("TOTP code accepted %d times, want 1", n)
+	}
+	if verifyAdminSecondFactor(1, code) {
+		t.Error("TOTP code accepted again after the race")
+	}
+	if n := race(recovery[0]); n != 1 {
+		t.Errorf("recovery code accepted %d times, want 1", n)
+	}
+	var stored string
+	db.QueryRow("SELECT totp_recovery_codes FROM admin_credentials WHERE id = 1").Scan(&stored)
+	if countRecoveryCodes(stored) != totpRecoveryCodes-1 {
+		t.Errorf("%d recovery codes left, want %d", countRecoveryCodes(stored), totpRecoveryCodes-1)
+	}
+}
+
+func TestAdminLoginWrongSecondFactorLooksLikeWrongPassword(t *testing.T) {
+	useTestDB(t)
+	resetAdminLoginAttempts(t)
+	seedTOTPAdmin(t)
+
+	login := func(password, totpCode string) string {
+		t.Helper()
+		captchaID := "test-captcha"
+		captchasMu.Lock()
+		captchas[captchaID] = captchaEntry{Code: "ABCD", Expiry: time.Now().Add(time.Minute)}
+		captchasMu.Unlock()
+		form := url.Values{"username": {"root"}, "password": {password}, "captcha_id": {captchaID}, "captcha": {"abcd"}, "totp_code": {totpCode}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handleAdminLogin(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("login: status %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	want := i18n.T(i18n.DetectLang(httptest.NewRequest(http.MethodPost, "/admin/login", nil)), "admin_login_error")
+	wrongPassword := login("nope", "12345")
+	wrongCode := login("pw", "12345")
+	if !strings.Contains(wrongPassword, want) || !strings.Contains(wrongCode, want) {
+		t.Errorf("wrong second factor should show %q like a wrong password", want)
+	}
+}
+
+func TestAdminTOTPQROnlyForPendingSecret(t *testing.T) {
+	useTestDB(t)
+	seedTOTPAdmin(t)
+
+	qr := func() *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/2fa/qr", nil)
+		req.Header.Set("X-Admin-ID", "1")
+		rec := httptest.NewRecorder()
+		handleAdminTOTP(rec, req)
+		return rec
+	}
+
+	if rec := qr(); rec.Code != http.StatusNotFound {
+		t.Errorf("QR of the enabled secret: status %d, want 404", rec.Code)
+	}
+	mustExec(t, "UPDATE admin_credentials SET totp_enabled = 0 WHERE id = 1")
+	if rec := qr(); rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("QR of a pending secret: status %d, type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
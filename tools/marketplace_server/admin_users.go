@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Admin user browser. Unlike /api/admin/accounts, which merges accounts by
+// email, this lists individual user rows so that large user tables can be
+// filtered, sorted and paged in SQL. Balances come from email_wallets, the
+// same wallet the user portal shows; users without an email fall back to
+// users.credits_balance. Deleted (anonymized) accounts are not listed.
+const (
+	defaultAdminUserPageSize = 50
+	maxAdminUserPageSize     = 200
+	maxAdminUserExportRows   = 50000
+)
+
+// adminUserSortColumns maps the sort parameter to its ORDER BY expression.
+// Every sortable column except balance is indexed.
+var adminUserSortColumns = map[string]string{
+	"id":           "u.id",
+	"email":        "u.email",
+	"display_name": "u.display_name",
+	"created_at":   "u.created_at",
+	"balance":      "balance",
+}
+
+// AdminUserInfo is one row of the admin user list.
+type AdminUserInfo struct {
+	ID           int64   `json:"id"`
+	Email        string  `json:"email"`
+	DisplayName  string  `json:"display_name"`
+	Username     string  `json:"username"`
+	AuthType     string  `json:"auth_type"`
+	IsBlocked    bool    `json:"is_blocked"`
+	StorefrontID int64   `json:"storefront_id,omitempty"`
+	StoreName    string  `json:"store_name,omitempty"`
+	Balance      float64 `json:"balance"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+// AdminUserListResponse is the paginated result of GET /api/admin/users.
+type AdminUserListResponse struct {
+	Items    []AdminUserInfo `json:"items"`
+	Total    int             `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+}
+
+// adminUserFilter holds the filters shared by the list and the export.
+type adminUserFilter struct {
+	Search        string
+	Blocked       string // "", "true" or "false"
+	HasStorefront string // "", "true" or "false"
+	MinBalance    float64
+	Sort          string
+	Order         string
+}
+
+// parseAdminUserFilter reads the filter and sort query parameters and returns
+// the error message to show for invalid values, or "".
+func parseAdminUserFilter(r *http.Request) (adminUserFilter, string) {
+	q := r.URL.Query()
+	f := adminUserFilter{
+		Search:        strings.TrimSpace(q.Get("search")),
+		Blocked:       strings.ToLower(strings.TrimSpace(q.Get("blocked"))),
+		HasStorefront: strings.ToLower(strings.TrimSpace(q.Get("has_storefront"))),
+		Sort:          strings.TrimSpace(q.Get("sort")),
+		Order:         strings.ToLower(strings.TrimSpace(q.Get("order"))),
+	}
+	if f.Blocked != "" && f.Blocked != "true" && f.Blocked != "false" {
+		return f, "blocked must be true or false"
+	}
+	if f.HasStorefront != "" && f.HasStorefront != "true" && f.HasStorefront != "false" {
+		return f, "has_storefront must be true or false"
+	}
+	if s := strings.TrimSpace(q.Get("min_balance")); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 {
+			return f, "min_balance must be a non-negative number"
+		}
+		f.MinBalance = v
+	}
+	if f.Sort == "" {
+		f.Sort = "created_at"
+	}
+	if _, ok := adminUserSortColumns[f.Sort]; !ok {
+		return f, "invalid sort column"
+	}
+	if f.Order != "asc" {
+		f.Order = "desc"
+	}
+	return f, ""
+}
+
+// query builds the SELECT of the users matching f, sorted, without LIMIT.
+// Ties are broken by id so pages are stable.
+func (f adminUserFilter) query() (string, []interface{}) {
+	conds := []string{"u.deleted_at IS NULL"}
+	var args []interface{}
+	if f.Search != "" {
+		conds = append(conds, "(u.email LIKE ? OR u.display_name LIKE ? OR u.username LIKE ?)")
+		like := "%" + f.Search + "%"
+		args = append(args, like, like, like)
+	}
+	switch f.Blocked {
+	case "true":
+		conds = append(conds, "u.is_blocked = 1")
+	case "false":
+		conds = append(conds, "COALESCE(u.is_blocked, 0) = 0")
+	}
+	switch f.HasStorefront {
+	case "true":
+		conds = append(conds, "s.id IS NOT NULL")
+	case "false":
+		conds = append(conds, "s.id IS NULL")
+	}
+	if f.MinBalance > 0 {
+		conds = append(conds, "balance >= ?")
+		args = append(args, f.MinBalance)
+	}
+	order := strings.ToUpper(f.Order)
+	return `SELECT u.id, COALESCE(u.email, ''), COALESCE(u.display_name, ''), COALESCE(u.username, ''), u.auth_type,
+			COALESCE(u.is_blocked, 0), COALESCE(s.id, 0), COALESCE(s.store_name, ''),
+			CASE WHEN COALESCE(u.email, '') = '' THEN COALESCE(u.credits_balance, 0) ELSE COALESCE(w.credits_balance, 0) END AS balance,
+			COALESCE(u.created_at, '')
+		FROM users u
+		LEFT JOIN email_wallets w ON w.email = u.email
+		LEFT JOIN author_storefronts s ON s.user_id = u.id
+		WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY ` + adminUserSortColumns[f.Sort] + " " + order + ", u.id " + order, args
+}
+
+// queryAdminUsers runs f with the given LIMIT and OFFSET.
+func queryAdminUsers(f adminUserFilter, limit, offset int) ([]AdminUserInfo, error) {
+	query, args := f.query()
+	rows, err := db.Query(query+" LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []AdminUserInfo{}
+	for rows.Next() {
+		var u AdminUserInfo
+		var blocked int
+		if err := rows.Scan(&u.ID, &u.Email, &u.DisplayName, &u.Username, &u.AuthType, &blocked,
+			&u.StorefrontID, &u.StoreName, &u.Balance, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		u.IsBlocked = blocked == 1
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// handleAdminUsers handles
+// GET /api/admin/users?search=&blocked=&has_storefront=&min_balance=&sort=created_at&order=desc&page=1&page_size=50
+// and dispatches /api/admin/users/export.
+func handleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/admin/users/export" {
+		handleAdminUsersExport(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	f, msg := parseAdminUserFilter(r)
+	if msg != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = defaultAdminUserPageSize
+	}
+	if pageSize > maxAdminUserPageSize {
+		pageSize = maxAdminUserPageSize
+	}
+
+	query, args := f.query()
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM ("+query+")", args...).Scan(&total); err != nil {
+		log.Printf("[ADMIN-USERS] count query error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "查询失败"})
+		return
+	}
+	users, err := queryAdminUsers(f, pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("[ADMIN-USERS] query error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "查询失败"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, AdminUserListResponse{
+		Items:    users,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// adminUserExportHeaders are the column titles of the user export.
+var adminUserExportHeaders = []string{
+	"用户 ID", "邮箱", "显示名称", "用户名", "登录方式", "状态", "小铺 ID", "小铺名称", "积分余额", "注册时间",
+}
+
+// handleAdminUsersExport handles GET /api/admin/users/export with the same
+// filters and sort as the list and returns up to maxAdminUserExportRows users
+// as an Excel file.
+func handleAdminUsersExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	f, msg := parseAdminUserFilter(r)
+	if msg != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	users, err := queryAdminUsers(f, maxAdminUserExportRows, 0)
+	if err != nil {
+		log.Printf("[ADMIN-USERS] export query error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "查询失败"})
+		return
+	}
+
+	xf := excelize.NewFile()
+	defer xf.Close()
+	sheetName := "用户"
+	xf.SetSheetName("Sheet1", sheetName)
+	for i, h := range adminUserExportHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		xf.SetCellValue(sheetName, cell, h)
+	}
+	for rowIdx, u := range users {
+		status := "正常"
+		if u.IsBlocked {
+			status = "已封禁"
+		}
+		var storefrontID interface{} = ""
+		if u.StorefrontID > 0 {
+			storefrontID = u.StorefrontID
+		}
+		cells := []interface{}{u.ID, u.Email, u.DisplayName, u.Username, u.AuthType, status,
+			storefrontID, u.StoreName, u.Balance, exportTimestamp(u.CreatedAt)}
+		for i, val := range cells {
+			cell, _ := excelize.CoordinatesToCellName(i+1, rowIdx+2)
+			xf.SetCellValue(sheetName, cell, val)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := xf.Write(&buf); err != nil {
+		log.Printf("[ADMIN-USERS] write Excel error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "导出失败"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users_%s.xlsx"`, time.Now().Format("20060102")))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func seedAdminUsers(t *testing.T) {
+	t.Helper()
+	mustExec(t, `INSERT INTO users (id, auth_type, auth_id, display_name, email, username, is_blocked, created_at) VALUES
+		(1, 'email', 'a', 'Alice', 'alice@example.com', 'alice', 0, '2026-01-01 00:00:00'),
+		(2, 'email', 'b', 'Bob', 'bob@example.com', 'bobby', 1, '2026-02-01 00:00:00'),
+		(3, 'sn', 'c', 'Carol', 'carol@example.com', NULL, 0, '2026-03-01 00:00:00'),
+		(4, 'sn', 'd', 'Dave', NULL, NULL, 0, '2026-04-01 00:00:00')`)
+	mustExec(t, "UPDATE users SET credits_balance = 7 WHERE id = 4")
+	mustExec(t, "UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = 3")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (5, 'sn', 'e', 'Eve', 'eve@example.com')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance) VALUES ('alice@example.com', 500), ('bob@example.com', 20), ('eve@example.com', 100)")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Alice Shop', 'alice')")
+}
+
+func listAdminUsers(t *testing.T, query string) (int, AdminUserListResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleAdminUsers(rec, httptest.NewRequest(http.MethodGet, "/api/admin/users?"+query, nil))
+	var resp AdminUserListResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func adminUserIDs(users []AdminUserInfo) []int64 {
+	ids := []int64{}
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func TestAdminUsersFiltersAndSort(t *testing.T) {
+	useTestDB(t)
+	seedAdminUsers(t)
+
+	cases := []struct {
+		query string
+		want  []int64
+	}{
+		{"", []int64{5, 4, 2, 1}},
+		{"search=bobby", []int64{2}},
+		{"search=example.com&sort=email&order=asc", []int64{1, 2, 5}},
+		{"blocked=true", []int64{2}},
+		{"blocked=false&sort=id&order=asc", []int64{1, 4, 5}},
+		{"has_storefront=true", []int64{1}},
+		{"has_storefront=false&sort=id&order=asc", []int64{2, 4, 5}},
+		{"min_balance=50&sort=balance", []int64{1, 5}},
+		{"sort=balance&order=asc", []int64{4, 2, 5, 1}},
+	}
+	for _, c := range cases {
+		code, resp := listAdminUsers(t, c.query)
+		if code != http.StatusOK {
+			t.Fatalf("%q: status %d", c.query, code)
+		}
+		if got := adminUserIDs(resp.Items); len(got) != len(c.want) || resp.Total != len(c.want) {
+			t.Errorf("%q: got %v (total %d), want %v", c.query, got, resp.Total, c.want)
+		} else {
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Errorf("%q: got %v, want %v", c.query, got, c.want)
+					break
+				}
+			}
+		}
+	}
+
+	_, resp := listAdminUsers(t, "sort=id&order=asc")
+	if u := resp.Items[0]; u.Balance != 500 || u.StorefrontID != 10 || u.StoreName != "Alice Shop" {
+		t.Errorf("alice = %+v, want wallet balance 500 and storefront 10", u)
+	}
+	if u := resp.Items[2]; u.Balance != 7 {
+		t.Errorf("user without email balance = %v, want 7 from users.credits_balance", u.Balance)
+	}
+
+	for _, bad := range []string{"sort=password_hash", "blocked=maybe", "has_storefront=1", "min_balance=-1"} {
+		if code, _ := listAdminUsers(t, bad); code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", bad, code)
+		}
+	}
+}
+
+func TestAdminUsersPagination(t *testing.T) {
+	useTestDB(t)
+	seedAdminUsers(t)
+
+	_, first := listAdminUsers(t, "sort=id&order=asc&page_size=3")
+	_, second := listAdminUsers(t, "sort=id&order=asc&page_size=3&page=2")
+	if first.Total != 4 || first.PageSize != 3 || len(first.Items) != 3 {
+		t.Fatalf("page 1 = %+v", first)
+	}
+	if second.Page != 2 || len(second.Items) != 1 || second.Items[0].ID != 5 {
+		t.Fatalf("page 2 = %+v", second)
+	}
+}
+
+func TestAdminUsersExport(t *testing.T) {
+	useTestDB(t)
+	seedAdminUsers(t)
+
+	rec := httptest.NewRecorder()
+	handleAdminUsers(rec, httptest.NewRequest(http.MethodGet, "/api/admin/users/export?blocked=false&sort=id&order=asc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, "spreadsheetml") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	f, err := excelize.OpenReader(rec.Body)
+	if err != nil {
+		t.Fatalf("open xlsx: %v", err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows("用户")
+	if err != nil {
+		t.Fatalf("GetRows: %v", err)
+	}
+	if len(rows) != 4 || rows[0][0] != "用户 ID" {
+		t.Fatalf("rows = %v, want header + 3 users", rows)
+	}
+	if rows[1][1] != "alice@example.com" || rows[1][7] != "Alice Shop" || rows[1][8] != "500" {
+		t.Errorf("first row = %v", rows[1])
+	}
+}
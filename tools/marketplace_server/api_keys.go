@@ -0,0 +1,347 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API keys let scripts and CI jobs call the API without a browser session or
+// a client JWT. A key is sent as "Authorization: Bearer vak_..." and only its
+// hash is stored in api_keys. Keys belong to a user or to an admin:
+//
+//   - User keys carry apiKeyScopes and are accepted only on routes wrapped in
+//     apiKeyAuth, which names the scope the route needs. Other user routes
+//     keep accepting the client JWT only.
+//   - Admin keys carry admin permissions as scopes and are accepted by
+//     permissionAuth on /api/ routes. The route's permission must be among the
+//     key's scopes and still be held by the admin, so revoking a permission
+//     also narrows the admin's keys. Routes open to any admin, or to the super
+//     admin only, never accept keys; in particular keys cannot create keys.
+const (
+	apiKeyPrefix       = "vak_"
+	maxAPIKeysPerOwner = 20
+	maxAPIKeyDays      = 3650
+)
+
+// User API key scopes.
+const (
+	APIScopeRead     = "read"     // account, library and license data
+	APIScopePublish  = "publish"  // upload, replace and re-key own packs
+	APIScopeDownload = "download" // download packs, which may spend credits
+)
+
+// apiKeyScopes are the scopes a user key can be given.
+var apiKeyScopes = []string{APIScopeRead, APIScopePublish, APIScopeDownload}
+
+// apiKey is an active key as loaded for authentication.
+type apiKey struct {
+	ID        int64
+	OwnerType string // "user" or "admin"
+	OwnerID   int64
+	Scopes    []string
+}
+
+func (k *apiKey) hasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerAPIKey returns the API key sent in the Authorization header, if the
+// bearer token is one.
+func bearerAPIKey(r *http.Request) (string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return "", false
+	}
+	return token, true
+}
+
+// lookupAPIKey returns the unrevoked, unexpired key matching raw and records
+// its use, or nil if there is none.
+func lookupAPIKey(raw string, now time.Time) (*apiKey, error) {
+	k := &apiKey{}
+	var scopes string
+	err := db.QueryRow(`SELECT id, owner_type, owner_id, scopes FROM api_keys
+		WHERE key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+		hashEmailToken(raw), now.UTC().Format("2006-01-02 15:04:05")).Scan(&k.ID, &k.OwnerType, &k.OwnerID, &scopes)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	k.Scopes = splitAPIKeyScopes(scopes)
+	db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", now.UTC().Format("2006-01-02 15:04:05"), k.ID)
+	return k, nil
+}
+
+func splitAPIKeyScopes(s string) []string {
+	var scopes []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			scopes = append(scopes, v)
+		}
+	}
+	return scopes
+}
+
+// apiKeyAuth authenticates a user route that also accepts user API keys
+// with the given scope. Requests without an API key go through
+// authMiddleware unchanged.
+func apiKeyAuth(scope string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		jwtAuth := authMiddleware(next)
+		return func(w http.ResponseWriter, r *http.Request) {
+			raw, ok := bearerAPIKey(r)
+			if !ok {
+				jwtAuth(w, r)
+				return
+			}
+			key, err := lookupAPIKey(raw, time.Now())
+			if err != nil {
+				log.Printf("[API-KEY] lookup failed: %v", err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+			if key == nil || key.OwnerType != "user" {
+				jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+			if !key.hasScope(scope) {
+				jsonResponse(w, http.StatusForbidden, map[string]string{"error": "insufficient_scope", "scope": scope})
+				return
+			}
+			var displayName string
+			db.QueryRow("SELECT COALESCE(display_name, '') FROM users WHERE id = ?", key.OwnerID).Scan(&displayName)
+			r.Header.Set("X-User-ID", strconv.FormatInt(key.OwnerID, 10))
+			r.Header.Set("X-Display-Name", displayName)
+			if b := userBlockStatus(key.OwnerID, time.Now()); b.Blocked {
+				writeBlockedJSON(w, r, b)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// authenticateAdminAPIKey resolves an admin key for a route requiring perm.
+// It returns the admin ID, or the status and error code to respond with.
+func authenticateAdminAPIKey(raw string, perm Permission) (int64, int, string) {
+	key, err := lookupAPIKey(raw, time.Now())
+	if err != nil {
+		log.Printf("[API-KEY] lookup failed: %v", err)
+		return 0, http.StatusInternalServerError, "internal_error"
+	}
+	if key == nil || key.OwnerType != "admin" {
+		return 0, http.StatusUnauthorized, "unauthorized"
+	}
+	granted := key.hasScope(string(perm))
+	for _, alias := range permissionAliases[perm] {
+		granted = granted || key.hasScope(string(alias))
+	}
+	if !granted {
+		return 0, http.StatusForbidden, "insufficient_scope"
+	}
+	if !hasPermission(key.OwnerID, perm) {
+		return 0, http.StatusForbidden, "permission_denied"
+	}
+	return key.OwnerID, 0, ""
+}
+
+// createAPIKey stores a new key and returns the raw key, which is shown to
+// the owner once and cannot be recovered afterwards.
+func createAPIKey(ownerType string, ownerID int64, name string, scopes []string, days int) (int64, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return 0, "", err
+	}
+	raw := apiKeyPrefix + hex.EncodeToString(b)
+	var expiresAt interface{}
+	if days > 0 {
+		expiresAt = time.Now().UTC().AddDate(0, 0, days).Format("2006-01-02 15:04:05")
+	}
+	res, err := db.Exec(`INSERT INTO api_keys (key_hash, key_prefix, name, owner_type, owner_id, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		hashEmailToken(raw), raw[:len(apiKeyPrefix)+6], name, ownerType, ownerID, strings.Join(scopes, ","), expiresAt)
+	if err != nil {
+		return 0, "", err
+	}
+	id, _ := res.LastInsertId()
+	return id, raw, nil
+}
+
+// APIKeyInfo is a key as listed to its owner; the key itself is never shown
+// again after creation.
+type APIKeyInfo struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+func listAPIKeys(ownerType string, ownerID int64) ([]APIKeyInfo, error) {
+	rows, err := db.Query(`SELECT id, name, key_prefix, scopes, COALESCE(last_used_at, ''), COALESCE(expires_at, ''),
+			COALESCE(revoked_at, ''), created_at
+		FROM api_keys WHERE owner_type = ? AND owner_id = ? ORDER BY id DESC`, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []APIKeyInfo{}
+	for rows.Next() {
+		var k APIKeyInfo
+		var scopes string
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &k.LastUsedAt, &k.ExpiresAt, &k.RevokedAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		k.Scopes = splitAPIKeyScopes(scopes)
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// handleAPIKeys serves GET/POST {base} and POST {base}/{id}/revoke for the
+// keys of one owner. allowed reports whether a scope may be granted.
+func handleAPIKeys(w http.ResponseWriter, r *http.Request, base, ownerType string, ownerID int64, allowed func(string) bool) (created, revoked int64) {
+	path := strings.TrimPrefix(r.URL.Path, base)
+	if strings.HasSuffix(path, "/revoke") {
+		if r.Method != http.MethodPost {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		id, err := strconv.ParseInt(strings.Trim(strings.TrimSuffix(path, "/revoke"), "/"), 10, 64)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_id"})
+			return
+		}
+		res, err := db.Exec(`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND owner_type = ? AND owner_id = ? AND revoked_at IS NULL`, id, ownerType, ownerID)
+		if err != nil {
+			log.Printf("[API-KEY] failed to revoke key %d: %v", id, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "key not found"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+		return 0, id
+	}
+	if path != "" && path != "/" {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := listAPIKeys(ownerType, ownerID)
+		if err != nil {
+			log.Printf("[API-KEY] failed to list keys: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, keys)
+	case http.MethodPost:
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" || len([]rune(name)) > 100 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "name is required (max 100 characters)"})
+			return
+		}
+		scopes := splitAPIKeyScopes(r.FormValue("scopes"))
+		if len(scopes) == 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "at least one scope is required"})
+			return
+		}
+		for _, s := range scopes {
+			if !allowed(s) {
+				jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("scope %q is not allowed", s)})
+				return
+			}
+		}
+		days := 0
+		if v := strings.TrimSpace(r.FormValue("expires_days")); v != "" {
+			d, err := strconv.Atoi(v)
+			if err != nil || d < 0 || d > maxAPIKeyDays {
+				jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("expires_days must be between 0 and %d", maxAPIKeyDays)})
+				return
+			}
+			days = d
+		}
+		var active int
+		db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE owner_type = ? AND owner_id = ? AND revoked_at IS NULL",
+			ownerType, ownerID).Scan(&active)
+		if active >= maxAPIKeysPerOwner {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("at most %d active keys are allowed", maxAPIKeysPerOwner)})
+			return
+		}
+		id, raw, err := createAPIKey(ownerType, ownerID, name, scopes, days)
+		if err != nil {
+			log.Printf("[API-KEY] failed to create key: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"id": id, "key": raw, "scopes": scopes})
+		return id, 0
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+	return
+}
+
+// handleUserAPIKeys handles /user/api-keys and /user/api-keys/{id}/revoke.
+func handleUserAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	allowed := func(s string) bool {
+		for _, v := range apiKeyScopes {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+	created, revoked := handleAPIKeys(w, r, "/user/api-keys", "user", userID, allowed)
+	if created != 0 {
+		log.Printf("[API-KEY] user %d created key %d", userID, created)
+	}
+	if revoked != 0 {
+		log.Printf("[API-KEY] user %d revoked key %d", userID, revoked)
+	}
+}
+
+// handleAdminAPIKeys handles /api/admin/api-keys and
+// /api/admin/api-keys/{id}/revoke for the signed-in admin's own keys. Scopes
+// are permissions the admin holds.
+func handleAdminAPIKeys(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	allowed := func(s string) bool {
+		_, ok := lookupPermission(Permission(s))
+		return ok && hasPermission(adminID, Permission(s))
+	}
+	created, revoked := handleAPIKeys(w, r, "/api/admin/api-keys", "admin", adminID, allowed)
+	if created != 0 {
+		recordAuditLog(adminID, "api_key_create", fmt.Sprintf("api_key:%d", created), r.FormValue("scopes"), getClientIP(r))
+	}
+	if revoked != 0 {
+		recordAuditLog(adminID, "api_key_revoke", fmt.Sprintf("api_key:%d", revoked), "", getClientIP(r))
+	}
+}
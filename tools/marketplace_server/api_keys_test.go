@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func createTestAPIKey(t *testing.T, h http.HandlerFunc, path, header, ownerID, scopes string) string {
+	t.Helper()
+	form := url.Values{"name": {"ci"}, "scopes": {scopes}}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(header, ownerID)
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	var body struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(body.Key, apiKeyPrefix) {
+		t.Fatalf("create key: %d %s", rec.Code, rec.Body.String())
+	}
+	return body.Key
+}
+
+func TestUserAPIKeyScopes(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (7, 'sn', 'ci', 'CI Bot')")
+
+	key := createTestAPIKey(t, handleUserAPIKeys, "/user/api-keys", "X-User-ID", "7", "read")
+	var stored int
+	db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE key_hash = ? OR key_prefix = ?", key, key).Scan(&stored)
+	if stored != 0 {
+		t.Fatal("raw key stored in the database")
+	}
+
+	var gotUser string
+	protected := func(scope string) http.HandlerFunc {
+		return apiKeyAuth(scope)(func(w http.ResponseWriter, r *http.Request) {
+			gotUser = r.Header.Get("X-User-ID")
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	do := func(scope, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/credits/balance", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		protected(scope)(rec, req)
+		return rec.Code
+	}
+	if code := do(APIScopeRead, key); code != http.StatusNoContent || gotUser != "7" {
+		t.Fatalf("read route: status %d, user %q", code, gotUser)
+	}
+	if code := do(APIScopePublish, key); code != http.StatusForbidden {
+		t.Errorf("publish route with read key: status %d, want 403", code)
+	}
+	var lastUsed string
+	db.QueryRow("SELECT COALESCE(last_used_at, '') FROM api_keys WHERE owner_id = 7").Scan(&lastUsed)
+	if lastUsed == "" {
+		t.Error("last_used_at not recorded")
+	}
+
+	// Scopes outside the user list are refused at creation.
+	form := url.Values{"name": {"x"}, "scopes": {"settings"}}
+	req := httptest.NewRequest(http.MethodPost, "/user/api-keys", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-User-ID", "7")
+	rec := httptest.NewRecorder()
+	handleUserAPIKeys(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("admin scope on user key: status %d, want 400", rec.Code)
+	}
+
+	// Expired and revoked keys stop working.
+	mustExec(t, "UPDATE api_keys SET expires_at = '2000-01-01 00:00:00'")
+	if code := do(APIScopeRead, key); code != http.StatusUnauthorized {
+		t.Errorf("expired key: status %d, want 401", code)
+	}
+	mustExec(t, "UPDATE api_keys SET expires_at = NULL")
+	var id string
+	db.QueryRow("SELECT id FROM api_keys WHERE owner_id = 7").Scan(&id)
+	req = httptest.NewRequest(http.MethodPost, "/user/api-keys/"+id+"/revoke", nil)
+	req.Header.Set("X-User-ID", "7")
+	rec = httptest.NewRecorder()
+	handleUserAPIKeys(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke: %d %s", rec.Code, rec.Body.String())
+	}
+	if code := do(APIScopeRead, key); code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status %d, want 401", code)
+	}
+}
+
+func TestAdminAPIKeyPermissions(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO admin_credentials (id, username, password_hash, role, permissions) VALUES (1, 'root', 'x', 'super', '')")
+	mustExec(t, "INSERT INTO admin_credentials (id, username, password_hash, role, permissions) VALUES (2, 'ops', 'x', 'regular', 'marketplace,review')")
+
+	key := createTestAPIKey(t, handleAdminAPIKeys, "/api/admin/api-keys", "X-Admin-ID", "2", "marketplace")
+
+	var gotAdmin string
+	route := func(perm Permission) http.HandlerFunc {
+		return permissionAuth(perm)(func(w http.ResponseWriter, r *http.Request) {
+			gotAdmin = r.Header.Get("X-Admin-ID")
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	do := func(perm Permission, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		route(perm)(rec, req)
+		return rec.Code
+	}
+	if code := do(PermMarketplace, "/api/admin/marketplace"); code != http.StatusNoContent || gotAdmin != "2" {
+		t.Fatalf("scoped route: status %d, admin %q", code, gotAdmin)
+	}
+	if code := do(PermReview, "/api/admin/review/pending"); code != http.StatusForbidden {
+		t.Errorf("permission held but not in key scopes: status %d, want 403", code)
+	}
+	// Non-API admin pages never accept keys.
+	if code := do(PermMarketplace, "/admin/settings/pack-upload"); code == http.StatusNoContent {
+		t.Error("key accepted on a non-API route")
+	}
+	// Losing the permission disables the key for it.
+	mustExec(t, "UPDATE admin_credentials SET permissions = 'review' WHERE id = 2")
+	if code := do(PermMarketplace, "/api/admin/marketplace"); code != http.StatusForbidden {
+		t.Errorf("revoked permission: status %d, want 403", code)
+	}
+	// User routes do not accept admin keys.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/credits/balance", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	apiKeyAuth(APIScopeRead)(func(w http.ResponseWriter, r *http.Request) {})(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("admin key on user route: status %d, want 401", rec.Code)
+	}
+
+	// Admins cannot grant permissions they lack.
+	form := url.Values{"name": {"x"}, "scopes": {"settings"}}
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/api-keys", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Admin-ID", "2")
+	rec = httptest.NewRecorder()
+	handleAdminAPIKeys(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("ungranted scope: status %d, want 400", rec.Code)
+	}
+}
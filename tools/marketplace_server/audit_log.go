@@ -0,0 +1,13 @@
+package main
+
+import "log"
+
+// recordAuditLog appends an entry to admin_audit_log. adminID is 0 when the
+// action was not performed by a logged-in admin (e.g. a login lockout).
+// Failures are logged but never block the action being audited.
+func recordAuditLog(adminID int64, action, target, detail, ip string) {
+	if _, err := db.Exec(`INSERT INTO admin_audit_log (admin_id, action, target, detail, ip_address) VALUES (?, ?, ?, ?, ?)`,
+		adminID, action, target, detail, ip); err != nil {
+		log.Printf("[AUDIT] failed to record %s on %q: %v", action, target, err)
+	}
+}
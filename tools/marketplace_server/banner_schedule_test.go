@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsBannerActiveWindow(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)
+	banner := CustomBannerSettings{
+		Text:    "Spring sale",
+		StartAt: start.Format(time.RFC3339),
+		EndAt:   end.Format(time.RFC3339),
+	}
+
+	cases := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"before window", start.Add(-time.Minute), false},
+		{"at window start", start, true},
+		{"in window", start.Add(72 * time.Hour), true},
+		{"at window end", end, false},
+		{"after window", end.Add(time.Hour), false},
+	}
+	for _, tc := range cases {
+		if got := isBannerActive(banner, tc.now); got != tc.want {
+			t.Errorf("%s: isBannerActive = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIsBannerActiveOpenEnded(t *testing.T) {
+	now := time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	if !isBannerActive(CustomBannerSettings{Text: "always"}, now) {
+		t.Error("banner without a window should always be active")
+	}
+	if !isBannerActive(CustomBannerSettings{StartAt: now.Add(-time.Hour).Format(time.RFC3339)}, now) {
+		t.Error("banner with only a past start_at should be active")
+	}
+	if isBannerActive(CustomBannerSettings{EndAt: now.Add(-time.Hour).Format(time.RFC3339)}, now) {
+		t.Error("banner with only a past end_at should be inactive")
+	}
+	if isBannerActive(CustomBannerSettings{StartAt: "next tuesday"}, now) {
+		t.Error("banner with an unparseable window should be inactive")
+	}
+}
+
+func TestNextBannerChange(t *testing.T) {
+	now := time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC)
+	soon := now.Add(10 * time.Minute)
+	later := now.Add(48 * time.Hour)
+
+	banners := []CustomBannerSettings{
+		{Text: "no schedule"},
+		{StartAt: now.Add(-time.Hour).Format(time.RFC3339), EndAt: later.Format(time.RFC3339)},
+		{StartAt: soon.Format(time.RFC3339)},
+	}
+	if got := nextBannerChange(banners, now); !got.Equal(soon) {
+		t.Errorf("nextBannerChange = %v, want %v", got, soon)
+	}
+	if got := nextBannerChange([]CustomBannerSettings{{Text: "no schedule"}}, now); !got.IsZero() {
+		t.Errorf("expected zero time without scheduled banners, got %v", got)
+	}
+}
+
+func TestValidateLayoutConfigBannerWindow(t *testing.T) {
+	base := `{"sections":[{"type":"hero","visible":true,"settings":{}},{"type":"pack_grid","visible":true,"settings":{"columns":2}},`
+
+	valid := base + `{"type":"custom_banner","visible":true,"settings":{"text":"hi","start_at":"2026-03-01T00:00:00Z","end_at":"2026-03-08T00:00:00Z"}}]}`
+	if msg := ValidateLayoutConfig(valid); msg != "" {
+		t.Errorf("expected valid config, got %q", msg)
+	}
+
+	reversed := base + `{"type":"custom_banner","visible":true,"settings":{"text":"hi","start_at":"2026-03-08T00:00:00Z","end_at":"2026-03-01T00:00:00Z"}}]}`
+	if msg := ValidateLayoutConfig(reversed); msg == "" {
+		t.Error("expected end_at before start_at to be rejected")
+	}
+
+	malformed := base + `{"type":"custom_banner","visible":true,"settings":{"text":"hi","start_at":"2026/03/01"}}]}`
+	if msg := ValidateLayoutConfig(malformed); msg == "" {
+		t.Error("expected malformed start_at to be rejected")
+	}
+}
+
+func TestSetStorefrontDataCapsTTLForScheduledBanners(t *testing.T) {
+	c := NewCache(DefaultCacheConfig())
+
+	c.SetStorefrontData("sf:plain::::", &StorefrontPublicData{})
+	c.SetStorefrontData("sf:scheduled::::", &StorefrontPublicData{ExpiresAt: time.Now().Add(30 * time.Second)})
+
+	if ttl := c.storefronts["sf:plain::::"].ttl; ttl != DefaultCacheConfig().StorefrontTTL {
+		t.Errorf("plain storefront ttl = %v, want default", ttl)
+	}
+	if ttl := c.storefronts["sf:scheduled::::"].ttl; ttl > 30*time.Second {
+		t.Errorf("scheduled storefront ttl = %v, want <= 30s", ttl)
+	}
+}
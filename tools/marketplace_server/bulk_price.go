@@ -0,0 +1,294 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxBulkPriceItems    = 500
+	minBulkPricePercent  = -90
+	maxBulkPricePercent  = 500
+	maxBulkPriceBodySize = 1 << 20 // 1MB
+)
+
+// BulkPriceItem is one explicit price change: exactly one of ListingID (pack,
+// price in credits) or ProductID (custom product, price in USD) is set.
+type BulkPriceItem struct {
+	ListingID int64   `json:"listing_id,omitempty"`
+	ProductID int64   `json:"product_id,omitempty"`
+	NewPrice  float64 `json:"new_price"`
+}
+
+// BulkPriceResult reports the outcome of one item.
+type BulkPriceResult struct {
+	ItemType string  `json:"item_type"` // "pack" or "custom_product"
+	ItemID   int64   `json:"item_id"`
+	OldPrice float64 `json:"old_price"`
+	NewPrice float64 `json:"new_price"`
+	Status   string  `json:"status"` // "updated", "unchanged" or "error"
+	Error    string  `json:"error,omitempty"`
+}
+
+// handleAuthorBulkPrice handles POST /user/author/bulk-price.
+// The JSON body either lists explicit changes ({"items": [{"listing_id": 1,
+// "new_price": 50}, {"product_id": 2, "new_price": 9.99}]}) or gives a
+// percentage applied to every paid pack and custom product of the caller
+// ({"percent": -10}). Valid items are updated in one transaction and logged to
+// price_change_history; invalid or foreign items are reported per item and
+// left untouched. Caches are invalidated once at the end.
+func handleAuthorBulkPrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	var req struct {
+		Items   []BulkPriceItem `json:"items"`
+		Percent float64         `json:"percent"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBulkPriceBodySize)).Decode(&req); err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的请求数据"})
+		return
+	}
+	switch {
+	case len(req.Items) > 0 && req.Percent != 0:
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "items 与 percent 不能同时指定"})
+		return
+	case len(req.Items) == 0 && req.Percent == 0:
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请指定要调整的商品或调价幅度"})
+		return
+	case len(req.Items) > maxBulkPriceItems:
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "单次最多调整 500 个商品"})
+		return
+	case req.Percent < minBulkPricePercent || req.Percent > maxBulkPricePercent:
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "调价幅度必须在 -90% 到 +500% 之间"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[BULK-PRICE] begin transaction error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "调价失败"})
+		return
+	}
+	defer tx.Rollback()
+
+	items := req.Items
+	if req.Percent != 0 {
+		items, err = bulkPriceCatalog(tx, userID)
+		if err != nil {
+			log.Printf("[BULK-PRICE] load catalog of user %d error: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "调价失败"})
+			return
+		}
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	results := make([]BulkPriceResult, 0, len(items))
+	var changedListings []int64
+	updated, failed := 0, 0
+	for _, item := range items {
+		var res BulkPriceResult
+		switch {
+		case (item.ListingID > 0) == (item.ProductID > 0):
+			res = BulkPriceResult{Status: "error", Error: "必须指定 listing_id 或 product_id 之一"}
+		case item.ListingID > 0:
+			res, err = bulkUpdatePackPrice(tx, userID, item, req.Percent, now)
+		default:
+			res, err = bulkUpdateCustomProductPrice(tx, userID, item, req.Percent, now)
+		}
+		if err != nil {
+			log.Printf("[BULK-PRICE] update %s %d error: %v", res.ItemType, res.ItemID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "调价失败"})
+			return
+		}
+		switch res.Status {
+		case "updated":
+			updated++
+			if res.ItemType == "pack" {
+				changedListings = append(changedListings, res.ItemID)
+			}
+		case "error":
+			failed++
+		}
+		results = append(results, res)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[BULK-PRICE] commit error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "调价失败"})
+		return
+	}
+
+	if updated > 0 {
+		var slug string
+		db.QueryRow("SELECT store_slug FROM author_storefronts WHERE user_id = ?", userID).Scan(&slug)
+		if slug != "" {
+			globalCache.InvalidateStorefront(slug)
+		}
+		if len(changedListings) > 0 {
+			globalCache.InvalidateHomepage()
+			for _, id := range changedListings {
+				var shareToken string
+				if err := db.QueryRow("SELECT share_token FROM pack_listings WHERE id = ?", id).Scan(&shareToken); err == nil && shareToken != "" {
+					globalCache.InvalidatePackDetail(shareToken)
+				}
+			}
+		}
+	}
+
+	log.Printf("[BULK-PRICE] user %d updated %d, failed %d of %d items", userID, updated, failed, len(items))
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"updated": updated,
+		"failed":  failed,
+		"results": results,
+	})
+}
+
+// bulkPriceCatalog lists every paid pack and custom product of userID as
+// items for a percentage adjustment.
+func bulkPriceCatalog(tx *sql.Tx, userID int64) ([]BulkPriceItem, error) {
+	var items []BulkPriceItem
+	rows, err := tx.Query("SELECT id FROM pack_listings WHERE user_id = ? AND share_mode != 'free' ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, BulkPriceItem{ListingID: id})
+	}
+	rows.Close()
+
+	rows, err = tx.Query(`SELECT p.id FROM custom_products p
+		JOIN author_storefronts s ON s.id = p.storefront_id
+		WHERE s.user_id = ? AND p.deleted_at IS NULL ORDER BY p.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, BulkPriceItem{ProductID: id})
+	}
+	return items, rows.Err()
+}
+
+// bulkUpdatePackPrice applies one pack price change inside tx. A non-nil
+// error is a database failure; validation problems are reported in the result.
+func bulkUpdatePackPrice(tx *sql.Tx, userID int64, item BulkPriceItem, percent float64, now string) (BulkPriceResult, error) {
+	res := BulkPriceResult{ItemType: "pack", ItemID: item.ListingID, Status: "error"}
+	var ownerID int64
+	var shareMode string
+	var oldPrice int
+	var salePrice sql.NullFloat64
+	err := tx.QueryRow(`SELECT user_id, share_mode, credits_price, CASE WHEN sale_end > ? THEN sale_price END
+		FROM pack_listings WHERE id = ?`, now, item.ListingID).Scan(&ownerID, &shareMode, &oldPrice, &salePrice)
+	if err == sql.ErrNoRows || (err == nil && ownerID != userID) {
+		res.Error = "分析包不存在"
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	res.OldPrice = float64(oldPrice)
+
+	newPrice := item.NewPrice
+	if percent != 0 {
+		newPrice = math.Round(float64(oldPrice) * (100 + percent) / 100)
+	}
+	res.NewPrice = newPrice
+	switch {
+	case shareMode == "free":
+		res.Error = "免费分析包无法调价"
+	case newPrice != math.Trunc(newPrice):
+		res.Error = "分析包价格必须为整数"
+	case salePrice.Valid && newPrice <= salePrice.Float64:
+		res.Error = "新价格必须高于限时折扣价"
+	default:
+		res.Error = validatePricingParams(shareMode, int(newPrice))
+	}
+	if res.Error != "" {
+		return res, nil
+	}
+	if int(newPrice) == oldPrice {
+		res.Status = "unchanged"
+		return res, nil
+	}
+
+	if _, err := tx.Exec("UPDATE pack_listings SET credits_price = ? WHERE id = ?", int(newPrice), item.ListingID); err != nil {
+		return res, err
+	}
+	if err := recordPriceChange(tx, "pack", item.ListingID, userID, res.OldPrice, newPrice, "bulk"); err != nil {
+		return res, err
+	}
+	res.Status = "updated"
+	return res, nil
+}
+
+// bulkUpdateCustomProductPrice applies one custom product price change inside
+// tx, like bulkUpdatePackPrice.
+func bulkUpdateCustomProductPrice(tx *sql.Tx, userID int64, item BulkPriceItem, percent float64, now string) (BulkPriceResult, error) {
+	res := BulkPriceResult{ItemType: "custom_product", ItemID: item.ProductID, Status: "error"}
+	var oldPrice float64
+	var salePrice sql.NullFloat64
+	err := tx.QueryRow(`SELECT p.price_usd, CASE WHEN p.sale_end > ? THEN p.sale_price END FROM custom_products p
+		JOIN author_storefronts s ON s.id = p.storefront_id
+		WHERE p.id = ? AND s.user_id = ? AND p.deleted_at IS NULL`, now, item.ProductID, userID).Scan(&oldPrice, &salePrice)
+	if err == sql.ErrNoRows {
+		res.Error = "商品不存在"
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	res.OldPrice = oldPrice
+
+	newPrice := item.NewPrice
+	if percent != 0 {
+		newPrice = oldPrice * (100 + percent) / 100
+	}
+	newPrice = math.Round(newPrice*100) / 100
+	res.NewPrice = newPrice
+	switch {
+	case newPrice <= 0 || newPrice > 9999.99:
+		res.Error = "价格必须为正数且不超过 9999.99 美元"
+	case salePrice.Valid && newPrice <= salePrice.Float64:
+		res.Error = "新价格必须高于限时折扣价"
+	}
+	if res.Error != "" {
+		return res, nil
+	}
+	if newPrice == oldPrice {
+		res.Status = "unchanged"
+		return res, nil
+	}
+
+	if _, err := tx.Exec("UPDATE custom_products SET price_usd = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", newPrice, item.ProductID); err != nil {
+		return res, err
+	}
+	if err := recordPriceChange(tx, "custom_product", item.ProductID, userID, oldPrice, newPrice, "bulk"); err != nil {
+		return res, err
+	}
+	res.Status = "updated"
+	return res, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorBulkPrice(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, custom_products_enabled) VALUES (10, 1, 'Mine', 'mine', 1)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (100, 1, 1, x'00', 'Per use', 'per_use', 50, 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (101, 1, 1, x'00', 'Monthly', 'subscription', 200, 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (102, 1, 1, x'00', 'Free', 'free', 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (103, 2, 1, x'00', 'Foreign', 'per_use', 10, 'published')")
+	mustExec(t, "INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, credits_amount) VALUES (5, 10, 'Credits', 'credits', 10, 100)")
+
+	post := func(body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/user/author/bulk-price", strings.NewReader(body))
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		handleAuthorBulkPrice(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	packPrice := func(id int64) int {
+		var p int
+		db.QueryRow("SELECT credits_price FROM pack_listings WHERE id = ?", id).Scan(&p)
+		return p
+	}
+	history := func() int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM price_change_history").Scan(&n)
+		return n
+	}
+
+	if code, _ := post(`{"items": [{"listing_id": 100, "new_price": 40}], "percent": 10}`); code != http.StatusBadRequest {
+		t.Errorf("items and percent: status %d, want 400", code)
+	}
+
+	code, resp := post(`{"items": [
+		{"listing_id": 100, "new_price": 40},
+		{"listing_id": 101, "new_price": 5000},
+		{"listing_id": 103, "new_price": 5},
+		{"product_id": 5, "new_price": 12.5}]}`)
+	if code != http.StatusOK || resp["updated"] != float64(2) || resp["failed"] != float64(2) {
+		t.Fatalf("explicit items: status %d, resp %v", code, resp)
+	}
+	if packPrice(100) != 40 || packPrice(101) != 200 || packPrice(103) != 10 {
+		t.Errorf("prices after explicit items: %d, %d, %d", packPrice(100), packPrice(101), packPrice(103))
+	}
+	if n := history(); n != 2 {
+		t.Errorf("history rows = %d, want 2", n)
+	}
+
+	// A percentage applies to every paid item of the caller only.
+	code, resp = post(`{"percent": 10}`)
+	if code != http.StatusOK || resp["updated"] != float64(3) {
+		t.Fatalf("percent: status %d, resp %v", code, resp)
+	}
+	var usd float64
+	db.QueryRow("SELECT price_usd FROM custom_products WHERE id = 5").Scan(&usd)
+	if packPrice(100) != 44 || packPrice(101) != 220 || packPrice(102) != 0 || packPrice(103) != 10 || usd != 13.75 {
+		t.Errorf("prices after +10%%: %d, %d, %d, %d, $%v", packPrice(100), packPrice(101), packPrice(102), packPrice(103), usd)
+	}
+	if n := history(); n != 5 {
+		t.Errorf("history rows = %d, want 5", n)
+	}
+}
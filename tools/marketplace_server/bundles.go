@@ -0,0 +1,550 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"marketplace_server/i18n"
+	"marketplace_server/templates"
+)
+
+// Pack bundles. An author groups several of their own paid packs into a
+// bundle sold at one discounted credits price. Buying a bundle grants every
+// pack in it within one transaction, as if each had been bought once (one use
+// of a per_use pack, one month of a subscription pack). The price is split
+// across the packs in proportion to their list prices so that each pack's
+// sales and the author's earnings are recorded as for single purchases.
+const (
+	minBundleItems      = 2
+	maxBundleItems      = 10
+	maxBundlesPerAuthor = 20
+	maxBundleNameLen    = 100
+	maxBundleDescLen    = 1000
+)
+
+// BundleItem is one pack of a bundle, priced at its current list price.
+type BundleItem struct {
+	ListingID    int64  `json:"listing_id"`
+	ShareToken   string `json:"share_token"`
+	PackName     string `json:"pack_name"`
+	ShareMode    string `json:"share_mode"`
+	CreditsPrice int    `json:"credits_price"`
+	// Available is false once the pack is unpublished, made free or moved to
+	// another author; a bundle with an unavailable item cannot be bought.
+	Available bool `json:"available"`
+}
+
+// PackBundle is a set of packs sold together.
+type PackBundle struct {
+	ID           int64        `json:"id"`
+	UserID       int64        `json:"user_id"`
+	AuthorName   string       `json:"author_name"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	CreditsPrice int          `json:"credits_price"`
+	Status       string       `json:"status"`
+	CreatedAt    string       `json:"created_at"`
+	Items        []BundleItem `json:"items"`
+}
+
+// ListTotal is what the bundle's packs cost when bought separately.
+func (b PackBundle) ListTotal() int {
+	total := 0
+	for _, item := range b.Items {
+		total += item.CreditsPrice
+	}
+	return total
+}
+
+// Savings is how much cheaper the bundle is than its packs bought separately.
+func (b PackBundle) Savings() int {
+	if s := b.ListTotal() - b.CreditsPrice; s > 0 {
+		return s
+	}
+	return 0
+}
+
+// Available reports whether the bundle can be bought.
+func (b PackBundle) Available() bool {
+	if b.Status != "active" || len(b.Items) < minBundleItems {
+		return false
+	}
+	for _, item := range b.Items {
+		if !item.Available {
+			return false
+		}
+	}
+	return true
+}
+
+// isBundlablePack reports whether a pack of shareMode can be part of a bundle.
+func isBundlablePack(shareMode string) bool {
+	return shareMode == "per_use" || shareMode == "subscription"
+}
+
+// loadBundleItems returns the packs of bundleID in display order.
+func loadBundleItems(bundleID, authorID int64) ([]BundleItem, error) {
+	rows, err := db.Query(`SELECT bi.listing_id, COALESCE(pl.share_token, ''), COALESCE(pl.pack_name, ''),
+		COALESCE(pl.share_mode, ''), COALESCE(pl.credits_price, 0), COALESCE(pl.status, ''), COALESCE(pl.user_id, 0)
+		FROM pack_bundle_items bi LEFT JOIN pack_listings pl ON pl.id = bi.listing_id
+		WHERE bi.bundle_id = ? ORDER BY bi.sort_order, bi.listing_id`, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BundleItem
+	for rows.Next() {
+		var item BundleItem
+		var status string
+		var ownerID int64
+		if err := rows.Scan(&item.ListingID, &item.ShareToken, &item.PackName, &item.ShareMode, &item.CreditsPrice, &status, &ownerID); err != nil {
+			return nil, err
+		}
+		item.Available = status == "published" && ownerID == authorID && isBundlablePack(item.ShareMode)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// loadBundle returns a bundle with its packs, or sql.ErrNoRows.
+func loadBundle(bundleID int64) (*PackBundle, error) {
+	b := &PackBundle{}
+	err := db.QueryRow(`SELECT b.id, b.user_id, COALESCE(u.display_name, ''), b.name, COALESCE(b.description, ''),
+		b.credits_price, b.status, b.created_at
+		FROM pack_bundles b LEFT JOIN users u ON u.id = b.user_id
+		WHERE b.id = ?`, bundleID).Scan(&b.ID, &b.UserID, &b.AuthorName, &b.Name, &b.Description,
+		&b.CreditsPrice, &b.Status, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if b.Items, err = loadBundleItems(b.ID, b.UserID); err != nil {
+		return nil, fmt.Errorf("load items of bundle %d: %w", b.ID, err)
+	}
+	return b, nil
+}
+
+// loadAuthorBundles returns the active bundles of userID, newest first.
+func loadAuthorBundles(userID int64) ([]PackBundle, error) {
+	rows, err := db.Query("SELECT id FROM pack_bundles WHERE user_id = ? AND status = 'active' ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	bundles := []PackBundle{}
+	for _, id := range ids {
+		b, err := loadBundle(id)
+		if err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, *b)
+	}
+	return bundles, nil
+}
+
+// bundlesContainingPack returns the buyable bundles that include listingID,
+// for the pack detail page.
+func bundlesContainingPack(listingID int64) []PackBundle {
+	rows, err := db.Query(`SELECT b.id FROM pack_bundles b
+		JOIN pack_bundle_items bi ON bi.bundle_id = b.id
+		WHERE bi.listing_id = ? AND b.status = 'active' ORDER BY b.id DESC LIMIT 5`, listingID)
+	if err != nil {
+		log.Printf("[BUNDLES] failed to query bundles of pack %d: %v", listingID, err)
+		return nil
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	var bundles []PackBundle
+	for _, id := range ids {
+		if b, err := loadBundle(id); err == nil && b.Available() {
+			bundles = append(bundles, *b)
+		}
+	}
+	return bundles
+}
+
+// splitBundlePrice divides price across items in proportion to weights,
+// handing the rounding remainder to the largest fractions first, so the parts
+// always add up to price. Equal weights are used when all are zero.
+func splitBundlePrice(price int, weights []int) []int {
+	parts := make([]int, len(weights))
+	if len(weights) == 0 {
+		return parts
+	}
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		weights = make([]int, len(weights))
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = len(weights)
+	}
+	type remainder struct {
+		index int
+		frac  int
+	}
+	rems := make([]remainder, len(weights))
+	assigned := 0
+	for i, w := range weights {
+		parts[i] = price * w / total
+		assigned += parts[i]
+		rems[i] = remainder{i, price * w % total}
+	}
+	sort.SliceStable(rems, func(a, b int) bool { return rems[a].frac > rems[b].frac })
+	for i := 0; assigned < price; i = (i + 1) % len(rems) {
+		parts[rems[i].index]++
+		assigned++
+	}
+	return parts
+}
+
+// validateBundle checks a bundle an author wants to create and returns the
+// error message to show, or "".
+func validateBundle(userID int64, name, description string, price int, listingIDs []int64) string {
+	if n := utf8.RuneCountInString(name); n < 2 || n > maxBundleNameLen {
+		return fmt.Sprintf("套餐名称长度必须在 2 到 %d 个字符之间", maxBundleNameLen)
+	}
+	if utf8.RuneCountInString(description) > maxBundleDescLen {
+		return fmt.Sprintf("套餐描述不能超过 %d 个字符", maxBundleDescLen)
+	}
+	seen := make(map[int64]bool)
+	for _, id := range listingIDs {
+		seen[id] = true
+	}
+	if len(seen) != len(listingIDs) || len(listingIDs) < minBundleItems || len(listingIDs) > maxBundleItems {
+		return fmt.Sprintf("套餐须包含 %d 到 %d 个不同的分析包", minBundleItems, maxBundleItems)
+	}
+	listTotal := 0
+	for _, id := range listingIDs {
+		var ownerID int64
+		var shareMode, status string
+		var creditsPrice int
+		err := db.QueryRow("SELECT user_id, share_mode, status, credits_price FROM pack_listings WHERE id = ?", id).Scan(&ownerID, &shareMode, &status, &creditsPrice)
+		if err != nil || ownerID != userID || status != "published" {
+			return "只能选择您自己已发布的分析包"
+		}
+		if !isBundlablePack(shareMode) {
+			return "套餐只能包含按次付费或订阅的分析包"
+		}
+		listTotal += creditsPrice
+	}
+	if price <= 0 || price >= listTotal {
+		return fmt.Sprintf("套餐价格必须大于 0 且低于单独购买合计 %d Credits", listTotal)
+	}
+	return ""
+}
+
+// handleUserBundles manages the authenticated author's bundles.
+//
+//	GET    /user/bundles        list active bundles
+//	POST   /user/bundles        {"name", "description", "credits_price", "listing_ids"} create a bundle
+//	DELETE /user/bundles?id=N   take a bundle off sale
+func handleUserBundles(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		bundles, err := loadAuthorBundles(userID)
+		if err != nil {
+			log.Printf("[BUNDLES] failed to load bundles of user %d: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"bundles": bundles})
+
+	case http.MethodPost:
+		var req struct {
+			Name         string  `json:"name"`
+			Description  string  `json:"description"`
+			CreditsPrice int     `json:"credits_price"`
+			ListingIDs   []int64 `json:"listing_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request_body"})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		req.Description = strings.TrimSpace(req.Description)
+		if msg := validateBundle(userID, req.Name, req.Description, req.CreditsPrice, req.ListingIDs); msg != "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": msg})
+			return
+		}
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM pack_bundles WHERE user_id = ? AND status = 'active'", userID).Scan(&count)
+		if count >= maxBundlesPerAuthor {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("最多同时在售 %d 个套餐", maxBundlesPerAuthor)})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("[BUNDLES] failed to begin transaction: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		defer tx.Rollback()
+		res, err := tx.Exec("INSERT INTO pack_bundles (user_id, name, description, credits_price) VALUES (?, ?, ?, ?)",
+			userID, req.Name, req.Description, req.CreditsPrice)
+		if err != nil {
+			log.Printf("[BUNDLES] failed to create bundle for user %d: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		bundleID, _ := res.LastInsertId()
+		for i, listingID := range req.ListingIDs {
+			if _, err := tx.Exec("INSERT INTO pack_bundle_items (bundle_id, listing_id, sort_order) VALUES (?, ?, ?)", bundleID, listingID, i); err != nil {
+				log.Printf("[BUNDLES] failed to add pack %d to bundle %d: %v", listingID, bundleID, err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("[BUNDLES] failed to commit bundle %d: %v", bundleID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		log.Printf("[BUNDLES] user %d created bundle %d with %d packs at %d credits", userID, bundleID, len(req.ListingIDs), req.CreditsPrice)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "id": bundleID})
+
+	case http.MethodDelete:
+		bundleID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil || bundleID <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "id must be positive"})
+			return
+		}
+		res, err := db.Exec("UPDATE pack_bundles SET status = 'archived', updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND status = 'active'", bundleID, userID)
+		if err != nil {
+			log.Printf("[BUNDLES] failed to archive bundle %d: %v", bundleID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "bundle_not_found"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
+
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// parseBundlePath returns the bundle id of /bundle/{id} or /bundle/{id}/{action}.
+func parseBundlePath(path string) (int64, string, bool) {
+	idStr, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(path, "/bundle/"), "/"), "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	return id, action, err == nil && id > 0
+}
+
+// handleBundlePage serves GET /bundle/{id}. Packs the viewer already owns
+// are left out of the list but are still granted again on purchase.
+func handleBundlePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	bundleID, action, ok := parseBundlePath(r.URL.Path)
+	if !ok || action != "" {
+		http.NotFound(w, r)
+		return
+	}
+	bundle, err := loadBundle(bundleID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[BUNDLES] failed to load bundle %d: %v", bundleID, err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if bundle.Status != "active" {
+		http.NotFound(w, r)
+		return
+	}
+
+	isLoggedIn, isAuthor := false, false
+	var owned map[int64]bool
+	if cookie, err := r.Cookie("user_session"); err == nil && isValidUserSession(cookie.Value) {
+		if userID := getUserSessionUserID(cookie.Value); userID > 0 {
+			isLoggedIn = true
+			isAuthor = userID == bundle.UserID
+			var hit bool
+			owned, hit = globalCache.GetUserPurchasedIDs(userID)
+			if !hit {
+				owned = getUserPurchasedListingIDs(userID)
+				globalCache.SetUserPurchasedIDs(userID, owned)
+			}
+		}
+	}
+	visible := make([]BundleItem, 0, len(bundle.Items))
+	for _, item := range bundle.Items {
+		if !owned[item.ListingID] {
+			visible = append(visible, item)
+		}
+	}
+
+	var storePublicID string
+	db.QueryRow("SELECT COALESCE(NULLIF(public_id, ''), CAST(id AS TEXT)) FROM author_storefronts WHERE user_id = ?", bundle.UserID).Scan(&storePublicID)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := i18n.TemplateData(r)
+	i18n.MergeTemplateData(data, map[string]interface{}{
+		"Bundle":        bundle,
+		"VisibleItems":  visible,
+		"OwnedCount":    len(bundle.Items) - len(visible),
+		"IsLoggedIn":    isLoggedIn,
+		"IsAuthor":      isAuthor,
+		"StorePublicID": storePublicID,
+	})
+	if err := templates.BundleTmpl.Execute(w, data); err != nil {
+		log.Printf("[BUNDLES] template execute error: %v", err)
+	}
+}
+
+// handleBundlePurchase handles POST /bundle/{id}/purchase. The whole bundle
+// is charged and granted in one transaction: one credits transaction per
+// pack, per_use quotas and user_purchased_packs updated. Nothing is charged
+// or granted if any pack is no longer for sale or the wallet cannot cover
+// the price.
+func handleBundlePurchase(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	bundleID, _, ok := parseBundlePath(r.URL.Path)
+	if !ok {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_bundle_id"})
+		return
+	}
+
+	bundle, err := loadBundle(bundleID)
+	if err == sql.ErrNoRows || (err == nil && bundle.Status != "active") {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "bundle_not_found"})
+		return
+	} else if err != nil {
+		log.Printf("[BUNDLE-PURCHASE] failed to load bundle %d: %v", bundleID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if bundle.UserID == userID {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "cannot_buy_own_pack"})
+		return
+	}
+	if !bundle.Available() {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "bundle_unavailable"})
+		return
+	}
+
+	balance := getWalletBalance(userID)
+	if balance < float64(bundle.CreditsPrice) {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"insufficient_balance": true,
+			"balance":              balance,
+			"total":                bundle.CreditsPrice,
+		})
+		return
+	}
+
+	weights := make([]int, len(bundle.Items))
+	for i, item := range bundle.Items {
+		weights[i] = item.CreditsPrice
+	}
+	parts := splitBundlePrice(bundle.CreditsPrice, weights)
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[BUNDLE-PURCHASE] failed to begin transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer tx.Rollback()
+
+	rowsAffected, err := deductWalletBalance(tx, userID, float64(bundle.CreditsPrice))
+	if err != nil {
+		log.Printf("[BUNDLE-PURCHASE] failed to deduct credits: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if rowsAffected == 0 {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"insufficient_balance": true,
+			"balance":              balance,
+			"total":                bundle.CreditsPrice,
+		})
+		return
+	}
+
+	charges := make([]packCharge, len(bundle.Items))
+	for i, item := range bundle.Items {
+		charges[i] = packCharge{ListingID: item.ListingID, ShareMode: item.ShareMode, Uses: 1, Amount: parts[i]}
+		if item.ShareMode == "per_use" {
+			charges[i].Description = fmt.Sprintf("Purchase 1 use from bundle %s: %s", bundle.Name, item.PackName)
+		} else {
+			charges[i].Description = fmt.Sprintf("Purchase 1 month(s) subscription from bundle %s: %s", bundle.Name, item.PackName)
+		}
+	}
+	if err := recordPackCharges(tx, userID, getClientIP(r), charges); err != nil {
+		log.Printf("[BUNDLE-PURCHASE] %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[BUNDLE-PURCHASE] failed to commit transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	for i, item := range bundle.Items {
+		publishPackSaleEvent(item.ListingID, "purchase", float64(parts[i]))
+	}
+	grantReferralRewards(userID)
+	globalCache.InvalidateUserPurchased(userID)
+
+	log.Printf("[BUNDLE-PURCHASE] user %d bought bundle %d (%d packs), cost=%d", userID, bundle.ID, len(bundle.Items), bundle.CreditsPrice)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"success":          true,
+		"credits_deducted": bundle.CreditsPrice,
+		"items":            bundle.Items,
+	})
+}
+
+// handleBundleRoutes dispatches /bundle/{id} and /bundle/{id}/purchase.
+func handleBundleRoutes(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/purchase") {
+		userAuth(denyWhileImpersonating(handleBundlePurchase))(w, r)
+		return
+	}
+	handleBundlePage(w, r)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitBundlePrice(t *testing.T) {
+	cases := []struct {
+		price   int
+		weights []int
+		want    []int
+	}{
+		{30, []int{10, 20}, []int{10, 20}},
+		{10, []int{10, 10, 10}, []int{4, 3, 3}},
+		{25, []int{20, 30}, []int{10, 15}},
+		{7, []int{0, 0}, []int{4, 3}},
+		{0, []int{5, 5}, []int{0, 0}},
+	}
+	for _, c := range cases {
+		got := splitBundlePrice(c.price, c.weights)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitBundlePrice(%d, %v) = %v, want %v", c.price, c.weights, got, c.want)
+		}
+	}
+}
+
+func TestBundlePurchase(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, credits_balance) VALUES (2, 'sn', 'buyer', 'Buyer', 100)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (100, 1, 1, x'00', 'Per use', 'per_use', 20, 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (101, 1, 1, x'00', 'Monthly', 'subscription', 30, 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (102, 1, 1, x'00', 'Free', 'free', 0, 'published')")
+
+	do := func(handler http.HandlerFunc, userID, method, target, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-User-ID", userID)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	for _, body := range []string{
+		`{"name": "Too dear", "credits_price": 50, "listing_ids": [100, 101]}`,
+		`{"name": "Single", "credits_price": 10, "listing_ids": [100]}`,
+		`{"name": "With free", "credits_price": 10, "listing_ids": [100, 102]}`,
+	} {
+		if code, resp := do(handleUserBundles, "1", http.MethodPost, "/user/bundles", body); code != http.StatusBadRequest {
+			t.Errorf("create %s: status %d, %v", body, code, resp)
+		}
+	}
+	code, resp := do(handleUserBundles, "1", http.MethodPost, "/user/bundles", `{"name": "Starter set", "credits_price": 25, "listing_ids": [100, 101]}`)
+	if code != http.StatusOK {
+		t.Fatalf("create: status %d, %v", code, resp)
+	}
+	bundleTarget := fmt.Sprintf("/bundle/%v/purchase", resp["id"])
+
+	if code, resp := do(handleBundlePurchase, "1", http.MethodPost, bundleTarget, ""); code != http.StatusBadRequest {
+		t.Errorf("author buying own bundle: status %d, %v", code, resp)
+	}
+
+	// The buyer already owns the per_use pack; it is granted again regardless.
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (2, 100)")
+
+	// A failure granting one pack rolls back the charge and every other grant.
+	mustExec(t, "CREATE TRIGGER fail_bundle_grant BEFORE INSERT ON user_purchased_packs WHEN NEW.listing_id = 101 BEGIN SELECT RAISE(ABORT, 'boom'); END")
+	if code, resp := do(handleBundlePurchase, "2", http.MethodPost, bundleTarget, ""); code != http.StatusInternalServerError {
+		t.Fatalf("purchase with failing grant: status %d, %v", code, resp)
+	}
+	var uses, txCount int
+	db.QueryRow("SELECT COUNT(*) FROM pack_usage_records WHERE user_id = 2").Scan(&uses)
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = 2").Scan(&txCount)
+	if getWalletBalance(2) != 100 || uses != 0 || txCount != 0 {
+		t.Fatalf("after failed purchase: balance %v, usage rows %d, transactions %d", getWalletBalance(2), uses, txCount)
+	}
+	mustExec(t, "DROP TRIGGER fail_bundle_grant")
+
+	code, resp = do(handleBundlePurchase, "2", http.MethodPost, bundleTarget, "")
+	if code != http.StatusOK || resp["credits_deducted"] != float64(25) {
+		t.Fatalf("purchase: status %d, %v", code, resp)
+	}
+	if getWalletBalance(2) != 75 {
+		t.Errorf("balance after purchase = %v, want 75", getWalletBalance(2))
+	}
+	var owned, total int
+	db.QueryRow("SELECT COUNT(*) FROM user_purchased_packs WHERE user_id = 2 AND listing_id IN (100, 101)").Scan(&owned)
+	db.QueryRow("SELECT total_purchased FROM pack_usage_records WHERE user_id = 2 AND listing_id = 100").Scan(&uses)
+	db.QueryRow("SELECT COUNT(*), CAST(-SUM(amount) AS INTEGER) FROM credits_transactions WHERE user_id = 2 AND transaction_type = 'purchase'").Scan(&txCount, &total)
+	if owned != 2 || uses != 1 || txCount != 2 || total != 25 {
+		t.Errorf("after purchase: owned %d, uses %d, transactions %d totalling %d", owned, uses, txCount, total)
+	}
+
+	// Unpublishing a pack makes the bundle unavailable without charging.
+	mustExec(t, "UPDATE pack_listings SET status = 'delisted' WHERE id = 101")
+	if code, resp := do(handleBundlePurchase, "2", http.MethodPost, bundleTarget, ""); code != http.StatusConflict || resp["error"] != "bundle_unavailable" {
+		t.Errorf("purchase with unpublished pack: status %d, %v", code, resp)
+	}
+	if getWalletBalance(2) != 75 {
+		t.Errorf("balance after unavailable purchase = %v, want 75", getWalletBalance(2))
+	}
+}
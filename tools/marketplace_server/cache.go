@@ -19,6 +19,7 @@ type CacheConfig struct {
 	ShareTokenTTL    time.Duration // ShareToken 映射缓存 TTL，默认 10 分钟
 	UserPurchasedTTL time.Duration // 用户已购买状态缓存 TTL，默认 1 分钟
 	HomepageTTL      time.Duration // 首页数据缓存 TTL，默认 2 分钟
+	FeedTTL          time.Duration // Atom feed 与 sitemap 缓存 TTL，默认 5 分钟
 	CleanupInterval  time.Duration // 定期清理间隔，默认 10 分钟
 }
 
@@ -31,6 +32,7 @@ func DefaultCacheConfig() CacheConfig {
 		ShareTokenTTL:    10 * time.Minute,
 		UserPurchasedTTL: 1 * time.Minute,
 		HomepageTTL:      2 * time.Minute,
+		FeedTTL:          5 * time.Minute,
 		CleanupInterval:  10 * time.Minute,
 	}
 }
@@ -45,16 +47,20 @@ type cacheEntry struct {
 
 // StorefrontPublicData 小铺页面公共数据（缓存对象）
 type StorefrontPublicData struct {
-	Storefront      StorefrontInfo              // 小铺基本信息
-	FeaturedPacks   []StorefrontPackInfo        // 推荐分析包列表
-	Packs           []StorefrontPackInfo        // 分析包列表
-	Categories      []string                    // 分类列表
-	CustomProducts  []CustomProduct             // 自定义产品列表
-	LayoutConfig    LayoutConfig                // 布局配置
-	ThemeCSS        string                      // 主题样式 CSS
-	PackGridColumns int                         // 分析包网格列数
-	BannerData      map[int]CustomBannerSettings // 自定义横幅数据
-	HeroLayout      string                      // hero 区块布局: "default" 或 "reversed"
+	Storefront            StorefrontInfo               // 小铺基本信息
+	FeaturedPacks         []StorefrontPackInfo         // 推荐分析包列表
+	Packs                 []StorefrontPackInfo         // 分析包列表
+	Categories            []string                     // 分类列表
+	CustomProducts        []CustomProduct              // 自定义产品列表
+	LayoutConfig          LayoutConfig                 // 布局配置
+	ThemeCSS              string                       // 主题样式 CSS
+	ThemeDarkCSS          string                       // 跟随系统深色模式时的主题样式 CSS
+	PackGridColumns       int                          // 分析包网格列数
+	PackGridMobileColumns int                          // 手机端分析包网格列数
+	BannerData            map[int]CustomBannerSettings // 自定义横幅数据
+	HeroLayout            string                       // hero 区块布局: "default" 或 "reversed"
+	HeroCTA               HeroCTA                      // hero 行动按钮
+	ExpiresAt             time.Time                    // 定时横幅或限时折扣下一次变化时间，零值表示两者都没有
 }
 
 // PackDetailPublicData 分析包详情页公共数据（缓存对象）
@@ -72,6 +78,11 @@ type PackDetailPublicData struct {
 	StoreSlug          string
 	StoreName          string
 	StorefrontPublicID string
+	Images             []PackImage   // 截图画廊，按 sort_order 排序
+	OriginalPrice      int           // 限时折扣进行中时的原价（CreditsPrice 为折扣价），否则为 0
+	SaleEndsAt         string        // 限时折扣结束时间（RFC 3339），无进行中的折扣时为空
+	ExpiresAt          time.Time     // 限时折扣下一次开始/结束时间（含相关推荐），零值表示无定时折扣
+	Related            []RelatedPack // 相关推荐候选（同作者优先，其次同分类最新），展示时再排除已购
 }
 
 // HomepagePublicData 首页公共数据（缓存对象，不含用户相关字段）
@@ -80,9 +91,11 @@ type HomepagePublicData struct {
 	DownloadURLWindows string
 	DownloadURLMacOS   string
 	FeaturedStores     []HomepageStoreInfo
+	FeaturedProducts   []HomepageProductInfo
 	TopSalesStores     []HomepageStoreInfo
 	TopDownloadsStores []HomepageStoreInfo
 	TopSalesProducts   []HomepageProductInfo
+	TrendingProducts   []HomepageProductInfo
 	TopDownloadsProducts []HomepageProductInfo
 	NewestProducts     []HomepageProductInfo
 	Categories         []HomepageCategoryInfo
@@ -97,7 +110,10 @@ type Cache struct {
 	shareTokens   map[string]*cacheEntry // key: shareToken -> listingID
 	userPurchased map[int64]*cacheEntry  // key: userID -> map[int64]bool
 	homepage      map[string]*cacheEntry // key: "hp" -> *HomepagePublicData
+	feeds         map[string]*cacheEntry // key: "feed:..." / "sitemap:..." -> 渲染好的 XML []byte
 	sfGroup       singleflight.Group     // 防止缓存击穿
+
+	homepageRefreshedAt time.Time // 首页数据最近一次写入缓存的时间
 }
 
 // NewCache 创建缓存实例
@@ -109,6 +125,7 @@ func NewCache(config CacheConfig) *Cache {
 		shareTokens:   make(map[string]*cacheEntry),
 		userPurchased: make(map[int64]*cacheEntry),
 		homepage:      make(map[string]*cacheEntry),
+		feeds:         make(map[string]*cacheEntry),
 	}
 }
 
@@ -145,14 +162,22 @@ func (c *Cache) GetStorefrontData(key string) (*StorefrontPublicData, bool) {
 }
 
 // SetStorefrontData 设置小铺公共数据缓存
+// 如果数据包含定时横幅或限时折扣（ExpiresAt 非零），TTL 会缩短到下一次变化时刻，
+// 保证横幅按时出现/消失、折扣价按时生效/恢复
 func (c *Cache) SetStorefrontData(key string, data *StorefrontPublicData) {
 	now := time.Now()
+	ttl := c.config.StorefrontTTL
+	if !data.ExpiresAt.IsZero() {
+		if untilChange := data.ExpiresAt.Sub(now); untilChange < ttl {
+			ttl = untilChange
+		}
+	}
 	c.mu.Lock()
 	c.storefronts[key] = &cacheEntry{
 		data:       data,
 		createdAt:  now,
 		lastAccess: now,
-		ttl:        c.config.StorefrontTTL,
+		ttl:        ttl,
 	}
 	c.mu.Unlock()
 	c.evictLRU()
@@ -177,14 +202,21 @@ func (c *Cache) GetPackDetail(shareToken string) (*PackDetailPublicData, bool) {
 }
 
 // SetPackDetail 设置分析包详情缓存
+// 如果分析包有定时折扣（ExpiresAt 非零），TTL 会缩短到折扣开始/结束时刻
 func (c *Cache) SetPackDetail(shareToken string, data *PackDetailPublicData) {
 	now := time.Now()
+	ttl := c.config.PackDetailTTL
+	if !data.ExpiresAt.IsZero() {
+		if untilChange := data.ExpiresAt.Sub(now); untilChange < ttl {
+			ttl = untilChange
+		}
+	}
 	c.mu.Lock()
 	c.packDetails[shareToken] = &cacheEntry{
 		data:       data,
 		createdAt:  now,
 		lastAccess: now,
-		ttl:        c.config.PackDetailTTL,
+		ttl:        ttl,
 	}
 	c.mu.Unlock()
 	c.evictLRU()
@@ -294,10 +326,30 @@ func (c *Cache) SetHomepageData(data *HomepagePublicData) {
 		lastAccess: now,
 		ttl:        c.config.HomepageTTL,
 	}
+	c.homepageRefreshedAt = now
 	c.mu.Unlock()
 	c.evictLRU()
 }
 
+// SetHomepageTTL 修改首页缓存 TTL，同时作用于当前已缓存的条目
+func (c *Cache) SetHomepageTTL(ttl time.Duration) {
+	c.mu.Lock()
+	c.config.HomepageTTL = ttl
+	if entry, ok := c.homepage["hp"]; ok {
+		entry.ttl = ttl
+	}
+	c.mu.Unlock()
+}
+
+// HomepageStatus 返回首页缓存 TTL、最近刷新时间以及当前是否有有效缓存
+func (c *Cache) HomepageStatus() (ttl time.Duration, refreshedAt time.Time, cached bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.homepage["hp"]
+	cached = ok && time.Now().Before(entry.createdAt.Add(entry.ttl))
+	return c.config.HomepageTTL, c.homepageRefreshedAt, cached
+}
+
 // InvalidateHomepage 清除首页缓存
 func (c *Cache) InvalidateHomepage() {
 	c.mu.Lock()
@@ -306,6 +358,49 @@ func (c *Cache) InvalidateHomepage() {
 	log.Printf("[CACHE] invalidated homepage cache")
 }
 
+// GetFeed 获取已渲染的 feed 文档缓存
+func (c *Cache) GetFeed(key string) ([]byte, bool) {
+	c.mu.RLock()
+	entry, ok := c.feeds[key]
+	if !ok {
+		c.mu.RUnlock()
+		return nil, false
+	}
+	if time.Now().After(entry.createdAt.Add(entry.ttl)) {
+		c.mu.RUnlock()
+		return nil, false
+	}
+	entry.lastAccess = time.Now()
+	data := entry.data.([]byte)
+	c.mu.RUnlock()
+	return data, true
+}
+
+// SetFeed 设置已渲染的 feed 文档缓存
+func (c *Cache) SetFeed(key string, data []byte) {
+	now := time.Now()
+	c.mu.Lock()
+	c.feeds[key] = &cacheEntry{
+		data:       data,
+		createdAt:  now,
+		lastAccess: now,
+		ttl:        c.config.FeedTTL,
+	}
+	c.mu.Unlock()
+	c.evictLRU()
+}
+
+// DoFeedQuery 使用 singleflight 生成 feed 文档
+func (c *Cache) DoFeedQuery(key string, fn func() ([]byte, error)) ([]byte, error) {
+	v, err, _ := c.sfGroup.Do("feed:"+key, func() (interface{}, error) {
+		return fn()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
 // DoHomepageQuery 使用 singleflight 执行首页数据查询
 func (c *Cache) DoHomepageQuery(fn func() (*HomepagePublicData, error)) (*HomepagePublicData, error) {
 	v, err, _ := c.sfGroup.Do("homepage", func() (interface{}, error) {
@@ -461,6 +556,11 @@ func (c *Cache) evictLRU() {
 				oldest = oldestEntry{mapName: "homepage", keyStr: k, time: e.lastAccess}
 			}
 		}
+		for k, e := range c.feeds {
+			if e.lastAccess.Before(oldest.time) {
+				oldest = oldestEntry{mapName: "feeds", keyStr: k, time: e.lastAccess}
+			}
+		}
 
 		// 删除最旧的条目
 		switch oldest.mapName {
@@ -474,6 +574,8 @@ func (c *Cache) evictLRU() {
 			delete(c.userPurchased, oldest.keyInt)
 		case "homepage":
 			delete(c.homepage, oldest.keyStr)
+		case "feeds":
+			delete(c.feeds, oldest.keyStr)
 		default:
 			// 如果没有找到任何条目，退出循环防止死循环
 			return
@@ -483,7 +585,7 @@ func (c *Cache) evictLRU() {
 
 // entryCountLocked 返回当前缓存条目总数（调用者必须持有锁）
 func (c *Cache) entryCountLocked() int {
-	return len(c.storefronts) + len(c.packDetails) + len(c.shareTokens) + len(c.userPurchased) + len(c.homepage)
+	return len(c.storefronts) + len(c.packDetails) + len(c.shareTokens) + len(c.userPurchased) + len(c.homepage) + len(c.feeds)
 }
 
 // EntryCount 返回当前缓存条目总数
@@ -524,6 +626,11 @@ func (c *Cache) cleanupExpired() {
 			delete(c.homepage, k)
 		}
 	}
+	for k, e := range c.feeds {
+		if now.After(e.createdAt.Add(e.ttl)) {
+			delete(c.feeds, k)
+		}
+	}
 }
 
 // startCleanupTicker 启动定期清理 goroutine
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// isCacheWarmupEnabled reports whether startup cache warm-up is on.
+// Enabled unless the cache_warmup_enabled setting is "false".
+func isCacheWarmupEnabled() bool {
+	return getSetting("cache_warmup_enabled") != "false"
+}
+
+// warmCaches preloads the homepage and the default view of every featured
+// storefront so the first visitors after a restart hit a warm cache. It goes
+// through the same singleflight groups as the request handlers, so a request
+// arriving mid warm-up waits for the in-flight query instead of repeating it.
+func warmCaches() {
+	start := time.Now()
+
+	if _, err := globalCache.DoHomepageQuery(func() (*HomepagePublicData, error) {
+		data, err := queryHomepagePublicData()
+		if err == nil {
+			globalCache.SetHomepageData(data)
+		}
+		return data, err
+	}); err != nil {
+		log.Printf("[CACHE-WARMUP] homepage failed: %v", err)
+	} else {
+		log.Printf("[CACHE-WARMUP] homepage warmed in %v", time.Since(start))
+	}
+
+	stores, err := queryFeaturedStorefronts()
+	if err != nil {
+		log.Printf("[CACHE-WARMUP] failed to list featured storefronts: %v", err)
+		return
+	}
+	storesStart := time.Now()
+	warmed := 0
+	for _, s := range stores {
+		cacheIdentifier := s.PublicID
+		if cacheIdentifier == "" {
+			cacheIdentifier = fmt.Sprintf("%d", s.StorefrontID)
+		}
+		// Matches the key handleStorefrontPage builds for an unfiltered visit.
+		cacheKey := buildStorefrontCacheKey(cacheIdentifier, "", "revenue", "", "")
+		if _, err := globalCache.DoStorefrontQuery(cacheKey, func() (*StorefrontPublicData, error) {
+			data, err := queryStorefrontPublicData(strconv.FormatInt(s.StorefrontID, 10), "", "revenue", "", "")
+			if err == nil {
+				globalCache.SetStorefrontData(cacheKey, data)
+			}
+			return data, err
+		}); err != nil {
+			log.Printf("[CACHE-WARMUP] storefront %d failed: %v", s.StorefrontID, err)
+			continue
+		}
+		warmed++
+	}
+	log.Printf("[CACHE-WARMUP] %d/%d featured storefronts warmed in %v (total %v)",
+		warmed, len(stores), time.Since(storesStart), time.Since(start))
+}
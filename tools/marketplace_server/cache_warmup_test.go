@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHomepageCacheSettingsKeepWarmupWhenFieldMissing(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	save := func(form url.Values) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/settings/homepage-cache", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handleAdminHomepageCache(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("save %v: status %d: %s", form, rec.Code, rec.Body.String())
+		}
+	}
+
+	save(url.Values{"ttl_seconds": {"60"}})
+	if !isCacheWarmupEnabled() {
+		t.Error("saving the TTL alone turned warm-up off")
+	}
+	save(url.Values{"ttl_seconds": {"60"}, "warmup_enabled": {"false"}})
+	if isCacheWarmupEnabled() {
+		t.Error("warmup_enabled=false did not turn warm-up off")
+	}
+	save(url.Values{"ttl_seconds": {"120"}})
+	if isCacheWarmupEnabled() {
+		t.Error("saving the TTL alone turned warm-up back on")
+	}
+	save(url.Values{"ttl_seconds": {"120"}, "warmup_enabled": {"true"}})
+	if !isCacheWarmupEnabled() {
+		t.Error("warmup_enabled=true did not turn warm-up on")
+	}
+}
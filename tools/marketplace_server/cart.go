@@ -0,0 +1,365 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Shopping cart. Buyers collect paid packs in cart_items and pay for all of
+// them at once from their credits wallet. Prices are not stored in the cart;
+// the current price (including flash sales) applies at checkout.
+const (
+	maxCartItems    = 50
+	maxCartQuantity = 1000 // uses of one per_use pack
+)
+
+// CartItem is one pack in a user's cart as returned by GET /user/cart.
+type CartItem struct {
+	id         int64
+	ListingID  int64  `json:"listing_id"`
+	PackName   string `json:"pack_name"`
+	ShareToken string `json:"share_token"`
+	ShareMode  string `json:"share_mode"`
+	Quantity   int    `json:"quantity"` // uses, for per_use packs
+	Months     int    `json:"months"`   // for subscription packs
+	UnitPrice  int    `json:"unit_price"`
+	Subtotal   int    `json:"subtotal"`
+	Available  bool   `json:"available"` // false once the pack is unpublished or made free
+	AddedAt    string `json:"added_at"`
+}
+
+// units returns how many price units the item is charged for.
+func (c CartItem) units() int {
+	if c.ShareMode == "subscription" {
+		return c.Months
+	}
+	return c.Quantity
+}
+
+// loadCart returns userID's cart priced at now, oldest item first.
+func loadCart(q walletQuerier, userID int64, now time.Time) ([]CartItem, error) {
+	rows, err := q.Query(`SELECT c.id, c.listing_id, c.quantity, c.months, c.created_at,
+		COALESCE(p.pack_name, ''), COALESCE(p.share_token, ''), COALESCE(p.share_mode, ''),
+		COALESCE(p.credits_price, 0), COALESCE(p.status, '')
+		FROM cart_items c LEFT JOIN pack_listings p ON p.id = c.listing_id
+		WHERE c.user_id = ? ORDER BY c.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CartItem{}
+	var ids []int64
+	for rows.Next() {
+		var item CartItem
+		var status string
+		if err := rows.Scan(&item.id, &item.ListingID, &item.Quantity, &item.Months, &item.AddedAt,
+			&item.PackName, &item.ShareToken, &item.ShareMode, &item.UnitPrice, &status); err != nil {
+			return nil, err
+		}
+		item.AddedAt = publishAtRFC3339(item.AddedAt)
+		item.Available = status == "published" && (item.ShareMode == "per_use" || item.ShareMode == "subscription")
+		items = append(items, item)
+		ids = append(ids, item.ListingID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sales := loadFlashSales("pack_listings", ids)
+	for i := range items {
+		if sale, ok := sales[items[i].ListingID]; ok && sale.ActiveAt(now) {
+			items[i].UnitPrice = int(sale.Price)
+		}
+		if items[i].Available {
+			items[i].Subtotal = items[i].UnitPrice * items[i].units()
+		}
+	}
+	return items, nil
+}
+
+// handleUserCart handles the authenticated user's cart.
+//
+//	GET    /user/cart                     list the cart with current prices
+//	POST   /user/cart                     {"listing_id": N, "quantity": N, "months": N} add or update a pack
+//	DELETE /user/cart?listing_id=N        remove a pack
+func handleUserCart(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := loadCart(db, userID, time.Now())
+		if err != nil {
+			log.Printf("[CART] failed to load cart of user %d: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		total := 0
+		for _, item := range items {
+			total += item.Subtotal
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"items":   items,
+			"count":   len(items),
+			"total":   total,
+			"balance": getWalletBalance(userID),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			ListingID int64 `json:"listing_id"`
+			Quantity  int   `json:"quantity"`
+			Months    int   `json:"months"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ListingID <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "listing_id must be positive"})
+			return
+		}
+		var ownerID int64
+		var shareMode string
+		err := db.QueryRow("SELECT user_id, share_mode FROM pack_listings WHERE id = ? AND status = 'published'", req.ListingID).Scan(&ownerID, &shareMode)
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "pack_not_found"})
+			return
+		} else if err != nil {
+			log.Printf("[CART] failed to query pack %d: %v", req.ListingID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if ownerID == userID {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "cannot_buy_own_pack"})
+			return
+		}
+		switch shareMode {
+		case "free":
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "pack_is_free"})
+			return
+		case "per_use":
+			if req.Quantity <= 0 {
+				req.Quantity = 1
+			}
+			if req.Quantity > maxCartQuantity {
+				jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("quantity_must_be_1_to_%d", maxCartQuantity)})
+				return
+			}
+			req.Months = 0
+		case "subscription":
+			if req.Months <= 0 {
+				req.Months = 1
+			}
+			if req.Months > 12 {
+				jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "months_must_be_1_to_12"})
+				return
+			}
+			req.Quantity = 0
+		default:
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "unsupported_share_mode"})
+			return
+		}
+
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM cart_items WHERE user_id = ? AND listing_id != ?", userID, req.ListingID).Scan(&count)
+		if count >= maxCartItems {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "cart_full"})
+			return
+		}
+		if _, err := db.Exec(`INSERT INTO cart_items (user_id, listing_id, quantity, months) VALUES (?, ?, ?, ?)
+			ON CONFLICT(user_id, listing_id) DO UPDATE SET quantity = excluded.quantity, months = excluded.months`,
+			userID, req.ListingID, req.Quantity, req.Months); err != nil {
+			log.Printf("[CART] failed to add pack %d to cart of user %d: %v", req.ListingID, userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "count": count + 1})
+
+	case http.MethodDelete:
+		listingID, err := strconv.ParseInt(r.URL.Query().Get("listing_id"), 10, 64)
+		if err != nil || listingID <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "listing_id must be positive"})
+			return
+		}
+		if _, err := db.Exec("DELETE FROM cart_items WHERE user_id = ? AND listing_id = ?", userID, listingID); err != nil {
+			log.Printf("[CART] failed to remove pack %d from cart of user %d: %v", listingID, userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM cart_items WHERE user_id = ?", userID).Scan(&count)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "count": count})
+
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// handleUserCartCheckout handles POST /user/cart/checkout. It charges the
+// whole cart in one transaction: one credits transaction per pack, per_use
+// quota and user_purchased_packs updated, and the charged items removed from
+// the cart. The cart is read inside that transaction, so an item added or
+// changed while the checkout runs is either charged or left in the cart. If
+// the wallet cannot cover the total nothing is charged. Packs that are no
+// longer for sale are dropped from the cart and the checkout is refused with
+// "items_unavailable" so the buyer can review the new total first.
+func handleUserCartCheckout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[CART-CHECKOUT] failed to begin transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer tx.Rollback()
+
+	items, err := loadCart(tx, userID, time.Now())
+	if err != nil {
+		log.Printf("[CART-CHECKOUT] failed to load cart of user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if len(items) == 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "cart_empty"})
+		return
+	}
+
+	var unavailable []CartItem
+	total := 0
+	for _, item := range items {
+		if !item.Available {
+			unavailable = append(unavailable, item)
+			continue
+		}
+		if units := item.units(); units <= 0 || units > maxCartQuantity || item.Subtotal < 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid_cart_item", "listing_id": item.ListingID})
+			return
+		}
+		total += item.Subtotal
+	}
+	if len(unavailable) > 0 {
+		for _, item := range unavailable {
+			if _, err := tx.Exec("DELETE FROM cart_items WHERE id = ?", item.id); err != nil {
+				log.Printf("[CART-CHECKOUT] failed to drop unavailable pack %d from cart of user %d: %v", item.ListingID, userID, err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("[CART-CHECKOUT] failed to commit transaction: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusConflict, map[string]interface{}{
+			"error":       "items_unavailable",
+			"unavailable": unavailable,
+		})
+		return
+	}
+	if total <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_total"})
+		return
+	}
+
+	rowsAffected, err := deductWalletBalance(tx, userID, float64(total))
+	if err != nil {
+		log.Printf("[CART-CHECKOUT] failed to deduct credits: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if rowsAffected == 0 {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"insufficient_balance": true,
+			"balance":              getWalletBalance(userID),
+			"total":                total,
+		})
+		return
+	}
+
+	charges := make([]packCharge, len(items))
+	for i, item := range items {
+		charges[i] = packCharge{ListingID: item.ListingID, ShareMode: item.ShareMode, Uses: item.Quantity, Amount: item.Subtotal}
+		if item.ShareMode == "per_use" {
+			charges[i].Description = fmt.Sprintf("Purchase %d uses from cart: %s", item.Quantity, item.PackName)
+		} else {
+			charges[i].Description = fmt.Sprintf("Purchase %d month(s) subscription from cart: %s", item.Months, item.PackName)
+		}
+	}
+	if err := recordPackCharges(tx, userID, getClientIP(r), charges); err != nil {
+		log.Printf("[CART-CHECKOUT] %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	for _, item := range items {
+		if _, err := tx.Exec("DELETE FROM cart_items WHERE id = ?", item.id); err != nil {
+			log.Printf("[CART-CHECKOUT] failed to remove pack %d from cart of user %d: %v", item.ListingID, userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[CART-CHECKOUT] failed to commit transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	for _, item := range items {
+		publishPackSaleEvent(item.ListingID, "purchase", float64(item.Subtotal))
+	}
+	grantReferralRewards(userID)
+	globalCache.InvalidateUserPurchased(userID)
+
+	log.Printf("[CART-CHECKOUT] user %d bought %d packs, cost=%d", userID, len(items), total)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"success":          true,
+		"credits_deducted": total,
+		"items":            items,
+	})
+}
+
+// packCharge is one pack paid for in a multi-pack purchase (cart or bundle).
+type packCharge struct {
+	ListingID   int64
+	ShareMode   string
+	Uses        int // per_use uses bought
+	Amount      int // credits charged for this pack
+	Description string
+}
+
+// recordPackCharges records, inside tx, a 'purchase' credits transaction,
+// the bought per_use quota and the library entry for each charge. The caller
+// has already debited the wallet with the sum of the amounts.
+func recordPackCharges(tx *sql.Tx, userID int64, ip string, charges []packCharge) error {
+	for _, c := range charges {
+		if _, err := tx.Exec(
+			`INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, description, ip_address)
+			 VALUES (?, 'purchase', ?, ?, ?, ?)`,
+			userID, -float64(c.Amount), c.ListingID, c.Description, ip,
+		); err != nil {
+			return fmt.Errorf("failed to record transaction (user=%d, listing=%d): %w", userID, c.ListingID, err)
+		}
+		if c.ShareMode == "per_use" {
+			if err := addPackUses(tx, userID, c.ListingID, c.Uses); err != nil {
+				return fmt.Errorf("failed to update total_purchased (user=%d, listing=%d): %w", userID, c.ListingID, err)
+			}
+		}
+		if err := upsertUserPurchasedPackTx(tx, userID, c.ListingID); err != nil {
+			return fmt.Errorf("failed to upsert purchased pack (user=%d, listing=%d): %w", userID, c.ListingID, err)
+		}
+	}
+	return nil
+}
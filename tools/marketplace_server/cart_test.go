@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserCartCheckout(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, credits_balance) VALUES (2, 'sn', 'buyer', 'Buyer', 100)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (100, 1, 1, x'00', 'Per use', 'per_use', 10, 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (101, 1, 1, x'00', 'Monthly', 'subscription', 20, 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (102, 1, 1, x'00', 'Pricey', 'per_use', 500, 'published')")
+
+	do := func(handler http.HandlerFunc, method, target, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-User-ID", "2")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	balance := func() float64 { return getWalletBalance(2) }
+
+	for _, body := range []string{`{"listing_id": 100, "quantity": 3}`, `{"listing_id": 101, "months": 2}`, `{"listing_id": 102}`} {
+		if code, resp := do(handleUserCart, http.MethodPost, "/user/cart", body); code != http.StatusOK {
+			t.Fatalf("add %s: status %d, %v", body, code, resp)
+		}
+	}
+	if code, resp := do(handleUserCart, http.MethodGet, "/user/cart", ""); code != http.StatusOK || resp["count"] != float64(3) || resp["total"] != float64(570) {
+		t.Fatalf("list: status %d, %v", code, resp)
+	}
+
+	// Insufficient funds: nothing is charged and the cart is kept.
+	if _, resp := do(handleUserCartCheckout, http.MethodPost, "/user/cart/checkout", ""); resp["insufficient_balance"] != true {
+		t.Fatalf("checkout over balance: %v", resp)
+	}
+	if balance() != 100 {
+		t.Errorf("balance after refused checkout = %v, want 100", balance())
+	}
+
+	if code, _ := do(handleUserCart, http.MethodDelete, "/user/cart?listing_id=102", ""); code != http.StatusOK {
+		t.Fatalf("remove: status %d", code)
+	}
+
+	// A pack unpublished after being added is dropped and must be reviewed.
+	mustExec(t, "UPDATE pack_listings SET status = 'delisted' WHERE id = 101")
+	code, resp := do(handleUserCartCheckout, http.MethodPost, "/user/cart/checkout", "")
+	if code != http.StatusConflict || resp["error"] != "items_unavailable" {
+		t.Fatalf("checkout with unpublished pack: status %d, %v", code, resp)
+	}
+	if balance() != 100 {
+		t.Errorf("balance after unavailable checkout = %v, want 100", balance())
+	}
+
+	code, resp = do(handleUserCartCheckout, http.MethodPost, "/user/cart/checkout", "")
+	if code != http.StatusOK || resp["credits_deducted"] != float64(30) {
+		t.Fatalf("checkout: status %d, %v", code, resp)
+	}
+	if balance() != 70 {
+		t.Errorf("balance after checkout = %v, want 70", balance())
+	}
+	var purchased, uses, txCount, cartCount int
+	db.QueryRow("SELECT COUNT(*) FROM user_purchased_packs WHERE user_id = 2 AND listing_id = 100").Scan(&purchased)
+	db.QueryRow("SELECT total_purchased FROM pack_usage_records WHERE user_id = 2 AND listing_id = 100").Scan(&uses)
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = 2 AND transaction_type = 'purchase'").Scan(&txCount)
+	db.QueryRow("SELECT COUNT(*) FROM cart_items WHERE user_id = 2").Scan(&cartCount)
+	if purchased != 1 || uses != 3 || txCount != 1 || cartCount != 0 {
+		t.Errorf("after checkout: purchased=%d uses=%d transactions=%d cart=%d", purchased, uses, txCount, cartCount)
+	}
+
+	if code, _ := do(handleUserCart, http.MethodPost, "/user/cart", `{"listing_id": 101}`); code != http.StatusNotFound {
+		t.Errorf("add unpublished pack: status %d, want 404", code)
+	}
+}
+
+func TestUserCartQuantityLimit(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, credits_balance) VALUES (2, 'sn', 'buyer', 'Buyer', 100)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (100, 1, 1, x'00', 'Per use', 'per_use', 10, 'published')")
+
+	req := httptest.NewRequest(http.MethodPost, "/user/cart", strings.NewReader(`{"listing_id": 100, "quantity": 1000000000000000000}`))
+	req.Header.Set("X-User-ID", "2")
+	rec := httptest.NewRecorder()
+	handleUserCart(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("huge quantity: status %d, want 400", rec.Code)
+	}
+
+	// A quantity that would overflow the total is refused at checkout
+	// without touching the wallet.
+	mustExec(t, "INSERT INTO cart_items (user_id, listing_id, quantity, months) VALUES (2, 100, 922337203685477581, 0)")
+	req = httptest.NewRequest(http.MethodPost, "/user/cart/checkout", nil)
+	req.Header.Set("X-User-ID", "2")
+	rec = httptest.NewRecorder()
+	handleUserCartCheckout(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("overflowing checkout: status %d, want 400: %s", rec.Code, rec.Body)
+	}
+	if got := getWalletBalance(2); got != 100 {
+		t.Errorf("balance = %v, want 100", got)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultCheckoutFunnelDays is the date range used when none is given.
+const defaultCheckoutFunnelDays = 30
+
+// CheckoutFunnel summarizes custom product orders created in a date range by
+// how far the buyer got. Abandoned orders are the ones the stale order
+// sweeper has cancelled or would cancel: still pending past
+// staleOrderMaxAge, or failed with staleOrderFailureReason.
+type CheckoutFunnel struct {
+	DateFrom  string `json:"date_from"`
+	DateTo    string `json:"date_to"`
+	Created   int    `json:"created"`
+	Pending   int    `json:"pending"`
+	Abandoned int    `json:"abandoned"`
+	Failed    int    `json:"failed"`
+	Paid      int    `json:"paid"`
+	Fulfilled int    `json:"fulfilled"`
+	// Captured counts paid and fulfilled orders.
+	Captured       int     `json:"captured"`
+	ConversionRate float64 `json:"conversion_rate"`
+	AbandonRate    float64 `json:"abandon_rate"`
+	// AvgCaptureSeconds is the mean time from order creation to PayPal
+	// capture over the CaptureTimed orders that recorded paid_at.
+	AvgCaptureSeconds float64 `json:"avg_capture_seconds"`
+	CaptureTimed      int     `json:"capture_timed"`
+}
+
+// parseFunnelDateRange reads date_from/date_to (YYYY-MM-DD, inclusive),
+// defaulting to the last defaultCheckoutFunnelDays days.
+func parseFunnelDateRange(r *http.Request, now time.Time) (from, to time.Time, ok bool) {
+	to = now.UTC().Truncate(24 * time.Hour)
+	from = to.AddDate(0, 0, -(defaultCheckoutFunnelDays - 1))
+	var err error
+	if s := r.URL.Query().Get("date_from"); s != "" {
+		if from, err = time.Parse("2006-01-02", s); err != nil {
+			return from, to, false
+		}
+	}
+	if s := r.URL.Query().Get("date_to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			return from, to, false
+		}
+	}
+	return from, to, !to.Before(from)
+}
+
+// queryCheckoutFunnel 统计 [from, to] 日期内创建的自定义商品订单在各阶段的数量。
+// storefrontID 为 0 时统计全平台。
+func queryCheckoutFunnel(storefrontID int64, from, to, now time.Time) (*CheckoutFunnel, error) {
+	staleCutoff := now.Add(-staleOrderMaxAge()).UTC().Format("2006-01-02 15:04:05")
+	query := `SELECT COUNT(*),
+		COALESCE(SUM(CASE WHEN o.status = 'pending' AND o.created_at >= ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN (o.status = 'pending' AND o.created_at < ?)
+			OR (o.status = 'failed' AND COALESCE(o.failure_reason, '') = ?) THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN o.status = 'failed' AND COALESCE(o.failure_reason, '') != ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN o.status = 'paid' THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN o.status = 'fulfilled' THEN 1 ELSE 0 END), 0),
+		AVG(CASE WHEN o.status IN ('paid', 'fulfilled') AND o.paid_at IS NOT NULL
+			THEN (julianday(o.paid_at) - julianday(o.created_at)) * 86400 END),
+		COUNT(CASE WHEN o.status IN ('paid', 'fulfilled') AND o.paid_at IS NOT NULL THEN 1 END)
+		FROM custom_product_orders o
+		JOIN custom_products p ON p.id = o.custom_product_id
+		WHERE o.created_at >= ? AND o.created_at <= ?`
+	args := []interface{}{staleCutoff, staleCutoff, staleOrderFailureReason, staleOrderFailureReason,
+		from.Format("2006-01-02") + " 00:00:00", to.Format("2006-01-02") + " 23:59:59"}
+	if storefrontID > 0 {
+		query += " AND p.storefront_id = ?"
+		args = append(args, storefrontID)
+	}
+
+	f := &CheckoutFunnel{DateFrom: from.Format("2006-01-02"), DateTo: to.Format("2006-01-02")}
+	var avgCapture sql.NullFloat64
+	if err := db.QueryRow(query, args...).Scan(&f.Created, &f.Pending, &f.Abandoned, &f.Failed, &f.Paid, &f.Fulfilled, &avgCapture, &f.CaptureTimed); err != nil {
+		return nil, fmt.Errorf("queryCheckoutFunnel: %w", err)
+	}
+	f.Captured = f.Paid + f.Fulfilled
+	if f.Created > 0 {
+		f.ConversionRate = float64(f.Captured) / float64(f.Created)
+		f.AbandonRate = float64(f.Abandoned) / float64(f.Created)
+	}
+	if avgCapture.Valid {
+		f.AvgCaptureSeconds = avgCapture.Float64
+	}
+	return f, nil
+}
+
+// handleStorefrontCheckoutFunnel handles GET /user/storefront/analytics/checkout?date_from=&date_to=
+// and returns the checkout funnel of the owner's custom products.
+func handleStorefrontCheckoutFunnel(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+	var storefrontID int64
+	if err := db.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID); err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
+		return
+	}
+	now := time.Now()
+	from, to, ok := parseFunnelDateRange(r, now)
+	if !ok {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "日期范围无效"})
+		return
+	}
+	funnel, err := queryCheckoutFunnel(storefrontID, from, to, now)
+	if err != nil {
+		log.Printf("[CHECKOUT-FUNNEL] failed to query funnel for storefront %d: %v", storefrontID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "加载失败"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, funnel)
+}
+
+// handleAdminCheckoutFunnel returns the platform checkout funnel, or one
+// store's with storefront_id.
+// GET /api/admin/sales/checkout-funnel?storefront_id=&date_from=&date_to=
+func handleAdminCheckoutFunnel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	var storefrontID int64
+	if s := r.URL.Query().Get("storefront_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid storefront_id"})
+			return
+		}
+		storefrontID = id
+	}
+	now := time.Now()
+	from, to, ok := parseFunnelDateRange(r, now)
+	if !ok {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "日期范围无效"})
+		return
+	}
+	funnel, err := queryCheckoutFunnel(storefrontID, from, to, now)
+	if err != nil {
+		log.Printf("[handleAdminCheckoutFunnel] %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, funnel)
+}
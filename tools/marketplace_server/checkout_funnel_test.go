@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQueryCheckoutFunnel(t *testing.T) {
+	useTestDB(t)
+	now := time.Date(2026, 6, 10, 12, 0, 0, 0, time.UTC)
+	ts := func(d time.Duration) string { return now.Add(-d).Format("2006-01-02 15:04:05") }
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'a', 'A'), (2, 'sn', 'b', 'B')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop'), (11, 2, 'Other', 'other')")
+	mustExec(t, "INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, credits_amount) VALUES (5, 10, 'Credits', 'credits', 10, 100), (6, 11, 'Other', 'credits', 10, 100)")
+	orders := []struct {
+		product int
+		status  string
+		reason  string
+		created time.Duration
+		paid    interface{}
+	}{
+		{5, "pending", "", time.Hour, nil},                                     // still within the stale window
+		{5, "pending", "", 48 * time.Hour, nil},                                // abandoned, not yet swept
+		{5, "failed", staleOrderFailureReason, 72 * time.Hour, nil},            // swept as abandoned
+		{5, "failed", "", 72 * time.Hour, nil},                                 // capture declined
+		{5, "paid", "", 5 * time.Hour, ts(5*time.Hour - 60*time.Second)},       // captured after 60s
+		{5, "fulfilled", "", 6 * time.Hour, ts(6*time.Hour - 180*time.Second)}, // captured after 180s
+		{5, "fulfilled", "", 7 * time.Hour, nil},                               // captured before paid_at existed
+		{5, "paid", "", 60 * 24 * time.Hour, ts(60 * 24 * time.Hour)},          // outside the range
+		{6, "paid", "", time.Hour, ts(time.Hour)},                              // another store
+	}
+	for _, o := range orders {
+		mustExec(t, `INSERT INTO custom_product_orders (custom_product_id, user_id, amount_usd, status, failure_reason, created_at, paid_at)
+			VALUES (?, 2, 10, ?, ?, ?, ?)`, o.product, o.status, o.reason, ts(o.created), o.paid)
+	}
+
+	from, to, ok := parseFunnelDateRange(httptest.NewRequest("GET", "/?date_from=2026-06-01", nil), now)
+	if !ok {
+		t.Fatal("date range rejected")
+	}
+	f, err := queryCheckoutFunnel(10, from, to, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Created != 7 || f.Pending != 1 || f.Abandoned != 2 || f.Failed != 1 || f.Paid != 1 || f.Fulfilled != 2 || f.Captured != 3 {
+		t.Errorf("funnel %+v", f)
+	}
+	if f.CaptureTimed != 2 || f.AvgCaptureSeconds < 119 || f.AvgCaptureSeconds > 121 {
+		t.Errorf("capture timing %v over %d orders, want 120s over 2", f.AvgCaptureSeconds, f.CaptureTimed)
+	}
+
+	platform, err := queryCheckoutFunnel(0, from, to, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if platform.Created != 8 {
+		t.Errorf("platform created %d, want 8", platform.Created)
+	}
+
+	if _, _, ok := parseFunnelDateRange(httptest.NewRequest("GET", "/?date_from=2026-06-10&date_to=2026-06-01", nil), now); ok {
+		t.Error("reversed date range accepted")
+	}
+}
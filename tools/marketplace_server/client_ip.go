@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Client addresses feed rate limits, the admin login lockout and the audit
+// log. X-Forwarded-For and X-Real-Ip are written by whoever sends the
+// request, so they are only believed when the connection itself comes from a
+// trusted reverse proxy: loopback, plus the addresses and CIDR ranges listed
+// in MARKETPLACE_TRUSTED_PROXIES (comma-separated). Otherwise the peer
+// address is used.
+var trustedProxies = parseTrustedProxies(os.Getenv("MARKETPLACE_TRUSTED_PROXIES"))
+
+// parseTrustedProxies parses a comma-separated list of IPs and CIDR ranges.
+// Invalid entries are logged and skipped.
+func parseTrustedProxies(list string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		} else if _, n, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		log.Printf("[WARN] ignoring invalid MARKETPLACE_TRUSTED_PROXIES entry %q", entry)
+	}
+	return nets
+}
+
+// isTrustedProxy reports whether ip may set forwarding headers.
+func isTrustedProxy(ip net.IP) bool {
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getClientIP extracts the client IP address from the request. Behind
+// trusted proxies it is the right-most X-Forwarded-For address not added by
+// one of them.
+func getClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if peer := net.ParseIP(host); peer == nil || !isTrustedProxy(peer) {
+		return host
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		parts := strings.Split(xff, ",")
+		for i := len(parts) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(parts[i])
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				break
+			}
+			if i == 0 || !isTrustedProxy(ip) {
+				return addr
+			}
+		}
+		return host
+	}
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-Ip")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return host
+}
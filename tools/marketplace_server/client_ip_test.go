@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetClientIP(t *testing.T) {
+	prev := trustedProxies
+	trustedProxies = parseTrustedProxies("10.0.0.0/8, 192.0.2.10, bogus")
+	t.Cleanup(func() { trustedProxies = prev })
+
+	for _, tc := range []struct {
+		name, remote, xff, realIP, want string
+	}{
+		{"direct client", "203.0.113.5:4000", "", "", "203.0.113.5"},
+		{"spoofed header from a client", "203.0.113.5:4000", "1.2.3.4", "5.6.7.8", "203.0.113.5"},
+		{"local proxy", "127.0.0.1:4000", "198.51.100.7", "", "198.51.100.7"},
+		{"client prepends a fake hop", "10.1.2.3:4000", "1.2.3.4, 198.51.100.7", "", "198.51.100.7"},
+		{"chain of trusted proxies", "10.1.2.3:4000", "198.51.100.7, 192.0.2.10, 10.9.9.9", "", "198.51.100.7"},
+		{"X-Real-Ip from a trusted proxy", "192.0.2.10:4000", "", "198.51.100.8", "198.51.100.8"},
+		{"malformed forwarding header", "10.1.2.3:4000", "not-an-ip", "", "10.1.2.3"},
+		{"IPv6 peer", "[2001:db8::1]:4000", "1.2.3.4", "", "2001:db8::1"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		if tc.xff != "" {
+			req.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-Ip", tc.realIP)
+		}
+		if got := getClientIP(req); got != tc.want {
+			t.Errorf("%s: getClientIP = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Content filter for user-generated text: product names and descriptions,
+// store names and descriptions, and the name and description of packs
+// submitted for review. Text containing a blocked word or more links than
+// allowed is rejected outright. Text containing a flagged word is saved but
+// recorded in content_flags so an admin can look at it. Blocked words are the
+// store slug profanity list plus the content_blocked_words setting; flagged
+// words come from content_flagged_words only.
+const defaultContentMaxURLs = 3
+
+// contentURLPattern matches the links counted by the spam check.
+var contentURLPattern = regexp.MustCompile(`(?i)(?:https?://|www\.)[^\s]+`)
+
+// contentFilterEnabled reports whether user text is filtered (on unless the
+// content_filter_enabled setting is "0").
+func contentFilterEnabled() bool {
+	return getSetting("content_filter_enabled") != "0"
+}
+
+// contentMaxURLs returns how many links one text may contain.
+func contentMaxURLs() int {
+	n, err := strconv.Atoi(getSetting("content_max_urls"))
+	if err != nil || n < 0 {
+		return defaultContentMaxURLs
+	}
+	return n
+}
+
+// matchContentTerm returns the first term found in text, or "". Terms made of
+// ASCII letters and digits only must match a whole word, so that "class" does
+// not hit "ass"; any other term, e.g. Chinese, matches anywhere.
+func matchContentTerm(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		words[w] = true
+	}
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		if isASCIIWord(term) {
+			if words[term] {
+				return term
+			}
+		} else if strings.Contains(lower, term) {
+			return term
+		}
+	}
+	return ""
+}
+
+func isASCIIWord(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkUserContent runs the content filter over texts. reject is the error
+// message to show when the text must not be saved; otherwise flag, if not
+// empty, is why the text should be reviewed by an admin.
+func checkUserContent(texts ...string) (reject, flag string) {
+	if !contentFilterEnabled() {
+		return "", ""
+	}
+	blocked := append(append([]string{}, defaultProfaneSlugWords...), slugWordList(getSetting("store_slug_profanity_words"))...)
+	blocked = append(blocked, contentWordList(getSetting("content_blocked_words"))...)
+	flagged := contentWordList(getSetting("content_flagged_words"))
+	maxURLs := contentMaxURLs()
+	for _, text := range texts {
+		if matchContentTerm(text, blocked) != "" {
+			return "内容包含不当词汇，请修改后重试", ""
+		}
+		if len(contentURLPattern.FindAllString(text, -1)) > maxURLs {
+			return fmt.Sprintf("内容包含过多链接（最多 %d 个），请修改后重试", maxURLs), ""
+		}
+		if term := matchContentTerm(text, flagged); term != "" && flag == "" {
+			flag = "flagged word: " + term
+		}
+	}
+	return "", flag
+}
+
+// contentWordList splits a comma/newline separated setting into lower-case
+// terms. Unlike slugWordList it keeps spaces, so phrases can be listed.
+func contentWordList(value string) []string {
+	var terms []string
+	for _, t := range strings.FieldsFunc(strings.ToLower(value), func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// flagUserContent records that an item's text needs admin review. An item has
+// at most one open flag; flagging it again updates the reason. An empty reason
+// is a no-op, so callers can pass checkUserContent's result directly.
+func flagUserContent(itemType string, itemID int64, reason, excerpt string) {
+	if reason == "" {
+		return
+	}
+	if r := []rune(excerpt); len(r) > 200 {
+		excerpt = string(r[:200])
+	}
+	res, err := db.Exec(`UPDATE content_flags SET reason = ?, excerpt = ?, created_at = CURRENT_TIMESTAMP
+		WHERE item_type = ? AND item_id = ? AND status = 'open'`, reason, excerpt, itemType, itemID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n > 0 {
+			return
+		}
+		_, err = db.Exec("INSERT INTO content_flags (item_type, item_id, reason, excerpt) VALUES (?, ?, ?, ?)",
+			itemType, itemID, reason, excerpt)
+	}
+	if err != nil {
+		log.Printf("[CONTENT-FILTER] failed to flag %s %d: %v", itemType, itemID, err)
+		return
+	}
+	log.Printf("[CONTENT-FILTER] flagged %s %d for review: %s", itemType, itemID, reason)
+}
+
+// ContentFlag is a piece of user text waiting for admin review.
+type ContentFlag struct {
+	ID         int64  `json:"id"`
+	ItemType   string `json:"item_type"`
+	ItemID     int64  `json:"item_id"`
+	Reason     string `json:"reason"`
+	Excerpt    string `json:"excerpt"`
+	Status     string `json:"status"`
+	CreatedAt  string `json:"created_at"`
+	ResolvedAt string `json:"resolved_at,omitempty"`
+}
+
+// handleAdminContentFlags handles GET /api/admin/content-flags?status=open|resolved
+// and POST /api/admin/content-flags/{id}/resolve.
+func handleAdminContentFlags(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/admin/content-flags")
+	if strings.HasSuffix(path, "/resolve") {
+		if r.Method != http.MethodPost {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		id, err := strconv.ParseInt(strings.Trim(strings.TrimSuffix(path, "/resolve"), "/"), 10, 64)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_id"})
+			return
+		}
+		adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+		res, err := db.Exec(`UPDATE content_flags SET status = 'resolved', resolved_by = ?, resolved_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND status = 'open'`, adminID, id)
+		if err != nil {
+			log.Printf("[CONTENT-FILTER] failed to resolve flag %d: %v", id, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "flag not found"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+	if path != "" && path != "/" {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+	rows, err := db.Query(`SELECT id, item_type, item_id, reason, excerpt, status, created_at, COALESCE(resolved_at, '')
+		FROM content_flags WHERE status = ? ORDER BY id DESC LIMIT 500`, status)
+	if err != nil {
+		log.Printf("[CONTENT-FILTER] failed to list flags: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer rows.Close()
+	flags := []ContentFlag{}
+	for rows.Next() {
+		var f ContentFlag
+		if err := rows.Scan(&f.ID, &f.ItemType, &f.ItemID, &f.Reason, &f.Excerpt, &f.Status, &f.CreatedAt, &f.ResolvedAt); err != nil {
+			log.Printf("[CONTENT-FILTER] scan error: %v", err)
+			continue
+		}
+		flags = append(flags, f)
+	}
+	jsonResponse(w, http.StatusOK, flags)
+}
+
+// handleAdminContentFilterSettings handles GET/POST /admin/settings/content-filter.
+func handleAdminContentFilterSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"enabled":       contentFilterEnabled(),
+			"blocked_words": strings.Join(contentWordList(getSetting("content_blocked_words")), "\n"),
+			"flagged_words": strings.Join(contentWordList(getSetting("content_flagged_words")), "\n"),
+			"max_urls":      contentMaxURLs(),
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxURLs, err := strconv.Atoi(strings.TrimSpace(r.FormValue("max_urls")))
+	if err != nil || maxURLs < 0 || maxURLs > 100 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "链接数上限必须为 0 到 100 之间的整数"})
+		return
+	}
+	enabled := "0"
+	if v := r.FormValue("enabled"); v == "1" || v == "true" {
+		enabled = "1"
+	}
+	for key, value := range map[string]string{
+		"content_filter_enabled": enabled,
+		"content_blocked_words":  strings.Join(contentWordList(r.FormValue("blocked_words")), ","),
+		"content_flagged_words":  strings.Join(contentWordList(r.FormValue("flagged_words")), ","),
+		"content_max_urls":       strconv.Itoa(maxURLs),
+	} {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckUserContent(t *testing.T) {
+	useTestDB(t)
+	if _, err := db.Exec(`INSERT INTO settings (key, value) VALUES
+		('content_blocked_words', '假货,buy followers'), ('content_flagged_words', 'telegram,微信'), ('content_max_urls', '2')`); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		text         string
+		reject, flag bool
+	}{
+		{"A perfectly normal product", false, false},
+		{"Classic assistant pack", false, false}, // no whole-word hit
+		{"This is shit", true, false},
+		{"正品，绝无假货", true, false},
+		{"We BUY FOLLOWERS cheap", true, false},
+		{"see https://a.example and www.b.example", false, false},
+		{"https://a.example https://b.example http://c.example", true, false},
+		{"Contact me on Telegram", false, true},
+		{"加微信购买", false, true},
+	}
+	for _, c := range cases {
+		reject, flag := checkUserContent(c.text)
+		if (reject != "") != c.reject || (flag != "") != c.flag {
+			t.Errorf("%q: reject=%q flag=%q, want reject=%v flag=%v", c.text, reject, flag, c.reject, c.flag)
+		}
+	}
+
+	if msg := validateStoreName("shit store"); msg == "" {
+		t.Error("validateStoreName accepted a blocked word")
+	}
+	p := CustomProduct{ProductName: "Good pack", Description: "fuck", PriceUSD: 1, ProductType: "credits", CreditsAmount: 1}
+	if msg := validateCustomProduct(p); msg == "" {
+		t.Error("validateCustomProduct accepted a blocked word in the description")
+	}
+
+	db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('content_filter_enabled', '0')")
+	if reject, flag := checkUserContent("shit on telegram"); reject != "" || flag != "" {
+		t.Errorf("filter off: reject=%q flag=%q", reject, flag)
+	}
+}
+
+func TestFlagUserContentAndResolve(t *testing.T) {
+	useTestDB(t)
+
+	flagUserContent("storefront", 7, "", "ignored")
+	flagUserContent("storefront", 7, "flagged word: telegram", "Shop")
+	flagUserContent("storefront", 7, "flagged word: 微信", "Shop 2")
+	var n int
+	var reason string
+	db.QueryRow("SELECT COUNT(*), MAX(reason) FROM content_flags WHERE item_type = 'storefront' AND item_id = 7").Scan(&n, &reason)
+	if n != 1 || reason != "flagged word: 微信" {
+		t.Fatalf("flags = %d (%q), want one open flag with the latest reason", n, reason)
+	}
+
+	rec := httptest.NewRecorder()
+	handleAdminContentFlags(rec, httptest.NewRequest(http.MethodGet, "/api/admin/content-flags", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"excerpt":"Shop 2"`) {
+		t.Fatalf("list: %d %s", rec.Code, rec.Body.String())
+	}
+	var id int64
+	db.QueryRow("SELECT id FROM content_flags").Scan(&id)
+	for i, want := range []int{http.StatusOK, http.StatusNotFound} {
+		rec = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/content-flags/"+strconv.FormatInt(id, 10)+"/resolve", nil)
+		req.Header.Set("X-Admin-ID", "1")
+		handleAdminContentFlags(rec, req)
+		if rec.Code != want {
+			t.Fatalf("resolve #%d: status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Credits expiry. When credits_expiry_days is set, every credits grant made
+// from then on (top-ups, bonuses, referral rewards, admin top-ups, gifts
+// received) gets an
+// expires_at stamp. Spending is attributed to the grants that expire soonest,
+// so a grant that has been partly spent only expires what is left of it.
+// startCreditsExpirySweeper writes that remainder off as a negative 'expiry'
+// transaction linked to the grant, capped by the wallet balance. Refunds and
+// credits granted while the policy was off never expire; turning the policy
+// off clears the stamps of grants that have not expired yet.
+const (
+	maxCreditsExpiryDays   = 3650
+	creditsExpirySweepRate = time.Hour
+	creditsExpiryTimeFmt   = "2006-01-02 15:04:05"
+)
+
+// creditsExpiryGrantTypes are the transaction types whose positive amounts
+// are subject to expiry.
+var creditsExpiryGrantTypes = []string{"purchase", "topup", "admin_topup", "bonus", "referral", "gift_in"}
+
+// creditsExpiryDays returns the configured lifetime of granted credits, or 0
+// when credits never expire.
+func creditsExpiryDays() int {
+	days, err := strconv.Atoi(getSetting("credits_expiry_days"))
+	if err != nil || days <= 0 {
+		return 0
+	}
+	return days
+}
+
+// stampCreditsExpiry sets expires_at on grants made since the policy was
+// turned on that do not have one yet.
+func stampCreditsExpiry() {
+	days := creditsExpiryDays()
+	since := getSetting("credits_expiry_since")
+	if days == 0 || since == "" {
+		return
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(creditsExpiryGrantTypes)), ", ")
+	args := []interface{}{"+" + strconv.Itoa(days) + " days"}
+	for _, t := range creditsExpiryGrantTypes {
+		args = append(args, t)
+	}
+	args = append(args, since)
+	if _, err := db.Exec(`UPDATE credits_transactions SET expires_at = datetime(created_at, ?)
+		WHERE expires_at IS NULL AND amount > 0 AND transaction_type IN (`+placeholders+`)
+		AND datetime(created_at) >= datetime(?)`, args...); err != nil {
+		log.Printf("[CREDITS-EXPIRY] failed to stamp grants: %v", err)
+	}
+}
+
+// CreditsExpiryLot is what is left of one expiring grant.
+type CreditsExpiryLot struct {
+	GrantID   int64   `json:"-"`
+	Amount    float64 `json:"amount"`
+	ExpiresAt string  `json:"expires_at"`
+	ExpiredAt string  `json:"-"`
+}
+
+// creditsExpiryLots replays the transactions of userID and returns the
+// unspent part of each grant with an expiry, soonest first. Debits draw from
+// the live lots that expire soonest among those granted before the debit,
+// then from credits that never expire. Transactions paid outside the wallet
+// (paid_externally) spend nothing. A lot is dead once it has expired:
+// its 'expiry' transaction, if any, took what was left of it.
+func creditsExpiryLots(userID int64) ([]*CreditsExpiryLot, error) {
+	rows, err := db.Query(`SELECT id, transaction_type, amount, datetime(created_at),
+		COALESCE(expires_at, ''), COALESCE(expired_at, ''), COALESCE(expiry_grant_id, 0), paid_externally
+		FROM credits_transactions WHERE user_id = ? ORDER BY datetime(created_at), id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []*CreditsExpiryLot
+	byID := map[int64]*CreditsExpiryLot{}
+	for rows.Next() {
+		var id, grantID int64
+		var txType, createdAt, expiresAt, expiredAt string
+		var amount float64
+		var paidExternally bool
+		if err := rows.Scan(&id, &txType, &amount, &createdAt, &expiresAt, &expiredAt, &grantID, &paidExternally); err != nil {
+			return nil, err
+		}
+		switch {
+		case amount > 0 && expiresAt != "":
+			lot := &CreditsExpiryLot{GrantID: id, Amount: amount, ExpiresAt: expiresAt, ExpiredAt: expiredAt}
+			lots = append(lots, lot)
+			byID[id] = lot
+		case txType == "expiry" && byID[grantID] != nil:
+			byID[grantID].Amount = 0
+		case amount < 0 && !paidExternally:
+			sort.SliceStable(lots, func(i, j int) bool { return lots[i].ExpiresAt < lots[j].ExpiresAt })
+			owed := -amount
+			for _, lot := range lots {
+				if owed <= 0 {
+					break
+				}
+				if lot.Amount <= 0 || (lot.ExpiredAt != "" && lot.ExpiredAt <= createdAt) {
+					continue
+				}
+				take := lot.Amount
+				if take > owed {
+					take = owed
+				}
+				lot.Amount -= take
+				owed -= take
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(lots, func(i, j int) bool { return lots[i].ExpiresAt < lots[j].ExpiresAt })
+	return lots, nil
+}
+
+// upcomingCreditsExpiries returns the credits of userID that will expire,
+// soonest first. It is empty while the policy is off.
+func upcomingCreditsExpiries(userID int64) []CreditsExpiryLot {
+	upcoming := []CreditsExpiryLot{}
+	if creditsExpiryDays() == 0 {
+		return upcoming
+	}
+	stampCreditsExpiry()
+	lots, err := creditsExpiryLots(userID)
+	if err != nil {
+		log.Printf("[CREDITS-EXPIRY] failed to load grants of user %d: %v", userID, err)
+		return upcoming
+	}
+	// Credits spent from a wallet shared with other accounts are not in this
+	// user's transactions, so never promise more than the balance.
+	balance := getWalletBalance(userID)
+	for _, lot := range lots {
+		if lot.ExpiredAt != "" || lot.Amount <= 0 || balance <= 0 {
+			continue
+		}
+		if lot.Amount > balance {
+			lot.Amount = balance
+		}
+		balance -= lot.Amount
+		upcoming = append(upcoming, *lot)
+	}
+	return upcoming
+}
+
+// startCreditsExpirySweeper periodically expires aged credits.
+func startCreditsExpirySweeper() {
+	go func() {
+		ticker := time.NewTicker(creditsExpirySweepRate)
+		defer ticker.Stop()
+		for {
+			expireCredits(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// expireCredits writes off the unspent part of every grant that expired by
+// now and returns the number of credits expired.
+func expireCredits(now time.Time) float64 {
+	if creditsExpiryDays() == 0 {
+		return 0
+	}
+	stampCreditsExpiry()
+	cutoff := now.UTC().Format(creditsExpiryTimeFmt)
+	rows, err := db.Query(`SELECT DISTINCT user_id FROM credits_transactions
+		WHERE expires_at IS NOT NULL AND expires_at <= ? AND expired_at IS NULL`, cutoff)
+	if err != nil {
+		log.Printf("[CREDITS-EXPIRY] failed to query due grants: %v", err)
+		return 0
+	}
+	var userIDs []int64
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+
+	var total float64
+	for _, userID := range userIDs {
+		total += expireUserCredits(userID, cutoff)
+	}
+	if total > 0 {
+		log.Printf("[CREDITS-EXPIRY] expired %.0f credits of %d users", total, len(userIDs))
+	}
+	return total
+}
+
+// expireUserCredits expires the due grants of userID and returns the number
+// of credits taken from the wallet. Each grant is marked expired in the same
+// transaction as its write-off so it is only ever expired once.
+func expireUserCredits(userID int64, cutoff string) float64 {
+	lots, err := creditsExpiryLots(userID)
+	if err != nil {
+		log.Printf("[CREDITS-EXPIRY] failed to load grants of user %d: %v", userID, err)
+		return 0
+	}
+	balance := getWalletBalance(userID)
+	var expired float64
+	for _, lot := range lots {
+		if lot.ExpiredAt != "" || lot.ExpiresAt > cutoff {
+			continue
+		}
+		amount := lot.Amount
+		if amount > balance {
+			amount = balance
+		}
+		if amount < 0 {
+			amount = 0
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("[CREDITS-EXPIRY] failed to begin transaction: %v", err)
+			return expired
+		}
+		res, err := tx.Exec("UPDATE credits_transactions SET expired_at = CURRENT_TIMESTAMP WHERE id = ? AND expired_at IS NULL", lot.GrantID)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("[CREDITS-EXPIRY] failed to mark grant %d expired: %v", lot.GrantID, err)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			continue
+		}
+		if amount > 0 {
+			deducted, err := deductWalletBalance(tx, userID, amount)
+			if err != nil || deducted == 0 {
+				tx.Rollback()
+				log.Printf("[CREDITS-EXPIRY] failed to deduct %.0f credits from user %d: %v", amount, userID, err)
+				continue
+			}
+			if _, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, description, expiry_grant_id)
+				VALUES (?, 'expiry', ?, ?, ?)`, userID, -amount, "Credits expired", lot.GrantID); err != nil {
+				tx.Rollback()
+				log.Printf("[CREDITS-EXPIRY] failed to record expiry of grant %d: %v", lot.GrantID, err)
+				continue
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("[CREDITS-EXPIRY] failed to commit expiry of grant %d: %v", lot.GrantID, err)
+			continue
+		}
+		balance -= amount
+		expired += amount
+	}
+	return expired
+}
+
+// handleUserCreditsExpiry handles GET /user/credits/expiring: the user's
+// credits that will expire and when.
+func handleUserCreditsExpiry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	upcoming := upcomingCreditsExpiries(userID)
+	var total float64
+	for _, lot := range upcoming {
+		total += lot.Amount
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"expiry_days": creditsExpiryDays(),
+		"total":       total,
+		"expiring":    upcoming,
+	})
+}
+
+// handleAdminCreditsExpirySettings handles GET/POST /admin/settings/credits-expiry.
+// expiry_days=0 turns the policy off.
+func handleAdminCreditsExpirySettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"expiry_days": creditsExpiryDays(),
+			"since":       getSetting("credits_expiry_since"),
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(r.FormValue("expiry_days")))
+	if err != nil || days < 0 || days > maxCreditsExpiryDays {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "有效期必须在 0-3650 天之间"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[ADMIN] failed to begin transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer tx.Rollback()
+	type stmt struct {
+		query string
+		args  []interface{}
+	}
+	stmts := []stmt{{"INSERT OR REPLACE INTO settings (key, value) VALUES ('credits_expiry_days', ?)", []interface{}{strconv.Itoa(days)}}}
+	if days == 0 {
+		// Credits granted under the policy but not yet expired are kept.
+		stmts = append(stmts,
+			stmt{"DELETE FROM settings WHERE key = 'credits_expiry_since'", nil},
+			stmt{"UPDATE credits_transactions SET expires_at = NULL WHERE expires_at IS NOT NULL AND expired_at IS NULL", nil})
+	} else if getSetting("credits_expiry_since") == "" {
+		// Only grants made from now on expire.
+		stmts = append(stmts, stmt{"INSERT OR REPLACE INTO settings (key, value) VALUES ('credits_expiry_since', ?)",
+			[]interface{}{time.Now().UTC().Format(creditsExpiryTimeFmt)}})
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(s.query, s.args...); err != nil {
+			log.Printf("[ADMIN] failed to save credits expiry settings: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ADMIN] failed to commit credits expiry settings: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
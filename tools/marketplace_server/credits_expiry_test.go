@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreditsExpiry(t *testing.T) {
+	useTestDB(t)
+
+	mustExec(t, "INSERT INTO settings (key, value) VALUES ('credits_expiry_days', '30'), ('credits_expiry_since', '2026-01-01 00:00:00')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, credits_balance) VALUES (2, 'sn', 'spender', 'Spender', 80)")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, credits_balance) VALUES (3, 'sn', 'drained', 'Drained', 20)")
+	for _, tx := range []struct {
+		userID int64
+		txType string
+		amount float64
+		at     string
+	}{
+		{2, "topup", 40, "2025-12-01 00:00:00"},      // before the policy: never expires
+		{2, "topup", 100, "2026-01-01 10:00:00"},     // expires 01-31
+		{2, "bonus", 50, "2026-01-10 10:00:00"},      // expires 02-09
+		{2, "purchase", -120, "2026-01-15 10:00:00"}, // spends all of the first grant, 20 of the bonus
+		{2, "email_refund", 10, "2026-01-16 10:00:00"},
+		{3, "topup", 100, "2026-01-01 10:00:00"},
+	} {
+		mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, created_at) VALUES (?, ?, ?, ?)", tx.userID, tx.txType, tx.amount, tx.at)
+	}
+	// A PayPal auto-renewal is recorded as spending but never touches the
+	// wallet, so it leaves the expiring grants alone.
+	mustExec(t, `INSERT INTO credits_transactions (user_id, transaction_type, amount, description, created_at, paid_externally)
+		VALUES (2, 'renew', -30, 'Renew subscription (1 month): Pack (PayPal auto-renewal)', '2026-01-20 10:00:00', 1)`)
+
+	req := httptest.NewRequest(http.MethodGet, "/user/credits/expiring", nil)
+	req.Header.Set("X-User-ID", "2")
+	rec := httptest.NewRecorder()
+	handleUserCreditsExpiry(rec, req)
+	var resp struct {
+		Total    float64            `json:"total"`
+		Expiring []CreditsExpiryLot `json:"expiring"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.Total != 30 || len(resp.Expiring) != 1 || resp.Expiring[0].ExpiresAt != "2026-02-09 10:00:00" {
+		t.Fatalf("upcoming: status %d, %s", rec.Code, rec.Body.String())
+	}
+
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	// The first grant is fully spent; user 3 only has 20 of their 100 left.
+	if got := expireCredits(day("2026-02-05")); got != 20 {
+		t.Errorf("first sweep expired %v, want 20", got)
+	}
+	if got := getWalletBalance(3); got != 0 {
+		t.Errorf("drained balance = %v, want 0", got)
+	}
+	if got := expireCredits(day("2026-02-10")); got != 30 {
+		t.Errorf("second sweep expired %v, want 30", got)
+	}
+	if got := expireCredits(day("2026-03-10")); got != 0 {
+		t.Errorf("repeated sweep expired %v, want 0", got)
+	}
+	if got := getWalletBalance(2); got != 50 {
+		t.Errorf("balance = %v, want 50", got)
+	}
+	var expiryRows int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE transaction_type = 'expiry' AND expiry_grant_id IS NOT NULL").Scan(&expiryRows)
+	if expiryRows != 2 {
+		t.Errorf("expiry transactions = %d, want 2", expiryRows)
+	}
+
+	// Turning the policy off keeps credits that have not expired yet.
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, created_at) VALUES (2, 'referral', 25, ?)", time.Now().UTC().Format(creditsExpiryTimeFmt))
+	stampCreditsExpiry()
+	form := url.Values{"expiry_days": {"0"}}
+	req = httptest.NewRequest(http.MethodPost, "/admin/settings/credits-expiry", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handleAdminCreditsExpirySettings(rec, req)
+	var pending int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE expires_at IS NOT NULL AND expired_at IS NULL").Scan(&pending)
+	if rec.Code != http.StatusOK || pending != 0 || getSetting("credits_expiry_since") != "" {
+		t.Errorf("disable: status %d, pending grants %d, since %q", rec.Code, pending, getSetting("credits_expiry_since"))
+	}
+}
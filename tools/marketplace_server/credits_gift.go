@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"marketplace_server/i18n"
+)
+
+// Peer-to-peer credits gifts. A user sends credits from their wallet to any
+// email address; the recipient needs no account, the credits wait in the
+// email wallet like a gift order's do. The sender's 'gift_out' and the
+// recipient's 'gift_in' transaction are written in the same database
+// transaction as the balance moves. A minimum amount and a daily limit per
+// sender keep the feature from being used to shuffle credits around. The
+// limit is checked inside that transaction, right after its first statement
+// writes the 'gift_out' row: the write takes SQLite's write lock, so
+// concurrent gifts are counted one after another and cannot all pass a stale
+// check.
+const (
+	defaultCreditsGiftMin        = 10
+	defaultCreditsGiftDailyLimit = 1000
+	maxCreditsGiftMessageLen     = 200
+)
+
+// getCreditsGiftLimits returns the smallest gift and the most credits a user
+// may give away per day (UTC).
+func getCreditsGiftLimits() (minAmount, dailyLimit int) {
+	minAmount, err := strconv.Atoi(getSetting("credits_gift_min"))
+	if err != nil || minAmount <= 0 {
+		minAmount = defaultCreditsGiftMin
+	}
+	dailyLimit, err = strconv.Atoi(getSetting("credits_gift_daily_limit"))
+	if err != nil || dailyLimit <= 0 {
+		dailyLimit = defaultCreditsGiftDailyLimit
+	}
+	return minAmount, dailyLimit
+}
+
+// creditsGiftedToday returns how many credits userID has given away today.
+func creditsGiftedToday(q walletQuerier, userID int64) float64 {
+	var total float64
+	q.QueryRow(`SELECT COALESCE(-SUM(amount), 0) FROM credits_transactions
+		WHERE user_id = ? AND transaction_type = 'gift_out' AND date(created_at) = date('now')`, userID).Scan(&total)
+	return total
+}
+
+// handleUserCreditsGift handles POST /user/credits/gift with a JSON body
+// {"email": "...", "amount": 50, "message": "..."}.
+func handleUserCreditsGift(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	var req struct {
+		Email   string  `json:"email"`
+		Amount  float64 `json:"amount"`
+		Message string  `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request_body"})
+		return
+	}
+	recipient := strings.ToLower(strings.TrimSpace(req.Email))
+	message := strings.TrimSpace(req.Message)
+	if !isValidEmailAddress(recipient) {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_email"})
+		return
+	}
+	if len([]rune(message)) > maxCreditsGiftMessageLen {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "gift_message_too_long"})
+		return
+	}
+	minAmount, dailyLimit := getCreditsGiftLimits()
+	if req.Amount != math.Trunc(req.Amount) || req.Amount < float64(minAmount) {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"error": "gift_amount_too_small", "min_amount": minAmount})
+		return
+	}
+
+	senderEmail := getEmailForUser(userID)
+	ownEmails := []string{senderEmail}
+	if linked := linkedWalletEmails(db, userID, senderEmail); linked != nil {
+		ownEmails = linked
+	}
+	for _, e := range ownEmails {
+		if e != "" && strings.EqualFold(e, recipient) {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "gift_cannot_self"})
+			return
+		}
+	}
+	ensureWalletExists(recipient)
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[CREDITS-GIFT] failed to begin transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, description)
+		VALUES (?, 'gift_out', ?, ?)`, userID, -req.Amount, fmt.Sprintf("Gift to %s", recipient)); err != nil {
+		log.Printf("[CREDITS-GIFT] failed to record gift_out for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if gifted := creditsGiftedToday(tx, userID); gifted > float64(dailyLimit) {
+		jsonResponse(w, http.StatusTooManyRequests, map[string]interface{}{
+			"error":       "gift_daily_limit_exceeded",
+			"daily_limit": dailyLimit,
+			"remaining":   math.Max(0, float64(dailyLimit)-(gifted-req.Amount)),
+		})
+		return
+	}
+
+	deducted, err := deductWalletBalance(tx, userID, req.Amount)
+	if err != nil {
+		log.Printf("[CREDITS-GIFT] failed to deduct %.0f credits from user %d: %v", req.Amount, userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if deducted == 0 {
+		jsonResponse(w, http.StatusPaymentRequired, map[string]string{"error": "insufficient_credits"})
+		return
+	}
+	if err := addWalletBalanceByEmailTx(tx, recipient, req.Amount); err != nil {
+		log.Printf("[CREDITS-GIFT] failed to credit %q: %v", recipient, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	// Recipients without an account only get the wallet balance; the
+	// transaction row needs a user.
+	var recipientID int64
+	if tx.QueryRow("SELECT id FROM users WHERE email = ? ORDER BY id ASC LIMIT 1", recipient).Scan(&recipientID) == nil {
+		from := senderEmail
+		if from == "" {
+			from = fmt.Sprintf("user %d", userID)
+		}
+		if _, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, description)
+			VALUES (?, 'gift_in', ?, ?)`, recipientID, req.Amount, fmt.Sprintf("Gift from %s", from)); err != nil {
+			log.Printf("[CREDITS-GIFT] failed to record gift_in for user %d: %v", recipientID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[CREDITS-GIFT] failed to commit gift of user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	log.Printf("[CREDITS-GIFT] user %d sent %.0f credits to %q", userID, req.Amount, recipient)
+
+	if _, err := loadSMTPConfig(); err == nil {
+		go sendCreditsGiftEmail(userID, senderEmail, recipient, int(req.Amount), message)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"success":       true,
+		"amount":        req.Amount,
+		"recipient":     recipient,
+		"balance_after": getWalletBalance(userID),
+	})
+}
+
+// sendCreditsGiftEmail tells the recipient of a credits gift about it.
+// Recipients with an account that opted out of email are skipped. Errors are
+// logged only.
+func sendCreditsGiftEmail(senderID int64, senderEmail, recipient string, amount int, message string) {
+	lang := userLang(senderID)
+	var recipientID int64
+	var emailAllowed int
+	if db.QueryRow("SELECT id, COALESCE(email_allowed, 1) FROM users WHERE email = ? ORDER BY id ASC LIMIT 1", recipient).Scan(&recipientID, &emailAllowed) == nil {
+		if emailAllowed == 0 {
+			log.Printf("[CREDITS-GIFT] skipping gift notice to %q: recipient has opted out", recipient)
+			return
+		}
+		lang = userLang(recipientID)
+	}
+	sender := senderEmail
+	if sender == "" {
+		sender = i18n.T(lang, "site_name")
+	}
+	body := fmt.Sprintf(i18n.T(lang, "credits_gift_email_body"), sender, amount, recipient)
+	if message != "" {
+		body += fmt.Sprintf(i18n.T(lang, "credits_gift_email_message"), message)
+	}
+	if err := sendSystemEmail(recipient, fmt.Sprintf(i18n.T(lang, "credits_gift_email_subject"), amount), body); err != nil {
+		log.Printf("[CREDITS-GIFT] failed to send gift notice to %q: %v", recipient, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUserCreditsGift(t *testing.T) {
+	useTestDB(t)
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, credits_balance) VALUES (2, 'email', 'sender', 'Sender', 'sender@example.com', 300)")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, credits_balance) VALUES (3, 'email', 'friend', 'Friend', 'friend@example.com', 0)")
+	mustExec(t, "INSERT INTO settings (key, value) VALUES ('credits_gift_min', '10'), ('credits_gift_daily_limit', '200')")
+
+	gift := func(body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/user/credits/gift", strings.NewReader(body))
+		req.Header.Set("X-User-ID", "2")
+		rec := httptest.NewRecorder()
+		handleUserCreditsGift(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	for body, want := range map[string]string{
+		`{"email": "friend@example.com", "amount": 5}`:    "gift_amount_too_small",
+		`{"email": "friend@example.com", "amount": 10.5}`: "gift_amount_too_small",
+		`{"email": "not-an-email", "amount": 50}`:         "invalid_email",
+		`{"email": "Sender@example.com", "amount": 50}`:   "gift_cannot_self",
+	} {
+		if code, resp := gift(body); code != http.StatusBadRequest || resp["error"] != want {
+			t.Errorf("%s: status %d, %v", body, code, resp)
+		}
+	}
+
+	if code, resp := gift(`{"email": "friend@example.com", "amount": 150, "message": "Happy birthday"}`); code != http.StatusOK {
+		t.Fatalf("gift to account: status %d, %v", code, resp)
+	}
+	// The second gift goes to an email without an account and hits the daily limit.
+	if code, resp := gift(`{"email": "new@example.com", "amount": 60}`); code != http.StatusTooManyRequests || resp["remaining"] != float64(50) {
+		t.Errorf("over daily limit: status %d, %v", code, resp)
+	}
+	if code, resp := gift(`{"email": "new@example.com", "amount": 50}`); code != http.StatusOK {
+		t.Fatalf("gift to new email: status %d, %v", code, resp)
+	}
+
+	if got := getWalletBalance(2); got != 100 {
+		t.Errorf("sender balance = %v, want 100", got)
+	}
+	if got := getWalletBalance(3); got != 150 {
+		t.Errorf("friend balance = %v, want 150", got)
+	}
+	if got := getWalletBalanceByEmail("new@example.com"); got != 50 {
+		t.Errorf("new email wallet = %v, want 50", got)
+	}
+	var outs, ins int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = 2 AND transaction_type = 'gift_out'").Scan(&outs)
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = 3 AND transaction_type = 'gift_in' AND amount = 150").Scan(&ins)
+	if outs != 2 || ins != 1 {
+		t.Errorf("transactions: gift_out %d, gift_in %d", outs, ins)
+	}
+
+	// A gift the sender cannot afford changes nothing.
+	mustExec(t, "UPDATE settings SET value = '1000' WHERE key = 'credits_gift_daily_limit'")
+	if code, resp := gift(`{"email": "friend@example.com", "amount": 500}`); code != http.StatusPaymentRequired {
+		t.Errorf("insufficient balance: status %d, %v", code, resp)
+	}
+	if getWalletBalance(2) != 100 || getWalletBalance(3) != 150 {
+		t.Errorf("balances changed after failed gift: %v, %v", getWalletBalance(2), getWalletBalance(3))
+	}
+}
+
+func TestUserCreditsGiftDailyLimitConcurrent(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, credits_balance) VALUES (2, 'email', 'sender', 'Sender', 'sender@example.com', 1000)")
+	mustExec(t, "INSERT INTO settings (key, value) VALUES ('credits_gift_min', '10'), ('credits_gift_daily_limit', '200')")
+
+	// Each gift fits the limit on its own; only one of them may go through.
+	const senders = 8
+	codes := make(chan int, senders)
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/user/credits/gift", strings.NewReader(`{"email": "friend@example.com", "amount": 150}`))
+			req.Header.Set("X-User-ID", "2")
+			rec := httptest.NewRecorder()
+			handleUserCreditsGift(rec, req)
+			codes <- rec.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	ok := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			ok++
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d concurrent gifts succeeded, want 1", ok)
+	}
+	if got := getWalletBalance(2); got != 850 {
+		t.Errorf("sender balance = %v, want 850", got)
+	}
+}
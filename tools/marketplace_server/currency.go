@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"marketplace_server/i18n"
+)
+
+// Prices are stored in USD. They can be shown in the buyer's preferred
+// currency using cached exchange rates, while PayPal charges the configured
+// settlement currency (settings key settlement_currency, default USD).
+const (
+	defaultExchangeRateAPI  = "https://open.er-api.com/v6/latest/USD"
+	exchangeRateRefreshRate = 6 * time.Hour
+	currencyCookieName      = "currency"
+)
+
+// exchangeRateCache holds the latest USD-based rates (units of currency per 1 USD).
+type exchangeRateCache struct {
+	mu        sync.RWMutex
+	rates     map[string]float64
+	fetchedAt time.Time
+}
+
+var exchangeRates = &exchangeRateCache{}
+
+// rate returns how many units of currency one USD buys.
+func (c *exchangeRateCache) rate(currency string) (float64, bool) {
+	if currency == "USD" {
+		return 1, true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok := c.rates[currency]
+	return r, ok && r > 0
+}
+
+func (c *exchangeRateCache) set(rates map[string]float64, at time.Time) {
+	c.mu.Lock()
+	c.rates = rates
+	c.fetchedAt = at
+	c.mu.Unlock()
+}
+
+func (c *exchangeRateCache) status() (map[string]float64, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	copied := make(map[string]float64, len(c.rates))
+	for k, v := range c.rates {
+		copied[k] = v
+	}
+	return copied, c.fetchedAt
+}
+
+// getExchangeRateAPIURL returns the rate source URL. The endpoint must return
+// JSON with a "rates" object keyed by currency code, relative to USD.
+func getExchangeRateAPIURL() string {
+	if u := strings.TrimSpace(getSetting("exchange_rate_api_url")); u != "" {
+		return u
+	}
+	return defaultExchangeRateAPI
+}
+
+// refreshExchangeRates fetches current rates and replaces the cache. On
+// failure the previous rates are kept.
+func refreshExchangeRates() error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(getExchangeRateAPIURL())
+	if err != nil {
+		return fmt.Errorf("fetch exchange rates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch exchange rates: status %d", resp.StatusCode)
+	}
+	var body struct {
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decode exchange rates: %w", err)
+	}
+	rates := make(map[string]float64)
+	for _, code := range i18n.SupportedCurrencies() {
+		if r, ok := body.Rates[code]; ok && r > 0 {
+			rates[code] = r
+		}
+	}
+	if len(rates) == 0 {
+		return fmt.Errorf("exchange rate response has no supported currencies")
+	}
+	exchangeRates.set(rates, time.Now())
+	return nil
+}
+
+// startExchangeRateRefresher keeps the exchange rate cache fresh in the background.
+func startExchangeRateRefresher() {
+	go func() {
+		ticker := time.NewTicker(exchangeRateRefreshRate)
+		defer ticker.Stop()
+		for {
+			if err := refreshExchangeRates(); err != nil {
+				log.Printf("[CURRENCY] %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// convertUSD converts amountUSD to currency, rounded to the currency's minor
+// unit. ok is false when no rate is available.
+func convertUSD(amountUSD float64, currency string) (float64, bool) {
+	r, ok := exchangeRates.rate(currency)
+	if !ok {
+		return 0, false
+	}
+	scale := math.Pow10(i18n.CurrencyDecimals(currency))
+	return math.Round(amountUSD*r*scale) / scale, true
+}
+
+// settlementCurrency returns the currency PayPal charges in.
+func settlementCurrency() string {
+	if c := strings.ToUpper(strings.TrimSpace(getSetting("settlement_currency"))); i18n.IsSupportedCurrency(c) {
+		return c
+	}
+	return "USD"
+}
+
+// buyerCurrency picks the display currency for a visitor: ?currency=, then
+// the currency cookie, then a default for their language. Currencies without
+// a known rate fall back to the settlement currency.
+func buyerCurrency(r *http.Request, lang i18n.Lang) string {
+	candidate := strings.ToUpper(r.URL.Query().Get("currency"))
+	if candidate == "" {
+		if c, err := r.Cookie(currencyCookieName); err == nil {
+			candidate = strings.ToUpper(c.Value)
+		}
+	}
+	if candidate == "" && lang == i18n.ZhCN {
+		candidate = "CNY"
+	}
+	if i18n.IsSupportedCurrency(candidate) {
+		if _, ok := exchangeRates.rate(candidate); ok {
+			return candidate
+		}
+	}
+	return settlementCurrency()
+}
+
+// handleAdminCurrencySettings handles GET/POST /admin/settings/currency.
+func handleAdminCurrencySettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		rates, fetchedAt := exchangeRates.status()
+		updated := ""
+		if !fetchedAt.IsZero() {
+			updated = fetchedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"settlement_currency":   settlementCurrency(),
+			"exchange_rate_api_url": getExchangeRateAPIURL(),
+			"currencies":            i18n.SupportedCurrencies(),
+			"rates":                 rates,
+			"rates_updated_at":      updated,
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(r.FormValue("settlement_currency")))
+	if !i18n.IsSupportedCurrency(currency) {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "不支持的结算币种"})
+		return
+	}
+	apiURL := strings.TrimSpace(r.FormValue("exchange_rate_api_url"))
+	if apiURL != "" && !strings.HasPrefix(apiURL, "https://") && !strings.HasPrefix(apiURL, "http://") {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "汇率接口地址必须以 http:// 或 https:// 开头"})
+		return
+	}
+	for key, value := range map[string]string{
+		"settlement_currency":   currency,
+		"exchange_rate_api_url": apiURL,
+	} {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	if err := refreshExchangeRates(); err != nil {
+		log.Printf("[CURRENCY] %v", err)
+		if currency != "USD" {
+			jsonResponse(w, http.StatusOK, map[string]string{"status": "ok", "warning": "已保存，但汇率获取失败：" + err.Error()})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/mail"
+
+	"marketplace_server/i18n"
+)
+
+// Gift orders. A custom product order with a recipient_email is still owned
+// (and paid) by its buyer, but the credits go to the recipient's email wallet
+// and licenses are bound to the recipient's email. The recipient needs no
+// account: the email wallet is created on the spot and is picked up when
+// someone registers with that address.
+
+// isValidEmailAddress reports whether s is a bare email address.
+func isValidEmailAddress(s string) bool {
+	if len(s) > 254 {
+		return false
+	}
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// orderLicenseEmail returns the email a license of the order is bound to:
+// the gift recipient if there is one, else the buyer's email.
+func orderLicenseEmail(buyerID int64, recipientEmail string) string {
+	if recipientEmail != "" {
+		return recipientEmail
+	}
+	return getEmailForUser(buyerID)
+}
+
+// creditCustomProductOrder adds the credits of a paid credits order to the
+// buyer's wallet, or to the recipient's email wallet for a gift, and records
+// the credits transaction. A top-up tier bonus is added with the credits and
+// recorded as a separate 'bonus' transaction.
+func creditCustomProductOrder(tx *sql.Tx, order CustomProductOrder, product CustomProduct) error {
+	amount := float64(product.CreditsAmount + order.BonusCredits)
+	if order.RecipientEmail == "" {
+		if err := addWalletBalance(tx, order.UserID, amount); err != nil {
+			return err
+		}
+		description := fmt.Sprintf("购买商品「%s」充值 %d 积分", product.ProductName, product.CreditsAmount)
+		if _, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, description, created_at)
+			VALUES (?, 'purchase', ?, ?, CURRENT_TIMESTAMP)`,
+			order.UserID, product.CreditsAmount, description); err != nil {
+			return err
+		}
+		return recordTopupBonus(tx, order.UserID, order.BonusCredits, product.ProductName)
+	}
+
+	if err := addWalletBalanceByEmailTx(tx, order.RecipientEmail, amount); err != nil {
+		return err
+	}
+	// The transaction row needs a user; recipients without an account only
+	// get the wallet balance.
+	var recipientID int64
+	if tx.QueryRow("SELECT id FROM users WHERE email = ? ORDER BY id ASC LIMIT 1", order.RecipientEmail).Scan(&recipientID) != nil {
+		return nil
+	}
+	description := fmt.Sprintf("收到赠送的商品「%s」充值 %d 积分", product.ProductName, product.CreditsAmount)
+	if _, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, description, created_at)
+		VALUES (?, 'purchase', ?, ?, CURRENT_TIMESTAMP)`,
+		recipientID, product.CreditsAmount, description); err != nil {
+		return err
+	}
+	return recordTopupBonus(tx, recipientID, order.BonusCredits, product.ProductName)
+}
+
+// sendGiftNoticeEmail tells the recipient of a fulfilled gift order what they
+// received. Recipients with an account that opted out of email are skipped.
+// Errors are logged only.
+func sendGiftNoticeEmail(orderID, buyerID int64, recipientEmail, buyerEmail, productName, productType string, creditsAmount int, licenseSN string) {
+	lang := userLang(buyerID)
+	var recipientID int64
+	var emailAllowed int
+	if db.QueryRow("SELECT id, COALESCE(email_allowed, 1) FROM users WHERE email = ? ORDER BY id ASC LIMIT 1", recipientEmail).Scan(&recipientID, &emailAllowed) == nil {
+		if emailAllowed == 0 {
+			log.Printf("[ORDER-EMAIL] skipping gift notice for order %d: recipient has opted out", orderID)
+			return
+		}
+		lang = userLang(recipientID)
+	}
+
+	sender := buyerEmail
+	if sender == "" {
+		sender = i18n.T(lang, "site_name")
+	}
+	subject := fmt.Sprintf(i18n.T(lang, "gift_email_subject"), productName)
+	var body string
+	if productType == "credits" {
+		body = fmt.Sprintf(i18n.T(lang, "gift_email_credits_body"), sender, productName, creditsAmount, recipientEmail)
+	} else {
+		body = fmt.Sprintf(i18n.T(lang, "gift_email_license_body"), sender, productName, licenseSN, recipientEmail)
+	}
+	if err := sendSystemEmail(recipientEmail, subject, body); err != nil {
+		log.Printf("[ORDER-EMAIL] failed to send gift notice for order %d to %q: %v", orderID, recipientEmail, err)
+		return
+	}
+	log.Printf("[ORDER-EMAIL] sent gift notice for order %d to %q", orderID, recipientEmail)
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestIsValidEmailAddress(t *testing.T) {
+	for s, want := range map[string]bool{
+		"friend@example.com":          true,
+		"first.last@sub.example.io":   true,
+		"":                            false,
+		"not-an-email":                false,
+		"Friend <friend@example.com>": false,
+		"friend@example.com, x@y.z":   false,
+	} {
+		if got := isValidEmailAddress(s); got != want {
+			t.Errorf("isValidEmailAddress(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestCreditCustomProductOrderGift(t *testing.T) {
+	useTestDB(t)
+	if _, err := db.Exec("INSERT INTO users (id, auth_type, auth_id, display_name, email, credits_balance) VALUES (2, 'email', 'b', 'Buyer', 'buyer@example.com', 5)"); err != nil {
+		t.Fatal(err)
+	}
+	product := CustomProduct{ProductName: "Credits", ProductType: "credits", CreditsAmount: 100}
+
+	credit := func(order CustomProductOrder) {
+		t.Helper()
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if err := creditCustomProductOrder(tx, order, product); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The recipient has no account yet: the credits wait in the email wallet.
+	credit(CustomProductOrder{UserID: 2, RecipientEmail: "friend@example.com"})
+	if got := getWalletBalanceByEmail("friend@example.com"); got != 100 {
+		t.Errorf("recipient wallet = %v, want 100", got)
+	}
+	if got := getWalletBalance(2); got != 5 {
+		t.Errorf("buyer balance = %v, want 5", got)
+	}
+
+	credit(CustomProductOrder{UserID: 2})
+	if got := getWalletBalance(2); got != 105 {
+		t.Errorf("buyer balance after own purchase = %v, want 105", got)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = 2").Scan(&n)
+	if n != 1 {
+		t.Errorf("buyer transactions = %d, want 1", n)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxCustomProducts          = 50
+	maxCustomProductImportSize = 1 << 20 // 1MB
+)
+
+// CustomProductImportRow reports the outcome of one CSV data row.
+type CustomProductImportRow struct {
+	Line        int    `json:"line"` // 1-based line in the CSV file, header included
+	ProductName string `json:"product_name"`
+	Status      string `json:"status"` // "imported", "skipped" or "error"
+	Error       string `json:"error,omitempty"`
+}
+
+// parseCustomProductCSV reads the uploaded CSV. The header row names the
+// columns (product_name, description, product_type, price_usd, credits_amount,
+// license_api_endpoint, license_api_key, license_product_id); product_name,
+// product_type and price_usd are required and unknown columns are ignored.
+// The returned products and rows are parallel: rows that cannot be parsed or
+// fail validateCustomProduct have Status "error", valid rows an empty Status.
+func parseCustomProductCSV(r io.Reader, storefrontID int64) ([]CustomProduct, []CustomProductImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("无法读取 CSV 表头")
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		cols[h] = i
+	}
+	for _, required := range []string{"product_name", "product_type", "price_usd"} {
+		if _, ok := cols[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV 缺少必需的列：%s", required)
+		}
+	}
+
+	var products []CustomProduct
+	var rows []CustomProductImportRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var line int
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			rows = append(rows, CustomProductImportRow{Line: line, Status: "error", Error: "CSV 格式错误"})
+			products = append(products, CustomProduct{})
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue // blank line
+		}
+		line, _ := cr.FieldPos(0)
+
+		p := CustomProduct{
+			StorefrontID:       storefrontID,
+			ProductName:        field("product_name"),
+			Description:        field("description"),
+			ProductType:        field("product_type"),
+			LicenseAPIEndpoint: field("license_api_endpoint"),
+			LicenseAPIKey:      field("license_api_key"),
+			LicenseProductID:   field("license_product_id"),
+		}
+		row := CustomProductImportRow{Line: line, ProductName: p.ProductName}
+
+		price, priceErr := strconv.ParseFloat(field("price_usd"), 64)
+		p.PriceUSD = price
+		var creditsErr error
+		if s := field("credits_amount"); s != "" {
+			p.CreditsAmount, creditsErr = strconv.Atoi(s)
+		}
+
+		switch {
+		case p.ProductType != "credits" && p.ProductType != "virtual_goods":
+			row.Error = "商品类型必须为 credits 或 virtual_goods"
+		case priceErr != nil:
+			row.Error = "价格格式无效"
+		case creditsErr != nil:
+			row.Error = "积分数量格式无效"
+		default:
+			row.Error = validateCustomProduct(p)
+		}
+		if row.Error != "" {
+			row.Status = "error"
+		}
+		products = append(products, p)
+		rows = append(rows, row)
+	}
+	return products, rows, nil
+}
+
+// handleCustomProductImport handles POST /user/storefront/custom-products/import.
+// It accepts a multipart CSV upload ("file") with the columns listed in
+// parseCustomProductCSV and inserts the valid rows as drafts in one
+// transaction. Rows whose product name already exists in the storefront are
+// skipped, and rows beyond the maxCustomProducts cap are reported as errors.
+// With all_or_nothing=1 nothing is imported unless every row is valid.
+// Responds with a per-row report.
+func handleCustomProductImport(w http.ResponseWriter, r *http.Request, userID int64) {
+	var storefrontID int64
+	var customProductsEnabled int
+	var slug string
+	err := db.QueryRow(
+		"SELECT id, COALESCE(custom_products_enabled, 0), store_slug FROM author_storefronts WHERE user_id = ?",
+		userID,
+	).Scan(&storefrontID, &customProductsEnabled, &slug)
+	if err == sql.ErrNoRows {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "您尚未创建小铺"})
+		return
+	}
+	if err != nil {
+		log.Printf("[handleCustomProductImport] query storefront error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "加载数据失败"})
+		return
+	}
+	if customProductsEnabled != 1 {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "您的小铺尚未开启自定义商品功能"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCustomProductImportSize+4096)
+	if err := r.ParseMultipartForm(maxCustomProductImportSize); err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "文件过大或格式无效（最大 1MB）"})
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请选择要导入的 CSV 文件"})
+		return
+	}
+	defer file.Close()
+	allOrNothing, _ := strconv.ParseBool(r.FormValue("all_or_nothing"))
+	if r.FormValue("all_or_nothing") == "on" {
+		allOrNothing = true // checkbox value
+	}
+
+	products, rows, err := parseCustomProductCSV(file, storefrontID)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "CSV 中没有商品数据"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[handleCustomProductImport] begin transaction error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "导入失败"})
+		return
+	}
+	defer tx.Rollback()
+
+	var productCount, maxSortOrder int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*), COALESCE(MAX(sort_order), 0) FROM custom_products WHERE storefront_id = ? AND deleted_at IS NULL",
+		storefrontID,
+	).Scan(&productCount, &maxSortOrder); err != nil {
+		log.Printf("[handleCustomProductImport] count products error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "导入失败"})
+		return
+	}
+
+	imported, skipped, failed := 0, 0, 0
+	// Products the content filter flagged, recorded once the import is committed
+	type flaggedProduct struct {
+		ID           int64
+		Reason, Name string
+	}
+	var flagged []flaggedProduct
+	for i := range rows {
+		if rows[i].Status == "error" {
+			failed++
+			continue
+		}
+		if productCount+imported >= maxCustomProducts {
+			rows[i].Status = "error"
+			rows[i].Error = fmt.Sprintf("自定义商品数量已达上限（%d 个）", maxCustomProducts)
+			failed++
+			continue
+		}
+		p := products[i]
+		result, err := tx.Exec(
+			`INSERT INTO custom_products (storefront_id, product_name, description, product_type, price_usd,
+				credits_amount, license_api_endpoint, license_api_key, license_product_id,
+				status, sort_order, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'draft', ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+			p.StorefrontID, p.ProductName, p.Description, p.ProductType, p.PriceUSD,
+			p.CreditsAmount, p.LicenseAPIEndpoint, p.LicenseAPIKey, p.LicenseProductID,
+			maxSortOrder+imported+1,
+		)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				rows[i].Status = "skipped"
+				rows[i].Error = "该商品名称已存在"
+				skipped++
+				continue
+			}
+			log.Printf("[handleCustomProductImport] insert product error (line %d): %v", rows[i].Line, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "导入失败"})
+			return
+		}
+		rows[i].Status = "imported"
+		imported++
+		if _, flag := checkUserContent(p.ProductName, p.Description); flag != "" {
+			if id, err := result.LastInsertId(); err == nil {
+				flagged = append(flagged, flaggedProduct{id, flag, p.ProductName})
+			}
+		}
+	}
+
+	if allOrNothing && failed > 0 {
+		for i := range rows {
+			if rows[i].Status == "imported" {
+				rows[i].Status = "skipped"
+				rows[i].Error = "存在错误行，未导入任何商品"
+			}
+		}
+		jsonResponse(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":    fmt.Sprintf("%d 行存在错误，未导入任何商品", failed),
+			"imported": 0,
+			"skipped":  skipped + imported,
+			"failed":   failed,
+			"rows":     rows,
+		})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[handleCustomProductImport] commit error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "导入失败"})
+		return
+	}
+	for _, f := range flagged {
+		flagUserContent("custom_product", f.ID, f.Reason, f.Name)
+	}
+	if imported > 0 {
+		globalCache.InvalidateStorefront(slug)
+	}
+
+	log.Printf("[handleCustomProductImport] storefront %d imported %d, skipped %d, failed %d", storefrontID, imported, skipped, failed)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"imported": imported,
+		"skipped":  skipped,
+		"failed":   failed,
+		"rows":     rows,
+	})
+}
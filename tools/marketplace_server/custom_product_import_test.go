@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCustomProductImport(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, custom_products_enabled) VALUES (10, 1, 'Mine', 'mine', 1)")
+	mustExec(t, "INSERT INTO custom_products (storefront_id, product_name, product_type, price_usd, credits_amount) VALUES (10, 'Existing', 'credits', 5, 100)")
+
+	upload := func(csv string, allOrNothing bool) (int, map[string]interface{}) {
+		t.Helper()
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, _ := mw.CreateFormFile("file", "products.csv")
+		fw.Write([]byte(csv))
+		if allOrNothing {
+			mw.WriteField("all_or_nothing", "1")
+		}
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/user/storefront/custom-products/import", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		handleCustomProductCRUD(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	count := func() int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM custom_products WHERE storefront_id = 10").Scan(&n)
+		return n
+	}
+
+	if code, _ := upload("name,price\nA,1\n", false); code != http.StatusBadRequest {
+		t.Errorf("missing columns: status %d, want 400", code)
+	}
+
+	csv := "\ufeffproduct_name,product_type,price_usd,credits_amount,license_api_endpoint\n" +
+		"Credits pack,credits,9.99,500,\n" +
+		"Existing,credits,5,100,\n" +
+		"License,virtual_goods,19,,https://license.example.com/bind\n" +
+		"Bad price,credits,abc,10,\n"
+
+	code, resp := upload(csv, true)
+	if code != http.StatusUnprocessableEntity || resp["imported"] != 0.0 || count() != 1 {
+		t.Fatalf("all-or-nothing: status %d, resp %v, count %d", code, resp, count())
+	}
+
+	code, resp = upload(csv, false)
+	if code != http.StatusOK || resp["imported"] != 2.0 || resp["skipped"] != 1.0 || resp["failed"] != 1.0 {
+		t.Fatalf("import: status %d, resp %v", code, resp)
+	}
+	rows := resp["rows"].([]interface{})
+	if row := rows[3].(map[string]interface{}); row["line"] != 5.0 || row["status"] != "error" {
+		t.Errorf("bad price row = %v", row)
+	}
+	if count() != 3 {
+		t.Errorf("product count = %d, want 3", count())
+	}
+
+	// Rows beyond the product cap are reported, not inserted.
+	for i := count(); i < maxCustomProducts-1; i++ {
+		mustExec(t, "INSERT INTO custom_products (storefront_id, product_name, product_type, price_usd, credits_amount) VALUES (10, ?, 'credits', 1, 1)", fmt.Sprintf("Filler %d", i))
+	}
+	code, resp = upload("product_name,product_type,price_usd,credits_amount\nLast one,credits,1,1\nOver cap,credits,1,1\n", false)
+	if code != http.StatusOK || resp["imported"] != 1.0 || resp["failed"] != 1.0 || count() != maxCustomProducts {
+		t.Errorf("cap: status %d, resp %v, count %d", code, resp, count())
+	}
+}
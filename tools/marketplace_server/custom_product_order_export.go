@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// customProductOrderExportHeaders are the column titles of the order export.
+// The export is built from CustomProductOrder only, so product secrets such as
+// license_api_key can never end up in the file.
+var customProductOrderExportHeaders = []string{
+	"订单号", "商品名称", "商品类型", "买家邮箱", "授权邮箱", "金额 (USD)", "实付金额", "币种",
+	"订单状态", "授权 SN", "PayPal 订单号", "创建时间", "更新时间",
+}
+
+var customProductOrderStatusLabels = map[string]string{
+	"pending": "待支付", "paid": "已支付", "fulfilled": "已履约", "failed": "失败",
+}
+
+var customProductTypeLabels = map[string]string{
+	"credits": "积分充值", "virtual_goods": "虚拟商品",
+}
+
+// customProductOrderExportRow flattens an order into export cells, in the
+// order of customProductOrderExportHeaders.
+func customProductOrderExportRow(o CustomProductOrder) []interface{} {
+	status := customProductOrderStatusLabels[o.Status]
+	if status == "" {
+		status = o.Status
+	}
+	productType := customProductTypeLabels[o.ProductType]
+	if productType == "" {
+		productType = o.ProductType
+	}
+	return []interface{}{
+		o.ID, o.ProductName, productType, o.BuyerEmail, o.LicenseEmail, o.AmountUSD, o.ChargedAmount, o.ChargedCurrency,
+		status, o.LicenseSN, o.PayPalOrderID, exportTimestamp(o.CreatedAt), exportTimestamp(o.UpdatedAt),
+	}
+}
+
+// exportTimestamp renders a DATETIME column, which the driver may return in
+// RFC 3339 form, as "2006-01-02 15:04:05" for spreadsheets.
+func exportTimestamp(s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+	return s
+}
+
+// handleStorefrontCustomProductOrdersExport handles
+// GET /user/storefront/custom-product-orders/export?format=csv|xlsx.
+// It exports the caller's custom product orders with the same product_name,
+// status and date_from/date_to filters as the orders page.
+func handleStorefrontCustomProductOrdersExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "不支持的导出格式", http.StatusBadRequest)
+		return
+	}
+
+	var storefrontID int64
+	err = db.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "您尚未创建小铺", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("[handleStorefrontCustomProductOrdersExport] query storefront error: %v", err)
+		http.Error(w, "加载数据失败", http.StatusInternalServerError)
+		return
+	}
+
+	orders, err := queryStorefrontCustomProductOrders(storefrontID, parseCustomProductOrderFilter(r))
+	if err != nil {
+		log.Printf("[handleStorefrontCustomProductOrdersExport] query orders error: %v", err)
+		http.Error(w, "加载数据失败", http.StatusInternalServerError)
+		return
+	}
+
+	filename := "custom_product_orders_" + time.Now().Format("20060102")
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		// UTF-8 BOM so Excel opens the Chinese headers correctly.
+		w.Write([]byte("\ufeff"))
+		cw := csv.NewWriter(w)
+		cw.Write(customProductOrderExportHeaders)
+		for _, o := range orders {
+			cells := customProductOrderExportRow(o)
+			record := make([]string, len(cells))
+			for i, c := range cells {
+				record[i] = fmt.Sprint(c)
+			}
+			cw.Write(record)
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			log.Printf("[handleStorefrontCustomProductOrdersExport] write CSV error: %v", err)
+		}
+		return
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	sheetName := "订单"
+	f.SetSheetName("Sheet1", sheetName)
+	for i, h := range customProductOrderExportHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, h)
+	}
+	for rowIdx, o := range orders {
+		for i, val := range customProductOrderExportRow(o) {
+			cell, _ := excelize.CoordinatesToCellName(i+1, rowIdx+2)
+			f.SetCellValue(sheetName, cell, val)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		log.Printf("[handleStorefrontCustomProductOrdersExport] write Excel error: %v", err)
+		http.Error(w, "导出失败", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xlsx"`, filename))
+	w.Write(buf.Bytes())
+}
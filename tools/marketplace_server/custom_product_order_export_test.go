@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestCustomProductOrdersExport(t *testing.T) {
+	useTestDB(t)
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Author', 'author@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'email', 'b', 'Buyer', 'buyer@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Mine', 'mine')")
+	mustExec(t, `INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, license_api_endpoint, license_api_key)
+		VALUES (1, 10, 'Pro license', 'virtual_goods', 19, 'https://license.example.com', 'super-secret-key')`)
+	mustExec(t, `INSERT INTO custom_product_orders (custom_product_id, user_id, amount_usd, license_sn, status, created_at)
+		VALUES (1, 2, 19, 'SN-001', 'fulfilled', '2026-03-05 10:00:00')`)
+	mustExec(t, `INSERT INTO custom_product_orders (custom_product_id, user_id, amount_usd, status, created_at)
+		VALUES (1, 2, 19, 'pending', '2026-04-01 10:00:00')`)
+
+	export := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/user/storefront/custom-product-orders/export?"+query, nil)
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		handleStorefrontCustomProductOrdersExport(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	rec := export("format=csv&date_from=2026-03-01&date_to=2026-03-31")
+	body := rec.Body.String()
+	if strings.Contains(body, "super-secret-key") {
+		t.Fatal("export leaks license_api_key")
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(body, "\ufeff"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("CSV rows = %d, want header + 1: %v", len(records), records)
+	}
+	if got := records[1]; got[3] != "buyer@example.com" || got[9] != "SN-001" || got[11] != "2026-03-05 10:00:00" {
+		t.Errorf("CSV row = %v", got)
+	}
+
+	if body := export("format=csv&status=pending").Body.String(); strings.Count(body, "\n") != 2 {
+		t.Errorf("status filter: %q", body)
+	}
+
+	rec = export("format=xlsx")
+	f, err := excelize.OpenReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, _ := f.GetRows("订单")
+	if len(rows) != 3 || rows[1][1] != "Pro license" {
+		t.Errorf("Excel rows = %v", rows)
+	}
+}
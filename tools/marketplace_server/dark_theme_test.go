@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+
+	"marketplace_server/templates"
+)
+
+func TestDarkThemeCSS(t *testing.T) {
+	if !ValidThemes["dark"] {
+		t.Fatal("dark is not a valid theme")
+	}
+	dark := GetThemeCSS("dark", "")
+	for _, v := range []string{"--page-bg:", "--surface-bg:", "--text-color:", "--card-border:", "--hero-gradient:"} {
+		if !strings.Contains(dark, v) {
+			t.Errorf("dark theme CSS lacks %s: %s", v, dark)
+		}
+	}
+	// Light themes keep emitting only the original five variables.
+	if css := GetThemeCSS("ocean", ""); strings.Contains(css, "--page-bg") || strings.Count(css, "--") != 5 {
+		t.Errorf("ocean theme CSS changed: %s", css)
+	}
+
+	if GetThemeDarkCSS("ocean", "light") != "" || GetThemeDarkCSS("dark", "auto") != "" {
+		t.Error("dark media-query CSS emitted when it is not needed")
+	}
+	if GetThemeDarkCSS("ocean", "auto") != dark {
+		t.Error("auto color scheme does not fall back to the dark palette")
+	}
+}
+
+func TestStorefrontRendersPrefersColorScheme(t *testing.T) {
+	data := StorefrontPageData{
+		Storefront:   StorefrontInfo{ID: 1, StoreName: "Shop", PublicID: "abc"},
+		ThemeCSS:     template.CSS(GetThemeCSS("ocean", "")),
+		ThemeDarkCSS: template.CSS(GetThemeDarkCSS("ocean", "auto")),
+	}
+	var buf bytes.Buffer
+	if err := templates.StorefrontTmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	page := buf.String()
+	if !strings.Contains(page, ":root { --primary-color: #0891b2") {
+		t.Error("theme variables missing or escaped")
+	}
+	if !strings.Contains(page, "@media (prefers-color-scheme: dark) { :root { --primary-color: #818cf8") {
+		t.Error("dark media query missing or escaped")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Online database backup. VACUUM INTO writes a consistent snapshot of the
+// live database to a new file from inside one read transaction, so readers
+// and writers carry on while it runs (in WAL mode writers are not blocked at
+// all). The snapshot is streamed to the admin and deleted. Backups need the
+// "backup" permission, run one at a time, and are limited to one per
+// dbBackupInterval across all admins; each is recorded in the audit log,
+// which is also where the interval is checked so it survives restarts.
+const dbBackupInterval = 10 * time.Minute
+
+// dbBackupMu serialises backups; a second request while one runs is refused
+// rather than queued.
+var dbBackupMu sync.Mutex
+
+// lastDBBackup returns when the last backup was taken, or the zero time.
+func lastDBBackup() time.Time {
+	var last string
+	if err := db.QueryRow("SELECT COALESCE(MAX(created_at), '') FROM admin_audit_log WHERE action = 'db_backup'").Scan(&last); err != nil || last == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, last); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// snapshotDatabase writes a consistent copy of the database to path, which
+// must not exist.
+func snapshotDatabase(path string) error {
+	_, err := db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// handleAdminDBBackup handles GET /api/admin/db-backup and responds with a
+// snapshot of the database as a file download.
+func handleAdminDBBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if !dbBackupMu.TryLock() {
+		jsonResponse(w, http.StatusTooManyRequests, map[string]string{"error": "备份正在进行中，请稍后再试"})
+		return
+	}
+	defer dbBackupMu.Unlock()
+	if wait := time.Until(lastDBBackup().Add(dbBackupInterval)); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)+1))
+		jsonResponse(w, http.StatusTooManyRequests, map[string]string{
+			"error": fmt.Sprintf("备份过于频繁，请 %d 分钟后再试", int(wait/time.Minute)+1),
+		})
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "marketplace-backup-")
+	if err != nil {
+		log.Printf("[DB-BACKUP] failed to create temp dir: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "备份失败"})
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "marketplace.db")
+	start := time.Now()
+	if err := snapshotDatabase(path); err != nil {
+		log.Printf("[DB-BACKUP] VACUUM INTO failed: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "备份失败"})
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("[DB-BACKUP] failed to open snapshot: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "备份失败"})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Printf("[DB-BACKUP] failed to stat snapshot: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "备份失败"})
+		return
+	}
+
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	recordAuditLog(adminID, "db_backup", "database", fmt.Sprintf("size=%d duration=%s", info.Size(), time.Since(start).Round(time.Millisecond)), getClientIP(r))
+	log.Printf("[DB-BACKUP] admin %d took a %d-byte backup in %s", adminID, info.Size(), time.Since(start).Round(time.Millisecond))
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="marketplace_%s.db"`, time.Now().Format("20060102_150405")))
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("[DB-BACKUP] failed to stream backup: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdminDBBackup(t *testing.T) {
+	useTestDB(t)
+	if _, err := db.Exec("INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'alice', 'Alice')"); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/db-backup", nil)
+		req.Header.Set("X-Admin-ID", "3")
+		rec := httptest.NewRecorder()
+		handleAdminDBBackup(rec, req)
+		return rec
+	}
+	rec := backup()
+	if rec.Code != http.StatusOK || !bytes.HasPrefix(rec.Body.Bytes(), []byte("SQLite format 3\x00")) {
+		t.Fatalf("backup: status %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+
+	// The snapshot is a complete, openable database.
+	path := filepath.Join(t.TempDir(), "restored.db")
+	if err := os.WriteFile(path, rec.Body.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	var name string
+	if err := restored.QueryRow("SELECT display_name FROM users WHERE id = 1").Scan(&name); err != nil || name != "Alice" {
+		t.Fatalf("restored user: %q, %v", name, err)
+	}
+
+	var audited int
+	db.QueryRow("SELECT COUNT(*) FROM admin_audit_log WHERE action = 'db_backup' AND admin_id = 3").Scan(&audited)
+	if audited != 1 {
+		t.Errorf("audit entries = %d, want 1", audited)
+	}
+	if rec := backup(); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("second backup: status %d, want 429 with Retry-After", rec.Code)
+	}
+}
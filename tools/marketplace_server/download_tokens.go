@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Single-use download tokens. A signed-in user who is entitled to a pack can
+// ask for a token and hand the resulting link to a browser or download
+// manager; the link works once, for downloadTokenTTL, and needs no session.
+// Only the token's hash is stored in downloads_tokens. Tokens never charge
+// credits: they are issued only for free packs and packs the user already
+// owns, the same cases in which /api/packs/{id}/download serves the file
+// without a purchase. A per_use download spends one use of the quota, and a
+// subscription pack needs an active subscription. Rotating a pack's
+// encryption password revokes its unused tokens.
+const (
+	downloadTokenTTL       = 10 * time.Minute
+	downloadTokenSweepRate = 30 * time.Minute
+)
+
+// packDownloadEntitled reports whether userID may download listingID without
+// paying: the pack is free and published, or the user bought it and has uses
+// left (per_use), an active subscription (subscription), or access that has
+// not ended (time-limited packs).
+func packDownloadEntitled(userID, listingID int64, now time.Time) (bool, error) {
+	var shareMode, status string
+	var validDays int
+	err := db.QueryRow("SELECT share_mode, status, COALESCE(valid_days, 0) FROM pack_listings WHERE id = ?", listingID).
+		Scan(&shareMode, &status, &validDays)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if shareMode == "free" && status == "published" {
+		return true, nil
+	}
+	var purchased int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_purchased_packs WHERE user_id = ? AND listing_id = ? AND (is_hidden IS NULL OR is_hidden = 0)`,
+		userID, listingID).Scan(&purchased); err != nil {
+		return false, err
+	}
+	if purchased == 0 {
+		return false, nil
+	}
+	switch {
+	case shareMode == "per_use":
+		var remaining int
+		err := db.QueryRow("SELECT COUNT(*) FROM pack_usage_records WHERE user_id = ? AND listing_id = ? AND used_count < total_purchased",
+			userID, listingID).Scan(&remaining)
+		return remaining > 0, err
+	case shareMode == "subscription":
+		return packSubscriptionActive(userID, listingID, now.UTC()), nil
+	case isTimeLimitedPack(shareMode, validDays):
+		if expiry, ok := timeLimitedExpiry(userID, listingID, validDays); ok && timeLimitedAccessEnded(expiry, now.UTC()) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// packIDFromPath returns the {id} of /api/packs/{id}/<suffix>.
+func packIDFromPath(path, suffix string) (int64, error) {
+	return strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(path, "/api/packs/"), suffix), 10, 64)
+}
+
+// handleIssueDownloadToken handles POST /api/packs/{id}/download-token.
+func handleIssueDownloadToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	listingID, err := packIDFromPath(r.URL.Path, "/download-token")
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+		return
+	}
+	now := time.Now()
+	entitled, err := packDownloadEntitled(userID, listingID, now)
+	if err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] entitlement check failed (user=%d, listing=%d): %v", userID, listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if !entitled {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "not_entitled"})
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to generate token: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	token := hex.EncodeToString(b)
+	expiresAt := now.Add(downloadTokenTTL).UTC()
+	if _, err := db.Exec("INSERT INTO downloads_tokens (token_hash, user_id, listing_id, expires_at) VALUES (?, ?, ?, ?)",
+		hashEmailToken(token), userID, listingID, expiresAt.Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to store token: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"token":        token,
+		"download_url": "/api/packs/" + strconv.FormatInt(listingID, 10) + "/download?token=" + token,
+		"expires_at":   expiresAt.Format(time.RFC3339),
+	})
+}
+
+// handleTokenDownload handles GET /api/packs/{id}/download?token=..., the
+// session-less download of a token issued by handleIssueDownloadToken. The
+// token is consumed only once the file is ready to stream, so a storage
+// error does not burn it; two concurrent requests with the same token cannot
+// both get past the UPDATE.
+func handleTokenDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	listingID, err := packIDFromPath(r.URL.Path, "/download")
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+		return
+	}
+	tokenHash := hashEmailToken(r.URL.Query().Get("token"))
+	now := time.Now().UTC()
+
+	var userID int64
+	var used, expired bool
+	err = db.QueryRow("SELECT user_id, used_at IS NOT NULL, expires_at <= ? FROM downloads_tokens WHERE token_hash = ? AND listing_id = ?",
+		now.Format("2006-01-02 15:04:05"), tokenHash, listingID).Scan(&userID, &used, &expired)
+	if err == sql.ErrNoRows {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "invalid_token"})
+		return
+	}
+	if err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to look up token: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if used {
+		jsonResponse(w, http.StatusGone, map[string]string{"error": "token_used"})
+		return
+	}
+	if expired {
+		jsonResponse(w, http.StatusGone, map[string]string{"error": "token_expired"})
+		return
+	}
+	entitled, err := packDownloadEntitled(userID, listingID, now)
+	if err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] entitlement check failed (user=%d, listing=%d): %v", userID, listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if !entitled {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "not_entitled"})
+		return
+	}
+
+	var fileRef packFileRef
+	var shareMode, packName, encryptionPassword, fileSHA256 string
+	var metaInfoStr sql.NullString
+	if err := db.QueryRow(`SELECT share_mode, file_data, COALESCE(file_storage, ''), COALESCE(file_key, ''), pack_name, meta_info,
+			COALESCE(encryption_password, ''), COALESCE(file_sha256, '') FROM pack_listings WHERE id = ?`, listingID).
+		Scan(&shareMode, &fileRef.Data, &fileRef.Storage, &fileRef.Key, &packName, &metaInfoStr, &encryptionPassword, &fileSHA256); err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to load listing %d: %v", listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	packFile, packFileSize, err := openPackFile(fileRef)
+	if err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to open pack file for listing %d: %v", listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer packFile.Close()
+	watermarkData, err := readPackForWatermark(listingID, packFile, packFileSize)
+	if err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+
+	// The token and, for per_use packs, one use are spent together, so a
+	// download refused for an exhausted quota leaves the token unused.
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to begin transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec("UPDATE downloads_tokens SET used_at = ?, used_ip = ? WHERE token_hash = ? AND used_at IS NULL",
+		now.Format("2006-01-02 15:04:05"), getClientIP(r), tokenHash)
+	if err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to consume token: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusGone, map[string]string{"error": "token_used"})
+		return
+	}
+	if shareMode == "per_use" {
+		if _, err := consumePackUse(tx, userID, listingID, now.Format(time.RFC3339Nano)); errors.Is(err, errUsageQuotaExhausted) {
+			jsonResponse(w, http.StatusPaymentRequired, map[string]string{"error": "USAGE_QUOTA_EXHAUSTED"})
+			return
+		} else if err != nil {
+			log.Printf("[DOWNLOAD-TOKEN] failed to consume pack use (user=%d, listing=%d): %v", userID, listingID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	switch shareMode {
+	case "free", "per_use", "subscription":
+		if _, err := tx.Exec("UPDATE pack_listings SET download_count = download_count + 1 WHERE id = ?", listingID); err != nil {
+			log.Printf("[DOWNLOAD-TOKEN] failed to increment download count: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to commit: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	_, _ = db.Exec("INSERT INTO user_downloads (user_id, listing_id, ip_address) VALUES (?, ?, ?)", userID, listingID, getClientIP(r))
+	file, size, sha := watermarkPackDownload(userID, listingID, packFile, watermarkData, packFileSize, fileSHA256)
+	servePackFile(w, packName, file, size, metaInfoStr, encryptionPassword, sha)
+}
+
+// startDownloadTokenSweeper periodically deletes expired download tokens.
+func startDownloadTokenSweeper() {
+	go func() {
+		ticker := time.NewTicker(downloadTokenSweepRate)
+		defer ticker.Stop()
+		for {
+			purgeDownloadTokens(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// purgeDownloadTokens deletes tokens that expired before now, used or not,
+// and returns how many were deleted.
+func purgeDownloadTokens(now time.Time) int64 {
+	res, err := db.Exec("DELETE FROM downloads_tokens WHERE expires_at <= ?", now.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		log.Printf("[DOWNLOAD-TOKEN] failed to purge expired tokens: %v", err)
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return n
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func issueDownloadToken(t *testing.T, userID string, listing string) (int, map[string]string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/packs/"+listing+"/download-token", nil)
+	req.Header.Set("X-User-ID", userID)
+	rec := httptest.NewRecorder()
+	handleIssueDownloadToken(rec, req)
+	var body map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec.Code, body
+}
+
+func downloadWithToken(url string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handleTokenDownload(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	return rec
+}
+
+func TestDownloadTokenSingleUse(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'author', 'Author'), (2, 'sn', 'buyer', 'Buyer'), (3, 'sn', 'other', 'Other')")
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status, encryption_password)
+		VALUES (100, 1, 1, ?, 'Paid', 'per_use', 10, 'published', 'pw')`, []byte("qap bytes"))
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (2, 100)")
+	mustExec(t, "INSERT INTO pack_usage_records (user_id, listing_id, used_count, total_purchased) VALUES (2, 100, 0, 5)")
+
+	if code, _ := issueDownloadToken(t, "3", "100"); code != http.StatusForbidden {
+		t.Fatalf("non-buyer got status %d, want 403", code)
+	}
+	code, body := issueDownloadToken(t, "2", "100")
+	if code != http.StatusOK || body["token"] == "" {
+		t.Fatalf("issue: %d %v", code, body)
+	}
+	var stored int
+	db.QueryRow("SELECT COUNT(*) FROM downloads_tokens WHERE token_hash = ?", body["token"]).Scan(&stored)
+	if stored != 0 {
+		t.Fatal("raw token stored in the database")
+	}
+
+	if rec := downloadWithToken("/api/packs/101/download?token=" + body["token"]); rec.Code != http.StatusNotFound {
+		t.Errorf("token for another pack: status %d, want 404", rec.Code)
+	}
+	rec := downloadWithToken(body["download_url"])
+	if rec.Code != http.StatusOK || rec.Body.String() != "qap bytes" || rec.Header().Get("X-Encryption-Password") != "pw" {
+		t.Fatalf("first download: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := downloadWithToken(body["download_url"]); rec.Code != http.StatusGone {
+		t.Errorf("reuse: status %d, want 410", rec.Code)
+	}
+
+	// Expired tokens are refused and then purged.
+	_, body = issueDownloadToken(t, "2", "100")
+	mustExec(t, "UPDATE downloads_tokens SET expires_at = '2000-01-01 00:00:00' WHERE used_at IS NULL")
+	if rec := downloadWithToken(body["download_url"]); rec.Code != http.StatusGone {
+		t.Errorf("expired: status %d, want 410", rec.Code)
+	}
+	if n := purgeDownloadTokens(time.Now()); n != 1 {
+		t.Errorf("purged %d tokens, want 1", n)
+	}
+
+	// Removing the pack from the library revokes the entitlement of an issued token.
+	_, body = issueDownloadToken(t, "2", "100")
+	mustExec(t, "UPDATE user_purchased_packs SET is_hidden = 1 WHERE user_id = 2")
+	if rec := downloadWithToken(body["download_url"]); rec.Code != http.StatusForbidden {
+		t.Errorf("lost entitlement: status %d, want 403", rec.Code)
+	}
+}
+
+func TestDownloadTokenPerUseQuota(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'author', 'Author'), (2, 'sn', 'buyer', 'Buyer')")
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status)
+		VALUES (100, 1, 1, x'00', 'Paid', 'per_use', 10, 'published')`)
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (2, 100)")
+	mustExec(t, "INSERT INTO pack_usage_records (user_id, listing_id, used_count, total_purchased) VALUES (2, 100, 0, 1)")
+	usedCount := func() int {
+		var n int
+		db.QueryRow("SELECT used_count FROM pack_usage_records WHERE user_id = 2 AND listing_id = 100").Scan(&n)
+		return n
+	}
+
+	// Two tokens issued while one use is left: the first spends it.
+	_, first := issueDownloadToken(t, "2", "100")
+	_, second := issueDownloadToken(t, "2", "100")
+	if rec := downloadWithToken(first["download_url"]); rec.Code != http.StatusOK {
+		t.Fatalf("first download: status %d", rec.Code)
+	}
+	if n := usedCount(); n != 1 {
+		t.Fatalf("used_count = %d after token download, want 1", n)
+	}
+
+	// The second is refused without being burned, and no new token is issued.
+	if rec := downloadWithToken(second["download_url"]); rec.Code != http.StatusForbidden {
+		t.Errorf("over quota: status %d, want 403", rec.Code)
+	}
+	if code, _ := issueDownloadToken(t, "2", "100"); code != http.StatusForbidden {
+		t.Errorf("issue over quota: status %d, want 403", code)
+	}
+	mustExec(t, "UPDATE pack_usage_records SET total_purchased = 2 WHERE user_id = 2")
+	if rec := downloadWithToken(second["download_url"]); rec.Code != http.StatusOK {
+		t.Errorf("after top-up: status %d, want 200", rec.Code)
+	}
+	if n := usedCount(); n != 2 {
+		t.Errorf("used_count = %d, want 2", n)
+	}
+}
+
+func TestDownloadTokenSubscriptionExpiry(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'author', 'Author'), (2, 'sn', 'buyer', 'Buyer')")
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status, valid_days)
+		VALUES (100, 1, 1, x'00', 'Monthly', 'subscription', 10, 'published', 30)`)
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (2, 100)")
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, created_at) VALUES (2, 'purchase', -10, 100, ?)",
+		time.Now().UTC().AddDate(0, 0, -40).Format("2006-01-02 15:04:05"))
+
+	if code, _ := issueDownloadToken(t, "2", "100"); code != http.StatusForbidden {
+		t.Fatalf("expired subscription: status %d, want 403", code)
+	}
+
+	// A PayPal auto-renewal within its grace period keeps access.
+	mustExec(t, "INSERT INTO pack_subscriptions (user_id, listing_id, billing_cycle, paypal_subscription_id, status, grace_until) VALUES (2, 100, 'monthly', 'I-1', 'active', ?)",
+		time.Now().UTC().Add(48*time.Hour).Format("2006-01-02 15:04:05"))
+	code, body := issueDownloadToken(t, "2", "100")
+	if code != http.StatusOK {
+		t.Fatalf("PayPal subscription: status %d, want 200", code)
+	}
+	if rec := downloadWithToken(body["download_url"]); rec.Code != http.StatusOK {
+		t.Errorf("PayPal subscription download: status %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"marketplace_server/i18n"
+	"marketplace_server/templates"
+)
+
+// emailChangeCooldown returns how long userID must wait before requesting
+// another email change link.
+func emailChangeCooldown(userID int64, now time.Time) time.Duration {
+	var last string
+	db.QueryRow("SELECT COALESCE(MAX(created_at), '') FROM email_change_requests WHERE user_id = ?", userID).Scan(&last)
+	t, err := time.Parse("2006-01-02 15:04:05", last)
+	if err != nil {
+		return 0
+	}
+	if remaining := emailVerifyCooldown - now.Sub(t); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// moveEmailWallet moves the wallet of oldEmail to newEmail within tx. If
+// newEmail already has a wallet (credits sent to it before it had an
+// account, or a linked email becoming the sign-in email) the balances are
+// summed; the password and username of the old wallet are kept unless the
+// new wallet has its own. The new wallet counts as verified.
+func moveEmailWallet(tx *sql.Tx, oldEmail, newEmail string) error {
+	for _, email := range []string{oldEmail, newEmail} {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO email_wallets (email, credits_balance, updated_at)
+			SELECT ?, COALESCE(SUM(credits_balance), 0), CURRENT_TIMESTAMP FROM users WHERE email = ?`, email, email); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE email_wallets SET
+			credits_balance = credits_balance + (SELECT COALESCE(credits_balance, 0) FROM email_wallets WHERE email = ?),
+			password_hash = COALESCE(NULLIF(password_hash, ''), (SELECT password_hash FROM email_wallets WHERE email = ?)),
+			username = COALESCE(NULLIF(username, ''), (SELECT username FROM email_wallets WHERE email = ?)),
+			email_verified = 1, email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE email = ?`, oldEmail, oldEmail, oldEmail, newEmail); err != nil {
+		return fmt.Errorf("merge wallet: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM email_wallets WHERE email = ?", oldEmail); err != nil {
+		return fmt.Errorf("remove old wallet: %w", err)
+	}
+	return nil
+}
+
+// changeAccountEmail moves every account on oldEmail to newEmail, together
+// with the wallet. Accounts sharing an email share the wallet and password,
+// so they move as one. credits_transactions are keyed by user and need no
+// change.
+func changeAccountEmail(userID int64, oldEmail, newEmail string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := moveEmailWallet(tx, oldEmail, newEmail); err != nil {
+		return err
+	}
+	for _, q := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"UPDATE users SET email = ? WHERE email = ?", []interface{}{newEmail, oldEmail}},
+		// The new address may have been linked to this account; it is now the sign-in email.
+		{"DELETE FROM account_emails WHERE user_id = ? AND email = ?", []interface{}{userID, newEmail}},
+		{"DELETE FROM password_reset_tokens WHERE email = ?", []interface{}{oldEmail}},
+		{"DELETE FROM email_verification_tokens WHERE email = ?", []interface{}{oldEmail}},
+	} {
+		if _, err := tx.Exec(q.query, q.args...); err != nil {
+			return fmt.Errorf("%s: %w", q.query, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// handleUserChangeEmail handles GET/POST /user/change-email. POST checks the
+// password and sends a confirmation link to the new address; nothing changes
+// until that link is followed.
+func handleUserChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	lang := i18n.DetectLang(r)
+
+	email := getEmailForUser(userID)
+	var walletPwHash sql.NullString
+	db.QueryRow("SELECT password_hash FROM email_wallets WHERE email = ?", email).Scan(&walletPwHash)
+	if email == "" || !walletPwHash.Valid || walletPwHash.String == "" {
+		http.Redirect(w, r, "/user/set-password", http.StatusFound)
+		return
+	}
+
+	renderForm := func(errMsg, successMsg string) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := i18n.TemplateData(r)
+		i18n.MergeTemplateData(data, map[string]interface{}{
+			"Email":   email,
+			"Error":   errMsg,
+			"Success": successMsg,
+		})
+		if err := templates.UserChangeEmailTmpl.Execute(w, data); err != nil {
+			log.Printf("[CHANGE-EMAIL] template execute error: %v", err)
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		success := ""
+		if r.URL.Query().Get("success") == "changed" {
+			success = i18n.T(lang, "email_change_done")
+		}
+		renderForm("", success)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	newEmail := strings.ToLower(strings.TrimSpace(r.FormValue("new_email")))
+	if !checkPassword(r.FormValue("password"), walletPwHash.String) {
+		renderForm(i18n.T(lang, "invalid_old_password"), "")
+		return
+	}
+	if !isValidEmailAddress(newEmail) {
+		renderForm(i18n.T(lang, "invalid_email"), "")
+		return
+	}
+	if strings.EqualFold(newEmail, email) {
+		renderForm(i18n.T(lang, "email_change_same"), "")
+		return
+	}
+	if emailTakenByOtherAccount(userID, newEmail) {
+		renderForm(i18n.T(lang, "email_link_taken"), "")
+		return
+	}
+	now := time.Now().UTC()
+	if remaining := emailChangeCooldown(userID, now); remaining > 0 {
+		renderForm(fmt.Sprintf(i18n.T(lang, "email_verify_cooldown"), int(remaining.Seconds())+1), "")
+		return
+	}
+
+	baseURL, ok := siteBaseURL()
+	if !ok {
+		renderForm(i18n.T(lang, "email_verify_send_failed"), "")
+		return
+	}
+	token := generateSessionID()
+	if _, err := db.Exec(`INSERT INTO email_change_requests (user_id, old_email, new_email, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, userID, email, newEmail, hashEmailToken(token),
+		now.Add(emailVerifyTokenTTL).Format("2006-01-02 15:04:05"), now.Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("[CHANGE-EMAIL] failed to store request for user %d: %v", userID, err)
+		renderForm(i18n.T(lang, "system_error"), "")
+		return
+	}
+	link := baseURL + "/user/change-email/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(i18n.T(lang, "email_change_body"), link, int(emailVerifyTokenTTL.Hours()))
+	if err := sendSystemEmail(newEmail, i18n.T(lang, "email_change_subject"), body); err != nil {
+		log.Printf("[CHANGE-EMAIL] failed to send confirmation to %q: %v", newEmail, err)
+		renderForm(i18n.T(lang, "email_verify_send_failed"), "")
+		return
+	}
+	log.Printf("[CHANGE-EMAIL] user %d requested change from %q to %q", userID, email, newEmail)
+	renderForm("", fmt.Sprintf(i18n.T(lang, "email_change_sent"), newEmail))
+}
+
+// handleUserChangeEmailVerify handles GET /user/change-email/verify?token=...
+// Following the link proves ownership of the new address and applies the
+// change. It needs no session so the link works from any browser.
+func handleUserChangeEmailVerify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	lang := i18n.DetectLang(r)
+	nowStr := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	var id, userID int64
+	var oldEmail, newEmail string
+	err := db.QueryRow(`SELECT id, user_id, old_email, new_email FROM email_change_requests
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		hashEmailToken(token), nowStr).Scan(&id, &userID, &oldEmail, &newEmail)
+	if token == "" || err != nil {
+		http.Redirect(w, r, "/user/?error=email_verify_invalid", http.StatusFound)
+		return
+	}
+	db.Exec("UPDATE email_change_requests SET used_at = ? WHERE user_id = ? AND used_at IS NULL", nowStr, userID)
+
+	// The account's email or the new address may have changed hands since the link was sent.
+	if getEmailForUser(userID) != oldEmail || emailTakenByOtherAccount(userID, newEmail) {
+		log.Printf("[CHANGE-EMAIL] stale request %d for user %d (%q -> %q)", id, userID, oldEmail, newEmail)
+		http.Redirect(w, r, "/user/?error=email_verify_invalid", http.StatusFound)
+		return
+	}
+	if err := changeAccountEmail(userID, oldEmail, newEmail); err != nil {
+		log.Printf("[CHANGE-EMAIL] failed to change %q to %q for user %d: %v", oldEmail, newEmail, userID, err)
+		http.Error(w, i18n.T(lang, "system_error"), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[CHANGE-EMAIL] user %d changed email from %q to %q", userID, oldEmail, newEmail)
+
+	// Tell the old address, in case the change was not made by its owner.
+	body := fmt.Sprintf(i18n.T(lang, "email_changed_notice"), newEmail)
+	if err := sendSystemEmail(oldEmail, i18n.T(lang, "email_change_subject"), body); err != nil {
+		log.Printf("[CHANGE-EMAIL] failed to notify old address %q: %v", oldEmail, err)
+	}
+	http.Redirect(w, r, "/user/change-email?success=changed", http.StatusFound)
+}
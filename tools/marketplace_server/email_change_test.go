@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserChangeEmailVerify(t *testing.T) {
+	useTestDB(t)
+	// Two accounts (web and desktop) share alice@old.example and its wallet.
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Alice', 'alice@old.example')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'SN-1', 'Alice PC', 'alice@old.example')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance, password_hash, username) VALUES ('alice@old.example', 30, 'pw-hash', 'alice')")
+	// Credits were gifted to the new address before it had an account.
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance) VALUES ('alice@new.example', 12.5)")
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount) VALUES (1, 'purchase', -5)")
+	mustExec(t, "INSERT INTO email_change_requests (user_id, old_email, new_email, token_hash, expires_at) VALUES (1, 'alice@old.example', 'alice@new.example', ?, '2999-01-01 00:00:00')", hashEmailToken("tok"))
+
+	rec := httptest.NewRecorder()
+	handleUserChangeEmailVerify(rec, httptest.NewRequest(http.MethodGet, "/user/change-email/verify?token=tok", nil))
+	if loc := rec.Header().Get("Location"); loc != "/user/change-email?success=changed" {
+		t.Fatalf("redirect = %q, body %s", loc, rec.Body.String())
+	}
+
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM users WHERE email = 'alice@new.example'").Scan(&n)
+	if n != 2 {
+		t.Errorf("%d accounts moved, want both", n)
+	}
+	var balance float64
+	var pw, username sql.NullString
+	var verified int
+	db.QueryRow("SELECT credits_balance, password_hash, username, email_verified FROM email_wallets WHERE email = 'alice@new.example'").Scan(&balance, &pw, &username, &verified)
+	if balance != 42.5 || pw.String != "pw-hash" || username.String != "alice" || verified != 1 {
+		t.Errorf("new wallet = %v %v %v verified=%d; want 42.5 pw-hash alice 1", balance, pw, username, verified)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM email_wallets WHERE email = 'alice@old.example'").Scan(&n)
+	if n != 0 {
+		t.Error("old wallet should be removed")
+	}
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = 1").Scan(&n)
+	if n != 1 {
+		t.Errorf("credits_transactions = %d rows, want 1", n)
+	}
+	if got := getWalletBalance(2); got != 42.5 {
+		t.Errorf("sibling account balance = %v, want 42.5", got)
+	}
+
+	// The link is single-use.
+	rec = httptest.NewRecorder()
+	handleUserChangeEmailVerify(rec, httptest.NewRequest(http.MethodGet, "/user/change-email/verify?token=tok", nil))
+	if loc := rec.Header().Get("Location"); loc != "/user/?error=email_verify_invalid" {
+		t.Errorf("reused token redirect = %q", loc)
+	}
+}
+
+func TestUserChangeEmailVerifyTaken(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Alice', 'alice@old.example')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance) VALUES ('alice@old.example', 30)")
+	mustExec(t, "INSERT INTO email_change_requests (user_id, old_email, new_email, token_hash, expires_at) VALUES (1, 'alice@old.example', 'bob@example.com', ?, '2999-01-01 00:00:00')", hashEmailToken("tok"))
+	// Bob registered with the address after the link was sent.
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'email', 'b', 'Bob', 'bob@example.com')")
+
+	rec := httptest.NewRecorder()
+	handleUserChangeEmailVerify(rec, httptest.NewRequest(http.MethodGet, "/user/change-email/verify?token=tok", nil))
+	if loc := rec.Header().Get("Location"); loc != "/user/?error=email_verify_invalid" {
+		t.Fatalf("redirect = %q", loc)
+	}
+	if email := getEmailForUser(1); email != "alice@old.example" {
+		t.Errorf("email = %q, want unchanged", email)
+	}
+	if got := getWalletBalanceByEmail("alice@old.example"); got != 30 {
+		t.Errorf("old wallet = %v, want 30", got)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"marketplace_server/i18n"
+)
+
+// Email verification limits.
+const (
+	emailVerifyTokenTTL = 24 * time.Hour
+	emailVerifyCooldown = time.Minute // minimum gap between two verification emails
+)
+
+// isEmailVerified reports whether the wallet for email has proven ownership.
+func isEmailVerified(email string) bool {
+	if email == "" {
+		return false
+	}
+	var verified int
+	db.QueryRow("SELECT COALESCE(email_verified, 0) FROM email_wallets WHERE email = ?", email).Scan(&verified)
+	return verified == 1
+}
+
+// markEmailVerified flags the wallet for email as verified. Used after a
+// verification link is followed and whenever ownership is otherwise proven
+// (password reset link, provider-verified OAuth email).
+func markEmailVerified(email string) {
+	if email == "" {
+		return
+	}
+	ensureWalletExists(email)
+	if _, err := db.Exec("UPDATE email_wallets SET email_verified = 1, email_verified_at = CURRENT_TIMESTAMP WHERE email = ? AND COALESCE(email_verified, 0) = 0", email); err != nil {
+		log.Printf("[EMAIL-VERIFY] failed to mark %q verified: %v", email, err)
+	}
+}
+
+// emailVerifyCooldownRemaining returns how long the user must wait before
+// another verification email may be sent to email.
+func emailVerifyCooldownRemaining(email string, now time.Time) time.Duration {
+	var last string
+	db.QueryRow("SELECT COALESCE(MAX(created_at), '') FROM email_verification_tokens WHERE email = ?", email).Scan(&last)
+	if last == "" {
+		return 0
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", last)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, last)
+	}
+	if err != nil {
+		return 0
+	}
+	if remaining := emailVerifyCooldown - now.Sub(t); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// cleanupEmailVerificationTokens removes used and expired verification tokens.
+func cleanupEmailVerificationTokens(now time.Time) {
+	nowStr := now.UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.Exec(`DELETE FROM email_verification_tokens WHERE expires_at < ? OR used_at IS NOT NULL`, nowStr); err != nil {
+		log.Printf("[EMAIL-VERIFY] cleanup failed: %v", err)
+	}
+}
+
+// handleUserEmailVerifySend handles POST /user/email/verify/send.
+// Sends a verification link to the logged-in user's email, at most once per cooldown.
+func handleUserEmailVerifySend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "未登录"})
+		return
+	}
+	lang := i18n.DetectLang(r)
+
+	var email string
+	if err := db.QueryRow("SELECT COALESCE(email, '') FROM users WHERE id = ?", userID).Scan(&email); err != nil || email == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_verify_no_email")})
+		return
+	}
+	if isEmailVerified(email) {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "verified": true})
+		return
+	}
+
+	now := time.Now()
+	if remaining := emailVerifyCooldownRemaining(email, now); remaining > 0 {
+		secs := int(remaining.Seconds()) + 1
+		jsonResponse(w, http.StatusTooManyRequests, map[string]interface{}{
+			"ok":       false,
+			"error":    fmt.Sprintf(i18n.T(lang, "email_verify_cooldown"), secs),
+			"cooldown": secs,
+		})
+		return
+	}
+
+	baseURL, ok := siteBaseURL()
+	if !ok {
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_verify_send_failed")})
+		return
+	}
+	token := generateSessionID()
+	if _, err := db.Exec(`INSERT INTO email_verification_tokens (email, token_hash, expires_at) VALUES (?, ?, ?)`,
+		email, hashEmailToken(token), now.Add(emailVerifyTokenTTL).UTC().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("[EMAIL-VERIFY] failed to store token for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+		return
+	}
+
+	link := baseURL + "/user/email/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(i18n.T(lang, "email_verify_body"), link, int(emailVerifyTokenTTL.Hours()))
+	if err := sendSystemEmail(email, i18n.T(lang, "email_verify_subject"), body); err != nil {
+		log.Printf("[EMAIL-VERIFY] failed to send verification email to %q: %v", email, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": i18n.T(lang, "email_verify_send_failed")})
+		return
+	}
+	log.Printf("[EMAIL-VERIFY] verification email sent to %q (user %d)", email, userID)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "cooldown": int(emailVerifyCooldown.Seconds())})
+}
+
+// handleUserEmailVerify handles GET /user/email/verify?token=...
+// It does not require a session so the link works from any browser.
+func handleUserEmailVerify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	nowStr := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	var tokenID int64
+	var email string
+	err := db.QueryRow(`SELECT id, email FROM email_verification_tokens
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		hashEmailToken(token), nowStr).Scan(&tokenID, &email)
+	if token == "" || err != nil {
+		http.Redirect(w, r, "/user/?error=email_verify_invalid", http.StatusFound)
+		return
+	}
+	if _, err := db.Exec(`UPDATE email_verification_tokens SET used_at = ? WHERE email = ? AND used_at IS NULL`, nowStr, email); err != nil {
+		log.Printf("[EMAIL-VERIFY] failed to consume tokens for %q: %v", email, err)
+	}
+	markEmailVerified(email)
+	log.Printf("[EMAIL-VERIFY] email %q verified", email)
+	http.Redirect(w, r, "/user/?success=email_verified", http.StatusFound)
+}
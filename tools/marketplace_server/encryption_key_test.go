@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDecryptAfterKeyRotation(t *testing.T) {
+	t.Setenv("PAYPAL_ENCRYPTION_RETIRED_KEYS", "")
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "old-key")
+	oldValue, err := encryptPayPalSecret("s3cret")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !strings.HasPrefix(oldValue, encryptedValuePrefix) {
+		t.Fatalf("ciphertext %q is not versioned", oldValue)
+	}
+
+	// Rotate: new primary key, old key retired.
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "new-key")
+	t.Setenv("PAYPAL_ENCRYPTION_RETIRED_KEYS", "old-key")
+	if got, err := decryptPayPalSecret(oldValue); err != nil || got != "s3cret" {
+		t.Fatalf("decrypt with retired key = %q, %v", got, err)
+	}
+
+	newValue, changed, err := reencryptSecret(oldValue)
+	if err != nil || !changed {
+		t.Fatalf("reencryptSecret = %v, %v", changed, err)
+	}
+	if _, changed, _ := reencryptSecret(newValue); changed {
+		t.Error("value under the current key was re-encrypted again")
+	}
+
+	// Once the retired key is dropped, only the re-encrypted value is readable.
+	t.Setenv("PAYPAL_ENCRYPTION_RETIRED_KEYS", "")
+	if _, err := decryptPayPalSecret(oldValue); err == nil {
+		t.Error("old value decrypted without its key")
+	}
+	if got, err := decryptPayPalSecret(newValue); err != nil || got != "s3cret" {
+		t.Errorf("decrypt re-encrypted value = %q, %v", got, err)
+	}
+}
+
+func TestDecryptLegacyUnversionedValue(t *testing.T) {
+	// Legacy format: bare hex nonce+ciphertext under SHA-256(key).
+	key := sha256.Sum256([]byte("legacy-key"))
+	block, _ := aes.NewCipher(key[:])
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	legacy := hex.EncodeToString(gcm.Seal(nonce, nonce, []byte("old-secret"), nil))
+
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "rotated-key")
+	t.Setenv("PAYPAL_ENCRYPTION_RETIRED_KEYS", "legacy-key")
+	if got, err := decryptPayPalSecret(legacy); err != nil || got != "old-secret" {
+		t.Errorf("decrypt legacy value = %q, %v", got, err)
+	}
+}
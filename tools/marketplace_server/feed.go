@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// feedEntryLimit caps the number of entries in an Atom feed.
+const feedEntryLimit = 50
+
+const atomContentType = "application/atom+xml; charset=utf-8"
+
+// atomFeed is an Atom 1.0 (RFC 4287) feed document. encoding/xml escapes
+// all text, so pack names and descriptions can be used as is.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     atomText    `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Summary   *atomText   `xml:"summary,omitempty"`
+}
+
+// atomTime converts a database timestamp to the RFC 3339 form Atom requires.
+func atomTime(s string) string {
+	if t, ok := parseTxTime(s); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// buildAtomFeed renders products as an Atom feed. selfURL is the feed's own
+// URL and pageURL the HTML page it mirrors.
+func buildAtomFeed(title, selfURL, pageURL, baseURL string, products []HomepageProductInfo) ([]byte, error) {
+	feed := atomFeed{
+		Title:  title,
+		ID:     selfURL,
+		Links:  []atomLink{{Href: selfURL, Rel: "self", Type: "application/atom+xml"}, {Href: pageURL, Rel: "alternate", Type: "text/html"}},
+		Author: atomAuthor{Name: title},
+	}
+	for _, p := range products {
+		link := fmt.Sprintf("%s/pack/%s", baseURL, p.ShareToken)
+		published := atomTime(p.CreatedAt)
+		entry := atomEntry{
+			Title:     atomText{Type: "text", Body: p.PackName},
+			ID:        link,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: published,
+			Updated:   published,
+		}
+		if p.AuthorName != "" {
+			entry.Author = &atomAuthor{Name: p.AuthorName}
+		}
+		if p.PackDesc != "" {
+			entry.Summary = &atomText{Type: "text", Body: p.PackDesc}
+		}
+		if published > feed.Updated {
+			feed.Updated = published
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if feed.Updated == "" {
+		feed.Updated = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// serveCachedXML writes the XML document stored under key, rendering it with
+// build on a cache miss.
+func serveCachedXML(w http.ResponseWriter, key, contentType string, build func() ([]byte, error)) {
+	body, hit := globalCache.GetFeed(key)
+	if !hit {
+		var err error
+		body, err = globalCache.DoFeedQuery(key, build)
+		if err != nil {
+			log.Printf("[FEED] failed to build %s: %v", key, err)
+			http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+			return
+		}
+		globalCache.SetFeed(key, body)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// handleFeed serves GET /feed.xml, the marketplace's newest packs.
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	baseURL := requestBaseURL(r)
+	serveCachedXML(w, "feed:site:"+baseURL, atomContentType, func() ([]byte, error) {
+		products, err := queryNewestProducts(feedEntryLimit)
+		if err != nil {
+			return nil, err
+		}
+		return buildAtomFeed("分析技能包市场", baseURL+"/feed.xml", baseURL+"/", baseURL, products)
+	})
+}
+
+// handleStorefrontFeed serves GET /store/{id}/feed.xml, the packs shown on one
+// storefront. The store may be addressed by public ID, numeric ID or slug.
+func handleStorefrontFeed(w http.ResponseWriter, r *http.Request, storeIdentifier string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	storefrontID, _, err := resolveStorefrontID(storeIdentifier)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	baseURL := requestBaseURL(r)
+	serveCachedXML(w, fmt.Sprintf("feed:store:%d:%s", storefrontID, baseURL), atomContentType, func() ([]byte, error) {
+		var storeName, publicID string
+		if err := db.QueryRow("SELECT COALESCE(store_name, ''), COALESCE(public_id, '') FROM author_storefronts WHERE id = ?",
+			storefrontID).Scan(&storeName, &publicID); err != nil {
+			return nil, err
+		}
+		if publicID == "" {
+			publicID = fmt.Sprintf("%d", storefrontID)
+		}
+		if storeName == "" {
+			storeName = "小铺"
+		}
+		products, err := queryStorefrontNewestPacks(storefrontID, feedEntryLimit)
+		if err != nil {
+			return nil, err
+		}
+		pageURL := fmt.Sprintf("%s/store/%s", baseURL, publicID)
+		return buildAtomFeed(storeName, pageURL+"/feed.xml", pageURL, baseURL, products)
+	})
+}
+
+// queryStorefrontNewestPacks returns the newest published packs shown on a
+// storefront: all of the author's packs when auto-add is on, else the packs
+// added to the store.
+func queryStorefrontNewestPacks(storefrontID int64, limit int) ([]HomepageProductInfo, error) {
+	rows, err := db.Query(`SELECT pl.id, pl.pack_name, COALESCE(pl.pack_description, ''), COALESCE(pl.author_name, ''), pl.share_mode, pl.credits_price,
+		pl.download_count, COALESCE(pl.share_token, ''), COALESCE(pl.created_at, '')
+		FROM pack_listings pl
+		JOIN author_storefronts sf ON sf.user_id = pl.user_id
+		WHERE sf.id = ? AND pl.status = 'published'
+		  AND (sf.auto_add_enabled = 1 OR EXISTS (
+			SELECT 1 FROM storefront_packs sp WHERE sp.storefront_id = sf.id AND sp.pack_listing_id = pl.id))
+		ORDER BY pl.created_at DESC
+		LIMIT ?`, storefrontID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("queryStorefrontNewestPacks: %w", err)
+	}
+	defer rows.Close()
+
+	var products []HomepageProductInfo
+	for rows.Next() {
+		var p HomepageProductInfo
+		if err := rows.Scan(&p.ListingID, &p.PackName, &p.PackDesc, &p.AuthorName, &p.ShareMode, &p.CreditsPrice, &p.DownloadCount, &p.ShareToken, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("queryStorefrontNewestPacks scan: %w", err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("queryStorefrontNewestPacks rows: %w", err)
+	}
+	return products, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStorefrontFeedEscapesAndFilters(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id, auto_add_enabled) VALUES (10, 1, 'Tom & Jerry', 'tj', 'pub10', 0)")
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, pack_description, share_mode, status, share_token, created_at)
+		VALUES (100, 1, 1, x'00', 'Sales <Q1> & "more"', 'a < b', 'free', 'published', 'tok100', '2026-01-02 03:04:05')`)
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, share_token) VALUES (101, 1, 1, x'00', 'Not in store', 'free', 'published', 'tok101')")
+	mustExec(t, "INSERT INTO storefront_packs (storefront_id, pack_listing_id) VALUES (10, 100)")
+
+	rec := httptest.NewRecorder()
+	handleStorefrontFeed(rec, httptest.NewRequest(http.MethodGet, "http://example.com/store/tj/feed.xml", nil), "tj")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "Sales &lt;Q1&gt; &amp; &#34;more&#34;") {
+		t.Errorf("pack name not escaped:\n%s", rec.Body)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed is not valid XML: %v", err)
+	}
+	if feed.Title != "Tom & Jerry" || len(feed.Entries) != 1 {
+		t.Fatalf("feed title %q with %d entries", feed.Title, len(feed.Entries))
+	}
+	e := feed.Entries[0]
+	if e.Link.Href != "http://example.com/pack/tok100" || e.Published != "2026-01-02T03:04:05Z" || e.Summary.Body != "a < b" {
+		t.Errorf("entry = %+v", e)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flash sales. Packs (credits) and custom products (USD) may carry a
+// sale_price that applies between sale_start and sale_end (UTC). Purchase
+// handlers charge packPriceAt / customProductPriceAt; cached storefront and
+// pack detail data show the sale price and expire at the next sale boundary.
+const maxFlashSaleDuration = 30 * 24 * time.Hour
+
+// FlashSale is a time-boxed discount. The zero value means no sale.
+type FlashSale struct {
+	Price float64
+	Start time.Time
+	End   time.Time
+}
+
+// ActiveAt reports whether the sale price applies at t.
+func (s FlashSale) ActiveAt(t time.Time) bool {
+	return !s.End.IsZero() && !t.Before(s.Start) && t.Before(s.End)
+}
+
+// NextChange returns when the sale next starts or ends after t, or the zero
+// time if it never changes again.
+func (s FlashSale) NextChange(t time.Time) time.Time {
+	switch {
+	case s.End.IsZero():
+		return time.Time{}
+	case t.Before(s.Start):
+		return s.Start
+	case t.Before(s.End):
+		return s.End
+	}
+	return time.Time{}
+}
+
+// parseDBTime parses a DATETIME column, which the driver may return either as
+// stored ("2006-01-02 15:04:05") or already in RFC 3339 form.
+func parseDBTime(s string) time.Time {
+	if t, err := time.Parse("2006-01-02 15:04:05", s); err == nil {
+		return t
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC()
+	}
+	return time.Time{}
+}
+
+func newFlashSale(price sql.NullFloat64, start, end string) FlashSale {
+	if !price.Valid {
+		return FlashSale{}
+	}
+	return FlashSale{Price: price.Float64, Start: parseDBTime(start), End: parseDBTime(end)}
+}
+
+// loadFlashSales returns the configured sales of the given rows of table
+// ("pack_listings" or "custom_products"), keyed by id.
+func loadFlashSales(table string, ids []int64) map[int64]FlashSale {
+	sales := make(map[int64]FlashSale)
+	if len(ids) == 0 {
+		return sales
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.Query(`SELECT id, sale_price, COALESCE(sale_start, ''), COALESCE(sale_end, '')
+		FROM `+table+` WHERE sale_price IS NOT NULL AND id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		log.Printf("[FLASH-SALE] failed to load sales from %s: %v", table, err)
+		return sales
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var price sql.NullFloat64
+		var start, end string
+		if err := rows.Scan(&id, &price, &start, &end); err != nil {
+			continue
+		}
+		sales[id] = newFlashSale(price, start, end)
+	}
+	return sales
+}
+
+// packPriceAt returns the credits price of listingID at now: the sale price
+// while a flash sale is running, basePrice otherwise.
+func packPriceAt(listingID int64, basePrice int, now time.Time) int {
+	if sale, ok := loadFlashSales("pack_listings", []int64{listingID})[listingID]; ok && sale.ActiveAt(now) {
+		return int(sale.Price)
+	}
+	return basePrice
+}
+
+// customProductPriceAt returns the USD price of productID at now.
+func customProductPriceAt(productID int64, basePrice float64, now time.Time) float64 {
+	if sale, ok := loadFlashSales("custom_products", []int64{productID})[productID]; ok && sale.ActiveAt(now) {
+		return sale.Price
+	}
+	return basePrice
+}
+
+// applyStorefrontSales replaces the prices of packs and products on sale at
+// now, keeping the regular price in OriginalPrice / OriginalPriceUSD, and
+// returns the next time any of their sales starts or ends (zero if none).
+func applyStorefrontSales(featured, packs []StorefrontPackInfo, products []CustomProduct, now time.Time) time.Time {
+	var next time.Time
+	track := func(sale FlashSale) {
+		if t := sale.NextChange(now); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	var packIDs []int64
+	for _, list := range [][]StorefrontPackInfo{featured, packs} {
+		for _, p := range list {
+			packIDs = append(packIDs, p.ListingID)
+		}
+	}
+	packSales := loadFlashSales("pack_listings", packIDs)
+	for _, list := range [][]StorefrontPackInfo{featured, packs} {
+		for i := range list {
+			sale, ok := packSales[list[i].ListingID]
+			if !ok {
+				continue
+			}
+			track(sale)
+			if sale.ActiveAt(now) && list[i].ShareMode != "free" {
+				list[i].OriginalPrice = list[i].CreditsPrice
+				list[i].CreditsPrice = int(sale.Price)
+				list[i].SaleEndsAt = sale.End.Format(time.RFC3339)
+			}
+		}
+	}
+
+	productIDs := make([]int64, len(products))
+	for i, p := range products {
+		productIDs[i] = p.ID
+	}
+	productSales := loadFlashSales("custom_products", productIDs)
+	for i := range products {
+		sale, ok := productSales[products[i].ID]
+		if !ok {
+			continue
+		}
+		track(sale)
+		if sale.ActiveAt(now) {
+			products[i].OriginalPriceUSD = products[i].PriceUSD
+			products[i].PriceUSD = sale.Price
+			products[i].SaleEndsAt = sale.End.Format(time.RFC3339)
+		}
+	}
+	return next
+}
+
+// earliestTime returns the earlier of two times, ignoring zero values.
+func earliestTime(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// validateFlashSale validates a sale against the item's regular price.
+// Returns an error message string; empty means validation passed.
+func validateFlashSale(salePrice, basePrice float64, start, end, now time.Time) string {
+	if salePrice <= 0 {
+		return "折扣价必须为正数"
+	}
+	if salePrice >= basePrice {
+		return "折扣价必须低于原价"
+	}
+	if !end.After(start) {
+		return "结束时间必须晚于开始时间"
+	}
+	if !end.After(now) {
+		return "结束时间必须晚于当前时间"
+	}
+	if end.Sub(start) > maxFlashSaleDuration {
+		return "限时折扣最长 30 天"
+	}
+	return ""
+}
+
+// parseFlashSaleWindow reads sale_start and sale_end (RFC 3339) from the form.
+func parseFlashSaleWindow(r *http.Request) (start, end time.Time, errMsg string) {
+	start, err1 := time.Parse(time.RFC3339, r.FormValue("sale_start"))
+	end, err2 := time.Parse(time.RFC3339, r.FormValue("sale_end"))
+	if err1 != nil || err2 != nil {
+		return time.Time{}, time.Time{}, "折扣时间格式无效"
+	}
+	return start.UTC(), end.UTC(), ""
+}
+
+// handleAuthorPackSale handles POST /user/author/pack-sale with form fields
+// listing_id, sale_price (credits), sale_start and sale_end (RFC 3339), or
+// clear=1 to remove the sale.
+func handleAuthorPackSale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+	listingID, err := strconv.ParseInt(r.FormValue("listing_id"), 10, 64)
+	if err != nil || listingID <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的分析包"})
+		return
+	}
+	var ownerID int64
+	var shareMode string
+	var creditsPrice int
+	err = db.QueryRow("SELECT user_id, share_mode, credits_price FROM pack_listings WHERE id = ?", listingID).Scan(&ownerID, &shareMode, &creditsPrice)
+	if err != nil || ownerID != userID {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "分析包不存在"})
+		return
+	}
+
+	if r.FormValue("clear") == "1" {
+		if _, err := db.Exec("UPDATE pack_listings SET sale_price = NULL, sale_start = NULL, sale_end = NULL WHERE id = ?", listingID); err != nil {
+			log.Printf("[FLASH-SALE] failed to clear sale of listing %d: %v", listingID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+			return
+		}
+		invalidateListingCaches(listingID)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+		return
+	}
+
+	if shareMode == "free" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "免费分析包无法设置折扣"})
+		return
+	}
+	salePrice, err := strconv.Atoi(r.FormValue("sale_price"))
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "折扣价必须为整数"})
+		return
+	}
+	start, end, errMsg := parseFlashSaleWindow(r)
+	if errMsg == "" {
+		errMsg = validateFlashSale(float64(salePrice), float64(creditsPrice), start, end, time.Now())
+	}
+	if errMsg != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": errMsg})
+		return
+	}
+
+	_, err = db.Exec("UPDATE pack_listings SET sale_price = ?, sale_start = ?, sale_end = ? WHERE id = ?",
+		salePrice, start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"), listingID)
+	if err != nil {
+		log.Printf("[FLASH-SALE] failed to save sale of listing %d: %v", listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+	invalidateListingCaches(listingID)
+
+	log.Printf("[FLASH-SALE] user %d set sale on listing %d: %d credits from %s to %s", userID, listingID, salePrice, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+}
+
+// handleCustomProductSale handles POST /user/storefront/custom-products/sale
+// with form fields product_id, sale_price (USD), sale_start and sale_end
+// (RFC 3339), or clear=1 to remove the sale.
+func handleCustomProductSale(w http.ResponseWriter, r *http.Request, userID int64) {
+	redirectErr := func(msg string) {
+		http.Redirect(w, r, "/user/storefront/custom-products?error="+url.QueryEscape(msg), http.StatusFound)
+	}
+	if err := r.ParseForm(); err != nil {
+		redirectErr("无效的表单数据")
+		return
+	}
+	productID, err := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
+	if err != nil || productID <= 0 {
+		redirectErr("无效的商品")
+		return
+	}
+
+	var priceUSD float64
+	var slug string
+	err = db.QueryRow(`SELECT p.price_usd, s.store_slug FROM custom_products p
+		JOIN author_storefronts s ON s.id = p.storefront_id
+		WHERE p.id = ? AND s.user_id = ? AND p.deleted_at IS NULL`, productID, userID).Scan(&priceUSD, &slug)
+	if err == sql.ErrNoRows {
+		redirectErr("商品不存在")
+		return
+	}
+	if err != nil {
+		log.Printf("[handleCustomProductSale] query product error: %v", err)
+		http.Error(w, "加载数据失败", http.StatusInternalServerError)
+		return
+	}
+
+	successMsg := "限时折扣已取消"
+	if r.FormValue("clear") == "1" {
+		_, err = db.Exec("UPDATE custom_products SET sale_price = NULL, sale_start = NULL, sale_end = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?", productID)
+	} else {
+		salePrice, perr := strconv.ParseFloat(r.FormValue("sale_price"), 64)
+		start, end, errMsg := parseFlashSaleWindow(r)
+		if perr != nil {
+			errMsg = "折扣价格式无效"
+		} else if errMsg == "" {
+			errMsg = validateFlashSale(salePrice, priceUSD, start, end, time.Now())
+		}
+		if errMsg != "" {
+			redirectErr(errMsg)
+			return
+		}
+		successMsg = fmt.Sprintf("限时折扣已设置：$ %.2f", salePrice)
+		_, err = db.Exec("UPDATE custom_products SET sale_price = ?, sale_start = ?, sale_end = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			salePrice, start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"), productID)
+	}
+	if err != nil {
+		log.Printf("[handleCustomProductSale] update product %d error: %v", productID, err)
+		http.Error(w, "保存失败", http.StatusInternalServerError)
+		return
+	}
+
+	globalCache.InvalidateStorefront(slug)
+	http.Redirect(w, r, "/user/storefront/custom-products?success="+url.QueryEscape(successMsg), http.StatusFound)
+}
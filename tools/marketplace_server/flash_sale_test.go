@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateFlashSale(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		price      float64
+		start, end time.Time
+		wantErr    bool
+	}{
+		{"valid", 80, now, now.Add(24 * time.Hour), false},
+		{"upcoming", 80, now.Add(time.Hour), now.Add(2 * time.Hour), false},
+		{"zero price", 0, now, now.Add(time.Hour), true},
+		{"not below base", 100, now, now.Add(time.Hour), true},
+		{"end before start", 80, now.Add(time.Hour), now, true},
+		{"already over", 80, now.Add(-2 * time.Hour), now.Add(-time.Hour), true},
+		{"too long", 80, now, now.Add(31 * 24 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := validateFlashSale(tt.price, 100, tt.start, tt.end, now); (got != "") != tt.wantErr {
+			t.Errorf("%s: validateFlashSale = %q, wantErr %v", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func TestFlashSalePricing(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (100, 1, 1, x'00', 'Paid', 'per_use', 100, 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (101, 1, 1, x'00', 'Free', 'free', 'published')")
+
+	post := func(userID string, form url.Values) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/user/author/pack-sale", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", userID)
+		rec := httptest.NewRecorder()
+		handleAuthorPackSale(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	end := start.Add(24 * time.Hour)
+	form := url.Values{"listing_id": {"100"}, "sale_price": {"60"}, "sale_start": {start.Format(time.RFC3339)}, "sale_end": {end.Format(time.RFC3339)}}
+	if code, _ := post("2", form); code != http.StatusNotFound {
+		t.Errorf("sale by non-owner: status %d, want 404", code)
+	}
+	if code, _ := post("1", url.Values{"listing_id": {"101"}, "sale_price": {"1"}, "sale_start": {start.Format(time.RFC3339)}, "sale_end": {end.Format(time.RFC3339)}}); code != http.StatusBadRequest {
+		t.Errorf("sale on free pack: status %d, want 400", code)
+	}
+	if code, _ := post("1", url.Values{"listing_id": {"100"}, "sale_price": {"120"}, "sale_start": {start.Format(time.RFC3339)}, "sale_end": {end.Format(time.RFC3339)}}); code != http.StatusBadRequest {
+		t.Errorf("sale above base price: status %d, want 400", code)
+	}
+	if code, resp := post("1", form); code != http.StatusOK {
+		t.Fatalf("set sale: status %d, resp %v", code, resp)
+	}
+
+	// The sale price applies only inside the window.
+	if got := packPriceAt(100, 100, time.Now()); got != 100 {
+		t.Errorf("price before sale = %d, want 100", got)
+	}
+	if got := packPriceAt(100, 100, start.Add(time.Minute)); got != 60 {
+		t.Errorf("price during sale = %d, want 60", got)
+	}
+	if got := packPriceAt(100, 100, end); got != 100 {
+		t.Errorf("price at sale end = %d, want 100", got)
+	}
+
+	// Storefront data shows the sale price and expires at the next boundary.
+	packs := []StorefrontPackInfo{{ListingID: 100, ShareMode: "per_use", CreditsPrice: 100}}
+	if next := applyStorefrontSales(nil, packs, nil, time.Now()); !next.Equal(start) || packs[0].OriginalPrice != 0 {
+		t.Errorf("before sale: next change %v (want %v), pack %+v", next, start, packs[0])
+	}
+	if next := applyStorefrontSales(nil, packs, nil, start.Add(time.Minute)); !next.Equal(end) || packs[0].CreditsPrice != 60 || packs[0].OriginalPrice != 100 {
+		t.Errorf("during sale: next change %v (want %v), pack %+v", next, end, packs[0])
+	}
+
+	if code, resp := post("1", url.Values{"listing_id": {"100"}, "clear": {"1"}}); code != http.StatusOK {
+		t.Fatalf("clear sale: status %d, resp %v", code, resp)
+	}
+	if got := packPriceAt(100, 100, start.Add(time.Minute)); got != 100 {
+		t.Errorf("price after clearing sale = %d, want 100", got)
+	}
+
+	// Custom products use the same window in USD.
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, custom_products_enabled) VALUES (10, 1, 'Mine', 'mine', 1)")
+	mustExec(t, "INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, credits_amount, status, sale_price, sale_start, sale_end) VALUES (5, 10, 'Pack of credits', 'credits', 10, 100, 'published', 7.5, ?, ?)",
+		start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"))
+	if got := customProductPriceAt(5, 10, start.Add(time.Minute)); got != 7.5 {
+		t.Errorf("custom product price during sale = %v, want 7.5", got)
+	}
+	if got := customProductPriceAt(5, 10, end.Add(time.Minute)); got != 10 {
+		t.Errorf("custom product price after sale = %v, want 10", got)
+	}
+}
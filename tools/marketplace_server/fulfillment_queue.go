@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Asynchronous fulfillment of virtual-goods orders. When the License API call
+// in handlePayPalReturn fails, the order stays 'paid' and a fulfillment_jobs
+// row is queued; a background worker retries it until it succeeds, fails
+// permanently, or runs out of attempts. Jobs left in 'failed' are "stuck" and
+// can be retried by an admin.
+const (
+	fulfillmentMaxAttempts  = 8
+	fulfillmentBaseDelay    = time.Minute
+	fulfillmentMaxDelay     = 6 * time.Hour
+	fulfillmentPollInterval = time.Minute
+	fulfillmentSweepAge     = 5 * time.Minute // paid orders older than this without a job get one
+)
+
+// enqueueFulfillment queues a fulfillment job for orderID. It is a no-op when
+// the order already has a job.
+func enqueueFulfillment(orderID int64, reason string) {
+	if _, err := db.Exec(`INSERT OR IGNORE INTO fulfillment_jobs (order_id, last_error) VALUES (?, ?)`, orderID, reason); err != nil {
+		log.Printf("[FULFILLMENT] failed to enqueue order %d: %v", orderID, err)
+		return
+	}
+	log.Printf("[FULFILLMENT] queued order %d: %s", orderID, reason)
+}
+
+// fulfillmentBackoff returns the wait before the next attempt after attempts failures.
+func fulfillmentBackoff(attempts int) time.Duration {
+	d := fulfillmentBaseDelay << (attempts - 1)
+	if d > fulfillmentMaxDelay || d <= 0 {
+		d = fulfillmentMaxDelay
+	}
+	return d
+}
+
+// startFulfillmentWorker runs the fulfillment loop in the background.
+func startFulfillmentWorker() {
+	// A job left 'running' by a crash or restart is picked up again.
+	if _, err := db.Exec(`UPDATE fulfillment_jobs SET status = 'pending' WHERE status = 'running'`); err != nil {
+		log.Printf("[FULFILLMENT] failed to reset running jobs: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(fulfillmentPollInterval)
+		defer ticker.Stop()
+		for {
+			runFulfillmentCycle(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// runFulfillmentCycle queues orphaned paid orders and processes due jobs.
+func runFulfillmentCycle(now time.Time) {
+	// Paid virtual-goods orders that never got a job (e.g. the server died
+	// between capture and fulfillment) are queued here.
+	if _, err := db.Exec(`INSERT OR IGNORE INTO fulfillment_jobs (order_id, last_error)
+		SELECT o.id, 'picked up by sweep' FROM custom_product_orders o
+		JOIN custom_products p ON p.id = o.custom_product_id
+		WHERE o.status = 'paid' AND p.product_type = 'virtual_goods' AND o.updated_at < ?`,
+		now.Add(-fulfillmentSweepAge).UTC().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("[FULFILLMENT] sweep failed: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT id FROM fulfillment_jobs WHERE status = 'pending' AND next_attempt_at <= ? ORDER BY id LIMIT 50`,
+		now.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		log.Printf("[FULFILLMENT] failed to list due jobs: %v", err)
+		return
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	for _, id := range ids {
+		processFulfillmentJob(id)
+	}
+}
+
+// processFulfillmentJob makes one attempt at a pending job. The job is claimed
+// with a conditional update and the order is only moved to 'fulfilled' while
+// still 'paid', so concurrent workers or the synchronous return path can never
+// fulfill the same order twice.
+func processFulfillmentJob(jobID int64) {
+	res, err := db.Exec(`UPDATE fulfillment_jobs SET status = 'running', updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'pending'`, jobID)
+	if err != nil {
+		log.Printf("[FULFILLMENT] failed to claim job %d: %v", jobID, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return
+	}
+
+	var orderID, userID, productID int64
+	var attempts int
+	var orderStatus, recipientEmail, productType, endpoint, apiKey, licenseProductID string
+	err = db.QueryRow(`SELECT j.order_id, j.attempts, o.user_id, o.status, COALESCE(o.recipient_email, ''), p.id, p.product_type,
+		p.license_api_endpoint, p.license_api_key, p.license_product_id
+		FROM fulfillment_jobs j
+		JOIN custom_product_orders o ON o.id = j.order_id
+		JOIN custom_products p ON p.id = o.custom_product_id
+		WHERE j.id = ?`, jobID).Scan(&orderID, &attempts, &userID, &orderStatus, &recipientEmail, &productID, &productType,
+		&endpoint, &apiKey, &licenseProductID)
+	if err != nil {
+		finishFulfillmentJob(jobID, "failed", attempts, fmt.Sprintf("load order: %v", err))
+		return
+	}
+	if orderStatus != "paid" {
+		// Fulfilled by the return path or resolved manually in the meantime.
+		finishFulfillmentJob(jobID, "done", attempts, "order status "+orderStatus)
+		return
+	}
+	if productType != "virtual_goods" {
+		finishFulfillmentJob(jobID, "failed", attempts, "unsupported product type "+productType)
+		return
+	}
+	if !customProductInStock(productID) {
+		// Parked until the author restocks; see requeueSoldOutFulfillments.
+		finishFulfillmentJob(jobID, "failed", attempts, fulfillmentSoldOut)
+		return
+	}
+
+	attempts++
+	email := orderLicenseEmail(userID, recipientEmail)
+	if email == "" {
+		retryFulfillmentJob(jobID, attempts, "buyer has no email", true)
+		return
+	}
+	sn, err := callLicenseAPI(endpoint, apiKey, email, licenseProductID)
+	if err != nil {
+		var apiErr *licenseAPIError
+		retryable := !errors.As(err, &apiErr) || apiErr.Retryable
+		retryFulfillmentJob(jobID, attempts, err.Error(), retryable)
+		return
+	}
+
+	fulfilled, err := fulfillVirtualGoodsOrder(orderID, productID, sn, email)
+	if errors.Is(err, errOutOfStock) {
+		// Another buyer took the last unit while the license was being bound.
+		log.Printf("[FULFILLMENT] order %d got SN %s but the product sold out", orderID, sn)
+		finishFulfillmentJob(jobID, "failed", attempts, fmt.Sprintf("SN %s issued but the product sold out", sn))
+		return
+	}
+	if err != nil {
+		// The SN was issued but not recorded; keep it in the job for manual follow-up.
+		log.Printf("[FULFILLMENT] order %d got SN %s but update failed: %v", orderID, sn, err)
+		finishFulfillmentJob(jobID, "failed", attempts, fmt.Sprintf("SN %s issued but order update failed: %v", sn, err))
+		return
+	}
+	if !fulfilled {
+		log.Printf("[FULFILLMENT] order %d was no longer paid when SN %s arrived", orderID, sn)
+		finishFulfillmentJob(jobID, "done", attempts, "order changed concurrently; SN "+sn+" not recorded")
+		return
+	}
+	finishFulfillmentJob(jobID, "done", attempts, "")
+	log.Printf("[FULFILLMENT] order %d fulfilled on attempt %d", orderID, attempts)
+	sendOrderStatusEmail(orderID)
+}
+
+// retryFulfillmentJob records a failed attempt and either schedules the next
+// one or marks the job stuck.
+func retryFulfillmentJob(jobID int64, attempts int, lastErr string, retryable bool) {
+	if !retryable || attempts >= fulfillmentMaxAttempts {
+		log.Printf("[FULFILLMENT] job %d stuck after %d attempts: %s", jobID, attempts, lastErr)
+		finishFulfillmentJob(jobID, "failed", attempts, lastErr)
+		return
+	}
+	next := time.Now().Add(fulfillmentBackoff(attempts)).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.Exec(`UPDATE fulfillment_jobs SET status = 'pending', attempts = ?, last_error = ?, next_attempt_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		attempts, lastErr, next, jobID); err != nil {
+		log.Printf("[FULFILLMENT] failed to reschedule job %d: %v", jobID, err)
+	}
+}
+
+func finishFulfillmentJob(jobID int64, status string, attempts int, lastErr string) {
+	if _, err := db.Exec(`UPDATE fulfillment_jobs SET status = ?, attempts = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		status, attempts, lastErr, jobID); err != nil {
+		log.Printf("[FULFILLMENT] failed to update job %d: %v", jobID, err)
+	}
+}
+
+// handleAdminFulfillmentJobs handles the admin fulfillment queue:
+//
+//	GET  /api/admin/fulfillment-jobs            — jobs not yet done
+//	POST /api/admin/fulfillment-jobs/{id}/retry — reset a job and run it now
+func handleAdminFulfillmentJobs(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/fulfillment-jobs"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		rows, err := db.Query(`SELECT j.id, j.order_id, j.status, j.attempts, COALESCE(j.last_error, ''),
+			COALESCE(j.next_attempt_at, ''), j.created_at, COALESCE(p.product_name, ''), COALESCE(u.email, ''), o.amount_usd
+			FROM fulfillment_jobs j
+			JOIN custom_product_orders o ON o.id = j.order_id
+			LEFT JOIN custom_products p ON p.id = o.custom_product_id
+			LEFT JOIN users u ON u.id = o.user_id
+			WHERE j.status != 'done'
+			ORDER BY CASE j.status WHEN 'failed' THEN 0 ELSE 1 END, j.id DESC
+			LIMIT 200`)
+		if err != nil {
+			log.Printf("[FULFILLMENT] list jobs failed: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		defer rows.Close()
+		jobs := []map[string]interface{}{}
+		for rows.Next() {
+			var id, orderID int64
+			var attempts int
+			var status, lastErr, nextAt, createdAt, productName, buyerEmail string
+			var amount float64
+			if err := rows.Scan(&id, &orderID, &status, &attempts, &lastErr, &nextAt, &createdAt, &productName, &buyerEmail, &amount); err != nil {
+				continue
+			}
+			jobs = append(jobs, map[string]interface{}{
+				"id": id, "order_id": orderID, "status": status, "attempts": attempts, "last_error": lastErr,
+				"next_attempt_at": nextAt, "created_at": createdAt, "product_name": productName,
+				"buyer_email": buyerEmail, "amount_usd": amount,
+			})
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"jobs": jobs})
+		return
+	}
+
+	idStr, action, _ := strings.Cut(path, "/")
+	jobID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || action != "retry" {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	res, err := db.Exec(`UPDATE fulfillment_jobs SET status = 'pending', next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN ('pending', 'failed')`, jobID)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "任务正在处理或已完成"})
+		return
+	}
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	log.Printf("[FULFILLMENT] admin %d triggered retry of job %d", adminID, jobID)
+	go processFulfillmentJob(jobID)
+	jsonResponse(w, http.StatusOK, map[string]bool{"ok": true})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The queued worker and the PayPal return path can both get to a paid order;
+// only one of them may bind a license or credit the buyer.
+func TestFulfillmentQueueAndReturnFulfillOnce(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	paypal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/oauth2/token":
+			fmt.Fprint(w, `{"access_token":"tok"}`)
+		case strings.HasSuffix(r.URL.Path, "/capture"):
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"status":"COMPLETED"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer paypal.Close()
+	prevBase := paypalAPIBaseURL
+	paypalAPIBaseURL = paypal.URL
+	t.Cleanup(func() { paypalAPIBaseURL = prevBase })
+
+	// The return path's License API call is slow: while it is in flight the
+	// worker picks up the queued job for the same order and finishes first.
+	var licenseCalls int32
+	license := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&licenseCalls, 1)
+		if n == 1 {
+			runFulfillmentCycle(time.Now())
+		}
+		fmt.Fprintf(w, `{"sn":"SN-%d"}`, n)
+	}))
+	defer license.Close()
+
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "test-key")
+	secret, err := encryptPayPalSecret("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('paypal_client_id', 'client'), ('paypal_client_secret', ?)", secret)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, credits_balance) VALUES (1, 'email', 'a', 'Alice', 'alice@example.com', 0)")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'email', 'b', 'Bob', 'bob@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, custom_products_enabled) VALUES (10, 2, 'Bob Store', 'bob', 1)")
+	mustExec(t, `INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, credits_amount, license_api_endpoint, status, stock_quantity)
+		VALUES (5, 10, 'License', 'virtual_goods', 10, 0, ?, 'published', 3),
+		       (6, 10, 'Credits', 'credits', 10, 100, '', 'published', NULL)`, license.URL)
+	mustExec(t, `INSERT INTO custom_product_orders (id, custom_product_id, user_id, paypal_order_id, amount_usd, status)
+		VALUES (1, 5, 1, 'ORDER-1', 10, 'pending'), (2, 6, 1, 'ORDER-2', 10, 'pending')`)
+	// A job already queued for the order, e.g. by an earlier failed return.
+	mustExec(t, "INSERT INTO fulfillment_jobs (order_id, last_error) VALUES (1, 'license API timeout')")
+
+	paypalReturn := func(token string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handlePayPalReturn(rec, httptest.NewRequest(http.MethodGet, "/custom-product/paypal/return?token="+token, nil))
+		loc, err := url.Parse(rec.Header().Get("Location"))
+		if rec.Code != http.StatusFound || err != nil {
+			t.Fatalf("return %s: status %d, location %q", token, rec.Code, rec.Header().Get("Location"))
+		}
+		return loc.Query().Get("success")
+	}
+
+	msg := paypalReturn("ORDER-1")
+	var status, sn, jobStatus string
+	var stock int
+	db.QueryRow("SELECT status, COALESCE(license_sn, '') FROM custom_product_orders WHERE id = 1").Scan(&status, &sn)
+	db.QueryRow("SELECT stock_quantity FROM custom_products WHERE id = 5").Scan(&stock)
+	db.QueryRow("SELECT status FROM fulfillment_jobs WHERE order_id = 1").Scan(&jobStatus)
+	if atomic.LoadInt32(&licenseCalls) != 2 {
+		t.Fatalf("License API called %d times, want 2 (return path and worker)", licenseCalls)
+	}
+	if status != "fulfilled" || sn != "SN-2" || stock != 2 || jobStatus != "done" {
+		t.Errorf("order %s with SN %q, stock %d, job %s; want fulfilled once with the worker's SN-2", status, sn, stock, jobStatus)
+	}
+	if strings.Contains(msg, "SN-1") {
+		t.Errorf("return page shows the unrecorded SN: %q", msg)
+	}
+
+	// A reload of the return URL and later worker cycles change nothing.
+	paypalReturn("ORDER-1")
+	runFulfillmentCycle(time.Now().Add(time.Hour))
+	db.QueryRow("SELECT stock_quantity FROM custom_products WHERE id = 5").Scan(&stock)
+	if n := atomic.LoadInt32(&licenseCalls); n != 2 || stock != 2 {
+		t.Errorf("after reload and sweep: %d License API calls, stock %d", n, stock)
+	}
+
+	// Credits are granted by the return path alone; the worker never picks
+	// up credits orders, so a sweep cannot credit them a second time.
+	paypalReturn("ORDER-2")
+	runFulfillmentCycle(time.Now().Add(time.Hour))
+	paypalReturn("ORDER-2")
+	var credited int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = 1 AND amount > 0").Scan(&credited)
+	if balance := getWalletBalance(1); balance != 100 || credited != 1 {
+		t.Errorf("credits order: balance %v from %d transactions, want 100 from 1", balance, credited)
+	}
+	var jobs int
+	db.QueryRow("SELECT COUNT(*) FROM fulfillment_jobs WHERE order_id = 2").Scan(&jobs)
+	if jobs != 0 {
+		t.Errorf("credits order got %d fulfillment jobs", jobs)
+	}
+}
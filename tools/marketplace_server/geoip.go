@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buyer regions. Sales record the buyer's IP in credits_transactions; a
+// countryResolver turns it into an ISO 3166 country code. The built-in
+// resolver reads an IP range CSV in the layout of the free GeoLite/DB-IP/
+// IP2Location country databases, one range per line as either
+// "start_ip,end_ip,country" or "network_cidr,country". Without a database
+// every buyer counts as unknownCountry.
+const unknownCountry = "unknown"
+
+// maxGeoCacheEntries bounds the per-IP lookup cache.
+const maxGeoCacheEntries = 10000
+
+// countryResolver maps a public IP address to an upper-case country code,
+// or "" when the address is not covered.
+type countryResolver interface {
+	Country(ip netip.Addr) string
+}
+
+// geoIPRange is one contiguous address range of an ipRangeCountryDB.
+type geoIPRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// ipRangeCountryDB resolves countries by binary search over sorted ranges.
+type ipRangeCountryDB struct {
+	ranges []geoIPRange
+}
+
+// Country implements countryResolver.
+func (db *ipRangeCountryDB) Country(ip netip.Addr) string {
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return db.ranges[i].end.Compare(ip) >= 0
+	})
+	if i < len(db.ranges) && db.ranges[i].start.Compare(ip) <= 0 {
+		return db.ranges[i].country
+	}
+	return ""
+}
+
+// parseIPRangeCountryDB reads a country range CSV. Header, comment and
+// malformed lines are skipped.
+func parseIPRangeCountryDB(r io.Reader) (*ipRangeCountryDB, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	db := &ipRangeCountryDB{}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rng geoIPRange
+		switch {
+		case len(rec) == 2 || (len(rec) > 2 && strings.Contains(rec[0], "/")):
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(rec[0]))
+			if err != nil {
+				continue
+			}
+			prefix = prefix.Masked()
+			rng.start, rng.end = prefix.Addr(), lastAddrInPrefix(prefix)
+			rng.country = rec[1]
+		case len(rec) >= 3:
+			start, err1 := netip.ParseAddr(strings.TrimSpace(rec[0]))
+			end, err2 := netip.ParseAddr(strings.TrimSpace(rec[1]))
+			if err1 != nil || err2 != nil || start.Is4() != end.Is4() || end.Less(start) {
+				continue
+			}
+			rng.start, rng.end, rng.country = start.Unmap(), end.Unmap(), rec[2]
+		default:
+			continue
+		}
+		rng.country = strings.ToUpper(strings.TrimSpace(rng.country))
+		if len(rng.country) != 2 || rng.country == "ZZ" || rng.country == "--" {
+			continue
+		}
+		db.ranges = append(db.ranges, rng)
+	}
+	sort.Slice(db.ranges, func(i, j int) bool { return db.ranges[i].start.Less(db.ranges[j].start) })
+	return db, nil
+}
+
+// lastAddrInPrefix returns the highest address of a masked prefix.
+func lastAddrInPrefix(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for bit := p.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+var (
+	geoResolverMu sync.RWMutex
+	geoResolver   countryResolver
+	geoCache      = make(map[string]string)
+)
+
+// setCountryResolver installs the resolver used by lookupCountry and clears
+// the lookup cache. A nil resolver maps every address to unknownCountry.
+func setCountryResolver(r countryResolver) {
+	geoResolverMu.Lock()
+	geoResolver = r
+	geoCache = make(map[string]string)
+	geoResolverMu.Unlock()
+}
+
+// loadGeoIPDatabase installs the range CSV at path as the country resolver.
+func loadGeoIPDatabase(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	db, err := parseIPRangeCountryDB(f)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	setCountryResolver(db)
+	log.Printf("[GEOIP] loaded %d ranges from %s", len(db.ranges), path)
+	return nil
+}
+
+// lookupCountry returns the country code of a recorded client IP, or
+// unknownCountry when the IP is empty, private, loopback, unparseable or not
+// covered by the resolver. Results are cached per IP.
+func lookupCountry(ipStr string) string {
+	ipStr = strings.TrimSpace(ipStr)
+	geoResolverMu.RLock()
+	country, ok := geoCache[ipStr]
+	resolver := geoResolver
+	geoResolverMu.RUnlock()
+	if ok {
+		return country
+	}
+
+	country = unknownCountry
+	if ip, err := netip.ParseAddr(strings.Trim(ipStr, "[]")); err == nil {
+		ip = ip.Unmap()
+		if resolver != nil && ip.IsGlobalUnicast() && !ip.IsPrivate() {
+			if c := resolver.Country(ip); c != "" {
+				country = c
+			}
+		}
+	}
+
+	geoResolverMu.Lock()
+	if len(geoCache) >= maxGeoCacheEntries {
+		geoCache = make(map[string]string)
+	}
+	geoCache[ipStr] = country
+	geoResolverMu.Unlock()
+	return country
+}
+
+// RegionSales is the sales total of one buyer country.
+type RegionSales struct {
+	Country string  `json:"country"`
+	Sales   int     `json:"sales"`
+	Credits float64 `json:"credits"`
+}
+
+// querySalesByCountry groups the sales matched by where (a clause over
+// credits_transactions ct LEFT JOIN pack_listings pl, as built by
+// buildSalesWhereClause) by buyer country, largest first.
+func querySalesByCountry(where string, args []interface{}) ([]RegionSales, error) {
+	rows, err := db.Query(`SELECT COALESCE(ct.ip_address, ''), COUNT(*), COALESCE(SUM(ABS(ct.amount)), 0)
+		FROM credits_transactions ct
+		LEFT JOIN pack_listings pl ON ct.listing_id = pl.id
+		`+where+`
+		GROUP BY COALESCE(ct.ip_address, '')`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querySalesByCountry: %w", err)
+	}
+	defer rows.Close()
+
+	byCountry := make(map[string]*RegionSales)
+	for rows.Next() {
+		var ip string
+		var n int
+		var credits float64
+		if err := rows.Scan(&ip, &n, &credits); err != nil {
+			return nil, fmt.Errorf("querySalesByCountry scan: %w", err)
+		}
+		country := lookupCountry(ip)
+		rs, ok := byCountry[country]
+		if !ok {
+			rs = &RegionSales{Country: country}
+			byCountry[country] = rs
+		}
+		rs.Sales += n
+		rs.Credits += credits
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querySalesByCountry rows: %w", err)
+	}
+
+	regions := make([]RegionSales, 0, len(byCountry))
+	for _, rs := range byCountry {
+		regions = append(regions, *rs)
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].Sales != regions[j].Sales {
+			return regions[i].Sales > regions[j].Sales
+		}
+		return regions[i].Country < regions[j].Country
+	})
+	return regions, nil
+}
+
+// handleStorefrontSalesRegions handles GET /user/storefront/analytics/regions?days=7|30|90
+// and returns the owner's sales grouped by buyer country.
+func handleStorefrontSalesRegions(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+	days := 30
+	switch r.URL.Query().Get("days") {
+	case "7":
+		days = 7
+	case "90":
+		days = 90
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1)).Format(viewDayLayout)
+	regions, err := querySalesByCountry(`WHERE ct.transaction_type IN ('purchase', 'purchase_uses', 'renew', 'download')
+		AND pl.user_id = ? AND ct.created_at >= ?`, []interface{}{userID, since})
+	if err != nil {
+		log.Printf("[GEOIP] failed to query sales regions for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "加载失败"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"days": days, "regions": regions})
+}
+
+// handleAdminSalesRegions returns platform sales grouped by buyer country.
+// GET /api/admin/sales/regions?category_id=&author_id=&storefront_id=&date_from=&date_to=
+func handleAdminSalesRegions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	where, args := buildSalesWhereClause(r)
+	if sid := r.URL.Query().Get("storefront_id"); sid != "" {
+		where += " AND pl.user_id = (SELECT user_id FROM author_storefronts WHERE id = ?)"
+		args = append(args, sid)
+	}
+	regions, err := querySalesByCountry(where, args)
+	if err != nil {
+		log.Printf("[handleAdminSalesRegions] %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"regions": regions})
+}
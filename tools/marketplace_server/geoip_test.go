@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIPRangeCountryDB(t *testing.T) {
+	db, err := parseIPRangeCountryDB(strings.NewReader(`# start,end,country
+network,country_iso_code
+1.0.0.0,1.0.0.255,au
+8.8.8.0/24,US
+2001:db8::/32,DE
+9.9.9.0,9.9.9.255,ZZ
+bogus line`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]string{
+		"1.0.0.7":     "AU",
+		"8.8.8.8":     "US",
+		"8.8.9.1":     "",
+		"2001:db8::1": "DE",
+		"9.9.9.9":     "",
+		"0.0.0.1":     "",
+	} {
+		if got := db.Country(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("%s: got %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestLookupCountryUnknown(t *testing.T) {
+	db, _ := parseIPRangeCountryDB(strings.NewReader("10.0.0.0/8,US\n127.0.0.0/8,US\n8.8.8.0/24,US\n"))
+	setCountryResolver(db)
+	t.Cleanup(func() { setCountryResolver(nil) })
+
+	for ip, want := range map[string]string{
+		"8.8.8.8":          "US",
+		"::ffff:8.8.8.8":   "US",
+		"10.1.2.3":         unknownCountry,
+		"127.0.0.1":        unknownCountry,
+		"":                 unknownCountry,
+		"not-an-ip":        unknownCountry,
+		"203.0.113.9":      unknownCountry,
+		"[2001:db8::dead]": unknownCountry,
+	} {
+		if got := lookupCountry(ip); got != want {
+			t.Errorf("%q: got %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestStorefrontSalesRegions(t *testing.T) {
+	useTestDB(t)
+	geo, _ := parseIPRangeCountryDB(strings.NewReader("8.8.8.0/24,US\n1.0.0.0/24,AU\n"))
+	setCountryResolver(geo)
+	t.Cleanup(func() { setCountryResolver(nil) })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'a', 'A'), (2, 'sn', 'b', 'B')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (1, 1, 1, x'00', 'P', 'per_use', 'published'), (2, 2, 1, x'00', 'Q', 'per_use', 'published')")
+	recent := time.Now().UTC().Format("2006-01-02 15:04:05")
+	for _, sale := range []struct {
+		listing int
+		ip      string
+	}{{1, "8.8.8.8"}, {1, "8.8.8.9"}, {1, "1.0.0.1"}, {1, "192.168.1.5"}, {1, ""}, {2, "1.0.0.1"}} {
+		mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, ip_address, created_at) VALUES (2, 'purchase', -10, ?, ?, ?)", sale.listing, sale.ip, recent)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/user/storefront/analytics/regions", nil)
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleStorefrontSalesRegions(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Regions []RegionSales `json:"regions"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	got := make(map[string]int)
+	for _, rs := range resp.Regions {
+		got[rs.Country] = rs.Sales
+	}
+	if len(got) != 3 || got["US"] != 2 || got["AU"] != 1 || got[unknownCountry] != 2 {
+		t.Errorf("regions %+v", resp.Regions)
+	}
+	if resp.Regions[0].Country != "US" || resp.Regions[0].Credits != 20 {
+		t.Errorf("largest region %+v, want US with 20 credits", resp.Regions[0])
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHeroCTAValidation(t *testing.T) {
+	layout := func(hero HeroSettings) string {
+		settings, _ := json.Marshal(hero)
+		return `{"sections":[{"type":"hero","visible":true,"settings":` + string(settings) + `},{"type":"pack_grid","visible":true,"settings":{}}]}`
+	}
+	for target, wantOK := range map[string]bool{
+		"":                             true,
+		"/store/abc":                   true,
+		"#store-packs":                 true,
+		"https://example.com/docs?a=1": true,
+		"javascript:alert(1)":          false,
+		" JavaScript:alert(1)":         false,
+		"data:text/html,hi":            false,
+		"//evil.example.com":           false,
+		"/\\evil.example.com":          false,
+		"ftp://example.com":            false,
+		"https://user:pw@example.com/": false,
+	} {
+		errMsg := ValidateLayoutConfig(layout(HeroSettings{CTAText: "Go", CTATarget: target}))
+		if (errMsg == "") != wantOK {
+			t.Errorf("target %q: error %q, want ok=%v", target, errMsg, wantOK)
+		}
+	}
+	if errMsg := ValidateLayoutConfig(layout(HeroSettings{CTAText: strings.Repeat("字", maxHeroCTATextLength+1)})); errMsg == "" {
+		t.Error("over-long button text accepted")
+	}
+}
+
+func TestResolveHeroCTA(t *testing.T) {
+	if cta := resolveHeroCTA(HeroSettings{Layout: "reversed"}); cta.Target != "" {
+		t.Errorf("no CTA configured: got %+v", cta)
+	}
+	if cta := resolveHeroCTA(HeroSettings{CTAText: "Shop now"}); cta.Target != defaultHeroCTATarget || cta.External {
+		t.Errorf("text only: got %+v", cta)
+	}
+	if cta := resolveHeroCTA(HeroSettings{CTATarget: "https://example.com"}); cta.Target != "https://example.com" || !cta.External || cta.Text != "" {
+		t.Errorf("external link: got %+v", cta)
+	}
+	if cta := resolveHeroCTA(HeroSettings{CTAText: "x", CTATarget: "javascript:alert(1)"}); cta.Target != "" {
+		t.Errorf("unsafe link rendered: got %+v", cta)
+	}
+}
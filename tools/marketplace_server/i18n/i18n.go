@@ -74,3 +74,9 @@ func AllTranslations(lang Lang) map[string]string {
 	}
 	return translations[ZhCN]
 }
+
+// ParseLang maps a stored or user-supplied language tag to a supported Lang.
+// Returns "" when the tag is empty or unsupported.
+func ParseLang(s string) Lang {
+	return normalizeLang(s)
+}
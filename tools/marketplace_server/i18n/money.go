@@ -0,0 +1,82 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+)
+
+// currencyInfo describes how a currency is written.
+type currencyInfo struct {
+	Symbol   string
+	Decimals int
+	NameZh   string
+}
+
+// currencies lists the currencies prices can be shown or charged in.
+var currencies = map[string]currencyInfo{
+	"USD": {"$", 2, "美元"},
+	"EUR": {"€", 2, "欧元"},
+	"GBP": {"£", 2, "英镑"},
+	"CNY": {"¥", 2, "人民币"},
+	"JPY": {"¥", 0, "日元"},
+	"HKD": {"HK$", 2, "港币"},
+	"CAD": {"CA$", 2, "加元"},
+	"AUD": {"A$", 2, "澳元"},
+}
+
+// SupportedCurrencies returns the supported ISO 4217 codes in display order.
+func SupportedCurrencies() []string {
+	return []string{"USD", "EUR", "GBP", "CNY", "JPY", "HKD", "CAD", "AUD"}
+}
+
+// IsSupportedCurrency reports whether code is a supported currency.
+func IsSupportedCurrency(code string) bool {
+	_, ok := currencies[code]
+	return ok
+}
+
+// CurrencyDecimals returns the number of minor-unit digits for code
+// (2 for unknown currencies).
+func CurrencyDecimals(code string) int {
+	if c, ok := currencies[code]; ok {
+		return c.Decimals
+	}
+	return 2
+}
+
+// FormatAmount formats amount with the currency's decimals and no grouping,
+// as payment APIs expect (e.g. "12.50", "1300").
+func FormatAmount(amount float64, code string) string {
+	return strconv.FormatFloat(amount, 'f', CurrencyDecimals(code), 64)
+}
+
+// FormatMoney formats amount for display, e.g. "$1,234.50 USD" in English or
+// "$1,234.50 美元" in Chinese. Unknown currencies fall back to the bare code.
+func FormatMoney(amount float64, code string, lang Lang) string {
+	c, ok := currencies[code]
+	if !ok {
+		return FormatAmount(amount, code) + " " + code
+	}
+	s := FormatAmount(amount, code)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	intPart, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		b.WriteString("." + frac)
+	}
+	out := c.Symbol + b.String()
+	if neg {
+		out = "-" + out
+	}
+	if lang == ZhCN {
+		return out + " " + c.NameZh
+	}
+	return out + " " + code
+}
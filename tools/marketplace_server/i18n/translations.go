@@ -42,9 +42,72 @@ var zhCN = map[string]string{
 	// User Dashboard
 	"email":                  "邮箱",
 	"credits_balance":        "Credits 余额",
+	"credits_expire_on":      "Credits 将过期于",
+	"gift_credits":           "赠送积分",
+	"mark_all_read":          "全部标为已读",
+	"gift_credits_limits":    "每次至少赠送，每日上限",
+	"gift_credits_email":     "收件人邮箱",
+	"gift_credits_amount":    "赠送数量",
+	"gift_credits_message":   "附言（可选）",
+	"gift_credits_send":      "确认赠送",
+	"gift_credits_confirm":   "确定赠送？",
+	"gift_amount_too_small":  "赠送数量低于最低限额",
+	"gift_message_too_long":  "附言不能超过 200 个字符",
+	"gift_cannot_self":       "不能赠送给自己",
+	"gift_daily_limit_exceeded": "已超过今日赠送上限",
+	"insufficient_credits":   "Credits 余额不足",
 	"change_password":        "修改密码",
 	"set_password":           "设置密码",
 	"billing_records":        "帐单记录",
+	"export_my_data":         "📦 导出我的数据",
+	"data_export_limited":    "数据导出过于频繁，请 %d 分钟后再试",
+	"data_export_failed":     "数据导出失败，请稍后重试",
+	"delete_account":         "🗑️ 删除账户",
+	"acct_delete_title":      "删除账户",
+	"acct_delete_intro":      "此操作不可撤销，请输入密码确认身份",
+	"acct_delete_warn":       "删除后，您的个人资料、收款信息与身份认证资料将被清除，小铺与分析包将下架。交易与提现记录会以匿名形式保留，用于财务对账。",
+	"acct_delete_shared":     "您的邮箱下还有其他账户，钱包余额将保留给这些账户。",
+	"acct_delete_balance":    "您的钱包中还有 %.2f Credits，删除账户后将作废且无法恢复。",
+	"acct_delete_agree":      "我了解钱包余额将被作废",
+	"acct_delete_phrase":     "请输入 %s 以确认删除",
+	"acct_delete_button":     "永久删除我的账户",
+	"acct_delete_confirm":    "确定要永久删除您的账户吗？此操作无法撤销。",
+	"acct_delete_forfeit":    "请确认放弃钱包余额后再删除账户",
+	"acct_delete_pending":    "您有尚未处理的提现申请，请待其完成后再删除账户",
+	"acct_delete_failed":     "删除账户失败，请稍后重试",
+	"acct_delete_done":       "您的账户已删除。感谢您曾使用我们的服务。",
+	"linked_emails":          "✉️ 关联邮箱",
+	"linked_emails_title":    "关联邮箱",
+	"linked_emails_intro":    "关联您的其他邮箱，合并查看并使用这些邮箱钱包中的 Credits。消费时优先扣除主邮箱余额。",
+	"combined_balance":       "合计余额",
+	"primary_email":          "主邮箱",
+	"pending_verify":         "待验证",
+	"set_primary_email":      "设为主邮箱",
+	"unlink_email":           "取消关联",
+	"confirm_unlink_email":   "确定取消关联该邮箱吗？其钱包余额将不再计入本账户。",
+	"link_email_label":       "添加邮箱",
+	"send_link_email":        "发送验证邮件",
+	"email_link_own":         "这是您的登录邮箱，无需关联",
+	"email_link_taken":       "该邮箱已属于其他账户，无法关联",
+	"email_not_linked":       "该邮箱未关联到您的账户",
+	"email_unlink_primary":   "请先将其他邮箱设为主邮箱",
+	"email_link_done":        "邮箱已关联，余额已合并显示",
+	"email_link_subject":     "确认关联邮箱",
+	"email_link_body":        "您好，\r\n\r\n有账户申请关联此邮箱。如是您本人操作，请点击以下链接确认：\r\n%s\r\n\r\n链接 %d 小时内有效。如非本人操作，请忽略此邮件。\r\n",
+	"change_email":           "修改邮箱",
+	"change_email_title":     "修改登录邮箱",
+	"change_email_subtitle":  "确认新邮箱后，账户与钱包余额将一并迁移到新邮箱",
+	"current_email":          "当前邮箱",
+	"new_email_label":        "新邮箱",
+	"email_change_same":      "新邮箱与当前邮箱相同",
+	"email_change_sent":      "确认邮件已发送至 %s，请点击邮件中的链接完成修改",
+	"email_change_done":      "邮箱已修改，钱包余额已迁移到新邮箱",
+	"email_change_subject":   "确认修改登录邮箱",
+	"email_change_body":      "您好，\r\n\r\n有账户申请将登录邮箱修改为此邮箱。如是您本人操作，请点击以下链接确认：\r\n%s\r\n\r\n链接 %d 小时内有效。如非本人操作，请忽略此邮件。\r\n",
+	"email_changed_notice":   "您好，\r\n\r\n您账户的登录邮箱已修改为 %s，钱包余额已一并迁移。\r\n\r\n如果这不是您本人的操作，请立即联系客服。\r\n",
+	"impersonation_banner":   "您正在以用户 <b>%s</b>（ID %d）的身份浏览（管理员模拟登录），提现、支付信息和账户安全操作已禁用",
+	"impersonation_exit":     "退出模拟登录",
+	"impersonation_denied":   "模拟登录期间不能执行此操作",
 	"payment_settings":       "收款设置",
 	"topup":                  "充值",
 	"topup_coming_soon":      "功能开发中",
@@ -72,6 +135,13 @@ var zhCN = map[string]string{
 	"subscription_expires":   "订阅到期：",
 	"expires_at":             "到期时间：",
 	"renew":                  "续费",
+	"pack_expired":          "已过期",
+	"renew_access":          "续期",
+	"renew_access_confirm":  "确认支付积分续期该分析包吗？",
+	"auto_renew_paypal":     "自动续费（PayPal）",
+	"cancel_auto_renew":     "取消自动续费",
+	"auto_renew_on":         "自动续费中",
+	"cancel_auto_renew_confirm": "确定取消自动续费吗？已付费的订阅期内仍可使用。",
 	"author_panel":           "作者面板",
 	"actual_revenue":         "实际收入 Credits（分成 {pct}%）",
 	"unwithdrawn_credits":    "未提现 Credits",
@@ -90,6 +160,27 @@ var zhCN = map[string]string{
 	"published":              "已发布",
 	"rejected":               "已拒绝",
 	"delisted":               "已下架",
+	"scheduled":             "已定时",
+	"quarantined":            "已隔离",
+	"review_quarantine":      "隔离的分析包",
+	"release_quarantine":     "解除隔离",
+	"no_quarantined_packs":   "暂无隔离的分析包",
+	"confirm_release":        "确定解除隔离并转入待审核？",
+	"scan_result_col":        "扫描结果",
+	"schedule_publish":      "定时发布",
+	"schedule_publish_hint": "审核通过后，分析包将在设定的时间自动上架，在此之前不会公开显示。",
+	"cancel_schedule":       "取消定时",
+	"publish_now":           "立即发布",
+	"scheduled_publish_at":  "定时发布",
+	"flash_sale":            "限时折扣",
+	"sale_ends_in":          "距结束",
+	"sale_ended":            "折扣已结束",
+	"sale_price":            "折扣价",
+	"sale_start":            "开始时间",
+	"sale_end":              "结束时间",
+	"set_flash_sale":        "设置限时折扣",
+	"clear_flash_sale":      "取消折扣",
+	"flash_sale_hint":       "折扣价须低于原价，最长 30 天，仅在时间段内生效",
 	"details":                "明细",
 	"delist":                 "下架",
 	"copy_share_link":        "复制分享链接",
@@ -206,7 +297,7 @@ var zhCN = map[string]string{
 	"withdraw_failed":        "提现失败",
 	"enter_valid_amount":     "请输入有效的提现数量",
 	"exceeds_balance":        "提现 Credits 数量不能超过可提现余额",
-	"below_minimum":          "扣除手续费后实付金额低于最低提现金额 100 元",
+	"below_minimum":          "扣除手续费后实付金额低于最低提现金额 {min} 元",
 	"net_amount":             "实付金额",
 	"fee":                    "手续费",
 	"withdraw_amount_label":  "提现金额",
@@ -281,7 +372,8 @@ var zhCN = map[string]string{
 	"err_invalid_withdraw_amount": "提现金额无效，请输入正确的数量。",
 	"err_withdraw_disabled":      "提现功能暂未开放。",
 	"err_withdraw_exceeds":       "提现数量超过可提现余额。",
-	"err_withdraw_below_min":     "扣除手续费后实付金额低于最低提现金额 100 元。",
+	"err_withdraw_wallet":        "提现数量超过钱包余额。",
+	"err_withdraw_below_min":     "扣除手续费后实付金额低于最低提现金额。",
 	"err_system":                 "系统错误，请稍后重试。",
 
 	// User Login
@@ -296,6 +388,73 @@ var zhCN = map[string]string{
 	"no_account":             "没有账号？绑定用户",
 	"captcha_error":          "验证码错误",
 	"login_error":            "用户名或密码错误",
+	"account_blocked":        "该账号已被禁用",
+	"account_blocked_reason": "原因：%s",
+	"account_blocked_until":  "解封时间：%s",
+	"oauth_failed":           "第三方登录失败，请重试",
+	"oauth_email_unverified":  "该邮箱已关联其他账号，但第三方账号未验证此邮箱，无法登录",
+	"oauth_login_google":     "使用 Google 登录",
+	"oauth_login_github":     "使用 GitHub 登录",
+	"oauth_divider":          "或",
+	"forgot_password_link":   "忘记密码？",
+	"forgot_password_title":  "找回密码",
+	"forgot_password_subtitle":  "输入账号邮箱，我们将发送重置密码链接",
+	"send_reset_link":        "发送重置链接",
+	"reset_link_sent":        "如果该邮箱已设置登录密码，重置链接已发送，请查收邮件（链接 30 分钟内有效）。",
+	"reset_email_subject":    "重置您的登录密码",
+	"reset_email_body":       "您好，\r\n\r\n我们收到了重置您登录密码的请求。请点击以下链接设置新密码：\r\n%s\r\n\r\n链接 %d 分钟内有效且只能使用一次。如果这不是您本人的操作，请忽略此邮件。\r\n",
+	"order_email_fulfilled_subject": "订单 #%d 已完成",
+	"order_email_license_body": "您好，\r\n\r\n您购买的「%s」已完成授权发放。\r\n授权 SN：%s\r\n绑定邮箱：%s\r\n订单号：#%d\r\n\r\n感谢您的购买！\r\n",
+	"order_email_credits_body": "您好，\r\n\r\n您购买的「%s」已到账，%d 积分已充值到您的账户。\r\n订单号：#%d\r\n\r\n感谢您的购买！\r\n",
+	"order_email_failed_subject": "订单 #%d 支付未完成",
+	"order_email_failed_body": "您好，\r\n\r\n您购买「%s」（%s）的支付未能完成，订单号 #%d。您的账户未被扣款，如需购买请重新下单。\r\n\r\n如有疑问，请联系我们。\r\n",
+	"order_email_gift_credits_body": "您好，\r\n\r\n您为 %s 购买的「%s」已送达，%d 积分已充值到对方的钱包。\r\n订单号：#%d\r\n\r\n感谢您的购买！\r\n",
+	"gift_email_subject": "您收到了一份礼物：「%s」",
+	"gift_email_credits_body": "您好，\r\n\r\n%s 为您购买了「%s」，%d 积分已充值到与本邮箱（%s）绑定的钱包。\r\n如果您还没有账户，使用本邮箱注册或登录市场后即可使用这些积分。\r\n\r\n祝您使用愉快！\r\n",
+	"gift_email_license_body": "您好，\r\n\r\n%s 为您购买了「%s」，授权已绑定到本邮箱。\r\n授权 SN：%s\r\n绑定邮箱：%s\r\n\r\n祝您使用愉快！\r\n",
+	"credits_gift_email_subject": "您收到了 %d 积分",
+	"credits_gift_email_body": "您好，\r\n\r\n%s 向您赠送了 %d 积分，已充值到与本邮箱（%s）绑定的钱包。\r\n如果您还没有账户，使用本邮箱注册或登录市场后即可使用这些积分。\r\n",
+	"credits_gift_email_message": "\r\n附言：%s\r\n",
+	"gift_recipient_email":  "赠送给（可选）",
+	"gift_recipient_hint":   "填写对方邮箱即可赠送，对方无需已有账户。留空则为自己购买。",
+	"topup_tier":            "充值档位",
+	"topup_tier_hint":       "大额档位额外赠送积分，括号内为每美元可得积分。",
+	"reset_password_title":   "重置密码",
+	"reset_token_invalid":    "重置链接无效或已过期",
+	"request_new_link":       "重新获取重置链接",
+	"reset_failed":           "密码重置失败，请稍后重试",
+	"reset_password_done":    "密码已重置，请使用新密码登录。",
+	"back_to_login":          "返回登录",
+	"unsubscribe_email_footer": "不想再收到此小铺的邮件？退订: %s\r\n",
+	"follow_email_subject":  "%s 上架了新分析包：%s",
+	"follow_email_body":     "您好，\r\n\r\n您关注的小铺「%s」上架了新分析包「%s」。\r\n查看详情: %s\r\n",
+	"unsubscribe_title":     "退订小铺邮件",
+	"unsubscribe_confirm":   "确定让 %s 不再接收「%s」的通知邮件吗？",
+	"unsubscribe_button":    "确认退订",
+	"unsubscribe_done":      "已退订，您将不再收到该小铺的通知邮件。",
+	"unsubscribe_invalid":   "退订链接无效或已失效",
+	"unsubscribe_failed":    "退订失败，请稍后重试",
+	"storefront_email_visit_store": "\r\n\r\n---\r\n访问小铺: %s\r\n",
+	"notify_tpl_version_update_name": "版本更新促销",
+	"notify_tpl_version_update_subject": "[{{.StoreName}}] 分析包版本更新通知",
+	"notify_tpl_version_update_body": "尊敬的客户：\n\n{{.StoreName}} 的分析包已更新至 {{.Version}} 版本。\n\n更新内容：\n{{.UpdateContent}}\n\n促销信息：\n{{.PromoInfo}}\n\n感谢您的支持！",
+	"notify_tpl_holiday_promo_name": "节假日促销",
+	"notify_tpl_holiday_promo_subject": "[{{.StoreName}}] 节假日促销活动",
+	"notify_tpl_holiday_promo_body": "尊敬的客户：\n\n值此 {{.HolidayName}} 之际，{{.StoreName}} 推出限时促销活动。\n\n活动时间：{{.PromoTime}}\n优惠内容：{{.PromoContent}}\n\n祝您节日快乐！",
+	"notify_tpl_flash_promo_name": "临时促销",
+	"notify_tpl_flash_promo_subject": "[{{.StoreName}}] 限时促销活动",
+	"notify_tpl_flash_promo_body": "尊敬的客户：\n\n{{.StoreName}} 推出限时促销活动。\n\n促销原因：{{.PromoReason}}\n活动时间：{{.PromoTime}}\n优惠内容：{{.PromoContent}}\n\n机会难得，欢迎选购！",
+	"email_verify_required":  "请先验证邮箱后再进行此操作。",
+	"email_verify_prompt":    "⚠️ 您的邮箱尚未验证，提现和申请客服支持前需要先完成验证。",
+	"email_verify_send_btn":  "发送验证邮件",
+	"email_verify_sent":      "验证邮件已发送，请查收",
+	"email_verified_success":  "✅ 邮箱验证成功。",
+	"email_verify_invalid":   "⚠️ 验证链接无效或已过期，请重新发送验证邮件。",
+	"email_verify_no_email":  "账号未绑定邮箱",
+	"email_verify_cooldown":  "发送过于频繁，请 %d 秒后重试",
+	"email_verify_send_failed":  "验证邮件发送失败，请稍后重试",
+	"email_verify_subject":   "请验证您的邮箱",
+	"email_verify_body":      "您好，\r\n\r\n请点击以下链接验证您的邮箱：\r\n%s\r\n\r\n链接 %d 小时内有效。如果这不是您本人的操作，请忽略此邮件。\r\n",
 
 	// User Register
 	"bind_register":          "绑定注册",
@@ -362,6 +521,20 @@ var zhCN = map[string]string{
 	"data_source":            "数据源",
 	"category":               "分类",
 	"downloads":              "下载",
+	"related_packs":          "相关推荐",
+	"same_author":            "同一作者",
+	"bundle_offers":          "优惠套餐",
+	"bundle_includes":        "套餐包含",
+	"bundle_list_total":      "单独购买合计",
+	"bundle_you_save":        "节省",
+	"bundle_buy":             "购买套餐",
+	"bundle_buy_confirm":     "确认购买此套餐？",
+	"bundle_own":             "这是您的套餐",
+	"bundle_unavailable":     "套餐暂不可购买",
+	"bundle_one_use":         "1 次使用",
+	"bundle_one_month":       "1 个月订阅",
+	"bundle_owned_note":      "另有 %d 个您已拥有的分析包未列出，购买后仍会一并获得",
+	"bundle_packs_count":     "个分析包",
 	"share":                  "分享",
 	"link_copied":            "链接已复制",
 	"share_storefront":       "🏪 分享小铺",
@@ -471,9 +644,13 @@ var zhCN = map[string]string{
 	"fee_rate_us":            "美国银行卡 手续费率（%）",
 	"fee_rate_eu":            "欧洲银行卡 手续费率（%）",
 	"fee_rate_cn":            "中国银行卡 手续费率（%）",
+	"min_withdrawal_label":   "最低提现金额（元，扣除手续费后）",
+	"fee_cap_label":          "单笔手续费上限（元，0 表示不设上限）",
+	"fee_capped":             "已达手续费上限",
 	"save_fee_settings":      "保存手续费设置",
 	"export_excel":           "导出 Excel",
 	"export_and_approve":     "导出并标记已付款",
+	"export_withdraw_report": "导出对账表",
 	"batch_approve":          "批量标记已付款",
 	"filter_by_author":       "按作者名过滤",
 	"applied_withdraw":       "已申请提现",
@@ -521,6 +698,11 @@ var zhCN = map[string]string{
 	"load_pending_failed":     "加载待审核列表失败",
 	"enter_reject_reason":     "请输入拒绝原因",
 	"rejected_done":           "已拒绝",
+	"bulk_approve":          "批量通过",
+	"bulk_reject":           "批量拒绝",
+	"bulk_review_done":      "已处理 {updated} 项，跳过 {skipped} 项",
+	"select_packs_first":    "请先勾选分析包",
+	"confirm_bulk_approve":  "确定通过选中的 {n} 个分析包？",
 	"marketplace_delisted":    "市场管理 - 已下架分析包",
 	"marketplace_listed":      "市场管理 - 在售分析包",
 	"no_delisted_packs":       "暂无已下架分析包",
@@ -548,6 +730,8 @@ var zhCN = map[string]string{
 	"topup_success":           "充值成功，新余额:",
 	"topup_failed":            "充值失败",
 	"confirm_block":           "确定要{action}客户 \"{name}\" 吗？",
+	"block_reason_prompt":     "封禁原因（将展示给用户，可留空）：",
+	"block_duration_prompt":   "封禁时长（小时，0 或留空为永久）：",
 	"blocked_done":            "已禁用",
 	"unblocked_done":          "已解禁",
 	"no_transactions_admin":   "暂无交易记录",
@@ -592,6 +776,21 @@ var zhCN = map[string]string{
 	"effective_date":          "生效日期（可选，留空立即生效）",
 	"display_duration":        "显示时长",
 	"days_0_permanent":        "天 (0=永久)",
+	"recurrence":              "重复",
+	"targeted_segment":        "定向（按购买记录筛选）",
+	"segment_hint":            "以下条件同时满足的用户将在发送时收到消息，留空的条件不限。",
+	"segment_listing":         "购买过的分析包 ID",
+	"segment_storefront":      "在该小铺购买过（小铺 ID）",
+	"segment_min_spend":       "累计消费不少于（Credits）",
+	"segment_estimate":        "估算人数",
+	"segment_count":           "约 {count} 人",
+	"recurrence_none":         "不重复",
+	"recurrence_daily":        "每天",
+	"recurrence_weekly":       "每周",
+	"recurrence_monthly":      "每月",
+	"recurrence_end":          "重复截止日期",
+	"recurrence_hint":         "重复消息的显示时长不能超过一个周期（每天 1 天、每周 7 天、每月 28 天）。已读过的用户不会再收到未读提醒。",
+	"preview_occurrences":     "预览排期",
 	"message_title":           "消息标题",
 	"message_content":         "消息内容",
 	"message_type":            "消息类型",
@@ -714,6 +913,19 @@ var zhCN = map[string]string{
 	"admin_password_mismatch_err": "两次输入的密码不一致",
 	"captcha_error_admin":     "验证码错误",
 	"admin_login_error":       "用户名或密码错误",
+	"admin_login_locked":      "登录尝试次数过多，请稍后再试",
+	"admin_totp_code":         "两步验证码（未启用可留空）",
+	"admin_2fa_title":         "两步验证",
+	"admin_2fa_scan_hint":     "使用身份验证器应用扫描二维码，或手动输入密钥，然后输入 6 位验证码完成启用。",
+	"admin_2fa_secret":        "密钥",
+	"admin_2fa_enable":        "启用",
+	"admin_2fa_disable":       "停用",
+	"admin_2fa_setup":         "设置两步验证",
+	"admin_2fa_on":            "已启用",
+	"admin_2fa_off":           "未启用",
+	"admin_2fa_recovery_left": "剩余恢复码",
+	"admin_2fa_recovery_hint": "请妥善保存以下恢复码，每个只能使用一次，关闭后将不再显示。",
+	"admin_2fa_code_prompt":   "输入验证码或恢复码",
 	"load_data_failed":        "加载数据失败",
 	"load_billing_failed":     "加载帐单数据失败",
 	"invalid_pack_link":       "无效的分析包链接",
@@ -724,8 +936,10 @@ var zhCN = map[string]string{
 	"withdraw_not_author":     "仅作者可以申请提现",
 	"withdraw_invalid_amount": "提现金额无效",
 	"withdraw_not_open":       "提现功能暂未开放",
+	"withdraw_kyc_required":   "提现金额超过限额，请先完成实名认证（KYC）并通过审核",
 	"withdraw_exceeds":        "提现数量超过可提现余额",
-	"withdraw_below_min_net":  "扣除手续费后实付金额低于最低提现金额 100 元",
+	"withdraw_exceeds_wallet": "提现数量超过钱包余额",
+	"withdraw_below_min_net":  "扣除手续费后实付金额低于最低提现金额 %s 元",
 
 	// Excel export headers (withdrawal)
 	"excel_withdraw_sheet":    "提现记录",
@@ -743,6 +957,16 @@ var zhCN = map[string]string{
 	"excel_bank_card_us":      "美国银行卡",
 	"excel_bank_card_eu":      "欧洲银行卡",
 	"excel_bank_card_cn":      "中国银行卡",
+	"excel_withdraw_id":       "提现ID",
+	"excel_withdraw_credits":  "提现积分",
+	"excel_cash_rate":         "兑换比例",
+	"excel_status":            "状态",
+	"excel_requested_at":      "申请时间",
+	"excel_paid_at":           "付款时间",
+	"excel_status_pending":    "已申请提现",
+	"excel_status_paid":       "已付款",
+	"excel_total":             "合计",
+	"excel_withdraw_count":    "%d 笔",
 
 	// Excel export headers (sales)
 	"excel_order_sheet":       "订单详表",
@@ -812,10 +1036,12 @@ var zhCN = map[string]string{
 	"homepage.title":              "分析技能包市场",
 	"homepage.hero_desc":          "站在专家肩上，洞察业务秘密",
 	"homepage.featured_stores":    "明星店铺",
+	"homepage.featured_products":   "精选产品",
 	"homepage.top_sales_stores":   "热销店铺",
 	"homepage.top_downloads_stores": "热门下载店铺",
 	"homepage.top_downloads_products": "热门下载产品",
 	"homepage.top_sales_products": "热销产品",
+	"homepage.trending_products": "近期热门",
 	"homepage.download_windows":   "Windows 下载",
 	"homepage.download_macos":     "macOS 下载",
 	"homepage.user_center":        "用户中心",
@@ -829,12 +1055,33 @@ var zhCN = map[string]string{
 	// Storefront
 	"stat_packs":              "分析包",
 	"stat_featured":           "推荐",
+	"stat_followers":        "关注",
+	"follow_store":          "关注小铺",
+	"hero_cta_default":      "浏览全部分析包",
+	"following_store":       "已关注",
+	"follow_failed":         "操作失败",
 	"featured_packs":          "店主推荐",
 	"filter_all":              "全部",
 	"sort_revenue":            "按销售金额",
 	"sort_downloads":          "按下载量",
 	"sort_orders":             "按订单数",
 	"storefront_empty":        "该小铺暂无分析包",
+	"store_paused_title":    "小铺暂停营业中",
+	"store_paused_desc":     "店主暂时关闭了小铺，目前不接受购买或领取。已购买的分析包仍可正常使用，欢迎稍后再来。",
+	"verified_seller":       "认证卖家",
+	"verified_seller_hint":  "该卖家已通过平台认证",
+	"store_paused_btn":      "暂停营业",
+	"sold_out":              "已售罄",
+	"store_policies":        "店铺政策",
+	"refund_policy":         "退款政策",
+	"store_terms":           "服务条款",
+	"store_contact":         "联系方式",
+	"back_to_store":         "返回小铺",
+	"screenshots":           "截图",
+	"screenshots_hint":      "最多 6 张，支持 PNG、JPEG、WebP，单张不超过 2MB。第一张将优先展示。",
+	"upload_screenshot":     "上传截图",
+	"confirm_delete_screenshot": "确定要删除这张截图吗？",
+	"upload_failed":         "上传失败",
 	"storefront_manage":       "店铺管理",
 	"search_packs":            "搜索分析包...",
 	"sub_duration":            "订阅时长",
@@ -846,6 +1093,14 @@ var zhCN = map[string]string{
 	"featured_stores_mgmt":    "明星店铺管理",
 	"featured_stores_hint":    "设置在首页展示的明星店铺",
 	"no_featured_stores":      "暂无明星店铺",
+	"featured_products_mgmt": "精选产品管理",
+	"featured_products_hint": "设置在首页展示的精选产品",
+	"no_featured_products": "暂无精选产品",
+	"no_matching_products": "没有找到匹配的分析包",
+	"search_product_placeholder": "搜索分析包名称或作者...",
+	"confirm_remove_featured_product": "确定要移除该精选产品吗？",
+	"featured_product_added": "已添加为精选产品",
+	"featured_product_removed": "已移除精选产品",
 	"no_matching_stores":      "没有找到匹配的店铺",
 	"confirm_remove_featured": "确定要移除该明星店铺吗？",
 	"featured_added":          "已添加为明星店铺",
@@ -861,6 +1116,10 @@ var zhCN = map[string]string{
 	"account_mgmt":            "账号管理",
 	"account_detail":          "账号详情",
 	"billing_mgmt":            "帐单管理",
+	"kyc_mgmt":                "实名审核",
+	"impersonate_mgmt":        "模拟登录",
+	"backup_mgmt":             "数据库备份",
+	"impersonate_confirm":     "确定要以该用户身份登录吗？此操作将被记录。",
 	"review_packs":            "审核分析包",
 	"review_custom_products":  "审核自定义商品",
 	"reject_custom_product":   "拒绝自定义商品",
@@ -877,6 +1136,9 @@ var zhCN = map[string]string{
 
 	// Custom products
 	"custom_products":         "自定义商品",
+	"charged_in":            "结算金额",
+	"tax_inclusive_total":   "含税合计",
+	"tax_included":          "含税",
 	"product_type_credits":    "积分充值",
 	"product_type_virtual":    "虚拟商品",
 	"purchase_confirm":        "购买确认",
@@ -895,6 +1157,7 @@ var zhCN = map[string]string{
 	"cp_status_paid":          "已支付",
 	"cp_status_fulfilled":     "已完成",
 	"cp_status_failed":        "失败",
+	"download_receipt":        "🧾 收据",
 	"cp_no_orders":            "暂无购买记录",
 
 	// Storefront manage
@@ -902,6 +1165,7 @@ var zhCN = map[string]string{
 	"sm_tab_settings":         "⚙️ 小铺设置",
 	"sm_tab_packs":            "📦 分析包管理",
 	"sm_tab_notify":           "📧 客户通知",
+	"sm_tab_analytics":      "📈 数据分析",
 	"sm_tab_custom_products":  "🛍️ 自定义商品",
 	"sm_basic_info":           "基本信息",
 	"sm_store_name":           "小铺名称",
@@ -911,7 +1175,7 @@ var zhCN = map[string]string{
 	"sm_store_desc_ph":        "介绍一下你的小铺...",
 	"sm_save_settings":        "💾 保存设置",
 	"sm_logo_settings":        "Logo 设置",
-	"sm_logo_hint":            "支持 PNG 或 JPEG 格式，文件大小不超过 2MB，也可直接 Ctrl+V 粘贴图片",
+	"sm_logo_hint":            "支持 PNG、JPEG 或 WebP 格式，文件大小不超过 2MB，也可直接 Ctrl+V 粘贴图片",
 	"sm_upload_logo":          "📤 上传 Logo",
 	"sm_store_link":           "小铺链接",
 	"sm_store_slug":           "小铺标识（Store Slug）",
@@ -956,6 +1220,17 @@ var zhCN = map[string]string{
 	"sm_add_banner":           "+ 添加横幅",
 	"sm_save_layout":          "💾 保存布局",
 	"sm_preview":              "👁️ 预览",
+	"sm_layout_presets":       "快速套用布局模板",
+	"sm_apply_preset":         "套用",
+	"sm_apply_preset_confirm": "套用模板将替换当前的页面布局（包括自定义横幅），确定继续吗？",
+	"sm_preset_classic":       "经典",
+	"sm_preset_classic_desc":  "横幅、推荐、筛选栏和双列列表",
+	"sm_preset_minimal":       "极简",
+	"sm_preset_minimal_desc":  "只保留横幅和单列列表",
+	"sm_preset_showcase":      "橱窗",
+	"sm_preset_showcase_desc": "突出推荐区，适合主打少量精品",
+	"sm_preset_grid_heavy":    "网格",
+	"sm_preset_grid_heavy_desc": "筛选栏加三列列表，适合商品较多的小铺",
 
 	// 客户支持
 	"customer_support":        "客户支持",
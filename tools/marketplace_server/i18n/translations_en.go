@@ -37,9 +37,72 @@ var enUS = map[string]string{
 	// User Dashboard
 	"email":                  "Email",
 	"credits_balance":        "Credits Balance",
+	"credits_expire_on":      "credits expire on",
+	"gift_credits":           "Gift Credits",
+	"mark_all_read":          "Mark all as read",
+	"gift_credits_limits":    "Minimum per gift / daily limit",
+	"gift_credits_email":     "Recipient email",
+	"gift_credits_amount":    "Amount",
+	"gift_credits_message":   "Message (optional)",
+	"gift_credits_send":      "Send Gift",
+	"gift_credits_confirm":   "Send this gift?",
+	"gift_amount_too_small":  "The amount is below the minimum gift",
+	"gift_message_too_long":  "The message may be at most 200 characters",
+	"gift_cannot_self":       "You cannot gift credits to yourself",
+	"gift_daily_limit_exceeded": "This exceeds your daily gift limit",
+	"insufficient_credits":   "Not enough credits",
 	"change_password":        "Change Password",
 	"set_password":           "Set Password",
 	"billing_records":        "Billing Records",
+	"export_my_data":         "📦 Export my data",
+	"data_export_limited":    "Data exports are limited to one per hour. Please try again in %d minutes",
+	"data_export_failed":     "Data export failed, please try again later",
+	"delete_account":         "🗑️ Delete account",
+	"acct_delete_title":      "Delete Account",
+	"acct_delete_intro":      "This cannot be undone. Enter your password to confirm it is you",
+	"acct_delete_warn":       "Your profile, payout details and identity documents will be erased, and your storefront and packs taken down. Transaction and withdrawal records are kept in anonymized form for accounting.",
+	"acct_delete_shared":     "Other accounts use your email, so the wallet balance stays with them.",
+	"acct_delete_balance":    "Your wallet still holds %.2f credits. They will be forfeited and cannot be recovered.",
+	"acct_delete_agree":      "I understand my wallet balance will be forfeited",
+	"acct_delete_phrase":     "Type %s to confirm",
+	"acct_delete_button":     "Permanently delete my account",
+	"acct_delete_confirm":    "Permanently delete your account? This cannot be undone.",
+	"acct_delete_forfeit":    "Please confirm that you forfeit your wallet balance",
+	"acct_delete_pending":    "You have a pending withdrawal. Please wait until it is processed before deleting your account",
+	"acct_delete_failed":     "Failed to delete the account, please try again later",
+	"acct_delete_done":       "Your account has been deleted. Thank you for having used our service.",
+	"linked_emails":          "✉️ Linked emails",
+	"linked_emails_title":    "Linked Emails",
+	"linked_emails_intro":    "Link your other emails to see and spend the credits in their wallets together. Purchases draw from the primary email first.",
+	"combined_balance":       "Combined balance",
+	"primary_email":          "Primary",
+	"pending_verify":         "Pending",
+	"set_primary_email":      "Make primary",
+	"unlink_email":           "Unlink",
+	"confirm_unlink_email":   "Unlink this email? Its wallet balance will no longer count towards this account.",
+	"link_email_label":       "Add an email",
+	"send_link_email":        "Send verification email",
+	"email_link_own":         "This is your sign-in email and is already part of your account",
+	"email_link_taken":       "This email belongs to another account and cannot be linked",
+	"email_not_linked":       "This email is not linked to your account",
+	"email_unlink_primary":   "Make another email primary first",
+	"email_link_done":        "Email linked, its balance is now included",
+	"email_link_subject":     "Confirm linking your email",
+	"email_link_body":        "Hello,\r\n\r\nAn account asked to link this email address. If this was you, click the link below to confirm:\r\n%s\r\n\r\nThe link is valid for %d hours. If it was not you, please ignore this email.\r\n",
+	"change_email":           "Change email",
+	"change_email_title":     "Change Sign-in Email",
+	"change_email_subtitle":  "Once the new address is confirmed, your account and wallet balance move to it",
+	"current_email":          "Current email",
+	"new_email_label":        "New email",
+	"email_change_same":      "The new email is the same as the current one",
+	"email_change_sent":      "A confirmation email was sent to %s. Click the link in it to complete the change",
+	"email_change_done":      "Your email was changed and your wallet balance moved to the new address",
+	"email_change_subject":   "Confirm your new sign-in email",
+	"email_change_body":      "Hello,\r\n\r\nAn account asked to change its sign-in email to this address. If this was you, click the link below to confirm:\r\n%s\r\n\r\nThe link is valid for %d hours. If it was not you, please ignore this email.\r\n",
+	"email_changed_notice":   "Hello,\r\n\r\nThe sign-in email of your account was changed to %s and your wallet balance moved with it.\r\n\r\nIf you did not do this, please contact support immediately.\r\n",
+	"impersonation_banner":   "You are viewing as <b>%s</b> (ID %d) via admin impersonation; withdrawals, payment info and account security changes are disabled",
+	"impersonation_exit":     "Exit impersonation",
+	"impersonation_denied":   "This action is not allowed while impersonating",
 	"payment_settings":       "Payment Settings",
 	"topup":                  "Top Up",
 	"topup_coming_soon":      "Coming soon",
@@ -67,6 +130,13 @@ var enUS = map[string]string{
 	"subscription_expires":   "Subscription expires: ",
 	"expires_at":             "Expires: ",
 	"renew":                  "Renew",
+	"pack_expired":          "Expired",
+	"renew_access":          "Extend Access",
+	"renew_access_confirm":  "Pay credits to extend access to this pack?",
+	"auto_renew_paypal":     "Auto-renew (PayPal)",
+	"cancel_auto_renew":     "Cancel Auto-renew",
+	"auto_renew_on":         "Auto-renewing",
+	"cancel_auto_renew_confirm": "Cancel auto-renewal? You keep access until the paid period ends.",
 	"author_panel":           "Author Panel",
 	"actual_revenue":         "Actual Revenue Credits ({pct}% split)",
 	"unwithdrawn_credits":    "Unwithdrawn Credits",
@@ -85,6 +155,27 @@ var enUS = map[string]string{
 	"published":              "Published",
 	"rejected":               "Rejected",
 	"delisted":               "Delisted",
+	"scheduled":             "Scheduled",
+	"quarantined":            "Quarantined",
+	"review_quarantine":      "Quarantined packs",
+	"release_quarantine":     "Release",
+	"no_quarantined_packs":   "No quarantined packs",
+	"confirm_release":        "Release this pack from quarantine and send it to review?",
+	"scan_result_col":        "Scan result",
+	"schedule_publish":      "Schedule release",
+	"schedule_publish_hint": "Once approved, the pack goes live automatically at this time and stays hidden until then.",
+	"cancel_schedule":       "Clear schedule",
+	"publish_now":           "Publish now",
+	"scheduled_publish_at":  "Scheduled release",
+	"flash_sale":            "Flash sale",
+	"sale_ends_in":          "Ends in",
+	"sale_ended":            "Sale ended",
+	"sale_price":            "Sale price",
+	"sale_start":            "Starts",
+	"sale_end":              "Ends",
+	"set_flash_sale":        "Set flash sale",
+	"clear_flash_sale":      "Remove sale",
+	"flash_sale_hint":       "The sale price must be below the regular price and applies only within the window (up to 30 days)",
 	"details":                "Details",
 	"delist":                 "Delist",
 	"copy_share_link":        "Copy Share Link",
@@ -201,7 +292,7 @@ var enUS = map[string]string{
 	"withdraw_failed":        "Withdrawal failed",
 	"enter_valid_amount":     "Please enter a valid withdrawal amount",
 	"exceeds_balance":        "Withdrawal amount exceeds available balance",
-	"below_minimum":          "Net amount after fees is below minimum withdrawal of 100 CNY",
+	"below_minimum":          "Net amount after fees is below minimum withdrawal of {min} CNY",
 	"net_amount":             "Net Amount",
 	"fee":                    "Fee",
 	"withdraw_amount_label":  "Withdrawal Amount",
@@ -276,7 +367,8 @@ var enUS = map[string]string{
 	"err_invalid_withdraw_amount": "Invalid withdrawal amount.",
 	"err_withdraw_disabled":      "Withdrawal is not available.",
 	"err_withdraw_exceeds":       "Withdrawal amount exceeds available balance.",
-	"err_withdraw_below_min":     "Net amount after fees is below minimum withdrawal of 100 CNY.",
+	"err_withdraw_wallet":        "Withdrawal amount exceeds your wallet balance.",
+	"err_withdraw_below_min":     "Net amount after fees is below the minimum withdrawal.",
 	"err_system":                 "System error, please try again later.",
 
 	// User Login
@@ -291,6 +383,73 @@ var enUS = map[string]string{
 	"no_account":             "No account? Bind user",
 	"captcha_error":          "Captcha verification failed",
 	"login_error":            "Invalid username or password",
+	"account_blocked":        "This account has been disabled",
+	"account_blocked_reason": "Reason: %s",
+	"account_blocked_until":  "Blocked until %s",
+	"oauth_failed":           "Third-party sign-in failed, please try again",
+	"oauth_email_unverified":  "This email belongs to another account, but the provider has not verified it, so sign-in was refused",
+	"oauth_login_google":     "Sign in with Google",
+	"oauth_login_github":     "Sign in with GitHub",
+	"oauth_divider":          "or",
+	"forgot_password_link":   "Forgot password?",
+	"forgot_password_title":  "Forgot Password",
+	"forgot_password_subtitle":  "Enter your account email and we'll send you a reset link",
+	"send_reset_link":        "Send Reset Link",
+	"reset_link_sent":        "If this email has a login password, a reset link has been sent. Please check your inbox (the link is valid for 30 minutes).",
+	"reset_email_subject":    "Reset your login password",
+	"reset_email_body":       "Hello,\r\n\r\nWe received a request to reset your login password. Click the link below to set a new password:\r\n%s\r\n\r\nThe link is valid for %d minutes and can only be used once. If you did not request this, please ignore this email.\r\n",
+	"order_email_fulfilled_subject": "Order #%d completed",
+	"order_email_license_body": "Hello,\r\n\r\nYour purchase \"%s\" has been delivered.\r\nLicense SN: %s\r\nBound email: %s\r\nOrder: #%d\r\n\r\nThank you for your purchase!\r\n",
+	"order_email_credits_body": "Hello,\r\n\r\nYour purchase \"%s\" is complete and %d credits have been added to your account.\r\nOrder: #%d\r\n\r\nThank you for your purchase!\r\n",
+	"order_email_failed_subject": "Payment for order #%d was not completed",
+	"order_email_failed_body": "Hello,\r\n\r\nThe payment for \"%s\" (%s), order #%d, could not be completed. You have not been charged; please place a new order if you still wish to buy.\r\n\r\nIf you have any questions, please contact us.\r\n",
+	"order_email_gift_credits_body": "Hello,\r\n\r\nYour gift \"%[2]s\" for %[1]s has been delivered and %[3]d credits have been added to their wallet.\r\nOrder: #%[4]d\r\n\r\nThank you for your purchase!\r\n",
+	"gift_email_subject": "You received a gift: \"%s\"",
+	"gift_email_credits_body": "Hello,\r\n\r\n%s bought you \"%s\": %d credits have been added to the wallet of this email address (%s).\r\nIf you don't have an account yet, sign up or log in to the marketplace with this email to use them.\r\n\r\nEnjoy!\r\n",
+	"gift_email_license_body": "Hello,\r\n\r\n%s bought you \"%s\" and the license has been bound to this email address.\r\nLicense SN: %s\r\nBound email: %s\r\n\r\nEnjoy!\r\n",
+	"credits_gift_email_subject": "You received %d credits",
+	"credits_gift_email_body": "Hello,\r\n\r\n%s sent you %d credits. They have been added to the wallet of this email address (%s).\r\nIf you don't have an account yet, sign up or log in to the marketplace with this email to use them.\r\n",
+	"credits_gift_email_message": "\r\nMessage: %s\r\n",
+	"gift_recipient_email":  "Gift to (optional)",
+	"gift_recipient_hint":   "Enter their email to send this as a gift; they don't need an account yet. Leave empty to buy for yourself.",
+	"topup_tier":            "Top-up tier",
+	"topup_tier_hint":       "Larger tiers include bonus credits; the rate in brackets is credits per US dollar.",
+	"reset_password_title":   "Reset Password",
+	"reset_token_invalid":    "This reset link is invalid or has expired",
+	"request_new_link":       "Request a new reset link",
+	"reset_failed":           "Password reset failed, please try again later",
+	"reset_password_done":    "Your password has been reset. Please log in with your new password.",
+	"back_to_login":          "Back to login",
+	"unsubscribe_email_footer": "Don't want these emails? Unsubscribe: %s\r\n",
+	"follow_email_subject":  "%s published a new pack: %s",
+	"follow_email_body":     "Hello,\r\n\r\nThe store you follow, \"%s\", has published a new pack: \"%s\".\r\nView it here: %s\r\n",
+	"unsubscribe_title":     "Unsubscribe from store emails",
+	"unsubscribe_confirm":   "Stop emailing %s about updates from “%s”?",
+	"unsubscribe_button":    "Unsubscribe",
+	"unsubscribe_done":      "You have been unsubscribed and will no longer receive notifications from this store.",
+	"unsubscribe_invalid":   "This unsubscribe link is invalid or has expired",
+	"unsubscribe_failed":    "Unsubscribe failed, please try again later",
+	"storefront_email_visit_store": "\r\n\r\n---\r\nVisit the store: %s\r\n",
+	"notify_tpl_version_update_name": "Version update promotion",
+	"notify_tpl_version_update_subject": "[{{.StoreName}}] Analysis pack update",
+	"notify_tpl_version_update_body": "Dear customer,\n\nThe analysis packs from {{.StoreName}} have been updated to version {{.Version}}.\n\nWhat's new:\n{{.UpdateContent}}\n\nPromotion:\n{{.PromoInfo}}\n\nThank you for your support!",
+	"notify_tpl_holiday_promo_name": "Holiday promotion",
+	"notify_tpl_holiday_promo_subject": "[{{.StoreName}}] Holiday sale",
+	"notify_tpl_holiday_promo_body": "Dear customer,\n\nTo celebrate {{.HolidayName}}, {{.StoreName}} is running a limited-time sale.\n\nWhen: {{.PromoTime}}\nOffer: {{.PromoContent}}\n\nHappy holidays!",
+	"notify_tpl_flash_promo_name": "Flash sale",
+	"notify_tpl_flash_promo_subject": "[{{.StoreName}}] Limited-time sale",
+	"notify_tpl_flash_promo_body": "Dear customer,\n\n{{.StoreName}} is running a limited-time sale.\n\nOccasion: {{.PromoReason}}\nWhen: {{.PromoTime}}\nOffer: {{.PromoContent}}\n\nDon't miss out!",
+	"email_verify_required":  "Please verify your email before doing this.",
+	"email_verify_prompt":    "⚠️ Your email is not verified. Verification is required before withdrawing or applying for customer support.",
+	"email_verify_send_btn":  "Send verification email",
+	"email_verify_sent":      "Verification email sent, please check your inbox",
+	"email_verified_success":  "✅ Your email has been verified.",
+	"email_verify_invalid":   "⚠️ The verification link is invalid or has expired. Please send a new one.",
+	"email_verify_no_email":  "No email is bound to this account",
+	"email_verify_cooldown":  "Too many requests, please retry in %d seconds",
+	"email_verify_send_failed":  "Failed to send the verification email, please try again later",
+	"email_verify_subject":   "Verify your email address",
+	"email_verify_body":      "Hello,\r\n\r\nPlease click the link below to verify your email address:\r\n%s\r\n\r\nThe link is valid for %d hours. If you did not request this, please ignore this email.\r\n",
 
 	// User Register
 	"bind_register":          "Bind & Register",
@@ -357,6 +516,20 @@ var enUS = map[string]string{
 	"data_source":            "Data Source",
 	"category":               "Category",
 	"downloads":              "Downloads",
+	"related_packs":          "Related packs",
+	"same_author":            "Same author",
+	"bundle_offers":          "Bundle deals",
+	"bundle_includes":        "This bundle includes",
+	"bundle_list_total":      "Bought separately",
+	"bundle_you_save":        "You save",
+	"bundle_buy":             "Buy bundle",
+	"bundle_buy_confirm":     "Buy this bundle?",
+	"bundle_own":             "This is your bundle",
+	"bundle_unavailable":     "Bundle unavailable",
+	"bundle_one_use":         "1 use",
+	"bundle_one_month":       "1 month subscription",
+	"bundle_owned_note":      "%d pack(s) you already own are not listed; they are still included with the purchase",
+	"bundle_packs_count":     "packs",
 	"share":                  "Share",
 	"link_copied":            "Link copied",
 	"share_storefront":       "🏪 Share Store",
@@ -466,9 +639,13 @@ var enUS = map[string]string{
 	"fee_rate_us":              "US Bank Card Fee Rate (%)",
 	"fee_rate_eu":              "EU Bank Card Fee Rate (%)",
 	"fee_rate_cn":              "China Bank Card Fee Rate (%)",
+	"min_withdrawal_label":     "Minimum Withdrawal (CNY, after fees)",
+	"fee_cap_label":            "Fee Cap per Withdrawal (CNY, 0 = no cap)",
+	"fee_capped":               "fee cap reached",
 	"save_fee_settings":        "Save Fee Settings",
 	"export_excel":             "Export Excel",
 	"export_and_approve":       "Export & Mark Paid",
+	"export_withdraw_report":   "Export Reconciliation",
 	"batch_approve":            "Batch Mark Paid",
 	"filter_by_author":         "Filter by author",
 	"applied_withdraw":         "Applied",
@@ -516,6 +693,11 @@ var enUS = map[string]string{
 	"load_pending_failed":     "Failed to load pending list",
 	"enter_reject_reason":     "Please enter rejection reason",
 	"rejected_done":           "Rejected",
+	"bulk_approve":          "Approve selected",
+	"bulk_reject":           "Reject selected",
+	"bulk_review_done":      "{updated} processed, {skipped} skipped",
+	"select_packs_first":    "Select packs first",
+	"confirm_bulk_approve":  "Approve the {n} selected packs?",
 	"marketplace_delisted":    "Marketplace - Delisted Packs",
 	"marketplace_listed":      "Marketplace - Listed Packs",
 	"no_delisted_packs":       "No delisted packs",
@@ -543,6 +725,8 @@ var enUS = map[string]string{
 	"topup_success":           "Top up successful, new balance:",
 	"topup_failed":            "Top up failed",
 	"confirm_block":           "Are you sure you want to {action} customer \"{name}\"?",
+	"block_reason_prompt":     "Block reason (shown to the user, optional):",
+	"block_duration_prompt":   "Block duration in hours (0 or empty = permanent):",
 	"blocked_done":            "Blocked",
 	"unblocked_done":          "Unblocked",
 	"no_transactions_admin":   "No transactions",
@@ -587,6 +771,21 @@ var enUS = map[string]string{
 	"effective_date":          "Effective Date (optional, leave empty for immediate)",
 	"display_duration":        "Display Duration",
 	"days_0_permanent":        "days (0=permanent)",
+	"recurrence":              "Repeat",
+	"targeted_segment":        "Targeted (by purchase history)",
+	"segment_hint":            "Users matching all of the criteria below when the message is sent receive it. Empty criteria match everyone.",
+	"segment_listing":         "Bought pack ID",
+	"segment_storefront":      "Bought from store ID",
+	"segment_min_spend":       "Total spend at least (credits)",
+	"segment_estimate":        "Estimate recipients",
+	"segment_count":           "About {count} users",
+	"recurrence_none":         "Does not repeat",
+	"recurrence_daily":        "Daily",
+	"recurrence_weekly":       "Weekly",
+	"recurrence_monthly":      "Monthly",
+	"recurrence_end":          "Repeat until",
+	"recurrence_hint":         "A repeating message may be displayed for at most one period (1 day daily, 7 days weekly, 28 days monthly). Users who have read it are not shown it as unread again.",
+	"preview_occurrences":     "Preview schedule",
 	"message_title":           "Message Title",
 	"message_content":         "Message Content",
 	"message_type":            "Message Type",
@@ -709,6 +908,19 @@ var enUS = map[string]string{
 	"admin_password_mismatch_err": "Passwords do not match",
 	"captcha_error_admin":     "Captcha verification failed",
 	"admin_login_error":       "Invalid username or password",
+	"admin_login_locked":      "Too many login attempts. Please try again later.",
+	"admin_totp_code":         "Two-factor code (leave blank if not enabled)",
+	"admin_2fa_title":         "Two-Factor Authentication",
+	"admin_2fa_scan_hint":     "Scan the QR code with an authenticator app or enter the secret manually, then enter the 6-digit code to enable.",
+	"admin_2fa_secret":        "Secret",
+	"admin_2fa_enable":        "Enable",
+	"admin_2fa_disable":       "Disable",
+	"admin_2fa_setup":         "Set up two-factor",
+	"admin_2fa_on":            "Enabled",
+	"admin_2fa_off":           "Not enabled",
+	"admin_2fa_recovery_left": "Recovery codes left",
+	"admin_2fa_recovery_hint": "Save these recovery codes somewhere safe. Each works once and they will not be shown again.",
+	"admin_2fa_code_prompt":   "Enter a code or recovery code",
 	"load_data_failed":        "Failed to load data",
 	"load_billing_failed":     "Failed to load billing data",
 	"invalid_pack_link":       "Invalid pack link",
@@ -719,8 +931,10 @@ var enUS = map[string]string{
 	"withdraw_not_author":     "Only authors can request withdrawals",
 	"withdraw_invalid_amount": "Invalid withdrawal amount",
 	"withdraw_not_open":       "Withdrawal is not available",
+	"withdraw_kyc_required":   "Withdrawals above the limit require approved identity verification (KYC)",
 	"withdraw_exceeds":        "Withdrawal amount exceeds available balance",
-	"withdraw_below_min_net":  "Net amount after fees is below minimum withdrawal of 100 CNY",
+	"withdraw_exceeds_wallet": "Withdrawal amount exceeds your wallet balance",
+	"withdraw_below_min_net":  "Net amount after fees is below minimum withdrawal of %s CNY",
 
 	// Excel export headers (withdrawal)
 	"excel_withdraw_sheet":    "Withdrawal Records",
@@ -738,6 +952,16 @@ var enUS = map[string]string{
 	"excel_bank_card_us":      "US Bank Card",
 	"excel_bank_card_eu":      "EU Bank Card",
 	"excel_bank_card_cn":      "CN Bank Card",
+	"excel_withdraw_id":       "Withdrawal ID",
+	"excel_withdraw_credits":  "Credits",
+	"excel_cash_rate":         "Cash Rate",
+	"excel_status":            "Status",
+	"excel_requested_at":      "Requested At",
+	"excel_paid_at":           "Paid At",
+	"excel_status_pending":    "Requested",
+	"excel_status_paid":       "Paid",
+	"excel_total":             "Total",
+	"excel_withdraw_count":    "%d withdrawals",
 
 	// Excel export headers (sales)
 	"excel_order_sheet":       "Order Details",
@@ -807,10 +1031,12 @@ var enUS = map[string]string{
 	"homepage.title":              "Analysis Pack Marketplace",
 	"homepage.hero_desc":          "Stand on experts' shoulders, unlock business insights",
 	"homepage.featured_stores":    "Featured Stores",
+	"homepage.featured_products":   "Featured Products",
 	"homepage.top_sales_stores":   "Top Sales Stores",
 	"homepage.top_downloads_stores": "Top Downloads Stores",
 	"homepage.top_downloads_products": "Top Downloaded Products",
 	"homepage.top_sales_products": "Top Selling Products",
+	"homepage.trending_products": "Trending Now",
 	"homepage.download_windows":   "Windows Download",
 	"homepage.download_macos":     "macOS Download",
 	"homepage.user_center":        "My Account",
@@ -824,12 +1050,33 @@ var enUS = map[string]string{
 	// Storefront
 	"stat_packs":              "Packs",
 	"stat_featured":           "Featured",
+	"stat_followers":        "Followers",
+	"follow_store":          "Follow",
+	"hero_cta_default":      "Browse all packs",
+	"following_store":       "Following",
+	"follow_failed":         "Action failed",
 	"featured_packs":          "Featured Picks",
 	"filter_all":              "All",
 	"sort_revenue":            "By Revenue",
 	"sort_downloads":          "By Downloads",
 	"sort_orders":             "By Orders",
 	"storefront_empty":        "This store has no packs yet",
+	"store_paused_title":    "This store is temporarily closed",
+	"store_paused_desc":     "The owner has paused this store, so purchases and claims are unavailable for now. Packs you already own keep working. Please check back later.",
+	"verified_seller":       "Verified seller",
+	"verified_seller_hint":  "This seller has been verified by the marketplace",
+	"store_paused_btn":      "Temporarily closed",
+	"sold_out":              "Sold out",
+	"store_policies":        "Store policies",
+	"refund_policy":         "Refund policy",
+	"store_terms":           "Terms of service",
+	"store_contact":         "Contact",
+	"back_to_store":         "Back to store",
+	"screenshots":           "Screenshots",
+	"screenshots_hint":      "Up to 6 images (PNG, JPEG or WebP, max 2MB each). The first one is shown first.",
+	"upload_screenshot":     "Upload screenshot",
+	"confirm_delete_screenshot": "Delete this screenshot?",
+	"upload_failed":         "Upload failed",
 	"storefront_manage":       "Store Management",
 	"search_packs":            "Search packs...",
 	"sub_duration":            "Subscription Duration",
@@ -841,6 +1088,14 @@ var enUS = map[string]string{
 	"featured_stores_mgmt":    "Featured Stores Management",
 	"featured_stores_hint":    "Set featured stores displayed on the homepage",
 	"no_featured_stores":      "No featured stores",
+	"featured_products_mgmt": "Featured Products Management",
+	"featured_products_hint": "Set featured products displayed on the homepage",
+	"no_featured_products": "No featured products",
+	"no_matching_products": "No matching packs found",
+	"search_product_placeholder": "Search by pack name or author...",
+	"confirm_remove_featured_product": "Remove this featured product?",
+	"featured_product_added": "Added to featured products",
+	"featured_product_removed": "Removed from featured products",
 	"no_matching_stores":      "No matching stores found",
 	"confirm_remove_featured": "Are you sure you want to remove this featured store?",
 	"featured_added":          "Added as featured store",
@@ -856,6 +1111,10 @@ var enUS = map[string]string{
 	"account_mgmt":            "Account Management",
 	"account_detail":          "Account Details",
 	"billing_mgmt":            "Billing Management",
+	"kyc_mgmt":                "KYC Review",
+	"impersonate_mgmt":        "Impersonate",
+	"backup_mgmt":             "Database Backup",
+	"impersonate_confirm":     "Sign in as this user? This will be audit-logged.",
 	"review_packs":            "Review Packs",
 	"review_custom_products":  "Review Custom Products",
 	"reject_custom_product":   "Reject Custom Product",
@@ -872,6 +1131,9 @@ var enUS = map[string]string{
 
 	// Custom products
 	"custom_products":         "Custom Products",
+	"charged_in":            "Charged as",
+	"tax_inclusive_total":   "Total incl. tax",
+	"tax_included":          "Includes tax",
 	"product_type_credits":    "Credits Top-Up",
 	"product_type_virtual":    "Virtual Goods",
 	"purchase_confirm":        "Purchase Confirmation",
@@ -890,6 +1152,7 @@ var enUS = map[string]string{
 	"cp_status_paid":          "Paid",
 	"cp_status_fulfilled":     "Fulfilled",
 	"cp_status_failed":        "Failed",
+	"download_receipt":        "🧾 Receipt",
 	"cp_no_orders":            "No orders yet",
 
 	// Storefront manage
@@ -897,6 +1160,7 @@ var enUS = map[string]string{
 	"sm_tab_settings":         "⚙️ Store Settings",
 	"sm_tab_packs":            "📦 Pack Management",
 	"sm_tab_notify":           "📧 Customer Notifications",
+	"sm_tab_analytics":      "📈 Analytics",
 	"sm_tab_custom_products":  "🛍️ Custom Products",
 	"sm_basic_info":           "Basic Info",
 	"sm_store_name":           "Store Name",
@@ -906,7 +1170,7 @@ var enUS = map[string]string{
 	"sm_store_desc_ph":        "Describe your store...",
 	"sm_save_settings":        "💾 Save Settings",
 	"sm_logo_settings":        "Logo Settings",
-	"sm_logo_hint":            "PNG or JPEG format, max 2MB. You can also paste with Ctrl+V",
+	"sm_logo_hint":            "PNG, JPEG or WebP format, max 2MB. You can also paste with Ctrl+V",
 	"sm_upload_logo":          "📤 Upload Logo",
 	"sm_store_link":           "Store Link",
 	"sm_store_slug":           "Store Slug",
@@ -951,6 +1215,17 @@ var enUS = map[string]string{
 	"sm_add_banner":           "+ Add Banner",
 	"sm_save_layout":          "💾 Save Layout",
 	"sm_preview":              "👁️ Preview",
+	"sm_layout_presets":       "Quick-start layout templates",
+	"sm_apply_preset":         "Apply",
+	"sm_apply_preset_confirm": "Applying a template replaces your current page layout (including custom banners). Continue?",
+	"sm_preset_classic":       "Classic",
+	"sm_preset_classic_desc":  "Banner, featured, filter bar and a two-column list",
+	"sm_preset_minimal":       "Minimal",
+	"sm_preset_minimal_desc":  "Just the banner and a single-column list",
+	"sm_preset_showcase":      "Showcase",
+	"sm_preset_showcase_desc": "Puts featured packs first, for stores with a few highlights",
+	"sm_preset_grid_heavy":    "Grid",
+	"sm_preset_grid_heavy_desc": "Filter bar and a three-column list, for large catalogs",
 
 	// Customer Support
 	"customer_support":        "Customer Support",
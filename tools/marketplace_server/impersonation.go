@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"marketplace_server/i18n"
+)
+
+// Support staff with the "impersonate" permission can open a short-lived user
+// session for a customer to reproduce what they see. Such a session carries
+// the admin's ID, which userAuth exposes as X-Impersonator-ID; every page
+// shows a banner with an exit button, and denyWhileImpersonating guards the
+// actions that move money or change credentials. The data export is refused
+// outright by denyAllWhileImpersonating.
+const impersonationTTL = 30 * time.Minute
+
+// createImpersonationSession creates a user session for userID on behalf of adminID.
+func createImpersonationSession(adminID, userID int64) string {
+	id := generateSessionID()
+	userSessionsMu.Lock()
+	userSessions[id] = userSessionEntry{UserID: userID, Expiry: time.Now().Add(impersonationTTL), ImpersonatorID: adminID}
+	userSessionsMu.Unlock()
+	return id
+}
+
+// getUserSessionImpersonator returns the admin ID behind a valid
+// impersonation session, or 0 for an ordinary session.
+func getUserSessionImpersonator(id string) int64 {
+	userSessionsMu.RLock()
+	entry, ok := userSessions[id]
+	userSessionsMu.RUnlock()
+	if !ok || time.Now().After(entry.Expiry) {
+		return 0
+	}
+	return entry.ImpersonatorID
+}
+
+// logImpersonationEnd records the end of an impersonation session.
+func logImpersonationEnd(adminID, userID int64, reason, ip string) {
+	log.Printf("[IMPERSONATE] admin %d stopped impersonating user %d (%s)", adminID, userID, reason)
+	recordAuditLog(adminID, "impersonate_end", fmt.Sprintf("user:%d", userID), reason, ip)
+}
+
+// handleAdminImpersonate handles POST /api/admin/impersonate {"user_id": N}.
+// It sets the user_session cookie for the target user; the admin session is
+// untouched, so the admin portal stays logged in alongside.
+func handleAdminImpersonate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	var req struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的用户 ID"})
+		return
+	}
+	var displayName string
+	var isBlocked int
+	var deletedAt *string
+	err := db.QueryRow("SELECT COALESCE(display_name, ''), COALESCE(is_blocked, 0), deleted_at FROM users WHERE id = ?",
+		req.UserID).Scan(&displayName, &isBlocked, &deletedAt)
+	if err != nil || deletedAt != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "用户不存在"})
+		return
+	}
+	if isBlocked == 1 {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "该用户已被禁用，无法模拟登录"})
+		return
+	}
+
+	sid := createImpersonationSession(adminID, req.UserID)
+	http.SetCookie(w, makeSessionCookie("user_session", sid, int(impersonationTTL.Seconds())))
+	log.Printf("[IMPERSONATE] admin %d started impersonating user %d", adminID, req.UserID)
+	recordAuditLog(adminID, "impersonate_start", fmt.Sprintf("user:%d", req.UserID),
+		fmt.Sprintf("display_name=%s expires_in=%v", displayName, impersonationTTL), getClientIP(r))
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "redirect": "/user/"})
+}
+
+// handleImpersonationExit handles POST /user/impersonation/exit. It ends the
+// impersonation session and returns to the admin portal.
+func handleImpersonationExit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sid := getUserSessionFromRequest(r)
+	userSessionsMu.Lock()
+	entry, ok := userSessions[sid]
+	if ok && entry.ImpersonatorID != 0 {
+		delete(userSessions, sid)
+	}
+	userSessionsMu.Unlock()
+	if ok && entry.ImpersonatorID != 0 {
+		http.SetCookie(w, makeSessionCookie("user_session", "", -1))
+		logImpersonationEnd(entry.ImpersonatorID, entry.UserID, "exit", getClientIP(r))
+		http.Redirect(w, r, "/admin/", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/user/", http.StatusFound)
+}
+
+// denyWhileImpersonating refuses state-changing requests to next when the
+// session is an impersonation session. Pages can still be viewed.
+func denyWhileImpersonating(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Impersonator-ID") != "" && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			refuseImpersonation(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// denyAllWhileImpersonating refuses every request to next, reads included,
+// when the session is an impersonation session. It guards routes such as the
+// personal data export whose GET hands the user's data to the caller.
+func denyAllWhileImpersonating(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Impersonator-ID") != "" {
+			refuseImpersonation(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// refuseImpersonation writes the 403 for a request refused during impersonation.
+func refuseImpersonation(w http.ResponseWriter, r *http.Request) {
+	msg := i18n.T(i18n.DetectLang(r), "impersonation_denied")
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, msg, http.StatusForbidden)
+	} else {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": msg})
+	}
+}
+
+var bodyTagPattern = regexp.MustCompile(`(?i)<body[^>]*>`)
+
+// impersonationBannerWriter buffers HTML responses so the impersonation
+// banner can be inserted after <body>. Other content types pass through.
+type impersonationBannerWriter struct {
+	http.ResponseWriter
+	banner    []byte
+	buf       bytes.Buffer
+	status    int
+	decided   bool
+	buffering bool
+}
+
+func (bw *impersonationBannerWriter) decide() {
+	if bw.decided {
+		return
+	}
+	bw.decided = true
+	bw.buffering = strings.HasPrefix(bw.Header().Get("Content-Type"), "text/html")
+	if !bw.buffering {
+		if bw.status != 0 {
+			bw.ResponseWriter.WriteHeader(bw.status)
+		}
+	}
+}
+
+func (bw *impersonationBannerWriter) WriteHeader(status int) {
+	bw.status = status
+	bw.decide()
+}
+
+func (bw *impersonationBannerWriter) Write(p []byte) (int, error) {
+	bw.decide()
+	if bw.buffering {
+		return bw.buf.Write(p)
+	}
+	return bw.ResponseWriter.Write(p)
+}
+
+func (bw *impersonationBannerWriter) Flush() {
+	if !bw.buffering {
+		if f, ok := bw.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+// finish writes the buffered HTML with the banner inserted.
+func (bw *impersonationBannerWriter) finish() {
+	if !bw.buffering {
+		return
+	}
+	body := bw.buf.Bytes()
+	if loc := bodyTagPattern.FindIndex(body); loc != nil {
+		out := make([]byte, 0, len(body)+len(bw.banner))
+		out = append(out, body[:loc[1]]...)
+		out = append(out, bw.banner...)
+		body = append(out, body[loc[1]:]...)
+	}
+	bw.Header().Del("Content-Length")
+	if bw.status != 0 {
+		bw.ResponseWriter.WriteHeader(bw.status)
+	}
+	bw.ResponseWriter.Write(body)
+}
+
+// impersonationBanner renders the bar shown on every page of an
+// impersonation session.
+func impersonationBanner(lang i18n.Lang, userID int64) []byte {
+	var displayName string
+	db.QueryRow("SELECT COALESCE(display_name, '') FROM users WHERE id = ?", userID).Scan(&displayName)
+	text := fmt.Sprintf(i18n.T(lang, "impersonation_banner"), html.EscapeString(displayName), userID)
+	return []byte(`<div id="impersonation-banner" style="position:sticky;top:0;z-index:10000;background:#b91c1c;color:#fff;padding:8px 16px;font-size:14px;display:flex;align-items:center;justify-content:center;gap:12px;">` +
+		`<span>` + text + `</span>` +
+		`<form method="POST" action="/user/impersonation/exit" style="margin:0;"><button type="submit" style="background:#fff;color:#b91c1c;border:none;border-radius:4px;padding:4px 12px;cursor:pointer;font-weight:600;">` +
+		html.EscapeString(i18n.T(lang, "impersonation_exit")) + `</button></form></div>`)
+}
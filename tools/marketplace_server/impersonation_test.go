@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminImpersonation(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (7, 'email', 'a', 'Alice <x>', 'alice@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, is_blocked) VALUES (8, 'email', 'b', 'Bob', 'bob@example.com', 1)")
+
+	start := func(userID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/impersonate", strings.NewReader(fmt.Sprintf(`{"user_id":%d}`, userID)))
+		req.Header.Set("X-Admin-ID", "3")
+		rec := httptest.NewRecorder()
+		handleAdminImpersonate(rec, req)
+		return rec
+	}
+	if rec := start(8); rec.Code != http.StatusConflict {
+		t.Errorf("impersonating a blocked user: status %d", rec.Code)
+	}
+	rec := start(7)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("start: status %d %s", rec.Code, rec.Body.String())
+	}
+	var sid string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "user_session" {
+			sid = c.Value
+		}
+	}
+	if getUserSessionUserID(sid) != 7 || getUserSessionImpersonator(sid) != 3 {
+		t.Fatalf("session user %d impersonator %d", getUserSessionUserID(sid), getUserSessionImpersonator(sid))
+	}
+
+	page := userAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body class=\"x\"><h1>Dashboard</h1></body></html>")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/user/", nil)
+	req.AddCookie(&http.Cookie{Name: "user_session", Value: sid})
+	rec = httptest.NewRecorder()
+	page(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, `<body class="x"><div id="impersonation-banner"`) || !strings.Contains(body, "Alice &lt;x&gt;") {
+		t.Errorf("banner missing or unescaped: %s", body)
+	}
+
+	withdrawn := false
+	withdraw := userAuth(denyWhileImpersonating(func(w http.ResponseWriter, r *http.Request) { withdrawn = true }))
+	req = httptest.NewRequest(http.MethodPost, "/user/author/withdraw", nil)
+	req.AddCookie(&http.Cookie{Name: "user_session", Value: sid})
+	rec = httptest.NewRecorder()
+	withdraw(rec, req)
+	if rec.Code != http.StatusForbidden || withdrawn {
+		t.Errorf("withdraw while impersonating: status %d, ran %v", rec.Code, withdrawn)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		handler      http.HandlerFunc
+	}{
+		{http.MethodPost, "/user/cart/checkout", userAuth(denyWhileImpersonating(handleUserCartCheckout))},
+		{http.MethodPost, "/bundle/1/purchase", handleBundleRoutes},
+		{http.MethodPost, "/user/pack/subscribe", userAuth(denyWhileImpersonating(handleUserPackSubscribe))},
+		{http.MethodPost, "/user/pack/subscribe/cancel", userAuth(denyWhileImpersonating(handleUserPackSubscribeCancel))},
+		{http.MethodPost, "/user/credits/gift", userAuth(denyWhileImpersonating(handleUserCreditsGift))},
+		{http.MethodGet, "/user/data-export", userAuth(denyAllWhileImpersonating(handleUserDataExport))},
+	} {
+		req = httptest.NewRequest(tc.method, tc.path, nil)
+		req.AddCookie(&http.Cookie{Name: "user_session", Value: sid})
+		rec = httptest.NewRecorder()
+		tc.handler(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s while impersonating: status %d", tc.method, tc.path, rec.Code)
+		}
+	}
+
+	// A forged header does not make an ordinary session an impersonation.
+	own := createUserSession(7)
+	req = httptest.NewRequest(http.MethodPost, "/user/author/withdraw", nil)
+	req.AddCookie(&http.Cookie{Name: "user_session", Value: own})
+	req.Header.Set("X-Impersonator-ID", "3")
+	withdraw(httptest.NewRecorder(), req)
+	if !withdrawn {
+		t.Error("ordinary session should be allowed to withdraw")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/user/impersonation/exit", nil)
+	req.AddCookie(&http.Cookie{Name: "user_session", Value: sid})
+	rec = httptest.NewRecorder()
+	handleImpersonationExit(rec, req)
+	if loc := rec.Header().Get("Location"); loc != "/admin/" {
+		t.Errorf("exit redirect = %q", loc)
+	}
+	if isValidUserSession(sid) {
+		t.Error("impersonation session should end on exit")
+	}
+	var actions []string
+	rows, _ := db.Query("SELECT action FROM admin_audit_log WHERE admin_id = 3 AND target = 'user:7' ORDER BY id")
+	for rows.Next() {
+		var a string
+		rows.Scan(&a)
+		actions = append(actions, a)
+	}
+	rows.Close()
+	if strings.Join(actions, ",") != "impersonate_start,impersonate_end" {
+		t.Errorf("audit actions = %v", actions)
+	}
+}
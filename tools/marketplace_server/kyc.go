@@ -0,0 +1,360 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// KYC statuses stored in users.kyc_status and kyc_submissions.status.
+const (
+	kycStatusNone     = "none"
+	kycStatusPending  = "pending"
+	kycStatusApproved = "approved"
+	kycStatusRejected = "rejected"
+)
+
+// maxKYCDocumentSize caps an uploaded identity document.
+const maxKYCDocumentSize = 5 << 20
+
+// kycDocumentTypes are the accepted values of the document_type form field.
+var kycDocumentTypes = map[string]bool{"id_card": true, "passport": true, "business_license": true}
+
+// kycContentTypes are the accepted document formats, detected from the file content.
+var kycContentTypes = map[string]bool{"image/jpeg": true, "image/png": true, "application/pdf": true}
+
+// getKYCWithdrawalThreshold returns the credits amount above which a
+// withdrawal requires approved KYC. 0 (the default) disables the gate.
+func getKYCWithdrawalThreshold() float64 {
+	threshold, err := strconv.ParseFloat(getSetting("kyc_withdrawal_threshold"), 64)
+	if err != nil || threshold < 0 {
+		return 0
+	}
+	return threshold
+}
+
+// getUserKYCStatus returns the user's KYC status, kycStatusNone if unset.
+func getUserKYCStatus(userID int64) string {
+	var status string
+	db.QueryRow("SELECT COALESCE(kyc_status, '') FROM users WHERE id = ?", userID).Scan(&status)
+	if status == "" {
+		return kycStatusNone
+	}
+	return status
+}
+
+// kycRequiredForWithdrawal reports whether withdrawing creditsAmount is
+// blocked until the user's KYC is approved.
+func kycRequiredForWithdrawal(userID int64, creditsAmount float64) bool {
+	threshold := getKYCWithdrawalThreshold()
+	if threshold <= 0 || creditsAmount <= threshold {
+		return false
+	}
+	return getUserKYCStatus(userID) != kycStatusApproved
+}
+
+// handleUserKYC handles /user/kyc. GET returns the caller's KYC status;
+// POST takes a multipart form with full_name, document_type and a document
+// file, stores the document encrypted and puts the user in pending review.
+func handleUserKYC(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		resp := map[string]interface{}{
+			"status":    getUserKYCStatus(userID),
+			"threshold": getKYCWithdrawalThreshold(),
+		}
+		var rejectReason, submittedAt string
+		err := db.QueryRow(`SELECT COALESCE(reject_reason, ''), created_at FROM kyc_submissions
+			WHERE user_id = ? ORDER BY id DESC LIMIT 1`, userID).Scan(&rejectReason, &submittedAt)
+		if err == nil {
+			resp["reject_reason"] = rejectReason
+			resp["submitted_at"] = publishAtRFC3339(submittedAt)
+		}
+		jsonResponse(w, http.StatusOK, resp)
+
+	case http.MethodPost:
+		switch getUserKYCStatus(userID) {
+		case kycStatusPending:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "kyc_pending"})
+			return
+		case kycStatusApproved:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "kyc_already_approved"})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxKYCDocumentSize+64<<10)
+		if err := r.ParseMultipartForm(maxKYCDocumentSize); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "document_too_large"})
+			return
+		}
+		fullName := strings.TrimSpace(r.FormValue("full_name"))
+		docType := r.FormValue("document_type")
+		if fullName == "" || len([]rune(fullName)) > 100 || !kycDocumentTypes[docType] {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		file, header, err := r.FormFile("document")
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "document_required"})
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxKYCDocumentSize+1))
+		if err != nil || len(data) == 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "document_required"})
+			return
+		}
+		if len(data) > maxKYCDocumentSize {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "document_too_large"})
+			return
+		}
+		contentType := http.DetectContentType(data)
+		if !kycContentTypes[contentType] {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "unsupported_document_type"})
+			return
+		}
+
+		encrypted, err := encryptPayPalSecret(string(data))
+		if err != nil {
+			log.Printf("[KYC] failed to encrypt document of user %d: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("[KYC] begin transaction error: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		defer tx.Rollback()
+		if _, err := tx.Exec(`INSERT INTO kyc_submissions (user_id, full_name, document_type, document_name, content_type, document_data)
+			VALUES (?, ?, ?, ?, ?, ?)`, userID, fullName, docType, filepath.Base(header.Filename), contentType, encrypted); err != nil {
+			log.Printf("[KYC] failed to store submission of user %d: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if _, err := tx.Exec("UPDATE users SET kyc_status = ? WHERE id = ?", kycStatusPending, userID); err != nil {
+			log.Printf("[KYC] failed to update status of user %d: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("[KYC] commit error: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		log.Printf("[KYC] user %d submitted %s document for review", userID, docType)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "status": kycStatusPending})
+
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// handleAdminKYCRoutes dispatches the KYC review queue:
+//
+//	GET  /api/admin/kyc?status=pending   list submissions (documents omitted)
+//	GET  /api/admin/kyc/{id}/document    download the decrypted document
+//	POST /api/admin/kyc/{id}/approve
+//	POST /api/admin/kyc/{id}/reject      JSON body {"reason": "..."}
+//
+// Document views and decisions are recorded in the admin audit log.
+func handleAdminKYCRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/kyc"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		handleAdminKYCList(w, r)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_id"})
+		return
+	}
+	switch parts[1] {
+	case "document":
+		if r.Method != http.MethodGet {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		handleAdminKYCDocument(w, r, id)
+	case "approve", "reject":
+		if r.Method != http.MethodPost {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		handleAdminKYCDecision(w, r, id, parts[1] == "approve")
+	default:
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func handleAdminKYCList(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = kycStatusPending
+	}
+	if status != kycStatusPending && status != kycStatusApproved && status != kycStatusRejected {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_status"})
+		return
+	}
+	rows, err := db.Query(`SELECT k.id, k.user_id, COALESCE(u.email, ''), COALESCE(u.display_name, ''), k.full_name,
+		k.document_type, COALESCE(k.document_name, ''), k.content_type, k.status, COALESCE(k.reject_reason, ''),
+		k.created_at, COALESCE(k.reviewed_at, '')
+		FROM kyc_submissions k
+		LEFT JOIN users u ON u.id = k.user_id
+		WHERE k.status = ?
+		ORDER BY k.id
+		LIMIT 200`, status)
+	if err != nil {
+		log.Printf("[KYC] list submissions failed: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer rows.Close()
+	submissions := []map[string]interface{}{}
+	for rows.Next() {
+		var id, userID int64
+		var email, displayName, fullName, docType, docName, contentType, st, rejectReason, createdAt, reviewedAt string
+		if err := rows.Scan(&id, &userID, &email, &displayName, &fullName, &docType, &docName, &contentType,
+			&st, &rejectReason, &createdAt, &reviewedAt); err != nil {
+			log.Printf("[KYC] scan error: %v", err)
+			continue
+		}
+		submissions = append(submissions, map[string]interface{}{
+			"id": id, "user_id": userID, "email": email, "display_name": displayName, "full_name": fullName,
+			"document_type": docType, "document_name": docName, "content_type": contentType, "status": st,
+			"reject_reason": rejectReason, "created_at": createdAt, "reviewed_at": reviewedAt,
+		})
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"submissions": submissions})
+}
+
+func handleAdminKYCDocument(w http.ResponseWriter, r *http.Request, id int64) {
+	var userID int64
+	var contentType, encrypted string
+	err := db.QueryRow("SELECT user_id, content_type, document_data FROM kyc_submissions WHERE id = ?", id).
+		Scan(&userID, &contentType, &encrypted)
+	if err == sql.ErrNoRows {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "submission_not_found"})
+		return
+	} else if err != nil {
+		log.Printf("[KYC] failed to query submission %d: %v", id, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	data, err := decryptPayPalSecret(encrypted)
+	if err != nil {
+		log.Printf("[KYC] failed to decrypt document of submission %d: %v", id, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "decrypt_failed"})
+		return
+	}
+
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	recordAuditLog(adminID, "kyc_document_view", fmt.Sprintf("kyc:%d", id), fmt.Sprintf("user_id=%d", userID), getClientIP(r))
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"kyc-%d\"", id))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(data))
+}
+
+func handleAdminKYCDecision(w http.ResponseWriter, r *http.Request, id int64, approve bool) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if !approve && reason == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "reason_required"})
+		return
+	}
+	if len([]rune(reason)) > 500 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "reason_too_long"})
+		return
+	}
+	newStatus := kycStatusRejected
+	if approve {
+		newStatus = kycStatusApproved
+		reason = ""
+	}
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[KYC] begin transaction error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer tx.Rollback()
+	var userID int64
+	var status string
+	err = tx.QueryRow("SELECT user_id, status FROM kyc_submissions WHERE id = ?", id).Scan(&userID, &status)
+	if err == sql.ErrNoRows {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "submission_not_found"})
+		return
+	} else if err != nil {
+		log.Printf("[KYC] failed to query submission %d: %v", id, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if status != kycStatusPending {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "already_reviewed"})
+		return
+	}
+	if _, err := tx.Exec(`UPDATE kyc_submissions SET status = ?, reviewed_by = ?, reviewed_at = CURRENT_TIMESTAMP, reject_reason = ?
+		WHERE id = ?`, newStatus, adminID, reason, id); err != nil {
+		log.Printf("[KYC] failed to update submission %d: %v", id, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if _, err := tx.Exec("UPDATE users SET kyc_status = ? WHERE id = ?", newStatus, userID); err != nil {
+		log.Printf("[KYC] failed to update status of user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[KYC] commit error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+
+	action := "kyc_reject"
+	detail := fmt.Sprintf("user_id=%d; reason=%s", userID, reason)
+	if approve {
+		action = "kyc_approve"
+		detail = fmt.Sprintf("user_id=%d", userID)
+	}
+	recordAuditLog(adminID, action, fmt.Sprintf("kyc:%d", id), detail, getClientIP(r))
+
+	log.Printf("[KYC] admin %d set submission %d (user %d) to %s", adminID, id, userID, newStatus)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "status": newStatus})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKYCSubmissionAndReview(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "kyc-test-key")
+	t.Setenv("PAYPAL_ENCRYPTION_RETIRED_KEYS", "")
+	if _, err := db.Exec("INSERT INTO users (id, auth_type, auth_id, email, display_name) VALUES (2, 'email', 'a', 'a@example.com', 'A')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO settings (key, value) VALUES ('kyc_withdrawal_threshold', '1000')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if kycRequiredForWithdrawal(2, 1000) {
+		t.Error("withdrawal at the threshold should not need KYC")
+	}
+	if !kycRequiredForWithdrawal(2, 1001) {
+		t.Error("withdrawal above the threshold should need KYC")
+	}
+
+	doc := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("x"), 100)...)
+	submit := func() int {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("full_name", "Alice Example")
+		mw.WriteField("document_type", "passport")
+		fw, _ := mw.CreateFormFile("document", "passport.pdf")
+		fw.Write(doc)
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/user/kyc", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("X-User-ID", "2")
+		rec := httptest.NewRecorder()
+		handleUserKYC(rec, req)
+		return rec.Code
+	}
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Admin-ID", "7")
+		rec := httptest.NewRecorder()
+		handleAdminKYCRoutes(rec, req)
+		return rec
+	}
+
+	if code := submit(); code != http.StatusOK {
+		t.Fatalf("submit: status %d", code)
+	}
+	if code := submit(); code != http.StatusConflict {
+		t.Errorf("resubmit while pending: status %d, want 409", code)
+	}
+	var stored string
+	db.QueryRow("SELECT document_data FROM kyc_submissions WHERE id = 1").Scan(&stored)
+	if !strings.HasPrefix(stored, encryptedValuePrefix) {
+		t.Errorf("document stored unencrypted: %.20q", stored)
+	}
+
+	rec := admin(http.MethodGet, "/api/admin/kyc/1/document", "")
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), doc) || rec.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("document download: status %d, type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	if rec := admin(http.MethodPost, "/api/admin/kyc/1/reject", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("reject without reason: status %d, want 400", rec.Code)
+	}
+	if rec := admin(http.MethodPost, "/api/admin/kyc/1/reject", `{"reason": "blurry"}`); rec.Code != http.StatusOK {
+		t.Fatalf("reject: status %d", rec.Code)
+	}
+	if got := getUserKYCStatus(2); got != kycStatusRejected {
+		t.Fatalf("status after reject = %q", got)
+	}
+
+	if code := submit(); code != http.StatusOK {
+		t.Fatalf("resubmit after reject: status %d", code)
+	}
+	if rec := admin(http.MethodPost, "/api/admin/kyc/2/approve", ""); rec.Code != http.StatusOK {
+		t.Fatalf("approve: status %d", rec.Code)
+	}
+	if rec := admin(http.MethodPost, "/api/admin/kyc/2/approve", ""); rec.Code != http.StatusConflict {
+		t.Errorf("approve twice: status %d, want 409", rec.Code)
+	}
+	if kycRequiredForWithdrawal(2, 5000) {
+		t.Error("approved user still blocked from large withdrawal")
+	}
+
+	var actions []string
+	rows, err := db.Query("SELECT action || ' ' || target FROM admin_audit_log ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a string
+		rows.Scan(&a)
+		actions = append(actions, a)
+	}
+	if strings.Join(actions, ",") != "kyc_document_view kyc:1,kyc_reject kyc:1,kyc_approve kyc:2" {
+		t.Errorf("audit log = %v", actions)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// License API retry defaults. Overridable via the license_api_max_attempts and
+// license_api_base_delay_ms settings.
+const (
+	defaultLicenseAPIMaxAttempts = 3
+	defaultLicenseAPIBaseDelay   = 500 * time.Millisecond
+	licenseAPIMaxDelay           = 10 * time.Second
+	licenseAPITimeout            = 10 * time.Second
+)
+
+// licenseRetryPolicy controls how callLicenseAPI retries transient failures.
+type licenseRetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// licenseAPIError is returned for a failed License API call. Retryable is set
+// for timeouts, connection errors, 429 and 5xx; anything else is permanent.
+type licenseAPIError struct {
+	StatusCode int
+	Retryable  bool
+	Err        error
+}
+
+func (e *licenseAPIError) Error() string { return e.Err.Error() }
+func (e *licenseAPIError) Unwrap() error { return e.Err }
+
+// getLicenseRetryPolicy returns the configured retry policy.
+func getLicenseRetryPolicy() licenseRetryPolicy {
+	p := licenseRetryPolicy{
+		MaxAttempts: defaultLicenseAPIMaxAttempts,
+		BaseDelay:   defaultLicenseAPIBaseDelay,
+		MaxDelay:    licenseAPIMaxDelay,
+	}
+	if n, err := strconv.Atoi(getSetting("license_api_max_attempts")); err == nil && n >= 1 && n <= 10 {
+		p.MaxAttempts = n
+	}
+	if ms, err := strconv.Atoi(getSetting("license_api_base_delay_ms")); err == nil && ms >= 0 && ms <= 60000 {
+		p.BaseDelay = time.Duration(ms) * time.Millisecond
+	}
+	return p
+}
+
+// backoff returns the wait before retry number attempt (1-based): exponential
+// in the attempt, capped at MaxDelay, with "equal jitter" so concurrent
+// callers don't retry in lockstep.
+func (p licenseRetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// callLicenseAPI calls an external License API to bind a license SN to a user email.
+// Request body: {"api_key": "...", "email": "...", "product_id": "..."}
+// Returns the license SN from the response. Transient failures are retried
+// according to the configured policy; the returned error is a *licenseAPIError.
+func callLicenseAPI(endpoint, apiKey, email, productID string) (sn string, err error) {
+	return callLicenseAPIWithPolicy(getLicenseRetryPolicy(), endpoint, apiKey, email, productID)
+}
+
+func callLicenseAPIWithPolicy(policy licenseRetryPolicy, endpoint, apiKey, email, productID string) (string, error) {
+	var lastErr error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		sn, err := callLicenseAPIOnce(endpoint, apiKey, email, productID)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("[LICENSE-API] succeeded on attempt %d for product %q", attempt, productID)
+			}
+			return sn, nil
+		}
+		lastErr = err
+		var apiErr *licenseAPIError
+		if !errors.As(err, &apiErr) || !apiErr.Retryable || attempt == policy.MaxAttempts {
+			break
+		}
+		delay := policy.backoff(attempt)
+		log.Printf("[LICENSE-API] attempt %d/%d for product %q failed: %v; retrying in %v",
+			attempt, policy.MaxAttempts, productID, err, delay)
+		time.Sleep(delay)
+	}
+	return "", lastErr
+}
+
+// callLicenseAPIOnce performs a single License API request.
+func callLicenseAPIOnce(endpoint, apiKey, email, productID string) (string, error) {
+	reqBody := map[string]string{
+		"api_key":    apiKey,
+		"email":      email,
+		"product_id": productID,
+	}
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", &licenseAPIError{Err: fmt.Errorf("failed to marshal license API request: %w", err)}
+	}
+
+	client := &http.Client{Timeout: licenseAPITimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(bodyBytes))
+	if err != nil {
+		// Timeouts and connection failures are worth retrying.
+		return "", &licenseAPIError{Retryable: true, Err: fmt.Errorf("license API request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", &licenseAPIError{StatusCode: resp.StatusCode, Retryable: true, Err: fmt.Errorf("failed to read license API response: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return "", &licenseAPIError{
+			StatusCode: resp.StatusCode,
+			Retryable:  retryable,
+			Err:        fmt.Errorf("license API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody))),
+		}
+	}
+
+	var result struct {
+		SN string `json:"sn"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", &licenseAPIError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to parse license API response: %w", err)}
+	}
+	if result.SN == "" {
+		return "", &licenseAPIError{StatusCode: resp.StatusCode, Err: fmt.Errorf("license API returned empty SN")}
+	}
+	return result.SN, nil
+}
+
+// handleAdminLicenseRetrySettings handles GET/POST /admin/settings/license-retry.
+// POST form values: max_attempts (1-10), base_delay_ms (0-60000).
+func handleAdminLicenseRetrySettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		p := getLicenseRetryPolicy()
+		jsonResponse(w, http.StatusOK, map[string]int{
+			"max_attempts":  p.MaxAttempts,
+			"base_delay_ms": int(p.BaseDelay / time.Millisecond),
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	attempts, err := strconv.Atoi(strings.TrimSpace(r.FormValue("max_attempts")))
+	if err != nil || attempts < 1 || attempts > 10 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "重试次数必须在 1 到 10 之间"})
+		return
+	}
+	delayMS, err := strconv.Atoi(strings.TrimSpace(r.FormValue("base_delay_ms")))
+	if err != nil || delayMS < 0 || delayMS > 60000 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "初始等待时间必须在 0 到 60000 毫秒之间"})
+		return
+	}
+	for key, value := range map[string]string{
+		"license_api_max_attempts":  strconv.Itoa(attempts),
+		"license_api_base_delay_ms": strconv.Itoa(delayMS),
+	} {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
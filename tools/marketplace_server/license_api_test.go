@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testLicensePolicy = licenseRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestCallLicenseAPIRetriesTransientFailures(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			http.Error(w, "upstream unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"sn": "SN-123"})
+	}))
+	defer srv.Close()
+
+	sn, err := callLicenseAPIWithPolicy(testLicensePolicy, srv.URL, "key", "buyer@example.com", "prod")
+	if err != nil {
+		t.Fatalf("callLicenseAPIWithPolicy: %v", err)
+	}
+	if sn != "SN-123" {
+		t.Errorf("sn = %q, want SN-123", sn)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("calls = %d, want 3", n)
+	}
+}
+
+func TestCallLicenseAPIDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "unknown product", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	_, err := callLicenseAPIWithPolicy(testLicensePolicy, srv.URL, "key", "buyer@example.com", "prod")
+	apiErr, ok := err.(*licenseAPIError)
+	if !ok || apiErr.Retryable || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %#v, want permanent 400 licenseAPIError", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("calls = %d, want 1", n)
+	}
+}
+
+func TestLicenseRetryBackoffIsBounded(t *testing.T) {
+	p := licenseRetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt <= 8; attempt++ {
+		want := p.BaseDelay << (attempt - 1)
+		if want > p.MaxDelay {
+			want = p.MaxDelay
+		}
+		if d := p.backoff(attempt); d < want/2 || d > want {
+			t.Errorf("backoff(%d) = %v, want within [%v, %v]", attempt, d, want/2, want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/webp"
+)
+
+// Logo size limits. Uploaded logos are downscaled so that neither side exceeds
+// logoMaxDimension; a second, smaller variant is generated for homepage cards.
+const (
+	logoMaxDimension      = 512
+	logoThumbMaxDimension = 128
+	logoJPEGQuality       = 85
+)
+
+// detectImageContentType sniffs the MIME type of an uploaded image.
+// http.DetectContentType only recognizes WebP when the VP8 chunk header
+// immediately follows the container header, so the RIFF/WEBP container is
+// checked explicitly first.
+func detectImageContentType(data []byte) string {
+	if len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return "image/webp"
+	}
+	return http.DetectContentType(data)
+}
+
+// isAllowedLogoContentType reports whether ct is an accepted logo upload format.
+func isAllowedLogoContentType(ct string) bool {
+	return ct == "image/png" || ct == "image/jpeg" || ct == "image/webp"
+}
+
+// normalizeWebPLogo decodes a WebP image and re-encodes it as PNG so stored
+// logos are always PNG or JPEG. PNG keeps any alpha channel intact. Images
+// over maxImagePixels are rejected before decoding.
+func normalizeWebPLogo(data []byte) ([]byte, error) {
+	cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode webp config: %w", err)
+	}
+	if err := checkImagePixels(cfg); err != nil {
+		return nil, err
+	}
+	img, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode webp: %w", err)
+	}
+	return encodeLogoImage(img, "image/png")
+}
+
+// Banner image limits. Banners are wide, so they are allowed a larger bounding
+// box than logos.
+const (
+	bannerImageMaxDimension = 1600
+	maxBannerImageSize      = 2 * 1024 * 1024 // 2MB
+)
+
+// processBannerImage decodes an uploaded PNG/JPEG/WebP banner image, downscales
+// it to bannerImageMaxDimension and re-encodes it (WebP is normalized to PNG).
+// Images over maxImagePixels are rejected before decoding.
+func processBannerImage(data []byte) ([]byte, string, error) {
+	img, format, err := decodeUploadedImage(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType := "image/png"
+	switch format {
+	case "png", "webp":
+	case "jpeg":
+		contentType = "image/jpeg"
+	default:
+		return nil, "", fmt.Errorf("unsupported image format: %s", format)
+	}
+
+	out, err := encodeLogoImage(resizeToFit(img, bannerImageMaxDimension), contentType)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, contentType, nil
+}
+
+// maxImagePixels caps the pixel count of uploaded images. Decoding and
+// resizing allocate several bytes per pixel, so a small file that declares
+// huge dimensions is rejected from its header, before it is decoded.
+const maxImagePixels = 4096 * 4096
+
+// checkImagePixels rejects image dimensions outside maxImagePixels.
+func checkImagePixels(cfg image.Config) error {
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return fmt.Errorf("image dimensions %dx%d exceed the %d pixel limit", cfg.Width, cfg.Height, maxImagePixels)
+	}
+	return nil
+}
+
+// decodeUploadedImage decodes data after checking its declared dimensions.
+func decodeUploadedImage(data []byte) (image.Image, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image config: %w", err)
+	}
+	if err := checkImagePixels(cfg); err != nil {
+		return nil, "", err
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image: %w", err)
+	}
+	return img, format, nil
+}
+
+// processedLogo holds the re-encoded full-size logo and its thumbnail.
+type processedLogo struct {
+	Full        []byte
+	Thumb       []byte
+	ContentType string
+}
+
+// processLogoImage decodes an uploaded PNG/JPEG/WebP logo, downscales it to
+// logoMaxDimension (preserving aspect ratio), and produces a thumbnail
+// variant. PNG and JPEG keep their original format so transparency survives
+// for PNG uploads; WebP is normalized to PNG. Images that fail to decode are
+// rejected, as are images over maxImagePixels.
+func processLogoImage(data []byte) (*processedLogo, error) {
+	img, format, err := decodeUploadedImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var contentType string
+	switch format {
+	case "png", "webp":
+		contentType = "image/png"
+	case "jpeg":
+		contentType = "image/jpeg"
+	default:
+		return nil, fmt.Errorf("unsupported image format: %s", format)
+	}
+
+	full, err := encodeLogoImage(resizeToFit(img, logoMaxDimension), contentType)
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := encodeLogoImage(resizeToFit(img, logoThumbMaxDimension), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &processedLogo{Full: full, Thumb: thumb, ContentType: contentType}, nil
+}
+
+// encodeLogoImage encodes img using the encoder matching contentType.
+func encodeLogoImage(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: logoJPEGQuality}); err != nil {
+			return nil, fmt.Errorf("encode jpeg: %w", err)
+		}
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("encode png: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// resizeToFit returns img scaled down so that its longest side is at most
+// maxDim pixels. Images already within bounds are returned unchanged.
+// Scaling uses box (area-average) sampling, which gives good quality for
+// downscaling without pulling in an external imaging library.
+func resizeToFit(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if srcW <= maxDim && srcH <= maxDim {
+		return img
+	}
+
+	dstW, dstH := maxDim, maxDim
+	if srcW >= srcH {
+		dstH = srcH * maxDim / srcW
+	} else {
+		dstW = srcW * maxDim / srcH
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	// Normalize to NRGBA so pixel access below is direct and alpha is not premultiplied.
+	src := image.NewNRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for dy := 0; dy < dstH; dy++ {
+		y0 := dy * srcH / dstH
+		y1 := (dy + 1) * srcH / dstH
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < dstW; dx++ {
+			x0 := dx * srcW / dstW
+			x1 := (dx + 1) * srcW / dstW
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				off := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(src.Pix[off])
+					g += uint64(src.Pix[off+1])
+					bl += uint64(src.Pix[off+2])
+					a += uint64(src.Pix[off+3])
+					n++
+					off += 4
+				}
+			}
+
+			doff := dst.PixOffset(dx, dy)
+			dst.Pix[doff] = uint8(r / n)
+			dst.Pix[doff+1] = uint8(g / n)
+			dst.Pix[doff+2] = uint8(bl / n)
+			dst.Pix[doff+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
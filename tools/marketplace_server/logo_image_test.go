@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testWebPLossless is a 1x1 lossless WebP image.
+const testWebPLossless = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+func decodeTestWebP(t *testing.T) []byte {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(testWebPLossless)
+	if err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+	return data
+}
+
+func TestDetectImageContentTypeWebP(t *testing.T) {
+	data := decodeTestWebP(t)
+	if ct := detectImageContentType(data); ct != "image/webp" {
+		t.Fatalf("expected image/webp, got %q", ct)
+	}
+	if !isAllowedLogoContentType(detectImageContentType(data)) {
+		t.Fatal("webp should be an allowed logo content type")
+	}
+}
+
+func TestProcessLogoImageValidWebP(t *testing.T) {
+	logo, err := processLogoImage(decodeTestWebP(t))
+	if err != nil {
+		t.Fatalf("processLogoImage: %v", err)
+	}
+	if logo.ContentType != "image/png" {
+		t.Fatalf("expected webp to be normalized to image/png, got %q", logo.ContentType)
+	}
+	for name, data := range map[string][]byte{"full": logo.Full, "thumb": logo.Thumb} {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s variant is not a valid PNG: %v", name, err)
+		}
+		if img.Bounds().Dx() != 1 || img.Bounds().Dy() != 1 {
+			t.Fatalf("%s variant has unexpected size %v", name, img.Bounds())
+		}
+	}
+
+	normalized, err := normalizeWebPLogo(decodeTestWebP(t))
+	if err != nil {
+		t.Fatalf("normalizeWebPLogo: %v", err)
+	}
+	if ct := detectImageContentType(normalized); ct != "image/png" {
+		t.Fatalf("expected normalized logo to be image/png, got %q", ct)
+	}
+}
+
+func TestProcessLogoImageTruncatedWebP(t *testing.T) {
+	data := decodeTestWebP(t)
+	truncated := data[:len(data)-6]
+
+	// The container header is intact, so sniffing still reports WebP...
+	if ct := detectImageContentType(truncated); ct != "image/webp" {
+		t.Fatalf("expected image/webp, got %q", ct)
+	}
+	// ...but decoding must fail so the upload is rejected rather than stored.
+	if _, err := processLogoImage(truncated); err == nil {
+		t.Fatal("expected truncated webp to be rejected")
+	}
+	if _, err := normalizeWebPLogo(truncated); err == nil {
+		t.Fatal("expected truncated webp to be rejected by normalizeWebPLogo")
+	}
+}
+
+func TestResizeToFitPreservesAspectRatio(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 1024, 256))
+	got := resizeToFit(src, logoMaxDimension).Bounds()
+	if got.Dx() != 512 || got.Dy() != 128 {
+		t.Fatalf("expected 512x128, got %dx%d", got.Dx(), got.Dy())
+	}
+
+	small := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	if resizeToFit(small, logoMaxDimension) != image.Image(small) {
+		t.Fatal("images within bounds should be returned unchanged")
+	}
+}
+
+// hugePNG returns a small PNG whose header declares width x height pixels.
+func hugePNG(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// IHDR: length at 8, type at 12, width and height at 16 and 20, CRC at 29.
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestProcessLogoImageRejectsHugeDimensions(t *testing.T) {
+	if _, err := processLogoImage(hugePNG(t, 30000, 30000)); err == nil || !strings.Contains(err.Error(), "pixel limit") {
+		t.Fatalf("processLogoImage(30000x30000) = %v, want pixel limit error", err)
+	}
+	if _, _, err := processBannerImage(hugePNG(t, 30000, 30000)); err == nil || !strings.Contains(err.Error(), "pixel limit") {
+		t.Fatalf("processBannerImage(30000x30000) = %v, want pixel limit error", err)
+	}
+}
+
+func TestNormalizeWebPLogoRejectsHugeDimensions(t *testing.T) {
+	data := decodeTestWebP(t)
+	// The VP8L header after the 0x2f signature packs width-1 and height-1
+	// into 14 bits each; keep the alpha and version bits of the fixture.
+	bits := binary.LittleEndian.Uint32(data[21:])
+	binary.LittleEndian.PutUint32(data[21:], bits&^(1<<28-1)|(16383<<14|16383))
+	if _, err := normalizeWebPLogo(data); err == nil || !strings.Contains(err.Error(), "pixel limit") {
+		t.Fatalf("normalizeWebPLogo(16384x16384) = %v, want pixel limit error", err)
+	}
+}
@@ -9,12 +9,12 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
-	"crypto/tls"
 	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
@@ -23,9 +23,9 @@ import (
 	"image/png"
 	"io"
 	"log"
+	"math"
 	"math/big"
 	"net/http"
-	"net/smtp"
 	"net/url"
 	"os"
 	"regexp"
@@ -130,9 +130,13 @@ type PackListingInfo struct {
 	RejectReason    string           `json:"reject_reason,omitempty"`
 	ReviewedBy      *int64           `json:"reviewed_by,omitempty"`
 	ReviewedAt      string           `json:"reviewed_at,omitempty"`
+	PublishAt       string           `json:"publish_at,omitempty"`
 	MetaInfo        json.RawMessage  `json:"meta_info"`
 	CreatedAt       string           `json:"created_at"`
+	FileSHA256      string           `json:"file_sha256,omitempty"`
+	ShareToken      string           `json:"share_token,omitempty"`
 	Purchased       bool             `json:"purchased"`
+	Saved           bool             `json:"saved"`
 }
 
 
@@ -173,18 +177,27 @@ type WithdrawalRequest struct {
 
 // StorefrontInfo 小铺基本信息
 type StorefrontInfo struct {
-	ID              int64  `json:"id"`
-	UserID          int64  `json:"user_id"`
-	PublicID        string `json:"public_id"`
-	StoreName       string `json:"store_name"`
-	StoreSlug       string `json:"store_slug"`
-	Description     string `json:"description"`
-	HasLogo         bool   `json:"has_logo"`
-	LogoContentType string `json:"logo_content_type"`
-	AutoAddEnabled  bool   `json:"auto_add_enabled"`
-	StoreLayout     string `json:"store_layout"`
-	CreatedAt       string `json:"created_at"`
-	UpdatedAt       string `json:"updated_at"`
+	ID                 int64  `json:"id"`
+	UserID             int64  `json:"user_id"`
+	PublicID           string `json:"public_id"`
+	StoreName          string `json:"store_name"`
+	StoreSlug          string `json:"store_slug"`
+	Description        string `json:"description"`
+	HasLogo            bool   `json:"has_logo"`
+	LogoContentType    string `json:"logo_content_type"`
+	AutoAddEnabled     bool   `json:"auto_add_enabled"`
+	StoreLayout        string `json:"store_layout"`
+	StoreStatus        string `json:"store_status"`
+	Announcement       string `json:"announcement"`
+	AnnouncementActive bool   `json:"announcement_active"`
+	RefundPolicy       string `json:"refund_policy"`
+	Terms              string `json:"terms"`
+	Contact            string `json:"contact"`
+	Verified           bool   `json:"verified"`
+	ColorScheme        string `json:"color_scheme"`
+	FollowerCount      int    `json:"follower_count"`
+	CreatedAt          string `json:"created_at"`
+	UpdatedAt          string `json:"updated_at"`
 }
 
 // StorefrontPackInfo 小铺中的分析包信息
@@ -203,6 +216,9 @@ type StorefrontPackInfo struct {
 	OrderCount    int     `json:"order_count"`
 	CategoryName  string  `json:"category_name"`
 	HasLogo       bool    `json:"has_logo"`
+	// 限时折扣进行中时：OriginalPrice 为原价，CreditsPrice 为折扣价
+	OriginalPrice int    `json:"original_price,omitempty"`
+	SaleEndsAt    string `json:"sale_ends_at,omitempty"`
 }
 
 // HomepageStoreInfo 首页店铺卡片数据
@@ -213,6 +229,7 @@ type HomepageStoreInfo struct {
 	StoreSlug    string
 	Description  string
 	HasLogo      bool
+	Verified     bool
 }
 
 // HomepageProductInfo 首页产品卡片数据
@@ -225,6 +242,7 @@ type HomepageProductInfo struct {
 	CreditsPrice  int
 	DownloadCount int
 	ShareToken    string
+	CreatedAt     string
 }
 
 // HomepageCategoryInfo 首页分类浏览卡片数据
@@ -243,9 +261,11 @@ type HomepageData struct {
 	DownloadURLMacOS   string
 	ServicePortalURL   string
 	FeaturedStores     []HomepageStoreInfo
+	FeaturedProducts   []HomepageProductInfo
 	TopSalesStores     []HomepageStoreInfo
 	TopDownloadsStores []HomepageStoreInfo
 	TopSalesProducts   []HomepageProductInfo
+	TrendingProducts   []HomepageProductInfo
 	TopDownloadsProducts []HomepageProductInfo
 	NewestProducts     []HomepageProductInfo
 	Categories         []HomepageCategoryInfo
@@ -254,9 +274,10 @@ type HomepageData struct {
 // queryFeaturedStorefronts 查询管理员设置的明星店铺，按 sort_order 升序排列，最多 16 个。
 func queryFeaturedStorefronts() ([]HomepageStoreInfo, error) {
 	rows, err := db.Query(`SELECT s.id, COALESCE(s.public_id, ''), s.store_name, s.store_slug, s.description,
-		CASE WHEN s.logo_data IS NOT NULL AND length(s.logo_data) > 0 THEN 1 ELSE 0 END as has_logo
+		CASE WHEN s.logo_data IS NOT NULL AND length(s.logo_data) > 0 THEN 1 ELSE 0 END as has_logo, COALESCE(s.verified, 0)
 		FROM featured_storefronts fs
 		JOIN author_storefronts s ON s.id = fs.storefront_id
+		WHERE COALESCE(s.store_status, 'active') NOT IN ('paused', 'deleted')
 		ORDER BY fs.sort_order ASC
 		LIMIT 16`)
 	if err != nil {
@@ -268,7 +289,7 @@ func queryFeaturedStorefronts() ([]HomepageStoreInfo, error) {
 	for rows.Next() {
 		var s HomepageStoreInfo
 		var hasLogo int
-		if err := rows.Scan(&s.StorefrontID, &s.PublicID, &s.StoreName, &s.StoreSlug, &s.Description, &hasLogo); err != nil {
+		if err := rows.Scan(&s.StorefrontID, &s.PublicID, &s.StoreName, &s.StoreSlug, &s.Description, &hasLogo, &s.Verified); err != nil {
 			return nil, fmt.Errorf("queryFeaturedStorefronts scan: %w", err)
 		}
 		s.HasLogo = hasLogo == 1
@@ -284,12 +305,13 @@ func queryFeaturedStorefronts() ([]HomepageStoreInfo, error) {
 // 通过聚合 credits_transactions 中每个店铺所有已发布产品的购买类交易金额绝对值计算总销售额。
 func queryTopSalesStorefronts(limit int) ([]HomepageStoreInfo, error) {
 	rows, err := db.Query(`SELECT s.id, COALESCE(s.public_id, ''), s.store_name, s.store_slug, s.description,
-		CASE WHEN s.logo_data IS NOT NULL AND length(s.logo_data) > 0 THEN 1 ELSE 0 END as has_logo,
+		CASE WHEN s.logo_data IS NOT NULL AND length(s.logo_data) > 0 THEN 1 ELSE 0 END as has_logo, COALESCE(s.verified, 0),
 		COALESCE(SUM(ABS(ct.amount)), 0) as total_sales
 		FROM author_storefronts s
 		JOIN pack_listings pl ON pl.user_id = s.user_id AND pl.status = 'published'
 		JOIN credits_transactions ct ON ct.listing_id = pl.id
 			AND ct.transaction_type IN ('purchase', 'purchase_uses', 'renew', 'download')
+		WHERE COALESCE(s.store_status, 'active') NOT IN ('paused', 'deleted')
 		GROUP BY s.id
 		HAVING total_sales > 0
 		ORDER BY total_sales DESC
@@ -304,7 +326,7 @@ func queryTopSalesStorefronts(limit int) ([]HomepageStoreInfo, error) {
 		var s HomepageStoreInfo
 		var hasLogo int
 		var totalSales float64
-		if err := rows.Scan(&s.StorefrontID, &s.PublicID, &s.StoreName, &s.StoreSlug, &s.Description, &hasLogo, &totalSales); err != nil {
+		if err := rows.Scan(&s.StorefrontID, &s.PublicID, &s.StoreName, &s.StoreSlug, &s.Description, &hasLogo, &s.Verified, &totalSales); err != nil {
 			return nil, fmt.Errorf("queryTopSalesStorefronts scan: %w", err)
 		}
 		s.HasLogo = hasLogo == 1
@@ -318,10 +340,11 @@ func queryTopSalesStorefronts(limit int) ([]HomepageStoreInfo, error) {
 
 func queryTopDownloadsStorefronts(limit int) ([]HomepageStoreInfo, error) {
 	rows, err := db.Query(`SELECT s.id, COALESCE(s.public_id, ''), s.store_name, s.store_slug, s.description,
-		CASE WHEN s.logo_data IS NOT NULL AND length(s.logo_data) > 0 THEN 1 ELSE 0 END as has_logo,
+		CASE WHEN s.logo_data IS NOT NULL AND length(s.logo_data) > 0 THEN 1 ELSE 0 END as has_logo, COALESCE(s.verified, 0),
 		COALESCE(SUM(pl.download_count), 0) as total_downloads
 		FROM author_storefronts s
 		JOIN pack_listings pl ON pl.user_id = s.user_id AND pl.status = 'published'
+		WHERE COALESCE(s.store_status, 'active') NOT IN ('paused', 'deleted')
 		GROUP BY s.id
 		HAVING total_downloads > 0
 		ORDER BY total_downloads DESC
@@ -336,7 +359,7 @@ func queryTopDownloadsStorefronts(limit int) ([]HomepageStoreInfo, error) {
 		var s HomepageStoreInfo
 		var hasLogo int
 		var totalDownloads float64
-		if err := rows.Scan(&s.StorefrontID, &s.PublicID, &s.StoreName, &s.StoreSlug, &s.Description, &hasLogo, &totalDownloads); err != nil {
+		if err := rows.Scan(&s.StorefrontID, &s.PublicID, &s.StoreName, &s.StoreSlug, &s.Description, &hasLogo, &s.Verified, &totalDownloads); err != nil {
 			return nil, fmt.Errorf("queryTopDownloadsStorefronts scan: %w", err)
 		}
 		s.HasLogo = hasLogo == 1
@@ -358,6 +381,7 @@ func queryTopSalesProducts(limit int) ([]HomepageProductInfo, error) {
 		JOIN credits_transactions ct ON ct.listing_id = pl.id
 			AND ct.transaction_type IN ('purchase', 'purchase_uses', 'renew', 'download')
 		WHERE pl.status = 'published'
+		  AND NOT EXISTS (SELECT 1 FROM author_storefronts ps WHERE ps.user_id = pl.user_id AND ps.store_status = 'paused')
 		GROUP BY pl.id
 		HAVING total_sales > 0
 		ORDER BY total_sales DESC
@@ -385,7 +409,7 @@ func queryTopSalesProducts(limit int) ([]HomepageProductInfo, error) {
 // queryNewestProducts 查询最新上架的已发布产品，按 created_at 降序，最多返回 limit 个。
 func queryNewestProducts(limit int) ([]HomepageProductInfo, error) {
 	rows, err := db.Query(`SELECT pl.id, pl.pack_name, COALESCE(pl.pack_description, ''), pl.author_name, pl.share_mode, pl.credits_price,
-		pl.download_count, COALESCE(pl.share_token, '')
+		pl.download_count, COALESCE(pl.share_token, ''), COALESCE(pl.created_at, '')
 		FROM pack_listings pl
 		WHERE pl.status = 'published'
 		ORDER BY pl.created_at DESC
@@ -398,7 +422,7 @@ func queryNewestProducts(limit int) ([]HomepageProductInfo, error) {
 	var products []HomepageProductInfo
 	for rows.Next() {
 		var p HomepageProductInfo
-		if err := rows.Scan(&p.ListingID, &p.PackName, &p.PackDesc, &p.AuthorName, &p.ShareMode, &p.CreditsPrice, &p.DownloadCount, &p.ShareToken); err != nil {
+		if err := rows.Scan(&p.ListingID, &p.PackName, &p.PackDesc, &p.AuthorName, &p.ShareMode, &p.CreditsPrice, &p.DownloadCount, &p.ShareToken, &p.CreatedAt); err != nil {
 			return nil, fmt.Errorf("queryNewestProducts scan: %w", err)
 		}
 		products = append(products, p)
@@ -409,6 +433,35 @@ func queryNewestProducts(limit int) ([]HomepageProductInfo, error) {
 	return products, nil
 }
 
+// queryFeaturedProducts 查询管理员设置的精选产品（仅已发布），按 sort_order 升序排列，最多 16 个。
+func queryFeaturedProducts() ([]HomepageProductInfo, error) {
+	rows, err := db.Query(`SELECT pl.id, pl.pack_name, COALESCE(pl.pack_description, ''), pl.author_name, pl.share_mode, pl.credits_price,
+		pl.download_count, COALESCE(pl.share_token, '')
+		FROM featured_products fp
+		JOIN pack_listings pl ON pl.id = fp.listing_id
+		WHERE pl.status = 'published'
+		  AND NOT EXISTS (SELECT 1 FROM author_storefronts ps WHERE ps.user_id = pl.user_id AND ps.store_status = 'paused')
+		ORDER BY fp.sort_order ASC
+		LIMIT 16`)
+	if err != nil {
+		return nil, fmt.Errorf("queryFeaturedProducts: %w", err)
+	}
+	defer rows.Close()
+
+	var products []HomepageProductInfo
+	for rows.Next() {
+		var p HomepageProductInfo
+		if err := rows.Scan(&p.ListingID, &p.PackName, &p.PackDesc, &p.AuthorName, &p.ShareMode, &p.CreditsPrice, &p.DownloadCount, &p.ShareToken); err != nil {
+			return nil, fmt.Errorf("queryFeaturedProducts scan: %w", err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("queryFeaturedProducts rows: %w", err)
+	}
+	return products, nil
+}
+
 // queryHomepageCategories 查询有已发布分析包的分类及其包数量。
 func queryHomepageCategories() ([]HomepageCategoryInfo, error) {
 	rows, err := db.Query(`SELECT c.id, c.name,
@@ -443,6 +496,7 @@ func queryTopDownloadsProducts(limit int) ([]HomepageProductInfo, error) {
 		pl.download_count, COALESCE(pl.share_token, '')
 		FROM pack_listings pl
 		WHERE pl.status = 'published' AND pl.download_count > 0
+		  AND NOT EXISTS (SELECT 1 FROM author_storefronts ps WHERE ps.user_id = pl.user_id AND ps.store_status = 'paused')
 		ORDER BY pl.download_count DESC
 		LIMIT ?`, limit)
 	if err != nil {
@@ -688,6 +742,216 @@ func handleAdminFeaturedStorefronts(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusNotFound, map[string]interface{}{"ok": false, "error": "not_found"})
 }
 
+// handleAdminFeaturedProducts 管理首页精选产品（搜索、添加、移除、排序，最多 16 个）。
+func handleAdminFeaturedProducts(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+
+	// GET /api/admin/featured-products/search — 搜索已发布的分析包
+	if path == "/api/admin/featured-products/search" {
+		if r.Method != http.MethodGet {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+			return
+		}
+		q := r.URL.Query().Get("q")
+		if q == "" {
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "data": []interface{}{}})
+			return
+		}
+		rows, err := db.Query(`SELECT pl.id, pl.pack_name, COALESCE(pl.author_name, '')
+			FROM pack_listings pl
+			WHERE pl.status = 'published'
+			  AND pl.id NOT IN (SELECT listing_id FROM featured_products)
+			  AND (pl.pack_name LIKE ? OR pl.author_name LIKE ?)
+			LIMIT 20`, "%"+q+"%", "%"+q+"%")
+		if err != nil {
+			log.Printf("[handleAdminFeaturedProducts] search error: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+			return
+		}
+		defer rows.Close()
+		type SearchResult struct {
+			ID         int64  `json:"id"`
+			PackName   string `json:"pack_name"`
+			AuthorName string `json:"author_name"`
+		}
+		var results []SearchResult
+		for rows.Next() {
+			var sr SearchResult
+			if err := rows.Scan(&sr.ID, &sr.PackName, &sr.AuthorName); err != nil {
+				continue
+			}
+			results = append(results, sr)
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("[handleAdminFeaturedProducts] search rows error: %v", err)
+		}
+		if results == nil {
+			results = []SearchResult{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "data": results})
+		return
+	}
+
+	// POST /api/admin/featured-products/remove — 移除精选产品
+	if path == "/api/admin/featured-products/remove" {
+		if r.Method != http.MethodPost {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+			return
+		}
+		listingID, err := strconv.ParseInt(r.FormValue("listing_id"), 10, 64)
+		if err != nil || listingID <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "invalid listing_id"})
+			return
+		}
+		result, err := db.Exec(`DELETE FROM featured_products WHERE listing_id = ?`, listingID)
+		if err != nil {
+			log.Printf("[handleAdminFeaturedProducts] remove error: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+			return
+		}
+		affected, _ := result.RowsAffected()
+		if affected == 0 {
+			jsonResponse(w, http.StatusNotFound, map[string]interface{}{"ok": false, "error": "product not in featured list"})
+			return
+		}
+		globalCache.InvalidateHomepage()
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+		return
+	}
+
+	// POST /api/admin/featured-products/reorder — 调整排序（ids 为按新顺序排列的 listing_id）
+	if path == "/api/admin/featured-products/reorder" {
+		if r.Method != http.MethodPost {
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+			return
+		}
+		var ids []int64
+		for _, p := range strings.Split(r.FormValue("ids"), ",") {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(p, 10, 64)
+			if err != nil {
+				jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "invalid ids format"})
+				return
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "invalid ids format"})
+			return
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("[handleAdminFeaturedProducts] reorder begin tx error: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+			return
+		}
+		defer tx.Rollback()
+		for i, id := range ids {
+			if _, err := tx.Exec(`UPDATE featured_products SET sort_order = ? WHERE listing_id = ?`, i+1, id); err != nil {
+				log.Printf("[handleAdminFeaturedProducts] reorder update error: %v", err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("[handleAdminFeaturedProducts] reorder commit error: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+			return
+		}
+		globalCache.InvalidateHomepage()
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+		return
+	}
+
+	// /api/admin/featured-products — GET (list) or POST (add)
+	if path == "/api/admin/featured-products" {
+		switch r.Method {
+		case http.MethodGet:
+			rows, err := db.Query(`SELECT fp.id, fp.listing_id, pl.pack_name, COALESCE(pl.author_name, ''), pl.status, fp.sort_order
+				FROM featured_products fp
+				JOIN pack_listings pl ON pl.id = fp.listing_id
+				ORDER BY fp.sort_order ASC`)
+			if err != nil {
+				log.Printf("[handleAdminFeaturedProducts] list error: %v", err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+				return
+			}
+			defer rows.Close()
+			type FeaturedItem struct {
+				ID         int64  `json:"id"`
+				ListingID  int64  `json:"listing_id"`
+				PackName   string `json:"pack_name"`
+				AuthorName string `json:"author_name"`
+				Status     string `json:"status"`
+				SortOrder  int    `json:"sort_order"`
+			}
+			var items []FeaturedItem
+			for rows.Next() {
+				var item FeaturedItem
+				if err := rows.Scan(&item.ID, &item.ListingID, &item.PackName, &item.AuthorName, &item.Status, &item.SortOrder); err != nil {
+					continue
+				}
+				items = append(items, item)
+			}
+			if err := rows.Err(); err != nil {
+				log.Printf("[handleAdminFeaturedProducts] list rows error: %v", err)
+			}
+			if items == nil {
+				items = []FeaturedItem{}
+			}
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "data": items})
+
+		case http.MethodPost:
+			listingID, err := strconv.ParseInt(r.FormValue("listing_id"), 10, 64)
+			if err != nil || listingID <= 0 {
+				jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "invalid listing_id"})
+				return
+			}
+			// 验证分析包存在且已发布
+			var exists int
+			err = db.QueryRow(`SELECT COUNT(*) FROM pack_listings WHERE id = ? AND status = 'published'`, listingID).Scan(&exists)
+			if err != nil || exists == 0 {
+				jsonResponse(w, http.StatusNotFound, map[string]interface{}{"ok": false, "error": "product not found"})
+				return
+			}
+			var alreadyFeatured int
+			db.QueryRow(`SELECT COUNT(*) FROM featured_products WHERE listing_id = ?`, listingID).Scan(&alreadyFeatured)
+			if alreadyFeatured > 0 {
+				jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "product already featured"})
+				return
+			}
+			// 检查数量上限 (最多 16 个)
+			var count int
+			db.QueryRow(`SELECT COUNT(*) FROM featured_products`).Scan(&count)
+			if count >= 16 {
+				jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "最多设置 16 个精选产品"})
+				return
+			}
+			var maxOrder sql.NullInt64
+			db.QueryRow(`SELECT MAX(sort_order) FROM featured_products`).Scan(&maxOrder)
+			newOrder := int64(1)
+			if maxOrder.Valid {
+				newOrder = maxOrder.Int64 + 1
+			}
+			if _, err := db.Exec(`INSERT INTO featured_products (listing_id, sort_order) VALUES (?, ?)`, listingID, newOrder); err != nil {
+				log.Printf("[handleAdminFeaturedProducts] add error: %v", err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "internal_error"})
+				return
+			}
+			globalCache.InvalidateHomepage()
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+
+		default:
+			jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+		}
+		return
+	}
+
+	jsonResponse(w, http.StatusNotFound, map[string]interface{}{"ok": false, "error": "not_found"})
+}
 
 // queryHomepagePublicData 查询首页所有公共数据（不含用户相关字段）。
 // 各子查询失败时记录日志并返回空切片，不影响其他数据。
@@ -700,6 +964,12 @@ func queryHomepagePublicData() (*HomepagePublicData, error) {
 	}
 	data.FeaturedStores = featuredStores
 
+	featuredProducts, err := queryFeaturedProducts()
+	if err != nil {
+		log.Printf("queryHomepagePublicData: queryFeaturedProducts error: %v", err)
+	}
+	data.FeaturedProducts = featuredProducts
+
 	topSalesStores, err := queryTopSalesStorefronts(16)
 	if err != nil {
 		log.Printf("queryHomepagePublicData: queryTopSalesStorefronts error: %v", err)
@@ -718,6 +988,12 @@ func queryHomepagePublicData() (*HomepagePublicData, error) {
 	}
 	data.TopSalesProducts = topSalesProducts
 
+	trendingProducts, err := queryTrendingProducts(16, time.Duration(getTrendingHalfLifeDays())*24*time.Hour, time.Now())
+	if err != nil {
+		log.Printf("queryHomepagePublicData: queryTrendingProducts error: %v", err)
+	}
+	data.TrendingProducts = trendingProducts
+
 	topDownloadsProducts, err := queryTopDownloadsProducts(32)
 	if err != nil {
 		log.Printf("queryHomepagePublicData: queryTopDownloadsProducts error: %v", err)
@@ -783,14 +1059,17 @@ func handleHomepage(w http.ResponseWriter, r *http.Request) {
 	if !hit {
 		var err error
 		publicData, err = globalCache.DoHomepageQuery(func() (*HomepagePublicData, error) {
-			return queryHomepagePublicData()
+			data, err := queryHomepagePublicData()
+			if err == nil {
+				globalCache.SetHomepageData(data)
+			}
+			return data, err
 		})
 		if err != nil {
 			log.Printf("handleHomepage: queryHomepagePublicData error: %v", err)
-			// 降级：使用空数据渲染页面
+			// 降级：使用空数据渲染页面（不写入缓存，下次请求重试）
 			publicData = &HomepagePublicData{}
 		}
-		globalCache.SetHomepageData(publicData)
 	}
 
 	// 4. Assemble template data (merge cached public data with per-user fields)
@@ -808,9 +1087,11 @@ func handleHomepage(w http.ResponseWriter, r *http.Request) {
 		DownloadURLMacOS:     publicData.DownloadURLMacOS,
 		ServicePortalURL:     homepageSPURL,
 		FeaturedStores:       publicData.FeaturedStores,
+		FeaturedProducts:     publicData.FeaturedProducts,
 		TopSalesStores:       publicData.TopSalesStores,
 		TopDownloadsStores:   publicData.TopDownloadsStores,
 		TopSalesProducts:     publicData.TopSalesProducts,
+		TrendingProducts:     publicData.TrendingProducts,
 		TopDownloadsProducts: publicData.TopDownloadsProducts,
 		NewestProducts:       publicData.NewestProducts,
 		Categories:           publicData.Categories,
@@ -855,30 +1136,39 @@ type SMTPConfig struct {
 
 // StorefrontPageData 小铺公开页面模板数据
 type StorefrontPageData struct {
-	Storefront          StorefrontInfo
-	FeaturedPacks       []StorefrontPackInfo
-	Packs               []StorefrontPackInfo
-	PurchasedIDs        map[int64]bool
-	IsLoggedIn          bool
-	CurrentUserID       int64
-	DefaultLang         string
-	Filter              string
-	Sort                string
-	SearchQuery         string
-	Categories          []string
-	CategoryFilter      string
-	DownloadURLWindows  string
-	DownloadURLMacOS    string
-	Sections            []SectionConfig
-	ThemeCSS            string
-	PackGridColumns     int
-	BannerData          map[int]CustomBannerSettings
-	HeroLayout          string // "default" or "reversed"
-	IsPreviewMode       bool
-	CustomProducts      []CustomProduct
-	FeaturedVisible     bool   // 推荐分析包区块是否可见
-	SupportApproved     bool   // 店铺客户支持系统是否已开通
-	ServicePortalURL    string // 客服系统地址
+	Storefront            StorefrontInfo
+	FeaturedPacks         []StorefrontPackInfo
+	Packs                 []StorefrontPackInfo
+	PurchasedIDs          map[int64]bool
+	IsLoggedIn            bool
+	CurrentUserID         int64
+	DefaultLang           string
+	Filter                string
+	Sort                  string
+	SearchQuery           string
+	Categories            []string
+	CategoryFilter        string
+	DownloadURLWindows    string
+	DownloadURLMacOS      string
+	Sections              []SectionConfig
+	ThemeCSS              template.CSS // 由 GetThemeCSS 生成，只含预设或已校验的颜色值
+	ThemeDarkCSS          template.CSS // color_scheme 为 auto 时深色模式下的主题变量
+	PackGridColumns       int
+	PackGridMobileColumns int // 手机端分析包网格列数（1 或 2）
+	BannerData            map[int]CustomBannerSettings
+	HeroLayout            string // "default" or "reversed"
+	HeroCTA               HeroCTA
+	IsPreviewMode         bool
+	CustomProducts        []CustomProduct
+	TopupTiers            []CreditsTopupTier // 积分商品可选的充值档位
+	Lang                  string   // 访客语言，用于金额格式
+	Currencies            []string // 可选展示币种
+	DisplayCurrency       string   // 访客当前展示币种
+	FeaturedVisible       bool     // 推荐分析包区块是否可见
+	SupportApproved       bool     // 店铺客户支持系统是否已开通
+	ServicePortalURL      string   // 客服系统地址
+	IsFollowing           bool     // 当前用户是否已关注该小铺
+	IsPaused              bool     // 小铺是否暂停营业
 }
 
 // StorefrontManageData 小铺管理页面模板数据
@@ -894,8 +1184,10 @@ type StorefrontManageData struct {
 	ActiveTab              string
 	LayoutSectionsJSON     string // JSON string of current LayoutConfig for the page layout editor
 	CurrentTheme           string // Current theme identifier for the theme selector
+	CustomTheme            CustomThemeColors // Custom theme colors (pre-filled with defaults when unset)
 	CustomProductsEnabled  bool   // Whether custom products feature is enabled for this storefront
 	CustomProducts         []CustomProduct // Custom products for this storefront (non-deleted)
+	TaxRate                float64         // Tax rate in percent charged on custom products
 	DecorationFee          string // Current decoration fee setting for display
 	DecorationFeeMax       string // Maximum decoration fee limit
 	SupportStatus          string              // 支持系统状态: "none", "pending", "approved", "disabled"
@@ -956,9 +1248,21 @@ type CustomProduct struct {
 	Status             string  `json:"status"`
 	RejectReason       string  `json:"reject_reason"`
 	SortOrder          int     `json:"sort_order"`
+	StockQuantity      *int    `json:"stock_quantity"` // virtual goods only, nil = unlimited
 	DeletedAt          *string `json:"deleted_at"`
 	CreatedAt          string  `json:"created_at"`
 	UpdatedAt          string  `json:"updated_at"`
+	// 店铺页面展示用（按访客币种换算，不入库）
+	DisplayPrice    float64 `json:"-"`
+	DisplayCurrency string  `json:"-"`
+	ChargePrice     float64 `json:"-"`
+	ChargeCurrency  string  `json:"-"`
+	ChargeTax       float64 `json:"-"` // 店铺税额，ChargePrice 不含税
+	ChargeTotal     float64 `json:"-"` // 含税结算金额
+	// 限时折扣进行中时：OriginalPriceUSD 为原价，PriceUSD 为折扣价
+	OriginalPriceUSD     float64 `json:"original_price_usd,omitempty"`
+	OriginalDisplayPrice float64 `json:"-"`
+	SaleEndsAt           string  `json:"sale_ends_at,omitempty"`
 }
 
 // CustomProductOrder 自定义商品订单
@@ -969,8 +1273,12 @@ type CustomProductOrder struct {
 	PayPalOrderID       string  `json:"paypal_order_id"`
 	PayPalPaymentStatus string  `json:"paypal_payment_status"`
 	AmountUSD           float64 `json:"amount_usd"`
+	ChargedAmount       float64 `json:"charged_amount"`
+	ChargedCurrency     string  `json:"charged_currency"`
 	LicenseSN           string  `json:"license_sn"`
 	LicenseEmail        string  `json:"license_email"`
+	RecipientEmail      string  `json:"recipient_email"` // gift recipient, empty for own purchases
+	BonusCredits        int     `json:"bonus_credits"`   // top-up tier bonus, credited as a 'bonus' transaction
 	Status              string  `json:"status"`
 	CreatedAt           string  `json:"created_at"`
 	UpdatedAt           string  `json:"updated_at"`
@@ -988,20 +1296,67 @@ type PayPalConfig struct {
 	Mode         string `json:"mode"`
 }
 
-// encryptPayPalSecret encrypts plaintext using AES-GCM.
-// Key is derived from PAYPAL_ENCRYPTION_KEY env var via SHA-256.
-// Returns hex-encoded nonce+ciphertext.
-func encryptPayPalSecret(plaintext string) (string, error) {
+// Secrets stored in the database (PayPal/OAuth client secrets, admin TOTP
+// seeds) are AES-GCM encrypted with keys derived from environment variables:
+//
+//	PAYPAL_ENCRYPTION_KEY          current key, used for all new writes
+//	PAYPAL_ENCRYPTION_RETIRED_KEYS comma-separated previous keys, read-only
+//
+// Ciphertext is written as "v1:<key id>:<hex nonce+ciphertext>", where the key
+// id is a short fingerprint of the derived key, so the right key can be picked
+// after a rotation. Legacy values are bare hex without a key id; they are
+// tried against every configured key. To rotate: move the old key into
+// PAYPAL_ENCRYPTION_RETIRED_KEYS, set the new one, restart, then run the
+// re-encrypt action in the admin settings and drop the retired key.
+
+// encryptionKey is one AES-256 key derived from an environment secret.
+type encryptionKey struct {
+	ID  string
+	Key [32]byte
+}
+
+const encryptedValuePrefix = "v1:"
+
+// deriveEncryptionKey turns an environment secret into an AES key and its id.
+func deriveEncryptionKey(secret string) encryptionKey {
+	k := encryptionKey{Key: sha256.Sum256([]byte(secret))}
+	fp := sha256.Sum256(k.Key[:])
+	k.ID = hex.EncodeToString(fp[:4])
+	return k
+}
+
+// loadEncryptionKeys returns the primary key followed by any retired keys.
+func loadEncryptionKeys() (primary encryptionKey, all []encryptionKey, err error) {
 	keyStr := os.Getenv("PAYPAL_ENCRYPTION_KEY")
 	if keyStr == "" {
-		return "", fmt.Errorf("PAYPAL_ENCRYPTION_KEY not set")
+		return encryptionKey{}, nil, fmt.Errorf("PAYPAL_ENCRYPTION_KEY not set")
 	}
-	hash := sha256.Sum256([]byte(keyStr))
-	block, err := aes.NewCipher(hash[:])
+	primary = deriveEncryptionKey(keyStr)
+	all = []encryptionKey{primary}
+	for _, old := range strings.Split(os.Getenv("PAYPAL_ENCRYPTION_RETIRED_KEYS"), ",") {
+		if old = strings.TrimSpace(old); old != "" && old != keyStr {
+			all = append(all, deriveEncryptionKey(old))
+		}
+	}
+	return primary, all, nil
+}
+
+func newEncryptionGCM(k encryptionKey) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.Key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptPayPalSecret encrypts plaintext with the current key using AES-GCM.
+// Returns "v1:<key id>:<hex nonce+ciphertext>".
+func encryptPayPalSecret(plaintext string) (string, error) {
+	primary, _, err := loadEncryptionKeys()
 	if err != nil {
 		return "", err
 	}
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newEncryptionGCM(primary)
 	if err != nil {
 		return "", err
 	}
@@ -1010,38 +1365,167 @@ func encryptPayPalSecret(plaintext string) (string, error) {
 		return "", err
 	}
 	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return hex.EncodeToString(ciphertext), nil
+	return encryptedValuePrefix + primary.ID + ":" + hex.EncodeToString(ciphertext), nil
 }
 
-// decryptPayPalSecret decrypts hex-encoded AES-GCM ciphertext.
+// decryptPayPalSecret decrypts a value written by encryptPayPalSecret with
+// the current or a retired key, including legacy unversioned hex values.
 func decryptPayPalSecret(ciphertextHex string) (string, error) {
-	keyStr := os.Getenv("PAYPAL_ENCRYPTION_KEY")
-	if keyStr == "" {
-		return "", fmt.Errorf("PAYPAL_ENCRYPTION_KEY not set")
-	}
-	hash := sha256.Sum256([]byte(keyStr))
-	block, err := aes.NewCipher(hash[:])
+	_, keys, err := loadEncryptionKeys()
 	if err != nil {
 		return "", err
 	}
-	gcm, err := cipher.NewGCM(block)
+	keyID, payload := encryptedValueKeyID(ciphertextHex)
+	data, err := hex.DecodeString(payload)
 	if err != nil {
 		return "", err
 	}
-	data, err := hex.DecodeString(ciphertextHex)
+	lastErr := fmt.Errorf("no configured key matches key id %q", keyID)
+	for _, k := range keys {
+		if keyID != "" && k.ID != keyID {
+			continue
+		}
+		gcm, err := newEncryptionGCM(k)
+		if err != nil {
+			return "", err
+		}
+		nonceSize := gcm.NonceSize()
+		if len(data) < nonceSize {
+			return "", fmt.Errorf("ciphertext too short")
+		}
+		plaintext, err := gcm.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+		if err == nil {
+			return string(plaintext), nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// encryptedValueKeyID splits a stored value into its key id ("" for legacy
+// values) and hex payload.
+func encryptedValueKeyID(value string) (keyID, payload string) {
+	if strings.HasPrefix(value, encryptedValuePrefix) {
+		rest := value[len(encryptedValuePrefix):]
+		if i := strings.Index(rest, ":"); i >= 0 {
+			return rest[:i], rest[i+1:]
+		}
+	}
+	return "", value
+}
+
+// reencryptSecret rewrites value under the current key. changed is false when
+// it already uses the current key.
+func reencryptSecret(value string) (newValue string, changed bool, err error) {
+	primary, _, err := loadEncryptionKeys()
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
+	if keyID, _ := encryptedValueKeyID(value); keyID == primary.ID {
+		return value, false, nil
 	}
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := decryptPayPalSecret(value)
 	if err != nil {
-		return "", err
+		return "", false, err
+	}
+	newValue, err = encryptPayPalSecret(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return newValue, true, nil
+}
+
+// encryptedSecretColumns lists every place an encrypted value is stored, as
+// (table, id column, value column, filter). Add new encrypted columns here so
+// key rotation covers them.
+var encryptedSecretColumns = []struct {
+	Table, IDColumn, ValueColumn, Where string
+}{
+	{"settings", "key", "value", "key IN ('paypal_client_secret', 'oauth_google_client_secret', 'oauth_github_client_secret', 'pack_storage_s3_secret', 'pack_scan_api_key')"},
+	{"admin_credentials", "id", "totp_secret", "COALESCE(totp_secret, '') != ''"},
+	{"kyc_submissions", "id", "document_data", "COALESCE(document_data, '') != ''"},
+}
+
+// reencryptStoredSecrets rewrites all stored secrets under the current key.
+// Values that fail to decrypt are counted and left untouched.
+func reencryptStoredSecrets() (updated, skipped, failed int, err error) {
+	for _, c := range encryptedSecretColumns {
+		rows, err := db.Query(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s", c.IDColumn, c.ValueColumn, c.Table, c.Where))
+		if err != nil {
+			return updated, skipped, failed, fmt.Errorf("query %s: %w", c.Table, err)
+		}
+		type row struct {
+			id    interface{}
+			value string
+		}
+		var pending []row
+		for rows.Next() {
+			var rw row
+			if err := rows.Scan(&rw.id, &rw.value); err == nil && rw.value != "" {
+				pending = append(pending, rw)
+			}
+		}
+		rows.Close()
+
+		for _, rw := range pending {
+			newValue, changed, err := reencryptSecret(rw.value)
+			if err != nil {
+				log.Printf("[KEY-ROTATION] cannot re-encrypt %s.%s (%v): %v", c.Table, c.ValueColumn, rw.id, err)
+				failed++
+				continue
+			}
+			if !changed {
+				skipped++
+				continue
+			}
+			if _, err := db.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ? AND %s = ?", c.Table, c.ValueColumn, c.IDColumn, c.ValueColumn),
+				newValue, rw.id, rw.value); err != nil {
+				log.Printf("[KEY-ROTATION] failed to update %s.%s (%v): %v", c.Table, c.ValueColumn, rw.id, err)
+				failed++
+				continue
+			}
+			updated++
+		}
+	}
+	return updated, skipped, failed, nil
+}
+
+// handleAdminEncryptionKeys handles /admin/settings/encryption.
+// GET reports the current key id and the number of retired keys;
+// POST re-encrypts every stored secret with the current key.
+func handleAdminEncryptionKeys(w http.ResponseWriter, r *http.Request) {
+	primary, keys, err := loadEncryptionKeys()
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "服务器加密配置错误"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"key_id":       primary.ID,
+			"retired_keys": len(keys) - 1,
+		})
+	case http.MethodPost:
+		updated, skipped, failed, err := reencryptStoredSecrets()
+		if err != nil {
+			log.Printf("[KEY-ROTATION] re-encrypt failed: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+		detail := fmt.Sprintf("key %s: %d updated, %d already current, %d failed", primary.ID, updated, skipped, failed)
+		log.Printf("[KEY-ROTATION] %s", detail)
+		recordAuditLog(adminID, "reencrypt_secrets", "key:"+primary.ID, detail, getClientIP(r))
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"ok":      true,
+			"key_id":  primary.ID,
+			"updated": updated,
+			"skipped": skipped,
+			"failed":  failed,
+		})
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 	}
-	return string(plaintext), nil
 }
 
 // maskPayPalSecret masks a secret string showing only first 4 and last 4 chars.
@@ -1133,9 +1617,17 @@ func handleAdminPayPalSettings(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// paypalAPIBaseURL overrides the PayPal API base URL when set. Only tests set
+// it, to point at a mock; there is deliberately no environment or settings
+// hook, so a deployment cannot be redirected away from PayPal.
+var paypalAPIBaseURL string
+
 // getPayPalBaseURL returns the PayPal API base URL based on mode.
 // sandbox uses https://api-m.sandbox.paypal.com, live uses https://api-m.paypal.com.
 func getPayPalBaseURL(mode string) string {
+	if paypalAPIBaseURL != "" {
+		return paypalAPIBaseURL
+	}
 	if mode == "live" {
 		return "https://api-m.paypal.com"
 	}
@@ -1177,16 +1669,41 @@ func getPayPalAccessToken(config PayPalConfig) (string, error) {
 	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
 		return "", fmt.Errorf("failed to parse token response: %w", err)
 	}
-	if tokenResp.AccessToken == "" {
-		return "", fmt.Errorf("empty access token in PayPal response")
+	if tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("empty access token in PayPal response")
+	}
+
+	return tokenResp.AccessToken, nil
+}
+
+// payPalOrderBody builds the Create Order request body. A non-zero tax is
+// itemized in the amount breakdown, and the order value is the item total
+// plus tax.
+func payPalOrderBody(currency string, itemTotal, taxTotal float64, description string) map[string]interface{} {
+	amount := map[string]interface{}{
+		"currency_code": currency,
+		"value":         i18n.FormatAmount(itemTotal+taxTotal, currency),
+	}
+	if taxTotal > 0 {
+		amount["breakdown"] = map[string]interface{}{
+			"item_total": map[string]string{"currency_code": currency, "value": i18n.FormatAmount(itemTotal, currency)},
+			"tax_total":  map[string]string{"currency_code": currency, "value": i18n.FormatAmount(taxTotal, currency)},
+		}
+	}
+	return map[string]interface{}{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]interface{}{
+			{
+				"amount":      amount,
+				"description": description,
+			},
+		},
 	}
-
-	return tokenResp.AccessToken, nil
 }
 
 // createPayPalOrder calls the PayPal Create Order API.
 // Returns the PayPal order ID and the approval URL for user redirect.
-func createPayPalOrder(config PayPalConfig, amountUSD string, description string) (orderID string, approveURL string, err error) {
+func createPayPalOrder(config PayPalConfig, currency string, itemTotal, taxTotal float64, description string) (orderID string, approveURL string, err error) {
 	accessToken, err := getPayPalAccessToken(config)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get access token: %w", err)
@@ -1195,20 +1712,7 @@ func createPayPalOrder(config PayPalConfig, amountUSD string, description string
 	baseURL := getPayPalBaseURL(config.Mode)
 	orderURL := baseURL + "/v2/checkout/orders"
 
-	orderBody := map[string]interface{}{
-		"intent": "CAPTURE",
-		"purchase_units": []map[string]interface{}{
-			{
-				"amount": map[string]string{
-					"currency_code": "USD",
-					"value":         amountUSD,
-				},
-				"description": description,
-			},
-		},
-	}
-
-	bodyBytes, err := json.Marshal(orderBody)
+	bodyBytes, err := json.Marshal(payPalOrderBody(currency, itemTotal, taxTotal, description))
 	if err != nil {
 		return "", "", fmt.Errorf("failed to marshal order body: %w", err)
 	}
@@ -1308,52 +1812,6 @@ func capturePayPalOrder(config PayPalConfig, orderID string) (status string, err
 	return captureResp.Status, nil
 }
 
-// callLicenseAPI calls an external License API to bind a license SN to a user email.
-// Request body: {"api_key": "...", "email": "...", "product_id": "..."}
-// Returns the license SN from the response.
-// Timeout: 10 seconds.
-func callLicenseAPI(endpoint, apiKey, email, productID string) (sn string, err error) {
-	reqBody := map[string]string{
-		"api_key":    apiKey,
-		"email":      email,
-		"product_id": productID,
-	}
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal license API request: %w", err)
-	}
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(bodyBytes))
-	if err != nil {
-		return "", fmt.Errorf("license API request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read license API response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("license API returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var result struct {
-		SN string `json:"sn"`
-	}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", fmt.Errorf("failed to parse license API response: %w", err)
-	}
-
-	if result.SN == "" {
-		return "", fmt.Errorf("license API returned empty SN")
-	}
-
-	return result.SN, nil
-}
-
-
 // handleCustomProductPurchase handles purchasing a custom product via PayPal.
 // POST /custom-product/{id}/purchase
 // Validates product exists and is published, reads PayPal config, creates PayPal order,
@@ -1380,6 +1838,34 @@ func handleCustomProductPurchase(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 		return
 	}
+	// Order emails are sent later in the language the buyer is using now.
+	rememberUserLang(userID, i18n.DetectLang(r))
+
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid Idempotency-Key"})
+		return
+	}
+
+	// Optional gift recipient; the payer stays the order owner.
+	var reqBody struct {
+		RecipientEmail string `json:"recipient_email"`
+		TierID         int64  `json:"tier_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && err != io.EOF {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的请求数据"})
+		return
+	}
+	recipientEmail := strings.ToLower(strings.TrimSpace(reqBody.RecipientEmail))
+	if recipientEmail != "" {
+		if !isValidEmailAddress(recipientEmail) {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "受赠人邮箱格式无效"})
+			return
+		}
+		if strings.EqualFold(recipientEmail, getEmailForUser(userID)) {
+			recipientEmail = "" // buying for oneself
+		}
+	}
 
 	// Query product: must exist, be published, and not soft-deleted
 	var product CustomProduct
@@ -1399,6 +1885,36 @@ func handleCustomProductPurchase(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
+	if isStorefrontPaused(product.StorefrontID) {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "小铺暂停营业中，暂不接受购买"})
+		return
+	}
+	if product.ProductType == "virtual_goods" && !customProductInStock(product.ID) {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "商品已售罄"})
+		return
+	}
+	product.PriceUSD = customProductPriceAt(product.ID, product.PriceUSD, time.Now())
+
+	// A credits product bought at a top-up tier charges the tier price instead
+	var tierCredits, tierBonus int
+	orderDescription := product.ProductName
+	if reqBody.TierID > 0 {
+		if product.ProductType != "credits" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "该商品不支持充值档位"})
+			return
+		}
+		tier, err := loadTopupTier(reqBody.TierID)
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "充值档位已变更，请刷新页面后重试"})
+			return
+		} else if err != nil {
+			log.Printf("[handleCustomProductPurchase] load topup tier error: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		product.PriceUSD, tierCredits, tierBonus = tier.PriceUSD, tier.Credits, tier.BonusCredits
+		orderDescription = fmt.Sprintf("%s (%d Credits)", product.ProductName, tier.TotalCredits())
+	}
 
 	// Read PayPal config from settings
 	clientID := getSetting("paypal_client_id")
@@ -1424,27 +1940,71 @@ func handleCustomProductPurchase(w http.ResponseWriter, r *http.Request) {
 		Mode:         mode,
 	}
 
-	// Create PayPal order
-	amountStr := fmt.Sprintf("%.2f", product.PriceUSD)
-	orderID, approveURL, err := createPayPalOrder(config, amountStr, product.ProductName)
-	if err != nil {
-		log.Printf("[handleCustomProductPurchase] create PayPal order error: %v", err)
-		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "创建支付订单失败，请重试"})
+	// Create PayPal order in the settlement currency
+	currency := settlementCurrency()
+	chargeAmount, ok := convertUSD(product.PriceUSD, currency)
+	if !ok {
+		log.Printf("[handleCustomProductPurchase] no exchange rate for settlement currency %s", currency)
+		jsonResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "汇率暂不可用，请稍后重试"})
 		return
 	}
+	taxAmount := computeTax(chargeAmount, storefrontTaxRate(product.StorefrontID), currency)
 
-	// Insert order record into custom_product_orders
-	_, err = db.Exec(`INSERT INTO custom_product_orders (custom_product_id, user_id, paypal_order_id, amount_usd, status, created_at, updated_at)
-		VALUES (?, ?, ?, ?, 'pending', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
-		product.ID, userID, orderID, product.PriceUSD)
+	createOrder := func() (interface{}, error) {
+		// A replayed Idempotency-Key gets the order created the first time.
+		if idempotencyKey != "" {
+			prev, err := findIdempotentPurchase(userID, idempotencyKey, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			if prev != nil {
+				return prev, nil
+			}
+		}
+		orderID, approveURL, err := createPayPalOrder(config, currency, chargeAmount, taxAmount, orderDescription)
+		if err != nil {
+			return nil, fmt.Errorf("create PayPal order: %w", err)
+		}
+		var key interface{}
+		if idempotencyKey != "" {
+			key = idempotencyKey
+		}
+		// Insert order record into custom_product_orders; charged_amount includes tax
+		if _, err := db.Exec(`INSERT INTO custom_product_orders (custom_product_id, user_id, paypal_order_id, amount_usd, charged_amount, charged_currency, tax_amount, recipient_email, credits_amount, bonus_credits, idempotency_key, approve_url, status, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+			product.ID, userID, orderID, product.PriceUSD, chargeAmount+taxAmount, currency, taxAmount, recipientEmail, tierCredits, tierBonus, key, approveURL); err != nil {
+			return nil, fmt.Errorf("insert order: %w", err)
+		}
+		return &idempotentPurchase{ProductID: product.ID, Status: "pending", ApproveURL: approveURL}, nil
+	}
+	var result interface{}
+	if idempotencyKey != "" {
+		result, err, _ = purchaseFlights.Do(purchaseFlightKey(userID, idempotencyKey), createOrder)
+	} else {
+		result, err = createOrder()
+	}
 	if err != nil {
-		log.Printf("[handleCustomProductPurchase] insert order error: %v", err)
-		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		log.Printf("[handleCustomProductPurchase] %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "创建支付订单失败，请重试"})
+		return
+	}
+	purchase := result.(*idempotentPurchase)
+	if purchase.ProductID != product.ID {
+		jsonResponse(w, http.StatusUnprocessableEntity, map[string]string{"error": "Idempotency-Key already used for another product"})
 		return
 	}
 
-	// Return approve URL for frontend redirect
-	jsonResponse(w, http.StatusOK, map[string]string{"approve_url": approveURL})
+	// A replayed key only gets the approve URL back while its order can still
+	// be paid; after that it gets the order's outcome.
+	switch purchase.Status {
+	case "pending":
+		// Return approve URL for frontend redirect
+		jsonResponse(w, http.StatusOK, map[string]string{"approve_url": purchase.ApproveURL})
+	case "paid", "fulfilled":
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "该订单已支付，请勿重复购买", "status": "completed"})
+	default:
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "该订单支付未成功，请重新发起购买", "status": "failed"})
+	}
 }
 
 // handlePayPalReturn handles the PayPal return callback after user completes payment.
@@ -1465,9 +2025,11 @@ func handlePayPalReturn(w http.ResponseWriter, r *http.Request) {
 
 	// Look up order by paypal_order_id
 	var order CustomProductOrder
-	err := db.QueryRow(`SELECT id, custom_product_id, user_id, paypal_order_id, status
+	err := db.QueryRow(`SELECT id, custom_product_id, user_id, paypal_order_id, COALESCE(recipient_email, ''),
+		COALESCE(credits_amount, 0), COALESCE(bonus_credits, 0), status
 		FROM custom_product_orders WHERE paypal_order_id = ?`, token).Scan(
-		&order.ID, &order.CustomProductID, &order.UserID, &order.PayPalOrderID, &order.Status,
+		&order.ID, &order.CustomProductID, &order.UserID, &order.PayPalOrderID, &order.RecipientEmail,
+		&order.CreditsAmount, &order.BonusCredits, &order.Status,
 	)
 	if err == sql.ErrNoRows {
 		http.Error(w, "无效的支付回调", http.StatusBadRequest)
@@ -1479,6 +2041,23 @@ func handlePayPalReturn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A reload of the return URL must not capture or fulfill the order again.
+	if order.Status == "paid" || order.Status == "fulfilled" {
+		var storeSlug string
+		db.QueryRow(`SELECT s.store_slug FROM custom_products p JOIN author_storefronts s ON s.id = p.storefront_id WHERE p.id = ?`,
+			order.CustomProductID).Scan(&storeSlug)
+		msg := "购买成功，授权绑定处理中，请稍后查看订单状态"
+		if order.Status == "fulfilled" {
+			msg = "购买成功"
+		}
+		if storeSlug != "" {
+			http.Redirect(w, r, "/store/"+storeSlug+"?success="+url.QueryEscape(msg), http.StatusFound)
+		} else {
+			http.Error(w, msg, http.StatusOK)
+		}
+		return
+	}
+
 	// Read PayPal config
 	clientID := getSetting("paypal_client_id")
 	encryptedSecret := getSetting("paypal_client_secret")
@@ -1518,6 +2097,10 @@ func handlePayPalReturn(w http.ResponseWriter, r *http.Request) {
 	if dbErr != nil {
 		log.Printf("[handlePayPalReturn] query product error: %v", dbErr)
 	}
+	if order.CreditsAmount > 0 {
+		// Bought at a top-up tier
+		product.CreditsAmount = order.CreditsAmount
+	}
 
 	// Get storefront slug for redirect
 	var storeSlug string
@@ -1534,6 +2117,8 @@ func handlePayPalReturn(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[handlePayPalReturn] capture failed for order %d: status=%s, err=%v", order.ID, captureStatus, err)
 		if _, dbErr := db.Exec(`UPDATE custom_product_orders SET status='failed', updated_at=CURRENT_TIMESTAMP WHERE id=?`, order.ID); dbErr != nil {
 			log.Printf("[handlePayPalReturn] failed to update order %d to failed status: %v", order.ID, dbErr)
+		} else {
+			go sendOrderStatusEmail(order.ID)
 		}
 
 		if storeSlug != "" {
@@ -1545,9 +2130,11 @@ func handlePayPalReturn(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Payment succeeded: update order paypal_payment_status and status
-	_, err = db.Exec(`UPDATE custom_product_orders SET paypal_payment_status='COMPLETED', status='paid', updated_at=CURRENT_TIMESTAMP WHERE id=?`, order.ID)
+	_, err = db.Exec(`UPDATE custom_product_orders SET paypal_payment_status='COMPLETED', status='paid', paid_at=CURRENT_TIMESTAMP, updated_at=CURRENT_TIMESTAMP WHERE id=?`, order.ID)
 	if err != nil {
 		log.Printf("[handlePayPalReturn] update order status error: %v", err)
+	} else {
+		publishCustomOrderEvent(order.ID)
 	}
 
 	// Fulfillment logic
@@ -1559,60 +2146,63 @@ func handlePayPalReturn(w http.ResponseWriter, r *http.Request) {
 			log.Printf("[handlePayPalReturn] begin tx for credits fulfillment failed for order %d: %v", order.ID, txErr)
 			successMsg = "购买成功"
 		} else {
-			err := addWalletBalance(tx, order.UserID, float64(product.CreditsAmount))
+			err := creditCustomProductOrder(tx, order, product)
 			if err != nil {
 				tx.Rollback()
 				log.Printf("[handlePayPalReturn] credits fulfillment failed for order %d: %v", order.ID, err)
 				// Keep status=paid, admin will handle manually
 				successMsg = "购买成功"
 			} else {
-				// Record transaction
-				description := fmt.Sprintf("购买商品「%s」充值 %d 积分", product.ProductName, product.CreditsAmount)
-				_, err = tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, description, created_at)
-					VALUES (?, 'purchase', ?, ?, CURRENT_TIMESTAMP)`,
-					order.UserID, product.CreditsAmount, description)
+				// Update order to fulfilled
+				_, err = tx.Exec(`UPDATE custom_product_orders SET status='fulfilled', updated_at=CURRENT_TIMESTAMP WHERE id=?`, order.ID)
 				if err != nil {
 					tx.Rollback()
-					log.Printf("[handlePayPalReturn] record credits transaction failed for order %d: %v", order.ID, err)
+					log.Printf("[handlePayPalReturn] update order to fulfilled failed for order %d: %v", order.ID, err)
 					successMsg = "购买成功"
 				} else {
-					// Update order to fulfilled
-					_, err = tx.Exec(`UPDATE custom_product_orders SET status='fulfilled', updated_at=CURRENT_TIMESTAMP WHERE id=?`, order.ID)
-					if err != nil {
-						tx.Rollback()
-						log.Printf("[handlePayPalReturn] update order to fulfilled failed for order %d: %v", order.ID, err)
+					if commitErr := tx.Commit(); commitErr != nil {
+						log.Printf("[handlePayPalReturn] commit credits fulfillment failed for order %d: %v", order.ID, commitErr)
 						successMsg = "购买成功"
 					} else {
-						if commitErr := tx.Commit(); commitErr != nil {
-							log.Printf("[handlePayPalReturn] commit credits fulfillment failed for order %d: %v", order.ID, commitErr)
-							successMsg = "购买成功"
-						} else {
-							successMsg = fmt.Sprintf("购买成功，已充值 %d 积分", product.CreditsAmount)
+						successMsg = fmt.Sprintf("购买成功，已充值 %d 积分", product.CreditsAmount+order.BonusCredits)
+						if order.RecipientEmail != "" {
+							successMsg = fmt.Sprintf("购买成功，已为 %s 充值 %d 积分", order.RecipientEmail, product.CreditsAmount+order.BonusCredits)
 						}
+						go sendOrderStatusEmail(order.ID)
 					}
 				}
 			}
 		}
 	} else if product.ProductType == "virtual_goods" {
 		// Virtual goods fulfillment: call License API to bind SN
-		userEmail := getEmailForUser(order.UserID)
-		if userEmail == "" {
+		userEmail := orderLicenseEmail(order.UserID, order.RecipientEmail)
+		if !customProductInStock(product.ID) {
+			log.Printf("[handlePayPalReturn] product %d sold out before order %d was fulfilled", product.ID, order.ID)
+			enqueueFulfillment(order.ID, fulfillmentSoldOut)
+			successMsg = "购买成功，商品暂时缺货，店主补货后将自动发放授权"
+		} else if userEmail == "" {
 			log.Printf("[handlePayPalReturn] user %d has no email, cannot fulfill virtual goods order %d", order.UserID, order.ID)
+			enqueueFulfillment(order.ID, "buyer has no email")
 			successMsg = "购买成功，授权绑定处理中，请稍后查看订单状态"
 		} else {
 			sn, licErr := callLicenseAPI(product.LicenseAPIEndpoint, product.LicenseAPIKey, userEmail, product.LicenseProductID)
 			if licErr != nil {
 				log.Printf("[handlePayPalReturn] license API call failed for order %d: %v", order.ID, licErr)
-				// Keep status=paid, user can check later
+				// Keep status=paid; the fulfillment worker retries in the background
+				enqueueFulfillment(order.ID, licErr.Error())
 				successMsg = "购买成功，授权绑定处理中，请稍后查看订单状态"
 			} else {
-				_, dbErr := db.Exec(`UPDATE custom_product_orders SET license_sn=?, license_email=?, status='fulfilled', updated_at=CURRENT_TIMESTAMP WHERE id=?`,
-					sn, userEmail, order.ID)
+				fulfilled, dbErr := fulfillVirtualGoodsOrder(order.ID, product.ID, sn, userEmail)
 				if dbErr != nil {
-					log.Printf("[handlePayPalReturn] update order license info failed for order %d: %v", order.ID, dbErr)
+					log.Printf("[handlePayPalReturn] update order license info failed for order %d (SN %s): %v", order.ID, sn, dbErr)
 					successMsg = "购买成功，授权绑定处理中，请稍后查看订单状态"
+				} else if !fulfilled {
+					// The fulfillment worker bound a license first; that SN is the buyer's.
+					log.Printf("[handlePayPalReturn] order %d was fulfilled concurrently; SN %s not recorded", order.ID, sn)
+					successMsg = "购买成功，授权已绑定，请查看订单状态"
 				} else {
 					successMsg = fmt.Sprintf("购买成功，授权 SN: %s 已绑定到 %s", sn, userEmail)
+					go sendOrderStatusEmail(order.ID)
 				}
 			}
 		}
@@ -1635,6 +2225,9 @@ func validateCustomProduct(p CustomProduct) string {
 	if nameLen < 2 || nameLen > 100 {
 		return "商品名称长度必须在 2 到 100 个字符之间"
 	}
+	if msg, _ := checkUserContent(p.ProductName, p.Description); msg != "" {
+		return msg
+	}
 	if p.PriceUSD <= 0 || p.PriceUSD > 9999.99 {
 		return "价格必须为正数且不超过 9999.99 美元"
 	}
@@ -1891,6 +2484,9 @@ func handleAdminCustomProductReject(w http.ResponseWriter, r *http.Request) {
 //   POST /user/storefront/custom-products/delete    — soft delete product (task 5.2)
 //   POST /user/storefront/custom-products/delist    — delist product (task 5.2)
 //   POST /user/storefront/custom-products/submit    — submit for review (task 5.2)
+//   POST /user/storefront/custom-products/import    — bulk CSV import
+//   POST /user/storefront/custom-products/sale      — set or clear a flash sale
+//   POST /user/storefront/custom-products/stock     — set or clear the stock of virtual goods
 func handleCustomProductCRUD(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.Header.Get("X-User-ID")
 	userID, err := strconv.ParseInt(userIDStr, 10, 64)
@@ -1918,6 +2514,12 @@ func handleCustomProductCRUD(w http.ResponseWriter, r *http.Request) {
 		handleCustomProductSubmit(w, r, userID)
 	case path == "/reorder" && r.Method == http.MethodPost:
 		handleCustomProductReorder(w, r, userID)
+	case path == "/import" && r.Method == http.MethodPost:
+		handleCustomProductImport(w, r, userID)
+	case path == "/sale" && r.Method == http.MethodPost:
+		handleCustomProductSale(w, r, userID)
+	case path == "/stock" && r.Method == http.MethodPost:
+		handleCustomProductStock(w, r, userID)
 	default:
 		http.NotFound(w, r)
 	}
@@ -1953,7 +2555,7 @@ func handleCustomProductList(w http.ResponseWriter, r *http.Request, userID int6
 		`SELECT id, storefront_id, product_name, COALESCE(description, ''), product_type,
 			price_usd, COALESCE(credits_amount, 0),
 			COALESCE(license_api_endpoint, ''), COALESCE(license_api_key, ''), COALESCE(license_product_id, ''),
-			status, COALESCE(reject_reason, ''), COALESCE(sort_order, 0),
+			status, COALESCE(reject_reason, ''), COALESCE(sort_order, 0), stock_quantity,
 			created_at, COALESCE(updated_at, '')
 		FROM custom_products
 		WHERE storefront_id = ? AND deleted_at IS NULL
@@ -1974,7 +2576,7 @@ func handleCustomProductList(w http.ResponseWriter, r *http.Request, userID int6
 			&p.ID, &p.StorefrontID, &p.ProductName, &p.Description, &p.ProductType,
 			&p.PriceUSD, &p.CreditsAmount,
 			&p.LicenseAPIEndpoint, &p.LicenseAPIKey, &p.LicenseProductID,
-			&p.Status, &p.RejectReason, &p.SortOrder,
+			&p.Status, &p.RejectReason, &p.SortOrder, &p.StockQuantity,
 			&p.CreatedAt, &p.UpdatedAt,
 		); err != nil {
 			log.Printf("[handleCustomProductList] scan product error: %v", err)
@@ -1985,6 +2587,10 @@ func handleCustomProductList(w http.ResponseWriter, r *http.Request, userID int6
 	if err := rows.Err(); err != nil {
 		log.Printf("[handleCustomProductList] rows iteration error: %v", err)
 	}
+	productIDs := make([]int64, len(products))
+	for i, p := range products {
+		productIDs[i] = p.ID
+	}
 
 	// Render the custom products management page
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -1992,6 +2598,7 @@ func handleCustomProductList(w http.ResponseWriter, r *http.Request, userID int6
 		"StorefrontID": storefrontID,
 		"StoreName":    storeName,
 		"Products":     products,
+		"Sales":        loadFlashSales("custom_products", productIDs),
 		"ErrorMsg":     r.URL.Query().Get("error"),
 		"SuccessMsg":   r.URL.Query().Get("success"),
 	}); err != nil {
@@ -2035,8 +2642,8 @@ func handleCustomProductCreate(w http.ResponseWriter, r *http.Request, userID in
 		http.Error(w, "加载数据失败", http.StatusInternalServerError)
 		return
 	}
-	if productCount >= 50 {
-		http.Redirect(w, r, "/user/storefront/custom-products?error="+url.QueryEscape("自定义商品数量已达上限（50 个）"), http.StatusFound)
+	if productCount >= maxCustomProducts {
+		http.Redirect(w, r, "/user/storefront/custom-products?error="+url.QueryEscape(fmt.Sprintf("自定义商品数量已达上限（%d 个）", maxCustomProducts)), http.StatusFound)
 		return
 	}
 
@@ -2078,7 +2685,7 @@ func handleCustomProductCreate(w http.ResponseWriter, r *http.Request, userID in
 	).Scan(&maxSortOrder)
 
 	// Insert into custom_products with status=draft
-	_, err = db.Exec(
+	result, err := db.Exec(
 		`INSERT INTO custom_products (storefront_id, product_name, description, product_type, price_usd,
 			credits_amount, license_api_endpoint, license_api_key, license_product_id,
 			status, sort_order, created_at, updated_at)
@@ -2096,6 +2703,11 @@ func handleCustomProductCreate(w http.ResponseWriter, r *http.Request, userID in
 		http.Error(w, "创建商品失败", http.StatusInternalServerError)
 		return
 	}
+	if _, flag := checkUserContent(product.ProductName, product.Description); flag != "" {
+		if productID, err := result.LastInsertId(); err == nil {
+			flagUserContent("custom_product", productID, flag, product.ProductName)
+		}
+	}
 
 	// Invalidate storefront cache after creating a custom product
 	var slug string
@@ -2132,9 +2744,9 @@ func handleCustomProductUpdate(w http.ResponseWriter, r *http.Request, userID in
 	// Query the product and verify ownership
 	var product CustomProduct
 	err = db.QueryRow(
-		"SELECT id, storefront_id, status FROM custom_products WHERE id = ? AND deleted_at IS NULL",
+		"SELECT id, storefront_id, status, price_usd FROM custom_products WHERE id = ? AND deleted_at IS NULL",
 		productID,
-	).Scan(&product.ID, &product.StorefrontID, &product.Status)
+	).Scan(&product.ID, &product.StorefrontID, &product.Status, &product.PriceUSD)
 	if err == sql.ErrNoRows {
 		http.Error(w, "商品不存在", http.StatusNotFound)
 		return
@@ -2198,6 +2810,9 @@ func handleCustomProductUpdate(w http.ResponseWriter, r *http.Request, userID in
 		http.Error(w, "更新商品失败", http.StatusInternalServerError)
 		return
 	}
+	logPriceChange("custom_product", productID, userID, product.PriceUSD, updated.PriceUSD)
+	_, flag := checkUserContent(updated.ProductName, updated.Description)
+	flagUserContent("custom_product", productID, flag, updated.ProductName)
 
 	// Invalidate storefront cache after updating a custom product
 	var slug string
@@ -2511,44 +3126,45 @@ func handleCustomProductReorder(w http.ResponseWriter, r *http.Request, userID i
 	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
 }
 
-// handleStorefrontCustomProductOrders handles GET /user/storefront/custom-product-orders.
-// Shows all custom product orders for the current user's storefront with optional filtering.
-func handleStorefrontCustomProductOrders(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// customProductOrderFilter holds the optional filters of the storefront
+// custom product orders page and its export.
+type customProductOrderFilter struct {
+	ProductName string
+	Status      string
+	DateFrom    string // YYYY-MM-DD, inclusive
+	DateTo      string // YYYY-MM-DD, inclusive
+}
 
-	userIDStr := r.Header.Get("X-User-ID")
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
-	if err != nil {
-		http.Redirect(w, r, "/user/login", http.StatusFound)
-		return
+// parseCustomProductOrderFilter reads the filters from the query string.
+// Malformed dates are ignored.
+func parseCustomProductOrderFilter(r *http.Request) customProductOrderFilter {
+	q := r.URL.Query()
+	f := customProductOrderFilter{
+		ProductName: strings.TrimSpace(q.Get("product_name")),
+		Status:      strings.TrimSpace(q.Get("status")),
 	}
-
-	// Get user's storefront
-	var storefrontID int64
-	err = db.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID)
-	if err == sql.ErrNoRows {
-		http.Error(w, "您尚未创建小铺", http.StatusForbidden)
-		return
+	if d := strings.TrimSpace(q.Get("date_from")); d != "" {
+		if _, err := time.Parse("2006-01-02", d); err == nil {
+			f.DateFrom = d
+		}
 	}
-	if err != nil {
-		log.Printf("[handleStorefrontCustomProductOrders] query storefront error: %v", err)
-		http.Error(w, "加载数据失败", http.StatusInternalServerError)
-		return
+	if d := strings.TrimSpace(q.Get("date_to")); d != "" {
+		if _, err := time.Parse("2006-01-02", d); err == nil {
+			f.DateTo = d
+		}
 	}
+	return f
+}
 
-	// Read optional filter params
-	filterProductName := strings.TrimSpace(r.URL.Query().Get("product_name"))
-	filterStatus := strings.TrimSpace(r.URL.Query().Get("status"))
-
-	// Build query with optional filters
+// queryStorefrontCustomProductOrders returns the storefront's custom product
+// orders matching the filter, newest first.
+func queryStorefrontCustomProductOrders(storefrontID int64, filter customProductOrderFilter) ([]CustomProductOrder, error) {
 	query := `SELECT o.id, o.custom_product_id, o.user_id, COALESCE(o.paypal_order_id, ''),
 		COALESCE(o.paypal_payment_status, ''), o.amount_usd,
-		COALESCE(o.license_sn, ''), COALESCE(o.license_email, ''),
+		COALESCE(o.charged_amount, o.amount_usd), COALESCE(o.charged_currency, 'USD'),
+		COALESCE(o.license_sn, ''), COALESCE(o.license_email, ''), COALESCE(o.recipient_email, ''),
 		o.status, o.created_at, COALESCE(o.updated_at, ''),
-		p.product_name, p.product_type, COALESCE(p.credits_amount, 0),
+		p.product_name, p.product_type, `+orderCreditsSQL+`,
 		COALESCE(u.email, '') as buyer_email
 		FROM custom_product_orders o
 		JOIN custom_products p ON o.custom_product_id = p.id
@@ -2556,23 +3172,29 @@ func handleStorefrontCustomProductOrders(w http.ResponseWriter, r *http.Request)
 		WHERE p.storefront_id = ?`
 	args := []interface{}{storefrontID}
 
-	if filterProductName != "" {
+	if filter.ProductName != "" {
 		query += " AND p.product_name LIKE ? ESCAPE '\\'"
-		escaped := strings.NewReplacer("%", "\\%", "_", "\\_").Replace(filterProductName)
+		escaped := strings.NewReplacer("%", "\\%", "_", "\\_").Replace(filter.ProductName)
 		args = append(args, "%"+escaped+"%")
 	}
-	if filterStatus != "" {
+	if filter.Status != "" {
 		query += " AND o.status = ?"
-		args = append(args, filterStatus)
+		args = append(args, filter.Status)
+	}
+	if filter.DateFrom != "" {
+		query += " AND date(o.created_at) >= ?"
+		args = append(args, filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		query += " AND date(o.created_at) <= ?"
+		args = append(args, filter.DateTo)
 	}
 
 	query += " ORDER BY o.created_at DESC"
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
-		log.Printf("[handleStorefrontCustomProductOrders] query orders error: %v", err)
-		http.Error(w, "加载数据失败", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -2582,25 +3204,66 @@ func handleStorefrontCustomProductOrders(w http.ResponseWriter, r *http.Request)
 		if err := rows.Scan(
 			&o.ID, &o.CustomProductID, &o.UserID, &o.PayPalOrderID,
 			&o.PayPalPaymentStatus, &o.AmountUSD,
-			&o.LicenseSN, &o.LicenseEmail,
+			&o.ChargedAmount, &o.ChargedCurrency,
+			&o.LicenseSN, &o.LicenseEmail, &o.RecipientEmail,
 			&o.Status, &o.CreatedAt, &o.UpdatedAt,
 			&o.ProductName, &o.ProductType, &o.CreditsAmount,
 			&o.BuyerEmail,
 		); err != nil {
-			log.Printf("[handleStorefrontCustomProductOrders] scan order error: %v", err)
+			log.Printf("[queryStorefrontCustomProductOrders] scan order error: %v", err)
 			continue
 		}
 		orders = append(orders, o)
 	}
 	if err := rows.Err(); err != nil {
-		log.Printf("[handleStorefrontCustomProductOrders] rows iteration error: %v", err)
+		log.Printf("[queryStorefrontCustomProductOrders] rows iteration error: %v", err)
+	}
+	return orders, nil
+}
+
+// handleStorefrontCustomProductOrders handles GET /user/storefront/custom-product-orders.
+// Shows all custom product orders for the current user's storefront with optional filtering.
+func handleStorefrontCustomProductOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+
+	// Get user's storefront
+	var storefrontID int64
+	err = db.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "您尚未创建小铺", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("[handleStorefrontCustomProductOrders] query storefront error: %v", err)
+		http.Error(w, "加载数据失败", http.StatusInternalServerError)
+		return
+	}
+
+	filter := parseCustomProductOrderFilter(r)
+	orders, err := queryStorefrontCustomProductOrders(storefrontID, filter)
+	if err != nil {
+		log.Printf("[handleStorefrontCustomProductOrders] query orders error: %v", err)
+		http.Error(w, "加载数据失败", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := templates.StorefrontCustomProductOrdersTmpl.Execute(w, map[string]interface{}{
 		"Orders":            orders,
-		"FilterProductName": filterProductName,
-		"FilterStatus":      filterStatus,
+		"FilterProductName": filter.ProductName,
+		"FilterStatus":      filter.Status,
+		"FilterDateFrom":    filter.DateFrom,
+		"FilterDateTo":      filter.DateTo,
 	}); err != nil {
 		log.Printf("[handleStorefrontCustomProductOrders] template execute error: %v", err)
 	}
@@ -2623,9 +3286,10 @@ func handleUserCustomProductOrders(w http.ResponseWriter, r *http.Request) {
 
 	query := `SELECT o.id, o.custom_product_id, o.user_id, COALESCE(o.paypal_order_id, ''),
 		COALESCE(o.paypal_payment_status, ''), o.amount_usd,
+		COALESCE(o.charged_amount, o.amount_usd), COALESCE(o.charged_currency, 'USD'),
 		COALESCE(o.license_sn, ''), COALESCE(o.license_email, ''),
 		o.status, o.created_at, COALESCE(o.updated_at, ''),
-		p.product_name, p.product_type, COALESCE(p.credits_amount, 0)
+		p.product_name, p.product_type, `+orderCreditsSQL+`
 		FROM custom_product_orders o
 		JOIN custom_products p ON o.custom_product_id = p.id
 		WHERE o.user_id = ?
@@ -2645,6 +3309,7 @@ func handleUserCustomProductOrders(w http.ResponseWriter, r *http.Request) {
 		if err := rows.Scan(
 			&o.ID, &o.CustomProductID, &o.UserID, &o.PayPalOrderID,
 			&o.PayPalPaymentStatus, &o.AmountUSD,
+			&o.ChargedAmount, &o.ChargedCurrency,
 			&o.LicenseSN, &o.LicenseEmail,
 			&o.Status, &o.CreatedAt, &o.UpdatedAt,
 			&o.ProductName, &o.ProductType, &o.CreditsAmount,
@@ -2668,36 +3333,237 @@ func handleUserCustomProductOrders(w http.ResponseWriter, r *http.Request) {
 
 
 
-// LayoutConfig 小铺布局配置
-type LayoutConfig struct {
-	Sections []SectionConfig `json:"sections"`
+// LayoutConfig 小铺布局配置
+type LayoutConfig struct {
+	Sections []SectionConfig `json:"sections"`
+}
+
+// SectionConfig 区块配置
+type SectionConfig struct {
+	Type     string          `json:"type"`
+	Visible  bool            `json:"visible"`
+	Settings json.RawMessage `json:"settings"`
+}
+
+// PackGridSettings 分析包网格区块设置
+type PackGridSettings struct {
+	Columns       int `json:"columns"`
+	MobileColumns int `json:"mobile_columns,omitempty"` // 手机端列数（1 或 2），为 0 时默认 1 列
+}
+
+// HeroSettings hero 区块设置
+type HeroSettings struct {
+	Layout    string `json:"hero_layout,omitempty"` // "default" 或 "reversed"
+	CTAText   string `json:"cta_text,omitempty"`    // 行动按钮文字，为空且设置了链接时使用默认文字
+	CTATarget string `json:"cta_target,omitempty"`  // 行动按钮链接：站内路径（/...）、页内锚点（#...）或 http(s) 外链
+}
+
+// maxHeroCTATextLength hero 行动按钮文字长度上限
+const maxHeroCTATextLength = 40
+
+// maxHeroCTATargetLength hero 行动按钮链接长度上限
+const maxHeroCTATargetLength = 500
+
+// defaultHeroCTATarget 只设置了按钮文字时的默认链接：跳到分析包列表
+const defaultHeroCTATarget = "#store-packs"
+
+// sanitizeHeroCTATarget 校验并规范化 hero 行动按钮链接。
+// 只接受站内路径、页内锚点和 http(s) 外链，其余（javascript:、data:、协议相对的 //host 等）一律拒绝。
+// 返回规范化后的链接、是否为外链，以及是否合法。
+func sanitizeHeroCTATarget(target string) (string, bool, bool) {
+	target = strings.TrimSpace(target)
+	if target == "" || len(target) > maxHeroCTATargetLength || strings.ContainsAny(target, "\x00\r\n\t\\") {
+		return "", false, false
+	}
+	if strings.HasPrefix(target, "#") {
+		return target, false, true
+	}
+	if strings.HasPrefix(target, "/") {
+		if strings.HasPrefix(target, "//") {
+			return "", false, false
+		}
+		return target, false, true
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return "", false, false
+	}
+	return u.String(), true, true
+}
+
+// HeroCTA 小铺页面上实际渲染的 hero 行动按钮，Target 为空表示不显示
+type HeroCTA struct {
+	Text     string // 按钮文字，为空时模板使用默认文字
+	Target   string
+	External bool // 外链在新窗口打开
+}
+
+// resolveHeroCTA 根据 hero 设置得出要渲染的按钮：两项都未设置时不显示；
+// 只有文字时跳到分析包列表；链接不合法时不显示按钮。
+func resolveHeroCTA(hs HeroSettings) HeroCTA {
+	text := strings.TrimSpace(hs.CTAText)
+	if strings.TrimSpace(hs.CTATarget) == "" {
+		if text == "" {
+			return HeroCTA{}
+		}
+		return HeroCTA{Text: text, Target: defaultHeroCTATarget}
+	}
+	target, external, ok := sanitizeHeroCTATarget(hs.CTATarget)
+	if !ok {
+		return HeroCTA{}
+	}
+	return HeroCTA{Text: text, Target: target, External: external}
+}
+
+// CustomBannerSettings 自定义横幅区块设置
+type CustomBannerSettings struct {
+	Text    string `json:"text"`
+	Style   string `json:"style"`
+	ImageID int64  `json:"image_id,omitempty"` // storefront_banner_images.id，为 0 表示纯文本横幅
+	StartAt string `json:"start_at,omitempty"` // 可选，RFC3339 格式，横幅开始展示时间
+	EndAt   string `json:"end_at,omitempty"`   // 可选，RFC3339 格式，横幅停止展示时间
+}
+
+// bannerWindow 解析横幅的展示时间窗口，未设置的一端返回零值
+func bannerWindow(bs CustomBannerSettings) (start, end time.Time, err error) {
+	if bs.StartAt != "" {
+		if start, err = time.Parse(time.RFC3339, bs.StartAt); err != nil {
+			return
+		}
+	}
+	if bs.EndAt != "" {
+		if end, err = time.Parse(time.RFC3339, bs.EndAt); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// isBannerActive 判断横幅在 now 时刻是否处于展示窗口内（start_at 含，end_at 不含）。
+// 时间格式无效的横幅视为不展示。
+func isBannerActive(bs CustomBannerSettings, now time.Time) bool {
+	start, end, err := bannerWindow(bs)
+	if err != nil {
+		return false
+	}
+	if !start.IsZero() && now.Before(start) {
+		return false
+	}
+	if !end.IsZero() && !now.Before(end) {
+		return false
+	}
+	return true
+}
+
+// nextBannerChange 返回 now 之后最近一次横幅显隐发生变化的时间，没有定时横幅时返回零值
+func nextBannerChange(banners []CustomBannerSettings, now time.Time) time.Time {
+	var next time.Time
+	for _, bs := range banners {
+		start, end, err := bannerWindow(bs)
+		if err != nil {
+			continue
+		}
+		for _, t := range []time.Time{start, end} {
+			if !t.IsZero() && t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+	return next
+}
+
+// maxCustomBanners 每个小铺最多的自定义横幅数量（同时也是横幅图片数量上限）
+const maxCustomBanners = 3
+
+// ValidThemes 支持的主题集合
+var ValidThemes = map[string]bool{
+	"default": true,
+	"ocean":   true,
+	"sunset":  true,
+	"forest":  true,
+	"minimal": true,
+	"dark":    true,
+}
+
+// ValidColorSchemes 小铺配色模式：light 始终使用所选主题；auto 在访客系统为深色模式时切换到 dark 主题
+var ValidColorSchemes = map[string]bool{
+	"light": true,
+	"auto":  true,
+}
+
+// CustomThemeColors 自定义主题配色（theme == "custom" 时使用，以 JSON 存储于 custom_theme 列）
+type CustomThemeColors struct {
+	PrimaryColor string `json:"primary_color"`
+	AccentColor  string `json:"accent_color"`
+	HeroStart    string `json:"hero_start"`
+	HeroEnd      string `json:"hero_end"`
+}
+
+// hexColorPattern 仅允许 #RGB 或 #RRGGBB 形式的颜色值，防止注入任意 CSS
+var hexColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ValidateCustomThemeColors 校验自定义主题配色，返回空字符串表示通过
+func ValidateCustomThemeColors(c CustomThemeColors) string {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"主色", c.PrimaryColor},
+		{"强调色", c.AccentColor},
+		{"横幅渐变起始色", c.HeroStart},
+		{"横幅渐变结束色", c.HeroEnd},
+	}
+	for _, f := range fields {
+		if !hexColorPattern.MatchString(f.value) {
+			return fmt.Sprintf("%s必须为十六进制颜色值（如 #6366f1）", f.name)
+		}
+	}
+	return ""
 }
 
-// SectionConfig 区块配置
-type SectionConfig struct {
-	Type     string          `json:"type"`
-	Visible  bool            `json:"visible"`
-	Settings json.RawMessage `json:"settings"`
+// ParseCustomThemeColors 解析并校验 custom_theme 列中的 JSON，无效时返回 false
+func ParseCustomThemeColors(jsonStr string) (CustomThemeColors, bool) {
+	var c CustomThemeColors
+	if jsonStr == "" {
+		return c, false
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &c); err != nil {
+		return c, false
+	}
+	if ValidateCustomThemeColors(c) != "" {
+		return c, false
+	}
+	return c.expanded(), true
 }
 
-// PackGridSettings 分析包网格区块设置
-type PackGridSettings struct {
-	Columns int `json:"columns"`
+// expanded 将各颜色统一为 #rrggbb，<input type="color"> 不接受 #RGB 简写
+func (c CustomThemeColors) expanded() CustomThemeColors {
+	return CustomThemeColors{
+		PrimaryColor: expandHexColor(c.PrimaryColor),
+		AccentColor:  expandHexColor(c.AccentColor),
+		HeroStart:    expandHexColor(c.HeroStart),
+		HeroEnd:      expandHexColor(c.HeroEnd),
+	}
 }
 
-// CustomBannerSettings 自定义横幅区块设置
-type CustomBannerSettings struct {
-	Text  string `json:"text"`
-	Style string `json:"style"`
+// expandHexColor 将 #RGB 展开为 #rrggbb
+func expandHexColor(hex string) string {
+	if len(hex) == 4 {
+		return strings.ToLower(fmt.Sprintf("#%c%c%c%c%c%c", hex[1], hex[1], hex[2], hex[2], hex[3], hex[3]))
+	}
+	return strings.ToLower(hex)
 }
 
-// ValidThemes 支持的主题集合
-var ValidThemes = map[string]bool{
-	"default": true,
-	"ocean":   true,
-	"sunset":  true,
-	"forest":  true,
-	"minimal": true,
+// mixHexColor 将颜色按 ratio（0~1）与 target 混合，用于派生 hover 色和边框色
+func mixHexColor(hex, target string, ratio float64) string {
+	a, b := expandHexColor(hex), expandHexColor(target)
+	var out [3]int64
+	for i := 0; i < 3; i++ {
+		ca, _ := strconv.ParseInt(a[1+i*2:3+i*2], 16, 64)
+		cb, _ := strconv.ParseInt(b[1+i*2:3+i*2], 16, 64)
+		out[i] = ca + int64(float64(cb-ca)*ratio+0.5)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", out[0], out[1], out[2])
 }
 
 // ValidSectionTypes 支持的区块类型集合
@@ -2795,16 +3661,31 @@ func ValidateLayoutConfig(jsonStr string) string {
 		if section.Type == "hero" && !section.Visible {
 			return "hero 区块不允许隐藏"
 		}
+		if section.Type == "hero" && len(section.Settings) > 0 {
+			var heroSettings HeroSettings
+			if err := json.Unmarshal(section.Settings, &heroSettings); err == nil {
+				if len([]rune(strings.TrimSpace(heroSettings.CTAText))) > maxHeroCTATextLength {
+					return fmt.Sprintf("按钮文字不能超过 %d 字符", maxHeroCTATextLength)
+				}
+				if strings.TrimSpace(heroSettings.CTATarget) != "" {
+					if _, _, ok := sanitizeHeroCTATarget(heroSettings.CTATarget); !ok {
+						return "按钮链接必须是站内路径（以 / 开头）或 http(s) 网址"
+					}
+				}
+			}
+		}
 		if section.Type == "pack_grid" && !section.Visible {
 			return "pack_grid 区块不允许隐藏"
 		}
 	}
 
-	if customBannerCount > 3 {
-		return "最多添加 3 个自定义横幅"
+	if customBannerCount > maxCustomBanners {
+		return fmt.Sprintf("最多添加 %d 个自定义横幅", maxCustomBanners)
 	}
 
-	// Validate custom_banner settings
+	// Validate custom_banner settings. Image size/type limits are enforced at
+	// upload time; here we only check that image references are well-formed.
+	usedImageIDs := make(map[int64]bool)
 	for _, section := range config.Sections {
 		if section.Type == "custom_banner" {
 			var bannerSettings CustomBannerSettings
@@ -2817,6 +3698,22 @@ func ValidateLayoutConfig(jsonStr string) string {
 					if bannerSettings.Style != "" && !validStyles[bannerSettings.Style] {
 						return fmt.Sprintf("不支持的横幅样式: %s", bannerSettings.Style)
 					}
+					if bannerSettings.ImageID < 0 {
+						return "无效的横幅图片"
+					}
+					start, end, err := bannerWindow(bannerSettings)
+					if err != nil {
+						return "横幅展示时间格式无效"
+					}
+					if !start.IsZero() && !end.IsZero() && !end.After(start) {
+						return "横幅结束时间必须晚于开始时间"
+					}
+					if bannerSettings.ImageID > 0 {
+						if usedImageIDs[bannerSettings.ImageID] {
+							return "同一张横幅图片不能重复使用"
+						}
+						usedImageIDs[bannerSettings.ImageID] = true
+					}
 				}
 			}
 		}
@@ -2831,6 +3728,9 @@ func ValidateLayoutConfig(jsonStr string) string {
 					if gridSettings.Columns != 0 && gridSettings.Columns != 1 && gridSettings.Columns != 2 && gridSettings.Columns != 3 {
 						return "列数必须为 1、2 或 3"
 					}
+					if gridSettings.MobileColumns != 0 && gridSettings.MobileColumns != 1 && gridSettings.MobileColumns != 2 {
+						return "手机端列数必须为 1 或 2"
+					}
 				}
 			}
 		}
@@ -2840,14 +3740,27 @@ func ValidateLayoutConfig(jsonStr string) string {
 }
 
 // GetThemeCSS 根据主题标识返回对应的 CSS 自定义属性字符串。
-// 如果主题标识无效，回退到 default 主题。
-func GetThemeCSS(theme string) string {
+// theme 为 "custom" 时使用 customThemeJSON 中的配色（hover 色和边框色自动派生）；
+// 如果主题标识或自定义配色无效，回退到 default 主题。
+func GetThemeCSS(theme string, customThemeJSON string) string {
 	type themeColors struct {
 		primaryColor string
 		primaryHover string
 		heroGradient string
 		accentColor  string
 		cardBorder   string
+		// 以下为深色主题覆盖的页面底色、卡片与文字颜色；为空时模板使用浅色默认值
+		pageBg        string
+		surfaceBg     string
+		surfaceBorder string
+		inputBg       string
+		glassBg       string
+		packBg        string
+		packBorder    string
+		textStrong    string
+		textColor     string
+		textSecondary string
+		textMuted     string
 	}
 
 	themes := map[string]themeColors{
@@ -2886,37 +3799,59 @@ func GetThemeCSS(theme string) string {
 			accentColor:  "#64748b",
 			cardBorder:   "#e2e8f0",
 		},
+		"dark": {
+			primaryColor:  "#818cf8",
+			primaryHover:  "#a5b4fc",
+			heroGradient:  "linear-gradient(135deg, #1e1b4b 0%, #172554 50%, #0f172a 100%)",
+			accentColor:   "#a78bfa",
+			cardBorder:    "#334155",
+			pageBg:        "#0b1120",
+			surfaceBg:     "#111827",
+			surfaceBorder: "#1f2937",
+			inputBg:       "#1e293b",
+			glassBg:       "rgba(15,23,42,0.55)",
+			packBg:        "linear-gradient(135deg, #111827 0%, #131c31 100%)",
+			packBorder:    "#273449",
+			textStrong:    "#f8fafc",
+			textColor:     "#e2e8f0",
+			textSecondary: "#cbd5e1",
+			textMuted:     "#94a3b8",
+		},
 	}
 
 	colors, ok := themes[theme]
 	if !ok {
 		colors = themes["default"]
 	}
+	if theme == "custom" {
+		if custom, valid := ParseCustomThemeColors(customThemeJSON); valid {
+			colors = themeColors{
+				primaryColor: expandHexColor(custom.PrimaryColor),
+				primaryHover: mixHexColor(custom.PrimaryColor, "#000000", 0.15),
+				heroGradient: fmt.Sprintf("linear-gradient(135deg, %s 0%%, %s 100%%)", expandHexColor(custom.HeroStart), expandHexColor(custom.HeroEnd)),
+				accentColor:  expandHexColor(custom.AccentColor),
+				cardBorder:   mixHexColor(custom.AccentColor, "#ffffff", 0.75),
+			}
+		}
+	}
 
-	return fmt.Sprintf("--primary-color: %s; --primary-hover: %s; --hero-gradient: %s; --accent-color: %s; --card-border: %s",
+	css := fmt.Sprintf("--primary-color: %s; --primary-hover: %s; --hero-gradient: %s; --accent-color: %s; --card-border: %s",
 		colors.primaryColor, colors.primaryHover, colors.heroGradient, colors.accentColor, colors.cardBorder)
+	if colors.pageBg != "" {
+		css += fmt.Sprintf("; --page-bg: %s; --surface-bg: %s; --surface-border: %s; --input-bg: %s; --glass-bg: %s; --pack-bg: %s; --pack-border: %s; --text-strong: %s; --text-color: %s; --text-secondary: %s; --text-muted: %s; color-scheme: dark",
+			colors.pageBg, colors.surfaceBg, colors.surfaceBorder, colors.inputBg, colors.glassBg, colors.packBg, colors.packBorder,
+			colors.textStrong, colors.textColor, colors.textSecondary, colors.textMuted)
+	}
+	return css
 }
 
-// notificationTemplates holds the predefined email notification templates.
-var notificationTemplates = []NotificationTemplate{
-	{
-		Type:    "version_update",
-		Name:    "版本更新促销",
-		Subject: "[{{.StoreName}}] 分析包版本更新通知",
-		Body:    "尊敬的客户：\n\n{{.StoreName}} 的分析包已更新至 {{.Version}} 版本。\n\n更新内容：\n{{.UpdateContent}}\n\n促销信息：\n{{.PromoInfo}}\n\n感谢您的支持！",
-	},
-	{
-		Type:    "holiday_promo",
-		Name:    "节假日促销",
-		Subject: "[{{.StoreName}}] 节假日促销活动",
-		Body:    "尊敬的客户：\n\n值此 {{.HolidayName}} 之际，{{.StoreName}} 推出限时促销活动。\n\n活动时间：{{.PromoTime}}\n优惠内容：{{.PromoContent}}\n\n祝您节日快乐！",
-	},
-	{
-		Type:    "flash_promo",
-		Name:    "临时促销",
-		Subject: "[{{.StoreName}}] 限时促销活动",
-		Body:    "尊敬的客户：\n\n{{.StoreName}} 推出限时促销活动。\n\n促销原因：{{.PromoReason}}\n活动时间：{{.PromoTime}}\n优惠内容：{{.PromoContent}}\n\n机会难得，欢迎选购！",
-	},
+// GetThemeDarkCSS 返回 color_scheme 为 auto 时在 prefers-color-scheme: dark 媒体查询中输出的变量；
+// 所选主题本身就是 dark 或配色模式为 light 时返回空字符串。
+func GetThemeDarkCSS(theme, colorScheme string) string {
+	if colorScheme != "auto" || theme == "dark" {
+		return ""
+	}
+	return GetThemeCSS("dark", "")
 }
 
 // validPaymentTypes is the set of allowed payment type values.
@@ -3066,12 +4001,11 @@ func handleGetPaymentFeeRate(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusOK, map[string]float64{"fee_rate": 0})
 		return
 	}
-	feeRateStr := getSetting("fee_rate_" + paymentType)
-	feeRate, _ := strconv.ParseFloat(feeRateStr, 64)
-	if feeRate < 0 {
-		feeRate = 0
-	}
-	jsonResponse(w, http.StatusOK, map[string]float64{"fee_rate": feeRate})
+	jsonResponse(w, http.StatusOK, map[string]float64{
+		"fee_rate":       getWithdrawalFeeRate(paymentType),
+		"fee_cap":        getWithdrawalFeeCap(paymentType),
+		"min_withdrawal": getMinWithdrawal(),
+	})
 }
 
 // handleGetAllPaymentFeeRates handles GET /user/payment-info/fee-rates
@@ -3081,20 +4015,14 @@ func handleGetAllPaymentFeeRates(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		return
 	}
-	allTypes := []string{"paypal", "wechat", "alipay", "check", "wire_transfer", "bank_card_us", "bank_card_eu", "bank_card_cn"}
-	rates := make(map[string]float64, len(allTypes))
-	for _, pt := range allTypes {
-		feeRateStr := getSetting("fee_rate_" + pt)
-		feeRate, _ := strconv.ParseFloat(feeRateStr, 64)
-		if feeRate < 0 {
-			feeRate = 0
-		}
-		rates[pt] = feeRate
+	rates := make(map[string]float64, len(withdrawalPaymentTypes))
+	for _, pt := range withdrawalPaymentTypes {
+		rates[pt] = getWithdrawalFeeRate(pt)
 	}
 	jsonResponse(w, http.StatusOK, rates)
 }
 
-// initDB initializes the SQLite database with WAL mode and creates all required tables.
+// initDB initializes the SQLite database with WAL mode and applies the pending schema migrations.
 func initDB(dbPath string) (*sql.DB, error) {
 	// Use _pragma parameters to ensure every connection from the pool gets the same settings.
 	// WAL mode is database-level (persists), but busy_timeout/synchronous/cache_size are per-connection.
@@ -3113,621 +4041,10 @@ func initDB(dbPath string) (*sql.DB, error) {
 	database.SetMaxIdleConns(4)
 	database.SetConnMaxLifetime(0) // reuse connections indefinitely
 
-	// Create users table (new schema with auth_type/auth_id)
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			auth_type TEXT NOT NULL,
-			auth_id TEXT NOT NULL,
-			display_name TEXT NOT NULL,
-			email TEXT,
-			credits_balance REAL DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(auth_type, auth_id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create users table: %w", err)
-	}
-
-	// Migrate users table: rename oauth_provider/oauth_provider_id to auth_type/auth_id
-	var usersTableSQL string
-	err = database.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='users'").Scan(&usersTableSQL)
-	if err == nil && strings.Contains(usersTableSQL, "oauth_provider") {
-		if _, err := database.Exec(`
-			CREATE TABLE users_new (
-				id INTEGER PRIMARY KEY AUTOINCREMENT,
-				auth_type TEXT NOT NULL,
-				auth_id TEXT NOT NULL,
-				display_name TEXT NOT NULL,
-				email TEXT,
-				credits_balance REAL DEFAULT 0,
-				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-				UNIQUE(auth_type, auth_id)
-			)
-		`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to create users_new table: %w", err)
-		}
-		if _, err := database.Exec(`
-			INSERT INTO users_new (id, auth_type, auth_id, display_name, email, credits_balance, created_at)
-			SELECT id, oauth_provider, oauth_provider_id, display_name, email, credits_balance, created_at FROM users
-		`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to migrate users data: %w", err)
-		}
-		if _, err := database.Exec(`DROP TABLE users`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to drop old users table: %w", err)
-		}
-		if _, err := database.Exec(`ALTER TABLE users_new RENAME TO users`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to rename users_new table: %w", err)
-		}
-		log.Println("Migrated users table: oauth_provider/oauth_provider_id → auth_type/auth_id")
-	}
-
-	// Create categories table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS categories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL UNIQUE,
-			description TEXT,
-			is_preset INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create categories table: %w", err)
-	}
-
-	// Create pack_listings table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS pack_listings (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			category_id INTEGER NOT NULL,
-			file_data BLOB NOT NULL,
-			pack_name TEXT NOT NULL,
-			pack_description TEXT,
-			source_name TEXT,
-			author_name TEXT,
-			share_mode TEXT NOT NULL,
-			credits_price INTEGER DEFAULT 0,
-			status TEXT DEFAULT 'pending',
-			download_count INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (category_id) REFERENCES categories(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create pack_listings table: %w", err)
-	}
-
-	// Add review-related columns to pack_listings (ignore error if already exists)
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN reject_reason TEXT")
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN reviewed_by INTEGER REFERENCES admin_credentials(id)")
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN reviewed_at DATETIME")
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN meta_info TEXT DEFAULT '{}'")
-
-	// Add billing-related columns to pack_listings (ignore error if already exists)
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN valid_days INTEGER DEFAULT 0")
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN billing_cycle TEXT DEFAULT ''")
-
-	// Add encryption_password column for paid pack encryption (ignore error if already exists)
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN encryption_password TEXT DEFAULT ''")
-
-	// Add version column for pack replacement tracking (ignore error if already exists)
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN version INTEGER DEFAULT 1")
-
-	// Add share_token column for public URLs (prevents sequential ID enumeration)
-	database.Exec("ALTER TABLE pack_listings ADD COLUMN share_token TEXT")
-	database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_pack_listings_share_token ON pack_listings(share_token) WHERE share_token IS NOT NULL")
-	// Backfill share_token for existing rows that don't have one
-	backfillShareTokens(database)
-
-	// Add username and password_hash columns to users table (ignore error if already exists)
-	database.Exec("ALTER TABLE users ADD COLUMN username TEXT")
-	database.Exec("ALTER TABLE users ADD COLUMN password_hash TEXT")
-
-	// Add is_blocked column to users table (ignore error if already exists)
-	database.Exec("ALTER TABLE users ADD COLUMN is_blocked INTEGER DEFAULT 0")
-
-	// Add email_allowed column to users table (default 1 = allowed)
-	database.Exec("ALTER TABLE users ADD COLUMN email_allowed INTEGER DEFAULT 1")
-	// Create unique index on username (ALTER TABLE ADD COLUMN does not support UNIQUE in SQLite)
-	database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username) WHERE username IS NOT NULL")
-
-	// Create user_downloads table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS user_downloads (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			listing_id INTEGER NOT NULL,
-			downloaded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create user_downloads table: %w", err)
-	}
-
-	// Create credits_transactions table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS credits_transactions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			transaction_type TEXT NOT NULL,
-			amount REAL NOT NULL,
-			listing_id INTEGER,
-			description TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create credits_transactions table: %w", err)
-	}
-
-	// Add ip_address column to credits_transactions for sales tracking (ignore error if already exists)
-	database.Exec("ALTER TABLE credits_transactions ADD COLUMN ip_address TEXT DEFAULT ''")
-
-	// Add ip_address column to user_downloads for buyer region tracking (ignore error if already exists)
-	database.Exec("ALTER TABLE user_downloads ADD COLUMN ip_address TEXT DEFAULT ''")
-
-	// Create user_purchased_packs table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS user_purchased_packs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			listing_id INTEGER NOT NULL,
-			is_hidden INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (listing_id) REFERENCES pack_listings(id),
-			UNIQUE(user_id, listing_id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create user_purchased_packs table: %w", err)
-	}
-
-	// Create pack_usage_records table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS pack_usage_records (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			listing_id INTEGER NOT NULL,
-			used_count INTEGER NOT NULL DEFAULT 0,
-			total_purchased INTEGER NOT NULL DEFAULT 0,
-			last_used_at DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (listing_id) REFERENCES pack_listings(id),
-			UNIQUE(user_id, listing_id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create pack_usage_records table: %w", err)
-	}
-
-	// Create pack_usage_log table (deduplication via UNIQUE constraint)
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS pack_usage_log (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			listing_id INTEGER NOT NULL,
-			used_at TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(user_id, listing_id, used_at)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create pack_usage_log table: %w", err)
-	}
-
-	// Create withdrawal_records table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS withdrawal_records (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			credits_amount REAL NOT NULL,
-			cash_rate REAL NOT NULL,
-			cash_amount REAL NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create withdrawal_records table: %w", err)
-	}
-
-	// Create user_payment_info table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS user_payment_info (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL UNIQUE,
-			payment_type TEXT NOT NULL,
-			payment_details TEXT NOT NULL,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create user_payment_info table: %w", err)
-	}
-
-	// Add payment/fee/status columns to withdrawal_records (ignore error if already exists)
-	database.Exec("ALTER TABLE withdrawal_records ADD COLUMN payment_type TEXT DEFAULT ''")
-	database.Exec("ALTER TABLE withdrawal_records ADD COLUMN payment_details TEXT DEFAULT '{}'")
-	database.Exec("ALTER TABLE withdrawal_records ADD COLUMN fee_rate REAL DEFAULT 0")
-	database.Exec("ALTER TABLE withdrawal_records ADD COLUMN fee_amount REAL DEFAULT 0")
-	database.Exec("ALTER TABLE withdrawal_records ADD COLUMN net_amount REAL DEFAULT 0")
-	database.Exec("ALTER TABLE withdrawal_records ADD COLUMN status TEXT DEFAULT 'paid'")
-	database.Exec("ALTER TABLE withdrawal_records ADD COLUMN display_name TEXT DEFAULT ''")
-
-	// Create settings table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS settings (
-			key TEXT PRIMARY KEY,
-			value TEXT NOT NULL
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create settings table: %w", err)
-	}
-
-	// Insert preset e-commerce categories (ignore if already exist)
-	presetCategories := []struct {
-		Name        string
-		Description string
-	}{
-		{"Shopify", "Shopify e-commerce platform analysis packs"},
-		{"BigCommerce", "BigCommerce e-commerce platform analysis packs"},
-		{"eBay", "eBay marketplace analysis packs"},
-		{"Etsy", "Etsy marketplace analysis packs"},
-	}
-	for _, cat := range presetCategories {
-		_, err := database.Exec(
-			"INSERT OR IGNORE INTO categories (name, description, is_preset) VALUES (?, ?, 1)",
-			cat.Name, cat.Description,
-		)
-		if err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to insert preset category %s: %w", cat.Name, err)
-		}
-	}
-
-	// Insert default settings (ignore if already exist)
-	_, err = database.Exec(
-		"INSERT OR IGNORE INTO settings (key, value) VALUES ('initial_credits_balance', '0')",
-	)
-	if err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to insert default settings: %w", err)
-	}
-
-	// Insert default credit_cash_rate setting (ignore if already exists)
-	_, err = database.Exec(
-		"INSERT OR IGNORE INTO settings (key, value) VALUES ('credit_cash_rate', '0')",
-	)
-	if err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to insert credit_cash_rate setting: %w", err)
-	}
-
-	// Migrate admin_credentials table: detect old CHECK(id=1) constraint and rebuild
-	var adminTableSQL string
-	err = database.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='admin_credentials'").Scan(&adminTableSQL)
-	if err == nil && strings.Contains(adminTableSQL, "CHECK") {
-		// Old table detected, migrate to new schema
-		if _, err := database.Exec(`
-			CREATE TABLE admin_credentials_new (
-				id INTEGER PRIMARY KEY AUTOINCREMENT,
-				username TEXT NOT NULL UNIQUE,
-				password_hash TEXT NOT NULL,
-				role TEXT NOT NULL DEFAULT 'regular',
-				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-			)
-		`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to create admin_credentials_new table: %w", err)
-		}
-		if _, err := database.Exec(`
-			INSERT INTO admin_credentials_new (id, username, password_hash, role, created_at)
-			SELECT id, username, password_hash, 'super', created_at FROM admin_credentials
-		`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to migrate admin_credentials data: %w", err)
-		}
-		if _, err := database.Exec(`DROP TABLE admin_credentials`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to drop old admin_credentials table: %w", err)
-		}
-		if _, err := database.Exec(`ALTER TABLE admin_credentials_new RENAME TO admin_credentials`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to rename admin_credentials_new table: %w", err)
-		}
-	} else {
-		// Fresh install or already migrated: create new schema
-		if _, err := database.Exec(`
-			CREATE TABLE IF NOT EXISTS admin_credentials (
-				id INTEGER PRIMARY KEY AUTOINCREMENT,
-				username TEXT NOT NULL UNIQUE,
-				password_hash TEXT NOT NULL,
-				role TEXT NOT NULL DEFAULT 'regular',
-				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-			)
-		`); err != nil {
-			database.Close()
-			return nil, fmt.Errorf("failed to create admin_credentials table: %w", err)
-		}
-	}
-
-	// Add permissions column to admin_credentials (ignore error if already exists)
-	database.Exec("ALTER TABLE admin_credentials ADD COLUMN permissions TEXT DEFAULT ''")
-
-	// Create notifications table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS notifications (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
-			target_type TEXT NOT NULL DEFAULT 'broadcast',
-			effective_date DATETIME NOT NULL,
-			display_duration_days INTEGER NOT NULL DEFAULT 0,
-			status TEXT NOT NULL DEFAULT 'active',
-			created_by INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (created_by) REFERENCES admin_credentials(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create notifications table: %w", err)
-	}
-
-	// Create notification_targets table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS notification_targets (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			notification_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			FOREIGN KEY (notification_id) REFERENCES notifications(id),
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			UNIQUE(notification_id, user_id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create notification_targets table: %w", err)
-	}
-
-	// Create email_wallets table for unified per-email balance
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS email_wallets (
-			email TEXT PRIMARY KEY,
-			credits_balance REAL DEFAULT 0,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create email_wallets table: %w", err)
-	}
-
-	// Migrate existing per-user balances into email_wallets (one-time)
-	// Sum all users.credits_balance grouped by email into email_wallets
-	database.Exec(`
-		INSERT OR IGNORE INTO email_wallets (email, credits_balance, updated_at)
-		SELECT email, SUM(credits_balance), CURRENT_TIMESTAMP
-		FROM users WHERE email IS NOT NULL AND email != '' GROUP BY email
-	`)
-
-	// Add password_hash and username columns to email_wallets (ignore error if already exists)
-	database.Exec("ALTER TABLE email_wallets ADD COLUMN password_hash TEXT")
-	database.Exec("ALTER TABLE email_wallets ADD COLUMN username TEXT")
-
-	// Migrate existing password_hash from users to email_wallets (one-time, pick the first non-null password per email)
-	database.Exec(`
-		UPDATE email_wallets SET password_hash = (
-			SELECT u.password_hash FROM users u
-			WHERE u.email = email_wallets.email AND u.password_hash IS NOT NULL AND u.password_hash != ''
-			ORDER BY u.id ASC LIMIT 1
-		), username = (
-			SELECT u.username FROM users u
-			WHERE u.email = email_wallets.email AND u.username IS NOT NULL AND u.username != ''
-			ORDER BY u.id ASC LIMIT 1
-		)
-		WHERE email_wallets.password_hash IS NULL OR email_wallets.password_hash = ''
-	`)
-
-	// Create author_storefronts table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS author_storefronts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL UNIQUE,
-			store_name TEXT DEFAULT '',
-			store_slug TEXT NOT NULL UNIQUE,
-			description TEXT DEFAULT '',
-			logo_data BLOB,
-			logo_content_type TEXT DEFAULT '',
-			auto_add_enabled INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create author_storefronts table: %w", err)
-	}
-	database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_storefronts_slug ON author_storefronts(store_slug)")
-
-	// Create storefront_packs table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS storefront_packs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			storefront_id INTEGER NOT NULL,
-			pack_listing_id INTEGER NOT NULL,
-			is_featured INTEGER DEFAULT 0,
-			featured_sort_order INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
-			FOREIGN KEY (pack_listing_id) REFERENCES pack_listings(id),
-			UNIQUE(storefront_id, pack_listing_id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create storefront_packs table: %w", err)
-	}
-
-	// Add logo columns to storefront_packs (ignore error if already exists)
-	database.Exec("ALTER TABLE storefront_packs ADD COLUMN logo_data BLOB")
-	database.Exec("ALTER TABLE storefront_packs ADD COLUMN logo_content_type TEXT")
-
-	// Add store_layout column to author_storefronts (ignore error if already exists)
-	database.Exec("ALTER TABLE author_storefronts ADD COLUMN store_layout TEXT DEFAULT 'default'")
-
-	// Add public_id column to author_storefronts for non-enumerable URLs (ignore error if already exists)
-	database.Exec("ALTER TABLE author_storefronts ADD COLUMN public_id TEXT")
-	database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_storefronts_public_id ON author_storefronts(public_id)")
-
-	// Add layout_config and theme columns to author_storefronts for storefront customization (ignore error if already exists)
-	database.Exec("ALTER TABLE author_storefronts ADD COLUMN layout_config TEXT")
-	database.Exec("ALTER TABLE author_storefronts ADD COLUMN theme TEXT DEFAULT 'default'")
-
-	// Create featured_storefronts table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS featured_storefronts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			storefront_id INTEGER NOT NULL UNIQUE,
-			sort_order INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create featured_storefronts table: %w", err)
-	}
-
-	// Create storefront_notifications table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS storefront_notifications (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			storefront_id INTEGER NOT NULL,
-			subject TEXT NOT NULL,
-			body TEXT NOT NULL,
-			recipient_count INTEGER DEFAULT 0,
-			template_type TEXT DEFAULT '',
-			status TEXT DEFAULT 'sent',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create storefront_notifications table: %w", err)
-	}
-
-	// Create email_credits_usage table for tracking email sending credits billing
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS email_credits_usage (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			storefront_id INTEGER NOT NULL,
-			store_name TEXT DEFAULT '',
-			recipient_count INTEGER DEFAULT 0,
-			credits_used REAL DEFAULT 0,
-			notification_id INTEGER DEFAULT 0,
-			description TEXT DEFAULT '',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create email_credits_usage table: %w", err)
-	}
-
-	// Add custom_products_enabled column to author_storefronts (ignore error if already exists)
-	database.Exec("ALTER TABLE author_storefronts ADD COLUMN custom_products_enabled INTEGER DEFAULT 0")
-
-	// Create custom_products table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS custom_products (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			storefront_id INTEGER NOT NULL,
-			product_name TEXT NOT NULL,
-			description TEXT DEFAULT '',
-			product_type TEXT NOT NULL CHECK(product_type IN ('credits', 'virtual_goods')),
-			price_usd REAL NOT NULL,
-			credits_amount INTEGER DEFAULT 0,
-			license_api_endpoint TEXT DEFAULT '',
-			license_api_key TEXT DEFAULT '',
-			license_product_id TEXT DEFAULT '',
-			status TEXT DEFAULT 'draft' CHECK(status IN ('draft', 'pending', 'published', 'rejected')),
-			reject_reason TEXT DEFAULT '',
-			sort_order INTEGER DEFAULT 0,
-			deleted_at DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
-			UNIQUE(storefront_id, product_name)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create custom_products table: %w", err)
-	}
-
-	// Create custom_product_orders table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS custom_product_orders (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			custom_product_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			paypal_order_id TEXT DEFAULT '',
-			paypal_payment_status TEXT DEFAULT '',
-			amount_usd REAL NOT NULL,
-			license_sn TEXT DEFAULT '',
-			license_email TEXT DEFAULT '',
-			status TEXT DEFAULT 'pending' CHECK(status IN ('pending', 'paid', 'fulfilled', 'failed')),
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (custom_product_id) REFERENCES custom_products(id),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)
-	`); err != nil {
-		database.Close()
-		return nil, fmt.Errorf("failed to create custom_product_orders table: %w", err)
-	}
-
-	// Create storefront_support_requests table
-	if _, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS storefront_support_requests (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			storefront_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			software_name TEXT NOT NULL DEFAULT 'vantagics',
-			store_name TEXT NOT NULL DEFAULT '',
-			welcome_message TEXT NOT NULL DEFAULT '',
-			status TEXT NOT NULL DEFAULT 'pending',
-			reviewed_by INTEGER,
-			reviewed_at DATETIME,
-			disable_reason TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (reviewed_by) REFERENCES admin_credentials(id)
-		)
-	`); err != nil {
+	if err := runMigrations(database); err != nil {
 		database.Close()
-		return nil, fmt.Errorf("failed to create storefront_support_requests table: %w", err)
+		return nil, err
 	}
-	database.Exec("CREATE INDEX IF NOT EXISTS idx_support_requests_storefront ON storefront_support_requests(storefront_id)")
-	database.Exec("CREATE INDEX IF NOT EXISTS idx_support_requests_status ON storefront_support_requests(status)")
 
 	return database, nil
 }
@@ -3973,6 +4290,14 @@ func handleStorefrontSupportApply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Email ownership must be verified before applying
+	var applicantEmail string
+	db.QueryRow("SELECT COALESCE(email, '') FROM users WHERE id = ?", userID).Scan(&applicantEmail)
+	if !isEmailVerified(applicantEmail) {
+		jsonResponse(w, http.StatusForbidden, map[string]interface{}{"success": false, "error": i18n.T(i18n.DetectLang(r), "email_verify_required"), "code": "email_unverified"})
+		return
+	}
+
 	// Step 2: Query user's storefront
 	var storefrontID int64
 	var storeName, description string
@@ -4840,6 +5165,9 @@ func getWalletBalance(userID int64) float64 {
 		db.QueryRow("SELECT credits_balance FROM users WHERE id = ?", userID).Scan(&balance)
 		return balance
 	}
+	if shares := accountWalletShares(userID); shares != nil {
+		return combinedWalletBalance(shares)
+	}
 	var balance float64
 	err := db.QueryRow("SELECT credits_balance FROM email_wallets WHERE email = ?", email).Scan(&balance)
 	if err != nil {
@@ -4868,18 +5196,26 @@ func deductWalletBalance(tx *sql.Tx, userID int64, amount float64) (int64, error
 		}
 		return result.RowsAffected()
 	}
-	// Ensure wallet row exists — initialize from sum of all user balances for this email if new
-	tx.Exec(`INSERT OR IGNORE INTO email_wallets (email, credits_balance)
-		SELECT ?, COALESCE(SUM(credits_balance), 0) FROM users WHERE email = ?`, email, email)
-	result, err := tx.Exec(
-		"UPDATE email_wallets SET credits_balance = credits_balance - ?, updated_at = CURRENT_TIMESTAMP WHERE email = ? AND credits_balance >= ?",
-		amount, email, amount)
-	if err != nil {
-		return 0, err
-	}
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return 0, err
+	var rows int64
+	if linked := linkedWalletEmails(tx, userID, email); linked != nil {
+		// Linked emails: draw from the primary wallet first, then the others
+		var err error
+		if rows, err = deductLinkedWallets(tx, linked, amount); err != nil {
+			return 0, err
+		}
+	} else {
+		// Ensure wallet row exists — initialize from sum of all user balances for this email if new
+		tx.Exec(`INSERT OR IGNORE INTO email_wallets (email, credits_balance)
+			SELECT ?, COALESCE(SUM(credits_balance), 0) FROM users WHERE email = ?`, email, email)
+		result, err := tx.Exec(
+			"UPDATE email_wallets SET credits_balance = credits_balance - ?, updated_at = CURRENT_TIMESTAMP WHERE email = ? AND credits_balance >= ?",
+			amount, email, amount)
+		if err != nil {
+			return 0, err
+		}
+		if rows, err = result.RowsAffected(); err != nil {
+			return 0, err
+		}
 	}
 	// Sync to users.credits_balance for backward compatibility (floor at 0)
 	if rows > 0 {
@@ -4896,6 +5232,10 @@ func addWalletBalance(tx *sql.Tx, userID int64, amount float64) error {
 		_, err := tx.Exec("UPDATE users SET credits_balance = credits_balance + ? WHERE id = ?", amount, userID)
 		return err
 	}
+	// With linked emails, credits go to the primary email's wallet
+	if linked := linkedWalletEmails(tx, userID, email); linked != nil {
+		email = linked[0]
+	}
 	// Ensure wallet row exists — initialize from sum of all user balances for this email if new
 	tx.Exec(`INSERT OR IGNORE INTO email_wallets (email, credits_balance)
 		SELECT ?, COALESCE(SUM(credits_balance), 0) FROM users WHERE email = ?`, email, email)
@@ -4918,12 +5258,21 @@ func addWalletBalanceByEmail(email string, amount float64) error {
 		return err
 	}
 	defer tx.Rollback()
+	if err := addWalletBalanceByEmailTx(tx, email, amount); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
+// addWalletBalanceByEmailTx is addWalletBalanceByEmail within an existing
+// transaction. The email needs no account yet: the wallet row is created and
+// the balance becomes available once a user registers with that email.
+func addWalletBalanceByEmailTx(tx *sql.Tx, email string, amount float64) error {
 	// Ensure wallet row exists — initialize from sum of user balances if new
 	tx.Exec(`INSERT OR IGNORE INTO email_wallets (email, credits_balance, updated_at)
 		SELECT ?, COALESCE(SUM(credits_balance), 0), CURRENT_TIMESTAMP
 		FROM users WHERE email = ?`, email, email)
-	_, err = tx.Exec(
+	_, err := tx.Exec(
 		"UPDATE email_wallets SET credits_balance = credits_balance + ?, updated_at = CURRENT_TIMESTAMP WHERE email = ?",
 		amount, email)
 	if err != nil {
@@ -4934,7 +5283,7 @@ func addWalletBalanceByEmail(email string, amount float64) error {
 	if tx.QueryRow("SELECT id FROM users WHERE email = ? ORDER BY id ASC LIMIT 1", email).Scan(&primaryID) == nil {
 		tx.Exec("UPDATE users SET credits_balance = credits_balance + ? WHERE id = ?", amount, primaryID)
 	}
-	return tx.Commit()
+	return nil
 }
 
 // getWalletBalanceByEmail returns the wallet balance for an email.
@@ -4980,12 +5329,15 @@ func handleAdminCreateNotification(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Title               string  `json:"title"`
-		Content             string  `json:"content"`
-		TargetType          string  `json:"target_type"`
-		TargetUserIDs       []int64 `json:"target_user_ids"`
-		EffectiveDate       string  `json:"effective_date"`
-		DisplayDurationDays int     `json:"display_duration_days"`
+		Title               string                      `json:"title"`
+		Content             string                      `json:"content"`
+		TargetType          string                      `json:"target_type"`
+		TargetUserIDs       []int64                     `json:"target_user_ids"`
+		TargetCriteria      *NotificationTargetCriteria `json:"target_criteria"`
+		EffectiveDate       string                      `json:"effective_date"`
+		DisplayDurationDays int                         `json:"display_duration_days"`
+		Recurrence          string                      `json:"recurrence"`
+		RecurrenceEnd       string                      `json:"recurrence_end"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
@@ -4997,7 +5349,16 @@ func handleAdminCreateNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.TargetType == "targeted" && len(req.TargetUserIDs) == 0 {
+	var targetCriteria string
+	if req.TargetCriteria != nil {
+		if msg := req.TargetCriteria.validate(); msg != "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": msg})
+			return
+		}
+		criteriaJSON, _ := json.Marshal(req.TargetCriteria)
+		targetCriteria = string(criteriaJSON)
+		req.TargetType = "targeted"
+	} else if req.TargetType == "targeted" && len(req.TargetUserIDs) == 0 {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "target_user_ids required for targeted messages"})
 		return
 	}
@@ -5019,6 +5380,27 @@ func handleAdminCreateNotification(w http.ResponseWriter, r *http.Request) {
 		effectiveDate = time.Now()
 	}
 
+	if req.Recurrence == "" {
+		req.Recurrence = "none"
+	}
+	var recurrenceEnd time.Time
+	if req.Recurrence != "none" {
+		parsed, err := time.Parse(time.RFC3339, req.RecurrenceEnd)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid recurrence_end format"})
+			return
+		}
+		recurrenceEnd = parsed
+	}
+	if msg := validateNotificationRecurrence(req.Recurrence, effectiveDate, req.DisplayDurationDays, recurrenceEnd); msg != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	recurrenceEndStr := ""
+	if !recurrenceEnd.IsZero() {
+		recurrenceEndStr = recurrenceEnd.Format(time.RFC3339)
+	}
+
 	adminID := getSessionAdminID(r)
 
 	tx, err := db.Begin()
@@ -5030,9 +5412,11 @@ func handleAdminCreateNotification(w http.ResponseWriter, r *http.Request) {
 	defer tx.Rollback()
 
 	result, err := tx.Exec(`
-		INSERT INTO notifications (title, content, target_type, effective_date, display_duration_days, status, created_by, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, 'active', ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		INSERT INTO notifications (title, content, target_type, effective_date, display_duration_days, status, created_by, created_at, updated_at,
+			recurrence, recurrence_anchor, recurrence_end, target_criteria)
+		VALUES (?, ?, ?, ?, ?, 'active', ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, ?, ?)`,
 		req.Title, req.Content, req.TargetType, effectiveDate.Format(time.RFC3339), req.DisplayDurationDays, adminID,
+		req.Recurrence, effectiveDate.Format(time.RFC3339), recurrenceEndStr, targetCriteria,
 	)
 	if err != nil {
 		log.Printf("Failed to insert notification: %v", err)
@@ -5047,6 +5431,21 @@ func handleAdminCreateNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.TargetCriteria != nil {
+		// Resolve the segment now, in the same transaction as the notification
+		segment, err := req.TargetCriteria.resolve(tx)
+		if err != nil {
+			log.Printf("Failed to resolve notification target criteria: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if len(segment) == 0 && len(req.TargetUserIDs) == 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "no users match the target criteria"})
+			return
+		}
+		req.TargetUserIDs = append(req.TargetUserIDs, segment...)
+	}
+
 	if req.TargetType == "targeted" {
 		for _, userID := range req.TargetUserIDs {
 			_, err := tx.Exec(`INSERT OR IGNORE INTO notification_targets (notification_id, user_id) VALUES (?, ?)`,
@@ -5080,6 +5479,9 @@ type AdminNotificationInfo struct {
 	CreatedBy           int64  `json:"created_by"`
 	CreatedAt           string `json:"created_at"`
 	TargetCount         int    `json:"target_count"`
+	Recurrence          string `json:"recurrence"`
+	RecurrenceEnd       string `json:"recurrence_end,omitempty"`
+	TargetCriteria      string `json:"target_criteria,omitempty"`
 }
 
 // handleAdminListNotifications handles GET /api/admin/notifications.
@@ -5092,7 +5494,8 @@ func handleAdminListNotifications(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := db.Query(`
-		SELECT id, title, content, target_type, effective_date, display_duration_days, status, created_by, created_at
+		SELECT id, title, content, target_type, effective_date, display_duration_days, status, created_by, created_at,
+			COALESCE(recurrence, 'none'), COALESCE(recurrence_end, ''), COALESCE(target_criteria, '')
 		FROM notifications
 		WHERE status != 'deleted'
 		ORDER BY created_at DESC`)
@@ -5106,7 +5509,7 @@ func handleAdminListNotifications(w http.ResponseWriter, r *http.Request) {
 	var notifications []AdminNotificationInfo
 	for rows.Next() {
 		var n AdminNotificationInfo
-		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.TargetType, &n.EffectiveDate, &n.DisplayDurationDays, &n.Status, &n.CreatedBy, &n.CreatedAt); err != nil {
+		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.TargetType, &n.EffectiveDate, &n.DisplayDurationDays, &n.Status, &n.CreatedBy, &n.CreatedAt, &n.Recurrence, &n.RecurrenceEnd, &n.TargetCriteria); err != nil {
 			log.Printf("Failed to scan notification: %v", err)
 			continue
 		}
@@ -5255,6 +5658,10 @@ func handleAdminDeleteNotification(w http.ResponseWriter, r *http.Request) {
 
 func handleAdminNotificationRoutes(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
+	if path == "/api/admin/notifications/estimate" {
+		handleAdminNotificationEstimate(w, r)
+		return
+	}
 	if path == "/api/admin/notifications" {
 		switch r.Method {
 		case http.MethodGet:
@@ -5266,7 +5673,11 @@ func handleAdminNotificationRoutes(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	// /api/admin/notifications/{id}/disable|enable|delete
+	// /api/admin/notifications/{id}/disable|enable|delete|occurrences
+	if strings.HasSuffix(path, "/occurrences") {
+		handleAdminNotificationOccurrences(w, r)
+		return
+	}
 	if strings.HasSuffix(path, "/disable") {
 		handleAdminDisableNotification(w, r)
 		return
@@ -5291,6 +5702,7 @@ type NotificationInfo struct {
 	EffectiveDate       string `json:"effective_date"`
 	DisplayDurationDays int    `json:"display_duration_days"`
 	CreatedAt           string `json:"created_at"`
+	Read                bool   `json:"read"`
 }
 
 // handleListNotifications handles GET /api/notifications.
@@ -5303,71 +5715,12 @@ func handleListNotifications(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := optionalUserID(r)
-	now := time.Now()
-
-	var rows *sql.Rows
-	var err error
-
-	if userID > 0 {
-		// Authenticated: broadcast + targeted for this user
-		rows, err = db.Query(`
-			SELECT DISTINCT n.id, n.title, n.content, n.target_type, n.effective_date, n.display_duration_days, n.created_at
-			FROM notifications n
-			LEFT JOIN notification_targets nt ON n.id = nt.notification_id
-			WHERE n.status = 'active'
-			  AND n.effective_date <= ?
-			  AND (n.target_type = 'broadcast' OR (n.target_type = 'targeted' AND nt.user_id = ?))
-			ORDER BY n.created_at DESC`,
-			now.Format(time.RFC3339), userID,
-		)
-	} else {
-		// Unauthenticated: broadcast only
-		rows, err = db.Query(`
-			SELECT id, title, content, target_type, effective_date, display_duration_days, created_at
-			FROM notifications
-			WHERE status = 'active'
-			  AND target_type = 'broadcast'
-			  AND effective_date <= ?
-			ORDER BY created_at DESC`,
-			now.Format(time.RFC3339),
-		)
-	}
-
+	notifications, err := visibleNotifications(optionalUserID(r), time.Now())
 	if err != nil {
 		log.Printf("Failed to query notifications: %v", err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
-	defer rows.Close()
-
-	var notifications []NotificationInfo
-	for rows.Next() {
-		var n NotificationInfo
-		var effectiveDateStr string
-		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.TargetType, &effectiveDateStr, &n.DisplayDurationDays, &n.CreatedAt); err != nil {
-			log.Printf("Failed to scan notification: %v", err)
-			continue
-		}
-		// Parse effective_date and apply visibility filter
-		effectiveDate, err := time.Parse(time.RFC3339, effectiveDateStr)
-		if err != nil {
-			log.Printf("Failed to parse effective_date for notification %d: %v", n.ID, err)
-			continue
-		}
-		if !isNotificationVisible(effectiveDate, n.DisplayDurationDays, now) {
-			continue
-		}
-		n.EffectiveDate = effectiveDateStr
-		notifications = append(notifications, n)
-	}
-	if err := rows.Err(); err != nil {
-		log.Printf("[handleListNotifications] rows iteration error: %v", err)
-	}
-
-	if notifications == nil {
-		notifications = []NotificationInfo{}
-	}
 
 	jsonResponse(w, http.StatusOK, notifications)
 }
@@ -5376,7 +5729,13 @@ func handleListNotifications(w http.ResponseWriter, r *http.Request) {
 // ensuring is_hidden is set to 0 (visible). If the record already exists
 // (same user_id + listing_id), it updates is_hidden to 0 and refreshes updated_at.
 func upsertUserPurchasedPack(userID int64, listingID int64) error {
-	_, err := db.Exec(`
+	return upsertUserPurchasedPackTx(db, userID, listingID)
+}
+
+// upsertUserPurchasedPackTx is upsertUserPurchasedPack on e, which may be a
+// transaction.
+func upsertUserPurchasedPackTx(e execer, userID int64, listingID int64) error {
+	_, err := e.Exec(`
 		INSERT INTO user_purchased_packs (user_id, listing_id, is_hidden, updated_at)
 		VALUES (?, ?, 0, CURRENT_TIMESTAMP)
 		ON CONFLICT(user_id, listing_id) DO UPDATE SET is_hidden = 0, updated_at = CURRENT_TIMESTAMP`,
@@ -5447,13 +5806,19 @@ func generateStoreSlug(displayName string) string {
 		slug = strings.TrimRight(slug, "-")
 	}
 
-	// 8. Check database uniqueness, append -2, -3, etc. on conflict
+	// 8. Never derive a slug from offensive words
+	if isProfaneStoreSlug(slug) {
+		slug = "store"
+	}
+
+	// 9. Check database uniqueness and reserved words, append -2, -3, etc. on conflict
 	baseSlug := slug
 	counter := 2
 	for {
 		var exists int
-		err := db.QueryRow("SELECT COUNT(*) FROM author_storefronts WHERE store_slug = ?", slug).Scan(&exists)
-		if err != nil || exists == 0 {
+		err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM author_storefronts WHERE store_slug = ?) +
+			(SELECT COUNT(*) FROM storefront_slug_history WHERE slug = ?)`, slug, slug).Scan(&exists)
+		if err != nil || (exists == 0 && !isReservedStoreSlug(slug)) {
 			break
 		}
 		suffix := fmt.Sprintf("-%d", counter)
@@ -5486,6 +5851,12 @@ func validateStoreSlug(slug string) string {
 	if !slugValidPattern.MatchString(slug) {
 		return "小铺标识仅允许小写字母、数字和连字符"
 	}
+	if isReservedStoreSlug(slug) {
+		return "该小铺标识为系统保留字，请换一个"
+	}
+	if isProfaneStoreSlug(slug) {
+		return "小铺标识包含不当词汇，请换一个"
+	}
 	return ""
 }
 
@@ -5499,6 +5870,9 @@ func validateStoreName(name string) string {
 	if length > 30 {
 		return "小铺名称长度不能超过 30 个字符"
 	}
+	if msg, _ := checkUserContent(name); msg != "" {
+		return msg
+	}
 	return ""
 }
 
@@ -5612,12 +5986,13 @@ func notImplementedHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // resolveStorefrontID resolves a public_id or numeric ID to the internal storefront ID.
-// Returns the internal ID and public_id, or error if not found.
+// Returns the internal ID and public_id, or error if not found. Storefronts of
+// deleted accounts are treated as not found.
 func resolveStorefrontID(identifier string) (int64, string, error) {
 	// Try parsing as numeric ID first (for backward compatibility during migration)
 	if id, err := strconv.ParseInt(identifier, 10, 64); err == nil {
 		var publicID string
-		err := db.QueryRow("SELECT COALESCE(public_id, '') FROM author_storefronts WHERE id = ?", id).Scan(&publicID)
+		err := db.QueryRow("SELECT COALESCE(public_id, '') FROM author_storefronts WHERE id = ? AND COALESCE(store_status, 'active') != 'deleted'", id).Scan(&publicID)
 		if err == sql.ErrNoRows {
 			return 0, "", fmt.Errorf("storefront not found")
 		}
@@ -5629,18 +6004,28 @@ func resolveStorefrontID(identifier string) (int64, string, error) {
 
 	// Try as public_id
 	var id int64
-	err := db.QueryRow("SELECT id FROM author_storefronts WHERE public_id = ?", identifier).Scan(&id)
+	err := db.QueryRow("SELECT id FROM author_storefronts WHERE public_id = ? AND COALESCE(store_status, 'active') != 'deleted'", identifier).Scan(&id)
+	if err == nil {
+		return id, identifier, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, "", err
+	}
+
+	// Try as the current store_slug
+	var publicID string
+	err = db.QueryRow("SELECT id, COALESCE(public_id, '') FROM author_storefronts WHERE store_slug = ? AND COALESCE(store_status, 'active') != 'deleted'", identifier).Scan(&id, &publicID)
 	if err == sql.ErrNoRows {
 		return 0, "", fmt.Errorf("storefront not found")
 	}
 	if err != nil {
 		return 0, "", err
 	}
-	return id, identifier, nil
+	return id, publicID, nil
 }
 
 // handleStorefrontRoutes dispatches public storefront routes.
-// Path format: /store/{public_id} or /store/{public_id}/logo
+// Path format: /store/{public_id or slug} or /store/{public_id or slug}/logo
 func handleStorefrontRoutes(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/store/")
 	path = strings.TrimSuffix(path, "/")
@@ -5656,8 +6041,24 @@ func handleStorefrontRoutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Links to a store's previous slug redirect permanently to its current one.
+	if _, _, err := resolveStorefrontID(storeID); err != nil {
+		rest := ""
+		if len(parts) == 2 {
+			rest = parts[1]
+		}
+		if redirectHistoricalSlug(w, r, storeID, rest) {
+			return
+		}
+	}
+
 	if len(parts) == 2 && parts[1] == "logo" {
-		handleStorefrontLogo(w, r, storeID)
+		handleStorefrontLogo(w, r, storeID, false)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "logo/thumb" {
+		handleStorefrontLogo(w, r, storeID, true)
 		return
 	}
 
@@ -5671,6 +6072,21 @@ func handleStorefrontRoutes(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if len(parts) == 2 && parts[1] == "feed.xml" {
+		handleStorefrontFeed(w, r, storeID)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "policies" {
+		handleStorefrontPolicies(w, r, storeID)
+		return
+	}
+
+	if len(parts) == 2 && strings.HasPrefix(parts[1], "banner/") {
+		handleStorefrontBannerImage(w, r, storeID, strings.TrimPrefix(parts[1], "banner/"))
+		return
+	}
+
 	if len(parts) == 1 {
 		handleStorefrontPage(w, r, storeID)
 		return
@@ -5703,6 +6119,22 @@ func handleStorefrontManagement(w http.ResponseWriter, r *http.Request) {
 		handleStorefrontRemovePack(w, r)
 	case path == "/auto-add" && r.Method == http.MethodPost:
 		handleStorefrontToggleAutoAdd(w, r)
+	case path == "/status" && r.Method == http.MethodPost:
+		handleStorefrontSetStatus(w, r)
+	case path == "/announcement" && r.Method == http.MethodPost:
+		handleStorefrontSetAnnouncement(w, r)
+	case path == "/policies" && r.Method == http.MethodPost:
+		handleStorefrontSavePolicies(w, r)
+	case path == "/tax-rate" && r.Method == http.MethodPost:
+		handleStorefrontSetTaxRate(w, r)
+	case path == "/analytics" && r.Method == http.MethodGet:
+		handleStorefrontAnalytics(w, r)
+	case path == "/analytics/regions" && r.Method == http.MethodGet:
+		handleStorefrontSalesRegions(w, r)
+	case path == "/analytics/checkout" && r.Method == http.MethodGet:
+		handleStorefrontCheckoutFunnel(w, r)
+	case path == "/events" && r.Method == http.MethodGet:
+		handleStorefrontEvents(w, r)
 	case path == "/featured" && r.Method == http.MethodPost:
 		handleStorefrontSetFeatured(w, r)
 	case path == "/featured/reorder" && r.Method == http.MethodPost:
@@ -5711,12 +6143,26 @@ func handleStorefrontManagement(w http.ResponseWriter, r *http.Request) {
 		handleStorefrontFeaturedLogoUpload(w, r)
 	case path == "/featured/logo/delete" && r.Method == http.MethodPost:
 		handleStorefrontFeaturedLogoDelete(w, r)
+	case path == "/banner-image" && r.Method == http.MethodPost:
+		handleStorefrontBannerImageUpload(w, r)
+	case path == "/banner-image/delete" && r.Method == http.MethodPost:
+		handleStorefrontBannerImageDelete(w, r)
 	case path == "/layout" && r.Method == http.MethodPost:
 		handleStorefrontSaveLayout(w, r)
 	case path == "/decoration/publish" && r.Method == http.MethodPost:
 		handlePublishDecoration(w, r)
 	case path == "/theme" && r.Method == http.MethodPost:
 		handleStorefrontSaveTheme(w, r)
+	case path == "/color-scheme" && r.Method == http.MethodPost:
+		handleStorefrontSaveColorScheme(w, r)
+	case path == "/layout-presets" && r.Method == http.MethodGet:
+		handleStorefrontLayoutPresets(w, r)
+	case path == "/layout-presets/apply" && r.Method == http.MethodPost:
+		handleStorefrontApplyLayoutPreset(w, r)
+	case path == "/design/export" && r.Method == http.MethodGet:
+		handleStorefrontDesignExport(w, r)
+	case path == "/design/import" && r.Method == http.MethodPost:
+		handleStorefrontDesignImport(w, r)
 	case path == "/notify" && r.Method == http.MethodPost:
 		handleStorefrontSendNotify(w, r)
 	case path == "/notify/recipients" && r.Method == http.MethodGet:
@@ -5748,15 +6194,22 @@ func queryStorefrontPublicData(storeID, filter, sortBy, search, category string)
 	var storeLayout sql.NullString
 	var layoutConfigRaw sql.NullString
 	var themeRaw sql.NullString
+	var customThemeRaw string
 	err := db.QueryRow(`SELECT id, user_id, COALESCE(public_id, ''), store_name, store_slug, description,
 		CASE WHEN logo_data IS NOT NULL AND LENGTH(logo_data) > 0 THEN 1 ELSE 0 END,
 		COALESCE(logo_content_type, ''), auto_add_enabled, COALESCE(store_layout, 'default'), created_at, updated_at,
-		layout_config, theme
+		layout_config, theme, COALESCE(custom_theme, ''), COALESCE(store_status, 'active'),
+		COALESCE(store_announcement, ''), COALESCE(announcement_active, 0),
+		COALESCE(refund_policy, ''), COALESCE(terms, ''), COALESCE(contact, ''), COALESCE(verified, 0),
+		COALESCE(color_scheme, 'light')
 		FROM author_storefronts WHERE id = ?`, storeID).Scan(
 		&storefront.ID, &storefront.UserID, &storefront.PublicID, &storefront.StoreName, &storefront.StoreSlug,
 		&storefront.Description, &storefront.HasLogo, &logoContentType,
 		&storefront.AutoAddEnabled, &storeLayout, &storefront.CreatedAt, &storefront.UpdatedAt,
-		&layoutConfigRaw, &themeRaw,
+		&layoutConfigRaw, &themeRaw, &customThemeRaw, &storefront.StoreStatus,
+		&storefront.Announcement, &storefront.AnnouncementActive,
+		&storefront.RefundPolicy, &storefront.Terms, &storefront.Contact, &storefront.Verified,
+		&storefront.ColorScheme,
 	)
 	if err != nil {
 		return nil, err
@@ -5783,40 +6236,76 @@ func queryStorefrontPublicData(storeID, filter, sortBy, search, category string)
 		layoutConfig = DefaultLayoutConfig()
 	}
 
-	// Extract pack_grid columns
+	// Extract pack_grid columns (desktop and mobile)
 	packGridColumns := 2
+	packGridMobileColumns := 1
 	for _, section := range layoutConfig.Sections {
 		if section.Type == "pack_grid" {
 			var gridSettings PackGridSettings
 			if len(section.Settings) > 0 {
-				if err := json.Unmarshal(section.Settings, &gridSettings); err == nil && gridSettings.Columns >= 1 && gridSettings.Columns <= 3 {
-					packGridColumns = gridSettings.Columns
+				if err := json.Unmarshal(section.Settings, &gridSettings); err == nil {
+					if gridSettings.Columns >= 1 && gridSettings.Columns <= 3 {
+						packGridColumns = gridSettings.Columns
+					}
+					if gridSettings.MobileColumns >= 1 && gridSettings.MobileColumns <= 2 {
+						packGridMobileColumns = gridSettings.MobileColumns
+					}
 				}
 			}
 			break
 		}
 	}
 
-	// Extract custom_banner settings
+	// Extract custom_banner settings, keeping only banners inside their visibility window
+	now := time.Now()
 	bannerData := make(map[int]CustomBannerSettings)
+	var allBanners []CustomBannerSettings
 	for i, section := range layoutConfig.Sections {
 		if section.Type == "custom_banner" && len(section.Settings) > 0 {
 			var bs CustomBannerSettings
 			if err := json.Unmarshal(section.Settings, &bs); err == nil {
+				allBanners = append(allBanners, bs)
+				if isBannerActive(bs, now) {
+					bannerData[i] = bs
+				}
+			}
+		}
+	}
+
+	// Drop references to banner images that no longer exist (or belong to another store)
+	if len(bannerData) > 0 {
+		bannerImageIDs := make(map[int64]bool)
+		biRows, biErr := db.Query("SELECT id FROM storefront_banner_images WHERE storefront_id = ?", storefront.ID)
+		if biErr != nil {
+			log.Printf("[STOREFRONT-PAGE] failed to query banner images for storefront %d: %v", storefront.ID, biErr)
+		} else {
+			for biRows.Next() {
+				var id int64
+				if biRows.Scan(&id) == nil {
+					bannerImageIDs[id] = true
+				}
+			}
+			biRows.Close()
+		}
+		for i, bs := range bannerData {
+			if bs.ImageID != 0 && !bannerImageIDs[bs.ImageID] {
+				bs.ImageID = 0
 				bannerData[i] = bs
 			}
 		}
 	}
 
-	// Extract hero layout setting (default or reversed)
+	// Extract hero layout setting (default or reversed) and the optional call-to-action button
 	heroLayout := "default"
+	var heroCTA HeroCTA
 	for _, section := range layoutConfig.Sections {
 		if section.Type == "hero" && len(section.Settings) > 0 {
-			var hs struct {
-				Layout string `json:"hero_layout"`
-			}
-			if err := json.Unmarshal(section.Settings, &hs); err == nil && hs.Layout == "reversed" {
-				heroLayout = "reversed"
+			var hs HeroSettings
+			if err := json.Unmarshal(section.Settings, &hs); err == nil {
+				if hs.Layout == "reversed" {
+					heroLayout = "reversed"
+				}
+				heroCTA = resolveHeroCTA(hs)
 			}
 			break
 		}
@@ -5825,11 +6314,12 @@ func queryStorefrontPublicData(storeID, filter, sortBy, search, category string)
 	// Resolve theme
 	theme := "default"
 	if themeRaw.Valid && themeRaw.String != "" {
-		if ValidThemes[themeRaw.String] {
+		if ValidThemes[themeRaw.String] || themeRaw.String == "custom" {
 			theme = themeRaw.String
 		}
 	}
-	themeCSS := GetThemeCSS(theme)
+	themeCSS := GetThemeCSS(theme, customThemeRaw)
+	themeDarkCSS := GetThemeDarkCSS(theme, storefront.ColorScheme)
 
 	// Fall back to author display_name if store_name is empty
 	if storefront.StoreName == "" {
@@ -5941,7 +6431,7 @@ func queryStorefrontPublicData(storeID, filter, sortBy, search, category string)
 		cpRows, cpErr := db.Query(`SELECT id, storefront_id, product_name, COALESCE(description, ''),
 			product_type, price_usd, COALESCE(credits_amount, 0),
 			COALESCE(license_api_endpoint, ''), COALESCE(license_api_key, ''), COALESCE(license_product_id, ''),
-			status, COALESCE(reject_reason, ''), COALESCE(sort_order, 0),
+			status, COALESCE(reject_reason, ''), COALESCE(sort_order, 0), stock_quantity,
 			created_at, COALESCE(updated_at, '')
 			FROM custom_products
 			WHERE storefront_id = ? AND status = 'published' AND deleted_at IS NULL
@@ -5955,7 +6445,7 @@ func queryStorefrontPublicData(storeID, filter, sortBy, search, category string)
 				if err := cpRows.Scan(&cp.ID, &cp.StorefrontID, &cp.ProductName, &cp.Description,
 					&cp.ProductType, &cp.PriceUSD, &cp.CreditsAmount,
 					&cp.LicenseAPIEndpoint, &cp.LicenseAPIKey, &cp.LicenseProductID,
-					&cp.Status, &cp.RejectReason, &cp.SortOrder,
+					&cp.Status, &cp.RejectReason, &cp.SortOrder, &cp.StockQuantity,
 					&cp.CreatedAt, &cp.UpdatedAt); err != nil {
 					log.Printf("[STOREFRONT-PAGE] failed to scan custom product row: %v", err)
 					continue
@@ -5968,17 +6458,24 @@ func queryStorefrontPublicData(storeID, filter, sortBy, search, category string)
 		}
 	}
 
+	// 6. Apply running flash sales; the cache entry expires at the next sale boundary
+	nextSaleChange := applyStorefrontSales(featuredPacks, packs, customProducts, now)
+
 	return &StorefrontPublicData{
-		Storefront:      storefront,
-		FeaturedPacks:   featuredPacks,
-		Packs:           packs,
-		Categories:      categories,
-		CustomProducts:  customProducts,
-		LayoutConfig:    layoutConfig,
-		ThemeCSS:        themeCSS,
-		PackGridColumns: packGridColumns,
-		BannerData:      bannerData,
-		HeroLayout:      heroLayout,
+		Storefront:            storefront,
+		FeaturedPacks:         featuredPacks,
+		Packs:                 packs,
+		Categories:            categories,
+		CustomProducts:        customProducts,
+		LayoutConfig:          layoutConfig,
+		ThemeCSS:              themeCSS,
+		ThemeDarkCSS:          themeDarkCSS,
+		PackGridColumns:       packGridColumns,
+		PackGridMobileColumns: packGridMobileColumns,
+		BannerData:            bannerData,
+		HeroLayout:            heroLayout,
+		HeroCTA:               heroCTA,
+		ExpiresAt:             earliestTime(nextBannerChange(allBanners, now), nextSaleChange),
 	}, nil
 }
 
@@ -6035,6 +6532,7 @@ func handleStorefrontPage(w http.ResponseWriter, r *http.Request, storeIdentifie
 		}
 		globalCache.SetStorefrontData(cacheKey, publicData)
 	}
+	trackStorefrontView(r, internalID)
 
 	// 3. Check if user is logged in and handle user-specific data
 	isLoggedIn := false
@@ -6086,31 +6584,91 @@ func handleStorefrontPage(w http.ResponseWriter, r *http.Request, storeIdentifie
 		}
 	}
 
+	// Show custom product prices in the visitor's currency; PayPal charges the settlement currency.
+	lang := i18n.DetectLang(r)
+	displayCurrency := buyerCurrency(r, lang)
+	if q := strings.ToUpper(r.URL.Query().Get("currency")); q == displayCurrency {
+		http.SetCookie(w, &http.Cookie{Name: currencyCookieName, Value: q, Path: "/", MaxAge: 365 * 24 * 3600, SameSite: http.SameSiteLaxMode})
+	}
+	chargeCurrency := settlementCurrency()
+	taxRate := storefrontTaxRate(publicData.Storefront.ID)
+	customProducts := make([]CustomProduct, len(publicData.CustomProducts))
+	for i, cp := range publicData.CustomProducts {
+		cp.DisplayPrice, cp.DisplayCurrency = cp.PriceUSD, "USD"
+		cp.OriginalDisplayPrice = cp.OriginalPriceUSD
+		if v, ok := convertUSD(cp.PriceUSD, displayCurrency); ok {
+			cp.DisplayPrice, cp.DisplayCurrency = v, displayCurrency
+			cp.OriginalDisplayPrice, _ = convertUSD(cp.OriginalPriceUSD, displayCurrency)
+		}
+		cp.ChargePrice, cp.ChargeCurrency = cp.PriceUSD, "USD"
+		if v, ok := convertUSD(cp.PriceUSD, chargeCurrency); ok {
+			cp.ChargePrice, cp.ChargeCurrency = v, chargeCurrency
+		}
+		cp.ChargeTax = computeTax(cp.ChargePrice, taxRate, cp.ChargeCurrency)
+		cp.ChargeTotal = cp.ChargePrice + cp.ChargeTax
+		customProducts[i] = cp
+	}
+
+	// Credits products offer the admin-defined top-up tiers, priced like the products
+	var topupTiers []CreditsTopupTier
+	for _, cp := range customProducts {
+		if cp.ProductType != "credits" {
+			continue
+		}
+		tiers, err := loadTopupTiers()
+		if err != nil {
+			log.Printf("[STOREFRONT-PAGE] failed to load topup tiers: %v", err)
+		}
+		for _, t := range tiers {
+			charge, currency := t.PriceUSD, "USD"
+			if v, ok := convertUSD(t.PriceUSD, chargeCurrency); ok {
+				charge, currency = v, chargeCurrency
+			}
+			t.ChargeTotal, t.ChargeCurrency = charge+computeTax(charge, taxRate, currency), currency
+			topupTiers = append(topupTiers, t)
+		}
+		break
+	}
+
+	// Follower count and follow state are live; the storefront data itself is cached.
+	storefront := publicData.Storefront
+	storefront.FollowerCount = storefrontFollowerCount(storefront.ID)
+	isFollowing := isLoggedIn && isFollowingStorefront(currentUserID, storefront.ID)
+
 	data := StorefrontPageData{
-		Storefront:         publicData.Storefront,
-		FeaturedPacks:      publicData.FeaturedPacks,
-		Packs:              publicData.Packs,
-		PurchasedIDs:       purchasedIDs,
-		IsLoggedIn:         isLoggedIn,
-		CurrentUserID:      currentUserID,
-		DefaultLang:        defaultLang,
-		Filter:             filter,
-		Sort:               sortBy,
-		SearchQuery:        searchQuery,
-		Categories:         publicData.Categories,
-		CategoryFilter:     categoryFilter,
-		DownloadURLWindows: downloadURLWindows,
-		DownloadURLMacOS:   downloadURLMacOS,
-		Sections:           publicData.LayoutConfig.Sections,
-		ThemeCSS:           publicData.ThemeCSS,
-		PackGridColumns:    publicData.PackGridColumns,
-		BannerData:         publicData.BannerData,
-		HeroLayout:         publicData.HeroLayout,
-		IsPreviewMode:      isPreviewMode,
-		CustomProducts:     publicData.CustomProducts,
-		FeaturedVisible:    isFeaturedVisible(publicData.LayoutConfig.Sections),
-		SupportApproved:    supportApproved,
-		ServicePortalURL:   supportServicePortalURL,
+		Storefront:            storefront,
+		FeaturedPacks:         publicData.FeaturedPacks,
+		Packs:                 publicData.Packs,
+		PurchasedIDs:          purchasedIDs,
+		IsLoggedIn:            isLoggedIn,
+		CurrentUserID:         currentUserID,
+		DefaultLang:           defaultLang,
+		Filter:                filter,
+		Sort:                  sortBy,
+		SearchQuery:           searchQuery,
+		Categories:            publicData.Categories,
+		CategoryFilter:        categoryFilter,
+		DownloadURLWindows:    downloadURLWindows,
+		DownloadURLMacOS:      downloadURLMacOS,
+		Sections:              publicData.LayoutConfig.Sections,
+		ThemeCSS:              template.CSS(publicData.ThemeCSS),
+		ThemeDarkCSS:          template.CSS(publicData.ThemeDarkCSS),
+		PackGridColumns:       publicData.PackGridColumns,
+		PackGridMobileColumns: publicData.PackGridMobileColumns,
+		BannerData:            publicData.BannerData,
+		HeroLayout:            publicData.HeroLayout,
+		HeroCTA:               publicData.HeroCTA,
+		IsPreviewMode:         isPreviewMode,
+		CustomProducts:        customProducts,
+		TopupTiers:            topupTiers,
+		Lang:                  string(lang),
+		Currencies:            i18n.SupportedCurrencies(),
+		DisplayCurrency:       displayCurrency,
+		FeaturedVisible:       isFeaturedVisible(publicData.LayoutConfig.Sections),
+		SupportApproved:       supportApproved,
+		ServicePortalURL:      supportServicePortalURL,
+		IsFollowing:           isFollowing,
+		IsPaused:              storefront.StoreStatus == storeStatusPaused,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -6124,7 +6682,10 @@ func handleStorefrontPage(w http.ResponseWriter, r *http.Request, storeIdentifie
 }
 
 
-func handleStorefrontLogo(w http.ResponseWriter, r *http.Request, storeIdentifier string) {
+// handleStorefrontLogo serves the storefront logo. When thumb is true the small
+// variant generated at upload time is served, falling back to the full-size logo
+// for logos uploaded before thumbnails existed.
+func handleStorefrontLogo(w http.ResponseWriter, r *http.Request, storeIdentifier string, thumb bool) {
 	// Resolve store identifier (public_id or numeric ID) to numeric ID
 	storefrontID, _, err := resolveStorefrontID(storeIdentifier)
 	if err != nil {
@@ -6132,9 +6693,14 @@ func handleStorefrontLogo(w http.ResponseWriter, r *http.Request, storeIdentifie
 		return
 	}
 
+	column := "logo_data"
+	if thumb {
+		column = "COALESCE(logo_thumb_data, logo_data)"
+	}
+
 	var logoData []byte
 	var logoContentType string
-	err = db.QueryRow(`SELECT logo_data, COALESCE(logo_content_type, '') FROM author_storefronts WHERE id = ?`, storefrontID).Scan(&logoData, &logoContentType)
+	err = db.QueryRow(`SELECT `+column+`, COALESCE(logo_content_type, '') FROM author_storefronts WHERE id = ?`, storefrontID).Scan(&logoData, &logoContentType)
 	if err == sql.ErrNoRows {
 		http.NotFound(w, r)
 		return
@@ -6178,15 +6744,20 @@ func handleStorefrontSettingsPage(w http.ResponseWriter, r *http.Request) {
 	var storeLayout sql.NullString
 	var layoutConfigRaw sql.NullString
 	var themeRaw sql.NullString
+	var customThemeRaw string
 	err = db.QueryRow(`SELECT id, user_id, COALESCE(public_id, ''), store_name, store_slug, description,
 		CASE WHEN logo_data IS NOT NULL AND LENGTH(logo_data) > 0 THEN 1 ELSE 0 END,
 		COALESCE(logo_content_type, ''), auto_add_enabled, COALESCE(store_layout, 'default'), created_at, updated_at,
-		layout_config, COALESCE(theme, 'default')
+		layout_config, COALESCE(theme, 'default'), COALESCE(custom_theme, ''), COALESCE(store_status, 'active'),
+		COALESCE(store_announcement, ''), COALESCE(announcement_active, 0),
+		COALESCE(refund_policy, ''), COALESCE(terms, ''), COALESCE(contact, ''), COALESCE(color_scheme, 'light')
 		FROM author_storefronts WHERE user_id = ?`, userID).Scan(
 		&storefront.ID, &storefront.UserID, &storefront.PublicID, &storefront.StoreName, &storefront.StoreSlug,
 		&storefront.Description, &storefront.HasLogo, &logoContentType,
 		&storefront.AutoAddEnabled, &storeLayout, &storefront.CreatedAt, &storefront.UpdatedAt,
-		&layoutConfigRaw, &themeRaw,
+		&layoutConfigRaw, &themeRaw, &customThemeRaw, &storefront.StoreStatus,
+		&storefront.Announcement, &storefront.AnnouncementActive,
+		&storefront.RefundPolicy, &storefront.Terms, &storefront.Contact, &storefront.ColorScheme,
 	)
 	if err == sql.ErrNoRows {
 		// Auto-create storefront on first visit
@@ -6213,12 +6784,16 @@ func handleStorefrontSettingsPage(w http.ResponseWriter, r *http.Request) {
 		err = db.QueryRow(`SELECT id, user_id, COALESCE(public_id, ''), store_name, store_slug, description,
 			CASE WHEN logo_data IS NOT NULL AND LENGTH(logo_data) > 0 THEN 1 ELSE 0 END,
 			COALESCE(logo_content_type, ''), auto_add_enabled, COALESCE(store_layout, 'default'), created_at, updated_at,
-			layout_config, COALESCE(theme, 'default')
+			layout_config, COALESCE(theme, 'default'), COALESCE(custom_theme, ''), COALESCE(store_status, 'active'),
+			COALESCE(store_announcement, ''), COALESCE(announcement_active, 0),
+			COALESCE(refund_policy, ''), COALESCE(terms, ''), COALESCE(contact, ''), COALESCE(color_scheme, 'light')
 			FROM author_storefronts WHERE user_id = ?`, userID).Scan(
 			&storefront.ID, &storefront.UserID, &storefront.PublicID, &storefront.StoreName, &storefront.StoreSlug,
 			&storefront.Description, &storefront.HasLogo, &logoContentType,
 			&storefront.AutoAddEnabled, &storeLayout, &storefront.CreatedAt, &storefront.UpdatedAt,
-			&layoutConfigRaw, &themeRaw,
+			&layoutConfigRaw, &themeRaw, &customThemeRaw, &storefront.StoreStatus,
+			&storefront.Announcement, &storefront.AnnouncementActive,
+			&storefront.RefundPolicy, &storefront.Terms, &storefront.Contact, &storefront.ColorScheme,
 		)
 		if err != nil {
 			log.Printf("[STOREFRONT-SETTINGS] failed to re-query storefront for user %d: %v", userID, err)
@@ -6259,7 +6834,11 @@ func handleStorefrontSettingsPage(w http.ResponseWriter, r *http.Request) {
 	if themeRaw.Valid && themeRaw.String != "" {
 		currentTheme = themeRaw.String
 	}
-	if !ValidThemes[currentTheme] {
+	customTheme, customThemeValid := ParseCustomThemeColors(customThemeRaw)
+	if !customThemeValid {
+		customTheme = CustomThemeColors{PrimaryColor: "#6366f1", AccentColor: "#8b5cf6", HeroStart: "#eef2ff", HeroEnd: "#f0fdf4"}
+	}
+	if !ValidThemes[currentTheme] && !(currentTheme == "custom" && customThemeValid) {
 		currentTheme = "default"
 	}
 
@@ -6388,11 +6967,9 @@ func handleStorefrontSettingsPage(w http.ResponseWriter, r *http.Request) {
 		defaultLang = "zh-CN"
 	}
 
-	// Load notification templates (placeholder until task 13.1 defines them)
-	var tmplList []NotificationTemplate
-	if notificationTemplates != nil {
-		tmplList = notificationTemplates
-	}
+	// Notification templates in the author's language; each recipient gets
+	// their own language when the email is sent.
+	tmplList := notificationTemplates(i18n.DetectLang(r))
 
 	// Query custom_products_enabled for this storefront
 	var cpEnabled int
@@ -6502,8 +7079,10 @@ func handleStorefrontSettingsPage(w http.ResponseWriter, r *http.Request) {
 		ActiveTab:             "settings",
 		LayoutSectionsJSON:    layoutSectionsJSON,
 		CurrentTheme:          currentTheme,
+		CustomTheme:           customTheme,
 		CustomProductsEnabled: customProductsEnabled,
 		CustomProducts:        customProducts,
+		TaxRate:               storefrontTaxRate(storefront.ID),
 		DecorationFee:         decorationFee,
 		DecorationFeeMax:      decorationFeeMax,
 		SupportStatus:         supportStatus,
@@ -6539,6 +7118,11 @@ func handleStorefrontSaveSettings(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": errMsg})
 		return
 	}
+	contentReject, contentFlag := checkUserContent(storeName, description)
+	if contentReject != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": contentReject})
+		return
+	}
 
 	// Update author_storefronts table
 	result, err := db.Exec(`UPDATE author_storefronts SET store_name = ?, description = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
@@ -6571,6 +7155,7 @@ func handleStorefrontSaveSettings(w http.ResponseWriter, r *http.Request) {
 	// Sync welcome message to support system when description is updated
 	var storefrontID int64
 	if err := db.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID); err == nil {
+		flagUserContent("storefront", storefrontID, contentFlag, storeName)
 		go syncSupportWelcomeMessage(storefrontID, description)
 	}
 
@@ -6662,12 +7247,27 @@ func handleStorefrontSaveTheme(w http.ResponseWriter, r *http.Request) {
 	}
 
 	theme := r.FormValue("theme")
-	if !ValidThemes[theme] {
-		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": "不支持的主题"})
-		return
+	var result sql.Result
+	if theme == "custom" {
+		custom := CustomThemeColors{
+			PrimaryColor: strings.TrimSpace(r.FormValue("primary_color")),
+			AccentColor:  strings.TrimSpace(r.FormValue("accent_color")),
+			HeroStart:    strings.TrimSpace(r.FormValue("hero_start")),
+			HeroEnd:      strings.TrimSpace(r.FormValue("hero_end")),
+		}
+		if errMsg := ValidateCustomThemeColors(custom); errMsg != "" {
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": errMsg})
+			return
+		}
+		customJSON, _ := json.Marshal(custom.expanded())
+		result, err = db.Exec(`UPDATE author_storefronts SET theme = ?, custom_theme = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`, theme, string(customJSON), userID)
+	} else {
+		if !ValidThemes[theme] {
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": "不支持的主题"})
+			return
+		}
+		result, err = db.Exec(`UPDATE author_storefronts SET theme = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`, theme, userID)
 	}
-
-	result, err := db.Exec(`UPDATE author_storefronts SET theme = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`, theme, userID)
 	if err != nil {
 		log.Printf("[STOREFRONT-SAVE-THEME] failed to update theme for user %d: %v", userID, err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "保存失败"})
@@ -6689,6 +7289,40 @@ func handleStorefrontSaveTheme(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
 }
 
+// handleStorefrontSaveColorScheme saves whether the storefront follows the
+// visitor's dark mode preference ("auto") or always uses its theme ("light").
+func handleStorefrontSaveColorScheme(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "未登录"})
+		return
+	}
+
+	scheme := r.FormValue("color_scheme")
+	if !ValidColorSchemes[scheme] {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": "不支持的配色模式"})
+		return
+	}
+	result, err := db.Exec(`UPDATE author_storefronts SET color_scheme = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`, scheme, userID)
+	if err != nil {
+		log.Printf("[STOREFRONT-SAVE-THEME] failed to update color_scheme for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "保存失败"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		jsonResponse(w, http.StatusNotFound, map[string]interface{}{"ok": false, "error": "小铺不存在"})
+		return
+	}
+
+	var slug string
+	if err := db.QueryRow("SELECT store_slug FROM author_storefronts WHERE user_id = ?", userID).Scan(&slug); err == nil {
+		globalCache.InvalidateStorefront(slug)
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+}
+
 
 
 func handleStorefrontUploadLogo(w http.ResponseWriter, r *http.Request) {
@@ -6728,16 +7362,24 @@ func handleStorefrontUploadLogo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate file format (PNG/JPEG only)
-	contentType := http.DetectContentType(fileData)
-	if contentType != "image/png" && contentType != "image/jpeg" {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "仅支持 PNG 或 JPEG 格式"})
+	// Validate file format (PNG/JPEG/WebP only)
+	contentType := detectImageContentType(fileData)
+	if !isAllowedLogoContentType(contentType) {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "仅支持 PNG、JPEG 或 WebP 格式"})
 		return
 	}
 
-	// Store logo_data and logo_content_type in author_storefronts table
-	result, err := db.Exec(`UPDATE author_storefronts SET logo_data = ?, logo_content_type = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
-		fileData, contentType, userID)
+	// Decode, downscale and generate the thumbnail variant; reject undecodable images
+	logo, err := processLogoImage(fileData)
+	if err != nil {
+		log.Printf("[STOREFRONT-UPLOAD-LOGO] failed to process image for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片无法解析，请上传有效的图片文件"})
+		return
+	}
+
+	// Store logo_data, logo_thumb_data and logo_content_type in author_storefronts table
+	result, err := db.Exec(`UPDATE author_storefronts SET logo_data = ?, logo_thumb_data = ?, logo_content_type = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
+		logo.Full, logo.Thumb, logo.ContentType, userID)
 	if err != nil {
 		log.Printf("[STOREFRONT-UPLOAD-LOGO] failed to update logo for user %d: %v", userID, err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
@@ -6804,159 +7446,309 @@ func handleStorefrontFeaturedLogoUpload(w http.ResponseWriter, r *http.Request)
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片大小不能超过 2MB"})
 		return
 	}
-
-	// Read file data
-	fileData, err := io.ReadAll(io.LimitReader(file, maxLogoSize+1))
+
+	// Read file data
+	fileData, err := io.ReadAll(io.LimitReader(file, maxLogoSize+1))
+	if err != nil {
+		log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] failed to read file for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "读取文件失败"})
+		return
+	}
+	if int64(len(fileData)) > maxLogoSize {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片大小不能超过 2MB"})
+		return
+	}
+
+	// Validate file format using content detection (PNG/JPEG/WebP only)
+	contentType := detectImageContentType(fileData)
+	if !isAllowedLogoContentType(contentType) {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "仅支持 PNG、JPEG 或 WebP 格式"})
+		return
+	}
+
+	// Normalize WebP to PNG so stored pack logos stay PNG/JPEG
+	if contentType == "image/webp" {
+		pngData, err := normalizeWebPLogo(fileData)
+		if err != nil {
+			log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] failed to decode webp for user %d: %v", userID, err)
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片无法解析，请上传有效的图片文件"})
+			return
+		}
+		fileData = pngData
+		contentType = "image/png"
+	}
+
+	// Verify the pack belongs to the current user's storefront and is featured
+	var storefrontID int64
+	err = db.QueryRow(`SELECT id FROM author_storefronts WHERE user_id = ?`, userID).Scan(&storefrontID)
+	if err != nil {
+		log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] storefront not found for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+		return
+	}
+
+	var isFeatured int
+	err = db.QueryRow(`SELECT is_featured FROM storefront_packs WHERE storefront_id = ? AND pack_listing_id = ?`, storefrontID, packListingID).Scan(&isFeatured)
+	if err != nil {
+		log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] pack %d not found in storefront %d: %v", packListingID, storefrontID, err)
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+		return
+	}
+
+	if isFeatured != 1 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "该分析包未设置为推荐"})
+		return
+	}
+
+	// Update logo_data and logo_content_type in storefront_packs
+	_, err = db.Exec(`UPDATE storefront_packs SET logo_data = ?, logo_content_type = ? WHERE storefront_id = ? AND pack_listing_id = ?`,
+		fileData, contentType, storefrontID, packListingID)
+	if err != nil {
+		log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] failed to update logo for storefront %d, pack %d: %v", storefrontID, packListingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+
+	// Invalidate storefront cache after logo upload
+	var slug string
+	if err := db.QueryRow("SELECT store_slug FROM author_storefronts WHERE id = ?", storefrontID).Scan(&slug); err == nil {
+		globalCache.InvalidateStorefront(slug)
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+func handleStorefrontFeaturedLogoDelete(w http.ResponseWriter, r *http.Request) {
+	// Get user_id from X-User-ID header (set by userAuth middleware)
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[STOREFRONT-FEATURED-LOGO-DELETE] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	// Parse pack_listing_id parameter
+	packListingIDStr := r.FormValue("pack_listing_id")
+	packListingID, err := strconv.ParseInt(packListingIDStr, 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的分析包ID"})
+		return
+	}
+
+	// Get storefront ID for the current user
+	var storefrontID int64
+	err = db.QueryRow(`SELECT id FROM author_storefronts WHERE user_id = ?`, userID).Scan(&storefrontID)
+	if err != nil {
+		log.Printf("[STOREFRONT-FEATURED-LOGO-DELETE] storefront not found for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+		return
+	}
+
+	// Verify the pack exists in storefront_packs for this storefront
+	var exists int
+	err = db.QueryRow(`SELECT 1 FROM storefront_packs WHERE storefront_id = ? AND pack_listing_id = ?`, storefrontID, packListingID).Scan(&exists)
+	if err != nil {
+		log.Printf("[STOREFRONT-FEATURED-LOGO-DELETE] pack %d not found in storefront %d: %v", packListingID, storefrontID, err)
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+		return
+	}
+
+	// Set logo_data and logo_content_type to NULL
+	_, err = db.Exec(`UPDATE storefront_packs SET logo_data = NULL, logo_content_type = NULL WHERE storefront_id = ? AND pack_listing_id = ?`,
+		storefrontID, packListingID)
+	if err != nil {
+		log.Printf("[STOREFRONT-FEATURED-LOGO-DELETE] failed to delete logo for storefront %d, pack %d: %v", storefrontID, packListingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "删除失败"})
+		return
+	}
+
+	// Invalidate storefront cache after logo deletion
+	var slug string
+	if err := db.QueryRow("SELECT store_slug FROM author_storefronts WHERE id = ?", storefrontID).Scan(&slug); err == nil {
+		globalCache.InvalidateStorefront(slug)
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+
+func handleStorefrontFeaturedLogo(w http.ResponseWriter, r *http.Request, storeIdentifier string, listingID string) {
+	// Parse listing ID
+	packListingID, err := strconv.ParseInt(listingID, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Resolve store identifier (public_id or numeric ID) to numeric ID
+	storefrontID, _, err := resolveStorefrontID(storeIdentifier)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Query logo data and content type from storefront_packs
+	var logoData []byte
+	var logoContentType string
+	err = db.QueryRow(`SELECT logo_data, COALESCE(logo_content_type, '') FROM storefront_packs WHERE storefront_id = ? AND pack_listing_id = ?`,
+		storefrontID, packListingID).Scan(&logoData, &logoContentType)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(logoData) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if logoContentType == "" {
+		logoContentType = "image/png"
+	}
+
+	w.Header().Set("Content-Type", logoContentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("Content-Length", strconv.Itoa(len(logoData)))
+	w.Write(logoData)
+}
+
+// handleStorefrontBannerImageUpload stores an image for a custom_banner section.
+// The returned image_id is referenced from the banner's layout settings.
+func handleStorefrontBannerImageUpload(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[STOREFRONT-BANNER-IMAGE-UPLOAD] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请选择要上传的图片"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxBannerImageSize {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片大小不能超过 2MB"})
+		return
+	}
+	fileData, err := io.ReadAll(io.LimitReader(file, maxBannerImageSize+1))
 	if err != nil {
-		log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] failed to read file for user %d: %v", userID, err)
+		log.Printf("[STOREFRONT-BANNER-IMAGE-UPLOAD] failed to read file for user %d: %v", userID, err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "读取文件失败"})
 		return
 	}
-	if int64(len(fileData)) > maxLogoSize {
+	if int64(len(fileData)) > maxBannerImageSize {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片大小不能超过 2MB"})
 		return
 	}
 
-	// Validate file format using content detection (PNG/JPEG only)
-	contentType := http.DetectContentType(fileData)
-	if contentType != "image/png" && contentType != "image/jpeg" {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "仅支持 PNG 或 JPEG 格式"})
+	if !isAllowedLogoContentType(detectImageContentType(fileData)) {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "仅支持 PNG、JPEG 或 WebP 格式"})
 		return
 	}
-
-	// Verify the pack belongs to the current user's storefront and is featured
-	var storefrontID int64
-	err = db.QueryRow(`SELECT id FROM author_storefronts WHERE user_id = ?`, userID).Scan(&storefrontID)
+	imageData, contentType, err := processBannerImage(fileData)
 	if err != nil {
-		log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] storefront not found for user %d: %v", userID, err)
-		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+		log.Printf("[STOREFRONT-BANNER-IMAGE-UPLOAD] failed to process image for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片无法解析，请上传有效的图片文件"})
 		return
 	}
 
-	var isFeatured int
-	err = db.QueryRow(`SELECT is_featured FROM storefront_packs WHERE storefront_id = ? AND pack_listing_id = ?`, storefrontID, packListingID).Scan(&isFeatured)
-	if err != nil {
-		log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] pack %d not found in storefront %d: %v", packListingID, storefrontID, err)
-		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+	var storefrontID int64
+	if err := db.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID); err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
 		return
 	}
 
-	if isFeatured != 1 {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "该分析包未设置为推荐"})
+	var imageCount int
+	db.QueryRow("SELECT COUNT(*) FROM storefront_banner_images WHERE storefront_id = ?", storefrontID).Scan(&imageCount)
+	if imageCount >= maxCustomBanners {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("最多上传 %d 张横幅图片，请先删除不再使用的图片", maxCustomBanners)})
 		return
 	}
 
-	// Update logo_data and logo_content_type in storefront_packs
-	_, err = db.Exec(`UPDATE storefront_packs SET logo_data = ?, logo_content_type = ? WHERE storefront_id = ? AND pack_listing_id = ?`,
-		fileData, contentType, storefrontID, packListingID)
+	result, err := db.Exec("INSERT INTO storefront_banner_images (storefront_id, image_data, content_type) VALUES (?, ?, ?)",
+		storefrontID, imageData, contentType)
 	if err != nil {
-		log.Printf("[STOREFRONT-FEATURED-LOGO-UPLOAD] failed to update logo for storefront %d, pack %d: %v", storefrontID, packListingID, err)
+		log.Printf("[STOREFRONT-BANNER-IMAGE-UPLOAD] failed to insert image for storefront %d: %v", storefrontID, err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
 		return
 	}
+	imageID, _ := result.LastInsertId()
 
-	// Invalidate storefront cache after logo upload
-	var slug string
-	if err := db.QueryRow("SELECT store_slug FROM author_storefronts WHERE id = ?", storefrontID).Scan(&slug); err == nil {
-		globalCache.InvalidateStorefront(slug)
-	}
-
-	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "image_id": imageID})
 }
 
-func handleStorefrontFeaturedLogoDelete(w http.ResponseWriter, r *http.Request) {
-	// Get user_id from X-User-ID header (set by userAuth middleware)
+// handleStorefrontBannerImageDelete removes a banner image owned by the current user's storefront.
+func handleStorefrontBannerImageDelete(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.Header.Get("X-User-ID")
 	userID, err := strconv.ParseInt(userIDStr, 10, 64)
 	if err != nil {
-		log.Printf("[STOREFRONT-FEATURED-LOGO-DELETE] invalid X-User-ID header: %q", userIDStr)
+		log.Printf("[STOREFRONT-BANNER-IMAGE-DELETE] invalid X-User-ID header: %q", userIDStr)
 		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
 		return
 	}
 
-	// Parse pack_listing_id parameter
-	packListingIDStr := r.FormValue("pack_listing_id")
-	packListingID, err := strconv.ParseInt(packListingIDStr, 10, 64)
-	if err != nil {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的分析包ID"})
+	imageID, err := strconv.ParseInt(r.FormValue("image_id"), 10, 64)
+	if err != nil || imageID <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的图片ID"})
 		return
 	}
 
-	// Get storefront ID for the current user
 	var storefrontID int64
-	err = db.QueryRow(`SELECT id FROM author_storefronts WHERE user_id = ?`, userID).Scan(&storefrontID)
-	if err != nil {
-		log.Printf("[STOREFRONT-FEATURED-LOGO-DELETE] storefront not found for user %d: %v", userID, err)
-		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
-		return
-	}
-
-	// Verify the pack exists in storefront_packs for this storefront
-	var exists int
-	err = db.QueryRow(`SELECT 1 FROM storefront_packs WHERE storefront_id = ? AND pack_listing_id = ?`, storefrontID, packListingID).Scan(&exists)
-	if err != nil {
-		log.Printf("[STOREFRONT-FEATURED-LOGO-DELETE] pack %d not found in storefront %d: %v", packListingID, storefrontID, err)
-		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+	var slug string
+	if err := db.QueryRow("SELECT id, store_slug FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID, &slug); err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在"})
 		return
 	}
 
-	// Set logo_data and logo_content_type to NULL
-	_, err = db.Exec(`UPDATE storefront_packs SET logo_data = NULL, logo_content_type = NULL WHERE storefront_id = ? AND pack_listing_id = ?`,
-		storefrontID, packListingID)
+	result, err := db.Exec("DELETE FROM storefront_banner_images WHERE id = ? AND storefront_id = ?", imageID, storefrontID)
 	if err != nil {
-		log.Printf("[STOREFRONT-FEATURED-LOGO-DELETE] failed to delete logo for storefront %d, pack %d: %v", storefrontID, packListingID, err)
+		log.Printf("[STOREFRONT-BANNER-IMAGE-DELETE] failed to delete image %d for storefront %d: %v", imageID, storefrontID, err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "删除失败"})
 		return
 	}
-
-	// Invalidate storefront cache after logo deletion
-	var slug string
-	if err := db.QueryRow("SELECT store_slug FROM author_storefronts WHERE id = ?", storefrontID).Scan(&slug); err == nil {
-		globalCache.InvalidateStorefront(slug)
+	if n, _ := result.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "图片不存在"})
+		return
 	}
 
+	globalCache.InvalidateStorefront(slug)
 	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
 }
 
-
-func handleStorefrontFeaturedLogo(w http.ResponseWriter, r *http.Request, storeIdentifier string, listingID string) {
-	// Parse listing ID
-	packListingID, err := strconv.ParseInt(listingID, 10, 64)
+// handleStorefrontBannerImage serves a custom banner image.
+func handleStorefrontBannerImage(w http.ResponseWriter, r *http.Request, storeIdentifier string, imageIDStr string) {
+	imageID, err := strconv.ParseInt(imageIDStr, 10, 64)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
-
-	// Resolve store identifier (public_id or numeric ID) to numeric ID
 	storefrontID, _, err := resolveStorefrontID(storeIdentifier)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	// Query logo data and content type from storefront_packs
-	var logoData []byte
-	var logoContentType string
-	err = db.QueryRow(`SELECT logo_data, COALESCE(logo_content_type, '') FROM storefront_packs WHERE storefront_id = ? AND pack_listing_id = ?`,
-		storefrontID, packListingID).Scan(&logoData, &logoContentType)
-	if err != nil {
-		http.NotFound(w, r)
-		return
-	}
-
-	if len(logoData) == 0 {
+	var imageData []byte
+	var contentType string
+	err = db.QueryRow("SELECT image_data, COALESCE(content_type, '') FROM storefront_banner_images WHERE id = ? AND storefront_id = ?",
+		imageID, storefrontID).Scan(&imageData, &contentType)
+	if err != nil || len(imageData) == 0 {
 		http.NotFound(w, r)
 		return
 	}
-
-	if logoContentType == "" {
-		logoContentType = "image/png"
+	if contentType == "" {
+		contentType = "image/png"
 	}
 
-	w.Header().Set("Content-Type", logoContentType)
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "public, max-age=3600")
-	w.Header().Set("Content-Length", strconv.Itoa(len(logoData)))
-	w.Write(logoData)
+	w.Header().Set("Content-Length", strconv.Itoa(len(imageData)))
+	w.Write(imageData)
 }
 
 func handleStorefrontUpdateSlug(w http.ResponseWriter, r *http.Request) {
@@ -6978,41 +7770,54 @@ func handleStorefrontUpdateSlug(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check uniqueness: slug must not be taken by another user
-	var existingUserID int64
-	err = db.QueryRow(`SELECT user_id FROM author_storefronts WHERE store_slug = ? AND user_id != ?`, slug, userID).Scan(&existingUserID)
-	if err == nil {
-		// Found another user with this slug
-		jsonResponse(w, http.StatusConflict, map[string]string{"error": "该标识已被占用"})
+	// Get the storefront and its old slug (for history and cache invalidation)
+	var storefrontID int64
+	var oldSlug string
+	err = db.QueryRow("SELECT id, store_slug FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID, &oldSlug)
+	if err == sql.ErrNoRows {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
 		return
 	}
-	if err != sql.ErrNoRows {
+	if err != nil {
+		log.Printf("[STOREFRONT-UPDATE-SLUG] failed to query storefront for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "更新标识失败"})
+		return
+	}
+
+	// Check uniqueness: slug must not be the current or a previous slug of another store
+	taken, err := slugTakenByOtherStore(slug, storefrontID)
+	if err != nil {
 		log.Printf("[STOREFRONT-UPDATE-SLUG] failed to check slug uniqueness for user %d: %v", userID, err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "检查标识失败"})
 		return
 	}
+	if taken {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "该标识已被占用"})
+		return
+	}
 
-	// Get old slug before update for cache invalidation
-	var oldSlug string
-	db.QueryRow("SELECT store_slug FROM author_storefronts WHERE user_id = ?", userID).Scan(&oldSlug)
-
-	// Update store_slug in author_storefronts table
-	result, err := db.Exec(`UPDATE author_storefronts SET store_slug = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
-		slug, userID)
+	// Update store_slug and keep the old slug as a redirect
+	tx, err := db.Begin()
 	if err != nil {
+		log.Printf("[STOREFRONT-UPDATE-SLUG] failed to begin transaction for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "更新标识失败"})
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE author_storefronts SET store_slug = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		slug, storefrontID); err != nil {
 		log.Printf("[STOREFRONT-UPDATE-SLUG] failed to update slug for user %d: %v", userID, err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "更新标识失败"})
 		return
 	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Printf("[STOREFRONT-UPDATE-SLUG] failed to get rows affected for user %d: %v", userID, err)
+	if err := recordSlugChange(tx, storefrontID, oldSlug, slug); err != nil {
+		log.Printf("[STOREFRONT-UPDATE-SLUG] failed to record slug history for storefront %d: %v", storefrontID, err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "更新标识失败"})
 		return
 	}
-	if rowsAffected == 0 {
-		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
+	if err := tx.Commit(); err != nil {
+		log.Printf("[STOREFRONT-UPDATE-SLUG] failed to commit slug update for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "更新标识失败"})
 		return
 	}
 
@@ -7094,6 +7899,8 @@ func handleStorefrontAddPack(w http.ResponseWriter, r *http.Request) {
 		globalCache.InvalidateStorefront(slug)
 	}
 
+	go notifyStorefrontFollowers(packListingID)
+
 	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
 }
 
@@ -7558,46 +8365,14 @@ func handleStorefrontSendNotify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Query recipients based on scope
-	type recipient struct {
-		UserID int64
-		Email  string
-	}
-	var recipients []recipient
-
-	if scope == "all" {
-		rows, err := db.Query(`
-			SELECT DISTINCT u.id, u.email FROM user_purchased_packs upp
-			JOIN users u ON upp.user_id = u.id
-			JOIN pack_listings pl ON upp.listing_id = pl.id
-			WHERE pl.user_id = ? AND u.email IS NOT NULL AND u.email != ''
-		`, userID)
-		if err != nil {
-			log.Printf("[STOREFRONT-SEND-NOTIFY] failed to query all recipients for user %d: %v", userID, err)
-			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "查询收件人失败"})
-			return
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var r recipient
-			if err := rows.Scan(&r.UserID, &r.Email); err != nil {
-				log.Printf("[STOREFRONT-SEND-NOTIFY] failed to scan recipient row: %v", err)
-				continue
-			}
-			recipients = append(recipients, r)
-		}
-		if err := rows.Err(); err != nil {
-			log.Printf("[STOREFRONT-SEND-NOTIFY] rows iteration error: %v", err)
-		}
-	} else if scope == "partial" {
+	// Query recipients based on scope (buyers who unsubscribed are excluded)
+	var listingIDs []interface{}
+	if scope == "partial" {
 		if listingIDsStr == "" {
 			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请选择至少一位收件人"})
 			return
 		}
-		parts := strings.Split(listingIDsStr, ",")
-		var listingIDs []interface{}
-		var placeholders []string
-		for _, p := range parts {
+		for _, p := range strings.Split(listingIDsStr, ",") {
 			p = strings.TrimSpace(p)
 			if p == "" {
 				continue
@@ -7607,45 +8382,23 @@ func handleStorefrontSendNotify(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 			listingIDs = append(listingIDs, id)
-			placeholders = append(placeholders, "?")
 		}
 		if len(listingIDs) == 0 {
 			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请选择至少一位收件人"})
 			return
 		}
-
-		// Join with pack_listings to verify the listings belong to this author
-		query := fmt.Sprintf(`
-			SELECT DISTINCT u.id, u.email FROM user_purchased_packs upp
-			JOIN users u ON upp.user_id = u.id
-			JOIN pack_listings pl ON upp.listing_id = pl.id
-			WHERE upp.listing_id IN (%s) AND pl.user_id = ? AND u.email IS NOT NULL AND u.email != ''
-		`, strings.Join(placeholders, ", "))
-
-		queryArgs := append(listingIDs, userID)
-		rows, err := db.Query(query, queryArgs...)
-		if err != nil {
-			log.Printf("[STOREFRONT-SEND-NOTIFY] failed to query partial recipients for user %d: %v", userID, err)
-			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "查询收件人失败"})
-			return
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var r recipient
-			if err := rows.Scan(&r.UserID, &r.Email); err != nil {
-				log.Printf("[STOREFRONT-SEND-NOTIFY] failed to scan recipient row: %v", err)
-				continue
-			}
-			recipients = append(recipients, r)
-		}
-		if err := rows.Err(); err != nil {
-			log.Printf("[STOREFRONT-SEND-NOTIFY] rows iteration error: %v", err)
-		}
-	} else {
+	} else if scope != "all" {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的 scope 参数"})
 		return
 	}
 
+	recipients, err := loadStorefrontRecipients(userID, listingIDs)
+	if err != nil {
+		log.Printf("[STOREFRONT-SEND-NOTIFY] failed to query %s recipients for user %d: %v", scope, userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "查询收件人失败"})
+		return
+	}
+
 	// Validate at least one recipient
 	if len(recipients) == 0 {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请选择至少一位收件人"})
@@ -7677,6 +8430,14 @@ func handleStorefrontSendNotify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Links carry a signed unsubscribe token, so they are built from the
+	// configured site address, never from the request's Host.
+	baseURL, ok := siteBaseURL()
+	if !ok {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "站点地址未配置，无法生成退订链接，请联系管理员"})
+		return
+	}
+
 	// --- Credits billing: 1 credit per recipient ---
 	creditsNeeded := float64(len(recipients))
 	tx, err := db.Begin()
@@ -7717,8 +8478,7 @@ func handleStorefrontSendNotify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Send emails using net/smtp
-	var sendErrors int
+	// Send emails over a single SMTP connection
 	fromHeader := smtpConfig.FromEmail
 	// Use store name as sender name so recipients see the actual shop name
 	senderName := storeName
@@ -7729,42 +8489,52 @@ func handleStorefrontSendNotify(w http.ResponseWriter, r *http.Request) {
 		fromHeader = fmt.Sprintf("%s <%s>", senderName, smtpConfig.FromEmail)
 	}
 
-	addr := fmt.Sprintf("%s:%d", smtpConfig.Host, smtpConfig.Port)
-	var auth smtp.Auth
-	if smtpConfig.Username != "" && smtpConfig.Password != "" {
-		auth = smtp.PlainAuth("", smtpConfig.Username, smtpConfig.Password, smtpConfig.Host)
+	storeURL := fmt.Sprintf("%s/store/%s", baseURL, storeSlug)
+
+	// Template placeholders posted as macro_<Name>; the template is rendered
+	// per recipient in their language.
+	templateData := map[string]string{"StoreName": storeName}
+	for key, values := range r.Form {
+		if name := strings.TrimPrefix(key, "macro_"); name != key && len(values) > 0 {
+			templateData[name] = strings.TrimSpace(values[0])
+		}
 	}
 
+	msgs := make([]smtpMessage, 0, len(recipients))
 	for _, rcpt := range recipients {
+		lang := userLang(rcpt.UserID)
+		rcptSubject, rcptBody := subject, body
+		if templateType != "" {
+			if s, b, ok := renderNotificationTemplate(templateType, lang, templateData); ok {
+				rcptSubject, rcptBody = s, b
+			}
+		}
+		unsubscribeURL := storefrontUnsubscribeURL(baseURL, storefrontID, rcpt.Email)
 		var msg bytes.Buffer
 		// Sanitize subject to prevent email header injection (strip CR/LF)
-		safeSubject := strings.NewReplacer("\r", "", "\n", "").Replace(subject)
+		safeSubject := strings.NewReplacer("\r", "", "\n", "").Replace(rcptSubject)
 		msg.WriteString(fmt.Sprintf("From: %s\r\n", fromHeader))
 		msg.WriteString(fmt.Sprintf("To: %s\r\n", rcpt.Email))
 		msg.WriteString(fmt.Sprintf("Subject: %s\r\n", safeSubject))
+		msg.WriteString(fmt.Sprintf("List-Unsubscribe: <%s>\r\n", unsubscribeURL))
+		msg.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
 		msg.WriteString("MIME-Version: 1.0\r\n")
 		msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
 		msg.WriteString("\r\n")
-		msg.WriteString(body)
-		// Append store URL
-		scheme := "https"
-		if r.TLS == nil && !strings.Contains(r.Host, "vantagics") {
-			scheme = "http"
-		}
-		storeURL := fmt.Sprintf("%s://%s/store/%s", scheme, r.Host, storeSlug)
-		msg.WriteString(fmt.Sprintf("\r\n\r\n---\r\n访问小铺: %s\r\n", storeURL))
-
-		var sendErr error
-		if smtpConfig.UseTLS {
-			sendErr = storefrontSendEmailTLS(smtpConfig, rcpt.Email, msg.Bytes())
-		} else {
-			sendErr = smtp.SendMail(addr, auth, smtpConfig.FromEmail, []string{rcpt.Email}, msg.Bytes())
-		}
-		if sendErr != nil {
-			log.Printf("[STOREFRONT-SEND-NOTIFY] failed to send email to %s: %v", rcpt.Email, sendErr)
-			sendErrors++
-		}
+		msg.WriteString(rcptBody)
+		// Append store URL and unsubscribe link
+		msg.WriteString(fmt.Sprintf(i18n.T(lang, "storefront_email_visit_store"), storeURL))
+		msg.WriteString(fmt.Sprintf(i18n.T(lang, "unsubscribe_email_footer"), unsubscribeURL))
+		msgs = append(msgs, smtpMessage{To: rcpt.Email, Data: msg.Bytes()})
+	}
+
+	sender := newSMTPSender(smtpConfig)
+	sendResult := sender.sendBatch(msgs)
+	sender.Close()
+	for to, sendErr := range sendResult.Errors {
+		log.Printf("[STOREFRONT-SEND-NOTIFY] failed to send email to %s: %v", to, sendErr)
 	}
+	sendErrors := sendResult.Failed
 
 	// Record to storefront_notifications table
 	status := "sent"
@@ -7837,51 +8607,6 @@ func handleStorefrontSendNotify(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// storefrontSendEmailTLS sends an email using direct TLS connection (port 465).
-func storefrontSendEmailTLS(config SMTPConfig, to string, msg []byte) error {
-	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
-	tlsConfig := &tls.Config{ServerName: config.Host}
-
-	conn, err := tls.Dial("tcp", addr, tlsConfig)
-	if err != nil {
-		return fmt.Errorf("TLS dial failed: %v", err)
-	}
-	defer conn.Close()
-
-	client, err := smtp.NewClient(conn, config.Host)
-	if err != nil {
-		return fmt.Errorf("SMTP client creation failed: %v", err)
-	}
-	defer client.Close()
-
-	if config.Username != "" && config.Password != "" {
-		auth := smtp.PlainAuth("", config.Username, config.Password, config.Host)
-		if err := client.Auth(auth); err != nil {
-			return fmt.Errorf("SMTP auth failed: %v", err)
-		}
-	}
-
-	if err := client.Mail(config.FromEmail); err != nil {
-		return fmt.Errorf("SMTP MAIL command failed: %v", err)
-	}
-	if err := client.Rcpt(to); err != nil {
-		return fmt.Errorf("SMTP RCPT command failed: %v", err)
-	}
-
-	w, err := client.Data()
-	if err != nil {
-		return fmt.Errorf("SMTP DATA command failed: %v", err)
-	}
-	if _, err = w.Write(msg); err != nil {
-		return fmt.Errorf("SMTP write failed: %v", err)
-	}
-	if err = w.Close(); err != nil {
-		return fmt.Errorf("SMTP close failed: %v", err)
-	}
-
-	return client.Quit()
-}
-
 func handleStorefrontGetRecipients(w http.ResponseWriter, r *http.Request) {
 	// Get user_id from X-User-ID header (set by userAuth middleware)
 	userIDStr := r.Header.Get("X-User-ID")
@@ -7901,12 +8626,8 @@ func handleStorefrontGetRecipients(w http.ResponseWriter, r *http.Request) {
 
 	if scope == "all" {
 		// Query all distinct users who purchased any pack by this author
-		err = db.QueryRow(`
-			SELECT COUNT(DISTINCT u.id) FROM user_purchased_packs upp
-			JOIN users u ON upp.user_id = u.id
-			JOIN pack_listings pl ON upp.listing_id = pl.id
-			WHERE pl.user_id = ? AND u.email IS NOT NULL AND u.email != ''
-		`, userID).Scan(&count)
+		where, args := storefrontRecipientsWhere(userID, nil)
+		err = db.QueryRow("SELECT COUNT(DISTINCT u.id) "+where, args...).Scan(&count)
 		if err != nil {
 			log.Printf("[STOREFRONT-GET-RECIPIENTS] failed to query all recipients for user %d: %v", userID, err)
 			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "查询收件人失败"})
@@ -7922,7 +8643,6 @@ func handleStorefrontGetRecipients(w http.ResponseWriter, r *http.Request) {
 
 		parts := strings.Split(listingIDsStr, ",")
 		var listingIDs []interface{}
-		var placeholders []string
 		for _, p := range parts {
 			p = strings.TrimSpace(p)
 			if p == "" {
@@ -7933,7 +8653,6 @@ func handleStorefrontGetRecipients(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 			listingIDs = append(listingIDs, id)
-			placeholders = append(placeholders, "?")
 		}
 
 		if len(listingIDs) == 0 {
@@ -7941,15 +8660,8 @@ func handleStorefrontGetRecipients(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		query := fmt.Sprintf(`
-			SELECT COUNT(DISTINCT u.id) FROM user_purchased_packs upp
-			JOIN users u ON upp.user_id = u.id
-			JOIN pack_listings pl ON upp.listing_id = pl.id
-			WHERE upp.listing_id IN (%s) AND pl.user_id = ? AND u.email IS NOT NULL AND u.email != ''
-		`, strings.Join(placeholders, ", "))
-
-		queryArgs := append(listingIDs, userID)
-		err = db.QueryRow(query, queryArgs...).Scan(&count)
+		where, args := storefrontRecipientsWhere(userID, listingIDs)
+		err = db.QueryRow("SELECT COUNT(DISTINCT u.id) "+where, args...).Scan(&count)
 		if err != nil {
 			log.Printf("[STOREFRONT-GET-RECIPIENTS] failed to query partial recipients for user %d: %v", userID, err)
 			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "查询收件人失败"})
@@ -8264,9 +8976,8 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		r.Header.Set("X-Display-Name", displayName)
 
 		// Check if user is blocked
-		var isBlocked int
-		if err := db.QueryRow("SELECT COALESCE(is_blocked, 0) FROM users WHERE id = ?", userID).Scan(&isBlocked); err == nil && isBlocked == 1 {
-			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "account_blocked", "message": "Your account has been blocked"})
+		if b := userBlockStatus(userID, time.Now()); b.Blocked {
+			writeBlockedJSON(w, r, b)
 			return
 		}
 
@@ -8333,6 +9044,23 @@ func createSession(adminID int64) string {
 	return id
 }
 
+// rotateSession issues a fresh admin session ID for adminID and drops the ID
+// the client presented, so a session fixed before login is never promoted.
+// With revokeAll every other session of adminID is ended too (password change).
+func rotateSession(oldID string, adminID int64, revokeAll bool) string {
+	sessionsMu.Lock()
+	delete(sessions, oldID)
+	if revokeAll {
+		for sid, entry := range sessions {
+			if entry.AdminID == adminID {
+				delete(sessions, sid)
+			}
+		}
+	}
+	sessionsMu.Unlock()
+	return createSession(adminID)
+}
+
 // isValidSession checks if a session ID is valid and not expired.
 func isValidSession(id string) bool {
 	sessionsMu.RLock()
@@ -8384,6 +9112,8 @@ var (
 type userSessionEntry struct {
 	UserID int64
 	Expiry time.Time
+	// ImpersonatorID is the admin behind an impersonation session, 0 otherwise.
+	ImpersonatorID int64
 }
 
 // Login ticket store for one-time ticket-based login (SSO from desktop client)
@@ -8428,6 +9158,55 @@ func createUserSession(userID int64) string {
 	return id
 }
 
+// rotateUserSession is the user-session counterpart of rotateSession.
+func rotateUserSession(oldID string, userID int64, revokeAll bool) string {
+	userSessionsMu.Lock()
+	delete(userSessions, oldID)
+	if revokeAll {
+		for sid, entry := range userSessions {
+			if entry.UserID == userID {
+				delete(userSessions, sid)
+			}
+		}
+	}
+	userSessionsMu.Unlock()
+	return createUserSession(userID)
+}
+
+// revokeEmailUserSessions ends the sessions of every account bound to email.
+// Passwords are email-level, so a password change affects all of them.
+func revokeEmailUserSessions(email string) {
+	rows, err := db.Query("SELECT id FROM users WHERE email = ?", email)
+	if err != nil {
+		log.Printf("[SESSION] failed to list users for email %q: %v", email, err)
+		return
+	}
+	userIDs := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			userIDs[id] = true
+		}
+	}
+	rows.Close()
+	userSessionsMu.Lock()
+	for sid, entry := range userSessions {
+		if userIDs[entry.UserID] {
+			delete(userSessions, sid)
+		}
+	}
+	userSessionsMu.Unlock()
+}
+
+// getUserSessionFromRequest extracts the user session ID from cookie.
+func getUserSessionFromRequest(r *http.Request) string {
+	cookie, err := r.Cookie("user_session")
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
 // isValidUserSession checks if a user session ID is valid and not expired.
 func isValidUserSession(id string) bool {
 	userSessionsMu.RLock()
@@ -8438,8 +9217,12 @@ func isValidUserSession(id string) bool {
 	}
 	if time.Now().After(entry.Expiry) {
 		userSessionsMu.Lock()
+		_, stillThere := userSessions[id]
 		delete(userSessions, id)
 		userSessionsMu.Unlock()
+		if stillThere && entry.ImpersonatorID != 0 {
+			logImpersonationEnd(entry.ImpersonatorID, entry.UserID, "expired", "")
+		}
 		return false
 	}
 	return true
@@ -8468,7 +9251,7 @@ func getAdminRole(adminID int64) string {
 }
 
 // allPermissions is the complete list of assignable permission keys.
-var allPermissions = []string{"categories", "marketplace", "accounts", "authors", "review", "settings", "customers", "sales", "notifications", "billing", "storefront_support"}
+var allPermissions = registeredPermissionKeys()
 
 // getAdminPermissions returns the permission list for the given admin ID.
 // id=1 always gets all permissions. Others get what's stored in the DB.
@@ -8484,20 +9267,21 @@ func getAdminPermissions(adminID int64) []string {
 	return strings.Split(perms, ",")
 }
 
-// hasPermission checks if the given admin has a specific permission.
-// "accounts" permission is satisfied by having "accounts", "authors", or "customers".
-func hasPermission(adminID int64, perm string) bool {
+// hasPermission checks if the given admin has a specific permission, directly
+// or through one of its permissionAliases.
+func hasPermission(adminID int64, perm Permission) bool {
 	if adminID == 1 {
 		return true
 	}
 	perms := getAdminPermissions(adminID)
 	for _, p := range perms {
-		if p == perm {
+		if Permission(p) == perm {
 			return true
 		}
-		// Backward compatibility: "accounts" is satisfied by old "authors" or "customers" permissions
-		if perm == "accounts" && (p == "authors" || p == "customers") {
-			return true
+		for _, alias := range permissionAliases[perm] {
+			if Permission(p) == alias {
+				return true
+			}
 		}
 	}
 	return false
@@ -8505,7 +9289,11 @@ func hasPermission(adminID int64, perm string) bool {
 
 // permissionAuth creates a middleware that checks if the admin has the specified permission.
 // id=1 always passes. Other admins must have the permission in their permissions list.
-func permissionAuth(permission string) func(http.HandlerFunc) http.HandlerFunc {
+// The permission must be in permissionRegistry; a typo panics at route registration.
+func permissionAuth(permission Permission) func(http.HandlerFunc) http.HandlerFunc {
+	if _, ok := lookupPermission(permission); !ok {
+		panic(fmt.Sprintf("permissionAuth: unregistered permission %q", permission))
+	}
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			if !isAdminSetup() {
@@ -8516,6 +9304,17 @@ func permissionAuth(permission string) func(http.HandlerFunc) http.HandlerFunc {
 				}
 				return
 			}
+			// Admin API keys are accepted on API routes instead of a session.
+			if raw, ok := bearerAPIKey(r); ok && isAPIRoute(r) {
+				adminID, status, code := authenticateAdminAPIKey(raw, permission)
+				if adminID == 0 {
+					jsonResponse(w, status, map[string]string{"error": code})
+					return
+				}
+				r.Header.Set("X-Admin-ID", strconv.FormatInt(adminID, 10))
+				next(w, r)
+				return
+			}
 			sid := getSessionFromRequest(r)
 			if !isValidSession(sid) {
 				if isAPIRoute(r) {
@@ -8535,7 +9334,7 @@ func permissionAuth(permission string) func(http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 			if !hasPermission(adminID, permission) {
-				jsonResponse(w, http.StatusForbidden, map[string]string{"error": "permission_denied"})
+				permissionDenied(w, permission)
 				return
 			}
 			r.Header.Set("X-Admin-ID", strconv.FormatInt(adminID, 10))
@@ -8594,15 +9393,22 @@ func userAuth(next http.HandlerFunc) http.HandlerFunc {
 		userID := getUserSessionUserID(cookie.Value)
 
 		// Check if user is blocked
-		var isBlocked int
-		if err := db.QueryRow("SELECT COALESCE(is_blocked, 0) FROM users WHERE id = ?", userID).Scan(&isBlocked); err == nil && isBlocked == 1 {
+		if userBlockStatus(userID, time.Now()).Blocked {
 			// Clear session and redirect to login with error
 			http.SetCookie(w, makeSessionCookie("user_session", "", -1))
-			http.Redirect(w, r, "/user/login?error=blocked", http.StatusFound)
+			http.Redirect(w, r, blockedLoginURL(userID), http.StatusFound)
 			return
 		}
 
 		r.Header.Set("X-User-ID", fmt.Sprintf("%d", userID))
+		r.Header.Del("X-Impersonator-ID")
+		if adminID := getUserSessionImpersonator(cookie.Value); adminID != 0 {
+			r.Header.Set("X-Impersonator-ID", strconv.FormatInt(adminID, 10))
+			bw := &impersonationBannerWriter{ResponseWriter: w, banner: impersonationBanner(i18n.DetectLang(r), userID)}
+			next(bw, r)
+			bw.finish()
+			return
+		}
 		next(w, r)
 	}
 }
@@ -9088,7 +9894,7 @@ func handleAdminSetup(w http.ResponseWriter, r *http.Request) {
 
 	adminID, _ := result.LastInsertId()
 	// Auto-login after setup
-	sid := createSession(adminID)
+	sid := rotateSession(getSessionFromRequest(r), adminID, false)
 	http.SetCookie(w, makeSessionCookie("admin_session", sid, 86400))
 	http.Redirect(w, r, "/admin/", http.StatusFound)
 }
@@ -9128,8 +9934,13 @@ func handleAdminLogin(w http.ResponseWriter, r *http.Request) {
 
 	errMsg := ""
 	lang := i18n.DetectLang(r)
+	clientIP := getClientIP(r)
 	var adminID int64
-	if !verifyCaptcha(captchaID, captchaAns) {
+	passwordFailed := false
+	if adminLoginLocked(username, clientIP, time.Now()) {
+		log.Printf("[LOGIN] rejected locked-out attempt for username=%q ip=%s", username, clientIP)
+		errMsg = i18n.T(lang, "admin_login_locked")
+	} else if !verifyCaptcha(captchaID, captchaAns) {
 		log.Printf("[LOGIN] captcha verification failed for ID=%q answer=%q", captchaID, captchaAns)
 		errMsg = i18n.T(lang, "captcha_error_admin")
 	} else {
@@ -9138,11 +9949,24 @@ func handleAdminLogin(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log.Printf("[LOGIN] db query error for username=%q: %v", username, err)
 			errMsg = i18n.T(lang, "admin_login_error")
+			passwordFailed = true
 		} else if !checkPassword(password, storedHash) {
 			log.Printf("[LOGIN] password check failed for username=%q adminID=%d", username, adminID)
 			errMsg = i18n.T(lang, "admin_login_error")
+			passwordFailed = true
+		} else if isAdminTOTPEnabled(adminID) && !verifyAdminSecondFactor(adminID, r.FormValue("totp_code")) {
+			log.Printf("[LOGIN] two-factor check failed for username=%q adminID=%d", username, adminID)
+			// Same message as a wrong password, so the form does not confirm the password.
+			errMsg = i18n.T(lang, "admin_login_error")
+			passwordFailed = true
 		} else {
 			log.Printf("[LOGIN] success for username=%q adminID=%d", username, adminID)
+			clearAdminLoginFailures(username, clientIP)
+		}
+	}
+	if passwordFailed {
+		if lockedFor := recordAdminLoginFailure(username, clientIP, time.Now()); lockedFor > 0 {
+			errMsg = i18n.T(lang, "admin_login_locked")
 		}
 	}
 
@@ -9159,7 +9983,7 @@ func handleAdminLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sid := createSession(adminID)
+	sid := rotateSession(getSessionFromRequest(r), adminID, false)
 	http.SetCookie(w, makeSessionCookie("admin_session", sid, 86400))
 	http.Redirect(w, r, "/admin/", http.StatusFound)
 }
@@ -9180,13 +10004,22 @@ func handleAdminLogout(w http.ResponseWriter, r *http.Request) {
 func handleUserLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		redirect := r.URL.Query().Get("redirect")
+		errMsg := ""
+		switch errKey := r.URL.Query().Get("error"); errKey {
+		case "oauth_failed", "oauth_email_unverified":
+			errMsg = i18n.T(i18n.DetectLang(r), errKey)
+		case "blocked":
+			errMsg = blockedLoginMessage(r)
+		}
 		captchaID := createMathCaptcha()
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		data := i18n.TemplateData(r)
 		i18n.MergeTemplateData(data, map[string]interface{}{
-			"CaptchaID": captchaID,
-			"Error":     "",
-			"Redirect":  redirect,
+			"CaptchaID":   captchaID,
+			"Error":       errMsg,
+			"Redirect":    redirect,
+			"OAuthGoogle": isOAuthLoginEnabled("google"),
+			"OAuthGitHub": isOAuthLoginEnabled("github"),
 		})
 		if err := templates.UserLoginTmpl.Execute(w, data); err != nil {
 			log.Printf("[USER-LOGIN] template execute error: %v", err)
@@ -9220,9 +10053,21 @@ func handleUserLogin(w http.ResponseWriter, r *http.Request) {
 			log.Printf("[USER-LOGIN] password check failed for email=%q", email)
 			errMsg = i18n.T(lang, "login_error")
 		} else {
-			// Find the first non-blocked user record for this email to create session
-			err = db.QueryRow("SELECT id FROM users WHERE email = ? AND COALESCE(is_blocked, 0) = 0 ORDER BY id ASC LIMIT 1", email).Scan(&userID)
-			if err != nil {
+			// Find the first non-blocked user record for this email to create session;
+			// a record whose temporary block has run out counts as unblocked.
+			now := time.Now()
+			err = db.QueryRow(`SELECT id FROM users WHERE email = ? AND (COALESCE(is_blocked, 0) = 0
+				OR (COALESCE(blocked_until, '') != '' AND blocked_until <= ?)) ORDER BY id ASC LIMIT 1`,
+				email, now.UTC().Format("2006-01-02 15:04:05")).Scan(&userID)
+			if err == nil {
+				userBlockStatus(userID, now)
+			}
+			var blockedID int64
+			if err == sql.ErrNoRows && db.QueryRow("SELECT id FROM users WHERE email = ? AND is_blocked = 1 ORDER BY id ASC LIMIT 1", email).Scan(&blockedID) == nil {
+				log.Printf("[USER-LOGIN] all accounts blocked for email=%q", email)
+				errMsg = blockedMessage(lang, userBlockStatus(blockedID, now))
+				userID = 0
+			} else if err != nil {
 				log.Printf("[USER-LOGIN] no active user record found for email=%q: %v", email, err)
 				errMsg = i18n.T(lang, "login_error")
 			} else {
@@ -9236,9 +10081,11 @@ func handleUserLogin(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		data := i18n.TemplateData(r)
 		i18n.MergeTemplateData(data, map[string]interface{}{
-			"CaptchaID": newCaptchaID,
-			"Error":     errMsg,
-			"Redirect":  redirect,
+			"CaptchaID":   newCaptchaID,
+			"Error":       errMsg,
+			"Redirect":    redirect,
+			"OAuthGoogle": isOAuthLoginEnabled("google"),
+			"OAuthGitHub": isOAuthLoginEnabled("github"),
 		})
 		if err := templates.UserLoginTmpl.Execute(w, data); err != nil {
 			log.Printf("[USER-LOGIN] template execute error: %v", err)
@@ -9246,7 +10093,7 @@ func handleUserLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sid := createUserSession(userID)
+	sid := rotateUserSession(getUserSessionFromRequest(r), userID, false)
 	http.SetCookie(w, makeSessionCookie("user_session", sid, 86400))
 
 	// Redirect to the original page if redirect parameter is a valid internal path
@@ -9269,6 +10116,7 @@ func handleUserRegister(w http.ResponseWriter, r *http.Request) {
 			"CaptchaID": captchaID,
 			"Error":     "",
 			"Redirect":  redirect,
+			"Ref":       normalizeReferralCode(r.URL.Query().Get("ref")),
 		})
 		if err := templates.UserRegisterTmpl.Execute(w, data); err != nil {
 			log.Printf("[USER-REGISTER] template execute error: %v", err)
@@ -9285,6 +10133,7 @@ func handleUserRegister(w http.ResponseWriter, r *http.Request) {
 	captchaID := r.FormValue("captcha_id")
 	captchaAns := strings.TrimSpace(r.FormValue("captcha_answer"))
 	redirect := r.FormValue("redirect")
+	ref := normalizeReferralCode(r.FormValue("ref"))
 
 	log.Printf("[USER-REGISTER] attempt: email=%q, captchaID=%q", email, captchaID)
 
@@ -9296,6 +10145,7 @@ func handleUserRegister(w http.ResponseWriter, r *http.Request) {
 			"CaptchaID": newCaptchaID,
 			"Error":     msg,
 			"Redirect":  redirect,
+			"Ref":       ref,
 		})
 		if err := templates.UserRegisterTmpl.Execute(w, data); err != nil {
 			log.Printf("[USER-REGISTER] template execute error: %v", err)
@@ -9443,10 +10293,14 @@ func handleUserRegister(w http.ResponseWriter, r *http.Request) {
 			hashed, username, email)
 	}
 
+	if ref != "" {
+		linkReferral(userID, ref)
+	}
+
 	log.Printf("[USER-REGISTER] success: email=%q sn=%q userID=%d username=%q", email, sn, userID, username)
 
 	// Step 6: Create session and redirect
-	sid := createUserSession(userID)
+	sid := rotateUserSession(getUserSessionFromRequest(r), userID, false)
 	http.SetCookie(w, makeSessionCookie("user_session", sid, 86400))
 
 	// Redirect to the original page if redirect parameter is a valid internal path (security: only allow /pack/ and /store/ prefix)
@@ -9473,6 +10327,10 @@ type PurchasedPackInfo struct {
 	AuthorName     string
 	DownloadCount  int
 	Version        int
+	BillingCycle   string // "monthly" or "yearly" when PayPal auto-renewal is offered
+	AutoRenew      bool
+	TimeLimited    bool // access ends valid_days after purchase unless renewed
+	Expired        bool // time-limited access has ended (including the grace period)
 }
 
 // BillingRecord holds a single billing/transaction record for the user billing page.
@@ -9497,6 +10355,10 @@ type AuthorPackInfo struct {
 	TotalRevenue float64
 	Version      int
 	ShareToken   string
+	PublishAt    string // RFC 3339, set while a release time is scheduled
+	SalePrice    int    // flash sale price in credits, 0 when no sale is configured
+	SaleStart    string // RFC 3339
+	SaleEnd      string // RFC 3339
 }
 
 // AuthorDashboardData holds all author panel data for the user dashboard.
@@ -9782,13 +10644,66 @@ func handleUserDashboard(w http.ResponseWriter, r *http.Request) {
 			packs[i].PurchaseDate = rr.CreatedAt
 		}
 
-		packs[i].ExpiresAt = currentExpiry.Format("2006-01-02 15:04:05")
+		packs[i].ExpiresAt = currentExpiry.Format("2006-01-02 15:04:05")
+	}
+
+	// Time-limited packs: expiry including renewals, and whether access has ended
+	for i := range packs {
+		if !isTimeLimitedPack(packs[i].ShareMode, packs[i].ValidDays) {
+			continue
+		}
+		packs[i].TimeLimited = true
+		if expiry, ok := timeLimitedExpiry(userID, packs[i].ListingID, packs[i].ValidDays); ok {
+			packs[i].ExpiresAt = expiry.Format("2006-01-02 15:04:05")
+			packs[i].Expired = timeLimitedAccessEnded(expiry, time.Now().UTC())
+		}
+	}
+
+	// PayPal auto-renewal: which subscription packs bill on a cycle, which the
+	// user has auto-renewing, and how far PayPal has already billed them.
+	if len(subListingIDs) > 0 && subscriptionUSDPerCredit() > 0 {
+		placeholders := make([]string, len(subListingIDs))
+		args := make([]interface{}, 0, len(subListingIDs)+1)
+		args = append(args, userID)
+		for i, lid := range subListingIDs {
+			placeholders[i] = "?"
+			args = append(args, lid)
+		}
+		autoRows, err := db.Query(`SELECT pl.id, COALESCE(pl.billing_cycle, ''),
+			    COALESCE(MAX(CASE WHEN ps.status = 'active' THEN 1 ELSE 0 END), 0), COALESCE(MAX(ps.paid_through), '')
+			FROM pack_listings pl
+			LEFT JOIN pack_subscriptions ps ON ps.listing_id = pl.id AND ps.user_id = ? AND ps.status != 'ended'
+			WHERE pl.id IN (`+strings.Join(placeholders, ",")+`)
+			GROUP BY pl.id`, args...)
+		if err != nil {
+			log.Printf("[USER-DASHBOARD] failed to query auto-renewal status for user %d: %v", userID, err)
+		} else {
+			defer autoRows.Close()
+			for autoRows.Next() {
+				var listingID int64
+				var cycle, paidThrough string
+				var autoRenew int
+				if err := autoRows.Scan(&listingID, &cycle, &autoRenew, &paidThrough); err != nil {
+					continue
+				}
+				for _, i := range subIndexMap[listingID] {
+					if packBillingCycleMonths(cycle) > 0 {
+						packs[i].BillingCycle = cycle
+					}
+					packs[i].AutoRenew = autoRenew == 1
+					if paidThrough > packs[i].ExpiresAt {
+						packs[i].ExpiresAt = paidThrough
+					}
+				}
+			}
+		}
 	}
 
 	// Query password status from email_wallets (email-level)
 	var walletPwHash sql.NullString
 	db.QueryRow("SELECT password_hash FROM email_wallets WHERE email = ?", user.Email).Scan(&walletPwHash)
 	hasPassword := walletPwHash.Valid && walletPwHash.String != ""
+	emailVerified := isEmailVerified(user.Email)
 
 	// --- Task 3.1: Author role detection + Task 3.3: Author packs ---
 	// Combine into a single flow: query author packs directly, derive isAuthor from result.
@@ -9803,7 +10718,8 @@ func handleUserDashboard(w http.ResponseWriter, r *http.Request) {
 	authorRows, err := db.Query(`
 		SELECT pl.id, pl.pack_name, pl.pack_description, pl.share_mode, pl.credits_price, pl.status,
 		       COALESCE(sales.sold_count, 0), COALESCE(sales.total_revenue, 0) * ? / 100,
-		       COALESCE(pl.version, 1), COALESCE(pl.share_token, '')
+		       COALESCE(pl.version, 1), COALESCE(pl.share_token, ''), COALESCE(pl.publish_at, ''),
+		       COALESCE(pl.sale_price, 0), COALESCE(pl.sale_start, ''), COALESCE(pl.sale_end, '')
 		FROM pack_listings pl
 		LEFT JOIN (
 		    SELECT listing_id, COUNT(*) as sold_count, SUM(ABS(amount)) as total_revenue
@@ -9821,17 +10737,21 @@ func handleUserDashboard(w http.ResponseWriter, r *http.Request) {
 		defer authorRows.Close()
 		for authorRows.Next() {
 			var ap AuthorPackInfo
-			if err := authorRows.Scan(&ap.ListingID, &ap.PackName, &ap.PackDesc, &ap.ShareMode, &ap.CreditsPrice, &ap.Status, &ap.SoldCount, &ap.TotalRevenue, &ap.Version, &ap.ShareToken); err != nil {
+			if err := authorRows.Scan(&ap.ListingID, &ap.PackName, &ap.PackDesc, &ap.ShareMode, &ap.CreditsPrice, &ap.Status, &ap.SoldCount, &ap.TotalRevenue, &ap.Version, &ap.ShareToken, &ap.PublishAt, &ap.SalePrice, &ap.SaleStart, &ap.SaleEnd); err != nil {
 				log.Printf("[USER-DASHBOARD] failed to scan author pack row: %v", err)
 				continue
 			}
+			ap.PublishAt = publishAtRFC3339(ap.PublishAt)
+			ap.SaleStart = publishAtRFC3339(ap.SaleStart)
+			ap.SaleEnd = publishAtRFC3339(ap.SaleEnd)
 			authorData.AuthorPacks = append(authorData.AuthorPacks, ap)
 		}
 		if err := authorRows.Err(); err != nil {
 			log.Printf("[handleUserDashboard] authorRows iteration error: %v", err)
 		}
 	}
-	isAuthor := len(authorData.AuthorPacks) > 0
+	carryover := storefrontTransferCarryover(userID)
+	isAuthor := len(authorData.AuthorPacks) > 0 || carryover > 0
 	authorData.IsAuthor = isAuthor
 
 	if isAuthor {
@@ -9848,6 +10768,7 @@ func handleUserDashboard(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log.Printf("[USER-DASHBOARD] failed to query total revenue for user %d: %v", userID, err)
 		}
+		totalRevenue += carryover
 
 		// Apply revenue split: publisher only gets their configured share (splitPct already loaded above)
 		publisherRevenue := totalRevenue * splitPct / 100
@@ -9884,42 +10805,14 @@ func handleUserDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// --- Task 9.1: Query visible notifications for this user ---
-	var notifications []NotificationInfo
-	now := time.Now()
-	notifRows, err := db.Query(`
-		SELECT DISTINCT n.id, n.title, n.content, n.target_type, n.effective_date, n.display_duration_days, n.created_at
-		FROM notifications n
-		LEFT JOIN notification_targets nt ON n.id = nt.notification_id
-		WHERE n.status = 'active'
-		  AND n.effective_date <= ?
-		  AND (n.target_type = 'broadcast' OR (n.target_type = 'targeted' AND nt.user_id = ?))
-		ORDER BY n.created_at DESC`,
-		now.Format(time.RFC3339), userID,
-	)
+	notifications, err := visibleNotifications(userID, time.Now())
 	if err != nil {
 		log.Printf("[USER-DASHBOARD] failed to query notifications for user %d: %v", userID, err)
-	} else {
-		defer notifRows.Close()
-		for notifRows.Next() {
-			var n NotificationInfo
-			var effectiveDateStr string
-			if err := notifRows.Scan(&n.ID, &n.Title, &n.Content, &n.TargetType, &effectiveDateStr, &n.DisplayDurationDays, &n.CreatedAt); err != nil {
-				log.Printf("[USER-DASHBOARD] failed to scan notification row: %v", err)
-				continue
-			}
-			effectiveDate, err := time.Parse(time.RFC3339, effectiveDateStr)
-			if err != nil {
-				log.Printf("[USER-DASHBOARD] failed to parse effective_date for notification %d: %v", n.ID, err)
-				continue
-			}
-			if !isNotificationVisible(effectiveDate, n.DisplayDurationDays, now) {
-				continue
-			}
-			n.EffectiveDate = effectiveDateStr
-			notifications = append(notifications, n)
-		}
-		if err := notifRows.Err(); err != nil {
-			log.Printf("[handleUserDashboard] notifRows iteration error: %v", err)
+	}
+	unreadNotifications := 0
+	for _, n := range notifications {
+		if !n.Read {
+			unreadNotifications++
 		}
 	}
 
@@ -9993,14 +10886,20 @@ func handleUserDashboard(w http.ResponseWriter, r *http.Request) {
 	if defaultLang == "" {
 		defaultLang = "zh-CN"
 	}
+	giftMin, giftDailyLimit := getCreditsGiftLimits()
 	if err := templates.UserDashboardTmpl.Execute(w, map[string]interface{}{
 		"User":                user,
 		"PurchasedPacks":      packs,
 		"HasPassword":         hasPassword,
+		"EmailVerified":       emailVerified,
 		"AuthorData":          authorData,
 		"TopPacksByDownloads": topPacksByDownloads,
 		"TopPacksByRevenue":   topPacksByRevenue,
+		"ExpiringCredits":     upcomingCreditsExpiries(userID),
+		"GiftMinCredits":      giftMin,
+		"GiftDailyLimit":      giftDailyLimit,
 		"Notifications":  notifications,
+		"UnreadNotifications": unreadNotifications,
 		"SuccessMsg":     successMsg,
 		"ErrorMsg":       errorMsg,
 		"DefaultLang":    defaultLang,
@@ -10106,6 +11005,8 @@ func handleUserRenewPerUse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	creditsPrice = packPriceAt(listingID, creditsPrice, time.Now())
+
 	if shareMode != "per_use" {
 		http.Redirect(w, r, "/user/?error=not_per_use", http.StatusFound)
 		return
@@ -10152,33 +11053,25 @@ func handleUserRenewPerUse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := addPackUses(tx, userID, listingID, quantity); err != nil {
+		log.Printf("[USER-RENEW-USES] failed to update total_purchased (user=%d, listing=%d): %v", userID, listingID, err)
+		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
+		return
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Printf("[USER-RENEW-USES] failed to commit transaction: %v", err)
 		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
 		return
 	}
+	publishPackSaleEvent(listingID, "purchase_uses", float64(totalCost))
+	grantReferralRewards(userID)
 
 	// Record/restore user purchased pack
 	if err := upsertUserPurchasedPack(userID, listingID); err != nil {
 		log.Printf("[USER-RENEW-USES] failed to upsert user purchased pack (user=%d, listing=%d): %v", userID, listingID, err)
 	}
 
-	// Sync pack_usage_records.total_purchased after successful renewal
-	_, err = db.Exec(
-		`INSERT OR IGNORE INTO pack_usage_records (user_id, listing_id, used_count, total_purchased) VALUES (?, ?, 0, 0)`,
-		userID, listingID,
-	)
-	if err != nil {
-		log.Printf("[USER-RENEW-USES] failed to insert pack_usage_records (user=%d, listing=%d): %v", userID, listingID, err)
-	}
-	_, err = db.Exec(
-		`UPDATE pack_usage_records SET total_purchased = total_purchased + ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ? AND listing_id = ?`,
-		quantity, userID, listingID,
-	)
-	if err != nil {
-		log.Printf("[USER-RENEW-USES] failed to update total_purchased (user=%d, listing=%d): %v", userID, listingID, err)
-	}
-
 	log.Printf("[USER-RENEW-USES] user %d renewed %d uses for pack %d (%s), cost=%d", userID, quantity, listingID, packName, totalCost)
 
 	// Invalidate user purchased cache after renewing per-use pack
@@ -10234,6 +11127,8 @@ func handleUserRenewSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	creditsPrice = packPriceAt(listingID, creditsPrice, time.Now())
+
 	if shareMode != "subscription" {
 		http.Redirect(w, r, "/user/?error=not_subscription", http.StatusFound)
 		return
@@ -10294,6 +11189,7 @@ func handleUserRenewSubscription(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
 		return
 	}
+	publishPackSaleEvent(listingID, "renew", float64(totalCost))
 
 	// Record/restore user purchased pack
 	if err := upsertUserPurchasedPack(userID, listingID); err != nil {
@@ -10427,6 +11323,7 @@ var validOAuthProviders = map[string]bool{
 	"apple":    true,
 	"facebook": true,
 	"amazon":   true,
+	"github":   true,
 }
 
 // handleOAuthCallback handles POST /api/auth/oauth.
@@ -10820,14 +11717,13 @@ func handleTicketLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if user is blocked
-	var isBlocked int
-	if err := db.QueryRow("SELECT COALESCE(is_blocked, 0) FROM users WHERE id = ?", userID).Scan(&isBlocked); err == nil && isBlocked == 1 {
-		http.Redirect(w, r, "/user/login?error=blocked", http.StatusFound)
+	if userBlockStatus(userID, time.Now()).Blocked {
+		http.Redirect(w, r, blockedLoginURL(userID), http.StatusFound)
 		return
 	}
 
 	// Create session
-	sid := createUserSession(userID)
+	sid := rotateUserSession(getUserSessionFromRequest(r), userID, false)
 	http.SetCookie(w, makeSessionCookie("user_session", sid, 86400))
 
 	// Check if this email has a password set in email_wallets
@@ -10931,6 +11827,12 @@ func handleUserChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sign out every other session on this email and keep the current client
+	// logged in under a fresh session ID.
+	revokeEmailUserSessions(email)
+	sid := rotateUserSession(getUserSessionFromRequest(r), userID, true)
+	http.SetCookie(w, makeSessionCookie("user_session", sid, 86400))
+
 	log.Printf("[CHANGE-PASSWORD] email %s (user %d) changed password successfully", email, userID)
 	renderForm("", i18n.T(lang, "change_password_success"))
 }
@@ -11040,14 +11942,9 @@ func handleUserSetPassword(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/user/dashboard", http.StatusFound)
 }
 
-// handleListCategories handles GET /api/categories.
-// Returns all categories with pack_count (number of published pack_listings per category).
-func handleListCategories(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
-
+// queryCategories returns all categories with pack_count (number of published
+// pack_listings per category).
+func queryCategories() ([]PackCategory, error) {
 	rows, err := db.Query(`
 		SELECT c.id, c.name, c.description, c.is_preset,
 			COUNT(CASE WHEN pl.status = 'published' THEN 1 END) AS pack_count
@@ -11057,9 +11954,7 @@ func handleListCategories(w http.ResponseWriter, r *http.Request) {
 		ORDER BY c.id
 	`)
 	if err != nil {
-		log.Printf("Failed to query categories: %v", err)
-		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -11069,9 +11964,7 @@ func handleListCategories(w http.ResponseWriter, r *http.Request) {
 		var isPreset int
 		var desc sql.NullString
 		if err := rows.Scan(&cat.ID, &cat.Name, &desc, &isPreset, &cat.PackCount); err != nil {
-			log.Printf("Failed to scan category: %v", err)
-			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
-			return
+			return nil, err
 		}
 		cat.IsPreset = isPreset == 1
 		if desc.Valid {
@@ -11079,8 +11972,22 @@ func handleListCategories(w http.ResponseWriter, r *http.Request) {
 		}
 		categories = append(categories, cat)
 	}
-	if err := rows.Err(); err != nil {
-		log.Printf("[handleListCategories] rows iteration error: %v", err)
+	return categories, rows.Err()
+}
+
+// handleListCategories handles GET /api/categories.
+// Returns all categories with pack_count (number of published pack_listings per category).
+func handleListCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	categories, err := queryCategories()
+	if err != nil {
+		log.Printf("Failed to query categories: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
 	}
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{"categories": categories})
@@ -11468,6 +12375,26 @@ func queryPackDetailPublicData(shareToken string, listingID int64) (*PackDetailP
 	if err != nil {
 		return nil, err
 	}
+	pd.Images, err = queryPackImages(listingID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if sale, ok := loadFlashSales("pack_listings", []int64{listingID})[listingID]; ok && pd.ShareMode != "free" {
+		if sale.ActiveAt(now) {
+			pd.OriginalPrice = pd.CreditsPrice
+			pd.CreditsPrice = int(sale.Price)
+			pd.SaleEndsAt = sale.End.Format(time.RFC3339)
+		}
+		pd.ExpiresAt = sale.NextChange(now)
+	}
+	related, relatedExpiry, err := queryRelatedPacks(listingID, now)
+	if err != nil {
+		// Suggestions are optional; render the page without them.
+		log.Printf("[PACK-DETAIL] failed to query related packs for pack id=%d: %v", listingID, err)
+	}
+	pd.Related = related
+	pd.ExpiresAt = earliestTime(pd.ExpiresAt, relatedExpiry)
 	return &pd, nil
 }
 
@@ -11564,10 +12491,12 @@ func handlePackDetailPage(w http.ResponseWriter, r *http.Request) {
 		}
 		globalCache.SetPackDetail(shareToken, packDetail)
 	}
+	trackPackView(r, listingID)
 
 	// 5.3: Check user login status and purchased state using cache
 	isLoggedIn := false
 	hasPurchased := false
+	var purchasedIDs map[int64]bool
 	cookie, cookieErr := r.Cookie("user_session")
 	if cookieErr == nil && isValidUserSession(cookie.Value) {
 		userID := getUserSessionUserID(cookie.Value)
@@ -11575,14 +12504,14 @@ func handlePackDetailPage(w http.ResponseWriter, r *http.Request) {
 			isLoggedIn = true
 
 			// Try user purchased cache first
-			cachedIDs, userHit := globalCache.GetUserPurchasedIDs(userID)
-			if userHit {
-				hasPurchased = cachedIDs[listingID]
-			} else {
-				purchasedIDs := getUserPurchasedListingIDs(userID)
+			var userHit bool
+			purchasedIDs, userHit = globalCache.GetUserPurchasedIDs(userID)
+			if !userHit {
+				purchasedIDs = getUserPurchasedListingIDs(userID)
 				globalCache.SetUserPurchasedIDs(userID, purchasedIDs)
-				hasPurchased = purchasedIDs[listingID]
 			}
+			hasPurchased = purchasedIDs[listingID]
+			recordRecentlyViewed(userID, listingID)
 		}
 	}
 
@@ -11607,6 +12536,11 @@ func handlePackDetailPage(w http.ResponseWriter, r *http.Request) {
 		"StoreSlug":           packDetail.StoreSlug,
 		"StoreName":           packDetail.StoreName,
 		"StorefrontPublicID":  packDetail.StorefrontPublicID,
+		"Images":              packDetail.Images,
+		"OriginalPrice":       packDetail.OriginalPrice,
+		"SaleEndsAt":          packDetail.SaleEndsAt,
+		"Related":             visibleRelatedPacks(packDetail.Related, purchasedIDs),
+		"Bundles":             bundlesContainingPack(listingID),
 	}); err != nil {
 		log.Printf("[PACK-DETAIL] template execute error: %v", err)
 	}
@@ -11734,6 +12668,8 @@ func handlePurchaseFromDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	creditsPrice = packPriceAt(listingID, creditsPrice, time.Now())
+
 	// Parse JSON body
 	var reqBody struct {
 		Quantity int `json:"quantity"`
@@ -11819,35 +12755,28 @@ func handlePurchaseFromDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// For per_use packs, add the bought uses to the quota
+	if shareMode == "per_use" {
+		if err := addPackUses(tx, userID, listingID, reqBody.Quantity); err != nil {
+			log.Printf("[PURCHASE-FROM-DETAIL] failed to update total_purchased (user=%d, listing=%d): %v", userID, listingID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Printf("[PURCHASE-FROM-DETAIL] failed to commit transaction: %v", err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
+	publishPackSaleEvent(listingID, "purchase", float64(totalCost))
+	grantReferralRewards(userID)
 
 	// Create/update user purchased pack record
 	if err := upsertUserPurchasedPack(userID, listingID); err != nil {
 		log.Printf("[PURCHASE-FROM-DETAIL] failed to upsert purchased pack (user=%d, listing=%d): %v", userID, listingID, err)
 	}
 
-	// For per_use packs, sync pack_usage_records.total_purchased
-	if shareMode == "per_use" {
-		_, err = db.Exec(
-			`INSERT OR IGNORE INTO pack_usage_records (user_id, listing_id, used_count, total_purchased) VALUES (?, ?, 0, 0)`,
-			userID, listingID,
-		)
-		if err != nil {
-			log.Printf("[PURCHASE-FROM-DETAIL] failed to insert pack_usage_records (user=%d, listing=%d): %v", userID, listingID, err)
-		}
-		_, err = db.Exec(
-			`UPDATE pack_usage_records SET total_purchased = total_purchased + ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ? AND listing_id = ?`,
-			reqBody.Quantity, userID, listingID,
-		)
-		if err != nil {
-			log.Printf("[PURCHASE-FROM-DETAIL] failed to update total_purchased (user=%d, listing=%d): %v", userID, listingID, err)
-		}
-	}
-
 	log.Printf("[PURCHASE-FROM-DETAIL] user %d purchased pack %d (%s), mode=%s, cost=%d", userID, listingID, packName, shareMode, totalCost)
 
 	// Invalidate user purchased cache after purchase
@@ -11869,8 +12798,17 @@ func handleUploadPack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse multipart form (max 500MB to match client limit)
-	if err := r.ParseMultipartForm(500 << 20); err != nil {
+	// Reject oversized uploads before reading them; larger form parts are
+	// buffered on disk rather than in memory.
+	maxPackSize := getMaxPackSize()
+	if !limitPackUploadBody(w, r, maxPackSize) {
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		if isRequestTooLarge(err) {
+			writePackTooLarge(w, maxPackSize)
+			return
+		}
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "failed to parse multipart form"})
 		return
 	}
@@ -11939,17 +12877,20 @@ func handleUploadPack(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	fileData, err := io.ReadAll(io.LimitReader(file, 500*1024*1024+1))
+	fileData, err := io.ReadAll(io.LimitReader(file, maxPackSize+1))
 	if err != nil {
 		log.Printf("Failed to read uploaded file: %v", err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
-	if int64(len(fileData)) > 500*1024*1024 {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "file_too_large"})
+	if int64(len(fileData)) > maxPackSize {
+		writePackTooLarge(w, maxPackSize)
 		return
 	}
 
+	// Scan the file as uploaded, before listing_id injection and encryption
+	scanResult := scanPackUpload(fileData)
+
 	// Parse .qap file as ZIP and extract metadata
 	zipReader, err := zip.NewReader(bytes.NewReader(fileData), int64(len(fileData)))
 	if err != nil {
@@ -12055,6 +12996,13 @@ func handleUploadPack(w http.ResponseWriter, r *http.Request) {
 		packName = "Untitled"
 	}
 
+	// Run the content filter over the text shown to buyers
+	contentReject, contentFlag := checkUserContent(packName, qapContent.Metadata.Description)
+	if contentReject != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "content_rejected", "message": contentReject})
+		return
+	}
+
 	// Extract meta info from schema_requirements
 	metaInfo := PackMetaInfo{Tables: []PackMetaTable{}}
 	for _, sr := range qapContent.SchemaRequirements {
@@ -12075,12 +13023,12 @@ func handleUploadPack(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Insert pack_listing record (with original fileData to get listingID first)
+	// Insert pack_listing record (with an empty file placeholder to get listingID first)
 	shareToken := generateShareToken()
 	result, err := db.Exec(
 		`INSERT INTO pack_listings (user_id, category_id, file_data, pack_name, pack_description, source_name, author_name, share_mode, credits_price, status, meta_info, encryption_password, share_token)
 		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending', ?, ?, ?)`,
-		userID, categoryID, fileData, packName, qapContent.Metadata.Description,
+		userID, categoryID, []byte{}, packName, qapContent.Metadata.Description,
 		qapContent.Metadata.SourceName, qapContent.Metadata.Author, shareMode, creditsPrice, metaInfoJSON, encryptionPassword, shareToken,
 	)
 	if err != nil {
@@ -12095,6 +13043,7 @@ func handleUploadPack(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
+	flagUserContent("pack_listing", listingID, contentFlag, packName)
 
 	// Inject listing_id into the .qap file, then encrypt if paid, and UPDATE file_data
 	{
@@ -12147,28 +13096,42 @@ func handleUploadPack(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		// Step 3: UPDATE file_data and encryption_password with the final version
-		_, err = db.Exec(
-			`UPDATE pack_listings SET file_data = ?, encryption_password = ? WHERE id = ?`,
-			fileData, encryptionPassword, listingID,
-		)
+		// Step 3: store the final file and UPDATE the listing to reference it
+		fileRef, err := putPackFile(listingID, fileData)
+		if err == nil {
+			_, err = db.Exec(
+				`UPDATE pack_listings SET file_data = ?, file_sha256 = ?, file_storage = ?, file_key = ?, encryption_password = ? WHERE id = ?`,
+				fileRef.Data, packFileSHA256(fileData), fileRef.Storage, fileRef.Key, encryptionPassword, listingID,
+			)
+			if err != nil {
+				deletePackFile(fileRef)
+			}
+		}
 		if err != nil {
-			log.Printf("Failed to update file_data with listing_id: %v", err)
+			log.Printf("Failed to store pack file for listing %d: %v", listingID, err)
+			db.Exec("DELETE FROM pack_listings WHERE id = ?", listingID)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
 		}
 	}
 
+	// Quarantine the listing if the scan found something
+	applyPackScanResult(listingID, scanResult, getClientIP(r))
+
 	// Read back the created listing
 	var listing PackListingInfo
 	var metaInfoReadBack sql.NullString
 	err = db.QueryRow(
 		`SELECT pl.id, pl.user_id, pl.category_id, c.name, pl.pack_name, pl.pack_description,
-		        pl.source_name, pl.author_name, pl.share_mode, pl.credits_price, pl.download_count, pl.status, pl.meta_info, pl.created_at
+		        pl.source_name, pl.author_name, pl.share_mode, pl.credits_price, pl.download_count, pl.status, pl.meta_info, pl.created_at,
+		        COALESCE(pl.file_sha256, '')
 		 FROM pack_listings pl
 		 JOIN categories c ON c.id = pl.category_id
 		 WHERE pl.id = ?`, listingID,
 	).Scan(&listing.ID, &listing.UserID, &listing.CategoryID, &listing.CategoryName,
 		&listing.PackName, &listing.PackDescription, &listing.SourceName, &listing.AuthorName,
-		&listing.ShareMode, &listing.CreditsPrice, &listing.DownloadCount, &listing.Status, &metaInfoReadBack, &listing.CreatedAt)
+		&listing.ShareMode, &listing.CreditsPrice, &listing.DownloadCount, &listing.Status, &metaInfoReadBack, &listing.CreatedAt,
+		&listing.FileSHA256)
 	if err != nil {
 		log.Printf("Failed to read back listing: %v", err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
@@ -12189,8 +13152,17 @@ func handleReplacePack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse multipart form (max 500MB)
-	if err := r.ParseMultipartForm(500 << 20); err != nil {
+	// Reject oversized uploads before reading them; larger form parts are
+	// buffered on disk rather than in memory.
+	maxPackSize := getMaxPackSize()
+	if !limitPackUploadBody(w, r, maxPackSize) {
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		if isRequestTooLarge(err) {
+			writePackTooLarge(w, maxPackSize)
+			return
+		}
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "failed to parse multipart form"})
 		return
 	}
@@ -12222,10 +13194,11 @@ func handleReplacePack(w http.ResponseWriter, r *http.Request) {
 	var categoryID int64
 	var shareMode string
 	var creditsPrice int
+	var oldFile packFileRef
 	err = db.QueryRow(
-		`SELECT user_id, status, COALESCE(version, 1), category_id, share_mode, credits_price FROM pack_listings WHERE id = ?`,
+		`SELECT user_id, status, COALESCE(version, 1), category_id, share_mode, credits_price, COALESCE(file_storage, ''), COALESCE(file_key, '') FROM pack_listings WHERE id = ?`,
 		listingID,
-	).Scan(&ownerID, &currentStatus, &currentVersion, &categoryID, &shareMode, &creditsPrice)
+	).Scan(&ownerID, &currentStatus, &currentVersion, &categoryID, &shareMode, &creditsPrice, &oldFile.Storage, &oldFile.Key)
 	if err == sql.ErrNoRows {
 		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "listing not found"})
 		return
@@ -12252,17 +13225,20 @@ func handleReplacePack(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	fileData, err := io.ReadAll(io.LimitReader(file, 500*1024*1024+1))
+	fileData, err := io.ReadAll(io.LimitReader(file, maxPackSize+1))
 	if err != nil {
 		log.Printf("[REPLACE-PACK] failed to read uploaded file: %v", err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
-	if int64(len(fileData)) > 500*1024*1024 {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "file_too_large"})
+	if int64(len(fileData)) > maxPackSize {
+		writePackTooLarge(w, maxPackSize)
 		return
 	}
 
+	// Scan the file as uploaded, before listing_id injection and encryption
+	scanResult := scanPackUpload(fileData)
+
 	// Parse .qap file as ZIP and extract metadata
 	zipReader, err := zip.NewReader(bytes.NewReader(fileData), int64(len(fileData)))
 	if err != nil {
@@ -12412,20 +13388,34 @@ func handleReplacePack(w http.ResponseWriter, r *http.Request) {
 
 	newVersion := currentVersion + 1
 
-	// Update the listing: replace file_data, update metadata, bump version, reset to pending
+	fileRef, err := putPackFile(listingID, fileData)
+	if err != nil {
+		log.Printf("[REPLACE-PACK] failed to store file for listing %d: %v", listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+
+	// Update the listing: replace the file, update metadata, bump version, reset to pending
 	_, err = db.Exec(`
 		UPDATE pack_listings
-		SET file_data = ?, pack_name = ?, pack_description = ?, source_name = ?, author_name = ?,
+		SET file_data = ?, file_sha256 = ?, file_storage = ?, file_key = ?, pack_name = ?, pack_description = ?, source_name = ?, author_name = ?,
 		    meta_info = ?, encryption_password = ?, version = ?,
 		    status = 'pending', reviewed_by = NULL, reviewed_at = NULL, reject_reason = NULL
 		WHERE id = ? AND user_id = ?
-	`, fileData, packName, qapContent.Metadata.Description, qapContent.Metadata.SourceName,
+	`, fileRef.Data, packFileSHA256(fileData), fileRef.Storage, fileRef.Key, packName, qapContent.Metadata.Description, qapContent.Metadata.SourceName,
 		qapContent.Metadata.Author, metaInfoJSON, encryptionPassword, newVersion, listingID, userID)
 	if err != nil {
 		log.Printf("[REPLACE-PACK] failed to update listing %d: %v", listingID, err)
+		if fileRef.Key != oldFile.Key {
+			deletePackFile(fileRef)
+		}
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
+	if oldFile.Key != fileRef.Key || oldFile.Storage != fileRef.Storage {
+		deletePackFile(oldFile)
+	}
+	newStatus := applyPackScanResult(listingID, scanResult, getClientIP(r))
 
 	log.Printf("[REPLACE-PACK] user %d replaced listing %d, version %d -> %d", userID, listingID, currentVersion, newVersion)
 
@@ -12440,7 +13430,7 @@ func handleReplacePack(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"listing_id":  listingID,
 		"new_version": newVersion,
-		"status":      "pending",
+		"status":      newStatus,
 	})
 }
 
@@ -12680,12 +13670,14 @@ func handleGetPurchasedPacks(w http.ResponseWriter, r *http.Request) {
 		ShareMode       string `json:"share_mode"`
 		CreditsPrice    int    `json:"credits_price"`
 		CreatedAt       string `json:"created_at"`
+		ExpiresAt       string `json:"expires_at,omitempty"`
+		Expired         bool   `json:"expired"`
 	}
 
 	rows, err := db.Query(`
 		SELECT pl.id, pl.pack_name, COALESCE(pl.pack_description, ''), COALESCE(pl.source_name, ''),
 		       COALESCE(u.display_name, u.email, '') as author_name,
-		       pl.share_mode, pl.credits_price, pl.created_at
+		       pl.share_mode, pl.credits_price, pl.created_at, COALESCE(pl.valid_days, 0)
 		FROM user_purchased_packs upp
 		JOIN pack_listings pl ON upp.listing_id = pl.id
 		LEFT JOIN users u ON pl.user_id = u.id
@@ -12700,56 +13692,73 @@ func handleGetPurchasedPacks(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	packs := []PurchasedPackJSON{}
+	validDays := map[int64]int{}
 	for rows.Next() {
 		var p PurchasedPackJSON
-		if err := rows.Scan(&p.ListingID, &p.PackName, &p.PackDescription, &p.SourceName, &p.AuthorName, &p.ShareMode, &p.CreditsPrice, &p.CreatedAt); err != nil {
+		var days int
+		if err := rows.Scan(&p.ListingID, &p.PackName, &p.PackDescription, &p.SourceName, &p.AuthorName, &p.ShareMode, &p.CreditsPrice, &p.CreatedAt, &days); err != nil {
 			log.Printf("[handleGetPurchasedPacks] scan error: %v", err)
 			continue
 		}
+		validDays[p.ListingID] = days
 		packs = append(packs, p)
 	}
 	if err := rows.Err(); err != nil {
 		log.Printf("[handleGetPurchasedPacks] rows iteration error: %v", err)
 	}
+	rows.Close()
+
+	// Time-limited packs report their expiry so the client can show expired ones
+	for i := range packs {
+		days := validDays[packs[i].ListingID]
+		if !isTimeLimitedPack(packs[i].ShareMode, days) {
+			continue
+		}
+		if expiry, ok := timeLimitedExpiry(userID, packs[i].ListingID, days); ok {
+			packs[i].ExpiresAt = expiry.Format(time.RFC3339)
+			packs[i].Expired = timeLimitedAccessEnded(expiry, time.Now().UTC())
+		}
+	}
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{"packs": packs})
 }
 
-// handleListPacks handles GET /api/packs.
-// Returns a list of published PackListingInfo (without file_data).
-// Supports optional category_id query parameter for filtering.
-func handleListPacks(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
+// packSearchFilter selects published packs for queryPublishedPacks.
+type packSearchFilter struct {
+	CategoryID int64  // 0 means all categories
+	Query      string // matched against pack name and description; "" means all
+	Limit      int    // 0 means no limit
+	Offset     int
+}
 
+// queryPublishedPacks returns the published packs matching f, newest first.
+func queryPublishedPacks(f packSearchFilter) ([]PackListingInfo, error) {
 	query := `
 		SELECT pl.id, pl.user_id, pl.category_id, c.name, pl.pack_name, pl.pack_description,
-		       pl.source_name, pl.author_name, pl.share_mode, pl.credits_price, pl.download_count, pl.meta_info, pl.created_at
+		       pl.source_name, pl.author_name, pl.share_mode, pl.credits_price, pl.download_count, pl.meta_info, pl.created_at,
+		       COALESCE(pl.file_sha256, ''), COALESCE(pl.share_token, '')
 		FROM pack_listings pl
 		JOIN categories c ON c.id = pl.category_id
 		WHERE pl.status = 'published'`
 	var args []interface{}
-
-	categoryIDStr := r.URL.Query().Get("category_id")
-	if categoryIDStr != "" {
-		categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
-		if err != nil {
-			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid category_id"})
-			return
-		}
+	if f.CategoryID != 0 {
 		query += " AND pl.category_id = ?"
-		args = append(args, categoryID)
+		args = append(args, f.CategoryID)
+	}
+	if f.Query != "" {
+		like := "%" + strings.NewReplacer("%", "\\%", "_", "\\_").Replace(f.Query) + "%"
+		query += " AND (pl.pack_name LIKE ? ESCAPE '\\' OR pl.pack_description LIKE ? ESCAPE '\\')"
+		args = append(args, like, like)
+	}
+	query += " ORDER BY pl.created_at DESC, pl.id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
 	}
-
-	query += " ORDER BY pl.created_at DESC"
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
-		log.Printf("Failed to query pack listings: %v", err)
-		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -12759,10 +13768,8 @@ func handleListPacks(w http.ResponseWriter, r *http.Request) {
 		var desc, sourceName, authorName, metaInfoStr sql.NullString
 		if err := rows.Scan(&l.ID, &l.UserID, &l.CategoryID, &l.CategoryName,
 			&l.PackName, &desc, &sourceName, &authorName,
-			&l.ShareMode, &l.CreditsPrice, &l.DownloadCount, &metaInfoStr, &l.CreatedAt); err != nil {
-			log.Printf("Failed to scan pack listing: %v", err)
-			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
-			return
+			&l.ShareMode, &l.CreditsPrice, &l.DownloadCount, &metaInfoStr, &l.CreatedAt, &l.FileSHA256, &l.ShareToken); err != nil {
+			return nil, err
 		}
 		if desc.Valid {
 			l.PackDescription = desc.String
@@ -12780,16 +13787,44 @@ func handleListPacks(w http.ResponseWriter, r *http.Request) {
 		}
 		listings = append(listings, l)
 	}
-	if err := rows.Err(); err != nil {
-		log.Printf("[handleListPacks] rows iteration error: %v", err)
+	return listings, rows.Err()
+}
+
+// handleListPacks handles GET /api/packs.
+// Returns a list of published PackListingInfo (without file_data).
+// Supports optional category_id query parameter for filtering.
+func handleListPacks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var f packSearchFilter
+	categoryIDStr := r.URL.Query().Get("category_id")
+	if categoryIDStr != "" {
+		categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid category_id"})
+			return
+		}
+		f.CategoryID = categoryID
+	}
+
+	listings, err := queryPublishedPacks(f)
+	if err != nil {
+		log.Printf("Failed to query pack listings: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
 	}
 
 	// Optionally resolve user identity from Authorization header
 	userID := optionalUserID(r)
 	if userID > 0 {
 		purchasedSet := getUserPurchasedListingIDs(userID)
+		savedSet := getUserWishlistListingIDs(userID)
 		for i := range listings {
 			listings[i].Purchased = purchasedSet[listings[i].ID]
+			listings[i].Saved = savedSet[listings[i].ID]
 		}
 	}
 
@@ -12814,8 +13849,10 @@ func sanitizeDownloadFilename(name string) string {
 	return replacer.Replace(name)
 }
 
-// servePackFile writes the pack file data as an HTTP response with appropriate headers.
-func servePackFile(w http.ResponseWriter, packName string, fileData []byte, metaInfoStr sql.NullString, encryptionPassword string) {
+// servePackFile streams the pack file as an HTTP response with appropriate headers.
+// fileSHA256 is the hash stored at upload time, sent as X-Content-SHA256 so
+// clients can detect a corrupted or tampered file after download.
+func servePackFile(w http.ResponseWriter, packName string, file io.Reader, size int64, metaInfoStr sql.NullString, encryptionPassword, fileSHA256 string) {
 	metaInfoValue := "{}"
 	if metaInfoStr.Valid && metaInfoStr.String != "" {
 		metaInfoValue = metaInfoStr.String
@@ -12825,10 +13862,17 @@ func servePackFile(w http.ResponseWriter, packName string, fileData []byte, meta
 	}
 	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.qap"`, sanitizeDownloadFilename(packName)))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(fileData)))
+	if size >= 0 {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+	}
 	w.Header().Set("X-Meta-Info", metaInfoValue)
+	if fileSHA256 != "" {
+		w.Header().Set("X-Content-SHA256", fileSHA256)
+	}
 	w.WriteHeader(http.StatusOK)
-	w.Write(fileData)
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Failed to stream pack file %q: %v", packName, err)
+	}
 }
 
 func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
@@ -12857,19 +13901,21 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 	// Look up the pack listing.
 	// For re-downloads by users who already purchased, allow any status (including delisted).
 	// First try published, then check if user has a purchase record for non-published packs.
-	// NOTE: file_data is loaded eagerly here. For very large packs, consider a two-phase
-	// approach: query metadata first, verify auth, then load file_data only when serving.
+	// file_data is empty for packs kept in external storage; those are streamed
+	// from file_storage/file_key instead.
 	var shareMode string
 	var creditsPrice int
-	var fileData []byte
+	var fileRef packFileRef
 	var packName string
 	var metaInfoStr sql.NullString
 	var encryptionPassword string
 	var packStatus string
+	var validDays int
+	var fileSHA256 string
 	err = db.QueryRow(
-		`SELECT share_mode, credits_price, file_data, pack_name, meta_info, encryption_password, status FROM pack_listings WHERE id = ?`,
+		`SELECT share_mode, credits_price, file_data, COALESCE(file_storage, ''), COALESCE(file_key, ''), pack_name, meta_info, encryption_password, status, COALESCE(valid_days, 0), COALESCE(file_sha256, '') FROM pack_listings WHERE id = ?`,
 		packID,
-	).Scan(&shareMode, &creditsPrice, &fileData, &packName, &metaInfoStr, &encryptionPassword, &packStatus)
+	).Scan(&shareMode, &creditsPrice, &fileRef.Data, &fileRef.Storage, &fileRef.Key, &packName, &metaInfoStr, &encryptionPassword, &packStatus, &validDays, &fileSHA256)
 	if err == sql.ErrNoRows {
 		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "pack not found"})
 		return
@@ -12879,6 +13925,26 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Open the file before any credits are charged so a storage outage never
+	// bills a download that cannot be served.
+	packFile, packFileSize, err := openPackFile(fileRef)
+	if err != nil {
+		log.Printf("Failed to open pack file for listing %d: %v", packID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer packFile.Close()
+	// A pack that is to be watermarked is read now, so a failed read is
+	// answered with an error before any use is consumed or credits charged.
+	watermarkData, err := readPackForWatermark(packID, packFile, packFileSize)
+	if err != nil {
+		log.Printf("Failed to read pack file for watermarking: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+
+	creditsPrice = packPriceAt(packID, creditsPrice, time.Now())
+
 	// If pack is not published, only allow re-download for users who already purchased it
 	if packStatus != "published" {
 		var purchaseCount int
@@ -12890,11 +13956,22 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "pack not found"})
 			return
 		}
+		if isTimeLimitedPack(shareMode, validDays) {
+			if expiry, purchased := timeLimitedExpiry(userID, packID, validDays); purchased && timeLimitedAccessEnded(expiry, time.Now().UTC()) {
+				jsonResponse(w, http.StatusForbidden, map[string]interface{}{
+					"error":      "ACCESS_EXPIRED",
+					"message":    "该分析包的使用期限已到期",
+					"expired_at": expiry.Format(time.RFC3339),
+				})
+				return
+			}
+		}
 		// User has purchased this pack before — allow re-download without charging
 		// Record download and return file data directly
 		_, _ = db.Exec("INSERT INTO user_downloads (user_id, listing_id, ip_address) VALUES (?, ?, ?)", userID, packID, getClientIP(r))
 
-		servePackFile(w, packName, fileData, metaInfoStr, encryptionPassword)
+		file, size, sha := watermarkPackDownload(userID, packID, packFile, watermarkData, packFileSize, fileSHA256)
+		servePackFile(w, packName, file, size, metaInfoStr, encryptionPassword, sha)
 		return
 	}
 
@@ -12913,103 +13990,96 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 		// For subscription packs, check if user already has an active (non-expired) subscription.
 		// If so, allow re-download without charging credits.
 		if shareMode == "subscription" {
-			hasActiveSubscription := false
-
-			// Get the original purchase date from credits_transactions (include 'renew' as it may be the first record)
-			var purchaseDateStr sql.NullString
-			err = db.QueryRow(`
-				SELECT MIN(created_at) FROM credits_transactions
-				WHERE user_id = ? AND listing_id = ? AND transaction_type IN ('purchase', 'download', 'purchase_uses', 'renew_subscription', 'renew')
-			`, userID, packID).Scan(&purchaseDateStr)
-
-			// Fallback: if no credits_transactions record, try user_purchased_packs.created_at
-			if (err != nil || !purchaseDateStr.Valid || purchaseDateStr.String == "") {
-				var uppDate sql.NullString
-				_ = db.QueryRow(`SELECT created_at FROM user_purchased_packs WHERE user_id = ? AND listing_id = ?`, userID, packID).Scan(&uppDate)
-				if uppDate.Valid && uppDate.String != "" {
-					purchaseDateStr = uppDate
-					err = nil
-				}
+			// Credits renewals, or a PayPal auto-renewal within its grace period,
+			// keep the subscription active.
+			hasActiveSubscription := packSubscriptionActive(userID, packID, time.Now().UTC())
+			if hasActiveSubscription {
+				log.Printf("[DOWNLOAD] User %d has an active subscription for pack %d, allowing free re-download", userID, packID)
 			}
 
-			if err == nil && purchaseDateStr.Valid && purchaseDateStr.String != "" {
-				// Parse purchase date
-				var baseTime time.Time
-				if t, err := time.Parse("2006-01-02 15:04:05", purchaseDateStr.String); err == nil {
-					baseTime = t
-				} else if t, err := time.Parse("2006-01-02T15:04:05Z", purchaseDateStr.String); err == nil {
-					baseTime = t
+			if hasActiveSubscription {
+				// Active subscription: allow download without charging, just increment download count
+				_, _ = db.Exec("UPDATE pack_listings SET download_count = download_count + 1 WHERE id = ?", packID)
+				_, _ = db.Exec("INSERT INTO user_downloads (user_id, listing_id, ip_address) VALUES (?, ?, ?)", userID, packID, getClientIP(r))
+				if err := upsertUserPurchasedPack(userID, packID); err != nil {
+					log.Printf("Failed to upsert user purchased pack: %v", err)
 				}
 
-				if !baseTime.IsZero() {
-					// Get valid_days from pack listing (default 30 for subscription)
-					var validDays int
-					db.QueryRow("SELECT COALESCE(valid_days, 0) FROM pack_listings WHERE id = ?", packID).Scan(&validDays)
-					if validDays == 0 {
-						validDays = 30
-					}
-					currentExpiry := baseTime.AddDate(0, 0, validDays)
-
-					// Apply all renew transactions to calculate cumulative expiry
-					renewRows, err := db.Query(`
-						SELECT created_at, COALESCE(description, '')
-						FROM credits_transactions
-						WHERE user_id = ? AND listing_id = ? AND transaction_type = 'renew'
-						ORDER BY created_at ASC
-					`, userID, packID)
-					if err == nil {
-						for renewRows.Next() {
-							var renewDateStr, desc string
-							if err := renewRows.Scan(&renewDateStr, &desc); err != nil {
-								continue
-							}
-							renewMonths := 1
-							if strings.Contains(desc, "yearly") || strings.Contains(desc, "14 month") {
-								renewMonths = 14
-							} else if strings.Contains(desc, "12 month") {
-								renewMonths = 12
-							}
-							var renewTime time.Time
-							if t, err := time.Parse("2006-01-02 15:04:05", renewDateStr); err == nil {
-								renewTime = t
-							} else if t, err := time.Parse("2006-01-02T15:04:05Z", renewDateStr); err == nil {
-								renewTime = t
-							}
-							if renewTime.IsZero() {
-								continue
-							}
-							if currentExpiry.After(renewTime) {
-								currentExpiry = currentExpiry.AddDate(0, renewMonths, 0)
-							} else {
-								currentExpiry = renewTime.AddDate(0, renewMonths, 0)
-							}
-						}
-						renewRows.Close()
-						if err := renewRows.Err(); err != nil {
-							log.Printf("[DOWNLOAD] renewRows iteration error: %v", err)
-						}
-					}
+				file, size, sha := watermarkPackDownload(userID, packID, packFile, watermarkData, packFileSize, fileSHA256)
+				servePackFile(w, packName, file, size, metaInfoStr, encryptionPassword, sha)
+				return
+			}
+		}
 
-					// Check if subscription is still active
-					if time.Now().UTC().Before(currentExpiry) {
-						hasActiveSubscription = true
-						log.Printf("[DOWNLOAD] User %d has active subscription for pack %d (expires: %s), allowing free re-download",
-							userID, packID, currentExpiry.Format("2006-01-02 15:04:05"))
-					}
+		// Each per_use download spends one use. used_at identifies the use so a
+		// retried download (or the client's later usage report) is not counted twice.
+		usageKey := time.Now().UTC().Format(time.RFC3339)
+		if v := r.URL.Query().Get("used_at"); v != "" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "used_at must be valid RFC3339 format"})
+				return
+			}
+			usageKey = v
+		}
+		var usage packUsage
+		if shareMode == "per_use" {
+			tx, err := db.Begin()
+			if err != nil {
+				log.Printf("Failed to begin transaction: %v", err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+			defer tx.Rollback()
+
+			_, hasQuota, err := loadPackUsage(tx, userID, packID)
+			if err != nil {
+				log.Printf("Failed to query pack_usage_records: %v", err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+			if hasQuota {
+				// Already bought: spend a use from the quota instead of charging again
+				usage, err = consumePackUse(tx, userID, packID, usageKey)
+				if errors.Is(err, errUsageQuotaExhausted) {
+					jsonResponse(w, http.StatusPaymentRequired, map[string]interface{}{
+						"error":           "USAGE_QUOTA_EXHAUSTED",
+						"message":         "使用次数已用完，请购买更多次数后再下载",
+						"used_count":      usage.Used,
+						"total_purchased": usage.Total,
+						"remaining_uses":  0,
+						"top_up_url":      fmt.Sprintf("/api/packs/%d/purchase-uses", packID),
+					})
+					return
+				}
+				if err != nil {
+					log.Printf("Failed to consume pack use (user=%d, listing=%d): %v", userID, packID, err)
+					jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+					return
+				}
+				if _, err := tx.Exec("UPDATE pack_listings SET download_count = download_count + 1 WHERE id = ?", packID); err != nil {
+					log.Printf("Failed to increment download count: %v", err)
+					jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+					return
 				}
-			}
-
-			if hasActiveSubscription {
-				// Active subscription: allow download without charging, just increment download count
-				_, _ = db.Exec("UPDATE pack_listings SET download_count = download_count + 1 WHERE id = ?", packID)
-				_, _ = db.Exec("INSERT INTO user_downloads (user_id, listing_id, ip_address) VALUES (?, ?, ?)", userID, packID, getClientIP(r))
-				if err := upsertUserPurchasedPack(userID, packID); err != nil {
-					log.Printf("Failed to upsert user purchased pack: %v", err)
+				if err := tx.Commit(); err != nil {
+					log.Printf("Failed to commit transaction: %v", err)
+					jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+					return
 				}
-
-				servePackFile(w, packName, fileData, metaInfoStr, encryptionPassword)
-				return
+				licenseJSON, _ := json.Marshal(map[string]interface{}{
+					"listing_id":          packID,
+					"pack_name":           packName,
+					"pricing_model":       shareMode,
+					"remaining_uses":      usage.Remaining(),
+					"total_uses":          usage.Total,
+					"used_at":             usageKey,
+					"expires_at":          "",
+					"subscription_months": 0,
+				})
+				w.Header().Set("X-Usage-License", string(licenseJSON))
+				break
 			}
+			tx.Rollback()
 		}
 
 		// Check user's credits balance (email wallet)
@@ -13062,6 +14132,20 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// First per_use download buys one use and spends it
+		if shareMode == "per_use" {
+			if err := addPackUses(tx, userID, packID, 1); err != nil {
+				log.Printf("Failed to add pack uses (user=%d, listing=%d): %v", userID, packID, err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+			if usage, err = consumePackUse(tx, userID, packID, usageKey); err != nil {
+				log.Printf("Failed to consume pack use (user=%d, listing=%d): %v", userID, packID, err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+		}
+
 		// Increment download count
 		_, err = tx.Exec("UPDATE pack_listings SET download_count = download_count + 1 WHERE id = ?", packID)
 		if err != nil {
@@ -13075,6 +14159,8 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 			return
 		}
+		publishPackSaleEvent(packID, "download", float64(creditsPrice))
+		grantReferralRewards(userID)
 
 		// Build X-Usage-License header based on pricing model
 		usageLicense := map[string]interface{}{
@@ -13090,8 +14176,9 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 		now := time.Now().UTC()
 		switch shareMode {
 		case "per_use":
-			usageLicense["remaining_uses"] = 1
-			usageLicense["total_uses"] = 1
+			usageLicense["remaining_uses"] = usage.Remaining()
+			usageLicense["total_uses"] = usage.Total
+			usageLicense["used_at"] = usageKey
 		case "subscription":
 			expiresAt := now.AddDate(0, 1, 0)
 			usageLicense["expires_at"] = expiresAt.Format(time.RFC3339)
@@ -13107,6 +14194,26 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Usage-License", string(licenseJSON))
 
 	default:
+		// Time-limited packs: re-download for free until expiry plus the grace
+		// period, then refuse until the buyer renews.
+		if isTimeLimitedPack(shareMode, validDays) {
+			if expiry, purchased := timeLimitedExpiry(userID, packID, validDays); purchased {
+				if timeLimitedAccessEnded(expiry, time.Now().UTC()) {
+					jsonResponse(w, http.StatusForbidden, map[string]interface{}{
+						"error":      "ACCESS_EXPIRED",
+						"message":    "该分析包的使用期限已到期，请续期后再下载",
+						"expired_at": expiry.Format(time.RFC3339),
+						"renew_url":  "/user/",
+					})
+					return
+				}
+				_, _ = db.Exec("INSERT INTO user_downloads (user_id, listing_id, ip_address) VALUES (?, ?, ?)", userID, packID, getClientIP(r))
+				file, size, sha := watermarkPackDownload(userID, packID, packFile, watermarkData, packFileSize, fileSHA256)
+				servePackFile(w, packName, file, size, metaInfoStr, encryptionPassword, sha)
+				return
+			}
+		}
+
 		// Legacy "paid" mode or unknown: treat as paid with basic deduction
 		balance := getWalletBalance(userID)
 
@@ -13165,6 +14272,8 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 			return
 		}
+		publishPackSaleEvent(packID, "download", float64(creditsPrice))
+		grantReferralRewards(userID)
 	}
 
 	// Record download in user_downloads table with buyer IP (non-critical, ignore errors)
@@ -13182,18 +14291,8 @@ func handleDownloadPack(w http.ResponseWriter, r *http.Request) {
 	globalCache.InvalidateUserPurchased(userID)
 
 	// Return file data as binary response with meta_info header
-	servePackFile(w, packName, fileData, metaInfoStr, encryptionPassword)
-
-	// For per_use packs, initialize pack_usage_records on first download (non-critical)
-	if shareMode == "per_use" {
-		_, err := db.Exec(
-			`INSERT OR IGNORE INTO pack_usage_records (user_id, listing_id, used_count, total_purchased) VALUES (?, ?, 0, 1)`,
-			userID, packID,
-		)
-		if err != nil {
-			log.Printf("Failed to initialize pack_usage_records for per_use pack (user=%d, listing=%d): %v", userID, packID, err)
-		}
-	}
+	file, size, sha := watermarkPackDownload(userID, packID, packFile, watermarkData, packFileSize, fileSHA256)
+	servePackFile(w, packName, file, size, metaInfoStr, encryptionPassword, sha)
 }
 
 // handlePurchaseAdditionalUses handles POST /api/packs/{id}/purchase-uses
@@ -13250,6 +14349,8 @@ func handlePurchaseAdditionalUses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	creditsPrice = packPriceAt(packID, creditsPrice, time.Now())
+
 	// Verify share_mode is per_use
 	if shareMode != "per_use" {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "pack is not per_use type"})
@@ -13307,39 +14408,39 @@ func handlePurchaseAdditionalUses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Top up the quota in the same transaction as the charge
+	if err := addPackUses(tx, userID, packID, req.Quantity); err != nil {
+		log.Printf("[PURCHASE-USES] failed to update total_purchased (user=%d, listing=%d): %v", userID, packID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	usage, _, err := loadPackUsage(tx, userID, packID)
+	if err != nil {
+		log.Printf("Failed to query pack_usage_records: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Printf("Failed to commit transaction: %v", err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
+	publishPackSaleEvent(packID, "purchase_uses", float64(totalCost))
+	grantReferralRewards(userID)
 
 	// Record/restore user purchased pack (non-critical, used for display)
 	if err := upsertUserPurchasedPack(userID, packID); err != nil {
 		log.Printf("Failed to upsert user purchased pack (user=%d, listing=%d): %v", userID, packID, err)
 	}
 
-	// Sync pack_usage_records.total_purchased after successful purchase
-	_, err = db.Exec(
-		`INSERT OR IGNORE INTO pack_usage_records (user_id, listing_id, used_count, total_purchased) VALUES (?, ?, 0, 0)`,
-		userID, packID,
-	)
-	if err != nil {
-		log.Printf("[PURCHASE-USES] failed to insert pack_usage_records (user=%d, listing=%d): %v", userID, packID, err)
-	}
-	_, err = db.Exec(
-		`UPDATE pack_usage_records SET total_purchased = total_purchased + ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ? AND listing_id = ?`,
-		req.Quantity, userID, packID,
-	)
-	if err != nil {
-		log.Printf("[PURCHASE-USES] failed to update total_purchased (user=%d, listing=%d): %v", userID, packID, err)
-	}
-
 	// Invalidate user purchased cache after purchasing additional uses
 	globalCache.InvalidateUserPurchased(userID)
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"success":         true,
-		"remaining_uses":  req.Quantity,
+		"success":          true,
+		"remaining_uses":   usage.Remaining(),
+		"total_purchased":  usage.Total,
 		"credits_deducted": totalCost,
 	})
 }
@@ -13402,6 +14503,8 @@ func handleRenewSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	creditsPrice = packPriceAt(packID, creditsPrice, time.Now())
+
 	// Verify share_mode is subscription
 	if shareMode != "subscription" {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "pack is not subscription type"})
@@ -13526,6 +14629,7 @@ func handleRenewSubscription(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
+	publishPackSaleEvent(packID, "renew", float64(totalCost))
 
 	// Record/restore user purchased pack (non-critical, used for display)
 	if err := upsertUserPurchasedPack(userID, packID); err != nil {
@@ -13789,13 +14893,7 @@ func handleCreateAdmin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate permissions
-	validPerms := map[string]bool{"categories": true, "marketplace": true, "authors": true, "review": true, "settings": true, "customers": true}
-	var filteredPerms []string
-	for _, p := range req.Permissions {
-		if validPerms[p] {
-			filteredPerms = append(filteredPerms, p)
-		}
-	}
+	filteredPerms := filterKnownPermissions(req.Permissions)
 	permsStr := strings.Join(filteredPerms, ",")
 
 	// Check username uniqueness
@@ -13896,6 +14994,9 @@ func handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 			return
 		}
+		// End other sessions and re-issue this one under a fresh ID.
+		sid := rotateSession(getSessionFromRequest(r), adminID, true)
+		http.SetCookie(w, makeSessionCookie("admin_session", sid, 86400))
 	}
 
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
@@ -13906,7 +15007,8 @@ func handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 func handlePendingList(w http.ResponseWriter, r *http.Request) {
 	rows, err := db.Query(`
 		SELECT pl.id, pl.user_id, pl.category_id, c.name, pl.pack_name, pl.pack_description,
-		       pl.source_name, pl.author_name, pl.share_mode, pl.credits_price, pl.download_count, pl.status, pl.meta_info, pl.created_at
+		       pl.source_name, pl.author_name, pl.share_mode, pl.credits_price, pl.download_count, pl.status, pl.meta_info, pl.created_at,
+		       COALESCE(pl.publish_at, '')
 		FROM pack_listings pl
 		JOIN categories c ON c.id = pl.category_id
 		WHERE pl.status = 'pending'
@@ -13922,11 +15024,12 @@ func handlePendingList(w http.ResponseWriter, r *http.Request) {
 		var p PackListingInfo
 		var categoryName, desc, sourceName, authorName, metaInfoStr sql.NullString
 		err := rows.Scan(&p.ID, &p.UserID, &p.CategoryID, &categoryName, &p.PackName, &desc,
-			&sourceName, &authorName, &p.ShareMode, &p.CreditsPrice, &p.DownloadCount, &p.Status, &metaInfoStr, &p.CreatedAt)
+			&sourceName, &authorName, &p.ShareMode, &p.CreditsPrice, &p.DownloadCount, &p.Status, &metaInfoStr, &p.CreatedAt, &p.PublishAt)
 		if err != nil {
 			log.Printf("Failed to scan pending listing: %v", err)
 			continue
 		}
+		p.PublishAt = publishAtRFC3339(p.PublishAt)
 		if categoryName.Valid {
 			p.CategoryName = categoryName.String
 		}
@@ -13977,22 +15080,24 @@ func handleApproveReview(w http.ResponseWriter, r *http.Request, listingID int64
 		return
 	}
 
-	_, err = db.Exec("UPDATE pack_listings SET status='published', reviewed_by=?, reviewed_at=CURRENT_TIMESTAMP WHERE id=? AND status='pending'",
-		adminID, listingID)
+	// Listings with a future publish_at become 'scheduled' and are published
+	// later by startScheduledPublisher.
+	_, err = db.Exec("UPDATE pack_listings SET status="+approvedListingStatusSQL+", reviewed_by=?, reviewed_at=CURRENT_TIMESTAMP WHERE id=? AND status='pending'",
+		time.Now().UTC().Format("2006-01-02 15:04:05"), adminID, listingID)
 	if err != nil {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
 		return
 	}
 
-	// Invalidate caches after approving a pack listing
-	globalCache.InvalidateStorefrontsByListingID(listingID)
-	globalCache.InvalidateHomepage()
-	var shareToken string
-	if err := db.QueryRow("SELECT share_token FROM pack_listings WHERE id = ?", listingID).Scan(&shareToken); err == nil && shareToken != "" {
-		globalCache.InvalidatePackDetail(shareToken)
+	var newStatus string
+	db.QueryRow("SELECT status FROM pack_listings WHERE id = ?", listingID).Scan(&newStatus)
+	if newStatus == "published" {
+		// Invalidate caches after approving a pack listing
+		invalidateListingCaches(listingID)
+		go notifyStorefrontFollowers(listingID)
 	}
 
-	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok", "listing_status": newStatus})
 }
 
 // handleRejectReview rejects a pending pack listing with a reason.
@@ -14045,7 +15150,15 @@ func handleReviewRoutes(w http.ResponseWriter, r *http.Request) {
 		handlePendingList(w, r)
 		return
 	}
-	// Parse: {id}/approve or {id}/reject
+	if path == "quarantine" && r.Method == http.MethodGet {
+		handleQuarantineList(w, r)
+		return
+	}
+	if (path == "bulk-approve" || path == "bulk-reject") && r.Method == http.MethodPost {
+		handleBulkReview(w, r, path == "bulk-approve")
+		return
+	}
+	// Parse: {id}/approve, {id}/reject or {id}/release
 	parts := strings.Split(path, "/")
 	if len(parts) == 2 {
 		id, err := strconv.ParseInt(parts[0], 10, 64)
@@ -14064,6 +15177,11 @@ func handleReviewRoutes(w http.ResponseWriter, r *http.Request) {
 				handleRejectReview(w, r, id)
 				return
 			}
+		case "release":
+			if r.Method == http.MethodPost {
+				handleReleaseQuarantine(w, r, id)
+				return
+			}
 		}
 	}
 	jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not_found"})
@@ -14130,6 +15248,10 @@ func handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
 	if feeRateBankCardCN == "" {
 		feeRateBankCardCN = "0"
 	}
+	feeCaps := make(map[string]string, len(withdrawalPaymentTypes))
+	for _, pt := range withdrawalPaymentTypes {
+		feeCaps[pt] = formatWithdrawalAmount(getWithdrawalFeeCap(pt))
+	}
 
 	// Get admin info from session
 	adminID := getSessionAdminID(r)
@@ -14150,6 +15272,8 @@ func handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
 		"FeeRateBankCardUS":   feeRateBankCardUS,
 		"FeeRateBankCardEU":   feeRateBankCardEU,
 		"FeeRateBankCardCN":          feeRateBankCardCN,
+		"FeeCaps":                    feeCaps,
+		"MinWithdrawal":              formatWithdrawalAmount(getMinWithdrawal()),
 		"RevenueSplitPublisherPct":   revenueSplitPublisherPct,
 		"RevenueSplitPlatformPct":    revenueSplitPlatformPct,
 		"AdminID":                    adminID,
@@ -14515,7 +15639,91 @@ func handleSaveServicePortalURL(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
 		return
 	}
+	// The portal is allowed as a frame-src in the CSP.
+	refreshCSPHeader()
+
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Bounds for the admin-configurable homepage cache TTL.
+const (
+	minHomepageCacheTTL = 10 * time.Second
+	maxHomepageCacheTTL = time.Hour
+)
+
+// getHomepageCacheTTL reads the homepage_cache_ttl setting (seconds).
+// Returns 0 when unset or invalid so the cache default applies.
+func getHomepageCacheTTL() time.Duration {
+	secs, err := strconv.Atoi(getSetting("homepage_cache_ttl"))
+	if err != nil {
+		return 0
+	}
+	ttl := time.Duration(secs) * time.Second
+	if ttl < minHomepageCacheTTL || ttl > maxHomepageCacheTTL {
+		return 0
+	}
+	return ttl
+}
+
+// handleAdminHomepageCache handles GET/POST /admin/settings/homepage-cache.
+// GET returns the TTL, last refresh time and warm-up flag; POST saves form
+// values "ttl_seconds" and, when present, "warmup_enabled".
+func handleAdminHomepageCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		ttl, refreshedAt, cached := globalCache.HomepageStatus()
+		lastRefreshed := ""
+		if !refreshedAt.IsZero() {
+			lastRefreshed = refreshedAt.Format(time.RFC3339)
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"ttl_seconds":    int(ttl.Seconds()),
+			"last_refreshed": lastRefreshed,
+			"cached":         cached,
+			"warmup_enabled": isCacheWarmupEnabled(),
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	secs, err := strconv.Atoi(strings.TrimSpace(r.FormValue("ttl_seconds")))
+	ttl := time.Duration(secs) * time.Second
+	if err != nil || ttl < minHomepageCacheTTL || ttl > maxHomepageCacheTTL {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("缓存时间必须在 %d 到 %d 秒之间", int(minHomepageCacheTTL.Seconds()), int(maxHomepageCacheTTL.Seconds()))})
+		return
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('homepage_cache_ttl', ?)", strconv.Itoa(secs)); err != nil {
+		log.Printf("[ADMIN] failed to save homepage_cache_ttl: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	// A form without the field leaves warm-up as it was.
+	if _, ok := r.Form["warmup_enabled"]; ok {
+		warmup := "false"
+		if r.FormValue("warmup_enabled") == "true" {
+			warmup = "true"
+		}
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('cache_warmup_enabled', ?)", warmup); err != nil {
+			log.Printf("[ADMIN] failed to save cache_warmup_enabled: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	globalCache.SetHomepageTTL(ttl)
+	log.Printf("[ADMIN] homepage cache TTL set to %v (warm-up on startup: %t)", ttl, isCacheWarmupEnabled())
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
 
+// handleAdminHomepageCacheRefresh drops the cached homepage so the next visit reloads it.
+// POST /admin/settings/homepage-cache/refresh
+func handleAdminHomepageCacheRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	globalCache.InvalidateHomepage()
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
@@ -14645,18 +15853,7 @@ func handleAdminTestSMTPConfig(w http.ResponseWriter, r *http.Request) {
 	msg.WriteString("If you received this email, the SMTP configuration is working correctly.\r\n")
 	msg.WriteString(fmt.Sprintf("\r\nSent at: %s\r\n", time.Now().Format(time.RFC3339)))
 
-	var sendErr error
-	if config.UseTLS {
-		sendErr = storefrontSendEmailTLS(config, req.TestEmail, msg.Bytes())
-	} else {
-		addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
-		var auth smtp.Auth
-		if config.Username != "" && config.Password != "" {
-			auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
-		}
-		sendErr = smtp.SendMail(addr, auth, config.FromEmail, []string{req.TestEmail}, msg.Bytes())
-	}
-
+	sendErr := sendSMTPMail(config, req.TestEmail, msg.Bytes())
 	if sendErr != nil {
 		log.Printf("[SMTP-TEST] failed to send test email to %s: %v", req.TestEmail, sendErr)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("发送失败: %v", sendErr)})
@@ -14750,14 +15947,16 @@ func handleAdminSaveWithdrawalFees(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		PaypalFeeRate       float64 `json:"paypal_fee_rate"`
-		WechatFeeRate       float64 `json:"wechat_fee_rate"`
-		AlipayFeeRate       float64 `json:"alipay_fee_rate"`
-		CheckFeeRate        float64 `json:"check_fee_rate"`
-		WireTransferFeeRate float64 `json:"wire_transfer_fee_rate"`
-		BankCardUSFeeRate   float64 `json:"bank_card_us_fee_rate"`
-		BankCardEUFeeRate   float64 `json:"bank_card_eu_fee_rate"`
-		BankCardCNFeeRate   float64 `json:"bank_card_cn_fee_rate"`
+		PaypalFeeRate       float64            `json:"paypal_fee_rate"`
+		WechatFeeRate       float64            `json:"wechat_fee_rate"`
+		AlipayFeeRate       float64            `json:"alipay_fee_rate"`
+		CheckFeeRate        float64            `json:"check_fee_rate"`
+		WireTransferFeeRate float64            `json:"wire_transfer_fee_rate"`
+		BankCardUSFeeRate   float64            `json:"bank_card_us_fee_rate"`
+		BankCardEUFeeRate   float64            `json:"bank_card_eu_fee_rate"`
+		BankCardCNFeeRate   float64            `json:"bank_card_cn_fee_rate"`
+		MinWithdrawal       *float64           `json:"min_withdrawal"`
+		FeeCaps             map[string]float64 `json:"fee_caps"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
@@ -14775,6 +15974,17 @@ func handleAdminSaveWithdrawalFees(w http.ResponseWriter, r *http.Request) {
 		"fee_rate_bank_card_cn":  req.BankCardCNFeeRate,
 	}
 
+	if req.MinWithdrawal != nil {
+		feeRates["min_withdrawal"] = *req.MinWithdrawal
+	}
+	for pt, feeCap := range req.FeeCaps {
+		if !validPaymentTypes[pt] {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid payment type: " + pt})
+			return
+		}
+		feeRates["fee_cap_"+pt] = feeCap
+	}
+
 	for key, rate := range feeRates {
 		if rate < 0 {
 			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": key + " must be non-negative"})
@@ -14879,7 +16089,7 @@ func handleAdminApproveWithdrawals(w http.ResponseWriter, r *http.Request) {
 		args[i] = id
 	}
 
-	query := fmt.Sprintf("UPDATE withdrawal_records SET status = 'paid' WHERE id IN (%s) AND status = 'pending'",
+	query := fmt.Sprintf("UPDATE withdrawal_records SET status = 'paid', paid_at = CURRENT_TIMESTAMP WHERE id IN (%s) AND status = 'pending'",
 		strings.Join(placeholders, ","))
 
 	result, err := db.Exec(query, args...)
@@ -14972,20 +16182,10 @@ func handleAdminExportWithdrawals(w http.ResponseWriter, r *http.Request) {
 		f.SetCellValue(sheetName, cell, h)
 	}
 
-	// Payment type labels
-	typeLabels := map[string]string{
-		"paypal": "PayPal", "wechat": i18n.T(lang, "excel_wechat"), "alipay": "AliPay", "check": i18n.T(lang, "excel_check"),
-		"bank_card": i18n.T(lang, "excel_bank_card"), "wire_transfer": i18n.T(lang, "excel_wire_transfer"),
-		"bank_card_us": i18n.T(lang, "excel_bank_card_us"), "bank_card_eu": i18n.T(lang, "excel_bank_card_eu"), "bank_card_cn": i18n.T(lang, "excel_bank_card_cn"),
-	}
-
 	// Write data rows
 	for rowIdx, wr := range withdrawals {
 		row := rowIdx + 2
-		typeLabel := typeLabels[wr.PaymentType]
-		if typeLabel == "" {
-			typeLabel = wr.PaymentType
-		}
+		typeLabel := withdrawalPaymentTypeLabel(lang, wr.PaymentType)
 		feeRatePercent := fmt.Sprintf("%.2f%%", wr.FeeRate*100)
 
 		cells := []interface{}{wr.DisplayName, typeLabel, wr.PaymentDetails, wr.CashAmount, feeRatePercent, wr.FeeAmount, wr.NetAmount}
@@ -15337,12 +16537,16 @@ func handleDecorationBillingExport(w http.ResponseWriter, r *http.Request) {
 
 
 // cash_amount = credits_amount × cash_rate
-// fee_amount = cash_amount × fee_rate / 100
+// fee_amount = cash_amount × fee_rate / 100, at most fee_cap when fee_cap > 0
 // net_amount = cash_amount - fee_amount
-func calculateWithdrawalFee(creditsAmount, cashRate, feeRate float64) (cashAmount, feeAmount, netAmount float64) {
-	cashAmount = creditsAmount * cashRate
-	feeAmount = cashAmount * feeRate / 100
-	netAmount = cashAmount - feeAmount
+// Cash and fee are rounded to cents so the minimum check matches what is paid.
+func calculateWithdrawalFee(creditsAmount, cashRate, feeRate, feeCap float64) (cashAmount, feeAmount, netAmount float64) {
+	cashAmount = math.Round(creditsAmount*cashRate*100) / 100
+	feeAmount = math.Round(cashAmount*feeRate) / 100
+	if feeCap > 0 && feeAmount > feeCap {
+		feeAmount = feeCap
+	}
+	netAmount = math.Round((cashAmount-feeAmount)*100) / 100
 	return
 }
 
@@ -15386,6 +16590,15 @@ func handleAuthorWithdraw(w http.ResponseWriter, r *http.Request) {
 
 	lang := i18n.DetectLang(r)
 
+	// Email ownership must be verified before any payout
+	var withdrawEmail string
+	db.QueryRow("SELECT COALESCE(email, '') FROM users WHERE id = ?", userID).Scan(&withdrawEmail)
+	if !isEmailVerified(withdrawEmail) {
+		log.Printf("[AUTHOR-WITHDRAW] user %d: rejected - email not verified", userID)
+		withdrawError("email_unverified", i18n.T(lang, "email_verify_required"))
+		return
+	}
+
 	// Payment info pre-check: user must have payment info set before withdrawing
 	var paymentType, paymentDetailsStr string
 	err = db.QueryRow("SELECT payment_type, payment_details FROM user_payment_info WHERE user_id = ?", userID).Scan(&paymentType, &paymentDetailsStr)
@@ -15412,7 +16625,7 @@ func handleAuthorWithdraw(w http.ResponseWriter, r *http.Request) {
 	// Verify user is an author (has at least one pack listing)
 	var authorPackCount int
 	err = db.QueryRow("SELECT COUNT(*) FROM pack_listings WHERE user_id = ?", userID).Scan(&authorPackCount)
-	if err != nil || authorPackCount == 0 {
+	if err != nil || (authorPackCount == 0 && storefrontTransferCarryover(userID) <= 0) {
 		log.Printf("[AUTHOR-WITHDRAW] user %d: rejected - not author (count=%d, err=%v)", userID, authorPackCount, err)
 		withdrawError("not_author", i18n.T(lang, "withdraw_not_author"))
 		return
@@ -15427,6 +16640,13 @@ func handleAuthorWithdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Large withdrawals require an approved KYC verification
+	if kycRequiredForWithdrawal(userID, creditsAmount) {
+		log.Printf("[AUTHOR-WITHDRAW] user %d: rejected - KYC required for %.2f credits", userID, creditsAmount)
+		withdrawError("kyc_required", i18n.T(lang, "withdraw_kyc_required"))
+		return
+	}
+
 	// Query credit_cash_rate from settings
 	cashRateStr := getSetting("credit_cash_rate")
 	cashRate, _ := strconv.ParseFloat(cashRateStr, 64)
@@ -15436,12 +16656,9 @@ func handleAuthorWithdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Read fee rate for the user's payment type from settings (default to 0 if not found)
-	feeRateStr := getSetting("fee_rate_" + paymentType)
-	feeRate, _ := strconv.ParseFloat(feeRateStr, 64)
-	if feeRate < 0 {
-		feeRate = 0
-	}
+	// Read fee rate and fee cap for the user's payment type from settings (default to 0 if not found)
+	feeRate := getWithdrawalFeeRate(paymentType)
+	feeCap := getWithdrawalFeeCap(paymentType)
 
 	// Calculate unwithdrawn credits: total revenue minus total withdrawn (with revenue split)
 	// Must match the dashboard query exactly: purchase, download, purchase_uses, renew with amount < 0
@@ -15458,6 +16675,7 @@ func handleAuthorWithdraw(w http.ResponseWriter, r *http.Request) {
 		withdrawError("internal", i18n.T(lang, "system_error"))
 		return
 	}
+	totalRevenue += storefrontTransferCarryover(userID)
 
 	// Apply revenue split: publisher only gets their configured share
 	splitPctStr := getSetting("revenue_split_publisher_pct")
@@ -15494,16 +16712,23 @@ func handleAuthorWithdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The credits are paid out of the wallet, so it must cover the amount too
+	if walletBalance := getWalletBalance(userID); creditsAmount > walletBalance {
+		log.Printf("[AUTHOR-WITHDRAW] user %d: rejected - amount %.2f exceeds wallet balance %.2f", userID, creditsAmount, walletBalance)
+		withdrawError("withdraw_exceeds_wallet", i18n.T(lang, "withdraw_exceeds_wallet"))
+		return
+	}
+
 	// Calculate cash_amount, fee_amount, net_amount using calculateWithdrawalFee
-	cashAmount, feeAmount, netAmount := calculateWithdrawalFee(creditsAmount, cashRate, feeRate)
+	cashAmount, feeAmount, netAmount := calculateWithdrawalFee(creditsAmount, cashRate, feeRate, feeCap)
 
-	log.Printf("[AUTHOR-WITHDRAW] user %d: cashRate=%.4f, feeRate=%.2f, cashAmount=%.2f, feeAmount=%.2f, netAmount=%.2f",
-		userID, cashRate, feeRate, cashAmount, feeAmount, netAmount)
+	log.Printf("[AUTHOR-WITHDRAW] user %d: cashRate=%.4f, feeRate=%.2f, feeCap=%.2f, cashAmount=%.2f, feeAmount=%.2f, netAmount=%.2f",
+		userID, cashRate, feeRate, feeCap, cashAmount, feeAmount, netAmount)
 
-	// Minimum withdrawal: net_amount must be at least 100 元
-	if netAmount < 100 {
-		log.Printf("[AUTHOR-WITHDRAW] user %d: rejected - netAmount %.2f < 100", userID, netAmount)
-		withdrawError("withdraw_below_minimum", i18n.T(lang, "withdraw_below_min_net"))
+	// Minimum withdrawal: net_amount must be at least min_withdrawal
+	if minWithdrawal := getMinWithdrawal(); netAmount < minWithdrawal {
+		log.Printf("[AUTHOR-WITHDRAW] user %d: rejected - netAmount %.2f < %.2f", userID, netAmount, minWithdrawal)
+		withdrawError("withdraw_below_minimum", fmt.Sprintf(i18n.T(lang, "withdraw_below_min_net"), formatWithdrawalAmount(minWithdrawal)))
 		return
 	}
 
@@ -15530,12 +16755,17 @@ func handleAuthorWithdraw(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Deduct from email wallet
-	_, err = deductWalletBalance(tx, userID, creditsAmount)
+	deducted, err := deductWalletBalance(tx, userID, creditsAmount)
 	if err != nil {
 		log.Printf("[AUTHOR-WITHDRAW] failed to update credits_balance: %v", err)
 		withdrawError("internal", i18n.T(lang, "system_error"))
 		return
 	}
+	if deducted == 0 {
+		log.Printf("[AUTHOR-WITHDRAW] user %d: rejected - wallet balance changed below %.2f", userID, creditsAmount)
+		withdrawError("withdraw_exceeds_wallet", i18n.T(lang, "withdraw_exceeds_wallet"))
+		return
+	}
 
 	if err := tx.Commit(); err != nil {
 		log.Printf("[AUTHOR-WITHDRAW] failed to commit transaction: %v", err)
@@ -15676,7 +16906,8 @@ func handleAuthorEditPack(w http.ResponseWriter, r *http.Request) {
 
 	// Verify listing belongs to current user
 	var ownerID int64
-	err = db.QueryRow("SELECT user_id FROM pack_listings WHERE id = ?", listingID).Scan(&ownerID)
+	var oldCreditsPrice int
+	err = db.QueryRow("SELECT user_id, credits_price FROM pack_listings WHERE id = ?", listingID).Scan(&ownerID, &oldCreditsPrice)
 	if err != nil {
 		log.Printf("[AUTHOR-EDIT-PACK] listing %d not found: %v", listingID, err)
 		http.Error(w, "Forbidden", http.StatusForbidden)
@@ -15722,6 +16953,7 @@ func handleAuthorEditPack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[AUTHOR-EDIT-PACK] user %d updated listing %d: name=%s mode=%s price=%d", userID, listingID, packName, shareMode, creditsPrice)
+	logPriceChange("pack", listingID, userID, float64(oldCreditsPrice), float64(creditsPrice))
 
 	// Cascade: clear featured status since pack is now pending (non-published) (Requirement 10.9)
 	_, err = db.Exec(`UPDATE storefront_packs SET is_featured = 0, featured_sort_order = 0 WHERE pack_listing_id = ? AND is_featured = 1`, listingID)
@@ -15772,7 +17004,9 @@ func handleAuthorDeletePack(w http.ResponseWriter, r *http.Request) {
 	// Verify listing belongs to current user and is rejected
 	var ownerID int64
 	var status string
-	err = db.QueryRow("SELECT user_id, status FROM pack_listings WHERE id = ?", listingID).Scan(&ownerID, &status)
+	var packFile packFileRef
+	err = db.QueryRow("SELECT user_id, status, COALESCE(file_storage, ''), COALESCE(file_key, '') FROM pack_listings WHERE id = ?", listingID).
+		Scan(&ownerID, &status, &packFile.Storage, &packFile.Key)
 	if err != nil {
 		log.Printf("[AUTHOR-DELETE-PACK] listing %d not found: %v", listingID, err)
 		http.Redirect(w, r, "/user/?error=not_found", http.StatusFound)
@@ -15797,6 +17031,10 @@ func handleAuthorDeletePack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	removeListingFromWishlists(listingID)
+	deletePackImages(listingID)
+	deletePackFile(packFile)
+
 	log.Printf("[AUTHOR-DELETE-PACK] user %d deleted rejected listing %d", userID, listingID)
 
 	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
@@ -16907,6 +18145,11 @@ func handleAdminMarketplaceRoutes(w http.ResponseWriter, r *http.Request) {
 		handleAdminRelistPack(w, r)
 		return
 	}
+	// /api/admin/marketplace/{id}/rotate-password
+	if strings.HasSuffix(path, "/rotate-password") {
+		handleAdminRotatePackPassword(w, r)
+		return
+	}
 	jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not_found"})
 }
 
@@ -17214,9 +18457,21 @@ func handleAdminCustomerToggleBlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get current blocked status
-	var isBlocked int
-	err = db.QueryRow("SELECT COALESCE(is_blocked, 0) FROM users WHERE id = ?", userID).Scan(&isBlocked)
+	// Optional reason and duration for a block
+	var req blockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	until, err := req.validate(time.Now())
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Get current blocked status (an expired temporary block counts as unblocked)
+	var exists int
+	err = db.QueryRow("SELECT 1 FROM users WHERE id = ?", userID).Scan(&exists)
 	if err == sql.ErrNoRows {
 		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "user_not_found"})
 		return
@@ -17225,23 +18480,18 @@ func handleAdminCustomerToggleBlock(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
 		return
 	}
+	newBlocked := !userBlockStatus(userID, time.Now()).Blocked
 
-	newBlocked := 0
-	if isBlocked == 0 {
-		newBlocked = 1
-	}
-
-	_, err = db.Exec("UPDATE users SET is_blocked = ? WHERE id = ?", newBlocked, userID)
-	if err != nil {
+	if err := setUsersBlocked(r, fmt.Sprintf("user:%d", userID), newBlocked, req.Reason, until, "id = ?", userID); err != nil {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
 		return
 	}
 
 	status := "unblocked"
-	if newBlocked == 1 {
+	if newBlocked {
 		status = "blocked"
 	}
-	jsonResponse(w, http.StatusOK, map[string]string{"status": status})
+	jsonResponse(w, http.StatusOK, map[string]string{"status": status, "blocked_until": until})
 }
 
 // handleAdminCustomerTransactions returns credits transaction history for a customer.
@@ -17503,13 +18753,20 @@ func handleAdminEmailToggleBlock(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		Email string `json:"email"`
+		blockRequest
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
 		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "email required"})
 		return
 	}
+	until, err := req.validate(time.Now())
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 
-	// Check if all accounts are currently blocked
+	// Check if all accounts are currently blocked (lifting expired temporary blocks first)
+	expireUserBlocks(time.Now())
 	var totalCount, blockedCount int
 	db.QueryRow("SELECT COUNT(*), COALESCE(SUM(CASE WHEN is_blocked=1 THEN 1 ELSE 0 END),0) FROM users WHERE email=?", req.Email).Scan(&totalCount, &blockedCount)
 	if totalCount == 0 {
@@ -17518,22 +18775,18 @@ func handleAdminEmailToggleBlock(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If all blocked → unblock all; otherwise → block all
-	newBlocked := 1
-	if blockedCount == totalCount {
-		newBlocked = 0
-	}
+	newBlocked := blockedCount != totalCount
 
-	_, err := db.Exec("UPDATE users SET is_blocked = ? WHERE email = ?", newBlocked, req.Email)
-	if err != nil {
+	if err := setUsersBlocked(r, "email:"+req.Email, newBlocked, req.Reason, until, "email = ?", req.Email); err != nil {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
 		return
 	}
 
 	status := "blocked"
-	if newBlocked == 0 {
+	if !newBlocked {
 		status = "unblocked"
 	}
-	jsonResponse(w, http.StatusOK, map[string]string{"status": status})
+	jsonResponse(w, http.StatusOK, map[string]string{"status": status, "blocked_until": until})
 }
 
 func handleAdminCustomerRoutes(w http.ResponseWriter, r *http.Request) {
@@ -17570,22 +18823,6 @@ func handleAdminCustomerRoutes(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not_found"})
 }
 
-// getClientIP extracts the client IP address from the request.
-func getClientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
-	}
-	if xri := r.Header.Get("X-Real-Ip"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-	host := r.RemoteAddr
-	if idx := strings.LastIndex(host, ":"); idx != -1 {
-		return host[:idx]
-	}
-	return host
-}
-
 // handleAdminSalesRoutes dispatches sales management API requests.
 func handleAdminSalesRoutes(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/admin/sales")
@@ -17596,6 +18833,10 @@ func handleAdminSalesRoutes(w http.ResponseWriter, r *http.Request) {
 		handleAdminSalesAuthors(w, r)
 	case path == "/export":
 		handleAdminSalesExport(w, r)
+	case path == "/regions":
+		handleAdminSalesRegions(w, r)
+	case path == "/checkout-funnel":
+		handleAdminCheckoutFunnel(w, r)
 	default:
 		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not_found"})
 	}
@@ -18023,6 +19264,9 @@ func handleSetLang(w http.ResponseWriter, r *http.Request) {
 		HttpOnly: false,
 		SameSite: http.SameSiteLaxMode,
 	})
+	if uid := getUserSessionUserID(getUserSessionFromRequest(r)); uid > 0 {
+		rememberUserLang(uid, i18n.ParseLang(lang))
+	}
 	// Use redirect query param first, then Referer header (validated to be same-origin)
 	redirect := r.URL.Query().Get("redirect")
 	if redirect == "" {
@@ -18044,39 +19288,190 @@ func handleSetLang(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, redirect, http.StatusFound)
 }
 
-// securityHeaders adds standard security headers to all responses.
-func securityHeaders(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+// Content-Security-Policy rollout modes, stored in settings key "csp_mode".
+const (
+	cspModeEnforce    = "enforce"     // send Content-Security-Policy (default)
+	cspModeReportOnly = "report-only" // send Content-Security-Policy-Report-Only
+	cspModeOff        = "off"         // send no CSP header
+)
 
-		// Build CSP: allow frame-src for the configured service portal URL
-		csp := "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; img-src 'self' data:"
-		spURL := getSetting("service_portal_url")
-		if spURL == "" {
-			spURL = servicePortalURL
+// buildContentSecurityPolicy returns the CSP sent with HTML pages.
+//
+//   - default-src 'self': everything not listed below must come from this origin.
+//   - script-src / style-src allow 'unsafe-inline' because every page embeds
+//     its scripts and styles, including the theme variables from GetThemeCSS
+//     and per-store custom CSS; fonts may come from Google Fonts.
+//   - img-src adds data: for inline SVG/placeholder images; logos and
+//     thumbnails are served from our own /store/ and /api/ endpoints.
+//   - frame-src allows the configured customer-service portal, which the
+//     support widget loads in an iframe.
+//   - frame-ancestors 'none' forbids framing us (clickjacking), matching
+//     X-Frame-Options: DENY for older browsers.
+//   - report-uri is appended when the csp_report_uri setting is present,
+//     so violations can be collected during a report-only rollout.
+func buildContentSecurityPolicy() string {
+	csp := "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; img-src 'self' data:"
+	spURL := getSetting("service_portal_url")
+	if spURL == "" {
+		spURL = servicePortalURL
+	}
+	if spURL != "" {
+		// Extract origin (scheme + host) from the full URL
+		if parsed, err := url.Parse(spURL); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+			csp += "; frame-src 'self' " + parsed.Scheme + "://" + parsed.Host
 		}
-		if spURL != "" {
-			// Extract origin (scheme + host) from the full URL
-			if parsed, err := url.Parse(spURL); err == nil && parsed.Scheme != "" && parsed.Host != "" {
-				csp += "; frame-src 'self' " + parsed.Scheme + "://" + parsed.Host
+	}
+	csp += "; frame-ancestors 'none'; base-uri 'self'; form-action 'self' https:"
+	if reportURI := getSetting("csp_report_uri"); reportURI != "" {
+		csp += "; report-uri " + reportURI
+	}
+	return csp
+}
+
+// cspHeaderCache holds the CSP header sent with HTML pages. It is built from
+// the csp_mode, csp_report_uri and service_portal_url settings on first use
+// and again whenever one of them is saved, so serving a page reads no
+// settings from the database.
+type cspHeaderCache struct {
+	mu     sync.RWMutex
+	loaded bool
+	name   string // "" when csp_mode is off
+	value  string
+}
+
+var cspHeader = &cspHeaderCache{}
+
+// get returns the header name and value, loading them on first use.
+func (c *cspHeaderCache) get() (name, value string) {
+	c.mu.RLock()
+	loaded, name, value := c.loaded, c.name, c.value
+	c.mu.RUnlock()
+	if !loaded {
+		return refreshCSPHeader()
+	}
+	return name, value
+}
+
+// refreshCSPHeader rebuilds the cached CSP header from settings.
+func refreshCSPHeader() (name, value string) {
+	switch getSetting("csp_mode") {
+	case cspModeOff:
+	case cspModeReportOnly:
+		name, value = "Content-Security-Policy-Report-Only", buildContentSecurityPolicy()
+	default:
+		name, value = "Content-Security-Policy", buildContentSecurityPolicy()
+	}
+	cspHeader.mu.Lock()
+	cspHeader.loaded, cspHeader.name, cspHeader.value = true, name, value
+	cspHeader.mu.Unlock()
+	return name, value
+}
+
+// securityHeadersWriter defers the HTML-only headers until the handler has
+// chosen its Content-Type, so images, JSON and downloads are left alone.
+type securityHeadersWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (sw *securityHeadersWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.wroteHeader = true
+		h := sw.Header()
+		if strings.HasPrefix(h.Get("Content-Type"), "text/html") {
+			h.Set("X-Frame-Options", "DENY")
+			if name, value := cspHeader.get(); name != "" {
+				h.Set(name, value)
 			}
 		}
-		csp += "; frame-ancestors 'none'"
-		w.Header().Set("Content-Security-Policy", csp)
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *securityHeadersWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		// With nosniff the browser trusts Content-Type only, so sniff here
+		// the way net/http would have done for handlers that don't set one.
+		if sw.Header().Get("Content-Type") == "" {
+			sw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
 
+// Flush keeps streaming responses working through the wrapper.
+func (sw *securityHeadersWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// securityHeaders adds standard security headers to all responses, plus
+// Content-Security-Policy and X-Frame-Options on HTML pages. The CSP can be
+// switched to report-only or off via the csp_mode setting for rollout.
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-XSS-Protection", "1; mode=block")
+		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
 		w.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
 		w.Header().Set("Permissions-Policy", "camera=(), microphone=(), geolocation=()")
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(&securityHeadersWriter{ResponseWriter: w}, r)
 	})
 }
 
+// handleAdminCSPSettings handles GET/POST /admin/settings/csp.
+// GET returns the current mode and report URI; POST saves form values "mode" and "report_uri".
+func handleAdminCSPSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		mode := getSetting("csp_mode")
+		if mode == "" {
+			mode = cspModeEnforce
+		}
+		jsonResponse(w, http.StatusOK, map[string]string{
+			"mode":       mode,
+			"report_uri": getSetting("csp_report_uri"),
+			"policy":     buildContentSecurityPolicy(),
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mode := strings.TrimSpace(r.FormValue("mode"))
+	if mode != cspModeEnforce && mode != cspModeReportOnly && mode != cspModeOff {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的 CSP 模式"})
+		return
+	}
+	reportURI := strings.TrimSpace(r.FormValue("report_uri"))
+	if reportURI != "" && !strings.HasPrefix(reportURI, "https://") && !strings.HasPrefix(reportURI, "/") {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "报告地址必须以 https:// 或 / 开头"})
+		return
+	}
+	if strings.ContainsAny(reportURI, "; \t\r\n") {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "报告地址格式无效"})
+		return
+	}
+
+	for key, value := range map[string]string{"csp_mode": mode, "csp_report_uri": reportURI} {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	refreshCSPHeader()
+	log.Printf("[ADMIN] CSP mode set to %q (report_uri=%q)", mode, reportURI)
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
 
 func main() {
 	port := flag.Int("port", 8088, "Server port")
 	dbPath := flag.String("db", "marketplace.db", "SQLite database path")
+	geoIPPath := flag.String("geoip", "", "IP-to-country range CSV (start_ip,end_ip,country or network,country)")
 	flag.Parse()
 
 	// Compute logo hash for cache busting (short hex prefix of SHA-256)
@@ -18092,6 +19487,13 @@ func main() {
 	}
 	defer db.Close()
 
+	// Resolve buyer countries for the sales region reports
+	if *geoIPPath != "" {
+		if err := loadGeoIPDatabase(*geoIPPath); err != nil {
+			log.Printf("[GEOIP] failed to load %s, buyer regions will be unknown: %v", *geoIPPath, err)
+		}
+	}
+
 	// Load default language setting
 	if dl := getSetting("default_language"); dl == "en-US" {
 		i18n.DefaultLang = i18n.EnUS
@@ -18101,14 +19503,40 @@ func main() {
 
 	// Initialize global cache
 	cacheConfig := DefaultCacheConfig()
+	if ttl := getHomepageCacheTTL(); ttl > 0 {
+		cacheConfig.HomepageTTL = ttl
+	}
 	globalCache = NewCache(cacheConfig)
 	globalCache.startCleanupTicker(context.Background())
-	log.Printf("[CACHE] initialized: MaxEntries=%d, StorefrontTTL=%v, PackDetailTTL=%v, ShareTokenTTL=%v, UserPurchasedTTL=%v, HomepageTTL=%v",
-		cacheConfig.MaxEntries, cacheConfig.StorefrontTTL, cacheConfig.PackDetailTTL, cacheConfig.ShareTokenTTL, cacheConfig.UserPurchasedTTL, cacheConfig.HomepageTTL)
+	log.Printf("[CACHE] initialized: MaxEntries=%d, StorefrontTTL=%v, PackDetailTTL=%v, ShareTokenTTL=%v, UserPurchasedTTL=%v, HomepageTTL=%v, FeedTTL=%v",
+		cacheConfig.MaxEntries, cacheConfig.StorefrontTTL, cacheConfig.PackDetailTTL, cacheConfig.ShareTokenTTL, cacheConfig.UserPurchasedTTL, cacheConfig.HomepageTTL, cacheConfig.FeedTTL)
 
 	// Backfill public_id for existing storefronts
 	backfillStorefrontPublicIDs(db)
 
+	// Retry paid but unfulfilled virtual-goods orders in the background
+	startFulfillmentWorker()
+	startViewTracker()
+	startScheduledPublisher()
+	startExchangeRateRefresher()
+	startPackSubscriptionSweeper()
+	startStaleOrderSweeper()
+	startCreditsExpirySweeper()
+	startNotificationRecurrenceWorker()
+	startDownloadTokenSweeper()
+
+	// Move pack files still stored as BLOBs to the configured external storage
+	if loadPackStorageConfig().Backend != packStorageDB {
+		go migratePackFilesToStorage()
+	}
+
+	// Warm the homepage and featured storefront caches without delaying startup
+	if isCacheWarmupEnabled() {
+		go warmCaches()
+	} else {
+		log.Printf("[CACHE-WARMUP] disabled by setting")
+	}
+
 	// Start background goroutine to clean up expired sessions and captchas
 	go func() {
 		ticker := time.NewTicker(10 * time.Minute)
@@ -18130,13 +19558,20 @@ func main() {
 			}
 			captchasMu.Unlock()
 			// Clean up expired user sessions
+			var endedImpersonations []userSessionEntry
 			userSessionsMu.Lock()
 			for id, entry := range userSessions {
 				if now.After(entry.Expiry) {
 					delete(userSessions, id)
+					if entry.ImpersonatorID != 0 {
+						endedImpersonations = append(endedImpersonations, entry)
+					}
 				}
 			}
 			userSessionsMu.Unlock()
+			for _, entry := range endedImpersonations {
+				logImpersonationEnd(entry.ImpersonatorID, entry.UserID, "expired", "")
+			}
 			// Clean up expired math captcha expressions
 			mathCaptchaExpressionsMu.Lock()
 			for id := range mathCaptchaExpressions {
@@ -18156,6 +19591,15 @@ func main() {
 				}
 			}
 			loginTicketsMu.Unlock()
+			// Clean up expired OAuth login states
+			cleanupOAuthStates(now)
+			// Forget stale admin login failure counters
+			cleanupAdminLoginAttempts(now)
+			// Lift temporary user blocks that have run out
+			expireUserBlocks(now)
+			// Purge old password reset tokens
+			cleanupPasswordResetTokens(now)
+			cleanupEmailVerificationTokens(now)
 		}
 	}()
 
@@ -18186,18 +19630,19 @@ func main() {
 	http.HandleFunc("/api/auth/sn-login", handleSNLogin)
 	http.HandleFunc("/api/auth/oauth", handleOAuthCallback) // kept for backward compatibility
 
-	// Category routes (listing is public, admin requires auth)
+	// Category routes (listing is public, admin management is in adminRoutes)
 	http.HandleFunc("/api/categories", handleListCategories)
-	http.HandleFunc("/api/admin/categories", permissionAuth("categories")(handleAdminCategories))
-	http.HandleFunc("/api/admin/categories/", permissionAuth("categories")(handleAdminCategories))
 
 	// Pack routes (upload and download require auth, listing is public)
-	http.HandleFunc("/api/packs/upload", authMiddleware(handleUploadPack))
-	http.HandleFunc("/api/packs/replace", authMiddleware(handleReplacePack))
+	http.HandleFunc("/api/packs/upload", apiKeyAuth(APIScopePublish)(handleUploadPack))
+	http.HandleFunc("/api/packs/upload-limits", handlePackUploadLimits)
+	http.HandleFunc("/api/packs/replace", apiKeyAuth(APIScopePublish)(handleReplacePack))
 	http.HandleFunc("/api/packs/report-usage", authMiddleware(handleReportPackUsage))
-	http.HandleFunc("/api/packs/listing-id", authMiddleware(handleGetListingID))
-	http.HandleFunc("/api/packs/purchased", authMiddleware(handleGetPurchasedPacks))
-	http.HandleFunc("/api/packs/my-licenses", authMiddleware(handleGetMyLicenses))
+	http.HandleFunc("/api/packs/listing-id", apiKeyAuth(APIScopeRead)(handleGetListingID))
+	http.HandleFunc("/api/packs/purchased", apiKeyAuth(APIScopeRead)(handleGetPurchasedPacks))
+	http.HandleFunc("/api/packs/my-licenses", apiKeyAuth(APIScopeRead)(handleGetMyLicenses))
+	http.HandleFunc("/api/wishlist", authMiddleware(handleWishlist))
+	http.HandleFunc("/api/wishlist/count", authMiddleware(handleWishlistCount))
 	http.HandleFunc("/api/packs", handleListPacks)
 	http.HandleFunc("/api/packs/", func(w http.ResponseWriter, r *http.Request) {
 		// Dispatch based on URL suffix
@@ -18209,15 +19654,22 @@ func main() {
 			authMiddleware(handlePurchaseAdditionalUses)(w, r)
 		case strings.HasSuffix(r.URL.Path, "/renew"):
 			authMiddleware(handleRenewSubscription)(w, r)
+		case strings.HasSuffix(r.URL.Path, "/rotate-password"):
+			apiKeyAuth(APIScopePublish)(handleRotatePackPassword)(w, r)
+		case strings.HasSuffix(r.URL.Path, "/download-token"):
+			apiKeyAuth(APIScopeDownload)(handleIssueDownloadToken)(w, r)
+		case strings.HasSuffix(r.URL.Path, "/download") && r.URL.Query().Get("token") != "":
+			// Single-use download links carry their own authorization
+			handleTokenDownload(w, r)
 		default:
-			authMiddleware(handleDownloadPack)(w, r)
+			apiKeyAuth(APIScopeDownload)(handleDownloadPack)(w, r)
 		}
 	})
 
 	// Credits routes (all require auth)
-	http.HandleFunc("/api/credits/balance", authMiddleware(handleGetBalance))
+	http.HandleFunc("/api/credits/balance", apiKeyAuth(APIScopeRead)(handleGetBalance))
 	http.HandleFunc("/api/credits/purchase", authMiddleware(handlePurchaseCredits))
-	http.HandleFunc("/api/credits/transactions", authMiddleware(handleListTransactions))
+	http.HandleFunc("/api/credits/transactions", apiKeyAuth(APIScopeRead)(handleListTransactions))
 
 	// Admin auth routes (public)
 	http.HandleFunc("/admin/setup", handleAdminSetup)
@@ -18226,114 +19678,45 @@ func main() {
 	http.HandleFunc("/admin/captcha", handleCaptchaImage)
 	http.HandleFunc("/admin/captcha/refresh", handleCaptchaRefresh)
 
-	// Admin management API routes (super admin id=1 only)
-	http.HandleFunc("/api/admin/admins", superAdminOnlyAuth(handleAdminManagement))
-	http.HandleFunc("/api/admin/profile", adminAuth(handleUpdateProfile))
-
-	// Marketplace management API routes (permission-based)
-	http.HandleFunc("/api/admin/marketplace", permissionAuth("marketplace")(handleAdminMarketplaceRoutes))
-	http.HandleFunc("/api/admin/marketplace/", permissionAuth("marketplace")(handleAdminMarketplaceRoutes))
-
-	// Unified account management API routes (permission-based, replaces separate author/customer)
-	http.HandleFunc("/api/admin/accounts", permissionAuth("accounts")(handleAdminAccountRoutes))
-	http.HandleFunc("/api/admin/accounts/", permissionAuth("accounts")(handleAdminAccountRoutes))
-
-	// Author management API routes (permission-based, kept for backward compatibility)
-	http.HandleFunc("/api/admin/authors", permissionAuth("authors")(handleAdminAuthorRoutes))
-	http.HandleFunc("/api/admin/authors/", permissionAuth("authors")(handleAdminAuthorRoutes))
-
-	// Customer management API routes (permission-based, kept for backward compatibility)
-	http.HandleFunc("/api/admin/customers", permissionAuth("customers")(handleAdminCustomerRoutes))
-	http.HandleFunc("/api/admin/customers/", permissionAuth("customers")(handleAdminCustomerRoutes))
+	// Authenticated admin routes (see adminRoutes)
+	registerAdminRoutes(http.DefaultServeMux)
 
 	// User notification query API (public, optional JWT auth)
 	http.HandleFunc("/api/notifications", handleListNotifications)
-
-	// Notification management API routes (permission-based)
-	http.HandleFunc("/api/admin/notifications", permissionAuth("notifications")(handleAdminNotificationRoutes))
-	http.HandleFunc("/api/admin/notifications/", permissionAuth("notifications")(handleAdminNotificationRoutes))
-
-	// Review API routes (permission-based)
-	http.HandleFunc("/api/admin/review/", permissionAuth("review")(handleReviewRoutes))
-
-	// Sales management API routes (permission-based)
-	http.HandleFunc("/api/admin/sales", permissionAuth("sales")(handleAdminSalesRoutes))
-	http.HandleFunc("/api/admin/sales/", permissionAuth("sales")(handleAdminSalesRoutes))
-
-	// Featured storefronts management API routes (permission-based)
-	http.HandleFunc("/api/admin/featured-storefronts", permissionAuth("settings")(handleAdminFeaturedStorefronts))
-	http.HandleFunc("/api/admin/featured-storefronts/", permissionAuth("settings")(handleAdminFeaturedStorefronts))
-
-	// Admin routes (protected by session auth)
-	http.HandleFunc("/admin/settings/initial-credits", permissionAuth("settings")(handleSetInitialCredits))
-	http.HandleFunc("/admin/settings/credit-cash-rate", permissionAuth("settings")(handleSetCreditCashRate))
-	http.HandleFunc("/admin/settings/paypal", permissionAuth("settings")(handleAdminPayPalSettings))
-	http.HandleFunc("/admin/api/settings/revenue-split", permissionAuth("settings")(handleAdminSaveRevenueSplit))
-	http.HandleFunc("/admin/api/settings/withdrawal-fees", permissionAuth("settings")(handleAdminSaveWithdrawalFees))
-	http.HandleFunc("/admin/api/settings/default-language", permissionAuth("settings")(handleSetDefaultLanguage))
-	http.HandleFunc("/admin/api/settings/download-urls", permissionAuth("settings")(handleSaveDownloadURLs))
-	http.HandleFunc("/admin/api/settings/smtp", permissionAuth("settings")(handleAdminSaveSMTPConfig))
-	http.HandleFunc("/admin/api/settings/smtp-test", permissionAuth("settings")(handleAdminTestSMTPConfig))
-	http.HandleFunc("/admin/settings/service-portal-url", permissionAuth("settings")(handleSaveServicePortalURL))
-	http.HandleFunc("/admin/settings/support-parent-product-id", permissionAuth("settings")(handleSaveSupportParentProductID))
-	http.HandleFunc("/admin/api/settings/decoration-fee", permissionAuth("billing")(handleSetDecorationFee))
-	http.HandleFunc("/admin/api/settings/decoration-fee-max", permissionAuth("billing")(handleSetDecorationFeeMax))
-	http.HandleFunc("/admin/api/withdrawals/export", permissionAuth("settings")(handleAdminExportWithdrawals))
-	http.HandleFunc("/admin/api/withdrawals/approve", permissionAuth("settings")(handleAdminApproveWithdrawals))
-	http.HandleFunc("/admin/api/withdrawals", permissionAuth("settings")(handleAdminGetWithdrawals))
-
-	// Billing management API routes (permission-based)
-	http.HandleFunc("/admin/api/billing", permissionAuth("billing")(handleAdminBillingList))
-	http.HandleFunc("/admin/api/billing/export", permissionAuth("billing")(handleAdminBillingExport))
-
-	// Decoration billing details API routes (permission-based)
-	http.HandleFunc("/admin/api/billing/decoration/export", permissionAuth("billing")(handleDecorationBillingExport))
-	http.HandleFunc("/admin/api/billing/decoration", permissionAuth("billing")(handleDecorationBillingList))
-
-	// Storefront support management API routes (permission-based)
-	http.HandleFunc("/admin/api/storefront-support/get-threshold", permissionAuth("storefront_support")(handleGetSupportThreshold))
-	http.HandleFunc("/admin/api/storefront-support/set-threshold", permissionAuth("storefront_support")(handleSetSupportThreshold))
-	http.HandleFunc("/admin/api/storefront-support/list", permissionAuth("storefront_support")(handleAdminStorefrontSupportList))
-	http.HandleFunc("/admin/api/storefront-support/approve", permissionAuth("storefront_support")(handleAdminStorefrontSupportApprove))
-	http.HandleFunc("/admin/api/storefront-support/disable", permissionAuth("storefront_support")(handleAdminStorefrontSupportDisable))
-	http.HandleFunc("/admin/api/storefront-support/re-approve", permissionAuth("storefront_support")(handleAdminStorefrontSupportReApprove))
-	http.HandleFunc("/admin/api/storefront-support/delete", permissionAuth("storefront_support")(handleAdminStorefrontSupportDelete))
+	http.HandleFunc("/api/notifications/read", authMiddleware(handleMarkNotificationsRead))
+	http.HandleFunc("/api/notifications/unread-count", authMiddleware(handleNotificationUnreadCount))
 
 	// Storefront support external query API routes (public)
 	http.HandleFunc("/api/storefront-support/status", handleStorefrontSupportStatus)
 	http.HandleFunc("/api/storefront-support/check", handleStorefrontSupportCheck)
 	http.HandleFunc("/api/storefront-support/customer-login", handleCustomerSupportLogin)
 
-	// Custom products admin routes (permission-based)
-	http.HandleFunc("/api/admin/pending-custom-products", permissionAuth("review")(handleAdminPendingCustomProducts))
-	http.HandleFunc("/admin/storefront/", permissionAuth("settings")(handleAdminCustomProductsToggle))
-	http.HandleFunc("/admin/custom-product/", permissionAuth("review")(func(w http.ResponseWriter, r *http.Request) {
-		switch {
-		case strings.HasSuffix(r.URL.Path, "/approve"):
-			handleAdminCustomProductApprove(w, r)
-		case strings.HasSuffix(r.URL.Path, "/reject"):
-			handleAdminCustomProductReject(w, r)
-		default:
-			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "not_found"})
-		}
-	}))
-
-	http.HandleFunc("/admin/", adminAuth(handleAdminDashboard))
-
 	// User portal routes
 	http.HandleFunc("/user/login", handleUserLogin)
+	http.HandleFunc("/user/oauth/", handleUserOAuth)
+	http.HandleFunc("/user/forgot-password", handleUserForgotPassword)
+	http.HandleFunc("/user/reset-password", handleUserResetPassword)
+	http.HandleFunc("/user/email/verify", handleUserEmailVerify)
+	http.HandleFunc("/user/email/verify/send", userAuth(handleUserEmailVerifySend))
+	http.HandleFunc("/user/email/unsubscribe", handleStorefrontUnsubscribe)
 	http.HandleFunc("/user/register", handleUserRegister)
 	http.HandleFunc("/user/logout", handleUserLogout)
 	http.HandleFunc("/user/ticket-login", handleTicketLogin)
-	http.HandleFunc("/user/change-password", userAuth(handleUserChangePassword))
-	http.HandleFunc("/user/set-password", userAuth(handleUserSetPassword))
+	http.HandleFunc("/user/change-password", userAuth(denyWhileImpersonating(handleUserChangePassword)))
+	http.HandleFunc("/user/change-email", userAuth(denyWhileImpersonating(handleUserChangeEmail)))
+	http.HandleFunc("/user/change-email/verify", handleUserChangeEmailVerify)
+	http.HandleFunc("/user/set-password", userAuth(denyWhileImpersonating(handleUserSetPassword)))
 	http.HandleFunc("/user/captcha", handleUserCaptchaImage)
 	http.HandleFunc("/user/captcha/refresh", handleUserCaptchaRefresh)
 	http.HandleFunc("/user/billing", userAuth(handleUserBilling))
 	http.HandleFunc("/user/pack/renew-uses", userAuth(handleUserRenewPerUse))
 	http.HandleFunc("/user/pack/renew-subscription", userAuth(handleUserRenewSubscription))
+	http.HandleFunc("/user/pack/renew-access", userAuth(handleUserRenewAccess))
+	http.HandleFunc("/user/pack/subscribe", userAuth(denyWhileImpersonating(handleUserPackSubscribe)))
+	http.HandleFunc("/user/pack/subscribe/return", userAuth(handleUserPackSubscribeReturn))
+	http.HandleFunc("/user/pack/subscribe/cancel", userAuth(denyWhileImpersonating(handleUserPackSubscribeCancel)))
 	http.HandleFunc("/user/pack/delete", userAuth(handleSoftDeletePack))
-	http.HandleFunc("/user/payment-info", userAuth(func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/user/payment-info", userAuth(denyWhileImpersonating(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
 			handleGetPaymentInfo(w, r)
@@ -18342,24 +19725,57 @@ func main() {
 		default:
 			jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		}
-	}))
+	})))
 	http.HandleFunc("/user/payment-info/fee-rate", userAuth(handleGetPaymentFeeRate))
 	http.HandleFunc("/user/payment-info/fee-rates", userAuth(handleGetAllPaymentFeeRates))
-	http.HandleFunc("/user/author/withdraw", userAuth(handleAuthorWithdraw))
+	http.HandleFunc("/user/author/withdraw", userAuth(denyWhileImpersonating(handleAuthorWithdraw)))
 	http.HandleFunc("/user/author/withdrawals", userAuth(handleAuthorWithdrawRecords))
 	http.HandleFunc("/user/author/edit-pack", userAuth(handleAuthorEditPack))
 	http.HandleFunc("/user/author/delete-pack", userAuth(handleAuthorDeletePack))
+	http.HandleFunc("/user/author/pack-images", userAuth(handleAuthorPackImages))
+	http.HandleFunc("/user/author/pack-images/reorder", userAuth(handleAuthorPackImagesReorder))
+	http.HandleFunc("/user/author/pack-images/delete", userAuth(handleAuthorPackImagesDelete))
+	http.HandleFunc("/pack-image/", handlePackImage)
 	http.HandleFunc("/user/author/delist-pack", userAuth(handleAuthorDelistPack))
+	http.HandleFunc("/user/author/schedule-pack", userAuth(handleAuthorSchedulePack))
+	http.HandleFunc("/user/author/unschedule-pack", userAuth(handleAuthorUnschedulePack))
+	http.HandleFunc("/user/author/pack-sale", userAuth(handleAuthorPackSale))
+	http.HandleFunc("/user/author/bulk-price", userAuth(handleAuthorBulkPrice))
+	http.HandleFunc("/user/author/price-history", userAuth(handleAuthorPriceHistory))
 	http.HandleFunc("/user/author/pack-purchases", userAuth(handleAuthorPackPurchases))
 	http.HandleFunc("/user/custom-product-orders", userAuth(handleUserCustomProductOrders))
+	http.HandleFunc("/user/receipts/order", userAuth(handleCustomProductOrderReceipt))
+	http.HandleFunc("/user/receipts/purchase", userAuth(handlePackPurchaseReceipt))
 	http.HandleFunc("/user/storefront/custom-product-orders", userAuth(handleStorefrontCustomProductOrders))
+	http.HandleFunc("/user/storefront/custom-product-orders/export", userAuth(handleStorefrontCustomProductOrdersExport))
 	http.HandleFunc("/user/storefront/custom-products", userAuth(handleCustomProductCRUD))
 	http.HandleFunc("/user/storefront/custom-products/", userAuth(handleCustomProductCRUD))
 	http.HandleFunc("/user/storefront/", userAuth(handleStorefrontManagement))
+	http.HandleFunc("/user/follows", userAuth(handleStorefrontFollows))
+	http.HandleFunc("/user/api-keys", userAuth(denyWhileImpersonating(handleUserAPIKeys)))
+	http.HandleFunc("/user/api-keys/", userAuth(denyWhileImpersonating(handleUserAPIKeys)))
+	http.HandleFunc("/user/cart", userAuth(handleUserCart))
+	http.HandleFunc("/user/cart/checkout", userAuth(denyWhileImpersonating(handleUserCartCheckout)))
+	http.HandleFunc("/user/bundles", userAuth(handleUserBundles))
+	http.HandleFunc("/user/referrals", userAuth(handleUserReferrals))
+	http.HandleFunc("/user/credits/expiring", userAuth(handleUserCreditsExpiry))
+	http.HandleFunc("/user/credits/gift", userAuth(denyWhileImpersonating(handleUserCreditsGift)))
+	http.HandleFunc("/user/notifications/read", userAuth(handleMarkNotificationsRead))
+	http.HandleFunc("/user/recently-viewed", userAuth(handleRecentlyViewed))
+	http.HandleFunc("/user/kyc", userAuth(handleUserKYC))
+	http.HandleFunc("/user/data-export", userAuth(denyAllWhileImpersonating(handleUserDataExport)))
+	http.HandleFunc("/user/delete-account", userAuth(denyWhileImpersonating(handleUserDeleteAccount)))
+	http.HandleFunc("/user/emails", userAuth(handleUserEmails))
+	http.HandleFunc("/user/emails/link", userAuth(denyWhileImpersonating(handleUserEmailLink)))
+	http.HandleFunc("/user/emails/verify", handleUserEmailLinkVerify)
+	http.HandleFunc("/user/emails/primary", userAuth(denyWhileImpersonating(handleUserEmailPrimary)))
+	http.HandleFunc("/user/emails/unlink", userAuth(denyWhileImpersonating(handleUserEmailUnlink)))
+	http.HandleFunc("/user/impersonation/exit", handleImpersonationExit)
 	http.HandleFunc("/user/", userAuth(handleUserDashboard))
 
 	// PayPal return callback (no auth required — PayPal redirects back without auth)
 	http.HandleFunc("/custom-product/paypal/return", handlePayPalReturn)
+	http.HandleFunc("/paypal/webhook", handlePayPalWebhook)
 
 	// Custom product purchase route (user session auth required, returns JSON)
 	http.HandleFunc("/custom-product/", func(w http.ResponseWriter, r *http.Request) {
@@ -18371,9 +19787,8 @@ func main() {
 				return
 			}
 			userID := getUserSessionUserID(cookie.Value)
-			var isBlocked int
-			if err := db.QueryRow("SELECT COALESCE(is_blocked, 0) FROM users WHERE id = ?", userID).Scan(&isBlocked); err == nil && isBlocked == 1 {
-				jsonResponse(w, http.StatusForbidden, map[string]string{"error": "账号已被封禁"})
+			if b := userBlockStatus(userID, time.Now()); b.Blocked {
+				writeBlockedJSON(w, r, b)
 				return
 			}
 			r.Header.Set("X-User-ID", fmt.Sprintf("%d", userID))
@@ -18385,6 +19800,12 @@ func main() {
 
 	// Storefront public routes (no auth required)
 	http.HandleFunc("/store/", handleStorefrontRoutes)
+	http.HandleFunc("/bundle/", handleBundleRoutes)
+	http.HandleFunc("/feed.xml", handleFeed)
+	http.HandleFunc("/sitemap.xml", handleSitemap)
+	http.HandleFunc("/api/stores/search", handleStoreSearch)
+	http.HandleFunc("/api/search/suggest", handleSearchSuggest)
+	http.HandleFunc("/api/v1/", publicAPICORS(handlePublicAPIv1))
 	http.HandleFunc("/api/decoration-fee", handleGetDecorationFee)
 
 	// Pack detail page route (catches /pack/*)
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Notification read state. Reads are stored per user in notification_reads,
+// so broadcast notifications, which have no notification_targets rows, are
+// tracked the same way as targeted ones. A notification is unread until the
+// user marks it read; which notifications count at all is still decided by
+// isNotificationVisible.
+
+// visibleNotifications returns the active notifications visible to userID
+// at now, newest first, with their read state. userID 0 gets broadcasts
+// only, all unread.
+func visibleNotifications(userID int64, now time.Time) ([]NotificationInfo, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT n.id, n.title, n.content, n.target_type, n.effective_date, n.display_duration_days, n.created_at,
+			EXISTS (SELECT 1 FROM notification_reads nr WHERE nr.notification_id = n.id AND nr.user_id = ?)
+		FROM notifications n
+		LEFT JOIN notification_targets nt ON n.id = nt.notification_id
+		WHERE n.status = 'active'
+		  AND n.effective_date <= ?
+		  AND (n.target_type = 'broadcast' OR (? > 0 AND n.target_type = 'targeted' AND nt.user_id = ?))
+		ORDER BY n.created_at DESC`,
+		userID, now.Format(time.RFC3339), userID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []NotificationInfo{}
+	for rows.Next() {
+		var n NotificationInfo
+		var effectiveDateStr string
+		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.TargetType, &effectiveDateStr, &n.DisplayDurationDays, &n.CreatedAt, &n.Read); err != nil {
+			log.Printf("Failed to scan notification: %v", err)
+			continue
+		}
+		// Parse effective_date and apply visibility filter
+		effectiveDate, err := time.Parse(time.RFC3339, effectiveDateStr)
+		if err != nil {
+			log.Printf("Failed to parse effective_date for notification %d: %v", n.ID, err)
+			continue
+		}
+		if !isNotificationVisible(effectiveDate, n.DisplayDurationDays, now) {
+			continue
+		}
+		n.EffectiveDate = effectiveDateStr
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// handleNotificationUnreadCount handles GET /api/notifications/unread-count.
+func handleNotificationUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	notifications, err := visibleNotifications(userID, time.Now())
+	if err != nil {
+		log.Printf("Failed to query notifications: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	unread := 0
+	for _, n := range notifications {
+		if !n.Read {
+			unread++
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]int{"unread": unread})
+}
+
+// handleMarkNotificationsRead handles POST /api/notifications/read and
+// /user/notifications/read with a JSON body {"ids": [1, 2]} or {"all": true}.
+// Only notifications currently visible to the user can be marked; other ids
+// are ignored.
+func handleMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	var req struct {
+		IDs []int64 `json:"ids"`
+		All bool    `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (!req.All && len(req.IDs) == 0) {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request_body"})
+		return
+	}
+
+	notifications, err := visibleNotifications(userID, time.Now())
+	if err != nil {
+		log.Printf("Failed to query notifications: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	wanted := make(map[int64]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		wanted[id] = true
+	}
+	marked := 0
+	for _, n := range notifications {
+		if n.Read || (!req.All && !wanted[n.ID]) {
+			continue
+		}
+		if _, err := db.Exec("INSERT OR IGNORE INTO notification_reads (notification_id, user_id) VALUES (?, ?)", n.ID, userID); err != nil {
+			log.Printf("Failed to mark notification %d read for user %d: %v", n.ID, userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		marked++
+	}
+	jsonResponse(w, http.StatusOK, map[string]int{"marked": marked})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotificationReadTracking(t *testing.T) {
+	useTestDB(t)
+
+	past := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	future := time.Now().Add(48 * time.Hour).Format(time.RFC3339)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (2, 'sn', 'reader', 'Reader'), (3, 'sn', 'other', 'Other')")
+	insert := func(id int, targetType, effective string, days int, status string) {
+		mustExec(t, `INSERT INTO notifications (id, title, content, target_type, effective_date, display_duration_days, status, created_by)
+			VALUES (?, 't', 'c', ?, ?, ?, ?, 1)`, id, targetType, effective, days, status)
+	}
+	insert(1, "broadcast", past, 0, "active")
+	insert(2, "targeted", past, 0, "active")
+	insert(3, "targeted", past, 0, "active")  // for user 3 only
+	insert(4, "broadcast", past, 1, "active") // display window over
+	insert(5, "broadcast", future, 0, "active")
+	insert(6, "broadcast", past, 0, "disabled")
+	mustExec(t, "INSERT INTO notification_targets (notification_id, user_id) VALUES (2, 2), (3, 3)")
+
+	do := func(handler http.HandlerFunc, method, body string) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/notifications", strings.NewReader(body))
+		req.Header.Set("X-User-ID", "2")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d, %s", method, body, rec.Code, rec.Body.String())
+		}
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+
+	if resp := do(handleNotificationUnreadCount, http.MethodGet, ""); resp["unread"] != float64(2) {
+		t.Errorf("unread before reading = %v, want 2", resp["unread"])
+	}
+	// Notifications the user cannot see are not marked.
+	if resp := do(handleMarkNotificationsRead, http.MethodPost, `{"ids": [1, 3, 4, 5]}`); resp["marked"] != float64(1) {
+		t.Errorf("marked = %v, want 1", resp["marked"])
+	}
+	if resp := do(handleNotificationUnreadCount, http.MethodGet, ""); resp["unread"] != float64(1) {
+		t.Errorf("unread after reading the broadcast = %v, want 1", resp["unread"])
+	}
+	do(handleMarkNotificationsRead, http.MethodPost, `{"all": true}`)
+	if resp := do(handleNotificationUnreadCount, http.MethodGet, ""); resp["unread"] != float64(0) {
+		t.Errorf("unread after reading all = %v, want 0", resp["unread"])
+	}
+
+	// Another user's read state is their own.
+	notifications, err := visibleNotifications(3, time.Now())
+	if err != nil || len(notifications) != 2 || notifications[0].Read || notifications[1].Read {
+		t.Errorf("user 3 notifications = %+v, %v", notifications, err)
+	}
+	var reads int
+	db.QueryRow("SELECT COUNT(*) FROM notification_reads").Scan(&reads)
+	if reads != 2 {
+		t.Errorf("notification_reads rows = %d, want 2", reads)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurring notifications. A notification with a recurrence other than
+// "none" repeats from its first effective date (recurrence_anchor) every
+// day, week or month until recurrence_end. Only one occurrence exists at a
+// time: startNotificationRecurrenceWorker moves effective_date to the next
+// occurrence once the current display window is over, so everything that
+// reads notifications, isNotificationVisible included, keeps working on the
+// single row. Read state lives in notification_reads keyed by notification,
+// so users who already read (dismissed) the announcement are not shown it as
+// unread again on later occurrences.
+const (
+	notificationRecurrenceSweepRate = 10 * time.Minute
+	maxNotificationRecurrenceYears  = 5
+	defaultOccurrencePreviewLimit   = 10
+	maxOccurrencePreviewLimit       = 50
+)
+
+// notificationRecurrencePeriodDays is the longest display window each
+// recurrence allows, so that occurrences never overlap.
+var notificationRecurrencePeriodDays = map[string]int{
+	"daily":   1,
+	"weekly":  7,
+	"monthly": 28,
+}
+
+// notificationOccurrence returns the k-th occurrence (k = 0 is the anchor).
+// Monthly occurrences keep the anchor's day of month, clamped to the last day
+// of shorter months.
+func notificationOccurrence(anchor time.Time, recurrence string, k int) time.Time {
+	switch recurrence {
+	case "daily":
+		return anchor.AddDate(0, 0, k)
+	case "weekly":
+		return anchor.AddDate(0, 0, 7*k)
+	case "monthly":
+		first := time.Date(anchor.Year(), anchor.Month()+time.Month(k), 1, anchor.Hour(), anchor.Minute(), anchor.Second(), 0, anchor.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		day := anchor.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+	return anchor
+}
+
+// validateNotificationRecurrence checks the recurrence settings of a
+// notification and returns the error message to show, or "".
+func validateNotificationRecurrence(recurrence string, effectiveDate time.Time, durationDays int, end time.Time) string {
+	if recurrence == "" || recurrence == "none" {
+		return ""
+	}
+	period, ok := notificationRecurrencePeriodDays[recurrence]
+	if !ok {
+		return "invalid recurrence"
+	}
+	if durationDays < 1 || durationDays > period {
+		return "display_duration_days of a " + recurrence + " notification must be between 1 and " + strconv.Itoa(period)
+	}
+	if end.IsZero() || !end.After(effectiveDate) {
+		return "recurrence_end must be after effective_date"
+	}
+	if end.After(effectiveDate.AddDate(maxNotificationRecurrenceYears, 0, 0)) {
+		return "recurrence_end must be within " + strconv.Itoa(maxNotificationRecurrenceYears) + " years"
+	}
+	return ""
+}
+
+// NotificationOccurrence is one display window of a recurring notification.
+type NotificationOccurrence struct {
+	EffectiveDate string `json:"effective_date"`
+	EndsAt        string `json:"ends_at"`
+}
+
+// upcomingNotificationOccurrences lists up to limit occurrences whose display
+// window has not ended by now, the current one first.
+func upcomingNotificationOccurrences(anchor time.Time, recurrence string, durationDays int, end, now time.Time, limit int) []NotificationOccurrence {
+	occurrences := []NotificationOccurrence{}
+	for k := 0; len(occurrences) < limit; k++ {
+		start := notificationOccurrence(anchor, recurrence, k)
+		if start.After(end) || (recurrence == "none" && k > 0) {
+			break
+		}
+		if !now.Before(start) && !isNotificationVisible(start, durationDays, now) {
+			continue
+		}
+		o := NotificationOccurrence{EffectiveDate: start.Format(time.RFC3339)}
+		if durationDays > 0 {
+			o.EndsAt = start.AddDate(0, 0, durationDays).Format(time.RFC3339)
+		}
+		occurrences = append(occurrences, o)
+	}
+	return occurrences
+}
+
+// startNotificationRecurrenceWorker periodically moves recurring
+// notifications on to their next occurrence.
+func startNotificationRecurrenceWorker() {
+	go func() {
+		ticker := time.NewTicker(notificationRecurrenceSweepRate)
+		defer ticker.Stop()
+		for {
+			advanceRecurringNotifications(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// advanceRecurringNotifications sets the effective_date of every active
+// recurring notification whose display window is over to its next occurrence
+// that is showing or still to come, skipping any missed while the server was
+// down. It returns how many notifications moved. Series past recurrence_end
+// are left alone; their last window has ended, so they are no longer visible.
+func advanceRecurringNotifications(now time.Time) int {
+	rows, err := db.Query(`SELECT id, effective_date, display_duration_days, recurrence, recurrence_anchor, recurrence_end
+		FROM notifications WHERE status = 'active' AND COALESCE(recurrence, 'none') != 'none'`)
+	if err != nil {
+		log.Printf("[NOTIFICATION-RECURRENCE] failed to query notifications: %v", err)
+		return 0
+	}
+	type recurring struct {
+		ID                                 int64
+		EffectiveDate, Anchor, End, Recurs string
+		DurationDays                       int
+	}
+	var due []recurring
+	for rows.Next() {
+		var n recurring
+		if rows.Scan(&n.ID, &n.EffectiveDate, &n.DurationDays, &n.Recurs, &n.Anchor, &n.End) == nil {
+			due = append(due, n)
+		}
+	}
+	rows.Close()
+
+	moved := 0
+	for _, n := range due {
+		effectiveDate, err1 := time.Parse(time.RFC3339, n.EffectiveDate)
+		anchor, err2 := time.Parse(time.RFC3339, n.Anchor)
+		end, err3 := time.Parse(time.RFC3339, n.End)
+		if err1 != nil || err2 != nil || err3 != nil {
+			log.Printf("[NOTIFICATION-RECURRENCE] notification %d has invalid dates, skipping", n.ID)
+			continue
+		}
+		if now.Before(effectiveDate) || isNotificationVisible(effectiveDate, n.DurationDays, now) {
+			continue
+		}
+		next := upcomingNotificationOccurrences(anchor, n.Recurs, n.DurationDays, end, now, 1)
+		if len(next) == 0 || next[0].EffectiveDate == n.EffectiveDate {
+			continue
+		}
+		if _, err := db.Exec("UPDATE notifications SET effective_date = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND effective_date = ?",
+			next[0].EffectiveDate, n.ID, n.EffectiveDate); err != nil {
+			log.Printf("[NOTIFICATION-RECURRENCE] failed to advance notification %d: %v", n.ID, err)
+			continue
+		}
+		moved++
+	}
+	if moved > 0 {
+		log.Printf("[NOTIFICATION-RECURRENCE] advanced %d recurring notifications", moved)
+	}
+	return moved
+}
+
+// handleAdminNotificationOccurrences handles
+// GET /api/admin/notifications/{id}/occurrences, which previews the upcoming
+// occurrences of a notification, and GET /api/admin/notifications/occurrences
+// with effective_date, display_duration_days, recurrence and recurrence_end
+// query parameters, which previews settings before a notification is created.
+// Both take an optional limit.
+func handleAdminNotificationOccurrences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	q := r.URL.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultOccurrencePreviewLimit
+	}
+	if limit > maxOccurrencePreviewLimit {
+		limit = maxOccurrencePreviewLimit
+	}
+
+	var anchorStr, endStr, recurrence string
+	var durationDays int
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/admin/notifications/"), "/occurrences")
+	if path == "occurrences" {
+		anchorStr, endStr, recurrence = q.Get("effective_date"), q.Get("recurrence_end"), q.Get("recurrence")
+		durationDays, _ = strconv.Atoi(q.Get("display_duration_days"))
+		if anchorStr == "" {
+			anchorStr = time.Now().Format(time.RFC3339)
+		}
+	} else {
+		id, err := strconv.ParseInt(path, 10, 64)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_id"})
+			return
+		}
+		if err := db.QueryRow(`SELECT COALESCE(NULLIF(recurrence_anchor, ''), effective_date), COALESCE(recurrence_end, ''),
+			COALESCE(recurrence, 'none'), display_duration_days FROM notifications WHERE id = ? AND status != 'deleted'`, id).
+			Scan(&anchorStr, &endStr, &recurrence, &durationDays); err != nil {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "notification not found"})
+			return
+		}
+	}
+	if recurrence == "" {
+		recurrence = "none"
+	}
+
+	anchor, err := time.Parse(time.RFC3339, anchorStr)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid effective_date format"})
+		return
+	}
+	end := anchor
+	if recurrence != "none" {
+		if end, err = time.Parse(time.RFC3339, endStr); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid recurrence_end format"})
+			return
+		}
+	}
+	if msg := validateNotificationRecurrence(recurrence, anchor, durationDays, end); msg != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"recurrence":  recurrence,
+		"occurrences": upcomingNotificationOccurrences(anchor, recurrence, durationDays, end, time.Now(), limit),
+	})
+}
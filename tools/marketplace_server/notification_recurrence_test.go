@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotificationOccurrenceMonthlyClamp(t *testing.T) {
+	anchor := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	for k, want := range []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"} {
+		if got := notificationOccurrence(anchor, "monthly", k).Format("2006-01-02"); got != want {
+			t.Errorf("occurrence %d = %s, want %s", k, got, want)
+		}
+	}
+}
+
+func TestValidateNotificationRecurrence(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 3, 0)
+	cases := []struct {
+		recurrence string
+		days       int
+		end        time.Time
+		ok         bool
+	}{
+		{"none", 0, time.Time{}, true},
+		{"weekly", 3, end, true},
+		{"weekly", 0, end, false},
+		{"daily", 2, end, false},
+		{"monthly", 28, time.Time{}, false},
+		{"monthly", 28, start, false},
+		{"monthly", 28, start.AddDate(6, 0, 0), false},
+		{"hourly", 1, end, false},
+	}
+	for _, c := range cases {
+		if got := validateNotificationRecurrence(c.recurrence, start, c.days, c.end) == ""; got != c.ok {
+			t.Errorf("%s/%d days: valid = %v, want %v", c.recurrence, c.days, got, c.ok)
+		}
+	}
+}
+
+func TestAdvanceRecurringNotifications(t *testing.T) {
+	useTestDB(t)
+
+	anchor := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC) // a Monday
+	end := anchor.AddDate(0, 0, 21)
+	if _, err := db.Exec(`INSERT INTO notifications (id, title, content, target_type, effective_date, display_duration_days, status, created_by,
+		recurrence, recurrence_anchor, recurrence_end) VALUES (1, 't', 'c', 'broadcast', ?, 2, 'active', 1, 'weekly', ?, ?)`,
+		anchor.Format(time.RFC3339), anchor.Format(time.RFC3339), end.Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	effectiveDate := func() string {
+		var s string
+		db.QueryRow("SELECT effective_date FROM notifications WHERE id = 1").Scan(&s)
+		return s
+	}
+
+	// Still showing: nothing moves.
+	if n := advanceRecurringNotifications(anchor.Add(24 * time.Hour)); n != 0 {
+		t.Errorf("advanced %d while visible", n)
+	}
+	// Window over: the next occurrence is a week after the anchor.
+	advanceRecurringNotifications(anchor.AddDate(0, 0, 3))
+	if got, want := effectiveDate(), anchor.AddDate(0, 0, 7).Format(time.RFC3339); got != want {
+		t.Errorf("effective_date = %s, want %s", got, want)
+	}
+	// A missed occurrence is skipped over.
+	advanceRecurringNotifications(anchor.AddDate(0, 0, 19))
+	if got, want := effectiveDate(), anchor.AddDate(0, 0, 21).Format(time.RFC3339); got != want {
+		t.Errorf("effective_date after gap = %s, want %s", got, want)
+	}
+	// Past recurrence_end the series stops.
+	if n := advanceRecurringNotifications(anchor.AddDate(0, 0, 30)); n != 0 {
+		t.Errorf("advanced %d past recurrence_end", n)
+	}
+
+	occurrences := upcomingNotificationOccurrences(anchor, "weekly", 2, end, anchor.AddDate(0, 0, 1), 10)
+	if len(occurrences) != 4 || occurrences[0].EffectiveDate != anchor.Format(time.RFC3339) {
+		t.Errorf("occurrences = %+v", occurrences)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Segment targeting for notifications. Instead of listing user IDs an admin
+// can describe who should get a targeted notification: buyers of a pack,
+// customers of a store, and/or users whose lifetime credits spend reaches a
+// threshold. All given criteria must match. The segment is resolved once,
+// when the notification is created, into notification_targets rows, so it
+// behaves like any other targeted notification afterwards; the criteria are
+// kept on the notification for reference.
+
+// spendTransactionTypes are the credits transactions that count as spending,
+// the same set author revenue is computed from.
+const spendTransactionTypes = "'purchase', 'download', 'purchase_uses', 'renew'"
+
+// NotificationTargetCriteria describes a segment of users. Zero values mean
+// "any".
+type NotificationTargetCriteria struct {
+	ListingID    int64   `json:"listing_id,omitempty"`
+	StorefrontID int64   `json:"storefront_id,omitempty"`
+	MinSpend     float64 `json:"min_spend,omitempty"`
+}
+
+// validate checks the criteria and returns the error message to show, or "".
+func (c NotificationTargetCriteria) validate() string {
+	if c.ListingID < 0 || c.StorefrontID < 0 || c.MinSpend < 0 {
+		return "target criteria must not be negative"
+	}
+	if c.ListingID == 0 && c.StorefrontID == 0 && c.MinSpend == 0 {
+		return "at least one target criterion is required"
+	}
+	var n int
+	if c.ListingID > 0 {
+		if db.QueryRow("SELECT COUNT(*) FROM pack_listings WHERE id = ?", c.ListingID).Scan(&n); n == 0 {
+			return "pack not found"
+		}
+	}
+	if c.StorefrontID > 0 {
+		if db.QueryRow("SELECT COUNT(*) FROM author_storefronts WHERE id = ?", c.StorefrontID).Scan(&n); n == 0 {
+			return "storefront not found"
+		}
+	}
+	return ""
+}
+
+// query builds the SELECT of the IDs of unblocked users matching c.
+func (c NotificationTargetCriteria) query() (string, []interface{}) {
+	conds := []string{"COALESCE(u.is_blocked, 0) = 0"}
+	var args []interface{}
+	if c.ListingID > 0 {
+		conds = append(conds, "EXISTS (SELECT 1 FROM user_purchased_packs upp WHERE upp.user_id = u.id AND upp.listing_id = ?)")
+		args = append(args, c.ListingID)
+	}
+	if c.StorefrontID > 0 {
+		conds = append(conds, `(EXISTS (SELECT 1 FROM credits_transactions ct
+				JOIN pack_listings pl ON pl.id = ct.listing_id
+				JOIN author_storefronts s ON s.user_id = pl.user_id
+				WHERE ct.user_id = u.id AND ct.amount < 0 AND s.id = ?)
+			OR EXISTS (SELECT 1 FROM custom_product_orders o
+				JOIN custom_products p ON p.id = o.custom_product_id
+				WHERE o.user_id = u.id AND o.status = 'fulfilled' AND p.storefront_id = ?))`)
+		args = append(args, c.StorefrontID, c.StorefrontID)
+	}
+	if c.MinSpend > 0 {
+		conds = append(conds, `(SELECT COALESCE(-SUM(ct.amount), 0) FROM credits_transactions ct
+			WHERE ct.user_id = u.id AND ct.amount < 0 AND ct.transaction_type IN (`+spendTransactionTypes+`)) >= ?`)
+		args = append(args, c.MinSpend)
+	}
+	return "SELECT u.id FROM users u WHERE " + strings.Join(conds, " AND "), args
+}
+
+// resolve returns the IDs of the users matching c.
+func (c NotificationTargetCriteria) resolve(tx *sql.Tx) ([]int64, error) {
+	query, args := c.query()
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// handleAdminNotificationEstimate handles POST /api/admin/notifications/estimate
+// with a JSON body of target criteria and returns how many users match now.
+func handleAdminNotificationEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	var c NotificationTargetCriteria
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if msg := c.validate(); msg != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	query, args := c.query()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM ("+query+")", args...).Scan(&count); err != nil {
+		log.Printf("Failed to estimate notification recipients: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]int{"count": count})
+}
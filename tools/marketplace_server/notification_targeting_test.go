@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotificationSegmentTargeting(t *testing.T) {
+	useTestDB(t)
+
+	// User 1 owns store 7 and pack 100. Users 2-4 are customers, 5 is blocked.
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (2, 'sn', 'a', 'A'), (3, 'sn', 'b', 'B'), (4, 'sn', 'c', 'C')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, is_blocked) VALUES (5, 'sn', 'd', 'D', 1)")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_slug) VALUES (7, 1, 'store')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (100, 1, 1, x'00', 'Pack', 'per_use', 50, 'published')")
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (2, 100), (3, 100), (5, 100)")
+	mustExec(t, `INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id) VALUES
+		(2, 'purchase', -50, 100), (2, 'purchase_uses', -200, 100), (3, 'purchase', -50, 100), (5, 'purchase', -500, 100)`)
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount) VALUES (4, 'gift_out', -1000)")
+	mustExec(t, "INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd) VALUES (9, 7, 'Credits', 'credits', 5)")
+	mustExec(t, "INSERT INTO custom_product_orders (custom_product_id, user_id, amount_usd, status) VALUES (9, 4, 5, 'fulfilled')")
+
+	post := func(handler http.HandlerFunc, target, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	for body, want := range map[string]float64{
+		`{"listing_id": 100}`:                   2, // blocked buyer excluded
+		`{"storefront_id": 7}`:                  3, // pack buyers and the custom product customer
+		`{"min_spend": 100}`:                    1, // gifts are not spending
+		`{"storefront_id": 7, "min_spend": 50}`: 2,
+	} {
+		if code, resp := post(handleAdminNotificationEstimate, "/api/admin/notifications/estimate", body); code != http.StatusOK || resp["count"] != want {
+			t.Errorf("estimate %s: status %d, %v, want %v", body, code, resp, want)
+		}
+	}
+	for _, body := range []string{`{}`, `{"listing_id": 999}`, `{"storefront_id": -1}`} {
+		if code, _ := post(handleAdminNotificationEstimate, "/api/admin/notifications/estimate", body); code != http.StatusBadRequest {
+			t.Errorf("estimate %s: status %d, want 400", body, code)
+		}
+	}
+
+	code, resp := post(handleAdminCreateNotification, "/api/admin/notifications",
+		`{"title": "New version", "content": "Pack updated", "target_criteria": {"listing_id": 100}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("create: status %d, %v", code, resp)
+	}
+	var targetType, criteria string
+	var targets int
+	db.QueryRow("SELECT target_type, target_criteria FROM notifications WHERE id = ?", resp["id"]).Scan(&targetType, &criteria)
+	db.QueryRow("SELECT COUNT(*) FROM notification_targets WHERE notification_id = ? AND user_id IN (2, 3)", resp["id"]).Scan(&targets)
+	if targetType != "targeted" || criteria != `{"listing_id":100}` || targets != 2 {
+		t.Errorf("created notification: type %q, criteria %q, targets %d", targetType, criteria, targets)
+	}
+
+	if code, _ := post(handleAdminCreateNotification, "/api/admin/notifications",
+		`{"title": "t", "content": "c", "target_criteria": {"min_spend": 100000}}`); code != http.StatusBadRequest {
+		t.Errorf("empty segment: status %d, want 400", code)
+	}
+}
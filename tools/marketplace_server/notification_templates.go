@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"text/template"
+
+	"marketplace_server/i18n"
+)
+
+// notificationTemplateTypes lists the predefined storefront notification
+// templates. The name, subject and body of each come from the i18n keys
+// notify_tpl_<type>_name/_subject/_body and may use {{.StoreName}} plus
+// author-supplied placeholders such as {{.Version}}.
+var notificationTemplateTypes = []string{"version_update", "holiday_promo", "flash_promo"}
+
+// notificationTemplates returns the predefined email notification templates in lang.
+func notificationTemplates(lang i18n.Lang) []NotificationTemplate {
+	list := make([]NotificationTemplate, 0, len(notificationTemplateTypes))
+	for _, t := range notificationTemplateTypes {
+		list = append(list, NotificationTemplate{
+			Type:    t,
+			Name:    i18n.T(lang, "notify_tpl_"+t+"_name"),
+			Subject: i18n.T(lang, "notify_tpl_"+t+"_subject"),
+			Body:    i18n.T(lang, "notify_tpl_"+t+"_body"),
+		})
+	}
+	return list
+}
+
+// renderNotificationTemplate fills the templateType template in lang with
+// data (StoreName and the author's placeholder values). ok is false when the
+// template type is unknown or fails to render.
+func renderNotificationTemplate(templateType string, lang i18n.Lang, data map[string]string) (subject, body string, ok bool) {
+	for _, tmpl := range notificationTemplates(lang) {
+		if tmpl.Type != templateType {
+			continue
+		}
+		render := func(text string) (string, bool) {
+			t, err := template.New(templateType).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				return "", false
+			}
+			var buf bytes.Buffer
+			if err := t.Execute(&buf, data); err != nil {
+				return "", false
+			}
+			return buf.String(), true
+		}
+		subject, ok1 := render(tmpl.Subject)
+		body, ok2 := render(tmpl.Body)
+		return subject, body, ok1 && ok2
+	}
+	return "", "", false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"marketplace_server/i18n"
+)
+
+func TestRenderNotificationTemplatePerLanguage(t *testing.T) {
+	data := map[string]string{"StoreName": "Acme", "Version": "2.0", "UpdateContent": "faster", "PromoInfo": "10% off"}
+
+	zhSubject, zhBody, ok := renderNotificationTemplate("version_update", i18n.ZhCN, data)
+	if !ok || zhSubject != "[Acme] 分析包版本更新通知" || !strings.Contains(zhBody, "已更新至 2.0 版本") {
+		t.Errorf("zh: ok=%v subject=%q body=%q", ok, zhSubject, zhBody)
+	}
+	enSubject, enBody, ok := renderNotificationTemplate("version_update", i18n.EnUS, data)
+	if !ok || enSubject != "[Acme] Analysis pack update" || !strings.Contains(enBody, "updated to version 2.0") {
+		t.Errorf("en: ok=%v subject=%q body=%q", ok, enSubject, enBody)
+	}
+
+	// Every template exists in both languages and leaves no placeholder behind.
+	for _, typ := range notificationTemplateTypes {
+		for _, lang := range []i18n.Lang{i18n.ZhCN, i18n.EnUS} {
+			subject, body, ok := renderNotificationTemplate(typ, lang, map[string]string{"StoreName": "Acme"})
+			if !ok || strings.Contains(subject+body, "{{") || strings.Contains(subject+body, "<no value>") {
+				t.Errorf("%s/%s: ok=%v subject=%q body=%q", typ, lang, ok, subject, body)
+			}
+		}
+	}
+	if _, _, ok := renderNotificationTemplate("unknown", i18n.EnUS, data); ok {
+		t.Error("unknown template type rendered")
+	}
+}
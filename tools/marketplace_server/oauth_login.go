@@ -0,0 +1,517 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauthLoginProvider describes the OAuth2 endpoints of a browser login provider.
+type oauthLoginProvider struct {
+	AuthURL  string
+	TokenURL string
+	Scope    string
+}
+
+// oauthLoginProviders lists the providers supported by the /user/oauth/ flow.
+// Client IDs and secrets are stored in settings as oauth_<provider>_client_id
+// and oauth_<provider>_client_secret (the secret AES-encrypted).
+var oauthLoginProviders = map[string]oauthLoginProvider{
+	"google": {
+		AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL: "https://oauth2.googleapis.com/token",
+		Scope:    "openid email profile",
+	},
+	"github": {
+		AuthURL:  "https://github.com/login/oauth/authorize",
+		TokenURL: "https://github.com/login/oauth/access_token",
+		Scope:    "read:user user:email",
+	},
+}
+
+// oauthProfile is the provider-independent subset of the user profile we need.
+type oauthProfile struct {
+	ID            string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OAuth state store: protects the callback against CSRF and carries the
+// post-login redirect target. The state is also set in a short-lived cookie
+// on the browser that started the login, and the callback must present both:
+// a state captured from someone else's flow is useless in another browser,
+// and a callback link forged by an attacker cannot log the victim into the
+// attacker's account.
+var (
+	oauthStates   = make(map[string]oauthStateEntry)
+	oauthStatesMu sync.Mutex
+)
+
+const (
+	oauthStateTTL    = 10 * time.Minute
+	oauthStateCookie = "oauth_state"
+)
+
+type oauthStateEntry struct {
+	Provider string
+	Redirect string
+	Expiry   time.Time
+}
+
+func createOAuthState(provider, redirect string) string {
+	id := generateSessionID()
+	oauthStatesMu.Lock()
+	oauthStates[id] = oauthStateEntry{Provider: provider, Redirect: redirect, Expiry: time.Now().Add(oauthStateTTL)}
+	oauthStatesMu.Unlock()
+	return id
+}
+
+// consumeOAuthState validates and removes a state value. Returns ok=false if the
+// state is unknown, expired, issued for a different provider, or does not match
+// cookieState, the value of the browser's oauth_state cookie.
+func consumeOAuthState(state, cookieState, provider string) (oauthStateEntry, bool) {
+	oauthStatesMu.Lock()
+	defer oauthStatesMu.Unlock()
+	entry, ok := oauthStates[state]
+	delete(oauthStates, state)
+	if !ok || time.Now().After(entry.Expiry) || entry.Provider != provider ||
+		subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		return oauthStateEntry{}, false
+	}
+	return entry, true
+}
+
+// cleanupOAuthStates removes expired OAuth states.
+func cleanupOAuthStates(now time.Time) {
+	oauthStatesMu.Lock()
+	for id, entry := range oauthStates {
+		if now.After(entry.Expiry) {
+			delete(oauthStates, id)
+		}
+	}
+	oauthStatesMu.Unlock()
+}
+
+// getOAuthCredentials returns the configured client ID and decrypted secret
+// for provider. ok is false when the provider is not configured.
+func getOAuthCredentials(provider string) (clientID, clientSecret string, ok bool) {
+	clientID = getSetting("oauth_" + provider + "_client_id")
+	encrypted := getSetting("oauth_" + provider + "_client_secret")
+	if clientID == "" || encrypted == "" {
+		return "", "", false
+	}
+	secret, err := decryptPayPalSecret(encrypted)
+	if err != nil {
+		log.Printf("[OAUTH] failed to decrypt %s client secret: %v", provider, err)
+		return "", "", false
+	}
+	return clientID, secret, true
+}
+
+// isOAuthLoginEnabled reports whether provider has credentials configured.
+func isOAuthLoginEnabled(provider string) bool {
+	return getSetting("oauth_"+provider+"_client_id") != "" && getSetting("oauth_"+provider+"_client_secret") != ""
+}
+
+// oauthRedirectURI builds the callback URL registered with the provider.
+func oauthRedirectURI(r *http.Request, provider string) string {
+	return requestBaseURL(r) + "/user/oauth/" + provider + "/callback"
+}
+
+// handleUserOAuth handles GET /user/oauth/{provider}/start and
+// GET /user/oauth/{provider}/callback.
+func handleUserOAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/user/oauth/"), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	provider, action := parts[0], parts[1]
+	cfg, known := oauthLoginProviders[provider]
+	if !known {
+		http.NotFound(w, r)
+		return
+	}
+	clientID, clientSecret, ok := getOAuthCredentials(provider)
+	if !ok {
+		http.Redirect(w, r, "/user/login?error=oauth_failed", http.StatusFound)
+		return
+	}
+
+	switch action {
+	case "start":
+		state := createOAuthState(provider, r.URL.Query().Get("redirect"))
+		http.SetCookie(w, makeSessionCookie(oauthStateCookie, state, int(oauthStateTTL.Seconds())))
+		q := url.Values{}
+		q.Set("client_id", clientID)
+		q.Set("redirect_uri", oauthRedirectURI(r, provider))
+		q.Set("response_type", "code")
+		q.Set("scope", cfg.Scope)
+		q.Set("state", state)
+		http.Redirect(w, r, cfg.AuthURL+"?"+q.Encode(), http.StatusFound)
+
+	case "callback":
+		var cookieState string
+		if c, err := r.Cookie(oauthStateCookie); err == nil {
+			cookieState = c.Value
+		}
+		http.SetCookie(w, makeSessionCookie(oauthStateCookie, "", -1))
+		entry, ok := consumeOAuthState(r.URL.Query().Get("state"), cookieState, provider)
+		if !ok {
+			log.Printf("[OAUTH] invalid or expired state for provider=%s", provider)
+			http.Redirect(w, r, "/user/login?error=oauth_failed", http.StatusFound)
+			return
+		}
+		if errParam := r.URL.Query().Get("error"); errParam != "" {
+			log.Printf("[OAUTH] provider=%s returned error=%q", provider, errParam)
+			http.Redirect(w, r, "/user/login?error=oauth_failed", http.StatusFound)
+			return
+		}
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			http.Redirect(w, r, "/user/login?error=oauth_failed", http.StatusFound)
+			return
+		}
+
+		accessToken, err := exchangeOAuthCode(cfg, clientID, clientSecret, code, oauthRedirectURI(r, provider))
+		if err != nil {
+			log.Printf("[OAUTH] token exchange failed for provider=%s: %v", provider, err)
+			http.Redirect(w, r, "/user/login?error=oauth_failed", http.StatusFound)
+			return
+		}
+		profile, err := fetchOAuthProfile(provider, accessToken)
+		if err != nil {
+			log.Printf("[OAUTH] profile fetch failed for provider=%s: %v", provider, err)
+			http.Redirect(w, r, "/user/login?error=oauth_failed", http.StatusFound)
+			return
+		}
+
+		userID, errKey := resolveOAuthUser(provider, profile)
+		if errKey == "blocked" && userID > 0 {
+			http.Redirect(w, r, blockedLoginURL(userID), http.StatusFound)
+			return
+		}
+		if errKey != "" {
+			http.Redirect(w, r, "/user/login?error="+errKey, http.StatusFound)
+			return
+		}
+
+		log.Printf("[OAUTH] login success provider=%s userID=%d", provider, userID)
+		sid := rotateUserSession(getUserSessionFromRequest(r), userID, false)
+		http.SetCookie(w, makeSessionCookie("user_session", sid, 86400))
+		redirect := entry.Redirect
+		if strings.HasPrefix(redirect, "/pack/") || strings.HasPrefix(redirect, "/store/") || strings.HasPrefix(redirect, "/user/") {
+			http.Redirect(w, r, redirect, http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/user/dashboard", http.StatusFound)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// exchangeOAuthCode trades an authorization code for an access token.
+func exchangeOAuthCode(cfg oauthLoginProvider, clientID, clientSecret, code, redirectURI string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+
+	req, err := http.NewRequest("POST", cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := externalHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("no access token in response (error=%q)", tokenResp.Error)
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// oauthGetJSON performs an authenticated GET and decodes the JSON response into out.
+func oauthGetJSON(endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := externalHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// fetchOAuthProfile loads the user's identity from the provider.
+func fetchOAuthProfile(provider, accessToken string) (oauthProfile, error) {
+	switch provider {
+	case "google":
+		var info struct {
+			Sub           string `json:"sub"`
+			Email         string `json:"email"`
+			EmailVerified bool   `json:"email_verified"`
+			Name          string `json:"name"`
+		}
+		if err := oauthGetJSON("https://openidconnect.googleapis.com/v1/userinfo", accessToken, &info); err != nil {
+			return oauthProfile{}, err
+		}
+		if info.Sub == "" {
+			return oauthProfile{}, fmt.Errorf("google profile missing sub")
+		}
+		return oauthProfile{ID: info.Sub, Email: info.Email, EmailVerified: info.EmailVerified, Name: info.Name}, nil
+
+	case "github":
+		var info struct {
+			ID    int64  `json:"id"`
+			Login string `json:"login"`
+			Name  string `json:"name"`
+		}
+		if err := oauthGetJSON("https://api.github.com/user", accessToken, &info); err != nil {
+			return oauthProfile{}, err
+		}
+		if info.ID == 0 {
+			return oauthProfile{}, fmt.Errorf("github profile missing id")
+		}
+		profile := oauthProfile{ID: strconv.FormatInt(info.ID, 10), Name: info.Name}
+		if profile.Name == "" {
+			profile.Name = info.Login
+		}
+		// The public profile email may be hidden; use the primary address from /user/emails.
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := oauthGetJSON("https://api.github.com/user/emails", accessToken, &emails); err != nil {
+			log.Printf("[OAUTH] github emails fetch failed: %v", err)
+		}
+		for _, e := range emails {
+			if e.Primary {
+				profile.Email = e.Email
+				profile.EmailVerified = e.Verified
+				break
+			}
+		}
+		return profile, nil
+	}
+	return oauthProfile{}, fmt.Errorf("unsupported provider: %s", provider)
+}
+
+// resolveOAuthUser finds or creates the users row for an OAuth identity and
+// returns its ID. On failure it returns an error key understood by the login page;
+// for "blocked" the ID of the blocked user is returned too.
+//
+// A new identity whose email already belongs to another account (e.g. an SN
+// user) is linked to that email's wallet only when the provider reports the
+// email as verified; otherwise the login is refused so an unverified address
+// cannot be used to take over an existing wallet.
+func resolveOAuthUser(provider string, profile oauthProfile) (int64, string) {
+	var userID int64
+	var blocked int
+	err := db.QueryRow("SELECT id, COALESCE(is_blocked, 0) FROM users WHERE auth_type = ? AND auth_id = ?",
+		provider, profile.ID).Scan(&userID, &blocked)
+	if err == nil {
+		if blocked != 0 && userBlockStatus(userID, time.Now()).Blocked {
+			return userID, "blocked"
+		}
+		return userID, ""
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("[OAUTH] failed to query user: %v", err)
+		return 0, "oauth_failed"
+	}
+
+	email := strings.TrimSpace(profile.Email)
+	if !profile.EmailVerified {
+		email = ""
+	}
+
+	existingEmail := false
+	if profile.Email != "" {
+		var userCount, activeCount, walletCount int
+		db.QueryRow("SELECT COUNT(*), COALESCE(SUM(CASE WHEN COALESCE(is_blocked, 0) = 0 THEN 1 ELSE 0 END), 0) FROM users WHERE email = ?",
+			strings.TrimSpace(profile.Email)).Scan(&userCount, &activeCount)
+		db.QueryRow("SELECT COUNT(*) FROM email_wallets WHERE email = ?", strings.TrimSpace(profile.Email)).Scan(&walletCount)
+		if userCount > 0 || walletCount > 0 {
+			if !profile.EmailVerified {
+				log.Printf("[OAUTH] refusing to link unverified email %q from provider=%s", profile.Email, provider)
+				return 0, "oauth_email_unverified"
+			}
+			if userCount > 0 && activeCount == 0 {
+				return 0, "blocked"
+			}
+			existingEmail = true
+		}
+	}
+
+	displayName := strings.TrimSpace(profile.Name)
+	if displayName == "" {
+		displayName = provider + " user"
+	}
+
+	// Linking to an existing email shares its wallet, so no initial credits are granted.
+	var initialBalance float64
+	if !existingEmail {
+		if s := getSetting("initial_credits_balance"); s != "" {
+			fmt.Sscanf(s, "%f", &initialBalance)
+		}
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO users (auth_type, auth_id, display_name, email, credits_balance) VALUES (?, ?, ?, ?, ?)",
+		provider, profile.ID, displayName, email, initialBalance,
+	)
+	if err != nil {
+		// A concurrent callback may have created the row; fall back to it.
+		if qerr := db.QueryRow("SELECT id FROM users WHERE auth_type = ? AND auth_id = ?", provider, profile.ID).Scan(&userID); qerr == nil {
+			return userID, ""
+		}
+		log.Printf("[OAUTH] failed to create user: %v", err)
+		return 0, "oauth_failed"
+	}
+	userID, err = result.LastInsertId()
+	if err != nil {
+		log.Printf("[OAUTH] failed to get last insert ID: %v", err)
+		return 0, "oauth_failed"
+	}
+
+	if initialBalance > 0 {
+		if _, err := db.Exec(
+			"INSERT INTO credits_transactions (user_id, transaction_type, amount, description) VALUES (?, 'initial', ?, 'Initial credits balance')",
+			userID, initialBalance,
+		); err != nil {
+			log.Printf("[OAUTH] failed to record initial credits transaction: %v", err)
+		}
+	}
+	if email != "" {
+		// Only provider-verified addresses reach this point.
+		markEmailVerified(email)
+	}
+	if existingEmail {
+		log.Printf("[OAUTH] linked provider=%s identity to existing email %q (userID=%d)", provider, email, userID)
+	}
+	return userID, ""
+}
+
+// handleAdminOAuthSettings handles GET/POST /admin/settings/oauth.
+// GET: returns the client ID and masked secret for each provider.
+// POST: saves one provider's credentials; an empty client_id disables the
+// provider, an empty client_secret keeps the stored secret.
+func handleAdminOAuthSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		resp := map[string]map[string]string{}
+		for provider := range oauthLoginProviders {
+			masked := ""
+			if encrypted := getSetting("oauth_" + provider + "_client_secret"); encrypted != "" {
+				if decrypted, err := decryptPayPalSecret(encrypted); err != nil {
+					log.Printf("Failed to decrypt oauth_%s_client_secret: %v", provider, err)
+					masked = "****"
+				} else {
+					masked = maskPayPalSecret(decrypted)
+				}
+			}
+			resp[provider] = map[string]string{
+				"client_id":     getSetting("oauth_" + provider + "_client_id"),
+				"client_secret": masked,
+			}
+		}
+		jsonResponse(w, http.StatusOK, resp)
+
+	case http.MethodPost:
+		var req struct {
+			Provider     string `json:"provider"`
+			ClientID     string `json:"client_id"`
+			ClientSecret string `json:"client_secret"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+		if _, ok := oauthLoginProviders[req.Provider]; !ok {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "不支持的登录方式"})
+			return
+		}
+		idKey := "oauth_" + req.Provider + "_client_id"
+		secretKey := "oauth_" + req.Provider + "_client_secret"
+
+		clientID := strings.TrimSpace(req.ClientID)
+		if clientID == "" {
+			if _, err := db.Exec("DELETE FROM settings WHERE key IN (?, ?)", idKey, secretKey); err != nil {
+				log.Printf("Failed to clear %s settings: %v", req.Provider, err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+			jsonResponse(w, http.StatusOK, map[string]bool{"ok": true})
+			return
+		}
+		if strings.TrimSpace(req.ClientSecret) == "" && getSetting(secretKey) == "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请填写 Client Secret"})
+			return
+		}
+
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", idKey, clientID); err != nil {
+			log.Printf("Failed to save %s: %v", idKey, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if strings.TrimSpace(req.ClientSecret) != "" {
+			encrypted, err := encryptPayPalSecret(req.ClientSecret)
+			if err != nil {
+				log.Printf("Failed to encrypt %s: %v", secretKey, err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "服务器加密配置错误"})
+				return
+			}
+			if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", secretKey, encrypted); err != nil {
+				log.Printf("Failed to save %s: %v", secretKey, err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+				return
+			}
+		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"ok": true})
+
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOAuthStateBoundToBrowser(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "oauth-test-key")
+	encrypted, err := encryptPayPalSecret("client-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('oauth_google_client_id', 'client-id'), ('oauth_google_client_secret', ?)", encrypted)
+
+	// The token endpoint is only reached once the state has been accepted.
+	var exchanges int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&exchanges, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer tokenSrv.Close()
+	prev := oauthLoginProviders["google"]
+	cfg := prev
+	cfg.TokenURL = tokenSrv.URL
+	oauthLoginProviders["google"] = cfg
+	t.Cleanup(func() { oauthLoginProviders["google"] = prev })
+
+	start := func() (state string, cookie *http.Cookie) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handleUserOAuth(rec, httptest.NewRequest(http.MethodGet, "/user/oauth/google/start?redirect=/user/", nil))
+		loc, err := url.Parse(rec.Header().Get("Location"))
+		if rec.Code != http.StatusFound || err != nil {
+			t.Fatalf("start: status %d, location %q", rec.Code, rec.Header().Get("Location"))
+		}
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == oauthStateCookie {
+				cookie = c
+			}
+		}
+		state = loc.Query().Get("state")
+		if cookie == nil || !cookie.HttpOnly || cookie.Value != state || cookie.MaxAge <= 0 || cookie.MaxAge > int(oauthStateTTL.Seconds()) {
+			t.Fatalf("state cookie = %+v, state %q", cookie, state)
+		}
+		return state, cookie
+	}
+	callback := func(state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/user/oauth/google/callback?code=abc&state="+url.QueryEscape(state), nil)
+		if cookie != nil {
+			req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+		rec := httptest.NewRecorder()
+		handleUserOAuth(rec, req)
+		if loc := rec.Header().Get("Location"); loc != "/user/login?error=oauth_failed" {
+			t.Fatalf("callback redirect = %q", loc)
+		}
+		return rec
+	}
+
+	// A state presented without the browser's cookie is refused, and spent.
+	state, cookie := start()
+	callback(state, nil)
+	callback(state, cookie)
+	if n := atomic.LoadInt32(&exchanges); n != 0 {
+		t.Fatalf("state without cookie reached the token exchange %d time(s)", n)
+	}
+
+	// Another browser's cookie does not match.
+	state, _ = start()
+	_, otherCookie := start()
+	callback(state, otherCookie)
+	if n := atomic.LoadInt32(&exchanges); n != 0 {
+		t.Fatalf("mismatched cookie reached the token exchange %d time(s)", n)
+	}
+
+	// The matching pair is accepted once; a replay is refused and the cookie cleared.
+	state, cookie = start()
+	rec := callback(state, cookie)
+	if n := atomic.LoadInt32(&exchanges); n != 1 {
+		t.Fatalf("valid state: %d token exchanges, want 1", n)
+	}
+	cleared := false
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oauthStateCookie && c.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Error("callback did not clear the state cookie")
+	}
+	callback(state, cookie)
+	if n := atomic.LoadInt32(&exchanges); n != 1 {
+		t.Errorf("replayed state reached the token exchange (%d exchanges)", n)
+	}
+}
+
+func TestResolveOAuthUserEmailCollision(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('initial_credits_balance', '50')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, credits_balance) VALUES (1, 'sn', 'alice', 'Alice', 'alice@example.com', 20)")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, is_blocked) VALUES (2, 'sn', 'bob', 'Bob', 'bob@example.com', 1)")
+	countUsers := func() int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n)
+		return n
+	}
+
+	// An unverified address that already has an account is not linked.
+	if id, errKey := resolveOAuthUser("google", oauthProfile{ID: "g-1", Email: "alice@example.com", Name: "Mallory"}); id != 0 || errKey != "oauth_email_unverified" {
+		t.Fatalf("unverified collision = %d, %q", id, errKey)
+	}
+	if n := countUsers(); n != 2 {
+		t.Fatalf("unverified collision created a user (%d users)", n)
+	}
+
+	// A verified address joins the existing wallet without initial credits.
+	id, errKey := resolveOAuthUser("google", oauthProfile{ID: "g-2", Email: "alice@example.com", EmailVerified: true, Name: "Alice G"})
+	if id == 0 || errKey != "" {
+		t.Fatalf("verified collision = %d, %q", id, errKey)
+	}
+	var email string
+	var balance float64
+	db.QueryRow("SELECT email, credits_balance FROM users WHERE id = ?", id).Scan(&email, &balance)
+	var initialTx int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = ? AND transaction_type = 'initial'", id).Scan(&initialTx)
+	if email != "alice@example.com" || balance != 0 || initialTx != 0 {
+		t.Errorf("linked user: email %q, balance %v, initial transactions %d", email, balance, initialTx)
+	}
+	if again, errKey := resolveOAuthUser("google", oauthProfile{ID: "g-2", Email: "alice@example.com", EmailVerified: true}); again != id || errKey != "" {
+		t.Errorf("second login = %d, %q, want %d", again, errKey, id)
+	}
+
+	// An address whose only accounts are blocked cannot be linked.
+	if id, errKey := resolveOAuthUser("github", oauthProfile{ID: "h-1", Email: "bob@example.com", EmailVerified: true}); id != 0 || errKey != "blocked" {
+		t.Errorf("blocked collision = %d, %q", id, errKey)
+	}
+
+	// A new address gets a fresh account with the initial credits.
+	id, errKey = resolveOAuthUser("github", oauthProfile{ID: "h-2", Email: "carol@example.com", EmailVerified: true})
+	db.QueryRow("SELECT credits_balance FROM users WHERE id = ?", id).Scan(&balance)
+	if id == 0 || errKey != "" || balance != 50 {
+		t.Errorf("new user = %d, %q, balance %v", id, errKey, balance)
+	}
+}
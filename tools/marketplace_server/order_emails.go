@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"marketplace_server/i18n"
+)
+
+// userLang returns the language to use for messages sent to userID outside of
+// a request: the language recorded from the user's last visit, else the site
+// default.
+func userLang(userID int64) i18n.Lang {
+	var stored string
+	db.QueryRow("SELECT COALESCE(preferred_lang, '') FROM users WHERE id = ?", userID).Scan(&stored)
+	if lang := i18n.ParseLang(stored); lang != "" {
+		return lang
+	}
+	if i18n.DefaultLang != "" {
+		return i18n.DefaultLang
+	}
+	return i18n.ZhCN
+}
+
+// rememberUserLang records lang as userID's preferred language.
+func rememberUserLang(userID int64, lang i18n.Lang) {
+	if userID <= 0 || lang == "" {
+		return
+	}
+	if _, err := db.Exec("UPDATE users SET preferred_lang = ? WHERE id = ? AND COALESCE(preferred_lang, '') != ?", string(lang), userID, string(lang)); err != nil {
+		log.Printf("[USER-LANG] failed to store language for user %d: %v", userID, err)
+	}
+}
+
+// sendOrderStatusEmail emails the buyer of a custom product order about its
+// current status ('fulfilled' or 'failed'). Buyers with email_allowed=0 or no
+// email are skipped. The recipient of a fulfilled gift order is notified as
+// well. Errors are logged only; the order itself is unaffected.
+func sendOrderStatusEmail(orderID int64) {
+	var userID int64
+	var status, licenseSN, licenseEmail, recipientEmail, productName, productType, currency string
+	var creditsAmount int
+	var amount float64
+	err := db.QueryRow(`SELECT o.user_id, o.status, COALESCE(o.license_sn, ''), COALESCE(o.license_email, ''), COALESCE(o.recipient_email, ''),
+		COALESCE(o.charged_amount, o.amount_usd), COALESCE(o.charged_currency, 'USD'),
+		COALESCE(p.product_name, ''), COALESCE(p.product_type, ''), `+orderCreditsSQL+`
+		FROM custom_product_orders o LEFT JOIN custom_products p ON p.id = o.custom_product_id
+		WHERE o.id = ?`, orderID).Scan(&userID, &status, &licenseSN, &licenseEmail, &recipientEmail, &amount, &currency, &productName, &productType, &creditsAmount)
+	if err != nil {
+		log.Printf("[ORDER-EMAIL] failed to load order %d: %v", orderID, err)
+		return
+	}
+
+	var email string
+	var emailAllowed int
+	db.QueryRow("SELECT COALESCE(email, ''), COALESCE(email_allowed, 1) FROM users WHERE id = ?", userID).Scan(&email, &emailAllowed)
+	if status == "fulfilled" && recipientEmail != "" {
+		sendGiftNoticeEmail(orderID, userID, recipientEmail, email, productName, productType, creditsAmount, licenseSN)
+	}
+	if email == "" || emailAllowed == 0 {
+		log.Printf("[ORDER-EMAIL] skipping order %d: buyer %d has no email or has opted out", orderID, userID)
+		return
+	}
+
+	lang := userLang(userID)
+	var subject, body string
+	switch {
+	case status == "fulfilled" && productType == "credits" && recipientEmail != "":
+		subject = fmt.Sprintf(i18n.T(lang, "order_email_fulfilled_subject"), orderID)
+		body = fmt.Sprintf(i18n.T(lang, "order_email_gift_credits_body"), recipientEmail, productName, creditsAmount, orderID)
+	case status == "fulfilled" && productType == "credits":
+		subject = fmt.Sprintf(i18n.T(lang, "order_email_fulfilled_subject"), orderID)
+		body = fmt.Sprintf(i18n.T(lang, "order_email_credits_body"), productName, creditsAmount, orderID)
+	case status == "fulfilled":
+		subject = fmt.Sprintf(i18n.T(lang, "order_email_fulfilled_subject"), orderID)
+		body = fmt.Sprintf(i18n.T(lang, "order_email_license_body"), productName, licenseSN, licenseEmail, orderID)
+	case status == "failed":
+		subject = fmt.Sprintf(i18n.T(lang, "order_email_failed_subject"), orderID)
+		body = fmt.Sprintf(i18n.T(lang, "order_email_failed_body"), productName, i18n.FormatMoney(amount, currency, lang), orderID)
+	default:
+		return
+	}
+
+	if err := sendSystemEmail(email, subject, body); err != nil {
+		log.Printf("[ORDER-EMAIL] failed to send %s email for order %d to %q: %v", status, orderID, email, err)
+		return
+	}
+	log.Printf("[ORDER-EMAIL] sent %s email for order %d to %q", status, orderID, email)
+}
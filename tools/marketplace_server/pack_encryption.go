@@ -225,3 +225,37 @@ func injectListingIDIntoQAP(zipData []byte, listingID int64) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// serverDecryptPackJSON reverses serverEncryptPackJSON.
+func serverDecryptPackJSON(data []byte, password string) ([]byte, error) {
+	headerLen := len(serverEncryptionMagic) + serverSaltLen
+	if len(data) < headerLen || string(data[:len(serverEncryptionMagic)]) != serverEncryptionMagic {
+		return nil, fmt.Errorf("not an encrypted pack")
+	}
+	salt := data[len(serverEncryptionMagic):headerLen]
+
+	key, err := scrypt.Key([]byte(password), salt, serverScryptN, serverScryptR, serverScryptP, serverScryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create GCM: %w", err)
+	}
+
+	if len(data) < headerLen+gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted pack is truncated")
+	}
+	nonce := data[headerLen : headerLen+gcm.NonceSize()]
+	plain, err := gcm.Open(nil, nonce, data[headerLen+gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return plain, nil
+}
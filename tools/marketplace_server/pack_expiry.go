@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time-limited packs (valid_days > 0, outside the subscription and per_use
+// models) grant access for valid_days from the purchase. Each 'renew'
+// transaction adds another valid_days, counted from the current expiry or
+// from the renewal itself if access had already lapsed. Downloads keep working
+// for time_limited_grace_days after expiry and are refused afterwards until
+// the buyer renews.
+const defaultTimeLimitedGraceDays = 3
+
+// isTimeLimitedPack reports whether access to a pack expires after valid_days.
+func isTimeLimitedPack(shareMode string, validDays int) bool {
+	if validDays <= 0 {
+		return false
+	}
+	switch shareMode {
+	case "free", "per_use", "subscription":
+		return false
+	}
+	return true
+}
+
+// timeLimitedGraceDays returns how long downloads are allowed after expiry.
+func timeLimitedGraceDays() int {
+	if n, err := strconv.Atoi(getSetting("time_limited_grace_days")); err == nil && n >= 0 {
+		return n
+	}
+	return defaultTimeLimitedGraceDays
+}
+
+// parseTxTime parses a credits_transactions/user_purchased_packs timestamp.
+func parseTxTime(s string) (time.Time, bool) {
+	if t, err := time.Parse("2006-01-02 15:04:05", s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05Z", s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// timeLimitedExpiry returns when the user's access to a time-limited pack
+// ends. purchased is false when the user never bought it.
+func timeLimitedExpiry(userID, listingID int64, validDays int) (expiry time.Time, purchased bool) {
+	var purchaseDate sql.NullString
+	db.QueryRow(`SELECT MIN(created_at) FROM credits_transactions
+		WHERE user_id = ? AND listing_id = ? AND transaction_type IN ('purchase', 'download')`, userID, listingID).Scan(&purchaseDate)
+	if !purchaseDate.Valid || purchaseDate.String == "" {
+		db.QueryRow(`SELECT created_at FROM user_purchased_packs WHERE user_id = ? AND listing_id = ?`, userID, listingID).Scan(&purchaseDate)
+	}
+	base, ok := parseTxTime(purchaseDate.String)
+	if !ok {
+		return time.Time{}, false
+	}
+	expiry = base.AddDate(0, 0, validDays)
+
+	rows, err := db.Query(`SELECT created_at FROM credits_transactions
+		WHERE user_id = ? AND listing_id = ? AND transaction_type = 'renew' ORDER BY created_at ASC`, userID, listingID)
+	if err != nil {
+		log.Printf("[PACK-EXPIRY] failed to query renewals (user=%d, listing=%d): %v", userID, listingID, err)
+		return expiry, true
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var createdAt string
+		if err := rows.Scan(&createdAt); err != nil {
+			continue
+		}
+		renewedAt, ok := parseTxTime(createdAt)
+		if !ok {
+			continue
+		}
+		if renewedAt.After(expiry) {
+			expiry = renewedAt
+		}
+		expiry = expiry.AddDate(0, 0, validDays)
+	}
+	return expiry, true
+}
+
+// timeLimitedAccessEnded reports whether downloads of a pack that expired at
+// expiry are refused at now.
+func timeLimitedAccessEnded(expiry, now time.Time) bool {
+	return !now.Before(expiry.AddDate(0, 0, timeLimitedGraceDays()))
+}
+
+// subscriptionExpiry returns when the user's credits subscription to a pack
+// ends: valid_days (30 if unset) from the first purchase, extended by each
+// 'renew' transaction by the months named in its description. purchased is
+// false when the user never bought it.
+func subscriptionExpiry(userID, listingID int64) (expiry time.Time, purchased bool) {
+	var purchaseDate sql.NullString
+	db.QueryRow(`SELECT MIN(created_at) FROM credits_transactions
+		WHERE user_id = ? AND listing_id = ? AND transaction_type IN ('purchase', 'download', 'purchase_uses', 'renew_subscription', 'renew')`,
+		userID, listingID).Scan(&purchaseDate)
+	if !purchaseDate.Valid || purchaseDate.String == "" {
+		db.QueryRow(`SELECT created_at FROM user_purchased_packs WHERE user_id = ? AND listing_id = ?`, userID, listingID).Scan(&purchaseDate)
+	}
+	base, ok := parseTxTime(purchaseDate.String)
+	if !ok {
+		return time.Time{}, false
+	}
+	var validDays int
+	db.QueryRow("SELECT COALESCE(valid_days, 0) FROM pack_listings WHERE id = ?", listingID).Scan(&validDays)
+	if validDays == 0 {
+		validDays = 30
+	}
+	expiry = base.AddDate(0, 0, validDays)
+
+	rows, err := db.Query(`SELECT created_at, COALESCE(description, '') FROM credits_transactions
+		WHERE user_id = ? AND listing_id = ? AND transaction_type = 'renew' ORDER BY created_at ASC`, userID, listingID)
+	if err != nil {
+		log.Printf("[PACK-EXPIRY] failed to query renewals (user=%d, listing=%d): %v", userID, listingID, err)
+		return expiry, true
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var createdAt, desc string
+		if err := rows.Scan(&createdAt, &desc); err != nil {
+			continue
+		}
+		renewedAt, ok := parseTxTime(createdAt)
+		if !ok {
+			continue
+		}
+		months := 1
+		if strings.Contains(desc, "yearly") || strings.Contains(desc, "14 month") {
+			months = 14
+		} else if strings.Contains(desc, "12 month") {
+			months = 12
+		}
+		if renewedAt.After(expiry) {
+			expiry = renewedAt
+		}
+		expiry = expiry.AddDate(0, months, 0)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("[PACK-EXPIRY] failed to read renewals (user=%d, listing=%d): %v", userID, listingID, err)
+	}
+	return expiry, true
+}
+
+// packSubscriptionActive reports whether the user's subscription to a pack
+// grants downloads at now, either through credits renewals or through a
+// PayPal auto-renewal, which keeps access through its grace period even when
+// the latest renewal has not been billed yet.
+func packSubscriptionActive(userID, listingID int64, now time.Time) bool {
+	if expiry, ok := subscriptionExpiry(userID, listingID); ok && now.Before(expiry) {
+		return true
+	}
+	return now.Before(packSubscriptionAccessUntil(userID, listingID))
+}
+
+// handleUserRenewAccess extends a time-limited pack by another valid_days.
+// POST /user/pack/renew-access
+// Form params: listing_id
+func handleUserRenewAccess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/user/", http.StatusFound)
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	listingID, err := strconv.ParseInt(r.FormValue("listing_id"), 10, 64)
+	if err != nil || listingID <= 0 {
+		http.Redirect(w, r, "/user/?error=invalid_listing", http.StatusFound)
+		return
+	}
+
+	var shareMode, packName string
+	var creditsPrice, validDays int
+	err = db.QueryRow(`SELECT share_mode, credits_price, pack_name, COALESCE(valid_days, 0)
+		FROM pack_listings WHERE id = ? AND status = 'published'`, listingID).Scan(&shareMode, &creditsPrice, &packName, &validDays)
+	if err == sql.ErrNoRows {
+		http.Redirect(w, r, "/user/?error=pack_not_found", http.StatusFound)
+		return
+	} else if err != nil {
+		log.Printf("[USER-RENEW-ACCESS] failed to query pack listing %d: %v", listingID, err)
+		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
+		return
+	}
+	creditsPrice = packPriceAt(listingID, creditsPrice, time.Now())
+	if !isTimeLimitedPack(shareMode, validDays) {
+		http.Redirect(w, r, "/user/?error=not_time_limited", http.StatusFound)
+		return
+	}
+	if _, purchased := timeLimitedExpiry(userID, listingID, validDays); !purchased {
+		http.Redirect(w, r, "/user/?error=pack_not_found", http.StatusFound)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[USER-RENEW-ACCESS] failed to begin transaction: %v", err)
+		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
+		return
+	}
+	defer tx.Rollback()
+
+	rowsAffected, err := deductWalletBalance(tx, userID, float64(creditsPrice))
+	if err != nil {
+		log.Printf("[USER-RENEW-ACCESS] failed to deduct credits: %v", err)
+		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
+		return
+	}
+	if rowsAffected == 0 {
+		http.Redirect(w, r, "/user/?error=insufficient_credits", http.StatusFound)
+		return
+	}
+	if _, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, description)
+		VALUES (?, 'renew', ?, ?, ?)`,
+		userID, -float64(creditsPrice), listingID, fmt.Sprintf("Renew access (%d days): %s", validDays, packName)); err != nil {
+		log.Printf("[USER-RENEW-ACCESS] failed to record transaction: %v", err)
+		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[USER-RENEW-ACCESS] failed to commit transaction: %v", err)
+		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
+		return
+	}
+	publishPackSaleEvent(listingID, "renew", float64(creditsPrice))
+
+	if err := upsertUserPurchasedPack(userID, listingID); err != nil {
+		log.Printf("[USER-RENEW-ACCESS] failed to upsert user purchased pack (user=%d, listing=%d): %v", userID, listingID, err)
+	}
+	globalCache.InvalidateUserPurchased(userID)
+	log.Printf("[USER-RENEW-ACCESS] user %d renewed pack %d (%s) for %d days, cost=%d", userID, listingID, packName, validDays, creditsPrice)
+	http.Redirect(w, r, "/user/?success=renew_access", http.StatusFound)
+}
+
+// handleAdminTimeLimitedSettings handles GET/POST /admin/settings/time-limited.
+func handleAdminTimeLimitedSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"grace_days": timeLimitedGraceDays()})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	grace, err := strconv.Atoi(r.FormValue("grace_days"))
+	if err != nil || grace < 0 || grace > 60 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "宽限天数必须在 0 到 60 之间"})
+		return
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('time_limited_grace_days', ?)", strconv.Itoa(grace)); err != nil {
+		log.Printf("[ADMIN] failed to save time_limited_grace_days: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeLimitedPackExpiryAndRenewal(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	ts := func(d time.Duration) string { return time.Now().UTC().Add(d).Format("2006-01-02 15:04:05") }
+	day := 24 * time.Hour
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, credits_balance) VALUES (2, 'sn', 'alice', 'Alice', 100)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status, encryption_password, valid_days) VALUES (100, 1, 1, x'00', 'Pack', 'time_limited', 10, 'published', '', 30)")
+	mustExec(t, "INSERT INTO settings (key, value) VALUES ('time_limited_grace_days', '3')")
+	// Bought 32 days ago: expired 2 days ago, still inside the 3-day grace period.
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, created_at) VALUES (2, 'download', -10, 100, ?)", ts(-32*day))
+
+	download := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/packs/100/download", nil)
+		req.Header.Set("X-User-ID", "2")
+		rec := httptest.NewRecorder()
+		handleDownloadPack(rec, req)
+		return rec.Code
+	}
+	if code := download(); code != http.StatusOK {
+		t.Fatalf("within grace: status %d, want 200", code)
+	}
+	if getWalletBalance(2) != 100 {
+		t.Fatalf("re-download within grace charged credits")
+	}
+
+	// Two more days pass: the grace period is over.
+	mustExec(t, "UPDATE credits_transactions SET created_at = ? WHERE transaction_type = 'download'", ts(-34*day))
+	if code := download(); code != http.StatusForbidden {
+		t.Fatalf("after grace: status %d, want 403", code)
+	}
+
+	// Renewing restarts the window from the renewal date.
+	req := httptest.NewRequest(http.MethodPost, "/user/pack/renew-access?listing_id=100", nil)
+	req.Header.Set("X-User-ID", "2")
+	handleUserRenewAccess(httptest.NewRecorder(), req)
+	var renewals int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE user_id = 2 AND transaction_type = 'renew'").Scan(&renewals)
+	if renewals != 1 || getWalletBalance(2) != 90 {
+		t.Fatalf("renewal: %d renew transactions, balance %v", renewals, getWalletBalance(2))
+	}
+	expiry, _ := timeLimitedExpiry(2, 100, 30)
+	if d := time.Until(expiry); d < 29*day || d > 31*day {
+		t.Errorf("expiry after renewal is %v away, want ~30 days", d)
+	}
+	if code := download(); code != http.StatusOK {
+		t.Errorf("after renewal: status %d, want 200", code)
+	}
+}
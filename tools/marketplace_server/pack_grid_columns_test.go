@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestPackGridMobileColumns(t *testing.T) {
+	layout := func(settings string) string {
+		return `{"sections":[{"type":"hero","visible":true,"settings":{}},{"type":"pack_grid","visible":true,"settings":` + settings + `}]}`
+	}
+	for settings, wantErr := range map[string]bool{
+		`{"columns":3}`:                     false,
+		`{"columns":3,"mobile_columns":2}`:  false,
+		`{"columns":2,"mobile_columns":3}`:  true,
+		`{"columns":2,"mobile_columns":-1}`: true,
+	} {
+		if got := ValidateLayoutConfig(layout(settings)) != ""; got != wantErr {
+			t.Errorf("ValidateLayoutConfig(%s) error = %v, want %v", settings, got, wantErr)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Pack screenshot limits. Screenshots go through the same validation and
+// downscaling as banner images.
+const (
+	maxPackImages    = 6
+	maxPackImageSize = 2 * 1024 * 1024 // 2MB
+)
+
+// PackImage is one screenshot in a pack's gallery.
+type PackImage struct {
+	ID        int64  `json:"id"`
+	SortOrder int    `json:"sort_order"`
+	URL       string `json:"url"`
+}
+
+// packImageURL is where handlePackImage serves an image.
+func packImageURL(imageID int64) string {
+	return fmt.Sprintf("/pack-image/%d", imageID)
+}
+
+// queryPackImages returns a listing's screenshots in gallery order.
+func queryPackImages(listingID int64) ([]PackImage, error) {
+	rows, err := db.Query("SELECT id, sort_order FROM pack_images WHERE listing_id = ? ORDER BY sort_order, id", listingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var images []PackImage
+	for rows.Next() {
+		var img PackImage
+		if err := rows.Scan(&img.ID, &img.SortOrder); err != nil {
+			return nil, err
+		}
+		img.URL = packImageURL(img.ID)
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+// deletePackImages removes all screenshots of a deleted listing.
+func deletePackImages(listingID int64) {
+	if _, err := db.Exec("DELETE FROM pack_images WHERE listing_id = ?", listingID); err != nil {
+		log.Printf("[PACK-IMAGES] failed to clean up images for listing %d: %v", listingID, err)
+	}
+}
+
+// invalidatePackImagesCache drops the cached detail page showing the gallery.
+func invalidatePackImagesCache(listingID int64) {
+	var shareToken string
+	if err := db.QueryRow("SELECT COALESCE(share_token, '') FROM pack_listings WHERE id = ?", listingID).Scan(&shareToken); err == nil && shareToken != "" {
+		globalCache.InvalidatePackDetail(shareToken)
+	}
+}
+
+// authorOwnsListing reports whether listingID belongs to userID.
+func authorOwnsListing(userID, listingID int64) bool {
+	var ownerID int64
+	err := db.QueryRow("SELECT user_id FROM pack_listings WHERE id = ?", listingID).Scan(&ownerID)
+	return err == nil && ownerID == userID
+}
+
+// handleAuthorPackImages handles /user/author/pack-images for the pack owner:
+// GET ?listing_id= lists the screenshots, POST (multipart listing_id + image)
+// uploads one.
+func handleAuthorPackImages(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[PACK-IMAGES] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseMultipartForm(maxPackImageSize); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片大小不能超过 2MB"})
+			return
+		}
+	} else if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	listingID, err := strconv.ParseInt(r.FormValue("listing_id"), 10, 64)
+	if err != nil || listingID <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的分析包ID"})
+		return
+	}
+	if !authorOwnsListing(userID, listingID) {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		images, err := queryPackImages(listingID)
+		if err != nil {
+			log.Printf("[PACK-IMAGES] failed to list images for listing %d: %v", listingID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "加载失败"})
+			return
+		}
+		if images == nil {
+			images = []PackImage{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "images": images, "max_images": maxPackImages})
+		return
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请选择要上传的图片"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxPackImageSize {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片大小不能超过 2MB"})
+		return
+	}
+	fileData, err := io.ReadAll(io.LimitReader(file, maxPackImageSize+1))
+	if err != nil {
+		log.Printf("[PACK-IMAGES] failed to read file for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "读取文件失败"})
+		return
+	}
+	if int64(len(fileData)) > maxPackImageSize {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片大小不能超过 2MB"})
+		return
+	}
+	if !isAllowedLogoContentType(detectImageContentType(fileData)) {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "仅支持 PNG、JPEG 或 WebP 格式"})
+		return
+	}
+	imageData, contentType, err := processBannerImage(fileData)
+	if err != nil {
+		log.Printf("[PACK-IMAGES] failed to process image for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "图片无法解析，请上传有效的图片文件"})
+		return
+	}
+
+	var imageCount, maxSort int
+	db.QueryRow("SELECT COUNT(*), COALESCE(MAX(sort_order), 0) FROM pack_images WHERE listing_id = ?", listingID).Scan(&imageCount, &maxSort)
+	if imageCount >= maxPackImages {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("每个分析包最多上传 %d 张截图", maxPackImages)})
+		return
+	}
+
+	result, err := db.Exec("INSERT INTO pack_images (listing_id, image_data, content_type, sort_order) VALUES (?, ?, ?, ?)",
+		listingID, imageData, contentType, maxSort+1)
+	if err != nil {
+		log.Printf("[PACK-IMAGES] failed to insert image for listing %d: %v", listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+	imageID, _ := result.LastInsertId()
+	invalidatePackImagesCache(listingID)
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "image": PackImage{ID: imageID, SortOrder: maxSort + 1, URL: packImageURL(imageID)}})
+}
+
+// handleAuthorPackImagesReorder handles POST /user/author/pack-images/reorder
+// with a JSON body {"listing_id": N, "ids": [...]} giving the new order.
+func handleAuthorPackImagesReorder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	var reqBody struct {
+		ListingID int64   `json:"listing_id"`
+		IDs       []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.ListingID <= 0 || len(reqBody.IDs) == 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "参数无效"})
+		return
+	}
+	if !authorOwnsListing(userID, reqBody.ListingID) {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "该分析包不属于当前作者"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[PACK-IMAGES] failed to begin transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "操作失败"})
+		return
+	}
+	defer tx.Rollback()
+	for i, id := range reqBody.IDs {
+		if _, err := tx.Exec("UPDATE pack_images SET sort_order = ? WHERE id = ? AND listing_id = ?", i+1, id, reqBody.ListingID); err != nil {
+			log.Printf("[PACK-IMAGES] failed to reorder image %d of listing %d: %v", id, reqBody.ListingID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "操作失败"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[PACK-IMAGES] failed to commit reorder for listing %d: %v", reqBody.ListingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "操作失败"})
+		return
+	}
+	invalidatePackImagesCache(reqBody.ListingID)
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// handleAuthorPackImagesDelete handles POST /user/author/pack-images/delete
+// with form value image_id.
+func handleAuthorPackImagesDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+	imageID, err := strconv.ParseInt(r.FormValue("image_id"), 10, 64)
+	if err != nil || imageID <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的图片ID"})
+		return
+	}
+
+	var listingID int64
+	err = db.QueryRow(`SELECT pi.listing_id FROM pack_images pi
+		JOIN pack_listings pl ON pl.id = pi.listing_id
+		WHERE pi.id = ? AND pl.user_id = ?`, imageID, userID).Scan(&listingID)
+	if err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "图片不存在"})
+		return
+	}
+	if _, err := db.Exec("DELETE FROM pack_images WHERE id = ?", imageID); err != nil {
+		log.Printf("[PACK-IMAGES] failed to delete image %d: %v", imageID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "删除失败"})
+		return
+	}
+	invalidatePackImagesCache(listingID)
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// handlePackImage serves GET /pack-image/{id}. Screenshots of published packs
+// are public and cacheable; the owner can also see them before publication.
+// Image IDs are never reused, so a changed gallery always yields new URLs.
+func handlePackImage(w http.ResponseWriter, r *http.Request) {
+	imageID, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/pack-image/"), 10, 64)
+	if err != nil || imageID <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var imageData []byte
+	var contentType, status string
+	var ownerID int64
+	err = db.QueryRow(`SELECT pi.image_data, COALESCE(pi.content_type, ''), pl.status, pl.user_id
+		FROM pack_images pi JOIN pack_listings pl ON pl.id = pi.listing_id
+		WHERE pi.id = ?`, imageID).Scan(&imageData, &contentType, &status, &ownerID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[PACK-IMAGES] failed to load image %d: %v", imageID, err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	cacheControl := "public, max-age=86400"
+	if status != "published" {
+		cookie, cookieErr := r.Cookie("user_session")
+		if cookieErr != nil || getUserSessionUserID(cookie.Value) != ownerID {
+			http.NotFound(w, r)
+			return
+		}
+		cacheControl = "private, no-cache"
+	}
+
+	etag := fmt.Sprintf(`"pimg-%d"`, imageID)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if contentType == "" {
+		contentType = "image/png"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(imageData)))
+	w.Write(imageData)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func uploadTestPackImage(t *testing.T, userID string, listingID int64) *httptest.ResponseRecorder {
+	t.Helper()
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("listing_id", fmt.Sprint(listingID))
+	fw, _ := mw.CreateFormFile("image", "shot.png")
+	fw.Write(img.Bytes())
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/user/author/pack-images", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-User-ID", userID)
+	rec := httptest.NewRecorder()
+	handleAuthorPackImages(rec, req)
+	return rec
+}
+
+func TestPackImagesLifecycle(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	if _, err := db.Exec("INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, share_token) VALUES (100, 1, 1, x'00', 'Pack', 'free', 'published', 'tok100')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := uploadTestPackImage(t, "2", 100); rec.Code != http.StatusForbidden {
+		t.Fatalf("upload by non-owner: status %d, want 403", rec.Code)
+	}
+	var ids []int64
+	for i := 0; i < maxPackImages; i++ {
+		rec := uploadTestPackImage(t, "1", 100)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("upload %d: status %d: %s", i, rec.Code, rec.Body.String())
+		}
+		var resp struct{ Image PackImage }
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		ids = append(ids, resp.Image.ID)
+	}
+	if rec := uploadTestPackImage(t, "1", 100); rec.Code != http.StatusBadRequest {
+		t.Fatalf("upload over limit: status %d, want 400", rec.Code)
+	}
+
+	// Move the last screenshot to the front.
+	order := append([]int64{ids[len(ids)-1]}, ids[:len(ids)-1]...)
+	payload, _ := json.Marshal(map[string]interface{}{"listing_id": 100, "ids": order})
+	req := httptest.NewRequest(http.MethodPost, "/user/author/pack-images/reorder", bytes.NewReader(payload))
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleAuthorPackImagesReorder(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reorder: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handlePackDetailPage(rec, httptest.NewRequest(http.MethodGet, "/pack/tok100", nil))
+	first := fmt.Sprintf(`id="galleryMain" src="%s"`, packImageURL(order[0]))
+	if !strings.Contains(rec.Body.String(), first) {
+		t.Errorf("gallery does not start with the reordered screenshot")
+	}
+
+	rec = httptest.NewRecorder()
+	handlePackImage(rec, httptest.NewRequest(http.MethodGet, packImageURL(ids[0]), nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || !strings.HasPrefix(rec.Header().Get("Cache-Control"), "public") {
+		t.Fatalf("serve: status %d, headers %v", rec.Code, rec.Header())
+	}
+	req = httptest.NewRequest(http.MethodGet, packImageURL(ids[0]), nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	handlePackImage(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional request: status %d, want 304", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/user/author/pack-images/delete", strings.NewReader(url.Values{"image_id": {fmt.Sprint(ids[0])}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-User-ID", "1")
+	rec = httptest.NewRecorder()
+	handleAuthorPackImagesDelete(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if images, _ := queryPackImages(100); len(images) != maxPackImages-1 {
+		t.Errorf("after delete: %d images, want %d", len(images), maxPackImages-1)
+	}
+
+	deletePackImages(100)
+	if images, _ := queryPackImages(100); len(images) != 0 {
+		t.Errorf("after listing cleanup: %d images remain", len(images))
+	}
+}
+
+func TestPackImageHiddenUntilPublished(t *testing.T) {
+	useTestDB(t)
+
+	if _, err := db.Exec("INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 1, 1, x'00', 'Pack', 'free', 'pending')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO pack_images (id, listing_id, image_data, content_type) VALUES (7, 100, x'00', 'image/png')"); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handlePackImage(rec, httptest.NewRequest(http.MethodGet, packImageURL(7), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unpublished image served anonymously: status %d", rec.Code)
+	}
+}
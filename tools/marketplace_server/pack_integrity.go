@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+)
+
+// packFileSHA256 returns the hex SHA-256 of a pack file as stored in
+// pack_listings.file_sha256 and sent in the X-Content-SHA256 header.
+func packFileSHA256(fileData []byte) string {
+	sum := sha256.Sum256(fileData)
+	return hex.EncodeToString(sum[:])
+}
+
+// backfillPackFileHashes computes file_sha256 for existing pack_listings rows
+// that lack one. Files are loaded one at a time to bound memory use. Only
+// files still in file_data need it: externally stored files are hashed when
+// they are written.
+func backfillPackFileHashes(database *sql.DB) {
+	rows, err := database.Query("SELECT id FROM pack_listings WHERE (file_sha256 IS NULL OR file_sha256 = '') AND COALESCE(file_key, '') = ''")
+	if err != nil {
+		log.Printf("[BACKFILL] failed to query rows without file_sha256: %v", err)
+		return
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("[BACKFILL] rows iteration error: %v", err)
+	}
+	for _, id := range ids {
+		var fileData []byte
+		if err := database.QueryRow("SELECT file_data FROM pack_listings WHERE id = ?", id).Scan(&fileData); err != nil {
+			log.Printf("[BACKFILL] failed to read file_data for id=%d: %v", id, err)
+			continue
+		}
+		if _, err := database.Exec("UPDATE pack_listings SET file_sha256 = ? WHERE id = ?", packFileSHA256(fileData), id); err != nil {
+			log.Printf("[BACKFILL] failed to set file_sha256 for id=%d: %v", id, err)
+		}
+	}
+	if len(ids) > 0 {
+		log.Printf("[BACKFILL] computed file_sha256 for %d existing pack_listings", len(ids))
+	}
+}
+
+// hashPackFile streams a stored pack file through SHA-256.
+func hashPackFile(ref packFileRef) (string, error) {
+	file, _, err := openPackFile(ref)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PackIntegrityMismatch is a pack whose stored file no longer matches the
+// hash recorded when it was uploaded.
+type PackIntegrityMismatch struct {
+	ListingID    int64  `json:"listing_id"`
+	PackName     string `json:"pack_name"`
+	StoredSHA256 string `json:"stored_sha256"`
+	ActualSHA256 string `json:"actual_sha256"`
+}
+
+// verifyPackIntegrity re-hashes the files of the given listings, or of all
+// listings when ids is empty, and reports those that differ from the stored
+// hash. Listings without a stored hash are counted as unhashed.
+func verifyPackIntegrity(ids []int64) (checked, unhashed int, mismatches []PackIntegrityMismatch, err error) {
+	if len(ids) == 0 {
+		rows, err := db.Query("SELECT id FROM pack_listings ORDER BY id")
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	mismatches = []PackIntegrityMismatch{}
+	for _, id := range ids {
+		var packName, stored string
+		var ref packFileRef
+		err := db.QueryRow("SELECT pack_name, COALESCE(file_sha256, ''), file_data, COALESCE(file_storage, ''), COALESCE(file_key, '') FROM pack_listings WHERE id = ?", id).
+			Scan(&packName, &stored, &ref.Data, &ref.Storage, &ref.Key)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return checked, unhashed, mismatches, err
+		}
+		checked++
+		if stored == "" {
+			unhashed++
+			continue
+		}
+		actual, err := hashPackFile(ref)
+		if err != nil {
+			// A missing or unreadable file is reported like a corrupted one.
+			log.Printf("[PACK-INTEGRITY] listing %d file unreadable: %v", id, err)
+			actual = ""
+		}
+		if actual != stored {
+			log.Printf("[PACK-INTEGRITY] listing %d file hash mismatch: stored=%s actual=%s", id, stored, actual)
+			mismatches = append(mismatches, PackIntegrityMismatch{
+				ListingID: id, PackName: packName, StoredSHA256: stored, ActualSHA256: actual,
+			})
+		}
+	}
+	return checked, unhashed, mismatches, nil
+}
+
+// handleAdminPackIntegrity handles POST /api/admin/pack-integrity with an
+// optional JSON body {"listing_ids": [1, 2]}. Without ids every pack is
+// checked.
+func handleAdminPackIntegrity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	var req struct {
+		ListingIDs []int64 `json:"listing_ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+	}
+
+	checked, unhashed, mismatches, err := verifyPackIntegrity(req.ListingIDs)
+	if err != nil {
+		log.Printf("[PACK-INTEGRITY] verification failed: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	log.Printf("[PACK-INTEGRITY] checked %d packs: %d mismatched, %d without hash", checked, len(mismatches), unhashed)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"checked":    checked,
+		"unhashed":   unhashed,
+		"mismatched": mismatches,
+	})
+}
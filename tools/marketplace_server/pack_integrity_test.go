@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPackIntegrity(t *testing.T) {
+	useTestDB(t)
+	for _, id := range []int64{100, 101, 102} {
+		if _, err := db.Exec("INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (?, 1, 1, ?, 'Pack', 'free', 'published')",
+			id, []byte("pack data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backfillPackFileHashes(db)
+
+	var stored string
+	db.QueryRow("SELECT file_sha256 FROM pack_listings WHERE id = 100").Scan(&stored)
+	if stored != packFileSHA256([]byte("pack data")) {
+		t.Fatalf("backfilled hash = %q", stored)
+	}
+
+	// Silent corruption of one file, and one row that lost its hash.
+	if _, err := db.Exec("UPDATE pack_listings SET file_data = ? WHERE id = 101", []byte("pack dat4")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE pack_listings SET file_sha256 = NULL WHERE id = 102"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/pack-integrity", strings.NewReader(""))
+	rec := httptest.NewRecorder()
+	handleAdminPackIntegrity(rec, req)
+	var resp struct {
+		Checked    int                     `json:"checked"`
+		Unhashed   int                     `json:"unhashed"`
+		Mismatched []PackIntegrityMismatch `json:"mismatched"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if resp.Checked != 3 || resp.Unhashed != 1 || len(resp.Mismatched) != 1 || resp.Mismatched[0].ListingID != 101 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	checked, _, mismatches, err := verifyPackIntegrity([]int64{100})
+	if err != nil || checked != 1 || len(mismatches) != 0 {
+		t.Errorf("verify single pack = %d, %v, %v", checked, mismatches, err)
+	}
+
+	rec = httptest.NewRecorder()
+	servePackFile(rec, "Pack", strings.NewReader("pack data"), 9, sql.NullString{}, "", stored)
+	if got := rec.Header().Get("X-Content-SHA256"); got != stored {
+		t.Errorf("X-Content-SHA256 = %q, want %q", got, stored)
+	}
+}
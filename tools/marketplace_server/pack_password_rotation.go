@@ -0,0 +1,201 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Encryption password rotation for paid packs. The stored file is decrypted
+// with the current password, re-encrypted under a fresh one and saved as a new
+// file; pack_listings is then switched to the new file, password and version
+// in one transaction. Until that commit the listing keeps pointing at the old
+// file and password, so a failure at any step leaves it fully usable, and the
+// new file is removed again. The same transaction revokes the pack's unused
+// download tokens. Copies downloaded before the rotation still open with the
+// password they were served with; only new downloads get the new one.
+
+var (
+	errPackNotEncrypted = errors.New("pack is not encrypted")
+	errPackChanged      = errors.New("pack changed during rotation")
+)
+
+// readPackJSON returns the pack.json entry of a .qap file.
+func readPackJSON(fileData []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(fileData), int64(len(fileData)))
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "pack.json" && f.Name != "analysis_pack.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", f.Name, err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("pack.json not found in ZIP")
+}
+
+// rotatePackPassword re-encrypts the file of listingID under a new password
+// and returns the listing's new version.
+func rotatePackPassword(listingID int64) (int, error) {
+	var oldRef packFileRef
+	var password string
+	var version int
+	err := db.QueryRow(`SELECT file_data, COALESCE(file_storage, ''), COALESCE(file_key, ''), COALESCE(encryption_password, ''), COALESCE(version, 1)
+		FROM pack_listings WHERE id = ?`, listingID).Scan(&oldRef.Data, &oldRef.Storage, &oldRef.Key, &password, &version)
+	if err != nil {
+		return 0, err
+	}
+	if password == "" {
+		return 0, errPackNotEncrypted
+	}
+
+	f, _, err := openPackFile(oldRef)
+	if err != nil {
+		return 0, fmt.Errorf("open pack file: %w", err)
+	}
+	fileData, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return 0, fmt.Errorf("read pack file: %w", err)
+	}
+	encrypted, err := readPackJSON(fileData)
+	if err != nil {
+		return 0, err
+	}
+	plain, err := serverDecryptPackJSON(encrypted, password)
+	if err != nil {
+		return 0, err
+	}
+	newPassword, err := generateSecurePassword()
+	if err != nil {
+		return 0, err
+	}
+	reencrypted, err := serverEncryptPackJSON(plain, newPassword)
+	if err != nil {
+		return 0, err
+	}
+	newData, err := repackZipWithEncryptedData(fileData, reencrypted)
+	if err != nil {
+		return 0, err
+	}
+
+	newRef, err := putPackFile(listingID, newData)
+	if err != nil {
+		return 0, fmt.Errorf("store pack file: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			deletePackFile(newRef)
+		}
+	}()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`UPDATE pack_listings SET file_data = ?, file_sha256 = ?, file_storage = ?, file_key = ?,
+			encryption_password = ?, version = ?
+		WHERE id = ? AND encryption_password = ? AND COALESCE(version, 1) = ?`,
+		newRef.Data, packFileSHA256(newData), newRef.Storage, newRef.Key, newPassword, version+1,
+		listingID, password, version)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, errPackChanged
+	}
+	if _, err := tx.Exec("DELETE FROM downloads_tokens WHERE listing_id = ? AND used_at IS NULL", listingID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	committed = true
+
+	deletePackFile(oldRef)
+	var shareToken string
+	if db.QueryRow("SELECT COALESCE(share_token, '') FROM pack_listings WHERE id = ?", listingID).Scan(&shareToken) == nil && shareToken != "" {
+		globalCache.InvalidatePackDetail(shareToken)
+	}
+	return version + 1, nil
+}
+
+// respondPackPasswordRotation runs the rotation and writes the JSON result.
+func respondPackPasswordRotation(w http.ResponseWriter, listingID int64) bool {
+	version, err := rotatePackPassword(listingID)
+	switch {
+	case err == nil:
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"status": "ok", "version": version})
+		return true
+	case err == sql.ErrNoRows:
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "pack not found"})
+	case errors.Is(err, errPackNotEncrypted):
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "pack_not_encrypted"})
+	case errors.Is(err, errPackChanged):
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "pack_changed"})
+	default:
+		log.Printf("[PACK-ROTATE] failed to rotate password of listing %d: %v", listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+	}
+	return false
+}
+
+// handleRotatePackPassword handles POST /api/packs/{id}/rotate-password for
+// the pack's author.
+func handleRotatePackPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	listingID, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/packs/"), "/rotate-password"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+		return
+	}
+	var ownerID int64
+	if err := db.QueryRow("SELECT user_id FROM pack_listings WHERE id = ?", listingID).Scan(&ownerID); err != nil || ownerID != userID {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "pack not found"})
+		return
+	}
+	if respondPackPasswordRotation(w, listingID) {
+		log.Printf("[PACK-ROTATE] user %d rotated the password of listing %d", userID, listingID)
+	}
+}
+
+// handleAdminRotatePackPassword handles
+// POST /api/admin/marketplace/{id}/rotate-password.
+func handleAdminRotatePackPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	listingID, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/admin/marketplace/"), "/rotate-password"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_id"})
+		return
+	}
+	if respondPackPasswordRotation(w, listingID) {
+		adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+		recordAuditLog(adminID, "pack_password_rotate", fmt.Sprintf("listing:%d", listingID), "", getClientIP(r))
+	}
+}
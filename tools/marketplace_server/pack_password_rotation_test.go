@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// seedEncryptedPack stores listing 100 as a paid pack encrypted with "old-pw"
+// in local storage under dir and returns the plain pack.json.
+func seedEncryptedPack(t *testing.T, dir string) []byte {
+	t.Helper()
+	globalCache = NewCache(CacheConfig{})
+	plain := []byte(`{"metadata":{"pack_name":"Paid"},"schema_requirements":[]}`)
+	encrypted, err := serverEncryptPackJSON(plain, "old-pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileData, err := repackZipWithEncryptedData(mustZip(t, map[string]string{"metadata.json": `{"pack_name":"Paid"}`}), encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('pack_storage_config', ?)`,
+		`{"backend":"local","local_dir":"`+dir+`"}`); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := putPackFile(100, fileData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'email', 'a', 'Author')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO pack_listings (id, user_id, category_id, file_data, file_storage, file_key, file_sha256, pack_name, share_mode, credits_price, status, encryption_password, version)
+		VALUES (100, 1, 1, ?, ?, ?, ?, 'Paid', 'per_use', 10, 'published', 'old-pw', 3)`,
+		ref.Data, ref.Storage, ref.Key, packFileSHA256(fileData)); err != nil {
+		t.Fatal(err)
+	}
+	return plain
+}
+
+func packFilesIn(t *testing.T, dir string) int {
+	t.Helper()
+	matches, _ := filepath.Glob(filepath.Join(dir, "packs", "100", "*.qap"))
+	return len(matches)
+}
+
+func TestRotatePackPassword(t *testing.T) {
+	useTestDB(t)
+	dir := t.TempDir()
+	plain := seedEncryptedPack(t, dir)
+	db.Exec("INSERT INTO downloads_tokens (token_hash, user_id, listing_id, expires_at) VALUES ('unused', 1, 100, '2999-01-01 00:00:00')")
+
+	version, err := rotatePackPassword(100)
+	if err != nil || version != 4 {
+		t.Fatalf("rotatePackPassword = %d, %v; want 4", version, err)
+	}
+
+	var ref packFileRef
+	var password, sha string
+	db.QueryRow("SELECT file_data, file_storage, file_key, encryption_password, file_sha256 FROM pack_listings WHERE id = 100").
+		Scan(&ref.Data, &ref.Storage, &ref.Key, &password, &sha)
+	if password == "old-pw" || password == "" {
+		t.Fatalf("password not rotated: %q", password)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref.Key)))
+	if err != nil {
+		t.Fatalf("read new file: %v", err)
+	}
+	if sha != packFileSHA256(data) {
+		t.Error("file_sha256 does not match the new file")
+	}
+	encrypted, err := readPackJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := serverDecryptPackJSON(encrypted, "old-pw"); err == nil {
+		t.Error("new file still opens with the old password")
+	}
+	got, err := serverDecryptPackJSON(encrypted, password)
+	if err != nil || string(got) != string(plain) {
+		t.Fatalf("decrypt with new password = %q, %v", got, err)
+	}
+	if n := packFilesIn(t, dir); n != 1 {
+		t.Errorf("%d pack files in storage, want only the new one", n)
+	}
+	var tokens int
+	db.QueryRow("SELECT COUNT(*) FROM downloads_tokens WHERE listing_id = 100").Scan(&tokens)
+	if tokens != 0 {
+		t.Errorf("%d download tokens left after rotation, want 0", tokens)
+	}
+
+	db.Exec("UPDATE pack_listings SET encryption_password = '' WHERE id = 100")
+	if _, err := rotatePackPassword(100); err != errPackNotEncrypted {
+		t.Errorf("unencrypted pack: err = %v, want errPackNotEncrypted", err)
+	}
+}
+
+func TestRotatePackPasswordRollsBackOnFailure(t *testing.T) {
+	useTestDB(t)
+	dir := t.TempDir()
+	seedEncryptedPack(t, dir)
+	var before packFileRef
+	var beforeSHA string
+	db.QueryRow("SELECT file_storage, file_key, file_sha256 FROM pack_listings WHERE id = 100").Scan(&before.Storage, &before.Key, &beforeSHA)
+
+	// Fail the switch-over after the new file has been stored.
+	if _, err := db.Exec(`CREATE TRIGGER fail_rotation BEFORE UPDATE OF encryption_password ON pack_listings
+		BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotatePackPassword(100); err == nil {
+		t.Fatal("rotation succeeded despite the failing update")
+	}
+
+	var password, key, sha string
+	var version int
+	db.QueryRow("SELECT encryption_password, file_key, file_sha256, version FROM pack_listings WHERE id = 100").Scan(&password, &key, &sha, &version)
+	if password != "old-pw" || key != before.Key || sha != beforeSHA || version != 3 {
+		t.Fatalf("listing changed after failed rotation: password=%q key=%q version=%d", password, key, version)
+	}
+	if n := packFilesIn(t, dir); n != 1 {
+		t.Fatalf("%d pack files in storage, want the new file removed", n)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(key)))
+	if err != nil {
+		t.Fatalf("old file gone: %v", err)
+	}
+	encrypted, _ := readPackJSON(data)
+	if _, err := serverDecryptPackJSON(encrypted, "old-pw"); err != nil {
+		t.Fatalf("old file no longer opens with the old password: %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pack scan providers.
+const (
+	packScanProviderClamAV = "clamav"
+	packScanProviderHTTP   = "http"
+)
+
+// Pack scan outcomes, stored in pack_listings.scan_status.
+const (
+	packScanClean    = "clean"
+	packScanInfected = "infected"
+	packScanError    = "error"
+)
+
+// defaultPackScanTimeout bounds one scan when timeout_seconds is unset.
+const defaultPackScanTimeout = 60 * time.Second
+
+// PackScanConfig is the pack_scan_config setting. Scanning is off unless
+// Enabled is set. With FailClosed, uploads whose scan fails are quarantined
+// like infected ones; otherwise they go to review as usual.
+type PackScanConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Provider       string `json:"provider"`
+	ClamAVAddr     string `json:"clamav_addr"`
+	HTTPURL        string `json:"http_url"`
+	SendHashOnly   bool   `json:"send_hash_only"`
+	FailClosed     bool   `json:"fail_closed"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+func loadPackScanConfig() PackScanConfig {
+	var cfg PackScanConfig
+	if raw := getSetting("pack_scan_config"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			log.Printf("[PACK-SCAN] invalid pack_scan_config: %v", err)
+		}
+	}
+	return cfg
+}
+
+func (c PackScanConfig) timeout() time.Duration {
+	if c.TimeoutSeconds > 0 {
+		return time.Duration(c.TimeoutSeconds) * time.Second
+	}
+	return defaultPackScanTimeout
+}
+
+// packScanResult is the outcome of scanning one upload. Status is empty
+// when scanning is disabled.
+type packScanResult struct {
+	Status    string
+	Signature string
+	Err       error
+}
+
+// detail describes the result for scan_detail and the audit log.
+func (r packScanResult) detail() string {
+	switch r.Status {
+	case packScanInfected:
+		return r.Signature
+	case packScanError:
+		return r.Err.Error()
+	}
+	return ""
+}
+
+// scanPackUpload scans an uploaded pack file with the configured provider.
+func scanPackUpload(data []byte) packScanResult {
+	cfg := loadPackScanConfig()
+	if !cfg.Enabled {
+		return packScanResult{}
+	}
+	var infected bool
+	var signature string
+	var err error
+	switch cfg.Provider {
+	case packScanProviderClamAV:
+		infected, signature, err = clamAVScan(cfg.ClamAVAddr, data, cfg.timeout())
+	case packScanProviderHTTP:
+		infected, signature, err = httpPackScan(cfg, data)
+	default:
+		err = fmt.Errorf("unknown scan provider %q", cfg.Provider)
+	}
+	if err != nil {
+		return packScanResult{Status: packScanError, Err: err}
+	}
+	if infected {
+		return packScanResult{Status: packScanInfected, Signature: signature}
+	}
+	return packScanResult{Status: packScanClean}
+}
+
+// clamAVScan streams data to clamd with the INSTREAM command.
+func clamAVScan(addr string, data []byte, timeout time.Duration) (infected bool, signature string, err error) {
+	if addr == "" {
+		return false, "", fmt.Errorf("clamd address is not configured")
+	}
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return false, "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return false, "", err
+	}
+	const chunkSize = 64 * 1024
+	var size [4]byte
+	for off := 0; off < len(data); off += chunkSize {
+		chunk := data[off:min(off+chunkSize, len(data))]
+		binary.BigEndian.PutUint32(size[:], uint32(len(chunk)))
+		if _, err := conn.Write(size[:]); err != nil {
+			return false, "", err
+		}
+		if _, err := conn.Write(chunk); err != nil {
+			return false, "", err
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := conn.Write(size[:]); err != nil {
+		return false, "", err
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil {
+		return false, "", err
+	}
+	// Replies look like "stream: OK" or "stream: Eicar-Signature FOUND".
+	text := strings.TrimSpace(strings.TrimRight(string(reply), "\x00"))
+	text = strings.TrimPrefix(text, "stream: ")
+	switch {
+	case text == "OK":
+		return false, "", nil
+	case strings.HasSuffix(text, " FOUND"):
+		return true, strings.TrimSuffix(text, " FOUND"), nil
+	}
+	return false, "", fmt.Errorf("clamd: %s", text)
+}
+
+// httpPackScan posts the file, or only its SHA-256 when SendHashOnly is set,
+// to a scanning service that answers {"infected": bool, "signature": "..."}.
+// An API key stored in pack_scan_api_key is sent as a bearer token.
+func httpPackScan(cfg PackScanConfig, data []byte) (infected bool, signature string, err error) {
+	if cfg.HTTPURL == "" {
+		return false, "", fmt.Errorf("scan endpoint is not configured")
+	}
+	hash := packFileSHA256(data)
+	var req *http.Request
+	if cfg.SendHashOnly {
+		body, _ := json.Marshal(map[string]string{"sha256": hash})
+		req, err = http.NewRequest(http.MethodPost, cfg.HTTPURL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		req, err = http.NewRequest(http.MethodPost, cfg.HTTPURL, bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+	}
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("X-Content-SHA256", hash)
+	if enc := getSetting("pack_scan_api_key"); enc != "" {
+		apiKey, err := decryptPayPalSecret(enc)
+		if err != nil {
+			return false, "", fmt.Errorf("decrypt scan API key: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := (&http.Client{Timeout: cfg.timeout()}).Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("scan endpoint returned %s", resp.Status)
+	}
+	var result struct {
+		Infected  bool   `json:"infected"`
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return false, "", fmt.Errorf("invalid scan response: %w", err)
+	}
+	return result.Infected, result.Signature, nil
+}
+
+// applyPackScanResult records the scan of a just-uploaded listing and
+// quarantines it when the file is infected, or when the scan failed and
+// scanning is configured to fail closed. It returns the listing's status.
+func applyPackScanResult(listingID int64, result packScanResult, ip string) string {
+	var status string
+	db.QueryRow("SELECT status FROM pack_listings WHERE id = ?", listingID).Scan(&status)
+	if result.Status == "" {
+		return status
+	}
+
+	quarantine := result.Status == packScanInfected ||
+		(result.Status == packScanError && loadPackScanConfig().FailClosed)
+	query := "UPDATE pack_listings SET scan_status = ?, scan_detail = ?, scanned_at = CURRENT_TIMESTAMP WHERE id = ?"
+	if quarantine {
+		query = "UPDATE pack_listings SET scan_status = ?, scan_detail = ?, scanned_at = CURRENT_TIMESTAMP, status = 'quarantined' WHERE id = ?"
+	}
+	if _, err := db.Exec(query, result.Status, result.detail(), listingID); err != nil {
+		log.Printf("[PACK-SCAN] failed to record scan of listing %d: %v", listingID, err)
+	} else if quarantine {
+		status = "quarantined"
+	}
+
+	detail := result.detail()
+	if quarantine {
+		detail = strings.TrimSpace(detail + " (quarantined)")
+	}
+	log.Printf("[PACK-SCAN] listing %d: %s %s", listingID, result.Status, detail)
+	recordAuditLog(0, "pack_scan_"+result.Status, fmt.Sprintf("listing:%d", listingID), detail, ip)
+	return status
+}
+
+// handleQuarantineList returns the quarantined pack listings.
+// GET /api/admin/review/quarantine
+func handleQuarantineList(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Query(`SELECT pl.id, pl.pack_name, COALESCE(u.display_name, ''), COALESCE(pl.scan_status, ''),
+		COALESCE(pl.scan_detail, ''), COALESCE(pl.scanned_at, ''), pl.created_at
+		FROM pack_listings pl LEFT JOIN users u ON u.id = pl.user_id
+		WHERE pl.status = 'quarantined' ORDER BY pl.id DESC`)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+	defer rows.Close()
+	type quarantinedPack struct {
+		ID         int64  `json:"id"`
+		PackName   string `json:"pack_name"`
+		AuthorName string `json:"author_name"`
+		ScanStatus string `json:"scan_status"`
+		ScanDetail string `json:"scan_detail"`
+		ScannedAt  string `json:"scanned_at"`
+		CreatedAt  string `json:"created_at"`
+	}
+	packs := []quarantinedPack{}
+	for rows.Next() {
+		var p quarantinedPack
+		if err := rows.Scan(&p.ID, &p.PackName, &p.AuthorName, &p.ScanStatus, &p.ScanDetail, &p.ScannedAt, &p.CreatedAt); err == nil {
+			packs = append(packs, p)
+		}
+	}
+	jsonResponse(w, http.StatusOK, packs)
+}
+
+// handleReleaseQuarantine moves a quarantined listing back to pending review
+// after an admin has checked it.
+// POST /api/admin/review/{id}/release
+func handleReleaseQuarantine(w http.ResponseWriter, r *http.Request, listingID int64) {
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	res, err := db.Exec("UPDATE pack_listings SET status = 'pending' WHERE id = ? AND status = 'quarantined'", listingID)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "not_quarantined"})
+		return
+	}
+	recordAuditLog(adminID, "pack_scan_release", fmt.Sprintf("listing:%d", listingID), "", getClientIP(r))
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleAdminPackScanSettings handles GET/POST /admin/settings/pack-scan.
+// The API key is never returned; posting an empty key keeps the stored one.
+func handleAdminPackScanSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"config":      loadPackScanConfig(),
+			"api_key_set": getSetting("pack_scan_api_key") != "",
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := PackScanConfig{
+		Enabled:      r.FormValue("enabled") == "1",
+		Provider:     strings.TrimSpace(r.FormValue("provider")),
+		ClamAVAddr:   strings.TrimSpace(r.FormValue("clamav_addr")),
+		HTTPURL:      strings.TrimSpace(r.FormValue("http_url")),
+		SendHashOnly: r.FormValue("send_hash_only") == "1",
+		FailClosed:   r.FormValue("fail_closed") == "1",
+	}
+	if v := strings.TrimSpace(r.FormValue("timeout_seconds")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 600 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "超时时间必须在 1-600 秒之间"})
+			return
+		}
+		cfg.TimeoutSeconds = n
+	}
+	switch cfg.Provider {
+	case packScanProviderClamAV:
+		if _, _, err := net.SplitHostPort(cfg.ClamAVAddr); cfg.Enabled && err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "clamd 地址格式应为 host:port"})
+			return
+		}
+	case packScanProviderHTTP:
+		if u, err := url.Parse(cfg.HTTPURL); cfg.Enabled && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "扫描接口地址无效"})
+			return
+		}
+	default:
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "未知的扫描方式"})
+		return
+	}
+
+	configJSON, _ := json.Marshal(cfg)
+	settings := map[string]string{"pack_scan_config": string(configJSON)}
+	if apiKey := r.FormValue("api_key"); apiKey != "" {
+		encrypted, err := encryptPayPalSecret(apiKey)
+		if err != nil {
+			log.Printf("[ADMIN] failed to encrypt scan API key: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		settings["pack_scan_api_key"] = encrypted
+	}
+	for key, value := range settings {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd answers INSTREAM requests, reporting data containing "EICAR" as infected.
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					return
+				}
+				var data []byte
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				if strings.Contains(string(data), "EICAR") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClamAVScan(t *testing.T) {
+	addr := fakeClamd(t)
+	big := strings.Repeat("x", 200*1024) + "EICAR"
+	infected, signature, err := clamAVScan(addr, []byte(big), 5*time.Second)
+	if err != nil || !infected || signature != "Eicar-Test-Signature" {
+		t.Errorf("infected file = %v, %q, %v", infected, signature, err)
+	}
+	if infected, _, err := clamAVScan(addr, []byte("clean pack"), 5*time.Second); err != nil || infected {
+		t.Errorf("clean file = %v, %v", infected, err)
+	}
+}
+
+func TestPackScanQuarantine(t *testing.T) {
+	useTestDB(t)
+	for _, id := range []int64{100, 101, 102} {
+		mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (?, 1, 1, x'00', 'Pack', 'free', 'pending')", id)
+	}
+
+	var gotHash string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			SHA256 string `json:"sha256"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotHash = req.SHA256
+		json.NewEncoder(w).Encode(map[string]interface{}{"infected": req.SHA256 == packFileSHA256([]byte("bad")), "signature": "Known-Bad"})
+	}))
+	defer srv.Close()
+	setConfig := func(cfg PackScanConfig) {
+		b, _ := json.Marshal(cfg)
+		mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('pack_scan_config', ?)", string(b))
+	}
+
+	if r := scanPackUpload([]byte("bad")); r.Status != "" {
+		t.Errorf("scan with scanning disabled = %+v", r)
+	}
+
+	setConfig(PackScanConfig{Enabled: true, Provider: packScanProviderHTTP, HTTPURL: srv.URL, SendHashOnly: true})
+	r := scanPackUpload([]byte("bad"))
+	if r.Status != packScanInfected || r.Signature != "Known-Bad" || gotHash != packFileSHA256([]byte("bad")) {
+		t.Fatalf("scan of bad file = %+v (hash sent %q)", r, gotHash)
+	}
+	if status := applyPackScanResult(100, r, "127.0.0.1"); status != "quarantined" {
+		t.Errorf("infected listing status = %q", status)
+	}
+	if r := scanPackUpload([]byte("good")); r.Status != packScanClean {
+		t.Errorf("scan of good file = %+v", r)
+	}
+
+	// An unreachable scanner leaves the listing pending when failing open...
+	setConfig(PackScanConfig{Enabled: true, Provider: packScanProviderHTTP, HTTPURL: "http://127.0.0.1:1/scan"})
+	r = scanPackUpload([]byte("good"))
+	if r.Status != packScanError {
+		t.Fatalf("scan with unreachable endpoint = %+v", r)
+	}
+	if status := applyPackScanResult(101, r, "127.0.0.1"); status != "pending" {
+		t.Errorf("fail-open listing status = %q", status)
+	}
+	// ...and quarantines it when failing closed.
+	setConfig(PackScanConfig{Enabled: true, Provider: packScanProviderHTTP, HTTPURL: "http://127.0.0.1:1/scan", FailClosed: true})
+	if status := applyPackScanResult(102, r, "127.0.0.1"); status != "quarantined" {
+		t.Errorf("fail-closed listing status = %q", status)
+	}
+
+	var audited int
+	db.QueryRow("SELECT COUNT(*) FROM admin_audit_log WHERE action LIKE 'pack_scan_%'").Scan(&audited)
+	if audited != 3 {
+		t.Errorf("audit log entries = %d, want 3", audited)
+	}
+
+	// Quarantined listings cannot be approved until released.
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/review/100/approve", nil)
+	rec := httptest.NewRecorder()
+	handleReviewRoutes(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("approve quarantined listing: status %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/review/100/release", nil)
+	req.Header.Set("X-Admin-ID", "1")
+	rec = httptest.NewRecorder()
+	handleReviewRoutes(rec, req)
+	var status string
+	db.QueryRow("SELECT status FROM pack_listings WHERE id = 100").Scan(&status)
+	if rec.Code != http.StatusOK || status != "pending" {
+		t.Errorf("release: status %d, listing %q", rec.Code, status)
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pack storage backends, selected by the "backend" field of the
+// pack_storage_config setting. packStorageDB keeps files in
+// pack_listings.file_data and is the default.
+const (
+	packStorageDB    = "db"
+	packStorageLocal = "local"
+	packStorageS3    = "s3"
+)
+
+// errPackFileNotFound is returned by PackStorage.Open for a missing object.
+var errPackFileNotFound = errors.New("pack file not found in storage")
+
+// PackStorage stores pack files outside the database, addressed by the key
+// kept in pack_listings.file_key.
+type PackStorage interface {
+	Put(key string, data []byte) error
+	Open(key string) (io.ReadCloser, int64, error)
+	Delete(key string) error
+}
+
+// PackStorageConfig is the pack_storage_config setting. The S3 secret key is
+// stored separately, encrypted, in the pack_storage_s3_secret setting.
+type PackStorageConfig struct {
+	Backend       string `json:"backend"`
+	LocalDir      string `json:"local_dir"`
+	S3Endpoint    string `json:"s3_endpoint"`
+	S3Region      string `json:"s3_region"`
+	S3Bucket      string `json:"s3_bucket"`
+	S3AccessKeyID string `json:"s3_access_key_id"`
+}
+
+// loadPackStorageConfig reads the pack_storage_config setting, defaulting to
+// database storage.
+func loadPackStorageConfig() PackStorageConfig {
+	var cfg PackStorageConfig
+	if raw := getSetting("pack_storage_config"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			log.Printf("[PACK-STORAGE] invalid pack_storage_config: %v", err)
+		}
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = packStorageDB
+	}
+	return cfg
+}
+
+// packStorageFor returns the storage for backend, built from the current
+// configuration. Rows remember which backend holds their file, so files stay
+// readable after the default backend is switched.
+func packStorageFor(backend string) (PackStorage, error) {
+	cfg := loadPackStorageConfig()
+	switch backend {
+	case packStorageLocal:
+		if cfg.LocalDir == "" {
+			return nil, fmt.Errorf("local pack storage directory is not configured")
+		}
+		return &localPackStorage{Dir: cfg.LocalDir}, nil
+	case packStorageS3:
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" || cfg.S3AccessKeyID == "" {
+			return nil, fmt.Errorf("S3 pack storage is not configured")
+		}
+		secret := ""
+		if enc := getSetting("pack_storage_s3_secret"); enc != "" {
+			var err error
+			if secret, err = decryptPayPalSecret(enc); err != nil {
+				return nil, fmt.Errorf("decrypt S3 secret: %w", err)
+			}
+		}
+		region := cfg.S3Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		return &s3PackStorage{
+			Endpoint: strings.TrimRight(cfg.S3Endpoint, "/"), Region: region, Bucket: cfg.S3Bucket,
+			AccessKeyID: cfg.S3AccessKeyID, SecretAccessKey: secret,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown pack storage backend %q", backend)
+}
+
+// packFileRef locates a pack file: either inline in Data (Storage == "") or
+// in the external storage named by Storage under Key.
+type packFileRef struct {
+	Data    []byte
+	Storage string
+	Key     string
+}
+
+// packFileKey is the storage key of a listing's file. Including the hash
+// means a replaced file never overwrites the object still being served.
+func packFileKey(listingID int64, fileSHA256 string) string {
+	return fmt.Sprintf("packs/%d/%s.qap", listingID, fileSHA256)
+}
+
+// putPackFile stores a listing's file in the configured backend and returns
+// the reference to save in pack_listings (file_data, file_storage, file_key).
+func putPackFile(listingID int64, data []byte) (packFileRef, error) {
+	cfg := loadPackStorageConfig()
+	if cfg.Backend == packStorageDB {
+		return packFileRef{Data: data}, nil
+	}
+	storage, err := packStorageFor(cfg.Backend)
+	if err != nil {
+		return packFileRef{}, err
+	}
+	key := packFileKey(listingID, packFileSHA256(data))
+	if err := storage.Put(key, data); err != nil {
+		return packFileRef{}, err
+	}
+	// file_data is NOT NULL, so externally stored files keep an empty BLOB.
+	return packFileRef{Data: []byte{}, Storage: cfg.Backend, Key: key}, nil
+}
+
+// openPackFile opens a pack file for streaming.
+func openPackFile(ref packFileRef) (io.ReadCloser, int64, error) {
+	if ref.Storage == "" {
+		return io.NopCloser(bytes.NewReader(ref.Data)), int64(len(ref.Data)), nil
+	}
+	storage, err := packStorageFor(ref.Storage)
+	if err != nil {
+		return nil, 0, err
+	}
+	return storage.Open(ref.Key)
+}
+
+// deletePackFile removes an externally stored file. Errors are logged only:
+// an orphaned object is harmless.
+func deletePackFile(ref packFileRef) {
+	if ref.Storage == "" || ref.Key == "" {
+		return
+	}
+	storage, err := packStorageFor(ref.Storage)
+	if err == nil {
+		err = storage.Delete(ref.Key)
+	}
+	if err != nil && !errors.Is(err, errPackFileNotFound) {
+		log.Printf("[PACK-STORAGE] failed to delete %s:%s: %v", ref.Storage, ref.Key, err)
+	}
+}
+
+// migratePackFilesToStorage moves file_data BLOBs into the configured
+// external backend, one listing at a time. A listing whose file is replaced
+// while it is being moved is skipped and picked up by the next run. The
+// freed database pages are reused by SQLite; run VACUUM to shrink the file.
+func migratePackFilesToStorage() (moved, failed int, err error) {
+	cfg := loadPackStorageConfig()
+	if cfg.Backend == packStorageDB {
+		return 0, 0, nil
+	}
+	storage, err := packStorageFor(cfg.Backend)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rows, err := db.Query("SELECT id FROM pack_listings WHERE COALESCE(file_key, '') = '' AND length(file_data) > 0 ORDER BY id")
+	if err != nil {
+		return 0, 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, id := range ids {
+		var data []byte
+		var storedHash string
+		if err := db.QueryRow("SELECT file_data, COALESCE(file_sha256, '') FROM pack_listings WHERE id = ? AND COALESCE(file_key, '') = ''", id).
+			Scan(&data, &storedHash); err != nil {
+			continue
+		}
+		hash := packFileSHA256(data)
+		key := packFileKey(id, hash)
+		if err := storage.Put(key, data); err != nil {
+			log.Printf("[PACK-STORAGE] failed to move listing %d to %s: %v", id, cfg.Backend, err)
+			failed++
+			continue
+		}
+		// Keep a stored hash even if it differs, so integrity checks still
+		// flag a file that was corrupted before the move.
+		res, err := db.Exec(`UPDATE pack_listings SET file_data = x'', file_sha256 = COALESCE(NULLIF(file_sha256, ''), ?), file_storage = ?, file_key = ?
+			WHERE id = ? AND COALESCE(file_key, '') = '' AND COALESCE(file_sha256, '') = ?`,
+			hash, cfg.Backend, key, id, storedHash)
+		if err != nil {
+			log.Printf("[PACK-STORAGE] failed to update listing %d after move: %v", id, err)
+			storage.Delete(key)
+			failed++
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			// Replaced concurrently; the new file was stored by the replace
+			// itself, possibly under the same key if the content is identical.
+			var currentKey string
+			db.QueryRow("SELECT COALESCE(file_key, '') FROM pack_listings WHERE id = ?", id).Scan(&currentKey)
+			if currentKey != key {
+				storage.Delete(key)
+			}
+			continue
+		}
+		moved++
+	}
+	if moved > 0 || failed > 0 {
+		log.Printf("[PACK-STORAGE] moved %d pack files to %s storage (%d failed)", moved, cfg.Backend, failed)
+	}
+	return moved, failed, nil
+}
+
+// localPackStorage keeps pack files under a directory on the local disk.
+type localPackStorage struct {
+	Dir string
+}
+
+func (s *localPackStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || strings.HasPrefix(clean, "..") {
+		return "", fmt.Errorf("invalid pack storage key %q", key)
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
+
+func (s *localPackStorage) Put(key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *localPackStorage) Open(key string) (io.ReadCloser, int64, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, 0, errPackFileNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func (s *localPackStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); os.IsNotExist(err) {
+		return errPackFileNotFound
+	} else {
+		return err
+	}
+}
+
+// s3PackStorage keeps pack files in an S3-compatible bucket, addressed
+// path-style (endpoint/bucket/key) so MinIO and similar services work too.
+// Requests are signed with AWS Signature Version 4.
+type s3PackStorage struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Client          *http.Client
+}
+
+func (s *s3PackStorage) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return &http.Client{Timeout: 10 * time.Minute}
+}
+
+func (s *s3PackStorage) newRequest(method, key string, body []byte) (*http.Request, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/" + s.Bucket + "/" + strings.TrimPrefix(key, "/")
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+	return req, nil
+}
+
+// sign adds SigV4 authentication headers for a request without query
+// parameters.
+func (s *s3PackStorage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	signingKey := mac(mac(mac(mac([]byte("AWS4"+s.SecretAccessKey), date), s.Region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(mac(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func (s *s3PackStorage) Put(key string, data []byte) error {
+	req, err := s.newRequest(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 PUT %s: %s: %s", key, resp.Status, msg)
+	}
+	return nil
+}
+
+func (s *s3PackStorage) Open(key string) (io.ReadCloser, int64, error) {
+	req, err := s.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, resp.ContentLength, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, 0, errPackFileNotFound
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	return nil, 0, fmt.Errorf("S3 GET %s: %s: %s", key, resp.Status, msg)
+}
+
+func (s *s3PackStorage) Delete(key string) error {
+	req, err := s.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 DELETE %s: %s: %s", key, resp.Status, msg)
+	}
+	return nil
+}
+
+// handleAdminPackStorageSettings handles GET/POST /admin/settings/pack-storage.
+// The S3 secret is never returned; posting an empty secret keeps the stored one.
+func handleAdminPackStorageSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		var pending int
+		db.QueryRow("SELECT COUNT(*) FROM pack_listings WHERE COALESCE(file_key, '') = '' AND length(file_data) > 0").Scan(&pending)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"config":        loadPackStorageConfig(),
+			"s3_secret_set": getSetting("pack_storage_s3_secret") != "",
+			"db_files":      pending,
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := PackStorageConfig{
+		Backend:       strings.TrimSpace(r.FormValue("backend")),
+		LocalDir:      strings.TrimSpace(r.FormValue("local_dir")),
+		S3Endpoint:    strings.TrimRight(strings.TrimSpace(r.FormValue("s3_endpoint")), "/"),
+		S3Region:      strings.TrimSpace(r.FormValue("s3_region")),
+		S3Bucket:      strings.TrimSpace(r.FormValue("s3_bucket")),
+		S3AccessKeyID: strings.TrimSpace(r.FormValue("s3_access_key_id")),
+	}
+	secret := r.FormValue("s3_secret_access_key")
+	switch cfg.Backend {
+	case packStorageDB:
+	case packStorageLocal:
+		if cfg.LocalDir == "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "存储目录不能为空"})
+			return
+		}
+		if err := os.MkdirAll(cfg.LocalDir, 0o755); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无法创建存储目录: " + err.Error()})
+			return
+		}
+	case packStorageS3:
+		if u, err := url.Parse(cfg.S3Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "S3 服务地址无效"})
+			return
+		}
+		if cfg.S3Bucket == "" || cfg.S3AccessKeyID == "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Bucket 和 Access Key 不能为空"})
+			return
+		}
+		if secret == "" && getSetting("pack_storage_s3_secret") == "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Secret Key 不能为空"})
+			return
+		}
+	default:
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "未知的存储方式"})
+		return
+	}
+
+	configJSON, _ := json.Marshal(cfg)
+	settings := map[string]string{"pack_storage_config": string(configJSON)}
+	if secret != "" {
+		encrypted, err := encryptPayPalSecret(secret)
+		if err != nil {
+			log.Printf("[ADMIN] failed to encrypt S3 secret: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		settings["pack_storage_s3_secret"] = encrypted
+	}
+	for key, value := range settings {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleAdminPackStorageMigrate handles POST /admin/settings/pack-storage/migrate,
+// moving pack files still kept in the database to the configured backend.
+func handleAdminPackStorageMigrate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if loadPackStorageConfig().Backend == packStorageDB {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请先选择外部存储方式"})
+		return
+	}
+	moved, failed, err := migratePackFilesToStorage()
+	if err != nil {
+		log.Printf("[PACK-STORAGE] migration failed: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	recordAuditLog(adminID, "pack_storage_migrate", "pack_storage", fmt.Sprintf("moved=%d failed=%d", moved, failed), getClientIP(r))
+	jsonResponse(w, http.StatusOK, map[string]int{"moved": moved, "failed": failed})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPackStorageMigrationAndDownload(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+	dir := t.TempDir()
+	if _, err := db.Exec("INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'email', 'a', 'Author')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO pack_listings (id, user_id, category_id, file_data, file_sha256, pack_name, share_mode, status) VALUES (100, 1, 1, ?, ?, 'Pack', 'free', 'published')",
+		[]byte("pack data"), packFileSHA256([]byte("pack data"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('pack_storage_config', ?)`,
+		`{"backend":"local","local_dir":"`+dir+`"}`); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, failed, err := migratePackFilesToStorage()
+	if err != nil || moved != 1 || failed != 0 {
+		t.Fatalf("migrate = %d, %d, %v", moved, failed, err)
+	}
+	var blobLen int
+	var storage, key string
+	db.QueryRow("SELECT length(file_data), file_storage, file_key FROM pack_listings WHERE id = 100").Scan(&blobLen, &storage, &key)
+	if blobLen != 0 || storage != packStorageLocal || key != packFileKey(100, packFileSHA256([]byte("pack data"))) {
+		t.Fatalf("after migration: blob=%d storage=%q key=%q", blobLen, storage, key)
+	}
+	if moved, _, _ := migratePackFilesToStorage(); moved != 0 {
+		t.Errorf("second migration moved %d files", moved)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/packs/100/download", nil)
+	req.Header.Set("X-User-ID", "2")
+	rec := httptest.NewRecorder()
+	handleDownloadPack(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "pack data" || rec.Header().Get("Content-Length") != "9" {
+		t.Fatalf("download: status %d, body %q, length %q", rec.Code, rec.Body.String(), rec.Header().Get("Content-Length"))
+	}
+
+	checked, _, mismatches, err := verifyPackIntegrity([]int64{100})
+	if err != nil || checked != 1 || len(mismatches) != 0 {
+		t.Errorf("verify = %d, %v, %v", checked, mismatches, err)
+	}
+
+	// A file missing from storage fails the download before anything is charged.
+	(&localPackStorage{Dir: dir}).Delete(key)
+	rec = httptest.NewRecorder()
+	handleDownloadPack(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("download of missing file: status %d", rec.Code)
+	}
+}
+
+func TestLocalPackStorageRejectsEscapingKeys(t *testing.T) {
+	s := &localPackStorage{Dir: t.TempDir()}
+	for _, key := range []string{"../x.qap", "/etc/passwd", "."} {
+		if err := s.Put(key, []byte("x")); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+	if _, _, err := s.Open("packs/1/missing.qap"); err != errPackFileNotFound {
+		t.Errorf("Open missing = %v", err)
+	}
+}
+
+func TestS3PackStorage(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") ||
+			r.Header.Get("x-amz-content-sha256") == "" || r.Header.Get("x-amz-date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	s := &s3PackStorage{Endpoint: srv.URL, Region: "eu-west-1", Bucket: "packs", AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	if err := s.Put("packs/1/abc.qap", []byte("pack data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objects["/packs/packs/1/abc.qap"]; !ok {
+		t.Fatalf("object stored under %v", objects)
+	}
+	rc, size, err := s.Open("packs/1/abc.qap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(body) != "pack data" || size != 9 {
+		t.Errorf("Open = %q (%d)", body, size)
+	}
+	if err := s.Delete("packs/1/abc.qap"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Open("packs/1/abc.qap"); err != errPackFileNotFound {
+		t.Errorf("Open after delete = %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxPackSizeMB is the pack upload limit used when the max_pack_size
+// setting is unset. It matches the limit clients enforced before it was
+// configurable.
+const defaultMaxPackSizeMB = 500
+
+// packUploadFormOverhead is the allowance for multipart boundaries and form
+// fields on top of the pack file itself.
+const packUploadFormOverhead = 1 << 20
+
+// getMaxPackSize returns the maximum pack file size in bytes. The
+// max_pack_size setting is in MB.
+func getMaxPackSize() int64 {
+	mb, err := strconv.ParseInt(getSetting("max_pack_size"), 10, 64)
+	if err != nil || mb <= 0 {
+		mb = defaultMaxPackSizeMB
+	}
+	return mb << 20
+}
+
+// limitPackUploadBody rejects uploads whose declared Content-Length already
+// exceeds the limit and caps the body for the rest. It returns false after
+// writing the 413 response.
+func limitPackUploadBody(w http.ResponseWriter, r *http.Request, maxSize int64) bool {
+	if r.ContentLength > maxSize+packUploadFormOverhead {
+		writePackTooLarge(w, maxSize)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+packUploadFormOverhead)
+	return true
+}
+
+// isRequestTooLarge reports whether err came from a body cut off by
+// limitPackUploadBody.
+func isRequestTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+func writePackTooLarge(w http.ResponseWriter, maxSize int64) {
+	jsonResponse(w, http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"error":    "file_too_large",
+		"message":  fmt.Sprintf("pack file exceeds the maximum size of %dMB", maxSize>>20),
+		"max_size": maxSize,
+	})
+}
+
+// handlePackUploadLimits handles GET /api/packs/upload-limits so clients can
+// check a file before uploading it.
+func handlePackUploadLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]int64{"max_pack_size": getMaxPackSize()})
+}
+
+// handleAdminPackUploadSettings handles GET/POST /admin/settings/pack-upload.
+func handleAdminPackUploadSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]int64{"max_pack_size_mb": getMaxPackSize() >> 20})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	mb, err := strconv.ParseInt(strings.TrimSpace(r.FormValue("max_pack_size_mb")), 10, 64)
+	if err != nil || mb < 1 || mb > 10240 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "上传大小上限必须在 1-10240 MB 之间"})
+		return
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('max_pack_size', ?)", strconv.FormatInt(mb, 10)); err != nil {
+		log.Printf("[ADMIN] failed to save max_pack_size: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
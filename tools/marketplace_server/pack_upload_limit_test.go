@@ -0,0 +1,116 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// qapOfSize builds a minimal .qap archive of exactly size bytes.
+func qapOfSize(t *testing.T, size int) []byte {
+	t.Helper()
+	build := func(padding int) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, content := range map[string]string{
+			"metadata.json": `{"pack_name":"Limit Pack","author":"A"}`,
+			"padding.bin":   strings.Repeat("x", padding),
+		} {
+			f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte(content))
+		}
+		zw.Close()
+		return buf.Bytes()
+	}
+	data := build(size - len(build(0)))
+	if len(data) != size {
+		t.Fatalf("built %d bytes, want %d", len(data), size)
+	}
+	return data
+}
+
+func packUploadRequest(t *testing.T, fileData []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("share_mode", "free")
+	mw.WriteField("category_id", "1")
+	part, _ := mw.CreateFormFile("file", "pack.qap")
+	part.Write(fileData)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/packs/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-User-ID", "1")
+	return req
+}
+
+func TestPackUploadSizeLimit(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('max_pack_size', '1')"); err != nil {
+		t.Fatal(err)
+	}
+	const limit = 1 << 20
+
+	rec := httptest.NewRecorder()
+	handlePackUploadLimits(rec, httptest.NewRequest(http.MethodGet, "/api/packs/upload-limits", nil))
+	if !strings.Contains(rec.Body.String(), `"max_pack_size":1048576`) {
+		t.Errorf("upload limits = %s", rec.Body.String())
+	}
+
+	// A file of exactly the limit is accepted.
+	rec = httptest.NewRecorder()
+	handleUploadPack(rec, packUploadRequest(t, qapOfSize(t, limit)))
+	if rec.Code != http.StatusOK && rec.Code != http.StatusCreated {
+		t.Fatalf("at limit: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// One byte over is rejected with 413, whether or not the body length is declared.
+	over := packUploadRequest(t, qapOfSize(t, limit+1))
+	over.ContentLength = -1
+	rec = httptest.NewRecorder()
+	handleUploadPack(rec, over)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "file_too_large") {
+		t.Errorf("over limit: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// A declared Content-Length far above the limit is rejected before reading.
+	huge := packUploadRequest(t, qapOfSize(t, 3*limit))
+	var read int64
+	huge.Body = readCounter{huge.Body, &read}
+	rec = httptest.NewRecorder()
+	handleUploadPack(rec, huge)
+	if rec.Code != http.StatusRequestEntityTooLarge || read != 0 {
+		t.Errorf("declared oversize: status %d, %d bytes read", rec.Code, read)
+	}
+
+	// An undeclared body far above the limit is cut off while parsing.
+	huge = packUploadRequest(t, qapOfSize(t, 3*limit))
+	huge.ContentLength = -1
+	rec = httptest.NewRecorder()
+	handleUploadPack(rec, huge)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("undeclared oversize: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+type readCounter struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (r readCounter) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	*r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Usage quotas for per_use packs. pack_usage_records holds how many uses a
+// user has bought (total_purchased) and spent (used_count); pack_usage_log
+// has one row per counted use, keyed by its used_at timestamp, so a retried
+// download or usage report is only counted once. last_used_at holds the key
+// of the most recent use, the only one a download may retry for free.
+
+// errUsageQuotaExhausted is returned when a per_use pack has no uses left.
+var errUsageQuotaExhausted = errors.New("usage quota exhausted")
+
+// packUsage is a user's quota for one per_use pack.
+type packUsage struct {
+	Used  int
+	Total int
+}
+
+// Remaining returns the number of uses left.
+func (u packUsage) Remaining() int {
+	if u.Total <= u.Used {
+		return 0
+	}
+	return u.Total - u.Used
+}
+
+// loadPackUsage returns the user's quota for a pack. ok is false when the
+// user has never bought uses of it.
+func loadPackUsage(tx *sql.Tx, userID, listingID int64) (usage packUsage, ok bool, err error) {
+	err = tx.QueryRow(`SELECT used_count, total_purchased FROM pack_usage_records WHERE user_id = ? AND listing_id = ?`,
+		userID, listingID).Scan(&usage.Used, &usage.Total)
+	if err == sql.ErrNoRows {
+		return packUsage{}, false, nil
+	}
+	return usage, err == nil, err
+}
+
+// addPackUses adds quantity bought uses to the user's quota.
+func addPackUses(tx *sql.Tx, userID, listingID int64, quantity int) error {
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO pack_usage_records (user_id, listing_id, used_count, total_purchased) VALUES (?, ?, 0, 0)`,
+		userID, listingID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE pack_usage_records SET total_purchased = total_purchased + ?, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND listing_id = ?`, quantity, userID, listingID)
+	return err
+}
+
+// consumePackUse spends one use identified by usedAt. Retrying the most
+// recent use (same usedAt as last_used_at) is not counted again while uses
+// are left; replaying any older usedAt costs a use like a new one, so old
+// keys cannot be reused for free downloads. When no uses are left it
+// returns errUsageQuotaExhausted along with the current quota; the caller
+// must roll back tx so the log entry is discarded.
+func consumePackUse(tx *sql.Tx, userID, listingID int64, usedAt string) (packUsage, error) {
+	res, err := tx.Exec(`INSERT OR IGNORE INTO pack_usage_log (user_id, listing_id, used_at) VALUES (?, ?, ?)`,
+		userID, listingID, usedAt)
+	if err != nil {
+		return packUsage{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var usage packUsage
+		var lastUsedAt string
+		if err := tx.QueryRow(`SELECT used_count, total_purchased, COALESCE(last_used_at, '') FROM pack_usage_records
+			WHERE user_id = ? AND listing_id = ?`, userID, listingID).Scan(&usage.Used, &usage.Total, &lastUsedAt); err != nil && err != sql.ErrNoRows {
+			return packUsage{}, err
+		}
+		if usage.Remaining() == 0 {
+			return usage, errUsageQuotaExhausted
+		}
+		if usedAt == lastUsedAt {
+			return usage, nil
+		}
+	}
+	res, err = tx.Exec(`UPDATE pack_usage_records SET used_count = used_count + 1, last_used_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND listing_id = ? AND used_count < total_purchased`, usedAt, userID, listingID)
+	if err != nil {
+		return packUsage{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		usage, _, err := loadPackUsage(tx, userID, listingID)
+		if err != nil {
+			return packUsage{}, err
+		}
+		return usage, errUsageQuotaExhausted
+	}
+	usage, _, err := loadPackUsage(tx, userID, listingID)
+	return usage, err
+}
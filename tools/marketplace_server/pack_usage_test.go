@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPerUseDownloadQuota(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, credits_balance) VALUES (2, 'sn', 'alice', 'Alice', 100)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status, encryption_password) VALUES (100, 1, 1, x'00', 'Pack', 'per_use', 10, 'published', '')")
+
+	download := func(usedAt string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/packs/100/download?used_at="+usedAt, nil)
+		req.Header.Set("X-User-ID", "2")
+		rec := httptest.NewRecorder()
+		handleDownloadPack(rec, req)
+		return rec
+	}
+	usage := func() packUsage {
+		t.Helper()
+		var u packUsage
+		db.QueryRow("SELECT used_count, total_purchased FROM pack_usage_records WHERE user_id = 2 AND listing_id = 100").Scan(&u.Used, &u.Total)
+		return u
+	}
+	balance := func() float64 { return getWalletBalance(2) }
+
+	// First download buys and spends one use.
+	if rec := download("2026-01-01T10:00:00Z"); rec.Code != http.StatusOK {
+		t.Fatalf("first download: status %d: %s", rec.Code, rec.Body)
+	}
+	if u := usage(); u.Used != 1 || u.Total != 1 || balance() != 90 {
+		t.Fatalf("after first download: usage %+v, balance %v", u, balance())
+	}
+
+	// Over quota: rejected without charging.
+	rec := download("2026-01-01T11:00:00Z")
+	var body map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusPaymentRequired || body["error"] != "USAGE_QUOTA_EXHAUSTED" {
+		t.Fatalf("over quota: status %d, body %v", rec.Code, body)
+	}
+	if u := usage(); u.Used != 1 || balance() != 90 {
+		t.Fatalf("over quota changed state: usage %+v, balance %v", u, balance())
+	}
+
+	// Replaying an already counted download is refused once the quota is spent.
+	if rec := download("2026-01-01T10:00:00Z"); rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("replayed download over quota: status %d, want 402", rec.Code)
+	}
+
+	// Top up three uses, then spend them; the last use succeeds and reports 0 left.
+	tx, _ := db.Begin()
+	if err := addPackUses(tx, 2, 100, 3); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+
+	// While uses are left, retrying the most recent download is allowed and
+	// not counted again.
+	if rec := download("2026-01-01T10:00:00Z"); rec.Code != http.StatusOK {
+		t.Fatalf("retried download: status %d", rec.Code)
+	}
+	if u := usage(); u.Used != 1 {
+		t.Fatalf("retried download was counted: usage %+v", u)
+	}
+	download("2026-01-01T12:00:00Z")
+
+	// Replaying an older download is not a retry: it costs a use.
+	if rec := download("2026-01-01T10:00:00Z"); rec.Code != http.StatusOK {
+		t.Fatalf("replayed older download: status %d", rec.Code)
+	}
+	if u := usage(); u.Used != 3 {
+		t.Fatalf("replayed older download was free: usage %+v", u)
+	}
+	rec = download("2026-01-01T13:00:00Z")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("last use: status %d: %s", rec.Code, rec.Body)
+	}
+	var license map[string]interface{}
+	json.Unmarshal([]byte(rec.Header().Get("X-Usage-License")), &license)
+	if license["remaining_uses"] != float64(0) || license["total_uses"] != float64(4) {
+		t.Errorf("last use license = %v", license)
+	}
+	if rec := download("2026-01-01T14:00:00Z"); rec.Code != http.StatusPaymentRequired {
+		t.Errorf("after last use: status %d, want 402", rec.Code)
+	}
+	if u := usage(); u.Used != 4 || u.Total != 4 || balance() != 90 {
+		t.Errorf("final usage %+v, balance %v", u, balance())
+	}
+}
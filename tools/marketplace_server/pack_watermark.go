@@ -0,0 +1,222 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Per-buyer watermarks. While the pack_watermark_enabled setting is "1",
+// every pack download is stamped at stream time with a mark naming the buyer
+// (a keyed hash of their email) and the order (the credits transaction that
+// paid for the pack, 0 for free packs). The stored file is never modified. Each
+// mark is recorded in pack_watermarks, so the buyer of a leaked copy can be
+// looked up with /api/admin/pack-watermarks. The X-Content-SHA256 header of a
+// watermarked download is the hash of the bytes actually sent.
+
+// packWatermarker embeds watermarks into one pack file format.
+type packWatermarker interface {
+	// Supports reports whether data is in the watermarker's format.
+	Supports(data []byte) bool
+	// Apply returns a copy of data carrying mark.
+	Apply(data []byte, mark string) ([]byte, error)
+}
+
+// packWatermarkers are tried in order; files no watermarker supports are
+// served unchanged.
+var packWatermarkers = []packWatermarker{qapWatermarker{}}
+
+// qapWatermarker marks .qap files, which are ZIP archives, through the
+// archive comment. Entries are copied without recompression, so encrypted
+// pack.json payloads are untouched and still open with the same password.
+type qapWatermarker struct{}
+
+func (qapWatermarker) Supports(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+func (qapWatermarker) Apply(data []byte, mark string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		if err := zw.Copy(f); err != nil {
+			return nil, fmt.Errorf("copy %s: %w", f.Name, err)
+		}
+	}
+	if err := zw.SetComment(mark); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("finalize zip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// packWatermarkEnabled reports whether downloads are watermarked.
+func packWatermarkEnabled() bool {
+	return getSetting("pack_watermark_enabled") == "1"
+}
+
+// packWatermarkSecretKey is the settings key of the HMAC key behind buyer
+// hashes in watermarks.
+const packWatermarkSecretKey = "pack_watermark_secret"
+
+// packWatermarkSecret returns the HMAC key for buyer hashes, creating and
+// storing a random one if none exists yet.
+func packWatermarkSecret() []byte {
+	if secret := getSetting(packWatermarkSecretKey); secret != "" {
+		return []byte(secret)
+	}
+	// INSERT OR IGNORE so concurrent first downloads agree on a single key.
+	db.Exec("INSERT OR IGNORE INTO settings (key, value) VALUES (?, ?)", packWatermarkSecretKey, generateSessionID())
+	return []byte(getSetting(packWatermarkSecretKey))
+}
+
+// buyerEmailHash identifies a buyer in watermarks without revealing the
+// address: the HMAC-SHA256, under a server secret, of the lower-cased email,
+// or of "user:<id>" for accounts without one. Without the secret a leaked
+// copy cannot be matched against a list of candidate addresses.
+func buyerEmailHash(userID int64) string {
+	email := strings.ToLower(strings.TrimSpace(getEmailForUser(userID)))
+	if email == "" {
+		email = fmt.Sprintf("user:%d", userID)
+	}
+	mac := hmac.New(sha256.New, packWatermarkSecret())
+	mac.Write([]byte(email))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// packWatermarkFor returns the mark for userID's copy of listingID and
+// records it.
+func packWatermarkFor(userID, listingID int64) string {
+	var orderID int64
+	db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM credits_transactions WHERE user_id = ? AND listing_id = ? AND amount < 0",
+		userID, listingID).Scan(&orderID)
+	emailHash := buyerEmailHash(userID)
+	mark := fmt.Sprintf("vantagics-wm:%d:%s:%d", listingID, emailHash, orderID)
+	if _, err := db.Exec(`INSERT OR IGNORE INTO pack_watermarks (watermark, user_id, listing_id, email_hash, order_id)
+		VALUES (?, ?, ?, ?, ?)`, mark, userID, listingID, emailHash, orderID); err != nil {
+		log.Printf("[PACK-WATERMARK] failed to record %q: %v", mark, err)
+	}
+	return mark
+}
+
+// packWatermarkMaxSize is the largest pack file that is watermarked.
+// Marking needs the whole file in memory, so larger packs are streamed
+// unmarked.
+const packWatermarkMaxSize = 64 << 20
+
+// readPackForWatermark reads the pack file into memory when downloads are
+// watermarked. Handlers call it before billing, so a read failure is answered
+// with an error instead of an empty file the buyer has paid for. It returns
+// nil when watermarking is off or the file is larger than
+// packWatermarkMaxSize; the file is then streamed as is.
+func readPackForWatermark(listingID int64, file io.Reader, size int64) ([]byte, error) {
+	if !packWatermarkEnabled() {
+		return nil, nil
+	}
+	if size > packWatermarkMaxSize {
+		log.Printf("[PACK-WATERMARK] listing %d is %d bytes, over the %d byte limit; serving it unmarked", listingID, size, packWatermarkMaxSize)
+		return nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(file, packWatermarkMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read listing %d: %w", listingID, err)
+	}
+	if len(data) > packWatermarkMaxSize {
+		// The stored size was wrong and the reader is partly spent.
+		return nil, fmt.Errorf("read listing %d: file exceeds %d bytes", listingID, packWatermarkMaxSize)
+	}
+	return data, nil
+}
+
+// watermarkPackDownload returns the file, size and SHA-256 to serve to
+// userID for listingID. data is what readPackForWatermark returned for file;
+// when it is nil the stored file is served as is. For unsupported formats, or
+// if marking fails, the unmarked bytes are served; a download is never
+// refused because of the watermark.
+func watermarkPackDownload(userID, listingID int64, file io.Reader, data []byte, size int64, fileSHA256 string) (io.Reader, int64, string) {
+	if data == nil {
+		return file, size, fileSHA256
+	}
+	for _, wm := range packWatermarkers {
+		if !wm.Supports(data) {
+			continue
+		}
+		marked, err := wm.Apply(data, packWatermarkFor(userID, listingID))
+		if err != nil {
+			log.Printf("[PACK-WATERMARK] failed to watermark listing %d: %v", listingID, err)
+			break
+		}
+		if fileSHA256 != "" {
+			fileSHA256 = packFileSHA256(marked)
+		}
+		return bytes.NewReader(marked), int64(len(marked)), fileSHA256
+	}
+	return bytes.NewReader(data), int64(len(data)), fileSHA256
+}
+
+// handleAdminPackWatermarkLookup handles GET /api/admin/pack-watermarks?mark=...
+// and returns the buyer and order a watermark was issued for.
+func handleAdminPackWatermarkLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	mark := strings.TrimSpace(r.URL.Query().Get("mark"))
+	var info struct {
+		Watermark   string `json:"watermark"`
+		UserID      int64  `json:"user_id"`
+		Email       string `json:"email"`
+		DisplayName string `json:"display_name"`
+		ListingID   int64  `json:"listing_id"`
+		PackName    string `json:"pack_name"`
+		OrderID     int64  `json:"order_id"`
+		CreatedAt   string `json:"created_at"`
+	}
+	err := db.QueryRow(`SELECT wm.watermark, wm.user_id, COALESCE(u.email, ''), COALESCE(u.display_name, ''),
+			wm.listing_id, COALESCE(pl.pack_name, ''), wm.order_id, wm.created_at
+		FROM pack_watermarks wm
+		LEFT JOIN users u ON u.id = wm.user_id
+		LEFT JOIN pack_listings pl ON pl.id = wm.listing_id
+		WHERE wm.watermark = ?`, mark).Scan(&info.Watermark, &info.UserID, &info.Email, &info.DisplayName,
+		&info.ListingID, &info.PackName, &info.OrderID, &info.CreatedAt)
+	if err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "watermark not found"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, info)
+}
+
+// handleAdminPackWatermarkSettings handles GET/POST /admin/settings/pack-watermark.
+func handleAdminPackWatermarkSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]bool{"enabled": packWatermarkEnabled()})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	value := "0"
+	if v := r.FormValue("enabled"); v == "1" || v == "true" {
+		value = "1"
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('pack_watermark_enabled', ?)", value); err != nil {
+		log.Printf("[ADMIN] failed to save pack_watermark_enabled: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWatermarkPackDownload(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'sn', 'author', 'Author', ''), (2, 'sn', 'buyer', 'Buyer', 'Buyer@Example.com')")
+	original := mustZip(t, map[string]string{"pack.json": `{"encrypted":"payload"}`, "meta.txt": "meta"})
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status)
+		VALUES (100, 1, 1, ?, 'Paid', 'per_use', 10, 'published')`, original)
+	mustExec(t, "INSERT INTO credits_transactions (id, user_id, transaction_type, amount, listing_id) VALUES (77, 2, 'purchase', -10, 100)")
+
+	watermark := func(file io.Reader, size int64, sha string) (io.Reader, int64, string) {
+		t.Helper()
+		data, err := readPackForWatermark(100, file, size)
+		if err != nil {
+			t.Fatalf("readPackForWatermark: %v", err)
+		}
+		return watermarkPackDownload(2, 100, file, data, size, sha)
+	}
+
+	// Off by default: the stored reader is passed through untouched.
+	in := bytes.NewReader(original)
+	if out, _, _ := watermark(in, int64(len(original)), "abc"); out != io.Reader(in) {
+		t.Fatal("watermark applied while disabled")
+	}
+
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('pack_watermark_enabled', '1')")
+	out, size, sha := watermark(bytes.NewReader(original), int64(len(original)), packFileSHA256(original))
+	marked, _ := io.ReadAll(out)
+	if int64(len(marked)) != size || sha != packFileSHA256(marked) {
+		t.Fatalf("size/hash do not match the served bytes: size=%d len=%d", size, len(marked))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(marked), int64(len(marked)))
+	if err != nil {
+		t.Fatalf("watermarked file is not a zip: %v", err)
+	}
+	wantMark := "vantagics-wm:100:" + buyerEmailHash(2) + ":77"
+	if zr.Comment != wantMark {
+		t.Fatalf("comment = %q, want %q", zr.Comment, wantMark)
+	}
+	if got, _ := readPackJSON(marked); string(got) != `{"encrypted":"payload"}` {
+		t.Errorf("pack.json changed: %q", got)
+	}
+	var stored []byte
+	db.QueryRow("SELECT file_data FROM pack_listings WHERE id = 100").Scan(&stored)
+	if !bytes.Equal(stored, original) {
+		t.Error("stored pack file was modified")
+	}
+
+	// The buyer of a leaked copy can be looked up.
+	rec := httptest.NewRecorder()
+	handleAdminPackWatermarkLookup(rec, httptest.NewRequest(http.MethodGet, "/api/admin/pack-watermarks?mark="+url.QueryEscape(zr.Comment), nil))
+	var info struct {
+		UserID  int64  `json:"user_id"`
+		Email   string `json:"email"`
+		OrderID int64  `json:"order_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &info)
+	if rec.Code != http.StatusOK || info.UserID != 2 || info.Email != "Buyer@Example.com" || info.OrderID != 77 {
+		t.Fatalf("lookup: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Formats without a watermarker are served unchanged, and without a stored
+	// hash none is invented.
+	out, _, sha = watermark(strings.NewReader("legacy bytes"), 12, "")
+	if b, _ := io.ReadAll(out); string(b) != "legacy bytes" || sha != "" {
+		t.Errorf("unsupported format: %q sha=%q", b, sha)
+	}
+}
+
+func TestWatermarkBuyerHashIsKeyed(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'buyer', 'Buyer', 'buyer@example.com')")
+
+	hash := buyerEmailHash(2)
+	plain := sha256.Sum256([]byte("buyer@example.com"))
+	if len(hash) != 64 || strings.HasPrefix(hex.EncodeToString(plain[:]), hash[:16]) {
+		t.Fatalf("buyer hash %q is a truncated or unkeyed digest", hash)
+	}
+	if buyerEmailHash(2) != hash {
+		t.Error("buyer hash changed between calls")
+	}
+	mustExec(t, "UPDATE settings SET value = 'another secret' WHERE key = ?", packWatermarkSecretKey)
+	if buyerEmailHash(2) == hash {
+		t.Error("buyer hash does not depend on the server secret")
+	}
+}
+
+func TestReadPackForWatermarkLimits(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('pack_watermark_enabled', '1')")
+
+	if _, err := readPackForWatermark(100, iotest.ErrReader(errors.New("disk gone")), 10); err == nil {
+		t.Error("read failure was not reported")
+	}
+	// Packs over the limit are streamed unmarked, without being read.
+	big := strings.NewReader("unread")
+	if data, err := readPackForWatermark(100, big, packWatermarkMaxSize+1); data != nil || err != nil || big.Len() != 6 {
+		t.Errorf("oversized pack: data=%v err=%v unread=%d", data != nil, err, big.Len())
+	}
+	// A stored size that understates the file is caught rather than buffered.
+	if _, err := readPackForWatermark(100, io.LimitReader(zeroReader{}, packWatermarkMaxSize+10), 10); err == nil {
+		t.Error("file larger than the limit was buffered")
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// A pack that cannot be read for watermarking fails the download before the
+// buyer's use is spent.
+func TestWatermarkReadFailureChargesNothing(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+	dir := t.TempDir()
+	storage := &localPackStorage{Dir: dir}
+	key := packFileKey(100, "broken")
+	path, err := storage.path(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A directory opens and stats like a file but cannot be read.
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, `INSERT OR REPLACE INTO settings (key, value) VALUES ('pack_storage_config', ?), ('pack_watermark_enabled', '1')`,
+		`{"backend":"local","local_dir":"`+dir+`"}`)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, credits_balance) VALUES (2, 'sn', 'buyer', 'Buyer', 100)")
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, file_storage, file_key, pack_name, share_mode, credits_price, status, encryption_password)
+		VALUES (100, 1, 1, x'', 'local', ?, 'Pack', 'per_use', 10, 'published', '')`, key)
+	mustExec(t, "INSERT INTO pack_usage_records (user_id, listing_id, used_count, total_purchased) VALUES (2, 100, 0, 5)")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/packs/100/download", nil)
+	req.Header.Set("X-User-ID", "2")
+	rec := httptest.NewRecorder()
+	handleDownloadPack(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("download: status %d: %s", rec.Code, rec.Body)
+	}
+	var used int
+	db.QueryRow("SELECT used_count FROM pack_usage_records WHERE user_id = 2 AND listing_id = 100").Scan(&used)
+	if used != 0 || getWalletBalance(2) != 100 {
+		t.Errorf("failed download spent a use (%d) or credits (balance %v)", used, getWalletBalance(2))
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"marketplace_server/i18n"
+	"marketplace_server/templates"
+)
+
+// Password reset limits.
+const (
+	passwordResetTokenTTL     = 30 * time.Minute
+	passwordResetMinInterval  = time.Minute // minimum gap between two requests for the same email
+	passwordResetMaxPerWindow = 3           // max requests per email within passwordResetWindow
+	passwordResetWindow       = time.Hour
+)
+
+// hashEmailToken returns the hex SHA-256 of a token sent by email. Only the
+// hash is stored, so a leaked database does not expose usable links.
+func hashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestBaseURL returns scheme://host for building absolute links back to this
+// server. The host comes from the client, so links that carry a secret or a
+// payment redirect use siteBaseURL instead.
+func requestBaseURL(r *http.Request) string {
+	scheme := "https"
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") != "https" {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host
+}
+
+// passwordResetRateLimited reports whether another reset email may not be sent
+// to email yet.
+func passwordResetRateLimited(email string, now time.Time) bool {
+	var recent int
+	var last string
+	db.QueryRow(`SELECT COUNT(*), COALESCE(MAX(created_at), '') FROM password_reset_tokens
+		WHERE email = ? AND created_at > ?`,
+		email, now.Add(-passwordResetWindow).UTC().Format("2006-01-02 15:04:05")).Scan(&recent, &last)
+	if recent >= passwordResetMaxPerWindow {
+		return true
+	}
+	if last != "" {
+		t, err := time.Parse("2006-01-02 15:04:05", last)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339, last)
+		}
+		if err == nil && now.Sub(t) < passwordResetMinInterval {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanupPasswordResetTokens deletes tokens that can no longer be used and are
+// outside the rate-limit window.
+func cleanupPasswordResetTokens(now time.Time) {
+	cutoff := now.Add(-24 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.Exec(`DELETE FROM password_reset_tokens WHERE created_at < ?`, cutoff); err != nil {
+		log.Printf("[PASSWORD-RESET] cleanup failed: %v", err)
+	}
+}
+
+// handleUserForgotPassword handles GET/POST /user/forgot-password.
+// The response is the same whether or not the email exists, and a failure to
+// send is only logged, so the page cannot be used to enumerate accounts.
+func handleUserForgotPassword(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.DetectLang(r)
+	render := func(errMsg string, sent bool) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := i18n.TemplateData(r)
+		i18n.MergeTemplateData(data, map[string]interface{}{
+			"CaptchaID": createMathCaptcha(),
+			"Error":     errMsg,
+			"Sent":      sent,
+		})
+		if err := templates.UserForgotPasswordTmpl.Execute(w, data); err != nil {
+			log.Printf("[PASSWORD-RESET] template execute error: %v", err)
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		render("", false)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if !verifyCaptcha(r.FormValue("captcha_id"), strings.TrimSpace(r.FormValue("captcha_answer"))) {
+		render(i18n.T(lang, "captcha_error"), false)
+		return
+	}
+	if email == "" {
+		render(i18n.T(lang, "enter_email"), false)
+		return
+	}
+
+	now := time.Now()
+	var passwordHash string
+	db.QueryRow("SELECT COALESCE(password_hash, '') FROM email_wallets WHERE email = ?", email).Scan(&passwordHash)
+	if passwordHash == "" {
+		log.Printf("[PASSWORD-RESET] no password login for email=%q, not sending", email)
+		render("", true)
+		return
+	}
+	if passwordResetRateLimited(email, now) {
+		log.Printf("[PASSWORD-RESET] rate limited email=%q", email)
+		render("", true)
+		return
+	}
+
+	baseURL, ok := siteBaseURL()
+	if !ok {
+		log.Printf("[PASSWORD-RESET] public_base_url is not set, not sending to email=%q", email)
+		render("", true)
+		return
+	}
+	token := generateSessionID()
+	if _, err := db.Exec(`INSERT INTO password_reset_tokens (email, token_hash, expires_at) VALUES (?, ?, ?)`,
+		email, hashEmailToken(token), now.Add(passwordResetTokenTTL).UTC().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("[PASSWORD-RESET] failed to store token for email=%q: %v", email, err)
+		render("", true)
+		return
+	}
+
+	link := baseURL + "/user/reset-password?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(i18n.T(lang, "reset_email_body"), link, int(passwordResetTokenTTL.Minutes()))
+	if err := sendSystemEmail(email, i18n.T(lang, "reset_email_subject"), body); err != nil {
+		log.Printf("[PASSWORD-RESET] failed to send email to %q: %v", email, err)
+		render("", true)
+		return
+	}
+	log.Printf("[PASSWORD-RESET] reset link sent to email=%q", email)
+	render("", true)
+}
+
+// handleUserResetPassword handles GET/POST /user/reset-password?token=...
+// A valid token lets the user set a new email-level password once.
+func handleUserResetPassword(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.DetectLang(r)
+	token := r.FormValue("token")
+	render := func(errMsg string, invalid, done bool) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := i18n.TemplateData(r)
+		i18n.MergeTemplateData(data, map[string]interface{}{
+			"Token":   token,
+			"Error":   errMsg,
+			"Invalid": invalid,
+			"Done":    done,
+		})
+		if err := templates.UserResetPasswordTmpl.Execute(w, data); err != nil {
+			log.Printf("[PASSWORD-RESET] template execute error: %v", err)
+		}
+	}
+
+	nowStr := time.Now().UTC().Format("2006-01-02 15:04:05")
+	var tokenID int64
+	var email string
+	err := db.QueryRow(`SELECT id, email FROM password_reset_tokens
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		hashEmailToken(token), nowStr).Scan(&tokenID, &email)
+	if token == "" || err != nil {
+		render(i18n.T(lang, "reset_token_invalid"), true, false)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		render("", false, false)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	password := r.FormValue("password")
+	password2 := r.FormValue("password2")
+	if len(password) < 6 {
+		render(i18n.T(lang, "password_min_6"), false, false)
+		return
+	} else if len(password) > 72 {
+		render(i18n.T(lang, "password_max_72"), false, false)
+		return
+	} else if password != password2 {
+		render(i18n.T(lang, "password_mismatch"), false, false)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[PASSWORD-RESET] begin tx failed: %v", err)
+		render(i18n.T(lang, "reset_failed"), false, false)
+		return
+	}
+	defer tx.Rollback()
+
+	// Claim the token first so two concurrent submissions cannot both succeed.
+	res, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`, nowStr, tokenID)
+	if err != nil {
+		log.Printf("[PASSWORD-RESET] failed to mark token used: %v", err)
+		render(i18n.T(lang, "reset_failed"), false, false)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		render(i18n.T(lang, "reset_token_invalid"), true, false)
+		return
+	}
+	if _, err := tx.Exec(`UPDATE email_wallets SET password_hash = ? WHERE email = ?`, hashPassword(password), email); err != nil {
+		log.Printf("[PASSWORD-RESET] failed to update password for email=%q: %v", email, err)
+		render(i18n.T(lang, "reset_failed"), false, false)
+		return
+	}
+	// Any other outstanding links for this email are now stale.
+	if _, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = ? WHERE email = ? AND used_at IS NULL`, nowStr, email); err != nil {
+		log.Printf("[PASSWORD-RESET] failed to invalidate other tokens for email=%q: %v", email, err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[PASSWORD-RESET] commit failed: %v", err)
+		render(i18n.T(lang, "reset_failed"), false, false)
+		return
+	}
+
+	// Log out existing sessions of every account sharing this email.
+	revokeEmailUserSessions(email)
+
+	// Following the emailed link proves ownership of the address.
+	markEmailVerified(email)
+
+	log.Printf("[PASSWORD-RESET] password reset for email=%q", email)
+	render("", false, true)
+}
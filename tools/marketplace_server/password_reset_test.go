@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"marketplace_server/i18n"
+)
+
+// A registered email whose reset link cannot be sent gets the same page as an
+// unknown email, so the form does not reveal which emails have accounts.
+func TestForgotPasswordSameResponseWhenSendingFails(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance, password_hash) VALUES ('alice@example.com', 0, ?)", hashPassword("pw"))
+
+	request := func(email string) string {
+		t.Helper()
+		captchasMu.Lock()
+		captchas["reset-captcha"] = captchaEntry{Code: "7", Expiry: time.Now().Add(time.Minute)}
+		captchasMu.Unlock()
+		form := url.Values{"email": {email}, "captcha_id": {"reset-captcha"}, "captcha_answer": {"7"}}
+		req := httptest.NewRequest(http.MethodPost, "/user/forgot-password", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handleUserForgotPassword(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("forgot password for %s: status %d", email, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	sent := i18n.T(i18n.DetectLang(httptest.NewRequest(http.MethodPost, "/user/forgot-password", nil)), "reset_link_sent")
+
+	// No public_base_url, then no SMTP: neither reveals that alice exists.
+	for _, step := range []string{"no public_base_url", "no SMTP"} {
+		if step == "no SMTP" {
+			mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('public_base_url', 'https://market.example.com')")
+		}
+		for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+			if body := request(email); !strings.Contains(body, sent) {
+				t.Errorf("%s, %s: page does not show the sent message", step, email)
+			}
+		}
+	}
+}
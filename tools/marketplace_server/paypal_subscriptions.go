@@ -0,0 +1,678 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"marketplace_server/i18n"
+)
+
+// Auto-renewing pack subscriptions billed through PayPal Subscriptions.
+//
+// Subscription packs whose billing_cycle is 'monthly' or 'yearly' can be
+// put on auto-renewal from the user portal. A PayPal plan is created per
+// listing, cycle and price (credits_price × months × subscription_usd_per_credit,
+// converted to the settlement currency) and the buyer approves a subscription
+// against it. Every billed cycle is recorded as a 'renew' credits transaction,
+// which extends the access window exactly like a manual renewal. Payments
+// arrive through the PAYMENT.SALE.COMPLETED webhook (and are also picked up on
+// the approval return), keyed by cycle number so retries are not counted twice.
+//
+// When a subscription is cancelled, suspended or expires, the buyer keeps
+// access until the paid period ends plus subscription_grace_days; the sweeper
+// then marks it 'ended'.
+const (
+	defaultSubscriptionGraceDays = 3
+	packSubscriptionSweepRate    = time.Hour
+)
+
+// packBillingCycleMonths returns the months billed per cycle, or 0 when the
+// cycle does not support auto-renewal.
+func packBillingCycleMonths(cycle string) int {
+	switch cycle {
+	case "monthly":
+		return 1
+	case "yearly":
+		return 12
+	}
+	return 0
+}
+
+// subscriptionUSDPerCredit returns the USD price of one credit used for
+// PayPal subscription plans. 0 means auto-renewal is disabled.
+func subscriptionUSDPerCredit() float64 {
+	rate, err := strconv.ParseFloat(getSetting("subscription_usd_per_credit"), 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// subscriptionGraceDays returns how long access survives a lapsed subscription.
+func subscriptionGraceDays() int {
+	if n, err := strconv.Atoi(getSetting("subscription_grace_days")); err == nil && n >= 0 {
+		return n
+	}
+	return defaultSubscriptionGraceDays
+}
+
+// payPalConfigFromSettings loads the PayPal credentials saved in the admin settings.
+func payPalConfigFromSettings() (PayPalConfig, error) {
+	clientID := getSetting("paypal_client_id")
+	encryptedSecret := getSetting("paypal_client_secret")
+	if clientID == "" || encryptedSecret == "" {
+		return PayPalConfig{}, fmt.Errorf("PayPal is not configured")
+	}
+	clientSecret, err := decryptPayPalSecret(encryptedSecret)
+	if err != nil {
+		return PayPalConfig{}, fmt.Errorf("decrypt PayPal secret: %w", err)
+	}
+	return PayPalConfig{ClientID: clientID, ClientSecret: clientSecret, Mode: getSetting("paypal_mode")}, nil
+}
+
+// payPalAPI sends a JSON request to the PayPal REST API and decodes the
+// response into out (when non-nil). requestID, if set, is sent as
+// PayPal-Request-Id so retried creates are idempotent.
+func payPalAPI(config PayPalConfig, method, path, requestID string, body, out interface{}) error {
+	accessToken, err := getPayPalAccessToken(config)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, getPayPalBaseURL(config.Mode)+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if requestID != "" {
+		req.Header.Set("PayPal-Request-Id", requestID)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("PayPal %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read PayPal response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("PayPal %s %s failed with status %d: %s", method, path, resp.StatusCode, string(respBody))
+	}
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse PayPal response: %w", err)
+		}
+	}
+	return nil
+}
+
+// payPalSubscription is the part of a PayPal subscription resource we use.
+type payPalSubscription struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	CustomID    string `json:"custom_id"`
+	BillingInfo struct {
+		NextBillingTime string `json:"next_billing_time"`
+		CycleExecutions []struct {
+			TenureType      string `json:"tenure_type"`
+			CyclesCompleted int    `json:"cycles_completed"`
+		} `json:"cycle_executions"`
+	} `json:"billing_info"`
+	Links []struct {
+		Href string `json:"href"`
+		Rel  string `json:"rel"`
+	} `json:"links"`
+}
+
+// cyclesCompleted returns how many regular cycles PayPal has billed.
+func (s *payPalSubscription) cyclesCompleted() int {
+	for _, c := range s.BillingInfo.CycleExecutions {
+		if c.TenureType == "REGULAR" {
+			return c.CyclesCompleted
+		}
+	}
+	return 0
+}
+
+// ensurePayPalPlan returns the PayPal plan for a listing's cycle and price,
+// creating the catalog product and plan on first use.
+func ensurePayPalPlan(config PayPalConfig, listingID int64, packName, cycle, currency, amount string) (string, error) {
+	var planID string
+	err := db.QueryRow(`SELECT paypal_plan_id FROM paypal_subscription_plans
+		WHERE listing_id = ? AND billing_cycle = ? AND currency = ? AND amount = ?`,
+		listingID, cycle, currency, amount).Scan(&planID)
+	if err == nil {
+		return planID, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	var product struct {
+		ID string `json:"id"`
+	}
+	if err := payPalAPI(config, "POST", "/v1/catalogs/products", fmt.Sprintf("pack-%d-product", listingID),
+		map[string]string{"name": packName, "type": "DIGITAL", "category": "SOFTWARE"}, &product); err != nil {
+		return "", err
+	}
+
+	intervalUnit := "MONTH"
+	if cycle == "yearly" {
+		intervalUnit = "YEAR"
+	}
+	var plan struct {
+		ID string `json:"id"`
+	}
+	if err := payPalAPI(config, "POST", "/v1/billing/plans", fmt.Sprintf("pack-%d-%s-%s-%s", listingID, cycle, currency, amount),
+		map[string]interface{}{
+			"product_id": product.ID,
+			"name":       fmt.Sprintf("%s (%s)", packName, cycle),
+			"billing_cycles": []map[string]interface{}{{
+				"frequency":    map[string]interface{}{"interval_unit": intervalUnit, "interval_count": 1},
+				"tenure_type":  "REGULAR",
+				"sequence":     1,
+				"total_cycles": 0,
+				"pricing_scheme": map[string]interface{}{
+					"fixed_price": map[string]string{"value": amount, "currency_code": currency},
+				},
+			}},
+			"payment_preferences": map[string]interface{}{
+				"auto_bill_outstanding":     true,
+				"payment_failure_threshold": 3,
+			},
+		}, &plan); err != nil {
+		return "", err
+	}
+
+	if _, err := db.Exec(`INSERT OR IGNORE INTO paypal_subscription_plans
+		(listing_id, billing_cycle, currency, amount, paypal_product_id, paypal_plan_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		listingID, cycle, currency, amount, product.ID, plan.ID); err != nil {
+		log.Printf("[PACK-SUB] failed to save plan %s for listing %d: %v", plan.ID, listingID, err)
+	}
+	return plan.ID, nil
+}
+
+// handleUserPackSubscribe starts a PayPal subscription for a pack.
+// POST /user/pack/subscribe
+// Form params: listing_id
+func handleUserPackSubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/user/", http.StatusFound)
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	listingID, err := strconv.ParseInt(r.FormValue("listing_id"), 10, 64)
+	if err != nil || listingID <= 0 {
+		http.Redirect(w, r, "/user/?error=invalid_listing", http.StatusFound)
+		return
+	}
+
+	var shareMode, packName, cycle string
+	var creditsPrice int
+	err = db.QueryRow(`SELECT share_mode, credits_price, pack_name, COALESCE(billing_cycle, '')
+		FROM pack_listings WHERE id = ? AND status = 'published'`, listingID).Scan(&shareMode, &creditsPrice, &packName, &cycle)
+	if err == sql.ErrNoRows {
+		http.Redirect(w, r, "/user/?error=pack_not_found", http.StatusFound)
+		return
+	} else if err != nil {
+		log.Printf("[PACK-SUB] failed to query pack listing %d: %v", listingID, err)
+		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
+		return
+	}
+	months := packBillingCycleMonths(cycle)
+	usdPerCredit := subscriptionUSDPerCredit()
+	if shareMode != "subscription" || months == 0 || usdPerCredit <= 0 {
+		http.Redirect(w, r, "/user/?error=auto_renew_unavailable", http.StatusFound)
+		return
+	}
+
+	var existing int
+	db.QueryRow(`SELECT COUNT(*) FROM pack_subscriptions WHERE user_id = ? AND listing_id = ? AND status = 'active'`,
+		userID, listingID).Scan(&existing)
+	if existing > 0 {
+		http.Redirect(w, r, "/user/?error=already_subscribed", http.StatusFound)
+		return
+	}
+
+	config, err := payPalConfigFromSettings()
+	if err != nil {
+		log.Printf("[PACK-SUB] %v", err)
+		http.Redirect(w, r, "/user/?error=payment_unavailable", http.StatusFound)
+		return
+	}
+	baseURL, ok := siteBaseURL()
+	if !ok {
+		http.Redirect(w, r, "/user/?error=payment_unavailable", http.StatusFound)
+		return
+	}
+	currency := settlementCurrency()
+	price, ok := convertUSD(float64(creditsPrice*months)*usdPerCredit, currency)
+	if !ok || price <= 0 {
+		log.Printf("[PACK-SUB] no exchange rate for settlement currency %s", currency)
+		http.Redirect(w, r, "/user/?error=payment_unavailable", http.StatusFound)
+		return
+	}
+	planID, err := ensurePayPalPlan(config, listingID, packName, cycle, currency, i18n.FormatAmount(price, currency))
+	if err != nil {
+		log.Printf("[PACK-SUB] failed to create plan for listing %d: %v", listingID, err)
+		http.Redirect(w, r, "/user/?error=payment_failed", http.StatusFound)
+		return
+	}
+
+	var sub payPalSubscription
+	if err := payPalAPI(config, "POST", "/v1/billing/subscriptions", "", map[string]interface{}{
+		"plan_id":   planID,
+		"custom_id": fmt.Sprintf("%d:%d", userID, listingID),
+		"application_context": map[string]string{
+			"user_action":         "SUBSCRIBE_NOW",
+			"shipping_preference": "NO_SHIPPING",
+			"return_url":          baseURL + "/user/pack/subscribe/return",
+			"cancel_url":          baseURL + "/user/?error=subscription_cancelled",
+		},
+	}, &sub); err != nil {
+		log.Printf("[PACK-SUB] failed to create subscription for user %d, listing %d: %v", userID, listingID, err)
+		http.Redirect(w, r, "/user/?error=payment_failed", http.StatusFound)
+		return
+	}
+
+	approveURL := ""
+	for _, l := range sub.Links {
+		if l.Rel == "approve" {
+			approveURL = l.Href
+		}
+	}
+	if sub.ID == "" || approveURL == "" {
+		log.Printf("[PACK-SUB] subscription response for user %d has no approve link", userID)
+		http.Redirect(w, r, "/user/?error=payment_failed", http.StatusFound)
+		return
+	}
+	if _, err := db.Exec(`INSERT INTO pack_subscriptions (user_id, listing_id, billing_cycle, paypal_subscription_id, status)
+		VALUES (?, ?, ?, ?, 'pending')`, userID, listingID, cycle, sub.ID); err != nil {
+		log.Printf("[PACK-SUB] failed to save subscription %s: %v", sub.ID, err)
+		http.Redirect(w, r, "/user/?error=internal", http.StatusFound)
+		return
+	}
+	log.Printf("[PACK-SUB] user %d started subscription %s for pack %d (%s %s %s)", userID, sub.ID, listingID, cycle, i18n.FormatAmount(price, currency), currency)
+	http.Redirect(w, r, approveURL, http.StatusFound)
+}
+
+// handleUserPackSubscribeReturn handles the buyer's return from PayPal after
+// approving a subscription.
+// GET /user/pack/subscribe/return?subscription_id=...
+func handleUserPackSubscribeReturn(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	subID := r.URL.Query().Get("subscription_id")
+	var owner int64
+	if err := db.QueryRow(`SELECT user_id FROM pack_subscriptions WHERE paypal_subscription_id = ?`, subID).Scan(&owner); err != nil || owner != userID {
+		http.Redirect(w, r, "/user/?error=subscription_not_found", http.StatusFound)
+		return
+	}
+	if err := syncPackSubscription(subID); err != nil {
+		log.Printf("[PACK-SUB] sync after approval of %s failed: %v", subID, err)
+		// The webhook will catch up once PayPal finishes activating it.
+	}
+	http.Redirect(w, r, "/user/?success=auto_renew_enabled", http.StatusFound)
+}
+
+// handleUserPackSubscribeCancel turns off auto-renewal. Access continues
+// until the paid period and grace period run out.
+// POST /user/pack/subscribe/cancel
+// Form params: listing_id
+func handleUserPackSubscribeCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/user/", http.StatusFound)
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	listingID, err := strconv.ParseInt(r.FormValue("listing_id"), 10, 64)
+	if err != nil || listingID <= 0 {
+		http.Redirect(w, r, "/user/?error=invalid_listing", http.StatusFound)
+		return
+	}
+	var subID string
+	err = db.QueryRow(`SELECT paypal_subscription_id FROM pack_subscriptions
+		WHERE user_id = ? AND listing_id = ? AND status = 'active' ORDER BY id DESC LIMIT 1`, userID, listingID).Scan(&subID)
+	if err != nil {
+		http.Redirect(w, r, "/user/?error=subscription_not_found", http.StatusFound)
+		return
+	}
+	config, err := payPalConfigFromSettings()
+	if err == nil {
+		err = payPalAPI(config, "POST", "/v1/billing/subscriptions/"+url.PathEscape(subID)+"/cancel", "",
+			map[string]string{"reason": "Cancelled by buyer"}, nil)
+	}
+	if err != nil {
+		log.Printf("[PACK-SUB] failed to cancel subscription %s: %v", subID, err)
+		http.Redirect(w, r, "/user/?error=payment_failed", http.StatusFound)
+		return
+	}
+	endPackSubscription(subID, "cancelled")
+	log.Printf("[PACK-SUB] user %d cancelled subscription %s for pack %d", userID, subID, listingID)
+	http.Redirect(w, r, "/user/?success=auto_renew_cancelled", http.StatusFound)
+}
+
+// syncPackSubscription fetches a subscription from PayPal, updates its status
+// and records any billed cycle not yet recorded.
+func syncPackSubscription(subID string) error {
+	config, err := payPalConfigFromSettings()
+	if err != nil {
+		return err
+	}
+	var sub payPalSubscription
+	if err := payPalAPI(config, "GET", "/v1/billing/subscriptions/"+url.PathEscape(subID), "", nil, &sub); err != nil {
+		return err
+	}
+	switch sub.Status {
+	case "ACTIVE":
+		var nextBilling time.Time
+		if t, err := time.Parse(time.RFC3339, sub.BillingInfo.NextBillingTime); err == nil {
+			nextBilling = t
+		}
+		return recordPackSubscriptionPayment(subID, sub.cyclesCompleted(), nextBilling)
+	case "CANCELLED":
+		endPackSubscription(subID, "cancelled")
+	case "SUSPENDED":
+		endPackSubscription(subID, "suspended")
+	case "EXPIRED":
+		endPackSubscription(subID, "expired")
+	}
+	return nil
+}
+
+// recordPackSubscriptionPayment records billed cycle number cycle of a
+// subscription as a 'renew' transaction and extends the paid period to
+// paidThrough (or by one billing cycle when PayPal did not report it).
+// Already recorded cycles are ignored.
+func recordPackSubscriptionPayment(subID string, cycle int, paidThrough time.Time) error {
+	if cycle <= 0 {
+		return nil // approved, but the first payment has not gone through yet
+	}
+	var id, userID, listingID int64
+	var billingCycle, packName string
+	var creditsPrice int
+	err := db.QueryRow(`SELECT ps.id, ps.user_id, ps.listing_id, ps.billing_cycle, pl.pack_name, pl.credits_price
+		FROM pack_subscriptions ps JOIN pack_listings pl ON pl.id = ps.listing_id
+		WHERE ps.paypal_subscription_id = ?`, subID).Scan(&id, &userID, &listingID, &billingCycle, &packName, &creditsPrice)
+	if err != nil {
+		return fmt.Errorf("load subscription %s: %w", subID, err)
+	}
+	months := packBillingCycleMonths(billingCycle)
+	if months == 0 {
+		months = 1
+	}
+	if paidThrough.IsZero() {
+		paidThrough = time.Now().UTC().AddDate(0, months, 0)
+	}
+	paidThrough = paidThrough.UTC()
+	graceUntil := paidThrough.AddDate(0, 0, subscriptionGraceDays())
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT OR IGNORE INTO pack_subscription_payments (subscription_id, cycle) VALUES (?, ?)`, id, cycle)
+	if err != nil {
+		return err
+	}
+	newPayment := false
+	if n, _ := res.RowsAffected(); n > 0 {
+		newPayment = true
+		// The amount is the credits equivalent of the cycle; it is paid through
+		// PayPal, so the wallet is not touched (paid_externally) but author
+		// revenue still counts it.
+		description := fmt.Sprintf("Renew subscription (%d month): %s (PayPal auto-renewal)", months, packName)
+		if _, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, description, paid_externally)
+			VALUES (?, 'renew', ?, ?, ?, 1)`, userID, -float64(creditsPrice*months), listingID, description); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE pack_subscriptions SET status = 'active', paid_through = ?, grace_until = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, paidThrough.Format("2006-01-02 15:04:05"), graceUntil.Format("2006-01-02 15:04:05"), id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if newPayment {
+		publishPackSaleEvent(listingID, "renew", float64(creditsPrice*months))
+	}
+
+	if err := upsertUserPurchasedPack(userID, listingID); err != nil {
+		log.Printf("[PACK-SUB] failed to upsert user purchased pack (user=%d, listing=%d): %v", userID, listingID, err)
+	}
+	globalCache.InvalidateUserPurchased(userID)
+	log.Printf("[PACK-SUB] subscription %s cycle %d recorded for user %d, pack %d, paid through %s",
+		subID, cycle, userID, listingID, paidThrough.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// endPackSubscription marks a subscription as no longer renewing. Access is
+// kept until grace days after the paid period.
+func endPackSubscription(subID, status string) {
+	var paidThrough string
+	if err := db.QueryRow(`SELECT COALESCE(paid_through, '') FROM pack_subscriptions WHERE paypal_subscription_id = ?`, subID).Scan(&paidThrough); err != nil {
+		log.Printf("[PACK-SUB] unknown subscription %s (%s)", subID, status)
+		return
+	}
+	graceUntil := ""
+	if t, err := time.Parse("2006-01-02 15:04:05", paidThrough); err == nil {
+		graceUntil = t.AddDate(0, 0, subscriptionGraceDays()).Format("2006-01-02 15:04:05")
+	}
+	if _, err := db.Exec(`UPDATE pack_subscriptions SET status = ?, grace_until = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE paypal_subscription_id = ? AND status IN ('pending', 'active', 'suspended')`, status, graceUntil, subID); err != nil {
+		log.Printf("[PACK-SUB] failed to mark subscription %s %s: %v", subID, status, err)
+		return
+	}
+	log.Printf("[PACK-SUB] subscription %s is now %s, access until %s", subID, status, graceUntil)
+}
+
+// packSubscriptionAccessUntil returns when a user's PayPal subscription to a
+// pack stops granting access (the paid period plus the grace period), or the
+// zero time if there is none.
+func packSubscriptionAccessUntil(userID, listingID int64) time.Time {
+	var graceUntil sql.NullString
+	db.QueryRow(`SELECT MAX(grace_until) FROM pack_subscriptions
+		WHERE user_id = ? AND listing_id = ? AND status != 'ended' AND grace_until != ''`, userID, listingID).Scan(&graceUntil)
+	if t, err := time.Parse("2006-01-02 15:04:05", graceUntil.String); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// startPackSubscriptionSweeper ends subscriptions whose grace period is over.
+func startPackSubscriptionSweeper() {
+	go func() {
+		ticker := time.NewTicker(packSubscriptionSweepRate)
+		defer ticker.Stop()
+		for {
+			sweepPackSubscriptions(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// sweepPackSubscriptions revokes access for subscriptions past their grace
+// period, including active ones whose renewal payment never arrived, and
+// drops approvals that were abandoned before the first payment.
+func sweepPackSubscriptions(now time.Time) {
+	nowStr := now.UTC().Format("2006-01-02 15:04:05")
+	res, err := db.Exec(`UPDATE pack_subscriptions SET status = 'ended', updated_at = CURRENT_TIMESTAMP
+		WHERE status != 'ended' AND grace_until != '' AND grace_until < ?`, nowStr)
+	if err != nil {
+		log.Printf("[PACK-SUB] sweep failed: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("[PACK-SUB] ended %d lapsed subscriptions", n)
+	}
+	db.Exec(`UPDATE pack_subscriptions SET status = 'ended', updated_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND created_at < ?`, now.UTC().Add(-24*time.Hour).Format("2006-01-02 15:04:05"))
+}
+
+// verifyPayPalWebhook asks PayPal to verify a webhook delivery against the
+// configured webhook ID.
+func verifyPayPalWebhook(config PayPalConfig, r *http.Request, body []byte) bool {
+	webhookID := getSetting("paypal_webhook_id")
+	if webhookID == "" {
+		log.Printf("[PAYPAL-WEBHOOK] paypal_webhook_id is not configured, rejecting event")
+		return false
+	}
+	var result struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	err := payPalAPI(config, "POST", "/v1/notifications/verify-webhook-signature", "", map[string]interface{}{
+		"auth_algo":         r.Header.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          r.Header.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   r.Header.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  r.Header.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": r.Header.Get("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        webhookID,
+		"webhook_event":     json.RawMessage(body),
+	}, &result)
+	if err != nil {
+		log.Printf("[PAYPAL-WEBHOOK] verification request failed: %v", err)
+		return false
+	}
+	return result.VerificationStatus == "SUCCESS"
+}
+
+// handlePayPalWebhook receives PayPal subscription events.
+// POST /paypal/webhook
+func handlePayPalWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	config, err := payPalConfigFromSettings()
+	if err != nil {
+		log.Printf("[PAYPAL-WEBHOOK] %v", err)
+		http.Error(w, "not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if !verifyPayPalWebhook(config, r, body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event struct {
+		ID        string `json:"id"`
+		EventType string `json:"event_type"`
+		Resource  struct {
+			ID                 string `json:"id"`
+			BillingAgreementID string `json:"billing_agreement_id"`
+		} `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	subID := ""
+	switch {
+	case event.EventType == "PAYMENT.SALE.COMPLETED":
+		subID = event.Resource.BillingAgreementID
+	case strings.HasPrefix(event.EventType, "BILLING.SUBSCRIPTION."):
+		subID = event.Resource.ID
+	}
+	if subID == "" {
+		w.WriteHeader(http.StatusOK) // not a subscription event
+		return
+	}
+	var known int
+	db.QueryRow(`SELECT COUNT(*) FROM pack_subscriptions WHERE paypal_subscription_id = ?`, subID).Scan(&known)
+	if known == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	log.Printf("[PAYPAL-WEBHOOK] %s %s for subscription %s", event.ID, event.EventType, subID)
+	// The event payload is only a trigger; the subscription is re-read from
+	// PayPal so out-of-order deliveries converge on the current state.
+	if err := syncPackSubscription(subID); err != nil {
+		log.Printf("[PAYPAL-WEBHOOK] sync of %s failed: %v", subID, err)
+		http.Error(w, "retry later", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAdminPackSubscriptionSettings handles GET/POST /admin/settings/pack-subscriptions.
+func handleAdminPackSubscriptionSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		var active int
+		db.QueryRow(`SELECT COUNT(*) FROM pack_subscriptions WHERE status = 'active'`).Scan(&active)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"usd_per_credit":       subscriptionUSDPerCredit(),
+			"grace_days":           subscriptionGraceDays(),
+			"webhook_id":           getSetting("paypal_webhook_id"),
+			"active_subscriptions": active,
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rate, err := strconv.ParseFloat(strings.TrimSpace(r.FormValue("usd_per_credit")), 64)
+	if err != nil || rate < 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "每积分美元价格必须为非负数"})
+		return
+	}
+	grace, err := strconv.Atoi(strings.TrimSpace(r.FormValue("grace_days")))
+	if err != nil || grace < 0 || grace > 60 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "宽限天数必须在 0 到 60 之间"})
+		return
+	}
+	for key, value := range map[string]string{
+		"subscription_usd_per_credit": strconv.FormatFloat(rate, 'f', -1, 64),
+		"subscription_grace_days":     strconv.Itoa(grace),
+		"paypal_webhook_id":           strings.TrimSpace(r.FormValue("webhook_id")),
+	} {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPackSubscriptionRenewalsAndGracePeriod(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'alice', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, billing_cycle) VALUES (100, 1, 1, x'00', 'Pack', 'subscription', 200, 'monthly')")
+	mustExec(t, "INSERT INTO pack_subscriptions (user_id, listing_id, billing_cycle, paypal_subscription_id) VALUES (2, 100, 'monthly', 'I-SUB')")
+	mustExec(t, "INSERT INTO settings (key, value) VALUES ('subscription_grace_days', '3')")
+
+	paidThrough := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Second)
+	// Cycle 1 arrives twice (approval return, then webhook); cycle 2 once.
+	for _, cycle := range []int{1, 1, 2} {
+		if err := recordPackSubscriptionPayment("I-SUB", cycle, paidThrough); err != nil {
+			t.Fatalf("record cycle %d: %v", cycle, err)
+		}
+	}
+	var renewals int
+	var total float64
+	db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM credits_transactions
+		WHERE user_id = 2 AND listing_id = 100 AND transaction_type = 'renew' AND paid_externally = 1`).Scan(&renewals, &total)
+	if renewals != 2 || total != -400 {
+		t.Errorf("PayPal renewals = %d totalling %v, want 2 totalling -400", renewals, total)
+	}
+
+	until := packSubscriptionAccessUntil(2, 100)
+	if want := paidThrough.AddDate(0, 0, 3); !until.Equal(want) {
+		t.Errorf("access until %v, want %v", until, want)
+	}
+
+	// Cancelling keeps access through the grace period; the sweep revokes it afterwards.
+	endPackSubscription("I-SUB", "cancelled")
+	sweepPackSubscriptions(time.Now())
+	if packSubscriptionAccessUntil(2, 100).IsZero() {
+		t.Fatal("access revoked before the grace period ended")
+	}
+	sweepPackSubscriptions(paidThrough.AddDate(0, 0, 4))
+	if !packSubscriptionAccessUntil(2, 100).IsZero() {
+		t.Error("access kept after the grace period ended")
+	}
+	var status string
+	db.QueryRow(`SELECT status FROM pack_subscriptions WHERE paypal_subscription_id = 'I-SUB'`).Scan(&status)
+	if status != "ended" {
+		t.Errorf("status = %q, want ended", status)
+	}
+}
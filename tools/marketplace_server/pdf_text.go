@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// A minimal text-only PDF writer for receipts. Text is set in the Adobe
+// predefined CJK font STSong-Light with the UniGB-UCS2-H encoding, which
+// every mainstream PDF viewer ships, so Chinese and Latin text render without
+// embedding a font file. Characters outside the BMP are replaced by '?' and
+// control characters by spaces.
+const (
+	pdfPageWidth  = 595 // A4, in points
+	pdfPageHeight = 842
+	pdfMargin     = 56
+)
+
+type pdfTextLine struct {
+	text string
+	size float64
+	gap  float64 // extra space above the line
+}
+
+// pdfDocument collects lines of text and lays them out top to bottom,
+// starting a new page when one fills up.
+type pdfDocument struct {
+	lines []pdfTextLine
+}
+
+// Heading adds a line of large text.
+func (d *pdfDocument) Heading(text string) {
+	d.lines = append(d.lines, pdfTextLine{text: text, size: 18, gap: 6})
+}
+
+// Text adds body text, wrapped to the page width.
+func (d *pdfDocument) Text(text string) {
+	d.addWrapped(text, 11, 0)
+}
+
+// Small adds small text, wrapped to the page width.
+func (d *pdfDocument) Small(text string) {
+	d.addWrapped(text, 9, 0)
+}
+
+// Space adds vertical space before the next line.
+func (d *pdfDocument) Space() {
+	d.lines = append(d.lines, pdfTextLine{gap: 10})
+}
+
+func (d *pdfDocument) addWrapped(text string, size, gap float64) {
+	maxWidth := float64(pdfPageWidth-2*pdfMargin) / size
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		for _, line := range wrapPDFText(para, maxWidth) {
+			d.lines = append(d.lines, pdfTextLine{text: line, size: size, gap: gap})
+			gap = 0
+		}
+	}
+}
+
+// pdfGlyphWidth is the advance of r in ems: CJK glyphs are full width, the
+// ASCII range of STSong-Light is half width.
+func pdfGlyphWidth(r rune) float64 {
+	if r < 0x80 {
+		return 0.5
+	}
+	return 1
+}
+
+// wrapPDFText breaks s into lines no wider than maxWidth ems, preferring to
+// break Latin text at spaces.
+func wrapPDFText(s string, maxWidth float64) []string {
+	if s == "" {
+		return []string{""}
+	}
+	var lines []string
+	runes := []rune(s)
+	for len(runes) > 0 {
+		width, end, lastSpace := 0.0, 0, -1
+		for end < len(runes) {
+			w := pdfGlyphWidth(runes[end])
+			if width+w > maxWidth {
+				break
+			}
+			if runes[end] == ' ' {
+				lastSpace = end
+			}
+			width += w
+			end++
+		}
+		if end < len(runes) && lastSpace > 0 {
+			end = lastSpace + 1
+		}
+		if end == 0 {
+			end = 1
+		}
+		lines = append(lines, strings.TrimRight(string(runes[:end]), " "))
+		runes = runes[end:]
+	}
+	return lines
+}
+
+// pdfHexText encodes s as a UCS-2 big-endian hex string operand.
+func pdfHexText(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		if r > 0xFFFF || r == utf8.RuneError {
+			r = '?'
+		} else if r < 0x20 {
+			r = ' '
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// Bytes renders the document as a PDF file.
+func (d *pdfDocument) Bytes() []byte {
+	// Lay the lines out into page content streams.
+	var pages []string
+	var content strings.Builder
+	y := float64(pdfPageHeight - pdfMargin)
+	for _, l := range d.lines {
+		lineHeight := l.size*1.5 + l.gap
+		if y-lineHeight < pdfMargin && content.Len() > 0 {
+			pages = append(pages, content.String())
+			content.Reset()
+			y = float64(pdfPageHeight - pdfMargin)
+		}
+		y -= lineHeight
+		if l.text != "" {
+			fmt.Fprintf(&content, "BT /F1 %g Tf %d %.2f Td %s Tj ET\n", l.size, pdfMargin, y, pdfHexText(l.text))
+		}
+	}
+	pages = append(pages, content.String())
+
+	// Objects 1-5 are the catalog, page tree and font; each page adds a page
+	// object and its content stream.
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	objects = append(objects, "<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>")
+	objects = append(objects, "<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	objects = append(objects, "<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] "+
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	for i, stream := range pages {
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 7+2*i))
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(stream), stream))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Permission is an admin permission key as stored, comma-separated, in
+// admin_credentials.permissions.
+type Permission string
+
+const (
+	PermCategories        Permission = "categories"
+	PermMarketplace       Permission = "marketplace"
+	PermAccounts          Permission = "accounts"
+	PermAuthors           Permission = "authors"
+	PermCustomers         Permission = "customers"
+	PermReview            Permission = "review"
+	PermSettings          Permission = "settings"
+	PermSales             Permission = "sales"
+	PermNotifications     Permission = "notifications"
+	PermBilling           Permission = "billing"
+	PermStorefrontSupport Permission = "storefront_support"
+	PermKYC               Permission = "kyc"
+	PermImpersonate       Permission = "impersonate"
+	PermBackup            Permission = "backup"
+)
+
+// permissionInfo describes one assignable permission.
+type permissionInfo struct {
+	Key Permission `json:"key"`
+	// Label is the Chinese display name; LabelKey the i18n key for it.
+	Label    string `json:"label"`
+	LabelKey string `json:"label_key"`
+	// Legacy permissions predate "accounts" and are kept so existing grants
+	// keep working; they are not offered for new admins.
+	Legacy bool `json:"legacy"`
+}
+
+// permissionRegistry is the single list of admin permissions. permissionAuth
+// refuses any key not listed here, and the admin UI builds its permission
+// checkboxes from it via /api/admin/permissions.
+var permissionRegistry = []permissionInfo{
+	{PermCategories, "分类管理", "category_mgmt", false},
+	{PermMarketplace, "市场管理", "marketplace_mgmt", false},
+	{PermAccounts, "账号管理", "account_mgmt", false},
+	{PermAuthors, "作者管理", "author_mgmt", true},
+	{PermCustomers, "客户管理", "customer_mgmt", true},
+	{PermReview, "审核管理", "review_mgmt", false},
+	{PermSettings, "系统设置", "system_settings", false},
+	{PermSales, "销售管理", "sales_mgmt", false},
+	{PermNotifications, "消息管理", "notification_mgmt", false},
+	{PermBilling, "收费管理", "billing_mgmt", false},
+	{PermStorefrontSupport, "店铺支持", "storefront_support_mgmt", false},
+	{PermKYC, "实名审核", "kyc_mgmt", false},
+	{PermImpersonate, "模拟登录", "impersonate_mgmt", false},
+	{PermBackup, "数据库备份", "backup_mgmt", false},
+}
+
+// permissionAliases lists, for a permission, the other grants that also
+// satisfy it. "accounts" merged the author and customer sections, so each
+// side accepts the other.
+var permissionAliases = map[Permission][]Permission{
+	PermAccounts:  {PermAuthors, PermCustomers},
+	PermAuthors:   {PermAccounts},
+	PermCustomers: {PermAccounts},
+}
+
+// lookupPermission returns the registry entry for p.
+func lookupPermission(p Permission) (permissionInfo, bool) {
+	for _, info := range permissionRegistry {
+		if info.Key == p {
+			return info, true
+		}
+	}
+	return permissionInfo{}, false
+}
+
+// registeredPermissionKeys returns every registered permission key.
+func registeredPermissionKeys() []string {
+	keys := make([]string, len(permissionRegistry))
+	for i, info := range permissionRegistry {
+		keys[i] = string(info.Key)
+	}
+	return keys
+}
+
+// filterKnownPermissions drops unknown and duplicate keys from perms.
+func filterKnownPermissions(perms []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range perms {
+		if _, ok := lookupPermission(Permission(p)); ok && !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// permissionDenied writes the 403 returned when an admin lacks perm.
+func permissionDenied(w http.ResponseWriter, perm Permission) {
+	label := string(perm)
+	if info, ok := lookupPermission(perm); ok {
+		label = info.Label
+	}
+	jsonResponse(w, http.StatusForbidden, map[string]string{
+		"error":      "permission_denied",
+		"permission": string(perm),
+		"message":    fmt.Sprintf("没有权限：需要「%s」权限", label),
+	})
+}
+
+// handleAdminPermissions handles GET /api/admin/permissions. It lists the
+// registered permissions and marks the ones the current admin holds.
+func handleAdminPermissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	type entry struct {
+		permissionInfo
+		Granted bool `json:"granted"`
+	}
+	list := make([]entry, len(permissionRegistry))
+	for i, info := range permissionRegistry {
+		list[i] = entry{info, hasPermission(adminID, info.Key)}
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"permissions": list})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// PriceChange is one row of price_change_history. Pack prices are in
+// credits, custom product prices in USD.
+type PriceChange struct {
+	ID        int64   `json:"id"`
+	ItemType  string  `json:"item_type"` // "pack" or "custom_product"
+	ItemID    int64   `json:"item_id"`
+	OldPrice  float64 `json:"old_price"`
+	NewPrice  float64 `json:"new_price"`
+	ChangedBy int64   `json:"changed_by"` // user ID
+	Source    string  `json:"source"`     // "edit" or "bulk"
+	CreatedAt string  `json:"created_at"` // RFC 3339
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordPriceChange appends an audit row to price_change_history.
+func recordPriceChange(e execer, itemType string, itemID, userID int64, oldPrice, newPrice float64, source string) error {
+	_, err := e.Exec(`INSERT INTO price_change_history (item_type, item_id, user_id, old_price, new_price, source)
+		VALUES (?, ?, ?, ?, ?, ?)`, itemType, itemID, userID, oldPrice, newPrice, source)
+	return err
+}
+
+// logPriceChange records a single-item price edit. Failures are logged and
+// never fail the edit itself.
+func logPriceChange(itemType string, itemID, userID int64, oldPrice, newPrice float64) {
+	if oldPrice == newPrice {
+		return
+	}
+	if err := recordPriceChange(db, itemType, itemID, userID, oldPrice, newPrice, "edit"); err != nil {
+		log.Printf("[PRICE-HISTORY] failed to record %s %d price change: %v", itemType, itemID, err)
+	}
+}
+
+// queryPriceHistory returns the newest price changes first, optionally
+// restricted to one item (itemID 0 means every item of itemType, and an empty
+// itemType means every item).
+func queryPriceHistory(itemType string, itemID int64, limit int) ([]PriceChange, error) {
+	query := `SELECT id, item_type, item_id, old_price, new_price, user_id, source, created_at
+		FROM price_change_history WHERE 1=1`
+	var args []interface{}
+	if itemType != "" {
+		query += " AND item_type = ?"
+		args = append(args, itemType)
+	}
+	if itemID > 0 {
+		query += " AND item_id = ?"
+		args = append(args, itemID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := []PriceChange{}
+	for rows.Next() {
+		var c PriceChange
+		if err := rows.Scan(&c.ID, &c.ItemType, &c.ItemID, &c.OldPrice, &c.NewPrice, &c.ChangedBy, &c.Source, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		c.CreatedAt = publishAtRFC3339(c.CreatedAt)
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
+// parsePriceHistoryItem reads item_type and item_id from the query string.
+func parsePriceHistoryItem(r *http.Request) (itemType string, itemID int64, ok bool) {
+	itemType = r.URL.Query().Get("item_type")
+	if itemType != "" && itemType != "pack" && itemType != "custom_product" {
+		return "", 0, false
+	}
+	if s := r.URL.Query().Get("item_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 || itemType == "" {
+			return "", 0, false
+		}
+		itemID = id
+	}
+	return itemType, itemID, true
+}
+
+// handleAuthorPriceHistory handles
+// GET /user/author/price-history?item_type=pack|custom_product&item_id=N.
+// It returns the price history of one of the caller's packs or products.
+func handleAuthorPriceHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+	itemType, itemID, ok := parsePriceHistoryItem(r)
+	if !ok || itemID == 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请指定 item_type 和 item_id"})
+		return
+	}
+
+	var ownerID int64
+	if itemType == "pack" {
+		err = db.QueryRow("SELECT user_id FROM pack_listings WHERE id = ?", itemID).Scan(&ownerID)
+	} else {
+		err = db.QueryRow(`SELECT s.user_id FROM custom_products p
+			JOIN author_storefronts s ON s.id = p.storefront_id WHERE p.id = ?`, itemID).Scan(&ownerID)
+	}
+	if err != nil || ownerID != userID {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "商品不存在"})
+		return
+	}
+
+	history, err := queryPriceHistory(itemType, itemID, 500)
+	if err != nil {
+		log.Printf("[PRICE-HISTORY] query history of %s %d error: %v", itemType, itemID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "加载数据失败"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"history": history})
+}
+
+// handleAdminPriceHistory handles GET /api/admin/price-history with optional
+// item_type and item_id filters, returning the latest 500 changes.
+func handleAdminPriceHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	itemType, itemID, ok := parsePriceHistoryItem(r)
+	if !ok {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_item"})
+		return
+	}
+	history, err := queryPriceHistory(itemType, itemID, 500)
+	if err != nil {
+		log.Printf("[PRICE-HISTORY] admin query error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"history": history})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPriceHistory(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	if _, err := db.Exec("INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status) VALUES (100, 1, 1, x'00', 'Paid', 'per_use', 50, 'published')"); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(price string) {
+		t.Helper()
+		form := url.Values{"listing_id": {"100"}, "pack_name": {"Paid"}, "share_mode": {"per_use"}, "credits_price": {price}}
+		req := httptest.NewRequest(http.MethodPost, "/user/author/edit-pack", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Requested-With", "XMLHttpRequest")
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		handleAuthorEditPack(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("edit-pack price %s: status %d, body %s", price, rec.Code, rec.Body.String())
+		}
+	}
+	edit("60")
+	edit("60") // unchanged price is not recorded
+	edit("45")
+
+	get := func(handler http.HandlerFunc, target, userID string) (int, []PriceChange) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-User-ID", userID)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp struct {
+			History []PriceChange `json:"history"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.History
+	}
+
+	code, history := get(handleAuthorPriceHistory, "/user/author/price-history?item_type=pack&item_id=100", "1")
+	if code != http.StatusOK || len(history) != 2 {
+		t.Fatalf("owner history: status %d, %+v", code, history)
+	}
+	if h := history[0]; h.OldPrice != 60 || h.NewPrice != 45 || h.ChangedBy != 1 || h.Source != "edit" || h.CreatedAt == "" {
+		t.Errorf("latest change = %+v", h)
+	}
+	if code, _ := get(handleAuthorPriceHistory, "/user/author/price-history?item_type=pack&item_id=100", "2"); code != http.StatusNotFound {
+		t.Errorf("non-owner history: status %d, want 404", code)
+	}
+
+	if code, history := get(handleAdminPriceHistory, "/api/admin/price-history?item_type=pack", ""); code != http.StatusOK || len(history) != 2 {
+		t.Errorf("admin history: status %d, %d rows", code, len(history))
+	}
+	if code, history := get(handleAdminPriceHistory, "/api/admin/price-history?item_type=custom_product", ""); code != http.StatusOK || len(history) != 0 {
+		t.Errorf("admin custom product history: status %d, %d rows", code, len(history))
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Public catalog API. /api/v1 is a read-only, versioned view of the catalog
+// for third parties: categories, pack search, pack detail by share token and
+// store detail. Its response types are separate from the ones the site uses
+// so that internal changes do not break integrators, and the OpenAPI document
+// served at /api/v1/swagger.json is generated from publicAPIRoutes and these
+// types, so it cannot drift from the handlers. Requests are rate limited per
+// client IP (public_api_rate_limit requests per minute).
+const (
+	publicAPIDefaultPageSize  = 20
+	publicAPIMaxPageSize      = 100
+	defaultPublicAPIRateLimit = 60
+	publicAPIRateLimitWindow  = time.Minute
+	maxPublicAPIQueryLength   = 100
+)
+
+// V1Category is a pack category.
+type V1Category struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PackCount   int    `json:"pack_count"`
+}
+
+// V1CategoryList is the response of GET /api/v1/categories.
+type V1CategoryList struct {
+	Categories []V1Category `json:"categories"`
+}
+
+// V1Pack is a published pack in search results.
+type V1Pack struct {
+	ShareToken   string `json:"share_token"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	CategoryID   int64  `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	AuthorName   string `json:"author_name"`
+	ShareMode    string `json:"share_mode"`
+	CreditsPrice int    `json:"credits_price"`
+	Downloads    int    `json:"downloads"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// V1PackList is one page of GET /api/v1/packs.
+type V1PackList struct {
+	Packs    []V1Pack `json:"packs"`
+	Page     int      `json:"page"`
+	PageSize int      `json:"page_size"`
+	HasMore  bool     `json:"has_more"`
+}
+
+// V1StoreRef identifies the store a pack is sold in.
+type V1StoreRef struct {
+	PublicID string `json:"public_id"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+}
+
+// V1PackDetail is the response of GET /api/v1/packs/{share_token}.
+type V1PackDetail struct {
+	ShareToken    string      `json:"share_token"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	SourceName    string      `json:"source_name"`
+	CategoryName  string      `json:"category_name"`
+	AuthorName    string      `json:"author_name"`
+	ShareMode     string      `json:"share_mode"`
+	CreditsPrice  int         `json:"credits_price"`
+	OriginalPrice int         `json:"original_price,omitempty"`
+	SaleEndsAt    string      `json:"sale_ends_at,omitempty"`
+	Downloads     int         `json:"downloads"`
+	ImageURLs     []string    `json:"image_urls"`
+	Store         *V1StoreRef `json:"store,omitempty"`
+}
+
+// V1StorePack is a pack listed in a store.
+type V1StorePack struct {
+	ShareToken    string `json:"share_token"`
+	Name          string `json:"name"`
+	CategoryName  string `json:"category_name"`
+	ShareMode     string `json:"share_mode"`
+	CreditsPrice  int    `json:"credits_price"`
+	OriginalPrice int    `json:"original_price,omitempty"`
+	Downloads     int    `json:"downloads"`
+	Featured      bool   `json:"featured"`
+}
+
+// V1Store is the response of GET /api/v1/stores/{store_id}.
+type V1Store struct {
+	PublicID    string        `json:"public_id"`
+	Slug        string        `json:"slug"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Verified    bool          `json:"verified"`
+	Followers   int           `json:"followers"`
+	CreatedAt   string        `json:"created_at"`
+	Packs       []V1StorePack `json:"packs"`
+}
+
+// V1Error is the body of every /api/v1 error response.
+type V1Error struct {
+	Error string `json:"error"`
+}
+
+// publicAPIParam is a path or query parameter of a public API route.
+type publicAPIParam struct {
+	Name        string
+	In          string // "path" or "query"
+	Type        string // "string" or "integer"
+	Description string
+}
+
+// publicAPIRoute is one /api/v1 endpoint. Path is an OpenAPI path template
+// whose {name} segments are passed to Handler.
+type publicAPIRoute struct {
+	Path     string
+	Summary  string
+	Params   []publicAPIParam
+	Response interface{}
+	Handler  func(w http.ResponseWriter, r *http.Request, params map[string]string)
+}
+
+// publicAPIRoutes lists the /api/v1 endpoints, in the order they are matched
+// and documented.
+func publicAPIRoutes() []publicAPIRoute {
+	return []publicAPIRoute{
+		{"/api/v1/categories", "List pack categories", nil, V1CategoryList{}, handleV1Categories},
+		{"/api/v1/packs", "Search published packs", []publicAPIParam{
+			{"q", "query", "string", "Text matched against pack names and descriptions"},
+			{"category_id", "query", "integer", "Only packs in this category"},
+			{"page", "query", "integer", "Page number, starting at 1"},
+			{"page_size", "query", "integer", "Packs per page (max 100)"},
+		}, V1PackList{}, handleV1Packs},
+		{"/api/v1/packs/{share_token}", "Get a published pack", []publicAPIParam{
+			{"share_token", "path", "string", "The pack's share token"},
+		}, V1PackDetail{}, handleV1PackDetail},
+		{"/api/v1/stores/{store_id}", "Get a store and its packs", []publicAPIParam{
+			{"store_id", "path", "string", "The store's public ID or slug"},
+		}, V1Store{}, handleV1Store},
+	}
+}
+
+// matchPublicAPIPath matches path against an OpenAPI path template and
+// returns the values of its {name} segments.
+func matchPublicAPIPath(template, path string) (map[string]string, bool) {
+	tp := strings.Split(template, "/")
+	pp := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	if len(tp) != len(pp) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, seg := range tp {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if pp[i] == "" {
+				return nil, false
+			}
+			params[seg[1:len(seg)-1]] = pp[i]
+		} else if seg != pp[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// handlePublicAPIv1 handles every /api/v1 request.
+func handlePublicAPIv1(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		jsonResponse(w, http.StatusMethodNotAllowed, V1Error{"method not allowed"})
+		return
+	}
+	if !publicAPILimiter.allow(getClientIP(r), publicAPIRateLimit(), time.Now(), w) {
+		jsonResponse(w, http.StatusTooManyRequests, V1Error{"rate_limited"})
+		return
+	}
+	if r.URL.Path == "/api/v1/swagger.json" {
+		handleV1Swagger(w, r)
+		return
+	}
+	for _, rt := range publicAPIRoutes() {
+		if params, ok := matchPublicAPIPath(rt.Path, r.URL.Path); ok {
+			rt.Handler(w, r, params)
+			return
+		}
+	}
+	jsonResponse(w, http.StatusNotFound, V1Error{"not found"})
+}
+
+func handleV1Categories(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	categories, err := queryCategories()
+	if err != nil {
+		log.Printf("[API-V1] failed to query categories: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, V1Error{"internal_error"})
+		return
+	}
+	resp := V1CategoryList{Categories: []V1Category{}}
+	for _, c := range categories {
+		resp.Categories = append(resp.Categories, V1Category{ID: c.ID, Name: c.Name, Description: c.Description, PackCount: c.PackCount})
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+func handleV1Packs(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	q := r.URL.Query()
+	f := packSearchFilter{Query: strings.TrimSpace(q.Get("q"))}
+	if utf8.RuneCountInString(f.Query) > maxPublicAPIQueryLength {
+		jsonResponse(w, http.StatusBadRequest, V1Error{"q is too long"})
+		return
+	}
+	if s := q.Get("category_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			jsonResponse(w, http.StatusBadRequest, V1Error{"invalid category_id"})
+			return
+		}
+		f.CategoryID = id
+	}
+	page, pageSize := 1, publicAPIDefaultPageSize
+	if s := q.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			jsonResponse(w, http.StatusBadRequest, V1Error{"invalid page"})
+			return
+		}
+		page = n
+	}
+	if s := q.Get("page_size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > publicAPIMaxPageSize {
+			jsonResponse(w, http.StatusBadRequest, V1Error{"invalid page_size"})
+			return
+		}
+		pageSize = n
+	}
+	// Fetch one extra row to learn whether another page exists.
+	f.Limit, f.Offset = pageSize+1, (page-1)*pageSize
+	listings, err := queryPublishedPacks(f)
+	if err != nil {
+		log.Printf("[API-V1] failed to search packs: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, V1Error{"internal_error"})
+		return
+	}
+	resp := V1PackList{Packs: []V1Pack{}, Page: page, PageSize: pageSize}
+	if len(listings) > pageSize {
+		listings, resp.HasMore = listings[:pageSize], true
+	}
+	for _, l := range listings {
+		resp.Packs = append(resp.Packs, V1Pack{
+			ShareToken:   l.ShareToken,
+			Name:         l.PackName,
+			Description:  l.PackDescription,
+			CategoryID:   l.CategoryID,
+			CategoryName: l.CategoryName,
+			AuthorName:   l.AuthorName,
+			ShareMode:    l.ShareMode,
+			CreditsPrice: l.CreditsPrice,
+			Downloads:    l.DownloadCount,
+			CreatedAt:    l.CreatedAt,
+		})
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+func handleV1PackDetail(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	shareToken := params["share_token"]
+	listingID, hit := globalCache.GetShareTokenMapping(shareToken)
+	if !hit {
+		var err error
+		listingID, err = globalCache.DoShareTokenResolve(shareToken, func() (int64, error) {
+			return resolveShareToken(shareToken)
+		})
+		if err != nil || listingID <= 0 {
+			jsonResponse(w, http.StatusNotFound, V1Error{"pack not found"})
+			return
+		}
+		globalCache.SetShareTokenMapping(shareToken, listingID)
+	}
+	pd, hit := globalCache.GetPackDetail(shareToken)
+	if !hit {
+		var err error
+		pd, err = globalCache.DoPackDetailQuery(shareToken, func() (*PackDetailPublicData, error) {
+			return queryPackDetailPublicData(shareToken, listingID)
+		})
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, V1Error{"pack not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("[API-V1] failed to query pack %d: %v", listingID, err)
+			jsonResponse(w, http.StatusInternalServerError, V1Error{"internal_error"})
+			return
+		}
+		globalCache.SetPackDetail(shareToken, pd)
+	}
+	resp := V1PackDetail{
+		ShareToken:    pd.ShareToken,
+		Name:          pd.PackName,
+		Description:   pd.PackDesc,
+		SourceName:    pd.SourceName,
+		CategoryName:  pd.CategoryName,
+		AuthorName:    pd.AuthorName,
+		ShareMode:     pd.ShareMode,
+		CreditsPrice:  pd.CreditsPrice,
+		OriginalPrice: pd.OriginalPrice,
+		SaleEndsAt:    pd.SaleEndsAt,
+		Downloads:     pd.DownloadCount,
+		ImageURLs:     []string{},
+	}
+	for _, img := range pd.Images {
+		resp.ImageURLs = append(resp.ImageURLs, img.URL)
+	}
+	if pd.StoreSlug != "" || pd.StorefrontPublicID != "" {
+		resp.Store = &V1StoreRef{PublicID: pd.StorefrontPublicID, Slug: pd.StoreSlug, Name: pd.StoreName}
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+func handleV1Store(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	internalID, publicID, err := resolveStorefrontID(params["store_id"])
+	if err != nil {
+		jsonResponse(w, http.StatusNotFound, V1Error{"store not found"})
+		return
+	}
+	cacheIdentifier := publicID
+	if cacheIdentifier == "" {
+		cacheIdentifier = strconv.FormatInt(internalID, 10)
+	}
+	cacheKey := buildStorefrontCacheKey(cacheIdentifier, "", "revenue", "", "")
+	data, hit := globalCache.GetStorefrontData(cacheKey)
+	if !hit {
+		data, err = globalCache.DoStorefrontQuery(cacheKey, func() (*StorefrontPublicData, error) {
+			return queryStorefrontPublicData(strconv.FormatInt(internalID, 10), "", "revenue", "", "")
+		})
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, V1Error{"store not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("[API-V1] failed to query store %d: %v", internalID, err)
+			jsonResponse(w, http.StatusInternalServerError, V1Error{"internal_error"})
+			return
+		}
+		globalCache.SetStorefrontData(cacheKey, data)
+	}
+	sf := data.Storefront
+	if sf.StoreStatus == "paused" {
+		jsonResponse(w, http.StatusNotFound, V1Error{"store not found"})
+		return
+	}
+	resp := V1Store{
+		PublicID:    sf.PublicID,
+		Slug:        sf.StoreSlug,
+		Name:        sf.StoreName,
+		Description: sf.Description,
+		Verified:    sf.Verified,
+		Followers:   sf.FollowerCount,
+		CreatedAt:   sf.CreatedAt,
+		Packs:       []V1StorePack{},
+	}
+	for _, p := range data.Packs {
+		resp.Packs = append(resp.Packs, V1StorePack{
+			ShareToken:    p.ShareToken,
+			Name:          p.PackName,
+			CategoryName:  p.CategoryName,
+			ShareMode:     p.ShareMode,
+			CreditsPrice:  p.CreditsPrice,
+			OriginalPrice: p.OriginalPrice,
+			Downloads:     p.DownloadCount,
+			Featured:      p.IsFeatured,
+		})
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// --- Rate limiting ---
+
+// publicAPIRateLimit returns the allowed requests per client IP per minute.
+func publicAPIRateLimit() int {
+	n, err := strconv.Atoi(getSetting("public_api_rate_limit"))
+	if err != nil || n <= 0 {
+		return defaultPublicAPIRateLimit
+	}
+	return n
+}
+
+// fixedWindowLimiter counts requests per key in fixed windows. All counts
+// are dropped when a window ends, so memory stays bounded by the number of
+// clients seen in one window.
+type fixedWindowLimiter struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+var publicAPILimiter = &fixedWindowLimiter{counts: map[string]int{}}
+
+// allow counts a request for key and reports whether it is within limit. It
+// sets the X-RateLimit-* headers, and Retry-After when the limit is reached.
+func (l *fixedWindowLimiter) allow(key string, limit int, now time.Time, w http.ResponseWriter) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.windowStart) >= publicAPIRateLimitWindow {
+		l.windowStart = now
+		l.counts = map[string]int{}
+	}
+	l.counts[key]++
+	remaining := limit - l.counts[key]
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	if remaining < 0 {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		retry := l.windowStart.Add(publicAPIRateLimitWindow).Sub(now)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retry/time.Second)+1))
+		return false
+	}
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	return true
+}
+
+// --- OpenAPI document ---
+
+var (
+	publicAPISpecOnce sync.Once
+	publicAPISpec     []byte
+)
+
+// handleV1Swagger serves the OpenAPI 3 document of /api/v1.
+func handleV1Swagger(w http.ResponseWriter, r *http.Request) {
+	publicAPISpecOnce.Do(func() {
+		var err error
+		publicAPISpec, err = json.MarshalIndent(buildPublicAPISpec(), "", "  ")
+		if err != nil {
+			log.Printf("[API-V1] failed to build OpenAPI document: %v", err)
+		}
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(publicAPISpec)
+}
+
+// buildPublicAPISpec generates the OpenAPI document from publicAPIRoutes.
+func buildPublicAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	errorRef := openAPISchema(reflect.TypeOf(V1Error{}), schemas)
+	paths := map[string]interface{}{}
+	for _, rt := range publicAPIRoutes() {
+		params := []map[string]interface{}{}
+		for _, p := range rt.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.In == "path",
+				"description": p.Description,
+				"schema":      map[string]string{"type": p.Type},
+			})
+		}
+		errorResp := func(desc string) map[string]interface{} {
+			return map[string]interface{}{
+				"description": desc,
+				"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": errorRef}},
+			}
+		}
+		responses := map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "OK",
+				"content": map[string]interface{}{"application/json": map[string]interface{}{
+					"schema": openAPISchema(reflect.TypeOf(rt.Response), schemas),
+				}},
+			},
+			"429": errorResp("Rate limit exceeded; see the Retry-After header"),
+		}
+		if len(rt.Params) > 0 {
+			responses["400"] = errorResp("Invalid parameter")
+		}
+		if strings.Contains(rt.Path, "{") {
+			responses["404"] = errorResp("Not found")
+		}
+		paths[rt.Path] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":    rt.Summary,
+				"parameters": params,
+				"responses":  responses,
+			},
+		}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Vantagics Marketplace API",
+			"version":     "1.0.0",
+			"description": "Read-only access to the public catalog. Requests are rate limited per client IP.",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// openAPISchema returns the schema of t, adding named struct types to
+// schemas and referring to them by $ref. Fields are named by their json tags;
+// fields without omitempty are required.
+func openAPISchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return openAPISchema(t.Elem(), schemas)
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		schemas[t.Name()] = nil // placeholder so recursive types terminate
+		props := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("json"), ",")
+			if tag[0] == "" || tag[0] == "-" {
+				continue
+			}
+			props[tag[0]] = openAPISchema(f.Type, schemas)
+			if len(tag) == 1 || tag[1] != "omitempty" {
+				required = append(required, tag[0])
+			}
+		}
+		schemas[t.Name()] = map[string]interface{}{"type": "object", "properties": props, "required": required}
+		return ref
+	}
+	return map[string]interface{}{}
+}
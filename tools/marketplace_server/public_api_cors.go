@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORS for the public API. Browser pages on the origins listed in the
+// public_api_cors_origins setting ("*" allows any) may call /api/v1; the
+// authenticated /api, /user and /admin routes never send CORS headers, so
+// browsers keep blocking cross-origin reads of them. Requests from other
+// origins are refused with 403 before reaching the API, preflights included.
+// Requests without an Origin header (servers, curl) are not affected.
+const (
+	publicAPICORSMethods        = "GET, HEAD, OPTIONS"
+	defaultPublicAPICORSHeaders = "Accept, Accept-Language, Content-Type"
+	publicAPICORSMaxAge         = 600
+)
+
+// publicAPICORSOrigins returns the configured origins, normalized to lower
+// case without a trailing slash.
+func publicAPICORSOrigins() []string {
+	var origins []string
+	for _, o := range contentWordList(getSetting("public_api_cors_origins")) {
+		origins = append(origins, strings.TrimSuffix(o, "/"))
+	}
+	return origins
+}
+
+// publicAPICORSHeaders returns the request headers browsers may send.
+func publicAPICORSHeaders() string {
+	if h := strings.TrimSpace(getSetting("public_api_cors_headers")); h != "" {
+		return h
+	}
+	return defaultPublicAPICORSHeaders
+}
+
+// publicAPIOriginAllowed reports whether origin may call the public API, and
+// the value to send as Access-Control-Allow-Origin.
+func publicAPIOriginAllowed(origin string) (string, bool) {
+	o := strings.ToLower(origin)
+	for _, allowed := range publicAPICORSOrigins() {
+		if allowed == "*" {
+			return "*", true
+		}
+		if allowed == o {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// publicAPICORS wraps the /api/v1 handler with CORS handling.
+func publicAPICORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		allowOrigin, ok := publicAPIOriginAllowed(origin)
+		if !ok {
+			jsonResponse(w, http.StatusForbidden, V1Error{"origin not allowed"})
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			switch r.Header.Get("Access-Control-Request-Method") {
+			case http.MethodGet, http.MethodHead:
+			default:
+				jsonResponse(w, http.StatusForbidden, V1Error{"method not allowed"})
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", publicAPICORSMethods)
+			w.Header().Set("Access-Control-Allow-Headers", publicAPICORSHeaders())
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(publicAPICORSMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", "X-RateLimit-Limit, X-RateLimit-Remaining, Retry-After")
+		next(w, r)
+	}
+}
+
+// handleAdminPublicAPISettings handles GET/POST /admin/settings/public-api.
+func handleAdminPublicAPISettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"cors_origins": strings.Join(publicAPICORSOrigins(), "\n"),
+			"cors_headers": publicAPICORSHeaders(),
+			"rate_limit":   publicAPIRateLimit(),
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rateLimit, err := strconv.Atoi(strings.TrimSpace(r.FormValue("rate_limit")))
+	if err != nil || rateLimit < 1 || rateLimit > 100000 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "每分钟请求上限必须为 1 到 100000 之间的整数"})
+		return
+	}
+	var origins []string
+	for _, o := range contentWordList(r.FormValue("cors_origins")) {
+		o = strings.TrimSuffix(o, "/")
+		if o != "*" && !strings.HasPrefix(o, "http://") && !strings.HasPrefix(o, "https://") {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "来源必须以 http:// 或 https:// 开头，或为 *：" + o})
+			return
+		}
+		origins = append(origins, o)
+	}
+	headers := strings.TrimSpace(r.FormValue("cors_headers"))
+	for _, h := range strings.Split(headers, ",") {
+		if strings.ContainsAny(strings.TrimSpace(h), " \r\n:") {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "请求头名称无效：" + h})
+			return
+		}
+	}
+	for key, value := range map[string]string{
+		"public_api_cors_origins": strings.Join(origins, ","),
+		"public_api_cors_headers": headers,
+		"public_api_rate_limit":   strconv.Itoa(rateLimit),
+	} {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublicAPICORS(t *testing.T) {
+	useTestDB(t)
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('public_api_cors_origins', 'https://partner.example.com, https://other.example.com/')"); err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	h := publicAPICORS(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	})
+	do := func(method, origin, requestMethod string) *httptest.ResponseRecorder {
+		called = false
+		req := httptest.NewRequest(method, "/api/v1/packs", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", requestMethod)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		rec := do(http.MethodOptions, "https://partner.example.com", http.MethodGet)
+		if rec.Code != http.StatusNoContent || called {
+			t.Fatalf("status %d, handler called %v", rec.Code, called)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://partner.example.com" {
+			t.Errorf("Allow-Origin = %q", got)
+		}
+		if rec.Header().Get("Access-Control-Allow-Methods") != publicAPICORSMethods ||
+			rec.Header().Get("Access-Control-Allow-Headers") != defaultPublicAPICORSHeaders {
+			t.Errorf("preflight headers: %v", rec.Header())
+		}
+		if rec := do(http.MethodOptions, "https://partner.example.com", http.MethodDelete); rec.Code != http.StatusForbidden {
+			t.Errorf("preflight for DELETE: status %d, want 403", rec.Code)
+		}
+	})
+
+	t.Run("allowed origin", func(t *testing.T) {
+		rec := do(http.MethodGet, "https://other.example.com", "")
+		if !called || rec.Header().Get("Access-Control-Allow-Origin") != "https://other.example.com" {
+			t.Fatalf("called %v, headers %v", called, rec.Header())
+		}
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		for _, method := range []string{http.MethodOptions, http.MethodGet} {
+			rec := do(method, "https://evil.example.com", http.MethodGet)
+			if rec.Code != http.StatusForbidden || called || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Errorf("%s: status %d, called %v, Allow-Origin %q", method, rec.Code, called, rec.Header().Get("Access-Control-Allow-Origin"))
+			}
+		}
+	})
+
+	t.Run("no origin", func(t *testing.T) {
+		rec := do(http.MethodGet, "", "")
+		if !called || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("server-to-server request: called %v, headers %v", called, rec.Header())
+		}
+	})
+}
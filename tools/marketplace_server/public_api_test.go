@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getPublicAPI(t *testing.T, path string, out interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handlePublicAPIv1(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestPublicAPIv1Catalog(t *testing.T) {
+	useTestDB(t)
+	globalCache = NewCache(CacheConfig{})
+	publicAPILimiter = &fixedWindowLimiter{counts: map[string]int{}}
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'author', 'Author')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id) VALUES (5, 1, 'Alice Store', 'alice-store', 'sf-alice')")
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, pack_description, share_mode, status, share_token)
+		VALUES (100, 1, 1, x'00', 'Sales Report', 'monthly sales', 'free', 'published', 'tok-sales'),
+		       (101, 1, 1, x'00', 'Inventory', 'stock levels', 'free', 'published', 'tok-inv'),
+		       (102, 1, 1, x'00', 'Draft Sales', '', 'free', 'pending', 'tok-draft')`)
+
+	var cats V1CategoryList
+	if code := getPublicAPI(t, "/api/v1/categories", &cats); code != http.StatusOK || len(cats.Categories) == 0 {
+		t.Fatalf("categories: %d %v", code, cats)
+	}
+
+	var packs V1PackList
+	if code := getPublicAPI(t, "/api/v1/packs?q=sales", &packs); code != http.StatusOK {
+		t.Fatalf("search: status %d", code)
+	}
+	if len(packs.Packs) != 1 || packs.Packs[0].ShareToken != "tok-sales" || packs.HasMore {
+		t.Fatalf("search returned %+v, want only the published Sales Report", packs)
+	}
+	if code := getPublicAPI(t, "/api/v1/packs?page_size=1", &packs); code != http.StatusOK || len(packs.Packs) != 1 || !packs.HasMore {
+		t.Errorf("paging: %d %+v", code, packs)
+	}
+	if code := getPublicAPI(t, "/api/v1/packs?page_size=1000", nil); code != http.StatusBadRequest {
+		t.Errorf("oversized page: status %d, want 400", code)
+	}
+
+	var detail V1PackDetail
+	if code := getPublicAPI(t, "/api/v1/packs/tok-sales", &detail); code != http.StatusOK || detail.Name != "Sales Report" ||
+		detail.Store == nil || detail.Store.Slug != "alice-store" {
+		t.Fatalf("detail: %d %+v", code, detail)
+	}
+	if code := getPublicAPI(t, "/api/v1/packs/tok-draft", nil); code != http.StatusNotFound {
+		t.Errorf("unpublished pack: status %d, want 404", code)
+	}
+
+	var store V1Store
+	if code := getPublicAPI(t, "/api/v1/stores/alice-store", &store); code != http.StatusOK || store.PublicID != "sf-alice" {
+		t.Fatalf("store: %d %+v", code, store)
+	}
+
+	rec := httptest.NewRecorder()
+	handlePublicAPIv1(rec, httptest.NewRequest(http.MethodPost, "/api/v1/packs", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want 405", rec.Code)
+	}
+}
+
+func TestPublicAPIv1SpecCoversRoutes(t *testing.T) {
+	spec := buildPublicAPISpec()
+	paths := spec["paths"].(map[string]interface{})
+	for _, rt := range publicAPIRoutes() {
+		if _, ok := paths[rt.Path]; !ok {
+			t.Errorf("%s missing from the OpenAPI document", rt.Path)
+		}
+	}
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"V1PackList", "V1Pack", "V1PackDetail", "V1StoreRef", "V1Store", "V1Error"} {
+		if schemas[name] == nil {
+			t.Errorf("schema %s missing", name)
+		}
+	}
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+}
+
+func TestPublicAPIv1RateLimit(t *testing.T) {
+	useTestDB(t)
+	publicAPILimiter = &fixedWindowLimiter{counts: map[string]int{}}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('public_api_rate_limit', '2')"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if code := getPublicAPI(t, "/api/v1/swagger.json", nil); code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	handlePublicAPIv1(rec, httptest.NewRequest(http.MethodGet, "/api/v1/categories", nil))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("over the limit: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultIdempotencyKeyTTLHours is how long an Idempotency-Key on a custom
+// product purchase is honoured when the idempotency_key_ttl_hours setting is
+// unset.
+const defaultIdempotencyKeyTTLHours = 24
+
+// maxIdempotencyKeyLen bounds the Idempotency-Key header.
+const maxIdempotencyKeyLen = 255
+
+// purchaseFlights collapses concurrent purchases with the same user and
+// Idempotency-Key into one, so a double-submit creates one PayPal order.
+var purchaseFlights singleflight.Group
+
+// idempotencyKeyTTL returns how long a purchase Idempotency-Key is honoured.
+func idempotencyKeyTTL() time.Duration {
+	hours, err := strconv.Atoi(getSetting("idempotency_key_ttl_hours"))
+	if err != nil || hours <= 0 {
+		hours = defaultIdempotencyKeyTTLHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// idempotentPurchase is the order previously created for an Idempotency-Key.
+type idempotentPurchase struct {
+	ProductID  int64
+	Status     string // custom_product_orders.status
+	ApproveURL string
+}
+
+// findIdempotentPurchase returns the order userID created with key within the
+// TTL, with its current status: the approve URL is only worth returning while
+// the order is still pending. Keys older than the TTL are released first so
+// they can be used again.
+func findIdempotentPurchase(userID int64, key string, now time.Time) (*idempotentPurchase, error) {
+	cutoff := now.Add(-idempotencyKeyTTL()).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.Exec(`UPDATE custom_product_orders SET idempotency_key = NULL
+		WHERE user_id = ? AND idempotency_key = ? AND created_at < ?`, userID, key, cutoff); err != nil {
+		return nil, err
+	}
+	var p idempotentPurchase
+	err := db.QueryRow(`SELECT custom_product_id, COALESCE(status, 'pending'), COALESCE(approve_url, '') FROM custom_product_orders
+		WHERE user_id = ? AND idempotency_key = ?`, userID, key).Scan(&p.ProductID, &p.Status, &p.ApproveURL)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// purchaseFlightKey scopes an Idempotency-Key to the user that sent it.
+func purchaseFlightKey(userID int64, key string) string {
+	return fmt.Sprintf("%d:%s", userID, key)
+}
+
+// handleAdminIdempotencySettings handles GET/POST /admin/settings/idempotency.
+func handleAdminIdempotencySettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]int{"ttl_hours": int(idempotencyKeyTTL().Hours())})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hours, err := strconv.Atoi(strings.TrimSpace(r.FormValue("ttl_hours")))
+	if err != nil || hours < 1 || hours > 720 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "有效期必须在 1-720 小时之间"})
+		return
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('idempotency_key_ttl_hours', ?)", strconv.Itoa(hours)); err != nil {
+		log.Printf("[ADMIN] failed to save idempotency_key_ttl_hours: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCustomProductPurchaseIdempotencyKey(t *testing.T) {
+	useTestDB(t)
+
+	var orders int32
+	paypal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/oauth2/token":
+			fmt.Fprint(w, `{"access_token":"tok"}`)
+		case "/v2/checkout/orders":
+			n := atomic.AddInt32(&orders, 1)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id":"ORDER-%d","links":[{"rel":"approve","href":"https://paypal.test/approve/%d"}]}`, n, n)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer paypal.Close()
+	prevBase := paypalAPIBaseURL
+	paypalAPIBaseURL = paypal.URL
+	t.Cleanup(func() { paypalAPIBaseURL = prevBase })
+
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "test-key")
+	secret, err := encryptPayPalSecret("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('paypal_client_id', 'client')")
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('paypal_client_secret', ?)", secret)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'email', 'b', 'Bob', 'bob@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, custom_products_enabled) VALUES (10, 2, 'Bob Store', 'bob', 1)")
+	mustExec(t, "INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, credits_amount, status) VALUES (5, 10, 'Credits', 'credits', 10, 100, 'published')")
+	mustExec(t, "INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, credits_amount, status) VALUES (6, 10, 'More', 'credits', 20, 200, 'published')")
+
+	purchase := func(productID int, key string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/custom-product/%d/purchase", productID), nil)
+		req.Header.Set("X-User-ID", "1")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		handleCustomProductPurchase(rec, req)
+		var resp struct {
+			ApproveURL string `json:"approve_url"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.ApproveURL
+	}
+
+	code, first := purchase(5, "k1")
+	if code != http.StatusOK || first == "" {
+		t.Fatalf("first purchase: status %d, approve_url %q", code, first)
+	}
+	if code, again := purchase(5, "k1"); code != http.StatusOK || again != first {
+		t.Errorf("replay: status %d, approve_url %q; want %q", code, again, first)
+	}
+	if n := atomic.LoadInt32(&orders); n != 1 {
+		t.Errorf("PayPal orders created = %d, want 1", n)
+	}
+	var rows int
+	db.QueryRow("SELECT COUNT(*) FROM custom_product_orders WHERE user_id = 1").Scan(&rows)
+	if rows != 1 {
+		t.Errorf("order rows = %d, want 1", rows)
+	}
+
+	// Once the order is paid or has failed, a replay gets that outcome rather
+	// than the approve link.
+	for _, tc := range []struct{ status, want string }{{"paid", "completed"}, {"fulfilled", "completed"}, {"failed", "failed"}} {
+		mustExec(t, "UPDATE custom_product_orders SET status = ? WHERE idempotency_key = 'k1'", tc.status)
+		req := httptest.NewRequest(http.MethodPost, "/custom-product/5/purchase", nil)
+		req.Header.Set("X-User-ID", "1")
+		req.Header.Set("Idempotency-Key", "k1")
+		rec := httptest.NewRecorder()
+		handleCustomProductPurchase(rec, req)
+		var resp map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != http.StatusConflict || resp["status"] != tc.want || resp["approve_url"] != "" {
+			t.Errorf("replay of %s order: status %d, body %v", tc.status, rec.Code, resp)
+		}
+	}
+	mustExec(t, "UPDATE custom_product_orders SET status = 'pending' WHERE idempotency_key = 'k1'")
+	if n := atomic.LoadInt32(&orders); n != 1 {
+		t.Errorf("PayPal orders created = %d, want 1", n)
+	}
+
+	if code, _ := purchase(6, "k1"); code != http.StatusUnprocessableEntity {
+		t.Errorf("same key, other product: status %d, want 422", code)
+	}
+	if code, _ := purchase(5, ""); code != http.StatusOK || atomic.LoadInt32(&orders) != 2 {
+		t.Errorf("purchase without key: status %d, orders %d", code, orders)
+	}
+
+	// Once the key expires it starts a new order.
+	mustExec(t, "UPDATE custom_product_orders SET created_at = '2000-01-01 00:00:00' WHERE idempotency_key = 'k1'")
+	if code, again := purchase(5, "k1"); code != http.StatusOK || again == first {
+		t.Errorf("expired key: status %d, approve_url %q", code, again)
+	}
+	if n := atomic.LoadInt32(&orders); n != 3 {
+		t.Errorf("PayPal orders created = %d, want 3", n)
+	}
+}
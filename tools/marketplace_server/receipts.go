@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"marketplace_server/i18n"
+	"marketplace_server/templates"
+)
+
+// maxReceiptPolicyRunes caps how much of a store policy is printed on a receipt.
+const maxReceiptPolicyRunes = 1500
+
+// receiptTransactionLabels names the credit transactions a receipt can cover.
+var receiptTransactionLabels = map[string]string{
+	"purchase":      "购买 / Purchase",
+	"purchase_uses": "按次购买 / Pay-per-use",
+	"renew":         "续费 / Renewal",
+	"download":      "下载 / Download",
+}
+
+// receipt is what a PDF receipt shows.
+type receipt struct {
+	Title        string
+	Number       string
+	Rows         [][2]string // label, value
+	StoreName    string
+	RefundPolicy string
+	Contact      string
+}
+
+// renderReceiptPDF lays a receipt out as a one-column PDF.
+func renderReceiptPDF(rc receipt) []byte {
+	var doc pdfDocument
+	doc.Heading(rc.Title)
+	doc.Small(rc.Number)
+	doc.Space()
+	for _, row := range rc.Rows {
+		if row[1] != "" {
+			doc.Text(row[0] + "：" + row[1])
+		}
+	}
+	if rc.RefundPolicy != "" {
+		doc.Space()
+		doc.Text("退款政策 / Refund policy")
+		doc.Small(truncateRunes(templates.MarkdownPlainText(rc.RefundPolicy), maxReceiptPolicyRunes))
+	}
+	if rc.Contact != "" {
+		doc.Space()
+		doc.Text("联系方式 / Contact")
+		doc.Small(truncateRunes(templates.MarkdownPlainText(rc.Contact), maxReceiptPolicyRunes))
+	}
+	doc.Space()
+	doc.Small("本收据由 Vantagics 分析技能包市场代 " + rc.StoreName + " 开具。")
+	doc.Small("Issued by the Vantagics marketplace on behalf of " + rc.StoreName + ".")
+	return doc.Bytes()
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with "…".
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+// writeReceiptPDF sends a rendered receipt as a download.
+func writeReceiptPDF(w http.ResponseWriter, filename string, rc receipt) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(renderReceiptPDF(rc))
+}
+
+// handleCustomProductOrderReceipt handles GET /user/receipts/order?id=N and
+// downloads the PDF receipt of a fulfilled custom product order. Only the
+// buyer and the owner of the selling store can download it.
+func handleCustomProductOrderReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	orderID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || orderID <= 0 {
+		http.Error(w, "订单号无效", http.StatusBadRequest)
+		return
+	}
+
+	var buyerID, ownerID int64
+	var productName, productType, currency, status, createdAt, paidAt, buyerEmail, recipientEmail, payPalOrderID string
+	var amount, taxAmount float64
+	var creditsAmount int
+	var rc receipt
+	err = db.QueryRow(`SELECT o.user_id, s.user_id, p.product_name, p.product_type, `+orderCreditsSQL+`,
+		COALESCE(o.charged_amount, o.amount_usd), COALESCE(o.tax_amount, 0), COALESCE(o.charged_currency, 'USD'), o.status,
+		o.created_at, COALESCE(o.paid_at, ''), COALESCE(u.email, ''), COALESCE(o.recipient_email, ''),
+		COALESCE(o.paypal_order_id, ''), s.store_name, COALESCE(s.refund_policy, ''), COALESCE(s.contact, '')
+		FROM custom_product_orders o
+		JOIN custom_products p ON p.id = o.custom_product_id
+		JOIN author_storefronts s ON s.id = p.storefront_id
+		LEFT JOIN users u ON u.id = o.user_id
+		WHERE o.id = ?`, orderID).Scan(&buyerID, &ownerID, &productName, &productType, &creditsAmount,
+		&amount, &taxAmount, &currency, &status, &createdAt, &paidAt, &buyerEmail, &recipientEmail,
+		&payPalOrderID, &rc.StoreName, &rc.RefundPolicy, &rc.Contact)
+	if err == sql.ErrNoRows || (err == nil && userID != buyerID && userID != ownerID) {
+		http.Error(w, "订单不存在", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("[RECEIPT] failed to load order %d: %v", orderID, err)
+		http.Error(w, "加载数据失败", http.StatusInternalServerError)
+		return
+	}
+	if status != "fulfilled" {
+		http.Error(w, "订单完成后才能下载收据", http.StatusConflict)
+		return
+	}
+
+	product := productName
+	if label := customProductTypeLabels[productType]; label != "" {
+		product += "（" + label + "）"
+	}
+	credits := ""
+	if productType == "credits" && creditsAmount > 0 {
+		credits = strconv.Itoa(creditsAmount)
+	}
+	tax := ""
+	if taxAmount > 0 {
+		tax = i18n.FormatMoney(taxAmount, currency, i18n.DetectLang(r))
+	}
+	rc.Title = "收据 Receipt"
+	rc.Number = fmt.Sprintf("No. CPO-%d", orderID)
+	rc.Rows = [][2]string{
+		{"订单号 / Order", fmt.Sprintf("#%d", orderID)},
+		{"店铺 / Store", rc.StoreName},
+		{"商品 / Product", product},
+		{"积分 / Credits", credits},
+		{"金额 / Amount", i18n.FormatMoney(amount, currency, i18n.DetectLang(r))},
+		{"其中税费 / Tax included", tax},
+		{"下单时间 / Ordered", exportTimestamp(createdAt)},
+		{"付款时间 / Paid", exportTimestamp(paidAt)},
+		{"买家邮箱 / Buyer", buyerEmail},
+		{"受赠人 / Gift recipient", recipientEmail},
+		{"PayPal 订单号 / PayPal order", payPalOrderID},
+	}
+	writeReceiptPDF(w, fmt.Sprintf("receipt-order-%d.pdf", orderID), rc)
+}
+
+// handlePackPurchaseReceipt handles GET /user/receipts/purchase?id=N and
+// downloads the PDF receipt of a pack bought with credits, N being the
+// credits_transactions id. Only the buyer and the pack's author can download it.
+func handlePackPurchaseReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	txID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || txID <= 0 {
+		http.Error(w, "交易号无效", http.StatusBadRequest)
+		return
+	}
+
+	var buyerID, authorID int64
+	var packName, txType, createdAt, buyerEmail, authorName string
+	var amount float64
+	var rc receipt
+	err = db.QueryRow(`SELECT ct.user_id, pl.user_id, pl.pack_name, ct.transaction_type, ABS(ct.amount), ct.created_at,
+		COALESCE(u.email, ''), COALESCE(pl.author_name, ''),
+		COALESCE(s.store_name, ''), COALESCE(s.refund_policy, ''), COALESCE(s.contact, '')
+		FROM credits_transactions ct
+		JOIN pack_listings pl ON pl.id = ct.listing_id
+		LEFT JOIN users u ON u.id = ct.user_id
+		LEFT JOIN author_storefronts s ON s.user_id = pl.user_id
+		WHERE ct.id = ? AND ct.transaction_type IN ('purchase', 'purchase_uses', 'renew', 'download')`, txID).Scan(
+		&buyerID, &authorID, &packName, &txType, &amount, &createdAt, &buyerEmail, &authorName,
+		&rc.StoreName, &rc.RefundPolicy, &rc.Contact)
+	if err == sql.ErrNoRows || (err == nil && userID != buyerID && userID != authorID) {
+		http.Error(w, "交易不存在", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("[RECEIPT] failed to load transaction %d: %v", txID, err)
+		http.Error(w, "加载数据失败", http.StatusInternalServerError)
+		return
+	}
+	if rc.StoreName == "" {
+		rc.StoreName = authorName
+	}
+
+	rc.Title = "收据 Receipt"
+	rc.Number = fmt.Sprintf("No. TX-%d", txID)
+	rc.Rows = [][2]string{
+		{"交易号 / Transaction", fmt.Sprintf("#%d", txID)},
+		{"店铺 / Store", rc.StoreName},
+		{"分析包 / Pack", packName},
+		{"类型 / Type", receiptTransactionLabels[txType]},
+		{"金额 / Amount", strconv.FormatFloat(amount, 'f', -1, 64) + " Credits"},
+		{"时间 / Date", exportTimestamp(createdAt)},
+		{"买家邮箱 / Buyer", buyerEmail},
+	}
+	writeReceiptPDF(w, fmt.Sprintf("receipt-purchase-%d.pdf", txID), rc)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrapPDFText(t *testing.T) {
+	lines := wrapPDFText("hello brave new world", 6)
+	if len(lines) != 2 || lines[0] != "hello brave" || lines[1] != "new world" {
+		t.Errorf("latin wrap %q", lines)
+	}
+	if lines := wrapPDFText(strings.Repeat("字", 7), 3); len(lines) != 3 || lines[2] != "字" {
+		t.Errorf("CJK wrap %q", lines)
+	}
+	if got := pdfHexText("A字\t"); got != "<00415B570020>" {
+		t.Errorf("hex text %s", got)
+	}
+}
+
+func TestPDFDocumentPaginates(t *testing.T) {
+	var doc pdfDocument
+	for i := 0; i < 80; i++ {
+		doc.Text("line")
+	}
+	pdf := doc.Bytes()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF file")
+	}
+	if !bytes.Contains(pdf, []byte("/Count 2")) {
+		t.Error("80 lines did not spill onto a second page")
+	}
+}
+
+func TestReceiptAccess(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'sn', 'a', 'Seller', 's@example.com'), (2, 'sn', 'b', 'Buyer', 'b@example.com'), (3, 'sn', 'c', 'Other', 'o@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, refund_policy, contact) VALUES (10, 1, '数据小铺', 'shop', '七天无理由退款', 'help@example.com')")
+	mustExec(t, "INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, credits_amount) VALUES (5, 10, 'Credits', 'credits', 10, 100)")
+	mustExec(t, "INSERT INTO custom_product_orders (id, custom_product_id, user_id, amount_usd, status) VALUES (7, 5, 2, 10, 'fulfilled'), (8, 5, 2, 10, 'pending')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (1, 1, 1, x'00', 'P', 'per_use', 'published')")
+	mustExec(t, "INSERT INTO credits_transactions (id, user_id, transaction_type, amount, listing_id) VALUES (20, 2, 'purchase', -30, 1), (21, 2, 'topup', 30, NULL)")
+
+	get := func(handler http.HandlerFunc, url, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("X-User-ID", userID)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+	for _, tc := range []struct {
+		handler http.HandlerFunc
+		url     string
+		userID  string
+		want    int
+	}{
+		{handleCustomProductOrderReceipt, "/user/receipts/order?id=7", "2", http.StatusOK},
+		{handleCustomProductOrderReceipt, "/user/receipts/order?id=7", "1", http.StatusOK},
+		{handleCustomProductOrderReceipt, "/user/receipts/order?id=7", "3", http.StatusNotFound},
+		{handleCustomProductOrderReceipt, "/user/receipts/order?id=8", "2", http.StatusConflict},
+		{handlePackPurchaseReceipt, "/user/receipts/purchase?id=20", "2", http.StatusOK},
+		{handlePackPurchaseReceipt, "/user/receipts/purchase?id=20", "1", http.StatusOK},
+		{handlePackPurchaseReceipt, "/user/receipts/purchase?id=20", "3", http.StatusNotFound},
+		{handlePackPurchaseReceipt, "/user/receipts/purchase?id=21", "2", http.StatusNotFound},
+	} {
+		if rec := get(tc.handler, tc.url, tc.userID); rec.Code != tc.want {
+			t.Errorf("%s as user %s: status %d, want %d", tc.url, tc.userID, rec.Code, tc.want)
+		}
+	}
+
+	rec := get(handleCustomProductOrderReceipt, "/user/receipts/order?id=7", "2")
+	if rec.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("content type %q", rec.Header().Get("Content-Type"))
+	}
+	// The refund policy is printed, UCS-2 encoded.
+	if !strings.Contains(rec.Body.String(), strings.Trim(pdfHexText("七天无理由退款"), "<>")) {
+		t.Error("receipt is missing the store refund policy")
+	}
+}
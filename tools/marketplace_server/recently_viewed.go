@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// recentlyViewedCap is how many pack views are kept per user.
+const recentlyViewedCap = 20
+
+// RecentlyViewedPack is a pack as returned by GET /user/recently-viewed.
+type RecentlyViewedPack struct {
+	ListingID    int64  `json:"listing_id"`
+	ShareToken   string `json:"share_token"`
+	PackName     string `json:"pack_name"`
+	AuthorName   string `json:"author_name"`
+	CategoryName string `json:"category_name"`
+	ShareMode    string `json:"share_mode"`
+	CreditsPrice int    `json:"credits_price"`
+	ViewedAt     string `json:"viewed_at"`
+}
+
+// recordRecentlyViewed moves listingID to the front of userID's recently
+// viewed list and prunes the list to recentlyViewedCap entries. Row ids give
+// the order, so a repeated view re-inserts the row instead of updating it.
+func recordRecentlyViewed(userID, listingID int64) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[RECENTLY-VIEWED] begin transaction error: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM user_recently_viewed WHERE user_id = ? AND listing_id = ?", userID, listingID); err != nil {
+		log.Printf("[RECENTLY-VIEWED] failed to record view (user=%d, listing=%d): %v", userID, listingID, err)
+		return
+	}
+	if _, err := tx.Exec("INSERT INTO user_recently_viewed (user_id, listing_id) VALUES (?, ?)", userID, listingID); err != nil {
+		log.Printf("[RECENTLY-VIEWED] failed to record view (user=%d, listing=%d): %v", userID, listingID, err)
+		return
+	}
+	if _, err := tx.Exec(`DELETE FROM user_recently_viewed WHERE user_id = ? AND id NOT IN (
+		SELECT id FROM user_recently_viewed WHERE user_id = ? ORDER BY id DESC LIMIT ?)`,
+		userID, userID, recentlyViewedCap); err != nil {
+		log.Printf("[RECENTLY-VIEWED] failed to prune views of user %d: %v", userID, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[RECENTLY-VIEWED] commit error: %v", err)
+	}
+}
+
+// handleRecentlyViewed handles GET /user/recently-viewed, listing the
+// caller's recently viewed packs most recent first. Packs that are no longer
+// published are left out.
+func handleRecentlyViewed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT pl.id, COALESCE(pl.share_token, ''), pl.pack_name, COALESCE(pl.author_name, ''), COALESCE(c.name, ''),
+		       pl.share_mode, pl.credits_price, v.viewed_at
+		FROM user_recently_viewed v
+		JOIN pack_listings pl ON pl.id = v.listing_id
+		LEFT JOIN categories c ON c.id = pl.category_id
+		WHERE v.user_id = ? AND pl.status = 'published'
+		ORDER BY v.id DESC`, userID)
+	if err != nil {
+		log.Printf("[RECENTLY-VIEWED] failed to list views of user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer rows.Close()
+	items := []RecentlyViewedPack{}
+	for rows.Next() {
+		var it RecentlyViewedPack
+		if err := rows.Scan(&it.ListingID, &it.ShareToken, &it.PackName, &it.AuthorName, &it.CategoryName,
+			&it.ShareMode, &it.CreditsPrice, &it.ViewedAt); err != nil {
+			log.Printf("[RECENTLY-VIEWED] scan error: %v", err)
+			continue
+		}
+		it.ViewedAt = publishAtRFC3339(it.ViewedAt)
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("[RECENTLY-VIEWED] rows iteration error: %v", err)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecentlyViewed(t *testing.T) {
+	useTestDB(t)
+	for id := int64(100); id < 100+recentlyViewedCap+2; id++ {
+		if _, err := db.Exec("INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (?, 1, 1, x'00', 'Pack', 'free', 'published')", id); err != nil {
+			t.Fatal(err)
+		}
+		recordRecentlyViewed(2, id)
+	}
+	recordRecentlyViewed(2, 105) // viewed again: moves to the front
+	if _, err := db.Exec("UPDATE pack_listings SET status = 'delisted' WHERE id = 106"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored int
+	db.QueryRow("SELECT COUNT(*) FROM user_recently_viewed WHERE user_id = 2").Scan(&stored)
+	if stored != recentlyViewedCap {
+		t.Errorf("stored views = %d, want %d", stored, recentlyViewedCap)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/user/recently-viewed", nil)
+	req.Header.Set("X-User-ID", "2")
+	rec := httptest.NewRecorder()
+	handleRecentlyViewed(rec, req)
+	var resp struct {
+		Items []RecentlyViewedPack `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body.String())
+	}
+	// 22 views pruned to 20 (100 and 101 dropped), minus the delisted 106.
+	if len(resp.Items) != recentlyViewedCap-1 {
+		t.Fatalf("items = %d, want %d", len(resp.Items), recentlyViewedCap-1)
+	}
+	if resp.Items[0].ListingID != 105 || resp.Items[1].ListingID != 100+recentlyViewedCap+1 {
+		t.Errorf("order starts with %d, %d", resp.Items[0].ListingID, resp.Items[1].ListingID)
+	}
+	for _, it := range resp.Items {
+		if it.ListingID == 100 || it.ListingID == 101 || it.ListingID == 106 {
+			t.Errorf("unexpected pack %d in list", it.ListingID)
+		}
+	}
+}
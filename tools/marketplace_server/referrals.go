@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Referral program. Every user has a referral code; a new account registered
+// with someone's code is linked to them in the referrals table, keyed by the
+// referred user so each account can be referred, and rewarded, only once.
+// When the referred user makes their first credits purchase both sides get
+// the configured rewards as 'referral' credits transactions.
+const (
+	referralCodeLen     = 8
+	referralCodeCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I look-alikes
+	maxReferralReward   = 100000
+)
+
+// generateReferralCode returns a random referral code.
+func generateReferralCode() string {
+	b := make([]byte, referralCodeLen)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(referralCodeCharset))))
+		if err != nil {
+			return ""
+		}
+		b[i] = referralCodeCharset[n.Int64()]
+	}
+	return string(b)
+}
+
+// normalizeReferralCode upper-cases a code typed or pasted by a user.
+func normalizeReferralCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// getUserReferralCode returns the referral code of userID, assigning one on
+// first use.
+func getUserReferralCode(userID int64) (string, error) {
+	var code string
+	if err := db.QueryRow("SELECT COALESCE(referral_code, '') FROM users WHERE id = ?", userID).Scan(&code); err != nil {
+		return "", err
+	}
+	// A collision with another user's code violates the unique index; retry
+	// with a fresh code.
+	for attempt := 0; code == "" && attempt < 5; attempt++ {
+		candidate := generateReferralCode()
+		if candidate == "" {
+			continue
+		}
+		if _, err := db.Exec("UPDATE users SET referral_code = ? WHERE id = ? AND referral_code IS NULL", candidate, userID); err != nil {
+			continue
+		}
+		db.QueryRow("SELECT COALESCE(referral_code, '') FROM users WHERE id = ?", userID).Scan(&code)
+	}
+	if code == "" {
+		return "", sql.ErrNoRows
+	}
+	return code, nil
+}
+
+// getReferralRewards returns the credits granted to the referrer and to the
+// referred user on the referred user's first purchase. 0 disables a side.
+func getReferralRewards() (referrer, referred int) {
+	referrer, _ = strconv.Atoi(getSetting("referral_reward_referrer"))
+	referred, _ = strconv.Atoi(getSetting("referral_reward_referred"))
+	if referrer < 0 {
+		referrer = 0
+	}
+	if referred < 0 {
+		referred = 0
+	}
+	return referrer, referred
+}
+
+// linkReferral records that newUserID signed up with code. It is a no-op for
+// unknown codes, self-referrals and accounts on an email that already had an
+// account before, since those share a wallet with an existing customer.
+func linkReferral(newUserID int64, code string) {
+	code = normalizeReferralCode(code)
+	if code == "" {
+		return
+	}
+	var referrerID int64
+	var referrerEmail string
+	if err := db.QueryRow("SELECT id, COALESCE(email, '') FROM users WHERE referral_code = ?", code).Scan(&referrerID, &referrerEmail); err != nil {
+		return
+	}
+	if referrerID == newUserID {
+		return
+	}
+	email := getEmailForUser(newUserID)
+	if email != "" {
+		if strings.EqualFold(email, referrerEmail) {
+			log.Printf("[REFERRAL] ignoring self-referral of user %d by user %d (same email)", newUserID, referrerID)
+			return
+		}
+		var others int
+		db.QueryRow("SELECT COUNT(*) FROM users WHERE email = ? AND id != ?", email, newUserID).Scan(&others)
+		if others > 0 {
+			return
+		}
+	}
+	if _, err := db.Exec("INSERT OR IGNORE INTO referrals (referred_user_id, referrer_id) VALUES (?, ?)", newUserID, referrerID); err != nil {
+		log.Printf("[REFERRAL] failed to link user %d to referrer %d: %v", newUserID, referrerID, err)
+		return
+	}
+	log.Printf("[REFERRAL] user %d signed up with the code of user %d", newUserID, referrerID)
+}
+
+// grantReferralRewards pays the referral rewards of userID once, after their
+// first purchase has been committed. Later calls are no-ops.
+func grantReferralRewards(userID int64) {
+	referrerReward, referredReward := getReferralRewards()
+	if referrerReward == 0 && referredReward == 0 {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[REFERRAL] failed to begin transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var referrerID int64
+	if err := tx.QueryRow("SELECT referrer_id FROM referrals WHERE referred_user_id = ? AND rewarded_at IS NULL", userID).Scan(&referrerID); err != nil {
+		return
+	}
+	res, err := tx.Exec(`UPDATE referrals SET rewarded_at = CURRENT_TIMESTAMP, referrer_reward = ?, referred_reward = ?
+		WHERE referred_user_id = ? AND rewarded_at IS NULL`, referrerReward, referredReward, userID)
+	if err != nil {
+		log.Printf("[REFERRAL] failed to mark referral of user %d rewarded: %v", userID, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return
+	}
+	for _, grant := range []struct {
+		userID      int64
+		amount      int
+		description string
+	}{
+		{referrerID, referrerReward, "Referral reward for inviting a new user"},
+		{userID, referredReward, "Referral reward for your first purchase"},
+	} {
+		if grant.amount == 0 {
+			continue
+		}
+		if err := addWalletBalance(tx, grant.userID, float64(grant.amount)); err != nil {
+			log.Printf("[REFERRAL] failed to credit user %d: %v", grant.userID, err)
+			return
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO credits_transactions (user_id, transaction_type, amount, description) VALUES (?, 'referral', ?, ?)",
+			grant.userID, float64(grant.amount), grant.description,
+		); err != nil {
+			log.Printf("[REFERRAL] failed to record referral transaction for user %d: %v", grant.userID, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[REFERRAL] failed to commit rewards of user %d: %v", userID, err)
+		return
+	}
+	log.Printf("[REFERRAL] rewarded referrer %d (%d credits) and user %d (%d credits)", referrerID, referrerReward, userID, referredReward)
+}
+
+// handleUserReferrals handles GET /user/referrals: the user's referral code
+// and link, and how many sign-ups and rewards it has brought.
+func handleUserReferrals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	code, err := getUserReferralCode(userID)
+	if err != nil {
+		log.Printf("[REFERRAL] failed to get referral code of user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+
+	type referralEntry struct {
+		JoinedAt   string `json:"joined_at"`
+		Rewarded   bool   `json:"rewarded"`
+		RewardedAt string `json:"rewarded_at,omitempty"`
+	}
+	rows, err := db.Query(`SELECT created_at, COALESCE(rewarded_at, '') FROM referrals
+		WHERE referrer_id = ? ORDER BY created_at DESC LIMIT 100`, userID)
+	if err != nil {
+		log.Printf("[REFERRAL] failed to query referrals of user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer rows.Close()
+	referrals := []referralEntry{}
+	for rows.Next() {
+		var e referralEntry
+		if err := rows.Scan(&e.JoinedAt, &e.RewardedAt); err != nil {
+			continue
+		}
+		e.Rewarded = e.RewardedAt != ""
+		referrals = append(referrals, e)
+	}
+
+	var signups, rewarded int
+	var earned float64
+	db.QueryRow(`SELECT COUNT(*), COUNT(rewarded_at), COALESCE(SUM(referrer_reward), 0)
+		FROM referrals WHERE referrer_id = ?`, userID).Scan(&signups, &rewarded, &earned)
+	referrerReward, referredReward := getReferralRewards()
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"code":            code,
+		"link":            "/user/register?ref=" + code,
+		"signups":         signups,
+		"rewarded":        rewarded,
+		"credits_earned":  earned,
+		"referrer_reward": referrerReward,
+		"referred_reward": referredReward,
+		"referrals":       referrals,
+	})
+}
+
+// handleAdminReferralSettings handles GET/POST /admin/settings/referrals.
+func handleAdminReferralSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		referrer, referred := getReferralRewards()
+		jsonResponse(w, http.StatusOK, map[string]int{"referrer_reward": referrer, "referred_reward": referred})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	referrer, err1 := strconv.Atoi(strings.TrimSpace(r.FormValue("referrer_reward")))
+	referred, err2 := strconv.Atoi(strings.TrimSpace(r.FormValue("referred_reward")))
+	if err1 != nil || err2 != nil || referrer < 0 || referred < 0 || referrer > maxReferralReward || referred > maxReferralReward {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "奖励必须是 0-100000 之间的整数"})
+		return
+	}
+	for key, value := range map[string]int{"referral_reward_referrer": referrer, "referral_reward_referred": referred} {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, strconv.Itoa(value)); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReferralRewards(t *testing.T) {
+	useTestDB(t)
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (10, 'sn', 'referrer', 'Referrer', 'referrer@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (11, 'sn', 'friend', 'Friend', 'friend@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (12, 'sn', 'second-sn', 'Referrer 2', 'referrer@example.com')")
+	mustExec(t, "INSERT INTO settings (key, value) VALUES ('referral_reward_referrer', '50'), ('referral_reward_referred', '20')")
+
+	code, err := getUserReferralCode(10)
+	if err != nil || len(code) != referralCodeLen {
+		t.Fatalf("getUserReferralCode = %q, %v", code, err)
+	}
+	if again, _ := getUserReferralCode(10); again != code {
+		t.Errorf("referral code changed from %q to %q", code, again)
+	}
+
+	linkReferral(10, code)
+	linkReferral(12, code)
+	linkReferral(11, " "+strings.ToLower(code)+" ")
+	linkReferral(11, "NOSUCHCODE")
+	var links int
+	db.QueryRow("SELECT COUNT(*) FROM referrals").Scan(&links)
+	if links != 1 {
+		t.Fatalf("referrals = %d, want only the friend's", links)
+	}
+
+	grantReferralRewards(11)
+	grantReferralRewards(11)
+	if got := getWalletBalance(10); got != 50 {
+		t.Errorf("referrer balance = %v, want 50", got)
+	}
+	if got := getWalletBalance(11); got != 20 {
+		t.Errorf("referred balance = %v, want 20", got)
+	}
+	var txCount int
+	db.QueryRow("SELECT COUNT(*) FROM credits_transactions WHERE transaction_type = 'referral'").Scan(&txCount)
+	if txCount != 2 {
+		t.Errorf("referral transactions = %d, want 2", txCount)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/user/referrals", nil)
+	req.Header.Set("X-User-ID", "10")
+	rec := httptest.NewRecorder()
+	handleUserReferrals(rec, req)
+	var resp map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp["code"] != code || resp["signups"] != float64(1) || resp["rewarded"] != float64(1) || resp["credits_earned"] != float64(50) {
+		t.Errorf("stats: status %d, %v", rec.Code, resp)
+	}
+}
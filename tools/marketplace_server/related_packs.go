@@ -0,0 +1,89 @@
+package main
+
+import "time"
+
+const (
+	// relatedPackLimit is how many related packs a pack detail page shows.
+	relatedPackLimit = 4
+	// relatedPackCandidates is how many are cached per pack, so that enough
+	// remain after hiding the ones the viewer already owns.
+	relatedPackCandidates = 12
+)
+
+// RelatedPack is a pack suggested on another pack's detail page.
+type RelatedPack struct {
+	ListingID     int64
+	ShareToken    string
+	PackName      string
+	AuthorName    string
+	ShareMode     string
+	CreditsPrice  int
+	OriginalPrice int // price before a running flash sale, else 0
+	DownloadCount int
+	SameAuthor    bool
+}
+
+// queryRelatedPacks returns other published packs by the same author,
+// followed by the newest packs of the same category, in a single query. The
+// returned time is when a flash sale among them next starts or ends (zero if
+// none), after which the prices are stale.
+func queryRelatedPacks(listingID int64, now time.Time) ([]RelatedPack, time.Time, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.share_token, p.pack_name, COALESCE(p.author_name, ''), p.share_mode,
+		       p.credits_price, p.download_count, p.user_id = cur.user_id
+		FROM pack_listings p
+		JOIN (SELECT user_id, category_id FROM pack_listings WHERE id = ?) cur
+		  ON p.user_id = cur.user_id OR p.category_id = cur.category_id
+		WHERE p.status = 'published' AND p.id != ? AND COALESCE(p.share_token, '') != ''
+		ORDER BY p.user_id = cur.user_id DESC, p.category_id = cur.category_id DESC, p.created_at DESC, p.id DESC
+		LIMIT ?`, listingID, listingID, relatedPackCandidates)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+	var related []RelatedPack
+	var ids []int64
+	for rows.Next() {
+		var rp RelatedPack
+		if err := rows.Scan(&rp.ListingID, &rp.ShareToken, &rp.PackName, &rp.AuthorName, &rp.ShareMode,
+			&rp.CreditsPrice, &rp.DownloadCount, &rp.SameAuthor); err != nil {
+			return nil, time.Time{}, err
+		}
+		related = append(related, rp)
+		ids = append(ids, rp.ListingID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var next time.Time
+	sales := loadFlashSales("pack_listings", ids)
+	for i := range related {
+		sale, ok := sales[related[i].ListingID]
+		if !ok || related[i].ShareMode == "free" {
+			continue
+		}
+		if sale.ActiveAt(now) {
+			related[i].OriginalPrice = related[i].CreditsPrice
+			related[i].CreditsPrice = int(sale.Price)
+		}
+		next = earliestTime(next, sale.NextChange(now))
+	}
+	return related, next, nil
+}
+
+// visibleRelatedPacks drops the packs in owned and returns at most
+// relatedPackLimit of the rest.
+func visibleRelatedPacks(related []RelatedPack, owned map[int64]bool) []RelatedPack {
+	visible := make([]RelatedPack, 0, relatedPackLimit)
+	for _, rp := range related {
+		if owned[rp.ListingID] {
+			continue
+		}
+		visible = append(visible, rp)
+		if len(visible) == relatedPackLimit {
+			break
+		}
+	}
+	return visible
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueryRelatedPacks(t *testing.T) {
+	useTestDB(t)
+	insert := func(id, userID, categoryID int64, status, createdAt string) {
+		t.Helper()
+		mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status, share_token, created_at)
+			VALUES (?, ?, ?, x'00', 'Pack', 'per_use', 10, ?, ?, ?)`, id, userID, categoryID, status, fmt.Sprintf("tok%d", id), createdAt)
+	}
+	insert(100, 1, 1, "published", "2026-01-01 00:00:00") // the viewed pack
+	insert(101, 1, 2, "published", "2026-01-02 00:00:00") // same author, other category
+	insert(102, 2, 1, "published", "2026-01-03 00:00:00") // same category
+	insert(103, 3, 1, "published", "2026-01-04 00:00:00") // same category, newer
+	insert(104, 1, 1, "delisted", "2026-01-05 00:00:00")  // unpublished
+	insert(105, 4, 2, "published", "2026-01-06 00:00:00") // unrelated
+
+	related, _, err := queryRelatedPacks(100, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for _, rp := range related {
+		got = append(got, rp.ListingID)
+	}
+	want := []int64{101, 103, 102}
+	if len(got) != len(want) {
+		t.Fatalf("related = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("related = %v, want %v", got, want)
+		}
+	}
+	if !related[0].SameAuthor || related[1].SameAuthor {
+		t.Errorf("SameAuthor flags = %v, %v", related[0].SameAuthor, related[1].SameAuthor)
+	}
+
+	visible := visibleRelatedPacks(related, map[int64]bool{103: true})
+	if len(visible) != 2 || visible[0].ListingID != 101 || visible[1].ListingID != 102 {
+		t.Errorf("visible without owned pack = %+v", visible)
+	}
+}
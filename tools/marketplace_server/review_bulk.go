@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBulkReviewIDs caps how many listings one bulk review request may touch.
+const maxBulkReviewIDs = 200
+
+// BulkReviewResult reports what happened to one listing of a bulk review.
+type BulkReviewResult struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`          // "published", "scheduled", "rejected" or "skipped"
+	Error  string `json:"error,omitempty"` // why a listing was skipped
+}
+
+// handleBulkReview handles POST /api/admin/review/bulk-approve and
+// /api/admin/review/bulk-reject with a JSON body {"ids": [...], "reason": "..."}
+// (reason is required to reject). All pending listings are updated in one
+// transaction; listings that are missing or no longer pending are skipped and
+// reported per item instead of failing the whole request.
+func handleBulkReview(w http.ResponseWriter, r *http.Request, approve bool) {
+	var body struct {
+		IDs    []int64 `json:"ids"`
+		Reason string  `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.IDs) == 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if len(body.IDs) > maxBulkReviewIDs {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "too_many_ids"})
+		return
+	}
+	reason := strings.TrimSpace(body.Reason)
+	if !approve && reason == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "reject_reason_required"})
+		return
+	}
+
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	reviewedAt := time.Now().UTC().Format("2006-01-02 15:04:05")
+	newStatus := "rejected"
+	if approve {
+		newStatus = "published"
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[BULK-REVIEW] failed to begin transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+	defer tx.Rollback()
+
+	results := make([]BulkReviewResult, 0, len(body.IDs))
+	seen := make(map[int64]bool, len(body.IDs))
+	var updated []int64
+	var publishedNow []bool // parallel to updated
+	for _, id := range body.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var res sql.Result
+		if approve {
+			// Listings with a future publish_at become 'scheduled'.
+			res, err = tx.Exec("UPDATE pack_listings SET status="+approvedListingStatusSQL+", reviewed_by=?, reviewed_at=? WHERE id=? AND status='pending'",
+				reviewedAt, adminID, reviewedAt, id)
+		} else {
+			res, err = tx.Exec("UPDATE pack_listings SET status='rejected', reject_reason=?, reviewed_by=?, reviewed_at=? WHERE id=? AND status='pending'",
+				reason, adminID, reviewedAt, id)
+		}
+		if err != nil {
+			log.Printf("[BULK-REVIEW] failed to update listing %d: %v", id, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			result := BulkReviewResult{ID: id, Status: "skipped", Error: "invalid_review_status"}
+			var exists int
+			if tx.QueryRow("SELECT 1 FROM pack_listings WHERE id = ?", id).Scan(&exists) != nil {
+				result.Error = "listing_not_found"
+			}
+			results = append(results, result)
+			continue
+		}
+		status := newStatus
+		if approve {
+			tx.QueryRow("SELECT status FROM pack_listings WHERE id = ?", id).Scan(&status)
+		}
+		updated = append(updated, id)
+		publishedNow = append(publishedNow, status == "published")
+		results = append(results, BulkReviewResult{ID: id, Status: status})
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[BULK-REVIEW] failed to commit: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+
+	action := "review_reject"
+	if approve {
+		action = "review_approve"
+	}
+	ip := getClientIP(r)
+	for i, id := range updated {
+		detail := fmt.Sprintf("bulk; reviewed_by=%d; reviewed_at=%s", adminID, reviewedAt)
+		if !approve {
+			detail += "; reason=" + reason
+		}
+		recordAuditLog(adminID, action, fmt.Sprintf("pack_listing:%d", id), detail, ip)
+
+		if approve && publishedNow[i] {
+			globalCache.InvalidateStorefrontsByListingID(id)
+			var shareToken string
+			if err := db.QueryRow("SELECT share_token FROM pack_listings WHERE id = ?", id).Scan(&shareToken); err == nil && shareToken != "" {
+				globalCache.InvalidatePackDetail(shareToken)
+			}
+			go notifyStorefrontFollowers(id)
+		}
+	}
+	if len(updated) > 0 {
+		globalCache.InvalidateHomepage()
+	}
+
+	log.Printf("[BULK-REVIEW] admin %d %s %d of %d listings", adminID, newStatus, len(updated), len(results))
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "ok",
+		"updated": len(updated),
+		"skipped": len(results) - len(updated),
+		"results": results,
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBulkReview(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	for _, q := range []string{
+		"INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 1, 1, x'00', 'A', 'free', 'pending')",
+		"INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (101, 1, 1, x'00', 'B', 'free', 'pending')",
+		"INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (102, 1, 1, x'00', 'C', 'free', 'published')",
+		"INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (103, 1, 1, x'00', 'D', 'free', 'pending')",
+	} {
+		mustExec(t, q)
+	}
+
+	review := func(action, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/review/"+action, strings.NewReader(body))
+		req.Header.Set("X-Admin-ID", "7")
+		rec := httptest.NewRecorder()
+		handleReviewRoutes(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	if code, _ := review("bulk-reject", `{"ids":[103]}`); code != http.StatusBadRequest {
+		t.Errorf("reject without reason: status %d, want 400", code)
+	}
+
+	code, resp := review("bulk-approve", `{"ids":[100,101,102,999,100]}`)
+	if code != http.StatusOK || resp["updated"] != 2.0 || resp["skipped"] != 2.0 {
+		t.Fatalf("approve: status %d, resp %v", code, resp)
+	}
+	results := resp["results"].([]interface{})
+	if got := results[2].(map[string]interface{})["error"]; got != "invalid_review_status" {
+		t.Errorf("published listing error = %v", got)
+	}
+	if got := results[3].(map[string]interface{})["error"]; got != "listing_not_found" {
+		t.Errorf("missing listing error = %v", got)
+	}
+
+	if code, resp = review("bulk-reject", `{"ids":[103,100],"reason":"Broken"}`); code != http.StatusOK || resp["updated"] != 1.0 {
+		t.Fatalf("reject: status %d, resp %v", code, resp)
+	}
+
+	var status, reason string
+	var reviewedBy int64
+	db.QueryRow("SELECT status, COALESCE(reject_reason, ''), reviewed_by FROM pack_listings WHERE id = 103").Scan(&status, &reason, &reviewedBy)
+	if status != "rejected" || reason != "Broken" || reviewedBy != 7 {
+		t.Errorf("listing 103 = %s/%q/%d", status, reason, reviewedBy)
+	}
+	var audits int
+	db.QueryRow("SELECT COUNT(*) FROM admin_audit_log WHERE admin_id = 7 AND action IN ('review_approve', 'review_reject') AND detail LIKE '%reviewed_at=%'").Scan(&audits)
+	if audits != 3 {
+		t.Errorf("audit entries = %d, want 3", audits)
+	}
+
+	// Approvals notify storefront followers in the background; let those
+	// goroutines finish before the test database is closed.
+	time.Sleep(50 * time.Millisecond)
+}
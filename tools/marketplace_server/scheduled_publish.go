@@ -0,0 +1,198 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Scheduled publishing. An author may give a pending pack a publish_at time;
+// when an admin approves it before that time the listing becomes 'scheduled'
+// instead of 'published'. Public pages only ever show 'published' listings, so
+// a scheduled pack stays hidden until startScheduledPublisher flips it.
+const (
+	scheduledPublishCheckRate = time.Minute
+	maxScheduleAhead          = 365 * 24 * time.Hour
+)
+
+// approvedListingStatusSQL is the status an approved listing moves to; it
+// takes the current UTC time ("2006-01-02 15:04:05") as its only argument.
+const approvedListingStatusSQL = "CASE WHEN COALESCE(publish_at, '') > ? THEN 'scheduled' ELSE 'published' END"
+
+// publishAtRFC3339 normalizes a publish_at value, which the driver may return
+// either as stored or already in RFC 3339 form, to RFC 3339 UTC.
+func publishAtRFC3339(s string) string {
+	if t, err := time.Parse("2006-01-02 15:04:05", s); err == nil {
+		return t.Format(time.RFC3339)
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+// invalidateListingCaches drops every cached page that shows listingID.
+func invalidateListingCaches(listingID int64) {
+	globalCache.InvalidateStorefrontsByListingID(listingID)
+	globalCache.InvalidateHomepage()
+	var shareToken string
+	if err := db.QueryRow("SELECT share_token FROM pack_listings WHERE id = ?", listingID).Scan(&shareToken); err == nil && shareToken != "" {
+		globalCache.InvalidatePackDetail(shareToken)
+	}
+}
+
+// loadOwnListingStatus reads listing_id from the form and returns its status
+// if it belongs to the caller. Otherwise it writes the error response and ok
+// is false.
+func loadOwnListingStatus(w http.ResponseWriter, r *http.Request) (userID, listingID int64, status string, ok bool) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return 0, 0, "", false
+	}
+	listingID, err = strconv.ParseInt(r.FormValue("listing_id"), 10, 64)
+	if err != nil || listingID <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "无效的分析包"})
+		return 0, 0, "", false
+	}
+	var ownerID int64
+	if err := db.QueryRow("SELECT user_id, status FROM pack_listings WHERE id = ?", listingID).Scan(&ownerID, &status); err != nil || ownerID != userID {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "分析包不存在"})
+		return 0, 0, "", false
+	}
+	return userID, listingID, status, true
+}
+
+// handleAuthorSchedulePack handles POST /user/author/schedule-pack with form
+// fields listing_id and publish_at (RFC 3339). It sets or changes the release
+// time of a pack that is pending review or already scheduled.
+func handleAuthorSchedulePack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, listingID, status, ok := loadOwnListingStatus(w, r)
+	if !ok {
+		return
+	}
+	if status != "pending" && status != "scheduled" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "只能为待审核或已定时的分析包设置发布时间"})
+		return
+	}
+
+	publishAt, err := time.Parse(time.RFC3339, r.FormValue("publish_at"))
+	now := time.Now()
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "发布时间格式无效"})
+		return
+	}
+	if !publishAt.After(now) || publishAt.After(now.Add(maxScheduleAhead)) {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "发布时间必须在未来一年之内"})
+		return
+	}
+	publishAtStr := publishAt.UTC().Format("2006-01-02 15:04:05")
+
+	res, err := db.Exec("UPDATE pack_listings SET publish_at = ? WHERE id = ? AND user_id = ? AND status IN ('pending', 'scheduled')",
+		publishAtStr, listingID, userID)
+	if err != nil {
+		log.Printf("[SCHEDULED-PUBLISH] failed to schedule listing %d: %v", listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "分析包状态已变化，请刷新后重试"})
+		return
+	}
+	log.Printf("[SCHEDULED-PUBLISH] user %d scheduled listing %d for %s", userID, listingID, publishAtStr)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "status": status, "publish_at": publishAt.UTC().Format(time.RFC3339)})
+}
+
+// handleAuthorUnschedulePack handles POST /user/author/unschedule-pack with
+// form field listing_id. It removes the release time: a pending pack is then
+// published as soon as it is approved, and an approved scheduled pack is
+// published right away.
+func handleAuthorUnschedulePack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, listingID, status, ok := loadOwnListingStatus(w, r)
+	if !ok {
+		return
+	}
+
+	newStatus := status
+	var err error
+	switch status {
+	case "pending":
+		_, err = db.Exec("UPDATE pack_listings SET publish_at = NULL WHERE id = ? AND status = 'pending'", listingID)
+	case "scheduled":
+		newStatus = "published"
+		_, err = db.Exec("UPDATE pack_listings SET status = 'published', publish_at = NULL WHERE id = ? AND status = 'scheduled'", listingID)
+	default:
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "该分析包没有定时发布"})
+		return
+	}
+	if err != nil {
+		log.Printf("[SCHEDULED-PUBLISH] failed to unschedule listing %d: %v", listingID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+
+	if newStatus == "published" {
+		invalidateListingCaches(listingID)
+		go notifyStorefrontFollowers(listingID)
+	}
+	log.Printf("[SCHEDULED-PUBLISH] user %d unscheduled listing %d (%s)", userID, listingID, newStatus)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "status": newStatus})
+}
+
+// startScheduledPublisher periodically publishes scheduled packs whose
+// publish_at has passed.
+func startScheduledPublisher() {
+	go func() {
+		ticker := time.NewTicker(scheduledPublishCheckRate)
+		defer ticker.Stop()
+		for {
+			publishDueScheduledPacks(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// publishDueScheduledPacks flips every scheduled listing due at now to
+// published and returns how many were published.
+func publishDueScheduledPacks(now time.Time) int {
+	rows, err := db.Query("SELECT id FROM pack_listings WHERE status = 'scheduled' AND publish_at <= ?",
+		now.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		log.Printf("[SCHEDULED-PUBLISH] failed to query due listings: %v", err)
+		return 0
+	}
+	var due []int64
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			due = append(due, id)
+		}
+	}
+	rows.Close()
+
+	published := 0
+	for _, id := range due {
+		res, err := db.Exec("UPDATE pack_listings SET status = 'published' WHERE id = ? AND status = 'scheduled'", id)
+		if err != nil {
+			log.Printf("[SCHEDULED-PUBLISH] failed to publish listing %d: %v", id, err)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		published++
+		invalidateListingCaches(id)
+		notifyStorefrontFollowers(id)
+		log.Printf("[SCHEDULED-PUBLISH] published listing %d", id)
+	}
+	return published
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduledPublishing(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 1, 1, x'00', 'Launch', 'free', 'pending')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (101, 1, 1, x'00', 'Live', 'free', 'published')")
+
+	post := func(handler http.HandlerFunc, userID string, form url.Values) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", userID)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	status := func(id int64) string {
+		var s string
+		db.QueryRow("SELECT status FROM pack_listings WHERE id = ?", id).Scan(&s)
+		return s
+	}
+
+	publishAt := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	form := url.Values{"listing_id": {"100"}, "publish_at": {publishAt.Format(time.RFC3339)}}
+	if code, _ := post(handleAuthorSchedulePack, "2", form); code != http.StatusNotFound {
+		t.Errorf("schedule by non-owner: status %d, want 404", code)
+	}
+	if code, _ := post(handleAuthorSchedulePack, "1", url.Values{"listing_id": {"101"}, "publish_at": {publishAt.Format(time.RFC3339)}}); code != http.StatusBadRequest {
+		t.Errorf("schedule published pack: status %d, want 400", code)
+	}
+	if code, _ := post(handleAuthorSchedulePack, "1", url.Values{"listing_id": {"100"}, "publish_at": {"2000-01-01T00:00:00Z"}}); code != http.StatusBadRequest {
+		t.Errorf("schedule in the past: status %d, want 400", code)
+	}
+	if code, resp := post(handleAuthorSchedulePack, "1", form); code != http.StatusOK {
+		t.Fatalf("schedule: status %d, resp %v", code, resp)
+	}
+
+	// Approval before publish_at leaves the pack scheduled and hidden.
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/review/100/approve", nil)
+	req.Header.Set("X-Admin-ID", "7")
+	rec := httptest.NewRecorder()
+	handleApproveReview(rec, req, 100)
+	if rec.Code != http.StatusOK || status(100) != "scheduled" {
+		t.Fatalf("approve: status %d, listing %s", rec.Code, status(100))
+	}
+
+	if n := publishDueScheduledPacks(time.Now()); n != 0 || status(100) != "scheduled" {
+		t.Errorf("published %d before publish_at, listing %s", n, status(100))
+	}
+	if n := publishDueScheduledPacks(publishAt.Add(time.Second)); n != 1 || status(100) != "published" {
+		t.Errorf("published %d after publish_at, listing %s", n, status(100))
+	}
+
+	// Un-scheduling an approved pack publishes it right away.
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, publish_at) VALUES (102, 1, 1, x'00', 'Later', 'free', 'scheduled', ?)",
+		publishAt.Format("2006-01-02 15:04:05"))
+	if code, resp := post(handleAuthorUnschedulePack, "1", url.Values{"listing_id": {"102"}}); code != http.StatusOK || resp["status"] != "published" {
+		t.Errorf("unschedule: status %d, resp %v", code, resp)
+	}
+
+	// Let the follower notification goroutines finish before the test
+	// database is closed.
+	time.Sleep(50 * time.Millisecond)
+}
+
+// The background publisher notifies followers without any request having
+// been served, e.g. right after a restart, using public_base_url.
+func TestScheduledPublishNotifiesFollowersAfterRestart(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+	server := newMockSMTPServer(t)
+	smtpJSON, _ := json.Marshal(server.config())
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('smtp_config', ?), ('public_base_url', 'https://market.example.com')", string(smtpJSON))
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'alice', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, auto_add_enabled) VALUES (10, 1, 'Shop', 'shop', 1)")
+	mustExec(t, "INSERT INTO storefront_followers (storefront_id, user_id) VALUES (10, 2)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, share_token, publish_at) VALUES (100, 1, 1, x'00', 'Launch', 'free', 'scheduled', 'tok', '2026-01-01 00:00:00')")
+
+	if n := publishDueScheduledPacks(time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC)); n != 1 {
+		t.Fatalf("published %d, want 1", n)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 1 || !strings.Contains(server.messages[0], "https://market.example.com/pack/tok") {
+		t.Errorf("follower emails = %q", server.messages)
+	}
+}
@@ -0,0 +1,1829 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Versioned schema migrations. Each migration is applied once, in version
+// order, and recorded in schema_migrations; initDB runs the pending ones at
+// startup. Databases created before schema_migrations existed have every step
+// run once on their next start, which is safe because every step is
+// idempotent: tables and indexes use IF NOT EXISTS, addColumns skips columns
+// that are already there and data steps only fill in what is missing. Keep new
+// steps idempotent too, so a crash between applying a step and recording it
+// does no harm. Append new migrations with the next version; never renumber,
+// edit or remove an existing one.
+
+// migration is one numbered schema change.
+type migration struct {
+	version int
+	name    string
+	up      func(database *sql.DB) error
+}
+
+// runMigrations applies the migrations not yet recorded in schema_migrations.
+func runMigrations(database *sql.DB) error {
+	if _, err := database.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := database.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := m.up(database); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if _, err := database.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+			return fmt.Errorf("failed to record migration %d (%s): %w", m.version, m.name, err)
+		}
+		count++
+	}
+	if count > 0 {
+		log.Printf("[MIGRATE] applied %d schema migration(s), now at version %d", count, migrations[len(migrations)-1].version)
+	}
+	return nil
+}
+
+// addColumns adds the columns defined by defs (as in ALTER TABLE ... ADD
+// COLUMN, e.g. "version INTEGER DEFAULT 1") to table, skipping those it
+// already has.
+func addColumns(database *sql.DB, table string, defs ...string) error {
+	rows, err := database.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		existing[strings.ToLower(name)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+
+	for _, def := range defs {
+		column := strings.ToLower(strings.Fields(def)[0])
+		if existing[column] {
+			continue
+		}
+		if _, err := database.Exec("ALTER TABLE " + table + " ADD COLUMN " + def); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+		}
+	}
+	return nil
+}
+
+// migrations lists every schema change in the order it is applied.
+var migrations = []migration{
+	// Create users table (new schema with auth_type/auth_id)
+	{1, "create_users", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				auth_type TEXT NOT NULL,
+				auth_id TEXT NOT NULL,
+				display_name TEXT NOT NULL,
+				email TEXT,
+				credits_balance REAL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(auth_type, auth_id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create users table: %w", err)
+		}
+		return nil
+	}},
+
+	// Migrate users table: rename oauth_provider/oauth_provider_id to auth_type/auth_id
+	{2, "rename_users_oauth_columns", func(database *sql.DB) error {
+		var usersTableSQL string
+		err := database.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='users'").Scan(&usersTableSQL)
+		if err == nil && strings.Contains(usersTableSQL, "oauth_provider") {
+			if _, err := database.Exec(`
+				CREATE TABLE users_new (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					auth_type TEXT NOT NULL,
+					auth_id TEXT NOT NULL,
+					display_name TEXT NOT NULL,
+					email TEXT,
+					credits_balance REAL DEFAULT 0,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					UNIQUE(auth_type, auth_id)
+				)
+			`); err != nil {
+				return fmt.Errorf("failed to create users_new table: %w", err)
+			}
+			if _, err := database.Exec(`
+				INSERT INTO users_new (id, auth_type, auth_id, display_name, email, credits_balance, created_at)
+				SELECT id, oauth_provider, oauth_provider_id, display_name, email, credits_balance, created_at FROM users
+			`); err != nil {
+				return fmt.Errorf("failed to migrate users data: %w", err)
+			}
+			if _, err := database.Exec(`DROP TABLE users`); err != nil {
+				return fmt.Errorf("failed to drop old users table: %w", err)
+			}
+			if _, err := database.Exec(`ALTER TABLE users_new RENAME TO users`); err != nil {
+				return fmt.Errorf("failed to rename users_new table: %w", err)
+			}
+			log.Println("Migrated users table: oauth_provider/oauth_provider_id → auth_type/auth_id")
+		}
+		return nil
+	}},
+
+	// Create categories table
+	{3, "create_categories", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS categories (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				description TEXT,
+				is_preset INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create categories table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create pack_listings table
+	{4, "create_pack_listings", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_listings (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				category_id INTEGER NOT NULL,
+				file_data BLOB NOT NULL,
+				pack_name TEXT NOT NULL,
+				pack_description TEXT,
+				source_name TEXT,
+				author_name TEXT,
+				share_mode TEXT NOT NULL,
+				credits_price INTEGER DEFAULT 0,
+				status TEXT DEFAULT 'pending',
+				download_count INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (category_id) REFERENCES categories(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_listings table: %w", err)
+		}
+		return nil
+	}},
+
+	// Add review-related columns to pack_listings
+	{5, "add_pack_listings_review", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings",
+			"reject_reason TEXT",
+			"reviewed_by INTEGER REFERENCES admin_credentials(id)",
+			"reviewed_at DATETIME",
+			"meta_info TEXT DEFAULT '{}'",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add billing-related columns to pack_listings
+	{6, "add_pack_listings_billing", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings",
+			"valid_days INTEGER DEFAULT 0",
+			"billing_cycle TEXT DEFAULT ''",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add encryption_password column for paid pack encryption
+	{7, "add_pack_listings_encryption_password", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings", "encryption_password TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add version column for pack replacement tracking
+	{8, "add_pack_listings_version", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings", "version INTEGER DEFAULT 1"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add share_token column for public URLs (prevents sequential ID enumeration)
+	{9, "add_pack_listings_share_token", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings", "share_token TEXT"); err != nil {
+			return err
+		}
+		database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_pack_listings_share_token ON pack_listings(share_token) WHERE share_token IS NOT NULL")
+		// Backfill share_token for existing rows that don't have one
+		backfillShareTokens(database)
+		return nil
+	}},
+
+	// Add file_storage/file_key columns for pack files kept outside the database
+	// (empty file_storage means the file is in file_data)
+	{10, "add_pack_listings_file_storage", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings",
+			"file_storage TEXT DEFAULT ''",
+			"file_key TEXT DEFAULT ''",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add upload scan columns (status 'quarantined' blocks review until an admin releases it)
+	{11, "add_pack_listings_scan", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings",
+			"scan_status TEXT DEFAULT ''",
+			"scan_detail TEXT DEFAULT ''",
+			"scanned_at DATETIME",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add file_sha256 column for pack file integrity checks
+	{12, "add_pack_listings_file_sha256", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings", "file_sha256 TEXT"); err != nil {
+			return err
+		}
+		// Backfill file_sha256 for existing rows that don't have one
+		backfillPackFileHashes(database)
+		return nil
+	}},
+
+	// Add publish_at column for scheduled publishing (UTC; status 'scheduled' until it passes)
+	{13, "add_pack_listings_publish_at", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings", "publish_at DATETIME"); err != nil {
+			return err
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_pack_listings_scheduled ON pack_listings(status, publish_at)")
+		return nil
+	}},
+
+	// Add flash sale columns (UTC; sale_price applies while sale_start <= now < sale_end)
+	{14, "add_pack_listings_flash_sale", func(database *sql.DB) error {
+		if err := addColumns(database, "pack_listings",
+			"sale_price INTEGER",
+			"sale_start DATETIME",
+			"sale_end DATETIME",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add username and password_hash columns to users table
+	{15, "add_users_username_password", func(database *sql.DB) error {
+		if err := addColumns(database, "users",
+			"username TEXT",
+			"password_hash TEXT",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add is_blocked column to users table
+	{16, "add_users_is_blocked", func(database *sql.DB) error {
+		if err := addColumns(database, "users", "is_blocked INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+		// Why a user is blocked and until when ('' = permanent), see userBlockStatus
+		if err := addColumns(database, "users",
+			"blocked_reason TEXT DEFAULT ''",
+			"blocked_until TEXT DEFAULT ''",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add email_allowed column to users table (default 1 = allowed)
+	{17, "add_users_email_allowed", func(database *sql.DB) error {
+		if err := addColumns(database, "users", "email_allowed INTEGER DEFAULT 1"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add preferred_lang column to users table (language for emails; '' = site default)
+	{18, "add_users_preferred_lang", func(database *sql.DB) error {
+		if err := addColumns(database, "users", "preferred_lang TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		// Create unique index on username (ALTER TABLE ADD COLUMN does not support UNIQUE in SQLite)
+		database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username) WHERE username IS NOT NULL")
+		return nil
+	}},
+
+	// Create user_downloads table
+	{19, "create_user_downloads", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS user_downloads (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				downloaded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create user_downloads table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create credits_transactions table
+	{20, "create_credits_transactions", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS credits_transactions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				transaction_type TEXT NOT NULL,
+				amount REAL NOT NULL,
+				listing_id INTEGER,
+				description TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create credits_transactions table: %w", err)
+		}
+		return nil
+	}},
+
+	// Add ip_address column to credits_transactions for sales tracking
+	{21, "add_credits_transactions_ip_address", func(database *sql.DB) error {
+		if err := addColumns(database, "credits_transactions", "ip_address TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		// Credits expiry: grants carry their expiry, 'expiry' rows the grant they write off
+		if err := addColumns(database, "credits_transactions",
+			"expires_at DATETIME",
+			"expired_at DATETIME",
+			"expiry_grant_id INTEGER",
+		); err != nil {
+			return err
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_credits_transactions_expires ON credits_transactions(expires_at) WHERE expired_at IS NULL")
+		return nil
+	}},
+
+	// Add ip_address column to user_downloads for buyer region tracking
+	{22, "add_user_downloads_ip_address", func(database *sql.DB) error {
+		if err := addColumns(database, "user_downloads", "ip_address TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Create user_purchased_packs table
+	{23, "create_user_purchased_packs", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS user_purchased_packs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				is_hidden INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id),
+				UNIQUE(user_id, listing_id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create user_purchased_packs table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create pack_usage_records table
+	{24, "create_pack_usage_records", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_usage_records (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				used_count INTEGER NOT NULL DEFAULT 0,
+				total_purchased INTEGER NOT NULL DEFAULT 0,
+				last_used_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id),
+				UNIQUE(user_id, listing_id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_usage_records table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create pack_usage_log table (deduplication via UNIQUE constraint)
+	{25, "create_pack_usage_log", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_usage_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				used_at TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(user_id, listing_id, used_at)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_usage_log table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create withdrawal_records table
+	{26, "create_withdrawal_records", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS withdrawal_records (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				credits_amount REAL NOT NULL,
+				cash_rate REAL NOT NULL,
+				cash_amount REAL NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create withdrawal_records table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create user_payment_info table
+	{27, "create_user_payment_info", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS user_payment_info (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL UNIQUE,
+				payment_type TEXT NOT NULL,
+				payment_details TEXT NOT NULL,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create user_payment_info table: %w", err)
+		}
+		return nil
+	}},
+
+	// Add payment/fee/status columns to withdrawal_records
+	{28, "add_withdrawal_records_payment", func(database *sql.DB) error {
+		if err := addColumns(database, "withdrawal_records",
+			"payment_type TEXT DEFAULT ''",
+			"payment_details TEXT DEFAULT '{}'",
+			"fee_rate REAL DEFAULT 0",
+			"fee_amount REAL DEFAULT 0",
+			"net_amount REAL DEFAULT 0",
+			"status TEXT DEFAULT 'paid'",
+			"display_name TEXT DEFAULT ''",
+			"paid_at DATETIME",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Create settings table
+	{29, "create_settings", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS settings (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create settings table: %w", err)
+		}
+		return nil
+	}},
+
+	// Insert preset e-commerce categories
+	{30, "insert_preset_categories", func(database *sql.DB) error {
+		presetCategories := []struct {
+			Name        string
+			Description string
+		}{
+			{"Shopify", "Shopify e-commerce platform analysis packs"},
+			{"BigCommerce", "BigCommerce e-commerce platform analysis packs"},
+			{"eBay", "eBay marketplace analysis packs"},
+			{"Etsy", "Etsy marketplace analysis packs"},
+		}
+		for _, cat := range presetCategories {
+			_, err := database.Exec(
+				"INSERT OR IGNORE INTO categories (name, description, is_preset) VALUES (?, ?, 1)",
+				cat.Name, cat.Description,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert preset category %s: %w", cat.Name, err)
+			}
+		}
+		return nil
+	}},
+
+	// Insert default settings
+	{31, "insert_default_initial_credits_balance", func(database *sql.DB) error {
+		_, err := database.Exec(
+			"INSERT OR IGNORE INTO settings (key, value) VALUES ('initial_credits_balance', '0')",
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert default settings: %w", err)
+		}
+		return nil
+	}},
+
+	// Insert default credit_cash_rate setting
+	{32, "insert_default_credit_cash_rate", func(database *sql.DB) error {
+		_, err := database.Exec(
+			"INSERT OR IGNORE INTO settings (key, value) VALUES ('credit_cash_rate', '0')",
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert credit_cash_rate setting: %w", err)
+		}
+		return nil
+	}},
+
+	// Migrate admin_credentials table: detect old CHECK(id=1) constraint and rebuild
+	{33, "rebuild_admin_credentials", func(database *sql.DB) error {
+		var adminTableSQL string
+		err := database.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='admin_credentials'").Scan(&adminTableSQL)
+		if err == nil && strings.Contains(adminTableSQL, "CHECK") {
+			// Old table detected, migrate to new schema
+			if _, err := database.Exec(`
+				CREATE TABLE admin_credentials_new (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					username TEXT NOT NULL UNIQUE,
+					password_hash TEXT NOT NULL,
+					role TEXT NOT NULL DEFAULT 'regular',
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)
+			`); err != nil {
+				return fmt.Errorf("failed to create admin_credentials_new table: %w", err)
+			}
+			if _, err := database.Exec(`
+				INSERT INTO admin_credentials_new (id, username, password_hash, role, created_at)
+				SELECT id, username, password_hash, 'super', created_at FROM admin_credentials
+			`); err != nil {
+				return fmt.Errorf("failed to migrate admin_credentials data: %w", err)
+			}
+			if _, err := database.Exec(`DROP TABLE admin_credentials`); err != nil {
+				return fmt.Errorf("failed to drop old admin_credentials table: %w", err)
+			}
+			if _, err := database.Exec(`ALTER TABLE admin_credentials_new RENAME TO admin_credentials`); err != nil {
+				return fmt.Errorf("failed to rename admin_credentials_new table: %w", err)
+			}
+		} else {
+			// Fresh install or already migrated: create new schema
+			if _, err := database.Exec(`
+				CREATE TABLE IF NOT EXISTS admin_credentials (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					username TEXT NOT NULL UNIQUE,
+					password_hash TEXT NOT NULL,
+					role TEXT NOT NULL DEFAULT 'regular',
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)
+			`); err != nil {
+				return fmt.Errorf("failed to create admin_credentials table: %w", err)
+			}
+		}
+		return nil
+	}},
+
+	// Add permissions column to admin_credentials
+	{34, "add_admin_credentials_permissions", func(database *sql.DB) error {
+		if err := addColumns(database, "admin_credentials", "permissions TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add TOTP two-factor columns to admin_credentials.
+	// totp_secret is AES-encrypted; totp_recovery_codes is a JSON array of SHA-256 hashes.
+	{35, "add_admin_credentials_totp", func(database *sql.DB) error {
+		if err := addColumns(database, "admin_credentials",
+			"totp_secret TEXT DEFAULT ''",
+			"totp_enabled INTEGER DEFAULT 0",
+			"totp_last_counter INTEGER DEFAULT 0",
+			"totp_recovery_codes TEXT DEFAULT ''",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Create notifications table
+	{36, "create_notifications", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS notifications (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				title TEXT NOT NULL,
+				content TEXT NOT NULL,
+				target_type TEXT NOT NULL DEFAULT 'broadcast',
+				effective_date DATETIME NOT NULL,
+				display_duration_days INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL DEFAULT 'active',
+				created_by INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (created_by) REFERENCES admin_credentials(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create notifications table: %w", err)
+		}
+		// Recurring notifications: none/daily/weekly/monthly from recurrence_anchor until recurrence_end
+		if err := addColumns(database, "notifications",
+			"recurrence TEXT DEFAULT 'none'",
+			"recurrence_anchor TEXT DEFAULT ''",
+			"recurrence_end TEXT DEFAULT ''",
+		); err != nil {
+			return err
+		}
+		// Segment criteria a targeted notification was resolved from (JSON, '' for explicit user lists)
+		if err := addColumns(database, "notifications", "target_criteria TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Create notification_targets table
+	{37, "create_notification_targets", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS notification_targets (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				notification_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				FOREIGN KEY (notification_id) REFERENCES notifications(id),
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				UNIQUE(notification_id, user_id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create notification_targets table: %w", err)
+		}
+		return nil
+	}},
+
+	// Per-user read state of notifications (broadcast and targeted alike)
+	{38, "create_notification_reads", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS notification_reads (
+				notification_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				read_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (notification_id, user_id),
+				FOREIGN KEY (notification_id) REFERENCES notifications(id),
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create notification_reads table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create email_wallets table for unified per-email balance
+	{39, "create_email_wallets", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS email_wallets (
+				email TEXT PRIMARY KEY,
+				credits_balance REAL DEFAULT 0,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create email_wallets table: %w", err)
+		}
+		return nil
+	}},
+
+	// Migrate existing per-user balances into email_wallets (one-time)
+	// Sum all users.credits_balance grouped by email into email_wallets
+	{40, "merge_user_balances_into_email_wallets", func(database *sql.DB) error {
+		database.Exec(`
+			INSERT OR IGNORE INTO email_wallets (email, credits_balance, updated_at)
+			SELECT email, SUM(credits_balance), CURRENT_TIMESTAMP
+			FROM users WHERE email IS NOT NULL AND email != '' GROUP BY email
+		`)
+		return nil
+	}},
+
+	// Add password_hash and username columns to email_wallets
+	{41, "add_email_wallets_credentials", func(database *sql.DB) error {
+		if err := addColumns(database, "email_wallets",
+			"password_hash TEXT",
+			"username TEXT",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add email verification columns to email_wallets.
+	// Existing wallets start unverified and are prompted on their next withdrawal or support application.
+	{42, "add_email_wallets_email_verified", func(database *sql.DB) error {
+		if err := addColumns(database, "email_wallets",
+			"email_verified INTEGER DEFAULT 0",
+			"email_verified_at DATETIME",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Migrate existing password_hash from users to email_wallets (one-time, pick the first non-null password per email)
+	{43, "copy_user_passwords_to_email_wallets", func(database *sql.DB) error {
+		database.Exec(`
+			UPDATE email_wallets SET password_hash = (
+				SELECT u.password_hash FROM users u
+				WHERE u.email = email_wallets.email AND u.password_hash IS NOT NULL AND u.password_hash != ''
+				ORDER BY u.id ASC LIMIT 1
+			), username = (
+				SELECT u.username FROM users u
+				WHERE u.email = email_wallets.email AND u.username IS NOT NULL AND u.username != ''
+				ORDER BY u.id ASC LIMIT 1
+			)
+			WHERE email_wallets.password_hash IS NULL OR email_wallets.password_hash = ''
+		`)
+		return nil
+	}},
+
+	// Create author_storefronts table
+	{44, "create_author_storefronts", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS author_storefronts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL UNIQUE,
+				store_name TEXT DEFAULT '',
+				store_slug TEXT NOT NULL UNIQUE,
+				description TEXT DEFAULT '',
+				logo_data BLOB,
+				logo_content_type TEXT DEFAULT '',
+				auto_add_enabled INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create author_storefronts table: %w", err)
+		}
+		database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_storefronts_slug ON author_storefronts(store_slug)")
+		return nil
+	}},
+
+	// Create storefront_packs table
+	{45, "create_storefront_packs", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_packs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				pack_listing_id INTEGER NOT NULL,
+				is_featured INTEGER DEFAULT 0,
+				featured_sort_order INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
+				FOREIGN KEY (pack_listing_id) REFERENCES pack_listings(id),
+				UNIQUE(storefront_id, pack_listing_id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_packs table: %w", err)
+		}
+		return nil
+	}},
+
+	// Add logo columns to storefront_packs
+	{46, "add_storefront_packs_logo", func(database *sql.DB) error {
+		if err := addColumns(database, "storefront_packs",
+			"logo_data BLOB",
+			"logo_content_type TEXT",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add store_layout column to author_storefronts
+	{47, "add_author_storefronts_store_layout", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts", "store_layout TEXT DEFAULT 'default'"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add public_id column to author_storefronts for non-enumerable URLs
+	{48, "add_author_storefronts_public_id", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts", "public_id TEXT"); err != nil {
+			return err
+		}
+		database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_storefronts_public_id ON author_storefronts(public_id)")
+		return nil
+	}},
+
+	// Add layout_config and theme columns to author_storefronts for storefront customization
+	{49, "add_author_storefronts_layout_theme", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts",
+			"layout_config TEXT",
+			"theme TEXT DEFAULT 'default'",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add logo_thumb_data column for the downscaled homepage card logo
+	{50, "add_author_storefronts_logo_thumb", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts", "logo_thumb_data BLOB"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add custom_theme column holding the JSON colors for theme='custom'
+	{51, "add_author_storefronts_custom_theme", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts", "custom_theme TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add color_scheme column ('light' or 'auto' to follow the visitor's dark mode preference)
+	{52, "add_author_storefronts_color_scheme", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts", "color_scheme TEXT DEFAULT 'light'"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add store_status column ('active' or 'paused') for the owner's closed mode
+	{53, "add_author_storefronts_store_status", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts", "store_status TEXT DEFAULT 'active'"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add store_announcement and announcement_active columns for the owner's one-click notice
+	{54, "add_author_storefronts_announcement", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts",
+			"store_announcement TEXT DEFAULT ''",
+			"announcement_active INTEGER DEFAULT 0",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add refund_policy, terms and contact columns for the store policies page
+	{55, "add_author_storefronts_policies", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts",
+			"refund_policy TEXT DEFAULT ''",
+			"terms TEXT DEFAULT ''",
+			"contact TEXT DEFAULT ''",
+		); err != nil {
+			return err
+		}
+		// Tax rate in percent charged on custom products, 0 = no tax
+		if err := addColumns(database, "author_storefronts", "tax_rate REAL DEFAULT 0"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Add admin-granted seller verification columns
+	{56, "add_author_storefronts_verification", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts",
+			"verified INTEGER DEFAULT 0",
+			"verified_at DATETIME",
+			"verified_by INTEGER DEFAULT 0",
+			"verified_note TEXT DEFAULT ''",
+		); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Create featured_storefronts table
+	{57, "create_featured_storefronts", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS featured_storefronts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL UNIQUE,
+				sort_order INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create featured_storefronts table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create storefront_banner_images table for custom_banner section images
+	{58, "create_storefront_banner_images", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_banner_images (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				image_data BLOB NOT NULL,
+				content_type TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_banner_images table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_banner_images_storefront ON storefront_banner_images(storefront_id)")
+		return nil
+	}},
+
+	// Create pack_images table for pack screenshot galleries
+	{59, "create_pack_images", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_images (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				listing_id INTEGER NOT NULL,
+				image_data BLOB NOT NULL,
+				content_type TEXT DEFAULT '',
+				sort_order INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_images table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_pack_images_listing ON pack_images(listing_id, sort_order)")
+		return nil
+	}},
+
+	// Create password_reset_tokens table (only the SHA-256 of each token is stored)
+	{60, "create_password_reset_tokens", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS password_reset_tokens (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				expires_at DATETIME NOT NULL,
+				used_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create password_reset_tokens table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_email ON password_reset_tokens(email, created_at)")
+		return nil
+	}},
+
+	// Create email_verification_tokens table (only the SHA-256 of each token is stored)
+	{61, "create_email_verification_tokens", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS email_verification_tokens (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				expires_at DATETIME NOT NULL,
+				used_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create email_verification_tokens table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_email ON email_verification_tokens(email, created_at)")
+		return nil
+	}},
+
+	// Create admin_audit_log table (security-relevant and administrative actions)
+	{62, "create_admin_audit_log", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS admin_audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				admin_id INTEGER DEFAULT 0,
+				action TEXT NOT NULL,
+				target TEXT DEFAULT '',
+				detail TEXT DEFAULT '',
+				ip_address TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create admin_audit_log table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created ON admin_audit_log(created_at)")
+		return nil
+	}},
+
+	// Create fulfillment_jobs table (retry queue for paid but unfulfilled custom product orders)
+	{63, "create_fulfillment_jobs", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS fulfillment_jobs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				order_id INTEGER NOT NULL UNIQUE,
+				status TEXT DEFAULT 'pending' CHECK(status IN ('pending', 'running', 'done', 'failed')),
+				attempts INTEGER DEFAULT 0,
+				last_error TEXT DEFAULT '',
+				next_attempt_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (order_id) REFERENCES custom_product_orders(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create fulfillment_jobs table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_fulfillment_jobs_due ON fulfillment_jobs(status, next_attempt_at)")
+		return nil
+	}},
+
+	// Create storefront_email_suppressions table (buyers who unsubscribed from a storefront's notifications)
+	{64, "create_storefront_email_suppressions", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_email_suppressions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				email TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
+				UNIQUE(storefront_id, email)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_email_suppressions table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create user_wishlist table (packs buyers saved for later)
+	{65, "create_user_wishlist", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS user_wishlist (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id),
+				UNIQUE(user_id, listing_id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create user_wishlist table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_user_wishlist_listing ON user_wishlist(listing_id)")
+		return nil
+	}},
+
+	// Create paypal_subscription_plans table (PayPal billing plans created for pack listings)
+	{66, "create_paypal_subscription_plans", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS paypal_subscription_plans (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				listing_id INTEGER NOT NULL,
+				billing_cycle TEXT NOT NULL,
+				currency TEXT NOT NULL,
+				amount TEXT NOT NULL,
+				paypal_product_id TEXT NOT NULL,
+				paypal_plan_id TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id),
+				UNIQUE(listing_id, billing_cycle, currency, amount)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create paypal_subscription_plans table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create pack_subscriptions table (auto-renewing PayPal subscriptions to packs)
+	{67, "create_pack_subscriptions", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_subscriptions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				billing_cycle TEXT NOT NULL,
+				paypal_subscription_id TEXT NOT NULL UNIQUE,
+				status TEXT NOT NULL DEFAULT 'pending',
+				paid_through TEXT DEFAULT '',
+				grace_until TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_subscriptions table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_pack_subscriptions_user ON pack_subscriptions(user_id, listing_id)")
+		return nil
+	}},
+
+	// Create pack_subscription_payments table (one row per billed cycle, so webhook retries are not double-counted)
+	{68, "create_pack_subscription_payments", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_subscription_payments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				subscription_id INTEGER NOT NULL,
+				cycle INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (subscription_id) REFERENCES pack_subscriptions(id),
+				UNIQUE(subscription_id, cycle)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_subscription_payments table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create storefront_followers table (buyers following a storefront for new-pack emails)
+	{69, "create_storefront_followers", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_followers (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				UNIQUE(storefront_id, user_id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_followers table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_storefront_followers_user ON storefront_followers(user_id)")
+		return nil
+	}},
+
+	// Create storefront_follow_notifications table (packs already announced to a storefront's followers)
+	{70, "create_storefront_follow_notifications", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_follow_notifications (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id),
+				UNIQUE(storefront_id, listing_id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_follow_notifications table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create storefront_slug_history table (previous slugs that redirect to the store's current slug)
+	{71, "create_storefront_slug_history", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_slug_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				slug TEXT NOT NULL UNIQUE,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_slug_history table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_storefront_slug_history_store ON storefront_slug_history(storefront_id)")
+		return nil
+	}},
+
+	// Create featured_products table (admin-picked products for the homepage)
+	{72, "create_featured_products", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS featured_products (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				listing_id INTEGER NOT NULL UNIQUE,
+				sort_order INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create featured_products table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create storefront_notifications table
+	{73, "create_storefront_notifications", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_notifications (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				subject TEXT NOT NULL,
+				body TEXT NOT NULL,
+				recipient_count INTEGER DEFAULT 0,
+				template_type TEXT DEFAULT '',
+				status TEXT DEFAULT 'sent',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_notifications table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create email_credits_usage table for tracking email sending credits billing
+	{74, "create_email_credits_usage", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS email_credits_usage (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				storefront_id INTEGER NOT NULL,
+				store_name TEXT DEFAULT '',
+				recipient_count INTEGER DEFAULT 0,
+				credits_used REAL DEFAULT 0,
+				notification_id INTEGER DEFAULT 0,
+				description TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create email_credits_usage table: %w", err)
+		}
+		return nil
+	}},
+
+	// Add custom_products_enabled column to author_storefronts
+	{75, "add_author_storefronts_custom_products_enabled", func(database *sql.DB) error {
+		if err := addColumns(database, "author_storefronts", "custom_products_enabled INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Create custom_products table
+	{76, "create_custom_products", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS custom_products (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				product_name TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				product_type TEXT NOT NULL CHECK(product_type IN ('credits', 'virtual_goods')),
+				price_usd REAL NOT NULL,
+				credits_amount INTEGER DEFAULT 0,
+				license_api_endpoint TEXT DEFAULT '',
+				license_api_key TEXT DEFAULT '',
+				license_product_id TEXT DEFAULT '',
+				status TEXT DEFAULT 'draft' CHECK(status IN ('draft', 'pending', 'published', 'rejected')),
+				reject_reason TEXT DEFAULT '',
+				sort_order INTEGER DEFAULT 0,
+				deleted_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
+				UNIQUE(storefront_id, product_name)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create custom_products table: %w", err)
+		}
+		return nil
+	}},
+
+	// Add flash sale columns to custom_products
+	{77, "add_custom_products_flash_sale", func(database *sql.DB) error {
+		if err := addColumns(database, "custom_products",
+			"sale_price REAL",
+			"sale_start DATETIME",
+			"sale_end DATETIME",
+		); err != nil {
+			return err
+		}
+		// Units left of a virtual goods product, NULL = unlimited
+		if err := addColumns(database, "custom_products", "stock_quantity INTEGER"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Create custom_product_orders table
+	{78, "create_custom_product_orders", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS custom_product_orders (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				custom_product_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				paypal_order_id TEXT DEFAULT '',
+				paypal_payment_status TEXT DEFAULT '',
+				amount_usd REAL NOT NULL,
+				license_sn TEXT DEFAULT '',
+				license_email TEXT DEFAULT '',
+				status TEXT DEFAULT 'pending' CHECK(status IN ('pending', 'paid', 'fulfilled', 'failed')),
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (custom_product_id) REFERENCES custom_products(id),
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create custom_product_orders table: %w", err)
+		}
+		return nil
+	}},
+
+	// Add charged amount/currency to custom_product_orders
+	{79, "add_custom_product_orders_charged", func(database *sql.DB) error {
+		if err := addColumns(database, "custom_product_orders",
+			"charged_amount REAL",
+			"charged_currency TEXT DEFAULT 'USD'",
+		); err != nil {
+			return err
+		}
+		database.Exec("UPDATE custom_product_orders SET charged_amount = amount_usd WHERE charged_amount IS NULL")
+		// Gift orders: the payer owns the order, recipient_email receives the credits or license
+		if err := addColumns(database, "custom_product_orders", "recipient_email TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Idempotency-Key of the purchase request and the approve URL returned for it
+	{80, "add_custom_product_orders_idempotency", func(database *sql.DB) error {
+		if err := addColumns(database, "custom_product_orders",
+			"idempotency_key TEXT",
+			"approve_url TEXT DEFAULT ''",
+		); err != nil {
+			return err
+		}
+		database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_product_orders_idempotency ON custom_product_orders(user_id, idempotency_key) WHERE idempotency_key IS NOT NULL")
+		return nil
+	}},
+
+	// Why an order failed without a PayPal capture attempt
+	{81, "add_custom_product_orders_failure_reason", func(database *sql.DB) error {
+		if err := addColumns(database, "custom_product_orders", "failure_reason TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		// When the PayPal capture succeeded, for checkout funnel timing
+		if err := addColumns(database, "custom_product_orders", "paid_at DATETIME"); err != nil {
+			return err
+		}
+		// Tax charged on top of the item price, included in charged_amount
+		if err := addColumns(database, "custom_product_orders", "tax_amount REAL DEFAULT 0"); err != nil {
+			return err
+		}
+		// Top-up tier snapshot of credits orders; 0 credits_amount means the product's own amount
+		if err := addColumns(database, "custom_product_orders",
+			"credits_amount INTEGER DEFAULT 0",
+			"bonus_credits INTEGER DEFAULT 0",
+		); err != nil {
+			return err
+		}
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS credits_topup_tiers (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				price_usd REAL NOT NULL,
+				credits INTEGER NOT NULL,
+				bonus_credits INTEGER NOT NULL DEFAULT 0
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create credits_topup_tiers table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create storefront_support_requests table
+	{82, "create_storefront_support_requests", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_support_requests (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				software_name TEXT NOT NULL DEFAULT 'vantagics',
+				store_name TEXT NOT NULL DEFAULT '',
+				welcome_message TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL DEFAULT 'pending',
+				reviewed_by INTEGER,
+				reviewed_at DATETIME,
+				disable_reason TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id),
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (reviewed_by) REFERENCES admin_credentials(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_support_requests table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_support_requests_storefront ON storefront_support_requests(storefront_id)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_support_requests_status ON storefront_support_requests(status)")
+		return nil
+	}},
+
+	// Create storefront_views and pack_views tables (daily page view counters for author analytics)
+	{83, "create_view_counters", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_views (
+				storefront_id INTEGER NOT NULL,
+				day TEXT NOT NULL,
+				views INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (storefront_id, day),
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_views table: %w", err)
+		}
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_views (
+				listing_id INTEGER NOT NULL,
+				day TEXT NOT NULL,
+				views INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (listing_id, day),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_views table: %w", err)
+		}
+		return nil
+	}},
+
+	// Create price_change_history table (audit trail of author price changes)
+	{84, "create_price_change_history", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS price_change_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				item_type TEXT NOT NULL CHECK(item_type IN ('pack', 'custom_product')),
+				item_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				old_price REAL NOT NULL,
+				new_price REAL NOT NULL,
+				source TEXT NOT NULL DEFAULT 'bulk',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create price_change_history table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_price_change_history_item ON price_change_history(item_type, item_id)")
+		return nil
+	}},
+
+	// Shopping cart: packs a user intends to buy in a single checkout
+	{85, "create_cart_items", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS cart_items (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				quantity INTEGER NOT NULL DEFAULT 0,
+				months INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(user_id, listing_id),
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create cart_items table: %w", err)
+		}
+		return nil
+	}},
+
+	// Pack bundles: several of an author's packs sold together at one price
+	{86, "create_pack_bundles", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_bundles (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				credits_price INTEGER NOT NULL,
+				status TEXT NOT NULL DEFAULT 'active' CHECK(status IN ('active', 'archived')),
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_bundles table: %w", err)
+		}
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_bundle_items (
+				bundle_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				sort_order INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (bundle_id, listing_id),
+				FOREIGN KEY (bundle_id) REFERENCES pack_bundles(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_bundle_items table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_pack_bundle_items_listing ON pack_bundle_items(listing_id)")
+		return nil
+	}},
+
+	// Referral program: one code per user, one referral (and reward) per referred user
+	{87, "create_referrals", func(database *sql.DB) error {
+		if err := addColumns(database, "users", "referral_code TEXT"); err != nil {
+			return err
+		}
+		database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_referral_code ON users(referral_code)")
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS referrals (
+				referred_user_id INTEGER PRIMARY KEY,
+				referrer_id INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				rewarded_at DATETIME,
+				referrer_reward REAL NOT NULL DEFAULT 0,
+				referred_reward REAL NOT NULL DEFAULT 0,
+				FOREIGN KEY (referred_user_id) REFERENCES users(id),
+				FOREIGN KEY (referrer_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create referrals table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_referrals_referrer ON referrals(referrer_id)")
+		return nil
+	}},
+
+	// Recently viewed packs per user, capped at recentlyViewedCap rows
+	{88, "create_user_recently_viewed", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS user_recently_viewed (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				viewed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create user_recently_viewed table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_user_recently_viewed_user ON user_recently_viewed(user_id, listing_id)")
+		return nil
+	}},
+
+	// KYC identity verification: status on users, submissions with encrypted documents
+	{89, "create_kyc", func(database *sql.DB) error {
+		if err := addColumns(database, "users", "kyc_status TEXT DEFAULT 'none'"); err != nil {
+			return err
+		}
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS kyc_submissions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				full_name TEXT NOT NULL,
+				document_type TEXT NOT NULL,
+				document_name TEXT DEFAULT '',
+				content_type TEXT NOT NULL,
+				document_data TEXT NOT NULL,
+				reviewed_by INTEGER,
+				reviewed_at DATETIME,
+				reject_reason TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create kyc_submissions table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_kyc_submissions_status ON kyc_submissions(status, id)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_kyc_submissions_user ON kyc_submissions(user_id)")
+		return nil
+	}},
+
+	// User data exports, kept to rate-limit them
+	{90, "create_user_data_exports", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS user_data_exports (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				ip_address TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create user_data_exports table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_user_data_exports_user ON user_data_exports(user_id, created_at)")
+		return nil
+	}},
+
+	// Set when the user deletes their account; the row stays, anonymized
+	{91, "add_users_deleted_at", func(database *sql.DB) error {
+		if err := addColumns(database, "users", "deleted_at DATETIME"); err != nil {
+			return err
+		}
+		return nil
+	}},
+
+	// Additional emails linked to an account, pooling their wallets. Rows stay
+	// pending (verified_at NULL) until the emailed link is followed.
+	{92, "create_account_emails", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS account_emails (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				email TEXT NOT NULL,
+				is_primary INTEGER NOT NULL DEFAULT 0,
+				verified_at DATETIME,
+				token_hash TEXT,
+				token_expires_at DATETIME,
+				token_sent_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				UNIQUE(user_id, email)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create account_emails table: %w", err)
+		}
+		database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_account_emails_verified ON account_emails(email) WHERE verified_at IS NOT NULL")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_account_emails_token ON account_emails(token_hash)")
+		return nil
+	}},
+
+	// Pending sign-in email changes (only the SHA-256 of each token is stored)
+	{93, "create_email_change_requests", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS email_change_requests (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				old_email TEXT NOT NULL,
+				new_email TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				expires_at DATETIME NOT NULL,
+				used_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create email_change_requests table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_email_change_requests_user ON email_change_requests(user_id, created_at)")
+		return nil
+	}},
+
+	// Storefront ownership changes; carried_revenue is the gross sales the
+	// previous owner keeps credit for after their listings move.
+	{94, "create_storefront_transfers", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS storefront_transfers (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				storefront_id INTEGER NOT NULL,
+				from_user_id INTEGER NOT NULL,
+				to_user_id INTEGER NOT NULL,
+				carried_revenue REAL NOT NULL DEFAULT 0,
+				admin_id INTEGER NOT NULL DEFAULT 0,
+				note TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (storefront_id) REFERENCES author_storefronts(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create storefront_transfers table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_storefront_transfers_from ON storefront_transfers(from_user_id)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_storefront_transfers_to ON storefront_transfers(to_user_id)")
+		return nil
+	}},
+
+	{95, "create_name_search_indexes", func(database *sql.DB) error {
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_author_storefronts_store_name ON author_storefronts(store_name COLLATE NOCASE)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_pack_listings_pack_name ON pack_listings(pack_name COLLATE NOCASE)")
+		return nil
+	}},
+
+	// Indexes for the admin user list (GET /api/admin/users): the email_wallets
+	// join, the blocked filter and the sortable columns
+	{96, "create_admin_user_list_indexes", func(database *sql.DB) error {
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_users_blocked ON users(is_blocked)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_users_display_name ON users(display_name)")
+		return nil
+	}},
+
+	// Create downloads_tokens table (single-use download links, hashed)
+	{97, "create_downloads_tokens", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS downloads_tokens (
+				token_hash TEXT PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				expires_at DATETIME NOT NULL,
+				used_at DATETIME,
+				used_ip TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (listing_id) REFERENCES pack_listings(id)
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create downloads_tokens table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_downloads_tokens_expires ON downloads_tokens(expires_at)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_downloads_tokens_listing ON downloads_tokens(listing_id)")
+		return nil
+	}},
+
+	// Create content_flags table (user text held for admin review by the content filter)
+	{98, "create_content_flags", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS content_flags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				item_type TEXT NOT NULL,
+				item_id INTEGER NOT NULL,
+				reason TEXT NOT NULL DEFAULT '',
+				excerpt TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL DEFAULT 'open',
+				resolved_by INTEGER,
+				resolved_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create content_flags table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_content_flags_item ON content_flags(item_type, item_id, status)")
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_content_flags_status ON content_flags(status, id)")
+		return nil
+	}},
+
+	// Create pack_watermarks table (which buyer and order each download watermark was issued for)
+	{99, "create_pack_watermarks", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS pack_watermarks (
+				watermark TEXT PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				listing_id INTEGER NOT NULL,
+				email_hash TEXT NOT NULL DEFAULT '',
+				order_id INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create pack_watermarks table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_pack_watermarks_user ON pack_watermarks(user_id)")
+		return nil
+	}},
+
+	// Create api_keys table (hashed API keys for programmatic access)
+	{100, "create_api_keys", func(database *sql.DB) error {
+		if _, err := database.Exec(`
+			CREATE TABLE IF NOT EXISTS api_keys (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				key_hash TEXT NOT NULL UNIQUE,
+				key_prefix TEXT NOT NULL DEFAULT '',
+				name TEXT NOT NULL DEFAULT '',
+				owner_type TEXT NOT NULL,
+				owner_id INTEGER NOT NULL,
+				scopes TEXT NOT NULL DEFAULT '',
+				last_used_at DATETIME,
+				expires_at DATETIME,
+				revoked_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to create api_keys table: %w", err)
+		}
+		database.Exec("CREATE INDEX IF NOT EXISTS idx_api_keys_owner ON api_keys(owner_type, owner_id)")
+		return nil
+	}},
+
+	// Mark credits transactions paid outside the wallet (PayPal auto-renewals),
+	// which must not draw down expiring credit grants
+	{101, "add_credits_transactions_paid_externally", func(database *sql.DB) error {
+		if err := addColumns(database, "credits_transactions", "paid_externally INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		if _, err := database.Exec(`UPDATE credits_transactions SET paid_externally = 1
+			WHERE transaction_type = 'renew' AND description LIKE '%(PayPal auto-renewal)'`); err != nil {
+			return fmt.Errorf("failed to mark PayPal renewals: %w", err)
+		}
+		return nil
+	}},
+}
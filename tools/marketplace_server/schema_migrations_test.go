@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// schemaSnapshot returns the definitions of every table and index.
+func schemaSnapshot(t *testing.T, database *sql.DB) string {
+	t.Helper()
+	rows, err := database.Query("SELECT type, name, COALESCE(sql, '') FROM sqlite_master ORDER BY type, name")
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	defer rows.Close()
+	var b strings.Builder
+	for rows.Next() {
+		var typ, name, def string
+		if err := rows.Scan(&typ, &name, &def); err != nil {
+			t.Fatalf("read schema: %v", err)
+		}
+		b.WriteString(typ + " " + name + ": " + def + "\n")
+	}
+	return b.String()
+}
+
+func TestMigrationVersionsIncrease(t *testing.T) {
+	names := make(map[string]bool)
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.name, m.version, i+1)
+		}
+		if names[m.name] {
+			t.Errorf("duplicate migration name %q", m.name)
+		}
+		names[m.name] = true
+	}
+}
+
+func TestMigrationsRunTwice(t *testing.T) {
+	useTestDB(t)
+
+	countApplied := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n); err != nil {
+			t.Fatalf("count schema_migrations: %v", err)
+		}
+		return n
+	}
+	if n := countApplied(); n != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", n, len(migrations))
+	}
+	before := schemaSnapshot(t, db)
+
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if after := schemaSnapshot(t, db); after != before {
+		t.Errorf("schema changed on second run:\n%s\nwant:\n%s", after, before)
+	}
+	if n := countApplied(); n != len(migrations) {
+		t.Errorf("applied %d migrations after second run, want %d", n, len(migrations))
+	}
+
+	// A database from before schema_migrations existed runs every step again.
+	if _, err := db.Exec("DELETE FROM schema_migrations"); err != nil {
+		t.Fatalf("clear schema_migrations: %v", err)
+	}
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("rerun on migrated schema: %v", err)
+	}
+	if after := schemaSnapshot(t, db); after != before {
+		t.Errorf("schema changed when rerun on migrated schema:\n%s\nwant:\n%s", after, before)
+	}
+	if n := countApplied(); n != len(migrations) {
+		t.Errorf("applied %d migrations after rerun, want %d", n, len(migrations))
+	}
+}
+
+func TestMigrationsUpgradeLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marketplace.db")
+	legacy, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	legacyExec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := legacy.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	// An old users table and a pack_listings table missing later columns.
+	legacyExec(`CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		oauth_provider TEXT NOT NULL,
+		oauth_provider_id TEXT NOT NULL,
+		display_name TEXT NOT NULL,
+		email TEXT,
+		credits_balance REAL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	legacyExec("INSERT INTO users (id, oauth_provider, oauth_provider_id, display_name, email) VALUES (1, 'sn', 'alice', 'Alice', 'alice@example.com')")
+	legacyExec(`CREATE TABLE pack_listings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		category_id INTEGER NOT NULL,
+		file_data BLOB,
+		pack_name TEXT NOT NULL,
+		pack_description TEXT,
+		source_name TEXT,
+		author_name TEXT,
+		share_mode TEXT NOT NULL,
+		credits_price INTEGER DEFAULT 0,
+		status TEXT DEFAULT 'pending',
+		download_count INTEGER DEFAULT 0,
+		version INTEGER DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	legacy.Close()
+
+	database, err := initDB(path)
+	if err != nil {
+		t.Fatalf("initDB: %v", err)
+	}
+	defer database.Close()
+
+	var authType, authID string
+	if err := database.QueryRow("SELECT auth_type, auth_id FROM users WHERE id = 1").Scan(&authType, &authID); err != nil {
+		t.Fatalf("read migrated user: %v", err)
+	}
+	if authType != "sn" || authID != "alice" {
+		t.Errorf("migrated user = %q/%q, want sn/alice", authType, authID)
+	}
+	var n int
+	if err := database.QueryRow("SELECT COUNT(*) FROM pragma_table_info('pack_listings') WHERE name IN ('share_token', 'file_sha256', 'sale_price')").Scan(&n); err != nil {
+		t.Fatalf("read pack_listings columns: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("pack_listings has %d of the added columns, want 3", n)
+	}
+	if err := database.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n); err != nil {
+		t.Fatalf("count schema_migrations: %v", err)
+	}
+	if n != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", n, len(migrations))
+	}
+}
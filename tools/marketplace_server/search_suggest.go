@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// minSuggestQueryLength is the shortest query, in characters, that
+	// produces suggestions; shorter input matches too much to be useful.
+	minSuggestQueryLength = 2
+	// maxSuggestionsPerType caps the packs and the stores suggested.
+	maxSuggestionsPerType = 5
+)
+
+// PackSuggestion is a published pack offered while the user types.
+type PackSuggestion struct {
+	ListingID  int64  `json:"listing_id"`
+	PackName   string `json:"pack_name"`
+	ShareToken string `json:"share_token"`
+}
+
+// StoreSuggestion is a storefront offered while the user types.
+type StoreSuggestion struct {
+	StorefrontID int64  `json:"storefront_id"`
+	PublicID     string `json:"public_id"`
+	StoreName    string `json:"store_name"`
+	StoreSlug    string `json:"store_slug"`
+}
+
+// SearchSuggestions is the response of the suggest endpoint.
+type SearchSuggestions struct {
+	OK       bool              `json:"ok"`
+	Query    string            `json:"query"`
+	Products []PackSuggestion  `json:"products"`
+	Stores   []StoreSuggestion `json:"stores"`
+}
+
+// querySearchSuggestions 按名称查找已发布的分析包和正常营业的小铺，名称前缀匹配优先。
+// 所属小铺已暂停/删除的分析包不会出现在建议中。
+func querySearchSuggestions(q string) (*SearchSuggestions, error) {
+	escaped := strings.NewReplacer("%", "\\%", "_", "\\_").Replace(q)
+	contains := "%" + escaped + "%"
+	prefix := escaped + "%"
+	result := &SearchSuggestions{OK: true, Query: q, Products: []PackSuggestion{}, Stores: []StoreSuggestion{}}
+
+	rows, err := db.Query(`SELECT pl.id, pl.pack_name, COALESCE(pl.share_token, '')
+		FROM pack_listings pl
+		LEFT JOIN author_storefronts s ON s.user_id = pl.user_id
+		WHERE pl.status = 'published' AND pl.pack_name LIKE ? ESCAPE '\'
+			AND COALESCE(s.store_status, 'active') NOT IN ('paused', 'deleted')
+		ORDER BY CASE WHEN pl.pack_name LIKE ? ESCAPE '\' THEN 0 ELSE 1 END, pl.download_count DESC, pl.id ASC
+		LIMIT ?`, contains, prefix, maxSuggestionsPerType)
+	if err != nil {
+		return nil, fmt.Errorf("querySearchSuggestions packs: %w", err)
+	}
+	for rows.Next() {
+		var p PackSuggestion
+		if err := rows.Scan(&p.ListingID, &p.PackName, &p.ShareToken); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("querySearchSuggestions packs scan: %w", err)
+		}
+		result.Products = append(result.Products, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querySearchSuggestions packs rows: %w", err)
+	}
+
+	// Stores need at least one published pack, like the homepage lists.
+	rows, err = db.Query(`SELECT s.id, COALESCE(s.public_id, ''), s.store_name, s.store_slug
+		FROM author_storefronts s
+		WHERE COALESCE(s.store_status, 'active') NOT IN ('paused', 'deleted')
+			AND s.store_name LIKE ? ESCAPE '\'
+			AND EXISTS (SELECT 1 FROM pack_listings pl WHERE pl.user_id = s.user_id AND pl.status = 'published')
+		ORDER BY CASE WHEN s.store_name LIKE ? ESCAPE '\' THEN 0 ELSE 1 END, COALESCE(s.verified, 0) DESC, s.id ASC
+		LIMIT ?`, contains, prefix, maxSuggestionsPerType)
+	if err != nil {
+		return nil, fmt.Errorf("querySearchSuggestions stores: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s StoreSuggestion
+		if err := rows.Scan(&s.StorefrontID, &s.PublicID, &s.StoreName, &s.StoreSlug); err != nil {
+			return nil, fmt.Errorf("querySearchSuggestions stores scan: %w", err)
+		}
+		result.Stores = append(result.Stores, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querySearchSuggestions stores rows: %w", err)
+	}
+	return result, nil
+}
+
+// handleSearchSuggest handles GET /api/search/suggest?q=..., returning pack
+// and store names matching what the user has typed so far. Queries shorter
+// than minSuggestQueryLength get empty lists without touching the database,
+// and identical queries arriving together or within the feed cache TTL share
+// one lookup.
+func handleSearchSuggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+		return
+	}
+	q := normalizeStoreSearchQuery(r.URL.Query().Get("q"))
+	if n := utf8.RuneCountInString(q); n < minSuggestQueryLength || n > maxStoreSearchQueryLength {
+		jsonResponse(w, http.StatusOK, SearchSuggestions{OK: true, Query: q, Products: []PackSuggestion{}, Stores: []StoreSuggestion{}})
+		return
+	}
+
+	key := "suggest:" + q
+	body, hit := globalCache.GetFeed(key)
+	if !hit {
+		var err error
+		body, err = globalCache.DoFeedQuery(key, func() ([]byte, error) {
+			result, err := querySearchSuggestions(q)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(result)
+		})
+		if err != nil {
+			log.Printf("[SEARCH-SUGGEST] failed to suggest %q: %v", q, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "搜索失败"})
+			return
+		}
+		globalCache.SetFeed(key, body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
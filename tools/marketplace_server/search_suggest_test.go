@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSearchSuggest(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{FeedTTL: time.Hour, MaxEntries: 100})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'a', 'A'), (2, 'sn', 'b', 'B')")
+	mustExec(t, `INSERT INTO author_storefronts (id, user_id, store_name, store_slug, store_status) VALUES
+		(10, 1, 'Sales Studio', 'studio', 'active'),
+		(11, 2, 'Sales Hidden', 'hidden', 'paused')`)
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, share_token) VALUES
+		(1, 1, 1, x'00', 'Monthly Sales', 'free', 'published', 't1'),
+		(2, 1, 1, x'00', 'Sales Forecast', 'free', 'published', 't2'),
+		(3, 1, 1, x'00', 'Sales Draft', 'free', 'pending', 't3'),
+		(4, 2, 1, x'00', 'Sales Paused', 'free', 'published', 't4')`)
+
+	suggest := func(q string) SearchSuggestions {
+		req := httptest.NewRequest(http.MethodGet, "/api/search/suggest?q="+q, nil)
+		rec := httptest.NewRecorder()
+		handleSearchSuggest(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("q=%s: status %d", q, rec.Code)
+		}
+		var result SearchSuggestions
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		return result
+	}
+
+	result := suggest("sales")
+	if len(result.Products) != 2 || result.Products[0].ListingID != 2 || result.Products[1].ListingID != 1 {
+		t.Errorf("products %+v, want prefix match 2 then 1", result.Products)
+	}
+	if len(result.Stores) != 1 || result.Stores[0].StorefrontID != 10 {
+		t.Errorf("stores %+v, want only the active store", result.Stores)
+	}
+
+	if result := suggest("s"); len(result.Products) != 0 || len(result.Stores) != 0 {
+		t.Errorf("one-character query returned %+v", result)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeadersHTMLOnly(t *testing.T) {
+	useTestDB(t)
+	refreshCSPHeader()
+
+	serve := func(h http.HandlerFunc) http.Header {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		securityHeaders(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Header()
+	}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	html := serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<p>hi</p>"))
+	})
+	if !strings.Contains(html.Get("Content-Security-Policy"), "frame-ancestors 'none'") || html.Get("X-Frame-Options") != "DENY" {
+		t.Errorf("HTML page headers: CSP %q, X-Frame-Options %q", html.Get("Content-Security-Policy"), html.Get("X-Frame-Options"))
+	}
+
+	for name, h := range map[string]http.HandlerFunc{
+		"json": func(w http.ResponseWriter, r *http.Request) {
+			jsonResponse(w, http.StatusOK, map[string]string{"ok": "1"})
+		},
+		"image": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+		},
+	} {
+		got := serve(h)
+		if got.Get("Content-Security-Policy") != "" || got.Get("X-Frame-Options") != "" {
+			t.Errorf("%s response got HTML-only headers: %v", name, got)
+		}
+		if got.Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s response missing nosniff", name)
+		}
+	}
+
+	// Without a Content-Type the type is sniffed before nosniff applies, so
+	// an image is still served as an image and gets no CSP.
+	sniffed := serve(func(w http.ResponseWriter, r *http.Request) { w.Write(png) })
+	if sniffed.Get("Content-Type") != "image/png" || sniffed.Get("Content-Security-Policy") != "" {
+		t.Errorf("unlabelled image: Content-Type %q, CSP %q", sniffed.Get("Content-Type"), sniffed.Get("Content-Security-Policy"))
+	}
+	sniffedHTML := serve(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<!DOCTYPE html><p>hi</p>")) })
+	if sniffedHTML.Get("Content-Security-Policy") == "" {
+		t.Error("unlabelled HTML page got no CSP")
+	}
+}
+
+func TestCSPHeaderFollowsSavedSettings(t *testing.T) {
+	useTestDB(t)
+	refreshCSPHeader()
+	t.Cleanup(func() {
+		cspHeader.mu.Lock()
+		cspHeader.loaded = false
+		cspHeader.mu.Unlock()
+	})
+
+	page := func() http.Header {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		securityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Header()
+	}
+	post := func(h http.HandlerFunc, form url.Values) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("save: status %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// Serving pages reads the cached header, not the settings table.
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('csp_mode', 'off')")
+	if page().Get("Content-Security-Policy") == "" {
+		t.Error("CSP changed without a save through the admin handler")
+	}
+
+	post(handleAdminCSPSettings, url.Values{"mode": {cspModeReportOnly}, "report_uri": {"/csp-report"}})
+	h := page()
+	if h.Get("Content-Security-Policy") != "" || !strings.HasSuffix(h.Get("Content-Security-Policy-Report-Only"), "; report-uri /csp-report") {
+		t.Errorf("report-only mode: %v", h)
+	}
+
+	post(handleSaveServicePortalURL, url.Values{"value": {"https://support.example.com/portal"}})
+	if !strings.Contains(page().Get("Content-Security-Policy-Report-Only"), "frame-src 'self' https://support.example.com") {
+		t.Errorf("service portal not allowed as frame-src: %v", page())
+	}
+
+	post(handleAdminCSPSettings, url.Values{"mode": {cspModeOff}})
+	h = page()
+	if h.Get("Content-Security-Policy") != "" || h.Get("Content-Security-Policy-Report-Only") != "" || h.Get("X-Frame-Options") != "DENY" {
+		t.Errorf("off mode: %v", h)
+	}
+}
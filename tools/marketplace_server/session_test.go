@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestRotateSessionInvalidatesPreLoginID(t *testing.T) {
+	preLogin := createSession(42)
+	other := createSession(42)
+
+	sid := rotateSession(preLogin, 42, false)
+	if sid == preLogin {
+		t.Fatal("rotateSession reused the pre-login session ID")
+	}
+	if isValidSession(preLogin) {
+		t.Error("pre-login session still valid after login")
+	}
+	if !isValidSession(sid) {
+		t.Error("new session not valid")
+	}
+	if !isValidSession(other) {
+		t.Error("unrelated session revoked without revokeAll")
+	}
+
+	// Password change ends every other session of the admin.
+	rotated := rotateSession(sid, 42, true)
+	if isValidSession(sid) || isValidSession(other) {
+		t.Error("old sessions still valid after password change")
+	}
+	if !isValidSession(rotated) {
+		t.Error("rotated session not valid")
+	}
+}
+
+func TestRotateUserSessionInvalidatesPreLoginID(t *testing.T) {
+	preLogin := createUserSession(7)
+	bystander := createUserSession(8)
+
+	sid := rotateUserSession(preLogin, 7, true)
+	if sid == preLogin || isValidUserSession(preLogin) {
+		t.Error("pre-login user session still valid after login")
+	}
+	if getUserSessionUserID(sid) != 7 {
+		t.Error("new user session not bound to the user")
+	}
+	if !isValidUserSession(bystander) {
+		t.Error("another user's session was revoked")
+	}
+}
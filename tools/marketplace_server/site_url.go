@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Links that carry a secret (password reset, email verification and change,
+// linked emails) or that a payment provider sends the buyer back to are built
+// from the public_base_url setting, never from the request: the Host header is
+// chosen by the client, so a forged one would mail a live token to, or
+// redirect a payment through, another domain. Until the setting is saved those
+// flows refuse to run.
+
+// siteBaseURL returns the configured scheme://host[/path] of the site without
+// a trailing slash, or "" and false when public_base_url is not set.
+func siteBaseURL() (string, bool) {
+	base := strings.TrimSuffix(strings.TrimSpace(getSetting("public_base_url")), "/")
+	if base == "" {
+		log.Printf("[SITE-URL] public_base_url is not configured; refusing to build an absolute link")
+		return "", false
+	}
+	return base, true
+}
+
+// normalizeSiteBaseURL validates an admin-entered base URL and returns it
+// without a trailing slash.
+func normalizeSiteBaseURL(value string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", false
+	}
+	return strings.TrimSuffix(u.String(), "/"), true
+}
+
+// handleAdminSiteURLSettings handles GET/POST /admin/settings/site-url.
+func handleAdminSiteURLSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]string{"public_base_url": getSetting("public_base_url")})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	base, ok := normalizeSiteBaseURL(r.FormValue("public_base_url"))
+	if !ok {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "站点地址必须是 http:// 或 https:// 开头的完整地址，且不含查询参数"})
+		return
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('public_base_url', ?)", base); err != nil {
+		log.Printf("[ADMIN] failed to save public_base_url: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeSiteBaseURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://market.example.com/":    "https://market.example.com",
+		" http://localhost:8088 ":        "http://localhost:8088",
+		"https://example.com/market":     "https://example.com/market",
+		"market.example.com":             "",
+		"javascript:alert(1)":            "",
+		"https://example.com/?next=evil": "",
+		"https://user@example.com":       "",
+	} {
+		got, ok := normalizeSiteBaseURL(in)
+		if ok != (want != "") || got != want {
+			t.Errorf("normalizeSiteBaseURL(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+}
+
+func TestEmailVerifySendNeedsSiteURL(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Alice', 'alice@example.com')")
+
+	// Without a configured site URL no token is issued, whatever the Host.
+	req := httptest.NewRequest(http.MethodPost, "/user/email/verify/send", nil)
+	req.Host = "attacker.example"
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleUserEmailVerifySend(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500", rec.Code)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM email_verification_tokens").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d tokens stored without a site URL", n)
+	}
+
+	mustExec(t, "INSERT INTO settings (key, value) VALUES ('public_base_url', 'https://market.example.com/')")
+	if base, ok := siteBaseURL(); !ok || base != "https://market.example.com" {
+		t.Errorf("siteBaseURL() = %q, %v", base, ok)
+	}
+}
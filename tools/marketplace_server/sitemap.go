@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sitemapMaxURLs is the sitemaps.org limit of URLs per sitemap file. Above it
+// /sitemap.xml becomes a sitemap index pointing at /sitemap.xml?page=N.
+var sitemapMaxURLs = 50000
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+var errSitemapPageNotFound = errors.New("sitemap page not found")
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// sitemapDate converts a database timestamp to a W3C date for <lastmod>.
+func sitemapDate(s string) string {
+	if t, ok := parseTxTime(s); ok {
+		return t.UTC().Format("2006-01-02")
+	}
+	return ""
+}
+
+// querySitemapURLs lists every public page: the homepage, each storefront
+// showing at least one published pack, and each published pack.
+func querySitemapURLs(baseURL string) ([]sitemapURL, error) {
+	urls := []sitemapURL{{Loc: baseURL + "/"}}
+
+	rows, err := db.Query(`SELECT sf.id, COALESCE(sf.public_id, ''), COALESCE(sf.updated_at, sf.created_at, '')
+		FROM author_storefronts sf
+		WHERE EXISTS (
+			SELECT 1 FROM pack_listings pl
+			WHERE pl.user_id = sf.user_id AND pl.status = 'published'
+			  AND (sf.auto_add_enabled = 1 OR EXISTS (
+				SELECT 1 FROM storefront_packs sp WHERE sp.storefront_id = sf.id AND sp.pack_listing_id = pl.id)))
+		ORDER BY sf.id`)
+	if err != nil {
+		return nil, fmt.Errorf("querySitemapURLs storefronts: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		var publicID, updatedAt string
+		if err := rows.Scan(&id, &publicID, &updatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("querySitemapURLs storefronts scan: %w", err)
+		}
+		if publicID == "" {
+			publicID = strconv.FormatInt(id, 10)
+		}
+		urls = append(urls, sitemapURL{Loc: fmt.Sprintf("%s/store/%s", baseURL, publicID), LastMod: sitemapDate(updatedAt)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querySitemapURLs storefronts rows: %w", err)
+	}
+
+	rows, err = db.Query(`SELECT share_token, COALESCE(reviewed_at, created_at, '')
+		FROM pack_listings
+		WHERE status = 'published' AND share_token IS NOT NULL AND share_token != ''
+		ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("querySitemapURLs packs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var shareToken, updatedAt string
+		if err := rows.Scan(&shareToken, &updatedAt); err != nil {
+			return nil, fmt.Errorf("querySitemapURLs packs scan: %w", err)
+		}
+		urls = append(urls, sitemapURL{Loc: fmt.Sprintf("%s/pack/%s", baseURL, shareToken), LastMod: sitemapDate(updatedAt)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querySitemapURLs packs rows: %w", err)
+	}
+	return urls, nil
+}
+
+// buildSitemap renders page (0 for /sitemap.xml itself) of the sitemap. When
+// all URLs fit in one file page 0 is the urlset; otherwise it is an index of
+// pages 1..N. errSitemapPageNotFound is returned for out-of-range pages.
+func buildSitemap(baseURL string, page int) ([]byte, error) {
+	urls, err := querySitemapURLs(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	pages := (len(urls) + sitemapMaxURLs - 1) / sitemapMaxURLs
+	switch {
+	case page == 0 && pages <= 1:
+		doc = sitemapURLSet{XMLNS: sitemapNS, URLs: urls}
+	case page == 0:
+		today := time.Now().UTC().Format("2006-01-02")
+		index := sitemapIndex{XMLNS: sitemapNS}
+		for i := 1; i <= pages; i++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: fmt.Sprintf("%s/sitemap.xml?page=%d", baseURL, i), LastMod: today})
+		}
+		doc = index
+	case pages > 1 && page <= pages:
+		end := page * sitemapMaxURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		doc = sitemapURLSet{XMLNS: sitemapNS, URLs: urls[(page-1)*sitemapMaxURLs : end]}
+	default:
+		return nil, errSitemapPageNotFound
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// handleSitemap serves GET /sitemap.xml and, for large catalogues, the
+// individual sitemap files at /sitemap.xml?page=N.
+func handleSitemap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	page := 0
+	if p := r.URL.Query().Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			http.NotFound(w, r)
+			return
+		}
+		page = n
+	}
+
+	baseURL := requestBaseURL(r)
+	key := fmt.Sprintf("sitemap:%s:%d", baseURL, page)
+	body, hit := globalCache.GetFeed(key)
+	if !hit {
+		var err error
+		body, err = globalCache.DoFeedQuery(key, func() ([]byte, error) { return buildSitemap(baseURL, page) })
+		if err == errSitemapPageNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("[SITEMAP] failed to build %s: %v", key, err)
+			http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+			return
+		}
+		globalCache.SetFeed(key, body)
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write(body)
+}
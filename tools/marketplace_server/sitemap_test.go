@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSitemapListsPublishedAndSplits(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (2, 'sn', 'bob', 'Bob')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id, auto_add_enabled) VALUES (10, 1, 'Shop', 'shop', 'pub10', 1)")
+	// Bob's store shows nothing, so it is left out.
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id, auto_add_enabled) VALUES (11, 2, 'Empty', 'empty', 'pub11', 0)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, share_token) VALUES (100, 1, 1, x'00', 'A', 'free', 'published', 'tokA')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, share_token) VALUES (101, 1, 1, x'00', 'B', 'free', 'pending', 'tokB')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, share_token) VALUES (102, 2, 1, x'00', 'C', 'free', 'published', 'tokC')")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleSitemap(rec, httptest.NewRequest(http.MethodGet, "http://example.com"+target, nil))
+		return rec
+	}
+
+	var set sitemapURLSet
+	if err := xml.Unmarshal(get("/sitemap.xml").Body.Bytes(), &set); err != nil {
+		t.Fatal(err)
+	}
+	var locs []string
+	for _, u := range set.URLs {
+		locs = append(locs, u.Loc)
+	}
+	want := []string{"http://example.com/", "http://example.com/store/pub10", "http://example.com/pack/tokA", "http://example.com/pack/tokC"}
+	if len(locs) != len(want) {
+		t.Fatalf("urls = %v, want %v", locs, want)
+	}
+	for i := range want {
+		if locs[i] != want[i] {
+			t.Fatalf("urls = %v, want %v", locs, want)
+		}
+	}
+
+	// With a smaller limit the same URLs are split behind an index.
+	prevMax := sitemapMaxURLs
+	sitemapMaxURLs = 3
+	t.Cleanup(func() { sitemapMaxURLs = prevMax })
+	var index sitemapIndex
+	if err := xml.Unmarshal(get("/sitemap.xml").Body.Bytes(), &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Sitemaps) != 2 || index.Sitemaps[1].Loc != "http://example.com/sitemap.xml?page=2" {
+		t.Fatalf("index = %+v", index)
+	}
+	set = sitemapURLSet{}
+	xml.Unmarshal(get("/sitemap.xml?page=2").Body.Bytes(), &set)
+	if len(set.URLs) != 1 || set.URLs[0].Loc != "http://example.com/pack/tokC" {
+		t.Errorf("page 2 = %+v", set.URLs)
+	}
+	if rec := get("/sitemap.xml?page=3"); rec.Code != http.StatusNotFound {
+		t.Errorf("page 3: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// SMTP sender defaults.
+const (
+	smtpDialTimeout    = 15 * time.Second
+	smtpMessageTimeout = 30 * time.Second // per message, MAIL FROM through end of DATA
+	smtpMaxAttempts    = 3
+	smtpRetryBaseDelay = time.Second
+)
+
+// smtpSender delivers messages over a single authenticated SMTP connection,
+// reconnecting only when the connection breaks. It is not safe for concurrent
+// use; create one per batch and Close it when done.
+type smtpSender struct {
+	config         SMTPConfig
+	dialTimeout    time.Duration
+	messageTimeout time.Duration
+	maxAttempts    int
+	retryDelay     time.Duration
+
+	conn   net.Conn
+	client *smtp.Client
+}
+
+// smtpMessage is one fully formatted message and its envelope recipient.
+type smtpMessage struct {
+	To   string
+	Data []byte
+}
+
+// smtpBatchResult reports the outcome of sendBatch. Errors holds the final
+// error for each recipient that could not be delivered.
+type smtpBatchResult struct {
+	Sent   int
+	Failed int
+	Errors map[string]error
+}
+
+// newSMTPSender returns a sender for config using the default timeouts and
+// retry policy. No connection is opened until the first message.
+func newSMTPSender(config SMTPConfig) *smtpSender {
+	return &smtpSender{
+		config:         config,
+		dialTimeout:    smtpDialTimeout,
+		messageTimeout: smtpMessageTimeout,
+		maxAttempts:    smtpMaxAttempts,
+		retryDelay:     smtpRetryBaseDelay,
+	}
+}
+
+// sendSMTPMail delivers a single message on its own connection.
+func sendSMTPMail(config SMTPConfig, to string, msg []byte) error {
+	s := newSMTPSender(config)
+	defer s.Close()
+	return s.Send(to, msg)
+}
+
+// connect dials the server and authenticates. With UseTLS the connection is
+// TLS from the start (port 465); otherwise STARTTLS is used when offered.
+func (s *smtpSender) connect() error {
+	addr := net.JoinHostPort(s.config.Host, fmt.Sprint(s.config.Port))
+	dialer := &net.Dialer{Timeout: s.dialTimeout}
+	var conn net.Conn
+	var err error
+	if s.config.UseTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: s.config.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("SMTP dial failed: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(s.messageTimeout))
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP client creation failed: %w", err)
+	}
+	if !s.config.UseTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+				client.Close()
+				return fmt.Errorf("SMTP STARTTLS failed: %w", err)
+			}
+		}
+	}
+	if s.config.Username != "" && s.config.Password != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			client.Close()
+			return fmt.Errorf("SMTP auth failed: %w", err)
+		}
+	}
+	s.conn = conn
+	s.client = client
+	return nil
+}
+
+// drop closes a connection whose state is unknown so the next attempt
+// starts fresh.
+func (s *smtpSender) drop() {
+	if s.client != nil {
+		s.client.Close()
+	}
+	s.conn = nil
+	s.client = nil
+}
+
+// sendOnce runs one MAIL/RCPT/DATA transaction on the current connection.
+func (s *smtpSender) sendOnce(to string, msg []byte) error {
+	if s.client == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	s.conn.SetDeadline(time.Now().Add(s.messageTimeout))
+
+	if err := s.client.Mail(s.config.FromEmail); err != nil {
+		return fmt.Errorf("SMTP MAIL command failed: %w", err)
+	}
+	if err := s.client.Rcpt(to); err != nil {
+		return fmt.Errorf("SMTP RCPT command failed: %w", err)
+	}
+	w, err := s.client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA command failed: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("SMTP write failed: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP close failed: %w", err)
+	}
+	return nil
+}
+
+// Send delivers msg to a single recipient, retrying transient failures with
+// exponential backoff.
+func (s *smtpSender) Send(to string, msg []byte) error {
+	var err error
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(s.retryDelay << (attempt - 2))
+		}
+		err = s.sendOnce(to, msg)
+		if err == nil {
+			return nil
+		}
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) {
+			// The server answered, so the connection is still usable once the
+			// transaction is reset.
+			if s.client != nil && s.client.Reset() != nil {
+				s.drop()
+			}
+			if protoErr.Code >= 500 {
+				return err // permanent, e.g. unknown mailbox
+			}
+			continue
+		}
+		// Network error or timeout: the connection can no longer be trusted.
+		s.drop()
+	}
+	return err
+}
+
+// sendBatch delivers msgs in order over the same connection and counts
+// successes and failures.
+func (s *smtpSender) sendBatch(msgs []smtpMessage) smtpBatchResult {
+	res := smtpBatchResult{Errors: map[string]error{}}
+	for _, m := range msgs {
+		if err := s.Send(m.To, m.Data); err != nil {
+			res.Failed++
+			res.Errors[m.To] = err
+			continue
+		}
+		res.Sent++
+	}
+	return res
+}
+
+// Close ends the SMTP session.
+func (s *smtpSender) Close() error {
+	if s.client == nil {
+		return nil
+	}
+	s.conn.SetDeadline(time.Now().Add(s.messageTimeout))
+	err := s.client.Quit()
+	s.drop()
+	return err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockSMTPServer is a minimal SMTP server whose replies depend on the
+// recipient's local part:
+//
+//	reject@… — RCPT always fails with 550
+//	flaky@…  — first RCPT fails with 451, later ones succeed
+//	slow@…   — first DATA never gets a reply (the connection stalls, then closes)
+type mockSMTPServer struct {
+	ln    net.Listener
+	stall time.Duration
+
+	mu        sync.Mutex
+	conns     int
+	rcptCount map[string]int
+	delivered []string
+	messages  []string
+}
+
+func newMockSMTPServer(t *testing.T) *mockSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	m := &mockSMTPServer{ln: ln, stall: time.Second, rcptCount: map[string]int{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			m.mu.Lock()
+			m.conns++
+			m.mu.Unlock()
+			go m.serve(c)
+		}
+	}()
+	return m
+}
+
+func (m *mockSMTPServer) config() SMTPConfig {
+	addr := m.ln.Addr().(*net.TCPAddr)
+	return SMTPConfig{Enabled: true, Host: "127.0.0.1", Port: addr.Port, FromEmail: "shop@example.com"}
+}
+
+func (m *mockSMTPServer) serve(c net.Conn) {
+	defer c.Close()
+	tp := textproto.NewConn(c)
+	tp.PrintfLine("220 mock ESMTP")
+	var rcpt string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 mock")
+		case "MAIL", "RSET":
+			rcpt = ""
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			rcpt = strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			m.mu.Lock()
+			m.rcptCount[rcpt]++
+			n := m.rcptCount[rcpt]
+			m.mu.Unlock()
+			switch {
+			case strings.HasPrefix(rcpt, "reject@"):
+				tp.PrintfLine("550 no such user")
+			case strings.HasPrefix(rcpt, "flaky@") && n == 1:
+				tp.PrintfLine("451 try again later")
+			default:
+				tp.PrintfLine("250 OK")
+			}
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			m.mu.Lock()
+			n := m.rcptCount[rcpt]
+			m.mu.Unlock()
+			if strings.HasPrefix(rcpt, "slow@") && n == 1 {
+				time.Sleep(m.stall)
+				return
+			}
+			m.mu.Lock()
+			m.delivered = append(m.delivered, rcpt)
+			m.messages = append(m.messages, string(data))
+			m.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSenderBatchReusesConnectionAndRetries(t *testing.T) {
+	server := newMockSMTPServer(t)
+	sender := newSMTPSender(server.config())
+	sender.messageTimeout = 200 * time.Millisecond
+	sender.retryDelay = time.Millisecond
+
+	var msgs []smtpMessage
+	for _, to := range []string{"a@example.com", "flaky@example.com", "reject@example.com", "slow@example.com", "b@example.com"} {
+		msgs = append(msgs, smtpMessage{To: to, Data: []byte("Subject: hi\r\n\r\nhello\r\n")})
+	}
+	res := sender.sendBatch(msgs)
+	if err := sender.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+
+	if res.Sent != 4 || res.Failed != 1 {
+		t.Errorf("sent=%d failed=%d, want 4 and 1", res.Sent, res.Failed)
+	}
+	if _, ok := res.Errors["reject@example.com"]; !ok || len(res.Errors) != 1 {
+		t.Errorf("errors = %v, want only reject@example.com", res.Errors)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	// One connection for the batch plus one reconnect after the stalled DATA.
+	if server.conns != 2 {
+		t.Errorf("connections = %d, want 2", server.conns)
+	}
+	if got := strings.Join(server.delivered, ","); got != "a@example.com,flaky@example.com,slow@example.com,b@example.com" {
+		t.Errorf("delivered = %s", got)
+	}
+	if server.rcptCount["reject@example.com"] != 1 {
+		t.Errorf("permanent failure retried %d times", server.rcptCount["reject@example.com"])
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Custom product orders are written as 'pending' when the PayPal order is
+// created and only move on when the buyer comes back to handlePayPalReturn.
+// Buyers who abandon the PayPal page leave the order pending forever, so
+// startStaleOrderSweeper fails them once they pass a configurable age.
+const (
+	defaultStaleOrderMaxAgeHours = 24
+	staleOrderSweepRate          = 30 * time.Minute
+	staleOrderFailureReason      = "expired: payment not completed"
+)
+
+// staleOrderMaxAge returns how long a custom product order may stay pending.
+func staleOrderMaxAge() time.Duration {
+	hours, err := strconv.Atoi(getSetting("stale_order_max_age_hours"))
+	if err != nil || hours <= 0 {
+		hours = defaultStaleOrderMaxAgeHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// startStaleOrderSweeper periodically cancels abandoned pending orders.
+func startStaleOrderSweeper() {
+	go func() {
+		ticker := time.NewTicker(staleOrderSweepRate)
+		defer ticker.Stop()
+		for {
+			cancelStaleOrders(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// cancelStaleOrders fails every pending order older than staleOrderMaxAge and
+// returns how many were cancelled.
+//
+// PayPal cannot void an order created with intent CAPTURE; an unapproved one
+// simply expires on PayPal's side. What it can tell us is whether the buyer
+// paid after all (the return redirect was lost), so each order is checked
+// first and left alone when PayPal reports it APPROVED or COMPLETED.
+func cancelStaleOrders(now time.Time) int {
+	cutoff := now.Add(-staleOrderMaxAge()).UTC().Format("2006-01-02 15:04:05")
+	rows, err := db.Query(`SELECT id, COALESCE(paypal_order_id, '') FROM custom_product_orders
+		WHERE status = 'pending' AND created_at < ?`, cutoff)
+	if err != nil {
+		log.Printf("[STALE-ORDERS] failed to query pending orders: %v", err)
+		return 0
+	}
+	type staleOrder struct {
+		ID            int64
+		PayPalOrderID string
+	}
+	var stale []staleOrder
+	for rows.Next() {
+		var o staleOrder
+		if rows.Scan(&o.ID, &o.PayPalOrderID) == nil {
+			stale = append(stale, o)
+		}
+	}
+	rows.Close()
+	if len(stale) == 0 {
+		return 0
+	}
+
+	config, configErr := payPalConfigFromSettings()
+	cancelled := 0
+	for _, o := range stale {
+		if configErr == nil && o.PayPalOrderID != "" {
+			var order struct {
+				Status string `json:"status"`
+			}
+			if err := payPalAPI(config, "GET", "/v2/checkout/orders/"+o.PayPalOrderID, "", nil, &order); err == nil &&
+				(order.Status == "APPROVED" || order.Status == "COMPLETED") {
+				log.Printf("[STALE-ORDERS] order %d is %s at PayPal, leaving it pending", o.ID, order.Status)
+				continue
+			}
+		}
+		// The status guard skips orders captured since the query; the
+		// Idempotency-Key is released so a retry starts a fresh order.
+		res, err := db.Exec(`UPDATE custom_product_orders SET status = 'failed', failure_reason = ?,
+			idempotency_key = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'pending'`,
+			staleOrderFailureReason, o.ID)
+		if err != nil {
+			log.Printf("[STALE-ORDERS] failed to cancel order %d: %v", o.ID, err)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			cancelled++
+		}
+	}
+	log.Printf("[STALE-ORDERS] cancelled %d of %d pending orders older than %v", cancelled, len(stale), staleOrderMaxAge())
+	return cancelled
+}
+
+// handleAdminStaleOrderSettings handles GET/POST /admin/settings/stale-orders.
+func handleAdminStaleOrderSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]int{"max_age_hours": int(staleOrderMaxAge().Hours())})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hours, err := strconv.Atoi(strings.TrimSpace(r.FormValue("max_age_hours")))
+	if err != nil || hours < 1 || hours > 720 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "超时时间必须在 1-720 小时之间"})
+		return
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('stale_order_max_age_hours', ?)", strconv.Itoa(hours)); err != nil {
+		log.Printf("[ADMIN] failed to save stale_order_max_age_hours: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCancelStaleOrders(t *testing.T) {
+	useTestDB(t)
+
+	paypal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/oauth2/token":
+			fmt.Fprint(w, `{"access_token":"tok"}`)
+		case r.URL.Path == "/v2/checkout/orders/PAID":
+			fmt.Fprint(w, `{"id":"PAID","status":"COMPLETED"}`)
+		case strings.HasPrefix(r.URL.Path, "/v2/checkout/orders/"):
+			fmt.Fprint(w, `{"status":"CREATED"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer paypal.Close()
+	prevBase := paypalAPIBaseURL
+	paypalAPIBaseURL = paypal.URL
+	t.Cleanup(func() { paypalAPIBaseURL = prevBase })
+	t.Setenv("PAYPAL_ENCRYPTION_KEY", "test-key")
+	secret, err := encryptPayPalSecret("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('paypal_client_id', 'client')")
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('paypal_client_secret', ?)", secret)
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('stale_order_max_age_hours', '2')")
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	insert := func(id int, paypalID, status, createdAt string) {
+		mustExec(t, `INSERT INTO custom_product_orders (id, custom_product_id, user_id, paypal_order_id, amount_usd, status, idempotency_key, created_at)
+			VALUES (?, 1, 1, ?, 10, ?, ?, ?)`, id, paypalID, status, fmt.Sprintf("key-%d", id), createdAt)
+	}
+	insert(1, "ABANDONED", "pending", "2026-05-01 09:00:00")
+	insert(2, "RECENT", "pending", "2026-05-01 11:00:00")
+	insert(3, "PAID", "pending", "2026-05-01 08:00:00")
+	insert(4, "DONE", "fulfilled", "2026-04-01 08:00:00")
+
+	if n := cancelStaleOrders(now); n != 1 {
+		t.Errorf("cancelled = %d, want 1", n)
+	}
+	for id, want := range map[int]string{1: "failed", 2: "pending", 3: "pending", 4: "fulfilled"} {
+		var status, reason string
+		db.QueryRow("SELECT status, COALESCE(failure_reason, '') FROM custom_product_orders WHERE id = ?", id).Scan(&status, &reason)
+		if status != want {
+			t.Errorf("order %d status = %q, want %q", id, status, want)
+		}
+		if (status == "failed") != (reason != "") {
+			t.Errorf("order %d: status %q with failure_reason %q", id, status, reason)
+		}
+	}
+	var key *string
+	db.QueryRow("SELECT idempotency_key FROM custom_product_orders WHERE id = 1").Scan(&key)
+	if key != nil {
+		t.Errorf("cancelled order kept idempotency_key %q", *key)
+	}
+}
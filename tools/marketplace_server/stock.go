@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Virtual goods may carry a finite stock_quantity for stores whose license
+// pool is limited; NULL means unlimited. A unit is taken in the same
+// transaction that marks an order fulfilled, so two buyers paying for the
+// last unit cannot both be fulfilled.
+
+// maxStockQuantity bounds the stock an author can set.
+const maxStockQuantity = 1000000
+
+// errOutOfStock is returned when a paid order finds its product sold out.
+var errOutOfStock = errors.New("custom product out of stock")
+
+// fulfillmentSoldOut is the last_error of fulfillment jobs parked until the
+// author restocks.
+const fulfillmentSoldOut = "sold out"
+
+// customProductInStock reports whether the product has unlimited stock or at
+// least one unit left.
+func customProductInStock(productID int64) bool {
+	var stock sql.NullInt64
+	if err := db.QueryRow("SELECT stock_quantity FROM custom_products WHERE id = ?", productID).Scan(&stock); err != nil {
+		return false
+	}
+	return !stock.Valid || stock.Int64 > 0
+}
+
+// SoldOut reports whether a virtual goods product has no units left.
+func (p CustomProduct) SoldOut() bool {
+	return p.ProductType == "virtual_goods" && p.StockQuantity != nil && *p.StockQuantity <= 0
+}
+
+// fulfillVirtualGoodsOrder records the license bound to a paid virtual goods
+// order, marks it fulfilled and takes one unit of stock, all in one
+// transaction. It returns false when the order is no longer paid, and
+// errOutOfStock, leaving the order paid, when the last unit went to another
+// buyer.
+func fulfillVirtualGoodsOrder(orderID, productID int64, sn, email string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE custom_product_orders SET license_sn = ?, license_email = ?, status = 'fulfilled', updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'paid'`, sn, email, orderID)
+	if err != nil {
+		return false, fmt.Errorf("update order: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	res, err = tx.Exec(`UPDATE custom_products SET stock_quantity = stock_quantity - 1
+		WHERE id = ? AND stock_quantity IS NOT NULL`, productID)
+	if err != nil {
+		return false, fmt.Errorf("take stock: %w", err)
+	}
+	tracked, _ := res.RowsAffected()
+	if tracked > 0 {
+		var left int64
+		if err := tx.QueryRow("SELECT stock_quantity FROM custom_products WHERE id = ?", productID).Scan(&left); err != nil {
+			return false, fmt.Errorf("read stock: %w", err)
+		}
+		if left < 0 {
+			return false, errOutOfStock
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	if tracked > 0 {
+		invalidateCustomProductStorefront(productID)
+	}
+	return true, nil
+}
+
+// requeueSoldOutFulfillments makes the jobs parked by a sell-out of productID
+// due again after a restock.
+func requeueSoldOutFulfillments(productID int64) {
+	res, err := db.Exec(`UPDATE fulfillment_jobs SET status = 'pending', next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE status = 'failed' AND last_error = ?
+		AND order_id IN (SELECT id FROM custom_product_orders WHERE custom_product_id = ? AND status = 'paid')`, fulfillmentSoldOut, productID)
+	if err != nil {
+		log.Printf("[FULFILLMENT] failed to requeue sold-out jobs of product %d: %v", productID, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("[FULFILLMENT] product %d restocked, requeued %d jobs", productID, n)
+	}
+}
+
+// invalidateCustomProductStorefront drops the cached page of the store
+// selling productID.
+func invalidateCustomProductStorefront(productID int64) {
+	var slug string
+	if err := db.QueryRow("SELECT s.store_slug FROM custom_products cp JOIN author_storefronts s ON s.id = cp.storefront_id WHERE cp.id = ?", productID).Scan(&slug); err == nil && slug != "" {
+		globalCache.InvalidateStorefront(slug)
+	}
+}
+
+// handleCustomProductStock handles POST /user/storefront/custom-products/stock.
+// Form value "stock_quantity" sets the units left of a virtual goods product;
+// an empty value removes the limit.
+func handleCustomProductStock(w http.ResponseWriter, r *http.Request, userID int64) {
+	redirectErr := func(msg string) {
+		http.Redirect(w, r, "/user/storefront/custom-products?error="+url.QueryEscape(msg), http.StatusFound)
+	}
+	if err := r.ParseForm(); err != nil {
+		redirectErr("无效的表单数据")
+		return
+	}
+	productID, err := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
+	if err != nil || productID <= 0 {
+		redirectErr("无效的商品")
+		return
+	}
+
+	var stock interface{}
+	inStock := true
+	successMsg := "已取消库存限制"
+	if s := strings.TrimSpace(r.FormValue("stock_quantity")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxStockQuantity {
+			redirectErr(fmt.Sprintf("库存数量必须为 0 到 %d 之间的整数", maxStockQuantity))
+			return
+		}
+		stock, inStock = n, n > 0
+		successMsg = fmt.Sprintf("库存已更新为 %d", n)
+	}
+
+	var productType string
+	err = db.QueryRow(`SELECT p.product_type FROM custom_products p
+		JOIN author_storefronts s ON s.id = p.storefront_id
+		WHERE p.id = ? AND s.user_id = ? AND p.deleted_at IS NULL`, productID, userID).Scan(&productType)
+	if err == sql.ErrNoRows {
+		redirectErr("商品不存在")
+		return
+	}
+	if err != nil {
+		log.Printf("[handleCustomProductStock] query product error: %v", err)
+		http.Error(w, "加载数据失败", http.StatusInternalServerError)
+		return
+	}
+	if productType != "virtual_goods" {
+		redirectErr("仅虚拟商品支持库存设置")
+		return
+	}
+
+	if _, err := db.Exec("UPDATE custom_products SET stock_quantity = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", stock, productID); err != nil {
+		log.Printf("[handleCustomProductStock] update product %d error: %v", productID, err)
+		http.Error(w, "保存失败", http.StatusInternalServerError)
+		return
+	}
+	if inStock {
+		requeueSoldOutFulfillments(productID)
+	}
+	invalidateCustomProductStorefront(productID)
+	http.Redirect(w, r, "/user/storefront/custom-products?success="+url.QueryEscape(successMsg), http.StatusFound)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestVirtualGoodsStock(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Alice', 'alice@example.com'), (2, 'email', 'b', 'Bob', 'bob@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, custom_products_enabled) VALUES (10, 2, 'Bob Store', 'bob', 1)")
+	mustExec(t, `INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd, license_api_endpoint, status, stock_quantity)
+		VALUES (5, 10, 'License', 'virtual_goods', 10, 'https://license.test', 'published', 1),
+		       (6, 10, 'Unlimited', 'virtual_goods', 10, 'https://license.test', 'published', NULL)`)
+	mustExec(t, `INSERT INTO custom_product_orders (id, custom_product_id, user_id, amount_usd, status)
+		VALUES (1, 5, 1, 10, 'paid'), (2, 5, 1, 10, 'paid'), (3, 6, 1, 10, 'paid')`)
+
+	stock := func(productID int64) *int {
+		var p CustomProduct
+		if err := db.QueryRow("SELECT stock_quantity FROM custom_products WHERE id = ?", productID).Scan(&p.StockQuantity); err != nil {
+			t.Fatal(err)
+		}
+		return p.StockQuantity
+	}
+	orderStatus := func(orderID int64) string {
+		var s string
+		db.QueryRow("SELECT status FROM custom_product_orders WHERE id = ?", orderID).Scan(&s)
+		return s
+	}
+
+	// Both buyers paid for the last unit; only the first fulfillment takes it.
+	if ok, err := fulfillVirtualGoodsOrder(1, 5, "SN-1", "alice@example.com"); !ok || err != nil {
+		t.Fatalf("first fulfillment = %v, %v", ok, err)
+	}
+	if s := stock(5); s == nil || *s != 0 {
+		t.Fatalf("stock after sale = %v, want 0", s)
+	}
+	if _, err := fulfillVirtualGoodsOrder(2, 5, "SN-2", "alice@example.com"); !errors.Is(err, errOutOfStock) {
+		t.Fatalf("second fulfillment err = %v, want errOutOfStock", err)
+	}
+	if s := stock(5); *s != 0 || orderStatus(2) != "paid" {
+		t.Errorf("sold-out fulfillment changed state: stock %d, order %s", *s, orderStatus(2))
+	}
+	if ok, _ := fulfillVirtualGoodsOrder(1, 5, "SN-1", "alice@example.com"); ok {
+		t.Errorf("fulfilled order fulfilled again")
+	}
+	if ok, err := fulfillVirtualGoodsOrder(3, 6, "SN-3", "alice@example.com"); !ok || err != nil || stock(6) != nil {
+		t.Errorf("unlimited product: %v, %v, stock %v", ok, err, stock(6))
+	}
+
+	// A sold-out product refuses new orders before reaching PayPal.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/custom-product/5/purchase", nil)
+	req.Header.Set("X-User-ID", "1")
+	handleCustomProductPurchase(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("purchase of sold-out product: status %d, want 409", rec.Code)
+	}
+
+	// Restocking requeues the jobs parked by the sell-out.
+	mustExec(t, "INSERT INTO fulfillment_jobs (order_id, status, last_error) VALUES (2, 'failed', ?)", fulfillmentSoldOut)
+	restock := func(userID, productID, quantity string) string {
+		req := httptest.NewRequest(http.MethodPost, "/user/storefront/custom-products/stock",
+			strings.NewReader(url.Values{"product_id": {productID}, "stock_quantity": {quantity}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", userID)
+		rec := httptest.NewRecorder()
+		handleCustomProductCRUD(rec, req)
+		return rec.Header().Get("Location")
+	}
+	if loc := restock("1", "5", "3"); !strings.Contains(loc, "error=") {
+		t.Errorf("non-owner restock redirected to %q", loc)
+	}
+	if loc := restock("2", "5", "-1"); !strings.Contains(loc, "error=") {
+		t.Errorf("negative stock redirected to %q", loc)
+	}
+	if loc := restock("2", "5", "3"); !strings.Contains(loc, "success=") {
+		t.Fatalf("restock redirected to %q", loc)
+	}
+	if s := stock(5); s == nil || *s != 3 {
+		t.Errorf("stock after restock = %v, want 3", s)
+	}
+	var jobStatus string
+	db.QueryRow("SELECT status FROM fulfillment_jobs WHERE order_id = 2").Scan(&jobStatus)
+	if jobStatus != "pending" {
+		t.Errorf("sold-out job status after restock = %q, want pending", jobStatus)
+	}
+	if loc := restock("2", "5", ""); !strings.Contains(loc, "success=") || stock(5) != nil {
+		t.Errorf("clearing stock: %q, stock %v", loc, stock(5))
+	}
+}
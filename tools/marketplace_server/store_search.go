@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// storeSearchPageSize is the number of stores returned per results page.
+	storeSearchPageSize = 20
+	// maxStoreSearchQueryLength caps the search text, in characters.
+	maxStoreSearchQueryLength = 50
+	// maxStoreSearchPage stops deep paging through the result set.
+	maxStoreSearchPage = 50
+)
+
+// StoreSearchResult is one page of the public store search.
+type StoreSearchResult struct {
+	OK      bool                `json:"ok"`
+	Query   string              `json:"query"`
+	Page    int                 `json:"page"`
+	HasMore bool                `json:"has_more"`
+	Stores  []HomepageStoreInfo `json:"stores"`
+}
+
+// normalizeStoreSearchQuery trims and lowercases the query so equivalent
+// searches share one cache entry.
+func normalizeStoreSearchQuery(q string) string {
+	return strings.ToLower(strings.Join(strings.Fields(q), " "))
+}
+
+// searchStorefronts 按店铺名称、slug 和简介搜索小铺，排除已暂停/已删除以及没有已发布分析包的小铺。
+// 结果按匹配程度排序：名称完全匹配 > 名称前缀 > 名称包含 > slug 包含 > 简介包含，同级再按认证状态和发布数量排序。
+func searchStorefronts(q string, page int) (*StoreSearchResult, error) {
+	escaped := strings.NewReplacer("%", "\\%", "_", "\\_").Replace(q)
+	contains := "%" + escaped + "%"
+	prefix := escaped + "%"
+
+	// Fetch one extra row to learn whether another page exists.
+	rows, err := db.Query(`SELECT s.id, COALESCE(s.public_id, ''), s.store_name, s.store_slug, s.description,
+		CASE WHEN s.logo_data IS NOT NULL AND length(s.logo_data) > 0 THEN 1 ELSE 0 END as has_logo, COALESCE(s.verified, 0),
+		CASE
+			WHEN lower(s.store_name) = ? THEN 0
+			WHEN s.store_name LIKE ? ESCAPE '\' THEN 1
+			WHEN s.store_name LIKE ? ESCAPE '\' THEN 2
+			WHEN s.store_slug LIKE ? ESCAPE '\' THEN 3
+			ELSE 4
+		END as rank,
+		COUNT(pl.id) as pack_count
+		FROM author_storefronts s
+		JOIN pack_listings pl ON pl.user_id = s.user_id AND pl.status = 'published'
+		WHERE COALESCE(s.store_status, 'active') NOT IN ('paused', 'deleted')
+			AND (s.store_name LIKE ? ESCAPE '\' OR s.store_slug LIKE ? ESCAPE '\' OR s.description LIKE ? ESCAPE '\')
+		GROUP BY s.id
+		ORDER BY rank ASC, COALESCE(s.verified, 0) DESC, pack_count DESC, s.id ASC
+		LIMIT ? OFFSET ?`,
+		q, prefix, contains, contains,
+		contains, contains, contains,
+		storeSearchPageSize+1, (page-1)*storeSearchPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("searchStorefronts: %w", err)
+	}
+	defer rows.Close()
+
+	result := &StoreSearchResult{OK: true, Query: q, Page: page, Stores: []HomepageStoreInfo{}}
+	for rows.Next() {
+		var s HomepageStoreInfo
+		var hasLogo, rank, packCount int
+		if err := rows.Scan(&s.StorefrontID, &s.PublicID, &s.StoreName, &s.StoreSlug, &s.Description, &hasLogo, &s.Verified, &rank, &packCount); err != nil {
+			return nil, fmt.Errorf("searchStorefronts scan: %w", err)
+		}
+		s.HasLogo = hasLogo == 1
+		result.Stores = append(result.Stores, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("searchStorefronts rows: %w", err)
+	}
+	if len(result.Stores) > storeSearchPageSize {
+		result.Stores = result.Stores[:storeSearchPageSize]
+		result.HasMore = true
+	}
+	return result, nil
+}
+
+// handleStoreSearch handles GET /api/stores/search?q=...&page=N, the public
+// store search used by the homepage. Results are cached briefly in the feed
+// cache so popular queries don't hit the database on every keystroke.
+func handleStoreSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "method not allowed"})
+		return
+	}
+	q := normalizeStoreSearchQuery(r.URL.Query().Get("q"))
+	if q == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "请输入搜索关键词"})
+		return
+	}
+	if utf8.RuneCountInString(q) > maxStoreSearchQueryLength {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": fmt.Sprintf("搜索关键词不能超过 %d 个字符", maxStoreSearchQueryLength)})
+		return
+	}
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > maxStoreSearchPage {
+			jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "页码无效"})
+			return
+		}
+		page = n
+	}
+
+	key := fmt.Sprintf("store-search:%d:%s", page, q)
+	body, hit := globalCache.GetFeed(key)
+	if !hit {
+		var err error
+		body, err = globalCache.DoFeedQuery(key, func() ([]byte, error) {
+			result, err := searchStorefronts(q, page)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(result)
+		})
+		if err != nil {
+			log.Printf("[STORE-SEARCH] failed to search %q: %v", q, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "搜索失败"})
+			return
+		}
+		globalCache.SetFeed(key, body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
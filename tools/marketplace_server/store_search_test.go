@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStoreSearch(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{FeedTTL: time.Hour, MaxEntries: 100})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'a', 'A'), (2, 'sn', 'b', 'B'), (3, 'sn', 'c', 'C'), (4, 'sn', 'd', 'D'), (5, 'sn', 'e', 'E')")
+	mustExec(t, `INSERT INTO author_storefronts (id, user_id, store_name, store_slug, description, store_status) VALUES
+		(10, 1, 'Data Lab', 'lab', 'charts', 'active'),
+		(11, 2, 'Big Data', 'big', 'tables', 'active'),
+		(12, 3, 'Sales Kit', 'data-kit', '', 'active'),
+		(13, 4, 'Paused Data', 'paused', '', 'paused'),
+		(14, 5, 'Empty Data', 'empty', '', 'active')`)
+	for _, userID := range []int{1, 2, 3, 4} {
+		mustExec(t, "INSERT INTO pack_listings (user_id, category_id, file_data, pack_name, share_mode, status) VALUES (?, 1, x'00', 'p', 'free', 'published')", userID)
+	}
+
+	search := func(query string) (int, StoreSearchResult) {
+		req := httptest.NewRequest(http.MethodGet, "/api/stores/search?"+query, nil)
+		rec := httptest.NewRecorder()
+		handleStoreSearch(rec, req)
+		var result StoreSearchResult
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		return rec.Code, result
+	}
+
+	code, result := search("q=+DATA+")
+	if code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	var got []int64
+	for _, s := range result.Stores {
+		got = append(got, s.StorefrontID)
+	}
+	want := []int64{10, 11, 12}
+	if len(got) != len(want) {
+		t.Fatalf("results %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("results %v, want %v", got, want)
+		}
+	}
+
+	if _, result := search("q=50%25"); len(result.Stores) != 0 {
+		t.Errorf("LIKE wildcard matched %d stores", len(result.Stores))
+	}
+	for _, query := range []string{"q=", "q=data&page=0", "q=data&page=x"} {
+		if code, _ := search(query); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, code)
+		}
+	}
+
+	// Popular queries are served from cache until it expires.
+	mustExec(t, "UPDATE author_storefronts SET store_status = 'paused' WHERE id = 10")
+	if _, result := search("q=data"); len(result.Stores) != 3 {
+		t.Errorf("cached search returned %d stores", len(result.Stores))
+	}
+}
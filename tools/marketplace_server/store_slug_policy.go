@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// defaultReservedStoreSlugs are slugs no store may use: the server's own route
+// prefixes plus names that could pass for the marketplace itself. Admins can
+// add more through the reserved_store_slugs setting.
+var defaultReservedStoreSlugs = []string{
+	"admin", "api", "custom-product", "feed", "marketplace-logo", "pack", "paypal",
+	"set-lang", "sitemap", "store", "user",
+	"about", "account", "assets", "billing", "help", "login", "logout", "official",
+	"register", "root", "settings", "static", "support", "system", "vantagics", "www",
+}
+
+// defaultProfaneSlugWords are rejected as any hyphen-separated part of a slug
+// while the profanity filter is on. Admins can add more through the
+// store_slug_profanity_words setting.
+var defaultProfaneSlugWords = []string{
+	"fuck", "fucker", "fucking", "shit", "bitch", "cunt", "dick", "cock", "pussy",
+	"asshole", "bastard", "nigger", "nigga", "faggot", "whore", "slut", "porn",
+}
+
+// slugWordList splits a comma/whitespace separated setting into lower-case words.
+func slugWordList(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+}
+
+// storeSlugProfanityFilterEnabled reports whether profane slugs are rejected
+// (on unless the store_slug_profanity_filter setting is "0").
+func storeSlugProfanityFilterEnabled() bool {
+	return getSetting("store_slug_profanity_filter") != "0"
+}
+
+// isReservedStoreSlug reports whether slug is reserved.
+func isReservedStoreSlug(slug string) bool {
+	for _, w := range defaultReservedStoreSlugs {
+		if slug == w {
+			return true
+		}
+	}
+	for _, w := range slugWordList(getSetting("reserved_store_slugs")) {
+		if slug == w {
+			return true
+		}
+	}
+	return false
+}
+
+// isProfaneStoreSlug reports whether any hyphen-separated part of slug is a
+// blocked word. It is always false while the profanity filter is off.
+func isProfaneStoreSlug(slug string) bool {
+	if !storeSlugProfanityFilterEnabled() {
+		return false
+	}
+	blocked := make(map[string]bool)
+	for _, w := range defaultProfaneSlugWords {
+		blocked[w] = true
+	}
+	for _, w := range slugWordList(getSetting("store_slug_profanity_words")) {
+		blocked[w] = true
+	}
+	for _, part := range strings.Split(slug, "-") {
+		if blocked[part] {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAdminStoreSlugSettings handles GET/POST /admin/settings/store-slugs.
+func handleAdminStoreSlugSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"builtin_reserved":  defaultReservedStoreSlugs,
+			"reserved":          strings.Join(slugWordList(getSetting("reserved_store_slugs")), "\n"),
+			"profanity_enabled": storeSlugProfanityFilterEnabled(),
+			"profanity_words":   strings.Join(slugWordList(getSetting("store_slug_profanity_words")), "\n"),
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profanityEnabled := "0"
+	if v := r.FormValue("profanity_enabled"); v == "1" || v == "true" {
+		profanityEnabled = "1"
+	}
+	for key, value := range map[string]string{
+		"reserved_store_slugs":        strings.Join(slugWordList(r.FormValue("reserved")), ","),
+		"store_slug_profanity_words":  strings.Join(slugWordList(r.FormValue("profanity_words")), ","),
+		"store_slug_profanity_filter": profanityEnabled,
+	} {
+		if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			log.Printf("[ADMIN] failed to save %s: %v", key, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
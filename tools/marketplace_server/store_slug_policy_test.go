@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestReservedAndProfaneStoreSlugs(t *testing.T) {
+	useTestDB(t)
+
+	if msg := validateStoreSlug("admin"); msg != "该小铺标识为系统保留字，请换一个" {
+		t.Errorf("admin: %q", msg)
+	}
+	if msg := validateStoreSlug("my-shit-shop"); msg != "小铺标识包含不当词汇，请换一个" {
+		t.Errorf("profane slug: %q", msg)
+	}
+	// Only whole hyphen-separated parts are matched.
+	if msg := validateStoreSlug("cocktail-data"); msg != "" {
+		t.Errorf("cocktail-data: %q", msg)
+	}
+
+	db.Exec("INSERT INTO settings (key, value) VALUES ('reserved_store_slugs', 'acme,partner')")
+	db.Exec("INSERT INTO settings (key, value) VALUES ('store_slug_profanity_filter', '0')")
+	if msg := validateStoreSlug("partner"); msg == "" {
+		t.Errorf("configured reserved word accepted")
+	}
+	if msg := validateStoreSlug("my-shit-shop"); msg != "" {
+		t.Errorf("profanity filter off: %q", msg)
+	}
+	db.Exec("UPDATE settings SET value = '1' WHERE key = 'store_slug_profanity_filter'")
+
+	for name, want := range map[string]string{
+		"Admin":     "admin-2",
+		"":          "store-2",
+		"Shit Shop": "store-2",
+		"Data Lab":  "data-lab",
+	} {
+		if got := generateStoreSlug(name); got != want {
+			t.Errorf("generateStoreSlug(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxTransferNoteLength caps the admin note stored with a storefront transfer.
+const maxTransferNoteLength = 500
+
+var (
+	errTransferSameOwner      = fmt.Errorf("target user already owns this storefront")
+	errTransferTargetHasStore = fmt.Errorf("target user already owns a storefront")
+)
+
+// storefrontTransferCarryover returns the gross sales credits a user keeps
+// (positive) or gave up (negative) through storefront transfers. A transfer
+// moves the pack listings, and with them every past sale, to the new owner;
+// carried_revenue books the sales made before the transfer back to the
+// previous owner so neither side's unwithdrawn balance changes.
+func storefrontTransferCarryover(userID int64) float64 {
+	var carryover float64
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN from_user_id = ? THEN carried_revenue ELSE 0 END), 0)
+		     - COALESCE(SUM(CASE WHEN to_user_id = ? THEN carried_revenue ELSE 0 END), 0)
+		FROM storefront_transfers
+		WHERE from_user_id = ? OR to_user_id = ?`, userID, userID, userID, userID).Scan(&carryover)
+	if err != nil {
+		log.Printf("[STOREFRONT-TRANSFER] failed to query carryover for user %d: %v", userID, err)
+		return 0
+	}
+	return carryover
+}
+
+// transferStorefront moves a storefront and all pack listings of its owner to
+// toUserID in one transaction. The store slug, public id, custom products
+// (keyed by storefront) and credits_transactions are left untouched.
+func transferStorefront(storefrontID, toUserID, adminID int64, note string) (fromUserID int64, packs int64, carried float64, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer tx.Rollback()
+
+	if err = tx.QueryRow("SELECT user_id FROM author_storefronts WHERE id = ?", storefrontID).Scan(&fromUserID); err != nil {
+		return 0, 0, 0, err
+	}
+	if fromUserID == toUserID {
+		return fromUserID, 0, 0, errTransferSameOwner
+	}
+	var owned int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM author_storefronts WHERE user_id = ?", toUserID).Scan(&owned); err != nil {
+		return fromUserID, 0, 0, err
+	}
+	if owned > 0 {
+		return fromUserID, 0, 0, errTransferTargetHasStore
+	}
+
+	if err = tx.QueryRow(`
+		SELECT COALESCE(SUM(ABS(ct.amount)), 0)
+		FROM credits_transactions ct
+		JOIN pack_listings pl ON ct.listing_id = pl.id
+		WHERE pl.user_id = ? AND ct.transaction_type IN ('purchase', 'download', 'purchase_uses', 'renew')
+		  AND ct.amount < 0`, fromUserID).Scan(&carried); err != nil {
+		return fromUserID, 0, 0, err
+	}
+
+	if _, err = tx.Exec("UPDATE author_storefronts SET user_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", toUserID, storefrontID); err != nil {
+		return fromUserID, 0, 0, err
+	}
+	res, err := tx.Exec("UPDATE pack_listings SET user_id = ? WHERE user_id = ?", toUserID, fromUserID)
+	if err != nil {
+		return fromUserID, 0, 0, err
+	}
+	packs, _ = res.RowsAffected()
+
+	if _, err = tx.Exec(`INSERT INTO storefront_transfers (storefront_id, from_user_id, to_user_id, carried_revenue, admin_id, note)
+		VALUES (?, ?, ?, ?, ?, ?)`, storefrontID, fromUserID, toUserID, carried, adminID, note); err != nil {
+		return fromUserID, 0, 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return fromUserID, 0, 0, err
+	}
+	return fromUserID, packs, carried, nil
+}
+
+// handleAdminStorefrontTransfer handles POST /api/admin/storefronts/transfer
+// with a JSON body {"storefront_id": N, "to_user_id": M, "note": "..."}.
+// The storefront keeps its slug and sales history; the previous owner keeps
+// the earnings made before the transfer. Every transfer is audit-logged.
+func handleAdminStorefrontTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	var req struct {
+		StorefrontID int64  `json:"storefront_id"`
+		ToUserID     int64  `json:"to_user_id"`
+		Note         string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.StorefrontID <= 0 || req.ToUserID <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if len([]rune(note)) > maxTransferNoteLength {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "note_too_long"})
+		return
+	}
+
+	var isBlocked int
+	var deletedAt *string
+	err := db.QueryRow("SELECT COALESCE(is_blocked, 0), deleted_at FROM users WHERE id = ?", req.ToUserID).Scan(&isBlocked, &deletedAt)
+	if err == sql.ErrNoRows || deletedAt != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "user_not_found"})
+		return
+	} else if err != nil {
+		log.Printf("[STOREFRONT-TRANSFER] failed to query user %d: %v", req.ToUserID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+	if isBlocked == 1 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "user_blocked"})
+		return
+	}
+
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	fromUserID, packs, carried, err := transferStorefront(req.StorefrontID, req.ToUserID, adminID, note)
+	switch {
+	case err == sql.ErrNoRows:
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "storefront_not_found"})
+		return
+	case err == errTransferSameOwner:
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "same_owner"})
+		return
+	case err == errTransferTargetHasStore:
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "target_has_storefront"})
+		return
+	case err != nil:
+		log.Printf("[STOREFRONT-TRANSFER] failed to transfer storefront %d to user %d: %v", req.StorefrontID, req.ToUserID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+
+	recordAuditLog(adminID, "storefront_transfer", fmt.Sprintf("storefront:%d", req.StorefrontID),
+		fmt.Sprintf("from=user:%d to=user:%d packs=%d carried_revenue=%.2f note=%s", fromUserID, req.ToUserID, packs, carried, note), getClientIP(r))
+	log.Printf("[STOREFRONT-TRANSFER] storefront %d moved from user %d to user %d (%d packs)", req.StorefrontID, fromUserID, req.ToUserID, packs)
+
+	var slug, publicID string
+	db.QueryRow("SELECT store_slug, COALESCE(public_id, '') FROM author_storefronts WHERE id = ?", req.StorefrontID).Scan(&slug, &publicID)
+	globalCache.InvalidateStorefront(slug)
+	if publicID != "" {
+		globalCache.InvalidateStorefront(publicID)
+	}
+	globalCache.InvalidateHomepage()
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":              true,
+		"from_user_id":    fromUserID,
+		"to_user_id":      req.ToUserID,
+		"packs_moved":     packs,
+		"carried_revenue": carried,
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminStorefrontTransfer(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'sn', 'seller', 'Seller', 'seller@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'buyer', 'Buyer', 'buyer@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (3, 'sn', 'other', 'Other', 'other@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (11, 3, 'Other', 'other-shop')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode) VALUES (100, 1, 1, x'00', 'Pack', 'per_use')")
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id) VALUES (3, 'purchase', -50, 100)")
+	mustExec(t, "INSERT INTO withdrawal_records (user_id, credits_amount, cash_rate, cash_amount) VALUES (1, 10, 1, 10)")
+
+	transfer := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/storefronts/transfer", strings.NewReader(body))
+		req.Header.Set("X-Admin-ID", "1")
+		rec := httptest.NewRecorder()
+		handleAdminStorefrontTransfer(rec, req)
+		return rec
+	}
+
+	if rec := transfer(`{"storefront_id": 10, "to_user_id": 3}`); rec.Code != http.StatusConflict {
+		t.Fatalf("target with a store: status %d, want 409", rec.Code)
+	}
+	if rec := transfer(`{"storefront_id": 10, "to_user_id": 2, "note": "sold"}`); rec.Code != http.StatusOK {
+		t.Fatalf("transfer: status %d body %s", rec.Code, rec.Body.String())
+	}
+
+	var owner, packOwner int64
+	var slug string
+	db.QueryRow("SELECT user_id, store_slug FROM author_storefronts WHERE id = 10").Scan(&owner, &slug)
+	db.QueryRow("SELECT user_id FROM pack_listings WHERE id = 100").Scan(&packOwner)
+	if owner != 2 || packOwner != 2 || slug != "shop" {
+		t.Fatalf("after transfer: store owner %d, pack owner %d, slug %q", owner, packOwner, slug)
+	}
+
+	// Sales before the transfer stay credited to the previous owner.
+	if got := storefrontTransferCarryover(1); got != 50 {
+		t.Errorf("previous owner carryover = %v, want 50", got)
+	}
+	if got := storefrontTransferCarryover(2); got != -50 {
+		t.Errorf("new owner carryover = %v, want -50", got)
+	}
+
+	var audits int
+	db.QueryRow("SELECT COUNT(*) FROM admin_audit_log WHERE action = 'storefront_transfer' AND target = 'storefront:10'").Scan(&audits)
+	if audits != 1 {
+		t.Errorf("audit rows = %d, want 1", audits)
+	}
+}
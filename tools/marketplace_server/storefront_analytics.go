@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storefront analytics. Store and pack detail page renders are counted per
+// UTC day in storefront_views and pack_views. Rendering only queues a
+// viewEvent; a single background goroutine de-duplicates repeat views by the
+// same visitor within viewDedupWindow and flushes the counts in batches, so
+// tracking never blocks a page or takes a DB write per request.
+const (
+	viewQueueSize     = 4096
+	viewDedupWindow   = 30 * time.Minute
+	viewFlushInterval = 30 * time.Second
+	viewDayLayout     = "2006-01-02"
+)
+
+const (
+	viewKindStore = "store"
+	viewKindPack  = "pack"
+)
+
+type viewEvent struct {
+	kind    string
+	id      int64
+	visitor string
+	at      time.Time
+}
+
+type viewBucket struct {
+	kind string
+	id   int64
+	day  string
+}
+
+// viewEvents is drained by the goroutine started in startViewTracker. Events
+// are dropped when it is full.
+var viewEvents = make(chan viewEvent, viewQueueSize)
+
+// trackStorefrontView counts a storefront page view.
+func trackStorefrontView(r *http.Request, storefrontID int64) {
+	trackView(r, viewKindStore, storefrontID)
+}
+
+// trackPackView counts a pack detail page view.
+func trackPackView(r *http.Request, listingID int64) {
+	trackView(r, viewKindPack, listingID)
+}
+
+func trackView(r *http.Request, kind string, id int64) {
+	if isCrawlerRequest(r) {
+		return
+	}
+	select {
+	case viewEvents <- viewEvent{kind: kind, id: id, visitor: viewVisitorKey(r), at: time.Now()}:
+	default:
+	}
+}
+
+// viewVisitorKey identifies a visitor for de-duplication: the user session
+// when logged in, otherwise the client IP and user agent.
+func viewVisitorKey(r *http.Request) string {
+	if cookie, err := r.Cookie("user_session"); err == nil && cookie.Value != "" {
+		return "s:" + cookie.Value
+	}
+	return "a:" + getClientIP(r) + "|" + r.UserAgent()
+}
+
+// isCrawlerRequest reports whether the request looks like a bot rather than a visitor.
+func isCrawlerRequest(r *http.Request) bool {
+	ua := strings.ToLower(r.UserAgent())
+	if ua == "" {
+		return true
+	}
+	for _, marker := range []string{"bot", "spider", "crawl", "slurp", "preview"} {
+		if strings.Contains(ua, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// viewCounter accumulates de-duplicated views between flushes. It is owned by
+// the tracker goroutine and not safe for concurrent use.
+type viewCounter struct {
+	seen    map[string]time.Time
+	pending map[viewBucket]int
+}
+
+func newViewCounter() *viewCounter {
+	return &viewCounter{seen: make(map[string]time.Time), pending: make(map[viewBucket]int)}
+}
+
+// add counts ev unless the same visitor viewed the same page within viewDedupWindow.
+func (c *viewCounter) add(ev viewEvent) {
+	key := fmt.Sprintf("%s:%d|%s", ev.kind, ev.id, ev.visitor)
+	if last, ok := c.seen[key]; ok && ev.at.Sub(last) < viewDedupWindow {
+		return
+	}
+	c.seen[key] = ev.at
+	c.pending[viewBucket{kind: ev.kind, id: ev.id, day: ev.at.UTC().Format(viewDayLayout)}]++
+}
+
+// flush writes the pending counts and forgets visitors outside the window.
+func (c *viewCounter) flush(now time.Time) {
+	for key, at := range c.seen {
+		if now.Sub(at) >= viewDedupWindow {
+			delete(c.seen, key)
+		}
+	}
+	if len(c.pending) == 0 {
+		return
+	}
+	pending := c.pending
+	c.pending = make(map[viewBucket]int)
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[ANALYTICS] failed to begin view flush: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	for b, n := range pending {
+		query := `INSERT INTO storefront_views (storefront_id, day, views) VALUES (?, ?, ?)
+			ON CONFLICT(storefront_id, day) DO UPDATE SET views = views + excluded.views`
+		if b.kind == viewKindPack {
+			query = `INSERT INTO pack_views (listing_id, day, views) VALUES (?, ?, ?)
+				ON CONFLICT(listing_id, day) DO UPDATE SET views = views + excluded.views`
+		}
+		if _, err := tx.Exec(query, b.id, b.day, n); err != nil {
+			log.Printf("[ANALYTICS] failed to record %d %s views for %d: %v", n, b.kind, b.id, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ANALYTICS] failed to commit view flush: %v", err)
+	}
+}
+
+// startViewTracker runs the view counting loop in the background.
+func startViewTracker() {
+	go func() {
+		c := newViewCounter()
+		ticker := time.NewTicker(viewFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case ev := <-viewEvents:
+				c.add(ev)
+			case now := <-ticker.C:
+				c.flush(now)
+			}
+		}
+	}()
+}
+
+// AnalyticsDay is one day of storefront traffic.
+type AnalyticsDay struct {
+	Date       string `json:"date"`
+	StoreViews int    `json:"store_views"`
+	PackViews  int    `json:"pack_views"`
+	Purchases  int    `json:"purchases"`
+}
+
+// AnalyticsPack is the traffic of one pack over the whole range.
+type AnalyticsPack struct {
+	ListingID  int64   `json:"listing_id"`
+	PackName   string  `json:"pack_name"`
+	Views      int     `json:"views"`
+	Purchases  int     `json:"purchases"`
+	Conversion float64 `json:"conversion"` // purchases / views, 0 without views
+}
+
+// handleStorefrontAnalytics handles GET /user/storefront/analytics?days=N
+// (7, 30 or 90; default 30). Purchases are first-time acquisitions from
+// user_purchased_packs, free claims included. Overall conversion is purchases
+// per page view (store plus pack pages), since packs can be bought from the
+// store page without opening their detail page.
+func handleStorefrontAnalytics(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[ANALYTICS] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+	days := 30
+	switch r.URL.Query().Get("days") {
+	case "7":
+		days = 7
+	case "90":
+		days = 90
+	}
+
+	var storefrontID int64
+	if err := db.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID); err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
+		return
+	}
+
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -(days - 1)).Format(viewDayLayout)
+	series := make([]AnalyticsDay, days)
+	dayIndex := make(map[string]int, days)
+	for i := range series {
+		d := now.AddDate(0, 0, i-(days-1)).Format(viewDayLayout)
+		series[i].Date = d
+		dayIndex[d] = i
+	}
+
+	fail := func(what string, err error) {
+		log.Printf("[ANALYTICS] failed to query %s for user %d: %v", what, userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "加载失败"})
+	}
+
+	rows, err := db.Query("SELECT day, views FROM storefront_views WHERE storefront_id = ? AND day >= ?", storefrontID, since)
+	if err != nil {
+		fail("store views", err)
+		return
+	}
+	for rows.Next() {
+		var day string
+		var n int
+		if rows.Scan(&day, &n) == nil {
+			if i, ok := dayIndex[day]; ok {
+				series[i].StoreViews += n
+			}
+		}
+	}
+	rows.Close()
+
+	packs := make(map[int64]*AnalyticsPack)
+	rows, err = db.Query("SELECT id, pack_name FROM pack_listings WHERE user_id = ? AND status = 'published'", userID)
+	if err != nil {
+		fail("packs", err)
+		return
+	}
+	for rows.Next() {
+		p := &AnalyticsPack{}
+		if rows.Scan(&p.ListingID, &p.PackName) == nil {
+			packs[p.ListingID] = p
+		}
+	}
+	rows.Close()
+	packStat := func(listingID int64, name string) *AnalyticsPack {
+		p, ok := packs[listingID]
+		if !ok {
+			p = &AnalyticsPack{ListingID: listingID, PackName: name}
+			packs[listingID] = p
+		}
+		return p
+	}
+
+	rows, err = db.Query(`SELECT pv.listing_id, pl.pack_name, pv.day, pv.views FROM pack_views pv
+		JOIN pack_listings pl ON pl.id = pv.listing_id
+		WHERE pl.user_id = ? AND pv.day >= ?`, userID, since)
+	if err != nil {
+		fail("pack views", err)
+		return
+	}
+	for rows.Next() {
+		var listingID int64
+		var name, day string
+		var n int
+		if rows.Scan(&listingID, &name, &day, &n) == nil {
+			if i, ok := dayIndex[day]; ok {
+				series[i].PackViews += n
+			}
+			packStat(listingID, name).Views += n
+		}
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT upp.listing_id, pl.pack_name, DATE(upp.created_at), COUNT(*) FROM user_purchased_packs upp
+		JOIN pack_listings pl ON pl.id = upp.listing_id
+		WHERE pl.user_id = ? AND upp.created_at >= ?
+		GROUP BY upp.listing_id, DATE(upp.created_at)`, userID, since)
+	if err != nil {
+		fail("purchases", err)
+		return
+	}
+	for rows.Next() {
+		var listingID int64
+		var name, day string
+		var n int
+		if rows.Scan(&listingID, &name, &day, &n) == nil {
+			if i, ok := dayIndex[day]; ok {
+				series[i].Purchases += n
+			}
+			packStat(listingID, name).Purchases += n
+		}
+	}
+	rows.Close()
+
+	var totalStore, totalPack, totalPurchases int
+	for _, d := range series {
+		totalStore += d.StoreViews
+		totalPack += d.PackViews
+		totalPurchases += d.Purchases
+	}
+	packList := make([]AnalyticsPack, 0, len(packs))
+	for _, p := range packs {
+		if p.Views > 0 {
+			p.Conversion = float64(p.Purchases) / float64(p.Views)
+		}
+		packList = append(packList, *p)
+	}
+	sort.Slice(packList, func(i, j int) bool {
+		if packList[i].Views != packList[j].Views {
+			return packList[i].Views > packList[j].Views
+		}
+		return packList[i].ListingID < packList[j].ListingID
+	})
+	conversion := 0.0
+	if totalStore+totalPack > 0 {
+		conversion = float64(totalPurchases) / float64(totalStore+totalPack)
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"days":        days,
+		"daily":       series,
+		"packs":       packList,
+		"store_views": totalStore,
+		"pack_views":  totalPack,
+		"purchases":   totalPurchases,
+		"conversion":  conversion,
+	})
+}
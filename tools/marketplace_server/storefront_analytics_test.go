@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestViewCounterDedupAndFlush(t *testing.T) {
+	useTestDB(t)
+
+	now := time.Now()
+	c := newViewCounter()
+	c.add(viewEvent{kind: viewKindStore, id: 10, visitor: "a", at: now})
+	c.add(viewEvent{kind: viewKindStore, id: 10, visitor: "a", at: now.Add(time.Minute)}) // refresh
+	c.add(viewEvent{kind: viewKindStore, id: 10, visitor: "b", at: now})
+	c.add(viewEvent{kind: viewKindPack, id: 100, visitor: "a", at: now})
+	c.flush(now.Add(time.Minute))
+
+	c.add(viewEvent{kind: viewKindStore, id: 10, visitor: "a", at: now.Add(viewDedupWindow + time.Minute)})
+	c.flush(now.Add(viewDedupWindow + time.Minute))
+
+	day := now.UTC().Format(viewDayLayout)
+	var storeViews, packViews int
+	db.QueryRow("SELECT views FROM storefront_views WHERE storefront_id = 10 AND day = ?", day).Scan(&storeViews)
+	db.QueryRow("SELECT views FROM pack_views WHERE listing_id = 100 AND day = ?", day).Scan(&packViews)
+	if storeViews != 3 || packViews != 1 {
+		t.Errorf("store views = %d, pack views = %d, want 3 and 1", storeViews, packViews)
+	}
+}
+
+func TestStorefrontAnalytics(t *testing.T) {
+	useTestDB(t)
+
+	today := time.Now().UTC().Format(viewDayLayout)
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 1, 1, x'00', 'Viewed', 'free', 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (101, 1, 1, x'00', 'Quiet', 'free', 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (200, 2, 1, x'00', 'Other author', 'free', 'published')")
+	mustExec(t, "INSERT INTO storefront_views (storefront_id, day, views) VALUES (10, ?, 6), (10, '2000-01-01', 50)", today)
+	mustExec(t, "INSERT INTO pack_views (listing_id, day, views) VALUES (100, ?, 4), (200, ?, 9)", today, today)
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (5, 100), (6, 100), (7, 200)")
+
+	req := httptest.NewRequest(http.MethodGet, "/user/storefront/analytics?days=7", nil)
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleStorefrontAnalytics(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Daily      []AnalyticsDay  `json:"daily"`
+		Packs      []AnalyticsPack `json:"packs"`
+		StoreViews int             `json:"store_views"`
+		PackViews  int             `json:"pack_views"`
+		Purchases  int             `json:"purchases"`
+		Conversion float64         `json:"conversion"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Daily) != 7 || resp.Daily[6].Date != today || resp.Daily[6].Purchases != 2 {
+		t.Errorf("daily series = %+v", resp.Daily)
+	}
+	if resp.StoreViews != 6 || resp.PackViews != 4 || resp.Purchases != 2 || resp.Conversion != 0.2 {
+		t.Errorf("totals = %d/%d/%d/%v, want 6/4/2/0.2", resp.StoreViews, resp.PackViews, resp.Purchases, resp.Conversion)
+	}
+	if len(resp.Packs) != 2 || resp.Packs[0].ListingID != 100 || resp.Packs[0].Conversion != 0.5 || resp.Packs[1].Views != 0 {
+		t.Errorf("packs = %+v", resp.Packs)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxStoreAnnouncementLen is the longest announcement, in characters.
+const maxStoreAnnouncementLen = 200
+
+// handleStorefrontSetAnnouncement handles POST /user/storefront/announcement.
+// It stores the owner's notice ("announcement") and whether it is shown
+// ("active"=1). The notice is independent of the layout sections, so setting
+// or clearing it never touches layout_config.
+func handleStorefrontSetAnnouncement(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[STOREFRONT-ANNOUNCEMENT] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	text := strings.TrimSpace(r.FormValue("announcement"))
+	active := 0
+	if v := r.FormValue("active"); v == "1" || v == "true" {
+		active = 1
+	}
+	if utf8.RuneCountInString(text) > maxStoreAnnouncementLen {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "公告内容不能超过 200 个字符"})
+		return
+	}
+	if active == 1 && text == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "公告内容不能为空"})
+		return
+	}
+
+	result, err := db.Exec(`UPDATE author_storefronts SET store_announcement = ?, announcement_active = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
+		text, active, userID)
+	if err != nil {
+		log.Printf("[STOREFRONT-ANNOUNCEMENT] failed to update announcement for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
+		return
+	}
+
+	var slug, publicID string
+	if err := db.QueryRow("SELECT store_slug, COALESCE(public_id, '') FROM author_storefronts WHERE user_id = ?", userID).Scan(&slug, &publicID); err == nil {
+		globalCache.InvalidateStorefront(slug)
+		if publicID != "" {
+			globalCache.InvalidateStorefront(publicID)
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "announcement": text, "announcement_active": active == 1})
+}
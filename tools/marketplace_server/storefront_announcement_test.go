@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStorefrontAnnouncement(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	if _, err := db.Exec("INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id, layout_config) VALUES (10, 1, 'Shop', 'shop', 'pub10', '')"); err != nil {
+		t.Fatal(err)
+	}
+
+	set := func(text, active string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/user/storefront/announcement", strings.NewReader(url.Values{"announcement": {text}, "active": {active}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		handleStorefrontSetAnnouncement(rec, req)
+		return rec.Code
+	}
+	page := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handleStorefrontRoutes(rec, httptest.NewRequest(http.MethodGet, "/store/pub10", nil))
+		return rec.Body.String()
+	}
+
+	page() // warm the cache
+	if code := set("Back on Monday", "1"); code != http.StatusOK {
+		t.Fatalf("set: status %d", code)
+	}
+	if !strings.Contains(page(), "Back on Monday") {
+		t.Errorf("active announcement not rendered")
+	}
+	if code := set("", "1"); code != http.StatusBadRequest {
+		t.Errorf("empty active announcement: status %d, want 400", code)
+	}
+	if code := set(strings.Repeat("字", maxStoreAnnouncementLen+1), "0"); code != http.StatusBadRequest {
+		t.Errorf("overlong announcement: status %d, want 400", code)
+	}
+	if code := set("", "0"); code != http.StatusOK {
+		t.Fatalf("clear: status %d", code)
+	}
+	if strings.Contains(page(), "Back on Monday") {
+		t.Errorf("cleared announcement still rendered")
+	}
+
+	// The announcement never touches the layout.
+	var layout string
+	db.QueryRow("SELECT COALESCE(layout_config, '') FROM author_storefronts WHERE id = 10").Scan(&layout)
+	if layout != "" {
+		t.Errorf("layout_config changed to %q", layout)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// storefrontDesignVersion is written into exported designs so future formats
+// can be told apart on import.
+const storefrontDesignVersion = 1
+
+// maxStorefrontDesignSize caps the body accepted by the design import.
+const maxStorefrontDesignSize = 64 * 1024
+
+// StorefrontDesign is the portable form of a storefront's look: the section
+// layout, the theme and, for theme "custom", its colors. It carries no
+// references to the exporting store's packs or uploaded images.
+type StorefrontDesign struct {
+	Version      int                `json:"version"`
+	LayoutConfig LayoutConfig       `json:"layout_config"`
+	Theme        string             `json:"theme"`
+	CustomTheme  *CustomThemeColors `json:"custom_theme,omitempty"`
+}
+
+// portableLayoutConfig returns a copy of config with store-specific
+// references removed: banner image ids point at the owner's uploads, so
+// custom banners keep only their text, style and schedule.
+func portableLayoutConfig(config LayoutConfig) LayoutConfig {
+	out := LayoutConfig{Sections: make([]SectionConfig, 0, len(config.Sections))}
+	for _, section := range config.Sections {
+		if section.Type == "custom_banner" && len(section.Settings) > 0 {
+			var bs CustomBannerSettings
+			if err := json.Unmarshal(section.Settings, &bs); err == nil {
+				bs.ImageID = 0
+				section.Settings, _ = json.Marshal(bs)
+			}
+		}
+		if len(section.Settings) == 0 {
+			section.Settings = json.RawMessage("{}")
+		}
+		out.Sections = append(out.Sections, section)
+	}
+	return out
+}
+
+// handleStorefrontDesignExport handles GET /user/storefront/design/export and
+// downloads the owner's layout and theme as a JSON file.
+func handleStorefrontDesignExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "未登录"})
+		return
+	}
+
+	var slug string
+	var layoutRaw, themeRaw sql.NullString
+	var customThemeRaw string
+	err = db.QueryRow(`SELECT store_slug, layout_config, theme, COALESCE(custom_theme, '')
+		FROM author_storefronts WHERE user_id = ?`, userID).Scan(&slug, &layoutRaw, &themeRaw, &customThemeRaw)
+	if err == sql.ErrNoRows {
+		jsonResponse(w, http.StatusNotFound, map[string]interface{}{"ok": false, "error": "小铺不存在"})
+		return
+	} else if err != nil {
+		log.Printf("[STOREFRONT-DESIGN] failed to load design for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "导出失败"})
+		return
+	}
+
+	design := StorefrontDesign{Version: storefrontDesignVersion, LayoutConfig: DefaultLayoutConfig(), Theme: "default"}
+	if layoutRaw.Valid && layoutRaw.String != "" {
+		if config, err := ParseLayoutConfig(layoutRaw.String); err == nil && len(config.Sections) > 0 {
+			design.LayoutConfig = config
+		}
+	}
+	design.LayoutConfig = portableLayoutConfig(design.LayoutConfig)
+	if ValidThemes[themeRaw.String] {
+		design.Theme = themeRaw.String
+	} else if themeRaw.String == "custom" {
+		if colors, ok := ParseCustomThemeColors(customThemeRaw); ok {
+			design.Theme = "custom"
+			design.CustomTheme = &colors
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="storefront-design-%s-%s.json"`, slug, time.Now().Format("20060102")))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(design)
+}
+
+// handleStorefrontDesignImport handles POST /user/storefront/design/import
+// with an exported design as the JSON body. The layout must pass
+// ValidateLayoutConfig and the theme must be one of ValidThemes (or "custom"
+// with valid colors); banner image references are dropped.
+func handleStorefrontDesignImport(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "未登录"})
+		return
+	}
+
+	var design StorefrontDesign
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStorefrontDesignSize)).Decode(&design); err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "装修模板文件格式无效"})
+		return
+	}
+	if design.Version != storefrontDesignVersion {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": "不支持的装修模板版本"})
+		return
+	}
+
+	layoutConfig, err := SerializeLayoutConfig(portableLayoutConfig(design.LayoutConfig))
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "装修模板文件格式无效"})
+		return
+	}
+	if errMsg := ValidateLayoutConfig(layoutConfig); errMsg != "" {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": errMsg})
+		return
+	}
+
+	customTheme := ""
+	if design.Theme == "custom" {
+		if design.CustomTheme == nil {
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": "自定义主题缺少配色"})
+			return
+		}
+		if errMsg := ValidateCustomThemeColors(*design.CustomTheme); errMsg != "" {
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": errMsg})
+			return
+		}
+		customJSON, _ := json.Marshal(design.CustomTheme.expanded())
+		customTheme = string(customJSON)
+	} else if !ValidThemes[design.Theme] {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": false, "error": "不支持的主题"})
+		return
+	}
+
+	// Keep the store's own colors when the imported theme is a preset.
+	result, err := db.Exec(`UPDATE author_storefronts SET layout_config = ?, store_layout = 'custom', theme = ?,
+		custom_theme = CASE WHEN ? = 'custom' THEN ? ELSE custom_theme END, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ?`, layoutConfig, design.Theme, design.Theme, customTheme, userID)
+	if err != nil {
+		log.Printf("[STOREFRONT-DESIGN] failed to import design for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "保存失败"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		jsonResponse(w, http.StatusNotFound, map[string]interface{}{"ok": false, "error": "小铺不存在"})
+		return
+	}
+
+	var slug string
+	if err := db.QueryRow("SELECT store_slug FROM author_storefronts WHERE user_id = ?", userID).Scan(&slug); err == nil {
+		globalCache.InvalidateStorefront(slug)
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+}
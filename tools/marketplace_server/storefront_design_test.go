@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStorefrontDesignExportImport(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	layout := `{"sections":[{"type":"hero","visible":true,"settings":{}},` +
+		`{"type":"custom_banner","visible":true,"settings":{"text":"Sale","style":"info","image_id":7}},` +
+		`{"type":"pack_grid","visible":true,"settings":{"columns":3}}]}`
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'a', 'A'), (2, 'sn', 'b', 'B')")
+	mustExec(t, `INSERT INTO author_storefronts (id, user_id, store_name, store_slug, layout_config, theme, custom_theme)
+		VALUES (10, 1, 'Shop', 'shop', ?, 'custom', '{"primary_color":"#112233","accent_color":"#445566","hero_start":"#778899","hero_end":"#aabbcc"}')`, layout)
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (11, 2, 'Other', 'other')")
+
+	req := httptest.NewRequest(http.MethodGet, "/user/storefront/design/export", nil)
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleStorefrontDesignExport(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: status %d", rec.Code)
+	}
+	exported := rec.Body.String()
+	if strings.Contains(exported, "image_id") {
+		t.Fatalf("export kept a banner image reference: %s", exported)
+	}
+
+	importDesign := func(body string) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/user/storefront/design/import", strings.NewReader(body))
+		req.Header.Set("X-User-ID", "2")
+		rec := httptest.NewRecorder()
+		handleStorefrontDesignImport(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+
+	if resp := importDesign(exported); resp["ok"] != true {
+		t.Fatalf("import: %v", resp)
+	}
+	var gotLayout, gotTheme, gotCustom string
+	db.QueryRow("SELECT layout_config, theme, custom_theme FROM author_storefronts WHERE id = 11").Scan(&gotLayout, &gotTheme, &gotCustom)
+	config, _ := ParseLayoutConfig(gotLayout)
+	if len(config.Sections) != 3 || gotTheme != "custom" || !strings.Contains(gotCustom, "#112233") {
+		t.Fatalf("imported layout %s theme %q custom %q", gotLayout, gotTheme, gotCustom)
+	}
+
+	bad := []string{
+		strings.Replace(exported, `"custom"`, `"neon"`, 1),
+		`{"version":1,"layout_config":{"sections":[{"type":"pack_grid","visible":true}]},"theme":"default"}`,
+	}
+	for _, body := range bad {
+		if resp := importDesign(body); resp["ok"] != false {
+			t.Errorf("import of %s accepted", body)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Live sales stream. Purchase and payment handlers publish a StoreEvent after
+// their transaction commits; GET /user/storefront/events relays the events of
+// the owner's own storefront as Server-Sent Events. Delivery is best effort:
+// a subscriber that falls behind loses events rather than slowing the buyer's
+// request down.
+const (
+	storeEventBuffer       = 16
+	storeEventHeartbeat    = 25 * time.Second
+	maxStoreEventStreams   = 5 // per storefront, e.g. a few open tabs
+	storeEventTypePackSale = "pack_sale"
+	storeEventTypeOrder    = "custom_order"
+)
+
+// StoreEvent is one sale pushed to the storefront owner.
+type StoreEvent struct {
+	Type            string  `json:"type"`
+	ListingID       int64   `json:"listing_id,omitempty"`
+	PackName        string  `json:"pack_name,omitempty"`
+	TransactionType string  `json:"transaction_type,omitempty"`
+	Credits         float64 `json:"credits,omitempty"`
+	OrderID         int64   `json:"order_id,omitempty"`
+	ProductName     string  `json:"product_name,omitempty"`
+	Amount          float64 `json:"amount,omitempty"`
+	Currency        string  `json:"currency,omitempty"`
+	Status          string  `json:"status,omitempty"`
+	CreatedAt       string  `json:"created_at"`
+}
+
+// storeEventHub fans events out to the subscribers of each storefront.
+type storeEventHub struct {
+	mu   sync.Mutex
+	subs map[int64]map[chan StoreEvent]struct{}
+}
+
+var storeEvents = &storeEventHub{subs: make(map[int64]map[chan StoreEvent]struct{})}
+
+// subscribe registers a listener for storefrontID. ok is false when the
+// storefront already has maxStoreEventStreams listeners. The returned
+// function must be called to unsubscribe.
+func (h *storeEventHub) subscribe(storefrontID int64) (ch chan StoreEvent, unsubscribe func(), ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs[storefrontID]) >= maxStoreEventStreams {
+		return nil, nil, false
+	}
+	ch = make(chan StoreEvent, storeEventBuffer)
+	if h.subs[storefrontID] == nil {
+		h.subs[storefrontID] = make(map[chan StoreEvent]struct{})
+	}
+	h.subs[storefrontID][ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[storefrontID], ch)
+		if len(h.subs[storefrontID]) == 0 {
+			delete(h.subs, storefrontID)
+		}
+	}, true
+}
+
+// publish delivers ev to the current listeners of storefrontID without blocking.
+func (h *storeEventHub) publish(storefrontID int64, ev StoreEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[storefrontID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// hasSubscribers reports whether anyone is listening to storefrontID.
+func (h *storeEventHub) hasSubscribers(storefrontID int64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[storefrontID]) > 0
+}
+
+// publishPackSaleEvent notifies the storefront of the pack's author about a
+// committed credits_transactions sale of the given credits.
+func publishPackSaleEvent(listingID int64, transactionType string, credits float64) {
+	var storefrontID int64
+	var packName string
+	err := db.QueryRow(`SELECT s.id, pl.pack_name FROM pack_listings pl
+		JOIN author_storefronts s ON s.user_id = pl.user_id
+		WHERE pl.id = ?`, listingID).Scan(&storefrontID, &packName)
+	if err != nil || !storeEvents.hasSubscribers(storefrontID) {
+		return
+	}
+	storeEvents.publish(storefrontID, StoreEvent{
+		Type:            storeEventTypePackSale,
+		ListingID:       listingID,
+		PackName:        packName,
+		TransactionType: transactionType,
+		Credits:         credits,
+		CreatedAt:       time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// publishCustomOrderEvent notifies the storefront selling a custom product
+// about an order's current status.
+func publishCustomOrderEvent(orderID int64) {
+	var ev StoreEvent
+	var storefrontID int64
+	err := db.QueryRow(`SELECT p.storefront_id, o.id, p.product_name, COALESCE(o.charged_amount, o.amount_usd),
+		COALESCE(o.charged_currency, 'USD'), o.status
+		FROM custom_product_orders o JOIN custom_products p ON p.id = o.custom_product_id
+		WHERE o.id = ?`, orderID).Scan(&storefrontID, &ev.OrderID, &ev.ProductName, &ev.Amount, &ev.Currency, &ev.Status)
+	if err != nil {
+		log.Printf("[STORE-EVENTS] failed to load order %d: %v", orderID, err)
+		return
+	}
+	ev.Type = storeEventTypeOrder
+	ev.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	storeEvents.publish(storefrontID, ev)
+}
+
+// handleStorefrontEvents handles GET /user/storefront/events, a Server-Sent
+// Events stream of the caller's own storefront sales. A comment line is sent
+// every storeEventHeartbeat so proxies keep the connection open.
+func handleStorefrontEvents(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[STORE-EVENTS] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	var storefrontID int64
+	if err := db.QueryRow("SELECT id FROM author_storefronts WHERE user_id = ?", userID).Scan(&storefrontID); err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
+		return
+	}
+
+	events, unsubscribe, ok := storeEvents.subscribe(storefrontID)
+	if !ok {
+		jsonResponse(w, http.StatusTooManyRequests, map[string]string{"error": "打开的实时连接过多，请关闭其他页面后重试"})
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 5000\n: connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(storeEventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
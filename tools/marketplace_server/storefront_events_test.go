@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorefrontEventsStream(t *testing.T) {
+	useTestDB(t)
+
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Mine', 'mine')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (11, 2, 'Other', 'other')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 1, 1, x'00', 'My pack', 'per_use', 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (200, 2, 1, x'00', 'Their pack', 'per_use', 'published')")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("X-User-ID", "1")
+		handleStorefrontEvents(w, r)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+	next := func() string {
+		t.Helper()
+		for {
+			select {
+			case l, ok := <-lines:
+				if !ok {
+					t.Fatal("stream closed")
+				}
+				if strings.HasPrefix(l, "data: ") {
+					return l
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for an event")
+			}
+		}
+	}
+
+	// Wait until the handler has subscribed.
+	for i := 0; !storeEvents.hasSubscribers(10); i++ {
+		if i > 200 {
+			t.Fatal("handler did not subscribe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	publishPackSaleEvent(200, "purchase", 99) // another author's store
+	publishPackSaleEvent(100, "purchase", 12)
+	if got := next(); !strings.Contains(got, `"pack_name":"My pack"`) || !strings.Contains(got, `"credits":12`) {
+		t.Errorf("first event = %s", got)
+	}
+
+	cancel()
+	for i := 0; storeEvents.hasSubscribers(10); i++ {
+		if i > 200 {
+			t.Fatal("subscriber not removed after disconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStoreEventHubLimitsStreams(t *testing.T) {
+	hub := &storeEventHub{subs: make(map[int64]map[chan StoreEvent]struct{})}
+	var unsubs []func()
+	for i := 0; i < maxStoreEventStreams; i++ {
+		_, unsub, ok := hub.subscribe(1)
+		if !ok {
+			t.Fatalf("subscription %d rejected", i)
+		}
+		unsubs = append(unsubs, unsub)
+	}
+	if _, _, ok := hub.subscribe(1); ok {
+		t.Error("subscription over the limit accepted")
+	}
+	unsubs[0]()
+	if _, _, ok := hub.subscribe(1); !ok {
+		t.Error("subscription rejected after a slot was freed")
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"marketplace_server/i18n"
+)
+
+// FollowedStorefront is a followed store as returned by GET /user/follows.
+type FollowedStorefront struct {
+	StorefrontID  int64  `json:"storefront_id"`
+	PublicID      string `json:"public_id"`
+	StoreName     string `json:"store_name"`
+	StoreSlug     string `json:"store_slug"`
+	HasLogo       bool   `json:"has_logo"`
+	FollowerCount int    `json:"follower_count"`
+	FollowedAt    string `json:"followed_at"`
+}
+
+// storefrontFollowerCount returns how many users follow a storefront.
+func storefrontFollowerCount(storefrontID int64) int {
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM storefront_followers WHERE storefront_id = ?", storefrontID).Scan(&n)
+	return n
+}
+
+// isFollowingStorefront reports whether userID follows the storefront.
+func isFollowingStorefront(userID, storefrontID int64) bool {
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM storefront_followers WHERE storefront_id = ? AND user_id = ?", storefrontID, userID).Scan(&n)
+	return n > 0
+}
+
+// handleStorefrontFollows handles the authenticated user's followed stores.
+//
+//	GET    /user/follows                    list followed storefronts
+//	POST   /user/follows                    {"storefront_id": N} follow a store
+//	DELETE /user/follows?storefront_id=N    unfollow a store
+func handleStorefrontFollows(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := db.Query(`
+			SELECT sf.id, COALESCE(sf.public_id, ''), COALESCE(sf.store_name, ''), COALESCE(sf.store_slug, ''),
+			       CASE WHEN sf.logo_data IS NOT NULL THEN 1 ELSE 0 END,
+			       (SELECT COUNT(*) FROM storefront_followers c WHERE c.storefront_id = sf.id),
+			       f.created_at
+			FROM storefront_followers f
+			JOIN author_storefronts sf ON sf.id = f.storefront_id
+			WHERE f.user_id = ?
+			ORDER BY f.created_at DESC, f.id DESC`, userID)
+		if err != nil {
+			log.Printf("[STOREFRONT-FOLLOW] failed to list follows for user %d: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		defer rows.Close()
+		stores := []FollowedStorefront{}
+		for rows.Next() {
+			var fs FollowedStorefront
+			if err := rows.Scan(&fs.StorefrontID, &fs.PublicID, &fs.StoreName, &fs.StoreSlug,
+				&fs.HasLogo, &fs.FollowerCount, &fs.FollowedAt); err != nil {
+				log.Printf("[STOREFRONT-FOLLOW] scan error: %v", err)
+				continue
+			}
+			stores = append(stores, fs)
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("[STOREFRONT-FOLLOW] rows iteration error: %v", err)
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"stores": stores, "count": len(stores)})
+
+	case http.MethodPost:
+		var req struct {
+			StorefrontID int64 `json:"storefront_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.StorefrontID <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "storefront_id must be positive"})
+			return
+		}
+		var ownerID int64
+		err := db.QueryRow("SELECT user_id FROM author_storefronts WHERE id = ?", req.StorefrontID).Scan(&ownerID)
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "store not found"})
+			return
+		} else if err != nil {
+			log.Printf("[STOREFRONT-FOLLOW] failed to query storefront %d: %v", req.StorefrontID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if ownerID == userID {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "cannot follow your own store"})
+			return
+		}
+		if _, err := db.Exec("INSERT OR IGNORE INTO storefront_followers (storefront_id, user_id) VALUES (?, ?)", req.StorefrontID, userID); err != nil {
+			log.Printf("[STOREFRONT-FOLLOW] failed to follow storefront %d for user %d: %v", req.StorefrontID, userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"success": true, "following": true, "follower_count": storefrontFollowerCount(req.StorefrontID),
+		})
+
+	case http.MethodDelete:
+		storefrontID, err := strconv.ParseInt(r.URL.Query().Get("storefront_id"), 10, 64)
+		if err != nil || storefrontID <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "storefront_id must be positive"})
+			return
+		}
+		if _, err := db.Exec("DELETE FROM storefront_followers WHERE storefront_id = ? AND user_id = ?", storefrontID, userID); err != nil {
+			log.Printf("[STOREFRONT-FOLLOW] failed to unfollow storefront %d for user %d: %v", storefrontID, userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"success": true, "following": false, "follower_count": storefrontFollowerCount(storefrontID),
+		})
+
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// loadStorefrontFollowerRecipients returns the followers of a storefront who
+// have an email address, allow email and have not unsubscribed from the store.
+func loadStorefrontFollowerRecipients(storefrontID int64) ([]storefrontRecipient, error) {
+	rows, err := db.Query(`
+		SELECT u.id, u.email
+		FROM storefront_followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.storefront_id = ? AND u.email IS NOT NULL AND u.email != ''
+		  AND COALESCE(u.email_allowed, 1) = 1
+		  AND NOT EXISTS (
+			SELECT 1 FROM storefront_email_suppressions s
+			WHERE s.storefront_id = f.storefront_id AND s.email = LOWER(TRIM(u.email)))`, storefrontID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recipients []storefrontRecipient
+	for rows.Next() {
+		var rc storefrontRecipient
+		if err := rows.Scan(&rc.UserID, &rc.Email); err != nil {
+			log.Printf("[STOREFRONT-FOLLOW-NOTIFY] failed to scan recipient row: %v", err)
+			continue
+		}
+		recipients = append(recipients, rc)
+	}
+	return recipients, rows.Err()
+}
+
+// notifyStorefrontFollowers emails the followers of the author's storefront
+// that listingID is now available there. It does nothing unless the pack is
+// published and shown on the storefront (auto-add or added by hand), and each
+// pack is announced at most once per storefront. Links, including the signed
+// unsubscribe link, use public_base_url; nothing is sent while it is unset.
+// Errors are logged only.
+func notifyStorefrontFollowers(listingID int64) {
+	var authorID int64
+	var packName, shareToken, status string
+	err := db.QueryRow(`SELECT user_id, pack_name, COALESCE(share_token, ''), status FROM pack_listings WHERE id = ?`,
+		listingID).Scan(&authorID, &packName, &shareToken, &status)
+	if err != nil {
+		log.Printf("[STOREFRONT-FOLLOW-NOTIFY] failed to load listing %d: %v", listingID, err)
+		return
+	}
+	if status != "published" {
+		return
+	}
+
+	var storefrontID int64
+	var storeName, storeSlug, publicID string
+	var autoAdd int
+	err = db.QueryRow(`SELECT id, COALESCE(store_name, ''), COALESCE(store_slug, ''), COALESCE(public_id, ''), auto_add_enabled
+		FROM author_storefronts WHERE user_id = ?`, authorID).Scan(&storefrontID, &storeName, &storeSlug, &publicID, &autoAdd)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[STOREFRONT-FOLLOW-NOTIFY] failed to load storefront of user %d: %v", authorID, err)
+		}
+		return
+	}
+	if autoAdd == 0 {
+		var inStore int
+		db.QueryRow("SELECT COUNT(*) FROM storefront_packs WHERE storefront_id = ? AND pack_listing_id = ?", storefrontID, listingID).Scan(&inStore)
+		if inStore == 0 {
+			return
+		}
+	}
+
+	recipients, err := loadStorefrontFollowerRecipients(storefrontID)
+	if err != nil {
+		log.Printf("[STOREFRONT-FOLLOW-NOTIFY] failed to query followers of storefront %d: %v", storefrontID, err)
+		return
+	}
+	if len(recipients) == 0 {
+		return
+	}
+	smtpConfig, err := loadSMTPConfig()
+	if err != nil {
+		log.Printf("[STOREFRONT-FOLLOW-NOTIFY] not notifying followers of storefront %d: %v", storefrontID, err)
+		return
+	}
+	baseURL, ok := siteBaseURL()
+	if !ok {
+		log.Printf("[STOREFRONT-FOLLOW-NOTIFY] not notifying followers of storefront %d: public_base_url is not set", storefrontID)
+		return
+	}
+
+	// Claim the announcement so a re-add or re-approval does not email twice.
+	res, err := db.Exec("INSERT OR IGNORE INTO storefront_follow_notifications (storefront_id, listing_id) VALUES (?, ?)", storefrontID, listingID)
+	if err != nil {
+		log.Printf("[STOREFRONT-FOLLOW-NOTIFY] failed to record notification for storefront %d, listing %d: %v", storefrontID, listingID, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return
+	}
+
+	fromHeader := smtpConfig.FromEmail
+	senderName := storeName
+	if senderName == "" {
+		senderName = smtpConfig.FromName
+	}
+	if senderName != "" {
+		fromHeader = fmt.Sprintf("%s <%s>", senderName, smtpConfig.FromEmail)
+	}
+	storeRef := storeSlug
+	if storeRef == "" {
+		storeRef = publicID
+	}
+	storeURL := fmt.Sprintf("%s/store/%s", baseURL, storeRef)
+	packURL := fmt.Sprintf("%s/pack/%s", baseURL, shareToken)
+	stripCRLF := strings.NewReplacer("\r", "", "\n", "")
+
+	msgs := make([]smtpMessage, 0, len(recipients))
+	for _, rcpt := range recipients {
+		lang := userLang(rcpt.UserID)
+		unsubscribeURL := storefrontUnsubscribeURL(baseURL, storefrontID, rcpt.Email)
+		var msg bytes.Buffer
+		msg.WriteString(fmt.Sprintf("From: %s\r\n", fromHeader))
+		msg.WriteString(fmt.Sprintf("To: %s\r\n", rcpt.Email))
+		msg.WriteString(fmt.Sprintf("Subject: %s\r\n", stripCRLF.Replace(fmt.Sprintf(i18n.T(lang, "follow_email_subject"), storeName, packName))))
+		msg.WriteString(fmt.Sprintf("List-Unsubscribe: <%s>\r\n", unsubscribeURL))
+		msg.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+		msg.WriteString("MIME-Version: 1.0\r\n")
+		msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		msg.WriteString("\r\n")
+		msg.WriteString(fmt.Sprintf(i18n.T(lang, "follow_email_body"), storeName, packName, packURL))
+		msg.WriteString(fmt.Sprintf(i18n.T(lang, "storefront_email_visit_store"), storeURL))
+		msg.WriteString(fmt.Sprintf(i18n.T(lang, "unsubscribe_email_footer"), unsubscribeURL))
+		msgs = append(msgs, smtpMessage{To: rcpt.Email, Data: msg.Bytes()})
+	}
+
+	sender := newSMTPSender(smtpConfig)
+	result := sender.sendBatch(msgs)
+	sender.Close()
+	for to, sendErr := range result.Errors {
+		log.Printf("[STOREFRONT-FOLLOW-NOTIFY] failed to send email to %s: %v", to, sendErr)
+	}
+	log.Printf("[STOREFRONT-FOLLOW-NOTIFY] storefront %d, listing %d: sent %d/%d emails", storefrontID, listingID, result.Sent, len(recipients))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStorefrontFollowAndRecipients(t *testing.T) {
+	useTestDB(t)
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'sn', 'author', 'Author', 'author@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'alice', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, email_allowed) VALUES (3, 'sn', 'bob', 'Bob', 'bob@example.com', 0)")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (4, 'sn', 'carol', 'Carol', 'Carol@Example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')")
+
+	call := func(userID, method, target, body string) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-User-ID", userID)
+		rec := httptest.NewRecorder()
+		handleStorefrontFollows(rec, req)
+		var out map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &out)
+		out["status"] = float64(rec.Code)
+		return out
+	}
+
+	for _, uid := range []string{"2", "3", "4"} {
+		call(uid, http.MethodPost, "/user/follows", `{"storefront_id":10}`)
+	}
+	if out := call("2", http.MethodPost, "/user/follows", `{"storefront_id":10}`); out["follower_count"] != float64(3) {
+		t.Fatalf("following twice: follower_count = %v", out["follower_count"])
+	}
+	if out := call("1", http.MethodPost, "/user/follows", `{"storefront_id":10}`); out["status"] != float64(http.StatusBadRequest) {
+		t.Fatalf("author following own store: status %v", out["status"])
+	}
+	out := call("2", http.MethodGet, "/user/follows", "")
+	if stores, _ := out["stores"].([]interface{}); len(stores) != 1 {
+		t.Fatalf("followed stores = %v", out)
+	}
+
+	// Bob opted out of email and Carol unsubscribed from the store.
+	mustExec(t, "INSERT INTO storefront_email_suppressions (storefront_id, email) VALUES (10, 'carol@example.com')")
+	recipients, err := loadStorefrontFollowerRecipients(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 1 || recipients[0].UserID != 2 {
+		t.Errorf("recipients = %+v, want only Alice", recipients)
+	}
+
+	if out := call("2", http.MethodDelete, "/user/follows?storefront_id=10", ""); out["follower_count"] != float64(2) {
+		t.Errorf("after unfollow: follower_count = %v", out["follower_count"])
+	}
+}
+
+// Follower emails carry a signed unsubscribe link, so their links come from
+// public_base_url only; without it nothing is sent and nothing is claimed.
+func TestNotifyStorefrontFollowersUsesSiteBaseURL(t *testing.T) {
+	useTestDB(t)
+	server := newMockSMTPServer(t)
+	smtpJSON, _ := json.Marshal(server.config())
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('smtp_config', ?)", string(smtpJSON))
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'sn', 'author', 'Author', 'author@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'alice', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, auto_add_enabled) VALUES (10, 1, 'Shop', 'shop', 1)")
+	mustExec(t, "INSERT INTO storefront_followers (storefront_id, user_id) VALUES (10, 2)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status, share_token) VALUES (100, 1, 1, x'00', 'Pack', 'free', 'published', 'tok')")
+
+	notifyStorefrontFollowers(100)
+	var claimed int
+	db.QueryRow("SELECT COUNT(*) FROM storefront_follow_notifications WHERE listing_id = 100").Scan(&claimed)
+	server.mu.Lock()
+	sent := len(server.messages)
+	server.mu.Unlock()
+	if sent != 0 || claimed != 0 {
+		t.Fatalf("without public_base_url: %d emails sent, %d notifications claimed", sent, claimed)
+	}
+
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('public_base_url', 'https://market.example.com')")
+	notifyStorefrontFollowers(100)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(server.messages))
+	}
+	msg := server.messages[0]
+	for _, want := range []string{"https://market.example.com/pack/tok", "https://market.example.com/store/shop", "List-Unsubscribe: <https://market.example.com/"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("email lacks %q:\n%s", want, msg)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"marketplace_server/i18n"
+)
+
+// layoutPreset is a named, curated page layout an owner can apply in one click.
+type layoutPreset struct {
+	Key     string
+	NameKey string // i18n key of the display name
+	DescKey string // i18n key of the one-line description
+	Build   func() LayoutConfig
+}
+
+// layoutSection builds a SectionConfig with the given JSON settings.
+func layoutSection(sectionType string, visible bool, settings string) SectionConfig {
+	return SectionConfig{Type: sectionType, Visible: visible, Settings: json.RawMessage(settings)}
+}
+
+// MinimalLayoutConfig 极简布局：只保留 hero 和单列分析包列表
+func MinimalLayoutConfig() LayoutConfig {
+	return LayoutConfig{
+		Sections: []SectionConfig{
+			layoutSection("hero", true, "{}"),
+			layoutSection("featured", false, "{}"),
+			layoutSection("filter_bar", false, "{}"),
+			layoutSection("pack_grid", true, `{"columns":1}`),
+		},
+	}
+}
+
+// ShowcaseLayoutConfig 橱窗布局：突出推荐区，随后是双列分析包列表
+func ShowcaseLayoutConfig() LayoutConfig {
+	return LayoutConfig{
+		Sections: []SectionConfig{
+			layoutSection("hero", true, "{}"),
+			layoutSection("featured", true, "{}"),
+			layoutSection("pack_grid", true, `{"columns":2}`),
+			layoutSection("filter_bar", false, "{}"),
+		},
+	}
+}
+
+// GridHeavyLayoutConfig 网格布局：筛选栏在前，三列分析包列表，隐藏推荐区
+func GridHeavyLayoutConfig() LayoutConfig {
+	return LayoutConfig{
+		Sections: []SectionConfig{
+			layoutSection("hero", true, "{}"),
+			layoutSection("filter_bar", true, "{}"),
+			layoutSection("pack_grid", true, `{"columns":3}`),
+			layoutSection("featured", false, "{}"),
+		},
+	}
+}
+
+// layoutPresets is the preset gallery, in display order.
+var layoutPresets = []layoutPreset{
+	{Key: "classic", NameKey: "sm_preset_classic", DescKey: "sm_preset_classic_desc", Build: DefaultLayoutConfig},
+	{Key: "minimal", NameKey: "sm_preset_minimal", DescKey: "sm_preset_minimal_desc", Build: MinimalLayoutConfig},
+	{Key: "showcase", NameKey: "sm_preset_showcase", DescKey: "sm_preset_showcase_desc", Build: ShowcaseLayoutConfig},
+	{Key: "grid-heavy", NameKey: "sm_preset_grid_heavy", DescKey: "sm_preset_grid_heavy_desc", Build: GridHeavyLayoutConfig},
+}
+
+// findLayoutPreset returns the preset with the given key.
+func findLayoutPreset(key string) (layoutPreset, bool) {
+	for _, p := range layoutPresets {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return layoutPreset{}, false
+}
+
+// handleStorefrontLayoutPresets handles GET /user/storefront/layout-presets
+// and lists the preset gallery with localized names and the section layout
+// each preset produces.
+func handleStorefrontLayoutPresets(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.DetectLang(r)
+	presets := make([]map[string]interface{}, 0, len(layoutPresets))
+	for _, p := range layoutPresets {
+		presets = append(presets, map[string]interface{}{
+			"key":           p.Key,
+			"name":          i18n.T(lang, p.NameKey),
+			"description":   i18n.T(lang, p.DescKey),
+			"layout_config": p.Build(),
+		})
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "presets": presets})
+}
+
+// handleStorefrontApplyLayoutPreset handles POST /user/storefront/layout-presets/apply
+// with form field "preset". The preset replaces the page layout exactly like
+// saving it from the section editor would.
+func handleStorefrontApplyLayoutPreset(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "未登录"})
+		return
+	}
+
+	preset, ok := findLayoutPreset(r.FormValue("preset"))
+	if !ok {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": "布局模板不存在"})
+		return
+	}
+	layoutConfig, err := SerializeLayoutConfig(preset.Build())
+	if err != nil {
+		log.Printf("[STOREFRONT-PRESET] failed to serialize preset %s: %v", preset.Key, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "保存失败"})
+		return
+	}
+	if errMsg := ValidateLayoutConfig(layoutConfig); errMsg != "" {
+		log.Printf("[STOREFRONT-PRESET] preset %s is invalid: %s", preset.Key, errMsg)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": errMsg})
+		return
+	}
+
+	result, err := db.Exec(`UPDATE author_storefronts SET layout_config = ?, store_layout = 'custom', updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`, layoutConfig, userID)
+	if err != nil {
+		log.Printf("[STOREFRONT-PRESET] failed to apply preset %s for user %d: %v", preset.Key, userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": "保存失败"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		jsonResponse(w, http.StatusNotFound, map[string]interface{}{"ok": false, "error": "小铺不存在"})
+		return
+	}
+
+	var slug string
+	if err := db.QueryRow("SELECT store_slug FROM author_storefronts WHERE user_id = ?", userID).Scan(&slug); err == nil {
+		globalCache.InvalidateStorefront(slug)
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true, "layout_switched": true, "layout_config": preset.Build()})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutPresetsAreValid(t *testing.T) {
+	for _, key := range []string{"minimal", "showcase", "grid-heavy"} {
+		if _, ok := findLayoutPreset(key); !ok {
+			t.Errorf("preset %q missing", key)
+		}
+	}
+	for _, p := range layoutPresets {
+		config, err := SerializeLayoutConfig(p.Build())
+		if err != nil {
+			t.Fatalf("%s: %v", p.Key, err)
+		}
+		if errMsg := ValidateLayoutConfig(config); errMsg != "" {
+			t.Errorf("preset %s is invalid: %s", p.Key, errMsg)
+		}
+	}
+}
+
+func TestApplyLayoutPresetInvalidatesCache(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{StorefrontTTL: time.Hour})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	if _, err := db.Exec("INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'a', 'A')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')"); err != nil {
+		t.Fatal(err)
+	}
+	cacheKey := buildStorefrontCacheKey("shop", "", "revenue", "", "")
+	globalCache.SetStorefrontData(cacheKey, &StorefrontPublicData{})
+
+	apply := func(preset string) *httptest.ResponseRecorder {
+		form := url.Values{"preset": {preset}}
+		req := httptest.NewRequest(http.MethodPost, "/user/storefront/layout-presets/apply", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		handleStorefrontApplyLayoutPreset(rec, req)
+		return rec
+	}
+
+	if rec := apply("neon"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown preset: status %d", rec.Code)
+	}
+	if rec := apply("grid-heavy"); rec.Code != http.StatusOK {
+		t.Fatalf("apply: status %d body %s", rec.Code, rec.Body.String())
+	}
+
+	var layout, storeLayout string
+	db.QueryRow("SELECT layout_config, store_layout FROM author_storefronts WHERE id = 10").Scan(&layout, &storeLayout)
+	if storeLayout != "custom" || !strings.Contains(layout, `"columns":3`) {
+		t.Fatalf("saved layout %s (store_layout %q)", layout, storeLayout)
+	}
+	if _, ok := globalCache.GetStorefrontData(cacheKey); ok {
+		t.Error("storefront cache still holds the pre-preset page")
+	}
+}
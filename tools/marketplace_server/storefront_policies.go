@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"marketplace_server/i18n"
+	"marketplace_server/templates"
+)
+
+// maxStorePolicyLen is the longest policy text, in characters, per field.
+const maxStorePolicyLen = 5000
+
+// HasPolicies reports whether the store published any policy text, i.e.
+// whether its /store/{id}/policies page exists.
+func (s StorefrontInfo) HasPolicies() bool {
+	return s.RefundPolicy != "" || s.Terms != "" || s.Contact != ""
+}
+
+// handleStorefrontSavePolicies handles POST /user/storefront/policies with the
+// refund_policy, terms and contact fields. Any of them may be empty.
+func handleStorefrontSavePolicies(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[STOREFRONT-POLICIES] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	refund := strings.TrimSpace(r.FormValue("refund_policy"))
+	terms := strings.TrimSpace(r.FormValue("terms"))
+	contact := strings.TrimSpace(r.FormValue("contact"))
+	for _, v := range []string{refund, terms, contact} {
+		if utf8.RuneCountInString(v) > maxStorePolicyLen {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "每项政策内容不能超过 5000 个字符"})
+			return
+		}
+	}
+
+	result, err := db.Exec(`UPDATE author_storefronts SET refund_policy = ?, terms = ?, contact = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
+		refund, terms, contact, userID)
+	if err != nil {
+		log.Printf("[STOREFRONT-POLICIES] failed to update policies for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
+		return
+	}
+
+	// The store page footer links to the policies page only while it has content.
+	var slug, publicID string
+	if err := db.QueryRow("SELECT store_slug, COALESCE(public_id, '') FROM author_storefronts WHERE user_id = ?", userID).Scan(&slug, &publicID); err == nil {
+		globalCache.InvalidateStorefront(slug)
+		if publicID != "" {
+			globalCache.InvalidateStorefront(publicID)
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// handleStorefrontPolicies serves GET /store/{id}/policies. Stores without any
+// policy text have no policies page.
+func handleStorefrontPolicies(w http.ResponseWriter, r *http.Request, storeIdentifier string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	internalID, _, err := resolveStorefrontID(storeIdentifier)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var sf StorefrontInfo
+	err = db.QueryRow(`SELECT id, COALESCE(public_id, ''), store_name,
+		COALESCE(refund_policy, ''), COALESCE(terms, ''), COALESCE(contact, '')
+		FROM author_storefronts WHERE id = ?`, internalID).Scan(
+		&sf.ID, &sf.PublicID, &sf.StoreName, &sf.RefundPolicy, &sf.Terms, &sf.Contact)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[STOREFRONT-POLICIES] failed to load policies for store %d: %v", internalID, err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if !sf.HasPolicies() {
+		http.NotFound(w, r)
+		return
+	}
+	if sf.PublicID == "" {
+		sf.PublicID = strconv.FormatInt(sf.ID, 10)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := i18n.TemplateData(r)
+	i18n.MergeTemplateData(data, map[string]interface{}{
+		"Storefront": sf,
+	})
+	if err := templates.StorefrontPoliciesTmpl.Execute(w, data); err != nil {
+		log.Printf("[STOREFRONT-POLICIES] template execute error: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStorefrontPoliciesPage(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	if _, err := db.Exec("INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id) VALUES (10, 1, 'Shop', 'shop', 'pub10')"); err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleStorefrontRoutes(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	if rec := get("/store/pub10/policies"); rec.Code != http.StatusNotFound {
+		t.Fatalf("blank policies: status %d, want 404", rec.Code)
+	}
+	if strings.Contains(get("/store/pub10").Body.String(), "/store/pub10/policies") {
+		t.Errorf("footer links to a missing policies page")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/user/storefront/policies", strings.NewReader(url.Values{
+		"refund_policy": {"<script>alert(1)</script> 7 days"},
+		"contact":       {"help@example.com"},
+	}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleStorefrontSavePolicies(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("save: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = get("/store/shop/policies")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "help@example.com") {
+		t.Fatalf("policies page: status %d", rec.Code)
+	}
+	if strings.Contains(body, "<script>alert(1)") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("policy text not escaped")
+	}
+	if strings.Contains(body, `id="terms"`) {
+		t.Errorf("empty terms section rendered")
+	}
+	if !strings.Contains(get("/store/pub10").Body.String(), "/store/pub10/policies") {
+		t.Errorf("footer does not link to the policies page")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// slugHistoryPerStore is how many previous slugs are kept (and redirected)
+// per storefront. Older ones are dropped and become free for other stores.
+const slugHistoryPerStore = 10
+
+// slugTakenByOtherStore reports whether slug is the current or a previous
+// slug of a storefront other than storefrontID (0 matches every store).
+func slugTakenByOtherStore(slug string, storefrontID int64) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM author_storefronts WHERE store_slug = ? AND id != ?) +
+		(SELECT COUNT(*) FROM storefront_slug_history WHERE slug = ? AND storefront_id != ?)`,
+		slug, storefrontID, slug, storefrontID).Scan(&n)
+	return n > 0, err
+}
+
+// recordSlugChange keeps oldSlug as a redirect to storefrontID after its
+// slug changed to newSlug, and trims the store's history to
+// slugHistoryPerStore entries.
+func recordSlugChange(tx *sql.Tx, storefrontID int64, oldSlug, newSlug string) error {
+	// The new slug is current again, so it no longer needs a redirect.
+	if _, err := tx.Exec("DELETE FROM storefront_slug_history WHERE slug = ?", newSlug); err != nil {
+		return err
+	}
+	if oldSlug == "" || oldSlug == newSlug {
+		return nil
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO storefront_slug_history (storefront_id, slug) VALUES (?, ?)`,
+		storefrontID, oldSlug); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM storefront_slug_history WHERE storefront_id = ? AND id NOT IN (
+		SELECT id FROM storefront_slug_history WHERE storefront_id = ? ORDER BY id DESC LIMIT ?)`,
+		storefrontID, storefrontID, slugHistoryPerStore)
+	return err
+}
+
+// redirectHistoricalSlug sends a 301 to the store's current slug when
+// identifier is one of its previous slugs. rest is the path after the store
+// segment. It reports whether a redirect was written.
+func redirectHistoricalSlug(w http.ResponseWriter, r *http.Request, identifier, rest string) bool {
+	var currentSlug string
+	err := db.QueryRow(`SELECT sf.store_slug FROM storefront_slug_history h
+		JOIN author_storefronts sf ON sf.id = h.storefront_id
+		WHERE h.slug = ?`, identifier).Scan(&currentSlug)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[STOREFRONT-SLUG] failed to look up slug history for %q: %v", identifier, err)
+		}
+		return false
+	}
+	target := "/store/" + url.PathEscape(currentSlug)
+	if rest != "" {
+		target += "/" + rest
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStorefrontSlugChangeRedirects(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (2, 'sn', 'bob', 'Bob')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id) VALUES (10, 1, 'Shop', 'old-shop', 'pub10')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id) VALUES (11, 2, 'Other', 'other', 'pub11')")
+
+	setSlug := func(userID int64, slug string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/user/storefront/slug", strings.NewReader(url.Values{"slug": {slug}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", fmt.Sprint(userID))
+		rec := httptest.NewRecorder()
+		handleStorefrontUpdateSlug(rec, req)
+		return rec.Code
+	}
+
+	if code := setSlug(1, "new-shop"); code != http.StatusOK {
+		t.Fatalf("rename: status %d", code)
+	}
+	rec := httptest.NewRecorder()
+	handleStorefrontRoutes(rec, httptest.NewRequest(http.MethodGet, "/store/old-shop/feed.xml?x=1", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/store/new-shop/feed.xml?x=1" {
+		t.Fatalf("old slug: status %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// The old slug still points at Shop, so Bob cannot take it.
+	if code := setSlug(2, "old-shop"); code != http.StatusConflict {
+		t.Errorf("reusing another store's old slug: status %d, want 409", code)
+	}
+	// Shop can take it back, which drops the redirect.
+	if code := setSlug(1, "old-shop"); code != http.StatusOK {
+		t.Fatalf("rename back: status %d", code)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM storefront_slug_history WHERE slug = 'old-shop'").Scan(&n)
+	if n != 0 {
+		t.Errorf("current slug still in history")
+	}
+
+	// History is capped per store; the oldest slugs are released.
+	for i := 0; i < slugHistoryPerStore+2; i++ {
+		setSlug(1, fmt.Sprintf("shop-%d", i))
+	}
+	db.QueryRow("SELECT COUNT(*) FROM storefront_slug_history WHERE storefront_id = 10").Scan(&n)
+	if n != slugHistoryPerStore {
+		t.Errorf("history has %d entries, want %d", n, slugHistoryPerStore)
+	}
+	if code := setSlug(2, "old-shop"); code != http.StatusOK {
+		t.Errorf("taking a released slug: status %d", code)
+	}
+}
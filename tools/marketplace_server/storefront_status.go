@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Values of author_storefronts.store_status. A paused store stays reachable
+// (and indexable) but shows a closed banner, takes no purchases and is left
+// out of the homepage store and product lists. A deleted store belongs to a
+// deleted account and is no longer reachable at all.
+const (
+	storeStatusActive  = "active"
+	storeStatusPaused  = "paused"
+	storeStatusDeleted = "deleted"
+)
+
+// isStorefrontPaused reports whether the storefront is currently paused.
+func isStorefrontPaused(storefrontID int64) bool {
+	var status string
+	if err := db.QueryRow("SELECT COALESCE(store_status, 'active') FROM author_storefronts WHERE id = ?", storefrontID).Scan(&status); err != nil {
+		return false
+	}
+	return status == storeStatusPaused
+}
+
+// handleStorefrontSetStatus handles POST /user/storefront/status, letting the
+// owner pause ("paused"=1) or reopen ("paused"=0) their storefront.
+func handleStorefrontSetStatus(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[STOREFRONT-STATUS] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	status := storeStatusActive
+	if v := r.FormValue("paused"); v == "1" || v == "true" {
+		status = storeStatusPaused
+	}
+
+	result, err := db.Exec(`UPDATE author_storefronts SET store_status = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
+		status, userID)
+	if err != nil {
+		log.Printf("[STOREFRONT-STATUS] failed to update store_status for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
+		return
+	}
+
+	// Page cache entries are keyed by public_id; homepage lists hide paused stores.
+	var slug, publicID string
+	if err := db.QueryRow("SELECT store_slug, COALESCE(public_id, '') FROM author_storefronts WHERE user_id = ?", userID).Scan(&slug, &publicID); err == nil {
+		globalCache.InvalidateStorefront(slug)
+		if publicID != "" {
+			globalCache.InvalidateStorefront(publicID)
+		}
+	}
+	globalCache.InvalidateHomepage()
+
+	log.Printf("[STOREFRONT-STATUS] user %d set storefront status to %s", userID, status)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "store_status": status})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPausedStorefront(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug, public_id, auto_add_enabled) VALUES (10, 1, 'Shop', 'shop', 'pub10', 1)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, credits_price, status, share_token, download_count) VALUES (100, 1, 1, x'00', 'A', 'per_use', 5, 'published', 'tokA', 3)")
+
+	page := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handleStorefrontRoutes(rec, httptest.NewRequest(http.MethodGet, "/store/pub10", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("store page: status %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+	if body := page(); strings.Contains(body, `class="store-paused-banner"`) || !strings.Contains(body, "login_to_buy") {
+		t.Fatalf("active store rendered as paused")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/user/storefront/status", strings.NewReader(url.Values{"paused": {"1"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleStorefrontSetStatus(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("pause: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The page stays reachable but shows the banner instead of buy buttons.
+	body := page()
+	if !strings.Contains(body, `class="store-paused-banner"`) || strings.Contains(body, "login_to_buy") {
+		t.Errorf("paused store page does not show closed mode")
+	}
+
+	stores, err := queryTopDownloadsStorefronts(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	products, err := queryTopDownloadsProducts(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stores) != 0 || len(products) != 0 {
+		t.Errorf("paused store still listed: stores=%v products=%v", stores, products)
+	}
+	if !isStorefrontPaused(10) {
+		t.Errorf("isStorefrontPaused = false")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Short #RGB colors are stored as #rrggbb, the only form <input type="color">
+// accepts, so the manage page can show the saved colors again.
+func TestStorefrontSaveThemeExpandsShortHex(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'a', 'A')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')")
+
+	form := url.Values{"theme": {"custom"}, "primary_color": {"#ABC"}, "accent_color": {"#123456"}, "hero_start": {"#fff"}, "hero_end": {"#0A0"}}
+	req := httptest.NewRequest(http.MethodPost, "/user/storefront/theme", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleStorefrontSaveTheme(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ok":true`) {
+		t.Fatalf("save: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var stored string
+	db.QueryRow("SELECT custom_theme FROM author_storefronts WHERE id = 10").Scan(&stored)
+	want := CustomThemeColors{PrimaryColor: "#aabbcc", AccentColor: "#123456", HeroStart: "#ffffff", HeroEnd: "#00aa00"}
+	if got, ok := ParseCustomThemeColors(stored); !ok || got != want || strings.Contains(stored, "#ABC") {
+		t.Errorf("stored %s, want %+v", stored, want)
+	}
+
+	// Rows saved before expansion are expanded when read.
+	mustExec(t, `UPDATE author_storefronts SET custom_theme = '{"primary_color":"#abc","accent_color":"#123456","hero_start":"#fff","hero_end":"#0a0"}' WHERE id = 10`)
+	db.QueryRow("SELECT custom_theme FROM author_storefronts WHERE id = 10").Scan(&stored)
+	if got, ok := ParseCustomThemeColors(stored); !ok || got != want {
+		t.Errorf("legacy row parsed as %+v, want %+v", got, want)
+	}
+}
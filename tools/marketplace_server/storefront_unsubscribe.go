@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"marketplace_server/i18n"
+	"marketplace_server/templates"
+)
+
+// storefrontUnsubscribeSecretKey is the settings key holding the HMAC key used
+// to sign unsubscribe links. It is generated on first use.
+const storefrontUnsubscribeSecretKey = "email_unsubscribe_secret"
+
+// storefrontNotSuppressedSQL excludes buyers who unsubscribed from the
+// storefront owned by pl.user_id. It expects the recipient query to alias
+// users as u and pack_listings as pl.
+const storefrontNotSuppressedSQL = ` AND NOT EXISTS (
+				SELECT 1 FROM storefront_email_suppressions s
+				JOIN author_storefronts sf ON sf.id = s.storefront_id
+				WHERE sf.user_id = pl.user_id AND s.email = LOWER(TRIM(u.email)))`
+
+// storefrontRecipient is a buyer who can receive a storefront notification.
+type storefrontRecipient struct {
+	UserID int64
+	Email  string
+}
+
+// storefrontRecipientsWhere returns the FROM/WHERE clause selecting buyers of
+// authorID's packs (restricted to listingIDs when non-empty) who have an
+// email address and have not unsubscribed from the author's storefront.
+func storefrontRecipientsWhere(authorID int64, listingIDs []interface{}) (string, []interface{}) {
+	clause := `FROM user_purchased_packs upp
+			JOIN users u ON upp.user_id = u.id
+			JOIN pack_listings pl ON upp.listing_id = pl.id
+			WHERE pl.user_id = ? AND u.email IS NOT NULL AND u.email != ''`
+	args := []interface{}{authorID}
+	if len(listingIDs) > 0 {
+		clause += fmt.Sprintf(" AND upp.listing_id IN (%s)", strings.TrimSuffix(strings.Repeat("?, ", len(listingIDs)), ", "))
+		args = append(args, listingIDs...)
+	}
+	return clause + storefrontNotSuppressedSQL, args
+}
+
+// loadStorefrontRecipients returns the buyers a notification from authorID
+// should be sent to.
+func loadStorefrontRecipients(authorID int64, listingIDs []interface{}) ([]storefrontRecipient, error) {
+	where, args := storefrontRecipientsWhere(authorID, listingIDs)
+	rows, err := db.Query("SELECT DISTINCT u.id, u.email "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recipients []storefrontRecipient
+	for rows.Next() {
+		var rc storefrontRecipient
+		if err := rows.Scan(&rc.UserID, &rc.Email); err != nil {
+			log.Printf("[STOREFRONT-SEND-NOTIFY] failed to scan recipient row: %v", err)
+			continue
+		}
+		recipients = append(recipients, rc)
+	}
+	return recipients, rows.Err()
+}
+
+// unsubscribeSecret returns the HMAC key for unsubscribe links, creating and
+// storing a random one if none exists yet.
+func unsubscribeSecret() []byte {
+	if secret := getSetting(storefrontUnsubscribeSecretKey); secret != "" {
+		return []byte(secret)
+	}
+	// INSERT OR IGNORE so concurrent first sends agree on a single key.
+	db.Exec("INSERT OR IGNORE INTO settings (key, value) VALUES (?, ?)", storefrontUnsubscribeSecretKey, generateSessionID())
+	return []byte(getSetting(storefrontUnsubscribeSecretKey))
+}
+
+// storefrontUnsubscribeToken signs (storefrontID, email) so a link can only
+// unsubscribe the address it was sent to.
+func storefrontUnsubscribeToken(storefrontID int64, email string) string {
+	mac := hmac.New(sha256.New, unsubscribeSecret())
+	fmt.Fprintf(mac, "storefront-unsubscribe:%d:%s", storefrontID, strings.ToLower(strings.TrimSpace(email)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// storefrontUnsubscribeURL builds the per-recipient unsubscribe link.
+func storefrontUnsubscribeURL(baseURL string, storefrontID int64, email string) string {
+	q := url.Values{}
+	q.Set("s", strconv.FormatInt(storefrontID, 10))
+	q.Set("e", email)
+	q.Set("t", storefrontUnsubscribeToken(storefrontID, email))
+	return baseURL + "/user/email/unsubscribe?" + q.Encode()
+}
+
+// suppressStorefrontEmail stops further notifications from storefrontID to email.
+func suppressStorefrontEmail(storefrontID int64, email string) error {
+	_, err := db.Exec("INSERT OR IGNORE INTO storefront_email_suppressions (storefront_id, email) VALUES (?, ?)",
+		storefrontID, strings.ToLower(strings.TrimSpace(email)))
+	return err
+}
+
+// handleStorefrontUnsubscribe handles GET/POST /user/email/unsubscribe?s=&e=&t=.
+// GET shows a confirmation button so link scanners do not unsubscribe anyone;
+// POST (including RFC 8058 one-click requests from mail clients) applies it.
+func handleStorefrontUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.DetectLang(r)
+	storefrontID, _ := strconv.ParseInt(r.FormValue("s"), 10, 64)
+	email := strings.TrimSpace(r.FormValue("e"))
+	token := r.FormValue("t")
+
+	var storeName string
+	valid := storefrontID > 0 && email != "" &&
+		hmac.Equal([]byte(token), []byte(storefrontUnsubscribeToken(storefrontID, email))) &&
+		db.QueryRow("SELECT store_name FROM author_storefronts WHERE id = ?", storefrontID).Scan(&storeName) == nil
+
+	render := func(errMsg string, done bool) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := i18n.TemplateData(r)
+		i18n.MergeTemplateData(data, map[string]interface{}{
+			"StorefrontID": storefrontID,
+			"Email":        email,
+			"Token":        token,
+			"StoreName":    storeName,
+			"Error":        errMsg,
+			"Done":         done,
+		})
+		if err := templates.UserUnsubscribeTmpl.Execute(w, data); err != nil {
+			log.Printf("[UNSUBSCRIBE] template execute error: %v", err)
+		}
+	}
+
+	if !valid {
+		w.WriteHeader(http.StatusBadRequest)
+		render(i18n.T(lang, "unsubscribe_invalid"), false)
+		return
+	}
+	if r.Method == http.MethodGet {
+		render("", false)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := suppressStorefrontEmail(storefrontID, email); err != nil {
+		log.Printf("[UNSUBSCRIBE] failed to suppress %q for storefront %d: %v", email, storefrontID, err)
+		render(i18n.T(lang, "unsubscribe_failed"), false)
+		return
+	}
+	log.Printf("[UNSUBSCRIBE] %q unsubscribed from storefront %d", email, storefrontID)
+	render("", true)
+}
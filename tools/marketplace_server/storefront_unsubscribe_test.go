@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestDB points the global db at a freshly initialised database for the
+// duration of the test.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	database, err := initDB(filepath.Join(t.TempDir(), "marketplace.db"))
+	if err != nil {
+		t.Fatalf("initDB: %v", err)
+	}
+	prev := db
+	db = database
+	t.Cleanup(func() {
+		db = prev
+		database.Close()
+	})
+}
+
+// mustExec runs query against the test database and fails the test on error.
+func mustExec(t *testing.T, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func TestSuppressedEmailExcludedFromNextSend(t *testing.T) {
+	useTestDB(t)
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'sn', 'author', 'Author', 'author@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'alice', 'Alice', 'Alice@Example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (3, 'sn', 'bob', 'Bob', 'bob@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode) VALUES (100, 1, 1, x'00', 'Pack', 'free')")
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (2, 100), (3, 100)")
+
+	recipients, err := loadStorefrontRecipients(1, nil)
+	if err != nil || len(recipients) != 2 {
+		t.Fatalf("before unsubscribe: got %v (err %v), want 2 recipients", recipients, err)
+	}
+
+	// Follow the link from Alice's email; the GET only confirms, the POST applies it.
+	link, _ := url.Parse(storefrontUnsubscribeURL("http://example.com", 10, "Alice@Example.com"))
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rec := httptest.NewRecorder()
+		handleStorefrontUnsubscribe(rec, httptest.NewRequest(method, link.RequestURI(), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s unsubscribe: status %d", method, rec.Code)
+		}
+		recipients, _ = loadStorefrontRecipients(1, nil)
+		if want := map[string]int{http.MethodGet: 2, http.MethodPost: 1}[method]; len(recipients) != want {
+			t.Fatalf("after %s: got %d recipients, want %d", method, len(recipients), want)
+		}
+	}
+	if recipients[0].Email != "bob@example.com" {
+		t.Errorf("remaining recipient = %q, want bob@example.com", recipients[0].Email)
+	}
+	recipients, _ = loadStorefrontRecipients(1, []interface{}{int64(100)})
+	if len(recipients) != 1 {
+		t.Errorf("partial scope: got %d recipients, want 1", len(recipients))
+	}
+
+	// A tampered token must not unsubscribe anyone.
+	q := link.Query()
+	q.Set("e", "bob@example.com")
+	rec := httptest.NewRecorder()
+	handleStorefrontUnsubscribe(rec, httptest.NewRequest(http.MethodPost, "/user/email/unsubscribe?"+q.Encode(), strings.NewReader("")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("tampered token: status %d, want 400", rec.Code)
+	}
+	if recipients, _ = loadStorefrontRecipients(1, nil); len(recipients) != 1 {
+		t.Errorf("tampered token changed recipients: got %d", len(recipients))
+	}
+}
+
+// The signed unsubscribe link in a mass email comes from public_base_url, not
+// the Host of the request that sent it; without it nothing is sent or charged.
+func TestStorefrontSendNotifyUsesSiteBaseURL(t *testing.T) {
+	useTestDB(t)
+	server := newMockSMTPServer(t)
+	smtpJSON, _ := json.Marshal(server.config())
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('smtp_config', ?)", string(smtpJSON))
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, credits_balance) VALUES (1, 'sn', 'author', 'Author', 'author@example.com', 10)")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'sn', 'alice', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode) VALUES (100, 1, 1, x'00', 'Pack', 'free')")
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (2, 100)")
+
+	send := func() *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"subject": {"News"}, "body": {"Hello"}, "scope": {"all"}}
+		req := httptest.NewRequest(http.MethodPost, "/user/storefront/notify/send", strings.NewReader(form.Encode()))
+		req.Host = "evil.example"
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		handleStorefrontSendNotify(rec, req)
+		return rec
+	}
+
+	if rec := send(); rec.Code != http.StatusInternalServerError || getWalletBalance(1) != 10 {
+		t.Fatalf("without public_base_url: status %d, balance %v", rec.Code, getWalletBalance(1))
+	}
+	server.mu.Lock()
+	sent := len(server.messages)
+	server.mu.Unlock()
+	if sent != 0 {
+		t.Fatalf("sent %d emails without public_base_url", sent)
+	}
+
+	mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES ('public_base_url', 'https://market.example.com')")
+	if rec := send(); rec.Code != http.StatusOK {
+		t.Fatalf("send: status %d: %s", rec.Code, rec.Body.String())
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(server.messages))
+	}
+	msg := server.messages[0]
+	if !strings.Contains(msg, "List-Unsubscribe: <https://market.example.com/") || strings.Contains(msg, "evil.example") {
+		t.Errorf("email links do not use public_base_url:\n%s", msg)
+	}
+}
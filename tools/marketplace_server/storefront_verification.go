@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxVerificationNoteLength caps the admin note stored with a verification.
+const maxVerificationNoteLength = 500
+
+// getVerificationSalesThreshold returns the total sales a storefront needs
+// before it can be verified. 0 (the default) means no threshold.
+func getVerificationSalesThreshold() int {
+	threshold, err := strconv.Atoi(getSetting("verification_sales_threshold"))
+	if err != nil || threshold < 0 {
+		return 0
+	}
+	return threshold
+}
+
+// handleAdminStorefrontVerification handles POST /api/admin/storefront-verification
+// with a JSON body {"storefront_id": N, "verified": true, "note": "...", "force": false}.
+// Verifying a storefront whose total sales are below
+// getVerificationSalesThreshold is refused unless force is set. Every change
+// is recorded in the admin audit log.
+func handleAdminStorefrontVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	var req struct {
+		StorefrontID int64  `json:"storefront_id"`
+		Verified     bool   `json:"verified"`
+		Note         string `json:"note"`
+		Force        bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.StorefrontID <= 0 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if len([]rune(note)) > maxVerificationNoteLength {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "note_too_long"})
+		return
+	}
+
+	var slug, publicID string
+	err := db.QueryRow("SELECT store_slug, COALESCE(public_id, '') FROM author_storefronts WHERE id = ?", req.StorefrontID).Scan(&slug, &publicID)
+	if err == sql.ErrNoRows {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "storefront_not_found"})
+		return
+	} else if err != nil {
+		log.Printf("[STOREFRONT-VERIFY] failed to query storefront %d: %v", req.StorefrontID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+
+	if req.Verified && !req.Force {
+		if threshold := getVerificationSalesThreshold(); threshold > 0 {
+			totalSales, err := computeStorefrontTotalSales(req.StorefrontID)
+			if err != nil {
+				log.Printf("[STOREFRONT-VERIFY] failed to compute sales of storefront %d: %v", req.StorefrontID, err)
+				jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+				return
+			}
+			if totalSales < float64(threshold) {
+				jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+					"error":       "sales_below_threshold",
+					"total_sales": totalSales,
+					"threshold":   threshold,
+				})
+				return
+			}
+		}
+	}
+
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	if req.Verified {
+		_, err = db.Exec(`UPDATE author_storefronts SET verified = 1, verified_at = CURRENT_TIMESTAMP, verified_by = ?, verified_note = ?
+			WHERE id = ?`, adminID, note, req.StorefrontID)
+	} else {
+		_, err = db.Exec(`UPDATE author_storefronts SET verified = 0, verified_at = NULL, verified_by = ?, verified_note = ?
+			WHERE id = ?`, adminID, note, req.StorefrontID)
+	}
+	if err != nil {
+		log.Printf("[STOREFRONT-VERIFY] failed to update storefront %d: %v", req.StorefrontID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "database_error"})
+		return
+	}
+
+	action := "storefront_unverify"
+	if req.Verified {
+		action = "storefront_verify"
+	}
+	detail := "note=" + note
+	if req.Force {
+		detail += "; forced"
+	}
+	recordAuditLog(adminID, action, fmt.Sprintf("storefront:%d", req.StorefrontID), detail, getClientIP(r))
+
+	globalCache.InvalidateStorefront(slug)
+	if publicID != "" {
+		globalCache.InvalidateStorefront(publicID)
+	}
+	globalCache.InvalidateHomepage()
+
+	log.Printf("[STOREFRONT-VERIFY] admin %d set storefront %d verified=%v", adminID, req.StorefrontID, req.Verified)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "verified": req.Verified})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminStorefrontVerification(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')")
+	mustExec(t, "INSERT INTO featured_storefronts (storefront_id, sort_order) VALUES (10, 1)")
+	mustExec(t, "INSERT INTO settings (key, value) VALUES ('verification_sales_threshold', '100')")
+
+	post := func(body string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/storefront-verification", strings.NewReader(body))
+		req.Header.Set("X-Admin-ID", "7")
+		rec := httptest.NewRecorder()
+		handleAdminStorefrontVerification(rec, req)
+		return rec.Code
+	}
+	verified := func() bool {
+		t.Helper()
+		data, err := queryStorefrontPublicData("10", "", "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data.Storefront.Verified
+	}
+
+	if code := post(`{"storefront_id": 10, "verified": true}`); code != http.StatusBadRequest {
+		t.Errorf("verify below sales threshold: status %d, want 400", code)
+	}
+	if code := post(`{"storefront_id": 99, "verified": true, "force": true}`); code != http.StatusNotFound {
+		t.Errorf("verify missing storefront: status %d, want 404", code)
+	}
+	if code := post(`{"storefront_id": 10, "verified": true, "force": true, "note": "checked ID"}`); code != http.StatusOK {
+		t.Fatalf("forced verify: status %d", code)
+	}
+	if !verified() {
+		t.Error("storefront not verified after forced verify")
+	}
+	stores, err := queryFeaturedStorefronts()
+	if err != nil || len(stores) != 1 || !stores[0].Verified {
+		t.Errorf("featured stores = %+v, %v", stores, err)
+	}
+
+	if code := post(`{"storefront_id": 10, "verified": false}`); code != http.StatusOK {
+		t.Fatalf("unverify: status %d", code)
+	}
+	if verified() {
+		t.Error("storefront still verified after unverify")
+	}
+
+	var actions []string
+	rows, err := db.Query("SELECT action || ' ' || target || ' ' || admin_id FROM admin_audit_log ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a string
+		rows.Scan(&a)
+		actions = append(actions, a)
+	}
+	if strings.Join(actions, ",") != "storefront_verify storefront:10 7,storefront_unverify storefront:10 7" {
+		t.Errorf("audit log = %v", actions)
+	}
+}
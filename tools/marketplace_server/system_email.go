@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// loadSMTPConfig reads the SMTP config from settings and checks that it is
+// enabled and complete.
+func loadSMTPConfig() (SMTPConfig, error) {
+	var config SMTPConfig
+	smtpJSON := getSetting("smtp_config")
+	if smtpJSON == "" {
+		return config, fmt.Errorf("smtp not configured")
+	}
+	if err := json.Unmarshal([]byte(smtpJSON), &config); err != nil {
+		return config, fmt.Errorf("failed to parse smtp config: %w", err)
+	}
+	if !config.Enabled || config.Host == "" || config.FromEmail == "" {
+		return config, fmt.Errorf("smtp disabled or incomplete")
+	}
+	return config, nil
+}
+
+// sendSystemEmail sends a plain-text email from the marketplace itself (as
+// opposed to storefront notifications, which use the store name as sender).
+func sendSystemEmail(to, subject, body string) error {
+	config, err := loadSMTPConfig()
+	if err != nil {
+		return err
+	}
+
+	fromHeader := config.FromEmail
+	if config.FromName != "" {
+		fromHeader = fmt.Sprintf("%s <%s>", config.FromName, config.FromEmail)
+	}
+	// Strip CR/LF to prevent header injection
+	stripCRLF := strings.NewReplacer("\r", "", "\n", "")
+
+	var msg bytes.Buffer
+	msg.WriteString(fmt.Sprintf("From: %s\r\n", fromHeader))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", stripCRLF.Replace(to)))
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", stripCRLF.Replace(subject)))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+
+	return sendSMTPMail(config, to, msg.Bytes())
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"marketplace_server/i18n"
+)
+
+// maxStoreTaxRate is the highest tax rate, in percent, a store may charge on
+// custom products.
+const maxStoreTaxRate = 50
+
+// storefrontTaxRate returns the tax rate, in percent, the store charges on
+// custom products. Stores that never set one charge no tax.
+func storefrontTaxRate(storefrontID int64) float64 {
+	var rate float64
+	if err := db.QueryRow("SELECT COALESCE(tax_rate, 0) FROM author_storefronts WHERE id = ?", storefrontID).Scan(&rate); err != nil {
+		return 0
+	}
+	if rate < 0 || rate > maxStoreTaxRate {
+		return 0
+	}
+	return rate
+}
+
+// computeTax returns the tax on amount at ratePercent, rounded to the minor
+// unit of currency.
+func computeTax(amount, ratePercent float64, currency string) float64 {
+	if amount <= 0 || ratePercent <= 0 {
+		return 0
+	}
+	scale := math.Pow10(i18n.CurrencyDecimals(currency))
+	return math.Round(amount*ratePercent/100*scale) / scale
+}
+
+// handleStorefrontSetTaxRate handles POST /user/storefront/tax-rate and saves
+// form value "tax_rate" (percent, 0 to maxStoreTaxRate) as the store's tax
+// rate on custom products.
+func handleStorefrontSetTaxRate(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.Header.Get("X-User-ID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[STOREFRONT-TAX] invalid X-User-ID header: %q", userIDStr)
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "未登录"})
+		return
+	}
+
+	rate := 0.0
+	if s := strings.TrimSpace(r.FormValue("tax_rate")); s != "" {
+		rate, err = strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(rate) || rate < 0 || rate > maxStoreTaxRate {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("税率必须在 0 到 %d 之间", maxStoreTaxRate)})
+			return
+		}
+		rate = math.Round(rate*100) / 100
+	}
+
+	result, err := db.Exec("UPDATE author_storefronts SET tax_rate = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?", rate, userID)
+	if err != nil {
+		log.Printf("[STOREFRONT-TAX] failed to update tax rate for user %d: %v", userID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "保存失败"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "小铺不存在，请先访问小铺设置页面"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "tax_rate": rate})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestComputeTax(t *testing.T) {
+	cases := []struct {
+		amount, rate float64
+		currency     string
+		want         float64
+	}{
+		{10, 0, "USD", 0},
+		{10, 8.25, "USD", 0.83},
+		{19.99, 13, "CNY", 2.6},
+		{1500, 10, "JPY", 150},
+		{999, 8, "JPY", 80},
+	}
+	for _, c := range cases {
+		if got := computeTax(c.amount, c.rate, c.currency); got != c.want {
+			t.Errorf("computeTax(%v, %v, %s) = %v, want %v", c.amount, c.rate, c.currency, got, c.want)
+		}
+	}
+}
+
+func TestPayPalOrderBodyTaxBreakdown(t *testing.T) {
+	amountOf := func(body map[string]interface{}) map[string]interface{} {
+		b, _ := json.Marshal(body)
+		var decoded struct {
+			PurchaseUnits []struct {
+				Amount map[string]interface{} `json:"amount"`
+			} `json:"purchase_units"`
+		}
+		if err := json.Unmarshal(b, &decoded); err != nil || len(decoded.PurchaseUnits) != 1 {
+			t.Fatalf("bad order body %s: %v", b, err)
+		}
+		return decoded.PurchaseUnits[0].Amount
+	}
+
+	amount := amountOf(payPalOrderBody("USD", 10, 0, "Credits"))
+	if amount["value"] != "10.00" || amount["breakdown"] != nil {
+		t.Errorf("untaxed amount = %v", amount)
+	}
+
+	amount = amountOf(payPalOrderBody("USD", 10, 0.83, "Credits"))
+	if amount["value"] != "10.83" {
+		t.Errorf("taxed value = %v, want 10.83", amount["value"])
+	}
+	breakdown, _ := amount["breakdown"].(map[string]interface{})
+	item, _ := breakdown["item_total"].(map[string]interface{})
+	tax, _ := breakdown["tax_total"].(map[string]interface{})
+	if item["value"] != "10.00" || tax["value"] != "0.83" || tax["currency_code"] != "USD" {
+		t.Errorf("breakdown = %v", breakdown)
+	}
+}
+
+func TestStorefrontSetTaxRate(t *testing.T) {
+	useTestDB(t)
+	if _, err := db.Exec("INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (10, 1, 'Shop', 'shop')"); err != nil {
+		t.Fatal(err)
+	}
+	if rate := storefrontTaxRate(10); rate != 0 {
+		t.Fatalf("default tax rate = %v, want 0", rate)
+	}
+
+	post := func(userID, rate string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user/storefront/tax-rate", strings.NewReader(url.Values{"tax_rate": {rate}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User-ID", userID)
+		rec := httptest.NewRecorder()
+		handleStorefrontSetTaxRate(rec, req)
+		return rec
+	}
+
+	if rec := post("1", "8.25"); rec.Code != http.StatusOK {
+		t.Fatalf("save: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rate := storefrontTaxRate(10); rate != 8.25 {
+		t.Errorf("tax rate = %v, want 8.25", rate)
+	}
+	for _, bad := range []string{"-1", "51", "abc"} {
+		if rec := post("1", bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("tax_rate %q: status %d, want 400", bad, rec.Code)
+		}
+	}
+	if rec := post("2", "5"); rec.Code != http.StatusNotFound {
+		t.Errorf("no storefront: status %d, want 404", rec.Code)
+	}
+	if rec := post("1", ""); rec.Code != http.StatusOK || storefrontTaxRate(10) != 0 {
+		t.Errorf("clearing the tax rate: status %d, rate %v", rec.Code, storefrontTaxRate(10))
+	}
+}
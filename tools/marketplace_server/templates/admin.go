@@ -262,6 +262,170 @@ const AdminHTML = `<!DOCTYPE html>
                 </div>
                 <button type="submit" class="btn btn-primary">保存设置</button>
             </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">License API 重试</h3>
+            <p class="form-hint" style="margin-bottom:12px;">虚拟商品发放时调用 License API 遇到超时或 5xx 错误会按指数退避重试，4xx 错误不重试。</p>
+            <form id="license-retry-form" onsubmit="saveLicenseRetryConfig(event)">
+                <div class="form-group">
+                    <label for="license-retry-attempts">最大尝试次数</label>
+                    <input type="number" id="license-retry-attempts" min="1" max="10" step="1" />
+                </div>
+                <div class="form-group">
+                    <label for="license-retry-delay">初始等待时间（毫秒）</label>
+                    <input type="number" id="license-retry-delay" min="0" max="60000" step="100" />
+                    <div class="form-hint">每次重试等待时间翻倍，并加入随机抖动</div>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">重复提交保护</h3>
+            <p class="form-hint" style="margin-bottom:12px;">购买请求携带相同 Idempotency-Key 时，在有效期内直接返回首次创建的支付订单，不会重复下单。</p>
+            <form id="idempotency-form" onsubmit="saveIdempotencyConfig(event)">
+                <div class="form-group">
+                    <label for="idempotency-ttl">Idempotency-Key 有效期（小时）</label>
+                    <input type="number" id="idempotency-ttl" min="1" max="720" step="1" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">未支付订单清理</h3>
+            <p class="form-hint" style="margin-bottom:12px;">创建后超过该时间仍未完成支付的自定义商品订单将自动标记为失败。</p>
+            <form id="stale-order-form" onsubmit="saveStaleOrderConfig(event)">
+                <div class="form-group">
+                    <label for="stale-order-max-age">超时时间（小时）</label>
+                    <input type="number" id="stale-order-max-age" min="1" max="720" step="1" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">邀请奖励</h3>
+            <p class="form-hint" style="margin-bottom:12px;">新用户使用邀请码注册后，在首次购买时向邀请人和新用户各发放一次 Credits 奖励。设为 0 则不发放。</p>
+            <form id="referral-form" onsubmit="saveReferralConfig(event)">
+                <div class="form-group">
+                    <label for="referral-referrer-reward">邀请人奖励（Credits）</label>
+                    <input type="number" id="referral-referrer-reward" min="0" max="100000" step="1" />
+                </div>
+                <div class="form-group">
+                    <label for="referral-referred-reward">新用户奖励（Credits）</label>
+                    <input type="number" id="referral-referred-reward" min="0" max="100000" step="1" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">积分有效期</h3>
+            <p class="form-hint" style="margin-bottom:12px;">开启后，此后发放的积分（充值、赠送、邀请奖励、管理员充值）在指定天数后过期，未用完的部分自动扣除并记为过期交易，扣除不会使余额为负。消费优先使用最早过期的积分。设为 0 则关闭，尚未过期的积分将不再过期。</p>
+            <form id="credits-expiry-form" onsubmit="saveCreditsExpiryConfig(event)">
+                <div class="form-group">
+                    <label for="credits-expiry-days">有效期（天）</label>
+                    <input type="number" id="credits-expiry-days" min="0" max="3650" step="1" />
+                    <p class="form-hint" id="credits-expiry-since"></p>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">积分充值档位</h3>
+            <p class="form-hint" style="margin-bottom:12px;">积分类商品除自身价格外，还可按以下档位购买。档位按价格从低到高排列，每美元可得积分（含赠送）不能低于上一档，最多 8 档。清空所有档位则不提供档位选择。</p>
+            <form id="topup-tier-form" onsubmit="saveTopupTiers(event)">
+                <table>
+                    <thead><tr><th>价格（USD）</th><th>基础积分</th><th>赠送积分</th><th></th></tr></thead>
+                    <tbody id="topup-tier-rows"></tbody>
+                </table>
+                <div style="margin:12px 0;">
+                    <button type="button" class="btn" onclick="addTopupTierRow()">添加档位</button>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">结算币种与汇率</h3>
+            <p class="form-hint" style="margin-bottom:12px;">商品价格以美元录入。店铺页面按访客选择的币种换算展示，PayPal 按结算币种收款，订单记录实际收款币种和金额。</p>
+            <form id="currency-form" onsubmit="saveCurrencyConfig(event)">
+                <div class="form-group">
+                    <label for="settlement-currency">结算币种</label>
+                    <select id="settlement-currency"></select>
+                </div>
+                <div class="form-group">
+                    <label for="exchange-rate-api-url">汇率接口地址</label>
+                    <input type="text" id="exchange-rate-api-url" placeholder="https://open.er-api.com/v6/latest/USD" />
+                    <div class="form-hint">返回以美元为基准的 JSON（含 rates 字段），每 6 小时刷新。最近更新：<span id="exchange-rates-updated">-</span></div>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">PayPal 自动续费订阅</h3>
+            <p class="form-hint" style="margin-bottom:12px;">设置了计费周期（monthly / yearly）的订阅分析包可由用户开通 PayPal 自动续费。每期价格 = 积分价格 × 月数 × 每积分美元价格，按结算币种收款。需在 PayPal 开发者后台将 Webhook 指向 https://&lt;域名&gt;/paypal/webhook。当前自动续费中：<span id="pack-sub-active">-</span></p>
+            <form id="pack-sub-form" onsubmit="savePackSubscriptionConfig(event)">
+                <div class="form-group">
+                    <label for="pack-sub-usd-per-credit">每积分美元价格</label>
+                    <input type="number" id="pack-sub-usd-per-credit" min="0" step="0.0001" />
+                    <div class="form-hint">设为 0 表示关闭自动续费</div>
+                </div>
+                <div class="form-group">
+                    <label for="pack-sub-grace-days">宽限天数</label>
+                    <input type="number" id="pack-sub-grace-days" min="0" max="60" />
+                    <div class="form-hint">订阅取消、暂停或扣款失败后，在已付费期结束后继续保留访问权限的天数</div>
+                </div>
+                <div class="form-group">
+                    <label for="pack-sub-webhook-id">Webhook ID</label>
+                    <input type="text" id="pack-sub-webhook-id" />
+                    <div class="form-hint">用于校验 PayPal Webhook 签名，未填写时拒绝所有 Webhook 事件</div>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">限时分析包</h3>
+            <p class="form-hint" style="margin-bottom:12px;">设置了有效天数的限时分析包，到期后在宽限期内仍可下载，宽限期过后需用户续期。</p>
+            <form id="time-limited-form" onsubmit="saveTimeLimitedConfig(event)">
+                <div class="form-group">
+                    <label for="time-limited-grace-days">到期宽限天数</label>
+                    <input type="number" id="time-limited-grace-days" min="0" max="60" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <hr style="margin:20px 0;border:none;border-top:1px solid #e2e8f0;">
+            <h3 style="font-size:14px;margin-bottom:8px;">密钥轮换</h3>
+            <p class="form-hint" style="margin-bottom:12px;">轮换 PAYPAL_ENCRYPTION_KEY 时，将旧密钥加入 PAYPAL_ENCRYPTION_RETIRED_KEYS 并重启，然后点击下方按钮用新密钥重新加密所有已保存的密钥。仅超级管理员可操作。</p>
+            <div class="form-hint" style="margin-bottom:12px;">当前密钥 ID：<code id="encryption-key-id">-</code>，已退役密钥：<span id="encryption-retired-keys">-</span></div>
+            <button type="button" class="btn btn-secondary" onclick="reencryptSecrets()">重新加密已保存的密钥</button>
+        </div>
+        <div class="card">
+            <h2>第三方登录配置</h2>
+            <p class="form-hint" style="margin-bottom:16px;">配置 Google / GitHub OAuth 登录。回调地址为 https://&lt;域名&gt;/user/oauth/google/callback 或 /user/oauth/github/callback。Client ID 留空则停用该登录方式，Client Secret 留空则保留原值。</p>
+            <form id="oauth-google-form" onsubmit="saveOAuthConfig(event, 'google')">
+                <h3 style="font-size:14px;margin-bottom:8px;">Google</h3>
+                <div class="form-group">
+                    <label for="oauth-google-client-id">Client ID</label>
+                    <input type="text" id="oauth-google-client-id" placeholder="Google Client ID" />
+                </div>
+                <div class="form-group">
+                    <label for="oauth-google-client-secret">Client Secret</label>
+                    <input type="password" id="oauth-google-client-secret" placeholder="••••••••" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <form id="oauth-github-form" onsubmit="saveOAuthConfig(event, 'github')" style="margin-top:20px;">
+                <h3 style="font-size:14px;margin-bottom:8px;">GitHub</h3>
+                <div class="form-group">
+                    <label for="oauth-github-client-id">Client ID</label>
+                    <input type="text" id="oauth-github-client-id" placeholder="GitHub Client ID" />
+                </div>
+                <div class="form-group">
+                    <label for="oauth-github-client-secret">Client Secret</label>
+                    <input type="password" id="oauth-github-client-secret" placeholder="••••••••" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+        </div>
+        <div class="card">
+            <h2>🔗 站点地址</h2>
+            <p class="form-hint" style="margin-bottom:16px;">邮件中的重置密码、邮箱验证链接以及 PayPal 支付返回地址均使用此地址生成，不使用请求的 Host。未设置时这些功能将无法使用。</p>
+            <form id="site-url-form" onsubmit="saveSiteURLConfig(event)">
+                <div class="form-group">
+                    <label for="site-public-base-url">站点公开地址</label>
+                    <input type="url" id="site-public-base-url" placeholder="https://market.vantagics.com" />
+                    <div class="form-hint">例如：https://market.vantagics.com（不含末尾斜杠）</div>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
         </div>
         <div class="card">
             <h2>🎧 客服系统地址设置</h2>
@@ -284,6 +448,241 @@ const AdminHTML = `<!DOCTYPE html>
                 <button type="submit" class="btn btn-primary">保存设置</button>
             </form>
         </div>
+        <div class="card">
+            <h2>⚡ 首页缓存</h2>
+            <p class="form-hint" style="margin-bottom:16px;">首页数据会缓存一段时间以减少数据库查询。缩短缓存时间可让新上架产品更快出现在首页。</p>
+            <form id="homepage-cache-form" onsubmit="saveHomepageCacheTTL(event)">
+                <div class="form-group">
+                    <label for="homepage-cache-ttl">缓存时间（秒）</label>
+                    <input type="number" id="homepage-cache-ttl" min="10" max="3600" step="1" />
+                    <div class="form-hint">范围 10 – 3600 秒，默认 120 秒</div>
+                </div>
+                <div class="form-group">
+                    <label style="display:flex;align-items:center;gap:8px;"><input type="checkbox" id="cache-warmup-enabled" /> 启动时预热首页和明星店铺缓存</label>
+                </div>
+                <div class="form-hint" style="margin-bottom:12px;">最近刷新时间：<span id="homepage-cache-refreshed">-</span></div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+                <button type="button" class="btn btn-secondary" onclick="refreshHomepageCache()">立即刷新</button>
+            </form>
+        </div>
+        <div class="card">
+            <h2>📈 近期热门排序</h2>
+            <p class="form-hint" style="margin-bottom:16px;">首页“近期热门”按销售额排序，越早的销售权重越低。半衰期越短，新近畅销的产品越容易上榜。</p>
+            <form id="trending-form" onsubmit="saveTrendingConfig(event)">
+                <div class="form-group">
+                    <label for="trending-half-life">半衰期（天）</label>
+                    <input type="number" id="trending-half-life" min="1" max="90" step="1" />
+                    <div class="form-hint">范围 1 – 90 天，默认 7 天：7 天前的一笔销售按一半计入</div>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+        </div>
+        <div class="card">
+            <h2>🔒 内容安全策略 (CSP)</h2>
+            <p class="form-hint" style="margin-bottom:16px;">控制 HTML 页面的 Content-Security-Policy 响应头。上线新策略时可先使用“仅报告”模式观察违规情况。</p>
+            <form id="csp-form" onsubmit="saveCSPConfig(event)">
+                <div class="form-group">
+                    <label for="csp-mode">模式</label>
+                    <select id="csp-mode">
+                        <option value="enforce">强制执行</option>
+                        <option value="report-only">仅报告 (Report-Only)</option>
+                        <option value="off">关闭</option>
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="csp-report-uri">违规报告地址</label>
+                    <input type="text" id="csp-report-uri" placeholder="https://example.com/csp-report" />
+                    <div class="form-hint">可选，留空则不发送违规报告</div>
+                </div>
+                <div class="form-hint" style="word-break:break-all;margin-bottom:12px;">当前策略：<code id="csp-policy"></code></div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+        </div>
+        <div class="card">
+            <h2>🏷️ 小铺标识规则</h2>
+            <p class="form-hint" style="margin-bottom:16px;">作者不能将保留字或不当词汇用作小铺标识，自动生成标识时也会跳过这些词。</p>
+            <form id="store-slug-form" onsubmit="saveStoreSlugConfig(event)">
+                <div class="form-group">
+                    <label for="store-slug-reserved">额外保留字</label>
+                    <textarea id="store-slug-reserved" rows="3" placeholder="每行一个"></textarea>
+                    <div class="form-hint">内置保留字：<span id="store-slug-builtin">-</span></div>
+                </div>
+                <div class="form-group">
+                    <label style="display:flex;align-items:center;gap:8px;"><input type="checkbox" id="store-slug-profanity-enabled" /> 启用不当词汇过滤</label>
+                </div>
+                <div class="form-group">
+                    <label for="store-slug-profanity-words">额外屏蔽词</label>
+                    <textarea id="store-slug-profanity-words" rows="3" placeholder="每行一个"></textarea>
+                    <div class="form-hint">标识中以连字符分隔的任一部分命中屏蔽词即被拒绝</div>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+        </div>
+        <div class="card">
+            <h2>🛡️ 内容过滤</h2>
+            <p class="form-hint" style="margin-bottom:16px;">检查商品名称与描述、小铺名称与简介、提交审核的分析包名称与描述。命中屏蔽词或链接过多的内容直接拒绝；命中待复核词的内容照常保存，并列入下方待复核列表。</p>
+            <form id="content-filter-form" onsubmit="saveContentFilterConfig(event)">
+                <div class="form-group">
+                    <label style="display:flex;align-items:center;gap:8px;"><input type="checkbox" id="content-filter-enabled" /> 启用内容过滤</label>
+                </div>
+                <div class="form-group">
+                    <label for="content-blocked-words">屏蔽词（拒绝）</label>
+                    <textarea id="content-blocked-words" rows="3" placeholder="每行一个，可填写短语"></textarea>
+                    <div class="form-hint">小铺标识的屏蔽词同样适用</div>
+                </div>
+                <div class="form-group">
+                    <label for="content-flagged-words">待复核词（保存后人工复核）</label>
+                    <textarea id="content-flagged-words" rows="3" placeholder="每行一个，可填写短语"></textarea>
+                </div>
+                <div class="form-group">
+                    <label for="content-max-urls">单段文字最多链接数</label>
+                    <input type="number" id="content-max-urls" min="0" max="100" step="1" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <h3 style="margin-top:20px;">待复核内容</h3>
+            <table>
+                <thead><tr><th>类型</th><th>ID</th><th>内容</th><th>原因</th><th>时间</th><th>操作</th></tr></thead>
+                <tbody id="content-flags-tbody"><tr><td colspan="6">-</td></tr></tbody>
+            </table>
+        </div>
+        <div class="card">
+            <h2>🌐 公开 API</h2>
+            <p class="form-hint" style="margin-bottom:16px;">/api/v1 只读目录接口的访问设置。只有列出的来源可以在浏览器中跨域调用，每行一个（如 https://example.com），* 表示允许所有来源；留空则拒绝所有跨域请求。</p>
+            <form id="public-api-form" onsubmit="savePublicAPIConfig(event)">
+                <div class="form-group">
+                    <label for="public-api-cors-origins">允许的来源</label>
+                    <textarea id="public-api-cors-origins" rows="4" placeholder="https://example.com"></textarea>
+                </div>
+                <div class="form-group">
+                    <label for="public-api-cors-headers">允许的请求头（逗号分隔）</label>
+                    <input type="text" id="public-api-cors-headers" />
+                </div>
+                <div class="form-group">
+                    <label for="public-api-rate-limit">每个 IP 每分钟请求上限</label>
+                    <input type="number" id="public-api-rate-limit" min="1" max="100000" step="1" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+        </div>
+        <div class="card">
+            <h2>💾 数据库备份</h2>
+            <p class="form-hint" style="margin-bottom:16px;">在线生成数据库的一致性快照并下载，备份期间服务照常读写。需要「数据库备份」权限，每 10 分钟最多一次，每次备份都会记入审计日志。</p>
+            <button type="button" class="btn btn-primary" onclick="downloadDBBackup()">下载数据库备份</button>
+        </div>
+        <div class="card">
+            <h2>📦 分析包上传限制</h2>
+            <p class="form-hint" style="margin-bottom:16px;">超过上限的分析包在上传时即被拒绝，客户端上传前也会检查此上限。</p>
+            <form id="pack-upload-form" onsubmit="savePackUploadConfig(event)">
+                <div class="form-group">
+                    <label for="max-pack-size">最大文件大小（MB）</label>
+                    <input type="number" id="max-pack-size" min="1" max="10240" step="1" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+        </div>
+        <div class="card">
+            <h2>🔏 下载水印</h2>
+            <p class="form-hint" style="margin-bottom:16px;">开启后，每次下载都会在分析包中写入买家邮箱哈希和订单号，文件泄露时可据此追查来源。存储的文件不会被修改。</p>
+            <form id="pack-watermark-form" onsubmit="savePackWatermarkConfig(event)">
+                <div class="form-group">
+                    <label style="display:flex;align-items:center;gap:8px;"><input type="checkbox" id="pack-watermark-enabled" /> 启用下载水印</label>
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+            <form id="pack-watermark-lookup-form" onsubmit="lookupPackWatermark(event)" style="margin-top:20px;">
+                <div class="form-group">
+                    <label for="pack-watermark-mark">查询水印</label>
+                    <input type="text" id="pack-watermark-mark" placeholder="vantagics-wm:..." />
+                </div>
+                <button type="submit" class="btn btn-primary">查询</button>
+            </form>
+            <div id="pack-watermark-result" class="form-hint" style="margin-top:12px;"></div>
+        </div>
+        <div class="card">
+            <h2>🗄️ 分析包文件存储</h2>
+            <p class="form-hint" style="margin-bottom:16px;">新上传的分析包文件保存到所选位置。已有文件记录各自的存储位置，切换后仍可下载；迁移会把数据库中的文件移到当前存储。</p>
+            <form id="pack-storage-form" onsubmit="savePackStorageConfig(event)">
+                <div class="form-group">
+                    <label for="pack-storage-backend">存储方式</label>
+                    <select id="pack-storage-backend" onchange="togglePackStorageFields()" style="padding:9px 12px;border:1px solid #d1d5db;border-radius:6px;font-size:14px;">
+                        <option value="db">数据库</option>
+                        <option value="local">本地磁盘</option>
+                        <option value="s3">S3 兼容对象存储</option>
+                    </select>
+                </div>
+                <div class="form-group pack-storage-local">
+                    <label for="pack-storage-local-dir">存储目录</label>
+                    <input type="text" id="pack-storage-local-dir" placeholder="/var/lib/marketplace/packs" />
+                </div>
+                <div class="form-group pack-storage-s3">
+                    <label for="pack-storage-s3-endpoint">服务地址</label>
+                    <input type="text" id="pack-storage-s3-endpoint" placeholder="https://s3.us-east-1.amazonaws.com" />
+                </div>
+                <div class="form-group pack-storage-s3">
+                    <label for="pack-storage-s3-region">Region</label>
+                    <input type="text" id="pack-storage-s3-region" placeholder="us-east-1" />
+                </div>
+                <div class="form-group pack-storage-s3">
+                    <label for="pack-storage-s3-bucket">Bucket</label>
+                    <input type="text" id="pack-storage-s3-bucket" />
+                </div>
+                <div class="form-group pack-storage-s3">
+                    <label for="pack-storage-s3-access-key">Access Key</label>
+                    <input type="text" id="pack-storage-s3-access-key" />
+                </div>
+                <div class="form-group pack-storage-s3">
+                    <label for="pack-storage-s3-secret">Secret Key</label>
+                    <input type="password" id="pack-storage-s3-secret" placeholder="••••••••" />
+                </div>
+                <div class="form-hint" style="margin-bottom:12px;">数据库中待迁移的文件：<span id="pack-storage-db-files">-</span></div>
+                <div style="display:flex;gap:8px;flex-wrap:wrap;">
+                    <button type="submit" class="btn btn-primary">保存设置</button>
+                    <button type="button" class="btn btn-secondary" onclick="migratePackStorage()">迁移数据库中的文件</button>
+                </div>
+            </form>
+        </div>
+        <div class="card">
+            <h2>🛡️ 分析包上传扫描</h2>
+            <p class="form-hint" style="margin-bottom:16px;">上传或更新分析包时调用病毒扫描服务。发现威胁的分析包会被隔离，管理员在审核页解除隔离后才能继续审核。</p>
+            <form id="pack-scan-form" onsubmit="savePackScanConfig(event)">
+                <div class="form-group">
+                    <label style="display:flex;align-items:center;gap:8px;"><input type="checkbox" id="pack-scan-enabled" /> 启用上传扫描</label>
+                </div>
+                <div class="form-group">
+                    <label for="pack-scan-provider">扫描方式</label>
+                    <select id="pack-scan-provider" style="padding:9px 12px;border:1px solid #d1d5db;border-radius:6px;font-size:14px;">
+                        <option value="clamav">ClamAV (clamd)</option>
+                        <option value="http">HTTP 扫描接口</option>
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="pack-scan-clamav-addr">clamd 地址</label>
+                    <input type="text" id="pack-scan-clamav-addr" placeholder="127.0.0.1:3310" />
+                </div>
+                <div class="form-group">
+                    <label for="pack-scan-http-url">扫描接口地址</label>
+                    <input type="text" id="pack-scan-http-url" placeholder="https://scanner.example.com/scan" />
+                    <div class="form-hint">接口返回 {"infected": true/false, "signature": "..."}</div>
+                </div>
+                <div class="form-group">
+                    <label for="pack-scan-api-key">接口密钥</label>
+                    <input type="password" id="pack-scan-api-key" />
+                </div>
+                <div class="form-group">
+                    <label style="display:flex;align-items:center;gap:8px;"><input type="checkbox" id="pack-scan-hash-only" /> 只发送文件 SHA-256（仅 HTTP 接口）</label>
+                </div>
+                <div class="form-group">
+                    <label style="display:flex;align-items:center;gap:8px;"><input type="checkbox" id="pack-scan-fail-closed" /> 扫描失败时隔离分析包</label>
+                    <div class="form-hint">不勾选时，扫描服务不可用不影响上传</div>
+                </div>
+                <div class="form-group">
+                    <label for="pack-scan-timeout">超时时间（秒）</label>
+                    <input type="number" id="pack-scan-timeout" min="1" max="600" placeholder="60" />
+                </div>
+                <button type="submit" class="btn btn-primary">保存设置</button>
+            </form>
+        </div>
     </div>
 
     <!-- SMTP Test Modal -->
@@ -309,6 +708,7 @@ const AdminHTML = `<!DOCTYPE html>
         <div class="wd-tabs">
             <button class="wd-tab active" onclick="switchReviewTab('review-tab-packs', this)">📋 <span data-i18n="review_packs">待审核分析包</span></button>
             <button class="wd-tab" onclick="switchReviewTab('review-tab-custom-products', this)">🛍️ <span data-i18n="review_custom_products">待审核商品</span></button>
+            <button class="wd-tab" onclick="switchReviewTab('review-tab-quarantine', this)">🛡️ <span data-i18n="review_quarantine">隔离的分析包</span></button>
         </div>
 
         <!-- Tab: 待审核分析包 -->
@@ -316,11 +716,15 @@ const AdminHTML = `<!DOCTYPE html>
         <div class="card">
             <div class="card-header">
                 <h2 data-i18n="review_packs">待审核分析包</h2>
-                <button class="btn btn-secondary" onclick="loadPendingPacks()">↻ <span data-i18n="refresh">刷新</span></button>
+                <div>
+                    <button class="btn btn-primary" onclick="bulkApprovePacks()" data-i18n="bulk_approve">批量通过</button>
+                    <button class="btn btn-danger" onclick="showBulkRejectModal()" data-i18n="bulk_reject">批量拒绝</button>
+                    <button class="btn btn-secondary" onclick="loadPendingPacks()">↻ <span data-i18n="refresh">刷新</span></button>
+                </div>
             </div>
             <table>
                 <thead>
-                    <tr><th data-i18n="id_col">ID</th><th data-i18n="name_col">名称</th><th data-i18n="category">分类</th><th data-i18n="author_col">作者</th><th data-i18n="mode_col">模式</th><th data-i18n="price_col">价格</th><th data-i18n="upload_time_col">上传时间</th><th data-i18n="actions">操作</th></tr>
+                    <tr><th><input type="checkbox" id="pending-select-all" onchange="toggleAllPending(this.checked)"></th><th data-i18n="id_col">ID</th><th data-i18n="name_col">名称</th><th data-i18n="category">分类</th><th data-i18n="author_col">作者</th><th data-i18n="mode_col">模式</th><th data-i18n="price_col">价格</th><th data-i18n="upload_time_col">上传时间</th><th data-i18n="actions">操作</th></tr>
                 </thead>
                 <tbody id="pending-list"></tbody>
             </table>
@@ -351,6 +755,22 @@ const AdminHTML = `<!DOCTYPE html>
             </table>
         </div>
         </div>
+
+        <!-- Tab: 隔离的分析包 -->
+        <div id="review-tab-quarantine" class="wd-tab-content" style="display:none;">
+        <div class="card">
+            <div class="card-header">
+                <h2 data-i18n="review_quarantine">隔离的分析包</h2>
+                <button class="btn btn-secondary" onclick="loadQuarantinedPacks()">↻ <span data-i18n="refresh">刷新</span></button>
+            </div>
+            <table>
+                <thead>
+                    <tr><th data-i18n="id_col">ID</th><th data-i18n="name_col">名称</th><th data-i18n="author_col">作者</th><th data-i18n="scan_result_col">扫描结果</th><th data-i18n="upload_time_col">上传时间</th><th data-i18n="actions">操作</th></tr>
+                </thead>
+                <tbody id="quarantine-list"></tbody>
+            </table>
+        </div>
+        </div>
     </div>
 
     <!-- Marketplace Management Section -->
@@ -609,6 +1029,39 @@ const AdminHTML = `<!DOCTYPE html>
                         <label for="fee-rate-bank-card-cn" data-i18n="fee_rate_cn">中国银行卡 手续费率（%）</label>
                         <input type="number" id="fee-rate-bank-card-cn" min="0" step="0.01" value="{{.FeeRateBankCardCN}}" />
                     </div>
+                    <div class="form-group">
+                        <label for="min-withdrawal" data-i18n="min_withdrawal_label">最低提现金额（元，扣除手续费后）</label>
+                        <input type="number" id="min-withdrawal" min="0" step="0.01" value="{{.MinWithdrawal}}" />
+                    </div>
+                    <div class="form-group">
+                        <label data-i18n="fee_cap_label">单笔手续费上限（元，0 表示不设上限）</label>
+                        <div style="display:grid;grid-template-columns:repeat(auto-fill,minmax(160px,1fr));gap:8px;">
+                        <label style="font-size:13px;font-weight:400;">PayPal
+                            <input type="number" class="fee-cap-input" data-type="paypal" min="0" step="0.01" value="{{index .FeeCaps "paypal"}}" />
+                        </label>
+                        <label style="font-size:13px;font-weight:400;"><span data-i18n="wechat">微信</span>
+                            <input type="number" class="fee-cap-input" data-type="wechat" min="0" step="0.01" value="{{index .FeeCaps "wechat"}}" />
+                        </label>
+                        <label style="font-size:13px;font-weight:400;">AliPay
+                            <input type="number" class="fee-cap-input" data-type="alipay" min="0" step="0.01" value="{{index .FeeCaps "alipay"}}" />
+                        </label>
+                        <label style="font-size:13px;font-weight:400;"><span data-i18n="check">支票</span>
+                            <input type="number" class="fee-cap-input" data-type="check" min="0" step="0.01" value="{{index .FeeCaps "check"}}" />
+                        </label>
+                        <label style="font-size:13px;font-weight:400;"><span data-i18n="wire_transfer">国际电汇</span>
+                            <input type="number" class="fee-cap-input" data-type="wire_transfer" min="0" step="0.01" value="{{index .FeeCaps "wire_transfer"}}" />
+                        </label>
+                        <label style="font-size:13px;font-weight:400;"><span data-i18n="bank_card_us">美国银行卡</span>
+                            <input type="number" class="fee-cap-input" data-type="bank_card_us" min="0" step="0.01" value="{{index .FeeCaps "bank_card_us"}}" />
+                        </label>
+                        <label style="font-size:13px;font-weight:400;"><span data-i18n="bank_card_eu">欧洲银行卡</span>
+                            <input type="number" class="fee-cap-input" data-type="bank_card_eu" min="0" step="0.01" value="{{index .FeeCaps "bank_card_eu"}}" />
+                        </label>
+                        <label style="font-size:13px;font-weight:400;"><span data-i18n="bank_card_cn">中国银行卡</span>
+                            <input type="number" class="fee-cap-input" data-type="bank_card_cn" min="0" step="0.01" value="{{index .FeeCaps "bank_card_cn"}}" />
+                        </label>
+                        </div>
+                    </div>
                     <button type="submit" class="btn btn-primary" data-i18n="save_fee_settings">保存手续费设置</button>
                 </form>
             </div>
@@ -633,6 +1086,10 @@ const AdminHTML = `<!DOCTYPE html>
                         <option value="paid" data-i18n="paid">已付款</option>
                     </select>
                     <input type="text" id="wd-author-filter" placeholder="按作者名过滤" data-i18n-placeholder="filter_by_author" oninput="loadWithdrawals()" style="padding:7px 12px;border:1px solid #d1d5db;border-radius:6px;font-size:13px;width:160px;" />
+                    <input type="date" id="wd-date-from" style="padding:6px 10px;border:1px solid #d1d5db;border-radius:6px;font-size:13px;" />
+                    <span>~</span>
+                    <input type="date" id="wd-date-to" style="padding:6px 10px;border:1px solid #d1d5db;border-radius:6px;font-size:13px;" />
+                    <button class="btn btn-secondary" onclick="exportWithdrawalReport()">📊 <span data-i18n="export_withdraw_report">导出对账表</span></button>
                 </div>
                 <table>
                     <thead>
@@ -713,6 +1170,19 @@ const AdminHTML = `<!DOCTYPE html>
                 </div>
             </div>
         </div>
+        <div class="card">
+            <div class="card-header">
+                <h2>📦 待发放订单</h2>
+                <button class="btn btn-secondary" onclick="loadFulfillmentJobs()">↻ <span data-i18n="refresh">刷新</span></button>
+            </div>
+            <p class="form-hint" style="margin-bottom:12px;">已付款但 License 授权尚未绑定的虚拟商品订单，系统会在后台自动重试；状态为“失败”的订单需要人工处理或手动重试。</p>
+            <table>
+                <thead>
+                    <tr><th>订单</th><th>商品</th><th>买家</th><th>状态</th><th>尝试次数</th><th>最近错误</th><th>下次重试</th><th>操作</th></tr>
+                </thead>
+                <tbody id="fulfillment-job-list"></tbody>
+            </table>
+        </div>
     </div>
 
     <!-- Billing Management Section -->
@@ -857,6 +1327,30 @@ const AdminHTML = `<!DOCTYPE html>
                 <tbody id="featured-list"></tbody>
             </table>
         </div>
+        <div class="card" style="margin-top:20px;">
+            <div class="card-header">
+                <h2 data-i18n="featured_products_mgmt">精选产品管理</h2>
+                <span id="featured-product-count" style="font-size:13px;color:#6b7280;"></span>
+            </div>
+            <p style="font-size:13px;color:#9ca3af;margin-bottom:16px;" data-i18n="featured_products_hint">管理首页展示的精选产品，最多可设置 16 个。</p>
+            <div style="position:relative;margin-bottom:20px;">
+                <div style="display:flex;gap:8px;">
+                    <input type="text" id="featured-product-search-input" placeholder="搜索分析包名称或作者..." data-i18n-placeholder="search_product_placeholder" oninput="searchFeaturedProducts()" style="flex:1;" />
+                </div>
+                <div id="featured-product-search-results" style="display:none;position:absolute;top:100%;left:0;right:0;background:#fff;border:1px solid #d1d5db;border-radius:6px;box-shadow:0 4px 12px rgba(0,0,0,0.1);max-height:240px;overflow-y:auto;z-index:10;margin-top:4px;"></div>
+            </div>
+            <table>
+                <thead>
+                    <tr>
+                        <th style="width:60px;" data-i18n="sort_order_col">排序</th>
+                        <th data-i18n="pack_name_col">分析包名称</th>
+                        <th data-i18n="author_col">作者</th>
+                        <th style="width:160px;" data-i18n="actions">操作</th>
+                    </tr>
+                </thead>
+                <tbody id="featured-product-list"></tbody>
+            </table>
+        </div>
     </div>
 
     <!-- Storefront Support Management Section -->
@@ -959,6 +1453,24 @@ const AdminHTML = `<!DOCTYPE html>
                 </div>
             </div>
         </div>
+        <div class="profile-card" style="margin-top: 20px;">
+            <h3><span class="icon-header"><span>🛡️</span> <span data-i18n="admin_2fa_title">两步验证</span></span></h3>
+            <div id="totp-status" class="form-hint" style="margin-bottom:12px;"></div>
+            <div id="totp-setup" style="display:none;">
+                <p class="form-hint" data-i18n="admin_2fa_scan_hint">使用身份验证器应用扫描二维码，或手动输入密钥，然后输入 6 位验证码完成启用。</p>
+                <img id="totp-qr" alt="QR" style="display:block;margin:12px 0;width:200px;height:200px;image-rendering:pixelated;" />
+                <div class="form-hint" style="word-break:break-all;"><span data-i18n="admin_2fa_secret">密钥</span>: <code id="totp-secret"></code></div>
+                <div class="form-group" style="margin-top:12px;">
+                    <input type="text" id="totp-enable-code" maxlength="6" inputmode="numeric" placeholder="123456" />
+                </div>
+                <button class="btn btn-primary btn-sm" onclick="enableTOTP()" data-i18n="admin_2fa_enable">启用</button>
+            </div>
+            <div id="totp-recovery" style="display:none;margin-top:12px;">
+                <p class="form-hint" data-i18n="admin_2fa_recovery_hint">请妥善保存以下恢复码，每个只能使用一次，关闭后将不再显示。</p>
+                <pre id="totp-recovery-codes" style="background:#f9fafb;padding:10px;border-radius:6px;font-size:13px;"></pre>
+            </div>
+            <div id="totp-actions" style="margin-top:8px;"></div>
+        </div>
         <div style="margin-top: 20px; display: flex; justify-content: flex-end;">
             <button class="btn btn-primary" onclick="saveProfile()" style="padding: 10px 28px; font-size: 14px;" data-i18n="save_changes">保存修改</button>
         </div>
@@ -1013,35 +1525,7 @@ const AdminHTML = `<!DOCTYPE html>
         </div>
         <div class="form-group">
             <label data-i18n="permission_settings">权限设置</label>
-            <div style="display:flex;flex-wrap:wrap;gap:12px;margin-top:6px;">
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="categories" class="new-admin-perm" /> <span data-i18n="category_mgmt">分类管理</span>
-                </label>
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="marketplace" class="new-admin-perm" /> <span data-i18n="marketplace_mgmt">市场管理</span>
-                </label>
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="authors" class="new-admin-perm" /> <span data-i18n="author_mgmt">作者管理</span>
-                </label>
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="customers" class="new-admin-perm" /> <span data-i18n="customer_mgmt">客户管理</span>
-                </label>
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="review" class="new-admin-perm" /> <span data-i18n="review_mgmt">审核管理</span>
-                </label>
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="settings" class="new-admin-perm" /> <span data-i18n="system_settings">系统设置</span>
-                </label>
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="notifications" class="new-admin-perm" /> <span data-i18n="notification_mgmt">消息管理</span>
-                </label>
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="sales" class="new-admin-perm" /> <span data-i18n="sales_mgmt">销售管理</span>
-                </label>
-                <label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">
-                    <input type="checkbox" value="billing" class="new-admin-perm" /> 收费管理
-                </label>
-            </div>
+            <div id="new-admin-perms" style="display:flex;flex-wrap:wrap;gap:12px;margin-top:6px;"></div>
         </div>
         <div class="modal-actions">
             <button class="btn btn-secondary" onclick="hideAddAdminModal()" data-i18n="cancel">取消</button>
@@ -1087,8 +1571,26 @@ const AdminHTML = `<!DOCTYPE html>
             <select id="notif-target-type" onchange="toggleTargetUsers()" style="width:100%;padding:9px 12px;border:1px solid #d1d5db;border-radius:6px;font-size:14px;">
                 <option value="broadcast" data-i18n="broadcast_all">广播（所有用户）</option>
                 <option value="targeted" data-i18n="targeted_specific">定向（指定用户）</option>
+                <option value="segment" data-i18n="targeted_segment">定向（按购买记录筛选）</option>
             </select>
         </div>
+        <div id="notif-segment-section" style="display:none;">
+            <p class="form-hint" style="margin-bottom:8px;" data-i18n="segment_hint">以下条件同时满足的用户将在发送时收到消息，留空的条件不限。</p>
+            <div class="form-group">
+                <label for="notif-seg-listing" data-i18n="segment_listing">购买过的分析包 ID</label>
+                <input type="number" id="notif-seg-listing" min="1" step="1" />
+            </div>
+            <div class="form-group">
+                <label for="notif-seg-storefront" data-i18n="segment_storefront">在该小铺购买过（小铺 ID）</label>
+                <input type="number" id="notif-seg-storefront" min="1" step="1" />
+            </div>
+            <div class="form-group">
+                <label for="notif-seg-min-spend" data-i18n="segment_min_spend">累计消费不少于（Credits）</label>
+                <input type="number" id="notif-seg-min-spend" min="0" step="1" />
+            </div>
+            <button class="btn btn-secondary btn-sm" onclick="estimateNotifSegment()" data-i18n="segment_estimate">估算人数</button>
+            <span id="notif-seg-count" style="margin-left:8px;font-size:13px;color:#374151;"></span>
+        </div>
         <div id="notif-target-section" style="display:none;">
             <div class="form-group">
                 <label data-i18n="search_users">搜索用户</label>
@@ -1114,6 +1616,24 @@ const AdminHTML = `<!DOCTYPE html>
                 <span style="font-size:13px;color:#6b7280;" data-i18n="days_0_permanent">天 (0=永久)</span>
             </div>
         </div>
+        <div class="form-group">
+            <label for="notif-recurrence" data-i18n="recurrence">重复</label>
+            <select id="notif-recurrence" onchange="toggleNotifRecurrence()" style="width:100%;padding:9px 12px;border:1px solid #d1d5db;border-radius:6px;font-size:14px;">
+                <option value="none" data-i18n="recurrence_none">不重复</option>
+                <option value="daily" data-i18n="recurrence_daily">每天</option>
+                <option value="weekly" data-i18n="recurrence_weekly">每周</option>
+                <option value="monthly" data-i18n="recurrence_monthly">每月</option>
+            </select>
+        </div>
+        <div id="notif-recurrence-section" style="display:none;">
+            <div class="form-group">
+                <label for="notif-recurrence-end" data-i18n="recurrence_end">重复截止日期</label>
+                <input type="datetime-local" id="notif-recurrence-end" style="width:100%;padding:9px 12px;border:1px solid #d1d5db;border-radius:6px;font-size:14px;" />
+                <p class="form-hint" data-i18n="recurrence_hint">重复消息的显示时长不能超过一个周期（每天 1 天、每周 7 天、每月 28 天）。已读过的用户不会再收到未读提醒。</p>
+            </div>
+            <button class="btn btn-secondary btn-sm" onclick="previewNotifOccurrences()" data-i18n="preview_occurrences">预览排期</button>
+            <div id="notif-occurrences" style="max-height:120px;overflow-y:auto;margin-top:8px;font-size:13px;color:#374151;"></div>
+        </div>
         <div class="modal-actions">
             <button class="btn btn-secondary" onclick="hideCreateNotification()" data-i18n="cancel">取消</button>
             <button class="btn btn-primary" onclick="createNotification()" data-i18n="send_notification">发送</button>
@@ -1127,7 +1647,7 @@ var adminID = {{.AdminID}};
 var permissions = {{.PermissionsJSON}};
 // Lazy _i18n wrapper: safe to call before I18nJS loads
 if (!window._i18n) { window._i18n = function(key, fallback) { return fallback || key; }; }
-var permLabels = { categories: window._i18n("category_mgmt","分类管理"), marketplace: window._i18n("marketplace_mgmt","市场管理"), accounts: window._i18n("account_mgmt","账号管理"), authors: window._i18n("author_mgmt","作者管理"), customers: window._i18n("customer_mgmt","客户管理"), review: window._i18n("review_mgmt","审核管理"), settings: window._i18n("system_settings","系统设置"), notifications: window._i18n("notification_mgmt","消息管理"), sales: window._i18n("sales_mgmt","销售管理"), billing: window._i18n("billing_mgmt","收费管理"), storefront_support: window._i18n("storefront_support_mgmt","店铺支持") };
+var permLabels = { categories: window._i18n("category_mgmt","分类管理"), marketplace: window._i18n("marketplace_mgmt","市场管理"), accounts: window._i18n("account_mgmt","账号管理"), authors: window._i18n("author_mgmt","作者管理"), customers: window._i18n("customer_mgmt","客户管理"), review: window._i18n("review_mgmt","审核管理"), settings: window._i18n("system_settings","系统设置"), notifications: window._i18n("notification_mgmt","消息管理"), sales: window._i18n("sales_mgmt","销售管理"), billing: window._i18n("billing_mgmt","收费管理"), storefront_support: window._i18n("storefront_support_mgmt","店铺支持"), kyc: window._i18n("kyc_mgmt","实名审核"), impersonate: window._i18n("impersonate_mgmt","模拟登录"), backup: window._i18n("backup_mgmt","数据库备份") };
 
 function hasPerm(p) {
     if (p === 'accounts') return permissions.indexOf('accounts') !== -1 || permissions.indexOf('authors') !== -1 || permissions.indexOf('customers') !== -1;
@@ -1169,13 +1689,14 @@ function showSection(name) {
     if (name === 'marketplace') loadMarketplacePacks();
     if (name === 'accounts') loadAccounts();
     if (name === 'admins') loadAdmins();
+    if (name === 'profile') loadTOTPStatus();
     if (name === 'review') { loadPendingPacks(); loadPendingCustomProducts(); }
     if (name === 'notifications') loadNotifications();
     if (name === 'withdrawals') loadWithdrawals();
-    if (name === 'sales') loadSalesData(1);
+    if (name === 'sales') { loadSalesData(1); loadFulfillmentJobs(); }
     if (name === 'billing') loadBillingData(1);
-    if (name === 'featured') loadFeaturedStorefronts();
-    if (name === 'settings') { loadSMTPConfig(); loadPayPalConfig(); }
+    if (name === 'featured') { loadFeaturedStorefronts(); loadFeaturedProducts(); }
+    if (name === 'settings') { loadSMTPConfig(); loadPayPalConfig(); loadLicenseRetryConfig(); loadIdempotencyConfig(); loadStaleOrderConfig(); loadReferralConfig(); loadCreditsExpiryConfig(); loadTopupTierConfig(); loadCurrencyConfig(); loadPackSubscriptionConfig(); loadTimeLimitedConfig(); loadEncryptionStatus(); loadOAuthConfig(); loadHomepageCacheStatus(); loadTrendingConfig(); loadCSPConfig(); loadStoreSlugConfig(); loadContentFilterConfig(); loadPublicAPIConfig(); loadPackUploadConfig(); loadPackWatermarkConfig(); loadPackStorageConfig(); loadPackScanConfig(); loadSiteURLConfig(); }
     if (name === 'storefront-support') { loadSupportThreshold(); loadStorefrontSupport(); }
 }
 
@@ -1192,6 +1713,13 @@ function apiFetch(url, opts) {
             setTimeout(function() { window.location.href = '/admin/login'; }, 1500);
             return Promise.reject(new Error('session_expired'));
         }
+        if (r.status === 403) {
+            // Missing permission: show which one instead of a bare error code.
+            return r.clone().json().then(function(d) {
+                if (d && d.message) showMsg(d.message, true);
+                return r;
+            }, function() { return r; });
+        }
         return r;
     });
 }
@@ -1429,6 +1957,488 @@ function loadPayPalConfig() {
     }).catch(function(err) {});
 }
 
+function loadLicenseRetryConfig() {
+    apiFetch('/admin/settings/license-retry').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('license-retry-attempts').value = d.max_attempts;
+        document.getElementById('license-retry-delay').value = d.base_delay_ms;
+    }).catch(function() {});
+}
+
+function saveLicenseRetryConfig(e) {
+    e.preventDefault();
+    var attempts = document.getElementById('license-retry-attempts').value.trim();
+    var delay = document.getElementById('license-retry-delay').value.trim();
+    apiFetch('/admin/settings/license-retry', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'max_attempts=' + encodeURIComponent(attempts) + '&base_delay_ms=' + encodeURIComponent(delay)
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('重试设置已保存', false); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadIdempotencyConfig() {
+    apiFetch('/admin/settings/idempotency').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('idempotency-ttl').value = d.ttl_hours;
+    }).catch(function() {});
+}
+
+function saveIdempotencyConfig(e) {
+    e.preventDefault();
+    apiFetch('/admin/settings/idempotency', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'ttl_hours=' + encodeURIComponent(document.getElementById('idempotency-ttl').value.trim())
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('重复提交保护设置已保存', false); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadStaleOrderConfig() {
+    apiFetch('/admin/settings/stale-orders').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('stale-order-max-age').value = d.max_age_hours;
+    }).catch(function() {});
+}
+
+function saveStaleOrderConfig(e) {
+    e.preventDefault();
+    apiFetch('/admin/settings/stale-orders', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'max_age_hours=' + encodeURIComponent(document.getElementById('stale-order-max-age').value.trim())
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('未支付订单清理设置已保存', false); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadReferralConfig() {
+    apiFetch('/admin/settings/referrals').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('referral-referrer-reward').value = d.referrer_reward;
+        document.getElementById('referral-referred-reward').value = d.referred_reward;
+    }).catch(function() {});
+}
+
+function saveReferralConfig(e) {
+    e.preventDefault();
+    apiFetch('/admin/settings/referrals', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'referrer_reward=' + encodeURIComponent(document.getElementById('referral-referrer-reward').value.trim()) +
+            '&referred_reward=' + encodeURIComponent(document.getElementById('referral-referred-reward').value.trim())
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('邀请奖励设置已保存', false); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadCreditsExpiryConfig() {
+    apiFetch('/admin/settings/credits-expiry').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('credits-expiry-days').value = d.expiry_days;
+        document.getElementById('credits-expiry-since').textContent = d.since ? '自 ' + d.since + ' (UTC) 起发放的积分适用' : '';
+    }).catch(function() {});
+}
+
+function saveCreditsExpiryConfig(e) {
+    e.preventDefault();
+    apiFetch('/admin/settings/credits-expiry', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'expiry_days=' + encodeURIComponent(document.getElementById('credits-expiry-days').value.trim())
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('积分有效期设置已保存', false); loadCreditsExpiryConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function addTopupTierRow(tier) {
+    tier = tier || {};
+    var tr = document.createElement('tr');
+    tr.innerHTML = '<td><input type="number" class="tier-price" min="0.01" max="9999.99" step="0.01" /></td>' +
+        '<td><input type="number" class="tier-credits" min="1" step="1" /></td>' +
+        '<td><input type="number" class="tier-bonus" min="0" step="1" /></td>' +
+        '<td><button type="button" class="btn btn-danger" onclick="this.closest(\'tr\').remove()">删除</button></td>';
+    tr.querySelector('.tier-price').value = tier.price_usd || '';
+    tr.querySelector('.tier-credits').value = tier.credits || '';
+    tr.querySelector('.tier-bonus').value = tier.bonus_credits || 0;
+    document.getElementById('topup-tier-rows').appendChild(tr);
+}
+
+function loadTopupTierConfig() {
+    apiFetch('/admin/settings/topup-tiers').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('topup-tier-rows').innerHTML = '';
+        (d.tiers || []).forEach(addTopupTierRow);
+    }).catch(function() {});
+}
+
+function saveTopupTiers(e) {
+    e.preventDefault();
+    var tiers = [];
+    document.querySelectorAll('#topup-tier-rows tr').forEach(function(tr) {
+        tiers.push({
+            price_usd: parseFloat(tr.querySelector('.tier-price').value) || 0,
+            credits: parseInt(tr.querySelector('.tier-credits').value, 10) || 0,
+            bonus_credits: parseInt(tr.querySelector('.tier-bonus').value, 10) || 0
+        });
+    });
+    apiFetch('/admin/settings/topup-tiers', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({tiers: tiers})
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('充值档位已保存', false); loadTopupTierConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadCurrencyConfig() {
+    apiFetch('/admin/settings/currency').then(function(r) { return r.json(); }).then(function(d) {
+        var sel = document.getElementById('settlement-currency');
+        sel.innerHTML = '';
+        (d.currencies || []).forEach(function(c) {
+            var opt = document.createElement('option');
+            opt.value = c;
+            opt.textContent = c + (d.rates && d.rates[c] ? '（1 USD = ' + d.rates[c] + '）' : '');
+            sel.appendChild(opt);
+        });
+        sel.value = d.settlement_currency;
+        document.getElementById('exchange-rate-api-url').value = d.exchange_rate_api_url || '';
+        document.getElementById('exchange-rates-updated').textContent = d.rates_updated_at || '尚未获取';
+    }).catch(function() {});
+}
+
+function saveCurrencyConfig(e) {
+    e.preventDefault();
+    var currency = document.getElementById('settlement-currency').value;
+    var apiURL = document.getElementById('exchange-rate-api-url').value.trim();
+    apiFetch('/admin/settings/currency', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'settlement_currency=' + encodeURIComponent(currency) + '&exchange_rate_api_url=' + encodeURIComponent(apiURL)
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg(res.data.warning || '币种设置已保存', !!res.data.warning); loadCurrencyConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadPackSubscriptionConfig() {
+    apiFetch('/admin/settings/pack-subscriptions').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('pack-sub-usd-per-credit').value = d.usd_per_credit;
+        document.getElementById('pack-sub-grace-days').value = d.grace_days;
+        document.getElementById('pack-sub-webhook-id').value = d.webhook_id || '';
+        document.getElementById('pack-sub-active').textContent = d.active_subscriptions;
+    }).catch(function() {});
+}
+
+function savePackSubscriptionConfig(e) {
+    e.preventDefault();
+    var body = 'usd_per_credit=' + encodeURIComponent(document.getElementById('pack-sub-usd-per-credit').value)
+        + '&grace_days=' + encodeURIComponent(document.getElementById('pack-sub-grace-days').value)
+        + '&webhook_id=' + encodeURIComponent(document.getElementById('pack-sub-webhook-id').value.trim());
+    apiFetch('/admin/settings/pack-subscriptions', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: body
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('自动续费设置已保存', false); loadPackSubscriptionConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadTimeLimitedConfig() {
+    apiFetch('/admin/settings/time-limited').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('time-limited-grace-days').value = d.grace_days;
+    }).catch(function() {});
+}
+
+function saveTimeLimitedConfig(e) {
+    e.preventDefault();
+    apiFetch('/admin/settings/time-limited', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'grace_days=' + encodeURIComponent(document.getElementById('time-limited-grace-days').value)
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('宽限期设置已保存', false); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadStoreSlugConfig() {
+    apiFetch('/admin/settings/store-slugs').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('store-slug-builtin').textContent = (d.builtin_reserved || []).join(', ');
+        document.getElementById('store-slug-reserved').value = d.reserved || '';
+        document.getElementById('store-slug-profanity-enabled').checked = !!d.profanity_enabled;
+        document.getElementById('store-slug-profanity-words').value = d.profanity_words || '';
+    }).catch(function() {});
+}
+
+function saveStoreSlugConfig(e) {
+    e.preventDefault();
+    var body = 'reserved=' + encodeURIComponent(document.getElementById('store-slug-reserved').value) +
+        '&profanity_enabled=' + (document.getElementById('store-slug-profanity-enabled').checked ? '1' : '0') +
+        '&profanity_words=' + encodeURIComponent(document.getElementById('store-slug-profanity-words').value);
+    apiFetch('/admin/settings/store-slugs', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: body
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('小铺标识规则已保存', false); loadStoreSlugConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadContentFilterConfig() {
+    apiFetch('/admin/settings/content-filter').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('content-filter-enabled').checked = !!d.enabled;
+        document.getElementById('content-blocked-words').value = d.blocked_words || '';
+        document.getElementById('content-flagged-words').value = d.flagged_words || '';
+        document.getElementById('content-max-urls').value = d.max_urls;
+    }).catch(function() {});
+    loadContentFlags();
+}
+
+function saveContentFilterConfig(e) {
+    e.preventDefault();
+    var body = 'enabled=' + (document.getElementById('content-filter-enabled').checked ? '1' : '0') +
+        '&blocked_words=' + encodeURIComponent(document.getElementById('content-blocked-words').value) +
+        '&flagged_words=' + encodeURIComponent(document.getElementById('content-flagged-words').value) +
+        '&max_urls=' + encodeURIComponent(document.getElementById('content-max-urls').value);
+    apiFetch('/admin/settings/content-filter', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: body
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('内容过滤设置已保存', false); loadContentFilterConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+var contentFlagTypeLabels = {custom_product: '自定义商品', storefront: '小铺', pack_listing: '分析包'};
+
+function loadContentFlags() {
+    apiFetch('/api/admin/content-flags').then(function(r) { return r.json(); }).then(function(flags) {
+        var tbody = document.getElementById('content-flags-tbody');
+        if (!flags || !flags.length) { tbody.innerHTML = '<tr><td colspan="6">暂无待复核内容</td></tr>'; return; }
+        tbody.innerHTML = flags.map(function(f) {
+            return '<tr><td>' + escHtml(contentFlagTypeLabels[f.item_type] || f.item_type) + '</td><td>' + f.item_id +
+                '</td><td>' + escHtml(f.excerpt) + '</td><td>' + escHtml(f.reason) + '</td><td>' + escHtml(f.created_at) +
+                '</td><td><button class="btn btn-secondary btn-sm" onclick="resolveContentFlag(' + f.id + ')">已复核</button></td></tr>';
+        }).join('');
+    }).catch(function() {});
+}
+
+function resolveContentFlag(id) {
+    apiFetch('/api/admin/content-flags/' + id + '/resolve', {method: 'POST'}).then(function(r) {
+        if (r.ok) { loadContentFlags(); } else { showMsg('操作失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadPublicAPIConfig() {
+    apiFetch('/admin/settings/public-api').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('public-api-cors-origins').value = d.cors_origins || '';
+        document.getElementById('public-api-cors-headers').value = d.cors_headers || '';
+        document.getElementById('public-api-rate-limit').value = d.rate_limit;
+    }).catch(function() {});
+}
+
+function savePublicAPIConfig(e) {
+    e.preventDefault();
+    var body = 'cors_origins=' + encodeURIComponent(document.getElementById('public-api-cors-origins').value) +
+        '&cors_headers=' + encodeURIComponent(document.getElementById('public-api-cors-headers').value.trim()) +
+        '&rate_limit=' + encodeURIComponent(document.getElementById('public-api-rate-limit').value.trim());
+    apiFetch('/admin/settings/public-api', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: body
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('公开 API 设置已保存', false); loadPublicAPIConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function downloadDBBackup() {
+    if (!confirm('确定要生成并下载数据库备份吗？此操作将被记录。')) return;
+    window.open('/api/admin/db-backup', '_blank');
+}
+
+function loadPackUploadConfig() {
+    apiFetch('/admin/settings/pack-upload').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('max-pack-size').value = d.max_pack_size_mb;
+    }).catch(function() {});
+}
+
+function savePackUploadConfig(e) {
+    e.preventDefault();
+    apiFetch('/admin/settings/pack-upload', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'max_pack_size_mb=' + encodeURIComponent(document.getElementById('max-pack-size').value.trim())
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('上传限制已保存', false); loadPackUploadConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadPackWatermarkConfig() {
+    apiFetch('/admin/settings/pack-watermark').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('pack-watermark-enabled').checked = !!d.enabled;
+    }).catch(function() {});
+}
+
+function savePackWatermarkConfig(e) {
+    e.preventDefault();
+    apiFetch('/admin/settings/pack-watermark', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'enabled=' + (document.getElementById('pack-watermark-enabled').checked ? '1' : '0')
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('水印设置已保存', false); loadPackWatermarkConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function lookupPackWatermark(e) {
+    e.preventDefault();
+    var el = document.getElementById('pack-watermark-result');
+    var mark = document.getElementById('pack-watermark-mark').value.trim();
+    apiFetch('/api/admin/pack-watermarks?mark=' + encodeURIComponent(mark)).then(function(r) {
+        return r.json().then(function(d) { return {ok: r.ok, data: d}; });
+    }).then(function(res) {
+        if (!res.ok) { el.textContent = '未找到该水印'; return; }
+        var d = res.data;
+        el.innerHTML = '用户 #' + d.user_id + ' ' + escHtml(d.email || d.display_name) +
+            '，分析包 #' + d.listing_id + ' ' + escHtml(d.pack_name) +
+            '，订单 #' + d.order_id + '，下载时间 ' + escHtml(d.created_at);
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function togglePackStorageFields() {
+    var backend = document.getElementById('pack-storage-backend').value;
+    document.querySelectorAll('.pack-storage-local').forEach(function(el) { el.style.display = backend === 'local' ? '' : 'none'; });
+    document.querySelectorAll('.pack-storage-s3').forEach(function(el) { el.style.display = backend === 's3' ? '' : 'none'; });
+}
+
+function loadPackStorageConfig() {
+    apiFetch('/admin/settings/pack-storage').then(function(r) { return r.json(); }).then(function(d) {
+        var c = d.config || {};
+        document.getElementById('pack-storage-backend').value = c.backend || 'db';
+        document.getElementById('pack-storage-local-dir').value = c.local_dir || '';
+        document.getElementById('pack-storage-s3-endpoint').value = c.s3_endpoint || '';
+        document.getElementById('pack-storage-s3-region').value = c.s3_region || '';
+        document.getElementById('pack-storage-s3-bucket').value = c.s3_bucket || '';
+        document.getElementById('pack-storage-s3-access-key').value = c.s3_access_key_id || '';
+        document.getElementById('pack-storage-s3-secret').value = '';
+        document.getElementById('pack-storage-s3-secret').placeholder = d.s3_secret_set ? '已设置，留空则不修改' : '';
+        document.getElementById('pack-storage-db-files').textContent = d.db_files;
+        togglePackStorageFields();
+    }).catch(function() {});
+}
+
+function savePackStorageConfig(e) {
+    e.preventDefault();
+    var data = new URLSearchParams();
+    data.append('backend', document.getElementById('pack-storage-backend').value);
+    data.append('local_dir', document.getElementById('pack-storage-local-dir').value.trim());
+    data.append('s3_endpoint', document.getElementById('pack-storage-s3-endpoint').value.trim());
+    data.append('s3_region', document.getElementById('pack-storage-s3-region').value.trim());
+    data.append('s3_bucket', document.getElementById('pack-storage-s3-bucket').value.trim());
+    data.append('s3_access_key_id', document.getElementById('pack-storage-s3-access-key').value.trim());
+    data.append('s3_secret_access_key', document.getElementById('pack-storage-s3-secret').value);
+    apiFetch('/admin/settings/pack-storage', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: data.toString()
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('文件存储配置已保存', false); loadPackStorageConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function migratePackStorage() {
+    if (!confirm('确定把数据库中的分析包文件迁移到当前存储吗？文件较多时需要一些时间。')) return;
+    apiFetch('/admin/settings/pack-storage/migrate', { method: 'POST' })
+    .then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (!res.ok) { showMsg(res.data.error || '操作失败', true); return; }
+        showMsg('已迁移 ' + res.data.moved + ' 个文件' + (res.data.failed ? '，' + res.data.failed + ' 个失败' : ''), res.data.failed > 0);
+        loadPackStorageConfig();
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadPackScanConfig() {
+    apiFetch('/admin/settings/pack-scan').then(function(r) { return r.json(); }).then(function(d) {
+        var c = d.config || {};
+        document.getElementById('pack-scan-enabled').checked = !!c.enabled;
+        document.getElementById('pack-scan-provider').value = c.provider || 'clamav';
+        document.getElementById('pack-scan-clamav-addr').value = c.clamav_addr || '';
+        document.getElementById('pack-scan-http-url').value = c.http_url || '';
+        document.getElementById('pack-scan-api-key').value = '';
+        document.getElementById('pack-scan-api-key').placeholder = d.api_key_set ? '已设置，留空则不修改' : '可选';
+        document.getElementById('pack-scan-hash-only').checked = !!c.send_hash_only;
+        document.getElementById('pack-scan-fail-closed').checked = !!c.fail_closed;
+        document.getElementById('pack-scan-timeout').value = c.timeout_seconds || '';
+    }).catch(function() {});
+}
+
+function savePackScanConfig(e) {
+    e.preventDefault();
+    var data = new URLSearchParams();
+    data.append('enabled', document.getElementById('pack-scan-enabled').checked ? '1' : '0');
+    data.append('provider', document.getElementById('pack-scan-provider').value);
+    data.append('clamav_addr', document.getElementById('pack-scan-clamav-addr').value.trim());
+    data.append('http_url', document.getElementById('pack-scan-http-url').value.trim());
+    data.append('api_key', document.getElementById('pack-scan-api-key').value);
+    data.append('send_hash_only', document.getElementById('pack-scan-hash-only').checked ? '1' : '0');
+    data.append('fail_closed', document.getElementById('pack-scan-fail-closed').checked ? '1' : '0');
+    data.append('timeout_seconds', document.getElementById('pack-scan-timeout').value.trim());
+    apiFetch('/admin/settings/pack-scan', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: data.toString()
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('上传扫描配置已保存', false); loadPackScanConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadEncryptionStatus() {
+    apiFetch('/admin/settings/encryption').then(function(r) { return r.ok ? r.json() : null; }).then(function(d) {
+        if (!d) return;
+        document.getElementById('encryption-key-id').textContent = d.key_id || '-';
+        document.getElementById('encryption-retired-keys').textContent = d.retired_keys;
+    }).catch(function() {});
+}
+
+function reencryptSecrets() {
+    if (!confirm('确定用当前密钥重新加密所有已保存的密钥吗？')) return;
+    apiFetch('/admin/settings/encryption', { method: 'POST' })
+    .then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (!res.ok) { showMsg(res.data.error || '操作失败', true); return; }
+        var d = res.data;
+        showMsg('已重新加密 ' + d.updated + ' 项，' + d.skipped + ' 项无需处理' + (d.failed ? '，' + d.failed + ' 项失败' : ''), d.failed > 0);
+        loadEncryptionStatus();
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
 function savePayPalConfig(e) {
     e.preventDefault();
     var data = new URLSearchParams();
@@ -1446,6 +2456,56 @@ function savePayPalConfig(e) {
     }).catch(function(err) { showMsg('请求失败: ' + err, true); });
 }
 
+// --- OAuth Login Config ---
+function loadOAuthConfig() {
+    apiFetch('/admin/settings/oauth').then(function(r) { return r.json(); })
+    .then(function(cfg) {
+        ['google', 'github'].forEach(function(p) {
+            var c = cfg[p] || {};
+            document.getElementById('oauth-' + p + '-client-id').value = c.client_id || '';
+            document.getElementById('oauth-' + p + '-client-secret').value = '';
+            document.getElementById('oauth-' + p + '-client-secret').placeholder = c.client_secret || '••••••••';
+        });
+    }).catch(function(err) {});
+}
+
+function saveOAuthConfig(e, provider) {
+    e.preventDefault();
+    apiFetch('/admin/settings/oauth', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({
+            provider: provider,
+            client_id: document.getElementById('oauth-' + provider + '-client-id').value.trim(),
+            client_secret: document.getElementById('oauth-' + provider + '-client-secret').value
+        })
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('登录配置已保存', false); loadOAuthConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadSiteURLConfig() {
+    apiFetch('/admin/settings/site-url').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('site-public-base-url').value = d.public_base_url || '';
+    }).catch(function() {});
+}
+
+function saveSiteURLConfig(e) {
+    e.preventDefault();
+    var val = document.getElementById('site-public-base-url').value.trim();
+    apiFetch('/admin/settings/site-url', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'public_base_url=' + encodeURIComponent(val)
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('站点地址已保存', false); loadSiteURLConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
 function saveServicePortalURL(e) {
     e.preventDefault();
     var val = document.getElementById('service-portal-url').value.trim();
@@ -1460,6 +2520,82 @@ function saveServicePortalURL(e) {
     }).catch(function(err) { showMsg('请求失败: ' + err, true); });
 }
 
+function loadHomepageCacheStatus() {
+    apiFetch('/admin/settings/homepage-cache').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('homepage-cache-ttl').value = d.ttl_seconds || '';
+        document.getElementById('cache-warmup-enabled').checked = !!d.warmup_enabled;
+        var refreshed = d.last_refreshed ? new Date(d.last_refreshed).toLocaleString() : '-';
+        if (d.last_refreshed && !d.cached) refreshed += '（已过期）';
+        document.getElementById('homepage-cache-refreshed').textContent = refreshed;
+    }).catch(function() {});
+}
+
+function saveHomepageCacheTTL(e) {
+    e.preventDefault();
+    var val = document.getElementById('homepage-cache-ttl').value.trim();
+    apiFetch('/admin/settings/homepage-cache', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'ttl_seconds=' + encodeURIComponent(val) + '&warmup_enabled=' + (document.getElementById('cache-warmup-enabled').checked ? 'true' : 'false')
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('首页缓存时间已保存', false); loadHomepageCacheStatus(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function refreshHomepageCache() {
+    apiFetch('/admin/settings/homepage-cache/refresh', { method: 'POST' })
+    .then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('首页缓存已清除，下次访问将重新加载', false); loadHomepageCacheStatus(); }
+        else { showMsg(res.data.error || '操作失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadTrendingConfig() {
+    apiFetch('/admin/settings/trending').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('trending-half-life').value = d.half_life_days || '';
+    }).catch(function() {});
+}
+
+function saveTrendingConfig(e) {
+    e.preventDefault();
+    var val = document.getElementById('trending-half-life').value.trim();
+    apiFetch('/admin/settings/trending', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'half_life_days=' + encodeURIComponent(val)
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('热门排序半衰期已保存', false); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
+function loadCSPConfig() {
+    apiFetch('/admin/settings/csp').then(function(r) { return r.json(); }).then(function(d) {
+        document.getElementById('csp-mode').value = d.mode || 'enforce';
+        document.getElementById('csp-report-uri').value = d.report_uri || '';
+        document.getElementById('csp-policy').textContent = d.policy || '';
+    }).catch(function() {});
+}
+
+function saveCSPConfig(e) {
+    e.preventDefault();
+    var mode = document.getElementById('csp-mode').value;
+    var reportURI = document.getElementById('csp-report-uri').value.trim();
+    apiFetch('/admin/settings/csp', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'mode=' + encodeURIComponent(mode) + '&report_uri=' + encodeURIComponent(reportURI)
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('安全策略已保存', false); loadCSPConfig(); }
+        else { showMsg(res.data.error || '保存失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
 function saveSupportParentProductID(e) {
     e.preventDefault();
     var val = document.getElementById('support-parent-product-id').value.trim();
@@ -1535,8 +2671,12 @@ function saveWithdrawalFees(e) {
         wire_transfer_fee_rate: parseFloat(document.getElementById('fee-rate-wire-transfer').value) || 0,
         bank_card_us_fee_rate: parseFloat(document.getElementById('fee-rate-bank-card-us').value) || 0,
         bank_card_eu_fee_rate: parseFloat(document.getElementById('fee-rate-bank-card-eu').value) || 0,
-        bank_card_cn_fee_rate: parseFloat(document.getElementById('fee-rate-bank-card-cn').value) || 0
+        bank_card_cn_fee_rate: parseFloat(document.getElementById('fee-rate-bank-card-cn').value) || 0,
+        min_withdrawal: parseFloat(document.getElementById('min-withdrawal').value) || 0,
+        fee_caps: {}
     };
+    var caps = document.querySelectorAll('.fee-cap-input');
+    for (var i = 0; i < caps.length; i++) { body.fee_caps[caps[i].getAttribute('data-type')] = parseFloat(caps[i].value) || 0; }
     apiFetch('/admin/api/settings/withdrawal-fees', {
         method: 'POST',
         headers: {'Content-Type': 'application/json'},
@@ -1592,8 +2732,18 @@ function loadAdmins() {
 function showAddAdminModal() {
     document.getElementById('new-admin-username').value = '';
     document.getElementById('new-admin-password').value = '';
-    var checks = document.querySelectorAll('.new-admin-perm');
-    for (var i = 0; i < checks.length; i++) checks[i].checked = false;
+    var box = document.getElementById('new-admin-perms');
+    box.innerHTML = '';
+    apiFetch('/api/admin/permissions').then(function(r) { return r.json(); }).then(function(data) {
+        var list = data.permissions || [];
+        var html = '';
+        for (var i = 0; i < list.length; i++) {
+            if (list[i].legacy) continue;
+            html += '<label style="display:flex;align-items:center;gap:4px;font-size:13px;font-weight:400;cursor:pointer;">';
+            html += '<input type="checkbox" value="' + escHtml(list[i].key) + '" class="new-admin-perm" /> ' + escHtml(window._i18n(list[i].label_key, list[i].label)) + '</label>';
+        }
+        box.innerHTML = html;
+    }).catch(function(err) { showMsg(window._i18n("request_failed","请求失败") + ': ' + err, true); });
     document.getElementById('add-admin-modal').className = 'modal-overlay show';
 }
 
@@ -1630,22 +2780,61 @@ function switchReviewTab(tabId, btn) {
     btn.classList.add('active');
     if (tabId === 'review-tab-packs') { loadPendingPacks(); }
     if (tabId === 'review-tab-custom-products') { loadPendingCustomProducts(); }
+    if (tabId === 'review-tab-quarantine') { loadQuarantinedPacks(); }
+}
+
+function loadQuarantinedPacks() {
+    apiFetch('/api/admin/review/quarantine').then(function(r) { return r.json(); }).then(function(data) {
+        var packs = data || [];
+        var tbody = document.getElementById('quarantine-list');
+        if (packs.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="6" style="text-align:center;color:#999;">' + window._i18n("no_quarantined_packs","暂无隔离的分析包") + '</td></tr>';
+            return;
+        }
+        var html = '';
+        for (var i = 0; i < packs.length; i++) {
+            var p = packs[i];
+            html += '<tr><td>' + p.id + '</td>';
+            html += '<td>' + escHtml(p.pack_name) + '</td>';
+            html += '<td>' + escHtml(p.author_name || '-') + '</td>';
+            html += '<td>' + escHtml(p.scan_status) + (p.scan_detail ? '<div style="font-size:11px;color:#c2410c;">' + escHtml(p.scan_detail) + '</div>' : '') + '</td>';
+            html += '<td>' + p.created_at + '</td>';
+            html += '<td class="actions"><button class="btn btn-secondary" onclick="releaseQuarantinedPack(' + p.id + ')">' + window._i18n("release_quarantine","解除隔离") + '</button></td></tr>';
+        }
+        tbody.innerHTML = html;
+    }).catch(function(err) { showMsg(window._i18n("request_failed","请求失败") + ': ' + err, true); });
+}
+
+function releaseQuarantinedPack(id) {
+    if (!confirm(window._i18n("confirm_release","确定解除隔离并转入待审核？"))) return;
+    apiFetch('/api/admin/review/' + id + '/release', { method: 'POST' })
+        .then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+        .then(function(res) {
+            if (res.ok) { loadQuarantinedPacks(); }
+            else { showMsg(res.data.error || window._i18n("operation_failed","操作失败"), true); }
+        }).catch(function(err) { showMsg(window._i18n("request_failed","请求失败") + ': ' + err, true); });
 }
 
 function loadPendingPacks() {
     apiFetch('/api/admin/review/pending').then(function(r) { return r.json(); }).then(function(data) {
         var packs = data || [];
         var tbody = document.getElementById('pending-list');
+        document.getElementById('pending-select-all').checked = false;
         if (packs.length === 0) {
-            tbody.innerHTML = '<tr><td colspan="8" style="text-align:center;color:#999;">' + window._i18n("no_pending_packs","暂无待审核分析包") + '</td></tr>';
+            tbody.innerHTML = '<tr><td colspan="9" style="text-align:center;color:#999;">' + window._i18n("no_pending_packs","暂无待审核分析包") + '</td></tr>';
             return;
         }
         var html = '';
         for (var i = 0; i < packs.length; i++) {
             var p = packs[i];
             html += '<tr>';
+            html += '<td><input type="checkbox" class="pending-select" value="' + p.id + '"></td>';
             html += '<td>' + p.id + '</td>';
-            html += '<td>' + escHtml(p.pack_name) + '</td>';
+            html += '<td>' + escHtml(p.pack_name);
+            if (p.publish_at) {
+                html += '<div style="font-size:11px;color:#4338ca;">⏰ ' + window._i18n("scheduled_publish_at","定时发布") + ': ' + escHtml(new Date(p.publish_at).toLocaleString()) + '</div>';
+            }
+            html += '</td>';
             html += '<td>' + escHtml(p.category_name) + '</td>';
             html += '<td>' + escHtml(p.author_name || '-') + '</td>';
             html += '<td>' + p.share_mode + '</td>';
@@ -1670,6 +2859,45 @@ function approvePack(id) {
         }).catch(function(err) { showMsg(window._i18n("request_failed","请求失败") + ': ' + err, true); });
 }
 
+function toggleAllPending(checked) {
+    var boxes = document.querySelectorAll('.pending-select');
+    for (var i = 0; i < boxes.length; i++) { boxes[i].checked = checked; }
+}
+
+function selectedPendingIDs() {
+    var ids = [];
+    var boxes = document.querySelectorAll('.pending-select:checked');
+    for (var i = 0; i < boxes.length; i++) { ids.push(parseInt(boxes[i].value, 10)); }
+    return ids;
+}
+
+function submitBulkReview(action, ids, reason) {
+    return apiFetch('/api/admin/review/' + action, {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({ids: ids, reason: reason || ''})
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (!res.ok) { showMsg(res.data.error || window._i18n("operation_failed","操作失败"), true); return false; }
+        var msg = window._i18n("bulk_review_done","已处理 {updated} 项，跳过 {skipped} 项").replace('{updated}', res.data.updated).replace('{skipped}', res.data.skipped);
+        showMsg(msg, res.data.skipped > 0);
+        loadPendingPacks();
+        return true;
+    }).catch(function(err) { showMsg(window._i18n("request_failed","请求失败") + ': ' + err, true); return false; });
+}
+
+function bulkApprovePacks() {
+    var ids = selectedPendingIDs();
+    if (ids.length === 0) { alert(window._i18n("select_packs_first","请先勾选分析包")); return; }
+    if (!confirm(window._i18n("confirm_bulk_approve","确定通过选中的 {n} 个分析包？").replace('{n}', ids.length))) return;
+    submitBulkReview('bulk-approve', ids);
+}
+
+function showBulkRejectModal() {
+    if (selectedPendingIDs().length === 0) { alert(window._i18n("select_packs_first","请先勾选分析包")); return; }
+    showRejectModal('bulk');
+}
+
 function showRejectModal(id) {
     document.getElementById('reject-pack-id').value = id;
     document.getElementById('reject-reason').value = '';
@@ -1684,6 +2912,10 @@ function submitReject() {
     var id = document.getElementById('reject-pack-id').value;
     var reason = document.getElementById('reject-reason').value.trim();
     if (!reason) { alert(window._i18n("enter_reject_reason","请输入拒绝原因")); return; }
+    if (id === 'bulk') {
+        submitBulkReview('bulk-reject', selectedPendingIDs(), reason).then(function(ok) { if (ok) hideRejectModal(); });
+        return;
+    }
     apiFetch('/api/admin/review/' + id + '/reject', {
         method: 'POST',
         headers: {'Content-Type': 'application/json'},
@@ -1922,13 +3154,18 @@ function showAccountDetail(email, page) {
         // Sub-accounts
         var subHtml = '';
         var subs = data.sub_accounts || [];
-        if (subs.length > 1) {
+        var canImpersonate = hasPerm('impersonate');
+        if (subs.length > 1 || canImpersonate) {
             subHtml += '<div style="font-size:12px;color:#6b7280;margin-bottom:8px;">' + window._i18n("sub_accounts","关联账号") + ' (' + subs.length + '):</div>';
             subHtml += '<div style="display:flex;flex-wrap:wrap;gap:6px;">';
             for (var si = 0; si < subs.length; si++) {
                 var s = subs[si];
                 var sStatus = s.is_blocked ? '🔴' : '🟢';
-                subHtml += '<span class="badge" style="background:#f1f5f9;color:#475569;font-size:11px;">' + sStatus + ' ' + escHtml(s.auth_id) + ' (' + escHtml(s.display_name) + ')</span>';
+                subHtml += '<span class="badge" style="background:#f1f5f9;color:#475569;font-size:11px;">' + sStatus + ' ' + escHtml(s.auth_id) + ' (' + escHtml(s.display_name) + ')';
+                if (canImpersonate && !s.is_blocked) {
+                    subHtml += ' <a href="javascript:void(0)" onclick="impersonateUser(' + s.id + ')" style="color:#b91c1c;">' + window._i18n("impersonate_mgmt","模拟登录") + '</a>';
+                }
+                subHtml += '</span>';
             }
             subHtml += '</div>';
         }
@@ -1968,6 +3205,18 @@ function showAccountDetail(email, page) {
     }).catch(function(err) { showMsg(window._i18n("load_account_detail_failed","加载账号详情失败") + ': ' + err, true); });
 }
 
+function impersonateUser(userId) {
+    if (!confirm(window._i18n("impersonate_confirm","确定要以该用户身份登录吗？此操作将被记录。"))) return;
+    apiFetch('/api/admin/impersonate', {
+        method: 'POST', headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({user_id: userId})
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { window.open(res.data.redirect, '_blank'); }
+        else { showMsg(res.data.error || window._i18n("operation_failed","操作失败"), true); }
+    }).catch(function(err) { showMsg(window._i18n("request_failed","请求失败") + ': ' + err, true); });
+}
+
 function switchAccountDetailTab(tab) {
     document.getElementById('acct-tab-packs').style.display = tab === 'packs' ? '' : 'none';
     document.getElementById('acct-tab-tx').style.display = tab === 'tx' ? '' : 'none';
@@ -2074,9 +3323,18 @@ function submitTopup() {
 function toggleAccountBlock(email, name, isCurrentlyBlocked) {
     var action = isCurrentlyBlocked ? window._i18n("unblock","解禁") : window._i18n("block","禁用");
     if (!confirm(window._i18n("confirm_block","确定要{action}客户 \"{name}\" 吗？").replace("{action}", action).replace("{name}", name + ' (' + email + ')'))) return;
+    var payload = {email: email};
+    if (!isCurrentlyBlocked) {
+        var reason = prompt(window._i18n("block_reason_prompt","封禁原因（将展示给用户，可留空）："), '');
+        if (reason === null) return;
+        var hours = prompt(window._i18n("block_duration_prompt","封禁时长（小时，0 或留空为永久）："), '0');
+        if (hours === null) return;
+        payload.reason = reason.trim();
+        payload.duration_hours = parseInt(hours, 10) || 0;
+    }
     apiFetch('/api/admin/accounts/toggle-block', {
         method: 'POST', headers: {'Content-Type': 'application/json'},
-        body: JSON.stringify({email: email})
+        body: JSON.stringify(payload)
     }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
     .then(function(res) {
         if (res.ok) { showMsg(res.data.status === 'blocked' ? window._i18n("blocked_done","已禁用") : window._i18n("unblocked_done","已解禁"), false); loadAccounts(); }
@@ -2146,6 +3404,71 @@ function saveProfile() {
     }).catch(function(err) { showMsg(window._i18n("request_failed","请求失败") + ': ' + err, true); });
 }
 
+// --- Admin Two-Factor Authentication ---
+function loadTOTPStatus() {
+    apiFetch('/api/admin/2fa/status').then(function(r) { return r.json(); }).then(function(d) {
+        var statusEl = document.getElementById('totp-status');
+        var actions = document.getElementById('totp-actions');
+        document.getElementById('totp-setup').style.display = 'none';
+        if (d.enabled) {
+            statusEl.textContent = window._i18n('admin_2fa_on', '已启用') + ' · ' + window._i18n('admin_2fa_recovery_left', '剩余恢复码') + ': ' + d.recovery_remaining;
+            actions.innerHTML = '<button class="btn btn-danger btn-sm" onclick="disableTOTP()">' + window._i18n('admin_2fa_disable', '停用') + '</button>';
+        } else {
+            statusEl.textContent = window._i18n('admin_2fa_off', '未启用');
+            actions.innerHTML = '<button class="btn btn-primary btn-sm" onclick="setupTOTP()">' + window._i18n('admin_2fa_setup', '设置两步验证') + '</button>';
+        }
+    }).catch(function() {});
+}
+
+function setupTOTP() {
+    apiFetch('/api/admin/2fa/setup', { method: 'POST' }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (!res.ok) { showMsg(res.data.error || window._i18n('change_failed', '修改失败'), true); return; }
+        document.getElementById('totp-secret').textContent = res.data.secret;
+        document.getElementById('totp-qr').src = '/api/admin/2fa/qr?t=' + Date.now();
+        document.getElementById('totp-setup').style.display = '';
+        document.getElementById('totp-actions').innerHTML = '';
+    });
+}
+
+function enableTOTP() {
+    var code = document.getElementById('totp-enable-code').value.trim();
+    apiFetch('/api/admin/2fa/enable', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({ code: code })
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (!res.ok) { showMsg(res.data.error || window._i18n('change_failed', '修改失败'), true); return; }
+        document.getElementById('totp-recovery-codes').textContent = (res.data.recovery_codes || []).join('\n');
+        document.getElementById('totp-recovery').style.display = '';
+        document.getElementById('totp-enable-code').value = '';
+        loadTOTPStatus();
+    });
+}
+
+function disableTOTP() {
+    var password = prompt(window._i18n('enter_current_pw_admin', '输入当前密码'));
+    if (!password) return;
+    var code = prompt(window._i18n('admin_2fa_code_prompt', '输入验证码或恢复码'));
+    if (!code) return;
+    apiFetch('/api/admin/2fa/disable', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({ password: password, code: code })
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (!res.ok) {
+            var errMsg = res.data.error;
+            if (errMsg === 'invalid_old_password') errMsg = window._i18n('invalid_old_password', '当前密码错误');
+            showMsg(errMsg || window._i18n('change_failed', '修改失败'), true);
+            return;
+        }
+        document.getElementById('totp-recovery').style.display = 'none';
+        loadTOTPStatus();
+    });
+}
+
 // --- Create Notification Modal ---
 var notifSelectedUsers = [];
 
@@ -2155,8 +3478,13 @@ function showCreateNotification() {
     document.getElementById('notif-target-type').value = 'broadcast';
     document.getElementById('notif-effective-date').value = '';
     document.getElementById('notif-duration').value = '0';
+    document.getElementById('notif-recurrence').value = 'none';
+    document.getElementById('notif-recurrence-end').value = '';
+    toggleNotifRecurrence();
     document.getElementById('notif-user-search').value = '';
     document.getElementById('notif-user-results').innerHTML = '';
+    ['notif-seg-listing', 'notif-seg-storefront', 'notif-seg-min-spend'].forEach(function(id) { document.getElementById(id).value = ''; });
+    document.getElementById('notif-seg-count').textContent = '';
     notifSelectedUsers = [];
     renderSelectedUsers();
     toggleTargetUsers();
@@ -2170,6 +3498,27 @@ function hideCreateNotification() {
 function toggleTargetUsers() {
     var type = document.getElementById('notif-target-type').value;
     document.getElementById('notif-target-section').style.display = type === 'targeted' ? '' : 'none';
+    document.getElementById('notif-segment-section').style.display = type === 'segment' ? '' : 'none';
+}
+
+function notifSegmentCriteria() {
+    return {
+        listing_id: parseInt(document.getElementById('notif-seg-listing').value) || 0,
+        storefront_id: parseInt(document.getElementById('notif-seg-storefront').value) || 0,
+        min_spend: parseFloat(document.getElementById('notif-seg-min-spend').value) || 0
+    };
+}
+
+function estimateNotifSegment() {
+    var out = document.getElementById('notif-seg-count');
+    apiFetch('/api/admin/notifications/estimate', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify(notifSegmentCriteria())
+    }).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        out.textContent = res.ok ? window._i18n('segment_count', '约 {count} 人').replace('{count}', res.data.count) : (res.data.error || '');
+    }).catch(function(err) { out.textContent = err; });
 }
 
 function searchNotifUsers() {
@@ -2247,6 +3596,7 @@ function loadNotifications() {
                 ? '<span class="badge" style="background:#ecfdf5;color:#065f46;">' + window._i18n("active","活跃") + '</span>'
                 : '<span class="badge" style="background:#f3f4f6;color:#6b7280;">' + window._i18n("disabled","已禁用") + '</span>';
             var durationText = n.display_duration_days === 0 ? window._i18n("permanent","永久") : n.display_duration_days + window._i18n("days_unit","天");
+            if (n.recurrence && n.recurrence !== 'none') durationText += ' · ' + window._i18n('recurrence_' + n.recurrence, n.recurrence);
             var toggleBtn = n.status === 'active'
                 ? '<button class="btn btn-secondary btn-sm" onclick="disableNotification(' + n.id + ')">' + window._i18n("disable","禁用") + '</button>'
                 : '<button class="btn btn-primary btn-sm" onclick="enableNotification(' + n.id + ')">' + window._i18n("enable","启用") + '</button>';
@@ -2265,6 +3615,36 @@ function loadNotifications() {
     }).catch(function(err) { showMsg(window._i18n("load_notifications_failed","加载消息列表失败") + ': ' + err, true); });
 }
 
+function toggleNotifRecurrence() {
+    var recurring = document.getElementById('notif-recurrence').value !== 'none';
+    document.getElementById('notif-recurrence-section').style.display = recurring ? 'block' : 'none';
+    document.getElementById('notif-occurrences').innerHTML = '';
+}
+
+function notifRecurrenceParams() {
+    var effectiveDate = document.getElementById('notif-effective-date').value;
+    var end = document.getElementById('notif-recurrence-end').value;
+    return {
+        effective_date: effectiveDate ? new Date(effectiveDate).toISOString() : '',
+        display_duration_days: parseInt(document.getElementById('notif-duration').value) || 0,
+        recurrence: document.getElementById('notif-recurrence').value,
+        recurrence_end: end ? new Date(end).toISOString() : ''
+    };
+}
+
+function previewNotifOccurrences() {
+    var p = notifRecurrenceParams();
+    var qs = Object.keys(p).map(function(k) { return k + '=' + encodeURIComponent(p[k]); }).join('&');
+    var box = document.getElementById('notif-occurrences');
+    apiFetch('/api/admin/notifications/occurrences?' + qs).then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (!res.ok) { box.textContent = res.data.error || ''; return; }
+        box.innerHTML = (res.data.occurrences || []).map(function(o) {
+            return '<div>' + escHtml(new Date(o.effective_date).toLocaleString()) + (o.ends_at ? ' → ' + escHtml(new Date(o.ends_at).toLocaleString()) : '') + '</div>';
+        }).join('');
+    }).catch(function(err) { box.textContent = err; });
+}
+
 function createNotification() {
     var title = document.getElementById('notif-title').value.trim();
     var content = document.getElementById('notif-content').value.trim();
@@ -2283,8 +3663,14 @@ function createNotification() {
         target_type: targetType,
         target_user_ids: targetUserIds,
         effective_date: effectiveDate ? new Date(effectiveDate).toISOString() : '',
-        display_duration_days: duration
+        display_duration_days: duration,
+        recurrence: notifRecurrenceParams().recurrence,
+        recurrence_end: notifRecurrenceParams().recurrence_end
     };
+    if (targetType === 'segment') {
+        body.target_type = 'targeted';
+        body.target_criteria = notifSegmentCriteria();
+    }
     apiFetch('/api/admin/notifications', {
         method: 'POST',
         headers: {'Content-Type': 'application/json'},
@@ -2362,6 +3748,17 @@ function exportWithdrawals() {
     window.open('/admin/api/withdrawals/export?ids=' + ids.join(','), '_blank');
 }
 
+function exportWithdrawalReport() {
+    var params = [];
+    var status = document.getElementById('wd-status-filter').value;
+    var from = document.getElementById('wd-date-from').value;
+    var to = document.getElementById('wd-date-to').value;
+    if (status) params.push('status=' + encodeURIComponent(status));
+    if (from) params.push('date_from=' + encodeURIComponent(from));
+    if (to) params.push('date_to=' + encodeURIComponent(to));
+    window.open('/admin/api/withdrawals/report' + (params.length ? '?' + params.join('&') : ''), '_blank');
+}
+
 function exportAndApproveWithdrawals() {
     var boxes = document.querySelectorAll('.wd-check:checked');
     var allIds = [];
@@ -2495,6 +3892,41 @@ function salesBuildFilterParams() {
     return params;
 }
 
+function loadFulfillmentJobs() {
+    apiFetch('/api/admin/fulfillment-jobs').then(function(r) { return r.json(); }).then(function(d) {
+        var tbody = document.getElementById('fulfillment-job-list');
+        var jobs = d.jobs || [];
+        if (jobs.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="8" style="text-align:center;color:#9ca3af;">暂无待发放订单</td></tr>';
+            return;
+        }
+        var statusText = { pending: '等待重试', running: '处理中', failed: '失败' };
+        var html = '';
+        jobs.forEach(function(j) {
+            html += '<tr>' +
+                '<td>#' + j.order_id + '</td>' +
+                '<td>' + escapeHtml(j.product_name) + '</td>' +
+                '<td>' + escapeHtml(j.buyer_email) + '</td>' +
+                '<td>' + (statusText[j.status] || j.status) + '</td>' +
+                '<td>' + j.attempts + '</td>' +
+                '<td style="max-width:260px;word-break:break-all;font-size:12px;">' + escapeHtml(j.last_error) + '</td>' +
+                '<td>' + (j.status === 'pending' ? escapeHtml(j.next_attempt_at) : '-') + '</td>' +
+                '<td>' + (j.status !== 'running' ? '<button class="btn btn-primary btn-sm" onclick="retryFulfillmentJob(' + j.id + ')">立即重试</button>' : '') + '</td>' +
+                '</tr>';
+        });
+        tbody.innerHTML = html;
+    }).catch(function() {});
+}
+
+function retryFulfillmentJob(id) {
+    apiFetch('/api/admin/fulfillment-jobs/' + id + '/retry', { method: 'POST' })
+    .then(function(r) { return r.json().then(function(d) { return {ok: r.ok, data: d}; }); })
+    .then(function(res) {
+        if (res.ok) { showMsg('已触发重试', false); setTimeout(loadFulfillmentJobs, 1500); }
+        else { showMsg(res.data.error || '操作失败', true); }
+    }).catch(function(err) { showMsg('请求失败: ' + err, true); });
+}
+
 function loadSalesData(page) {
     loadSalesFilterOptions();
     if (typeof page !== 'number' || page < 1) page = 1;
@@ -2962,12 +4394,115 @@ function moveFeatured(index, direction) {
     });
 }
 
+var featuredProductSearchTimer = null;
+
+function loadFeaturedProducts() {
+    apiFetch('/api/admin/featured-products').then(function(r) { return r.json(); }).then(function(data) {
+        if (!data.ok) { showMsg(data.error || 'Failed to load', true); return; }
+        var list = data.data || [];
+        var countEl = document.getElementById('featured-product-count');
+        countEl.textContent = window._i18n('featured_count_label', '已选') + ' ' + list.length + '/16 ' + window._i18n('featured_count_unit', '个');
+        countEl.style.color = list.length >= 16 ? '#ef4444' : '#6b7280';
+        var tbody = document.getElementById('featured-product-list');
+        if (list.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="4" style="text-align:center;color:#9ca3af;padding:32px;">' + window._i18n('no_featured_products', '暂无精选产品，请通过上方搜索添加') + '</td></tr>';
+            return;
+        }
+        var html = '';
+        for (var i = 0; i < list.length; i++) {
+            var p = list[i];
+            html += '<tr data-id="' + p.listing_id + '">';
+            html += '<td style="text-align:center;">';
+            if (i > 0) html += '<button class="btn btn-secondary btn-sm" onclick="moveFeaturedProduct(' + i + ',-1)" title="上移" style="padding:2px 6px;margin-right:4px;">↑</button>';
+            if (i < list.length - 1) html += '<button class="btn btn-secondary btn-sm" onclick="moveFeaturedProduct(' + i + ',1)" title="下移" style="padding:2px 6px;">↓</button>';
+            html += '</td>';
+            html += '<td>' + escapeHtml(p.pack_name);
+            if (p.status !== 'published') html += ' <span style="color:#ef4444;font-size:12px;">(' + escapeHtml(p.status) + ')</span>';
+            html += '</td>';
+            html += '<td>' + escapeHtml(p.author_name) + '</td>';
+            html += '<td><button class="btn btn-danger btn-sm" onclick="removeFeaturedProduct(' + p.listing_id + ')">' + window._i18n('remove', '移除') + '</button></td>';
+            html += '</tr>';
+        }
+        tbody.innerHTML = html;
+    });
+}
+
+function searchFeaturedProducts() {
+    clearTimeout(featuredProductSearchTimer);
+    var q = document.getElementById('featured-product-search-input').value.trim();
+    var resultsDiv = document.getElementById('featured-product-search-results');
+    if (q.length < 1) { resultsDiv.style.display = 'none'; return; }
+    featuredProductSearchTimer = setTimeout(function() {
+        apiFetch('/api/admin/featured-products/search?q=' + encodeURIComponent(q)).then(function(r) { return r.json(); }).then(function(data) {
+            if (!data.ok || !data.data || data.data.length === 0) {
+                resultsDiv.innerHTML = '<div style="padding:12px;color:#9ca3af;font-size:13px;">' + window._i18n('no_matching_products', '没有找到匹配的分析包') + '</div>';
+                resultsDiv.style.display = '';
+                return;
+            }
+            var html = '';
+            for (var i = 0; i < data.data.length; i++) {
+                var p = data.data[i];
+                html += '<div onclick="addFeaturedProduct(' + p.id + ')" style="padding:10px 14px;cursor:pointer;font-size:13px;border-bottom:1px solid #f3f4f6;transition:background 0.1s;"';
+                html += ' onmouseover="this.style.background=\'#f9fafb\'" onmouseout="this.style.background=\'#fff\'">';
+                html += escapeHtml(p.pack_name);
+                if (p.author_name) html += ' <span style="color:#9ca3af;font-size:12px;">(' + escapeHtml(p.author_name) + ')</span>';
+                html += '</div>';
+            }
+            resultsDiv.innerHTML = html;
+            resultsDiv.style.display = '';
+        });
+    }, 300);
+}
+
+function addFeaturedProduct(listingId) {
+    var fd = new FormData();
+    fd.append('listing_id', listingId);
+    apiFetch('/api/admin/featured-products', { method: 'POST', body: fd }).then(function(r) { return r.json(); }).then(function(data) {
+        if (!data.ok) { showMsg(data.error || 'Failed to add', true); return; }
+        document.getElementById('featured-product-search-input').value = '';
+        document.getElementById('featured-product-search-results').style.display = 'none';
+        showMsg(window._i18n('featured_product_added', '已添加为精选产品'));
+        loadFeaturedProducts();
+    });
+}
+
+function removeFeaturedProduct(listingId) {
+    if (!confirm(window._i18n('confirm_remove_featured_product', '确定移除该精选产品？'))) return;
+    var fd = new FormData();
+    fd.append('listing_id', listingId);
+    apiFetch('/api/admin/featured-products/remove', { method: 'POST', body: fd }).then(function(r) { return r.json(); }).then(function(data) {
+        if (!data.ok) { showMsg(data.error || 'Failed to remove', true); return; }
+        showMsg(window._i18n('featured_product_removed', '已移除精选产品'));
+        loadFeaturedProducts();
+    });
+}
+
+function moveFeaturedProduct(index, direction) {
+    var rows = document.querySelectorAll('#featured-product-list tr[data-id]');
+    var ids = [];
+    for (var i = 0; i < rows.length; i++) ids.push(rows[i].getAttribute('data-id'));
+    var newIndex = index + direction;
+    if (newIndex < 0 || newIndex >= ids.length) return;
+    var tmp = ids[index];
+    ids[index] = ids[newIndex];
+    ids[newIndex] = tmp;
+    var fd = new FormData();
+    fd.append('ids', ids.join(','));
+    apiFetch('/api/admin/featured-products/reorder', { method: 'POST', body: fd }).then(function(r) { return r.json(); }).then(function(data) {
+        if (!data.ok) { showMsg(data.error || 'Failed to reorder', true); return; }
+        loadFeaturedProducts();
+    });
+}
+
 // Hide search results when clicking outside
 document.addEventListener('click', function(e) {
-    var searchArea = document.getElementById('featured-search-input');
-    var resultsDiv = document.getElementById('featured-search-results');
-    if (searchArea && resultsDiv && !searchArea.contains(e.target) && !resultsDiv.contains(e.target)) {
-        resultsDiv.style.display = 'none';
+    var pairs = [['featured-search-input', 'featured-search-results'], ['featured-product-search-input', 'featured-product-search-results']];
+    for (var i = 0; i < pairs.length; i++) {
+        var searchArea = document.getElementById(pairs[i][0]);
+        var resultsDiv = document.getElementById(pairs[i][1]);
+        if (searchArea && resultsDiv && !searchArea.contains(e.target) && !resultsDiv.contains(e.target)) {
+            resultsDiv.style.display = 'none';
+        }
     }
 });
 
@@ -53,6 +53,10 @@ const loginHTML = `<!DOCTYPE html>
             <label for="password" data-i18n="password">密码</label>
             <input type="password" id="password" name="password" required autocomplete="current-password" />
         </div>
+        <div class="form-group">
+            <label for="totp_code" data-i18n="admin_totp_code">两步验证码（未启用可留空）</label>
+            <input type="text" id="totp_code" name="totp_code" autocomplete="one-time-code" inputmode="numeric" maxlength="9" />
+        </div>
         <div class="form-group">
             <label for="captcha" data-i18n="captcha">验证码</label>
             <div class="captcha-row">
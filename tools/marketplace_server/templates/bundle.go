@@ -0,0 +1,106 @@
+package templates
+
+import "html/template"
+
+// BundleTmpl is the parsed pack bundle page template.
+var BundleTmpl = template.Must(template.New("bundle").Funcs(BaseFuncMap).Parse(bundleHTML))
+
+const bundleHTML = `<!DOCTYPE html>
+<html lang="{{.HtmlLang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Bundle.Name}} - {{index .T "site_name"}}</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f8fafc; color: #1e293b; }
+        .page { max-width: 760px; margin: 0 auto; padding: 32px 20px 48px; }
+        .back { display: inline-block; font-size: 13px; color: #6366f1; text-decoration: none; margin-bottom: 16px; }
+        h1 { font-size: 24px; font-weight: 800; margin-bottom: 6px; }
+        .author { font-size: 13px; color: #64748b; margin-bottom: 16px; }
+        .desc { font-size: 14px; line-height: 1.7; color: #334155; white-space: pre-wrap; word-break: break-word; margin-bottom: 20px; }
+        .card { background: #fff; border: 1px solid #e2e8f0; border-radius: 14px; padding: 22px 24px; margin-bottom: 16px; }
+        .card h2 { font-size: 16px; font-weight: 700; margin-bottom: 12px; }
+        .item { display: flex; align-items: center; justify-content: space-between; gap: 12px; padding: 10px 0; border-top: 1px solid #f1f5f9; font-size: 14px; }
+        .item:first-of-type { border-top: none; }
+        .item a { color: #0f172a; text-decoration: none; font-weight: 600; }
+        .item a:hover { color: #6366f1; }
+        .item-meta { font-size: 12px; color: #64748b; white-space: nowrap; }
+        .item-price { font-size: 13px; color: #94a3b8; text-decoration: line-through; white-space: nowrap; }
+        .note { font-size: 12px; color: #64748b; margin-top: 10px; }
+        .buy { display: flex; align-items: center; justify-content: space-between; gap: 16px; flex-wrap: wrap; }
+        .price { font-size: 26px; font-weight: 800; color: #6366f1; }
+        .price-sub { font-size: 13px; color: #64748b; margin-top: 2px; }
+        .save { color: #16a34a; font-weight: 700; }
+        .btn { display: inline-block; border: none; border-radius: 10px; padding: 10px 22px; font-size: 14px; font-weight: 700; cursor: pointer; text-decoration: none; background: #6366f1; color: #fff; }
+        .btn:disabled { background: #cbd5e1; cursor: not-allowed; }
+        .msg { display: none; margin-top: 12px; font-size: 13px; padding: 10px 14px; border-radius: 10px; }
+        .msg-ok { background: #f0fdf4; color: #16a34a; }
+        .msg-err { background: #fef2f2; color: #dc2626; }
+    </style>
+</head>
+<body>
+<div class="page">
+    {{if .StorePublicID}}<a class="back" href="/store/{{.StorePublicID}}">&larr; {{index .T "back_to_store"}}</a>{{end}}
+    <h1>{{.Bundle.Name}}</h1>
+    <div class="author">{{.Bundle.AuthorName}}</div>
+    {{if .Bundle.Description}}<div class="desc">{{.Bundle.Description}}</div>{{end}}
+
+    <section class="card buy">
+        <div>
+            <div class="price">{{.Bundle.CreditsPrice}} Credits</div>
+            <div class="price-sub">{{index .T "bundle_list_total"}} {{.Bundle.ListTotal}} Credits{{if .Bundle.Savings}} · <span class="save">{{index .T "bundle_you_save"}} {{.Bundle.Savings}} Credits</span>{{end}}</div>
+        </div>
+        <div>
+            {{if not .Bundle.Available}}<button class="btn" disabled>{{index .T "bundle_unavailable"}}</button>
+            {{else if .IsAuthor}}<button class="btn" disabled>{{index .T "bundle_own"}}</button>
+            {{else if .IsLoggedIn}}<button class="btn" id="buyBtn" onclick="buyBundle()">{{index .T "bundle_buy"}}</button>
+            {{else}}<a class="btn" href="/user/login?redirect=/bundle/{{.Bundle.ID}}">{{index .T "login_to_buy"}}</a>{{end}}
+        </div>
+    </section>
+    <div class="msg msg-ok" id="successMsg"></div>
+    <div class="msg msg-err" id="errorMsg"></div>
+
+    <section class="card">
+        <h2>{{index .T "bundle_includes"}}</h2>
+        {{range .VisibleItems}}
+        <div class="item">
+            <div>
+                <a href="/pack/{{.ShareToken}}">{{.PackName}}</a>
+                <div class="item-meta">{{if eq .ShareMode "per_use"}}{{index $.T "bundle_one_use"}}{{else}}{{index $.T "bundle_one_month"}}{{end}}</div>
+            </div>
+            <span class="item-price">{{.CreditsPrice}} Credits</span>
+        </div>
+        {{end}}
+        {{if .OwnedCount}}<div class="note">{{printf (index .T "bundle_owned_note") .OwnedCount}}</div>{{end}}
+    </section>
+</div>
+<script>
+function buyBundle() {
+    var t = window._i18n || function(k, f) { return f; };
+    var b = document.getElementById('buyBtn'), ok = document.getElementById('successMsg'), err = document.getElementById('errorMsg');
+    if (!confirm(t('bundle_buy_confirm', '确认以 {{.Bundle.CreditsPrice}} Credits 购买此套餐？'))) return;
+    b.disabled = true;
+    ok.style.display = err.style.display = 'none';
+    fetch('/bundle/{{.Bundle.ID}}/purchase', { method: 'POST' })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.success) {
+            ok.textContent = t('purchase_success', '购买成功！');
+            ok.style.display = 'block';
+            setTimeout(function() { location.href = '/user/dashboard'; }, 1200);
+            return;
+        }
+        err.textContent = d.insufficient_balance ? t('insufficient_balance', '余额不足，当前余额') + ' ' + (d.balance || 0) + ' Credits' : (d.error || t('purchase_failed', '购买失败'));
+        err.style.display = 'block';
+        b.disabled = false;
+    }).catch(function() {
+        err.textContent = t('network_error', '网络错误');
+        err.style.display = 'block';
+        b.disabled = false;
+    });
+}
+</script>
+` + I18nJS + `
+</body>
+</html>`
@@ -87,6 +87,17 @@ const customProductManageHTML = `<!DOCTYPE html>
         .conditional-fields { display: none; }
         .empty-state { text-align: center; padding: 40px 20px; color: #94a3b8; font-size: 14px; }
         .reject-reason { font-size: 12px; color: #dc2626; margin-top: 4px; }
+        .import-hint { font-size: 12px; color: #64748b; margin-bottom: 12px; line-height: 1.6; }
+        .import-hint code { background: #f1f5f9; padding: 1px 4px; border-radius: 4px; }
+        .import-report { margin-top: 12px; font-size: 13px; }
+        .import-report table { width: 100%; border-collapse: collapse; margin-top: 8px; }
+        .import-report td { padding: 4px 6px; border-bottom: 1px solid #f1f5f9; }
+        .sale-info { font-size: 12px; color: #ef4444; margin-top: 4px; }
+        .sale-form { margin-top: 8px; font-size: 12px; }
+        .sale-form summary { cursor: pointer; color: #6366f1; }
+        .sale-form form { display: flex; flex-wrap: wrap; gap: 8px; align-items: flex-end; margin-top: 8px; }
+        .sale-form input { padding: 6px 8px; font-size: 13px; border: 1px solid #e2e8f0; border-radius: 6px; }
+        .sale-form .btn { padding: 6px 12px; font-size: 13px; }
     </style>
 </head>
 <body>
@@ -116,6 +127,7 @@ const customProductManageHTML = `<!DOCTYPE html>
                     <div class="product-meta">
                         <span>$ {{printf "%.2f" .PriceUSD}}</span>
                         {{if eq .ProductType "credits"}}<span>{{.CreditsAmount}} 积分</span>{{end}}
+                        {{if .SoldOut}}<span style="color:#dc2626;">已售罄</span>{{else if .StockQuantity}}<span>库存 {{.StockQuantity}}</span>{{end}}
                         <span class="status-badge status-{{.Status}}">
                             {{if eq .Status "draft"}}草稿{{end}}
                             {{if eq .Status "pending"}}待审核{{end}}
@@ -126,6 +138,38 @@ const customProductManageHTML = `<!DOCTYPE html>
                     {{if and (eq .Status "rejected") (ne .RejectReason "")}}
                     <div class="reject-reason">拒绝原因：{{.RejectReason}}</div>
                     {{end}}
+                    {{with index $.Sales .ID}}{{if .Price}}
+                    <div class="sale-info">⚡ 限时折扣 $ {{printf "%.2f" .Price}}（{{.Start.Format "2006-01-02 15:04"}} ~ {{.End.Format "2006-01-02 15:04"}} UTC）</div>
+                    {{end}}{{end}}
+                    <details class="sale-form">
+                        <summary>设置限时折扣</summary>
+                        <form method="POST" action="/user/storefront/custom-products/sale" onsubmit="return fillSaleWindow(this)">
+                            <input type="hidden" name="product_id" value="{{.ID}}">
+                            <input type="hidden" name="sale_start">
+                            <input type="hidden" name="sale_end">
+                            <label>折扣价 (USD)<br><input type="number" name="sale_price" step="0.01" min="0.01" required></label>
+                            <label>开始时间<br><input type="datetime-local" data-target="sale_start" required></label>
+                            <label>结束时间<br><input type="datetime-local" data-target="sale_end" required></label>
+                            <button type="submit" class="btn btn-primary">保存</button>
+                        </form>
+                        <form method="POST" action="/user/storefront/custom-products/sale">
+                            <input type="hidden" name="product_id" value="{{.ID}}">
+                            <input type="hidden" name="clear" value="1">
+                            <button type="submit" class="btn">取消折扣</button>
+                        </form>
+                        <div class="import-hint">折扣价须低于原价，最长 30 天，仅在时间段内生效。</div>
+                    </details>
+                    {{if eq .ProductType "virtual_goods"}}
+                    <details class="sale-form">
+                        <summary>设置库存</summary>
+                        <form method="POST" action="/user/storefront/custom-products/stock">
+                            <input type="hidden" name="product_id" value="{{.ID}}">
+                            <label>剩余库存<br><input type="number" name="stock_quantity" min="0" max="1000000" step="1" value="{{if .StockQuantity}}{{.StockQuantity}}{{end}}" placeholder="不限"></label>
+                            <button type="submit" class="btn btn-primary">保存</button>
+                        </form>
+                        <div class="import-hint">授权数量有限时填写剩余库存，每完成一笔订单自动减一，售罄后停止接单；留空表示不限库存。</div>
+                    </details>
+                    {{end}}
                 </div>
             </div>
             {{end}}
@@ -179,13 +223,75 @@ const customProductManageHTML = `<!DOCTYPE html>
             <button type="submit" class="btn btn-primary">创建商品</button>
         </form>
     </div>
+    <div class="card" id="importForm">
+        <div class="card-title">📥 批量导入 (CSV)</div>
+        <div class="import-hint">
+            首行为表头，支持列：<code>product_name</code>、<code>description</code>、<code>product_type</code>（credits / virtual_goods）、<code>price_usd</code>、<code>credits_amount</code>、<code>license_api_endpoint</code>、<code>license_api_key</code>、<code>license_product_id</code>。
+            导入的商品为草稿状态，名称已存在的行将被跳过。
+        </div>
+        <form id="import-form" onsubmit="return importProducts(event)">
+            <div class="form-group">
+                <input type="file" name="file" accept=".csv,text/csv" required>
+            </div>
+            <div class="form-group">
+                <label style="font-weight:normal;"><input type="checkbox" name="all_or_nothing" value="1" style="width:auto;"> 任意一行出错时不导入任何商品</label>
+            </div>
+            <button type="submit" class="btn btn-primary" id="import-btn">导入</button>
+        </form>
+        <div class="import-report" id="import-report"></div>
+    </div>
 </div>
 <script>
+function fillSaleWindow(form) {
+    var inputs = form.querySelectorAll('input[type=datetime-local]');
+    for (var i = 0; i < inputs.length; i++) {
+        var d = new Date(inputs[i].value);
+        if (isNaN(d.getTime())) return false;
+        form.elements[inputs[i].getAttribute('data-target')].value = d.toISOString();
+    }
+    return true;
+}
 function toggleTypeFields() {
     var t = document.getElementById('product_type').value;
     document.getElementById('credits-fields').style.display = t === 'credits' ? 'block' : 'none';
     document.getElementById('virtual-fields').style.display = t === 'virtual_goods' ? 'block' : 'none';
 }
+function importProducts(e) {
+    e.preventDefault();
+    var btn = document.getElementById('import-btn');
+    var report = document.getElementById('import-report');
+    btn.disabled = true;
+    report.textContent = '导入中...';
+    fetch('/user/storefront/custom-products/import', {method: 'POST', body: new FormData(document.getElementById('import-form'))})
+        .then(function(r) { return r.json(); })
+        .then(function(d) {
+            btn.disabled = false;
+            report.textContent = '';
+            var summary = document.createElement('div');
+            summary.textContent = d.error ? d.error : ('已导入 ' + d.imported + ' 个，跳过 ' + d.skipped + ' 个，错误 ' + d.failed + ' 个');
+            summary.className = 'msg ' + (d.error ? 'msg-err' : 'msg-ok');
+            report.appendChild(summary);
+            var rows = (d.rows || []).filter(function(row) { return row.status !== 'imported'; });
+            if (rows.length) {
+                var table = document.createElement('table');
+                rows.forEach(function(row) {
+                    var tr = table.insertRow();
+                    tr.insertCell().textContent = '第 ' + row.line + ' 行';
+                    tr.insertCell().textContent = row.product_name || '';
+                    tr.insertCell().textContent = row.error || '';
+                });
+                report.appendChild(table);
+            }
+            if (d.imported > 0) {
+                var reload = document.createElement('a');
+                reload.href = '/user/storefront/custom-products';
+                reload.textContent = '刷新商品列表';
+                report.appendChild(reload);
+            }
+        })
+        .catch(function() { btn.disabled = false; report.textContent = '导入失败'; });
+    return false;
+}
 </script>
 </body>
 </html>`
@@ -21,7 +21,8 @@ var homepageFuncMap = template.FuncMap{
 		}
 		return string(runes[0])
 	},
-	"logoURL": func() string { return LogoURL },
+	"logoURL":      func() string { return LogoURL },
+	"markdownText": MarkdownPlainText,
 }
 
 // HomepageTmpl is the parsed template for the marketplace homepage.
@@ -36,6 +37,7 @@ const homepageHTML = `<!DOCTYPE html>
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="default-lang" content="{{.DefaultLang}}">
     <title data-i18n="homepage.title">分析技能包市场</title>
+    <link rel="alternate" type="application/atom+xml" title="分析技能包市场" href="/feed.xml">
     <style>
         *,*::before,*::after { margin: 0; padding: 0; box-sizing: border-box; }
         body {
@@ -169,6 +171,11 @@ const homepageHTML = `<!DOCTYPE html>
             overflow: hidden; text-overflow: ellipsis; white-space: nowrap;
             max-width: 100%;
         }
+        .store-card-verified {
+            display: inline-flex; align-items: center; justify-content: center;
+            width: 14px; height: 14px; margin-left: 4px; vertical-align: -2px;
+            border-radius: 50%; background: #0ea5e9; color: #fff;
+        }
         .store-card-desc {
             font-size: 12px; color: #64748b; line-height: 1.5;
             overflow: hidden; text-overflow: ellipsis;
@@ -346,13 +353,56 @@ const homepageHTML = `<!DOCTYPE html>
             <a class="store-card" href="/store/{{.PublicID}}">
                 <div class="store-card-avatar">
                     {{if .HasLogo}}
-                    <img src="/store/{{.PublicID}}/logo" alt="{{.StoreName}}">
+                    <img src="/store/{{.PublicID}}/logo/thumb" alt="{{.StoreName}}">
                     {{else}}
                     <div class="store-card-avatar-letter">{{firstChar .StoreName}}</div>
                     {{end}}
                 </div>
-                <div class="store-card-name" title="{{.StoreName}}">{{.StoreName}}</div>
-                <div class="store-card-desc">{{truncateDesc .Description 80}}</div>
+                <div class="store-card-name" title="{{.StoreName}}">{{.StoreName}}{{if .Verified}}<span class="store-card-verified" title="认证卖家" data-i18n-title="verified_seller"><svg viewBox="0 0 24 24" width="10" height="10" fill="none" stroke="currentColor" stroke-width="3" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg></span>{{end}}</div>
+                <div class="store-card-desc">{{truncateDesc (markdownText .Description) 80}}</div>
+            </a>
+            {{end}}
+        </div>
+    </div>
+    {{end}}
+
+    <!-- Featured Products Section -->
+    {{if .FeaturedProducts}}
+    <div class="section">
+        <h2 class="section-title">
+            <svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 2l3.09 6.26L22 9.27l-5 4.87 1.18 6.88L12 17.77l-6.18 3.25L7 14.14 2 9.27l6.91-1.01L12 2z"/></svg>
+            <span data-i18n="homepage.featured_products">精选产品</span>
+        </h2>
+        <div class="card-grid">
+            {{range .FeaturedProducts}}
+            <a class="product-card" href="/pack/{{.ShareToken}}">
+                <div class="product-card-top">
+                    <div class="product-card-icon">
+                        <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 16V8a2 2 0 0 0-1-1.73l-7-4a2 2 0 0 0-2 0l-7 4A2 2 0 0 0 3 8v8a2 2 0 0 0 1 1.73l7 4a2 2 0 0 0 2 0l7-4A2 2 0 0 0 21 16z"/><polyline points="3.27 6.96 12 12.01 20.73 6.96"/><line x1="12" y1="22.08" x2="12" y2="12"/></svg>
+                    </div>
+                    <div class="product-card-title">
+                        <span class="product-card-name" title="{{.PackName}}">{{.PackName}}</span>
+                        {{if eq .ShareMode "free"}}<span class="product-tag tag-free" data-i18n="free">免费</span>
+                        {{else if eq .ShareMode "per_use"}}<span class="product-tag tag-per-use" data-i18n="per_use">按次</span>
+                        {{else if eq .ShareMode "subscription"}}<span class="product-tag tag-subscription" data-i18n="subscription">订阅</span>
+                        {{end}}
+                    </div>
+                </div>
+                <div class="product-card-author">{{.AuthorName}}</div>
+                {{if .PackDesc}}<div class="product-card-desc">{{markdownText .PackDesc}}</div>{{end}}
+                <div class="product-card-footer">
+                    {{if eq .ShareMode "free"}}
+                    <span class="product-card-price price-free" data-i18n="free">免费</span>
+                    {{else if eq .ShareMode "per_use"}}
+                    <span class="product-card-price">{{.CreditsPrice}} Credits/<span data-i18n="homepage.per_use_unit">次</span></span>
+                    {{else if eq .ShareMode "subscription"}}
+                    <span class="product-card-price">{{.CreditsPrice}} Credits/<span data-i18n="homepage.monthly_unit">月</span></span>
+                    {{end}}
+                    <span class="product-card-downloads">
+                        <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4"/><polyline points="7 10 12 15 17 10"/><line x1="12" y1="15" x2="12" y2="3"/></svg>
+                        {{.DownloadCount}}
+                    </span>
+                </div>
             </a>
             {{end}}
         </div>
@@ -371,13 +421,13 @@ const homepageHTML = `<!DOCTYPE html>
             <a class="store-card" href="/store/{{.PublicID}}">
                 <div class="store-card-avatar">
                     {{if .HasLogo}}
-                    <img src="/store/{{.PublicID}}/logo" alt="{{.StoreName}}">
+                    <img src="/store/{{.PublicID}}/logo/thumb" alt="{{.StoreName}}">
                     {{else}}
                     <div class="store-card-avatar-letter">{{firstChar .StoreName}}</div>
                     {{end}}
                 </div>
-                <div class="store-card-name" title="{{.StoreName}}">{{.StoreName}}</div>
-                <div class="store-card-desc">{{truncateDesc .Description 80}}</div>
+                <div class="store-card-name" title="{{.StoreName}}">{{.StoreName}}{{if .Verified}}<span class="store-card-verified" title="认证卖家" data-i18n-title="verified_seller"><svg viewBox="0 0 24 24" width="10" height="10" fill="none" stroke="currentColor" stroke-width="3" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg></span>{{end}}</div>
+                <div class="store-card-desc">{{truncateDesc (markdownText .Description) 80}}</div>
             </a>
             {{end}}
         </div>
@@ -396,13 +446,56 @@ const homepageHTML = `<!DOCTYPE html>
             <a class="store-card" href="/store/{{.PublicID}}">
                 <div class="store-card-avatar">
                     {{if .HasLogo}}
-                    <img src="/store/{{.PublicID}}/logo" alt="{{.StoreName}}">
+                    <img src="/store/{{.PublicID}}/logo/thumb" alt="{{.StoreName}}">
                     {{else}}
                     <div class="store-card-avatar-letter">{{firstChar .StoreName}}</div>
                     {{end}}
                 </div>
-                <div class="store-card-name" title="{{.StoreName}}">{{.StoreName}}</div>
-                <div class="store-card-desc">{{truncateDesc .Description 80}}</div>
+                <div class="store-card-name" title="{{.StoreName}}">{{.StoreName}}{{if .Verified}}<span class="store-card-verified" title="认证卖家" data-i18n-title="verified_seller"><svg viewBox="0 0 24 24" width="10" height="10" fill="none" stroke="currentColor" stroke-width="3" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg></span>{{end}}</div>
+                <div class="store-card-desc">{{truncateDesc (markdownText .Description) 80}}</div>
+            </a>
+            {{end}}
+        </div>
+    </div>
+    {{end}}
+
+    <!-- Trending Products Section: recent sales weighted by time decay -->
+    {{if .TrendingProducts}}
+    <div class="section">
+        <h2 class="section-title">
+            <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><polyline points="23 6 13.5 15.5 8.5 10.5 1 18"/><polyline points="17 6 23 6 23 12"/></svg>
+            <span data-i18n="homepage.trending_products">近期热门</span>
+        </h2>
+        <div class="card-grid">
+            {{range .TrendingProducts}}
+            <a class="product-card" href="/pack/{{.ShareToken}}">
+                <div class="product-card-top">
+                    <div class="product-card-icon">
+                        <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 16V8a2 2 0 0 0-1-1.73l-7-4a2 2 0 0 0-2 0l-7 4A2 2 0 0 0 3 8v8a2 2 0 0 0 1 1.73l7 4a2 2 0 0 0 2 0l7-4A2 2 0 0 0 21 16z"/><polyline points="3.27 6.96 12 12.01 20.73 6.96"/><line x1="12" y1="22.08" x2="12" y2="12"/></svg>
+                    </div>
+                    <div class="product-card-title">
+                        <span class="product-card-name" title="{{.PackName}}">{{.PackName}}</span>
+                        {{if eq .ShareMode "free"}}<span class="product-tag tag-free" data-i18n="free">免费</span>
+                        {{else if eq .ShareMode "per_use"}}<span class="product-tag tag-per-use" data-i18n="per_use">按次</span>
+                        {{else if eq .ShareMode "subscription"}}<span class="product-tag tag-subscription" data-i18n="subscription">订阅</span>
+                        {{end}}
+                    </div>
+                </div>
+                <div class="product-card-author">{{.AuthorName}}</div>
+                {{if .PackDesc}}<div class="product-card-desc">{{markdownText .PackDesc}}</div>{{end}}
+                <div class="product-card-footer">
+                    {{if eq .ShareMode "free"}}
+                    <span class="product-card-price price-free" data-i18n="free">免费</span>
+                    {{else if eq .ShareMode "per_use"}}
+                    <span class="product-card-price">{{.CreditsPrice}} Credits/<span data-i18n="homepage.per_use_unit">次</span></span>
+                    {{else if eq .ShareMode "subscription"}}
+                    <span class="product-card-price">{{.CreditsPrice}} Credits/<span data-i18n="homepage.monthly_unit">月</span></span>
+                    {{end}}
+                    <span class="product-card-downloads">
+                        <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4"/><polyline points="7 10 12 15 17 10"/><line x1="12" y1="15" x2="12" y2="3"/></svg>
+                        {{.DownloadCount}}
+                    </span>
+                </div>
             </a>
             {{end}}
         </div>
@@ -432,7 +525,7 @@ const homepageHTML = `<!DOCTYPE html>
                     </div>
                 </div>
                 <div class="product-card-author">{{.AuthorName}}</div>
-                {{if .PackDesc}}<div class="product-card-desc">{{.PackDesc}}</div>{{end}}
+                {{if .PackDesc}}<div class="product-card-desc">{{markdownText .PackDesc}}</div>{{end}}
                 <div class="product-card-footer">
                     {{if eq .ShareMode "free"}}
                     <span class="product-card-price price-free" data-i18n="free">免费</span>
@@ -475,7 +568,7 @@ const homepageHTML = `<!DOCTYPE html>
                     </div>
                 </div>
                 <div class="product-card-author">{{.AuthorName}}</div>
-                {{if .PackDesc}}<div class="product-card-desc">{{.PackDesc}}</div>{{end}}
+                {{if .PackDesc}}<div class="product-card-desc">{{markdownText .PackDesc}}</div>{{end}}
                 <div class="product-card-footer">
                     {{if eq .ShareMode "free"}}
                     <span class="product-card-price price-free" data-i18n="free">免费</span>
@@ -549,7 +642,7 @@ const homepageHTML = `<!DOCTYPE html>
                     </div>
                 </div>
                 <div class="product-card-author">{{.AuthorName}}</div>
-                {{if .PackDesc}}<div class="product-card-desc">{{.PackDesc}}</div>{{end}}
+                {{if .PackDesc}}<div class="product-card-desc">{{markdownText .PackDesc}}</div>{{end}}
                 <div class="product-card-footer">
                     {{if eq .ShareMode "free"}}
                     <span class="product-card-price price-free" data-i18n="free">免费</span>
@@ -7,7 +7,11 @@ import "html/template"
 // Default fallback is the unversioned path.
 var LogoURL = "/marketplace-logo.png"
 
-// BaseFuncMap provides the logoURL function shared by all templates.
+// BaseFuncMap provides the logoURL, formatMoney and description Markdown
+// functions shared by all templates.
 var BaseFuncMap = template.FuncMap{
-	"logoURL": func() string { return LogoURL },
+	"logoURL":        func() string { return LogoURL },
+	"formatMoney":    formatMoney,
+	"renderMarkdown": RenderMarkdown,
+	"markdownText":   MarkdownPlainText,
 }
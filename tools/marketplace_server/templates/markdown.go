@@ -0,0 +1,303 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Description Markdown
+//
+// Pack, custom product and storefront descriptions are written in a small
+// Markdown dialect: paragraphs, # headings, - / 1. lists, > quotes, ``` code
+// blocks, --- rules, and inline **bold**, *italic*, `code` and [text](url).
+// Raw HTML in the source is always escaped. The generated HTML then goes
+// through sanitizeDescriptionHTML, which keeps only mdAllowedTags and safe
+// link targets, so nothing executable can reach the page even if the
+// renderer has a bug. Input that cannot be rendered falls back to escaped
+// plain text with line breaks.
+
+// maxMarkdownInput bounds the work done per description; longer input is
+// shown as plain text.
+const maxMarkdownInput = 20000
+
+// mdAllowedTags are the only elements that survive sanitization.
+var mdAllowedTags = map[string]bool{
+	"p": true, "br": true, "strong": true, "em": true, "code": true, "pre": true,
+	"ul": true, "ol": true, "li": true, "blockquote": true, "hr": true, "a": true,
+	"h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// mdVoidTags have no end tag.
+var mdVoidTags = map[string]bool{"br": true, "hr": true}
+
+// mdDroppedContentTags are removed together with everything inside them.
+var mdDroppedContentTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "template": true, "textarea": true, "title": true, "svg": true, "math": true,
+}
+
+var (
+	mdHeading          = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdBullet           = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	mdOrdered          = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+	mdRule             = regexp.MustCompile(`^\s{0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdInlineCode       = regexp.MustCompile("`([^`\n]+)`")
+	mdBold             = regexp.MustCompile(`\*\*([^*\n]+?)\*\*|__([^_\n]+?)__`)
+	mdItalic           = regexp.MustCompile(`\*([^*\n]+?)\*|\b_([^_\n]+?)_\b`)
+	mdLink             = regexp.MustCompile(`\[([^\]\n]+)\]\(([^)\s]+)\)`)
+	mdCodeHolder       = regexp.MustCompile("\x00(\\d+)\x00")
+	errMarkdownTooLong = errors.New("markdown: input too long")
+)
+
+// RenderMarkdown renders a description to sanitized HTML.
+func RenderMarkdown(s string) template.HTML {
+	out, err := renderMarkdownHTML(s)
+	if err != nil {
+		return plainTextHTML(s)
+	}
+	return template.HTML(out)
+}
+
+// MarkdownPlainText returns the visible text of a description with the
+// Markdown syntax removed, for previews and meta tags.
+func MarkdownPlainText(s string) string {
+	out, err := renderMarkdownHTML(s)
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.TextToken:
+			b.Write(z.Text())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			b.WriteByte(' ')
+		}
+	}
+}
+
+// plainTextHTML is the raw-text fallback: escaped, with line breaks kept.
+func plainTextHTML(s string) template.HTML {
+	return template.HTML(strings.ReplaceAll(template.HTMLEscapeString(s), "\n", "<br>"))
+}
+
+// renderMarkdownHTML renders s and sanitizes the result.
+func renderMarkdownHTML(s string) (out string, err error) {
+	if len(s) > maxMarkdownInput {
+		return "", errMarkdownTooLong
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("markdown: %v", r)
+		}
+	}()
+	return sanitizeDescriptionHTML(markdownToHTML(s))
+}
+
+// markdownToHTML converts the block structure of s, escaping all text.
+func markdownToHTML(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	var para []string
+	listTag := ""
+
+	flushPara := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + strings.Join(para, "<br>") + "</p>")
+			para = nil
+		}
+	}
+	closeList := func() {
+		if listTag != "" {
+			b.WriteString("</" + listTag + ">")
+			listTag = ""
+		}
+	}
+	openList := func(tag string) {
+		if listTag != tag {
+			closeList()
+			b.WriteString("<" + tag + ">")
+			listTag = tag
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flushPara()
+			closeList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, template.HTMLEscapeString(lines[i]))
+			}
+			b.WriteString("<pre><code>" + strings.Join(code, "\n") + "</code></pre>")
+		case trimmed == "":
+			flushPara()
+			closeList()
+		case mdRule.MatchString(line):
+			flushPara()
+			closeList()
+			b.WriteString("<hr>")
+		case mdHeading.MatchString(trimmed):
+			flushPara()
+			closeList()
+			m := mdHeading.FindStringSubmatch(trimmed)
+			// Page titles use h1/h2, so description headings start at h3.
+			level := len(m[1]) + 2
+			if level > 6 {
+				level = 6
+			}
+			fmt.Fprintf(&b, "<h%d>%s</h%d>", level, markdownInline(m[2]), level)
+		case mdBullet.MatchString(line):
+			flushPara()
+			openList("ul")
+			b.WriteString("<li>" + markdownInline(mdBullet.FindStringSubmatch(line)[1]) + "</li>")
+		case mdOrdered.MatchString(line):
+			flushPara()
+			openList("ol")
+			b.WriteString("<li>" + markdownInline(mdOrdered.FindStringSubmatch(line)[1]) + "</li>")
+		case strings.HasPrefix(trimmed, ">"):
+			flushPara()
+			closeList()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, markdownInline(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">"))))
+			}
+			i--
+			b.WriteString("<blockquote>" + strings.Join(quote, "<br>") + "</blockquote>")
+		default:
+			closeList()
+			para = append(para, markdownInline(trimmed))
+		}
+	}
+	flushPara()
+	closeList()
+	return b.String()
+}
+
+// markdownInline escapes s and applies the inline rules. Code spans are
+// swapped out first so their contents are not formatted.
+func markdownInline(s string) string {
+	s = template.HTMLEscapeString(s)
+	var codes []string
+	s = mdInlineCode.ReplaceAllStringFunc(s, func(m string) string {
+		codes = append(codes, "<code>"+mdInlineCode.FindStringSubmatch(m)[1]+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(codes)-1)
+	})
+	s = mdLink.ReplaceAllStringFunc(s, func(m string) string {
+		parts := mdLink.FindStringSubmatch(m)
+		if !safeLinkURL(html.UnescapeString(parts[2])) {
+			return parts[1]
+		}
+		return `<a href="` + parts[2] + `">` + parts[1] + `</a>`
+	})
+	s = mdBold.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = mdItalic.ReplaceAllString(s, "<em>$1$2</em>")
+	return mdCodeHolder.ReplaceAllStringFunc(s, func(m string) string {
+		var n int
+		fmt.Sscanf(strings.Trim(m, "\x00"), "%d", &n)
+		return codes[n]
+	})
+}
+
+// safeLinkURL reports whether u is an http(s) or mailto link.
+func safeLinkURL(u string) bool {
+	l := strings.ToLower(strings.TrimSpace(u))
+	return strings.HasPrefix(l, "https://") || strings.HasPrefix(l, "http://") || strings.HasPrefix(l, "mailto:")
+}
+
+// sanitizeDescriptionHTML keeps only mdAllowedTags, drops every attribute
+// except a safe href on links, removes script-like elements with their
+// content and closes any element left open.
+func sanitizeDescriptionHTML(in string) (string, error) {
+	var b strings.Builder
+	var open []string
+	skipDepth := 0
+	skipTag := ""
+
+	z := html.NewTokenizer(strings.NewReader(in))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return "", z.Err()
+			}
+			break
+		}
+		tok := z.Token()
+
+		if skipDepth > 0 {
+			switch {
+			case tt == html.StartTagToken && tok.Data == skipTag:
+				skipDepth++
+			case tt == html.EndTagToken && tok.Data == skipTag:
+				skipDepth--
+			}
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			b.WriteString(html.EscapeString(tok.Data))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if mdDroppedContentTags[tok.Data] {
+				if tt == html.StartTagToken {
+					skipDepth, skipTag = 1, tok.Data
+				}
+				continue
+			}
+			if !mdAllowedTags[tok.Data] {
+				continue
+			}
+			if tok.Data == "a" {
+				href := ""
+				for _, a := range tok.Attr {
+					if a.Key == "href" && safeLinkURL(a.Val) {
+						href = a.Val
+					}
+				}
+				if href == "" {
+					b.WriteString(`<a>`)
+				} else {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `" target="_blank" rel="nofollow noopener noreferrer">`)
+				}
+			} else {
+				b.WriteString("<" + tok.Data + ">")
+			}
+			if !mdVoidTags[tok.Data] {
+				open = append(open, tok.Data)
+			}
+		case html.EndTagToken:
+			// Only close elements that are open, innermost first.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == tok.Data {
+					for j := len(open) - 1; j >= i; j-- {
+						b.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String(), nil
+}
+
+// markdownCSS styles rendered descriptions; pages wrap them in class="md".
+const markdownCSS = `.md p,.md ul,.md ol,.md pre,.md blockquote,.md h3,.md h4,.md h5,.md h6{margin:0 0 6px;}.md>:last-child{margin-bottom:0;}.md ul,.md ol{padding-left:18px;}.md h3,.md h4,.md h5,.md h6{font-size:1.05em;font-weight:700;}.md blockquote{padding-left:10px;border-left:3px solid #e2e8f0;color:#64748b;}.md pre{background:rgba(0,0,0,0.05);padding:8px 10px;border-radius:6px;overflow-x:auto;}.md code{font-family:monospace;font-size:0.92em;}.md a{color:inherit;text-decoration:underline;}.md hr{border:none;border-top:1px solid #e2e8f0;margin:8px 0;}
+`
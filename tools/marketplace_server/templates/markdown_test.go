@@ -0,0 +1,95 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestRenderMarkdownFormatting(t *testing.T) {
+	got := string(RenderMarkdown("# Title\n\nSome **bold** and *italic* with `a*b*c`.\n\n- one\n- [two](https://example.com/?a=1&b=2)\n\n> quoted"))
+	for _, want := range []string{
+		"<h3>Title</h3>",
+		"<strong>bold</strong>",
+		"<em>italic</em>",
+		"<code>a*b*c</code>",
+		"<ul><li>one</li>",
+		`<a href="https://example.com/?a=1&amp;b=2" target="_blank" rel="nofollow noopener noreferrer">two</a>`,
+		"<blockquote>quoted</blockquote>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in %s", want, got)
+		}
+	}
+}
+
+func TestRenderMarkdownNeutralizesXSS(t *testing.T) {
+	payloads := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`<svg onload=alert(1)>`,
+		`<style>body{display:none}</style>`,
+		`<a href="javascript:alert(1)">x</a>`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`,
+		`[x](https://example.com/" onmouseover="alert(1))`,
+		"**<iframe src=//evil>**",
+		"```\n</code></pre><script>alert(1)</script>\n```",
+		`<p onclick="alert(1)">hi</p>`,
+		"`<b onmouseover=alert(1)>`",
+	}
+	for _, p := range payloads {
+		assertSafeDescriptionHTML(t, p, string(RenderMarkdown(p)))
+	}
+}
+
+// assertSafeDescriptionHTML fails unless out only contains allowed elements,
+// links with an http(s)/mailto href, and no other attributes.
+func assertSafeDescriptionHTML(t *testing.T, in, out string) {
+	t.Helper()
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+		tok := z.Token()
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		if !mdAllowedTags[tok.Data] {
+			t.Errorf("RenderMarkdown(%q) kept <%s>: %s", in, tok.Data, out)
+		}
+		for _, a := range tok.Attr {
+			switch {
+			case tok.Data == "a" && a.Key == "href" && safeLinkURL(a.Val):
+			case tok.Data == "a" && (a.Key == "target" || a.Key == "rel"):
+			default:
+				t.Errorf("RenderMarkdown(%q) kept %s=%q on <%s>: %s", in, a.Key, a.Val, tok.Data, out)
+			}
+		}
+	}
+}
+
+func TestSanitizeDescriptionHTML(t *testing.T) {
+	got, err := sanitizeDescriptionHTML(`<p style="x" onclick="y">a<script>b</script><div>c</div><a href="javascript:z">d</a><em>e`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<p>ac<a>d</a><em>e</em></p>`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRenderMarkdownFallback(t *testing.T) {
+	long := strings.Repeat("<b>x</b>\n", maxMarkdownInput/8)
+	got := string(RenderMarkdown(long))
+	if strings.Contains(got, "<b>") || !strings.Contains(got, "&lt;b&gt;x&lt;/b&gt;<br>") {
+		t.Errorf("fallback is not escaped plain text: %.80s", got)
+	}
+	if got := MarkdownPlainText("**Hello** [world](https://example.com)\n\n- a"); got != "Hello world a" {
+		t.Errorf("MarkdownPlainText = %q", got)
+	}
+}
@@ -0,0 +1,9 @@
+package templates
+
+import "marketplace_server/i18n"
+
+// formatMoney formats an amount in the given currency for display. lang is a
+// language tag such as "en-US"; an unknown tag uses the currency code suffix.
+func formatMoney(amount float64, currency, lang string) string {
+	return i18n.FormatMoney(amount, currency, i18n.ParseLang(lang))
+}
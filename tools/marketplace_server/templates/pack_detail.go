@@ -12,12 +12,13 @@ const packDetailHTML = `<!DOCTYPE html>
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>{{.PackName}} - 分析技能包市场</title>
     <meta property="og:title" content="{{.PackName}} - 分析技能包市场" />
-    <meta property="og:description" content="{{.PackDescription}}" />
+    <meta property="og:description" content="{{markdownText .PackDescription}}" />
     <meta property="og:type" content="product" />
     <meta name="twitter:card" content="summary_large_image" />
     <meta name="twitter:title" content="{{.PackName}}" />
-    <meta name="twitter:description" content="{{.PackDescription}}" />
+    <meta name="twitter:description" content="{{markdownText .PackDescription}}" />
     <style>
+        ` + markdownCSS + `
         *,*::before,*::after{margin:0;padding:0;box-sizing:border-box}
         body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,"Microsoft YaHei",sans-serif;background:#f8f9fc;min-height:100vh;color:#1e293b;-webkit-font-smoothing:antialiased}
         .page{max-width:720px;margin:0 auto;padding:24px 20px 36px}
@@ -47,7 +48,7 @@ const packDetailHTML = `<!DOCTYPE html>
         .pack-author{display:flex;align-items:center;gap:6px;font-size:13px;color:#64748b;font-weight:500}
         .pack-author svg{opacity:.5}
         .hero-desc{margin-top:12px;padding:10px 14px;background:rgba(255,255,255,0.55);border-radius:8px;border:1px solid rgba(226,232,240,0.5);overflow:hidden}
-        .hero-desc-text{font-size:13px;color:#475569;line-height:1.6;overflow:hidden;text-overflow:ellipsis;display:-webkit-box;-webkit-line-clamp:2;-webkit-box-orient:vertical}
+        .hero-desc-text{font-size:13px;color:#475569;line-height:1.6}
         .dl-btn{display:inline-flex;align-items:center;gap:5px;padding:7px 14px;border-radius:8px;font-size:13px;font-weight:600;text-decoration:none;transition:all .25s;border:1px solid #e2e8f0;background:#fff;color:#475569}
         .dl-btn:hover{background:#f8fafc;border-color:#cbd5e1;box-shadow:0 2px 8px rgba(0,0,0,0.06);transform:translateY(-1px)}
         .dl-btn svg{width:16px;height:16px;flex-shrink:0}
@@ -58,6 +59,9 @@ const packDetailHTML = `<!DOCTYPE html>
         .price-free{color:#16a34a}
         .price-unit{font-size:14px;font-weight:600}
         .price-sub{font-size:12px;color:#94a3b8;margin-top:2px}
+        .price-original{font-size:14px;font-weight:600;color:#94a3b8;text-decoration:line-through;margin-left:6px}
+        .sale-badge{display:inline-block;font-size:11px;font-weight:700;color:#fff;background:#ef4444;border-radius:4px;padding:1px 6px;margin-right:6px}
+        .sale-countdown{font-size:12px;color:#ef4444;font-weight:600;margin-top:2px}
         .btn{padding:11px 24px;border:none;border-radius:12px;font-size:14px;font-weight:600;cursor:pointer;display:inline-flex;align-items:center;gap:7px;text-decoration:none;transition:all .25s cubic-bezier(.4,0,.2,1);font-family:inherit;white-space:nowrap}
         .btn-green{background:linear-gradient(135deg,#22c55e,#16a34a);color:#fff;box-shadow:0 2px 8px rgba(34,197,94,0.25)}
         .btn-green:hover{box-shadow:0 4px 16px rgba(34,197,94,0.3);transform:translateY(-1px)}
@@ -90,6 +94,27 @@ const packDetailHTML = `<!DOCTYPE html>
         .nav-store{padding:7px 16px;font-size:13px;font-weight:500;color:#6366f1;background:#eef2ff;border:1px solid #c7d2fe;border-radius:8px;text-decoration:none;transition:all .2s;display:inline-flex;align-items:center;gap:5px}
         .nav-store:hover{background:#e0e7ff;border-color:#a5b4fc;box-shadow:0 1px 3px rgba(99,102,241,0.12)}
         .nav-store svg{width:14px;height:14px}
+        .gallery{background:#fff;border:1px solid #e2e8f0;border-radius:16px;padding:14px;margin-bottom:12px}
+        .gallery-main{display:block;width:100%;max-height:420px;object-fit:contain;border-radius:10px;background:#f8fafc}
+        .gallery-thumbs{display:flex;gap:8px;margin-top:10px;overflow-x:auto}
+        .gallery-thumb{flex-shrink:0;width:88px;height:56px;padding:0;border:2px solid transparent;border-radius:8px;overflow:hidden;cursor:pointer;background:#f8fafc}
+        .gallery-thumb.active{border-color:#6366f1}
+        .gallery-thumb img{width:100%;height:100%;object-fit:cover;display:block}
+        .related{margin-top:20px}
+        .related-title{font-size:14px;font-weight:700;color:#1e293b;margin-bottom:10px}
+        .related-grid{display:grid;grid-template-columns:repeat(2,1fr);gap:10px}
+        @media(max-width:560px){.related-grid{grid-template-columns:1fr}}
+        .related-card{display:block;background:#fff;border:1px solid #e2e8f0;border-radius:12px;padding:12px 14px;text-decoration:none;color:inherit;transition:all .2s}
+        .related-card:hover{border-color:#c7d2fe;box-shadow:0 2px 8px rgba(99,102,241,0.1)}
+        .related-name{font-size:14px;font-weight:600;color:#0f172a;white-space:nowrap;overflow:hidden;text-overflow:ellipsis;margin-bottom:4px}
+        .related-meta{display:flex;align-items:center;justify-content:space-between;gap:8px;font-size:12px;color:#64748b}
+        .related-price{font-weight:700;color:#6366f1;white-space:nowrap}
+        .related-price .price-original{font-size:11px;margin-left:4px}
+        .bundles{margin-top:20px}
+        .bundle-card{display:flex;align-items:center;justify-content:space-between;gap:12px;background:#fff;border:1px solid #e2e8f0;border-radius:12px;padding:12px 14px;margin-bottom:8px;text-decoration:none;color:inherit;transition:all .2s}
+        .bundle-card:hover{border-color:#c7d2fe;box-shadow:0 2px 8px rgba(99,102,241,0.1)}
+        .bundle-save{font-size:11px;font-weight:700;color:#16a34a;margin-left:6px}
+        .related-price.price-free{color:#16a34a}
         .foot{text-align:center;margin-top:28px;padding-top:16px;border-top:1px solid #e2e8f0}
         .foot-text{font-size:11px;color:#94a3b8}
         .foot-text a{color:#6366f1;text-decoration:none}
@@ -113,7 +138,7 @@ const packDetailHTML = `<!DOCTYPE html>
         </div>
         <h1 class="pack-title">{{.PackName}}</h1>
         <p class="pack-author"><svg width="14" height="14" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M20 21v-2a4 4 0 0 0-4-4H8a4 4 0 0 0-4 4v2"/><circle cx="12" cy="7" r="4"/></svg> {{.AuthorName}}</p>
-        {{if .PackDescription}}<div class="hero-desc"><span class="hero-desc-text">{{.PackDescription}}</span></div>{{end}}
+        {{if .PackDescription}}<div class="hero-desc"><div class="hero-desc-text md">{{renderMarkdown .PackDescription}}</div></div>{{end}}
         </div>
         <div class="hero-right">
             <div class="hero-stat"><div class="hero-stat-label" data-i18n="data_source">数据源</div><div class="hero-stat-val">{{.SourceName}}</div></div>
@@ -126,10 +151,16 @@ const packDetailHTML = `<!DOCTYPE html>
             </div>
         </div>
     </div></div>
+    {{if .Images}}
+    <div class="gallery">
+        <a id="galleryLink" href="{{(index .Images 0).URL}}" target="_blank" rel="noopener"><img class="gallery-main" id="galleryMain" src="{{(index .Images 0).URL}}" alt="{{.PackName}}"></a>
+        {{if gt (len .Images) 1}}<div class="gallery-thumbs">{{range $i, $img := .Images}}<button type="button" class="gallery-thumb{{if eq $i 0}} active{{end}}" data-src="{{$img.URL}}" onclick="showShot(this)"><img src="{{$img.URL}}" alt="" loading="lazy"></button>{{end}}</div>{{end}}
+    </div>
+    {{end}}
     <div class="action-bar">
         <div>
             {{if eq .ShareMode "free"}}<div class="price price-free" data-i18n="free">免费</div><div class="price-sub" data-i18n="no_credits_free">无需 Credits，直接领取</div>
-            {{else}}<div class="price">{{.CreditsPrice}} <span class="price-unit">Credits</span></div><div class="price-sub">{{if eq .ShareMode "per_use"}}<span data-i18n="per_use_label">每次使用</span>{{else}}<span data-i18n="monthly_sub">每月订阅</span>{{end}}</div>{{end}}
+            {{else}}<div class="price">{{if .OriginalPrice}}<span class="sale-badge" data-i18n="flash_sale">限时折扣</span>{{end}}{{.CreditsPrice}} <span class="price-unit">Credits</span>{{if .OriginalPrice}}<span class="price-original">{{.OriginalPrice}}</span>{{end}}</div>{{if .SaleEndsAt}}<div class="sale-countdown" data-ends="{{.SaleEndsAt}}"></div>{{end}}<div class="price-sub">{{if eq .ShareMode "per_use"}}<span data-i18n="per_use_label">每次使用</span>{{else}}<span data-i18n="monthly_sub">每月订阅</span>{{end}}</div>{{end}}
         </div>
         <div>
             {{if not .IsLoggedIn}}
@@ -152,6 +183,18 @@ const packDetailHTML = `<!DOCTYPE html>
     {{end}}
     <div class="msg msg-ok" id="successMsg"></div>
     <div class="msg msg-err" id="errorMsg"></div>
+    {{if .Bundles}}
+    <div class="bundles">
+        <div class="related-title" data-i18n="bundle_offers">优惠套餐</div>
+        {{range .Bundles}}<a class="bundle-card" href="/bundle/{{.ID}}"><div><div class="related-name">{{.Name}}</div><div class="related-meta"><span>{{len .Items}} <span data-i18n="bundle_packs_count">个分析包</span></span></div></div><span class="related-price">{{.CreditsPrice}} Credits{{if .Savings}}<span class="bundle-save"><span data-i18n="bundle_you_save">节省</span> {{.Savings}}</span>{{end}}</span></a>{{end}}
+    </div>
+    {{end}}
+    {{if .Related}}
+    <div class="related">
+        <div class="related-title" data-i18n="related_packs">相关推荐</div>
+        <div class="related-grid">{{range .Related}}<a class="related-card" href="/pack/{{.ShareToken}}"><div class="related-name">{{.PackName}}</div><div class="related-meta"><span>{{if .SameAuthor}}<span data-i18n="same_author">同一作者</span>{{else}}{{.AuthorName}}{{end}} · {{.DownloadCount}} <span data-i18n="downloads">下载</span></span>{{if eq .ShareMode "free"}}<span class="related-price price-free" data-i18n="free">免费</span>{{else}}<span class="related-price">{{.CreditsPrice}} Credits{{if .OriginalPrice}}<span class="price-original">{{.OriginalPrice}}</span>{{end}}</span>{{end}}</div></a>{{end}}</div>
+    </div>
+    {{end}}
     {{end}}
     <div class="foot"><p class="foot-text">Vantagics <span data-i18n="site_name">分析技能包市场</span> · <a href="/" data-i18n="browse_more">浏览更多</a></p></div>
 </div>
@@ -159,6 +202,7 @@ const packDetailHTML = `<!DOCTYPE html>
 <script>
 var listingID={{.ListingID}},shareToken="{{.ShareToken}}",creditsPrice={{.CreditsPrice}},shareMode="{{.ShareMode}}";
 var dlURLWindows="{{.DownloadURLWindows}}",dlURLMacOS="{{.DownloadURLMacOS}}";
+(function(){var els=document.querySelectorAll(".sale-countdown[data-ends]");if(!els.length)return;function pad(n){return n<10?"0"+n:n}function tick(){var now=Date.now(),t=window._i18n||function(k,f){return f};els.forEach(function(el){var s=Math.floor((new Date(el.getAttribute("data-ends")).getTime()-now)/1000);if(s<=0){el.textContent=t("sale_ended","折扣已结束");return}var d=Math.floor(s/86400),h=Math.floor(s%86400/3600),m=Math.floor(s%3600/60);el.textContent=t("sale_ends_in","距结束")+" "+(d>0?d+"d ":"")+pad(h)+":"+pad(m)+":"+pad(s%60)})}tick();setInterval(tick,1000)})();
 (function(){var u=encodeURIComponent(location.href),t=encodeURIComponent(document.title),x=document.getElementById("shareX"),l=document.getElementById("shareLI");if(x)x.href="https://twitter.com/intent/tweet?text="+t+"&url="+u;if(l)l.href="https://www.linkedin.com/sharing/share-offsite/?url="+u})();
 (function(){
     var c=document.getElementById("dlButtons");if(!c)return;
@@ -196,6 +240,13 @@ var dlURLWindows="{{.DownloadURLWindows}}",dlURLMacOS="{{.DownloadURLMacOS}}";
     }
     fit();window.addEventListener('resize',fit);
 })();
+function showShot(btn){
+    var src=btn.getAttribute('data-src');
+    document.getElementById('galleryMain').src=src;
+    document.getElementById('galleryLink').href=src;
+    var thumbs=document.querySelectorAll('.gallery-thumb');
+    for(var i=0;i<thumbs.length;i++)thumbs[i].classList.toggle('active',thumbs[i]===btn);
+}
 function showMsg(a,b){var s=document.getElementById("successMsg"),e=document.getElementById("errorMsg");if(s)s.style.display="none";if(e)e.style.display="none";if(a==="success"&&s){s.textContent=b;s.style.display="block"}else if(e){e.textContent=b;e.style.display="block"}}
 function copyLink(){navigator.clipboard.writeText(location.href).then(function(){var t=document.getElementById("copyToast");t.classList.add("show");setTimeout(function(){t.classList.remove("show")},2e3)})}
 function claimPack(){if(!confirm(window._i18n("add_to_purchased_confirm","是否将此分析包添加到您的已购分析技能包中？")))return;var b=document.getElementById("claimBtn");b.disabled=!0;b.innerHTML=window._i18n("claiming","领取中...");fetch("/pack/"+shareToken+"/claim",{method:"POST",headers:{"Content-Type":"application/json"}}).then(function(r){return r.json()}).then(function(d){if(d.success){showMsg("success",window._i18n("claim_success","领取成功！"));b.outerHTML='<div class="badge-owned">'+window._i18n("claimed","已领取")+'</div>'}else{showMsg("error",d.error||window._i18n("claim_failed","领取失败"));b.disabled=!1;b.innerHTML=window._i18n("claim_free","免费领取")}}).catch(function(){showMsg("error",window._i18n("network_error","网络错误"));b.disabled=!1;b.innerHTML=window._i18n("claim_free","免费领取")})}
@@ -1,7 +1,6 @@
 package templates
 
 import (
-	"fmt"
 	"html/template"
 	"regexp"
 	"strings"
@@ -23,12 +22,6 @@ var storefrontFuncMap = template.FuncMap{
 		}
 		return string(runes[0])
 	},
-	"formatPriceUSD": func(price float64) string {
-		if price == float64(int(price)) {
-			return fmt.Sprintf("$%.0f", price)
-		}
-		return fmt.Sprintf("$%.2f", price)
-	},
 	"productTypeLabel": func(productType string) string {
 		switch productType {
 		case "credits":
@@ -42,7 +35,10 @@ var storefrontFuncMap = template.FuncMap{
 	"renderBannerMarkdown": func(s string) template.HTML {
 		return template.HTML(bannerMarkdownToHTML(s))
 	},
-	"logoURL": func() string { return LogoURL },
+	"renderMarkdown": RenderMarkdown,
+	"markdownText":   MarkdownPlainText,
+	"logoURL":        func() string { return LogoURL },
+	"formatMoney":    formatMoney,
 }
 
 // bannerMarkdownToHTML converts a subset of markdown to safe HTML for banner text.
@@ -88,22 +84,25 @@ const storefrontHTML = `<!DOCTYPE html>
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="default-lang" content="{{.DefaultLang}}">
     <title>{{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}小铺{{end}}</title>
+    <link rel="alternate" type="application/atom+xml" title="{{.Storefront.StoreName}}" href="/store/{{.Storefront.PublicID}}/feed.xml">
     <meta property="og:type" content="website" />
     <meta property="og:title" content="{{if .Storefront.StoreName}}{{.Storefront.StoreName}}的小铺{{else}}小铺{{end}}" />
-    <meta property="og:description" content="{{if .Storefront.Description}}{{truncateDesc .Storefront.Description 200}}{{else}}该作者暂未设置小铺描述{{end}}" />
+    <meta property="og:description" content="{{if .Storefront.Description}}{{truncateDesc (markdownText .Storefront.Description) 200}}{{else}}该作者暂未设置小铺描述{{end}}" />
     {{if .Storefront.HasLogo}}<meta property="og:image" content="/store/{{.Storefront.ID}}/logo" />{{end}}
     <meta name="twitter:card" content="summary" />
     <meta name="twitter:title" content="{{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}小铺{{end}}" />
-    <meta name="twitter:description" content="{{if .Storefront.Description}}{{truncateDesc .Storefront.Description 200}}{{else}}该作者暂未设置小铺描述{{end}}" />
+    <meta name="twitter:description" content="{{if .Storefront.Description}}{{truncateDesc (markdownText .Storefront.Description) 200}}{{else}}该作者暂未设置小铺描述{{end}}" />
     {{if .Storefront.HasLogo}}<meta name="twitter:image" content="/store/{{.Storefront.ID}}/logo" />{{end}}
     <style>:root { {{.ThemeCSS}} }</style>
+    {{if .ThemeDarkCSS}}<style>@media (prefers-color-scheme: dark) { :root { {{.ThemeDarkCSS}} } }</style>{{end}}
     <style>
+        ` + markdownCSS + `
         *,*::before,*::after { margin: 0; padding: 0; box-sizing: border-box; }
         body {
             font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Microsoft YaHei", sans-serif;
-            background: #f8f9fc;
+            background: var(--page-bg, #f8f9fc);
             min-height: 100vh;
-            color: #1e293b;
+            color: var(--text-color, #1e293b);
             line-height: 1.6;
             -webkit-font-smoothing: antialiased;
             -moz-osx-font-smoothing: grayscale;
@@ -114,8 +113,8 @@ const storefrontHTML = `<!DOCTYPE html>
         .nav {
             display: flex; align-items: center; justify-content: space-between;
             margin-bottom: 28px; padding: 12px 16px;
-            background: #fff; border-radius: 14px;
-            border: 1px solid #e2e8f0;
+            background: var(--surface-bg, #fff); border-radius: 14px;
+            border: 1px solid var(--surface-border, #e2e8f0);
             box-shadow: 0 1px 3px rgba(0,0,0,0.04);
         }
         .logo-link {
@@ -128,7 +127,7 @@ const storefrontHTML = `<!DOCTYPE html>
             box-shadow: 0 2px 8px rgba(99,102,241,0.25);
         }
         .logo-mark img { width: 100%; height: 100%; object-fit: cover; }
-        .logo-text { font-size: 15px; font-weight: 700; color: #0f172a; letter-spacing: -0.3px; }
+        .logo-text { font-size: 15px; font-weight: 700; color: var(--text-strong, #0f172a); letter-spacing: -0.3px; }
         
         /* ── Store Name in Nav ── */
         .store-name-nav {
@@ -140,10 +139,16 @@ const storefrontHTML = `<!DOCTYPE html>
             padding: 0 20px;
             gap: 4px;
         }
+        .verified-badge {
+            display: inline-flex; align-items: center; gap: 3px;
+            padding: 2px 8px; margin-left: 6px; vertical-align: middle;
+            font-size: 11px; font-weight: 700; letter-spacing: 0;
+            color: #0369a1; background: #e0f2fe; border: 1px solid #bae6fd; border-radius: 999px;
+        }
         .store-name-nav .store-name-title {
             font-size: 20px;
             font-weight: 800;
-            color: #0f172a;
+            color: var(--text-strong, #0f172a);
             letter-spacing: -0.5px;
             margin: 0;
             line-height: 1.2;
@@ -152,7 +157,7 @@ const storefrontHTML = `<!DOCTYPE html>
         }
         .store-name-nav .powered-by-link {
             font-size: 11px;
-            color: #94a3b8;
+            color: var(--text-muted, #94a3b8);
             text-decoration: none;
             font-weight: 500;
             transition: color 0.2s;
@@ -219,11 +224,11 @@ const storefrontHTML = `<!DOCTYPE html>
             font-size: 38px; font-weight: 800; color: #fff;
         }
         .store-name {
-            font-size: 22px; font-weight: 800; color: #0f172a;
+            font-size: 22px; font-weight: 800; color: var(--text-strong, #0f172a);
             margin-bottom: 8px; letter-spacing: -0.4px;
         }
         .store-desc {
-            font-size: 13px; color: #475569; line-height: 1.7;
+            font-size: 13px; color: var(--text-secondary, #475569); line-height: 1.7;
             max-width: 220px;
         }
         .store-stats {
@@ -231,11 +236,13 @@ const storefrontHTML = `<!DOCTYPE html>
         }
         .store-stat {
             display: flex; flex-direction: column; align-items: center;
-            padding: 6px 12px; background: rgba(255,255,255,0.6);
+            padding: 6px 12px; background: var(--glass-bg, rgba(255,255,255,0.6));
             border-radius: 8px; border: 1px solid rgba(226,232,240,0.5);
         }
         .store-stat-val { font-size: 16px; font-weight: 800; color: var(--primary-hover); }
-        .store-stat-label { font-size: 10px; color: #64748b; font-weight: 600; text-transform: uppercase; letter-spacing: 0.5px; }
+        .store-stat-label { font-size: 10px; color: var(--text-muted, #64748b); font-weight: 600; text-transform: uppercase; letter-spacing: 0.5px; }
+        .store-follow { margin-top: 14px; }
+        .store-cta { margin-top: 14px; }
 
         /* ── Featured Section ── */
         .store-featured {
@@ -243,7 +250,7 @@ const storefrontHTML = `<!DOCTYPE html>
             display: flex; flex-direction: column;
         }
         .store-featured-title {
-            font-size: 11px; font-weight: 700; color: #475569;
+            font-size: 11px; font-weight: 700; color: var(--text-secondary, #475569);
             margin-bottom: 12px; display: flex; align-items: center; gap: 6px;
             letter-spacing: 0.8px; text-transform: uppercase;
         }
@@ -262,7 +269,7 @@ const storefrontHTML = `<!DOCTYPE html>
             position: relative; overflow: hidden;
         }
         .featured-card:hover {
-            transform: translateY(-3px); background: #fff;
+            transform: translateY(-3px); background: var(--surface-bg, #fff);
             box-shadow: 0 8px 32px rgba(99,102,241,0.1), 0 2px 8px rgba(0,0,0,0.04);
             border-color: #c7d2fe;
         }
@@ -286,7 +293,7 @@ const storefrontHTML = `<!DOCTYPE html>
             flex: 1; min-width: 0;
         }
         .featured-name {
-            font-size: 13px; font-weight: 700; color: #1e293b;
+            font-size: 13px; font-weight: 700; color: var(--text-color, #1e293b);
             line-height: 1.3;
             overflow: hidden; text-overflow: ellipsis; white-space: nowrap;
             max-width: 100%;
@@ -299,25 +306,25 @@ const storefrontHTML = `<!DOCTYPE html>
         .featured-tag-per_use { background: #e0e7ff; color: #3730a3; }
         .featured-tag-subscription { background: #ede9fe; color: #6d28d9; }
         .featured-desc {
-            font-size: 11px; color: #334155; line-height: 1.5;
+            font-size: 11px; color: var(--text-secondary, #334155); line-height: 1.5;
             margin-bottom: 10px; flex: 1;
             overflow: hidden; text-overflow: ellipsis;
             display: -webkit-box; -webkit-line-clamp: 2; -webkit-box-orient: vertical;
         }
         .featured-footer {
             display: flex; align-items: center; justify-content: space-between;
-            width: 100%; padding-top: 8px; border-top: 1px solid #e2e8f0;
+            width: 100%; padding-top: 8px; border-top: 1px solid var(--surface-border, #e2e8f0);
         }
         .featured-price { font-size: 12px; font-weight: 800; }
         .featured-price.price-free { color: #15803d; }
         .featured-price.price-paid { color: #4338ca; }
         .featured-downloads {
             display: flex; align-items: center; gap: 3px;
-            font-size: 11px; color: #475569; font-weight: 500;
+            font-size: 11px; color: var(--text-secondary, #475569); font-weight: 500;
         }
         .featured-downloads svg { width: 12px; height: 12px; opacity: 0.8; }
         .featured-empty-slot {
-            background: rgba(255,255,255,0.4); border-radius: 14px; padding: 16px;
+            background: var(--glass-bg, rgba(255,255,255,0.4)); border-radius: 14px; padding: 16px;
             border: 1px dashed rgba(203,213,225,0.6); display: flex;
             align-items: center; justify-content: center;
             color: #cbd5e1; font-size: 20px;
@@ -335,11 +342,11 @@ const storefrontHTML = `<!DOCTYPE html>
             font-size: 12px; font-weight: 600; text-decoration: none;
             transition: all .25s cubic-bezier(.4,0,.2,1);
             border: 1px solid var(--card-border);
-            background: rgba(255,255,255,0.75); color: var(--primary-hover);
+            background: var(--glass-bg, rgba(255,255,255,0.75)); color: var(--primary-hover);
             backdrop-filter: blur(8px);
         }
         .sf-dl-btn:hover {
-            background: rgba(255,255,255,0.85); border-color: #c7d2fe;
+            background: var(--glass-bg, rgba(255,255,255,0.85)); border-color: #c7d2fe;
             box-shadow: 0 4px 16px rgba(99,102,241,0.15);
             transform: translateY(-1px);
             color: var(--primary-hover);
@@ -362,17 +369,17 @@ const storefrontHTML = `<!DOCTYPE html>
             margin-bottom: 20px; flex-wrap: wrap;
         }
         .filter-group {
-            display: flex; gap: 4px; background: #fff;
-            border: 1px solid #e2e8f0; border-radius: 10px; padding: 3px;
+            display: flex; gap: 4px; background: var(--surface-bg, #fff);
+            border: 1px solid var(--surface-border, #e2e8f0); border-radius: 10px; padding: 3px;
             box-shadow: 0 1px 3px rgba(0,0,0,0.06);
         }
         .filter-btn {
             padding: 7px 16px; border: none; border-radius: 8px;
             font-size: 12px; font-weight: 600; cursor: pointer;
-            background: transparent; color: #334155; transition: all 0.2s;
+            background: transparent; color: var(--text-secondary, #334155); transition: all 0.2s;
             text-decoration: none; display: inline-block;
         }
-        .filter-btn:hover { color: #0f172a; background: #f1f5f9; }
+        .filter-btn:hover { color: var(--text-strong, #0f172a); background: var(--input-bg, #f1f5f9); }
         .filter-btn.active {
             background: linear-gradient(135deg, #312e81, #1e1b4b); color: #fff;
             box-shadow: 0 2px 8px rgba(49,46,129,0.35);
@@ -380,15 +387,15 @@ const storefrontHTML = `<!DOCTYPE html>
         }
         .search-input {
             padding: 8px 16px; border: 1px solid #cbd5e1; border-radius: 10px;
-            font-size: 13px; background: #fff; min-width: 200px;
-            transition: all 0.2s; color: #1e293b;
+            font-size: 13px; background: var(--surface-bg, #fff); min-width: 200px;
+            transition: all 0.2s; color: var(--text-color, #1e293b);
             box-shadow: 0 1px 3px rgba(0,0,0,0.06);
         }
         .search-input:focus { outline: none; border-color: var(--primary-color); box-shadow: 0 0 0 3px rgba(99,102,241,0.1); }
-        .search-input::placeholder { color: #94a3b8; }
+        .search-input::placeholder { color: var(--text-muted, #94a3b8); }
         .sort-select {
             padding: 8px 16px; border: 1px solid #cbd5e1; border-radius: 10px;
-            font-size: 13px; background: #fff; color: #1e293b; cursor: pointer;
+            font-size: 13px; background: var(--surface-bg, #fff); color: var(--text-color, #1e293b); cursor: pointer;
             transition: all 0.2s; box-shadow: 0 1px 3px rgba(0,0,0,0.06);
         }
         .sort-select:focus { outline: none; border-color: var(--primary-color); }
@@ -396,8 +403,8 @@ const storefrontHTML = `<!DOCTYPE html>
         /* ── Pack Grid ── */
         .pack-list { display: grid; grid-template-columns: repeat(2, 1fr); gap: 14px; }
         .pack-item {
-            background: linear-gradient(135deg, #fafbff 0%, #f5f7ff 100%); border-radius: 14px; padding: 22px 24px;
-            border: 1px solid #e0e7ff;
+            background: var(--pack-bg, linear-gradient(135deg, #fafbff 0%, #f5f7ff 100%)); border-radius: 14px; padding: 22px 24px;
+            border: 1px solid var(--pack-border, #e0e7ff);
             box-shadow: 0 1px 3px rgba(0,0,0,0.04);
             display: flex; flex-direction: column; gap: 12px;
             transition: all 0.25s cubic-bezier(.4,0,.2,1);
@@ -432,7 +439,7 @@ const storefrontHTML = `<!DOCTYPE html>
         .featured-icon-wrap .featured-icon-img {
             position: absolute; top: 0; left: 0; z-index: 1;
         }
-        .pack-item-name { font-size: 15px; font-weight: 700; color: #0f172a; letter-spacing: -0.2px; }
+        .pack-item-name { font-size: 15px; font-weight: 700; color: var(--text-strong, #0f172a); letter-spacing: -0.2px; }
         .tag {
             display: inline-flex; align-items: center;
             padding: 3px 10px; border-radius: 20px;
@@ -444,18 +451,18 @@ const storefrontHTML = `<!DOCTYPE html>
         .tag-subscription { background: #f5f3ff; color: #7c3aed; border: 1px solid #ddd6fe; }
         .tag-category { background: #f0f9ff; color: #0369a1; border: 1px solid #bae6fd; }
         .pack-item-desc {
-            font-size: 13px; color: #64748b; line-height: 1.7;
+            font-size: 13px; color: var(--text-muted, #64748b); line-height: 1.7;
             margin-bottom: 12px;
             overflow: hidden; text-overflow: ellipsis;
             display: -webkit-box; -webkit-line-clamp: 2; -webkit-box-orient: vertical;
         }
         .pack-item-footer {
             display: flex; align-items: center; justify-content: space-between;
-            padding-top: 12px; border-top: 1px solid #f1f5f9;
+            padding-top: 12px; border-top: 1px solid var(--surface-border, #f1f5f9);
         }
         .pack-item-meta {
             display: flex; align-items: center; gap: 14px;
-            font-size: 12px; color: #64748b;
+            font-size: 12px; color: var(--text-muted, #64748b);
         }
         .pack-item-meta .meta-item { display: flex; align-items: center; gap: 4px; }
         .pack-item-meta .meta-item svg { width: 14px; height: 14px; opacity: 0.7; }
@@ -493,15 +500,15 @@ const storefrontHTML = `<!DOCTYPE html>
         .badge-owned svg { width: 14px; height: 14px; }
         .btn-ghost {
             padding: 9px 20px; font-size: 13px; border-radius: 10px;
-            background: #f8fafc; color: #64748b; border: 1px solid #e2e8f0;
+            background: var(--input-bg, #f8fafc); color: var(--text-muted, #64748b); border: 1px solid var(--surface-border, #e2e8f0);
             cursor: pointer; transition: all .2s; font-family: inherit; font-weight: 600;
         }
-        .btn-ghost:hover { background: #f1f5f9; color: #475569; }
+        .btn-ghost:hover { background: var(--input-bg, #f1f5f9); color: var(--text-secondary, #475569); }
 
         /* ── Empty State ── */
         .empty-state {
-            text-align: center; padding: 56px 24px; color: #64748b;
-            background: #fff; border-radius: 16px; border: 1px dashed #cbd5e1;
+            text-align: center; padding: 56px 24px; color: var(--text-muted, #64748b);
+            background: var(--surface-bg, #fff); border-radius: 16px; border: 1px dashed #cbd5e1;
         }
         .empty-state .icon { font-size: 40px; margin-bottom: 14px; opacity: 0.5; }
         .empty-state p { font-size: 14px; font-weight: 500; }
@@ -515,36 +522,36 @@ const storefrontHTML = `<!DOCTYPE html>
         }
         .modal-overlay.show { display: flex; }
         .modal-box {
-            background: #fff; border-radius: 18px; padding: 32px;
+            background: var(--surface-bg, #fff); border-radius: 18px; padding: 32px;
             max-width: 420px; width: 90%;
             box-shadow: 0 24px 64px rgba(0,0,0,0.15), 0 8px 24px rgba(0,0,0,0.08);
-            position: relative; border: 1px solid #e2e8f0;
+            position: relative; border: 1px solid var(--surface-border, #e2e8f0);
         }
         .modal-close {
             position: absolute; top: 16px; right: 18px;
             background: none; border: none; font-size: 18px; cursor: pointer;
-            color: #94a3b8; width: 32px; height: 32px; border-radius: 8px;
+            color: var(--text-muted, #94a3b8); width: 32px; height: 32px; border-radius: 8px;
             display: flex; align-items: center; justify-content: center;
             transition: all 0.15s;
         }
-        .modal-close:hover { background: #f1f5f9; color: #475569; }
-        .modal-title { font-size: 17px; font-weight: 700; color: #0f172a; margin-bottom: 22px; letter-spacing: -0.2px; }
+        .modal-close:hover { background: var(--input-bg, #f1f5f9); color: var(--text-secondary, #475569); }
+        .modal-title { font-size: 17px; font-weight: 700; color: var(--text-strong, #0f172a); margin-bottom: 22px; letter-spacing: -0.2px; }
         .modal-actions { display: flex; gap: 10px; justify-content: flex-end; margin-top: 22px; }
 
         /* ── Form Fields ── */
         .field-group { margin-bottom: 16px; }
         .field-group label {
-            font-size: 12px; color: #475569; display: block;
+            font-size: 12px; color: var(--text-secondary, #475569); display: block;
             margin-bottom: 6px; font-weight: 600;
         }
         .field-group input, .field-group select {
             width: 100%; padding: 10px 14px;
-            border: 1px solid #e2e8f0; border-radius: 10px;
-            font-size: 14px; background: #f8fafc;
-            transition: all 0.2s; color: #1e293b; font-family: inherit;
+            border: 1px solid var(--surface-border, #e2e8f0); border-radius: 10px;
+            font-size: 14px; background: var(--input-bg, #f8fafc);
+            transition: all 0.2s; color: var(--text-color, #1e293b); font-family: inherit;
         }
         .field-group input:focus, .field-group select:focus {
-            outline: none; border-color: var(--primary-color); background: #fff;
+            outline: none; border-color: var(--primary-color); background: var(--surface-bg, #fff);
             box-shadow: 0 0 0 3px rgba(99,102,241,0.1);
         }
         .total-price { font-size: 18px; font-weight: 800; color: var(--primary-hover); margin-bottom: 4px; letter-spacing: -0.3px; }
@@ -553,10 +560,12 @@ const storefrontHTML = `<!DOCTYPE html>
         .msg { display: none; padding: 14px 18px; border-radius: 12px; font-size: 13px; margin-bottom: 16px; font-weight: 600; }
         .msg-ok { background: #ecfdf5; color: #059669; border: 1px solid #a7f3d0; }
         .msg-err { background: #fef2f2; color: #dc2626; border: 1px solid #fecaca; }
+        .store-announcement { padding: 12px 18px; border-radius: 12px; font-size: 13px; margin-bottom: 16px; background: var(--surface-bg, #fff); color: var(--primary-hover); border: 1px solid var(--card-border); white-space: pre-line; }
+        .store-paused-banner { padding: 14px 18px; border-radius: 12px; font-size: 13px; margin-bottom: 16px; background: #fffbeb; color: #92400e; border: 1px solid #fde68a; }
 
         /* ── Footer ── */
-        .foot { text-align: center; margin-top: 36px; padding-top: 20px; border-top: 1px solid #e2e8f0; }
-        .foot-text { font-size: 12px; color: #94a3b8; font-weight: 500; }
+        .foot { text-align: center; margin-top: 36px; padding-top: 20px; border-top: 1px solid var(--surface-border, #e2e8f0); }
+        .foot-text { font-size: 12px; color: var(--text-muted, #94a3b8); font-weight: 500; }
         .foot-text a { color: var(--primary-color); text-decoration: none; font-weight: 600; }
         .foot-text a:hover { text-decoration: underline; }
         .powered-by {
@@ -590,7 +599,7 @@ const storefrontHTML = `<!DOCTYPE html>
                 flex-basis: 100%;
                 order: 3;
                 padding: 12px 0 0 0;
-                border-top: 1px solid #e2e8f0;
+                border-top: 1px solid var(--surface-border, #e2e8f0);
                 margin-top: 10px;
             }
             .store-name-nav .store-name-title {
@@ -603,9 +612,12 @@ const storefrontHTML = `<!DOCTYPE html>
             .store-stats { justify-content: center; }
             .filter-bar { flex-direction: column; align-items: stretch; }
             .search-input { min-width: auto; }
-            .pack-list { grid-template-columns: 1fr !important; }
+            .pack-list { grid-template-columns: repeat(var(--mobile-columns, 1), 1fr) !important; }
             .featured-grid { grid-template-columns: repeat(2, 1fr); }
         }
+        .price-original { color: var(--text-muted, #94a3b8); text-decoration: line-through; font-weight: 500; margin-left: 4px; }
+        .sale-badge { display: inline-block; font-size: 11px; font-weight: 700; color: #fff; background: #ef4444; border-radius: 4px; padding: 1px 6px; margin-right: 4px; }
+        .sale-countdown { font-size: 12px; color: #ef4444; font-weight: 600; }
     </style>
 </head>
 <body>
@@ -615,6 +627,10 @@ const storefrontHTML = `<!DOCTYPE html>
 </div>
 {{end}}
 <div class="page">
+    {{if and .Storefront.AnnouncementActive .Storefront.Announcement}}
+    <!-- Store Announcement -->
+    <div class="store-announcement">📢 {{.Storefront.Announcement}}</div>
+    {{end}}
     <!-- Navigation -->
     <nav class="nav">
         <a class="logo-link" href="/">
@@ -625,6 +641,7 @@ const storefrontHTML = `<!DOCTYPE html>
         <div class="store-name-nav">
             <h1 class="store-name-title">
                 {{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}小铺{{end}}
+                {{if .Storefront.Verified}}<span class="verified-badge" title="该卖家已通过平台认证" data-i18n-title="verified_seller_hint"><svg viewBox="0 0 24 24" width="14" height="14" fill="none" stroke="currentColor" stroke-width="3" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg><span data-i18n="verified_seller">认证卖家</span></span>{{end}}
             </h1>
             <a href="https://market.vantagics.com" target="_blank" rel="noopener" class="powered-by-link">
                 Powered by Vantagics Skills market
@@ -639,6 +656,9 @@ const storefrontHTML = `<!DOCTYPE html>
     <!-- Messages -->
     <div class="msg msg-ok" id="successMsg"></div>
     <div class="msg msg-err" id="errorMsg"></div>
+    {{if .IsPaused}}
+    <div class="store-paused-banner"><strong data-i18n="store_paused_title">小铺暂停营业中</strong> <span data-i18n="store_paused_desc">店主暂时关闭了小铺，目前不接受购买或领取。已购买的分析包仍可正常使用，欢迎稍后再来。</span></div>
+    {{end}}
 
     <!-- Dynamic Sections -->
     {{range $index, $section := .Sections}}{{if $section.Visible}}
@@ -654,7 +674,7 @@ const storefrontHTML = `<!DOCTYPE html>
                     <div class="store-avatar-letter">{{firstChar $.Storefront.StoreName}}</div>
                     {{end}}
                 </div>
-                <p class="store-desc">{{if $.Storefront.Description}}{{$.Storefront.Description}}{{else}}该作者暂未设置小铺描述{{end}}</p>
+                <div class="store-desc md">{{if $.Storefront.Description}}{{renderMarkdown $.Storefront.Description}}{{else}}该作者暂未设置小铺描述{{end}}</div>
                 <div class="store-stats">
                     <div class="store-stat">
                         <span class="store-stat-val">{{len $.Packs}}</span>
@@ -666,7 +686,25 @@ const storefrontHTML = `<!DOCTYPE html>
                         <span class="store-stat-label" data-i18n="stat_featured">推荐</span>
                     </div>
                     {{end}}
+                    <div class="store-stat">
+                        <span class="store-stat-val" id="followerCount">{{$.Storefront.FollowerCount}}</span>
+                        <span class="store-stat-label" data-i18n="stat_followers">关注</span>
+                    </div>
                 </div>
+                {{if $.HeroCTA.Target}}
+                <div class="store-cta">
+                    <a class="btn btn-indigo" href="{{$.HeroCTA.Target}}"{{if $.HeroCTA.External}} target="_blank" rel="noopener nofollow"{{end}}>{{if $.HeroCTA.Text}}{{$.HeroCTA.Text}}{{else}}<span data-i18n="hero_cta_default">浏览全部分析包</span>{{end}}</a>
+                </div>
+                {{end}}
+                {{if ne $.CurrentUserID $.Storefront.UserID}}
+                <div class="store-follow">
+                    {{if $.IsLoggedIn}}
+                    <button class="btn btn-indigo" id="followBtn" data-following="{{if $.IsFollowing}}1{{else}}0{{end}}" onclick="toggleFollow({{$.Storefront.ID}})">{{if $.IsFollowing}}<span data-i18n="following_store">已关注</span>{{else}}<span data-i18n="follow_store">关注小铺</span>{{end}}</button>
+                    {{else}}
+                    <a class="btn btn-indigo" href="/user/login?redirect=/store/{{$.Storefront.PublicID}}" data-i18n="follow_store">关注小铺</a>
+                    {{end}}
+                </div>
+                {{end}}
             </div>
             {{if and $.FeaturedPacks $.FeaturedVisible}}
             <div class="store-featured">
@@ -700,14 +738,15 @@ const storefrontHTML = `<!DOCTYPE html>
                                 {{end}}
                             </div>
                         </div>
-                        {{if .PackDesc}}<div class="featured-desc">{{.PackDesc}}</div>
+                        {{if .PackDesc}}<div class="featured-desc">{{markdownText .PackDesc}}</div>
                         {{else}}<div class="featured-desc" style="color:#94a3b8;font-style:italic;" data-i18n="no_description">暂无描述</div>
                         {{end}}
                         <div class="featured-footer">
                             {{if eq .ShareMode "free"}}
                             <span class="featured-price price-free" data-i18n="free">免费</span>
                             {{else}}
-                            <span class="featured-price price-paid">{{.CreditsPrice}} Credits</span>
+                            <span class="featured-price price-paid">{{if .OriginalPrice}}<span class="sale-badge" data-i18n="flash_sale">限时折扣</span>{{end}}{{.CreditsPrice}} Credits{{if .OriginalPrice}}<span class="price-original">{{.OriginalPrice}}</span>{{end}}</span>
+                            {{if .SaleEndsAt}}<span class="sale-countdown" data-ends="{{.SaleEndsAt}}"></span>{{end}}
                             {{end}}
                             <span class="featured-downloads">
                                 <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4"/><polyline points="7 10 12 15 17 10"/><line x1="12" y1="15" x2="12" y2="3"/></svg>
@@ -758,9 +797,9 @@ const storefrontHTML = `<!DOCTYPE html>
     </div>
     {{else if eq .Type "pack_grid"}}
     <!-- Pack List -->
-    <div data-section-type="{{.Type}}">
+    <div id="store-packs" data-section-type="{{.Type}}">
     {{if $.Packs}}
-    <div class="pack-list" style="grid-template-columns: repeat({{$.PackGridColumns}}, 1fr);">
+    <div class="pack-list" style="grid-template-columns: repeat({{$.PackGridColumns}}, 1fr); --mobile-columns: {{$.PackGridMobileColumns}};">
         {{range $.Packs}}
         <div class="pack-item">
             <div class="pack-item-body">
@@ -786,14 +825,15 @@ const storefrontHTML = `<!DOCTYPE html>
                     {{end}}
                     {{if .CategoryName}}<span class="tag tag-category">{{.CategoryName}}</span>{{end}}
                 </div>
-                {{if .PackDesc}}<div class="pack-item-desc">{{.PackDesc}}</div>{{end}}
+                {{if .PackDesc}}<div class="pack-item-desc">{{markdownText .PackDesc}}</div>{{end}}
             </div>
             <div class="pack-item-footer">
                 <div class="pack-item-meta">
                     {{if eq .ShareMode "free"}}
                     <span class="meta-item"><span class="pack-item-price price-free" data-i18n="free">免费</span></span>
                     {{else}}
-                    <span class="meta-item"><span class="pack-item-price">{{.CreditsPrice}} Credits</span></span>
+                    <span class="meta-item"><span class="pack-item-price">{{if .OriginalPrice}}<span class="sale-badge" data-i18n="flash_sale">限时折扣</span>{{end}}{{.CreditsPrice}} Credits{{if .OriginalPrice}}<span class="price-original">{{.OriginalPrice}}</span>{{end}}</span></span>
+                    {{if .SaleEndsAt}}<span class="meta-item sale-countdown" data-ends="{{.SaleEndsAt}}"></span>{{end}}
                     {{end}}
                     <span class="meta-item">
                         <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4"/><polyline points="7 10 12 15 17 10"/><line x1="12" y1="15" x2="12" y2="3"/></svg>
@@ -801,7 +841,9 @@ const storefrontHTML = `<!DOCTYPE html>
                     </span>
                 </div>
                 <div class="pack-item-actions">
-                    {{if $.IsLoggedIn}}
+                    {{if $.IsPaused}}
+                        <button class="btn" disabled data-i18n="store_paused_btn">暂停营业</button>
+                    {{else if $.IsLoggedIn}}
                         {{if index $.PurchasedIDs .ListingID}}
                         <span class="badge-owned">
                             <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2.5" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg>
@@ -833,7 +875,9 @@ const storefrontHTML = `<!DOCTYPE html>
     </div>
     {{else if eq .Type "custom_banner"}}
     <!-- Custom Banner -->
-    {{with index $.BannerData $index}}{{if .Text}}
+    {{with index $.BannerData $index}}{{if .ImageID}}
+    <div data-section-type="custom_banner" style="margin-bottom: 20px; border-radius: 12px; overflow: hidden;"><img src="/store/{{$.Storefront.ID}}/banner/{{.ImageID}}" alt="{{.Text}}" style="display: block; width: 100%; height: auto;"></div>
+    {{else if .Text}}
     <div data-section-type="custom_banner" style="padding: 16px 20px; border-radius: 12px; margin-bottom: 20px; font-size: 14px; font-weight: 500; line-height: 1.6; border: 1px solid {{if eq .Style "success"}}#bbf7d0{{else if eq .Style "warning"}}#fde68a{{else}}#bfdbfe{{end}}; background: {{if eq .Style "success"}}#f0fdf4{{else if eq .Style "warning"}}#fffbeb{{else}}#eff6ff{{end}}; color: {{if eq .Style "success"}}#166534{{else if eq .Style "warning"}}#92400e{{else}}#1e40af{{end}};">{{renderBannerMarkdown .Text}}</div>
     {{end}}{{end}}
     {{end}}
@@ -845,6 +889,9 @@ const storefrontHTML = `<!DOCTYPE html>
         <div style="font-size: 16px; font-weight: 700; color: #0f172a; margin-bottom: 16px; display: flex; align-items: center; gap: 8px; letter-spacing: -0.2px;">
             <svg viewBox="0 0 24 24" width="20" height="20" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M6 2L3 6v14a2 2 0 0 0 2 2h14a2 2 0 0 0 2-2V6l-3-4z"/><line x1="3" y1="6" x2="21" y2="6"/><path d="M16 10a4 4 0 0 1-8 0"/></svg>
             <span data-i18n="custom_products">自定义商品</span>
+            <select aria-label="Currency" style="margin-left: auto; font-size: 13px; padding: 4px 8px; border: 1px solid #e2e8f0; border-radius: 6px; background: #fff;" onchange="var u = new URL(location.href); u.searchParams.set('currency', this.value); location.href = u.toString();">
+                {{range .Currencies}}<option value="{{.}}"{{if eq . $.DisplayCurrency}} selected{{end}}>{{.}}</option>{{end}}
+            </select>
         </div>
         <div class="pack-list" style="grid-template-columns: repeat(2, 1fr);">
             {{range .CustomProducts}}
@@ -856,15 +903,22 @@ const storefrontHTML = `<!DOCTYPE html>
                         {{else if eq .ProductType "virtual_goods"}}<span class="tag" style="background:#ede9fe;color:#6d28d9;border:1px solid #ddd6fe;" data-i18n="product_type_virtual">虚拟商品</span>
                         {{end}}
                     </div>
-                    {{if .Description}}<div class="pack-item-desc">{{.Description}}</div>{{end}}
+                    {{if .Description}}<div class="pack-item-desc md" style="display:block;">{{renderMarkdown .Description}}</div>{{end}}
                 </div>
                 <div class="pack-item-footer">
                     <div class="pack-item-meta">
-                        <span class="meta-item"><span class="pack-item-price" style="color:var(--primary-hover);">{{formatPriceUSD .PriceUSD}} USD</span></span>
+                        <span class="meta-item"><span class="pack-item-price" style="color:var(--primary-hover);">{{if .OriginalPriceUSD}}<span class="sale-badge" data-i18n="flash_sale">限时折扣</span>{{end}}{{formatMoney .DisplayPrice .DisplayCurrency $.Lang}}{{if .OriginalPriceUSD}}<span class="price-original">{{formatMoney .OriginalDisplayPrice .DisplayCurrency $.Lang}}</span>{{end}}</span></span>
+                        {{if .SaleEndsAt}}<span class="meta-item sale-countdown" data-ends="{{.SaleEndsAt}}"></span>{{end}}
+                        {{if ne .DisplayCurrency .ChargeCurrency}}<span class="meta-item" style="font-size: 12px; color: #64748b;"><span data-i18n="charged_in">结算金额</span>: {{formatMoney .ChargePrice .ChargeCurrency $.Lang}}</span>{{end}}
+                        {{if gt .ChargeTax 0.0}}<span class="meta-item" style="font-size: 12px; color: #64748b;"><span data-i18n="tax_inclusive_total">含税合计</span>: {{formatMoney .ChargeTotal .ChargeCurrency $.Lang}}</span>{{end}}
                     </div>
                     <div class="pack-item-actions">
-                        {{if $.IsLoggedIn}}
-                        <button class="btn btn-indigo" onclick="showCustomProductPurchaseDialog({{.ID}}, '{{.ProductName}}', {{.PriceUSD}})" data-i18n="purchase">购买</button>
+                        {{if $.IsPaused}}
+                        <button class="btn" disabled data-i18n="store_paused_btn">暂停营业</button>
+                        {{else if .SoldOut}}
+                        <button class="btn" disabled data-i18n="sold_out">已售罄</button>
+                        {{else if $.IsLoggedIn}}
+                        <button class="btn btn-indigo" onclick="showCustomProductPurchaseDialog({{.ID}}, '{{.ProductName}}', '{{formatMoney .ChargeTotal .ChargeCurrency $.Lang}}', '{{if gt .ChargeTax 0.0}}{{formatMoney .ChargeTax .ChargeCurrency $.Lang}}{{end}}', {{if eq .ProductType "credits"}}{{.CreditsAmount}}{{else}}0{{end}})" data-i18n="purchase">购买</button>
                         {{else}}
                         <a class="btn btn-indigo" href="/user/login?redirect=/store/{{$.Storefront.ID}}" data-i18n="login_to_buy">登录后购买</a>
                         {{end}}
@@ -885,11 +939,27 @@ const storefrontHTML = `<!DOCTYPE html>
             <div style="margin-bottom: 16px;">
                 <div style="font-size: 14px; color: #475569; margin-bottom: 8px;"><span data-i18n="custom_product_label">商品</span>：<strong id="cpProductName"></strong></div>
                 <div class="total-price" id="cpProductPrice"></div>
+                <div style="font-size: 12px; color: #64748b; margin-top: 4px; display: none;" id="cpProductTax"><span data-i18n="tax_included">含税</span> <span id="cpProductTaxAmount"></span></div>
                 <div style="font-size: 13px; color: #64748b; margin-top: 8px; display: flex; align-items: center; gap: 6px;">
                     <svg viewBox="0 0 24 24" width="16" height="16" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><rect x="1" y="4" width="22" height="16" rx="2" ry="2"/><line x1="1" y1="10" x2="23" y2="10"/></svg>
                     <span data-i18n="payment_via_paypal">支付方式：PayPal</span>
                 </div>
             </div>
+            {{if .TopupTiers}}
+            <div class="field-group" id="cpTierGroup" style="display: none;">
+                <label for="cpTier" data-i18n="topup_tier">充值档位</label>
+                <select id="cpTier" onchange="selectCustomProductTier()">
+                    <option value="0" id="cpTierDefault"></option>
+                    {{range .TopupTiers}}<option value="{{.ID}}" data-charge="{{formatMoney .ChargeTotal .ChargeCurrency $.Lang}}">{{.Credits}}{{if .BonusCredits}} + {{.BonusCredits}}{{end}} Credits · {{formatMoney .ChargeTotal .ChargeCurrency $.Lang}} ({{.CreditsPerUSD}} Credits/USD)</option>{{end}}
+                </select>
+                <div style="font-size: 12px; color: #94a3b8; margin-top: 4px;" data-i18n="topup_tier_hint">大额档位额外赠送积分，括号内为每美元可得积分。</div>
+            </div>
+            {{end}}
+            <div class="field-group">
+                <label for="cpRecipientEmail" data-i18n="gift_recipient_email">赠送给（可选）</label>
+                <input type="email" id="cpRecipientEmail" maxlength="254" placeholder="friend@example.com">
+                <div style="font-size: 12px; color: #94a3b8; margin-top: 4px;" data-i18n="gift_recipient_hint">填写对方邮箱即可赠送，对方无需已有账户。留空则为自己购买。</div>
+            </div>
             <div class="modal-actions">
                 <button class="btn-ghost" onclick="closeCustomProductPurchaseDialog()" data-i18n="cancel">取消</button>
                 <button class="btn btn-indigo" id="cpConfirmBtn" onclick="confirmCustomProductPurchase()" data-i18n="confirm_purchase">确认购买</button>
@@ -900,7 +970,7 @@ const storefrontHTML = `<!DOCTYPE html>
 
     <!-- Footer -->
     <div class="foot">
-        <p class="foot-text">Vantagics <span data-i18n="site_name">分析技能包市场</span> · <a href="/" data-i18n="browse_more">浏览更多</a></p>
+        <p class="foot-text">Vantagics <span data-i18n="site_name">分析技能包市场</span> · <a href="/" data-i18n="browse_more">浏览更多</a>{{if .Storefront.HasPolicies}} · <a href="/store/{{.Storefront.PublicID}}/policies" data-i18n="store_policies">店铺政策</a>{{end}}</p>
         <div class="powered-by">
             Powered by
             <a href="https://vantagics.com" target="_blank" rel="noopener">
@@ -968,6 +1038,22 @@ const storefrontHTML = `<!DOCTYPE html>
 var _currentShareToken = '';
 var _currentShareMode = '';
 var _currentCreditsPrice = 0;
+(function() {
+    var els = document.querySelectorAll('.sale-countdown[data-ends]');
+    if (!els.length) return;
+    function pad(n) { return n < 10 ? '0' + n : n; }
+    function tick() {
+        var now = Date.now(), t = window._i18n || function(k, f) { return f; };
+        els.forEach(function(el) {
+            var s = Math.floor((new Date(el.getAttribute('data-ends')).getTime() - now) / 1000);
+            if (s <= 0) { el.textContent = t('sale_ended', '折扣已结束'); return; }
+            var d = Math.floor(s / 86400), h = Math.floor(s % 86400 / 3600), m = Math.floor(s % 3600 / 60);
+            el.textContent = t('sale_ends_in', '距结束') + ' ' + (d > 0 ? d + 'd ' : '') + pad(h) + ':' + pad(m) + ':' + pad(s % 60);
+        });
+    }
+    tick();
+    setInterval(tick, 1000);
+})();
 var _storeID = '{{.Storefront.ID}}';
 var _dlURLWindows = "{{.DownloadURLWindows}}";
 var _dlURLMacOS = "{{.DownloadURLMacOS}}";
@@ -1042,6 +1128,26 @@ function changeCat(val) {
     window.location.search = params.toString();
 }
 
+function toggleFollow(storefrontID) {
+    var btn = document.getElementById('followBtn');
+    var following = btn.getAttribute('data-following') === '1';
+    var req = following
+        ? fetch('/user/follows?storefront_id=' + storefrontID, { method: 'DELETE' })
+        : fetch('/user/follows', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ storefront_id: storefrontID }) });
+    req.then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (!d.success) {
+            showMsg('error', d.error || window._i18n('follow_failed', '操作失败'));
+            return;
+        }
+        btn.setAttribute('data-following', d.following ? '1' : '0');
+        btn.textContent = d.following ? window._i18n('following_store', '已关注') : window._i18n('follow_store', '关注小铺');
+        document.getElementById('followerCount').textContent = d.follower_count;
+    }).catch(function() {
+        showMsg('error', window._i18n('network_error', '网络错误'));
+    });
+}
+
 function claimPack(shareToken) {
     if (!confirm(window._i18n('add_to_purchased_confirm', '是否将此分析包添加到您的已购分析技能包中？'))) return;
     fetch('/pack/' + shareToken + '/claim', {
@@ -1144,25 +1250,52 @@ function confirmPurchase() {
     });
 }
 
-var _cpCurrentProductID = 0;
-function showCustomProductPurchaseDialog(productID, productName, priceUSD) {
+var _cpCurrentProductID = 0, _cpChargeText = '', _cpTaxText = '';
+function showCustomProductPurchaseDialog(productID, productName, chargeText, taxText, creditsAmount) {
     _cpCurrentProductID = productID;
+    _cpChargeText = chargeText;
+    _cpTaxText = taxText;
+    var tierGroup = document.getElementById('cpTierGroup');
+    if (tierGroup) {
+        tierGroup.style.display = creditsAmount ? '' : 'none';
+        document.getElementById('cpTier').value = '0';
+        document.getElementById('cpTierDefault').textContent = creditsAmount + ' Credits · ' + chargeText;
+    }
     var nameEl = document.getElementById('cpProductName');
     var priceEl = document.getElementById('cpProductPrice');
+    var taxEl = document.getElementById('cpProductTax');
     if (nameEl) nameEl.textContent = productName;
-    if (priceEl) priceEl.textContent = '$' + priceUSD.toFixed(2) + ' USD';
+    if (priceEl) priceEl.textContent = chargeText;
+    if (taxEl) {
+        document.getElementById('cpProductTaxAmount').textContent = taxText || '';
+        taxEl.style.display = taxText ? '' : 'none';
+    }
+    var recipientEl = document.getElementById('cpRecipientEmail');
+    if (recipientEl) recipientEl.value = '';
     document.getElementById('customProductPurchaseModal').classList.add('show');
 }
+function selectCustomProductTier() {
+    var opt = document.getElementById('cpTier').selectedOptions[0];
+    var tier = opt && opt.value !== '0';
+    document.getElementById('cpProductPrice').textContent = tier ? opt.getAttribute('data-charge') : _cpChargeText;
+    var taxEl = document.getElementById('cpProductTax');
+    if (taxEl) taxEl.style.display = !tier && _cpTaxText ? '' : 'none';
+}
 function closeCustomProductPurchaseDialog() {
     document.getElementById('customProductPurchaseModal').classList.remove('show');
 }
 function confirmCustomProductPurchase() {
     if (!_cpCurrentProductID) return;
     var btn = document.getElementById('cpConfirmBtn');
+    var recipientEl = document.getElementById('cpRecipientEmail');
+    var recipient = recipientEl ? recipientEl.value.trim() : '';
+    var tierGroup = document.getElementById('cpTierGroup');
+    var tierID = tierGroup && tierGroup.style.display !== 'none' ? parseInt(document.getElementById('cpTier').value, 10) || 0 : 0;
     if (btn) { btn.disabled = true; btn.textContent = window._i18n('processing', '处理中...'); }
     fetch('/custom-product/' + _cpCurrentProductID + '/purchase', {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' }
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ recipient_email: recipient, tier_id: tierID })
     }).then(function(r) { return r.json(); })
     .then(function(d) {
         if (d.approve_url) {
@@ -82,6 +82,24 @@ const storefrontManageHTML = `<!DOCTYPE html>
         }
         .card-title .icon { font-size: 16px; }
 
+        /* Analytics */
+        .an-summary { display: grid; grid-template-columns: repeat(4, 1fr); gap: 12px; margin-bottom: 20px; }
+        .an-stat { background: #f8fafc; border: 1px solid #e2e8f0; border-radius: 10px; padding: 14px 16px; }
+        .an-stat-label { font-size: 12px; color: #64748b; margin-bottom: 4px; }
+        .an-stat-val { font-size: 22px; font-weight: 800; color: #1e293b; }
+        .an-chart { display: flex; align-items: flex-end; gap: 2px; height: 140px; padding-top: 8px; border-bottom: 1px solid #e2e8f0; }
+        .an-bar { flex: 1; display: flex; flex-direction: column; justify-content: flex-end; min-width: 2px; height: 100%; }
+        .an-bar-store { background: #a5b4fc; }
+        .an-bar-pack { background: #4f46e5; }
+        .an-legend { display: flex; gap: 16px; font-size: 12px; color: #64748b; margin-top: 8px; }
+        .an-legend i { display: inline-block; width: 10px; height: 10px; border-radius: 2px; margin-right: 4px; vertical-align: middle; }
+        .an-table { width: 100%; border-collapse: collapse; font-size: 13px; }
+        .an-table th { text-align: left; color: #64748b; font-weight: 600; padding: 8px; border-bottom: 1px solid #e2e8f0; }
+        .an-table td { padding: 8px; border-bottom: 1px solid #f1f5f9; color: #334155; }
+        .an-live-item { display: flex; justify-content: space-between; gap: 12px; padding: 8px 0; border-bottom: 1px solid #f1f5f9; font-size: 13px; color: #334155; }
+        .an-live-item span:last-child { color: #16a34a; font-weight: 600; white-space: nowrap; }
+        @media (max-width: 640px) { .an-summary { grid-template-columns: repeat(2, 1fr); } }
+
         /* Form fields */
         .field-group { margin-bottom: 16px; }
         .field-group label {
@@ -538,6 +556,7 @@ const storefrontManageHTML = `<!DOCTYPE html>
         <button class="tab-btn active" onclick="switchTab('settings', this)" data-i18n="sm_tab_settings">⚙️ 小铺设置</button>
         <button class="tab-btn" onclick="switchTab('packs', this)" data-i18n="sm_tab_packs">📦 分析包管理</button>
         <button class="tab-btn" onclick="switchTab('notify', this)" data-i18n="sm_tab_notify">📧 客户通知</button>
+        <button class="tab-btn" onclick="switchTab('analytics', this); loadAnalytics()" data-i18n="sm_tab_analytics">📈 数据分析</button>
         {{if .CustomProductsEnabled}}<button class="tab-btn" onclick="switchTab('custom-products', this)" data-i18n="sm_tab_custom_products">🛍️ 自定义商品</button>{{end}}
     </div>
 
@@ -570,8 +589,8 @@ const storefrontManageHTML = `<!DOCTYPE html>
                     {{end}}
                 </div>
                 <div class="logo-upload-info">
-                    <p data-i18n="sm_logo_hint">支持 PNG 或 JPEG 格式，文件大小不超过 2MB，也可直接 Ctrl+V 粘贴图片</p>
-                    <input type="file" id="logoFile" accept="image/png,image/jpeg" style="display:none;" onchange="uploadLogo()">
+                    <p data-i18n="sm_logo_hint">支持 PNG、JPEG 或 WebP 格式，文件大小不超过 2MB，也可直接 Ctrl+V 粘贴图片</p>
+                    <input type="file" id="logoFile" accept="image/png,image/jpeg,image/webp" style="display:none;" onchange="uploadLogo()">
                     <button class="btn btn-ghost" onclick="document.getElementById('logoFile').click()" data-i18n="sm_upload_logo">📤 上传 Logo</button>
                 </div>
             </div>
@@ -651,6 +670,11 @@ const storefrontManageHTML = `<!DOCTYPE html>
         <div class="card">
             <div class="card-title"><span class="icon">📐</span> <span data-i18n="sm_page_layout">页面布局</span></div>
             <div class="field-hint" style="margin-bottom:14px;" data-i18n="sm_page_layout_hint">拖拽调整区块顺序，控制各区块的显示和参数设置</div>
+            <div style="display:flex;gap:8px;align-items:center;flex-wrap:wrap;margin-bottom:14px;">
+                <span class="field-hint" data-i18n="sm_layout_presets">快速套用布局模板</span>
+                <select id="layoutPresetSelect" style="padding:6px 10px;border:1px solid #cbd5e1;border-radius:8px;font-size:13px;min-width:220px;"></select>
+                <button class="btn-ghost btn-sm" onclick="applyLayoutPreset()" data-i18n="sm_apply_preset">套用</button>
+            </div>
             <div class="section-list" id="sectionList"></div>
             <div class="layout-actions">
                 <button class="btn btn-green btn-sm" id="addBannerBtn" onclick="addCustomBanner()" data-i18n="sm_add_banner">+ 添加横幅</button>
@@ -747,6 +771,88 @@ const storefrontManageHTML = `<!DOCTYPE html>
                 </div>
                 <div class="theme-name">极简灰白</div>
             </div>
+            <div class="theme-option{{if eq .CurrentTheme "dark"}} theme-option-active{{end}}" data-theme="dark" onclick="selectTheme('dark')">
+                <div class="theme-swatches">
+                    <span class="theme-swatch" style="background:#0b1120;"></span>
+                    <span class="theme-swatch" style="background:#818cf8;"></span>
+                </div>
+                <div class="theme-name">深色夜间</div>
+            </div>
+            <div class="theme-option{{if eq .CurrentTheme "custom"}} theme-option-active{{end}}" data-theme="custom" onclick="toggleCustomThemeEditor()">
+                <div class="theme-swatches">
+                    <span class="theme-swatch" id="customSwatchPrimary" style="background:{{.CustomTheme.PrimaryColor}};"></span>
+                    <span class="theme-swatch" id="customSwatchAccent" style="background:{{.CustomTheme.AccentColor}};"></span>
+                </div>
+                <div class="theme-name">自定义配色</div>
+            </div>
+        </div>
+        <div id="customThemeEditor" style="display:none;margin-top:16px;">
+            <div style="display:grid;grid-template-columns:repeat(auto-fill,minmax(160px,1fr));gap:12px;">
+                <label class="field-hint">主色 <input type="color" id="customPrimaryColor" value="{{.CustomTheme.PrimaryColor}}"></label>
+                <label class="field-hint">强调色 <input type="color" id="customAccentColor" value="{{.CustomTheme.AccentColor}}"></label>
+                <label class="field-hint">横幅渐变起始色 <input type="color" id="customHeroStart" value="{{.CustomTheme.HeroStart}}"></label>
+                <label class="field-hint">横幅渐变结束色 <input type="color" id="customHeroEnd" value="{{.CustomTheme.HeroEnd}}"></label>
+            </div>
+            <button class="btn btn-indigo btn-sm" style="margin-top:12px;" onclick="saveCustomTheme()">应用自定义配色</button>
+        </div>
+        <div class="toggle-row" style="margin-top:16px;">
+            <div>
+                <div class="toggle-label">跟随访客系统深色模式</div>
+                <div class="toggle-desc">开启后，访客设备处于深色模式时小铺自动切换为深色夜间配色</div>
+            </div>
+            <button class="toggle-switch{{if eq .Storefront.ColorScheme "auto"}} on{{end}}" id="colorSchemeToggle" onclick="toggleColorScheme()"></button>
+        </div>
+        <div class="field-hint" style="margin-top:16px;">装修模板包含区块布局和主题配色，可导入到其他小铺（不含商品和横幅图片）</div>
+        <div style="display:flex;gap:8px;margin-top:8px;">
+            <a class="btn-ghost btn-sm" style="text-decoration:none;" href="/user/storefront/design/export">导出装修模板</a>
+            <button class="btn-ghost btn-sm" onclick="document.getElementById('designImportFile').click()">导入装修模板</button>
+            <input type="file" id="designImportFile" accept="application/json,.json" style="display:none;" onchange="importDesign(this)">
+        </div>
+
+        <!-- Store status -->
+        <div class="card">
+            <div class="card-title"><span class="icon">🏖️</span> 营业状态</div>
+            <div class="toggle-row">
+                <div>
+                    <div class="toggle-label">暂停营业</div>
+                    <div class="toggle-desc">开启后小铺页面仍可访问，但会显示暂停营业提示，且不接受购买；小铺也不会出现在首页推荐和排行中</div>
+                </div>
+                <button class="toggle-switch{{if eq .Storefront.StoreStatus "paused"}} on{{end}}" id="storeStatusToggle" onclick="toggleStoreStatus()"></button>
+            </div>
+        </div>
+
+        <!-- Store announcement -->
+        <div class="card">
+            <div class="card-title"><span class="icon">📢</span> 小铺公告</div>
+            <div class="field-group">
+                <label for="storeAnnouncement">公告内容</label>
+                <textarea id="storeAnnouncement" rows="2" maxlength="200" placeholder="例如：国庆期间订单将于 10 月 8 日后统一处理">{{.Storefront.Announcement}}</textarea>
+                <div class="field-hint">公告显示在小铺页面顶部，最多 200 字符，与小铺布局设置互不影响</div>
+            </div>
+            <div style="display:flex;gap:8px;">
+                <button class="btn btn-indigo" onclick="saveAnnouncement(true)">发布公告</button>
+                <button class="btn btn-ghost" id="clearAnnouncementBtn" onclick="saveAnnouncement(false)"{{if not .Storefront.AnnouncementActive}} style="display:none;"{{end}}>撤下公告</button>
+            </div>
+        </div>
+
+        <!-- Store policies -->
+        <div class="card">
+            <div class="card-title"><span class="icon">📜</span> 店铺政策</div>
+            <div class="field-group">
+                <label for="refundPolicy">退款政策</label>
+                <textarea id="refundPolicy" rows="4" maxlength="5000" placeholder="例如：购买后 7 天内未激活的授权可申请全额退款">{{.Storefront.RefundPolicy}}</textarea>
+            </div>
+            <div class="field-group">
+                <label for="storeTerms">服务条款</label>
+                <textarea id="storeTerms" rows="4" maxlength="5000">{{.Storefront.Terms}}</textarea>
+            </div>
+            <div class="field-group">
+                <label for="storeContact">联系方式</label>
+                <textarea id="storeContact" rows="2" maxlength="5000" placeholder="例如：support@example.com">{{.Storefront.Contact}}</textarea>
+                <div class="field-hint">以上内容均可留空；全部留空时不显示店铺政策页面。填写后小铺页脚会显示“店铺政策”链接</div>
+            </div>
+            <button class="btn btn-indigo" onclick="savePolicies()">💾 保存政策</button>
+            <a class="btn btn-ghost" id="policiesLink" href="/store/{{.Storefront.PublicID}}/policies" target="_blank" rel="noopener"{{if not .Storefront.HasPolicies}} style="display:none;"{{end}}>查看政策页面</a>
         </div>
     </div>
 
@@ -849,7 +955,7 @@ const storefrontManageHTML = `<!DOCTYPE html>
                     </div>
                     <div class="featured-logo-actions">
                         <button class="featured-logo-btn" onclick="uploadFeaturedLogo({{.ListingID}})">📤 上传 Logo</button>
-                        <input type="file" id="featured-logo-input-{{.ListingID}}" accept="image/png,image/jpeg" style="display:none">
+                        <input type="file" id="featured-logo-input-{{.ListingID}}" accept="image/png,image/jpeg,image/webp" style="display:none">
                         {{if .HasLogo}}<button class="featured-logo-btn featured-logo-btn-danger" id="featured-logo-delete-{{.ListingID}}" onclick="deleteFeaturedLogo({{.ListingID}})">🗑️ 删除</button>{{end}}
                     </div>
                     <button class="btn btn-ghost btn-sm" onclick="removeFeatured({{.ListingID}})">取消推荐</button>
@@ -970,6 +1076,40 @@ const storefrontManageHTML = `<!DOCTYPE html>
         </div>
     </div>
 
+    <!-- ==================== Tab: 数据分析 ==================== -->
+    <div class="tab-content" id="tab-analytics">
+        <div class="card">
+            <div class="card-title" style="justify-content:space-between;">
+                <span><span class="icon">📈</span> 访问与转化</span>
+                <select id="analyticsDays" onchange="loadAnalytics(true)" style="padding:6px 10px;border:1px solid #cbd5e1;border-radius:8px;font-size:13px;">
+                    <option value="7">近 7 天</option>
+                    <option value="30" selected>近 30 天</option>
+                    <option value="90">近 90 天</option>
+                </select>
+            </div>
+            <div class="an-summary">
+                <div class="an-stat"><div class="an-stat-label">小铺访问</div><div class="an-stat-val" id="anStoreViews">-</div></div>
+                <div class="an-stat"><div class="an-stat-label">分析包浏览</div><div class="an-stat-val" id="anPackViews">-</div></div>
+                <div class="an-stat"><div class="an-stat-label">新增购买</div><div class="an-stat-val" id="anPurchases">-</div></div>
+                <div class="an-stat"><div class="an-stat-label">访问转化率</div><div class="an-stat-val" id="anConversion">-</div></div>
+            </div>
+            <div class="an-chart" id="anChart"></div>
+            <div class="an-legend"><span><i style="background:#a5b4fc;"></i>小铺访问</span><span><i style="background:#4f46e5;"></i>分析包浏览</span></div>
+            <div class="field-hint">同一访客 30 分钟内的重复访问只计一次，数据约 30 秒更新一次。</div>
+        </div>
+        <div class="card">
+            <div class="card-title"><span class="icon">🔔</span> 实时成交 <span class="field-hint" id="liveSalesStatus" style="margin:0 0 0 auto;">连接中...</span></div>
+            <div class="an-live" id="liveSalesList"><div class="field-hint">页面打开期间的新成交会显示在这里</div></div>
+        </div>
+        <div class="card">
+            <div class="card-title"><span class="icon">📦</span> 分析包表现</div>
+            <table class="an-table">
+                <thead><tr><th>分析包</th><th>浏览</th><th>购买</th><th>转化率</th></tr></thead>
+                <tbody id="anPackRows"><tr><td colspan="4" style="color:#94a3b8;">加载中...</td></tr></tbody>
+            </table>
+        </div>
+    </div>
+
     {{if .CustomProductsEnabled}}
     <!-- ==================== Tab 4: 自定义商品 ==================== -->
     <div class="tab-content" id="tab-custom-products" data-testid="custom-products-tab">
@@ -1031,6 +1171,17 @@ const storefrontManageHTML = `<!DOCTYPE html>
             {{end}}
         </div>
 
+        <!-- Tax rate -->
+        <div class="card">
+            <div class="card-title"><span class="icon">🧾</span> 税费设置</div>
+            <div class="field-group">
+                <label for="storeTaxRate">税率 (%)</label>
+                <input type="number" id="storeTaxRate" min="0" max="50" step="0.01" value="{{.TaxRate}}" placeholder="0">
+                <div class="field-hint">买家购买自定义商品时在商品价格之外另加此税率的税费，结算前显示含税金额；填 0 或留空表示不收税</div>
+            </div>
+            <button class="btn btn-indigo" onclick="saveTaxRate()">💾 保存税率</button>
+        </div>
+
         <!-- Create/Edit form -->
         <div class="card" id="cpFormCard" style="display:none;">
             <div class="card-title" id="cpFormTitle"><span class="icon">➕</span> 添加商品</div>
@@ -1212,8 +1363,8 @@ function doUploadLogo(file) {
         showMsg('err', '图片大小不能超过 2MB');
         return;
     }
-    if (file.type !== 'image/png' && file.type !== 'image/jpeg') {
-        showMsg('err', '仅支持 PNG 或 JPEG 格式');
+    if (file.type !== 'image/png' && file.type !== 'image/jpeg' && file.type !== 'image/webp') {
+        showMsg('err', '仅支持 PNG、JPEG 或 WebP 格式');
         return;
     }
     var fd = new FormData();
@@ -1242,7 +1393,7 @@ document.addEventListener('paste', function(e) {
     if (!activeTab || !activeTab.classList.contains('active')) return;
     var items = (e.clipboardData || e.originalEvent.clipboardData).items;
     for (var i = 0; i < items.length; i++) {
-        if (items[i].type === 'image/png' || items[i].type === 'image/jpeg') {
+        if (items[i].type === 'image/png' || items[i].type === 'image/jpeg' || items[i].type === 'image/webp') {
             e.preventDefault();
             doUploadLogo(items[i].getAsFile());
             return;
@@ -1502,6 +1653,72 @@ function selectTheme(theme) {
     }).catch(function() { showMsg('err', '网络错误'); });
 }
 
+/* ===== Settings: Color scheme (follow system dark mode) ===== */
+function toggleColorScheme() {
+    var btn = document.getElementById('colorSchemeToggle');
+    var enabling = !btn.classList.contains('on');
+    var fd = new FormData();
+    fd.append('color_scheme', enabling ? 'auto' : 'light');
+    fetch('/user/storefront/color-scheme', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.ok) {
+            if (enabling) { btn.classList.add('on'); } else { btn.classList.remove('on'); }
+            showToast(enabling ? '已开启跟随系统深色模式' : '已关闭跟随系统深色模式');
+        } else {
+            showMsg('err', d.error || '保存失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
+/* ===== Settings: Custom Theme ===== */
+function toggleCustomThemeEditor() {
+    var editor = document.getElementById('customThemeEditor');
+    editor.style.display = editor.style.display === 'none' ? 'block' : 'none';
+}
+function saveCustomTheme() {
+    var fd = new FormData();
+    fd.append('theme', 'custom');
+    fd.append('primary_color', document.getElementById('customPrimaryColor').value);
+    fd.append('accent_color', document.getElementById('customAccentColor').value);
+    fd.append('hero_start', document.getElementById('customHeroStart').value);
+    fd.append('hero_end', document.getElementById('customHeroEnd').value);
+    fetch('/user/storefront/theme', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.ok) {
+            showToast('主题已切换');
+            document.getElementById('customSwatchPrimary').style.background = document.getElementById('customPrimaryColor').value;
+            document.getElementById('customSwatchAccent').style.background = document.getElementById('customAccentColor').value;
+            document.querySelectorAll('.theme-option').forEach(function(el) { el.classList.remove('theme-option-active'); });
+            var opt = document.querySelector('.theme-option[data-theme="custom"]');
+            if (opt) opt.classList.add('theme-option-active');
+        } else {
+            showMsg('err', d.error || '保存失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
+/* ===== Settings: Design template import ===== */
+function importDesign(input) {
+    var file = input.files[0];
+    input.value = '';
+    if (!file) return;
+    if (!confirm('导入后将覆盖当前的区块布局和主题，确定继续吗？')) return;
+    file.text().then(function(text) {
+        return fetch('/user/storefront/design/import', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: text });
+    })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.ok) {
+            showToast('装修模板已导入');
+            setTimeout(function() { location.reload(); }, 800);
+        } else {
+            showMsg('err', d.error || '导入失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
 /* ===== Packs: Toggle auto-add ===== */
 function toggleAutoAdd() {
     var btn = document.getElementById('autoAddToggle');
@@ -1522,6 +1739,170 @@ function toggleAutoAdd() {
     }).catch(function() { showMsg('err', '网络错误'); });
 }
 
+/* ===== Settings: Toggle store status ===== */
+function toggleStoreStatus() {
+    var btn = document.getElementById('storeStatusToggle');
+    var pausing = !btn.classList.contains('on');
+    var fd = new FormData();
+    fd.append('paused', pausing ? '1' : '0');
+    fetch('/user/storefront/status', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.success) {
+            if (pausing) { btn.classList.add('on'); } else { btn.classList.remove('on'); }
+            showMsg('ok', pausing ? '小铺已暂停营业' : '小铺已恢复营业');
+        } else {
+            showMsg('err', d.error || '操作失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
+/* ===== Settings: Store announcement ===== */
+function saveAnnouncement(active) {
+    var input = document.getElementById('storeAnnouncement');
+    var text = active ? input.value.trim() : '';
+    if (active && !text) {
+        showMsg('err', '公告内容不能为空');
+        return;
+    }
+    var fd = new FormData();
+    fd.append('announcement', text);
+    fd.append('active', active ? '1' : '0');
+    fetch('/user/storefront/announcement', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.success) {
+            input.value = d.announcement;
+            document.getElementById('clearAnnouncementBtn').style.display = active ? '' : 'none';
+            showMsg('ok', active ? '公告已发布' : '公告已撤下');
+        } else {
+            showMsg('err', d.error || '保存失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
+/* ===== Settings: Store policies ===== */
+function savePolicies() {
+    var refund = document.getElementById('refundPolicy').value.trim();
+    var terms = document.getElementById('storeTerms').value.trim();
+    var contact = document.getElementById('storeContact').value.trim();
+    var fd = new FormData();
+    fd.append('refund_policy', refund);
+    fd.append('terms', terms);
+    fd.append('contact', contact);
+    fetch('/user/storefront/policies', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.success) {
+            document.getElementById('policiesLink').style.display = (refund || terms || contact) ? '' : 'none';
+            showMsg('ok', '店铺政策已保存');
+        } else {
+            showMsg('err', d.error || '保存失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
+/* ===== Custom products: Tax rate ===== */
+function saveTaxRate() {
+    var fd = new FormData();
+    fd.append('tax_rate', document.getElementById('storeTaxRate').value.trim());
+    fetch('/user/storefront/tax-rate', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.success) {
+            document.getElementById('storeTaxRate').value = d.tax_rate;
+            showMsg('ok', '税率已保存');
+        } else {
+            showMsg('err', d.error || '保存失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
+/* ===== Analytics ===== */
+var analyticsLoaded = false;
+var liveSales = null;
+function loadAnalytics(force) {
+    startLiveSales();
+    if (analyticsLoaded && !force) return;
+    analyticsLoaded = true;
+    var days = document.getElementById('analyticsDays').value;
+    fetch('/user/storefront/analytics?days=' + days)
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (!d.success) { analyticsLoaded = false; showMsg('err', d.error || '加载失败'); return; }
+        document.getElementById('anStoreViews').textContent = d.store_views;
+        document.getElementById('anPackViews').textContent = d.pack_views;
+        document.getElementById('anPurchases').textContent = d.purchases;
+        document.getElementById('anConversion').textContent = (d.conversion * 100).toFixed(1) + '%';
+
+        var chart = document.getElementById('anChart');
+        chart.innerHTML = '';
+        var max = 1;
+        d.daily.forEach(function(day) { max = Math.max(max, day.store_views + day.pack_views); });
+        d.daily.forEach(function(day) {
+            var bar = document.createElement('div');
+            bar.className = 'an-bar';
+            bar.title = day.date + '：小铺访问 ' + day.store_views + '，分析包浏览 ' + day.pack_views + '，购买 ' + day.purchases;
+            var store = document.createElement('div');
+            store.className = 'an-bar-store';
+            store.style.height = (day.store_views / max * 100) + '%';
+            var pack = document.createElement('div');
+            pack.className = 'an-bar-pack';
+            pack.style.height = (day.pack_views / max * 100) + '%';
+            bar.appendChild(store);
+            bar.appendChild(pack);
+            chart.appendChild(bar);
+        });
+
+        var tbody = document.getElementById('anPackRows');
+        tbody.innerHTML = '';
+        if (!d.packs.length) {
+            tbody.innerHTML = '<tr><td colspan="4" style="color:#94a3b8;">暂无数据</td></tr>';
+            return;
+        }
+        d.packs.forEach(function(p) {
+            var tr = document.createElement('tr');
+            [p.pack_name, p.views, p.purchases, p.views ? (p.conversion * 100).toFixed(1) + '%' : '-'].forEach(function(v) {
+                var td = document.createElement('td');
+                td.textContent = v;
+                tr.appendChild(td);
+            });
+            tbody.appendChild(tr);
+        });
+    }).catch(function() { analyticsLoaded = false; showMsg('err', '网络错误'); });
+}
+
+function startLiveSales() {
+    if (liveSales || !window.EventSource) return;
+    var status = document.getElementById('liveSalesStatus');
+    var list = document.getElementById('liveSalesList');
+    var first = true;
+    liveSales = new EventSource('/user/storefront/events');
+    liveSales.onopen = function() { status.textContent = '● 已连接'; };
+    liveSales.onerror = function() { status.textContent = '重新连接中...'; };
+    function addItem(name, amount) {
+        if (first) { list.innerHTML = ''; first = false; }
+        var item = document.createElement('div');
+        item.className = 'an-live-item';
+        var left = document.createElement('span');
+        left.textContent = new Date().toLocaleTimeString() + ' · ' + name;
+        var right = document.createElement('span');
+        right.textContent = amount;
+        item.appendChild(left);
+        item.appendChild(right);
+        list.insertBefore(item, list.firstChild);
+        while (list.children.length > 20) list.removeChild(list.lastChild);
+    }
+    liveSales.addEventListener('pack_sale', function(e) {
+        var d = JSON.parse(e.data);
+        addItem(d.pack_name, '+' + d.credits + ' Credits');
+    });
+    liveSales.addEventListener('custom_order', function(e) {
+        var d = JSON.parse(e.data);
+        addItem(d.product_name, '+' + d.amount.toFixed(2) + ' ' + d.currency);
+    });
+}
+
 /* ===== Packs: Add pack modal ===== */
 function showAddPackModal() {
     document.getElementById('addPackModal').classList.add('show');
@@ -1770,7 +2151,7 @@ document.addEventListener('paste', function(e) {
     var items = e.clipboardData && e.clipboardData.items;
     if (!items) return;
     for (var i = 0; i < items.length; i++) {
-        if (items[i].type === 'image/png' || items[i].type === 'image/jpeg') {
+        if (items[i].type === 'image/png' || items[i].type === 'image/jpeg' || items[i].type === 'image/webp') {
             var file = items[i].getAsFile();
             if (!file) continue;
             var fd = new FormData();
@@ -1951,6 +2332,7 @@ function updateEmailPreview() {
 function sendNotification() {
     var templateType = document.getElementById('notifyTemplate').value;
     var subject, body;
+    var macroValues = {};
 
     if (templateType && _tplSubject) {
         // Template mode: replace macros with actual values
@@ -1966,6 +2348,7 @@ function sendNotification() {
             var re = new RegExp('\\{\\{\\.' + macro + '\\}\\}', 'g');
             subject = subject.replace(re, val);
             body = body.replace(re, val);
+            macroValues[macro] = val;
         });
         if (missing.length > 0) {
             showMsg('err', '请填写以下模板变量：' + missing.join('、'));
@@ -1998,6 +2381,10 @@ function sendNotification() {
     fd.append('body', body);
     fd.append('scope', scope);
     fd.append('template_type', templateType);
+    // Placeholder values let the server render the template in each recipient's language
+    Object.keys(macroValues).forEach(function(macro) {
+        fd.append('macro_' + macro, macroValues[macro]);
+    });
     if (listingIds.length > 0) {
         fd.append('listing_ids', listingIds.join(','));
     }
@@ -2098,6 +2485,46 @@ function initLayoutSections() {
     renderSectionList();
 }
 
+/* ===== Page Layout: Preset gallery ===== */
+function loadLayoutPresets() {
+    var sel = document.getElementById('layoutPresetSelect');
+    if (!sel) return;
+    fetch('/user/storefront/layout-presets')
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (!d.ok) return;
+        sel.innerHTML = '';
+        d.presets.forEach(function(p) {
+            var opt = document.createElement('option');
+            opt.value = p.key;
+            opt.textContent = p.name + ' — ' + p.description;
+            sel.appendChild(opt);
+        });
+    }).catch(function() {});
+}
+function applyLayoutPreset() {
+    var sel = document.getElementById('layoutPresetSelect');
+    if (!sel || !sel.value) return;
+    if (!confirm(window._i18n('sm_apply_preset_confirm', '套用模板将替换当前的页面布局（包括自定义横幅），确定继续吗？'))) return;
+    var fd = new FormData();
+    fd.append('preset', sel.value);
+    fetch('/user/storefront/layout-presets/apply', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.ok) {
+            _layoutSections = d.layout_config.sections.map(function(s) {
+                return { type: s.type, visible: s.visible, settings: s.settings || {} };
+            });
+            renderSectionList();
+            showToast('布局已保存');
+            var customRadio = document.querySelector('input[name="store_layout"][value="custom"]');
+            if (customRadio) customRadio.checked = true;
+        } else {
+            showMsg('err', d.error || '保存失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
 function renderSectionList() {
     var list = document.getElementById('sectionList');
     if (!list) return;
@@ -2130,6 +2557,14 @@ function renderSectionList() {
             html += '<option value="default"' + (heroLayout === 'default' ? ' selected' : '') + '>Logo 在左，推荐在右</option>';
             html += '<option value="reversed"' + (heroLayout === 'reversed' ? ' selected' : '') + '>推荐在左，Logo 在右</option>';
             html += '</select></div>';
+            var ctaText = (sec.settings && sec.settings.cta_text) || '';
+            var ctaTarget = (sec.settings && sec.settings.cta_target) || '';
+            html += '<div class="section-banner-settings">';
+            html += '<div class="field-group"><label>按钮文字（可选）</label>';
+            html += '<input type="text" maxlength="40" value="' + escapeAttr(ctaText) + '" oninput="updateHeroCTA(' + idx + ', \'cta_text\', this.value)" placeholder="浏览全部分析包"></div>';
+            html += '<div class="field-group"><label>按钮链接（可选，站内路径如 /store/... 或 https:// 网址）</label>';
+            html += '<input type="text" maxlength="500" value="' + escapeAttr(ctaTarget) + '" oninput="updateHeroCTA(' + idx + ', \'cta_target\', this.value)" placeholder="留空则跳到分析包列表"></div>';
+            html += '</div>';
         }
 
         // Pack grid columns setting
@@ -2141,6 +2576,12 @@ function renderSectionList() {
             html += '<option value="1"' + (cols === 1 ? ' selected' : '') + '>1 列</option>';
             html += '<option value="2"' + (cols === 2 ? ' selected' : '') + '>2 列</option>';
             html += '<option value="3"' + (cols === 3 ? ' selected' : '') + '>3 列</option>';
+            html += '</select>';
+            var mobileCols = (sec.settings && sec.settings.mobile_columns) || 1;
+            html += '<span style="font-size:12px;color:#64748b;margin-left:8px;">手机端:</span>';
+            html += '<select class="section-columns-select" onchange="updatePackGridMobileColumns(' + idx + ', this.value)">';
+            html += '<option value="1"' + (mobileCols === 1 ? ' selected' : '') + '>1 列</option>';
+            html += '<option value="2"' + (mobileCols === 2 ? ' selected' : '') + '>2 列</option>';
             html += '</select></div>';
         }
 
@@ -2160,6 +2601,24 @@ function renderSectionList() {
             html += '<option value="success"' + (style === 'success' ? ' selected' : '') + '>成功（绿色）</option>';
             html += '<option value="warning"' + (style === 'warning' ? ' selected' : '') + '>警告（橙色）</option>';
             html += '</select></div>';
+            var startAt = (sec.settings && sec.settings.start_at) || '';
+            var endAt = (sec.settings && sec.settings.end_at) || '';
+            html += '<div class="field-group"><label>展示时间（可选，留空表示不限）</label>';
+            html += '<div style="display:flex;gap:8px;flex-wrap:wrap;">';
+            html += '<input type="datetime-local" value="' + toLocalDatetimeValue(startAt) + '" onchange="updateBannerWindow(' + idx + ', \'start_at\', this.value)">';
+            html += '<span style="font-size:12px;color:#64748b;align-self:center;">至</span>';
+            html += '<input type="datetime-local" value="' + toLocalDatetimeValue(endAt) + '" onchange="updateBannerWindow(' + idx + ', \'end_at\', this.value)">';
+            html += '</div></div>';
+            var imageId = (sec.settings && sec.settings.image_id) || 0;
+            html += '<div class="field-group"><label>横幅图片（可选，设置后替代文本显示）</label>';
+            if (imageId) {
+                html += '<div style="margin-bottom:6px;"><img src="/store/{{.Storefront.PublicID}}/banner/' + imageId + '" alt="Banner" style="max-width:100%;max-height:120px;border-radius:8px;"></div>';
+                html += '<button class="btn btn-ghost btn-sm" onclick="clearBannerImage(' + idx + ')">移除图片</button>';
+            } else {
+                html += '<input type="file" accept="image/png,image/jpeg,image/webp" onchange="uploadBannerImage(' + idx + ', this)">';
+                html += '<div class="field-hint" style="margin-top:2px;">支持 PNG、JPEG 或 WebP 格式，文件大小不超过 2MB</div>';
+            }
+            html += '</div>';
             html += '</div>';
         }
 
@@ -2219,12 +2678,31 @@ function updatePackGridColumns(idx, val) {
     _layoutSections[idx].settings.columns = cols;
 }
 
+function updatePackGridMobileColumns(idx, val) {
+    if (idx < 0 || idx >= _layoutSections.length) return;
+    var cols = parseInt(val);
+    if (cols < 1 || cols > 2) cols = 1;
+    if (!_layoutSections[idx].settings) _layoutSections[idx].settings = {};
+    _layoutSections[idx].settings.mobile_columns = cols;
+}
+
 function updateHeroLayout(idx, val) {
     if (idx < 0 || idx >= _layoutSections.length) return;
     if (!_layoutSections[idx].settings) _layoutSections[idx].settings = {};
     _layoutSections[idx].settings.hero_layout = val;
 }
 
+function updateHeroCTA(idx, field, val) {
+    if (idx < 0 || idx >= _layoutSections.length) return;
+    if (!_layoutSections[idx].settings) _layoutSections[idx].settings = {};
+    val = val.trim();
+    if (val) {
+        _layoutSections[idx].settings[field] = val;
+    } else {
+        delete _layoutSections[idx].settings[field];
+    }
+}
+
 function updateBannerText(idx, val) {
     if (idx < 0 || idx >= _layoutSections.length) return;
     if (val.length > 500) val = val.substring(0, 500);
@@ -2240,6 +2718,67 @@ function updateBannerStyle(idx, val) {
     _layoutSections[idx].settings.style = val;
 }
 
+/* Banner schedule: settings store RFC3339 (UTC), inputs show browser-local time */
+function toLocalDatetimeValue(iso) {
+    if (!iso) return '';
+    var d = new Date(iso);
+    if (isNaN(d.getTime())) return '';
+    var pad = function(n) { return (n < 10 ? '0' : '') + n; };
+    return d.getFullYear() + '-' + pad(d.getMonth() + 1) + '-' + pad(d.getDate()) + 'T' + pad(d.getHours()) + ':' + pad(d.getMinutes());
+}
+
+function updateBannerWindow(idx, field, val) {
+    if (idx < 0 || idx >= _layoutSections.length) return;
+    if (!_layoutSections[idx].settings) _layoutSections[idx].settings = {};
+    if (!val) {
+        delete _layoutSections[idx].settings[field];
+        return;
+    }
+    var d = new Date(val);
+    if (isNaN(d.getTime())) return;
+    _layoutSections[idx].settings[field] = d.toISOString().replace(/\.\d{3}Z$/, 'Z');
+}
+
+function uploadBannerImage(idx, input) {
+    if (idx < 0 || idx >= _layoutSections.length) return;
+    if (!input.files.length) return;
+    var file = input.files[0];
+    if (file.size > 2 * 1024 * 1024) {
+        showMsg('err', '图片大小不能超过 2MB');
+        return;
+    }
+    var fd = new FormData();
+    fd.append('image', file);
+    fetch('/user/storefront/banner-image', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (d.success) {
+            if (!_layoutSections[idx].settings) _layoutSections[idx].settings = {};
+            _layoutSections[idx].settings.image_id = d.image_id;
+            renderSectionList();
+            showToast('图片已上传，保存布局后生效');
+        } else {
+            showMsg('err', d.error || '上传失败');
+        }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
+function clearBannerImage(idx) {
+    if (idx < 0 || idx >= _layoutSections.length) return;
+    var settings = _layoutSections[idx].settings || {};
+    var imageId = settings.image_id;
+    delete settings.image_id;
+    renderSectionList();
+    if (!imageId) return;
+    var fd = new FormData();
+    fd.append('image_id', imageId);
+    fetch('/user/storefront/banner-image/delete', { method: 'POST', body: fd })
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+        if (!d.success) { showMsg('err', d.error || '删除失败'); }
+    }).catch(function() { showMsg('err', '网络错误'); });
+}
+
 function addCustomBanner() {
     var bannerCount = 0;
     _layoutSections.forEach(function(s) { if (s.type === 'custom_banner') bannerCount++; });
@@ -2258,6 +2797,9 @@ function addCustomBanner() {
 function removeCustomBanner(idx) {
     if (idx < 0 || idx >= _layoutSections.length) return;
     if (_layoutSections[idx].type !== 'custom_banner') return;
+    if (_layoutSections[idx].settings && _layoutSections[idx].settings.image_id) {
+        clearBannerImage(idx);
+    }
     _layoutSections.splice(idx, 1);
     renderSectionList();
 }
@@ -2358,6 +2900,7 @@ function getSectionDragAfter(container, y) {
 // Initialize layout sections on page load
 document.addEventListener('DOMContentLoaded', function() {
     initLayoutSections();
+    loadLayoutPresets();
     initCustomProductDragDrop();
     // If already in custom layout, show publish decoration bar
     var currentLayout = '{{.Storefront.StoreLayout}}';
@@ -2492,6 +3035,8 @@ function applySupportSystem() {
         } else {
             showMsg('err', d.error || '申请失败');
             if (btn) btn.disabled = false;
+            // Unverified email: send the user to the dashboard where verification can be requested
+            if (d.code === 'email_unverified') setTimeout(function() { location.href = '/user/'; }, 1500);
         }
     }).catch(function() {
         showMsg('err', '网络错误');
@@ -2671,10 +3216,20 @@ const storefrontCustomProductOrdersHTML = `<!DOCTYPE html>
                     <option value="failed"{{if eq .FilterStatus "failed"}} selected{{end}}>失败</option>
                 </select>
             </div>
+            <div class="filter-group">
+                <label>开始日期</label>
+                <input type="date" name="date_from" value="{{.FilterDateFrom}}">
+            </div>
+            <div class="filter-group">
+                <label>结束日期</label>
+                <input type="date" name="date_to" value="{{.FilterDateTo}}">
+            </div>
             <button type="submit" class="btn btn-indigo">🔍 筛选</button>
-            {{if or .FilterProductName .FilterStatus}}
+            {{if or .FilterProductName .FilterStatus .FilterDateFrom .FilterDateTo}}
             <a href="/user/storefront/custom-product-orders" class="btn btn-ghost">清除筛选</a>
             {{end}}
+            <a href="/user/storefront/custom-product-orders/export?format=csv&product_name={{.FilterProductName}}&status={{.FilterStatus}}&date_from={{.FilterDateFrom}}&date_to={{.FilterDateTo}}" class="btn btn-ghost">⬇️ 导出 CSV</a>
+            <a href="/user/storefront/custom-product-orders/export?format=xlsx&product_name={{.FilterProductName}}&status={{.FilterStatus}}&date_from={{.FilterDateFrom}}&date_to={{.FilterDateTo}}" class="btn btn-ghost">⬇️ 导出 Excel</a>
         </form>
     </div>
 
@@ -2700,7 +3255,7 @@ const storefrontCustomProductOrdersHTML = `<!DOCTYPE html>
                         <td>#{{.ID}}</td>
                         <td>{{.ProductName}}</td>
                         <td>{{.BuyerEmail}}</td>
-                        <td>$ {{printf "%.2f" .AmountUSD}}</td>
+                        <td>{{formatMoney .ChargedAmount .ChargedCurrency ""}}</td>
                         <td>
                             <span class="status-badge status-{{.Status}}">
                                 {{if eq .Status "pending"}}待支付{{end}}
@@ -2711,6 +3266,9 @@ const storefrontCustomProductOrdersHTML = `<!DOCTYPE html>
                             {{if and (eq .ProductType "virtual_goods") (eq .Status "fulfilled") (ne .LicenseSN "")}}
                             <div class="sn-info">🔑 SN: {{.LicenseSN}}</div>
                             {{end}}
+                            {{if eq .Status "fulfilled"}}
+                            <div><a href="/user/receipts/order?id={{.ID}}" style="font-size:12px;">🧾 收据</a></div>
+                            {{end}}
                         </td>
                         <td>{{.CreatedAt}}</td>
                     </tr>
@@ -12,16 +12,17 @@ const novP1 = `<!DOCTYPE html>
 <meta charset="UTF-8"><meta name="viewport" content="width=device-width, initial-scale=1.0">
 <meta name="default-lang" content="{{.DefaultLang}}">
 <title>{{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}小铺{{end}} - 分析技能包市场</title>
+<link rel="alternate" type="application/atom+xml" title="{{.Storefront.StoreName}}" href="/store/{{.Storefront.PublicID}}/feed.xml">
 <meta property="og:type" content="website" />
 <meta property="og:title" content="{{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}小铺{{end}}" />
-<meta property="og:description" content="{{if .Storefront.Description}}{{truncateDesc .Storefront.Description 200}}{{else}}该作者暂未设置小铺描述{{end}}" />
+<meta property="og:description" content="{{if .Storefront.Description}}{{truncateDesc (markdownText .Storefront.Description) 200}}{{else}}该作者暂未设置小铺描述{{end}}" />
 {{if .Storefront.HasLogo}}<meta property="og:image" content="/store/{{.Storefront.PublicID}}/logo" />{{end}}
 <meta name="twitter:card" content="summary" />
 <meta name="twitter:title" content="{{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}小铺{{end}}" />
-<meta name="twitter:description" content="{{if .Storefront.Description}}{{truncateDesc .Storefront.Description 200}}{{else}}该作者暂未设置小铺描述{{end}}" />
+<meta name="twitter:description" content="{{if .Storefront.Description}}{{truncateDesc (markdownText .Storefront.Description) 200}}{{else}}该作者暂未设置小铺描述{{end}}" />
 {{if .Storefront.HasLogo}}<meta name="twitter:image" content="/store/{{.Storefront.PublicID}}/logo" />{{end}}
 <style>
-*,*::before,*::after{margin:0;padding:0;box-sizing:border-box;}
+` + markdownCSS + `*,*::before,*::after{margin:0;padding:0;box-sizing:border-box;}
 :root{--g100:#fdf6e3;--g200:#f5e6b8;--g300:#e8d08a;--g400:#d4b45a;--g500:#b8943a;--g600:#9a7a2e;--g700:#7c6124;--cream:#faf7f0;--tp:#3d3425;--ts:#7a6f5d;--tm:#a89f8b;--cbg:rgba(255,255,255,0.85);--cb:rgba(212,180,90,0.25);--cs:0 4px 24px rgba(184,148,58,0.08);}
 body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,"Microsoft YaHei",sans-serif;background:var(--cream);min-height:100vh;color:var(--tp);line-height:1.6;-webkit-font-smoothing:antialiased;position:relative;overflow-x:hidden;}
 body::before{content:'';position:fixed;top:-120px;right:-80px;width:500px;height:500px;border-radius:50%;background:radial-gradient(ellipse,rgba(212,180,90,0.12) 0%,transparent 70%);pointer-events:none;z-index:0;}
@@ -45,9 +46,11 @@ body::after{content:'';position:fixed;bottom:-100px;left:-60px;width:400px;heigh
 .store-avatar{width:100%;height:100%;border-radius:50%;overflow:hidden;background:#fff;}
 .store-avatar img{width:100%;height:100%;object-fit:cover;}
 .store-avatar-letter{width:100%;height:100%;background:linear-gradient(135deg,var(--g400),var(--g600));display:flex;align-items:center;justify-content:center;font-size:42px;font-weight:800;color:#fff;}
+.verified-badge{display:inline-flex;align-items:center;gap:3px;padding:2px 8px;margin-left:6px;vertical-align:middle;font-size:11px;font-weight:700;letter-spacing:0;color:#0369a1;background:#e0f2fe;border:1px solid #bae6fd;border-radius:999px;}
 .store-name{font-size:22px;font-weight:800;color:var(--tp);margin-bottom:8px;letter-spacing:-0.4px;}
 .store-desc{font-size:13px;color:var(--ts);line-height:1.7;max-width:220px;}
 .store-stats{display:flex;gap:16px;margin-top:14px;}
+.store-follow{margin-top:14px;}
 .store-stat{display:flex;flex-direction:column;align-items:center;padding:8px 16px;background:rgba(255,255,255,0.7);border-radius:12px;border:1px solid var(--cb);}
 .store-stat-val{font-size:18px;font-weight:800;color:var(--g600);}
 .store-stat-label{font-size:10px;color:var(--tm);font-weight:600;text-transform:uppercase;letter-spacing:0.5px;}
@@ -113,6 +116,8 @@ const novP2 = `.store-featured{flex:1;min-width:0;display:flex;flex-direction:co
 .total-price{font-size:18px;font-weight:800;color:var(--g600);margin-bottom:4px;}
 .msg{display:none;padding:14px 18px;border-radius:12px;font-size:13px;margin-bottom:16px;font-weight:600;}
 .msg-ok{background:#f0f5e8;color:#5a7a2e;border:1px solid #d4e4b8;}.msg-err{background:#fef2f2;color:#dc2626;border:1px solid #fecaca;}
+.store-announcement{padding:12px 18px;border-radius:12px;font-size:13px;margin-bottom:16px;background:var(--g100);color:var(--g700);border:1px solid var(--g200);white-space:pre-line;}
+.store-paused-banner{padding:14px 18px;border-radius:12px;font-size:13px;margin-bottom:16px;background:#fffbeb;color:#92400e;border:1px solid #fde68a;}
 .foot{text-align:center;margin-top:36px;padding-top:20px;border-top:1px solid rgba(212,180,90,0.15);}
 .foot-text{font-size:12px;color:var(--tm);font-weight:500;}.foot-text a{color:var(--g600);text-decoration:none;font-weight:600;}.foot-text a:hover{text-decoration:underline;}
 .powered-by{margin-top:10px;font-size:11px;color:var(--tm);font-weight:500;display:flex;align-items:center;justify-content:center;gap:5px;}
@@ -122,26 +127,29 @@ const novP2 = `.store-featured{flex:1;min-width:0;display:flex;flex-direction:co
 </style></head><body>
 `
 const novP3 = `<div class="page">
+{{if and .Storefront.AnnouncementActive .Storefront.Announcement}}<div class="store-announcement">📢 {{.Storefront.Announcement}}</div>{{end}}
 <nav class="nav"><a class="logo-link" href="/"><span class="logo-mark"><img src="{{logoURL}}" alt="" style="width:100%;height:100%;object-fit:cover;border-radius:inherit;"></span><span class="logo-text" data-i18n="site_name">分析技能包市场</span></a>
 <div class="nav-actions">{{if or .DownloadURLWindows .DownloadURLMacOS}}<span id="sfDlBtn"></span>{{end}}{{if .IsLoggedIn}}<a class="nav-link" href="/user/dashboard" data-i18n="personal_center">个人中心</a>{{else}}<a class="nav-link" href="/user/login" data-i18n="login">登录</a>{{end}}</div></nav>
 <div class="store-hero"><div class="hero-glow"></div><div class="store-hero-inner{{if eq .HeroLayout "reversed"}} hero-reversed{{end}}">
 <div class="store-profile"><div class="store-avatar-ring"><div class="store-avatar">{{if .Storefront.HasLogo}}<img src="/store/{{.Storefront.PublicID}}/logo" alt="{{.Storefront.StoreName}}">{{else}}<div class="store-avatar-letter">{{firstChar .Storefront.StoreName}}</div>{{end}}</div></div>
-<h1 class="store-name">{{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}小铺{{end}}</h1>
-<p class="store-desc">{{if .Storefront.Description}}{{.Storefront.Description}}{{else}}该作者暂未设置小铺描述{{end}}</p>
-<div class="store-stats"><div class="store-stat"><span class="store-stat-val">{{len .Packs}}</span><span class="store-stat-label" data-i18n="stat_packs">分析包</span></div>{{if and .FeaturedPacks .FeaturedVisible}}<div class="store-stat"><span class="store-stat-val">{{len .FeaturedPacks}}</span><span class="store-stat-label" data-i18n="stat_featured">推荐</span></div>{{end}}</div></div>
+<h1 class="store-name">{{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}小铺{{end}}{{if .Storefront.Verified}}<span class="verified-badge" title="该卖家已通过平台认证" data-i18n-title="verified_seller_hint"><svg viewBox="0 0 24 24" width="14" height="14" fill="none" stroke="currentColor" stroke-width="3" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg><span data-i18n="verified_seller">认证卖家</span></span>{{end}}</h1>
+<div class="store-desc md">{{if .Storefront.Description}}{{renderMarkdown .Storefront.Description}}{{else}}该作者暂未设置小铺描述{{end}}</div>
+<div class="store-stats"><div class="store-stat"><span class="store-stat-val">{{len .Packs}}</span><span class="store-stat-label" data-i18n="stat_packs">分析包</span></div>{{if and .FeaturedPacks .FeaturedVisible}}<div class="store-stat"><span class="store-stat-val">{{len .FeaturedPacks}}</span><span class="store-stat-label" data-i18n="stat_featured">推荐</span></div>{{end}}<div class="store-stat"><span class="store-stat-val" id="followerCount">{{.Storefront.FollowerCount}}</span><span class="store-stat-label" data-i18n="stat_followers">关注</span></div></div>
+{{if ne .CurrentUserID .Storefront.UserID}}<div class="store-follow">{{if .IsLoggedIn}}<button class="btn btn-indigo" id="followBtn" data-following="{{if .IsFollowing}}1{{else}}0{{end}}" onclick="toggleFollow({{.Storefront.ID}})">{{if .IsFollowing}}<span data-i18n="following_store">已关注</span>{{else}}<span data-i18n="follow_store">关注小铺</span>{{end}}</button>{{else}}<a class="btn btn-indigo" href="/user/login?redirect=/store/{{.Storefront.PublicID}}" data-i18n="follow_store">关注小铺</a>{{end}}</div>{{end}}</div>
 {{if and .FeaturedPacks .FeaturedVisible}}<div class="store-featured"><div class="store-featured-header"><div class="store-featured-title"><svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 2l3.09 6.26L22 9.27l-5 4.87 1.18 6.88L12 17.77l-6.18 3.25L7 14.14 2 9.27l6.91-1.01L12 2z"/></svg><span data-i18n="featured_packs">店主推荐</span></div></div>
-<div class="featured-grid">{{range .FeaturedPacks}}<a class="featured-card" href="/pack/{{.ShareToken}}" target="_blank" rel="noopener"><div class="featured-card-top">{{if .HasLogo}}<img class="featured-icon-img" src="/store/{{$.Storefront.PublicID}}/featured/{{.ListingID}}/logo" alt="{{.PackName}}">{{else}}<div class="featured-icon"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><line x1="18" y1="20" x2="18" y2="10"/><line x1="12" y1="20" x2="12" y2="4"/><line x1="6" y1="20" x2="6" y2="14"/></svg></div>{{end}}<div class="featured-card-title"><div class="featured-name" title="{{.PackName}}">{{.PackName}}</div>{{if eq .ShareMode "free"}}<span class="featured-tag featured-tag-free" data-i18n="free">免费</span>{{else if eq .ShareMode "per_use"}}<span class="featured-tag featured-tag-per_use" data-i18n="per_use">按次收费</span>{{else if eq .ShareMode "subscription"}}<span class="featured-tag featured-tag-subscription" data-i18n="subscription">订阅制</span>{{end}}</div></div>{{if .PackDesc}}<div class="featured-desc">{{.PackDesc}}</div>{{else}}<div class="featured-desc" style="color:var(--tm);" data-i18n="no_description">暂无描述</div>{{end}}<div class="featured-footer">{{if eq .ShareMode "free"}}<span class="featured-price price-free" data-i18n="free">免费</span>{{else}}<span class="featured-price price-paid">{{.CreditsPrice}} Credits</span>{{end}}<span class="featured-downloads"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4"/><polyline points="7 10 12 15 17 10"/><line x1="12" y1="15" x2="12" y2="3"/></svg>{{.DownloadCount}}</span></div></a>{{end}}</div></div>{{end}}
+<div class="featured-grid">{{range .FeaturedPacks}}<a class="featured-card" href="/pack/{{.ShareToken}}" target="_blank" rel="noopener"><div class="featured-card-top">{{if .HasLogo}}<img class="featured-icon-img" src="/store/{{$.Storefront.PublicID}}/featured/{{.ListingID}}/logo" alt="{{.PackName}}">{{else}}<div class="featured-icon"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><line x1="18" y1="20" x2="18" y2="10"/><line x1="12" y1="20" x2="12" y2="4"/><line x1="6" y1="20" x2="6" y2="14"/></svg></div>{{end}}<div class="featured-card-title"><div class="featured-name" title="{{.PackName}}">{{.PackName}}</div>{{if eq .ShareMode "free"}}<span class="featured-tag featured-tag-free" data-i18n="free">免费</span>{{else if eq .ShareMode "per_use"}}<span class="featured-tag featured-tag-per_use" data-i18n="per_use">按次收费</span>{{else if eq .ShareMode "subscription"}}<span class="featured-tag featured-tag-subscription" data-i18n="subscription">订阅制</span>{{end}}</div></div>{{if .PackDesc}}<div class="featured-desc">{{markdownText .PackDesc}}</div>{{else}}<div class="featured-desc" style="color:var(--tm);" data-i18n="no_description">暂无描述</div>{{end}}<div class="featured-footer">{{if eq .ShareMode "free"}}<span class="featured-price price-free" data-i18n="free">免费</span>{{else}}<span class="featured-price price-paid">{{.CreditsPrice}} Credits</span>{{end}}<span class="featured-downloads"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4"/><polyline points="7 10 12 15 17 10"/><line x1="12" y1="15" x2="12" y2="3"/></svg>{{.DownloadCount}}</span></div></a>{{end}}</div></div>{{end}}
 </div></div>
 <div class="msg msg-ok" id="successMsg"></div><div class="msg msg-err" id="errorMsg"></div>
+{{if .IsPaused}}<div class="store-paused-banner"><strong data-i18n="store_paused_title">小铺暂停营业中</strong> <span data-i18n="store_paused_desc">店主暂时关闭了小铺，目前不接受购买或领取。已购买的分析包仍可正常使用，欢迎稍后再来。</span></div>{{end}}
 <div class="filter-bar"><div class="filter-group"><a class="filter-btn{{if eq .Filter ""}} active{{end}}" href="?filter=&sort={{.Sort}}&q={{.SearchQuery}}&cat={{.CategoryFilter}}" data-i18n="filter_all">全部</a><a class="filter-btn{{if eq .Filter "free"}} active{{end}}" href="?filter=free&sort={{.Sort}}&q={{.SearchQuery}}&cat={{.CategoryFilter}}" data-i18n="free">免费</a><a class="filter-btn{{if eq .Filter "per_use"}} active{{end}}" href="?filter=per_use&sort={{.Sort}}&q={{.SearchQuery}}&cat={{.CategoryFilter}}" data-i18n="per_use">按次收费</a><a class="filter-btn{{if eq .Filter "subscription"}} active{{end}}" href="?filter=subscription&sort={{.Sort}}&q={{.SearchQuery}}&cat={{.CategoryFilter}}" data-i18n="subscription">订阅制</a></div>
 {{if .Categories}}<select class="sort-select" id="catSelect" onchange="changeCat(this.value)"><option value=""{{if eq .CategoryFilter ""}} selected{{end}} data-i18n="all_categories">全部类别</option>{{range .Categories}}<option value="{{.}}"{{if eq $.CategoryFilter .}} selected{{end}}>{{.}}</option>{{end}}</select>{{end}}
 <form id="searchForm" method="GET" style="display:flex;gap:8px;align-items:center;"><input type="hidden" name="filter" value="{{.Filter}}"><input type="hidden" name="sort" value="{{.Sort}}"><input type="hidden" name="cat" value="{{.CategoryFilter}}"><input class="search-input" type="text" name="q" value="{{.SearchQuery}}" placeholder="搜索分析包..." data-i18n-placeholder="search_packs"></form>
 <select class="sort-select" id="sortSelect" onchange="changeSort(this.value)"><option value="revenue"{{if eq .Sort "revenue"}} selected{{end}} data-i18n="sort_revenue">按销售金额</option><option value="downloads"{{if eq .Sort "downloads"}} selected{{end}} data-i18n="sort_downloads">按下载量</option><option value="orders"{{if eq .Sort "orders"}} selected{{end}} data-i18n="sort_orders">按订单数</option></select></div>
-{{if .Packs}}<div class="pack-list">{{range .Packs}}<div class="pack-item"><div class="pack-item-body"><div class="pack-item-header"><span class="pack-item-name">{{.PackName}}</span>{{if eq .ShareMode "free"}}<span class="tag tag-free" data-i18n="free">免费</span>{{else if eq .ShareMode "per_use"}}<span class="tag tag-per-use" data-i18n="per_use">按次收费</span>{{else if eq .ShareMode "subscription"}}<span class="tag tag-subscription" data-i18n="subscription">订阅制</span>{{end}}{{if .CategoryName}}<span class="tag tag-category">{{.CategoryName}}</span>{{end}}</div>{{if .PackDesc}}<div class="pack-item-desc">{{.PackDesc}}</div>{{end}}</div>
+{{if .Packs}}<div class="pack-list">{{range .Packs}}<div class="pack-item"><div class="pack-item-body"><div class="pack-item-header"><span class="pack-item-name">{{.PackName}}</span>{{if eq .ShareMode "free"}}<span class="tag tag-free" data-i18n="free">免费</span>{{else if eq .ShareMode "per_use"}}<span class="tag tag-per-use" data-i18n="per_use">按次收费</span>{{else if eq .ShareMode "subscription"}}<span class="tag tag-subscription" data-i18n="subscription">订阅制</span>{{end}}{{if .CategoryName}}<span class="tag tag-category">{{.CategoryName}}</span>{{end}}</div>{{if .PackDesc}}<div class="pack-item-desc">{{markdownText .PackDesc}}</div>{{end}}</div>
 <div class="pack-item-footer"><div class="pack-item-meta">{{if eq .ShareMode "free"}}<span class="meta-item"><span class="pack-item-price price-free" data-i18n="free">免费</span></span>{{else}}<span class="meta-item"><span class="pack-item-price">{{.CreditsPrice}} Credits</span></span>{{end}}<span class="meta-item"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4"/><polyline points="7 10 12 15 17 10"/><line x1="12" y1="15" x2="12" y2="3"/></svg>{{.DownloadCount}}</span></div>
-<div class="pack-item-actions">{{if $.IsLoggedIn}}{{if index $.PurchasedIDs .ListingID}}<span class="badge-owned"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2.5" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg><span data-i18n="already_purchased">已购买</span></span>{{else if eq .ShareMode "free"}}<button class="btn btn-green" onclick="claimPack('{{.ShareToken}}')" data-i18n="claim_free">免费领取</button>{{else}}<button class="btn btn-indigo" onclick="showPurchaseDialog('{{.ShareToken}}', '{{.ShareMode}}', {{.CreditsPrice}}, '{{.PackName}}')" data-i18n="purchase">购买</button>{{end}}{{else}}{{if eq .ShareMode "free"}}<a class="btn btn-green" href="/user/login?redirect=/store/{{$.Storefront.PublicID}}" data-i18n="login_to_claim">登录后领取</a>{{else}}<a class="btn btn-indigo" href="/user/login?redirect=/store/{{$.Storefront.PublicID}}" data-i18n="login_to_buy">登录后购买</a>{{end}}{{end}}</div></div></div>{{end}}</div>
+<div class="pack-item-actions">{{if $.IsPaused}}<button class="btn" disabled data-i18n="store_paused_btn">暂停营业</button>{{else if $.IsLoggedIn}}{{if index $.PurchasedIDs .ListingID}}<span class="badge-owned"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2.5" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg><span data-i18n="already_purchased">已购买</span></span>{{else if eq .ShareMode "free"}}<button class="btn btn-green" onclick="claimPack('{{.ShareToken}}')" data-i18n="claim_free">免费领取</button>{{else}}<button class="btn btn-indigo" onclick="showPurchaseDialog('{{.ShareToken}}', '{{.ShareMode}}', {{.CreditsPrice}}, '{{.PackName}}')" data-i18n="purchase">购买</button>{{end}}{{else}}{{if eq .ShareMode "free"}}<a class="btn btn-green" href="/user/login?redirect=/store/{{$.Storefront.PublicID}}" data-i18n="login_to_claim">登录后领取</a>{{else}}<a class="btn btn-indigo" href="/user/login?redirect=/store/{{$.Storefront.PublicID}}" data-i18n="login_to_buy">登录后购买</a>{{end}}{{end}}</div></div></div>{{end}}</div>
 {{else}}<div class="empty-state"><div class="icon">📭</div><p data-i18n="storefront_empty">该小铺暂无分析包</p></div>{{end}}
-<div class="foot"><p class="foot-text">Vantagics <span data-i18n="site_name">分析技能包市场</span> &middot; <a href="/" data-i18n="browse_more">浏览更多</a></p><div class="powered-by">Powered by <a href="https://vantagics.com" target="_blank" rel="noopener"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 16V8a2 2 0 0 0-1-1.73l-7-4a2 2 0 0 0-2 0l-7 4A2 2 0 0 0 3 8v8a2 2 0 0 0 1 1.73l7 4a2 2 0 0 0 2 0l7-4A2 2 0 0 0 21 16z"/><polyline points="3.27 6.96 12 12.01 20.73 6.96"/><line x1="12" y1="22.08" x2="12" y2="12"/></svg>Vantagics</a></div></div></div>
+<div class="foot"><p class="foot-text">Vantagics <span data-i18n="site_name">分析技能包市场</span> &middot; <a href="/" data-i18n="browse_more">浏览更多</a>{{if .Storefront.HasPolicies}} &middot; <a href="/store/{{.Storefront.PublicID}}/policies" data-i18n="store_policies">店铺政策</a>{{end}}</p><div class="powered-by">Powered by <a href="https://vantagics.com" target="_blank" rel="noopener"><svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 16V8a2 2 0 0 0-1-1.73l-7-4a2 2 0 0 0-2 0l-7 4A2 2 0 0 0 3 8v8a2 2 0 0 0 1 1.73l7 4a2 2 0 0 0 2 0l7-4A2 2 0 0 0 21 16z"/><polyline points="3.27 6.96 12 12.01 20.73 6.96"/><line x1="12" y1="22.08" x2="12" y2="12"/></svg>Vantagics</a></div></div></div>
 <div class="modal-overlay" id="purchaseModal"><div class="modal-box">
 <button class="modal-close" onclick="closePurchaseDialog()">&times;</button>
 <div class="modal-title" id="purchaseModalTitle" data-i18n="purchase">购买</div>
@@ -171,6 +179,7 @@ function showToast(msg){var t=document.getElementById('toast');t.textContent=msg
 function showMsg(type,msg){var s=document.getElementById('successMsg');var e=document.getElementById('errorMsg');if(s)s.style.display='none';if(e)e.style.display='none';if(type==='success'&&s){s.textContent=msg;s.style.display='block';}else if(e){e.textContent=msg;e.style.display='block';}}
 function changeSort(val){var p=new URLSearchParams(window.location.search);p.set('sort',val);window.location.search=p.toString();}
 function changeCat(val){var p=new URLSearchParams(window.location.search);p.set('cat',val);window.location.search=p.toString();}
+function toggleFollow(storefrontID){var btn=document.getElementById('followBtn');var following=btn.getAttribute('data-following')==='1';var req=following?fetch('/user/follows?storefront_id='+storefrontID,{method:'DELETE'}):fetch('/user/follows',{method:'POST',headers:{'Content-Type':'application/json'},body:JSON.stringify({storefront_id:storefrontID})});req.then(function(r){return r.json();}).then(function(d){if(!d.success){showMsg('error',d.error||window._i18n('follow_failed','操作失败'));return;}btn.setAttribute('data-following',d.following?'1':'0');btn.textContent=d.following?window._i18n('following_store','已关注'):window._i18n('follow_store','关注小铺');document.getElementById('followerCount').textContent=d.follower_count;}).catch(function(){showMsg('error',window._i18n('network_error','网络错误'));});}
 function claimPack(shareToken){if(!confirm(window._i18n('add_to_purchased_confirm','是否将此分析包添加到您的已购分析技能包中？')))return;fetch('/pack/'+shareToken+'/claim',{method:'POST',headers:{'Content-Type':'application/json'}}).then(function(r){return r.json();}).then(function(d){if(d.success){showMsg('success',window._i18n('claim_success','领取成功！'));setTimeout(function(){location.reload();},1000);}else{showMsg('error',d.error||window._i18n('claim_failed','领取失败'));}}).catch(function(){showMsg('error',window._i18n('network_error','网络错误'));});}
 function showPurchaseDialog(shareToken,shareMode,creditsPrice,packName){_currentShareToken=shareToken;_currentShareMode=shareMode;_currentCreditsPrice=creditsPrice;document.getElementById('purchaseModalTitle').textContent=window._i18n('purchase','购买')+' - '+packName;var pu=document.getElementById('perUseFields');var su=document.getElementById('subscriptionFields');pu.style.display='none';su.style.display='none';if(shareMode==='per_use'){pu.style.display='block';document.getElementById('purchaseQuantity').value=1;}else if(shareMode==='subscription'){su.style.display='block';document.getElementById('purchaseDuration').selectedIndex=0;}updatePurchaseTotal();document.getElementById('purchaseModal').classList.add('show');}
 function closePurchaseDialog(){document.getElementById('purchaseModal').classList.remove('show');}
@@ -0,0 +1,50 @@
+package templates
+
+import "html/template"
+
+// StorefrontPoliciesTmpl is the parsed storefront refund/terms/contact page template.
+var StorefrontPoliciesTmpl = template.Must(template.New("storefront_policies").Funcs(BaseFuncMap).Parse(storefrontPoliciesHTML))
+
+const storefrontPoliciesHTML = `<!DOCTYPE html>
+<html lang="{{.HtmlLang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{index .T "store_policies"}} - {{if .Storefront.StoreName}}{{.Storefront.StoreName}}{{else}}{{index .T "site_name"}}{{end}}</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f8fafc; color: #1e293b; }
+        .page { max-width: 760px; margin: 0 auto; padding: 32px 20px 48px; }
+        .back { display: inline-block; font-size: 13px; color: #6366f1; text-decoration: none; margin-bottom: 16px; }
+        h1 { font-size: 24px; font-weight: 800; margin-bottom: 24px; }
+        .policy { background: #fff; border: 1px solid #e2e8f0; border-radius: 14px; padding: 22px 24px; margin-bottom: 16px; }
+        .policy h2 { font-size: 16px; font-weight: 700; margin-bottom: 10px; }
+        .policy-text { font-size: 14px; line-height: 1.7; color: #334155; white-space: pre-wrap; word-break: break-word; }
+    </style>
+</head>
+<body>
+<div class="page">
+    <a class="back" href="/store/{{.Storefront.PublicID}}">&larr; {{index .T "back_to_store"}}</a>
+    <h1>{{if .Storefront.StoreName}}{{.Storefront.StoreName}} · {{end}}{{index .T "store_policies"}}</h1>
+    {{if .Storefront.RefundPolicy}}
+    <section class="policy" id="refund">
+        <h2>{{index .T "refund_policy"}}</h2>
+        <div class="policy-text">{{.Storefront.RefundPolicy}}</div>
+    </section>
+    {{end}}
+    {{if .Storefront.Terms}}
+    <section class="policy" id="terms">
+        <h2>{{index .T "store_terms"}}</h2>
+        <div class="policy-text">{{.Storefront.Terms}}</div>
+    </section>
+    {{end}}
+    {{if .Storefront.Contact}}
+    <section class="policy" id="contact">
+        <h2>{{index .T "store_contact"}}</h2>
+        <div class="policy-text">{{.Storefront.Contact}}</div>
+    </section>
+    {{end}}
+</div>
+` + I18nJS + `
+</body>
+</html>`
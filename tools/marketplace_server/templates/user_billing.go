@@ -80,6 +80,7 @@ const userBillingHTML = `<!DOCTYPE html>
         .billing-table tr:last-child td { border-bottom: none; }
         .billing-table tr:hover td { background: #f8fafc; }
         .amount-positive { color: #059669; font-weight: 600; }
+        .receipt-link { font-size: 12px; color: #6366f1; text-decoration: none; margin-left: 6px; white-space: nowrap; }
         .amount-negative { color: #ef4444; font-weight: 600; }
 
         /* Empty state */
@@ -119,7 +120,7 @@ const userBillingHTML = `<!DOCTYPE html>
                 <tr>
                     <td>{{.TransactionType}}</td>
                     <td>{{if lt .Amount 0.0}}<span class="amount-negative">{{printf "%.2f" .Amount}}</span>{{else}}<span class="amount-positive">+{{printf "%.2f" .Amount}}</span>{{end}}</td>
-                    <td>{{if .PackName}}{{.PackName}}{{else}}-{{end}}</td>
+                    <td>{{if .PackName}}{{.PackName}}{{if or (eq .TransactionType "purchase") (eq .TransactionType "purchase_uses") (eq .TransactionType "renew") (eq .TransactionType "download")}} <a class="receipt-link" href="/user/receipts/purchase?id={{.ID}}" data-i18n="download_receipt">🧾 收据</a>{{end}}{{else}}-{{end}}</td>
                     <td>{{if .Description}}{{.Description}}{{else}}-{{end}}</td>
                     <td>{{.CreatedAt}}</td>
                 </tr>
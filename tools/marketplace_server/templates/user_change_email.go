@@ -0,0 +1,141 @@
+package templates
+
+import "html/template"
+
+// UserChangeEmailTmpl is the parsed change-email page template.
+var UserChangeEmailTmpl = template.Must(template.New("user_change_email").Funcs(BaseFuncMap).Parse(userChangeEmailHTML))
+
+const userChangeEmailHTML = `<!DOCTYPE html>
+<html lang="{{.HtmlLang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{index .T "change_email_title"}} - {{index .T "site_name"}}</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: linear-gradient(135deg, #f0f4ff 0%, #e8f5e9 50%, #f3e8ff 100%);
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+        .auth-card {
+            background: #fff;
+            border-radius: 16px;
+            padding: 40px;
+            width: 420px;
+            max-width: 90%;
+            box-shadow: 0 4px 24px rgba(0,0,0,0.08);
+            border: 1px solid #e2e8f0;
+        }
+        .logo { text-align: center; margin-bottom: 20px; font-size: 36px; }
+        .auth-card h1 {
+            font-size: 22px;
+            color: #1e293b;
+            margin-bottom: 8px;
+            text-align: center;
+            font-weight: 700;
+        }
+        .auth-card .subtitle {
+            font-size: 14px;
+            color: #64748b;
+            text-align: center;
+            margin-bottom: 28px;
+        }
+        .form-group { margin-bottom: 18px; }
+        .form-group label {
+            display: block;
+            font-size: 13px;
+            color: #475569;
+            margin-bottom: 6px;
+            font-weight: 500;
+        }
+        .form-group input {
+            width: 100%;
+            padding: 10px 12px;
+            border: 1px solid #cbd5e1;
+            border-radius: 8px;
+            font-size: 14px;
+            color: #1e293b;
+            background: #f8fafc;
+            transition: border-color 0.2s, box-shadow 0.2s;
+        }
+        .form-group input:focus {
+            outline: none;
+            border-color: #6366f1;
+            box-shadow: 0 0 0 3px rgba(99,102,241,0.1);
+            background: #fff;
+        }
+        .form-group input::placeholder { color: #94a3b8; }
+        .btn-submit {
+            width: 100%;
+            padding: 11px;
+            background: linear-gradient(135deg, #6366f1, #8b5cf6);
+            color: #fff;
+            border: none;
+            border-radius: 8px;
+            font-size: 15px;
+            font-weight: 500;
+            cursor: pointer;
+            margin-top: 8px;
+            transition: opacity 0.2s;
+        }
+        .btn-submit:hover { opacity: 0.9; }
+        .error-msg {
+            background: #fef2f2;
+            color: #dc2626;
+            padding: 10px 14px;
+            border-radius: 8px;
+            font-size: 13px;
+            margin-bottom: 16px;
+            border: 1px solid #fecaca;
+        }
+        .success-msg {
+            background: #f0fdf4;
+            color: #16a34a;
+            padding: 10px 14px;
+            border-radius: 8px;
+            font-size: 13px;
+            margin-bottom: 16px;
+            border: 1px solid #bbf7d0;
+        }
+        .back-link {
+            display: block;
+            text-align: center;
+            margin-top: 16px;
+            font-size: 13px;
+            color: #6366f1;
+            text-decoration: none;
+        }
+        .back-link:hover { text-decoration: underline; }
+    </style>
+</head>
+<body>
+<div class="auth-card">
+    <div class="logo"><img src="{{logoURL}}" alt="" style="width:48px;height:48px;border-radius:12px;"></div>
+    <h1>{{index .T "change_email_title"}}</h1>
+    <p class="subtitle">{{index .T "change_email_subtitle"}}</p>
+    {{if .Error}}<div class="error-msg">{{.Error}}</div>{{end}}
+    {{if .Success}}<div class="success-msg">{{.Success}}</div>{{end}}
+    <form method="POST" action="/user/change-email">
+        <div class="form-group">
+            <label>{{index .T "current_email"}}</label>
+            <input type="email" value="{{.Email}}" disabled />
+        </div>
+        <div class="form-group">
+            <label for="new_email">{{index .T "new_email_label"}}</label>
+            <input type="email" id="new_email" name="new_email" required autocomplete="email" placeholder="name@example.com" />
+        </div>
+        <div class="form-group">
+            <label for="password">{{index .T "current_password"}}</label>
+            <input type="password" id="password" name="password" required autocomplete="current-password" placeholder="{{index .T "enter_current_password"}}" />
+        </div>
+        <button type="submit" class="btn-submit">{{index .T "send_link_email"}}</button>
+    </form>
+    <a href="/user/dashboard" class="back-link">← {{index .T "back_to_center"}}</a>
+</div>
+` + I18nJS + `
+</body>
+</html>`
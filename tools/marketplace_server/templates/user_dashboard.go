@@ -132,6 +132,12 @@ const userDashboardHTML = `<!DOCTYPE html>
             font-weight: 700;
             font-size: 22px;
         }
+        .credits-info .expiring {
+            display: block;
+            font-size: 12px;
+            color: #b45309;
+            margin-top: 2px;
+        }
         .user-actions {
             display: flex;
             gap: 8px;
@@ -464,6 +470,9 @@ const userDashboardHTML = `<!DOCTYPE html>
         .status-published { background: #ecfdf5; color: #047857; border: 1px solid #a7f3d0; }
         .status-rejected { background: #fef2f2; color: #dc2626; border: 1px solid #fecaca; }
         .status-delisted { background: #f8fafc; color: #64748b; border: 1px solid #e2e8f0; }
+        .status-scheduled { background: #eef2ff; color: #4338ca; border: 1px solid #c7d2fe; }
+        .status-quarantined { background: #fff7ed; color: #c2410c; border: 1px solid #fed7aa; }
+        .publish-at { display: block; font-size: 11px; color: #64748b; margin-top: 4px; }
         .version-badge {
             display: inline-block;
             padding: 2px 8px;
@@ -555,6 +564,26 @@ const userDashboardHTML = `<!DOCTYPE html>
             color: #475569;
             line-height: 1.7;
         }
+        .notification-card.read {
+            border-left-color: #cbd5e1;
+            opacity: 0.75;
+        }
+        .notif-unread-badge {
+            background: #ef4444;
+            color: #fff;
+            font-size: 11px;
+            font-weight: 700;
+            border-radius: 9px;
+            padding: 1px 7px;
+        }
+        .notif-mark-read {
+            margin-left: auto;
+            font-size: 12px;
+            color: #4f46e5;
+            background: none;
+            border: none;
+            cursor: pointer;
+        }
 
         /* Modal overlay */
         .modal-overlay {
@@ -753,18 +782,54 @@ const userDashboardHTML = `<!DOCTYPE html>
     {{if eq .SuccessMsg "withdraw"}}
     <div class="msg-box msg-success" style="display:block;margin-bottom:16px;" data-i18n="err_withdraw_submitted">✅ 提现申请已提交，请等待管理员审核付款。</div>
     {{end}}
-    {{if eq .ErrorMsg "no_payment_info"}}
+    {{if eq .SuccessMsg "email_verified"}}
+    <div class="msg-box msg-success" style="display:block;margin-bottom:16px;" data-i18n="email_verified_success">✅ 邮箱验证成功。</div>
+    {{end}}
+    {{if and .User.Email (not .EmailVerified)}}
+    <div class="msg-box msg-error" id="email-verify-banner" style="display:block;margin-bottom:16px;">
+        <span data-i18n="email_verify_prompt">⚠️ 您的邮箱尚未验证，提现和申请客服支持前需要先完成验证。</span>
+        <button type="button" id="email-verify-btn" onclick="sendEmailVerification()" style="margin-left:8px;padding:4px 12px;border:1px solid #dc2626;border-radius:6px;background:#fff;color:#dc2626;cursor:pointer;font-size:13px;" data-i18n="email_verify_send_btn">发送验证邮件</button>
+    </div>
+    <script>
+    function sendEmailVerification() {
+        var btn = document.getElementById('email-verify-btn');
+        btn.disabled = true;
+        fetch('/user/email/verify/send', { method: 'POST' }).then(function(r) { return r.json(); }).then(function(d) {
+            if (d.ok && d.verified) { location.reload(); return; }
+            if (d.ok) alert(window._i18n ? window._i18n('email_verify_sent', '验证邮件已发送，请查收') : '验证邮件已发送，请查收');
+            else alert(d.error || 'error');
+            var wait = d.cooldown || 0;
+            if (wait <= 0) { btn.disabled = false; return; }
+            var label = btn.textContent;
+            var timer = setInterval(function() {
+                wait--;
+                if (wait <= 0) { clearInterval(timer); btn.textContent = label; btn.disabled = false; return; }
+                btn.textContent = label + ' (' + wait + 's)';
+            }, 1000);
+        }).catch(function() { btn.disabled = false; });
+    }
+    </script>
+    {{end}}
+    {{if eq .ErrorMsg "email_unverified"}}
+    <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="email_verify_required">⚠️ 请先验证邮箱后再进行此操作。</div>
+    {{else if eq .ErrorMsg "email_verify_invalid"}}
+    <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="email_verify_invalid">⚠️ 验证链接无效或已过期，请重新发送验证邮件。</div>
+    {{else if eq .ErrorMsg "no_payment_info"}}
     <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_no_payment_info">⚠️ 请先设置收款信息后再进行提现操作。</div>
     {{else if eq .ErrorMsg "not_author"}}
     <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_not_author">⚠️ 仅作者可以申请提现。</div>
     {{else if eq .ErrorMsg "invalid_withdraw_amount"}}
     <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_invalid_withdraw_amount">⚠️ 提现金额无效，请输入正确的数量。</div>
+    {{else if eq .ErrorMsg "kyc_required"}}
+    <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="withdraw_kyc_required">⚠️ 提现金额超过限额，请先完成实名认证（KYC）并通过审核</div>
     {{else if eq .ErrorMsg "withdraw_disabled"}}
     <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_withdraw_disabled">⚠️ 提现功能暂未开放。</div>
     {{else if eq .ErrorMsg "withdraw_exceeds_balance"}}
     <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_withdraw_exceeds">⚠️ 提现数量超过可提现余额。</div>
     {{else if eq .ErrorMsg "withdraw_below_minimum"}}
-    <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_withdraw_below_min">⚠️ 扣除手续费后实付金额低于最低提现金额 100 元。</div>
+    <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_withdraw_below_min">⚠️ 扣除手续费后实付金额低于最低提现金额。</div>
+    {{else if eq .ErrorMsg "withdraw_exceeds_wallet"}}
+    <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_withdraw_wallet">⚠️ 提现数量超过钱包余额。</div>
     {{else if eq .ErrorMsg "internal"}}
     <div class="msg-box msg-error" style="display:block;margin-bottom:16px;" data-i18n="err_system">⚠️ 系统错误，请稍后重试。</div>
     {{end}}
@@ -788,6 +853,9 @@ const userDashboardHTML = `<!DOCTYPE html>
             <div class="credits-info">
                 <span class="label" data-i18n="credits_balance">Credits 余额</span>
                 <span class="balance">{{printf "%.0f" .User.CreditsBalance}}</span>
+                {{range $i, $lot := .ExpiringCredits}}{{if lt $i 3}}
+                <span class="expiring">⏳ {{printf "%.0f" $lot.Amount}} <span data-i18n="credits_expire_on">Credits 将过期于</span> {{slice $lot.ExpiresAt 0 10}}</span>
+                {{end}}{{end}}
             </div>
             {{if .AuthorData.StorefrontSlug}}
             <button class="btn-share-link" style="padding:7px 14px;font-size:13px;font-weight:600;border-radius:7px;" data-storefront-slug="{{.AuthorData.StorefrontSlug}}" onclick="copyStorefrontLink(this)" data-i18n="share_storefront">🏪 分享小铺</button>
@@ -796,6 +864,7 @@ const userDashboardHTML = `<!DOCTYPE html>
             {{end}}
             {{if .HasPassword}}
             <a class="btn btn-accent" href="/user/change-password" data-i18n="change_password">修改密码</a>
+            <a class="btn btn-accent" href="/user/change-email" data-i18n="change_email">修改邮箱</a>
             {{else}}
             <a class="btn btn-accent" href="/user/set-password" data-i18n="set_password">设置密码</a>
             {{end}}
@@ -803,15 +872,22 @@ const userDashboardHTML = `<!DOCTYPE html>
             <a class="btn btn-ghost" href="/user/custom-product-orders" data-i18n="custom_product_orders">🛒 自定义商品购买记录</a>
             <button class="btn btn-warm" onclick="openPaymentSettingsModal()" data-i18n="payment_settings">收款设置</button>
             <button class="btn btn-secondary" onclick="alert(window._i18n('topup_coming_soon','功能开发中'))" data-i18n="topup">充值</button>
+            <button class="btn btn-secondary" onclick="openGiftCreditsModal()" data-i18n="gift_credits">赠送积分</button>
+            <a class="btn btn-ghost" href="/user/emails" data-i18n="linked_emails">✉️ 关联邮箱</a>
+            <a class="btn btn-ghost" href="/user/data-export" data-i18n="export_my_data">📦 导出我的数据</a>
+            <a class="btn btn-ghost" href="/user/delete-account" data-i18n="delete_account">🗑️ 删除账户</a>
             <a class="btn btn-danger-outline" href="/user/logout" data-i18n="logout">退出登录</a>
         </div>
     </div>
 
     {{if .Notifications}}
     <div class="notification-section">
-        <div class="section-title"><span class="icon">📢</span> <span data-i18n="system_messages">系统消息</span></div>
+        <div class="section-title" style="display:flex;align-items:center;gap:6px;"><span class="icon">📢</span> <span data-i18n="system_messages">系统消息</span>
+            {{if .UnreadNotifications}}<span class="notif-unread-badge" id="notifUnreadBadge">{{.UnreadNotifications}}</span>
+            <button class="notif-mark-read" id="notifMarkAllRead" onclick="markAllNotificationsRead()" data-i18n="mark_all_read">全部标为已读</button>{{end}}
+        </div>
         {{range .Notifications}}
-        <div class="notification-card">
+        <div class="notification-card{{if .Read}} read{{end}}">
             <div class="notif-title">📌 {{.Title}}</div>
             <div class="notif-content">{{.Content}}</div>
         </div>
@@ -852,7 +928,7 @@ const userDashboardHTML = `<!DOCTYPE html>
                         {{if .SourceName}}<br><span class="pack-info-item"><span data-i18n="datasource">数据源：</span><span class="info-value">{{.SourceName}}</span></span>{{end}}
                     </div>
                     <div class="pack-date">{{if eq .ShareMode "subscription"}}<span data-i18n="subscription_start">订阅起始：</span>{{else}}<span data-i18n="download_time">下载时间：</span>{{end}}{{.PurchaseDate}}</div>
-                    {{if .ExpiresAt}}<div class="pack-expires{{if eq .ShareMode "subscription"}} subscription-expires{{end}}">{{if eq .ShareMode "subscription"}}<span data-i18n="subscription_expires">订阅到期：</span>{{else}}<span data-i18n="expires_at">到期时间：</span>{{end}}{{.ExpiresAt}}</div>{{end}}
+                    {{if .ExpiresAt}}<div class="pack-expires{{if eq .ShareMode "subscription"}} subscription-expires{{end}}">{{if eq .ShareMode "subscription"}}<span data-i18n="subscription_expires">订阅到期：</span>{{else}}<span data-i18n="expires_at">到期时间：</span>{{end}}{{.ExpiresAt}}{{if .Expired}} <span class="tag tag-time-limited" data-i18n="pack_expired">已过期</span>{{end}}</div>{{end}}
                     {{if eq .ShareMode "per_use"}}<div class="pack-usage"><span class="usage-progress{{if eq .UsedCount .TotalPurchased}} usage-exhausted{{end}}"><span data-i18n="used_count">已使用</span> {{.UsedCount}}/{{.TotalPurchased}}</span></div>{{end}}
                 </div>
                 <div class="pack-actions">
@@ -864,6 +940,24 @@ const userDashboardHTML = `<!DOCTYPE html>
                         data-credits-price="{{.CreditsPrice}}"
                         onclick="openRenewModal(this)" data-i18n="renew">续费</button>
                     {{end}}
+                    {{if .TimeLimited}}
+                    <form method="POST" action="/user/pack/renew-access" style="display:inline;" onsubmit="return confirm(window._i18n('renew_access_confirm','确认支付积分续期该分析包吗？'))">
+                        <input type="hidden" name="listing_id" value="{{.ListingID}}">
+                        <button type="submit" class="btn btn-primary btn-sm" data-i18n="renew_access">续期</button>
+                    </form>
+                    {{end}}
+                    {{if .AutoRenew}}
+                    <form method="POST" action="/user/pack/subscribe/cancel" style="display:inline;" onsubmit="return confirm(window._i18n('cancel_auto_renew_confirm','确定取消自动续费吗？已付费的订阅期内仍可使用。'))">
+                        <input type="hidden" name="listing_id" value="{{.ListingID}}">
+                        <span class="tag tag-subscription" data-i18n="auto_renew_on">自动续费中</span>
+                        <button type="submit" class="btn btn-secondary btn-sm" data-i18n="cancel_auto_renew">取消自动续费</button>
+                    </form>
+                    {{else if .BillingCycle}}
+                    <form method="POST" action="/user/pack/subscribe" style="display:inline;">
+                        <input type="hidden" name="listing_id" value="{{.ListingID}}">
+                        <button type="submit" class="btn btn-secondary btn-sm" data-i18n="auto_renew_paypal">自动续费（PayPal）</button>
+                    </form>
+                    {{end}}
                     <button class="btn-danger-sm"
                         data-listing-id="{{.ListingID}}"
                         data-pack-name="{{.PackName}}"
@@ -940,8 +1034,12 @@ const userDashboardHTML = `<!DOCTYPE html>
                             {{else if eq .Status "published"}}<span class="status-badge status-published" data-i18n="published">已发布</span>
                             {{else if eq .Status "rejected"}}<span class="status-badge status-rejected" data-i18n="rejected">已拒绝</span>
                             {{else if eq .Status "delisted"}}<span class="status-badge status-delisted" data-i18n="delisted">已下架</span>
+                            {{else if eq .Status "scheduled"}}<span class="status-badge status-scheduled" data-i18n="scheduled">已定时</span>
+                            {{else if eq .Status "quarantined"}}<span class="status-badge status-quarantined" data-i18n="quarantined">已隔离</span>
                             {{else}}<span class="status-badge">{{.Status}}</span>
                             {{end}}
+                            {{if .PublishAt}}<span class="publish-at">⏰ <span class="js-local-time" data-utc="{{.PublishAt}}">{{.PublishAt}}</span></span>{{end}}
+                            {{if .SalePrice}}<span class="publish-at">⚡ <span data-i18n="flash_sale">限时折扣</span> {{.SalePrice}} Credits · <span class="js-local-time" data-utc="{{.SaleStart}}">{{.SaleStart}}</span> ~ <span class="js-local-time" data-utc="{{.SaleEnd}}">{{.SaleEnd}}</span></span>{{end}}
                         </td>
                         <td>{{.SoldCount}}</td>
                         <td>{{printf "%.0f" .TotalRevenue}} Credits</td>
@@ -958,6 +1056,28 @@ const userDashboardHTML = `<!DOCTYPE html>
                                     data-share-mode="{{.ShareMode}}"
                                     data-credits-price="{{.CreditsPrice}}"
                                     onclick="openEditPackModal(this)" data-i18n="edit">编辑</button>
+                                <button class="btn btn-ghost btn-sm"
+                                    data-listing-id="{{.ListingID}}"
+                                    data-pack-name="{{.PackName}}"
+                                    onclick="openPackImagesModal(this)" data-i18n="screenshots">截图</button>
+                                {{if or (eq .Status "pending") (eq .Status "scheduled")}}
+                                <button class="btn btn-ghost btn-sm"
+                                    data-listing-id="{{.ListingID}}"
+                                    data-pack-name="{{.PackName}}"
+                                    data-status="{{.Status}}"
+                                    data-publish-at="{{.PublishAt}}"
+                                    onclick="openScheduleModal(this)" data-i18n="schedule_publish">定时发布</button>
+                                {{end}}
+                                {{if ne .ShareMode "free"}}
+                                <button class="btn btn-ghost btn-sm"
+                                    data-listing-id="{{.ListingID}}"
+                                    data-pack-name="{{.PackName}}"
+                                    data-credits-price="{{.CreditsPrice}}"
+                                    data-sale-price="{{if .SalePrice}}{{.SalePrice}}{{end}}"
+                                    data-sale-start="{{.SaleStart}}"
+                                    data-sale-end="{{.SaleEnd}}"
+                                    onclick="openSaleModal(this)" data-i18n="flash_sale">限时折扣</button>
+                                {{end}}
                                 {{if eq .Status "published"}}
                                 <button class="btn-danger-sm"
                                     data-listing-id="{{.ListingID}}"
@@ -1314,6 +1434,31 @@ const userDashboardHTML = `<!DOCTYPE html>
   <input type="hidden" name="credits_amount" id="withdrawFormCredits">
 </form>
 
+<div id="giftCreditsModal" class="modal-overlay">
+  <div class="modal-box" style="max-width:400px;padding:24px;">
+    <button onclick="closeGiftCreditsModal()" class="modal-close">&times;</button>
+    <h3 style="font-size:16px;font-weight:600;color:#334155;margin-bottom:14px;" data-i18n="gift_credits">赠送积分</h3>
+    <div style="font-size:12px;color:#718096;margin-bottom:10px;"><span data-i18n="gift_credits_limits">每次至少赠送，每日上限</span>：{{.GiftMinCredits}} / {{.GiftDailyLimit}} Credits</div>
+    <div style="margin-bottom:10px;">
+      <label style="font-size:12px;color:#4a5568;display:block;margin-bottom:4px;font-weight:500;" data-i18n="gift_credits_email">收件人邮箱</label>
+      <input id="giftCreditsEmail" type="email" maxlength="254" style="width:100%;padding:8px 12px;border:1px solid #e2e8f0;border-radius:8px;font-size:13px;">
+    </div>
+    <div style="margin-bottom:10px;">
+      <label style="font-size:12px;color:#4a5568;display:block;margin-bottom:4px;font-weight:500;" data-i18n="gift_credits_amount">赠送数量</label>
+      <input id="giftCreditsAmount" type="number" min="{{.GiftMinCredits}}" step="1" style="width:100%;padding:8px 12px;border:1px solid #e2e8f0;border-radius:8px;font-size:13px;">
+    </div>
+    <div style="margin-bottom:10px;">
+      <label style="font-size:12px;color:#4a5568;display:block;margin-bottom:4px;font-weight:500;" data-i18n="gift_credits_message">附言（可选）</label>
+      <input id="giftCreditsMessage" type="text" maxlength="200" style="width:100%;padding:8px 12px;border:1px solid #e2e8f0;border-radius:8px;font-size:13px;">
+    </div>
+    <div id="giftCreditsError" style="display:none;padding:6px 10px;background:#fff7ed;border:1px solid #fed7aa;border-radius:8px;margin-bottom:8px;font-size:12px;color:#9a3412;"></div>
+    <div style="display:flex;gap:8px;justify-content:flex-end;margin-top:12px;">
+      <button class="btn btn-secondary" onclick="closeGiftCreditsModal()" style="padding:6px 14px;font-size:13px;" data-i18n="cancel">取消</button>
+      <button class="btn btn-warm" id="giftCreditsSubmitBtn" onclick="submitGiftCredits()" style="padding:6px 14px;font-size:13px;" data-i18n="gift_credits_send">确认赠送</button>
+    </div>
+  </div>
+</div>
+
 <!-- Withdrawal Records Modal -->
 <div id="withdrawRecordsModal" class="modal-overlay">
   <div class="modal-box" style="max-width:700px;padding:24px;">
@@ -1369,6 +1514,22 @@ const userDashboardHTML = `<!DOCTYPE html>
   </div>
 </div>
 
+<!-- Pack Screenshots Modal -->
+<div id="packImagesModal" class="modal-overlay">
+  <div class="modal-box" style="max-width:560px;">
+    <button onclick="closePackImagesModal()" class="modal-close">&times;</button>
+    <h3 class="modal-title"><span data-i18n="screenshots">截图</span> · <span id="packImagesName"></span></h3>
+    <input type="hidden" id="packImagesListingId">
+    <div class="field-hint" style="margin-bottom:12px;" data-i18n="screenshots_hint">最多 6 张，支持 PNG、JPEG、WebP，单张不超过 2MB。第一张将优先展示。</div>
+    <div id="packImagesList" style="display:flex;flex-wrap:wrap;gap:10px;margin-bottom:14px;"></div>
+    <input type="file" id="packImageFile" accept="image/png,image/jpeg,image/webp" style="display:none;" onchange="uploadPackImage(this)">
+    <div class="modal-actions">
+      <button type="button" class="btn btn-secondary" onclick="closePackImagesModal()" data-i18n="close">关闭</button>
+      <button type="button" class="btn btn-primary" id="packImageUploadBtn" onclick="document.getElementById('packImageFile').click()" data-i18n="upload_screenshot">上传截图</button>
+    </div>
+  </div>
+</div>
+
 <!-- Renew Modal -->
 <div id="renewModal" class="modal-overlay">
   <div class="modal-box" style="max-width:420px;">
@@ -1489,6 +1650,43 @@ const userDashboardHTML = `<!DOCTYPE html>
   <input type="hidden" name="listing_id" id="delistListingId">
 </form>
 
+<!-- Scheduled Publishing Modal -->
+<div id="scheduleModal" class="modal-overlay">
+  <div class="modal-box" style="max-width:420px;">
+    <button onclick="closeScheduleModal()" class="modal-close">&times;</button>
+    <h3 class="modal-title" data-i18n="schedule_publish">定时发布</h3>
+    <div style="font-size:14px;color:#4a5568;margin-bottom:8px;"><span data-i18n="pack_label">分析包</span>：<span id="schedulePackName" style="font-weight:600;"></span></div>
+    <div style="font-size:13px;color:#64748b;margin-bottom:12px;" data-i18n="schedule_publish_hint">审核通过后，分析包将在设定的时间自动上架，在此之前不会公开显示。</div>
+    <input type="datetime-local" id="schedulePublishAt" style="width:100%;padding:8px 10px;border:1px solid #cbd5e1;border-radius:8px;margin-bottom:20px;">
+    <input type="hidden" id="scheduleListingId">
+    <div class="modal-actions">
+      <button class="btn btn-secondary" id="unscheduleBtn" onclick="submitUnschedule()" data-i18n="cancel_schedule">取消定时</button>
+      <button class="btn btn-primary" onclick="submitSchedule()" data-i18n="save">保存</button>
+    </div>
+  </div>
+</div>
+
+<!-- Flash Sale Modal -->
+<div id="saleModal" class="modal-overlay">
+  <div class="modal-box" style="max-width:420px;">
+    <button onclick="closeSaleModal()" class="modal-close">&times;</button>
+    <h3 class="modal-title" data-i18n="set_flash_sale">设置限时折扣</h3>
+    <div style="font-size:14px;color:#4a5568;margin-bottom:8px;"><span data-i18n="pack_label">分析包</span>：<span id="salePackName" style="font-weight:600;"></span> (<span id="saleBasePrice"></span> Credits)</div>
+    <div style="font-size:13px;color:#64748b;margin-bottom:12px;" data-i18n="flash_sale_hint">折扣价须低于原价，最长 30 天，仅在时间段内生效</div>
+    <label style="font-size:13px;color:#475569;" data-i18n="sale_price">折扣价</label>
+    <input type="number" id="salePrice" min="1" step="1" style="width:100%;padding:8px 10px;border:1px solid #cbd5e1;border-radius:8px;margin-bottom:10px;">
+    <label style="font-size:13px;color:#475569;" data-i18n="sale_start">开始时间</label>
+    <input type="datetime-local" id="saleStart" style="width:100%;padding:8px 10px;border:1px solid #cbd5e1;border-radius:8px;margin-bottom:10px;">
+    <label style="font-size:13px;color:#475569;" data-i18n="sale_end">结束时间</label>
+    <input type="datetime-local" id="saleEnd" style="width:100%;padding:8px 10px;border:1px solid #cbd5e1;border-radius:8px;margin-bottom:20px;">
+    <input type="hidden" id="saleListingId">
+    <div class="modal-actions">
+      <button class="btn btn-secondary" id="clearSaleBtn" onclick="submitClearSale()" data-i18n="clear_flash_sale">取消折扣</button>
+      <button class="btn btn-primary" onclick="submitSale()" data-i18n="save">保存</button>
+    </div>
+  </div>
+</div>
+
 <script>
 /* Tab switching */
 function switchTab(tab) {
@@ -1552,6 +1750,8 @@ function escapeHtml(str) {
 var _renewState = {listingId:"", shareMode:"", creditsPrice:0};
 var _withdrawPaymentInfo = null;
 var _withdrawFeeRate = 0;
+var _withdrawFeeCap = 0;
+var _withdrawMinNet = 100;
 var _paymentTypeLabels = {"paypal":"PayPal","wechat":window._i18n("wechat","微信"),"alipay":"AliPay","check":window._i18n("check","支票"),"wire_transfer":window._i18n("wire_transfer","国际电汇"),"bank_card_us":window._i18n("bank_card_us","美国银行卡"),"bank_card_eu":window._i18n("bank_card_eu","欧洲银行卡"),"bank_card_cn":window._i18n("bank_card_cn","中国银行卡")};
 var _savedPaymentType = "";
 var _savedPaymentDetails = {};
@@ -1726,7 +1926,7 @@ function openWithdrawModal() {
     document.getElementById("withdrawNoPaymentWarning").style.display="none";
     document.getElementById("withdrawFormContent").style.display="block";
     document.getElementById("withdrawPaymentInfo").style.display="none";
-    _withdrawPaymentInfo=null; _withdrawFeeRate=0;
+    _withdrawPaymentInfo=null; _withdrawFeeRate=0; _withdrawFeeCap=0; _withdrawMinNet=100;
     document.getElementById("withdrawModal").style.display="flex";
     fetch("/user/payment-info",{credentials:"same-origin"})
         .then(function(r){return r.json();})
@@ -1741,6 +1941,8 @@ function openWithdrawModal() {
                     .then(function(r){return r.json();})
                     .then(function(feeData){
                         _withdrawFeeRate=feeData.fee_rate||0;
+                        _withdrawFeeCap=feeData.fee_cap||0;
+                        if(typeof feeData.min_withdrawal==="number")_withdrawMinNet=feeData.min_withdrawal;
                         document.getElementById("withdrawFeeRateLabel").innerText=_withdrawFeeRate.toFixed(1)+"%";
                         document.getElementById("withdrawPaymentInfo").style.display="flex";
                         calcWithdrawCash();
@@ -1749,6 +1951,38 @@ function openWithdrawModal() {
         }).catch(function(){});
 }
 function closeWithdrawModal(){document.getElementById("withdrawModal").style.display="none";}
+function openGiftCreditsModal(){
+    ["giftCreditsEmail","giftCreditsAmount","giftCreditsMessage"].forEach(function(id){document.getElementById(id).value="";});
+    document.getElementById("giftCreditsError").style.display="none";
+    document.getElementById("giftCreditsModal").style.display="flex";
+}
+function markAllNotificationsRead(){
+    fetch("/user/notifications/read",{method:"POST",credentials:"same-origin",headers:{"Content-Type":"application/json"},body:JSON.stringify({all:true})})
+    .then(function(r){ if(!r.ok) return;
+        document.querySelectorAll(".notification-card").forEach(function(el){el.classList.add("read");});
+        ["notifUnreadBadge","notifMarkAllRead"].forEach(function(id){var el=document.getElementById(id); if(el) el.remove();});
+    });
+}
+function closeGiftCreditsModal(){document.getElementById("giftCreditsModal").style.display="none";}
+function submitGiftCredits(){
+    var btn=document.getElementById("giftCreditsSubmitBtn");
+    var errBox=document.getElementById("giftCreditsError");
+    var email=document.getElementById("giftCreditsEmail").value.trim();
+    var amount=parseInt(document.getElementById("giftCreditsAmount").value,10)||0;
+    if(!confirm(window._i18n('gift_credits_confirm','确定赠送？')+' '+amount+' Credits → '+email)) return;
+    btn.disabled=true;
+    errBox.style.display="none";
+    fetch("/user/credits/gift",{method:"POST",credentials:"same-origin",headers:{"Content-Type":"application/json"},
+        body:JSON.stringify({email:email,amount:amount,message:document.getElementById("giftCreditsMessage").value})})
+    .then(function(r){return r.json();})
+    .then(function(data){
+        btn.disabled=false;
+        if(data.success){ closeGiftCreditsModal(); location.reload(); return; }
+        errBox.textContent=window._i18n(data.error,data.error);
+        errBox.style.display="block";
+    })
+    .catch(function(){ btn.disabled=false; errBox.textContent=window._i18n('network_error','网络错误'); errBox.style.display="block"; });
+}
 function openWithdrawRecordsModal(){
     document.getElementById("withdrawRecordsModal").style.display="flex";
     document.getElementById("withdrawRecordsContent").innerHTML='<div style="text-align:center;padding:30px;color:#94a3b8;">'+window._i18n('loading','加载中...')+'</div>';
@@ -1794,12 +2028,19 @@ function openWithdrawRecordsModal(){
     });
 }
 function closeWithdrawRecordsModal(){document.getElementById("withdrawRecordsModal").style.display="none";}
+// calcWithdrawFee mirrors calculateWithdrawalFee on the server: the fee is
+// rounded to cents and capped at _withdrawFeeCap when one is configured.
+function calcWithdrawFee(cash) {
+    var fee=Math.round(cash*_withdrawFeeRate)/100;
+    if(_withdrawFeeCap>0&&fee>_withdrawFeeCap)fee=_withdrawFeeCap;
+    return fee;
+}
 function calcWithdrawCash() {
     var credits=parseFloat(document.getElementById("withdrawCreditsInput").value)||0;
     var rate=parseFloat(document.getElementById("withdrawCashRate").innerText)||0;
     var maxCredits=parseFloat(document.getElementById("withdrawCreditsInput").max)||0;
     var splitPct=parseFloat(document.getElementById("withdrawSplitPctLabel").innerText)||0;
-    var cash=credits*rate;
+    var cash=Math.round(credits*rate*100)/100;
     var warning=document.getElementById("withdrawWarning");
     var submitBtn=document.getElementById("withdrawSubmitBtn");
     var formulaBox=document.getElementById("withdrawFormulaBox");
@@ -1808,14 +2049,15 @@ function calcWithdrawCash() {
     submitBtn.disabled=false;
     submitBtn.style.opacity="1";
     if(credits<=0){formulaBox.style.display="none";netEl.style.display="none";return;}
-    var fee=cash*_withdrawFeeRate/100;
-    var net=cash-fee;
+    var fee=calcWithdrawFee(cash);
+    var net=Math.round((cash-fee)*100)/100;
     var _yuan=window._i18n('yuan','元');
     var lines=[];
     lines.push('<span style="color:#94a3b8;">① '+window._i18n('formula_step1','分成后可提现余额已含分成比例')+' '+splitPct+'%</span>');
     lines.push('<span style="color:#334155;">② '+window._i18n('formula_step2','提现金额')+' = '+credits+' × '+rate.toFixed(2)+' = <b>'+cash.toFixed(2)+'</b> '+_yuan+'</span>');
     if(_withdrawFeeRate>0){
-        lines.push('<span style="color:#334155;">③ '+window._i18n('formula_step3','手续费')+' = '+cash.toFixed(2)+' × '+_withdrawFeeRate.toFixed(1)+'% = <b>'+fee.toFixed(2)+'</b> '+_yuan+'</span>');
+        var capNote=(_withdrawFeeCap>0&&fee>=_withdrawFeeCap)?'（'+window._i18n('fee_capped','已达手续费上限')+'）':'';
+        lines.push('<span style="color:#334155;">③ '+window._i18n('formula_step3','手续费')+' = '+cash.toFixed(2)+' × '+_withdrawFeeRate.toFixed(1)+'% = <b>'+fee.toFixed(2)+'</b> '+_yuan+capNote+'</span>');
         lines.push('<span style="color:#10b981;font-weight:600;">④ '+window._i18n('formula_step4','实付')+' = '+cash.toFixed(2)+' − '+fee.toFixed(2)+' = <b>'+net.toFixed(2)+'</b> '+_yuan+'</span>');
     } else {
         lines.push('<span style="color:#10b981;font-weight:600;">③ '+window._i18n('formula_step4','实付')+' = <b>'+cash.toFixed(2)+'</b> '+_yuan+'（'+window._i18n('no_fee','无手续费')+'）</span>');
@@ -1828,8 +2070,8 @@ function calcWithdrawCash() {
         warning.innerHTML='⚠️ '+window._i18n('exceeds_balance','提现 Credits 数量不能超过可提现余额')+'（'+maxCredits+' Credits）';
         warning.style.display="block";
         submitBtn.disabled=true;submitBtn.style.opacity="0.5";
-    } else if(net<100){
-        warning.innerHTML='⚠️ '+window._i18n('formula_step4','实付')+' '+net.toFixed(2)+' '+_yuan+'，'+window._i18n('below_minimum','扣除手续费后实付金额低于最低提现金额 100 元');
+    } else if(net<_withdrawMinNet){
+        warning.innerHTML='⚠️ '+window._i18n('formula_step4','实付')+' '+net.toFixed(2)+' '+_yuan+'，'+window._i18n('below_minimum','扣除手续费后实付金额低于最低提现金额 {min} 元').replace('{min}',_withdrawMinNet);
         warning.style.display="block";
         submitBtn.disabled=true;submitBtn.style.opacity="0.5";
     }
@@ -1840,11 +2082,11 @@ function submitWithdraw() {
     var maxCredits=parseFloat(document.getElementById("withdrawCreditsInput").max)||0;
     if(credits>maxCredits){alert(window._i18n("exceeds_balance","提现 Credits 数量不能超过可提现余额")+"（"+maxCredits+" Credits）");return;}
     var rate=parseFloat(document.getElementById("withdrawCashRate").innerText)||0;
-    var cash=credits*rate;
-    var fee=cash*_withdrawFeeRate/100;
-    var net=cash-fee;
+    var cash=Math.round(credits*rate*100)/100;
+    var fee=calcWithdrawFee(cash);
+    var net=Math.round((cash-fee)*100)/100;
     var _yuan=window._i18n('yuan','元');
-    if(net<100){alert(window._i18n("below_minimum","扣除手续费后实付金额低于最低提现金额 100 元"));return;}
+    if(net<_withdrawMinNet){alert(window._i18n("below_minimum","扣除手续费后实付金额低于最低提现金额 {min} 元").replace("{min}",_withdrawMinNet));return;}
     if(!confirm(window._i18n("confirm_withdraw","确认提现")+" "+credits+" Credits？\n\n"+window._i18n("withdraw_amount_label","提现金额")+"："+cash.toFixed(2)+" "+_yuan+"\n"+window._i18n("fee","手续费")+"："+fee.toFixed(2)+" "+_yuan+"\n"+window._i18n("net_amount","实付金额")+"："+net.toFixed(2)+" "+_yuan)){return;}
     var btn=document.getElementById("withdrawSubmitBtn");
     btn.disabled=true;btn.innerText=window._i18n("submitting","提交中...");
@@ -1935,6 +2177,79 @@ function onEditShareModeChange() {
     else if(mode==="subscription"){ps.style.display="block";pi.min=100;pi.max=1000;hint.innerText=window._i18n("subscription_price_hint","订阅：100-1000 Credits");}
 }
 
+/* Pack Screenshots Modal */
+var packImages=[];
+function openPackImagesModal(btn) {
+    var lid=btn.getAttribute("data-listing-id");
+    document.getElementById("packImagesListingId").value=lid;
+    document.getElementById("packImagesName").textContent=btn.getAttribute("data-pack-name");
+    packImages=[];renderPackImages(6);
+    document.getElementById("packImagesModal").style.display="flex";
+    fetch("/user/author/pack-images?listing_id="+encodeURIComponent(lid),{credentials:"same-origin"})
+    .then(function(r){return r.json();})
+    .then(function(data){
+        if(data.success){packImages=data.images||[];renderPackImages(data.max_images);}
+        else{alert(data.error||window._i18n("load_failed","加载失败"));}
+    }).catch(function(){alert(window._i18n("network_error","网络错误，请重试"));});
+}
+function closePackImagesModal(){document.getElementById("packImagesModal").style.display="none";}
+function renderPackImages(max){
+    var list=document.getElementById("packImagesList");
+    list.innerHTML="";
+    packImages.forEach(function(img,i){
+        var cell=document.createElement("div");
+        cell.style.cssText="width:120px;border:1px solid #e2e8f0;border-radius:8px;overflow:hidden;background:#f8fafc;";
+        var pic=document.createElement("img");
+        pic.src=img.url;pic.style.cssText="width:120px;height:76px;object-fit:cover;display:block;";
+        cell.appendChild(pic);
+        var bar=document.createElement("div");
+        bar.style.cssText="display:flex;justify-content:space-between;padding:4px;";
+        [["←",function(){movePackImage(i,-1);},i===0],["→",function(){movePackImage(i,1);},i===packImages.length-1],["✕",function(){deletePackImage(img.id);},false]].forEach(function(b){
+            var el=document.createElement("button");
+            el.type="button";el.className="btn btn-ghost btn-sm";el.textContent=b[0];el.disabled=b[2];el.onclick=b[1];
+            bar.appendChild(el);
+        });
+        cell.appendChild(bar);
+        list.appendChild(cell);
+    });
+    if(max)document.getElementById("packImageUploadBtn").disabled=packImages.length>=max;
+}
+function uploadPackImage(input){
+    if(!input.files||!input.files[0])return;
+    var fd=new FormData();
+    fd.append("listing_id",document.getElementById("packImagesListingId").value);
+    fd.append("image",input.files[0]);
+    input.value="";
+    fetch("/user/author/pack-images",{method:"POST",credentials:"same-origin",body:fd})
+    .then(function(r){return r.json();})
+    .then(function(data){
+        if(data.success){packImages.push(data.image);renderPackImages(6);}
+        else{alert(data.error||window._i18n("upload_failed","上传失败"));}
+    }).catch(function(){alert(window._i18n("network_error","网络错误，请重试"));});
+}
+function movePackImage(i,delta){
+    var j=i+delta;
+    if(j<0||j>=packImages.length)return;
+    var tmp=packImages[i];packImages[i]=packImages[j];packImages[j]=tmp;
+    renderPackImages(6);
+    fetch("/user/author/pack-images/reorder",{method:"POST",credentials:"same-origin",headers:{"Content-Type":"application/json"},
+        body:JSON.stringify({listing_id:parseInt(document.getElementById("packImagesListingId").value,10),ids:packImages.map(function(img){return img.id;})})})
+    .then(function(r){return r.json();})
+    .then(function(data){if(!data.success)alert(data.error||window._i18n("save_failed","保存失败，请重试"));})
+    .catch(function(){alert(window._i18n("network_error","网络错误，请重试"));});
+}
+function deletePackImage(id){
+    if(!confirm(window._i18n("confirm_delete_screenshot","确定要删除这张截图吗？")))return;
+    var fd=new FormData();
+    fd.append("image_id",id);
+    fetch("/user/author/pack-images/delete",{method:"POST",credentials:"same-origin",body:fd})
+    .then(function(r){return r.json();})
+    .then(function(data){
+        if(data.success){packImages=packImages.filter(function(img){return img.id!==id;});renderPackImages(6);}
+        else{alert(data.error||window._i18n("delete_failed","删除失败"));}
+    }).catch(function(){alert(window._i18n("network_error","网络错误，请重试"));});
+}
+
 /* Renew Modal */
 function openRenewModal(btn) {
     var listingId=btn.getAttribute("data-listing-id");
@@ -2037,6 +2352,75 @@ function showShareToast(btn){
     if(t){t.classList.add("show");setTimeout(function(){t.classList.remove("show")},2000)}
 }
 
+/* Scheduled Publishing */
+function pad2(n){return n<10?"0"+n:""+n;}
+function openScheduleModal(btn){
+    document.getElementById("scheduleListingId").value=btn.getAttribute("data-listing-id");
+    document.getElementById("schedulePackName").textContent=btn.getAttribute("data-pack-name");
+    var at=btn.getAttribute("data-publish-at"), input=document.getElementById("schedulePublishAt");
+    input.value="";
+    if(at){var d=new Date(at);input.value=d.getFullYear()+"-"+pad2(d.getMonth()+1)+"-"+pad2(d.getDate())+"T"+pad2(d.getHours())+":"+pad2(d.getMinutes());}
+    var unBtn=document.getElementById("unscheduleBtn");
+    unBtn.style.display=at?"":"none";
+    unBtn.textContent=btn.getAttribute("data-status")==="scheduled"?window._i18n("publish_now","立即发布"):window._i18n("cancel_schedule","取消定时");
+    document.getElementById("scheduleModal").style.display="flex";
+}
+function closeScheduleModal(){document.getElementById("scheduleModal").style.display="none";}
+function postSchedule(url,fd){
+    fd.append("listing_id",document.getElementById("scheduleListingId").value);
+    fetch(url,{method:"POST",credentials:"same-origin",body:fd})
+    .then(function(r){return r.json();})
+    .then(function(data){
+        if(data.ok){location.reload();}
+        else{alert(data.error||window._i18n("save_failed","保存失败"));}
+    }).catch(function(){alert(window._i18n("network_error","网络错误，请重试"));});
+}
+function submitSchedule(){
+    var v=document.getElementById("schedulePublishAt").value;
+    if(!v){return;}
+    var fd=new FormData();
+    fd.append("publish_at",new Date(v).toISOString());
+    postSchedule("/user/author/schedule-pack",fd);
+}
+function submitUnschedule(){postSchedule("/user/author/unschedule-pack",new FormData());}
+
+/* Flash Sale */
+function toLocalInput(s){if(!s)return "";var d=new Date(s);return d.getFullYear()+"-"+pad2(d.getMonth()+1)+"-"+pad2(d.getDate())+"T"+pad2(d.getHours())+":"+pad2(d.getMinutes());}
+function openSaleModal(btn){
+    document.getElementById("saleListingId").value=btn.getAttribute("data-listing-id");
+    document.getElementById("salePackName").textContent=btn.getAttribute("data-pack-name");
+    document.getElementById("saleBasePrice").textContent=btn.getAttribute("data-credits-price");
+    document.getElementById("salePrice").value=btn.getAttribute("data-sale-price");
+    document.getElementById("saleStart").value=toLocalInput(btn.getAttribute("data-sale-start"));
+    document.getElementById("saleEnd").value=toLocalInput(btn.getAttribute("data-sale-end"));
+    document.getElementById("clearSaleBtn").style.display=btn.getAttribute("data-sale-price")?"":"none";
+    document.getElementById("saleModal").style.display="flex";
+}
+function closeSaleModal(){document.getElementById("saleModal").style.display="none";}
+function postSale(fd){
+    fd.append("listing_id",document.getElementById("saleListingId").value);
+    fetch("/user/author/pack-sale",{method:"POST",credentials:"same-origin",body:fd})
+    .then(function(r){return r.json();})
+    .then(function(data){
+        if(data.ok){location.reload();}
+        else{alert(data.error||window._i18n("save_failed","保存失败"));}
+    }).catch(function(){alert(window._i18n("network_error","网络错误，请重试"));});
+}
+function submitSale(){
+    var s=document.getElementById("saleStart").value,e=document.getElementById("saleEnd").value;
+    if(!s||!e){return;}
+    var fd=new FormData();
+    fd.append("sale_price",document.getElementById("salePrice").value);
+    fd.append("sale_start",new Date(s).toISOString());
+    fd.append("sale_end",new Date(e).toISOString());
+    postSale(fd);
+}
+function submitClearSale(){var fd=new FormData();fd.append("clear","1");postSale(fd);}
+document.querySelectorAll(".js-local-time").forEach(function(el){
+    var d=new Date(el.getAttribute("data-utc"));
+    if(!isNaN(d)){el.textContent=d.toLocaleString();}
+});
+
 /* Author Delist Published Pack Modal */
 function openAuthorDelistModal(btn){
     document.getElementById("delistListingId").value=btn.getAttribute("data-listing-id");
@@ -2156,6 +2540,7 @@ const userCustomProductOrdersHTML = `<!DOCTYPE html>
         .status-fulfilled { background: #dcfce7; color: #16a34a; border: 1px solid #bbf7d0; }
         .status-failed { background: #fef2f2; color: #dc2626; border: 1px solid #fecaca; }
         .sn-info { font-size: 12px; color: #6366f1; margin-top: 4px; word-break: break-all; }
+        .receipt-link { font-size: 12px; color: #6366f1; text-decoration: none; }
         .credits-info { font-size: 12px; color: #059669; margin-top: 4px; font-weight: 600; }
         .type-tag {
             display: inline-block; padding: 2px 8px; border-radius: 4px;
@@ -2208,7 +2593,7 @@ const userCustomProductOrdersHTML = `<!DOCTYPE html>
                             {{else}}{{.ProductType}}{{end}}
                         </td>
                         <td>{{.CreatedAt}}</td>
-                        <td>$ {{printf "%.2f" .AmountUSD}}</td>
+                        <td>{{formatMoney .ChargedAmount .ChargedCurrency ""}}</td>
                         <td>
                             <span class="status-badge status-{{.Status}}">
                                 {{if eq .Status "pending"}}<span data-i18n="cp_status_pending">待支付</span>{{end}}
@@ -2231,6 +2616,9 @@ const userCustomProductOrdersHTML = `<!DOCTYPE html>
                             {{if eq .Status "failed"}}
                             <span style="font-size:12px;color:#94a3b8;">—</span>
                             {{end}}
+                            {{if eq .Status "fulfilled"}}
+                            <div><a class="receipt-link" href="/user/receipts/order?id={{.ID}}" data-i18n="download_receipt">🧾 收据</a></div>
+                            {{end}}
                         </td>
                     </tr>
                     {{end}}
@@ -0,0 +1,176 @@
+package templates
+
+import "html/template"
+
+// UserDeleteAccountTmpl is the parsed account deletion page template.
+var UserDeleteAccountTmpl = template.Must(template.New("user_delete_account").Funcs(BaseFuncMap).Parse(userDeleteAccountHTML))
+
+const userDeleteAccountHTML = `<!DOCTYPE html>
+<html lang="{{.HtmlLang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{index .T "acct_delete_title"}} - {{index .T "site_name"}}</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: linear-gradient(135deg, #f0f4ff 0%, #e8f5e9 50%, #f3e8ff 100%);
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+        .auth-card {
+            background: #fff;
+            border-radius: 16px;
+            padding: 40px;
+            width: 420px;
+            max-width: 90%;
+            box-shadow: 0 4px 24px rgba(0,0,0,0.08);
+            border: 1px solid #e2e8f0;
+        }
+        .logo { text-align: center; margin-bottom: 20px; font-size: 36px; }
+        .auth-card h1 {
+            font-size: 22px;
+            color: #1e293b;
+            margin-bottom: 8px;
+            text-align: center;
+            font-weight: 700;
+        }
+        .auth-card .subtitle {
+            font-size: 14px;
+            color: #64748b;
+            text-align: center;
+            margin-bottom: 28px;
+        }
+        .form-group { margin-bottom: 18px; }
+        .form-group label {
+            display: block;
+            font-size: 13px;
+            color: #475569;
+            margin-bottom: 6px;
+            font-weight: 500;
+        }
+        .form-group input {
+            width: 100%;
+            padding: 10px 12px;
+            border: 1px solid #cbd5e1;
+            border-radius: 8px;
+            font-size: 14px;
+            color: #1e293b;
+            background: #f8fafc;
+            transition: border-color 0.2s, box-shadow 0.2s;
+        }
+        .form-group input:focus {
+            outline: none;
+            border-color: #6366f1;
+            box-shadow: 0 0 0 3px rgba(99,102,241,0.1);
+            background: #fff;
+        }
+        .form-group input::placeholder { color: #94a3b8; }
+        .btn-submit {
+            width: 100%;
+            padding: 11px;
+            background: linear-gradient(135deg, #dc2626, #ef4444);
+            color: #fff;
+            border: none;
+            border-radius: 8px;
+            font-size: 15px;
+            font-weight: 500;
+            cursor: pointer;
+            margin-top: 8px;
+            transition: opacity 0.2s;
+        }
+        .btn-submit:hover { opacity: 0.9; }
+        .error-msg {
+            background: #fef2f2;
+            color: #dc2626;
+            padding: 10px 14px;
+            border-radius: 8px;
+            font-size: 13px;
+            margin-bottom: 16px;
+            border: 1px solid #fecaca;
+        }
+        .success-msg {
+            background: #f0fdf4;
+            color: #16a34a;
+            padding: 10px 14px;
+            border-radius: 8px;
+            font-size: 13px;
+            margin-bottom: 16px;
+            border: 1px solid #bbf7d0;
+        }
+        .client-error {
+            color: #dc2626;
+            font-size: 12px;
+            margin-top: 4px;
+            display: none;
+        }
+        .back-link {
+            display: block;
+            text-align: center;
+            margin-top: 16px;
+            font-size: 13px;
+            color: #6366f1;
+            text-decoration: none;
+        }
+        .back-link:hover { text-decoration: underline; }
+            .warning-box {
+            background: #fff7ed;
+            color: #9a3412;
+            padding: 12px 14px;
+            border-radius: 8px;
+            font-size: 13px;
+            line-height: 1.6;
+            margin-bottom: 18px;
+            border: 1px solid #fed7aa;
+        }
+        .checkbox-group {
+            display: flex;
+            align-items: flex-start;
+            gap: 8px;
+            font-size: 13px;
+            color: #475569;
+            margin-bottom: 18px;
+        }
+        .checkbox-group input { margin-top: 3px; }
+    </style>
+</head>
+<body>
+<div class="auth-card">
+    <div class="logo"><img src="{{logoURL}}" alt="" style="width:48px;height:48px;border-radius:12px;"></div>
+    <h1>{{index .T "acct_delete_title"}}</h1>
+    {{if .Deleted}}
+    <div class="success-msg">{{index .T "acct_delete_done"}}</div>
+    <a href="/" class="back-link">← {{index .T "back_to_home"}}</a>
+    {{else}}
+    <p class="subtitle">{{index .T "acct_delete_intro"}}</p>
+    {{if .Error}}<div class="error-msg">{{.Error}}</div>{{end}}
+    <div class="warning-box">
+        {{index .T "acct_delete_warn"}}
+        {{if .WalletShared}}<br>{{index .T "acct_delete_shared"}}{{else if gt .Balance 0.0}}<br>{{printf (index .T "acct_delete_balance") .Balance}}{{end}}
+    </div>
+    <form method="POST" action="/user/delete-account" onsubmit="return confirm(i18nConfirmDelete)">
+        <div class="form-group">
+            <label for="password">{{index .T "current_password"}}</label>
+            <input type="password" id="password" name="password" required autocomplete="current-password" placeholder="{{index .T "enter_current_password"}}" />
+        </div>
+        <div class="form-group">
+            <label for="confirm">{{printf (index .T "acct_delete_phrase") .ConfirmPhrase}}</label>
+            <input type="text" id="confirm" name="confirm" required autocomplete="off" placeholder="{{.ConfirmPhrase}}" />
+        </div>
+        {{if and (not .WalletShared) (gt .Balance 0.0)}}
+        <label class="checkbox-group"><input type="checkbox" name="forfeit_balance" value="1" required /> <span>{{index .T "acct_delete_agree"}}</span></label>
+        {{end}}
+        <button type="submit" class="btn-submit">{{index .T "acct_delete_button"}}</button>
+    </form>
+    <a href="/user/dashboard" class="back-link">← {{index .T "back_to_center"}}</a>
+    {{end}}
+</div>
+<script>
+var i18nConfirmDelete = "{{index .T "acct_delete_confirm"}}";
+</script>
+` + I18nJS + `
+</body>
+</html>`
@@ -0,0 +1,185 @@
+package templates
+
+import "html/template"
+
+// UserEmailsTmpl is the parsed linked emails page template.
+var UserEmailsTmpl = template.Must(template.New("user_emails").Funcs(BaseFuncMap).Parse(userEmailsHTML))
+
+const userEmailsHTML = `<!DOCTYPE html>
+<html lang="{{.HtmlLang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{index .T "linked_emails_title"}} - {{index .T "site_name"}}</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: linear-gradient(135deg, #f0f4ff 0%, #e8f5e9 50%, #f3e8ff 100%);
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+        .auth-card {
+            background: #fff;
+            border-radius: 16px;
+            padding: 40px;
+            width: 560px;
+            max-width: 90%;
+            box-shadow: 0 4px 24px rgba(0,0,0,0.08);
+            border: 1px solid #e2e8f0;
+        }
+        .logo { text-align: center; margin-bottom: 20px; font-size: 36px; }
+        .auth-card h1 {
+            font-size: 22px;
+            color: #1e293b;
+            margin-bottom: 8px;
+            text-align: center;
+            font-weight: 700;
+        }
+        .auth-card .subtitle {
+            font-size: 14px;
+            color: #64748b;
+            text-align: center;
+            margin-bottom: 28px;
+        }
+        .form-group { margin-bottom: 18px; }
+        .form-group label {
+            display: block;
+            font-size: 13px;
+            color: #475569;
+            margin-bottom: 6px;
+            font-weight: 500;
+        }
+        .form-group input {
+            width: 100%;
+            padding: 10px 12px;
+            border: 1px solid #cbd5e1;
+            border-radius: 8px;
+            font-size: 14px;
+            color: #1e293b;
+            background: #f8fafc;
+            transition: border-color 0.2s, box-shadow 0.2s;
+        }
+        .form-group input:focus {
+            outline: none;
+            border-color: #6366f1;
+            box-shadow: 0 0 0 3px rgba(99,102,241,0.1);
+            background: #fff;
+        }
+        .form-group input::placeholder { color: #94a3b8; }
+        .btn-submit {
+            width: 100%;
+            padding: 11px;
+            background: linear-gradient(135deg, #6366f1, #8b5cf6);
+            color: #fff;
+            border: none;
+            border-radius: 8px;
+            font-size: 15px;
+            font-weight: 500;
+            cursor: pointer;
+            margin-top: 8px;
+            transition: opacity 0.2s;
+        }
+        .btn-submit:hover { opacity: 0.9; }
+        .error-msg {
+            background: #fef2f2;
+            color: #dc2626;
+            padding: 10px 14px;
+            border-radius: 8px;
+            font-size: 13px;
+            margin-bottom: 16px;
+            border: 1px solid #fecaca;
+        }
+        .success-msg {
+            background: #f0fdf4;
+            color: #16a34a;
+            padding: 10px 14px;
+            border-radius: 8px;
+            font-size: 13px;
+            margin-bottom: 16px;
+            border: 1px solid #bbf7d0;
+        }
+        .email-table { width: 100%; border-collapse: collapse; font-size: 13px; margin-bottom: 18px; }
+        .email-table th, .email-table td { padding: 8px 6px; border-bottom: 1px solid #e2e8f0; text-align: left; }
+        .email-table th { color: #64748b; font-weight: 500; }
+        .tag { display: inline-block; padding: 1px 8px; border-radius: 10px; font-size: 11px; margin-left: 4px; }
+        .tag-primary { background: #eef2ff; color: #4f46e5; }
+        .tag-pending { background: #fff7ed; color: #c2410c; }
+        .link-btn { background: none; border: none; color: #6366f1; cursor: pointer; font-size: 12px; padding: 0 4px; }
+        .link-btn.danger { color: #dc2626; }
+        .total { font-size: 14px; color: #1e293b; margin-bottom: 18px; text-align: center; }
+        .back-link {
+            display: block;
+            text-align: center;
+            margin-top: 16px;
+            font-size: 13px;
+            color: #6366f1;
+            text-decoration: none;
+        }
+        .back-link:hover { text-decoration: underline; }
+    </style>
+</head>
+<body>
+<div class="auth-card">
+    <div class="logo"><img src="{{logoURL}}" alt="" style="width:48px;height:48px;border-radius:12px;"></div>
+    <h1>{{index .T "linked_emails_title"}}</h1>
+    <p class="subtitle">{{index .T "linked_emails_intro"}}</p>
+    {{if eq .Success "linked"}}<div class="success-msg">{{index .T "email_link_done"}}</div>{{end}}
+    {{if eq .Error "invalid"}}<div class="error-msg">{{index .T "email_verify_invalid"}}</div>{{end}}
+    {{if eq .Error "taken"}}<div class="error-msg">{{index .T "email_link_taken"}}</div>{{end}}
+    <div class="total">{{index .T "combined_balance"}}: <strong>{{printf "%.2f" .TotalBalance}}</strong></div>
+    <table class="email-table">
+        <tr><th>{{index .T "email"}}</th><th>{{index .T "credits_balance"}}</th><th></th></tr>
+        {{range .Emails}}
+        <tr>
+            <td>{{.Email}}{{if .Primary}}<span class="tag tag-primary">{{index $.T "primary_email"}}</span>{{end}}{{if not .Verified}}<span class="tag tag-pending">{{index $.T "pending_verify"}}</span>{{end}}</td>
+            <td>{{if .Verified}}{{printf "%.2f" .Balance}}{{else}}-{{end}}</td>
+            <td>
+                {{if and .Verified (not .Primary)}}<button class="link-btn" onclick="emailAction('primary', '{{.Email}}')">{{index $.T "set_primary_email"}}</button>{{end}}
+                {{if and (not .SignIn) (not .Primary)}}<button class="link-btn danger" onclick="emailAction('unlink', '{{.Email}}')">{{index $.T "unlink_email"}}</button>{{end}}
+            </td>
+        </tr>
+        {{end}}
+    </table>
+    <form onsubmit="return linkEmail()">
+        <div class="form-group">
+            <label for="email">{{index .T "link_email_label"}}</label>
+            <input type="email" id="email" name="email" required placeholder="name@example.com" />
+        </div>
+        <button type="submit" class="btn-submit" id="link-btn">{{index .T "send_link_email"}}</button>
+    </form>
+    <a href="/user/dashboard" class="back-link">← {{index .T "back_to_center"}}</a>
+</div>
+<script>
+var i18nLinkSent = "{{index .T "email_verify_sent"}}";
+var i18nConfirmUnlink = "{{index .T "confirm_unlink_email"}}";
+function postForm(url, email) {
+    return fetch(url, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+        body: 'email=' + encodeURIComponent(email)
+    }).then(function(r) { return r.json(); });
+}
+function linkEmail() {
+    var btn = document.getElementById('link-btn');
+    btn.disabled = true;
+    postForm('/user/emails/link', document.getElementById('email').value).then(function(d) {
+        if (d.ok && d.verified) { location.reload(); return; }
+        alert(d.ok ? i18nLinkSent : (d.error || 'error'));
+        btn.disabled = false;
+    }).catch(function() { btn.disabled = false; });
+    return false;
+}
+function emailAction(action, email) {
+    if (action === 'unlink' && !confirm(i18nConfirmUnlink)) return;
+    postForm('/user/emails/' + action, email).then(function(d) {
+        if (d.ok) location.reload();
+        else alert(d.error || 'error');
+    });
+}
+</script>
+` + I18nJS + `
+</body>
+</html>`
@@ -121,6 +121,36 @@ const userLoginHTML = `<!DOCTYPE html>
             margin-bottom: 16px;
             border: 1px solid #fecaca;
         }
+        .oauth-divider {
+            display: flex;
+            align-items: center;
+            gap: 10px;
+            margin: 18px 0 12px;
+            color: #94a3b8;
+            font-size: 13px;
+        }
+        .oauth-divider::before, .oauth-divider::after {
+            content: "";
+            flex: 1;
+            height: 1px;
+            background: #e2e8f0;
+        }
+        .btn-oauth {
+            display: block;
+            width: 100%;
+            padding: 10px;
+            margin-bottom: 8px;
+            background: #fff;
+            color: #334155;
+            border: 1px solid #cbd5e1;
+            border-radius: 8px;
+            font-size: 14px;
+            text-align: center;
+            text-decoration: none;
+            box-sizing: border-box;
+            transition: background 0.2s;
+        }
+        .btn-oauth:hover { background: #f8fafc; }
         .auth-footer {
             text-align: center;
             margin-top: 20px;
@@ -163,7 +193,14 @@ const userLoginHTML = `<!DOCTYPE html>
         </div>
         <button type="submit" class="btn-submit">{{index .T "login"}}</button>
     </form>
+    {{if or .OAuthGoogle .OAuthGitHub}}
+    <div class="oauth-divider">{{index .T "oauth_divider"}}</div>
+    {{if .OAuthGoogle}}<a class="btn-oauth" href="/user/oauth/google/start{{if .Redirect}}?redirect={{.Redirect}}{{end}}">{{index .T "oauth_login_google"}}</a>{{end}}
+    {{if .OAuthGitHub}}<a class="btn-oauth" href="/user/oauth/github/start{{if .Redirect}}?redirect={{.Redirect}}{{end}}">{{index .T "oauth_login_github"}}</a>{{end}}
+    {{end}}
     <div class="auth-footer">
+        <a href="/user/forgot-password">{{index .T "forgot_password_link"}}</a>
+        <span style="color:#cbd5e1;margin:0 8px;">|</span>
         <a href="/user/register{{if .Redirect}}?redirect={{.Redirect}}{{end}}">{{index .T "no_account"}}</a>
     </div>
 </div>
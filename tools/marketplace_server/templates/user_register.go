@@ -155,6 +155,7 @@ const userRegisterHTML = `<!DOCTYPE html>
     <form method="POST" action="/user/register" onsubmit="return validateForm()">
         <input type="hidden" name="captcha_id" id="captcha_id" value="{{.CaptchaID}}" />
         <input type="hidden" name="redirect" value="{{.Redirect}}" />
+        <input type="hidden" name="ref" value="{{.Ref}}" />
         <div class="form-group">
             <label for="email">{{index .T "email"}}</label>
             <input type="email" id="email" name="email" required autocomplete="email" placeholder="{{index .T "enter_email"}}" />
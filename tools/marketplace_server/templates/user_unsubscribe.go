@@ -0,0 +1,37 @@
+package templates
+
+import "html/template"
+
+// UserUnsubscribeTmpl is the parsed storefront email unsubscribe page template.
+var UserUnsubscribeTmpl = template.Must(template.New("user_unsubscribe").Funcs(BaseFuncMap).Parse(userUnsubscribeHTML))
+
+const userUnsubscribeHTML = `<!DOCTYPE html>
+<html lang="{{.HtmlLang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>{{index .T "unsubscribe_title"}} - {{index .T "site_name"}}</title>
+` + userResetPasswordStyle + `</head>
+<body>
+<div class="auth-card">
+    <div class="logo"><img src="{{logoURL}}" alt="" style="width:48px;height:48px;border-radius:12px;"></div>
+    <h1>{{index .T "unsubscribe_title"}}</h1>
+    {{if .Done}}
+    <div class="success-msg">{{index .T "unsubscribe_done"}}</div>
+    {{else if .StoreName}}
+    {{if .Error}}<div class="error-msg">{{.Error}}</div>{{end}}
+    <p class="subtitle">{{printf (index .T "unsubscribe_confirm") .Email .StoreName}}</p>
+    <form method="POST" action="/user/email/unsubscribe">
+        <input type="hidden" name="s" value="{{.StorefrontID}}" />
+        <input type="hidden" name="e" value="{{.Email}}" />
+        <input type="hidden" name="t" value="{{.Token}}" />
+        <button type="submit" class="btn-submit">{{index .T "unsubscribe_button"}}</button>
+    </form>
+    {{else}}
+    <div class="error-msg">{{.Error}}</div>
+    {{end}}
+</div>
+` + I18nJS + `
+</body>
+</html>`
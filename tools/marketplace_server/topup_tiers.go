@@ -0,0 +1,174 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+)
+
+// Credits top-up tiers. Admins define a short list of price points, each
+// granting a base amount of credits plus optional bonus credits, and every
+// credits product offers them as purchase options next to its own price.
+// PayPal charges the tier's fiat price; on fulfillment the base credits are
+// recorded as the usual 'purchase' transaction and the bonus as a separate
+// 'bonus' transaction. Orders keep a snapshot of the tier so later edits do
+// not change what a paid order delivers.
+const maxTopupTiers = 8
+
+// orderCreditsSQL is the credits a custom product order delivers: the tier
+// snapshot when bought at a tier, else the product's amount, plus any bonus.
+// It expects the order aliased o and the product p.
+const orderCreditsSQL = "COALESCE(NULLIF(o.credits_amount, 0), p.credits_amount, 0) + COALESCE(o.bonus_credits, 0)"
+
+// CreditsTopupTier is one purchase option of credits products.
+type CreditsTopupTier struct {
+	ID           int64   `json:"id"`
+	PriceUSD     float64 `json:"price_usd"`
+	Credits      int     `json:"credits"`
+	BonusCredits int     `json:"bonus_credits"`
+	// 店铺页面展示用（按结算币种换算，不入库）
+	ChargeTotal    float64 `json:"-"`
+	ChargeCurrency string  `json:"-"`
+}
+
+// TotalCredits is what the buyer receives for the tier.
+func (t CreditsTopupTier) TotalCredits() int {
+	return t.Credits + t.BonusCredits
+}
+
+// CreditsPerUSD is the effective rate of the tier, bonus included.
+func (t CreditsTopupTier) CreditsPerUSD() float64 {
+	if t.PriceUSD <= 0 {
+		return 0
+	}
+	return math.Round(float64(t.TotalCredits())/t.PriceUSD*100) / 100
+}
+
+// loadTopupTiers returns the configured tiers, cheapest first.
+func loadTopupTiers() ([]CreditsTopupTier, error) {
+	rows, err := db.Query("SELECT id, price_usd, credits, bonus_credits FROM credits_topup_tiers ORDER BY price_usd, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tiers := []CreditsTopupTier{}
+	for rows.Next() {
+		var t CreditsTopupTier
+		if err := rows.Scan(&t.ID, &t.PriceUSD, &t.Credits, &t.BonusCredits); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
+
+// loadTopupTier returns one tier, or sql.ErrNoRows.
+func loadTopupTier(id int64) (CreditsTopupTier, error) {
+	var t CreditsTopupTier
+	err := db.QueryRow("SELECT id, price_usd, credits, bonus_credits FROM credits_topup_tiers WHERE id = ?", id).
+		Scan(&t.ID, &t.PriceUSD, &t.Credits, &t.BonusCredits)
+	return t, err
+}
+
+// validateTopupTiers checks a tier list an admin wants to save and returns
+// the error message to show, or "". Tiers must be listed by increasing price
+// and a dearer tier may not give fewer credits per dollar than a cheaper one.
+func validateTopupTiers(tiers []CreditsTopupTier) string {
+	if len(tiers) > maxTopupTiers {
+		return fmt.Sprintf("最多设置 %d 个充值档位", maxTopupTiers)
+	}
+	for i, t := range tiers {
+		if t.PriceUSD <= 0 || t.PriceUSD > 9999.99 || math.Abs(math.Round(t.PriceUSD*100)-t.PriceUSD*100) > 1e-6 {
+			return fmt.Sprintf("第 %d 档：价格必须为正数、最多两位小数且不超过 9999.99 美元", i+1)
+		}
+		if t.Credits <= 0 {
+			return fmt.Sprintf("第 %d 档：积分数量必须为正数", i+1)
+		}
+		if t.BonusCredits < 0 || t.BonusCredits > t.Credits {
+			return fmt.Sprintf("第 %d 档：赠送积分不能为负数，也不能超过基础积分", i+1)
+		}
+		if i > 0 {
+			prev := tiers[i-1]
+			if t.PriceUSD <= prev.PriceUSD {
+				return fmt.Sprintf("第 %d 档：价格必须高于上一档", i+1)
+			}
+			if float64(t.TotalCredits())/t.PriceUSD < float64(prev.TotalCredits())/prev.PriceUSD {
+				return fmt.Sprintf("第 %d 档：每美元可得积分不能低于上一档", i+1)
+			}
+		}
+	}
+	return ""
+}
+
+// recordTopupBonus records the bonus credits of a tier order for userID. The
+// wallet has already been credited together with the base credits.
+func recordTopupBonus(tx *sql.Tx, userID int64, bonus int, productName string) error {
+	if bonus <= 0 {
+		return nil
+	}
+	_, err := tx.Exec(`INSERT INTO credits_transactions (user_id, transaction_type, amount, description, created_at)
+		VALUES (?, 'bonus', ?, ?, CURRENT_TIMESTAMP)`,
+		userID, bonus, fmt.Sprintf("购买商品「%s」赠送 %d 积分", productName, bonus))
+	return err
+}
+
+// handleAdminTopupTiers handles GET/POST /admin/settings/topup-tiers.
+// POST {"tiers": [{"price_usd", "credits", "bonus_credits"}]} replaces all
+// tiers; an empty list turns tiers off.
+func handleAdminTopupTiers(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		tiers, err := loadTopupTiers()
+		if err != nil {
+			log.Printf("[ADMIN] failed to load topup tiers: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"tiers": tiers})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Tiers []CreditsTopupTier `json:"tiers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid_request_body"})
+		return
+	}
+	if msg := validateTopupTiers(req.Tiers); msg != "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("[ADMIN] failed to begin transaction: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM credits_topup_tiers"); err != nil {
+		log.Printf("[ADMIN] failed to clear topup tiers: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	for _, t := range req.Tiers {
+		if _, err := tx.Exec("INSERT INTO credits_topup_tiers (price_usd, credits, bonus_credits) VALUES (?, ?, ?)",
+			t.PriceUSD, t.Credits, t.BonusCredits); err != nil {
+			log.Printf("[ADMIN] failed to save topup tier: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ADMIN] failed to commit topup tiers: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestValidateTopupTiers(t *testing.T) {
+	cases := []struct {
+		name  string
+		tiers []CreditsTopupTier
+		ok    bool
+	}{
+		{"empty", nil, true},
+		{"valid", []CreditsTopupTier{{PriceUSD: 5, Credits: 500}, {PriceUSD: 20, Credits: 2000, BonusCredits: 200}}, true},
+		{"zero price", []CreditsTopupTier{{PriceUSD: 0, Credits: 500}}, false},
+		{"sub-cent price", []CreditsTopupTier{{PriceUSD: 4.999, Credits: 500}}, false},
+		{"no credits", []CreditsTopupTier{{PriceUSD: 5}}, false},
+		{"bonus above credits", []CreditsTopupTier{{PriceUSD: 5, Credits: 100, BonusCredits: 101}}, false},
+		{"price not increasing", []CreditsTopupTier{{PriceUSD: 10, Credits: 1000}, {PriceUSD: 10, Credits: 1100}}, false},
+		{"worse rate", []CreditsTopupTier{{PriceUSD: 5, Credits: 600}, {PriceUSD: 10, Credits: 1000, BonusCredits: 100}}, false},
+		{"too many", make([]CreditsTopupTier, maxTopupTiers+1), false},
+	}
+	for _, c := range cases {
+		if got := validateTopupTiers(c.tiers) == ""; got != c.ok {
+			t.Errorf("%s: valid = %v, want %v (%q)", c.name, got, c.ok, validateTopupTiers(c.tiers))
+		}
+	}
+}
+
+func TestCreditCustomProductOrderTopupBonus(t *testing.T) {
+	useTestDB(t)
+	if _, err := db.Exec("INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'email', 'b', 'Buyer', 'buyer@example.com')"); err != nil {
+		t.Fatal(err)
+	}
+	// handlePayPalReturn swaps in the tier's base credits before crediting.
+	product := CustomProduct{ProductName: "Credits", ProductType: "credits", CreditsAmount: 1000}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := creditCustomProductOrder(tx, CustomProductOrder{UserID: 2, BonusCredits: 150}, product); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := getWalletBalance(2); got != 1150 {
+		t.Errorf("balance = %v, want 1150", got)
+	}
+	var purchase, bonus float64
+	db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM credits_transactions WHERE user_id = 2 AND transaction_type = 'purchase'").Scan(&purchase)
+	db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM credits_transactions WHERE user_id = 2 AND transaction_type = 'bonus'").Scan(&bonus)
+	if purchase != 1000 || bonus != 150 {
+		t.Errorf("transactions: purchase %v, bonus %v, want 1000 and 150", purchase, bonus)
+	}
+}
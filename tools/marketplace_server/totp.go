@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238 defaults understood by all authenticator apps).
+const (
+	totpPeriod        = 30 // seconds per time step
+	totpDigits        = 6
+	totpSkew          = 1 // accept codes from this many steps before/after now
+	totpRecoveryCodes = 10
+)
+
+var totpBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateTOTPSecret returns a new random 160-bit secret, base32 encoded.
+func generateTOTPSecret() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand.Read failed: %v", err))
+	}
+	return totpBase32.EncodeToString(b)
+}
+
+// totpCodeAt computes the HOTP value (RFC 4226) for the given counter.
+func totpCodeAt(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// verifyTOTP checks code against secretB32 at time t, allowing totpSkew steps
+// of clock drift in either direction. Codes at or before lastCounter are
+// rejected so a code cannot be replayed. Returns the matched counter.
+func verifyTOTP(secretB32, code string, t time.Time, lastCounter uint64) (uint64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	secret, err := totpBase32.DecodeString(strings.ToUpper(secretB32))
+	if err != nil || len(secret) == 0 {
+		return 0, false
+	}
+	current := uint64(t.Unix()) / totpPeriod
+	for d := -totpSkew; d <= totpSkew; d++ {
+		counter := current + uint64(d)
+		if d < 0 && current < uint64(-d) {
+			continue
+		}
+		if counter <= lastCounter {
+			continue
+		}
+		if hmac.Equal([]byte(totpCodeAt(secret, counter)), []byte(code)) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// totpProvisioningURI builds the otpauth:// URI that authenticator apps import.
+func totpProvisioningURI(issuer, account, secretB32 string) string {
+	q := url.Values{}
+	q.Set("secret", secretB32)
+	q.Set("issuer", issuer)
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// generateRecoveryCodes returns fresh one-time recovery codes and the JSON
+// array of their SHA-256 hashes for storage. The codes are random enough that
+// a fast hash is sufficient.
+func generateRecoveryCodes() ([]string, string) {
+	codes := make([]string, totpRecoveryCodes)
+	hashes := make([]string, totpRecoveryCodes)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			panic(fmt.Sprintf("crypto/rand.Read failed: %v", err))
+		}
+		raw := strings.ToLower(totpBase32.EncodeToString(b)) // 8 chars
+		codes[i] = raw[:4] + "-" + raw[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	data, _ := json.Marshal(hashes)
+	return codes, string(data)
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// consumeRecoveryCode checks code against the stored JSON hash list. On a match
+// it returns the list with that code removed.
+func consumeRecoveryCode(storedJSON, code string) (string, bool) {
+	var hashes []string
+	if storedJSON == "" || json.Unmarshal([]byte(storedJSON), &hashes) != nil {
+		return storedJSON, false
+	}
+	h := hashRecoveryCode(code)
+	for i, stored := range hashes {
+		if hmac.Equal([]byte(stored), []byte(h)) {
+			remaining := append(hashes[:i:i], hashes[i+1:]...)
+			data, _ := json.Marshal(remaining)
+			return string(data), true
+		}
+	}
+	return storedJSON, false
+}
+
+// countRecoveryCodes returns how many unused recovery codes remain.
+func countRecoveryCodes(storedJSON string) int {
+	var hashes []string
+	if storedJSON == "" || json.Unmarshal([]byte(storedJSON), &hashes) != nil {
+		return 0
+	}
+	return len(hashes)
+}
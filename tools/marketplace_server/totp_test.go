@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key from RFC 6238 Appendix B.
+var rfc6238Secret = totpBase32.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range cases {
+		got := totpCodeAt([]byte("12345678901234567890"), uint64(tc.unix)/totpPeriod)
+		if got != tc.want {
+			t.Errorf("T=%d: code = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyTOTPDriftTolerance(t *testing.T) {
+	issued := time.Unix(1111111109, 0)
+	code := "081804"
+
+	cases := []struct {
+		name   string
+		offset time.Duration
+		want   bool
+	}{
+		{"same step", 0, true},
+		{"one step late", 30 * time.Second, true},
+		{"one step early", -30 * time.Second, true},
+		{"two steps late", 60 * time.Second, false},
+		{"two steps early", -60 * time.Second, false},
+		{"far outside window", 5 * time.Minute, false},
+	}
+	for _, tc := range cases {
+		if _, ok := verifyTOTP(rfc6238Secret, code, issued.Add(tc.offset), 0); ok != tc.want {
+			t.Errorf("%s: verifyTOTP = %v, want %v", tc.name, ok, tc.want)
+		}
+	}
+}
+
+func TestVerifyTOTPRejectsReplayAndMalformed(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	counter, ok := verifyTOTP(rfc6238Secret, "081804", now, 0)
+	if !ok {
+		t.Fatal("valid code rejected")
+	}
+	if _, ok := verifyTOTP(rfc6238Secret, "081804", now, counter); ok {
+		t.Error("replayed code accepted")
+	}
+	for _, code := range []string{"", "08180", "0818045", "abcdef"} {
+		if _, ok := verifyTOTP(rfc6238Secret, code, now, 0); ok {
+			t.Errorf("malformed code %q accepted", code)
+		}
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	codes, stored := generateRecoveryCodes()
+	if len(codes) != totpRecoveryCodes || countRecoveryCodes(stored) != totpRecoveryCodes {
+		t.Fatalf("got %d codes, %d stored", len(codes), countRecoveryCodes(stored))
+	}
+	remaining, ok := consumeRecoveryCode(stored, codes[3])
+	if !ok {
+		t.Fatal("recovery code rejected")
+	}
+	if countRecoveryCodes(remaining) != totpRecoveryCodes-1 {
+		t.Errorf("remaining = %d, want %d", countRecoveryCodes(remaining), totpRecoveryCodes-1)
+	}
+	if _, ok := consumeRecoveryCode(remaining, codes[3]); ok {
+		t.Error("recovery code accepted twice")
+	}
+	if _, ok := consumeRecoveryCode(remaining, "zzzz-zzzz"); ok {
+		t.Error("unknown recovery code accepted")
+	}
+}
+
+func TestQRFormatAndErrorCorrection(t *testing.T) {
+	// Level M, mask 0 format string from ISO/IEC 18004 Table C.1.
+	if got := qrFormatBits(0); got != 0x5412 {
+		t.Errorf("qrFormatBits(0) = %#x, want 0x5412", got)
+	}
+
+	// 1-M example from ISO/IEC 18004 Annex I ("01234567").
+	data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+	got := qrRSRemainder(data, qrRSDivisor(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Errorf("RS remainder = % X, want % X", got, want)
+	}
+
+	qr, err := encodeQR([]byte(totpProvisioningURI(adminTOTPIssuer, "admin", rfc6238Secret)))
+	if err != nil {
+		t.Fatalf("encodeQR: %v", err)
+	}
+	if (qr.size-17)%4 != 0 {
+		t.Errorf("unexpected symbol size %d", qr.size)
+	}
+	if _, err := qr.PNG(4); err != nil {
+		t.Errorf("PNG: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bounds and default for the admin-configurable trending half-life.
+const (
+	defaultTrendingHalfLifeDays = 7
+	minTrendingHalfLifeDays     = 1
+	maxTrendingHalfLifeDays     = 90
+)
+
+// trendingWindowHalfLives limits the sales considered for trending to this
+// many half-lives; anything older contributes less than 1% of its value.
+const trendingWindowHalfLives = 7
+
+// getTrendingHalfLifeDays reads the trending_half_life_days setting, falling
+// back to defaultTrendingHalfLifeDays when unset or out of range.
+func getTrendingHalfLifeDays() int {
+	days, err := strconv.Atoi(getSetting("trending_half_life_days"))
+	if err != nil || days < minTrendingHalfLifeDays || days > maxTrendingHalfLifeDays {
+		return defaultTrendingHalfLifeDays
+	}
+	return days
+}
+
+// queryTrendingProducts 查询近期热度最高的已发布产品，最多返回 limit 个。
+// 每笔购买类交易的金额按距 now 的时间指数衰减（半衰期 halfLife）后累加，
+// 因此近期的销售权重更高，早年的爆款不会长期霸榜。
+func queryTrendingProducts(limit int, halfLife time.Duration, now time.Time) ([]HomepageProductInfo, error) {
+	since := now.Add(-trendingWindowHalfLives * halfLife)
+	rows, err := db.Query(`SELECT pl.id, pl.pack_name, COALESCE(pl.pack_description, ''), COALESCE(pl.author_name, ''), pl.share_mode, pl.credits_price,
+		pl.download_count, COALESCE(pl.share_token, ''),
+		julianday(?) - julianday(ct.created_at) as age_days, ABS(ct.amount)
+		FROM pack_listings pl
+		JOIN credits_transactions ct ON ct.listing_id = pl.id
+			AND ct.transaction_type IN ('purchase', 'purchase_uses', 'renew', 'download')
+			AND ct.created_at >= ?
+		WHERE pl.status = 'published'
+		  AND NOT EXISTS (SELECT 1 FROM author_storefronts ps WHERE ps.user_id = pl.user_id AND ps.store_status = 'paused')`,
+		now.UTC().Format("2006-01-02 15:04:05"), since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, fmt.Errorf("queryTrendingProducts: %w", err)
+	}
+	defer rows.Close()
+
+	halfLifeDays := halfLife.Hours() / 24
+	products := make(map[int64]HomepageProductInfo)
+	scores := make(map[int64]float64)
+	for rows.Next() {
+		var p HomepageProductInfo
+		var ageDays, amount float64
+		if err := rows.Scan(&p.ListingID, &p.PackName, &p.PackDesc, &p.AuthorName, &p.ShareMode, &p.CreditsPrice, &p.DownloadCount, &p.ShareToken, &ageDays, &amount); err != nil {
+			return nil, fmt.Errorf("queryTrendingProducts scan: %w", err)
+		}
+		if ageDays < 0 {
+			ageDays = 0
+		}
+		products[p.ListingID] = p
+		scores[p.ListingID] += amount * math.Exp(-math.Ln2*ageDays/halfLifeDays)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("queryTrendingProducts rows: %w", err)
+	}
+
+	ids := make([]int64, 0, len(scores))
+	for id, score := range scores {
+		if score > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	trending := make([]HomepageProductInfo, 0, len(ids))
+	for _, id := range ids {
+		trending = append(trending, products[id])
+	}
+	return trending, nil
+}
+
+// handleAdminTrendingSettings handles GET/POST /admin/settings/trending.
+// GET returns the half-life in days; POST saves form value "half_life_days"
+// and drops the cached homepage so the new ranking shows immediately.
+func handleAdminTrendingSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"half_life_days": getTrendingHalfLifeDays()})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days, err := strconv.Atoi(strings.TrimSpace(r.FormValue("half_life_days")))
+	if err != nil || days < minTrendingHalfLifeDays || days > maxTrendingHalfLifeDays {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("半衰期必须在 %d 到 %d 天之间", minTrendingHalfLifeDays, maxTrendingHalfLifeDays)})
+		return
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES ('trending_half_life_days', ?)", strconv.Itoa(days)); err != nil {
+		log.Printf("[ADMIN] failed to save trending_half_life_days: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	globalCache.InvalidateHomepage()
+	log.Printf("[ADMIN] trending half-life set to %d days", days)
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
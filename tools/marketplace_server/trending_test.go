@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryTrendingProducts(t *testing.T) {
+	useTestDB(t)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(daysAgo int) string {
+		return now.AddDate(0, 0, -daysAgo).Format("2006-01-02 15:04:05")
+	}
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (1, 'sn', 'a', 'A')")
+	mustExec(t, `INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES
+		(1, 1, 1, x'00', 'Old Hit', 'per_use', 'published'),
+		(2, 1, 1, x'00', 'New Hit', 'per_use', 'published'),
+		(3, 1, 1, x'00', 'Draft', 'per_use', 'pending')`)
+	// The old hit sold far more in total, but a month ago.
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, created_at) VALUES (1, 'purchase', -1000, 1, ?)", at(30))
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, created_at) VALUES (1, 'purchase', -100, 2, ?)", at(1))
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id, created_at) VALUES (1, 'purchase', -5000, 3, ?)", at(1))
+
+	ids := func(halfLife time.Duration) []int64 {
+		products, err := queryTrendingProducts(10, halfLife, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []int64
+		for _, p := range products {
+			out = append(out, p.ListingID)
+		}
+		return out
+	}
+	if got := ids(3 * 24 * time.Hour); len(got) != 1 || got[0] != 2 {
+		t.Errorf("3-day half-life: got %v, want only the recent hit", got)
+	}
+	if got := ids(30 * 24 * time.Hour); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("30-day half-life: got %v, want old hit first", got)
+	}
+}
+
+func TestAdminTrendingSettings(t *testing.T) {
+	useTestDB(t)
+	prevCache := globalCache
+	globalCache = NewCache(CacheConfig{})
+	t.Cleanup(func() { globalCache = prevCache })
+
+	post := func(days string) int {
+		form := url.Values{"half_life_days": {days}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/settings/trending", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handleAdminTrendingSettings(rec, req)
+		return rec.Code
+	}
+	if code := post("0"); code != http.StatusBadRequest {
+		t.Errorf("half-life 0: status %d", code)
+	}
+	if got := getTrendingHalfLifeDays(); got != defaultTrendingHalfLifeDays {
+		t.Errorf("default half-life %d", got)
+	}
+	if code := post("14"); code != http.StatusOK {
+		t.Fatalf("half-life 14: status %d", code)
+	}
+	if got := getTrendingHalfLifeDays(); got != 14 {
+		t.Errorf("saved half-life %d, want 14", got)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"marketplace_server/i18n"
+)
+
+// A block on users.is_blocked may carry a reason shown to the user and an
+// expiry in blocked_until ("2006-01-02 15:04:05" UTC, empty for permanent).
+// Expired blocks are lifted lazily by userBlockStatus when the user next
+// authenticates, and in bulk by expireUserBlocks from the cleanup loop.
+const maxBlockReasonLen = 500
+
+// userBlock is the block state of one user.
+type userBlock struct {
+	Blocked bool
+	Reason  string
+	Until   time.Time // zero for a permanent block
+}
+
+// userBlockStatus returns the block state of userID at now, lifting the block
+// first if it has expired.
+func userBlockStatus(userID int64, now time.Time) userBlock {
+	var blocked int
+	var reason, until string
+	err := db.QueryRow("SELECT COALESCE(is_blocked, 0), COALESCE(blocked_reason, ''), COALESCE(blocked_until, '') FROM users WHERE id = ?",
+		userID).Scan(&blocked, &reason, &until)
+	if err != nil || blocked == 0 {
+		return userBlock{}
+	}
+	b := userBlock{Blocked: true, Reason: reason}
+	if until == "" {
+		return b
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", until)
+	if err != nil {
+		return b
+	}
+	if now.UTC().Before(t) {
+		b.Until = t
+		return b
+	}
+	res, err := db.Exec(`UPDATE users SET is_blocked = 0, blocked_reason = '', blocked_until = ''
+		WHERE id = ? AND is_blocked = 1 AND blocked_until = ?`, userID, until)
+	if err != nil {
+		log.Printf("[BAN] failed to lift expired block of user %d: %v", userID, err)
+		return b
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("[BAN] block of user %d expired", userID)
+		recordAuditLog(0, "user_unblock", fmt.Sprintf("user:%d", userID), "expired", "")
+	}
+	return userBlock{}
+}
+
+// expireUserBlocks lifts every block whose blocked_until has passed and
+// returns how many users were unblocked.
+func expireUserBlocks(now time.Time) int {
+	nowStr := now.UTC().Format("2006-01-02 15:04:05")
+	rows, err := db.Query("SELECT id FROM users WHERE is_blocked = 1 AND COALESCE(blocked_until, '') != '' AND blocked_until <= ?", nowStr)
+	if err != nil {
+		log.Printf("[BAN] failed to query expired blocks: %v", err)
+		return 0
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	lifted := 0
+	for _, id := range ids {
+		if !userBlockStatus(id, now).Blocked {
+			lifted++
+		}
+	}
+	if lifted > 0 {
+		log.Printf("[BAN] lifted %d expired blocks", lifted)
+	}
+	return lifted
+}
+
+// blockedMessage is the text shown to a blocked user.
+func blockedMessage(lang i18n.Lang, b userBlock) string {
+	msg := i18n.T(lang, "account_blocked")
+	if b.Reason != "" {
+		msg += " " + fmt.Sprintf(i18n.T(lang, "account_blocked_reason"), b.Reason)
+	}
+	if !b.Until.IsZero() {
+		msg += " " + fmt.Sprintf(i18n.T(lang, "account_blocked_until"), b.Until.Format("2006-01-02 15:04")+" UTC")
+	}
+	return msg
+}
+
+// writeBlockedJSON writes the 403 returned to a blocked user by API routes.
+func writeBlockedJSON(w http.ResponseWriter, r *http.Request, b userBlock) {
+	resp := map[string]string{
+		"error":   "account_blocked",
+		"message": blockedMessage(i18n.DetectLang(r), b),
+		"reason":  b.Reason,
+	}
+	if !b.Until.IsZero() {
+		resp["blocked_until"] = b.Until.Format(time.RFC3339)
+	}
+	jsonResponse(w, http.StatusForbidden, resp)
+}
+
+// blockedNoticeToken signs userID so the login page only shows the block
+// reason to the browser that was turned away, not to anyone guessing IDs.
+func blockedNoticeToken(userID int64) string {
+	mac := hmac.New(sha256.New, jwtSecret)
+	fmt.Fprintf(mac, "blocked-notice:%d", userID)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// blockedLoginURL is where a blocked user is sent from HTML flows.
+func blockedLoginURL(userID int64) string {
+	q := url.Values{}
+	q.Set("error", "blocked")
+	q.Set("u", strconv.FormatInt(userID, 10))
+	q.Set("t", blockedNoticeToken(userID))
+	return "/user/login?" + q.Encode()
+}
+
+// blockedLoginMessage returns the message for /user/login?error=blocked,
+// including the reason when the link carries a valid notice token.
+func blockedLoginMessage(r *http.Request) string {
+	lang := i18n.DetectLang(r)
+	userID, err := strconv.ParseInt(r.URL.Query().Get("u"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(r.URL.Query().Get("t")), []byte(blockedNoticeToken(userID))) {
+		return i18n.T(lang, "account_blocked")
+	}
+	b := userBlockStatus(userID, time.Now())
+	if !b.Blocked {
+		return i18n.T(lang, "account_blocked")
+	}
+	return blockedMessage(lang, b)
+}
+
+// blockRequest is the optional body of the block endpoints. DurationHours 0
+// blocks until an admin lifts it.
+type blockRequest struct {
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"`
+}
+
+// validate normalizes the request and returns the blocked_until value.
+func (req *blockRequest) validate(now time.Time) (string, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len([]rune(req.Reason)) > maxBlockReasonLen {
+		return "", fmt.Errorf("封禁原因不能超过 %d 个字符", maxBlockReasonLen)
+	}
+	if req.DurationHours < 0 || req.DurationHours > 24*365*10 {
+		return "", fmt.Errorf("封禁时长无效")
+	}
+	if req.DurationHours == 0 {
+		return "", nil
+	}
+	return now.UTC().Add(time.Duration(req.DurationHours) * time.Hour).Format("2006-01-02 15:04:05"), nil
+}
+
+// setUsersBlocked blocks (with reason and until) or unblocks the users
+// matched by where/args, and records the change in the audit log.
+func setUsersBlocked(r *http.Request, target string, blocked bool, reason, until, where string, args ...interface{}) error {
+	var res sql.Result
+	var err error
+	if blocked {
+		res, err = db.Exec("UPDATE users SET is_blocked = 1, blocked_reason = ?, blocked_until = ? WHERE "+where,
+			append([]interface{}{reason, until}, args...)...)
+	} else {
+		res, err = db.Exec("UPDATE users SET is_blocked = 0, blocked_reason = '', blocked_until = '' WHERE "+where, args...)
+	}
+	if err != nil {
+		return err
+	}
+	adminID, _ := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	n, _ := res.RowsAffected()
+	action, detail := "user_unblock", fmt.Sprintf("accounts=%d", n)
+	if blocked {
+		action = "user_block"
+		detail = fmt.Sprintf("accounts=%d until=%q reason=%q", n, until, reason)
+	}
+	recordAuditLog(adminID, action, target, detail, getClientIP(r))
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemporaryUserBlock(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Alice', 'alice@example.com')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'email', 'b', 'Bob', 'bob@example.com')")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/customers/1/toggle-block", strings.NewReader(`{"reason":"chargeback abuse","duration_hours":48}`))
+	req.Header.Set("X-Admin-ID", "4")
+	rec := httptest.NewRecorder()
+	handleAdminCustomerToggleBlock(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"blocked"`) {
+		t.Fatalf("block: status %d %s", rec.Code, rec.Body.String())
+	}
+	b := userBlockStatus(1, time.Now())
+	if !b.Blocked || b.Reason != "chargeback abuse" || b.Until.Sub(time.Now()) < 47*time.Hour {
+		t.Fatalf("block state = %+v", b)
+	}
+	var detail string
+	db.QueryRow("SELECT detail FROM admin_audit_log WHERE admin_id = 4 AND action = 'user_block' AND target = 'user:1'").Scan(&detail)
+	if !strings.Contains(detail, "chargeback abuse") {
+		t.Errorf("audit detail = %q", detail)
+	}
+
+	// The user is turned away with a link that shows them the reason.
+	page := userAuth(func(w http.ResponseWriter, r *http.Request) { t.Error("blocked user reached the page") })
+	req = httptest.NewRequest(http.MethodGet, "/user/", nil)
+	req.AddCookie(&http.Cookie{Name: "user_session", Value: createUserSession(1)})
+	rec = httptest.NewRecorder()
+	page(rec, req)
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	if loc == nil || loc.Path != "/user/login" || loc.Query().Get("error") != "blocked" {
+		t.Fatalf("redirect = %q", rec.Header().Get("Location"))
+	}
+	if msg := blockedLoginMessage(httptest.NewRequest(http.MethodGet, loc.String(), nil)); !strings.Contains(msg, "chargeback abuse") {
+		t.Errorf("login message = %q, want the reason", msg)
+	}
+	q := loc.Query()
+	q.Set("u", "2")
+	if msg := blockedLoginMessage(httptest.NewRequest(http.MethodGet, "/user/login?"+q.Encode(), nil)); strings.Contains(msg, "chargeback") {
+		t.Errorf("tampered link revealed a reason: %q", msg)
+	}
+
+	// Once blocked_until passes the block lifts lazily.
+	if userBlockStatus(1, time.Now().Add(49*time.Hour)).Blocked {
+		t.Error("block should have expired")
+	}
+	var blocked int
+	var reason string
+	db.QueryRow("SELECT is_blocked, blocked_reason FROM users WHERE id = 1").Scan(&blocked, &reason)
+	if blocked != 0 || reason != "" {
+		t.Errorf("after expiry: is_blocked=%d reason=%q", blocked, reason)
+	}
+
+	// ... or in bulk from the sweep; permanent blocks stay.
+	mustExec(t, "UPDATE users SET is_blocked = 1, blocked_until = '2000-01-01 00:00:00' WHERE id = 1")
+	mustExec(t, "UPDATE users SET is_blocked = 1, blocked_until = '' WHERE id = 2")
+	if n := expireUserBlocks(time.Now()); n != 1 {
+		t.Errorf("expireUserBlocks lifted %d, want 1", n)
+	}
+	if !userBlockStatus(2, time.Now().Add(24*365*time.Hour)).Blocked {
+		t.Error("permanent block was lifted")
+	}
+}
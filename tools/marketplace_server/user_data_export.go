@@ -0,0 +1,208 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"marketplace_server/i18n"
+)
+
+// dataExportMinInterval is the minimum gap between two data exports of the
+// same user. Building an export reads every table holding the user's data.
+const dataExportMinInterval = time.Hour
+
+// dataExportSections are the files of a data export, each produced by one
+// query filtered on the exporting user's id. Columns holding other people's
+// personal data (such as gift recipients' emails) are deliberately left out.
+var dataExportSections = []struct {
+	File  string
+	Query string
+}{
+	{"profile.json", `SELECT u.id, u.auth_type, u.display_name, COALESCE(u.username, '') AS username,
+		COALESCE(u.email, '') AS email, COALESCE(u.preferred_lang, '') AS preferred_lang,
+		COALESCE(u.email_allowed, 1) AS email_allowed, COALESCE(u.kyc_status, 'none') AS kyc_status, u.created_at,
+		COALESCE(ew.credits_balance, 0) AS wallet_balance,
+		COALESCE(pi.payment_type, '') AS payment_type, COALESCE(pi.payment_details, '') AS payment_details
+		FROM users u
+		LEFT JOIN email_wallets ew ON ew.email = u.email AND COALESCE(u.email, '') != ''
+		LEFT JOIN user_payment_info pi ON pi.user_id = u.id
+		WHERE u.id = ?`},
+		{"linked_emails.json", `SELECT email, is_primary, verified_at, created_at FROM account_emails WHERE user_id = ? ORDER BY id`},
+	{"purchases.json", `SELECT upp.listing_id, COALESCE(pl.pack_name, '') AS pack_name, upp.is_hidden, upp.created_at
+		FROM user_purchased_packs upp LEFT JOIN pack_listings pl ON pl.id = upp.listing_id
+		WHERE upp.user_id = ? ORDER BY upp.id`},
+	{"credits_transactions.json", `SELECT id, transaction_type, amount, listing_id, COALESCE(description, '') AS description,
+		COALESCE(ip_address, '') AS ip_address, created_at
+		FROM credits_transactions WHERE user_id = ? ORDER BY id`},
+	{"downloads.json", `SELECT d.listing_id, COALESCE(pl.pack_name, '') AS pack_name, COALESCE(d.ip_address, '') AS ip_address, d.downloaded_at
+		FROM user_downloads d LEFT JOIN pack_listings pl ON pl.id = d.listing_id
+		WHERE d.user_id = ? ORDER BY d.id`},
+	{"custom_product_orders.json", `SELECT o.id, o.custom_product_id, COALESCE(cp.product_name, '') AS product_name,
+		o.amount_usd, o.charged_amount, COALESCE(o.charged_currency, 'USD') AS charged_currency,
+		o.paypal_order_id, o.status, o.license_sn, o.license_email,
+		CASE WHEN COALESCE(o.recipient_email, '') != '' THEN 1 ELSE 0 END AS is_gift,
+		o.created_at, o.updated_at
+		FROM custom_product_orders o LEFT JOIN custom_products cp ON cp.id = o.custom_product_id
+		WHERE o.user_id = ? ORDER BY o.id`},
+	{"storefront.json", `SELECT id, store_name, store_slug, description, COALESCE(store_layout, 'default') AS store_layout,
+		COALESCE(layout_config, '') AS layout_config, COALESCE(theme, 'default') AS theme, COALESCE(custom_theme, '') AS custom_theme,
+		COALESCE(store_status, 'active') AS store_status, COALESCE(store_announcement, '') AS store_announcement,
+		COALESCE(announcement_active, 0) AS announcement_active, COALESCE(refund_policy, '') AS refund_policy,
+		COALESCE(terms, '') AS terms, COALESCE(contact, '') AS contact, auto_add_enabled,
+		COALESCE(custom_products_enabled, 0) AS custom_products_enabled, COALESCE(verified, 0) AS verified,
+		created_at, updated_at
+		FROM author_storefronts WHERE user_id = ?`},
+}
+
+// queryExportRows runs query and returns its rows as column-name maps.
+func queryExportRows(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// buildUserDataExport returns a zip archive of everything stored about userID.
+func buildUserDataExport(userID int64) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, section := range dataExportSections {
+		rows, err := queryExportRows(section.Query, userID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", section.File, err)
+		}
+		var content interface{} = rows
+		if section.File == "profile.json" || section.File == "storefront.json" {
+			// Single-row sections are exported as an object, or null.
+			content = nil
+			if len(rows) > 0 {
+				content = rows[0]
+			}
+		}
+		data, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		f, err := zw.Create(section.File)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var logo []byte
+	var logoType string
+	db.QueryRow("SELECT logo_data, COALESCE(logo_content_type, '') FROM author_storefronts WHERE user_id = ?", userID).Scan(&logo, &logoType)
+	if len(logo) > 0 {
+		ext := ".png"
+		if strings.Contains(logoType, "jpeg") {
+			ext = ".jpg"
+		}
+		f, err := zw.Create("storefront_logo" + ext)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(logo); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dataExportRetryAfter returns how long userID must wait before the next
+// export, or 0 if an export is allowed now.
+func dataExportRetryAfter(userID int64, now time.Time) time.Duration {
+	var last string
+	db.QueryRow("SELECT COALESCE(MAX(created_at), '') FROM user_data_exports WHERE user_id = ?", userID).Scan(&last)
+	if last == "" {
+		return 0
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", last)
+	if err != nil {
+		return 0
+	}
+	if wait := t.Add(dataExportMinInterval).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// handleUserDataExport handles GET /user/data-export, returning a zip of the
+// user's profile, purchases, credits transactions, downloads, custom product
+// orders and storefront configuration.
+func handleUserDataExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	lang := i18n.DetectLang(r)
+
+	now := time.Now().UTC()
+	if wait := dataExportRetryAfter(userID, now); wait > 0 {
+		minutes := int(wait.Minutes()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, fmt.Sprintf(i18n.T(lang, "data_export_limited"), minutes), http.StatusTooManyRequests)
+		return
+	}
+
+	data, err := buildUserDataExport(userID)
+	if err != nil {
+		log.Printf("[DATA-EXPORT] failed to build export for user %d: %v", userID, err)
+		http.Error(w, i18n.T(lang, "data_export_failed"), http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.Exec("INSERT INTO user_data_exports (user_id, ip_address, created_at) VALUES (?, ?, ?)",
+		userID, getClientIP(r), now.Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("[DATA-EXPORT] failed to record export for user %d: %v", userID, err)
+	}
+	log.Printf("[DATA-EXPORT] user %d exported %d bytes", userID, len(data))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="my_data_%s.zip"`, now.Format("20060102")))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
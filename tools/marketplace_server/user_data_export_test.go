@@ -0,0 +1,83 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserDataExport(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email, password_hash) VALUES (1, 'email', 'a', 'Alice', 'alice@example.com', 'secret-hash')")
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (2, 'email', 'b', 'Bob', 'bob@example.com')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance) VALUES ('alice@example.com', 42)")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 2, 1, x'00', 'Bob Pack', 'per_use', 'published')")
+	mustExec(t, "INSERT INTO user_purchased_packs (user_id, listing_id) VALUES (1, 100)")
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id) VALUES (1, 'purchase', -10, 100)")
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id) VALUES (2, 'purchase', -99, 100)")
+	mustExec(t, "INSERT INTO user_downloads (user_id, listing_id, ip_address) VALUES (1, 100, '10.0.0.1')")
+	mustExec(t, "INSERT INTO author_storefronts (id, user_id, store_name, store_slug) VALUES (5, 2, 'Bob Store', 'bob')")
+	mustExec(t, "INSERT INTO custom_products (id, storefront_id, product_name, product_type, price_usd) VALUES (7, 5, 'Gift Card', 'credits', 10)")
+	mustExec(t, "INSERT INTO custom_product_orders (user_id, custom_product_id, amount_usd, status, recipient_email) VALUES (1, 7, 10, 'paid', 'friend@example.com')")
+
+	req := httptest.NewRequest(http.MethodGet, "/user/data-export", nil)
+	req.Header.Set("X-User-ID", "1")
+	rec := httptest.NewRecorder()
+	handleUserDataExport(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+
+	var profile map[string]interface{}
+	if err := json.Unmarshal([]byte(files["profile.json"]), &profile); err != nil {
+		t.Fatalf("profile.json: %v", err)
+	}
+	if profile["email"] != "alice@example.com" || profile["wallet_balance"] != float64(42) {
+		t.Errorf("profile = %v", profile)
+	}
+	var txs []map[string]interface{}
+	json.Unmarshal([]byte(files["credits_transactions.json"]), &txs)
+	if len(txs) != 1 || txs[0]["amount"] != float64(-10) {
+		t.Errorf("credits_transactions = %v", txs)
+	}
+	if !strings.Contains(files["purchases.json"], "Bob Pack") || !strings.Contains(files["downloads.json"], "10.0.0.1") {
+		t.Errorf("purchases = %s, downloads = %s", files["purchases.json"], files["downloads.json"])
+	}
+	if !strings.Contains(files["custom_product_orders.json"], `"is_gift": 1`) {
+		t.Errorf("custom_product_orders = %s", files["custom_product_orders.json"])
+	}
+	if strings.TrimSpace(files["storefront.json"]) != "null" {
+		t.Errorf("storefront = %s", files["storefront.json"])
+	}
+	all := strings.Join([]string{files["profile.json"], files["purchases.json"], files["credits_transactions.json"],
+		files["downloads.json"], files["custom_product_orders.json"]}, "\n")
+	for _, leaked := range []string{"secret-hash", "friend@example.com", "bob@example.com", "Bob Store"} {
+		if strings.Contains(all, leaked) {
+			t.Errorf("export contains %q", leaked)
+		}
+	}
+
+	// A second export within the hour is rate-limited.
+	rec = httptest.NewRecorder()
+	handleUserDataExport(rec, req)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("second export: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// WishlistItem is a saved pack as returned by GET /api/wishlist.
+type WishlistItem struct {
+	ListingID    int64  `json:"listing_id"`
+	PackName     string `json:"pack_name"`
+	AuthorName   string `json:"author_name"`
+	CategoryName string `json:"category_name"`
+	ShareMode    string `json:"share_mode"`
+	CreditsPrice int    `json:"credits_price"`
+	SavedAt      string `json:"saved_at"`
+	Purchased    bool   `json:"purchased"`
+}
+
+// getUserWishlistListingIDs returns the set of listing IDs the user has saved.
+func getUserWishlistListingIDs(userID int64) map[int64]bool {
+	saved := make(map[int64]bool)
+	rows, err := db.Query("SELECT listing_id FROM user_wishlist WHERE user_id = ?", userID)
+	if err != nil {
+		log.Printf("[WISHLIST] failed to query wishlist for user %d: %v", userID, err)
+		return saved
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lid int64
+		if rows.Scan(&lid) == nil {
+			saved[lid] = true
+		}
+	}
+	return saved
+}
+
+// wishlistCount returns how many published packs the user has saved.
+func wishlistCount(userID int64) int {
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM user_wishlist w JOIN pack_listings pl ON pl.id = w.listing_id
+		WHERE w.user_id = ? AND pl.status = 'published'`, userID).Scan(&n)
+	return n
+}
+
+// removeListingFromWishlists drops a deleted listing from every wishlist.
+func removeListingFromWishlists(listingID int64) {
+	if _, err := db.Exec("DELETE FROM user_wishlist WHERE listing_id = ?", listingID); err != nil {
+		log.Printf("[WISHLIST] failed to clean up wishlists for listing %d: %v", listingID, err)
+	}
+}
+
+// handleWishlist handles the authenticated user's wishlist.
+//
+//	GET    /api/wishlist                 list saved published packs and the badge count
+//	POST   /api/wishlist                 {"listing_id": N} save a pack
+//	DELETE /api/wishlist?listing_id=N    remove a pack
+func handleWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := db.Query(`
+			SELECT pl.id, pl.pack_name, COALESCE(pl.author_name, ''), COALESCE(c.name, ''),
+			       pl.share_mode, pl.credits_price, w.created_at
+			FROM user_wishlist w
+			JOIN pack_listings pl ON pl.id = w.listing_id
+			LEFT JOIN categories c ON c.id = pl.category_id
+			WHERE w.user_id = ? AND pl.status = 'published'
+			ORDER BY w.created_at DESC, w.id DESC`, userID)
+		if err != nil {
+			log.Printf("[WISHLIST] failed to list wishlist for user %d: %v", userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		defer rows.Close()
+		items := []WishlistItem{}
+		for rows.Next() {
+			var it WishlistItem
+			if err := rows.Scan(&it.ListingID, &it.PackName, &it.AuthorName, &it.CategoryName,
+				&it.ShareMode, &it.CreditsPrice, &it.SavedAt); err != nil {
+				log.Printf("[WISHLIST] scan error: %v", err)
+				continue
+			}
+			items = append(items, it)
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("[WISHLIST] rows iteration error: %v", err)
+		}
+		rows.Close()
+
+		purchasedSet := getUserPurchasedListingIDs(userID)
+		for i := range items {
+			items[i].Purchased = purchasedSet[items[i].ListingID]
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+
+	case http.MethodPost:
+		var req struct {
+			ListingID int64 `json:"listing_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ListingID <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "listing_id must be positive"})
+			return
+		}
+		var status string
+		err := db.QueryRow("SELECT status FROM pack_listings WHERE id = ?", req.ListingID).Scan(&status)
+		if err == sql.ErrNoRows || (err == nil && status != "published") {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "pack not found"})
+			return
+		} else if err != nil {
+			log.Printf("[WISHLIST] failed to query listing %d: %v", req.ListingID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		if _, err := db.Exec("INSERT OR IGNORE INTO user_wishlist (user_id, listing_id) VALUES (?, ?)", userID, req.ListingID); err != nil {
+			log.Printf("[WISHLIST] failed to save listing %d for user %d: %v", req.ListingID, userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "count": wishlistCount(userID)})
+
+	case http.MethodDelete:
+		listingID, err := strconv.ParseInt(r.URL.Query().Get("listing_id"), 10, 64)
+		if err != nil || listingID <= 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "listing_id must be positive"})
+			return
+		}
+		if _, err := db.Exec("DELETE FROM user_wishlist WHERE user_id = ? AND listing_id = ?", userID, listingID); err != nil {
+			log.Printf("[WISHLIST] failed to remove listing %d for user %d: %v", listingID, userID, err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "count": wishlistCount(userID)})
+
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// handleWishlistCount handles GET /api/wishlist/count for the badge.
+func handleWishlistCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]int{"count": wishlistCount(userID)})
+}
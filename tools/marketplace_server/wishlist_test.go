@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWishlistAddListRemove(t *testing.T) {
+	useTestDB(t)
+
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name) VALUES (2, 'sn', 'alice', 'Alice')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 1, 1, x'00', 'A', 'free', 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (101, 1, 1, x'00', 'B', 'free', 'published')")
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (102, 1, 1, x'00', 'C', 'free', 'pending')")
+
+	call := func(method, target, body string) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-User-ID", "2")
+		rec := httptest.NewRecorder()
+		handleWishlist(rec, req)
+		var out map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &out)
+		out["status"] = float64(rec.Code)
+		return out
+	}
+
+	call(http.MethodPost, "/api/wishlist", `{"listing_id":100}`)
+	call(http.MethodPost, "/api/wishlist", `{"listing_id":100}`) // saving twice is a no-op
+	if out := call(http.MethodPost, "/api/wishlist", `{"listing_id":101}`); out["count"] != float64(2) {
+		t.Fatalf("count after saving two packs = %v", out["count"])
+	}
+	if out := call(http.MethodPost, "/api/wishlist", `{"listing_id":102}`); out["status"] != float64(http.StatusNotFound) {
+		t.Fatalf("saving an unpublished pack: status %v", out["status"])
+	}
+
+	// A pack delisted after being saved drops out of the view.
+	mustExec(t, "UPDATE pack_listings SET status = 'delisted' WHERE id = 101")
+	out := call(http.MethodGet, "/api/wishlist", "")
+	if items, _ := out["items"].([]interface{}); len(items) != 1 || out["count"] != float64(1) {
+		t.Fatalf("list = %v", out)
+	}
+
+	call(http.MethodDelete, "/api/wishlist?listing_id=100", "")
+	removeListingFromWishlists(101)
+	var rows int
+	db.QueryRow("SELECT COUNT(*) FROM user_wishlist").Scan(&rows)
+	if rows != 0 {
+		t.Errorf("%d wishlist rows left after removal and cleanup", rows)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strconv"
+)
+
+// defaultMinWithdrawal is the minimum net payout (cash after fees) used when
+// the min_withdrawal setting is unset.
+const defaultMinWithdrawal = 100
+
+// withdrawalPaymentTypes are the payment types offered for withdrawals, in
+// display order. The legacy bank_card type is not offered anymore.
+var withdrawalPaymentTypes = []string{"paypal", "wechat", "alipay", "check", "wire_transfer", "bank_card_us", "bank_card_eu", "bank_card_cn"}
+
+// getMinWithdrawal returns the minimum net payout of a withdrawal. 0 disables
+// the minimum.
+func getMinWithdrawal() float64 {
+	value := getSetting("min_withdrawal")
+	if value == "" {
+		return defaultMinWithdrawal
+	}
+	min, err := strconv.ParseFloat(value, 64)
+	if err != nil || min < 0 {
+		return defaultMinWithdrawal
+	}
+	return min
+}
+
+// getWithdrawalFeeRate returns the fee rate of a payment type in percent.
+func getWithdrawalFeeRate(paymentType string) float64 {
+	rate, _ := strconv.ParseFloat(getSetting("fee_rate_"+paymentType), 64)
+	if rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// getWithdrawalFeeCap returns the maximum fee charged for one withdrawal of
+// a payment type, in cash. 0 (the default) means the fee is not capped.
+func getWithdrawalFeeCap(paymentType string) float64 {
+	feeCap, _ := strconv.ParseFloat(getSetting("fee_cap_"+paymentType), 64)
+	if feeCap < 0 {
+		return 0
+	}
+	return feeCap
+}
+
+// formatWithdrawalAmount renders a cash amount for error messages without
+// trailing zeros, e.g. 100 or 99.5.
+func formatWithdrawalAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCalculateWithdrawalFee(t *testing.T) {
+	tests := []struct {
+		credits, rate, feeRate, feeCap float64
+		wantFee, wantNet               float64
+	}{
+		{100, 1, 0, 0, 0, 100},
+		{100, 1, 2, 0, 2, 98},
+		{1000, 1, 2, 5, 5, 995}, // capped
+		{250, 1, 2, 5, 5, 245},  // fee exactly at the cap
+		{102.05, 1, 2, 0, 2.04, 100.01},
+		{33.333, 0.3, 3, 0, 0.3, 9.7}, // cash rounded to 10.00 first
+	}
+	for _, tt := range tests {
+		_, fee, net := calculateWithdrawalFee(tt.credits, tt.rate, tt.feeRate, tt.feeCap)
+		if fee != tt.wantFee || net != tt.wantNet {
+			t.Errorf("calculateWithdrawalFee(%v, %v, %v, %v) fee=%v net=%v, want %v %v",
+				tt.credits, tt.rate, tt.feeRate, tt.feeCap, fee, net, tt.wantFee, tt.wantNet)
+		}
+	}
+}
+
+func TestAuthorWithdrawLimits(t *testing.T) {
+	useTestDB(t)
+	mustExec(t, "INSERT INTO users (id, auth_type, auth_id, display_name, email) VALUES (1, 'email', 'a', 'Author', 'author@example.com')")
+	mustExec(t, "INSERT INTO email_wallets (email, credits_balance, email_verified) VALUES ('author@example.com', 150, 1)")
+	mustExec(t, `INSERT INTO user_payment_info (user_id, payment_type, payment_details) VALUES (1, 'paypal', '{"account":"author@example.com","username":"Author"}')`)
+	mustExec(t, "INSERT INTO pack_listings (id, user_id, category_id, file_data, pack_name, share_mode, status) VALUES (100, 1, 1, x'00', 'Pack', 'per_use', 'published')")
+	mustExec(t, "INSERT INTO credits_transactions (user_id, transaction_type, amount, listing_id) VALUES (5, 'purchase', -1000, 100)")
+	for key, value := range map[string]string{
+		"credit_cash_rate": "1", "revenue_split_publisher_pct": "100",
+		"fee_rate_paypal": "2", "fee_cap_paypal": "1", "min_withdrawal": "100",
+	} {
+		mustExec(t, "INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
+	}
+
+	withdraw := func(amount string) (ok bool, code string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/user/author/withdraw", strings.NewReader("credits_amount="+amount))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("X-User-ID", "1")
+		rec := httptest.NewRecorder()
+		handleAuthorWithdraw(rec, req)
+		var resp struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("withdraw %s: %v (%s)", amount, err, rec.Body.String())
+		}
+		return resp.OK, resp.Error
+	}
+
+	// 100.99 - 1 (capped fee) = 99.99, one cent below the minimum.
+	if ok, code := withdraw("100.99"); ok || code != "withdraw_below_minimum" {
+		t.Errorf("below minimum: ok=%v error=%q", ok, code)
+	}
+	if ok, code := withdraw("151"); ok || code != "withdraw_exceeds_wallet" {
+		t.Errorf("above wallet balance: ok=%v error=%q", ok, code)
+	}
+	if ok, code := withdraw("1001"); ok || code != "withdraw_exceeds_balance" {
+		t.Errorf("above unwithdrawn revenue: ok=%v error=%q", ok, code)
+	}
+	// 101 - 1 = 100, exactly the minimum.
+	if ok, code := withdraw("101"); !ok {
+		t.Fatalf("at minimum: error=%q", code)
+	}
+
+	var fee, net float64
+	db.QueryRow("SELECT fee_amount, net_amount FROM withdrawal_records WHERE user_id = 1").Scan(&fee, &net)
+	if fee != 1 || net != 100 {
+		t.Errorf("stored fee=%v net=%v, want 1 and 100", fee, net)
+	}
+	if balance := getWalletBalance(1); balance != 49 {
+		t.Errorf("wallet balance = %v, want 49", balance)
+	}
+}
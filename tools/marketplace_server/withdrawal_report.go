@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"marketplace_server/i18n"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// withdrawalPaymentTypeLabel returns the display name of a payment type,
+// falling back to the raw type for unknown values.
+func withdrawalPaymentTypeLabel(lang i18n.Lang, paymentType string) string {
+	switch paymentType {
+	case "paypal":
+		return "PayPal"
+	case "alipay":
+		return "AliPay"
+	case "wechat", "check", "bank_card", "wire_transfer", "bank_card_us", "bank_card_eu", "bank_card_cn":
+		return i18n.T(lang, "excel_"+paymentType)
+	}
+	return paymentType
+}
+
+// payoutPlainFields are payment detail fields that identify the payee or the
+// bank without exposing an account, so reports show them unmasked.
+var payoutPlainFields = map[string]bool{
+	"username": true, "account_holder": true, "legal_name": true, "real_name": true, "beneficiary_name": true,
+	"full_legal_name": true, "bank_name": true, "bank_branch": true, "account_type": true, "swift_code": true,
+	"bic_swift": true, "province": true, "city": true,
+}
+
+// payoutTailFields are account identifiers of which only the last four
+// characters are shown. Any field not listed here or in payoutPlainFields is
+// masked completely.
+var payoutTailFields = map[string]bool{
+	"account": true, "card_number": true, "account_number": true, "iban": true, "routing_number": true, "phone": true,
+}
+
+// maskWithdrawalPaymentDetails renders a withdrawal's payment_details JSON as
+// "field: value" pairs with account numbers and addresses masked, so the
+// reconciliation report cannot be used to send payouts.
+func maskWithdrawalPaymentDetails(paymentType, detailsJSON string) string {
+	var details map[string]string
+	if err := json.Unmarshal([]byte(detailsJSON), &details); err != nil || len(details) == 0 {
+		return ""
+	}
+	fields := requiredFieldsByPaymentType[paymentType]
+	if len(fields) == 0 {
+		for k := range details {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+	}
+	var parts []string
+	for _, field := range fields {
+		value := strings.TrimSpace(details[field])
+		if value == "" {
+			continue
+		}
+		switch {
+		case payoutPlainFields[field]:
+		case payoutTailFields[field]:
+			value = maskAccountTail(value)
+		default:
+			value = "****"
+		}
+		parts = append(parts, field+": "+value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// maskAccountTail keeps the last four characters of an account identifier.
+// Email addresses keep their first character and domain instead.
+func maskAccountTail(value string) string {
+	if at := strings.LastIndex(value, "@"); at > 0 {
+		return value[:1] + "***" + value[at:]
+	}
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return "****"
+	}
+	return "****" + string(runes[len(runes)-4:])
+}
+
+// withdrawalReportTotal accumulates the totals row of one payment type.
+type withdrawalReportTotal struct {
+	Count                   int
+	Credits, Cash, Fee, Net float64
+}
+
+// handleAdminWithdrawalReport exports withdrawal records for reconciliation.
+// GET /admin/api/withdrawals/report?status=pending|paid&date_from=YYYY-MM-DD&date_to=YYYY-MM-DD
+// The date range applies to the request date and is inclusive. Payment
+// details are masked, and a totals row per payment type follows the records.
+func handleAdminWithdrawalReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	q := r.URL.Query()
+	var conditions []string
+	var args []interface{}
+	if status := q.Get("status"); status != "" {
+		if status != "pending" && status != "paid" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+			return
+		}
+		conditions = append(conditions, "w.status = ?")
+		args = append(args, status)
+	}
+	for _, p := range []struct{ param, cond string }{
+		{"date_from", "date(w.created_at) >= ?"},
+		{"date_to", "date(w.created_at) <= ?"},
+	} {
+		d := strings.TrimSpace(q.Get(p.param))
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid " + p.param})
+			return
+		}
+		conditions = append(conditions, p.cond)
+		args = append(args, d)
+	}
+
+	query := `SELECT w.id, w.user_id, COALESCE(w.display_name, ''), COALESCE(u.email, ''), w.credits_amount, w.cash_rate,
+		w.cash_amount, COALESCE(w.payment_type, ''), COALESCE(w.payment_details, ''), COALESCE(w.fee_rate, 0),
+		COALESCE(w.fee_amount, 0), COALESCE(w.net_amount, 0), COALESCE(w.status, ''), w.created_at, COALESCE(w.paid_at, '')
+		FROM withdrawal_records w
+		LEFT JOIN users u ON u.id = w.user_id`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY w.created_at, w.id"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("[WITHDRAW-REPORT] query failed: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal_error"})
+		return
+	}
+	defer rows.Close()
+
+	lang := i18n.DetectLang(r)
+	f := excelize.NewFile()
+	defer f.Close()
+	sheetName := i18n.T(lang, "excel_withdraw_sheet")
+	f.SetSheetName("Sheet1", sheetName)
+	writeRow := func(row int, cells []interface{}) {
+		for i, val := range cells {
+			cell, _ := excelize.CoordinatesToCellName(i+1, row)
+			f.SetCellValue(sheetName, cell, val)
+		}
+	}
+
+	writeRow(1, []interface{}{
+		i18n.T(lang, "excel_withdraw_id"), i18n.T(lang, "excel_user_id"), i18n.T(lang, "excel_author_name"),
+		i18n.T(lang, "excel_email"), i18n.T(lang, "excel_payment_method"), i18n.T(lang, "excel_payment_detail"),
+		i18n.T(lang, "excel_withdraw_credits"), i18n.T(lang, "excel_cash_rate"), i18n.T(lang, "excel_withdraw_amount"),
+		i18n.T(lang, "excel_fee_rate"), i18n.T(lang, "excel_fee_amount"), i18n.T(lang, "excel_net_amount"),
+		i18n.T(lang, "excel_status"), i18n.T(lang, "excel_requested_at"), i18n.T(lang, "excel_paid_at"),
+	})
+
+	totals := map[string]*withdrawalReportTotal{}
+	row := 2
+	for rows.Next() {
+		var wr WithdrawalRequest
+		var email, paidAt string
+		if err := rows.Scan(&wr.ID, &wr.UserID, &wr.DisplayName, &email, &wr.CreditsAmount, &wr.CashRate,
+			&wr.CashAmount, &wr.PaymentType, &wr.PaymentDetails, &wr.FeeRate,
+			&wr.FeeAmount, &wr.NetAmount, &wr.Status, &wr.CreatedAt, &paidAt); err != nil {
+			log.Printf("[WITHDRAW-REPORT] scan error: %v", err)
+			continue
+		}
+		status := wr.Status
+		if status == "pending" || status == "paid" {
+			status = i18n.T(lang, "excel_status_"+status)
+		}
+		writeRow(row, []interface{}{
+			wr.ID, wr.UserID, wr.DisplayName, email, withdrawalPaymentTypeLabel(lang, wr.PaymentType),
+			maskWithdrawalPaymentDetails(wr.PaymentType, wr.PaymentDetails),
+			wr.CreditsAmount, wr.CashRate, wr.CashAmount, fmt.Sprintf("%.2f%%", wr.FeeRate*100), wr.FeeAmount, wr.NetAmount,
+			status, exportTimestamp(wr.CreatedAt), exportTimestamp(paidAt),
+		})
+		row++
+
+		t := totals[wr.PaymentType]
+		if t == nil {
+			t = &withdrawalReportTotal{}
+			totals[wr.PaymentType] = t
+		}
+		t.Count++
+		t.Credits += wr.CreditsAmount
+		t.Cash += wr.CashAmount
+		t.Fee += wr.FeeAmount
+		t.Net += wr.NetAmount
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("[WITHDRAW-REPORT] rows iteration error: %v", err)
+	}
+
+	paymentTypes := make([]string, 0, len(totals))
+	for pt := range totals {
+		paymentTypes = append(paymentTypes, pt)
+	}
+	sort.Strings(paymentTypes)
+	row++ // blank line between the records and the totals
+	for _, pt := range paymentTypes {
+		t := totals[pt]
+		writeRow(row, []interface{}{
+			i18n.T(lang, "excel_total"), "", "", "", withdrawalPaymentTypeLabel(lang, pt),
+			fmt.Sprintf(i18n.T(lang, "excel_withdraw_count"), t.Count),
+			t.Credits, "", t.Cash, "", t.Fee, t.Net,
+		})
+		row++
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		log.Printf("[WITHDRAW-REPORT] write Excel error: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate Excel file"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="withdrawal_report_%s.xlsx"`, time.Now().Format("20060102")))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestMaskWithdrawalPaymentDetails(t *testing.T) {
+	got := maskWithdrawalPaymentDetails("bank_card_us", `{"legal_name":"Alice","routing_number":"021000021","account_number":"123456789","account_type":"checking"}`)
+	want := "legal_name: Alice, routing_number: ****0021, account_number: ****6789, account_type: checking"
+	if got != want {
+		t.Errorf("bank_card_us = %q, want %q", got, want)
+	}
+	if got := maskWithdrawalPaymentDetails("paypal", `{"account":"alice@example.com","username":"Alice"}`); got != "account: a***@example.com, username: Alice" {
+		t.Errorf("paypal = %q", got)
+	}
+	if got := maskWithdrawalPaymentDetails("check", `{"full_legal_name":"Alice","street_address":"1 Main St"}`); got != "full_legal_name: Alice, street_address: ****" {
+		t.Errorf("check = %q", got)
+	}
+}
+
+func TestAdminWithdrawalReport(t *testing.T) {
+	useTestDB(t)
+	insert := func(paymentType, details, status, createdAt string, credits, net float64) {
+		t.Helper()
+		mustExec(t, `INSERT INTO withdrawal_records (user_id, credits_amount, cash_rate, cash_amount, payment_type, payment_details,
+			fee_rate, fee_amount, net_amount, status, display_name, created_at) VALUES (1, ?, 1, ?, ?, ?, 0.02, ?, ?, ?, 'Author', ?)`,
+			credits, credits, paymentType, details, credits-net, net, status, createdAt)
+	}
+	insert("paypal", `{"account":"alice@example.com","username":"Alice"}`, "paid", "2026-03-01 10:00:00", 100, 98)
+	insert("paypal", `{"account":"alice@example.com","username":"Alice"}`, "paid", "2026-03-05 10:00:00", 200, 196)
+	insert("bank_card_cn", `{"real_name":"A","card_number":"6222020200112233","bank_branch":"X"}`, "paid", "2026-03-06 10:00:00", 50, 49)
+	insert("paypal", `{"account":"alice@example.com","username":"Alice"}`, "pending", "2026-03-07 10:00:00", 70, 68)
+	insert("paypal", `{"account":"alice@example.com","username":"Alice"}`, "paid", "2026-04-01 10:00:00", 10, 9)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/withdrawals/report?status=paid&date_from=2026-03-01&date_to=2026-03-31", nil)
+	rec := httptest.NewRecorder()
+	handleAdminWithdrawalReport(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Header, three records, blank line, one totals row per payment type.
+	if len(rows) != 7 {
+		t.Fatalf("rows = %d, want 7: %v", len(rows), rows)
+	}
+	for _, row := range rows[1:4] {
+		if strings.Contains(strings.Join(row, "|"), "alice@example.com") || strings.Contains(strings.Join(row, "|"), "6222020200112233") {
+			t.Errorf("unmasked payment details in %v", row)
+		}
+	}
+	paypalTotal := rows[6]
+	if paypalTotal[4] != "PayPal" || paypalTotal[6] != "300" || paypalTotal[11] != "294" {
+		t.Errorf("PayPal totals row = %v", paypalTotal)
+	}
+	if cnTotal := rows[5]; cnTotal[6] != "50" || cnTotal[11] != "49" {
+		t.Errorf("bank_card_cn totals row = %v", cnTotal)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/api/withdrawals/report?date_from=2026-13-01", nil)
+	rec = httptest.NewRecorder()
+	handleAdminWithdrawalReport(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid date: status %d, want 400", rec.Code)
+	}
+}